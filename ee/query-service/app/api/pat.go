@@ -46,6 +46,7 @@ func (ah *APIHandler) createPAT(w http.ResponseWriter, r *http.Request) {
 		Name:      req.Name,
 		Role:      req.Role,
 		ExpiresAt: req.ExpiresInDays,
+		Scopes:    req.Scopes,
 	}
 	err = validatePATRequest(pat)
 	if err != nil {
@@ -85,6 +86,9 @@ func validatePATRequest(req model.PAT) error {
 	if req.Name == "" {
 		return fmt.Errorf("valid name is required")
 	}
+	if err := auth.ValidateScopes(req.Scopes); err != nil {
+		return err
+	}
 	return nil
 }
 
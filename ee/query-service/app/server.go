@@ -45,8 +45,10 @@ import (
 	"go.signoz.io/signoz/pkg/query-service/app/opamp"
 	opAmpModel "go.signoz.io/signoz/pkg/query-service/app/opamp/model"
 	"go.signoz.io/signoz/pkg/query-service/app/preferences"
+	"go.signoz.io/signoz/pkg/query-service/app/rulesgrpc"
 	"go.signoz.io/signoz/pkg/query-service/cache"
 	baseconst "go.signoz.io/signoz/pkg/query-service/constants"
+	basedao "go.signoz.io/signoz/pkg/query-service/dao"
 	"go.signoz.io/signoz/pkg/query-service/healthcheck"
 	basealm "go.signoz.io/signoz/pkg/query-service/integrations/alertManager"
 	baseint "go.signoz.io/signoz/pkg/query-service/interfaces"
@@ -56,6 +58,7 @@ import (
 	"go.signoz.io/signoz/pkg/query-service/telemetry"
 	"go.signoz.io/signoz/pkg/query-service/utils"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 )
 
 const AppDbEngine = "sqlite"
@@ -76,6 +79,9 @@ type ServerOptions struct {
 	FluxInterval      string
 	Cluster           string
 	GatewayUrl        string
+	// RulesGRPCHostPort is the address of the grpc API of the rules, it is
+	// not served when empty
+	RulesGRPCHostPort string
 }
 
 // Server runs HTTP api service
@@ -91,6 +97,10 @@ type Server struct {
 	privateConn net.Listener
 	privateHTTP *http.Server
 
+	// grpc API of the rules
+	rulesGRPCConn net.Listener
+	rulesGRPC     *grpc.Server
+
 	// Usage manager
 	usageManager *usage.Manager
 
@@ -295,6 +305,10 @@ func NewServer(serverOptions *ServerOptions) (*Server, error) {
 
 	s.privateHTTP = privateServer
 
+	if serverOptions.RulesGRPCHostPort != "" {
+		s.rulesGRPC = rulesgrpc.NewServer(rm, userFromRequest(apiHandler))
+	}
+
 	s.opampServer = opamp.InitializeServer(
 		&opAmpModel.AllAgents, agentConfMgr,
 	)
@@ -329,12 +343,9 @@ func (s *Server) createPrivateServer(apiHandler *api.APIHandler) (*http.Server,
 	}, nil
 }
 
-func (s *Server) createPublicServer(apiHandler *api.APIHandler) (*http.Server, error) {
-
-	r := baseapp.NewRouter()
-
-	// add auth middleware
-	getUserFromRequest := func(r *http.Request) (*basemodel.UserPayload, error) {
+// userFromRequest returns the authentication of the users of the public APIs
+func userFromRequest(apiHandler *api.APIHandler) func(r *http.Request) (*basemodel.UserPayload, error) {
+	return func(r *http.Request) (*basemodel.UserPayload, error) {
 		user, err := auth.GetUserFromRequest(r, apiHandler)
 
 		if err != nil {
@@ -347,7 +358,14 @@ func (s *Server) createPublicServer(apiHandler *api.APIHandler) (*http.Server, e
 
 		return user, nil
 	}
-	am := baseapp.NewAuthMiddleware(getUserFromRequest)
+}
+
+func (s *Server) createPublicServer(apiHandler *api.APIHandler) (*http.Server, error) {
+
+	r := baseapp.NewRouter()
+
+	// add auth middleware
+	am := baseapp.NewAuthMiddleware(userFromRequest(apiHandler))
 
 	r.Use(baseapp.LogCommentEnricher)
 	r.Use(setTimeoutMiddleware)
@@ -361,6 +379,8 @@ func (s *Server) createPublicServer(apiHandler *api.APIHandler) (*http.Server, e
 	apiHandler.RegisterQueryRangeV4Routes(r, am)
 	apiHandler.RegisterWebSocketPaths(r, am)
 	apiHandler.RegisterMessagingQueuesRoutes(r, am)
+	apiHandler.RegisterAlertmanagerV2Routes(r, am)
+	apiHandler.RegisterSlackRoutes(r, am)
 
 	c := cors.New(cors.Options{
 		AllowedOrigins: []string{"*"},
@@ -619,6 +639,14 @@ func (s *Server) initListeners() error {
 	}
 	zap.L().Info(fmt.Sprintf("Query server started listening on private port %s...", s.serverOptions.PrivateHostPort))
 
+	if s.rulesGRPC != nil {
+		s.rulesGRPCConn, err = net.Listen("tcp", s.serverOptions.RulesGRPCHostPort)
+		if err != nil {
+			return err
+		}
+		zap.L().Info(fmt.Sprintf("Query server started listening on rules grpc port %s...", s.serverOptions.RulesGRPCHostPort))
+	}
+
 	return nil
 }
 
@@ -683,6 +711,17 @@ func (s *Server) Start() error {
 
 	}()
 
+	if s.rulesGRPC != nil {
+		go func() {
+			zap.L().Info("Starting rules gRPC server", zap.String("addr", s.serverOptions.RulesGRPCHostPort))
+
+			if err := s.rulesGRPC.Serve(s.rulesGRPCConn); err != nil && err != grpc.ErrServerStopped {
+				zap.L().Error("Could not start rules gRPC server", zap.Error(err))
+				s.unavailableChannel <- healthcheck.Unavailable
+			}
+		}()
+	}
+
 	go func() {
 		zap.L().Info("Starting OpAmp Websocket server", zap.String("addr", baseconst.OpAmpWsEndpoint))
 		err := s.opampServer.Start(baseconst.OpAmpWsEndpoint)
@@ -708,6 +747,10 @@ func (s *Server) Stop() error {
 		}
 	}
 
+	if s.rulesGRPC != nil {
+		s.rulesGRPC.GracefulStop()
+	}
+
 	s.opampServer.Stop()
 
 	if s.ruleManager != nil {
@@ -742,12 +785,21 @@ func makeRulesManager(
 		AlertManagerURLs: []string{alertManagerURL},
 	}
 
+	// the rules are stored in postgres when it is set so that the
+	// replicas do not depend on a single-node sqlite file
+	if dsn := baseconst.GetRulesDBDSN(); dsn != "" {
+		if db, err = rules.OpenPostgres(dsn); err != nil {
+			return nil, err
+		}
+	}
+
 	// create manager opts
 	managerOpts := &rules.ManagerOptions{
 		NotifierOpts: notifierOpts,
 		Queriers: &rules.Queriers{
 			PqlEngine: pqle,
 			Ch:        ch.GetConn(),
+			Cache:     rules.NewQueryCache(baseconst.GetRuleQueryCacheTTL()),
 		},
 		RepoURL:      ruleRepoURL,
 		DBConn:       db,
@@ -757,6 +809,128 @@ func makeRulesManager(
 		FeatureFlags: fm,
 		Reader:       ch,
 		EvalDelay:    baseconst.GetEvalDelay(),
+		EvalWorkers:  baseconst.GetRuleEvalWorkers(),
+		EvalTimeout:  baseconst.GetRuleEvalTimeout(),
+
+		EvalTenantWorkers:   baseconst.GetRuleEvalTenantWorkers(),
+		OwnerTeamLabel:      baseconst.GetRuleOwnerTeamLabel(),
+		ChaosChannels:       baseconst.GetRuleChaosChannels(),
+		EvalCatchUpLookback: baseconst.GetRuleEvalCatchUpLookback(),
+		EvalLogSize:         baseconst.GetRuleEvalLogSize(),
+		QueryCostWarnRows:   baseconst.GetRuleQueryCostWarnRows(),
+		QueryCostMaxRows:    baseconst.GetRuleQueryCostMaxRows(),
+		MaxActiveAlerts:     baseconst.GetRuleMaxActiveAlerts(),
+		MaxSeries:           baseconst.GetRuleMaxSeries(),
+		TraceExemplars:      baseconst.GetRuleTraceExemplars(),
+		LogSamples:          baseconst.GetRuleLogSamples(),
+		ClickHouseSandbox: &rules.ClickHouseSandbox{
+			MaxExecutionTime: baseconst.GetRuleClickHouseMaxExecutionTime(),
+			MaxRowsToRead:    baseconst.GetRuleClickHouseMaxRowsToRead(),
+			MaxMemoryUsage:   baseconst.GetRuleClickHouseMaxMemoryUsage(),
+			ReadOnly:         baseconst.IsRuleClickHouseReadOnlyEnabled(),
+			Profile:          baseconst.GetRuleClickHouseProfile(),
+		},
+		WarmUp:       baseconst.GetRuleWarmUp(),
+		Downsampling: baseconst.IsRuleDownsamplingEnabled(),
+		StateHistoryRetention: rules.StateHistoryRetention{
+			Detail:      rules.Duration(baseconst.GetRuleStateHistoryDetailRetention()),
+			Transitions: rules.Duration(baseconst.GetRuleStateHistoryTransitionsRetention()),
+		},
+		StaleRules: rules.StaleRuleOptions{
+			After:       baseconst.GetRuleStaleAfter(),
+			AutoDisable: baseconst.IsRuleStaleAutoDisableEnabled(),
+		},
+		Incidents: rules.IncidentOptions{
+			AutoSeverities: baseconst.GetRuleIncidentAutoSeverities(),
+			Window:         baseconst.GetRuleIncidentWindow(),
+		},
+		Correlation: rules.CorrelationOptions{
+			Window:   baseconst.GetRuleCorrelationWindow(),
+			Annotate: baseconst.IsRuleCorrelationAnnotateEnabled(),
+		},
+	}
+
+	warmUpMode, err := rules.ParseWarmUpMode(baseconst.GetRuleWarmUpMode())
+	if err != nil {
+		return nil, err
+	}
+	managerOpts.WarmUpMode = warmUpMode
+
+	if path := baseconst.GetRuleQueryBackendsConfig(); path != "" {
+		if err := managerOpts.Queriers.RegisterBackends(path); err != nil {
+			return nil, err
+		}
+	}
+
+	managerOpts.Events = rules.NewEventBus()
+	if path := baseconst.GetRuleEventSinksConfig(); path != "" {
+		if err := managerOpts.Events.RegisterSinks(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if path := baseconst.GetRuleTeamQuotasConfig(); path != "" {
+		quotas, err := rules.LoadTeamQuotas(path)
+		if err != nil {
+			return nil, err
+		}
+		managerOpts.TeamQuotas = quotas
+	}
+
+	if path := baseconst.GetRuleExternalAlertmanagersConfig(); path != "" {
+		externals, err := basealm.LoadExternalAlertmanagers(path, managerOpts.NotifierOpts.QueueCapacity)
+		if err != nil {
+			return nil, err
+		}
+		managerOpts.ExternalAlertmanagers = externals
+	}
+
+	if endpoint := baseconst.GetRuleRemoteWriteURL(); endpoint != "" {
+		username, password := baseconst.GetRuleRemoteWriteBasicAuth()
+		writer, err := rules.NewRemoteWriter(rules.RemoteWriteOptions{
+			URL:      endpoint,
+			Username: username,
+			Password: password,
+		})
+		if err != nil {
+			return nil, err
+		}
+		managerOpts.RemoteWrite = writer
+	}
+
+	if dir := baseconst.GetRuleAlertSpillDir(); dir != "" {
+		spill, err := rules.NewDiskAlertSpill(dir)
+		if err != nil {
+			return nil, err
+		}
+		managerOpts.AlertSpill = spill
+	}
+
+	if channel := baseconst.GetRuleDigestChannel(); channel != "" {
+		weekday, err := rules.ParseWeekday(baseconst.GetRuleDigestWeekday())
+		if err != nil {
+			return nil, err
+		}
+		managerOpts.AlertDigest = &rules.AlertDigestOptions{
+			Channel:   channel,
+			Weekday:   weekday,
+			Hour:      baseconst.GetRuleDigestHour(),
+			TeamLabel: baseconst.GetRuleDigestTeamLabel(),
+		}
+	}
+
+	if baseconst.IsRuleShardingEnabled() {
+		managerOpts.Sharding = &rules.ShardOptions{
+			ReplicaID: baseconst.GetRuleReplicaID(),
+		}
+	}
+
+	// the objects stored before they were scoped by org belong to the
+	// only org of the install
+	if orgs, apiErr := basedao.DB().GetOrgs(context.Background()); apiErr != nil {
+		zap.L().Error("failed to get the orgs", zap.Error(apiErr.Err))
+	} else if len(orgs) == 1 {
+		managerOpts.LegacyOrg = orgs[0].Id
 	}
 
 	// create Manager
@@ -43,8 +43,9 @@ func GetUserFromRequest(r *http.Request, apiHandler *api.APIHandler) (*basemodel
 			user.User.GroupId = group.Id
 			user.User.Id = pat.Id
 			return &basemodel.UserPayload{
-				User: user.User,
-				Role: pat.Role,
+				User:   user.User,
+				Role:   pat.Role,
+				Scopes: pat.Scopes,
 			}, nil
 		}
 		if err != nil {
@@ -63,8 +63,10 @@ func (m *modelDao) createUserForSAMLRequest(ctx context.Context, email string) (
 }
 
 // PrepareSsoRedirect prepares redirect page link after SSO response
-// is successfully parsed (i.e. valid email is available)
-func (m *modelDao) PrepareSsoRedirect(ctx context.Context, redirectUri, email string) (redirectURL string, apierr basemodel.BaseApiError) {
+// is successfully parsed (i.e. valid email is available). The teams
+// mapped from the groups of the user replace the teams of the user,
+// unless they are nil.
+func (m *modelDao) PrepareSsoRedirect(ctx context.Context, redirectUri, email string, teams []string) (redirectURL string, apierr basemodel.BaseApiError) {
 
 	userPayload, apierr := m.GetUserByEmail(ctx, email)
 	if !apierr.IsNil() {
@@ -85,6 +87,14 @@ func (m *modelDao) PrepareSsoRedirect(ctx context.Context, redirectUri, email st
 		user = &userPayload.User
 	}
 
+	if teams != nil {
+		if apiErr := m.UpdateUserTeams(ctx, user.Id, teams); apiErr != nil {
+			zap.L().Error("failed to update the teams of the SSO login user", zap.Error(apiErr.Err))
+			return "", model.InternalErrorStr("failed to update the teams of the user")
+		}
+		user.Teams = teams
+	}
+
 	tokenStore, err := baseauth.GenerateJWTForUser(user)
 	if err != nil {
 		zap.L().Error("failed to generate token for SSO login user", zap.Error(err))
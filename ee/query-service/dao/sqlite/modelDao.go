@@ -136,6 +136,12 @@ func InitDB(dataSourceName string) (*modelDao, error) {
 			return nil, fmt.Errorf("error in adding column: %v", err.Error())
 		}
 	}
+	if !columnExists(m.DB(), "personal_access_tokens", "scopes") {
+		_, err = m.DB().Exec("ALTER TABLE personal_access_tokens ADD COLUMN scopes TEXT NOT NULL DEFAULT '[]';")
+		if err != nil {
+			return nil, fmt.Errorf("error in adding column: %v", err.Error())
+		}
+	}
 	return m, nil
 }
 
@@ -10,7 +10,10 @@ import (
 	"syscall"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 	"go.signoz.io/signoz/ee/query-service/app"
 	"go.signoz.io/signoz/pkg/query-service/auth"
@@ -77,6 +80,25 @@ func initZapLog(enableQueryServiceLogOTLPExport bool) *zap.Logger {
 	return logger
 }
 
+// initTracerProvider exports the spans of the query service, e.g. of the
+// rule evaluations, to the OTLP endpoint
+func initTracerProvider(res *resource.Resource) (*sdktrace.TracerProvider, error) {
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithInsecure()}
+	if baseconst.OTLPTarget != "" {
+		opts = append(opts, otlptracegrpc.WithEndpoint(baseconst.OTLPTarget))
+	}
+	exporter, err := otlptracegrpc.New(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	return tp, nil
+}
+
 func main() {
 	var promConfigPath, skipTopLvlOpsPath string
 
@@ -95,6 +117,7 @@ func main() {
 	var maxOpenConns int
 	var dialTimeout time.Duration
 	var gatewayUrl string
+	var rulesGRPCHostPort string
 
 	flag.StringVar(&promConfigPath, "config", "./config/prometheus.yml", "(prometheus config to read metrics)")
 	flag.StringVar(&skipTopLvlOpsPath, "skip-top-level-ops", "", "(config file to skip top level operations)")
@@ -109,6 +132,7 @@ func main() {
 	flag.BoolVar(&enableQueryServiceLogOTLPExport, "enable.query.service.log.otlp.export", false, "(enable query service log otlp export)")
 	flag.StringVar(&cluster, "cluster", "cluster", "(cluster name - defaults to 'cluster')")
 	flag.StringVar(&gatewayUrl, "gateway-url", "", "(url to the gateway)")
+	flag.StringVar(&rulesGRPCHostPort, "rules.grpc-addr", "", "(address to serve the grpc API of the rules, disabled when empty)")
 
 	flag.Parse()
 
@@ -119,6 +143,18 @@ func main() {
 
 	version.PrintVersion()
 
+	if baseconst.IsRuleTracingEnabled() {
+		tp, err := initTracerProvider(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String("query-service"),
+		))
+		if err != nil {
+			zap.L().Error("Failed to initialize the tracer provider", zap.Error(err))
+		} else {
+			defer tp.Shutdown(context.Background())
+		}
+	}
+
 	serverOptions := &app.ServerOptions{
 		HTTPHostPort:      baseconst.HTTPHostPort,
 		PromConfigPath:    promConfigPath,
@@ -134,6 +170,7 @@ func main() {
 		FluxInterval:      fluxInterval,
 		Cluster:           cluster,
 		GatewayUrl:        gatewayUrl,
+		RulesGRPCHostPort: rulesGRPCHostPort,
 	}
 
 	// Read the jwt secret key
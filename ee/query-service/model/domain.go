@@ -33,9 +33,19 @@ type OrgDomain struct {
 	SamlConfig       *SamlConfig        `json:"samlConfig"`
 	GoogleAuthConfig *GoogleOAuthConfig `json:"googleAuthConfig"`
 
+	// TeamMappings map the groups of the identity provider to the teams
+	// of the users logging in through the domain
+	TeamMappings []GroupTeam `json:"teamMappings,omitempty"`
+
 	Org *basemodel.Organization
 }
 
+// GroupTeam maps a group of the identity provider to a team
+type GroupTeam struct {
+	Group string `json:"group"`
+	Team  string `json:"team"`
+}
+
 func (od *OrgDomain) String() string {
 	return fmt.Sprintf("[%s]%s-%s ", od.Name, od.Id.String(), od.SsoType)
 }
@@ -100,6 +110,33 @@ func (od *OrgDomain) GetSAMLCert() string {
 	return ""
 }
 
+func (od *OrgDomain) GetSAMLGroupsAttribute() string {
+	if od.SamlConfig != nil && od.SamlConfig.GroupsAttribute != "" {
+		return od.SamlConfig.GroupsAttribute
+	}
+	return "groups"
+}
+
+// TeamsOf maps the groups of the identity provider to the teams of the
+// user. It returns nil when the domain has no mappings, so that the teams
+// set by hand are kept.
+func (od *OrgDomain) TeamsOf(groups []string) []string {
+	if len(od.TeamMappings) == 0 {
+		return nil
+	}
+	teams := []string{}
+	seen := map[string]bool{}
+	for _, group := range groups {
+		for _, m := range od.TeamMappings {
+			if strings.EqualFold(m.Group, group) && !seen[m.Team] {
+				seen[m.Team] = true
+				teams = append(teams, m.Team)
+			}
+		}
+	}
+	return teams
+}
+
 // PrepareGoogleOAuthProvider creates GoogleProvider that is used in
 // requesting OAuth and also used in processing response from google
 func (od *OrgDomain) PrepareGoogleOAuthProvider(siteUrl *url.URL) (sso.OAuthCallbackProvider, error) {
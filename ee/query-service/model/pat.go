@@ -1,5 +1,10 @@
 package model
 
+import (
+	"database/sql/driver"
+	"encoding/json"
+)
+
 type User struct {
 	Id                string `json:"id" db:"id"`
 	Name              string `json:"name" db:"name"`
@@ -13,20 +18,45 @@ type CreatePATRequestBody struct {
 	Name          string `json:"name"`
 	Role          string `json:"role"`
 	ExpiresInDays int64  `json:"expiresInDays"`
+	// Scopes limits the token to the APIs of the scopes, e.g. rules:read,
+	// the token reaches all the APIs of its role without scopes
+	Scopes PATScopes `json:"scopes"`
+}
+
+// PATScopes are the scopes of a PAT, stored as a json list
+type PATScopes []string
+
+func (s *PATScopes) Scan(src interface{}) error {
+	switch data := src.(type) {
+	case []byte:
+		return json.Unmarshal(data, s)
+	case string:
+		return json.Unmarshal([]byte(data), s)
+	}
+	return nil
+}
+
+func (s PATScopes) Value() (driver.Value, error) {
+	if s == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal([]string(s))
+	return string(b), err
 }
 
 type PAT struct {
-	Id              string `json:"id" db:"id"`
-	UserID          string `json:"userId" db:"user_id"`
-	CreatedByUser   User   `json:"createdByUser"`
-	UpdatedByUser   User   `json:"updatedByUser"`
-	Token           string `json:"token" db:"token"`
-	Role            string `json:"role" db:"role"`
-	Name            string `json:"name" db:"name"`
-	CreatedAt       int64  `json:"createdAt" db:"created_at"`
-	ExpiresAt       int64  `json:"expiresAt" db:"expires_at"`
-	UpdatedAt       int64  `json:"updatedAt" db:"updated_at"`
-	LastUsed        int64  `json:"lastUsed" db:"last_used"`
-	Revoked         bool   `json:"revoked" db:"revoked"`
-	UpdatedByUserID string `json:"updatedByUserId" db:"updated_by_user_id"`
+	Id              string    `json:"id" db:"id"`
+	UserID          string    `json:"userId" db:"user_id"`
+	CreatedByUser   User      `json:"createdByUser"`
+	UpdatedByUser   User      `json:"updatedByUser"`
+	Token           string    `json:"token" db:"token"`
+	Role            string    `json:"role" db:"role"`
+	Name            string    `json:"name" db:"name"`
+	CreatedAt       int64     `json:"createdAt" db:"created_at"`
+	ExpiresAt       int64     `json:"expiresAt" db:"expires_at"`
+	UpdatedAt       int64     `json:"updatedAt" db:"updated_at"`
+	LastUsed        int64     `json:"lastUsed" db:"last_used"`
+	Revoked         bool      `json:"revoked" db:"revoked"`
+	UpdatedByUserID string    `json:"updatedByUserId" db:"updated_by_user_id"`
+	Scopes          PATScopes `json:"scopes" db:"scopes"`
 }
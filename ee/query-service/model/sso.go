@@ -15,6 +15,9 @@ type SamlConfig struct {
 	SamlEntity string `json:"samlEntity"`
 	SamlIdp    string `json:"samlIdp"`
 	SamlCert   string `json:"samlCert"`
+	// GroupsAttribute is the attribute of the assertion holding the
+	// groups of the user, "groups" when empty
+	GroupsAttribute string `json:"groupsAttribute,omitempty"`
 }
 
 // GoogleOauthConfig contains a generic config to support oauth 
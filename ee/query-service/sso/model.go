@@ -11,6 +11,7 @@ type SSOIdentity struct {
 	PreferredUsername string
 	Email             string
 	EmailVerified     bool
+	Groups            []string
 	ConnectorData []byte
 }
 
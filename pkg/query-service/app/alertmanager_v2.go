@@ -0,0 +1,153 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go.signoz.io/signoz/pkg/query-service/common"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.signoz.io/signoz/pkg/query-service/rules"
+	"go.signoz.io/signoz/pkg/query-service/version"
+	"go.uber.org/zap"
+)
+
+// RegisterAlertmanagerV2Routes serves the Alertmanager v2 API over the
+// alerts of the rules, the maintenances and the channels. The responses
+// are not wrapped in the SigNoz envelope, the clients of Alertmanager
+// expect the bare models.
+func (aH *APIHandler) RegisterAlertmanagerV2Routes(router *mux.Router, am *AuthMiddleware) {
+	router.HandleFunc("/api/v2/alerts", am.ViewAccess(aH.getAMv2Alerts)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v2/alerts", am.EditAccess(aH.postAMv2Alerts)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v2/silences", am.ViewAccess(aH.getAMv2Silences)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v2/silences", am.EditAccess(aH.postAMv2Silence)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v2/silence/{id}", am.ViewAccess(aH.getAMv2Silence)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v2/silence/{id}", am.EditAccess(aH.deleteAMv2Silence)).Methods(http.MethodDelete)
+	router.HandleFunc("/api/v2/receivers", am.ViewAccess(aH.getAMv2Receivers)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v2/status", am.ViewAccess(aH.getAMv2Status)).Methods(http.MethodGet)
+}
+
+// respondAMv2Error writes the error as Alertmanager does, a json string
+// with the status of the error
+func respondAMv2Error(w http.ResponseWriter, apiErr *model.ApiError) {
+	code := http.StatusInternalServerError
+	switch apiErr.Typ {
+	case model.ErrorBadData:
+		code = http.StatusBadRequest
+	case model.ErrorNotFound:
+		code = http.StatusNotFound
+	}
+	b, _ := json.Marshal(apiErr.Err.Error())
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if n, err := w.Write(b); err != nil {
+		zap.L().Error("error writing response", zap.Int("bytesWritten", n), zap.Error(err))
+	}
+}
+
+func (aH *APIHandler) getAMv2Alerts(w http.ResponseWriter, r *http.Request) {
+	matchers, err := rules.ParseAMv2Filter(r.URL.Query()["filter"])
+	if err != nil {
+		respondAMv2Error(w, &model.ApiError{Typ: model.ErrorBadData, Err: err})
+		return
+	}
+	silenced := true
+	if s := r.URL.Query().Get("silenced"); s != "" {
+		if silenced, err = strconv.ParseBool(s); err != nil {
+			respondAMv2Error(w, &model.ApiError{Typ: model.ErrorBadData, Err: err})
+			return
+		}
+	}
+
+	alerts, err := aH.ruleManager.AMv2Alerts(r.Context(), matchers, silenced)
+	if err != nil {
+		respondAMv2Error(w, &model.ApiError{Typ: model.ErrorInternal, Err: err})
+		return
+	}
+	aH.WriteJSON(w, r, alerts)
+}
+
+func (aH *APIHandler) postAMv2Alerts(w http.ResponseWriter, r *http.Request) {
+	var alerts []rules.AMv2PostableAlert
+	if err := json.NewDecoder(r.Body).Decode(&alerts); err != nil {
+		respondAMv2Error(w, &model.ApiError{Typ: model.ErrorBadData, Err: err})
+		return
+	}
+
+	if apiErr := aH.ruleManager.PostAMv2Alerts(r.Context(), alerts); apiErr != nil {
+		respondAMv2Error(w, apiErr)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (aH *APIHandler) getAMv2Silences(w http.ResponseWriter, r *http.Request) {
+	matchers, err := rules.ParseAMv2Filter(r.URL.Query()["filter"])
+	if err != nil {
+		respondAMv2Error(w, &model.ApiError{Typ: model.ErrorBadData, Err: err})
+		return
+	}
+
+	silences, err := aH.ruleManager.AMv2Silences(r.Context(), matchers)
+	if err != nil {
+		respondAMv2Error(w, &model.ApiError{Typ: model.ErrorInternal, Err: err})
+		return
+	}
+	aH.WriteJSON(w, r, silences)
+}
+
+func (aH *APIHandler) postAMv2Silence(w http.ResponseWriter, r *http.Request) {
+	silence := rules.AMv2Silence{}
+	if err := json.NewDecoder(r.Body).Decode(&silence); err != nil {
+		respondAMv2Error(w, &model.ApiError{Typ: model.ErrorBadData, Err: err})
+		return
+	}
+
+	id, apiErr := aH.ruleManager.PutAMv2Silence(r.Context(), &silence)
+	if apiErr != nil {
+		respondAMv2Error(w, apiErr)
+		return
+	}
+	aH.WriteJSON(w, r, map[string]string{"silenceID": id})
+}
+
+func (aH *APIHandler) getAMv2Silence(w http.ResponseWriter, r *http.Request) {
+	silence, apiErr := aH.ruleManager.AMv2Silence(r.Context(), mux.Vars(r)["id"])
+	if apiErr != nil {
+		respondAMv2Error(w, apiErr)
+		return
+	}
+	aH.WriteJSON(w, r, silence)
+}
+
+func (aH *APIHandler) deleteAMv2Silence(w http.ResponseWriter, r *http.Request) {
+	if apiErr := aH.ruleManager.ExpireAMv2Silence(r.Context(), mux.Vars(r)["id"]); apiErr != nil {
+		respondAMv2Error(w, apiErr)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// getAMv2Receivers lists the channels as the receivers
+func (aH *APIHandler) getAMv2Receivers(w http.ResponseWriter, r *http.Request) {
+	channels, apiErr := aH.reader.GetChannels()
+	if apiErr != nil {
+		respondAMv2Error(w, apiErr)
+		return
+	}
+
+	user := common.GetUserFromContext(r.Context())
+	receivers := make([]rules.AMv2Receiver, 0, len(*channels))
+	for _, channel := range *channels {
+		if user != nil && !channel.VisibleTo(user.OrgId) {
+			continue
+		}
+		receivers = append(receivers, rules.AMv2Receiver{Name: channel.Name})
+	}
+	aH.WriteJSON(w, r, receivers)
+}
+
+func (aH *APIHandler) getAMv2Status(w http.ResponseWriter, r *http.Request) {
+	aH.WriteJSON(w, r, rules.NewAMv2Status(version.GetVersion()))
+}
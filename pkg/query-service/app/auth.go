@@ -15,6 +15,57 @@ type AuthMiddleware struct {
 	GetUserFromRequest func(r *http.Request) (*model.UserPayload, error)
 }
 
+// scopedRoutes are the APIs reachable with the scoped API tokens and the
+// scope they require, by method and path template
+var scopedRoutes = map[string]string{
+	"GET /api/v1/rules":                                auth.ScopeRulesRead,
+	"GET /api/v1/rules/health":                         auth.ScopeRulesRead,
+	"GET /api/v1/rules/{id}":                           auth.ScopeRulesRead,
+	"GET /api/v1/rules/{id}/alerts":                    auth.ScopeRulesRead,
+	"GET /api/v1/rules/{id}/evaluations":               auth.ScopeRulesRead,
+	"POST /api/v1/rules/{id}/history/stats":            auth.ScopeRulesRead,
+	"POST /api/v1/rules/{id}/history/timeline":         auth.ScopeRulesRead,
+	"POST /api/v1/rules/{id}/history/top_contributors": auth.ScopeRulesRead,
+	"POST /api/v1/rules/{id}/history/overall_status":   auth.ScopeRulesRead,
+	"GET /api/v1/downtime_schedules":                   auth.ScopeRulesRead,
+	"GET /api/v1/downtime_schedules/{id}":              auth.ScopeRulesRead,
+	"GET /api/v2/alerts":                               auth.ScopeRulesRead,
+	"GET /api/v1/alerts/stream":                        auth.ScopeRulesRead,
+	"GET /api/v1/alerts/active":                        auth.ScopeRulesRead,
+	"GET /api/v1/alerts/counts":                        auth.ScopeRulesRead,
+	"GET /api/v1/alert_views":                          auth.ScopeRulesRead,
+	"GET /api/v1/alert_views/{id}":                     auth.ScopeRulesRead,
+	"GET /ws/alerts":                                   auth.ScopeRulesRead,
+	"GET /api/v2/silences":                             auth.ScopeRulesRead,
+	"GET /api/v2/silence/{id}":                         auth.ScopeRulesRead,
+	"POST /api/v1/rules":                               auth.ScopeRulesWrite,
+	"PUT /api/v1/rules/{id}":                           auth.ScopeRulesWrite,
+	"PATCH /api/v1/rules/{id}":                         auth.ScopeRulesWrite,
+	"DELETE /api/v1/rules/{id}":                        auth.ScopeRulesWrite,
+	"POST /api/v1/rules/{id}/clone":                    auth.ScopeRulesWrite,
+	"POST /api/v1/downtime_schedules":                  auth.ScopeRulesWrite,
+	"PUT /api/v1/downtime_schedules/{id}":              auth.ScopeRulesWrite,
+	"DELETE /api/v1/downtime_schedules/{id}":           auth.ScopeRulesWrite,
+	"POST /api/v2/silences":                            auth.ScopeRulesWrite,
+	"DELETE /api/v2/silence/{id}":                      auth.ScopeRulesWrite,
+	"POST /api/v1/rules/{id}/alerts/{fingerprint}/ack": auth.ScopeAlertsAck,
+	"POST /api/v1/testChannel":                         auth.ScopeChannelsTest,
+}
+
+// routeScope returns the scope required by the route of the request, empty
+// when the route is not reachable with the scoped tokens
+func routeScope(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return ""
+	}
+	tmpl, err := route.GetPathTemplate()
+	if err != nil {
+		return ""
+	}
+	return scopedRoutes[r.Method+" "+tmpl]
+}
+
 func NewAuthMiddleware(f func(r *http.Request) (*model.UserPayload, error)) *AuthMiddleware {
 	return &AuthMiddleware{
 		GetUserFromRequest: f,
@@ -45,6 +96,13 @@ func (am *AuthMiddleware) ViewAccess(f func(http.ResponseWriter, *http.Request))
 			}, nil)
 			return
 		}
+		if !auth.HasScope(user, routeScope(r)) {
+			RespondError(w, &model.ApiError{
+				Typ: model.ErrorForbidden,
+				Err: errors.New("API is not in the scopes of the token"),
+			}, nil)
+			return
+		}
 		ctx := context.WithValue(r.Context(), constants.ContextUserKey, user)
 		r = r.WithContext(ctx)
 		f(w, r)
@@ -68,6 +126,13 @@ func (am *AuthMiddleware) EditAccess(f func(http.ResponseWriter, *http.Request))
 			}, nil)
 			return
 		}
+		if !auth.HasScope(user, routeScope(r)) {
+			RespondError(w, &model.ApiError{
+				Typ: model.ErrorForbidden,
+				Err: errors.New("API is not in the scopes of the token"),
+			}, nil)
+			return
+		}
 		ctx := context.WithValue(r.Context(), constants.ContextUserKey, user)
 		r = r.WithContext(ctx)
 		f(w, r)
@@ -92,6 +157,13 @@ func (am *AuthMiddleware) SelfAccess(f func(http.ResponseWriter, *http.Request))
 			}, nil)
 			return
 		}
+		if !auth.HasScope(user, routeScope(r)) {
+			RespondError(w, &model.ApiError{
+				Typ: model.ErrorForbidden,
+				Err: errors.New("API is not in the scopes of the token"),
+			}, nil)
+			return
+		}
 		ctx := context.WithValue(r.Context(), constants.ContextUserKey, user)
 		r = r.WithContext(ctx)
 		f(w, r)
@@ -115,6 +187,13 @@ func (am *AuthMiddleware) AdminAccess(f func(http.ResponseWriter, *http.Request)
 			}, nil)
 			return
 		}
+		if !auth.HasScope(user, routeScope(r)) {
+			RespondError(w, &model.ApiError{
+				Typ: model.ErrorForbidden,
+				Err: errors.New("API is not in the scopes of the token"),
+			}, nil)
+			return
+		}
 		ctx := context.WithValue(r.Context(), constants.ContextUserKey, user)
 		r = r.WithContext(ctx)
 		f(w, r)
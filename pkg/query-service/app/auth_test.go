@@ -0,0 +1,46 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.signoz.io/signoz/pkg/query-service/auth"
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+func TestRouteScope(t *testing.T) {
+	token := &model.UserPayload{Scopes: []string{auth.ScopeRulesRead, auth.ScopeAlertsAck}}
+	session := &model.UserPayload{}
+
+	var allowed, sessionAllowed bool
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		allowed = auth.HasScope(token, routeScope(r))
+		sessionAllowed = auth.HasScope(session, routeScope(r))
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/rules/{id}", handler).Methods(http.MethodGet, http.MethodPut)
+	router.HandleFunc("/api/v1/rules/{id}/alerts/{fingerprint}/ack", handler).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/channels", handler).Methods(http.MethodGet)
+
+	cases := []struct {
+		method, path string
+		allowed      bool
+	}{
+		{http.MethodGet, "/api/v1/rules/1", true},
+		{http.MethodPut, "/api/v1/rules/1", false},
+		{http.MethodPost, "/api/v1/rules/1/alerts/00000000000000a1/ack", true},
+		// the routes without a scope are not reachable with the tokens
+		{http.MethodGet, "/api/v1/channels", false},
+	}
+	for _, c := range cases {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(c.method, c.path, nil))
+		if allowed != c.allowed {
+			t.Errorf("%s %s: expected allowed %v, got %v", c.method, c.path, c.allowed, allowed)
+		}
+		if !sessionAllowed {
+			t.Errorf("%s %s: the users without scopes must reach all the routes", c.method, c.path)
+		}
+	}
+}
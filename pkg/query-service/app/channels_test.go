@@ -0,0 +1,124 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/constants"
+	am "go.signoz.io/signoz/pkg/query-service/integrations/alertManager"
+	"go.signoz.io/signoz/pkg/query-service/interfaces"
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+// channelsReader keeps the channels in memory
+type channelsReader struct {
+	interfaces.Reader
+	channels []model.ChannelItem
+}
+
+func (r *channelsReader) GetChannel(id string) (*model.ChannelItem, *model.ApiError) {
+	for _, c := range r.channels {
+		if fmt.Sprint(c.Id) == id {
+			return &c, nil
+		}
+	}
+	return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("channel not found")}
+}
+
+func (r *channelsReader) GetChannels() (*[]model.ChannelItem, *model.ApiError) {
+	channels := append([]model.ChannelItem{}, r.channels...)
+	return &channels, nil
+}
+
+func (r *channelsReader) DeleteChannel(string) *model.ApiError {
+	return nil
+}
+
+func channelsHandler() *APIHandler {
+	slack := `{"name":"%s","slack_configs":[{"api_url":"https://hooks.slack.com/services/T0/B0/secret","channel":"#%s"}]}`
+	return &APIHandler{reader: &channelsReader{channels: []model.ChannelItem{
+		{Id: 1, Name: "acme-oncall", OrgId: "acme", Data: fmt.Sprintf(slack, "acme-oncall", "oncall")},
+		{Id: 2, Name: "globex-private", OrgId: "globex", Data: fmt.Sprintf(slack, "globex-private", "private")},
+		{Id: 3, Name: "globex-shared", OrgId: "globex", Shared: true, Data: fmt.Sprintf(slack, "globex-shared", "shared")},
+	}}}
+}
+
+func channelsRequest(method, id string) *http.Request {
+	req := httptest.NewRequest(method, "/api/v1/channels/"+id, nil)
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	user := &model.UserPayload{User: model.User{OrgId: "acme"}, Role: "ADMIN"}
+	return req.WithContext(context.WithValue(req.Context(), constants.ContextUserKey, user))
+}
+
+func TestChannelAccess(t *testing.T) {
+	aH := channelsHandler()
+	for _, tc := range []struct {
+		name   string
+		method string
+		id     string
+		status int
+	}{
+		{name: "read own channel", method: http.MethodGet, id: "1", status: http.StatusOK},
+		{name: "read shared channel", method: http.MethodGet, id: "3", status: http.StatusOK},
+		{name: "read channel of another org", method: http.MethodGet, id: "2", status: http.StatusNotFound},
+		{name: "delete own channel", method: http.MethodDelete, id: "1", status: http.StatusOK},
+		{name: "delete shared channel", method: http.MethodDelete, id: "3", status: http.StatusForbidden},
+		{name: "delete channel of another org", method: http.MethodDelete, id: "2", status: http.StatusNotFound},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			if tc.method == http.MethodGet {
+				aH.getChannel(rec, channelsRequest(tc.method, tc.id))
+			} else {
+				aH.deleteChannel(rec, channelsRequest(tc.method, tc.id))
+			}
+			assert.Equal(t, tc.status, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+func TestListChannelsRedactsSharedChannels(t *testing.T) {
+	rec := httptest.NewRecorder()
+	channelsHandler().listChannels(rec, channelsRequest(http.MethodGet, ""))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "hooks.slack.com")
+
+	var resp struct {
+		Data []model.ChannelItem `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Data, 2)
+
+	// the secrets of the own channels are redacted
+	assert.Equal(t, "acme-oncall", resp.Data[0].Name)
+	assert.JSONEq(t, `{"name":"acme-oncall","slack_configs":[{"api_url":"[REDACTED]","channel":"#oncall"}]}`, resp.Data[0].Data)
+
+	// the channels shared by other orgs only show their integrations
+	assert.Equal(t, "globex-shared", resp.Data[1].Name)
+	assert.JSONEq(t, `{"name":"globex-shared","slack_configs":[{}]}`, resp.Data[1].Data)
+}
+
+func TestCheckChannelsEgress(t *testing.T) {
+	policy := am.NewEgressPolicy("hooks.slack.com")
+	channels := checkChannelsEgress([]model.ChannelItem{
+		{Id: 1, Name: "slack", Data: `{"name":"slack","slack_configs":[{"api_url":"https://hooks.slack.com/services/T0/B0/x"}]}`},
+		{Id: 2, Name: "webhook", Data: `{"name":"webhook","webhook_configs":[{"url":"https://attacker.example/?x={{"}]}`},
+	}, policy)
+	require.Len(t, channels, 2)
+	assert.Contains(t, channels[0].Data, "hooks.slack.com")
+
+	// the channel refused by the allowlist is kept without integrations
+	assert.Equal(t, "webhook", channels[1].Name)
+	assert.NotContains(t, channels[1].Data, "attacker.example")
+	receiver := &am.Receiver{}
+	require.NoError(t, json.Unmarshal([]byte(channels[1].Data), receiver))
+	assert.Equal(t, "webhook", receiver.Name)
+	assert.Empty(t, receiver.WebhookConfigs)
+}
@@ -414,12 +414,31 @@ func (r *ClickHouseReader) LoadChannel(channel *model.ChannelItem) *model.ApiErr
 	return nil
 }
 
+// GetChannel returns the channel with its secrets decrypted and read from
+// the external stores. A channel whose secrets can not be read is returned
+// as stored so that it can still be fixed or deleted.
 func (r *ClickHouseReader) GetChannel(id string) (*model.ChannelItem, *model.ApiError) {
+	channel, apiErr := r.getStoredChannel(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	data, err := am.DefaultSecretStore().Open(context.Background(), []byte(channel.Data))
+	if err != nil {
+		zap.L().Error("failed to read the secrets of the channel", zap.String("id", id), zap.Error(err))
+		return channel, nil
+	}
+	channel.Data = string(data)
+	return channel, nil
+}
+
+// getStoredChannel returns the channel as stored, with its secrets
+// encrypted
+func (r *ClickHouseReader) getStoredChannel(id string) (*model.ChannelItem, *model.ApiError) {
 
 	idInt, _ := strconv.Atoi(id)
 	channel := model.ChannelItem{}
 
-	query := "SELECT id, created_at, updated_at, name, type, data data FROM notification_channels WHERE id=? "
+	query := "SELECT id, created_at, updated_at, name, type, data data, org_id, shared FROM notification_channels WHERE id=? "
 
 	stmt, err := r.localDB.Preparex(query)
 
@@ -443,7 +462,7 @@ func (r *ClickHouseReader) DeleteChannel(id string) *model.ApiError {
 
 	idInt, _ := strconv.Atoi(id)
 
-	channelToDelete, apiErrorObj := r.GetChannel(id)
+	channelToDelete, apiErrorObj := r.getStoredChannel(id)
 
 	if apiErrorObj != nil {
 		return apiErrorObj
@@ -490,7 +509,7 @@ func (r *ClickHouseReader) GetChannels() (*[]model.ChannelItem, *model.ApiError)
 
 	channels := []model.ChannelItem{}
 
-	query := "SELECT id, created_at, updated_at, name, type, data data FROM notification_channels"
+	query := "SELECT id, created_at, updated_at, name, type, data data, org_id, shared FROM notification_channels"
 
 	err := r.localDB.Select(&channels, query)
 
@@ -501,6 +520,17 @@ func (r *ClickHouseReader) GetChannels() (*[]model.ChannelItem, *model.ApiError)
 		return nil, &model.ApiError{Typ: model.ErrorInternal, Err: err}
 	}
 
+	// a channel whose secrets can not be read is kept as stored, its
+	// notifications fail until they can
+	for i := range channels {
+		data, err := am.DefaultSecretStore().Open(context.Background(), []byte(channels[i].Data))
+		if err != nil {
+			zap.L().Error("failed to read the secrets of the channel", zap.String("name", channels[i].Name), zap.Error(err))
+			continue
+		}
+		channels[i].Data = string(data)
+	}
+
 	return &channels, nil
 
 }
@@ -537,6 +567,15 @@ func getChannelType(receiver *am.Receiver) string {
 	if receiver.MSTeamsConfigs != nil {
 		return "msteams"
 	}
+	if receiver.PushConfigs != nil {
+		return "push"
+	}
+	if receiver.IRCConfigs != nil {
+		return "irc"
+	}
+	if receiver.XMPPConfigs != nil {
+		return "xmpp"
+	}
 	return ""
 }
 
@@ -544,7 +583,7 @@ func (r *ClickHouseReader) EditChannel(receiver *am.Receiver, id string) (*am.Re
 
 	idInt, _ := strconv.Atoi(id)
 
-	channel, apiErrObj := r.GetChannel(id)
+	channel, apiErrObj := r.getStoredChannel(id)
 
 	if apiErrObj != nil {
 		return nil, apiErrObj
@@ -553,6 +592,15 @@ func (r *ClickHouseReader) EditChannel(receiver *am.Receiver, id string) (*am.Re
 		return nil, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("channel name cannot be changed")}
 	}
 
+	stored, resolved, apiErrObj := sealChannel(receiver, channel.Data)
+	if apiErrObj != nil {
+		return nil, apiErrObj
+	}
+
+	if apiErrObj := r.validateChannel(resolved); apiErrObj != nil {
+		return nil, apiErrObj
+	}
+
 	tx, err := r.localDB.Begin()
 	if err != nil {
 		return nil, &model.ApiError{Typ: model.ErrorInternal, Err: err}
@@ -566,10 +614,8 @@ func (r *ClickHouseReader) EditChannel(receiver *am.Receiver, id string) (*am.Re
 		return nil, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("unsupported feature. please upgrade your plan to access this feature")}
 	}
 
-	receiverString, _ := json.Marshal(receiver)
-
 	{
-		stmt, err := tx.Prepare(`UPDATE notification_channels SET updated_at=$1, type=$2, data=$3 WHERE id=$4;`)
+		stmt, err := tx.Prepare(`UPDATE notification_channels SET updated_at=$1, type=$2, data=$3, shared=$4 WHERE id=$5;`)
 
 		if err != nil {
 			zap.L().Error("Error in preparing statement for UPDATE to notification_channels", zap.Error(err))
@@ -578,14 +624,15 @@ func (r *ClickHouseReader) EditChannel(receiver *am.Receiver, id string) (*am.Re
 		}
 		defer stmt.Close()
 
-		if _, err := stmt.Exec(time.Now(), channel_type, string(receiverString), idInt); err != nil {
+		if _, err := stmt.Exec(time.Now(), channel_type, string(stored), receiver.Shared, idInt); err != nil {
 			zap.L().Error("Error in Executing prepared statement for UPDATE to notification_channels", zap.Error(err))
 			tx.Rollback() // return an error too, we may want to wrap them
 			return nil, &model.ApiError{Typ: model.ErrorInternal, Err: err}
 		}
 	}
 
-	apiError := r.alertManager.EditRoute(receiver)
+	resolved.SetLocale(am.ResolveLocale(r, resolved.Locale))
+	apiError := r.alertManager.EditRoute(resolved)
 	if apiError != nil {
 		tx.Rollback()
 		return nil, apiError
@@ -601,7 +648,16 @@ func (r *ClickHouseReader) EditChannel(receiver *am.Receiver, id string) (*am.Re
 
 }
 
-func (r *ClickHouseReader) CreateChannel(receiver *am.Receiver) (*am.Receiver, *model.ApiError) {
+func (r *ClickHouseReader) CreateChannel(receiver *am.Receiver, orgId string) (*am.Receiver, *model.ApiError) {
+
+	stored, resolved, apiErrObj := sealChannel(receiver, "{}")
+	if apiErrObj != nil {
+		return nil, apiErrObj
+	}
+
+	if apiErrObj := r.validateChannel(resolved); apiErrObj != nil {
+		return nil, apiErrObj
+	}
 
 	channel_type := getChannelType(receiver)
 
@@ -611,15 +667,13 @@ func (r *ClickHouseReader) CreateChannel(receiver *am.Receiver) (*am.Receiver, *
 		return nil, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("unsupported feature. please upgrade your plan to access this feature")}
 	}
 
-	receiverString, _ := json.Marshal(receiver)
-
 	tx, err := r.localDB.Begin()
 	if err != nil {
 		return nil, &model.ApiError{Typ: model.ErrorInternal, Err: err}
 	}
 
 	{
-		stmt, err := tx.Prepare(`INSERT INTO notification_channels (created_at, updated_at, name, type, data) VALUES($1,$2,$3,$4,$5);`)
+		stmt, err := tx.Prepare(`INSERT INTO notification_channels (created_at, updated_at, name, type, data, org_id, shared) VALUES($1,$2,$3,$4,$5,$6,$7);`)
 		if err != nil {
 			zap.L().Error("Error in preparing statement for INSERT to notification_channels", zap.Error(err))
 			tx.Rollback()
@@ -627,14 +681,15 @@ func (r *ClickHouseReader) CreateChannel(receiver *am.Receiver) (*am.Receiver, *
 		}
 		defer stmt.Close()
 
-		if _, err := stmt.Exec(time.Now(), time.Now(), receiver.Name, channel_type, string(receiverString)); err != nil {
+		if _, err := stmt.Exec(time.Now(), time.Now(), receiver.Name, channel_type, string(stored), orgId, receiver.Shared); err != nil {
 			zap.L().Error("Error in Executing prepared statement for INSERT to notification_channels", zap.Error(err))
 			tx.Rollback() // return an error too, we may want to wrap them
 			return nil, &model.ApiError{Typ: model.ErrorInternal, Err: err}
 		}
 	}
 
-	apiError := r.alertManager.AddRoute(receiver)
+	resolved.SetLocale(am.ResolveLocale(r, resolved.Locale))
+	apiError := r.alertManager.AddRoute(resolved)
 	if apiError != nil {
 		tx.Rollback()
 		return nil, apiError
@@ -650,6 +705,238 @@ func (r *ClickHouseReader) CreateChannel(receiver *am.Receiver) (*am.Receiver, *
 
 }
 
+// sealChannel returns the receiver json to store, with the secrets left
+// redacted set to their stored value and the other ones encrypted, and the
+// receiver with its secrets read from the external stores for the alert
+// manager
+func sealChannel(receiver *am.Receiver, storedData string) ([]byte, *am.Receiver, *model.ApiError) {
+	data, err := json.Marshal(receiver)
+	if err != nil {
+		return nil, nil, &model.ApiError{Typ: model.ErrorBadData, Err: err}
+	}
+	data, err = am.RestoreRedacted(data, []byte(storedData))
+	if err != nil {
+		return nil, nil, &model.ApiError{Typ: model.ErrorBadData, Err: err}
+	}
+	secrets := am.DefaultSecretStore()
+	opened, err := secrets.Open(context.Background(), data)
+	if err != nil {
+		return nil, nil, &model.ApiError{Typ: model.ErrorBadData, Err: err}
+	}
+	resolved := &am.Receiver{}
+	if err := json.Unmarshal(opened, resolved); err != nil {
+		return nil, nil, &model.ApiError{Typ: model.ErrorInternal, Err: err}
+	}
+	stored, err := secrets.Seal(data)
+	if err != nil {
+		return nil, nil, &model.ApiError{Typ: model.ErrorInternal, Err: err}
+	}
+	return stored, resolved, nil
+}
+
+// validateChannel ensures the locale and timezone set on the receiver
+// can be used to render notifications and that its destinations are
+// allowed
+func (r *ClickHouseReader) validateChannel(receiver *am.Receiver) *model.ApiError {
+	if receiver.Locale != "" {
+		if _, apiErrObj := r.GetLocale(receiver.Locale); apiErrObj != nil {
+			return &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("unknown locale %s", receiver.Locale)}
+		}
+	}
+	if receiver.Timezone != "" {
+		if _, err := time.LoadLocation(receiver.Timezone); err != nil {
+			return &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("invalid timezone %s: %v", receiver.Timezone, err)}
+		}
+	}
+	if err := receiver.Validate(am.DefaultEgressPolicy()); err != nil {
+		return &model.ApiError{Typ: model.ErrorBadData, Err: err}
+	}
+	return nil
+}
+
+// GetLocales returns the built-in locales followed by the
+// user defined locales
+func (r *ClickHouseReader) GetLocales() (*[]am.Locale, *model.ApiError) {
+
+	locales := am.BuiltinLocales()
+	sort.Slice(locales, func(i, j int) bool {
+		return locales[i].Name < locales[j].Name
+	})
+
+	data := []string{}
+	err := r.localDB.Select(&data, "SELECT data FROM notification_locales ORDER BY name")
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return nil, &model.ApiError{Typ: model.ErrorInternal, Err: err}
+	}
+
+	for _, d := range data {
+		locale := am.Locale{}
+		if err := json.Unmarshal([]byte(d), &locale); err != nil {
+			zap.L().Error("invalid locale data", zap.Error(err))
+			continue
+		}
+		locales = append(locales, locale)
+	}
+
+	return &locales, nil
+}
+
+func (r *ClickHouseReader) GetLocale(name string) (*am.Locale, *model.ApiError) {
+
+	if locale, ok := am.BuiltinLocale(name); ok {
+		return &locale, nil
+	}
+
+	var data string
+	err := r.localDB.Get(&data, "SELECT data FROM notification_locales WHERE name=$1", name)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("locale %s not found", name)}
+		}
+		zap.L().Error("Error in getting locale", zap.String("name", name), zap.Error(err))
+		return nil, &model.ApiError{Typ: model.ErrorInternal, Err: err}
+	}
+
+	locale := am.Locale{}
+	if err := json.Unmarshal([]byte(data), &locale); err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorInternal, Err: err}
+	}
+	return &locale, nil
+}
+
+// SaveLocale creates or replaces a user defined locale
+func (r *ClickHouseReader) SaveLocale(locale *am.Locale) (*am.Locale, *model.ApiError) {
+
+	locale.Builtin = false
+	if err := locale.Validate(); err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorBadData, Err: err}
+	}
+
+	data, err := json.Marshal(locale)
+	if err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorBadData, Err: err}
+	}
+
+	_, err = r.localDB.Exec(`INSERT INTO notification_locales (created_at, updated_at, name, data) VALUES ($1, $2, $3, $4)
+		ON CONFLICT(name) DO UPDATE SET updated_at=excluded.updated_at, data=excluded.data`, time.Now(), time.Now(), locale.Name, string(data))
+	if err != nil {
+		zap.L().Error("Error in saving locale", zap.String("name", locale.Name), zap.Error(err))
+		return nil, &model.ApiError{Typ: model.ErrorInternal, Err: err}
+	}
+
+	return locale, nil
+}
+
+func (r *ClickHouseReader) DeleteLocale(name string) *model.ApiError {
+
+	if _, ok := am.BuiltinLocale(name); ok {
+		return &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("built-in locale %s can not be deleted", name)}
+	}
+
+	var inUse int
+	err := r.localDB.Get(&inUse, "SELECT count(*) FROM notification_channels WHERE json_extract(data, '$.locale') = $1", name)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return &model.ApiError{Typ: model.ErrorInternal, Err: err}
+	}
+	if inUse > 0 {
+		return &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("locale %s is used by %d channel(s)", name, inUse)}
+	}
+
+	if _, err := r.localDB.Exec("DELETE FROM notification_locales WHERE name=$1", name); err != nil {
+		zap.L().Error("Error in deleting locale", zap.String("name", name), zap.Error(err))
+		return &model.ApiError{Typ: model.ErrorInternal, Err: err}
+	}
+	return nil
+}
+
+// GetPushDevices returns the devices registered by the user
+func (r *ClickHouseReader) GetPushDevices(userId string) (*[]am.PushDevice, *model.ApiError) {
+
+	devices := []am.PushDevice{}
+	err := r.localDB.Select(&devices, "SELECT id, created_at, user_id, platform, token FROM push_devices WHERE user_id=$1 ORDER BY id", userId)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return nil, &model.ApiError{Typ: model.ErrorInternal, Err: err}
+	}
+	return &devices, nil
+}
+
+// RegisterPushDevice stores the device token for the user, a token
+// registered again moves to the new user as the app was signed in
+// with a different account
+func (r *ClickHouseReader) RegisterPushDevice(device *am.PushDevice) (*am.PushDevice, *model.ApiError) {
+
+	if err := device.Validate(); err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorBadData, Err: err}
+	}
+	device.CreatedAt = time.Now()
+
+	_, err := r.localDB.Exec(`INSERT INTO push_devices (created_at, user_id, platform, token) VALUES ($1, $2, $3, $4)
+		ON CONFLICT(token) DO UPDATE SET created_at=excluded.created_at, user_id=excluded.user_id, platform=excluded.platform`,
+		device.CreatedAt, device.UserId, device.Platform, device.Token)
+	if err != nil {
+		zap.L().Error("Error in registering push device", zap.Error(err))
+		return nil, &model.ApiError{Typ: model.ErrorInternal, Err: err}
+	}
+
+	if err := r.localDB.Get(&device.Id, "SELECT id FROM push_devices WHERE token=$1", device.Token); err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorInternal, Err: err}
+	}
+	return device, nil
+}
+
+func (r *ClickHouseReader) DeletePushDevice(userId string, id string) *model.ApiError {
+
+	idInt, err := strconv.Atoi(id)
+	if err != nil {
+		return &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("invalid device id %s", id)}
+	}
+
+	res, err := r.localDB.Exec("DELETE FROM push_devices WHERE id=$1 AND user_id=$2", idInt, userId)
+	if err != nil {
+		zap.L().Error("Error in deleting push device", zap.Error(err))
+		return &model.ApiError{Typ: model.ErrorInternal, Err: err}
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("device %s not found", id)}
+	}
+	return nil
+}
+
+// GetPushDevicesForTargets returns the devices of the users with the given
+// emails and of the members of the given groups
+func (r *ClickHouseReader) GetPushDevicesForTargets(users []string, groups []string) ([]am.PushDevice, *model.ApiError) {
+
+	devices := []am.PushDevice{}
+	if len(users) == 0 && len(groups) == 0 {
+		return devices, nil
+	}
+
+	// sqlx.In does not accept empty slices
+	if len(users) == 0 {
+		users = []string{""}
+	}
+	if len(groups) == 0 {
+		groups = []string{""}
+	}
+
+	query, args, err := sqlx.In(`SELECT d.id, d.created_at, d.user_id, d.platform, d.token FROM push_devices d
+		JOIN users u ON u.id = d.user_id
+		LEFT JOIN groups g ON g.id = u.group_id
+		WHERE u.email IN (?) OR g.name IN (?)`, users, groups)
+	if err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorInternal, Err: err}
+	}
+
+	if err := r.localDB.Select(&devices, r.localDB.Rebind(query), args...); err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return nil, &model.ApiError{Typ: model.ErrorInternal, Err: err}
+	}
+	return devices, nil
+}
+
 func (r *ClickHouseReader) GetInstantQueryMetricsResult(ctx context.Context, queryParams *model.InstantQueryMetricsParams) (*promql.Result, *stats.QueryStats, *model.ApiError) {
 	qry, err := r.queryEngine.NewInstantQuery(ctx, r.remoteStorage, nil, queryParams.Query, queryParams.Time)
 	if err != nil {
@@ -4778,11 +5065,15 @@ func (r *ClickHouseReader) GetTimeSeriesResultV3(ctx context.Context, query stri
 	}
 	defer rows.Close()
 
-	var (
-		columnTypes = rows.ColumnTypes()
-		columnNames = rows.Columns()
-		vars        = make([]interface{}, len(columnTypes))
-	)
+	vars, countOfNumberCols := timeSeriesVars(rows)
+	return readRowsForTimeSeriesResult(rows, vars, rows.Columns(), countOfNumberCols)
+}
+
+// timeSeriesVars returns the scan destinations of the columns of rows and
+// the number of numeric columns
+func timeSeriesVars(rows driver.Rows) ([]interface{}, int) {
+	columnTypes := rows.ColumnTypes()
+	vars := make([]interface{}, len(columnTypes))
 	var countOfNumberCols int
 
 	for i := range columnTypes {
@@ -4803,8 +5094,37 @@ func (r *ClickHouseReader) GetTimeSeriesResultV3(ctx context.Context, query stri
 			countOfNumberCols++
 		}
 	}
+	return vars, countOfNumberCols
+}
 
-	return readRowsForTimeSeriesResult(rows, vars, columnNames, countOfNumberCols)
+// StreamTimeSeriesResultV3 runs the query and calls f with the series key,
+// the labels and the point of every row as it is read, the series are not
+// collected in memory. The point is nil for rows without a value. Reading
+// stops at the first error returned by f.
+func StreamTimeSeriesResultV3(ctx context.Context, db clickhouse.Conn, query string, f func(key string, labels map[string]string, point *v3.Point) error) error {
+	rows, err := db.Query(ctx, query)
+	if err != nil {
+		zap.L().Error("error while streaming time series result", zap.Error(err))
+		return err
+	}
+	defer rows.Close()
+
+	vars, countOfNumberCols := timeSeriesVars(rows)
+	columnNames := rows.Columns()
+	for rows.Next() {
+		if err := rows.Scan(vars...); err != nil {
+			return err
+		}
+		groupBy, groupAttributes, _, metricPoint := readRow(vars, columnNames, countOfNumberCols)
+		if metricPoint != nil && (math.IsNaN(metricPoint.Value) || math.IsInf(metricPoint.Value, 0)) {
+			continue
+		}
+		sort.Strings(groupBy)
+		if err := f(strings.Join(groupBy, ""), groupAttributes, metricPoint); err != nil {
+			return err
+		}
+	}
+	return getPersonalisedError(rows.Err())
 }
 
 // GetListResultV3 runs the query and returns list of rows
@@ -5222,12 +5542,47 @@ func (r *ClickHouseReader) AddRuleStateHistory(ctx context.Context, ruleStateHis
 	return nil
 }
 
+// SetRuleStateHistoryTTL keeps the state history items without a state
+// change for detail and the state changes for transitions
+func (r *ClickHouseReader) SetRuleStateHistoryTTL(ctx context.Context, detail, transitions time.Duration) error {
+	tableName := getLocalTableName(signozHistoryDBName + "." + ruleStateHistoryTableName)
+	req := fmt.Sprintf(
+		"ALTER TABLE %v ON CLUSTER %s MODIFY TTL toDateTime(intDiv(unix_milli, 1000)) + INTERVAL %v SECOND DELETE WHERE state_changed = false, "+
+			"toDateTime(intDiv(unix_milli, 1000)) + INTERVAL %v SECOND DELETE",
+		tableName, r.cluster, int64(detail.Seconds()), int64(transitions.Seconds()))
+
+	zap.L().Info("Executing TTL request: ", zap.String("request", req))
+	if err := r.db.Exec(ctx, req); err != nil {
+		zap.L().Error("error while setting ttl", zap.Error(err))
+		return fmt.Errorf("error while setting the state history ttl: %w", err)
+	}
+	return nil
+}
+
+// DeleteRuleStateHistory deletes the state history items without a state
+// change before detailBefore and all the items before transitionsBefore
+func (r *ClickHouseReader) DeleteRuleStateHistory(ctx context.Context, detailBefore, transitionsBefore int64) error {
+	tableName := getLocalTableName(signozHistoryDBName + "." + ruleStateHistoryTableName)
+	req := fmt.Sprintf(
+		"ALTER TABLE %v ON CLUSTER %s DELETE WHERE (unix_milli < %d AND state_changed = false) OR unix_milli < %d",
+		tableName, r.cluster, detailBefore, transitionsBefore)
+
+	if err := r.db.Exec(ctx, req); err != nil {
+		return fmt.Errorf("error while deleting the state history: %w", err)
+	}
+	return nil
+}
+
+// ReadRuleStateHistoryByRuleID reads the state history of the rule, of
+// all rules when ruleID is empty
 func (r *ClickHouseReader) ReadRuleStateHistoryByRuleID(
 	ctx context.Context, ruleID string, params *v3.QueryRuleStateHistory) (*v3.RuleStateTimeline, error) {
 
 	var conditions []string
 
-	conditions = append(conditions, fmt.Sprintf("rule_id = '%s'", ruleID))
+	if ruleID != "" {
+		conditions = append(conditions, fmt.Sprintf("rule_id = '%s'", ruleID))
+	}
 
 	conditions = append(conditions, fmt.Sprintf("unix_milli >= %d AND unix_milli < %d", params.Start, params.End))
 
@@ -5330,6 +5685,43 @@ func (r *ClickHouseReader) ReadRuleStateHistoryTopContributorsByRuleID(
 	return contributors, nil
 }
 
+// ReadRuleStateChanges returns the state changes of the alerts of all
+// rules between start and end, oldest first
+func (r *ClickHouseReader) ReadRuleStateChanges(ctx context.Context, start, end int64) ([]v3.RuleStateHistory, error) {
+	query := fmt.Sprintf("SELECT * FROM %s.%s WHERE state_changed = true AND unix_milli >= %d AND unix_milli <= %d ORDER BY unix_milli ASC",
+		signozHistoryDBName, ruleStateHistoryTableName, start, end)
+
+	history := []v3.RuleStateHistory{}
+	err := r.db.Select(ctx, &history, query)
+	if err != nil {
+		zap.L().Error("Error while reading rule state changes", zap.Error(err))
+		return nil, err
+	}
+
+	return history, nil
+}
+
+func (r *ClickHouseReader) GetRulesLastFired(ctx context.Context) (map[string]int64, error) {
+	query := fmt.Sprintf("SELECT rule_id, max(unix_milli) AS last_fired FROM %s.%s WHERE state_changed = true AND state = 'firing' GROUP BY rule_id",
+		signozHistoryDBName, ruleStateHistoryTableName)
+
+	var rows []struct {
+		RuleID    string `ch:"rule_id"`
+		LastFired int64  `ch:"last_fired"`
+	}
+	err := r.db.Select(ctx, &rows, query)
+	if err != nil {
+		zap.L().Error("Error while reading the last firing of the rules", zap.Error(err))
+		return nil, err
+	}
+
+	lastFired := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		lastFired[row.RuleID] = row.LastFired
+	}
+	return lastFired, nil
+}
+
 func (r *ClickHouseReader) GetOverallStateTransitions(ctx context.Context, ruleID string, params *v3.QueryRuleStateHistory) ([]v3.RuleStateTransition, error) {
 
 	tmpl := `WITH firing_events AS (
@@ -83,6 +83,32 @@ func InitDB(dataSourceName string) (*sqlx.DB, error) {
 		return nil, fmt.Errorf("error in creating notification_channles table: %s", err.Error())
 	}
 
+	table_schema = `CREATE TABLE IF NOT EXISTS notification_locales (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created_at datetime NOT NULL,
+		updated_at datetime NOT NULL,
+		name TEXT NOT NULL UNIQUE,
+		data TEXT NOT NULL
+	);`
+
+	_, err = db.Exec(table_schema)
+	if err != nil {
+		return nil, fmt.Errorf("error in creating notification_locales table: %s", err.Error())
+	}
+
+	table_schema = `CREATE TABLE IF NOT EXISTS push_devices (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created_at datetime NOT NULL,
+		user_id TEXT NOT NULL,
+		platform TEXT NOT NULL,
+		token TEXT NOT NULL UNIQUE
+	);`
+
+	_, err = db.Exec(table_schema)
+	if err != nil {
+		return nil, fmt.Errorf("error in creating push_devices table: %s", err.Error())
+	}
+
 	tableSchema := `CREATE TABLE IF NOT EXISTS planned_maintenance (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		name TEXT NOT NULL,
@@ -99,6 +125,263 @@ func InitDB(dataSourceName string) (*sqlx.DB, error) {
 		return nil, fmt.Errorf("error in creating planned_maintenance table: %s", err.Error())
 	}
 
+	tableSchema = `CREATE TABLE IF NOT EXISTS alert_chart_snapshots (
+		id TEXT PRIMARY KEY,
+		png BLOB NOT NULL,
+		created_at datetime NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_alert_chart_snapshots_created_at ON alert_chart_snapshots (created_at);`
+	_, err = db.Exec(tableSchema)
+	if err != nil {
+		return nil, fmt.Errorf("error in creating alert_chart_snapshots table: %s", err.Error())
+	}
+
+	tableSchema = `CREATE TABLE IF NOT EXISTS template_snippets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		description TEXT NOT NULL DEFAULT '',
+		content TEXT NOT NULL,
+		created_at datetime NOT NULL,
+		created_by TEXT NOT NULL,
+		updated_at datetime NOT NULL,
+		updated_by TEXT NOT NULL
+	);`
+	_, err = db.Exec(tableSchema)
+	if err != nil {
+		return nil, fmt.Errorf("error in creating template_snippets table: %s", err.Error())
+	}
+
+	tableSchema = `CREATE TABLE IF NOT EXISTS rule_evaluators (
+		id TEXT PRIMARY KEY,
+		heartbeat_at datetime NOT NULL
+	);`
+	_, err = db.Exec(tableSchema)
+	if err != nil {
+		return nil, fmt.Errorf("error in creating rule_evaluators table: %s", err.Error())
+	}
+
+	tableSchema = `CREATE TABLE IF NOT EXISTS rule_evaluations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		rule_id TEXT NOT NULL,
+		evaluated_at datetime NOT NULL,
+		duration_ms INTEGER NOT NULL,
+		query TEXT NOT NULL,
+		series INTEGER NOT NULL,
+		samples TEXT NOT NULL,
+		error TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_rule_evaluations_rule_id ON rule_evaluations (rule_id);`
+	_, err = db.Exec(tableSchema)
+	if err != nil {
+		return nil, fmt.Errorf("error in creating rule_evaluations table: %s", err.Error())
+	}
+
+	tableSchema = `CREATE TABLE IF NOT EXISTS rule_state_history_retention (
+		id INTEGER PRIMARY KEY,
+		detail_seconds INTEGER NOT NULL,
+		transitions_seconds INTEGER NOT NULL,
+		updated_at datetime NOT NULL
+	);`
+	_, err = db.Exec(tableSchema)
+	if err != nil {
+		return nil, fmt.Errorf("error in creating rule_state_history_retention table: %s", err.Error())
+	}
+
+	tableSchema = `CREATE TABLE IF NOT EXISTS rule_alert_acks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		rule_id TEXT NOT NULL,
+		fingerprint TEXT NOT NULL,
+		acked_at datetime NOT NULL,
+		acked_by TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_rule_alert_acks_acked_at ON rule_alert_acks (acked_at);`
+	_, err = db.Exec(tableSchema)
+	if err != nil {
+		return nil, fmt.Errorf("error in creating rule_alert_acks table: %s", err.Error())
+	}
+
+	tableSchema = `CREATE TABLE IF NOT EXISTS rule_notifications (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		rule_id TEXT NOT NULL,
+		alert_name TEXT NOT NULL,
+		fingerprint TEXT NOT NULL,
+		state TEXT NOT NULL,
+		status TEXT NOT NULL,
+		receivers TEXT NOT NULL,
+		labels TEXT NOT NULL,
+		sent_at datetime NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_rule_notifications_sent_at ON rule_notifications (sent_at);`
+	_, err = db.Exec(tableSchema)
+	if err != nil {
+		return nil, fmt.Errorf("error in creating rule_notifications table: %s", err.Error())
+	}
+
+	tableSchema = `CREATE TABLE IF NOT EXISTS rule_series_activity (
+		rule_id TEXT PRIMARY KEY,
+		empty_since datetime,
+		last_series_at datetime,
+		auto_disabled_at datetime
+	);`
+	_, err = db.Exec(tableSchema)
+	if err != nil {
+		return nil, fmt.Errorf("error in creating rule_series_activity table: %s", err.Error())
+	}
+
+	tableSchema = `CREATE TABLE IF NOT EXISTS incidents (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL,
+		status TEXT NOT NULL,
+		severity TEXT NOT NULL,
+		assignee TEXT NOT NULL DEFAULT '',
+		labels TEXT NOT NULL,
+		auto BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at datetime NOT NULL,
+		created_by TEXT NOT NULL DEFAULT '',
+		updated_at datetime NOT NULL,
+		resolved_at datetime,
+		org_id TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS idx_incidents_status ON incidents (status);
+	CREATE TABLE IF NOT EXISTS incident_alerts (
+		incident_id INTEGER NOT NULL,
+		rule_id TEXT NOT NULL,
+		rule_name TEXT NOT NULL,
+		fingerprint TEXT NOT NULL,
+		labels TEXT NOT NULL,
+		state TEXT NOT NULL,
+		added_at datetime NOT NULL,
+		resolved_at datetime,
+		PRIMARY KEY (incident_id, rule_id, fingerprint),
+		FOREIGN KEY (incident_id) REFERENCES incidents(id) ON DELETE CASCADE
+	);`
+	_, err = db.Exec(tableSchema)
+	if err != nil {
+		return nil, fmt.Errorf("error in creating incidents table: %s", err.Error())
+	}
+
+	tableSchema = `CREATE TABLE IF NOT EXISTS change_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		kind TEXT NOT NULL,
+		source TEXT NOT NULL,
+		title TEXT NOT NULL,
+		description TEXT NOT NULL,
+		labels TEXT NOT NULL,
+		timestamp datetime NOT NULL,
+		created_at datetime NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_change_events_timestamp ON change_events (timestamp);`
+	_, err = db.Exec(tableSchema)
+	if err != nil {
+		return nil, fmt.Errorf("error in creating change_events table: %s", err.Error())
+	}
+
+	tableSchema = `CREATE TABLE IF NOT EXISTS alert_share_links (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		token_hash TEXT NOT NULL UNIQUE,
+		rule_id TEXT NOT NULL,
+		fingerprint TEXT NOT NULL DEFAULT '',
+		org_id TEXT NOT NULL DEFAULT '',
+		expires_at datetime NOT NULL,
+		created_at datetime NOT NULL,
+		created_by TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS idx_alert_share_links_rule_id ON alert_share_links (rule_id);`
+	_, err = db.Exec(tableSchema)
+	if err != nil {
+		return nil, fmt.Errorf("error in creating alert_share_links table: %s", err.Error())
+	}
+
+	tableSchema = `CREATE TABLE IF NOT EXISTS rule_query_costs (
+		rule_id TEXT NOT NULL,
+		hour datetime NOT NULL,
+		evaluations INTEGER NOT NULL,
+		read_rows INTEGER NOT NULL,
+		read_bytes INTEGER NOT NULL,
+		PRIMARY KEY (rule_id, hour)
+	);
+	CREATE INDEX IF NOT EXISTS idx_rule_query_costs_hour ON rule_query_costs (hour);`
+	_, err = db.Exec(tableSchema)
+	if err != nil {
+		return nil, fmt.Errorf("error in creating rule_query_costs table: %s", err.Error())
+	}
+
+	tableSchema = `CREATE TABLE IF NOT EXISTS custom_units (
+		name TEXT PRIMARY KEY,
+		base TEXT NOT NULL,
+		factor REAL NOT NULL,
+		symbol TEXT NOT NULL DEFAULT '',
+		created_at datetime NOT NULL,
+		created_by TEXT NOT NULL
+	);`
+	_, err = db.Exec(tableSchema)
+	if err != nil {
+		return nil, fmt.Errorf("error in creating custom_units table: %s", err.Error())
+	}
+
+	tableSchema = `CREATE TABLE IF NOT EXISTS org_format_settings (
+		org_id TEXT PRIMARY KEY,
+		data TEXT NOT NULL,
+		updated_at datetime NOT NULL,
+		updated_by TEXT NOT NULL
+	);`
+	_, err = db.Exec(tableSchema)
+	if err != nil {
+		return nil, fmt.Errorf("error in creating org_format_settings table: %s", err.Error())
+	}
+
+	tableSchema = `CREATE TABLE IF NOT EXISTS rule_calendars (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		org_id TEXT NOT NULL DEFAULT '',
+		name TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		events TEXT NOT NULL,
+		created_at datetime NOT NULL,
+		created_by TEXT NOT NULL,
+		updated_at datetime NOT NULL,
+		updated_by TEXT NOT NULL,
+		UNIQUE (org_id, name)
+	);`
+	_, err = db.Exec(tableSchema)
+	if err != nil {
+		return nil, fmt.Errorf("error in creating rule_calendars table: %s", err.Error())
+	}
+
+	tableSchema = `CREATE TABLE IF NOT EXISTS service_catalog (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		org_id TEXT NOT NULL DEFAULT '',
+		service TEXT NOT NULL,
+		team TEXT NOT NULL DEFAULT '',
+		channels TEXT NOT NULL,
+		runbook TEXT NOT NULL DEFAULT '',
+		created_at datetime NOT NULL,
+		created_by TEXT NOT NULL,
+		updated_at datetime NOT NULL,
+		updated_by TEXT NOT NULL,
+		UNIQUE (org_id, service)
+	);`
+	_, err = db.Exec(tableSchema)
+	if err != nil {
+		return nil, fmt.Errorf("error in creating service_catalog table: %s", err.Error())
+	}
+
+	tableSchema = `CREATE TABLE IF NOT EXISTS alert_views (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		org_id TEXT NOT NULL DEFAULT '',
+		name TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		shared BOOLEAN NOT NULL DEFAULT FALSE,
+		query TEXT NOT NULL,
+		created_at datetime NOT NULL,
+		created_by TEXT NOT NULL,
+		updated_at datetime NOT NULL,
+		updated_by TEXT NOT NULL
+	);`
+	_, err = db.Exec(tableSchema)
+	if err != nil {
+		return nil, fmt.Errorf("error in creating alert_views table: %s", err.Error())
+	}
+
 	table_schema = `CREATE TABLE IF NOT EXISTS ttl_status (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		transaction_id TEXT NOT NULL,
@@ -146,6 +429,36 @@ func InitDB(dataSourceName string) (*sqlx.DB, error) {
 		return nil, fmt.Errorf("error in adding column updated_by to dashboards table: %s", err.Error())
 	}
 
+	channelOrg := `ALTER TABLE notification_channels ADD COLUMN org_id TEXT NOT NULL DEFAULT '';`
+	_, err = db.Exec(channelOrg)
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return nil, fmt.Errorf("error in adding column org_id to notification_channels table: %s", err.Error())
+	}
+
+	channelShared := `ALTER TABLE notification_channels ADD COLUMN shared INTEGER NOT NULL DEFAULT 0;`
+	_, err = db.Exec(channelShared)
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return nil, fmt.Errorf("error in adding column shared to notification_channels table: %s", err.Error())
+	}
+
+	ruleOrg := `ALTER TABLE rules ADD COLUMN org_id TEXT NOT NULL DEFAULT '';`
+	_, err = db.Exec(ruleOrg)
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return nil, fmt.Errorf("error in adding column org_id to rules table: %s", err.Error())
+	}
+
+	maintenanceOrg := `ALTER TABLE planned_maintenance ADD COLUMN org_id TEXT NOT NULL DEFAULT '';`
+	_, err = db.Exec(maintenanceOrg)
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return nil, fmt.Errorf("error in adding column org_id to planned_maintenance table: %s", err.Error())
+	}
+
+	incidentOrg := `ALTER TABLE incidents ADD COLUMN org_id TEXT NOT NULL DEFAULT '';`
+	_, err = db.Exec(incidentOrg)
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return nil, fmt.Errorf("error in adding column org_id to incidents table: %s", err.Error())
+	}
+
 	locked := `ALTER TABLE dashboards ADD COLUMN locked INTEGER DEFAULT 0;`
 	_, err = db.Exec(locked)
 	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
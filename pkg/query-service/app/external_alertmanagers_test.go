@@ -0,0 +1,69 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	am "go.signoz.io/signoz/pkg/query-service/integrations/alertManager"
+	"go.signoz.io/signoz/pkg/query-service/rules"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+func TestExternalAlertmanagerStats(t *testing.T) {
+	var accepted int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v2/alerts" {
+			atomic.AddInt32(&accepted, 1)
+		}
+	}))
+	defer server.Close()
+
+	externals, err := am.NewExternalAlertmanagers(am.ExternalAlertmanagersConfig{
+		Clusters: []am.ExternalCluster{
+			{Name: "primary", URLs: []string{server.URL}},
+			// nothing listens on port 1, the alerts stay queued
+			{Name: "unreachable", URLs: []string{"http://127.0.0.1:1"}, Timeout: time.Second},
+		},
+	}, 100)
+	require.NoError(t, err)
+	manager, err := rules.NewManager(&rules.ManagerOptions{
+		NotifierOpts:          am.NotifierOptions{AlertManagerURLs: []string{server.URL}},
+		ExternalAlertmanagers: externals,
+	})
+	require.NoError(t, err)
+	externals.Run()
+	defer externals.Stop()
+	externals.Send(&am.Alert{Labels: labels.FromMap(map[string]string{"alertname": "HighLatency"})})
+
+	aH := &APIHandler{ruleManager: manager}
+	stats := func() map[string]am.NotifierStats {
+		rec := httptest.NewRecorder()
+		aH.getExternalAlertmanagerStats(rec, httptest.NewRequest(http.MethodGet, "/api/v1/rules/notifier/external", nil))
+		var resp struct {
+			Status string                      `json:"status"`
+			Data   map[string]am.NotifierStats `json:"data"`
+		}
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, string(statusSuccess), resp.Status)
+		return resp.Data
+	}
+
+	require.Eventually(t, func() bool {
+		s := stats()
+		return s["primary"].Sent == 1 && s["unreachable"].ConsecutiveFailures == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	s := stats()
+	assert.Equal(t, 100, s["primary"].QueueCapacity)
+	assert.Equal(t, 0, s["primary"].QueueDepth)
+	assert.Equal(t, 1, s["unreachable"].QueueDepth)
+	assert.Contains(t, s["unreachable"].LastError, "http://127.0.0.1:1/api/v2/alerts")
+	assert.Equal(t, time.Second, s["unreachable"].Backoff)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&accepted))
+}
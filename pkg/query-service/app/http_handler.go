@@ -3,12 +3,16 @@ package app
 import (
 	"bytes"
 	"context"
+	"crypto/subtle"
+	"database/sql"
 	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
 	"math"
 	"net/http"
+	"net/url"
 	"regexp"
 	"slices"
 	"strconv"
@@ -40,6 +44,7 @@ import (
 	"go.signoz.io/signoz/pkg/query-service/cache"
 	"go.signoz.io/signoz/pkg/query-service/common"
 	"go.signoz.io/signoz/pkg/query-service/constants"
+	"go.signoz.io/signoz/pkg/query-service/converter"
 	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
 	"go.signoz.io/signoz/pkg/query-service/postprocess"
 
@@ -331,6 +336,7 @@ func (aH *APIHandler) RegisterQueryRangeV3Routes(router *mux.Router, am *AuthMid
 func (aH *APIHandler) RegisterWebSocketPaths(router *mux.Router, am *AuthMiddleware) {
 	subRouter := router.PathPrefix("/ws").Subrouter()
 	subRouter.HandleFunc("/query_progress", am.ViewAccess(aH.GetQueryProgressUpdates)).Methods(http.MethodGet)
+	subRouter.HandleFunc("/alerts", am.ViewAccess(aH.wsAlertEvents)).Methods(http.MethodGet)
 }
 
 func (aH *APIHandler) RegisterQueryRangeV4Routes(router *mux.Router, am *AuthMiddleware) {
@@ -347,6 +353,8 @@ func (aH *APIHandler) Respond(w http.ResponseWriter, data interface{}) {
 // RegisterPrivateRoutes registers routes for this handler on the given router
 func (aH *APIHandler) RegisterPrivateRoutes(router *mux.Router) {
 	router.HandleFunc("/api/v1/channels", aH.listChannels).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/channels/locales", aH.listLocales).Methods(http.MethodGet)
+	router.Handle("/metrics", aH.ruleManager.MetricsHandler()).Methods(http.MethodGet)
 }
 
 // RegisterRoutes registers routes for this handler on the given router
@@ -354,31 +362,144 @@ func (aH *APIHandler) RegisterRoutes(router *mux.Router, am *AuthMiddleware) {
 	router.HandleFunc("/api/v1/query_range", am.ViewAccess(aH.queryRangeMetrics)).Methods(http.MethodGet)
 	router.HandleFunc("/api/v1/query", am.ViewAccess(aH.queryMetrics)).Methods(http.MethodGet)
 	router.HandleFunc("/api/v1/channels", am.ViewAccess(aH.listChannels)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/channels/locales", am.ViewAccess(aH.listLocales)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/channels/locales", am.AdminAccess(aH.saveLocale)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/channels/locales/{name}", am.AdminAccess(aH.deleteLocale)).Methods(http.MethodDelete)
 	router.HandleFunc("/api/v1/channels/{id}", am.ViewAccess(aH.getChannel)).Methods(http.MethodGet)
 	router.HandleFunc("/api/v1/channels/{id}", am.AdminAccess(aH.editChannel)).Methods(http.MethodPut)
 	router.HandleFunc("/api/v1/channels/{id}", am.AdminAccess(aH.deleteChannel)).Methods(http.MethodDelete)
 	router.HandleFunc("/api/v1/channels", am.EditAccess(aH.createChannel)).Methods(http.MethodPost)
 	router.HandleFunc("/api/v1/testChannel", am.EditAccess(aH.testChannel)).Methods(http.MethodPost)
 
+	router.HandleFunc("/api/v1/push/devices", am.ViewAccess(aH.listPushDevices)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/push/devices", am.ViewAccess(aH.registerPushDevice)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/push/devices/{id}", am.ViewAccess(aH.deletePushDevice)).Methods(http.MethodDelete)
+
 	router.HandleFunc("/api/v1/alerts", am.ViewAccess(aH.getAlerts)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/alerts/stream", am.ViewAccess(aH.streamAlertEvents)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/alerts/active", am.ViewAccess(aH.listActiveAlerts)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/alerts/counts", am.ViewAccess(aH.getAlertCounts)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/alert_views", am.ViewAccess(aH.listAlertViews)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/alert_views", am.ViewAccess(aH.createAlertView)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/alert_views/{id}", am.ViewAccess(aH.getAlertView)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/alert_views/{id}", am.ViewAccess(aH.editAlertView)).Methods(http.MethodPut)
+	router.HandleFunc("/api/v1/alert_views/{id}", am.ViewAccess(aH.deleteAlertView)).Methods(http.MethodDelete)
+	// chart snapshots are fetched by slack, email and teams clients
+	// without credentials, the urls are signed and expire
+	router.HandleFunc("/api/v1/alerts/snapshots/{id}", am.OpenAccess(aH.getChartSnapshot)).Methods(http.MethodGet)
+	// alerts from other systems authenticate with the inbound token
+	router.HandleFunc("/api/v1/alerts/inbound/nagios", am.OpenAccess(aH.receiveNagiosChecks)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/alerts/inbound/zabbix", am.OpenAccess(aH.receiveZabbixChecks)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/alerts/inbound/{source}", am.OpenAccess(aH.receiveInboundAlerts)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/alerts/paging/{provider}", am.OpenAccess(aH.receivePageUpdates)).Methods(http.MethodPost)
+	// the public feeds authenticate with the public alerts token
+	router.HandleFunc("/api/v1/public/alerts", am.OpenAccess(aH.getPublicAlerts)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/public/alerts/feed", am.OpenAccess(aH.getPublicAlertsFeed)).Methods(http.MethodGet)
+	// the share links authenticate with their own token
+	router.HandleFunc("/api/v1/public/share/{token}", am.OpenAccess(aH.getSharedAlertView)).Methods(http.MethodGet)
 
 	router.HandleFunc("/api/v1/rules", am.ViewAccess(aH.listRules)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/template_functions", am.ViewAccess(aH.getTemplateFunctions)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/template_preview", am.ViewAccess(aH.previewTemplate)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/rules/notification_preview", am.EditAccess(aH.previewNotifications)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/rules/cost_estimate", am.EditAccess(aH.estimateRuleCost)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/rules/suggest_threshold", am.EditAccess(aH.suggestThresholds)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/rules/import/cloudwatch", am.EditAccess(aH.importCloudWatchAlarms)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/alerting/config/export", am.AdminAccess(aH.exportAlertingConfig)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/alerting/config/import", am.AdminAccess(aH.importAlertingConfig)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/alerting/chaos/storm", am.AdminAccess(aH.getStormStatus)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/alerting/chaos/storm", am.AdminAccess(aH.startStorm)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/alerting/chaos/storm", am.AdminAccess(aH.stopStorm)).Methods(http.MethodDelete)
+	router.HandleFunc("/api/v1/alerting/routing/simulate", am.EditAccess(aH.simulateRouting)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/rules/reload", am.AdminAccess(aH.reloadRules)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/rules/notifier", am.AdminAccess(aH.getNotifierStats)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/notifier/external", am.AdminAccess(aH.getExternalAlertmanagerStats)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/backends", am.ViewAccess(aH.getQueryBackends)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/warmup", am.ViewAccess(aH.getWarmUpStatus)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/warmup", am.AdminAccess(aH.endWarmUp)).Methods(http.MethodDelete)
+	router.HandleFunc("/api/v1/rules/profile", am.AdminAccess(aH.getSlowestRules)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/cost_report", am.AdminAccess(aH.getCostReport)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/scheduler", am.AdminAccess(aH.getSchedulerStatus)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/scheduler/pause", am.AdminAccess(aH.pauseScheduler)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/rules/scheduler/pause", am.AdminAccess(aH.resumeScheduler)).Methods(http.MethodDelete)
+	router.HandleFunc("/api/v1/annotations", am.ViewAccess(aH.getAnnotations)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/analytics", am.ViewAccess(aH.getAlertAnalytics)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/rules/noise", am.ViewAccess(aH.getNoiseReport)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/rules/digest", am.ViewAccess(aH.getAlertDigest)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/rules/digest/send", am.EditAccess(aH.sendAlertDigest)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/rules/quotas", am.ViewAccess(aH.getTeamQuotaUsage)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/stale", am.ViewAccess(aH.getStaleRules)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/health", am.ViewAccess(aH.getRulesHealth)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/tuning", am.ViewAccess(aH.getTuningRecommendations)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/tuning/{id}/apply", am.EditAccess(aH.applyTuning)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/changes", am.ViewAccess(aH.listChangeEvents)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/changes", am.EditAccess(aH.addChangeEvent)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/deploys", am.EditAccess(aH.addDeployMarker)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/incidents", am.ViewAccess(aH.listIncidents)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/incidents", am.EditAccess(aH.createIncident)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/incidents/{id}", am.ViewAccess(aH.getIncident)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/incidents/{id}", am.EditAccess(aH.updateIncident)).Methods(http.MethodPatch)
+	router.HandleFunc("/api/v1/incidents/{id}/alerts", am.EditAccess(aH.addIncidentAlerts)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/incidents/{id}/timeline", am.ViewAccess(aH.getIncidentTimeline)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/incidents/{id}/timeline/export", am.ViewAccess(aH.exportIncidentTimeline)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/history/export", am.ViewAccess(aH.exportStateHistory)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/rules/history/retention", am.AdminAccess(aH.getStateHistoryRetention)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/history/retention", am.AdminAccess(aH.setStateHistoryRetention)).Methods(http.MethodPut)
 	router.HandleFunc("/api/v1/rules/{id}", am.ViewAccess(aH.getRule)).Methods(http.MethodGet)
 	router.HandleFunc("/api/v1/rules", am.EditAccess(aH.createRule)).Methods(http.MethodPost)
 	router.HandleFunc("/api/v1/rules/{id}", am.EditAccess(aH.editRule)).Methods(http.MethodPut)
 	router.HandleFunc("/api/v1/rules/{id}", am.EditAccess(aH.deleteRule)).Methods(http.MethodDelete)
 	router.HandleFunc("/api/v1/rules/{id}", am.EditAccess(aH.patchRule)).Methods(http.MethodPatch)
 	router.HandleFunc("/api/v1/testRule", am.EditAccess(aH.testRule)).Methods(http.MethodPost)
-	router.HandleFunc("/api/v1/rules/{id}/history/stats", am.ViewAccess(aH.getRuleStats)).Methods(http.MethodPost)
-	router.HandleFunc("/api/v1/rules/{id}/history/timeline", am.ViewAccess(aH.getRuleStateHistory)).Methods(http.MethodPost)
-	router.HandleFunc("/api/v1/rules/{id}/history/top_contributors", am.ViewAccess(aH.getRuleStateHistoryTopContributors)).Methods(http.MethodPost)
-	router.HandleFunc("/api/v1/rules/{id}/history/overall_status", am.ViewAccess(aH.getOverallStateTransitions)).Methods(http.MethodPost)
-
-	router.HandleFunc("/api/v1/downtime_schedules", am.OpenAccess(aH.listDowntimeSchedules)).Methods(http.MethodGet)
-	router.HandleFunc("/api/v1/downtime_schedules/{id}", am.OpenAccess(aH.getDowntimeSchedule)).Methods(http.MethodGet)
-	router.HandleFunc("/api/v1/downtime_schedules", am.OpenAccess(aH.createDowntimeSchedule)).Methods(http.MethodPost)
-	router.HandleFunc("/api/v1/downtime_schedules/{id}", am.OpenAccess(aH.editDowntimeSchedule)).Methods(http.MethodPut)
-	router.HandleFunc("/api/v1/downtime_schedules/{id}", am.OpenAccess(aH.deleteDowntimeSchedule)).Methods(http.MethodDelete)
+	router.HandleFunc("/api/v1/rules/{id}/history/stats", am.ViewAccess(aH.tenantRule(aH.getRuleStats))).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/rules/{id}/history/timeline", am.ViewAccess(aH.tenantRule(aH.getRuleStateHistory))).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/rules/{id}/history/top_contributors", am.ViewAccess(aH.tenantRule(aH.getRuleStateHistoryTopContributors))).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/rules/{id}/history/overall_status", am.ViewAccess(aH.tenantRule(aH.getOverallStateTransitions))).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/rules/{id}/alerts", am.ViewAccess(aH.tenantRule(aH.getRuleAlerts))).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/{id}/alerts/{fingerprint}/ack", am.EditAccess(aH.tenantRule(aH.acknowledgeAlert))).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/rules/{id}/alerts/{fingerprint}/related", am.ViewAccess(aH.tenantRule(aH.getRelatedToAlert))).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/{id}/alerts/{fingerprint}", am.ViewAccess(aH.tenantRule(aH.getAlertDetail))).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/{id}/alerts/{fingerprint}/timeline", am.ViewAccess(aH.tenantRule(aH.exportAlertTimeline))).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/{id}/runbook", am.ViewAccess(aH.tenantRule(aH.getRuleRunbook))).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/{id}/evaluations", am.ViewAccess(aH.tenantRule(aH.getRuleEvaluations))).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/{id}/explain", am.ViewAccess(aH.tenantRule(aH.explainRule))).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/rules/{id}/canary", am.ViewAccess(aH.tenantRule(aH.getCanaryVerdict))).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/{id}/cost_estimate", am.ViewAccess(aH.tenantRule(aH.estimateStoredRuleCost))).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/{id}/clone", am.EditAccess(aH.tenantRule(aH.cloneRule))).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/rules/{id}/share", am.ViewAccess(aH.tenantRule(aH.listShareLinks))).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/{id}/share", am.EditAccess(aH.tenantRule(aH.createShareLink))).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/rules/{id}/share/{linkId}", am.EditAccess(aH.tenantRule(aH.revokeShareLink))).Methods(http.MethodDelete)
+	router.HandleFunc("/api/v1/rules/{id}/query_cost", am.ViewAccess(aH.tenantRule(aH.getRuleQueryCost))).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/{id}/profile", am.AdminAccess(aH.tenantRule(aH.profileRule))).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/rules/{id}/evaluate", am.AdminAccess(aH.tenantRule(aH.evaluateRule))).Methods(http.MethodPost)
+
+	router.HandleFunc("/api/v1/template_snippets", am.ViewAccess(aH.listTemplateSnippets)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/template_snippets/{id}", am.ViewAccess(aH.getTemplateSnippet)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/template_snippets", am.EditAccess(aH.createTemplateSnippet)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/template_snippets/{id}", am.EditAccess(aH.editTemplateSnippet)).Methods(http.MethodPut)
+	router.HandleFunc("/api/v1/template_snippets/{id}", am.EditAccess(aH.deleteTemplateSnippet)).Methods(http.MethodDelete)
+	router.HandleFunc("/api/v1/units/custom", am.ViewAccess(aH.listCustomUnits)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/units/custom", am.EditAccess(aH.createCustomUnit)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/units/custom/{name}", am.EditAccess(aH.deleteCustomUnit)).Methods(http.MethodDelete)
+	router.HandleFunc("/api/v1/settings/format", am.ViewAccess(aH.getFormatSettings)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/settings/format", am.AdminAccess(aH.setFormatSettings)).Methods(http.MethodPut)
+	router.HandleFunc("/api/v1/settings/format", am.AdminAccess(aH.deleteFormatSettings)).Methods(http.MethodDelete)
+	router.HandleFunc("/api/v1/calendars", am.ViewAccess(aH.listCalendars)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/calendars", am.EditAccess(aH.createCalendar)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/calendars/import", am.EditAccess(aH.importCalendar)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/calendars/{id}", am.EditAccess(aH.editCalendar)).Methods(http.MethodPut)
+	router.HandleFunc("/api/v1/calendars/{id}", am.EditAccess(aH.deleteCalendar)).Methods(http.MethodDelete)
+
+	router.HandleFunc("/api/v1/service_catalog", am.ViewAccess(aH.listServiceEntries)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/service_catalog", am.EditAccess(aH.createServiceEntry)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/service_catalog/{id}", am.EditAccess(aH.editServiceEntry)).Methods(http.MethodPut)
+	router.HandleFunc("/api/v1/service_catalog/{id}", am.EditAccess(aH.deleteServiceEntry)).Methods(http.MethodDelete)
+
+	router.HandleFunc("/api/v1/downtime_schedules", am.ViewAccess(aH.listDowntimeSchedules)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/downtime_schedules/{id}", am.ViewAccess(aH.getDowntimeSchedule)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/downtime_schedules", am.EditAccess(aH.createDowntimeSchedule)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/downtime_schedules/{id}", am.EditAccess(aH.editDowntimeSchedule)).Methods(http.MethodPut)
+	router.HandleFunc("/api/v1/downtime_schedules/{id}", am.EditAccess(aH.deleteDowntimeSchedule)).Methods(http.MethodDelete)
 
 	router.HandleFunc("/api/v1/dashboards", am.ViewAccess(aH.getDashboards)).Methods(http.MethodGet)
 	router.HandleFunc("/api/v1/dashboards", am.EditAccess(aH.createDashboards)).Methods(http.MethodPost)
@@ -504,152 +625,1683 @@ func (aH *APIHandler) getRule(w http.ResponseWriter, r *http.Request) {
 	aH.Respond(w, ruleResponse)
 }
 
-// populateTemporality adds the temporality to the query if it is not present
-func (aH *APIHandler) populateTemporality(ctx context.Context, qp *v3.QueryRangeParamsV3) error {
-
-	aH.temporalityMux.Lock()
-	defer aH.temporalityMux.Unlock()
-
-	missingTemporality := make([]string, 0)
-	metricNameToTemporality := make(map[string]map[v3.Temporality]bool)
-	if qp.CompositeQuery != nil && len(qp.CompositeQuery.BuilderQueries) > 0 {
-		for _, query := range qp.CompositeQuery.BuilderQueries {
-			// if there is no temporality specified in the query but we have it in the map
-			// then use the value from the map
-			if query.Temporality == "" && aH.temporalityMap[query.AggregateAttribute.Key] != nil {
-				// We prefer delta if it is available
-				if aH.temporalityMap[query.AggregateAttribute.Key][v3.Delta] {
-					query.Temporality = v3.Delta
-				} else if aH.temporalityMap[query.AggregateAttribute.Key][v3.Cumulative] {
-					query.Temporality = v3.Cumulative
-				} else {
-					query.Temporality = v3.Unspecified
-				}
-			}
-			// we don't have temporality for this metric
-			if query.DataSource == v3.DataSourceMetrics && query.Temporality == "" {
-				missingTemporality = append(missingTemporality, query.AggregateAttribute.Key)
-			}
-			if _, ok := metricNameToTemporality[query.AggregateAttribute.Key]; !ok {
-				metricNameToTemporality[query.AggregateAttribute.Key] = make(map[v3.Temporality]bool)
+// tenantRule responds not found to the requests on the rules of the other
+// orgs, before calling f
+func (aH *APIHandler) tenantRule(f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		if _, err := aH.ruleManager.GetRule(r.Context(), id); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				RespondError(w, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("rule %s not found", id)}, nil)
+				return
 			}
+			RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+			return
 		}
+		f(w, r)
 	}
+}
 
-	nameToTemporality, err := aH.reader.FetchTemporality(ctx, missingTemporality)
+// getRuleEvaluations returns the evaluation log of the rule, the number of
+// evaluations is set with the limit param
+func (aH *APIHandler) getRuleEvaluations(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	limit := 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil {
+			RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("invalid limit %q", l)}, nil)
+			return
+		}
+	}
+	evaluations, err := aH.ruleManager.GetRuleEvaluations(r.Context(), id, limit)
 	if err != nil {
-		return err
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
 	}
+	aH.Respond(w, evaluations)
+}
 
-	if qp.CompositeQuery != nil && len(qp.CompositeQuery.BuilderQueries) > 0 {
-		for name := range qp.CompositeQuery.BuilderQueries {
-			query := qp.CompositeQuery.BuilderQueries[name]
-			if query.DataSource == v3.DataSourceMetrics && query.Temporality == "" {
-				if nameToTemporality[query.AggregateAttribute.Key][v3.Delta] {
-					query.Temporality = v3.Delta
-				} else if nameToTemporality[query.AggregateAttribute.Key][v3.Cumulative] {
-					query.Temporality = v3.Cumulative
-				} else {
-					query.Temporality = v3.Unspecified
-				}
-				aH.temporalityMap[query.AggregateAttribute.Key] = nameToTemporality[query.AggregateAttribute.Key]
-			}
+// getCanaryVerdict returns the verdict of the last evaluation of a canary
+// rule, the deploy pipelines evaluate the rule first for a fresh verdict
+func (aH *APIHandler) getCanaryVerdict(w http.ResponseWriter, r *http.Request) {
+	verdict, apiErr := aH.ruleManager.CanaryVerdict(r.Context(), mux.Vars(r)["id"])
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, verdict)
+}
+
+// explainRule re-runs the rule for the requested time and returns why each
+// series did or did not match the condition of the rule
+func (aH *APIHandler) explainRule(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	req := rules.ExplainRequest{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+			return
 		}
 	}
-	return nil
+	ts := time.Now()
+	if req.Timestamp > 0 {
+		ts = time.UnixMilli(req.Timestamp)
+	}
+
+	explanation, apiErr := aH.ruleManager.ExplainRule(r.Context(), id, ts)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, explanation)
 }
 
-func (aH *APIHandler) listDowntimeSchedules(w http.ResponseWriter, r *http.Request) {
-	schedules, err := aH.ruleManager.RuleDB().GetAllPlannedMaintenance(r.Context())
+// reloadRules applies the stored rules to the running rules without a
+// restart
+func (aH *APIHandler) reloadRules(w http.ResponseWriter, r *http.Request) {
+	result, err := aH.ruleManager.Reload(r.Context())
 	if err != nil {
 		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
 		return
 	}
+	aH.Respond(w, result)
+}
 
-	// The schedules are stored as JSON in the database, so we need to filter them here
-	// Since the number of schedules is expected to be small, this should be fine
+// getNotifierStats returns the state of the queue of notifications sent to
+// the alert manager
+func (aH *APIHandler) getNotifierStats(w http.ResponseWriter, r *http.Request) {
+	aH.Respond(w, aH.ruleManager.NotifierStats())
+}
 
-	if r.URL.Query().Get("active") != "" {
-		activeSchedules := make([]rules.PlannedMaintenance, 0)
-		active, _ := strconv.ParseBool(r.URL.Query().Get("active"))
-		for _, schedule := range schedules {
-			now := time.Now().In(time.FixedZone(schedule.Schedule.Timezone, 0))
-			if schedule.IsActive(now) == active {
-				activeSchedules = append(activeSchedules, schedule)
+// getExternalAlertmanagerStats returns the state of the queue of every
+// external Alertmanager cluster the alerts are forwarded to
+func (aH *APIHandler) getExternalAlertmanagerStats(w http.ResponseWriter, r *http.Request) {
+	aH.Respond(w, aH.ruleManager.ExternalAlertmanagerStats())
+}
+
+// getQueryBackends lists the query backends the rules can select
+func (aH *APIHandler) getQueryBackends(w http.ResponseWriter, r *http.Request) {
+	aH.Respond(w, aH.ruleManager.QueryBackends())
+}
+
+// getWarmUpStatus tells whether the notifications are suppressed after a
+// restart
+func (aH *APIHandler) getWarmUpStatus(w http.ResponseWriter, r *http.Request) {
+	aH.Respond(w, aH.ruleManager.WarmUpStatus())
+}
+
+// endWarmUp ends the warm up before its time
+func (aH *APIHandler) endWarmUp(w http.ResponseWriter, r *http.Request) {
+	aH.Respond(w, aH.ruleManager.EndWarmUp())
+}
+
+// getAlertCounts returns the number of alerts in every state over the time
+// range for the dashboard panels, e.g.
+// ?start=..&end=..&step=60000&match={service_name="checkout"}&state=firing
+// &groupBy=severity
+func (aH *APIHandler) getAlertCounts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := rules.AlertCountsRequest{
+		Matchers: q.Get("match"),
+		RuleIDs:  q["ruleId"],
+		States:   q["state"],
+	}
+	for name, v := range map[string]*int64{"start": &req.Start, "end": &req.End, "step": &req.Step} {
+		if s := q.Get(name); s != "" {
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("invalid %s %q", name, s)}, nil)
+				return
 			}
+			*v = n
 		}
-		schedules = activeSchedules
+	}
+	if v := q.Get("groupBy"); v != "" {
+		req.GroupBy = strings.Split(v, ",")
+	}
+	if err := req.Validate(); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
 	}
 
-	if r.URL.Query().Get("recurring") != "" {
-		recurringSchedules := make([]rules.PlannedMaintenance, 0)
-		recurring, _ := strconv.ParseBool(r.URL.Query().Get("recurring"))
-		for _, schedule := range schedules {
-			if schedule.IsRecurring() == recurring {
-				recurringSchedules = append(recurringSchedules, schedule)
+	series, err := aH.ruleManager.AlertCounts(r.Context(), &req)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+	aH.Respond(w, series)
+}
+
+// getAnnotations returns the periods the alerts fired in the time range,
+// for the dashboard panels to overlay
+func (aH *APIHandler) getAnnotations(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := rules.AnnotationsRequest{
+		Matchers: q.Get("matchers"),
+		RuleID:   q.Get("ruleId"),
+	}
+	for name, v := range map[string]*int64{"start": &req.Start, "end": &req.End} {
+		if s := q.Get(name); s != "" {
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("invalid %s %q", name, s)}, nil)
+				return
 			}
+			*v = n
 		}
-		schedules = recurringSchedules
+	}
+	if l := q.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil {
+			RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("invalid limit %q", l)}, nil)
+			return
+		}
+		req.Limit = limit
+	}
+	if err := req.Validate(); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
 	}
 
-	aH.Respond(w, schedules)
+	annotations, err := aH.ruleManager.Annotations(r.Context(), &req)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+	aH.Respond(w, annotations)
 }
 
-func (aH *APIHandler) getDowntimeSchedule(w http.ResponseWriter, r *http.Request) {
-	id := mux.Vars(r)["id"]
-	schedule, err := aH.ruleManager.RuleDB().GetPlannedMaintenanceByID(r.Context(), id)
+// getCostReport returns the data read by the rule evaluations over the
+// period, grouped by rule, team or query type
+func (aH *APIHandler) getCostReport(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := rules.CostReportRequest{GroupBy: q.Get("groupBy")}
+	for name, v := range map[string]*int64{"start": &req.Start, "end": &req.End} {
+		if s := q.Get(name); s != "" {
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("invalid %s %q", name, s)}, nil)
+				return
+			}
+			*v = n
+		}
+	}
+	if l := q.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil {
+			RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("invalid limit %q", l)}, nil)
+			return
+		}
+		req.Limit = limit
+	}
+
+	report, apiErr := aH.ruleManager.CostReport(r.Context(), &req)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, report)
+}
+
+// getAlertAnalytics returns the alert frequency, time to resolve and
+// firing time of the rules and their teams
+func (aH *APIHandler) getAlertAnalytics(w http.ResponseWriter, r *http.Request) {
+	params := v3.QueryAlertAnalytics{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	if err := params.Validate(); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	res, err := aH.ruleManager.AlertAnalytics(r.Context(), &params)
 	if err != nil {
 		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
 		return
 	}
-	aH.Respond(w, schedule)
+	aH.Respond(w, res)
 }
 
-func (aH *APIHandler) createDowntimeSchedule(w http.ResponseWriter, r *http.Request) {
-	var schedule rules.PlannedMaintenance
-	err := json.NewDecoder(r.Body).Decode(&schedule)
-	if err != nil {
+// getNoiseReport ranks the rules by the noise of their alerts
+func (aH *APIHandler) getNoiseReport(w http.ResponseWriter, r *http.Request) {
+	params := v3.QueryNoiseReport{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
 		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
 		return
 	}
-	if err := schedule.Validate(); err != nil {
+	if err := params.Validate(); err != nil {
 		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
 		return
 	}
 
-	_, err = aH.ruleManager.RuleDB().CreatePlannedMaintenance(r.Context(), schedule)
+	res, err := aH.ruleManager.NoiseReport(r.Context(), &params)
 	if err != nil {
 		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
 		return
 	}
-	aH.Respond(w, nil)
+	aH.Respond(w, res)
 }
 
-func (aH *APIHandler) editDowntimeSchedule(w http.ResponseWriter, r *http.Request) {
-	id := mux.Vars(r)["id"]
-	var schedule rules.PlannedMaintenance
-	err := json.NewDecoder(r.Body).Decode(&schedule)
+// getAlertDigest returns the weekly alert quality digest of every team
+func (aH *APIHandler) getAlertDigest(w http.ResponseWriter, r *http.Request) {
+	params := v3.QueryAlertDigest{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	if err := params.Validate(); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	res, err := aH.ruleManager.AlertDigest(r.Context(), &params)
 	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+	aH.Respond(w, res)
+}
+
+// sendAlertDigest sends the weekly alert quality digest to a channel now
+func (aH *APIHandler) sendAlertDigest(w http.ResponseWriter, r *http.Request) {
+	params := struct {
+		v3.QueryAlertDigest
+		Channel string `json:"channel"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
 		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
 		return
 	}
-	if err := schedule.Validate(); err != nil {
+	if err := params.Validate(); err != nil {
 		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
 		return
 	}
-	_, err = aH.ruleManager.RuleDB().EditPlannedMaintenance(r.Context(), schedule, id)
+
+	res, apiErr := aH.ruleManager.SendAlertDigest(r.Context(), params.Channel, &params.QueryAlertDigest)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, res)
+}
+
+// getRulesHealth returns the health of the evaluations of all the rules
+// and of the notifications in a single call, for the status pages
+func (aH *APIHandler) getRulesHealth(w http.ResponseWriter, r *http.Request) {
+	aH.Respond(w, aH.ruleManager.RulesHealthSummary(r.Context()))
+}
+
+// getStaleRules returns the rules whose query stopped returning series
+func (aH *APIHandler) getStaleRules(w http.ResponseWriter, r *http.Request) {
+	// days is the number of consecutive days without series, the
+	// configured duration by default
+	var after time.Duration
+	if s := r.URL.Query().Get("days"); s != "" {
+		days, err := strconv.Atoi(s)
+		if err != nil || days <= 0 {
+			RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("invalid days %q", s)}, nil)
+			return
+		}
+		after = time.Duration(days) * 24 * time.Hour
+	}
+
+	res, err := aH.ruleManager.StaleRules(r.Context(), after)
 	if err != nil {
 		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
 		return
 	}
-	aH.Respond(w, nil)
+	aH.Respond(w, res)
 }
 
-func (aH *APIHandler) deleteDowntimeSchedule(w http.ResponseWriter, r *http.Request) {
+// getTuningRecommendations returns the changes to the rules that would
+// have removed some of their alerts, refresh computes them again
+func (aH *APIHandler) getTuningRecommendations(w http.ResponseWriter, r *http.Request) {
+	refresh := r.URL.Query().Get("refresh") == "true"
+	report, apiErr := aH.ruleManager.TuningRecommendations(r.Context(), refresh)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, report)
+}
+
+func (aH *APIHandler) applyTuning(w http.ResponseWriter, r *http.Request) {
+	rule, apiErr := aH.ruleManager.ApplyTuning(r.Context(), mux.Vars(r)["id"])
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, rule)
+}
+
+func incidentID(r *http.Request) (int64, *model.ApiError) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return 0, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("invalid incident id %q", mux.Vars(r)["id"])}
+	}
+	return id, nil
+}
+
+// listIncidents returns the incidents, of the comma separated statuses
+// when given
+func (aH *APIHandler) listIncidents(w http.ResponseWriter, r *http.Request) {
+	var statuses []string
+	if s := r.URL.Query().Get("status"); s != "" {
+		statuses = strings.Split(s, ",")
+	}
+
+	res, err := aH.ruleManager.Incidents(r.Context(), statuses...)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+	aH.Respond(w, res)
+}
+
+// createIncident opens an incident by hand
+func (aH *APIHandler) createIncident(w http.ResponseWriter, r *http.Request) {
+	params := rules.PostableIncident{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	if err := params.Validate(); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	res, apiErr := aH.ruleManager.CreateIncident(r.Context(), &params)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, res)
+}
+
+func (aH *APIHandler) getIncident(w http.ResponseWriter, r *http.Request) {
+	id, apiErr := incidentID(r)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+
+	res, apiErr := aH.ruleManager.GetIncident(r.Context(), id)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, res)
+}
+
+// updateIncident changes the status, severity, assignee or title of an
+// incident
+func (aH *APIHandler) updateIncident(w http.ResponseWriter, r *http.Request) {
+	id, apiErr := incidentID(r)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	params := rules.IncidentUpdate{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	if err := params.Validate(); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	res, apiErr := aH.ruleManager.UpdateIncident(r.Context(), id, &params)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, res)
+}
+
+// addIncidentAlerts adds active alerts to an incident
+func (aH *APIHandler) addIncidentAlerts(w http.ResponseWriter, r *http.Request) {
+	id, apiErr := incidentID(r)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	var params []rules.IncidentAlertRef
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	res, apiErr := aH.ruleManager.AddIncidentAlerts(r.Context(), id, params)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, res)
+}
+
+// getIncidentTimeline returns the state changes of the alerts of an
+// incident with its own changes
+func (aH *APIHandler) getIncidentTimeline(w http.ResponseWriter, r *http.Request) {
+	id, apiErr := incidentID(r)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+
+	res, apiErr := aH.ruleManager.IncidentTimeline(r.Context(), id)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, res)
+}
+
+// exportIncidentTimeline returns the timeline of the incident as a
+// markdown or json file for the postmortem
+func (aH *APIHandler) exportIncidentTimeline(w http.ResponseWriter, r *http.Request) {
+	id, apiErr := incidentID(r)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+
+	export, apiErr := aH.ruleManager.IncidentTimelineExport(r.Context(), id)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	writeTimelineExport(w, export, r.URL.Query().Get("format"), fmt.Sprintf("incident-%d", id))
+}
+
+// exportAlertTimeline returns the timeline of the alert between start and
+// end, in unix milliseconds, the last day by default, as a markdown or
+// json file for the postmortem
+func (aH *APIHandler) exportAlertTimeline(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	fingerprint, err := strconv.ParseUint(mux.Vars(r)["fingerprint"], 10, 64)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("invalid fingerprint %q", mux.Vars(r)["fingerprint"])}, nil)
+		return
+	}
+	q := r.URL.Query()
+	end := time.Now().UnixMilli()
+	start := end - 24*time.Hour.Milliseconds()
+	for name, v := range map[string]*int64{"start": &start, "end": &end} {
+		if s := q.Get(name); s != "" {
+			if *v, err = strconv.ParseInt(s, 10, 64); err != nil {
+				RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("invalid %s %q", name, s)}, nil)
+				return
+			}
+		}
+	}
+
+	export, apiErr := aH.ruleManager.AlertTimelineExport(r.Context(), id, fingerprint, time.UnixMilli(start), time.UnixMilli(end))
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	writeTimelineExport(w, export, q.Get("format"), fmt.Sprintf("alert-%s-%d", id, fingerprint))
+}
+
+func writeTimelineExport(w http.ResponseWriter, export *rules.TimelineExport, format, name string) {
+	data, contentType, err := export.Encode(format)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	ext := "md"
+	if format == rules.TimelineJSON {
+		ext = "json"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"-timeline."+ext))
+	w.Write(data)
+}
+
+// listChangeEvents returns the changes made between start and end, in
+// unix milliseconds, the last day by default
+func (aH *APIHandler) listChangeEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	end := time.Now().UnixMilli()
+	start := end - 24*time.Hour.Milliseconds()
+	for name, v := range map[string]*int64{"start": &start, "end": &end} {
+		if s := q.Get(name); s != "" {
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("invalid %s %q", name, s)}, nil)
+				return
+			}
+			*v = n
+		}
+	}
+	if start > end {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("start must not be after end")}, nil)
+		return
+	}
+
+	res, err := aH.ruleManager.ChangeEvents(r.Context(), time.UnixMilli(start), time.UnixMilli(end))
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+	aH.Respond(w, res)
+}
+
+// addChangeEvent records a deployment, kubernetes event or config change
+func (aH *APIHandler) addChangeEvent(w http.ResponseWriter, r *http.Request) {
+	change := rules.ChangeEvent{}
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	res, apiErr := aH.ruleManager.AddChangeEvent(r.Context(), &change)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, res)
+}
+
+// addDeployMarker records a deploy of a service, the alerts of the
+// service starting after it are tagged with it
+func (aH *APIHandler) addDeployMarker(w http.ResponseWriter, r *http.Request) {
+	marker := rules.DeployMarker{}
+	if err := json.NewDecoder(r.Body).Decode(&marker); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	res, apiErr := aH.ruleManager.AddDeployMarker(r.Context(), &marker)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, res)
+}
+
+// getRelatedToAlert returns the alerts and changes possibly related to
+// the active alert
+// getTeamQuotaUsage returns the usage of the quotas of the teams
+func (aH *APIHandler) getTeamQuotaUsage(w http.ResponseWriter, r *http.Request) {
+	usage, apiErr := aH.ruleManager.TeamQuotaUsage(r.Context())
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, usage)
+}
+
+// parseAlertListQuery parses the query of the active alerts list, e.g.
+// ?state=firing&severity=critical&match={service_name="checkout"}&minAge=5m
+// &acked=false&sort=-severity&limit=50&fields=ruleName,labels
+// &groupBy=service_name,severity
+func parseAlertListQuery(r *http.Request) (*rules.AlertListQuery, error) {
+	q := r.URL.Query()
+	query := &rules.AlertListQuery{
+		States:     q["state"],
+		Severities: q["severity"],
+		Matchers:   q.Get("match"),
+		RuleIDs:    q["ruleId"],
+		Sort:       q.Get("sort"),
+		Cursor:     q.Get("cursor"),
+	}
+	var err error
+	if v := q.Get("minAge"); v != "" {
+		if query.MinAge, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("invalid minAge: %w", err)
+		}
+	}
+	if v := q.Get("maxAge"); v != "" {
+		if query.MaxAge, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("invalid maxAge: %w", err)
+		}
+	}
+	for name, dest := range map[string]**bool{"acked": &query.Acked, "silenced": &query.Silenced} {
+		if v := q.Get(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*dest = &b
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if query.Limit, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("invalid limit: %w", err)
+		}
+	}
+	if v := q.Get("fields"); v != "" {
+		query.Fields = strings.Split(v, ",")
+	}
+	if v := q.Get("groupBy"); v != "" {
+		query.GroupBy = strings.Split(v, ",")
+	}
+	return query, nil
+}
+
+// listActiveAlerts returns a page of the active alerts of all the rules,
+// filtered and sorted on the server. The filters and the sort of the saved
+// view are used when the view is set.
+func (aH *APIHandler) listActiveAlerts(w http.ResponseWriter, r *http.Request) {
+	query, err := parseAlertListQuery(r)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	if id := r.URL.Query().Get("view"); id != "" {
+		view, apiErr := aH.ruleManager.GetAlertView(r.Context(), id)
+		if apiErr != nil {
+			RespondError(w, apiErr, nil)
+			return
+		}
+		view.Query.Apply(query)
+	}
+	list, apiErr := aH.ruleManager.ListAlerts(r.Context(), query)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, list)
+}
+
+func (aH *APIHandler) listAlertViews(w http.ResponseWriter, r *http.Request) {
+	views, apiErr := aH.ruleManager.ListAlertViews(r.Context())
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, views)
+}
+
+func (aH *APIHandler) getAlertView(w http.ResponseWriter, r *http.Request) {
+	view, apiErr := aH.ruleManager.GetAlertView(r.Context(), mux.Vars(r)["id"])
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, view)
+}
+
+func (aH *APIHandler) createAlertView(w http.ResponseWriter, r *http.Request) {
+	var view rules.AlertView
+	if err := json.NewDecoder(r.Body).Decode(&view); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	if apiErr := aH.ruleManager.CreateAlertView(r.Context(), &view); apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, view)
+}
+
+func (aH *APIHandler) editAlertView(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	var view rules.AlertView
+	if err := json.NewDecoder(r.Body).Decode(&view); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	if apiErr := aH.ruleManager.EditAlertView(r.Context(), &view, id); apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, nil)
+}
+
+func (aH *APIHandler) deleteAlertView(w http.ResponseWriter, r *http.Request) {
+	if apiErr := aH.ruleManager.DeleteAlertView(r.Context(), mux.Vars(r)["id"]); apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, nil)
+}
+
+// getRuleAlerts returns the active alerts of the rule with their recent
+// values
+func (aH *APIHandler) getRuleAlerts(w http.ResponseWriter, r *http.Request) {
+	alerts, apiErr := aH.ruleManager.RuleAlerts(mux.Vars(r)["id"])
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, alerts)
+}
+
+// getAlertDetail returns the active alert with the runbook of its rule
+func (aH *APIHandler) getAlertDetail(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	fingerprint, err := strconv.ParseUint(mux.Vars(r)["fingerprint"], 10, 64)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("invalid fingerprint %q", mux.Vars(r)["fingerprint"])}, nil)
+		return
+	}
+
+	detail, apiErr := aH.ruleManager.AlertDetail(r.Context(), id, fingerprint)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, detail)
+}
+
+// alertEventHeartbeat keeps the idle alert streams open through the proxies
+const alertEventHeartbeat = 30 * time.Second
+
+// parseEventFilter parses the selection of the alert streams, e.g.
+// ?match={service_name="checkout"}&ruleId=1&type=firing&type=resolved
+func parseEventFilter(r *http.Request) (*rules.EventFilter, *model.ApiError) {
+	q := r.URL.Query()
+	filter := &rules.EventFilter{Matchers: q.Get("match"), RuleIDs: q["ruleId"]}
+	for _, t := range q["type"] {
+		filter.Types = append(filter.Types, rules.EventType(t))
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorBadData, Err: err}
+	}
+	return filter, nil
+}
+
+// watchAlertEvents sends the events of the rules of the org selected by the
+// filter until the request is done or the client is gone
+func (aH *APIHandler) watchAlertEvents(ctx context.Context, filter *rules.EventFilter, send func(rules.AlertEvent) error, heartbeat func() error) {
+	sub := aH.ruleManager.SubscribeEvents(0)
+	defer sub.Close()
+	tick := time.NewTicker(alertEventHeartbeat)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			if err := heartbeat(); err != nil {
+				return
+			}
+		case e, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if !filter.Matches(e) || !aH.ruleManager.AlertEventVisible(ctx, e) {
+				continue
+			}
+			if err := send(e); err != nil {
+				zap.L().Debug("alert stream closed", zap.Error(err))
+				return
+			}
+		}
+	}
+}
+
+// streamAlertEvents streams the state changes of the alerts and the health
+// changes of the rules as server-sent events
+func (aH *APIHandler) streamAlertEvents(w http.ResponseWriter, r *http.Request) {
+	filter, apiErr := parseEventFilter(r)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		RespondError(w, &model.ApiError{Typ: model.ErrorStreamingNotSupported, Err: errors.New("streaming is not supported")}, nil)
+		return
+	}
+
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	aH.watchAlertEvents(r.Context(), filter, func(e rules.AlertEvent) error {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}, func() error {
+		if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	})
+}
+
+// wsAlertEvents streams the state changes of the alerts and the health
+// changes of the rules over a websocket
+func (aH *APIHandler) wsAlertEvents(w http.ResponseWriter, r *http.Request) {
+	filter, apiErr := parseEventFilter(r)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+
+	// the auth token may be passed as the protocol, see GetQueryProgressUpdates
+	upgradeResponseHeaders := http.Header{}
+	if protocol := r.Header.Get("Sec-WebSocket-Protocol"); protocol != "" {
+		upgradeResponseHeaders.Add("Sec-WebSocket-Protocol", protocol)
+	}
+	c, err := aH.Upgrader.Upgrade(w, r, upgradeResponseHeaders)
+	if err != nil {
+		RespondError(w, model.InternalError(fmt.Errorf("couldn't upgrade connection: %w", err)), nil)
+		return
+	}
+	defer c.Close()
+
+	// the context of the request is not done when the client closes the
+	// websocket, the reads tell
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	aH.watchAlertEvents(ctx, filter, func(e rules.AlertEvent) error {
+		return c.WriteJSON(e)
+	}, func() error {
+		return c.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second))
+	})
+}
+
+// getRuleRunbook returns the runbook of the rule, rendered to html for the
+// stored ones
+func (aH *APIHandler) getRuleRunbook(w http.ResponseWriter, r *http.Request) {
+	runbook, apiErr := aH.ruleManager.RuleRunbook(r.Context(), mux.Vars(r)["id"])
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, runbook)
+}
+
+func (aH *APIHandler) getRelatedToAlert(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	fingerprint, err := strconv.ParseUint(mux.Vars(r)["fingerprint"], 10, 64)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("invalid fingerprint %q", mux.Vars(r)["fingerprint"])}, nil)
+		return
+	}
+
+	res, apiErr := aH.ruleManager.RelatedToAlert(r.Context(), id, fingerprint)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, res)
+}
+
+// acknowledgeAlert records that the firing alert was acknowledged
+func (aH *APIHandler) acknowledgeAlert(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	fingerprint, err := strconv.ParseUint(mux.Vars(r)["fingerprint"], 10, 64)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("invalid fingerprint %q", mux.Vars(r)["fingerprint"])}, nil)
+		return
+	}
+
+	ack, apiErr := aH.ruleManager.AcknowledgeAlert(r.Context(), id, fingerprint)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, ack)
+}
+
+// exportStateHistory writes the state history or the notification log as
+// a csv, json or ndjson file
+func (aH *APIHandler) exportStateHistory(w http.ResponseWriter, r *http.Request) {
+	req := rules.ExportRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		typ := model.ErrorBadData
+		if errors.Is(err, rules.ErrExportParquet) {
+			typ = model.ErrorNotImplemented
+		}
+		RespondError(w, &model.ApiError{Typ: typ, Err: err}, nil)
+		return
+	}
+
+	// the file is built before it is sent so that the failures are
+	// reported as errors
+	buf := &bytes.Buffer{}
+	if apiErr := aH.ruleManager.Export(r.Context(), &req, buf); apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	w.Header().Set("Content-Type", req.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", req.FileName()))
+	w.Write(buf.Bytes())
+}
+
+// getStateHistoryRetention returns how long the rule state history is kept
+func (aH *APIHandler) getStateHistoryRetention(w http.ResponseWriter, r *http.Request) {
+	retention, err := aH.ruleManager.StateHistoryRetention()
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorUnavailable, Err: err}, nil)
+		return
+	}
+	aH.Respond(w, retention)
+}
+
+// setStateHistoryRetention changes how long the rule state history is kept
+func (aH *APIHandler) setStateHistoryRetention(w http.ResponseWriter, r *http.Request) {
+	var retention rules.StateHistoryRetention
+	if err := json.NewDecoder(r.Body).Decode(&retention); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	if err := retention.Validate(); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	if err := aH.ruleManager.SetStateHistoryRetention(r.Context(), retention); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+	aH.Respond(w, retention)
+}
+
+// getSlowestRules returns the rules with the longest recent evaluations
+func (aH *APIHandler) getSlowestRules(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil {
+			RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("invalid limit %q", l)}, nil)
+			return
+		}
+	}
+	aH.Respond(w, aH.ruleManager.SlowestRules(limit))
+}
+
+// profileRule returns a cpu profile taken during the next evaluation of
+// the rule, in the pprof format
+func (aH *APIHandler) profileRule(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	var wait time.Duration
+	if v := r.URL.Query().Get("wait"); v != "" {
+		var err error
+		wait, err = time.ParseDuration(v)
+		if err != nil {
+			RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("invalid wait %q", v)}, nil)
+			return
+		}
+	}
+
+	profile, apiErr := aH.ruleManager.ProfileRule(r.Context(), id, wait)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=rule-%s.pprof", id))
+	w.Write(profile)
+}
+
+// startStorm sends a storm of synthetic alerts through the notification
+// pipeline to the sandbox channels
+func (aH *APIHandler) startStorm(w http.ResponseWriter, r *http.Request) {
+	req := rules.StormRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	status, apiErr := aH.ruleManager.StartStorm(&req)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, status)
+}
+
+// getStormStatus returns the progress of the running or the last storm
+func (aH *APIHandler) getStormStatus(w http.ResponseWriter, r *http.Request) {
+	status, apiErr := aH.ruleManager.StormStatus()
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, status)
+}
+
+// stopStorm stops the running storm and resolves its alerts
+func (aH *APIHandler) stopStorm(w http.ResponseWriter, r *http.Request) {
+	status, apiErr := aH.ruleManager.StopStorm()
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, status)
+}
+
+// simulateRouting replays the alerts of the last days with a proposed
+// change of the channels, silences and maintenances
+func (aH *APIHandler) simulateRouting(w http.ResponseWriter, r *http.Request) {
+	req := rules.RoutingSimulationRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	sim, apiErr := aH.ruleManager.SimulateRouting(r.Context(), &req)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, sim)
+}
+
+// getSchedulerStatus returns the scheduled rule tasks with their last and
+// next runs, and the evaluations waiting for or running in the workers
+func (aH *APIHandler) getSchedulerStatus(w http.ResponseWriter, r *http.Request) {
+	aH.Respond(w, aH.ruleManager.SchedulerStatus())
+}
+
+// pauseScheduler stops the scheduled evaluations of all rules
+func (aH *APIHandler) pauseScheduler(w http.ResponseWriter, r *http.Request) {
+	aH.Respond(w, aH.ruleManager.PauseScheduler(r.Context(), true))
+}
+
+// resumeScheduler resumes the scheduled evaluations of all rules
+func (aH *APIHandler) resumeScheduler(w http.ResponseWriter, r *http.Request) {
+	aH.Respond(w, aH.ruleManager.PauseScheduler(r.Context(), false))
+}
+
+// evaluateRule evaluates the rule immediately, also when the scheduler is
+// paused, and responds with its alerts once the evaluation is done
+func (aH *APIHandler) evaluateRule(w http.ResponseWriter, r *http.Request) {
+	req := rules.RuleEvaluationRequest{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+			return
+		}
+	}
+	eval, apiErr := aH.ruleManager.EvaluateRuleNow(r.Context(), mux.Vars(r)["id"], &req)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, eval)
+}
+
+// estimateRuleCost estimates the query cost of a rule before it is saved
+func (aH *APIHandler) estimateRuleCost(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	estimate, apiErr := aH.ruleManager.EstimatePostableRuleCost(r.Context(), string(body))
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, estimate)
+}
+
+// suggestThresholds suggests thresholds for the query of a rule being
+// edited from the values of the query over the lookback
+func (aH *APIHandler) suggestThresholds(w http.ResponseWriter, r *http.Request) {
+	req := rules.ThresholdSuggestionRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	suggestion, apiErr := aH.ruleManager.SuggestThresholds(r.Context(), &req)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, suggestion)
+}
+
+// importCloudWatchAlarms converts the cloudwatch alarms into threshold
+// rules, the rules are created unless it is a dry run
+func (aH *APIHandler) importCloudWatchAlarms(w http.ResponseWriter, r *http.Request) {
+	req := rules.CloudWatchImportRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	conversions, apiErr := aH.ruleManager.ImportCloudWatchAlarms(r.Context(), &req)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, conversions)
+}
+
+// exportAlertingConfig returns the whole alerting config as a bundle, the
+// secrets of the channels are redacted unless ?secrets=true
+func (aH *APIHandler) exportAlertingConfig(w http.ResponseWriter, r *http.Request) {
+	bundle, apiErr := aH.ruleManager.ExportConfig(r.Context(), r.URL.Query().Get("secrets") == "true")
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, bundle)
+}
+
+// importAlertingConfig imports a bundle exported by another environment
+func (aH *APIHandler) importAlertingConfig(w http.ResponseWriter, r *http.Request) {
+	req := rules.ConfigImportRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	results, apiErr := aH.ruleManager.ImportConfig(r.Context(), &req)
+	if apiErr != nil {
+		RespondError(w, apiErr, results)
+		return
+	}
+	aH.Respond(w, results)
+}
+
+// cloneRule creates copies of the rule with other variable values, the
+// body lists the instances, e.g.
+// {"instances": [{"variables": {"env": "staging"}}, {"variables": {"env": "prod"}}]}
+func (aH *APIHandler) cloneRule(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		Instances []rules.RuleInstance `json:"instances"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	results, apiErr := aH.ruleManager.CloneRule(r.Context(), mux.Vars(r)["id"], req.Instances)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, results)
+}
+
+// estimateStoredRuleCost estimates the query cost of a saved rule
+func (aH *APIHandler) estimateStoredRuleCost(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	estimate, apiErr := aH.ruleManager.EstimateStoredRuleCost(r.Context(), id)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, estimate)
+}
+
+// getRuleQueryCost returns the data read by the recent evaluations of the rule
+func (aH *APIHandler) getRuleQueryCost(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	aH.Respond(w, aH.ruleManager.GetRuleQueryCost(id))
+}
+
+// getTemplateFunctions lists the functions available in alert templates
+// for the editor autocomplete
+func (aH *APIHandler) getTemplateFunctions(w http.ResponseWriter, r *http.Request) {
+	aH.Respond(w, rules.TemplateFunctionDocs())
+}
+
+// previewTemplate renders an annotation template for the live preview
+// in the rule editor
+func (aH *APIHandler) previewTemplate(w http.ResponseWriter, r *http.Request) {
+	req := rules.TemplatePreviewRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	if req.RuleId == "" && req.Sample == nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("either ruleId or sample is required")}, nil)
+		return
+	}
+
+	resp, apiErr := aH.ruleManager.PreviewTemplate(r.Context(), &req)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, resp)
+}
+
+// previewNotifications renders what the channels of a rule receive when
+// its alerts fire and resolve, before the rule is saved
+func (aH *APIHandler) previewNotifications(w http.ResponseWriter, r *http.Request) {
+	req := rules.NotificationPreviewRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	resp, apiErr := aH.ruleManager.PreviewNotifications(r.Context(), &req)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, resp)
+}
+
+// populateTemporality adds the temporality to the query if it is not present
+func (aH *APIHandler) populateTemporality(ctx context.Context, qp *v3.QueryRangeParamsV3) error {
+
+	aH.temporalityMux.Lock()
+	defer aH.temporalityMux.Unlock()
+
+	missingTemporality := make([]string, 0)
+	metricNameToTemporality := make(map[string]map[v3.Temporality]bool)
+	if qp.CompositeQuery != nil && len(qp.CompositeQuery.BuilderQueries) > 0 {
+		for _, query := range qp.CompositeQuery.BuilderQueries {
+			// if there is no temporality specified in the query but we have it in the map
+			// then use the value from the map
+			if query.Temporality == "" && aH.temporalityMap[query.AggregateAttribute.Key] != nil {
+				// We prefer delta if it is available
+				if aH.temporalityMap[query.AggregateAttribute.Key][v3.Delta] {
+					query.Temporality = v3.Delta
+				} else if aH.temporalityMap[query.AggregateAttribute.Key][v3.Cumulative] {
+					query.Temporality = v3.Cumulative
+				} else {
+					query.Temporality = v3.Unspecified
+				}
+			}
+			// we don't have temporality for this metric
+			if query.DataSource == v3.DataSourceMetrics && query.Temporality == "" {
+				missingTemporality = append(missingTemporality, query.AggregateAttribute.Key)
+			}
+			if _, ok := metricNameToTemporality[query.AggregateAttribute.Key]; !ok {
+				metricNameToTemporality[query.AggregateAttribute.Key] = make(map[v3.Temporality]bool)
+			}
+		}
+	}
+
+	nameToTemporality, err := aH.reader.FetchTemporality(ctx, missingTemporality)
+	if err != nil {
+		return err
+	}
+
+	if qp.CompositeQuery != nil && len(qp.CompositeQuery.BuilderQueries) > 0 {
+		for name := range qp.CompositeQuery.BuilderQueries {
+			query := qp.CompositeQuery.BuilderQueries[name]
+			if query.DataSource == v3.DataSourceMetrics && query.Temporality == "" {
+				if nameToTemporality[query.AggregateAttribute.Key][v3.Delta] {
+					query.Temporality = v3.Delta
+				} else if nameToTemporality[query.AggregateAttribute.Key][v3.Cumulative] {
+					query.Temporality = v3.Cumulative
+				} else {
+					query.Temporality = v3.Unspecified
+				}
+				aH.temporalityMap[query.AggregateAttribute.Key] = nameToTemporality[query.AggregateAttribute.Key]
+			}
+		}
+	}
+	return nil
+}
+
+func (aH *APIHandler) listDowntimeSchedules(w http.ResponseWriter, r *http.Request) {
+	schedules, err := aH.ruleManager.RuleDB().GetAllPlannedMaintenance(r.Context())
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+
+	// The schedules are stored as JSON in the database, so we need to filter them here
+	// Since the number of schedules is expected to be small, this should be fine
+
+	if r.URL.Query().Get("active") != "" {
+		activeSchedules := make([]rules.PlannedMaintenance, 0)
+		active, _ := strconv.ParseBool(r.URL.Query().Get("active"))
+		for _, schedule := range schedules {
+			now := time.Now().In(time.FixedZone(schedule.Schedule.Timezone, 0))
+			if schedule.IsActive(now) == active {
+				activeSchedules = append(activeSchedules, schedule)
+			}
+		}
+		schedules = activeSchedules
+	}
+
+	if r.URL.Query().Get("recurring") != "" {
+		recurringSchedules := make([]rules.PlannedMaintenance, 0)
+		recurring, _ := strconv.ParseBool(r.URL.Query().Get("recurring"))
+		for _, schedule := range schedules {
+			if schedule.IsRecurring() == recurring {
+				recurringSchedules = append(recurringSchedules, schedule)
+			}
+		}
+		schedules = recurringSchedules
+	}
+
+	aH.Respond(w, schedules)
+}
+
+func (aH *APIHandler) getDowntimeSchedule(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	schedule, err := aH.ruleManager.RuleDB().GetPlannedMaintenanceByID(r.Context(), id)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+	aH.Respond(w, schedule)
+}
+
+func (aH *APIHandler) createDowntimeSchedule(w http.ResponseWriter, r *http.Request) {
+	var schedule rules.PlannedMaintenance
+	err := json.NewDecoder(r.Body).Decode(&schedule)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	if err := schedule.Validate(); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	_, err = aH.ruleManager.RuleDB().CreatePlannedMaintenance(r.Context(), schedule)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+	aH.Respond(w, nil)
+}
+
+func (aH *APIHandler) editDowntimeSchedule(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	var schedule rules.PlannedMaintenance
+	err := json.NewDecoder(r.Body).Decode(&schedule)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	if err := schedule.Validate(); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	_, err = aH.ruleManager.RuleDB().EditPlannedMaintenance(r.Context(), schedule, id)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+	aH.Respond(w, nil)
+}
+
+func (aH *APIHandler) deleteDowntimeSchedule(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	_, err := aH.ruleManager.RuleDB().DeletePlannedMaintenance(r.Context(), id)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+	aH.Respond(w, nil)
+}
+
+func (aH *APIHandler) listTemplateSnippets(w http.ResponseWriter, r *http.Request) {
+	snippets, err := aH.ruleManager.RuleDB().GetTemplateSnippets(r.Context())
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+	aH.Respond(w, snippets)
+}
+
+func (aH *APIHandler) getTemplateSnippet(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	snippet, err := aH.ruleManager.RuleDB().GetTemplateSnippetByID(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			RespondError(w, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("template snippet %s not found", id)}, nil)
+			return
+		}
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+	aH.Respond(w, snippet)
+}
+
+func (aH *APIHandler) createTemplateSnippet(w http.ResponseWriter, r *http.Request) {
+	var snippet rules.TemplateSnippet
+	if err := json.NewDecoder(r.Body).Decode(&snippet); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	if err := snippet.Validate(); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	id, err := aH.ruleManager.RuleDB().CreateTemplateSnippet(r.Context(), snippet)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+	aH.reloadTemplateSnippets(r.Context())
+	snippet.Id = id
+	aH.Respond(w, snippet)
+}
+
+func (aH *APIHandler) editTemplateSnippet(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	var snippet rules.TemplateSnippet
+	if err := json.NewDecoder(r.Body).Decode(&snippet); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	if err := snippet.Validate(); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	if err := aH.ruleManager.RuleDB().EditTemplateSnippet(r.Context(), snippet, id); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+	aH.reloadTemplateSnippets(r.Context())
+	aH.Respond(w, nil)
+}
+
+func (aH *APIHandler) deleteTemplateSnippet(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if err := aH.ruleManager.RuleDB().DeleteTemplateSnippet(r.Context(), id); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+	aH.reloadTemplateSnippets(r.Context())
+	aH.Respond(w, nil)
+}
+
+// reloadTemplateSnippets makes the rules use the updated snippets, the
+// change is already stored so a failure is only logged
+func (aH *APIHandler) reloadTemplateSnippets(ctx context.Context) {
+	if err := aH.ruleManager.ReloadTemplateSnippets(ctx); err != nil {
+		zap.L().Error("failed to reload template snippets", zap.Error(err))
+	}
+}
+
+func (aH *APIHandler) listCustomUnits(w http.ResponseWriter, r *http.Request) {
+	units, apiErr := aH.ruleManager.CustomUnits(r.Context())
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, units)
+}
+
+// createCustomUnit registers a unit the thresholds are converted with and
+// the notifications show the values in
+func (aH *APIHandler) createCustomUnit(w http.ResponseWriter, r *http.Request) {
+	var unit converter.CustomUnit
+	if err := json.NewDecoder(r.Body).Decode(&unit); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	if apiErr := aH.ruleManager.CreateCustomUnit(r.Context(), &unit); apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, unit)
+}
+
+func (aH *APIHandler) deleteCustomUnit(w http.ResponseWriter, r *http.Request) {
+	if apiErr := aH.ruleManager.DeleteCustomUnit(r.Context(), mux.Vars(r)["name"]); apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, nil)
+}
+
+func (aH *APIHandler) getFormatSettings(w http.ResponseWriter, r *http.Request) {
+	settings, apiErr := aH.ruleManager.FormatSettings(r.Context())
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, settings)
+}
+
+// setFormatSettings sets the separators and the precision the values of
+// the alerts of the org are formatted with
+func (aH *APIHandler) setFormatSettings(w http.ResponseWriter, r *http.Request) {
+	var settings rules.FormatSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	if apiErr := aH.ruleManager.SetFormatSettings(r.Context(), &settings); apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, settings)
+}
+
+func (aH *APIHandler) deleteFormatSettings(w http.ResponseWriter, r *http.Request) {
+	if apiErr := aH.ruleManager.DeleteFormatSettings(r.Context()); apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, nil)
+}
+
+func (aH *APIHandler) listCalendars(w http.ResponseWriter, r *http.Request) {
+	calendars, apiErr := aH.ruleManager.ListCalendars(r.Context())
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, calendars)
+}
+
+func (aH *APIHandler) createCalendar(w http.ResponseWriter, r *http.Request) {
+	var calendar rules.Calendar
+	if err := json.NewDecoder(r.Body).Decode(&calendar); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	if apiErr := aH.ruleManager.CreateCalendar(r.Context(), &calendar); apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, calendar)
+}
+
+// importCalendar creates or replaces a calendar with the events of an
+// iCalendar file
+func (aH *APIHandler) importCalendar(w http.ResponseWriter, r *http.Request) {
+	var req rules.CalendarImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	calendar, apiErr := aH.ruleManager.ImportCalendar(r.Context(), &req)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, calendar)
+}
+
+func (aH *APIHandler) editCalendar(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	var calendar rules.Calendar
+	if err := json.NewDecoder(r.Body).Decode(&calendar); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	if apiErr := aH.ruleManager.EditCalendar(r.Context(), &calendar, id); apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, nil)
+}
+
+func (aH *APIHandler) deleteCalendar(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if apiErr := aH.ruleManager.DeleteCalendar(r.Context(), id); apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, nil)
+}
+
+func (aH *APIHandler) listServiceEntries(w http.ResponseWriter, r *http.Request) {
+	entries, apiErr := aH.ruleManager.ListServiceEntries(r.Context())
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, entries)
+}
+
+func (aH *APIHandler) createServiceEntry(w http.ResponseWriter, r *http.Request) {
+	var entry rules.ServiceEntry
+	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	if apiErr := aH.ruleManager.CreateServiceEntry(r.Context(), &entry); apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, entry)
+}
+
+func (aH *APIHandler) editServiceEntry(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	var entry rules.ServiceEntry
+	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	if apiErr := aH.ruleManager.EditServiceEntry(r.Context(), &entry, id); apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, nil)
+}
+
+func (aH *APIHandler) deleteServiceEntry(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
-	_, err := aH.ruleManager.RuleDB().DeletePlannedMaintenance(r.Context(), id)
-	if err != nil {
-		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+	if apiErr := aH.ruleManager.DeleteServiceEntry(r.Context(), id); apiErr != nil {
+		RespondError(w, apiErr, nil)
 		return
 	}
 	aH.Respond(w, nil)
@@ -1230,18 +2882,79 @@ func (aH *APIHandler) editRule(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// checkChannelAccess makes sure the channel belongs to the org of the
+// user making the request, shared channels of other orgs can be read
+// but not changed
+func (aH *APIHandler) checkChannelAccess(r *http.Request, id string, edit bool) (*model.ChannelItem, *model.ApiError) {
+	channel, apiErrorObj := aH.reader.GetChannel(id)
+	if apiErrorObj != nil {
+		return nil, apiErrorObj
+	}
+	user := common.GetUserFromContext(r.Context())
+	if user == nil {
+		return channel, nil
+	}
+	if !channel.VisibleTo(user.OrgId) {
+		return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("channel %s not found", id)}
+	}
+	if edit && !channel.OwnedBy(user.OrgId) {
+		return nil, &model.ApiError{Typ: model.ErrorForbidden, Err: fmt.Errorf("channel %s is shared by another org and can not be changed", channel.Name)}
+	}
+	if edit {
+		stored := &am.Receiver{}
+		if err := json.Unmarshal([]byte(channel.Data), stored); err == nil && !auth.IsTeamMember(user, stored.Team) {
+			return nil, &model.ApiError{Typ: model.ErrorForbidden, Err: fmt.Errorf("channel %s is owned by team %s and can only be changed by its members", channel.Name, stored.Team)}
+		}
+	}
+	return channel, nil
+}
+
+// checkChannelTeam refuses the channels of the teams of which the user is
+// not a member
+func checkChannelTeam(r *http.Request, receiver *am.Receiver) *model.ApiError {
+	user := common.GetUserFromContext(r.Context())
+	if user != nil && !auth.IsTeamMember(user, receiver.Team) {
+		return &model.ApiError{Typ: model.ErrorForbidden, Err: fmt.Errorf("channels of team %s can only be saved by its members", receiver.Team)}
+	}
+	return nil
+}
+
 func (aH *APIHandler) getChannel(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
-	channel, apiErrorObj := aH.reader.GetChannel(id)
+	channel, apiErrorObj := aH.checkChannelAccess(r, id, false)
 	if apiErrorObj != nil {
 		RespondError(w, apiErrorObj, nil)
 		return
 	}
+	if apiErrorObj := redactChannel(r, channel); apiErrorObj != nil {
+		RespondError(w, apiErrorObj, nil)
+		return
+	}
 	aH.Respond(w, channel)
 }
 
+// redactChannel hides the secrets of the channel from the users, the
+// channels shared by other orgs only show their integrations
+func redactChannel(r *http.Request, channel *model.ChannelItem) *model.ApiError {
+	data, err := am.RedactSecrets([]byte(channel.Data))
+	if err == nil {
+		if user := common.GetUserFromContext(r.Context()); user != nil && !channel.OwnedBy(user.OrgId) {
+			data, err = am.RedactShared(data)
+		}
+	}
+	if err != nil {
+		return &model.ApiError{Typ: model.ErrorInternal, Err: err}
+	}
+	channel.Data = string(data)
+	return nil
+}
+
 func (aH *APIHandler) deleteChannel(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
+	if _, apiErrorObj := aH.checkChannelAccess(r, id, true); apiErrorObj != nil {
+		RespondError(w, apiErrorObj, nil)
+		return
+	}
 	apiErrorObj := aH.reader.DeleteChannel(id)
 	if apiErrorObj != nil {
 		RespondError(w, apiErrorObj, nil)
@@ -1256,9 +2969,50 @@ func (aH *APIHandler) listChannels(w http.ResponseWriter, r *http.Request) {
 		RespondError(w, apiErrorObj, nil)
 		return
 	}
+
+	// the alert manager lists the channels through the private
+	// router without a user and needs all of them, with their secrets
+	user := common.GetUserFromContext(r.Context())
+	if user == nil {
+		checked := checkChannelsEgress(*channels, am.DefaultEgressPolicy())
+		channels = &checked
+	} else {
+		visible := []model.ChannelItem{}
+		for _, channel := range *channels {
+			if !channel.VisibleTo(user.OrgId) {
+				continue
+			}
+			if apiErrorObj := redactChannel(r, &channel); apiErrorObj != nil {
+				RespondError(w, apiErrorObj, nil)
+				return
+			}
+			visible = append(visible, channel)
+		}
+		channels = &visible
+	}
 	aH.Respond(w, channels)
 }
 
+// checkChannelsEgress empties the integrations of the channels whose
+// destinations are not allowed by the egress policy, e.g. saved before the
+// allowlist was tightened or with a referenced secret changed since, so
+// that the alert manager does not deliver to them
+func checkChannelsEgress(channels []model.ChannelItem, policy *am.EgressPolicy) []model.ChannelItem {
+	checked := make([]model.ChannelItem, 0, len(channels))
+	for _, channel := range channels {
+		receiver := &am.Receiver{}
+		if err := json.Unmarshal([]byte(channel.Data), receiver); err == nil {
+			if err := policy.CheckReceiver(receiver); err != nil {
+				zap.L().Error("the channel is not delivered, its destinations are not allowed", zap.String("name", channel.Name), zap.Error(err))
+				data, _ := json.Marshal(am.Receiver{Name: receiver.Name})
+				channel.Data = string(data)
+			}
+		}
+		checked = append(checked, channel)
+	}
+	return checked
+}
+
 // testChannels sends test alert to all registered channels
 func (aH *APIHandler) testChannel(w http.ResponseWriter, r *http.Request) {
 
@@ -1276,7 +3030,30 @@ func (aH *APIHandler) testChannel(w http.ResponseWriter, r *http.Request) {
 		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
 		return
 	}
+	if err := receiver.Validate(am.DefaultEgressPolicy()); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	// push channels are delivered by query-service
+	if len(receiver.PushConfigs) > 0 {
+		if apiErrorObj := aH.ruleManager.TestPushChannel(r.Context(), receiver); apiErrorObj != nil {
+			RespondError(w, apiErrorObj, nil)
+			return
+		}
+		aH.Respond(w, "test alert sent")
+		return
+	}
+	// so are irc and xmpp channels
+	if len(receiver.IRCConfigs) > 0 || len(receiver.XMPPConfigs) > 0 {
+		if apiErrorObj := aH.ruleManager.TestChatChannel(r.Context(), receiver); apiErrorObj != nil {
+			RespondError(w, apiErrorObj, nil)
+			return
+		}
+		aH.Respond(w, "test alert sent")
+		return
+	}
 	// send alert
+	receiver.SetLocale(am.ResolveLocale(aH.reader, receiver.Locale))
 	apiErrorObj := aH.alertManager.TestReceiver(receiver)
 	if apiErrorObj != nil {
 		RespondError(w, apiErrorObj, nil)
@@ -1304,6 +3081,16 @@ func (aH *APIHandler) editChannel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if _, apiErrorObj := aH.checkChannelAccess(r, id, true); apiErrorObj != nil {
+		RespondError(w, apiErrorObj, nil)
+		return
+	}
+
+	if apiErrorObj := checkChannelTeam(r, receiver); apiErrorObj != nil {
+		RespondError(w, apiErrorObj, nil)
+		return
+	}
+
 	_, apiErrorObj := aH.reader.EditChannel(receiver, id)
 
 	if apiErrorObj != nil {
@@ -1332,7 +3119,17 @@ func (aH *APIHandler) createChannel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, apiErrorObj := aH.reader.CreateChannel(receiver)
+	orgId := ""
+	if user := common.GetUserFromContext(r.Context()); user != nil {
+		orgId = user.OrgId
+	}
+
+	if apiErrorObj := checkChannelTeam(r, receiver); apiErrorObj != nil {
+		RespondError(w, apiErrorObj, nil)
+		return
+	}
+
+	_, apiErrorObj := aH.reader.CreateChannel(receiver, orgId)
 
 	if apiErrorObj != nil {
 		RespondError(w, apiErrorObj, nil)
@@ -1343,6 +3140,338 @@ func (aH *APIHandler) createChannel(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// listLocales returns the locale catalog used to render notifications
+func (aH *APIHandler) listLocales(w http.ResponseWriter, r *http.Request) {
+	locales, apiErrorObj := aH.reader.GetLocales()
+	if apiErrorObj != nil {
+		RespondError(w, apiErrorObj, nil)
+		return
+	}
+	aH.Respond(w, locales)
+}
+
+func (aH *APIHandler) saveLocale(w http.ResponseWriter, r *http.Request) {
+	locale := &am.Locale{}
+	if err := json.NewDecoder(r.Body).Decode(locale); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	locale, apiErrorObj := aH.reader.SaveLocale(locale)
+	if apiErrorObj != nil {
+		RespondError(w, apiErrorObj, nil)
+		return
+	}
+	aH.Respond(w, locale)
+}
+
+func (aH *APIHandler) deleteLocale(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	apiErrorObj := aH.reader.DeleteLocale(name)
+	if apiErrorObj != nil {
+		RespondError(w, apiErrorObj, nil)
+		return
+	}
+	aH.Respond(w, "locale successfully deleted")
+}
+
+// listPushDevices returns the devices registered by the current user
+func (aH *APIHandler) listPushDevices(w http.ResponseWriter, r *http.Request) {
+	user := common.GetUserFromContext(r.Context())
+	devices, apiErrorObj := aH.reader.GetPushDevices(user.Id)
+	if apiErrorObj != nil {
+		RespondError(w, apiErrorObj, nil)
+		return
+	}
+	aH.Respond(w, devices)
+}
+
+func (aH *APIHandler) registerPushDevice(w http.ResponseWriter, r *http.Request) {
+	user := common.GetUserFromContext(r.Context())
+	device := &am.PushDevice{}
+	if err := json.NewDecoder(r.Body).Decode(device); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	device.UserId = user.Id
+
+	device, apiErrorObj := aH.reader.RegisterPushDevice(device)
+	if apiErrorObj != nil {
+		RespondError(w, apiErrorObj, nil)
+		return
+	}
+	aH.Respond(w, device)
+}
+
+func (aH *APIHandler) deletePushDevice(w http.ResponseWriter, r *http.Request) {
+	user := common.GetUserFromContext(r.Context())
+	id := mux.Vars(r)["id"]
+	if apiErrorObj := aH.reader.DeletePushDevice(user.Id, id); apiErrorObj != nil {
+		RespondError(w, apiErrorObj, nil)
+		return
+	}
+	aH.Respond(w, "device successfully deleted")
+}
+
+func (aH *APIHandler) getChartSnapshot(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	query := r.URL.Query()
+	png, ok := aH.ruleManager.GetChartSnapshot(r.Context(), id, query.Get("expires"), query.Get("signature"))
+	if !ok {
+		RespondError(w, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("snapshot not found")}, nil)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.WriteHeader(http.StatusOK)
+	w.Write(png)
+}
+
+// receiveInboundAlerts accepts the webhooks of alertmanager, grafana,
+// cloudwatch (through SNS), azure monitor and google cloud monitoring.
+// The channels query param limits the channels the alerts are sent to.
+func (aH *APIHandler) receiveInboundAlerts(w http.ResponseWriter, r *http.Request) {
+	org, ok := inboundTokenOrg(r.Context(), requestToken(r))
+	if !ok {
+		RespondError(w, &model.ApiError{Typ: model.ErrorUnauthorized, Err: fmt.Errorf("invalid inbound alerts token")}, nil)
+		return
+	}
+
+	defer r.Body.Close()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	source := rules.InboundSource(mux.Vars(r)["source"])
+	alerts, err := rules.ParseInboundAlerts(source, body)
+
+	var confirmation *rules.SNSSubscriptionConfirmation
+	if errors.As(err, &confirmation) {
+		if apiErr := confirmSNSSubscription(confirmation); apiErr != nil {
+			RespondError(w, apiErr, nil)
+			return
+		}
+		aH.Respond(w, "subscription confirmed")
+		return
+	}
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	var channels []string
+	if c := r.URL.Query().Get("channels"); c != "" {
+		channels = strings.Split(c, ",")
+	}
+
+	if apiErr := aH.ruleManager.ReceiveExternalAlerts(r.Context(), org, source, alerts, channels); apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, map[string]int{"received": len(alerts)})
+}
+
+// receiveNagiosChecks accepts the submitcheck command of NRDP, so that
+// send_nrdp can submit the passive check results of nagios. The token is
+// the token field of the form, as sent by send_nrdp, or the inbound token.
+func (aH *APIHandler) receiveNagiosChecks(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	token := requestToken(r)
+	if token == "" {
+		token = form.Get("token")
+	}
+	org, ok := inboundTokenOrg(r.Context(), token)
+	if !ok {
+		writeNRDPResult(w, http.StatusUnauthorized, -1, "BAD TOKEN", "")
+		return
+	}
+
+	results, err := rules.ParseNRDPCheckResults(form, time.Now())
+	if err != nil {
+		writeNRDPResult(w, http.StatusBadRequest, -1, "BAD DATA", err.Error())
+		return
+	}
+	if apiErr := aH.receivePassiveChecks(r, org, rules.InboundSourceNagios, results); apiErr != nil {
+		writeNRDPResult(w, http.StatusInternalServerError, -1, "ERROR", apiErr.Error())
+		return
+	}
+	writeNRDPResult(w, http.StatusOK, 0, "OK", fmt.Sprintf("%d checks processed.", len(results)))
+}
+
+// writeNRDPResult writes the xml result of NRDP, which send_nrdp reads
+func writeNRDPResult(w http.ResponseWriter, code, status int, message, output string) {
+	var buf bytes.Buffer
+	buf.WriteString("<?xml version=\"1.0\" ?>\n<result>")
+	fmt.Fprintf(&buf, "<status>%d</status><message>", status)
+	xml.EscapeText(&buf, []byte(message))
+	buf.WriteString("</message>")
+	if output != "" {
+		buf.WriteString("<meta><output>")
+		xml.EscapeText(&buf, []byte(output))
+		buf.WriteString("</output></meta>")
+	}
+	buf.WriteString("</result>\n")
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(code)
+	w.Write(buf.Bytes())
+}
+
+// receiveZabbixChecks accepts the sender data request of zabbix sender,
+// sent over http by the zabbix http agents and scripts. The value of the
+// items is the state of the check, 0 ok, 1 warning, 2 critical or 3
+// unknown.
+func (aH *APIHandler) receiveZabbixChecks(w http.ResponseWriter, r *http.Request) {
+	org, ok := inboundTokenOrg(r.Context(), requestToken(r))
+	if !ok {
+		RespondError(w, &model.ApiError{Typ: model.ErrorUnauthorized, Err: fmt.Errorf("invalid inbound alerts token")}, nil)
+		return
+	}
+
+	defer r.Body.Close()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	start := time.Now()
+	results, failed, err := rules.ParseZabbixSenderData(body, start)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	if apiErr := aH.receivePassiveChecks(r, org, rules.InboundSourceZabbix, results); apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	// the response of the zabbix server
+	aH.WriteJSON(w, r, map[string]string{
+		"response": "success",
+		"info": fmt.Sprintf("processed: %d; failed: %d; total: %d; seconds spent: %f",
+			len(results), failed, len(results)+failed, time.Since(start).Seconds()),
+	})
+}
+
+// receivePassiveChecks receives the alerts of the check results for the
+// org, sent to the channels of the channels query param
+func (aH *APIHandler) receivePassiveChecks(r *http.Request, org string, source rules.InboundSource, results []rules.PassiveCheckResult) *model.ApiError {
+	alerts := make([]rules.ExternalAlert, 0, len(results))
+	for _, c := range results {
+		alerts = append(alerts, c.Alert(source))
+	}
+
+	var channels []string
+	if c := r.URL.Query().Get("channels"); c != "" {
+		channels = strings.Split(c, ",")
+	}
+	return aH.ruleManager.ReceiveExternalAlerts(r.Context(), org, source, alerts, channels)
+}
+
+// inboundTokenOrg returns the org of the inbound alerts token. The tokens
+// of INBOUND_ALERTS_TOKENS belong to their org, INBOUND_ALERTS_TOKEN to
+// the only org of the install and to none once there are several.
+func inboundTokenOrg(ctx context.Context, token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+	for _, pair := range strings.Split(constants.InboundAlertsTokens, ",") {
+		org, expected, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && org != "" && expected != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1 {
+			return org, true
+		}
+	}
+
+	if constants.InboundAlertsToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(constants.InboundAlertsToken)) != 1 {
+		return "", false
+	}
+	return singleOrg(ctx, "INBOUND_ALERTS_TOKEN", "INBOUND_ALERTS_TOKENS")
+}
+
+// singleOrg returns the only org of the install for the token of the env
+// var, the token is refused once there are several orgs and the tokens of
+// the orgs are set in tokensEnv instead
+func singleOrg(ctx context.Context, env, tokensEnv string) (string, bool) {
+	orgs, apiErr := dao.DB().GetOrgs(ctx)
+	if apiErr != nil {
+		zap.L().Error("failed to get the orgs of the token", zap.String("token", env), zap.Error(apiErr.Err))
+		return "", false
+	}
+	if len(orgs) != 1 {
+		zap.L().Warn(fmt.Sprintf("%s is refused when there are several orgs, set the tokens of the orgs in %s", env, tokensEnv))
+		return "", false
+	}
+	return orgs[0].Id, true
+}
+
+// requestToken returns the token of the request, the bearer token or the
+// token query param
+func requestToken(r *http.Request) string {
+	if bearer := r.Header.Get("Authorization"); strings.HasPrefix(bearer, "Bearer ") {
+		return strings.TrimPrefix(bearer, "Bearer ")
+	}
+	return r.URL.Query().Get("token")
+}
+
+// receivePageUpdates accepts the webhooks of pagerduty and opsgenie, the
+// acknowledgements and resolutions of the pages are applied to the alerts
+func (aH *APIHandler) receivePageUpdates(w http.ResponseWriter, r *http.Request) {
+	org, ok := inboundTokenOrg(r.Context(), requestToken(r))
+	if !ok {
+		RespondError(w, &model.ApiError{Typ: model.ErrorUnauthorized, Err: fmt.Errorf("invalid inbound alerts token")}, nil)
+		return
+	}
+
+	defer r.Body.Close()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	updates, err := rules.ParsePageUpdates(rules.PagingProvider(mux.Vars(r)["provider"]), body)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	updated, apiErr := aH.ruleManager.SyncPages(r.Context(), org, updates)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, map[string]int{"updated": updated})
+}
+
+// confirmSNSSubscription visits the subscribe url sent by SNS, only
+// urls of the SNS service are followed
+func confirmSNSSubscription(c *rules.SNSSubscriptionConfirmation) *model.ApiError {
+	u, err := url.Parse(c.SubscribeURL)
+	if err != nil || u.Scheme != "https" || !strings.HasPrefix(u.Host, "sns.") || !strings.HasSuffix(u.Host, ".amazonaws.com") {
+		return &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("invalid sns subscribe url %q", c.SubscribeURL)}
+	}
+	resp, err := http.Get(u.String())
+	if err != nil {
+		return &model.ApiError{Typ: model.ErrorInternal, Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode > 299 {
+		return &model.ApiError{Typ: model.ErrorInternal, Err: fmt.Errorf("sns subscription confirmation failed: %s", resp.Status)}
+	}
+	return nil
+}
+
 func (aH *APIHandler) getAlerts(w http.ResponseWriter, r *http.Request) {
 	params := r.URL.Query()
 	amEndpoint := constants.GetAlertManagerApiPrefix()
@@ -38,6 +38,63 @@ func which(start, end int64) (int64, int64, string) {
 	return start, end, tableName
 }
 
+// WhichSamplesTableToUse returns the samples table the query reads. The
+// aggregated tables are only used when the query allows downsampling and
+// the step in seconds is a multiple of their resolution, so that the
+// intervals of the query are made of whole aggregated rows.
+func WhichSamplesTableToUse(start, end, step int64, mq *v3.BuilderQuery) string {
+	// the distinct values are lost in the aggregated tables
+	if mq.TimeAggregation == v3.TimeAggregationCountDistinct {
+		return constants.SIGNOZ_SAMPLES_V4_TABLENAME
+	}
+	fits1h := step > 0 && step%int64(time.Hour.Seconds()) == 0
+	fits30m := step > 0 && step%int64((30*time.Minute).Seconds()) == 0
+	fits5m := step > 0 && step%int64((5*time.Minute).Seconds()) == 0
+
+	switch mq.Downsampling {
+	case v3.Downsampling1h:
+		if fits1h {
+			return constants.SIGNOZ_SAMPLES_V4_AGG_30M_TABLENAME
+		}
+	case v3.Downsampling30m:
+		if fits30m {
+			return constants.SIGNOZ_SAMPLES_V4_AGG_30M_TABLENAME
+		}
+	case v3.Downsampling5m:
+		if fits5m {
+			return constants.SIGNOZ_SAMPLES_V4_AGG_5M_TABLENAME
+		}
+	case v3.DownsamplingAuto:
+		if end-start > oneDayInMilliseconds && fits30m {
+			return constants.SIGNOZ_SAMPLES_V4_AGG_30M_TABLENAME
+		}
+		if end-start > sixHoursInMilliseconds && fits5m {
+			return constants.SIGNOZ_SAMPLES_V4_AGG_5M_TABLENAME
+		}
+	}
+	return constants.SIGNOZ_SAMPLES_V4_TABLENAME
+}
+
+// SamplesAggregate returns the expression of the aggregate function, one
+// of avg, sum, min, max, count and anyLast, over the values of the samples
+// table
+func SamplesAggregate(tableName, fn string) string {
+	if tableName != constants.SIGNOZ_SAMPLES_V4_AGG_5M_TABLENAME && tableName != constants.SIGNOZ_SAMPLES_V4_AGG_30M_TABLENAME {
+		return fn + "(value)"
+	}
+	// the aggregated tables keep the last, min, max, sum and count of the
+	// samples of every series by interval
+	switch fn {
+	case "avg":
+		return "sum(sum) / sum(count)"
+	case "count":
+		return "sum(count)"
+	case "anyLast":
+		return "anyLast(last)"
+	}
+	return fn + "(" + fn + ")"
+}
+
 // PrepareTimeseriesFilterQuery builds the sub-query to be used for filtering timeseries based on the search criteria
 func PrepareTimeseriesFilterQuery(start, end int64, mq *v3.BuilderQuery) (string, error) {
 	var conditions []string
@@ -532,3 +532,69 @@ func TestPrepareMetricQueryGauge(t *testing.T) {
 		})
 	}
 }
+
+func TestPrepareMetricQueryDownsampling(t *testing.T) {
+	testCases := []struct {
+		name                  string
+		downsampling          v3.Downsampling
+		step                  int64
+		expectedQueryContains string
+	}{
+		{
+			name:                  "raw samples by default",
+			step:                  1800,
+			expectedQueryContains: "avg(value) as per_series_value FROM signoz_metrics.distributed_samples_v4 INNER JOIN",
+		},
+		{
+			name:                  "auto picks the 30m table for a day long range",
+			downsampling:          v3.DownsamplingAuto,
+			step:                  1800,
+			expectedQueryContains: "sum(sum) / sum(count) as per_series_value FROM signoz_metrics.distributed_samples_v4_agg_30m INNER JOIN",
+		},
+		{
+			name:                  "auto picks the 5m table when the step is not a multiple of 30m",
+			downsampling:          v3.DownsamplingAuto,
+			step:                  600,
+			expectedQueryContains: "sum(sum) / sum(count) as per_series_value FROM signoz_metrics.distributed_samples_v4_agg_5m INNER JOIN",
+		},
+		{
+			name:                  "the 1h tier reads the 30m table by whole hours",
+			downsampling:          v3.Downsampling1h,
+			step:                  3600,
+			expectedQueryContains: "sum(sum) / sum(count) as per_series_value FROM signoz_metrics.distributed_samples_v4_agg_30m INNER JOIN",
+		},
+		{
+			name:                  "raw samples when the step is not a multiple of an hour",
+			downsampling:          v3.Downsampling1h,
+			step:                  1800,
+			expectedQueryContains: "avg(value) as per_series_value FROM signoz_metrics.distributed_samples_v4 INNER JOIN",
+		},
+		{
+			name:                  "raw samples when the step is finer than the aggregated tables",
+			downsampling:          v3.Downsampling5m,
+			step:                  60,
+			expectedQueryContains: "avg(value) as per_series_value FROM signoz_metrics.distributed_samples_v4 INNER JOIN",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			builderQuery := &v3.BuilderQuery{
+				QueryName:    "A",
+				StepInterval: testCase.step,
+				DataSource:   v3.DataSourceMetrics,
+				AggregateAttribute: v3.AttributeKey{
+					Key: "system_cpu_usage",
+				},
+				Temporality:      v3.Unspecified,
+				Expression:       "A",
+				TimeAggregation:  v3.TimeAggregationAvg,
+				SpaceAggregation: v3.SpaceAggregationSum,
+				Downsampling:     testCase.downsampling,
+			}
+			query, err := PrepareMetricQuery(1650991982000, 1651078382000, v3.QueryTypeBuilder, v3.PanelTypeGraph, builderQuery, metricsV3.Options{})
+			assert.Nil(t, err)
+			assert.Contains(t, query, testCase.expectedQueryContains)
+		})
+	}
+}
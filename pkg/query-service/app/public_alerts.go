@@ -0,0 +1,261 @@
+package app
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	plabels "github.com/prometheus/prometheus/model/labels"
+	"go.signoz.io/signoz/pkg/query-service/constants"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.signoz.io/signoz/pkg/query-service/rules"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+	"go.uber.org/zap"
+)
+
+// PublicAlert is a firing alert of the public feeds, the annotations other
+// than the summary and the description are left out
+type PublicAlert struct {
+	RuleID      string            `json:"ruleId"`
+	Name        string            `json:"name"`
+	Severity    string            `json:"severity,omitempty"`
+	Summary     string            `json:"summary,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Labels      map[string]string `json:"labels"`
+	StartsAt    time.Time         `json:"startsAt"`
+	Fingerprint string            `json:"fingerprint"`
+	URL         string            `json:"url,omitempty"`
+}
+
+// publicFeed is what the feeds of a public alerts token list, the alerts
+// of its org matching its selector
+type publicFeed struct {
+	org      string
+	matchers []*plabels.Matcher
+}
+
+// publicTokenFeed returns the feed of the public alerts token. The tokens
+// of PUBLIC_ALERTS_TOKENS belong to their org, PUBLIC_ALERTS_TOKEN to the
+// only org of the install and to none once there are several.
+func publicTokenFeed(ctx context.Context, token string) (publicFeed, bool) {
+	if token == "" {
+		return publicFeed{}, false
+	}
+	for _, pair := range strings.Split(constants.PublicAlertsTokens, ";") {
+		org, expected, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || org == "" {
+			continue
+		}
+		selector := ""
+		if i := strings.Index(expected, "{"); i >= 0 {
+			expected, selector = expected[:i], expected[i:]
+		}
+		if expected == "" || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+			continue
+		}
+		matchers, err := rules.ParseAMv2Filter(publicSelectorFilter(selector))
+		if err != nil {
+			zap.L().Error("invalid selector of the public alerts token", zap.String("org", org), zap.Error(err))
+			return publicFeed{}, false
+		}
+		return publicFeed{org: org, matchers: matchers}, true
+	}
+
+	if constants.PublicAlertsToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(constants.PublicAlertsToken)) != 1 {
+		return publicFeed{}, false
+	}
+	org, ok := singleOrg(ctx, "PUBLIC_ALERTS_TOKEN", "PUBLIC_ALERTS_TOKENS")
+	return publicFeed{org: org}, ok
+}
+
+// publicSelectorFilter returns the matchers of the selector of a token as
+// a filter of the Alertmanager API
+func publicSelectorFilter(selector string) []string {
+	inner := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(selector, "{"), "}"))
+	if inner == "" {
+		return nil
+	}
+	return []string{inner}
+}
+
+// publicAlerts returns the firing alerts of the org of the token selected
+// by the filter query params, which are matchers like {service="api"}, the
+// oldest first
+func (aH *APIHandler) publicAlerts(r *http.Request) ([]PublicAlert, *model.ApiError) {
+	feed, ok := publicTokenFeed(r.Context(), requestToken(r))
+	if !ok {
+		return nil, &model.ApiError{Typ: model.ErrorUnauthorized, Err: fmt.Errorf("invalid public alerts token")}
+	}
+	matchers, err := rules.ParseAMv2Filter(r.URL.Query()["filter"])
+	if err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorBadData, Err: err}
+	}
+	// the filters of the request narrow the selector of the token
+	matchers = append(matchers, feed.matchers...)
+	ctx := context.WithValue(r.Context(), constants.ContextUserKey, &model.UserPayload{User: model.User{OrgId: feed.org}})
+	alerts, err := aH.ruleManager.AMv2Alerts(ctx, matchers, false)
+	if err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorInternal, Err: err}
+	}
+
+	res := make([]PublicAlert, 0, len(alerts))
+	for _, a := range alerts {
+		res = append(res, PublicAlert{
+			RuleID:      a.Labels[labels.AlertRuleIdLabel],
+			Name:        a.Labels[labels.AlertNameLabel],
+			Severity:    a.Labels["severity"],
+			Summary:     a.Annotations["summary"],
+			Description: a.Annotations["description"],
+			Labels:      a.Labels,
+			StartsAt:    a.StartsAt,
+			Fingerprint: a.Fingerprint,
+			URL:         a.GeneratorURL,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].StartsAt.Before(res[j].StartsAt) })
+	return res, nil
+}
+
+// getPublicAlerts serves the firing alerts as json for the wallboards
+func (aH *APIHandler) getPublicAlerts(w http.ResponseWriter, r *http.Request) {
+	alerts, apiErr := aH.publicAlerts(r)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, alerts)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	Title   string    `xml:"title"`
+	ID      string    `xml:"id"`
+	Updated string    `xml:"updated"`
+	Summary string    `xml:"summary,omitempty"`
+	Link    *atomLink `xml:"link,omitempty"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Description string    `xml:"description"`
+	Link        string    `xml:"link"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+	Description string `xml:"description,omitempty"`
+	Link        string `xml:"link,omitempty"`
+}
+
+// publicAlertTitle is the title of the entry of the alert in the feeds
+func publicAlertTitle(a PublicAlert) string {
+	if a.Severity != "" {
+		return fmt.Sprintf("[%s] %s", a.Severity, a.Name)
+	}
+	return a.Name
+}
+
+// publicAlertID identifies a firing of the alert, an alert that fires
+// again is a new entry
+func publicAlertID(a PublicAlert) string {
+	return fmt.Sprintf("urn:signoz:alert:%s:%d", a.Fingerprint, a.StartsAt.Unix())
+}
+
+// newAtomFeed returns the atom feed of the alerts, the newest first
+func newAtomFeed(alerts []PublicAlert, now time.Time) atomFeed {
+	feed := atomFeed{Title: "SigNoz firing alerts", ID: "urn:signoz:alerts", Updated: now.UTC().Format(time.RFC3339)}
+	for i := len(alerts) - 1; i >= 0; i-- {
+		a := alerts[i]
+		entry := atomEntry{
+			Title:   publicAlertTitle(a),
+			ID:      publicAlertID(a),
+			Updated: a.StartsAt.UTC().Format(time.RFC3339),
+			Summary: a.Summary,
+		}
+		if a.URL != "" {
+			entry.Link = &atomLink{Href: a.URL}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return feed
+}
+
+// newRSSFeed returns the rss feed of the alerts, the newest first
+func newRSSFeed(alerts []PublicAlert, link string) rssFeed {
+	feed := rssFeed{Version: "2.0", Channel: rssChannel{Title: "SigNoz firing alerts", Description: "The alerts firing in SigNoz", Link: link}}
+	for i := len(alerts) - 1; i >= 0; i-- {
+		a := alerts[i]
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       publicAlertTitle(a),
+			GUID:        publicAlertID(a),
+			PubDate:     a.StartsAt.UTC().Format(time.RFC1123Z),
+			Description: a.Summary,
+			Link:        a.URL,
+		})
+	}
+	return feed
+}
+
+// getPublicAlertsFeed serves the firing alerts as an atom feed, or as an
+// rss feed with format=rss
+func (aH *APIHandler) getPublicAlertsFeed(w http.ResponseWriter, r *http.Request) {
+	alerts, apiErr := aH.publicAlerts(r)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+
+	var feed interface{}
+	contentType := "application/atom+xml; charset=utf-8"
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "atom":
+		feed = newAtomFeed(alerts, time.Now())
+	case "rss":
+		// the channel links to the feed, without the token
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		feed = newRSSFeed(alerts, fmt.Sprintf("%s://%s%s", scheme, r.Host, r.URL.Path))
+		contentType = "application/rss+xml; charset=utf-8"
+	default:
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("unknown feed format %q", format)}, nil)
+		return
+	}
+
+	b, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write([]byte(xml.Header))
+	if n, err := w.Write(b); err != nil {
+		zap.L().Error("error writing response", zap.Int("bytesWritten", n), zap.Error(err))
+	}
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/constants"
+)
+
+func TestPublicAlertsFeeds(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	alerts := []PublicAlert{
+		{Name: "HighLatency", Severity: "critical", Summary: "p99 over 2s", Fingerprint: "00000000000000a1", StartsAt: start, URL: "http://signoz/alerts/1"},
+		{Name: "Errors", Fingerprint: "00000000000000b2", StartsAt: start.Add(time.Minute)},
+	}
+
+	atom := newAtomFeed(alerts, start.Add(time.Hour))
+	if len(atom.Entries) != 2 || atom.Entries[0].Title != "Errors" || atom.Entries[1].Title != "[critical] HighLatency" {
+		t.Fatalf("unexpected atom entries %+v", atom.Entries)
+	}
+	if atom.Entries[1].ID != "urn:signoz:alert:00000000000000a1:1709294400" || atom.Entries[1].Link.Href != "http://signoz/alerts/1" {
+		t.Errorf("unexpected atom entry %+v", atom.Entries[1])
+	}
+	b, err := xml.Marshal(atom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), `<feed xmlns="http://www.w3.org/2005/Atom">`) {
+		t.Errorf("unexpected atom feed %s", b)
+	}
+
+	rss := newRSSFeed(alerts, "http://signoz/api/v1/public/alerts/feed")
+	if len(rss.Channel.Items) != 2 || rss.Channel.Items[1].PubDate != "Fri, 01 Mar 2024 12:00:00 +0000" {
+		t.Errorf("unexpected rss items %+v", rss.Channel.Items)
+	}
+}
+
+func TestRequestToken(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/v1/public/alerts?token=secret", nil)
+	if token := requestToken(r); token != "secret" {
+		t.Errorf("unexpected token %q of the token query param", token)
+	}
+	r = httptest.NewRequest("GET", "/api/v1/public/alerts", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	if token := requestToken(r); token != "secret" {
+		t.Errorf("unexpected bearer token %q", token)
+	}
+	if token := requestToken(httptest.NewRequest("GET", "/api/v1/public/alerts", nil)); token != "" {
+		t.Errorf("unexpected token %q of a request without one", token)
+	}
+}
+
+func TestInboundTokenOrg(t *testing.T) {
+	tokens, token := constants.InboundAlertsTokens, constants.InboundAlertsToken
+	defer func() { constants.InboundAlertsTokens, constants.InboundAlertsToken = tokens, token }()
+	constants.InboundAlertsTokens = "org-a=secret-a, org-b=secret-b,invalid"
+	constants.InboundAlertsToken = ""
+
+	for token, expected := range map[string]string{"secret-a": "org-a", "secret-b": "org-b"} {
+		if org, ok := inboundTokenOrg(context.Background(), token); !ok || org != expected {
+			t.Errorf("token %s got org %q, expected %q", token, org, expected)
+		}
+	}
+	for _, token := range []string{"", "other", "invalid", "org-a"} {
+		if org, ok := inboundTokenOrg(context.Background(), token); ok {
+			t.Errorf("token %q was accepted for org %q", token, org)
+		}
+	}
+}
+
+func TestPublicTokenFeed(t *testing.T) {
+	tokens, token := constants.PublicAlertsTokens, constants.PublicAlertsToken
+	defer func() { constants.PublicAlertsTokens, constants.PublicAlertsToken = tokens, token }()
+	constants.PublicAlertsTokens = `org-a=secret-a; org-b=secret-b{team="web", severity=~"critical|error"};org-c=secret-c{team=};invalid`
+	constants.PublicAlertsToken = ""
+
+	feed, ok := publicTokenFeed(context.Background(), "secret-a")
+	if !ok || feed.org != "org-a" || len(feed.matchers) != 0 {
+		t.Errorf("unexpected feed %+v of the token without a selector", feed)
+	}
+	feed, ok = publicTokenFeed(context.Background(), "secret-b")
+	if !ok || feed.org != "org-b" || len(feed.matchers) != 2 {
+		t.Fatalf("unexpected feed %+v of the token with a selector", feed)
+	}
+	if !feed.matchers[0].Matches("web") || feed.matchers[0].Matches("api") || !feed.matchers[1].Matches("error") {
+		t.Errorf("unexpected matchers %v of the selector", feed.matchers)
+	}
+
+	// the tokens of the other orgs, with an invalid selector and the
+	// legacy token when it is not set are refused
+	for _, token := range []string{"", "other", "invalid", "org-a", "secret-c", `secret-b{team="web"}`} {
+		if feed, ok := publicTokenFeed(context.Background(), token); ok {
+			t.Errorf("token %q was accepted for org %q", token, feed.org)
+		}
+	}
+}
@@ -415,6 +415,10 @@ func (c *cacheKeyGenerator) GenerateKeys(params *v3.QueryRangeParamsV3) map[stri
 				parts = append(parts, fmt.Sprintf("shiftBy=%d", query.ShiftBy))
 			}
 
+			if query.Downsampling != v3.DownsamplingUnspecified && query.Downsampling != v3.DownsamplingRaw {
+				parts = append(parts, fmt.Sprintf("downsampling=%s", query.Downsampling))
+			}
+
 			if query.AggregateAttribute.Key != "" {
 				parts = append(parts, fmt.Sprintf("aggregateAttribute=%s", query.AggregateAttribute.CacheKey()))
 			}
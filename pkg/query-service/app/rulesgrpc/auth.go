@@ -0,0 +1,129 @@
+package rulesgrpc
+
+import (
+	"context"
+	"net/http"
+
+	"go.signoz.io/signoz/pkg/query-service/auth"
+	"go.signoz.io/signoz/pkg/query-service/constants"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// role is the least group of the users allowed to call a method
+type role int
+
+const (
+	roleViewer role = iota
+	roleEditor
+	roleAdmin
+)
+
+func (r role) allows(user *model.UserPayload) bool {
+	switch r {
+	case roleViewer:
+		return auth.IsViewer(user) || auth.IsEditor(user) || auth.IsAdmin(user)
+	case roleEditor:
+		return auth.IsEditor(user) || auth.IsAdmin(user)
+	default:
+		return auth.IsAdmin(user)
+	}
+}
+
+type methodAccess struct {
+	role role
+	// scope is the scope of the API tokens reaching the method, the
+	// methods without a scope are not reachable with the scoped tokens
+	scope string
+}
+
+// methods are the access of the methods, the same as the one of their
+// REST APIs
+var methods = map[string]methodAccess{
+	RulesService_ListRules_FullMethodName:      {roleViewer, auth.ScopeRulesRead},
+	RulesService_GetRule_FullMethodName:        {roleViewer, auth.ScopeRulesRead},
+	RulesService_CreateRule_FullMethodName:     {roleEditor, auth.ScopeRulesWrite},
+	RulesService_UpdateRule_FullMethodName:     {roleEditor, auth.ScopeRulesWrite},
+	RulesService_PatchRule_FullMethodName:      {roleEditor, auth.ScopeRulesWrite},
+	RulesService_DeleteRule_FullMethodName:     {roleEditor, auth.ScopeRulesWrite},
+	RulesService_ListAlerts_FullMethodName:     {roleViewer, auth.ScopeRulesRead},
+	RulesService_WatchAlerts_FullMethodName:    {roleViewer, auth.ScopeRulesRead},
+	RulesService_EvaluateRule_FullMethodName:   {roleAdmin, ""},
+	RulesService_PauseScheduler_FullMethodName: {roleAdmin, ""},
+}
+
+// authHeaders are the metadata passed to the authentication of the REST
+// APIs as the headers of the same name
+var authHeaders = []string{"Authorization", "SIGNOZ-API-KEY"}
+
+// authenticator authenticates the calls with the credentials of the REST
+// APIs, the jwt of the user or an API token
+type authenticator struct {
+	getUser func(r *http.Request) (*model.UserPayload, error)
+}
+
+// authenticate returns the context of the call with the user and the jwt
+// of the REST APIs
+func (a *authenticator) authenticate(ctx context.Context, method string) (context.Context, error) {
+	access, ok := methods[method]
+	if !ok {
+		return nil, status.Errorf(codes.PermissionDenied, "method %s is not allowed", method)
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, method, nil)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, h := range authHeaders {
+		for _, v := range md.Get(h) {
+			r.Header.Add(h, v)
+		}
+	}
+
+	user, err := a.getUser(r)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if !access.role.allows(user) {
+		return nil, status.Errorf(codes.PermissionDenied, "method %s is not accessible to the group of the user", method)
+	}
+	if !auth.HasScope(user, access.scope) {
+		return nil, status.Errorf(codes.PermissionDenied, "method %s is not in the scopes of the token", method)
+	}
+
+	ctx = context.WithValue(ctx, constants.ContextUserKey, user)
+	if r.Header.Get("Authorization") != "" {
+		ctx = auth.AttachJwtToContext(ctx, r)
+	}
+	return ctx, nil
+}
+
+func (a *authenticator) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := a.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *authenticator) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authenticatedStream is a stream with the context of the authenticated call
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,482 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: rules.proto
+
+package rulesgrpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	RulesService_ListRules_FullMethodName      = "/signoz.rules.v1.RulesService/ListRules"
+	RulesService_GetRule_FullMethodName        = "/signoz.rules.v1.RulesService/GetRule"
+	RulesService_CreateRule_FullMethodName     = "/signoz.rules.v1.RulesService/CreateRule"
+	RulesService_UpdateRule_FullMethodName     = "/signoz.rules.v1.RulesService/UpdateRule"
+	RulesService_PatchRule_FullMethodName      = "/signoz.rules.v1.RulesService/PatchRule"
+	RulesService_DeleteRule_FullMethodName     = "/signoz.rules.v1.RulesService/DeleteRule"
+	RulesService_ListAlerts_FullMethodName     = "/signoz.rules.v1.RulesService/ListAlerts"
+	RulesService_WatchAlerts_FullMethodName    = "/signoz.rules.v1.RulesService/WatchAlerts"
+	RulesService_EvaluateRule_FullMethodName   = "/signoz.rules.v1.RulesService/EvaluateRule"
+	RulesService_PauseScheduler_FullMethodName = "/signoz.rules.v1.RulesService/PauseScheduler"
+)
+
+// RulesServiceClient is the client API for RulesService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type RulesServiceClient interface {
+	ListRules(ctx context.Context, in *ListRulesRequest, opts ...grpc.CallOption) (*ListRulesResponse, error)
+	GetRule(ctx context.Context, in *GetRuleRequest, opts ...grpc.CallOption) (*Rule, error)
+	CreateRule(ctx context.Context, in *CreateRuleRequest, opts ...grpc.CallOption) (*Rule, error)
+	// UpdateRule replaces the definition of the rule
+	UpdateRule(ctx context.Context, in *UpdateRuleRequest, opts ...grpc.CallOption) (*Rule, error)
+	// PatchRule changes the fields of the rule set in the definition
+	PatchRule(ctx context.Context, in *PatchRuleRequest, opts ...grpc.CallOption) (*Rule, error)
+	DeleteRule(ctx context.Context, in *DeleteRuleRequest, opts ...grpc.CallOption) (*DeleteRuleResponse, error)
+	// ListAlerts returns the active alerts of a rule
+	ListAlerts(ctx context.Context, in *ListAlertsRequest, opts ...grpc.CallOption) (*ListAlertsResponse, error)
+	// WatchAlerts streams the state changes of the alerts
+	WatchAlerts(ctx context.Context, in *WatchAlertsRequest, opts ...grpc.CallOption) (RulesService_WatchAlertsClient, error)
+	// EvaluateRule evaluates the rule now, out of its schedule
+	EvaluateRule(ctx context.Context, in *EvaluateRuleRequest, opts ...grpc.CallOption) (*EvaluateRuleResponse, error)
+	// PauseScheduler pauses or resumes the evaluations of all the rules
+	PauseScheduler(ctx context.Context, in *PauseSchedulerRequest, opts ...grpc.CallOption) (*SchedulerStatus, error)
+}
+
+type rulesServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRulesServiceClient(cc grpc.ClientConnInterface) RulesServiceClient {
+	return &rulesServiceClient{cc}
+}
+
+func (c *rulesServiceClient) ListRules(ctx context.Context, in *ListRulesRequest, opts ...grpc.CallOption) (*ListRulesResponse, error) {
+	out := new(ListRulesResponse)
+	err := c.cc.Invoke(ctx, RulesService_ListRules_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rulesServiceClient) GetRule(ctx context.Context, in *GetRuleRequest, opts ...grpc.CallOption) (*Rule, error) {
+	out := new(Rule)
+	err := c.cc.Invoke(ctx, RulesService_GetRule_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rulesServiceClient) CreateRule(ctx context.Context, in *CreateRuleRequest, opts ...grpc.CallOption) (*Rule, error) {
+	out := new(Rule)
+	err := c.cc.Invoke(ctx, RulesService_CreateRule_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rulesServiceClient) UpdateRule(ctx context.Context, in *UpdateRuleRequest, opts ...grpc.CallOption) (*Rule, error) {
+	out := new(Rule)
+	err := c.cc.Invoke(ctx, RulesService_UpdateRule_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rulesServiceClient) PatchRule(ctx context.Context, in *PatchRuleRequest, opts ...grpc.CallOption) (*Rule, error) {
+	out := new(Rule)
+	err := c.cc.Invoke(ctx, RulesService_PatchRule_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rulesServiceClient) DeleteRule(ctx context.Context, in *DeleteRuleRequest, opts ...grpc.CallOption) (*DeleteRuleResponse, error) {
+	out := new(DeleteRuleResponse)
+	err := c.cc.Invoke(ctx, RulesService_DeleteRule_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rulesServiceClient) ListAlerts(ctx context.Context, in *ListAlertsRequest, opts ...grpc.CallOption) (*ListAlertsResponse, error) {
+	out := new(ListAlertsResponse)
+	err := c.cc.Invoke(ctx, RulesService_ListAlerts_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rulesServiceClient) WatchAlerts(ctx context.Context, in *WatchAlertsRequest, opts ...grpc.CallOption) (RulesService_WatchAlertsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &RulesService_ServiceDesc.Streams[0], RulesService_WatchAlerts_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &rulesServiceWatchAlertsClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RulesService_WatchAlertsClient interface {
+	Recv() (*AlertEvent, error)
+	grpc.ClientStream
+}
+
+type rulesServiceWatchAlertsClient struct {
+	grpc.ClientStream
+}
+
+func (x *rulesServiceWatchAlertsClient) Recv() (*AlertEvent, error) {
+	m := new(AlertEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *rulesServiceClient) EvaluateRule(ctx context.Context, in *EvaluateRuleRequest, opts ...grpc.CallOption) (*EvaluateRuleResponse, error) {
+	out := new(EvaluateRuleResponse)
+	err := c.cc.Invoke(ctx, RulesService_EvaluateRule_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rulesServiceClient) PauseScheduler(ctx context.Context, in *PauseSchedulerRequest, opts ...grpc.CallOption) (*SchedulerStatus, error) {
+	out := new(SchedulerStatus)
+	err := c.cc.Invoke(ctx, RulesService_PauseScheduler_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RulesServiceServer is the server API for RulesService service.
+// All implementations must embed UnimplementedRulesServiceServer
+// for forward compatibility
+type RulesServiceServer interface {
+	ListRules(context.Context, *ListRulesRequest) (*ListRulesResponse, error)
+	GetRule(context.Context, *GetRuleRequest) (*Rule, error)
+	CreateRule(context.Context, *CreateRuleRequest) (*Rule, error)
+	// UpdateRule replaces the definition of the rule
+	UpdateRule(context.Context, *UpdateRuleRequest) (*Rule, error)
+	// PatchRule changes the fields of the rule set in the definition
+	PatchRule(context.Context, *PatchRuleRequest) (*Rule, error)
+	DeleteRule(context.Context, *DeleteRuleRequest) (*DeleteRuleResponse, error)
+	// ListAlerts returns the active alerts of a rule
+	ListAlerts(context.Context, *ListAlertsRequest) (*ListAlertsResponse, error)
+	// WatchAlerts streams the state changes of the alerts
+	WatchAlerts(*WatchAlertsRequest, RulesService_WatchAlertsServer) error
+	// EvaluateRule evaluates the rule now, out of its schedule
+	EvaluateRule(context.Context, *EvaluateRuleRequest) (*EvaluateRuleResponse, error)
+	// PauseScheduler pauses or resumes the evaluations of all the rules
+	PauseScheduler(context.Context, *PauseSchedulerRequest) (*SchedulerStatus, error)
+	mustEmbedUnimplementedRulesServiceServer()
+}
+
+// UnimplementedRulesServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedRulesServiceServer struct {
+}
+
+func (UnimplementedRulesServiceServer) ListRules(context.Context, *ListRulesRequest) (*ListRulesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRules not implemented")
+}
+func (UnimplementedRulesServiceServer) GetRule(context.Context, *GetRuleRequest) (*Rule, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRule not implemented")
+}
+func (UnimplementedRulesServiceServer) CreateRule(context.Context, *CreateRuleRequest) (*Rule, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateRule not implemented")
+}
+func (UnimplementedRulesServiceServer) UpdateRule(context.Context, *UpdateRuleRequest) (*Rule, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateRule not implemented")
+}
+func (UnimplementedRulesServiceServer) PatchRule(context.Context, *PatchRuleRequest) (*Rule, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PatchRule not implemented")
+}
+func (UnimplementedRulesServiceServer) DeleteRule(context.Context, *DeleteRuleRequest) (*DeleteRuleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteRule not implemented")
+}
+func (UnimplementedRulesServiceServer) ListAlerts(context.Context, *ListAlertsRequest) (*ListAlertsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAlerts not implemented")
+}
+func (UnimplementedRulesServiceServer) WatchAlerts(*WatchAlertsRequest, RulesService_WatchAlertsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchAlerts not implemented")
+}
+func (UnimplementedRulesServiceServer) EvaluateRule(context.Context, *EvaluateRuleRequest) (*EvaluateRuleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EvaluateRule not implemented")
+}
+func (UnimplementedRulesServiceServer) PauseScheduler(context.Context, *PauseSchedulerRequest) (*SchedulerStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PauseScheduler not implemented")
+}
+func (UnimplementedRulesServiceServer) mustEmbedUnimplementedRulesServiceServer() {}
+
+// UnsafeRulesServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RulesServiceServer will
+// result in compilation errors.
+type UnsafeRulesServiceServer interface {
+	mustEmbedUnimplementedRulesServiceServer()
+}
+
+func RegisterRulesServiceServer(s grpc.ServiceRegistrar, srv RulesServiceServer) {
+	s.RegisterService(&RulesService_ServiceDesc, srv)
+}
+
+func _RulesService_ListRules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRulesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RulesServiceServer).ListRules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RulesService_ListRules_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RulesServiceServer).ListRules(ctx, req.(*ListRulesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RulesService_GetRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRuleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RulesServiceServer).GetRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RulesService_GetRule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RulesServiceServer).GetRule(ctx, req.(*GetRuleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RulesService_CreateRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateRuleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RulesServiceServer).CreateRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RulesService_CreateRule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RulesServiceServer).CreateRule(ctx, req.(*CreateRuleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RulesService_UpdateRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateRuleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RulesServiceServer).UpdateRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RulesService_UpdateRule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RulesServiceServer).UpdateRule(ctx, req.(*UpdateRuleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RulesService_PatchRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PatchRuleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RulesServiceServer).PatchRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RulesService_PatchRule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RulesServiceServer).PatchRule(ctx, req.(*PatchRuleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RulesService_DeleteRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRuleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RulesServiceServer).DeleteRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RulesService_DeleteRule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RulesServiceServer).DeleteRule(ctx, req.(*DeleteRuleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RulesService_ListAlerts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAlertsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RulesServiceServer).ListAlerts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RulesService_ListAlerts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RulesServiceServer).ListAlerts(ctx, req.(*ListAlertsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RulesService_WatchAlerts_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchAlertsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RulesServiceServer).WatchAlerts(m, &rulesServiceWatchAlertsServer{ServerStream: stream})
+}
+
+type RulesService_WatchAlertsServer interface {
+	Send(*AlertEvent) error
+	grpc.ServerStream
+}
+
+type rulesServiceWatchAlertsServer struct {
+	grpc.ServerStream
+}
+
+func (x *rulesServiceWatchAlertsServer) Send(m *AlertEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _RulesService_EvaluateRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EvaluateRuleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RulesServiceServer).EvaluateRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RulesService_EvaluateRule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RulesServiceServer).EvaluateRule(ctx, req.(*EvaluateRuleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RulesService_PauseScheduler_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PauseSchedulerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RulesServiceServer).PauseScheduler(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RulesService_PauseScheduler_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RulesServiceServer).PauseScheduler(ctx, req.(*PauseSchedulerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// RulesService_ServiceDesc is the grpc.ServiceDesc for RulesService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RulesService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "signoz.rules.v1.RulesService",
+	HandlerType: (*RulesServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListRules",
+			Handler:    _RulesService_ListRules_Handler,
+		},
+		{
+			MethodName: "GetRule",
+			Handler:    _RulesService_GetRule_Handler,
+		},
+		{
+			MethodName: "CreateRule",
+			Handler:    _RulesService_CreateRule_Handler,
+		},
+		{
+			MethodName: "UpdateRule",
+			Handler:    _RulesService_UpdateRule_Handler,
+		},
+		{
+			MethodName: "PatchRule",
+			Handler:    _RulesService_PatchRule_Handler,
+		},
+		{
+			MethodName: "DeleteRule",
+			Handler:    _RulesService_DeleteRule_Handler,
+		},
+		{
+			MethodName: "ListAlerts",
+			Handler:    _RulesService_ListAlerts_Handler,
+		},
+		{
+			MethodName: "EvaluateRule",
+			Handler:    _RulesService_EvaluateRule_Handler,
+		},
+		{
+			MethodName: "PauseScheduler",
+			Handler:    _RulesService_PauseScheduler_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchAlerts",
+			Handler:       _RulesService_WatchAlerts_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "rules.proto",
+}
@@ -0,0 +1,295 @@
+// Package rulesgrpc serves the rules manager over grpc for the internal
+// services managing the alert rules programmatically. The API is defined in
+// rules.proto, the rules are exchanged in the json of the REST APIs.
+package rulesgrpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative rules.proto
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.signoz.io/signoz/pkg/query-service/rules"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// watchBuffer is the number of events buffered for a watcher, the events
+// are dropped for the watchers that are behind
+const watchBuffer = 1000
+
+// RuleManager is the part of the rules manager served over grpc
+type RuleManager interface {
+	ListRuleStates(ctx context.Context) (*rules.GettableRules, error)
+	GetRule(ctx context.Context, id string) (*rules.GettableRule, error)
+	CreateRule(ctx context.Context, ruleStr string) (*rules.GettableRule, error)
+	EditRule(ctx context.Context, ruleStr string, id string) error
+	PatchRule(ctx context.Context, ruleStr string, id string) (*rules.GettableRule, error)
+	DeleteRule(ctx context.Context, id string) error
+	RuleAlerts(ruleID string) ([]rules.ActiveAlert, *model.ApiError)
+	EvaluateRuleNow(ctx context.Context, id string, req *rules.RuleEvaluationRequest) (*rules.RuleEvaluation, *model.ApiError)
+	PauseScheduler(ctx context.Context, paused bool) rules.SchedulerStatus
+	SubscribeEvents(buffer int) *rules.EventSubscription
+	AlertEventVisible(ctx context.Context, e rules.AlertEvent) bool
+}
+
+// NewServer returns the grpc server of the rules API, the calls are
+// authenticated like the REST APIs with getUser
+func NewServer(manager RuleManager, getUser func(r *http.Request) (*model.UserPayload, error), opts ...grpc.ServerOption) *grpc.Server {
+	a := &authenticator{getUser: getUser}
+	opts = append(opts, grpc.ChainUnaryInterceptor(a.unary), grpc.ChainStreamInterceptor(a.stream))
+	s := grpc.NewServer(opts...)
+	RegisterRulesServiceServer(s, &service{manager: manager})
+	return s
+}
+
+type service struct {
+	UnimplementedRulesServiceServer
+	manager RuleManager
+}
+
+func (s *service) ListRules(ctx context.Context, _ *ListRulesRequest) (*ListRulesResponse, error) {
+	list, err := s.manager.ListRuleStates(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	res := &ListRulesResponse{}
+	for _, r := range list.Rules {
+		rule, err := toRule(r)
+		if err != nil {
+			return nil, err
+		}
+		res.Rules = append(res.Rules, rule)
+	}
+	return res, nil
+}
+
+func (s *service) GetRule(ctx context.Context, req *GetRuleRequest) (*Rule, error) {
+	r, err := s.getRule(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	return toRule(r)
+}
+
+// getRule returns the rule when it is visible to the org of the call
+func (s *service) getRule(ctx context.Context, id string) (*rules.GettableRule, error) {
+	r, err := s.manager.GetRule(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, status.Errorf(codes.NotFound, "rule %s not found", id)
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return r, nil
+}
+
+func (s *service) CreateRule(ctx context.Context, req *CreateRuleRequest) (*Rule, error) {
+	def, err := definition(req.GetDefinition())
+	if err != nil {
+		return nil, err
+	}
+	r, err := s.manager.CreateRule(ctx, def)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return toRule(r)
+}
+
+func (s *service) UpdateRule(ctx context.Context, req *UpdateRuleRequest) (*Rule, error) {
+	def, err := definition(req.GetDefinition())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.manager.EditRule(ctx, def, req.GetId()); err != nil {
+		return nil, errorStatus(err)
+	}
+	return s.GetRule(ctx, &GetRuleRequest{Id: req.GetId()})
+}
+
+func (s *service) PatchRule(ctx context.Context, req *PatchRuleRequest) (*Rule, error) {
+	def, err := definition(req.GetDefinition())
+	if err != nil {
+		return nil, err
+	}
+	r, err := s.manager.PatchRule(ctx, def, req.GetId())
+	if err != nil {
+		return nil, errorStatus(err)
+	}
+	return toRule(r)
+}
+
+func (s *service) DeleteRule(ctx context.Context, req *DeleteRuleRequest) (*DeleteRuleResponse, error) {
+	if err := s.manager.DeleteRule(ctx, req.GetId()); err != nil {
+		return nil, errorStatus(err)
+	}
+	return &DeleteRuleResponse{}, nil
+}
+
+func (s *service) ListAlerts(ctx context.Context, req *ListAlertsRequest) (*ListAlertsResponse, error) {
+	if _, err := s.getRule(ctx, req.GetRuleId()); err != nil {
+		return nil, err
+	}
+	alerts, apiErr := s.manager.RuleAlerts(req.GetRuleId())
+	if apiErr != nil {
+		return nil, errorStatus(apiErr)
+	}
+	res := &ListAlertsResponse{}
+	for _, a := range alerts {
+		alert := &Alert{
+			Fingerprint: a.Fingerprint,
+			Labels:      a.Labels,
+			State:       a.State,
+			Value:       a.Value,
+			ActiveAt:    timestamppb.New(a.ActiveAt),
+		}
+		if !a.FiredAt.IsZero() {
+			alert.FiredAt = timestamppb.New(a.FiredAt)
+		}
+		res.Alerts = append(res.Alerts, alert)
+	}
+	return res, nil
+}
+
+// WatchAlerts streams the events of the alerts of the rules visible to the
+// org of the call until the call is done
+func (s *service) WatchAlerts(req *WatchAlertsRequest, stream RulesService_WatchAlertsServer) error {
+	ctx := stream.Context()
+	sub := s.manager.SubscribeEvents(watchBuffer)
+	defer sub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-sub.C:
+			if !ok {
+				return status.Error(codes.Unavailable, "the rules manager is stopping")
+			}
+			if len(req.GetRuleIds()) > 0 && !slices.Contains(req.GetRuleIds(), e.RuleID) {
+				continue
+			}
+			if len(req.GetTypes()) > 0 && !slices.Contains(req.GetTypes(), string(e.Type)) {
+				continue
+			}
+			if !s.manager.AlertEventVisible(ctx, e) {
+				continue
+			}
+			if err := stream.Send(toAlertEvent(e)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *service) EvaluateRule(ctx context.Context, req *EvaluateRuleRequest) (*EvaluateRuleResponse, error) {
+	if _, err := s.getRule(ctx, req.GetId()); err != nil {
+		return nil, err
+	}
+	evalReq := &rules.RuleEvaluationRequest{Reason: req.GetReason()}
+	if req.GetNotify() != nil {
+		notify := req.GetNotify().GetValue()
+		evalReq.Notify = &notify
+	}
+	eval, apiErr := s.manager.EvaluateRuleNow(ctx, req.GetId(), evalReq)
+	if apiErr != nil {
+		return nil, errorStatus(apiErr)
+	}
+	evaluation, err := toStruct(eval)
+	if err != nil {
+		return nil, err
+	}
+	return &EvaluateRuleResponse{Evaluation: evaluation}, nil
+}
+
+func (s *service) PauseScheduler(ctx context.Context, req *PauseSchedulerRequest) (*SchedulerStatus, error) {
+	st := s.manager.PauseScheduler(ctx, req.GetPaused())
+	res := &SchedulerStatus{Paused: st.Paused, PausedBy: st.PausedBy}
+	if !st.PausedAt.IsZero() {
+		res.PausedAt = timestamppb.New(st.PausedAt)
+	}
+	return res, nil
+}
+
+// definition returns the json of the rule for the rules manager
+func definition(def *structpb.Struct) (string, error) {
+	if def == nil {
+		return "", status.Error(codes.InvalidArgument, "definition of the rule is required")
+	}
+	data, err := def.MarshalJSON()
+	if err != nil {
+		return "", status.Error(codes.InvalidArgument, err.Error())
+	}
+	return string(data), nil
+}
+
+func toRule(r *rules.GettableRule) (*Rule, error) {
+	def, err := toStruct(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Rule{Id: r.Id, State: r.State.String(), Definition: def}, nil
+}
+
+// toStruct returns the json of the REST APIs of v as a struct
+func toStruct(v interface{}) (*structpb.Struct, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	s := &structpb.Struct{}
+	if err := s.UnmarshalJSON(data); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return s, nil
+}
+
+func toAlertEvent(e rules.AlertEvent) *AlertEvent {
+	return &AlertEvent{
+		Type:        string(e.Type),
+		RuleId:      e.RuleID,
+		RuleName:    e.RuleName,
+		Fingerprint: e.Fingerprint,
+		Labels:      e.Labels,
+		Annotations: e.Annotations,
+		Value:       e.Value,
+		Timestamp:   timestamppb.New(e.Timestamp),
+		Actor:       e.Actor,
+	}
+}
+
+// errorCodes are the grpc codes of the errors of the REST APIs
+var errorCodes = map[model.ErrorType]codes.Code{
+	model.ErrorTimeout:        codes.DeadlineExceeded,
+	model.ErrorCanceled:       codes.Canceled,
+	model.ErrorBadData:        codes.InvalidArgument,
+	model.ErrorUnavailable:    codes.Unavailable,
+	model.ErrorNotFound:       codes.NotFound,
+	model.ErrorNotImplemented: codes.Unimplemented,
+	model.ErrorUnauthorized:   codes.Unauthenticated,
+	model.ErrorForbidden:      codes.PermissionDenied,
+	model.ErrorConflict:       codes.AlreadyExists,
+}
+
+// errorStatus returns the status of an error of the rules manager
+func errorStatus(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return status.Error(codes.NotFound, "rule not found")
+	}
+	var apiErr *model.ApiError
+	if errors.As(err, &apiErr) {
+		if code, ok := errorCodes[apiErr.Typ]; ok {
+			return status.Error(code, apiErr.Err.Error())
+		}
+		return status.Error(codes.Internal, apiErr.Err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
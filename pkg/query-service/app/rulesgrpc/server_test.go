@@ -0,0 +1,256 @@
+package rulesgrpc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/auth"
+	"go.signoz.io/signoz/pkg/query-service/common"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.signoz.io/signoz/pkg/query-service/rules"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type fakeManager struct {
+	rules  map[string]*rules.GettableRule
+	events *rules.EventBus
+	// created is the definition of the last created rule
+	created string
+}
+
+func (f *fakeManager) ListRuleStates(ctx context.Context) (*rules.GettableRules, error) {
+	res := &rules.GettableRules{}
+	for _, r := range f.rules {
+		res.Rules = append(res.Rules, r)
+	}
+	return res, nil
+}
+
+func (f *fakeManager) GetRule(ctx context.Context, id string) (*rules.GettableRule, error) {
+	r, ok := f.rules[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return r, nil
+}
+
+func (f *fakeManager) CreateRule(ctx context.Context, ruleStr string) (*rules.GettableRule, error) {
+	if !strings.Contains(ruleStr, "alert") {
+		return nil, errors.New("alert name is required")
+	}
+	f.created = ruleStr
+	return &rules.GettableRule{Id: "2", State: rules.StateInactive}, nil
+}
+
+func (f *fakeManager) EditRule(ctx context.Context, ruleStr string, id string) error {
+	return nil
+}
+
+func (f *fakeManager) PatchRule(ctx context.Context, ruleStr string, id string) (*rules.GettableRule, error) {
+	return f.GetRule(ctx, id)
+}
+
+func (f *fakeManager) DeleteRule(ctx context.Context, id string) error {
+	return nil
+}
+
+func (f *fakeManager) RuleAlerts(ruleID string) ([]rules.ActiveAlert, *model.ApiError) {
+	return []rules.ActiveAlert{{Fingerprint: 42, Labels: map[string]string{"service_name": "checkout"}, State: "firing", Value: 812, ActiveAt: time.Unix(100, 0)}}, nil
+}
+
+func (f *fakeManager) EvaluateRuleNow(ctx context.Context, id string, req *rules.RuleEvaluationRequest) (*rules.RuleEvaluation, *model.ApiError) {
+	if req.Notify != nil && !*req.Notify {
+		return nil, &model.ApiError{Typ: model.ErrorConflict, Err: errors.New("rule is being evaluated")}
+	}
+	return &rules.RuleEvaluation{RuleID: id, State: "firing", Firing: 1}, nil
+}
+
+func (f *fakeManager) PauseScheduler(ctx context.Context, paused bool) rules.SchedulerStatus {
+	return rules.SchedulerStatus{Paused: paused, PausedBy: common.GetUserFromContext(ctx).Email}
+}
+
+func (f *fakeManager) SubscribeEvents(buffer int) *rules.EventSubscription {
+	return f.events.Subscribe(buffer)
+}
+
+func (f *fakeManager) AlertEventVisible(ctx context.Context, e rules.AlertEvent) bool {
+	return e.RuleID != "other-org"
+}
+
+// users are the users of the tokens in the authorization metadata
+var users = map[string]*model.UserPayload{
+	"Bearer viewer": {User: model.User{Email: "viewer@example.com", OrgId: "acme", GroupId: "viewers"}},
+	"Bearer admin":  {User: model.User{Email: "admin@example.com", OrgId: "acme", GroupId: "admins"}},
+	"Bearer token":  {User: model.User{OrgId: "acme", GroupId: "admins"}, Scopes: []string{auth.ScopeRulesRead}},
+}
+
+func newTestClient(t *testing.T, manager RuleManager) RulesServiceClient {
+	auth.AuthCacheObj = auth.AuthCache{AdminGroupId: "admins", EditorGroupId: "editors", ViewerGroupId: "viewers"}
+	getUser := func(r *http.Request) (*model.UserPayload, error) {
+		user, ok := users[r.Header.Get("Authorization")]
+		if !ok {
+			return nil, errors.New("invalid token")
+		}
+		return user, nil
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	srv := NewServer(manager, getUser)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return NewRulesServiceClient(conn)
+}
+
+func as(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", token)
+}
+
+func TestRulesServiceAuth(t *testing.T) {
+	manager := &fakeManager{rules: map[string]*rules.GettableRule{"1": {Id: "1"}}, events: rules.NewEventBus()}
+	client := newTestClient(t, manager)
+
+	_, err := client.GetRule(context.Background(), &GetRuleRequest{Id: "1"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.GetRule(as("Bearer viewer"), &GetRuleRequest{Id: "1"})
+	assert.NoError(t, err)
+
+	_, err = client.DeleteRule(as("Bearer viewer"), &DeleteRuleRequest{Id: "1"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// the scoped tokens only reach the methods of their scopes
+	_, err = client.GetRule(as("Bearer token"), &GetRuleRequest{Id: "1"})
+	assert.NoError(t, err)
+	_, err = client.DeleteRule(as("Bearer token"), &DeleteRuleRequest{Id: "1"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = client.PauseScheduler(as("Bearer token"), &PauseSchedulerRequest{Paused: true})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	res, err := client.PauseScheduler(as("Bearer admin"), &PauseSchedulerRequest{Paused: true})
+	require.NoError(t, err)
+	assert.True(t, res.Paused)
+	assert.Equal(t, "admin@example.com", res.PausedBy)
+}
+
+func TestRulesServiceMethods(t *testing.T) {
+	manager := &fakeManager{
+		rules:  map[string]*rules.GettableRule{"1": {Id: "1", State: rules.StateFiring, PostableRule: rules.PostableRule{AlertName: "checkout latency"}}},
+		events: rules.NewEventBus(),
+	}
+	client := newTestClient(t, manager)
+	ctx := as("Bearer admin")
+
+	rule, err := client.GetRule(ctx, &GetRuleRequest{Id: "1"})
+	require.NoError(t, err)
+	assert.Equal(t, "firing", rule.State)
+	assert.Equal(t, "checkout latency", rule.Definition.Fields["alert"].GetStringValue())
+
+	_, err = client.GetRule(ctx, &GetRuleRequest{Id: "9"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	list, err := client.ListRules(ctx, &ListRulesRequest{})
+	require.NoError(t, err)
+	assert.Len(t, list.Rules, 1)
+
+	def, err := structpb.NewStruct(map[string]interface{}{"alert": "cart errors", "evalWindow": "5m"})
+	require.NoError(t, err)
+	created, err := client.CreateRule(ctx, &CreateRuleRequest{Definition: def})
+	require.NoError(t, err)
+	assert.Equal(t, "2", created.Id)
+	assert.JSONEq(t, `{"alert":"cart errors","evalWindow":"5m"}`, manager.created)
+
+	_, err = client.CreateRule(ctx, &CreateRuleRequest{Definition: &structpb.Struct{}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.CreateRule(ctx, &CreateRuleRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	alerts, err := client.ListAlerts(ctx, &ListAlertsRequest{RuleId: "1"})
+	require.NoError(t, err)
+	require.Len(t, alerts.Alerts, 1)
+	assert.Equal(t, uint64(42), alerts.Alerts[0].Fingerprint)
+	assert.Equal(t, "checkout", alerts.Alerts[0].Labels["service_name"])
+	assert.Nil(t, alerts.Alerts[0].FiredAt)
+
+	eval, err := client.EvaluateRule(ctx, &EvaluateRuleRequest{Id: "1", Reason: "deploy"})
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), eval.Evaluation.Fields["firing"].GetNumberValue())
+
+	_, err = client.EvaluateRule(ctx, &EvaluateRuleRequest{Id: "1", Notify: wrapperspb.Bool(false)})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+}
+
+func TestRulesServiceWatchAlerts(t *testing.T) {
+	manager := &fakeManager{events: rules.NewEventBus()}
+	client := newTestClient(t, manager)
+
+	ctx, cancel := context.WithCancel(as("Bearer viewer"))
+	defer cancel()
+	stream, err := client.WatchAlerts(ctx, &WatchAlertsRequest{Types: []string{string(rules.EventFiring)}})
+	require.NoError(t, err)
+
+	// the events are streamed from the subscription of the call, the
+	// markers are published until the stream is subscribed
+	subscribed := make(chan struct{})
+	go func() {
+		tick := time.NewTicker(10 * time.Millisecond)
+		defer tick.Stop()
+		for {
+			select {
+			case <-subscribed:
+				return
+			case <-tick.C:
+				manager.events.Publish(rules.AlertEvent{Type: rules.EventFiring, RuleID: "0"})
+			}
+		}
+	}()
+	e, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "0", e.RuleId)
+	close(subscribed)
+
+	ts := time.Unix(1000, 0).UTC()
+	manager.events.Publish(
+		rules.AlertEvent{Type: rules.EventPending, RuleID: "1", Timestamp: ts},
+		rules.AlertEvent{Type: rules.EventFiring, RuleID: "other-org", Timestamp: ts},
+		rules.AlertEvent{Type: rules.EventFiring, RuleID: "1", RuleName: "checkout latency", Fingerprint: 42, Labels: map[string]string{"service_name": "checkout"}, Value: 812, Timestamp: ts},
+	)
+
+	for {
+		e, err := stream.Recv()
+		require.NoError(t, err)
+		if e.RuleId == "0" {
+			continue
+		}
+		assert.Equal(t, "firing", e.Type)
+		assert.Equal(t, "1", e.RuleId)
+		assert.Equal(t, uint64(42), e.Fingerprint)
+		assert.Equal(t, "checkout", e.Labels["service_name"])
+		assert.Equal(t, ts, e.Timestamp.AsTime())
+		break
+	}
+
+	manager.events.Stop()
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
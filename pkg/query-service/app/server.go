@@ -30,6 +30,7 @@ import (
 	"go.signoz.io/signoz/pkg/query-service/app/opamp"
 	opAmpModel "go.signoz.io/signoz/pkg/query-service/app/opamp/model"
 	"go.signoz.io/signoz/pkg/query-service/app/preferences"
+	"go.signoz.io/signoz/pkg/query-service/app/rulesgrpc"
 	"go.signoz.io/signoz/pkg/query-service/common"
 	"go.signoz.io/signoz/pkg/query-service/migrate"
 	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
@@ -49,6 +50,7 @@ import (
 	"go.signoz.io/signoz/pkg/query-service/telemetry"
 	"go.signoz.io/signoz/pkg/query-service/utils"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 )
 
 type ServerOptions struct {
@@ -66,6 +68,9 @@ type ServerOptions struct {
 	CacheConfigPath   string
 	FluxInterval      string
 	Cluster           string
+	// RulesGRPCHostPort is the address of the grpc API of the rules, it is
+	// not served when empty
+	RulesGRPCHostPort string
 }
 
 // Server runs HTTP, Mux and a grpc server
@@ -81,6 +86,10 @@ type Server struct {
 	privateConn net.Listener
 	privateHTTP *http.Server
 
+	// grpc API of the rules
+	rulesGRPCConn net.Listener
+	rulesGRPC     *grpc.Server
+
 	opampServer *opamp.Server
 
 	unavailableChannel chan healthcheck.Status
@@ -182,6 +191,12 @@ func NewServer(serverOptions *ServerOptions) (*Server, error) {
 		return nil, err
 	}
 
+	// the stored channels are checked against the egress allowlist loaded
+	// with this configuration, the ones it refuses are logged
+	if channels, apiErr := reader.GetChannels(); apiErr == nil {
+		checkChannelsEgress(*channels, am.DefaultEgressPolicy())
+	}
+
 	telemetry.GetInstance().SetReader(reader)
 	apiHandler, err := NewAPIHandler(APIHandlerOpts{
 		Reader:                        reader,
@@ -225,6 +240,10 @@ func NewServer(serverOptions *ServerOptions) (*Server, error) {
 
 	s.privateHTTP = privateServer
 
+	if serverOptions.RulesGRPCHostPort != "" {
+		s.rulesGRPC = rulesgrpc.NewServer(rm, getUserFromRequest)
+	}
+
 	_, err = opAmpModel.InitDB(localDB)
 	if err != nil {
 		return nil, err
@@ -284,19 +303,6 @@ func (s *Server) createPublicServer(api *APIHandler) (*http.Server, error) {
 	r.Use(loggingMiddleware)
 
 	// add auth middleware
-	getUserFromRequest := func(r *http.Request) (*model.UserPayload, error) {
-		user, err := auth.GetUserFromRequest(r)
-
-		if err != nil {
-			return nil, err
-		}
-
-		if user.User.OrgId == "" {
-			return nil, model.UnauthorizedError(errors.New("orgId is missing in the claims"))
-		}
-
-		return user, nil
-	}
 	am := NewAuthMiddleware(getUserFromRequest)
 
 	api.RegisterRoutes(r, am)
@@ -306,6 +312,8 @@ func (s *Server) createPublicServer(api *APIHandler) (*http.Server, error) {
 	api.RegisterWebSocketPaths(r, am)
 	api.RegisterQueryRangeV4Routes(r, am)
 	api.RegisterMessagingQueuesRoutes(r, am)
+	api.RegisterAlertmanagerV2Routes(r, am)
+	api.RegisterSlackRoutes(r, am)
 
 	c := cors.New(cors.Options{
 		AllowedOrigins: []string{"*"},
@@ -322,6 +330,21 @@ func (s *Server) createPublicServer(api *APIHandler) (*http.Server, error) {
 	}, nil
 }
 
+// getUserFromRequest authenticates the user of the public APIs
+func getUserFromRequest(r *http.Request) (*model.UserPayload, error) {
+	user, err := auth.GetUserFromRequest(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if user.User.OrgId == "" {
+		return nil, model.UnauthorizedError(errors.New("orgId is missing in the claims"))
+	}
+
+	return user, nil
+}
+
 // TODO(remove): Implemented at pkg/http/middleware/logging.go
 // loggingMiddleware is used for logging public api calls
 func loggingMiddleware(next http.Handler) http.Handler {
@@ -608,6 +631,14 @@ func (s *Server) initListeners() error {
 	}
 	zap.L().Info(fmt.Sprintf("Query server started listening on private port %s...", s.serverOptions.PrivateHostPort))
 
+	if s.rulesGRPC != nil {
+		s.rulesGRPCConn, err = net.Listen("tcp", s.serverOptions.RulesGRPCHostPort)
+		if err != nil {
+			return err
+		}
+		zap.L().Info(fmt.Sprintf("Query server started listening on rules grpc port %s...", s.serverOptions.RulesGRPCHostPort))
+	}
+
 	return nil
 }
 
@@ -672,6 +703,17 @@ func (s *Server) Start() error {
 
 	}()
 
+	if s.rulesGRPC != nil {
+		go func() {
+			zap.L().Info("Starting rules gRPC server", zap.String("addr", s.serverOptions.RulesGRPCHostPort))
+
+			if err := s.rulesGRPC.Serve(s.rulesGRPCConn); err != nil && err != grpc.ErrServerStopped {
+				zap.L().Error("Could not start rules gRPC server", zap.Error(err))
+				s.unavailableChannel <- healthcheck.Unavailable
+			}
+		}()
+	}
+
 	go func() {
 		zap.L().Info("Starting OpAmp Websocket server", zap.String("addr", constants.OpAmpWsEndpoint))
 		err := s.opampServer.Start(constants.OpAmpWsEndpoint)
@@ -697,6 +739,10 @@ func (s *Server) Stop() error {
 		}
 	}
 
+	if s.rulesGRPC != nil {
+		s.rulesGRPC.GracefulStop()
+	}
+
 	s.opampServer.Stop()
 
 	if s.ruleManager != nil {
@@ -728,12 +774,21 @@ func makeRulesManager(
 		AlertManagerURLs: []string{alertManagerURL},
 	}
 
+	// the rules are stored in postgres when it is set so that the
+	// replicas do not depend on a single-node sqlite file
+	if dsn := constants.GetRulesDBDSN(); dsn != "" {
+		if db, err = rules.OpenPostgres(dsn); err != nil {
+			return nil, err
+		}
+	}
+
 	// create manager opts
 	managerOpts := &rules.ManagerOptions{
 		NotifierOpts: notifierOpts,
 		Queriers: &rules.Queriers{
 			PqlEngine: pqle,
 			Ch:        ch.GetConn(),
+			Cache:     rules.NewQueryCache(constants.GetRuleQueryCacheTTL()),
 		},
 		RepoURL:      ruleRepoURL,
 		DBConn:       db,
@@ -743,6 +798,132 @@ func makeRulesManager(
 		FeatureFlags: fm,
 		Reader:       ch,
 		EvalDelay:    constants.GetEvalDelay(),
+		EvalWorkers:  constants.GetRuleEvalWorkers(),
+		EvalTimeout:  constants.GetRuleEvalTimeout(),
+
+		EvalTenantWorkers:   constants.GetRuleEvalTenantWorkers(),
+		OwnerTeamLabel:      constants.GetRuleOwnerTeamLabel(),
+		ChaosChannels:       constants.GetRuleChaosChannels(),
+		EvalCatchUpLookback: constants.GetRuleEvalCatchUpLookback(),
+		EvalLogSize:         constants.GetRuleEvalLogSize(),
+		QueryCostWarnRows:   constants.GetRuleQueryCostWarnRows(),
+		QueryCostMaxRows:    constants.GetRuleQueryCostMaxRows(),
+		MaxActiveAlerts:     constants.GetRuleMaxActiveAlerts(),
+		MaxSeries:           constants.GetRuleMaxSeries(),
+		TraceExemplars:      constants.GetRuleTraceExemplars(),
+		LogSamples:          constants.GetRuleLogSamples(),
+		ClickHouseSandbox: &rules.ClickHouseSandbox{
+			MaxExecutionTime: constants.GetRuleClickHouseMaxExecutionTime(),
+			MaxRowsToRead:    constants.GetRuleClickHouseMaxRowsToRead(),
+			MaxMemoryUsage:   constants.GetRuleClickHouseMaxMemoryUsage(),
+			ReadOnly:         constants.IsRuleClickHouseReadOnlyEnabled(),
+			Profile:          constants.GetRuleClickHouseProfile(),
+		},
+		WarmUp:       constants.GetRuleWarmUp(),
+		Downsampling: constants.IsRuleDownsamplingEnabled(),
+		StateHistoryRetention: rules.StateHistoryRetention{
+			Detail:      rules.Duration(constants.GetRuleStateHistoryDetailRetention()),
+			Transitions: rules.Duration(constants.GetRuleStateHistoryTransitionsRetention()),
+		},
+		StaleRules: rules.StaleRuleOptions{
+			After:       constants.GetRuleStaleAfter(),
+			AutoDisable: constants.IsRuleStaleAutoDisableEnabled(),
+		},
+		Incidents: rules.IncidentOptions{
+			AutoSeverities: constants.GetRuleIncidentAutoSeverities(),
+			Window:         constants.GetRuleIncidentWindow(),
+		},
+		Correlation: rules.CorrelationOptions{
+			Window:   constants.GetRuleCorrelationWindow(),
+			Annotate: constants.IsRuleCorrelationAnnotateEnabled(),
+		},
+		Dedup: rules.DedupOptions{
+			Window:       constants.GetRuleDedupWindow(),
+			IgnoreLabels: constants.GetRuleDedupIgnoreLabels(),
+		},
+	}
+
+	warmUpMode, err := rules.ParseWarmUpMode(constants.GetRuleWarmUpMode())
+	if err != nil {
+		return nil, err
+	}
+	managerOpts.WarmUpMode = warmUpMode
+
+	if path := constants.GetRuleQueryBackendsConfig(); path != "" {
+		if err := managerOpts.Queriers.RegisterBackends(path); err != nil {
+			return nil, err
+		}
+	}
+
+	managerOpts.Events = rules.NewEventBus()
+	if path := constants.GetRuleEventSinksConfig(); path != "" {
+		if err := managerOpts.Events.RegisterSinks(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if path := constants.GetRuleTeamQuotasConfig(); path != "" {
+		quotas, err := rules.LoadTeamQuotas(path)
+		if err != nil {
+			return nil, err
+		}
+		managerOpts.TeamQuotas = quotas
+	}
+
+	if path := constants.GetRuleExternalAlertmanagersConfig(); path != "" {
+		externals, err := am.LoadExternalAlertmanagers(path, managerOpts.NotifierOpts.QueueCapacity)
+		if err != nil {
+			return nil, err
+		}
+		managerOpts.ExternalAlertmanagers = externals
+	}
+
+	if endpoint := constants.GetRuleRemoteWriteURL(); endpoint != "" {
+		username, password := constants.GetRuleRemoteWriteBasicAuth()
+		writer, err := rules.NewRemoteWriter(rules.RemoteWriteOptions{
+			URL:      endpoint,
+			Username: username,
+			Password: password,
+		})
+		if err != nil {
+			return nil, err
+		}
+		managerOpts.RemoteWrite = writer
+	}
+
+	if dir := constants.GetRuleAlertSpillDir(); dir != "" {
+		spill, err := rules.NewDiskAlertSpill(dir)
+		if err != nil {
+			return nil, err
+		}
+		managerOpts.AlertSpill = spill
+	}
+
+	if channel := constants.GetRuleDigestChannel(); channel != "" {
+		weekday, err := rules.ParseWeekday(constants.GetRuleDigestWeekday())
+		if err != nil {
+			return nil, err
+		}
+		managerOpts.AlertDigest = &rules.AlertDigestOptions{
+			Channel:   channel,
+			Weekday:   weekday,
+			Hour:      constants.GetRuleDigestHour(),
+			TeamLabel: constants.GetRuleDigestTeamLabel(),
+		}
+	}
+
+	if constants.IsRuleShardingEnabled() {
+		managerOpts.Sharding = &rules.ShardOptions{
+			ReplicaID: constants.GetRuleReplicaID(),
+		}
+	}
+
+	// the objects stored before they were scoped by org belong to the
+	// only org of the install
+	if orgs, apiErr := dao.DB().GetOrgs(context.Background()); apiErr != nil {
+		zap.L().Error("failed to get the orgs", zap.Error(apiErr.Err))
+	} else if len(orgs) == 1 {
+		managerOpts.LegacyOrg = orgs[0].Id
 	}
 
 	// create Manager
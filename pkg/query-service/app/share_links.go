@@ -0,0 +1,69 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.signoz.io/signoz/pkg/query-service/rules"
+)
+
+// createShareLink creates an expiring read-only link of the rule, or of
+// one of its alerts, viewable without login
+func (aH *APIHandler) createShareLink(w http.ResponseWriter, r *http.Request) {
+	req := rules.ShareLinkRequest{}
+	// the request body is optional
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	link, apiErr := aH.ruleManager.CreateShareLink(r.Context(), mux.Vars(r)["id"], &req)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, link)
+}
+
+// listShareLinks returns the share links of the rule that did not expire
+func (aH *APIHandler) listShareLinks(w http.ResponseWriter, r *http.Request) {
+	links, apiErr := aH.ruleManager.ShareLinks(r.Context(), mux.Vars(r)["id"])
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, links)
+}
+
+// revokeShareLink deletes a share link of the rule
+func (aH *APIHandler) revokeShareLink(w http.ResponseWriter, r *http.Request) {
+	linkId, err := strconv.ParseInt(mux.Vars(r)["linkId"], 10, 64)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("invalid share link id %q", mux.Vars(r)["linkId"])}, nil)
+		return
+	}
+
+	if apiErr := aH.ruleManager.RevokeShareLink(r.Context(), mux.Vars(r)["id"], linkId); apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, "share link revoked")
+}
+
+// getSharedAlertView serves the view of a share link, the token of the
+// link authenticates the request
+func (aH *APIHandler) getSharedAlertView(w http.ResponseWriter, r *http.Request) {
+	view, apiErr := aH.ruleManager.SharedAlertView(r.Context(), mux.Vars(r)["token"])
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	aH.Respond(w, view)
+}
@@ -0,0 +1,297 @@
+package app
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.signoz.io/signoz/pkg/query-service/auth"
+	"go.signoz.io/signoz/pkg/query-service/constants"
+	"go.signoz.io/signoz/pkg/query-service/dao"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+	"go.uber.org/zap"
+)
+
+const (
+	// slackMaxSkew is how old a slack request can be, older requests are
+	// rejected as replays
+	slackMaxSkew = 5 * time.Minute
+	// slackMaxAlerts is the number of firing alerts listed by the command
+	slackMaxAlerts = 20
+
+	slackActionAck     = "ack"
+	slackActionSnooze  = "snooze"
+	slackActionSilence = "silence"
+
+	slackSnoozeDuration  = time.Hour
+	slackSilenceDuration = 24 * time.Hour
+)
+
+// RegisterSlackRoutes serves the slash command and the interactivity of
+// the slack app. The requests are authenticated with the signing secret of
+// the app and the slack users act as the SigNoz user with the same email.
+func (aH *APIHandler) RegisterSlackRoutes(router *mux.Router, am *AuthMiddleware) {
+	router.HandleFunc("/api/v1/slack/commands", am.OpenAccess(aH.slackCommand)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/slack/interactions", am.OpenAccess(aH.slackInteraction)).Methods(http.MethodPost)
+}
+
+// slackMessage is the response to a slash command or an interaction, it is
+// only visible to the slack user
+type slackMessage struct {
+	ResponseType    string        `json:"response_type,omitempty"`
+	ReplaceOriginal bool          `json:"replace_original,omitempty"`
+	Text            string        `json:"text"`
+	Blocks          []interface{} `json:"blocks,omitempty"`
+}
+
+func respondSlack(w http.ResponseWriter, text string, blocks []interface{}) {
+	b, _ := json.Marshal(slackMessage{ResponseType: "ephemeral", Text: text, Blocks: blocks})
+	w.Header().Set("Content-Type", "application/json")
+	if n, err := w.Write(b); err != nil {
+		zap.L().Error("error writing response", zap.Int("bytesWritten", n), zap.Error(err))
+	}
+}
+
+// verifySlackRequest checks the signature of the request and returns its
+// form
+func verifySlackRequest(r *http.Request, secret string, now time.Time) (url.Values, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("the slack app is not configured")
+	}
+	ts := r.Header.Get("X-Slack-Request-Timestamp")
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid slack request timestamp")
+	}
+	if d := now.Sub(time.Unix(sec, 0)); d > slackMaxSkew || d < -slackMaxSkew {
+		return nil, fmt.Errorf("stale slack request")
+	}
+
+	defer r.Body.Close()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:", ts)
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(r.Header.Get("X-Slack-Signature"))) {
+		return nil, fmt.Errorf("invalid slack signature")
+	}
+	return url.ParseQuery(string(body))
+}
+
+// slackUser returns the SigNoz user of the slack user, matched by email
+func slackUser(ctx context.Context, slackUserID string) (*model.UserPayload, error) {
+	if constants.SlackBotToken == "" {
+		return nil, fmt.Errorf("the slack bot token is not set, slack users can not be mapped to SigNoz users")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://slack.com/api/users.info?user="+url.QueryEscape(slackUserID), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+constants.SlackBotToken)
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var info struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+		User  struct {
+			Profile struct {
+				Email string `json:"email"`
+			} `json:"profile"`
+		} `json:"user"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, err
+	}
+	if !info.OK {
+		return nil, fmt.Errorf("failed to look up the slack user: %s", info.Error)
+	}
+	user, apiErr := dao.DB().GetUserByEmail(ctx, info.User.Profile.Email)
+	if apiErr != nil {
+		return nil, apiErr.Err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("no SigNoz user with the email %s", info.User.Profile.Email)
+	}
+	return user, nil
+}
+
+// slackCommand handles /signoz alerts, the firing alerts are listed with
+// the buttons to acknowledge, snooze and silence them
+func (aH *APIHandler) slackCommand(w http.ResponseWriter, r *http.Request) {
+	form, err := verifySlackRequest(r, constants.SlackSigningSecret, time.Now())
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorUnauthorized, Err: err}, nil)
+		return
+	}
+
+	if args := strings.Fields(form.Get("text")); len(args) == 0 || args[0] != "alerts" {
+		respondSlack(w, "Usage: /signoz alerts", nil)
+		return
+	}
+	user, err := slackUser(r.Context(), form.Get("user_id"))
+	if err != nil {
+		respondSlack(w, err.Error(), nil)
+		return
+	}
+	// the alerts of the org of the user only
+	ctx := context.WithValue(r.Context(), constants.ContextUserKey, user)
+
+	alerts, err := aH.ruleManager.AMv2Alerts(ctx, nil, false)
+	if err != nil {
+		respondSlack(w, fmt.Sprintf("failed to list the alerts: %v", err), nil)
+		return
+	}
+	if len(alerts) == 0 {
+		respondSlack(w, "No alerts are firing", nil)
+		return
+	}
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].StartsAt.Before(alerts[j].StartsAt) })
+
+	text := fmt.Sprintf("%d alerts are firing", len(alerts))
+	if len(alerts) > slackMaxAlerts {
+		text = fmt.Sprintf("%d alerts are firing, the %d oldest are listed", len(alerts), slackMaxAlerts)
+		alerts = alerts[:slackMaxAlerts]
+	}
+	blocks := []interface{}{slackSection(text)}
+	for _, a := range alerts {
+		ruleID := a.Labels[labels.AlertRuleIdLabel]
+		blocks = append(blocks, slackSection(fmt.Sprintf("*%s* firing since <!date^%d^{date_short_pretty} {time}|%s>\n%s",
+			a.Labels[labels.AlertNameLabel], a.StartsAt.Unix(), a.StartsAt.UTC().Format(time.RFC3339), a.Annotations["summary"])))
+		if ruleID == "" {
+			continue
+		}
+		value := ruleID + ":" + a.Fingerprint
+		blocks = append(blocks, map[string]interface{}{
+			"type": "actions",
+			"elements": []interface{}{
+				slackButton(slackActionAck, "Acknowledge", value),
+				slackButton(slackActionSnooze, "Snooze 1h", value),
+				slackButton(slackActionSilence, "Silence 24h", value),
+			},
+		})
+	}
+	respondSlack(w, text, blocks)
+}
+
+func slackSection(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "section",
+		"text": map[string]string{"type": "mrkdwn", "text": text},
+	}
+}
+
+func slackButton(actionID, text, value string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":      "button",
+		"action_id": actionID,
+		"text":      map[string]string{"type": "plain_text", "text": text},
+		"value":     value,
+	}
+}
+
+// slackInteractionPayload is the payload of the buttons of the block kit
+// messages and of the legacy attachments sent by the slack channels
+type slackInteractionPayload struct {
+	Type string `json:"type"`
+	User struct {
+		ID string `json:"id"`
+	} `json:"user"`
+	Actions []struct {
+		// ActionID is set by the block kit buttons, Name by the buttons
+		// of the attachments
+		ActionID string `json:"action_id"`
+		Name     string `json:"name"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+}
+
+// slackInteraction handles the ack, snooze and silence buttons. The value
+// of the button is the rule id, followed by the hex fingerprint of the
+// alert when the button is for one alert: {{ .CommonLabels.ruleId }} in the
+// actions of the slack channels.
+func (aH *APIHandler) slackInteraction(w http.ResponseWriter, r *http.Request) {
+	form, err := verifySlackRequest(r, constants.SlackSigningSecret, time.Now())
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorUnauthorized, Err: err}, nil)
+		return
+	}
+
+	var payload slackInteractionPayload
+	if err := json.Unmarshal([]byte(form.Get("payload")), &payload); err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	if len(payload.Actions) == 0 {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	user, err := slackUser(r.Context(), payload.User.ID)
+	if err != nil {
+		respondSlack(w, err.Error(), nil)
+		return
+	}
+	if !(auth.IsEditor(user) || auth.IsAdmin(user)) {
+		respondSlack(w, "Only the editors and admins can act on the alerts", nil)
+		return
+	}
+	ctx := context.WithValue(r.Context(), constants.ContextUserKey, user)
+
+	action := payload.Actions[0]
+	name := action.ActionID
+	if name == "" {
+		name = action.Name
+	}
+	ruleID, fp, _ := strings.Cut(action.Value, ":")
+	var fingerprint uint64
+	if fp != "" {
+		if fingerprint, err = strconv.ParseUint(fp, 16, 64); err != nil {
+			respondSlack(w, fmt.Sprintf("invalid alert fingerprint %q", fp), nil)
+			return
+		}
+	}
+
+	var apiErr *model.ApiError
+	var text string
+	switch name {
+	case slackActionAck:
+		var acked int
+		acked, apiErr = aH.ruleManager.AcknowledgeRuleAlerts(ctx, ruleID, fingerprint)
+		text = fmt.Sprintf("%s acknowledged %d alerts of rule %s", user.Email, acked, ruleID)
+	case slackActionSnooze:
+		_, apiErr = aH.ruleManager.MuteRule(ctx, ruleID, slackSnoozeDuration, fmt.Sprintf("snoozed from slack by %s", user.Email))
+		text = fmt.Sprintf("%s snoozed rule %s for %s", user.Email, ruleID, slackSnoozeDuration)
+	case slackActionSilence:
+		_, apiErr = aH.ruleManager.MuteRule(ctx, ruleID, slackSilenceDuration, fmt.Sprintf("silenced from slack by %s", user.Email))
+		text = fmt.Sprintf("%s silenced rule %s for %s", user.Email, ruleID, slackSilenceDuration)
+	default:
+		respondSlack(w, fmt.Sprintf("unknown action %q", name), nil)
+		return
+	}
+	if apiErr != nil {
+		respondSlack(w, apiErr.Err.Error(), nil)
+		return
+	}
+	respondSlack(w, text, nil)
+}
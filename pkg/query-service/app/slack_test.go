@@ -0,0 +1,58 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifySlackRequest(t *testing.T) {
+	secret := "8f742231b10e8888abcd99yyyzzz85a5"
+	body := "command=%2Fsignoz&text=alerts&user_id=U2147483697"
+	now := time.Unix(1531420618, 0)
+
+	sign := func(ts time.Time, body string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte("v0:" + strconv.FormatInt(ts.Unix(), 10) + ":" + body))
+		return "v0=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	tests := []struct {
+		name      string
+		ts        time.Time
+		signature string
+		secret    string
+		wantErr   bool
+	}{
+		{name: "valid", ts: now, signature: sign(now, body), secret: secret},
+		{name: "tampered", ts: now, signature: sign(now, body+"&x=1"), secret: secret, wantErr: true},
+		{name: "replayed", ts: now.Add(-10 * time.Minute), signature: sign(now.Add(-10*time.Minute), body), secret: secret, wantErr: true},
+		{name: "not configured", ts: now, signature: sign(now, body), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/v1/slack/commands", strings.NewReader(body))
+			r.Header.Set("X-Slack-Request-Timestamp", strconv.FormatInt(tt.ts.Unix(), 10))
+			r.Header.Set("X-Slack-Signature", tt.signature)
+
+			form, err := verifySlackRequest(r, tt.secret, now)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if form.Get("text") != "alerts" || form.Get("user_id") != "U2147483697" {
+				t.Errorf("unexpected form %v", form)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 	"text/template"
 	"time"
 
@@ -510,6 +511,7 @@ func GenerateJWTForUser(user *model.User) (model.UserJwtObject, error) {
 		"email": user.Email,
 		"exp":   j.AccessJwtExpiry,
 		"orgId": user.OrgId,
+		"teams": strings.Join(user.Teams, ","),
 	})
 
 	j.AccessJwt, err = token.SignedString([]byte(JwtSecret))
@@ -524,6 +526,7 @@ func GenerateJWTForUser(user *model.User) (model.UserJwtObject, error) {
 		"email": user.Email,
 		"exp":   j.RefreshJwtExpiry,
 		"orgId": user.OrgId,
+		"teams": strings.Join(user.Teams, ","),
 	})
 
 	j.RefreshJwt, err = token.SignedString([]byte(JwtSecret))
@@ -56,12 +56,18 @@ func validateUser(tok string) (*model.UserPayload, error) {
 		orgId = claims["orgId"].(string)
 	}
 
+	var teams model.UserTeams
+	if claims["teams"] != nil {
+		teams.Scan(claims["teams"].(string))
+	}
+
 	return &model.UserPayload{
 		User: model.User{
 			Id:      claims["id"].(string),
 			GroupId: claims["gid"].(string),
 			Email:   claims["email"].(string),
 			OrgId:   orgId,
+			Teams:   teams,
 		},
 	}, nil
 }
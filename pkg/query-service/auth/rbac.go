@@ -3,6 +3,7 @@ package auth
 import (
 	"context"
 	"net/http"
+	"slices"
 
 	"github.com/pkg/errors"
 	"go.signoz.io/signoz/pkg/query-service/constants"
@@ -67,6 +68,12 @@ func IsViewer(user *model.UserPayload) bool { return user.GroupId == AuthCacheOb
 func IsEditor(user *model.UserPayload) bool { return user.GroupId == AuthCacheObj.EditorGroupId }
 func IsAdmin(user *model.UserPayload) bool  { return user.GroupId == AuthCacheObj.AdminGroupId }
 
+// IsTeamMember tells whether the user can change the objects owned by the
+// team, the admins can change the objects of all the teams
+func IsTeamMember(user *model.UserPayload, team string) bool {
+	return team == "" || IsAdmin(user) || slices.Contains(user.Teams, team)
+}
+
 func ValidatePassword(password string) error {
 	if len(password) < minimumPasswordLength {
 		return errors.Errorf("Password should be atleast %d characters.", minimumPasswordLength)
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+// The scopes of the API tokens of the automations managing the alerts
+const (
+	ScopeRulesRead    = "rules:read"
+	ScopeRulesWrite   = "rules:write"
+	ScopeAlertsAck    = "alerts:ack"
+	ScopeChannelsTest = "channels:test"
+)
+
+var Scopes = []string{ScopeRulesRead, ScopeRulesWrite, ScopeAlertsAck, ScopeChannelsTest}
+
+func ValidateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return fmt.Errorf("unknown scope %q, expected one of %v", scope, Scopes)
+		}
+	}
+	return nil
+}
+
+// HasScope tells whether the user can reach an API of the scope, the users
+// without scopes reach all the APIs and the ones with scopes only the APIs
+// of their scopes
+func HasScope(user *model.UserPayload, scope string) bool {
+	if len(user.Scopes) == 0 {
+		return true
+	}
+	return scope != "" && slices.Contains(user.Scopes, scope)
+}
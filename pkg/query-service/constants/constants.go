@@ -3,6 +3,7 @@ package constants
 import (
 	"os"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -75,6 +76,8 @@ var TimestampSortFeature = GetOrDefaultEnv("TIMESTAMP_SORT_FEATURE", "true")
 
 var PreferRPMFeature = GetOrDefaultEnv("PREFER_RPM_FEATURE", "false")
 
+var AlertChartSnapshots = GetOrDefaultEnv("ALERT_CHART_SNAPSHOTS", "true")
+
 func IsDurationSortFeatureEnabled() bool {
 	isDurationSortFeatureEnabledStr := DurationSortFeature
 	isDurationSortFeatureEnabledBool, err := strconv.ParseBool(isDurationSortFeatureEnabledStr)
@@ -161,6 +164,437 @@ func GetEvalDelay() time.Duration {
 	return evalDelayDuration
 }
 
+// GetRuleEvalWorkers returns the number of rules evaluated concurrently
+func GetRuleEvalWorkers() int {
+	return GetOrDefaultEnvInt("RULES_EVAL_WORKERS", 10)
+}
+
+// GetRuleEvalTenantWorkers returns the number of rules of an org
+// evaluated concurrently, zero only shares the workers fairly between the
+// orgs
+func GetRuleEvalTenantWorkers() int {
+	return GetOrDefaultEnvInt("RULES_EVAL_TENANT_WORKERS", 0)
+}
+
+// GetRuleEvalLogSize returns the number of evaluations recorded in the
+// evaluation log of every rule, zero disables the log
+func GetRuleEvalLogSize() int {
+	return GetOrDefaultEnvInt("RULES_EVAL_LOG_SIZE", 0)
+}
+
+// GetRuleQueryCostWarnRows returns the number of rows scanned by one
+// evaluation of a rule above which saving the rule logs a warning
+func GetRuleQueryCostWarnRows() uint64 {
+	return uint64(GetOrDefaultEnvInt("RULES_QUERY_COST_WARN_ROWS", 0))
+}
+
+// GetRuleQueryCostMaxRows returns the number of rows scanned by one
+// evaluation of a rule above which the rule is not saved
+func GetRuleQueryCostMaxRows() uint64 {
+	return uint64(GetOrDefaultEnvInt("RULES_QUERY_COST_MAX_ROWS", 0))
+}
+
+// GetRuleClickHouseMaxExecutionTime returns the longest a raw clickhouse
+// query of a rule runs, zero is unlimited
+func GetRuleClickHouseMaxExecutionTime() time.Duration {
+	d, err := time.ParseDuration(GetOrDefaultEnv("RULES_CLICKHOUSE_MAX_EXECUTION_TIME", "30s"))
+	if err != nil {
+		return 30 * time.Second
+	}
+	return d
+}
+
+// GetRuleClickHouseMaxRowsToRead returns the number of rows a raw
+// clickhouse query of a rule reads at most, zero is unlimited
+func GetRuleClickHouseMaxRowsToRead() uint64 {
+	return uint64(GetOrDefaultEnvInt("RULES_CLICKHOUSE_MAX_ROWS_TO_READ", 0))
+}
+
+// GetRuleClickHouseMaxMemoryUsage returns the bytes of memory a raw
+// clickhouse query of a rule uses at most, zero is unlimited
+func GetRuleClickHouseMaxMemoryUsage() uint64 {
+	return uint64(GetOrDefaultEnvInt("RULES_CLICKHOUSE_MAX_MEMORY_USAGE", 0))
+}
+
+// IsRuleClickHouseReadOnlyEnabled tells whether the raw clickhouse queries
+// of the rules run in readonly mode
+func IsRuleClickHouseReadOnlyEnabled() bool {
+	return GetOrDefaultEnv("RULES_CLICKHOUSE_READONLY", "true") == "true"
+}
+
+// GetRuleClickHouseProfile returns the clickhouse settings profile the
+// raw clickhouse queries of the rules run with
+func GetRuleClickHouseProfile() string {
+	return GetOrDefaultEnv("RULES_CLICKHOUSE_PROFILE", "")
+}
+
+// GetRuleTraceExemplars returns the number of example traces attached to
+// the alerts of the traces rules, zero disables them
+func GetRuleTraceExemplars() int {
+	return GetOrDefaultEnvInt("RULES_TRACE_EXEMPLARS", 3)
+}
+
+// GetRuleLogSamples returns the number of log lines attached to the
+// alerts of the logs queries, zero disables them
+func GetRuleLogSamples() int {
+	return GetOrDefaultEnvInt("RULES_LOG_SAMPLES", 5)
+}
+
+// GetRuleMaxActiveAlerts returns the number of active alerts a rule holds
+// in memory, zero uses the default of the rules package
+func GetRuleMaxActiveAlerts() int {
+	return GetOrDefaultEnvInt("RULES_MAX_ACTIVE_ALERTS", 0)
+}
+
+// GetRuleMaxSeries returns the number of series of the selected query
+// of a threshold rule after which its evaluation fails, zero is unlimited
+func GetRuleMaxSeries() int {
+	return GetOrDefaultEnvInt("RULES_MAX_SERIES", 0)
+}
+
+// GetRuleAlertSpillDir returns the directory the active alerts over the
+// limit are written to, they are dropped when it is not set
+func GetRuleAlertSpillDir() string {
+	return GetOrDefaultEnv("RULES_ALERT_SPILL_DIR", "")
+}
+
+// GetRuleQueryBackendsConfig returns the path of the file listing the
+// additional query backends the rules can select
+func GetRuleQueryBackendsConfig() string {
+	return GetOrDefaultEnv("RULES_QUERY_BACKENDS_CONFIG", "")
+}
+
+// GetRuleDigestChannel returns the notification channel the weekly alert
+// quality digest is sent to, empty disables the digest
+func GetRuleDigestChannel() string {
+	return GetOrDefaultEnv("RULES_DIGEST_CHANNEL", "")
+}
+
+// GetRuleDigestWeekday returns the day of the week the digest is sent
+func GetRuleDigestWeekday() string {
+	return GetOrDefaultEnv("RULES_DIGEST_WEEKDAY", "monday")
+}
+
+// GetRuleDigestHour returns the hour of the day, in UTC, the digest is
+// sent
+func GetRuleDigestHour() int {
+	return GetOrDefaultEnvInt("RULES_DIGEST_HOUR", 9)
+}
+
+// GetRuleDigestTeamLabel returns the label of the rules naming their team
+func GetRuleDigestTeamLabel() string {
+	return GetOrDefaultEnv("RULES_DIGEST_TEAM_LABEL", "team")
+}
+
+// GetRuleStaleAfter returns how long the query of a rule returns no
+// series before the rule is stale, zero keeps the default
+func GetRuleStaleAfter() time.Duration {
+	after, err := time.ParseDuration(GetOrDefaultEnv("RULES_STALE_AFTER", "168h"))
+	if err != nil {
+		return 0
+	}
+	return after
+}
+
+// IsRuleStaleAutoDisableEnabled tells whether the stale rules are disabled
+func IsRuleStaleAutoDisableEnabled() bool {
+	enabled, err := strconv.ParseBool(GetOrDefaultEnv("RULES_STALE_AUTO_DISABLE", "false"))
+	if err != nil {
+		return false
+	}
+	return enabled
+}
+
+// GetRuleIncidentAutoSeverities returns the severities or priorities of
+// the alerts that open an incident
+func GetRuleIncidentAutoSeverities() []string {
+	var severities []string
+	for _, s := range strings.Split(GetOrDefaultEnv("RULES_INCIDENT_AUTO_SEVERITIES", "critical,P1"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			severities = append(severities, s)
+		}
+	}
+	return severities
+}
+
+// GetRuleIncidentWindow returns how long after the last alert of an
+// incident the correlated alerts join it, zero keeps the default
+func GetRuleIncidentWindow() time.Duration {
+	window, err := time.ParseDuration(GetOrDefaultEnv("RULES_INCIDENT_WINDOW", "30m"))
+	if err != nil {
+		return 0
+	}
+	return window
+}
+
+// GetRuleCorrelationWindow returns how far apart two alerts can start to
+// be related by time, zero keeps the default
+func GetRuleCorrelationWindow() time.Duration {
+	window, err := time.ParseDuration(GetOrDefaultEnv("RULES_CORRELATION_WINDOW", "15m"))
+	if err != nil {
+		return 0
+	}
+	return window
+}
+
+// IsRuleCorrelationAnnotateEnabled tells whether the notifications list
+// the alerts and changes possibly related to the firing alerts
+func IsRuleCorrelationAnnotateEnabled() bool {
+	enabled, err := strconv.ParseBool(GetOrDefaultEnv("RULES_CORRELATION_ANNOTATE", "false"))
+	if err != nil {
+		return false
+	}
+	return enabled
+}
+
+// GetRuleDedupWindow returns how long a rule keeps contributing to the
+// merged notifications of the alerts with the same labels, zero disables
+// the deduplication
+func GetRuleDedupWindow() time.Duration {
+	window, err := time.ParseDuration(GetOrDefaultEnv("RULES_DEDUP_WINDOW", "0"))
+	if err != nil {
+		return 0
+	}
+	return window
+}
+
+// GetRuleDedupIgnoreLabels returns the labels left out when the alerts of
+// different rules are compared
+func GetRuleDedupIgnoreLabels() []string {
+	var names []string
+	for _, s := range strings.Split(GetOrDefaultEnv("RULES_DEDUP_IGNORE_LABELS", ""), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			names = append(names, s)
+		}
+	}
+	return names
+}
+
+// GetRuleExternalAlertmanagersConfig returns the path of the file listing
+// the Alertmanager clusters the alerts are forwarded to
+func GetRuleExternalAlertmanagersConfig() string {
+	return GetOrDefaultEnv("RULES_EXTERNAL_ALERTMANAGERS_CONFIG", "")
+}
+
+// GetRuleTeamQuotasConfig returns the path of the file with the quotas
+// of the rules, alerts and notifications of the teams
+func GetRuleTeamQuotasConfig() string {
+	return GetOrDefaultEnv("RULES_TEAM_QUOTAS_CONFIG", "")
+}
+
+// GetRulesDBDSN returns the postgres connection string of the rules
+// metadata store, empty keeps the rules in the local sqlite file
+func GetRulesDBDSN() string {
+	return GetOrDefaultEnv("RULES_DB_DSN", "")
+}
+
+// GetRuleOwnerTeamLabel returns the label naming the team owning a rule,
+// empty disables the team ownership of the rules
+func GetRuleOwnerTeamLabel() string {
+	return GetOrDefaultEnv("RULES_OWNER_TEAM_LABEL", "")
+}
+
+// GetRuleChaosChannels returns the sandbox channels the synthetic alert
+// storms can be sent to, empty disables the storms
+func GetRuleChaosChannels() []string {
+	var channels []string
+	for _, c := range strings.Split(GetOrDefaultEnv("RULES_CHAOS_CHANNELS", ""), ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			channels = append(channels, c)
+		}
+	}
+	return channels
+}
+
+// GetRuleRemoteWriteURL returns the Prometheus remote write endpoint the
+// results of the recording rules are written to
+func GetRuleRemoteWriteURL() string {
+	return GetOrDefaultEnv("RULES_REMOTE_WRITE_URL", "")
+}
+
+// GetRuleRemoteWriteBasicAuth returns the credentials of the remote write
+// endpoint
+func GetRuleRemoteWriteBasicAuth() (string, string) {
+	return GetOrDefaultEnv("RULES_REMOTE_WRITE_USERNAME", ""), GetOrDefaultEnv("RULES_REMOTE_WRITE_PASSWORD", "")
+}
+
+// GetRuleEventSinksConfig returns the path of the file listing the sinks
+// the state transitions of the alerts are sent to
+func GetRuleEventSinksConfig() string {
+	return GetOrDefaultEnv("RULES_EVENT_SINKS_CONFIG", "")
+}
+
+// GetRuleEvalTimeout returns the maximum duration of a rule evaluation,
+// zero limits the evaluation to the frequency of the rule
+func GetRuleEvalTimeout() time.Duration {
+	timeout, err := time.ParseDuration(GetOrDefaultEnv("RULES_EVAL_TIMEOUT", "0s"))
+	if err != nil {
+		return 0
+	}
+	return timeout
+}
+
+// GetRuleWarmUp returns how long after the start the rules are evaluated
+// without sending their alerts, zero disables the warm up
+func GetRuleWarmUp() time.Duration {
+	warmUp, err := time.ParseDuration(GetOrDefaultEnv("RULES_WARM_UP", "0s"))
+	if err != nil {
+		return 0
+	}
+	return warmUp
+}
+
+// GetRuleWarmUpMode returns what happens to the alerts found during the
+// warm up, suppress or rebuild
+func GetRuleWarmUpMode() string {
+	return GetOrDefaultEnv("RULES_WARM_UP_MODE", "suppress")
+}
+
+// GetRuleStateHistoryDetailRetention returns how long every item of the
+// rule state history is kept, zero keeps the default
+func GetRuleStateHistoryDetailRetention() time.Duration {
+	retention, err := time.ParseDuration(GetOrDefaultEnv("RULES_STATE_HISTORY_DETAIL_RETENTION", "720h"))
+	if err != nil {
+		return 0
+	}
+	return retention
+}
+
+// GetRuleStateHistoryTransitionsRetention returns how long the state
+// changes of the rule state history are kept, zero keeps the default
+func GetRuleStateHistoryTransitionsRetention() time.Duration {
+	retention, err := time.ParseDuration(GetOrDefaultEnv("RULES_STATE_HISTORY_TRANSITIONS_RETENTION", "8760h"))
+	if err != nil {
+		return 0
+	}
+	return retention
+}
+
+// GetRuleQueryCacheTTL returns how long the rules share a query result,
+// zero disables the cache
+func GetRuleQueryCacheTTL() time.Duration {
+	ttl, err := time.ParseDuration(GetOrDefaultEnv("RULES_QUERY_CACHE_TTL", "30s"))
+	if err != nil {
+		return 0
+	}
+	return ttl
+}
+
+// GetRuleEvalCatchUpLookback returns how far back the missed evaluations
+// of a rule are re-evaluated, zero disables the catch up
+func GetRuleEvalCatchUpLookback() time.Duration {
+	lookback, err := time.ParseDuration(GetOrDefaultEnv("RULES_EVAL_CATCHUP_LOOKBACK", "0s"))
+	if err != nil {
+		return 0
+	}
+	return lookback
+}
+
+// IsRuleDownsamplingEnabled tells whether the threshold rules may read the
+// aggregated samples tables
+func IsRuleDownsamplingEnabled() bool {
+	enabled, err := strconv.ParseBool(GetOrDefaultEnv("RULES_DOWNSAMPLING_ENABLED", "false"))
+	if err != nil {
+		return false
+	}
+	return enabled
+}
+
+// IsRuleShardingEnabled tells whether the rules are split across the
+// replicas sharing the rule db
+func IsRuleShardingEnabled() bool {
+	enabled, err := strconv.ParseBool(GetOrDefaultEnv("RULES_SHARDING_ENABLED", "false"))
+	if err != nil {
+		return false
+	}
+	return enabled
+}
+
+// GetRuleReplicaID returns the id of the replica on the sharding ring,
+// the host name by default
+func GetRuleReplicaID() string {
+	hostname, _ := os.Hostname()
+	return GetOrDefaultEnv("RULES_REPLICA_ID", hostname)
+}
+
+// IsRuleTracingEnabled tells whether the spans of the rule evaluations
+// are exported to the OTLP endpoint
+func IsRuleTracingEnabled() bool {
+	enabled, err := strconv.ParseBool(GetOrDefaultEnv("RULES_TRACING_ENABLED", "false"))
+	if err != nil {
+		return false
+	}
+	return enabled
+}
+
+// IsAlertChartSnapshotsEnabled tells whether threshold rules render a
+// chart snapshot of the alerting query when an alert starts firing
+func IsAlertChartSnapshotsEnabled() bool {
+	enabled, err := strconv.ParseBool(AlertChartSnapshots)
+	if err != nil {
+		return false
+	}
+	return enabled
+}
+
+// Push notification providers, a provider is only enabled when its
+// credentials are configured
+var (
+	FCMProjectID       = GetOrDefaultEnv("FCM_PROJECT_ID", "")
+	FCMCredentialsFile = GetOrDefaultEnv("FCM_CREDENTIALS_FILE", "")
+	APNSKeyFile        = GetOrDefaultEnv("APNS_KEY_FILE", "")
+	APNSKeyID          = GetOrDefaultEnv("APNS_KEY_ID", "")
+	APNSTeamID         = GetOrDefaultEnv("APNS_TEAM_ID", "")
+	APNSTopic          = GetOrDefaultEnv("APNS_TOPIC", "io.signoz.app")
+	APNSEndpoint       = GetOrDefaultEnv("APNS_ENDPOINT", "https://api.push.apple.com")
+)
+
+// NotificationEgressAllowlist is a comma separated list of hosts,
+// wildcard domains (*.slack.com) and CIDR ranges notifications can be
+// delivered to. Empty allows all destinations.
+var NotificationEgressAllowlist = GetOrDefaultEnv("NOTIFICATION_EGRESS_ALLOWLIST", "")
+
+// ChannelSecretsKey is the base64 encoded 32 bytes master key encrypting
+// the secrets of the notification channels at rest. The secrets are stored
+// as they are when it is not set.
+var ChannelSecretsKey = GetOrDefaultEnv("CHANNEL_SECRETS_KEY", "")
+
+// SecretsAllowedPrefixes is a comma separated list of the references to
+// the external secret stores channels can use, e.g.
+// env/SIGNOZ_CHANNEL_,vault/secret/data/signoz/. No reference is allowed
+// when it is not set.
+var SecretsAllowedPrefixes = GetOrDefaultEnv("SECRETS_ALLOWED_PREFIXES", "")
+
+// InboundAlertsToken authenticates the webhooks sending alerts from other
+// systems. The alerts are sent to the only org, so it is refused once
+// there are several orgs, see InboundAlertsTokens.
+var InboundAlertsToken = GetOrDefaultEnv("INBOUND_ALERTS_TOKEN", "")
+
+// InboundAlertsTokens is a comma separated list of org_id=token pairs,
+// the alerts sent with a token belong to its org. The inbound endpoints
+// are disabled when neither this nor INBOUND_ALERTS_TOKEN is set.
+var InboundAlertsTokens = GetOrDefaultEnv("INBOUND_ALERTS_TOKENS", "")
+
+// PublicAlertsToken gives read-only access to the firing alerts of the
+// only org for the status dashboards, so it is refused once there are
+// several orgs, see PublicAlertsTokens.
+var PublicAlertsToken = GetOrDefaultEnv("PUBLIC_ALERTS_TOKEN", "")
+
+// PublicAlertsTokens is a semicolon separated list of org_id=token pairs,
+// the public feeds of a token list the firing alerts of its org. A token
+// can be followed by the selector its feeds are restricted to, e.g.
+// org-1=secret{team="web",severity="critical"}. The public feeds are
+// disabled when neither this nor PUBLIC_ALERTS_TOKEN is set.
+var PublicAlertsTokens = GetOrDefaultEnv("PUBLIC_ALERTS_TOKENS", "")
+
+// SlackSigningSecret verifies the slash commands and the interactions sent
+// by the slack app, the slack endpoints are disabled when it is not set
+var SlackSigningSecret = GetOrDefaultEnv("SLACK_SIGNING_SECRET", "")
+
+// SlackBotToken looks up the email of the slack users, they act as the
+// SigNoz user with the same email
+var SlackBotToken = GetOrDefaultEnv("SLACK_BOT_TOKEN", "")
+
 var ContextTimeoutMaxAllowed = GetContextTimeoutMaxAllowed()
 
 const (
@@ -217,6 +651,8 @@ var GroupByColMap = map[string]struct{}{
 const (
 	SIGNOZ_METRIC_DBNAME                      = "signoz_metrics"
 	SIGNOZ_SAMPLES_V4_TABLENAME               = "distributed_samples_v4"
+	SIGNOZ_SAMPLES_V4_AGG_5M_TABLENAME        = "distributed_samples_v4_agg_5m"
+	SIGNOZ_SAMPLES_V4_AGG_30M_TABLENAME       = "distributed_samples_v4_agg_30m"
 	SIGNOZ_TRACE_DBNAME                       = "signoz_traces"
 	SIGNOZ_SPAN_INDEX_TABLENAME               = "distributed_signoz_index_v2"
 	SIGNOZ_TIMESERIES_v4_LOCAL_TABLENAME      = "time_series_v4"
@@ -228,6 +664,8 @@ const (
 var TimeoutExcludedRoutes = map[string]bool{
 	"/api/v1/logs/tail":     true,
 	"/api/v3/logs/livetail": true,
+	"/api/v1/alerts/stream": true,
+	"/ws/alerts":            true,
 }
 
 // alert related constants
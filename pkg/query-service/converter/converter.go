@@ -37,6 +37,7 @@ var (
 	PercentConverter    = NewPercentConverter()
 	BoolConverter       = NewBoolConverter()
 	ThroughputConverter = NewThroughputConverter()
+	CurrencyConverter   = NewCurrencyConverter()
 	NoneConverter       = &noneConverter{}
 )
 
@@ -49,15 +50,20 @@ func FromUnit(u Unit) Converter {
 		return DataConverter
 	case "binBps", "Bps", "binbps", "bps", "KiBs", "Kibits", "KBs", "Kbits", "MiBs", "Mibits", "MBs", "Mbits", "GiBs", "Gibits", "GBs", "Gbits", "TiBs", "Tibits", "TBs", "Tbits", "PiBs", "Pibits", "PBs", "Pbits":
 		return DataRateConverter
-	case "percent", "percentunit":
+	case "percent", "percentunit", "ratio", "permille", "basispoint", "ppm":
 		return PercentConverter
 	case "bool", "bool_yes_no", "bool_true_false", "bool_1_0":
 		return BoolConverter
-	case "cps", "ops", "reqps", "rps", "wps", "iops", "cpm", "opm", "rpm", "wpm":
+	case "cps", "ops", "reqps", "rps", "wps", "iops", "cpm", "opm", "rpm", "wpm", "reqpm", "reqph":
 		return ThroughputConverter
-	default:
-		return NoneConverter
 	}
+	if _, ok := CurrencySymbols[u]; ok {
+		return CurrencyConverter
+	}
+	if _, ok := LookupCustomUnit(u); ok {
+		return CustomConverter
+	}
+	return NoneConverter
 }
 
 func UnitToName(u string) string {
@@ -156,6 +162,14 @@ func UnitToName(u string) string {
 		return " %"
 	case "percentunit":
 		return " %"
+	case "ratio":
+		return ""
+	case "permille":
+		return " ‰"
+	case "basispoint":
+		return " bp"
+	case "ppm":
+		return " ppm"
 	case "bool":
 		return ""
 	case "bool_yes_no":
@@ -184,7 +198,20 @@ func UnitToName(u string) string {
 		return " reads/min (rpm)"
 	case "wpm":
 		return " writes/min (wpm)"
-	default:
-		return u
+	case "reqpm":
+		return " requests/min (rpm)"
+	case "reqph":
+		return " requests/hour (rph)"
+	}
+	if _, ok := CurrencySymbols[Unit(u)]; ok {
+		// the currency symbol is put before the amount by the formatter
+		return ""
+	}
+	if unit, ok := LookupCustomUnit(Unit(u)); ok {
+		if unit.Symbol != "" {
+			return " " + unit.Symbol
+		}
+		return " " + unit.Name
 	}
+	return u
 }
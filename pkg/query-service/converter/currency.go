@@ -0,0 +1,34 @@
+package converter
+
+// CurrencySymbols are the symbols of the currency units
+var CurrencySymbols = map[Unit]string{
+	"currencyUSD": "$",
+	"currencyEUR": "€",
+	"currencyGBP": "£",
+	"currencyJPY": "¥",
+	"currencyCNY": "¥",
+	"currencyINR": "₹",
+	"currencyCHF": "CHF ",
+	"currencyCAD": "C$",
+	"currencyAUD": "A$",
+	"currencyBRL": "R$",
+}
+
+// currencyConverter is a converter for amounts of money
+type currencyConverter struct{}
+
+func NewCurrencyConverter() Converter {
+	return &currencyConverter{}
+}
+
+func (*currencyConverter) Name() string {
+	return "currency"
+}
+
+func (c *currencyConverter) Convert(v Value, to Unit) Value {
+	// there are no exchange rates, the amounts keep their value
+	return Value{
+		F: v.F,
+		U: to,
+	}
+}
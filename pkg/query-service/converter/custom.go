@@ -0,0 +1,127 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+var customUnitNameRe = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_/.-]*$`)
+
+// CustomUnit is a unit defined by the users as a multiple of a base unit,
+// e.g. the unit "kreq" with the base "req" and the factor 1000. The base
+// can be a built-in unit, e.g. the unit "weeks" with the base "d" and the
+// factor 7.
+type CustomUnit struct {
+	Name   string  `json:"name"`
+	Base   string  `json:"base"`
+	Factor float64 `json:"factor"`
+	// Symbol is shown after the formatted values, the name by default
+	Symbol string `json:"symbol,omitempty"`
+}
+
+func (u *CustomUnit) Validate() error {
+	if !customUnitNameRe.MatchString(u.Name) {
+		return fmt.Errorf("invalid unit name %q, it must start with a letter and contain only letters, digits, '_', '/', '.' and '-'", u.Name)
+	}
+	if FromUnit(Unit(u.Name)) != NoneConverter {
+		return fmt.Errorf("unit %s is a built-in unit", u.Name)
+	}
+	if u.Base == "" {
+		return fmt.Errorf("base unit is required")
+	}
+	if u.Base == u.Name {
+		return fmt.Errorf("unit %s can not be its own base", u.Name)
+	}
+	if u.Factor <= 0 {
+		return fmt.Errorf("factor must be greater than 0")
+	}
+	return nil
+}
+
+// customUnits holds the custom units by name. The map is replaced as a
+// whole on every update so readers can use it without copying.
+var customUnits = struct {
+	mtx   sync.RWMutex
+	units map[Unit]CustomUnit
+}{units: map[Unit]CustomUnit{}}
+
+// SetCustomUnits replaces the custom units. The bases that are custom
+// units themselves are resolved to their own base.
+func SetCustomUnits(units []CustomUnit) {
+	m := make(map[Unit]CustomUnit, len(units))
+	for _, u := range units {
+		m[Unit(u.Name)] = u
+	}
+	for name, u := range m {
+		// the chain of bases is bounded by the number of units to stop
+		// on cycles
+		for i := 0; i < len(units); i++ {
+			base, ok := m[Unit(u.Base)]
+			if !ok || base.Name == string(name) {
+				break
+			}
+			u.Base, u.Factor = base.Base, u.Factor*base.Factor
+		}
+		m[name] = u
+	}
+	customUnits.mtx.Lock()
+	customUnits.units = m
+	customUnits.mtx.Unlock()
+}
+
+// LookupCustomUnit returns the custom unit with the name
+func LookupCustomUnit(u Unit) (CustomUnit, bool) {
+	customUnits.mtx.RLock()
+	defer customUnits.mtx.RUnlock()
+	unit, ok := customUnits.units[u]
+	return unit, ok
+}
+
+// customConverter converts the custom units through their base
+type customConverter struct{}
+
+func (*customConverter) Name() string {
+	return "custom"
+}
+
+// toBase returns the value in the base unit of the custom unit
+func toBase(v Value) Value {
+	if unit, ok := LookupCustomUnit(v.U); ok {
+		return Value{F: v.F * unit.Factor, U: Unit(unit.Base)}
+	}
+	return v
+}
+
+func (c *customConverter) Convert(v Value, to Unit) Value {
+	from := toBase(v)
+	target, custom := LookupCustomUnit(to)
+	base := to
+	if custom {
+		base = Unit(target.Base)
+	}
+	// the built-in bases are converted by their converter
+	converted := from
+	if from.U != base {
+		converted = FromUnit(from.U).Convert(from, base)
+	}
+	if custom {
+		return Value{F: converted.F / target.Factor, U: to}
+	}
+	return Value{F: converted.F, U: to}
+}
+
+// CustomConverter converts the values of the custom units
+var CustomConverter = &customConverter{}
+
+// Convert converts the value to the unit, through the bases of the custom
+// units when one of the units is a custom unit
+func Convert(v Value, to Unit) Value {
+	if _, ok := LookupCustomUnit(v.U); ok {
+		return CustomConverter.Convert(v, to)
+	}
+	if _, ok := LookupCustomUnit(to); ok {
+		return CustomConverter.Convert(v, to)
+	}
+	return FromUnit(v.U).Convert(v, to)
+}
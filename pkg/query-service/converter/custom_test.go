@@ -0,0 +1,48 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomUnits(t *testing.T) {
+	SetCustomUnits([]CustomUnit{
+		{Name: "kreq", Base: "req", Factor: 1000},
+		{Name: "mreq", Base: "kreq", Factor: 1000},
+		{Name: "weeks", Base: "d", Factor: 7, Symbol: "wk"},
+	})
+	t.Cleanup(func() { SetCustomUnits(nil) })
+
+	assert.Equal(t, CustomConverter, FromUnit("kreq"))
+	assert.Equal(t, NoneConverter, FromUnit("req"))
+
+	// the units sharing a base
+	assert.Equal(t, Value{F: 2000, U: "kreq"}, Convert(Value{F: 2, U: "mreq"}, "kreq"))
+	assert.Equal(t, Value{F: 1.5, U: "kreq"}, Convert(Value{F: 1500, U: "req"}, "kreq"))
+	assert.Equal(t, Value{F: 3000, U: "req"}, Convert(Value{F: 3, U: "kreq"}, "req"))
+
+	// a built-in base is converted by its converter
+	assert.Equal(t, Value{F: 336, U: "h"}, Convert(Value{F: 2, U: "weeks"}, "h"))
+	assert.Equal(t, Value{F: 1, U: "weeks"}, Convert(Value{F: 168, U: "h"}, "weeks"))
+
+	assert.Equal(t, " wk", UnitToName("weeks"))
+	assert.Equal(t, " kreq", UnitToName("kreq"))
+
+	SetCustomUnits(nil)
+	assert.Equal(t, NoneConverter, FromUnit("kreq"))
+}
+
+func TestCustomUnitValidate(t *testing.T) {
+	assert.NoError(t, (&CustomUnit{Name: "kreq", Base: "req", Factor: 1000}).Validate())
+	assert.Error(t, (&CustomUnit{Name: "1req", Base: "req", Factor: 1}).Validate())
+	assert.Error(t, (&CustomUnit{Name: "ms", Base: "s", Factor: 0.001}).Validate())
+	assert.Error(t, (&CustomUnit{Name: "kreq", Factor: 1000}).Validate())
+	assert.Error(t, (&CustomUnit{Name: "kreq", Base: "kreq", Factor: 1000}).Validate())
+	assert.Error(t, (&CustomUnit{Name: "kreq", Base: "req", Factor: 0}).Validate())
+}
+
+func TestCurrencyConverter(t *testing.T) {
+	assert.Equal(t, CurrencyConverter, FromUnit("currencyUSD"))
+	assert.Equal(t, Value{F: 12.5, U: "currencyUSD"}, Convert(Value{F: 12.5, U: "currencyUSD"}, "currencyUSD"))
+}
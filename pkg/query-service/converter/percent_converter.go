@@ -11,12 +11,19 @@ func (*percentConverter) Name() string {
 	return "percent"
 }
 
+// FromPercentUnit returns the percents of one unit of the ratio
 func FromPercentUnit(u Unit) float64 {
 	switch u {
 	case "percent":
 		return 1
-	case "percentunit":
+	case "percentunit", "ratio":
 		return 100
+	case "permille":
+		return 0.1
+	case "basispoint":
+		return 0.01
+	case "ppm":
+		return 0.0001
 	default:
 		return 1
 	}
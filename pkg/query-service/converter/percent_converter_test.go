@@ -14,3 +14,12 @@ func TestPercentConverter(t *testing.T) {
 	assert.Equal(t, Value{F: 1, U: "percentunit"}, percentConverter.Convert(Value{F: 100, U: "percent"}, "percentunit"))
 	assert.Equal(t, Value{F: 0.01, U: "percentunit"}, percentConverter.Convert(Value{F: 1, U: "percent"}, "percentunit"))
 }
+
+func TestPercentConverterVariants(t *testing.T) {
+	percentConverter := NewPercentConverter()
+
+	assert.Equal(t, Value{F: 50, U: "percent"}, percentConverter.Convert(Value{F: 0.5, U: "ratio"}, "percent"))
+	assert.Equal(t, Value{F: 5, U: "permille"}, percentConverter.Convert(Value{F: 0.5, U: "percent"}, "permille"))
+	assert.Equal(t, Value{F: 25, U: "basispoint"}, percentConverter.Convert(Value{F: 0.25, U: "percent"}, "basispoint"))
+	assert.InDelta(t, 1000, percentConverter.Convert(Value{F: 0.1, U: "percent"}, "ppm").F, 1e-9)
+}
@@ -12,10 +12,24 @@ func (*throughputConverter) Name() string {
 	return "throughput"
 }
 
+// FromThroughputUnit returns the number of events per second of one unit
+// of the throughput
+func FromThroughputUnit(u Unit) float64 {
+	switch u {
+	case "cpm", "opm", "rpm", "wpm", "reqpm":
+		return 1.0 / 60
+	case "reqph":
+		return 1.0 / 3600
+	default:
+		return 1
+	}
+}
+
 func (c *throughputConverter) Convert(v Value, to Unit) Value {
-	// There is no conversion to be done for throughput
+	// the kinds of events are not converted, only the per second, minute
+	// and hour rates
 	return Value{
-		F: v.F,
+		F: v.F * FromThroughputUnit(v.U) / FromThroughputUnit(to),
 		U: to,
 	}
 }
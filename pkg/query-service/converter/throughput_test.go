@@ -0,0 +1,17 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThroughputConverter(t *testing.T) {
+	throughputConverter := NewThroughputConverter()
+
+	assert.Equal(t, Value{F: 10, U: "reqps"}, throughputConverter.Convert(Value{F: 10, U: "reqps"}, "reqps"))
+	assert.Equal(t, Value{F: 600, U: "reqpm"}, throughputConverter.Convert(Value{F: 10, U: "reqps"}, "reqpm"))
+	assert.Equal(t, Value{F: 2, U: "reqps"}, throughputConverter.Convert(Value{F: 120, U: "reqpm"}, "reqps"))
+	assert.Equal(t, Value{F: 60, U: "reqpm"}, throughputConverter.Convert(Value{F: 3600, U: "reqph"}, "reqpm"))
+	assert.Equal(t, Value{F: 1, U: "ops"}, throughputConverter.Convert(Value{F: 60, U: "opm"}, "ops"))
+}
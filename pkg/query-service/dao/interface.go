@@ -62,6 +62,7 @@ type Mutations interface {
 
 	UpdateUserPassword(ctx context.Context, hash, userId string) *model.ApiError
 	UpdateUserGroup(ctx context.Context, userId, groupId string) *model.ApiError
+	UpdateUserTeams(ctx context.Context, userId string, teams []string) *model.ApiError
 
 	SetApdexSettings(ctx context.Context, set *model.ApdexSettings) *model.ApiError
 
@@ -3,6 +3,7 @@ package sqlite
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
@@ -93,6 +94,12 @@ func InitDB(dataSourceName string) (*ModelDaoSqlite, error) {
 		return nil, fmt.Errorf("error in creating tables: %v", err.Error())
 	}
 
+	// sqlite does not support "IF NOT EXISTS"
+	_, err = db.Exec(`ALTER TABLE users ADD COLUMN teams TEXT NOT NULL DEFAULT '';`)
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return nil, fmt.Errorf("error in adding column teams to users table: %s", err.Error())
+	}
+
 	mds := &ModelDaoSqlite{db: db}
 
 	ctx := context.Background()
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -239,6 +240,15 @@ func (mds *ModelDaoSqlite) UpdateUserGroup(ctx context.Context, userId, groupId
 	return nil
 }
 
+func (mds *ModelDaoSqlite) UpdateUserTeams(ctx context.Context, userId string, teams []string) *model.ApiError {
+
+	q := `UPDATE users SET teams=? WHERE id=?;`
+	if _, err := mds.db.ExecContext(ctx, q, strings.Join(teams, ","), userId); err != nil {
+		return &model.ApiError{Typ: model.ErrorInternal, Err: err}
+	}
+	return nil
+}
+
 func (mds *ModelDaoSqlite) DeleteUser(ctx context.Context, id string) *model.ApiError {
 
 	result, err := mds.db.ExecContext(ctx, `DELETE from users where id=?;`, id)
@@ -273,6 +283,7 @@ func (mds *ModelDaoSqlite) GetUser(ctx context.Context,
 				u.profile_picture_url,
 				u.org_id,
 				u.group_id,
+				u.teams,
 				g.name as role,
 				o.name as organization,
 				COALESCE((select uf.flags
@@ -321,6 +332,7 @@ func (mds *ModelDaoSqlite) GetUserByEmail(ctx context.Context,
 				u.profile_picture_url,
 				u.org_id,
 				u.group_id,
+				u.teams,
 				g.name as role,
 				o.name as organization
 			from users u, groups g, organizations o
@@ -363,6 +375,7 @@ func (mds *ModelDaoSqlite) GetUsersWithOpts(ctx context.Context, limit int) ([]m
 				u.profile_picture_url,
 				u.org_id,
 				u.group_id,
+				u.teams,
 				g.name as role,
 				o.name as organization
 			from users u, groups g, organizations o
@@ -394,6 +407,7 @@ func (mds *ModelDaoSqlite) GetUsersByOrg(ctx context.Context,
 				u.profile_picture_url,
 				u.org_id,
 				u.group_id,
+				u.teams,
 				g.name as role,
 				o.name as organization
 			from users u, groups g, organizations o
@@ -421,6 +435,7 @@ func (mds *ModelDaoSqlite) GetUsersByGroup(ctx context.Context,
 				u.profile_picture_url,
 				u.org_id,
 				u.group_id,
+				u.teams,
 				g.name as role,
 				o.name as organization
 			from users u, groups g, organizations o
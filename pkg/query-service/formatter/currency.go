@@ -0,0 +1,30 @@
+package formatter
+
+import (
+	"fmt"
+
+	"go.signoz.io/signoz/pkg/query-service/converter"
+)
+
+type currencyFormatter struct{}
+
+func NewCurrencyFormatter() Formatter {
+	return &currencyFormatter{}
+}
+
+func (*currencyFormatter) Name() string {
+	return "currency"
+}
+
+func (f *currencyFormatter) Format(value float64, unit string) string {
+	symbol, ok := converter.CurrencySymbols[converter.Unit(unit)]
+	if !ok {
+		// When unit is not matched, return the value as it is.
+		return fmt.Sprintf("%v", value)
+	}
+	scaler := scaledUnits(1000, []string{"", "K", "M", "B", "T"}, 0)
+	if value < 0 {
+		return "-" + symbol + scaler(-value, nil)
+	}
+	return symbol + scaler(value, nil)
+}
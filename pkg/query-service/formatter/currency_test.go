@@ -0,0 +1,25 @@
+package formatter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.signoz.io/signoz/pkg/query-service/converter"
+)
+
+func TestCurrency(t *testing.T) {
+	currencyFormatter := FromUnit("currencyUSD")
+
+	assert.Equal(t, "$12.5", currencyFormatter.Format(12.5, "currencyUSD"))
+	assert.Equal(t, "$1.50K", currencyFormatter.Format(1500, "currencyUSD"))
+	assert.Equal(t, "-€2M", currencyFormatter.Format(-2000000, "currencyEUR"))
+}
+
+func TestCustomUnit(t *testing.T) {
+	converter.SetCustomUnits([]converter.CustomUnit{{Name: "kreq", Base: "req", Factor: 1000, Symbol: "k requests"}})
+	t.Cleanup(func() { converter.SetCustomUnits(nil) })
+
+	assert.Equal(t, "1.50 k requests", FromUnit("kreq").Format(1.5, "kreq"))
+	assert.Equal(t, "25 ppm", FromUnit("ppm").Format(25, "ppm"))
+	assert.Equal(t, "120 req/m", FromUnit("reqpm").Format(120, "reqpm"))
+}
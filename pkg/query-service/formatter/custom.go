@@ -0,0 +1,30 @@
+package formatter
+
+import (
+	"fmt"
+
+	"go.signoz.io/signoz/pkg/query-service/converter"
+)
+
+type customFormatter struct{}
+
+func NewCustomFormatter() Formatter {
+	return &customFormatter{}
+}
+
+func (*customFormatter) Name() string {
+	return "custom"
+}
+
+func (f *customFormatter) Format(value float64, unit string) string {
+	custom, ok := converter.LookupCustomUnit(converter.Unit(unit))
+	if !ok {
+		// When unit is not matched, return the value as it is.
+		return fmt.Sprintf("%v", value)
+	}
+	symbol := custom.Symbol
+	if symbol == "" {
+		symbol = custom.Name
+	}
+	return toFixed(value, nil) + " " + symbol
+}
@@ -1,5 +1,7 @@
 package formatter
 
+import "go.signoz.io/signoz/pkg/query-service/converter"
+
 type Formatter interface {
 	Format(value float64, unit string) string
 
@@ -14,6 +16,8 @@ var (
 	DataFormatter       = NewDataFormatter()
 	DataRateFormatter   = NewDataRateFormatter()
 	ThroughputFormatter = NewThroughputFormatter()
+	CurrencyFormatter   = NewCurrencyFormatter()
+	CustomFormatter     = NewCustomFormatter()
 )
 
 func FromUnit(u string) Formatter {
@@ -24,13 +28,18 @@ func FromUnit(u string) Formatter {
 		return DataFormatter
 	case "binBps", "Bps", "binbps", "bps", "KiBs", "Kibits", "KBs", "Kbits", "MiBs", "Mibits", "MBs", "Mbits", "GiBs", "Gibits", "GBs", "Gbits", "TiBs", "Tibits", "TBs", "Tbits", "PiBs", "Pibits", "PBs", "Pbits":
 		return DataRateFormatter
-	case "percent", "percentunit":
+	case "percent", "percentunit", "ratio", "permille", "basispoint", "ppm":
 		return PercentFormatter
 	case "bool", "bool_yes_no", "bool_true_false", "bool_1_0":
 		return BoolFormatter
-	case "cps", "ops", "reqps", "rps", "wps", "iops", "cpm", "opm", "rpm", "wpm":
+	case "cps", "ops", "reqps", "rps", "wps", "iops", "cpm", "opm", "rpm", "wpm", "reqpm", "reqph":
 		return ThroughputFormatter
-	default:
-		return NoneFormatter
 	}
+	if _, ok := converter.CurrencySymbols[converter.Unit(u)]; ok {
+		return CurrencyFormatter
+	}
+	if _, ok := converter.LookupCustomUnit(converter.Unit(u)); ok {
+		return CustomFormatter
+	}
+	return NoneFormatter
 }
@@ -0,0 +1,147 @@
+package formatter
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"go.signoz.io/signoz/pkg/query-service/converter"
+)
+
+const (
+	BytesSI  = "si"
+	BytesIEC = "iec"
+
+	MaxSignificantDigits = 15
+
+	maxPlainNumber = 1e15
+)
+
+// numberRe matches the numbers in the formatted values
+var numberRe = regexp.MustCompile(`-?\d+(\.\d+)?([eE][-+]?\d+)?`)
+
+// Options are the locale and the precision the values are formatted with.
+// The zero value keeps the output of the formatters.
+type Options struct {
+	DecimalSeparator   string `json:"decimalSeparator,omitempty"`
+	ThousandsSeparator string `json:"thousandsSeparator,omitempty"`
+	// Bytes formats the data sizes and rates with the SI (1000) or the
+	// IEC (1024) prefixes, whatever their unit
+	Bytes string `json:"bytes,omitempty"`
+	// MaxSignificantDigits rounds the numbers, zero keeps the decimals of
+	// the formatters
+	MaxSignificantDigits int `json:"maxSignificantDigits,omitempty"`
+}
+
+func (o *Options) Validate() error {
+	switch o.Bytes {
+	case "", BytesSI, BytesIEC:
+	default:
+		return fmt.Errorf("bytes must be %s or %s", BytesSI, BytesIEC)
+	}
+	if o.MaxSignificantDigits < 0 || o.MaxSignificantDigits > MaxSignificantDigits {
+		return fmt.Errorf("maxSignificantDigits must be between 0 and %d", MaxSignificantDigits)
+	}
+	if o.DecimalSeparator != "" && o.DecimalSeparator == o.ThousandsSeparator {
+		return fmt.Errorf("decimal and thousands separators must be different")
+	}
+	return nil
+}
+
+func (o *Options) isZero() bool {
+	return o == nil || *o == Options{}
+}
+
+// Format formats the value of the unit with the options
+func Format(value float64, unit string, opts *Options) string {
+	if opts.isZero() {
+		return FromUnit(unit).Format(value, unit)
+	}
+	var formatted string
+	switch {
+	case opts.Bytes != "" && FromUnit(unit) == DataFormatter:
+		bytes := converter.DataConverter.Convert(converter.Value{F: value, U: converter.Unit(unit)}, "bytes").F
+		formatted = opts.humanizeBytes(bytes)
+	case opts.Bytes != "" && FromUnit(unit) == DataRateFormatter:
+		bytes := converter.DataRateConverter.Convert(converter.Value{F: value, U: converter.Unit(unit)}, "Bps").F
+		formatted = opts.humanizeBytes(bytes) + "/s"
+	default:
+		formatted = FromUnit(unit).Format(value, unit)
+	}
+	return opts.localize(formatted)
+}
+
+func (o *Options) humanizeBytes(bytes float64) string {
+	if o.Bytes == BytesSI {
+		return humanize.Bytes(uint64(bytes))
+	}
+	return humanize.IBytes(uint64(bytes))
+}
+
+// localize rounds the numbers of the formatted value and writes them with
+// the separators of the options
+func (o *Options) localize(formatted string) string {
+	return numberRe.ReplaceAllStringFunc(formatted, func(number string) string {
+		v, err := strconv.ParseFloat(number, 64)
+		if err != nil {
+			return number
+		}
+		exponent := strings.ContainsAny(number, "eE")
+		// the exponent notation is kept for the numbers too large to be
+		// written in full
+		if exponent && math.Abs(v) >= maxPlainNumber {
+			return number
+		}
+		if o.MaxSignificantDigits > 0 {
+			v = roundSignificant(v, o.MaxSignificantDigits)
+		}
+		if exponent || o.MaxSignificantDigits > 0 {
+			number = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		return o.separate(number)
+	})
+}
+
+// separate writes the number with the separators of the options
+func (o *Options) separate(number string) string {
+	sign := ""
+	if strings.HasPrefix(number, "-") {
+		sign, number = "-", number[1:]
+	}
+	intPart, fracPart, hasFrac := strings.Cut(number, ".")
+
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, c := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(o.ThousandsSeparator)
+		}
+		b.WriteRune(c)
+	}
+	if hasFrac {
+		sep := o.DecimalSeparator
+		if sep == "" {
+			sep = "."
+		}
+		b.WriteString(sep)
+		b.WriteString(fracPart)
+	}
+	return b.String()
+}
+
+func roundSignificant(v float64, digits int) float64 {
+	if v == 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return v
+	}
+	shift := float64(digits) - math.Ceil(math.Log10(math.Abs(v)))
+	// the factor is kept above one, the negative powers of ten are not exact
+	if shift >= 0 {
+		factor := math.Pow(10, shift)
+		return math.Round(v*factor) / factor
+	}
+	factor := math.Pow(10, -shift)
+	return math.Round(v/factor) * factor
+}
@@ -0,0 +1,31 @@
+package formatter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatWithOptions(t *testing.T) {
+	// no options keep the output of the formatters
+	assert.Equal(t, FromUnit("ms").Format(1234.5, "ms"), Format(1234.5, "ms", nil))
+	assert.Equal(t, FromUnit("bytes").Format(1536, "bytes"), Format(1536, "bytes", &Options{}))
+
+	de := &Options{DecimalSeparator: ",", ThousandsSeparator: "."}
+	assert.Equal(t, "1.234.567", Format(1234567, "none", de))
+	assert.Equal(t, "-12,5%", Format(-12.5, "percent", de))
+
+	assert.Equal(t, "1.5 kB", Format(1500, "bytes", &Options{Bytes: BytesSI}))
+	assert.Equal(t, "1.5 KiB", Format(1536, "bytes", &Options{Bytes: BytesIEC}))
+	assert.Equal(t, "2.0 MB/s", Format(2, "MBs", &Options{Bytes: BytesSI}))
+
+	assert.Equal(t, "3.14", Format(3.14159, "none", &Options{MaxSignificantDigits: 3}))
+	assert.Equal(t, "123000", Format(123456, "none", &Options{MaxSignificantDigits: 3}))
+}
+
+func TestOptionsValidate(t *testing.T) {
+	assert.NoError(t, (&Options{Bytes: BytesIEC, MaxSignificantDigits: 4}).Validate())
+	assert.Error(t, (&Options{Bytes: "binary"}).Validate())
+	assert.Error(t, (&Options{MaxSignificantDigits: MaxSignificantDigits + 1}).Validate())
+	assert.Error(t, (&Options{DecimalSeparator: ",", ThousandsSeparator: ","}).Validate())
+}
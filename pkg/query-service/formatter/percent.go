@@ -26,6 +26,14 @@ func (f *percentFormatter) Format(value float64, unit string) string {
 		return toPercent(value, nil)
 	case "percentunit":
 		return toPercentUnit(value, nil)
+	case "ratio":
+		return toFixed(value, nil)
+	case "permille":
+		return toFixed(value, nil) + "‰"
+	case "basispoint":
+		return toFixed(value, nil) + " bp"
+	case "ppm":
+		return toFixed(value, nil) + " ppm"
 	}
 	// When unit is not matched, return the value as it is.
 	return fmt.Sprintf("%v", value)
@@ -42,6 +42,10 @@ func (f *throughputFormatter) Format(value float64, unit string) string {
 		return simpleCountUnit(value, nil, "r/m")
 	case "wpm":
 		return simpleCountUnit(value, nil, "w/m")
+	case "reqpm":
+		return simpleCountUnit(value, nil, "req/m")
+	case "reqph":
+		return simpleCountUnit(value, nil, "req/h")
 	}
 	// When unit is not matched, return the value as it is.
 	return fmt.Sprintf("%v", value)
@@ -0,0 +1,575 @@
+package alertManager
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// chatChannelsRefresh is how often the irc and xmpp channels are
+	// reloaded from the channel store
+	chatChannelsRefresh = time.Minute
+
+	// chatQueueSize is the number of messages waiting for a connection,
+	// messages are dropped when the queue is full
+	chatQueueSize = 200
+
+	// chatSendAttempts is the number of times a message is sent before it
+	// is dropped, the connection is reopened between the attempts
+	chatSendAttempts = 3
+
+	chatDialTimeout = 30 * time.Second
+	chatMinBackoff  = time.Second
+	chatMaxBackoff  = time.Minute
+
+	// the defaults keep below the flood limits of the common irc servers
+	defaultChatRateLimit = 0.5
+	defaultChatBurst     = 4
+)
+
+// chatRootCAs verifies the certificates of the chat servers, the roots of
+// the system when nil
+var chatRootCAs *x509.CertPool
+
+// ChatRateLimit limits the messages sent over a connection
+type ChatRateLimit struct {
+	// RateLimit is the number of messages per second, 0.5 when not set
+	RateLimit float64 `yaml:"rate_limit,omitempty" json:"rate_limit,omitempty"`
+	// Burst is the number of messages sent at once, 4 when not set
+	Burst int `yaml:"burst,omitempty" json:"burst,omitempty"`
+}
+
+func (c ChatRateLimit) validate() error {
+	if c.RateLimit < 0 || c.Burst < 0 {
+		return fmt.Errorf("the rate limit and burst can not be negative")
+	}
+	return nil
+}
+
+func (c ChatRateLimit) limiter() *rate.Limiter {
+	limit, burst := c.RateLimit, c.Burst
+	if limit == 0 {
+		limit = defaultChatRateLimit
+	}
+	if burst == 0 {
+		burst = defaultChatBurst
+	}
+	return rate.NewLimiter(rate.Limit(limit), burst)
+}
+
+// IRCConfig configures the irc channel, the alerts are sent to the irc
+// channels and nicks of Targets
+type IRCConfig struct {
+	SendResolved bool `yaml:"send_resolved" json:"send_resolved"`
+
+	// Server is the host and port of the irc server
+	Server string `yaml:"server" json:"server"`
+	TLS    bool   `yaml:"tls,omitempty" json:"tls,omitempty"`
+	Nick   string `yaml:"nick" json:"nick"`
+	// Password is the server password sent with PASS
+	Password string `yaml:"password,omitempty" json:"password,omitempty"`
+	// Targets are the channels, like #alerts, and the nicks to message
+	Targets []string `yaml:"targets" json:"targets"`
+	// Notice sends the alerts as NOTICE instead of PRIVMSG, so that the
+	// bots of the channel do not answer them
+	Notice bool `yaml:"notice,omitempty" json:"notice,omitempty"`
+
+	ChatRateLimit `yaml:",inline"`
+}
+
+func (c *IRCConfig) Validate() error {
+	if _, _, err := net.SplitHostPort(c.Server); err != nil {
+		return fmt.Errorf("irc server %q must be a host and port: %w", c.Server, err)
+	}
+	if c.Nick == "" || strings.ContainsAny(c.Nick, " \r\n") {
+		return fmt.Errorf("irc channel needs a nick without spaces")
+	}
+	if len(c.Targets) == 0 {
+		return fmt.Errorf("irc channel needs at least one target")
+	}
+	for _, t := range c.Targets {
+		if t == "" || strings.ContainsAny(t, " ,\r\n") {
+			return fmt.Errorf("invalid irc target %q", t)
+		}
+	}
+	return c.ChatRateLimit.validate()
+}
+
+// XMPPConfig configures the xmpp channel, the alerts are sent to the
+// users of To and the multi user chat rooms of Rooms
+type XMPPConfig struct {
+	SendResolved bool `yaml:"send_resolved" json:"send_resolved"`
+
+	// JID is the address of the account SigNoz signs in with
+	JID      string `yaml:"jid" json:"jid"`
+	Password string `yaml:"password" json:"password"`
+	// Server is the host and port of the server, looked up in the SRV
+	// records of the domain of the jid when not set
+	Server string `yaml:"server,omitempty" json:"server,omitempty"`
+	// DirectTLS connects with tls instead of STARTTLS
+	DirectTLS bool `yaml:"direct_tls,omitempty" json:"direct_tls,omitempty"`
+
+	To    []string `yaml:"to,omitempty" json:"to,omitempty"`
+	Rooms []string `yaml:"rooms,omitempty" json:"rooms,omitempty"`
+	// Nick is the nick in the rooms, signoz when not set
+	Nick string `yaml:"nick,omitempty" json:"nick,omitempty"`
+
+	ChatRateLimit `yaml:",inline"`
+}
+
+func (c *XMPPConfig) Validate() error {
+	if _, domain := splitJID(c.JID); domain == "" {
+		return fmt.Errorf("invalid xmpp jid %q", c.JID)
+	}
+	if c.Password == "" {
+		return fmt.Errorf("xmpp channel needs a password")
+	}
+	if c.Server != "" {
+		if _, _, err := net.SplitHostPort(c.Server); err != nil {
+			return fmt.Errorf("xmpp server %q must be a host and port: %w", c.Server, err)
+		}
+	}
+	if len(c.To) == 0 && len(c.Rooms) == 0 {
+		return fmt.Errorf("xmpp channel needs at least one user or room")
+	}
+	for _, jid := range append(append([]string{}, c.To...), c.Rooms...) {
+		if _, domain := splitJID(jid); domain == "" {
+			return fmt.Errorf("invalid xmpp jid %q", jid)
+		}
+	}
+	return c.ChatRateLimit.validate()
+}
+
+// chatMessage is a line of text for a target of a chat connection
+type chatMessage struct {
+	target string
+	// room tells that the target is an xmpp room
+	room bool
+	text string
+}
+
+// chatClient is an open connection to a chat server
+type chatClient interface {
+	Send(msg chatMessage) error
+	Close() error
+}
+
+// chatConnection opens the connections to a chat server
+type chatConnection interface {
+	// key identifies the connection, the channels sharing the server and
+	// the account share the connection
+	key() string
+	// servers are the hosts the connection is opened to
+	servers() []string
+	dial(ctx context.Context, policy *EgressPolicy) (chatClient, error)
+	limiter() *rate.Limiter
+}
+
+func (c *IRCConfig) key() string {
+	return fmt.Sprintf("irc|%s|%t|%s|%s", c.Server, c.TLS, c.Nick, c.Password)
+}
+
+func (c *IRCConfig) servers() []string {
+	return []string{c.Server}
+}
+
+func (c *XMPPConfig) key() string {
+	return fmt.Sprintf("xmpp|%s|%s|%t|%s|%s", c.JID, c.Server, c.DirectTLS, c.Nick, c.Password)
+}
+
+func (c *XMPPConfig) servers() []string {
+	if c.Server != "" {
+		return []string{c.Server}
+	}
+	// the server is looked up at delivery time
+	_, domain := splitJID(c.JID)
+	return []string{domain}
+}
+
+// chatMessages returns the messages of the alert for the targets of the
+// connection
+func chatMessages(conn chatConnection, text string) []chatMessage {
+	var messages []chatMessage
+	switch c := conn.(type) {
+	case *IRCConfig:
+		for _, t := range c.Targets {
+			messages = append(messages, chatMessage{target: t, text: text})
+		}
+	case *XMPPConfig:
+		for _, to := range c.To {
+			messages = append(messages, chatMessage{target: to, text: text})
+		}
+		for _, room := range c.Rooms {
+			messages = append(messages, chatMessage{target: room, room: true, text: text})
+		}
+	}
+	return messages
+}
+
+// NewChatText returns the text of the alert, on a single line for irc.
+// The state, times and value are rendered with the locale and timezone
+// of the channel.
+func NewChatText(alert *Alert, locale *Locale, timezone string) string {
+	locale = locale.orDefault()
+	text := fmt.Sprintf("[%s] %s", locale.alertStatus(alert), alert.Name())
+	if severity := alert.Labels.Get("severity"); severity != "" {
+		text += fmt.Sprintf(" (%s)", severity)
+	}
+	summary := alert.Annotations.Get("summary")
+	if summary == "" {
+		summary = alert.Annotations.Get("description")
+	}
+	if summary != "" {
+		text += ": " + strings.Join(strings.Fields(RenderMarkdown(summary, MarkupPlain)), " ")
+	}
+	if details := locale.alertDetails(alert, timezone); details != "" {
+		text += " (" + details + ")"
+	}
+	if alert.GeneratorURL != "" {
+		text += " " + alert.GeneratorURL
+	}
+	return text
+}
+
+// chatSession delivers the messages of a connection one at a time, at the
+// rate of the connection. The connection is opened on the first message
+// and reopened with a backoff when it breaks.
+type chatSession struct {
+	conn    chatConnection
+	policy  *EgressPolicy
+	limiter *rate.Limiter
+	queue   chan chatMessage
+	ctx     context.Context
+	cancel  context.CancelFunc
+	client  chatClient
+	backoff time.Duration
+}
+
+func newChatSession(conn chatConnection, policy *EgressPolicy) *chatSession {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &chatSession{
+		conn:    conn,
+		policy:  policy,
+		limiter: conn.limiter(),
+		queue:   make(chan chatMessage, chatQueueSize),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+func (s *chatSession) enqueue(msg chatMessage) {
+	select {
+	case s.queue <- msg:
+	default:
+		zap.L().Warn("chat notification queue is full, dropping message", zap.String("target", msg.target))
+	}
+}
+
+func (s *chatSession) run() {
+	defer func() {
+		if s.client != nil {
+			s.client.Close()
+		}
+	}()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case msg := <-s.queue:
+			if err := s.limiter.Wait(s.ctx); err != nil {
+				return
+			}
+			if err := s.send(msg); err != nil {
+				zap.L().Error("failed to send chat notification", zap.String("target", msg.target), zap.Error(err))
+			}
+		}
+	}
+}
+
+func (s *chatSession) stop() {
+	s.cancel()
+}
+
+// send delivers the message, reconnecting when the connection is broken
+func (s *chatSession) send(msg chatMessage) error {
+	var err error
+	for attempt := 0; attempt < chatSendAttempts; attempt++ {
+		if s.client == nil {
+			if err = s.connect(); err != nil {
+				return err
+			}
+		}
+		if err = s.client.Send(msg); err == nil {
+			return nil
+		}
+		s.client.Close()
+		s.client = nil
+	}
+	return err
+}
+
+// connect opens the connection, waiting longer after every failure up to
+// a minute until the session is stopped
+func (s *chatSession) connect() error {
+	for {
+		ctx, cancel := context.WithTimeout(s.ctx, chatDialTimeout)
+		client, err := s.conn.dial(ctx, s.policy)
+		cancel()
+		if err == nil {
+			s.client = client
+			s.backoff = 0
+			return nil
+		}
+
+		if s.backoff == 0 {
+			s.backoff = chatMinBackoff
+		} else if s.backoff *= 2; s.backoff > chatMaxBackoff {
+			s.backoff = chatMaxBackoff
+		}
+		zap.L().Warn("failed to connect to chat server, retrying", zap.Strings("servers", s.conn.servers()), zap.Duration("backoff", s.backoff), zap.Error(err))
+		select {
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		case <-time.After(s.backoff):
+		}
+	}
+}
+
+// ChatStore gives the dispatcher access to the channels and their
+// locales
+type ChatStore interface {
+	LocaleStore
+	GetChannels() (*[]model.ChannelItem, *model.ApiError)
+}
+
+// chatChannel holds the irc and xmpp configs of a channel and how its
+// messages are rendered
+type chatChannel struct {
+	irc      []IRCConfig
+	xmpp     []XMPPConfig
+	locale   *Locale
+	timezone string
+}
+
+// ChatDispatcher delivers the alerts sent to irc and xmpp channels. Like
+// the push channels they are handled by query-service, the alert manager
+// only knows them as receivers without integrations. The connections stay
+// open between the alerts and are shared by the channels using the same
+// server and account.
+type ChatDispatcher struct {
+	store  ChatStore
+	policy *EgressPolicy
+
+	queue chan []*Alert
+	done  chan struct{}
+
+	mtx               sync.Mutex
+	channels          map[string]chatChannel
+	channelsFetchedAt time.Time
+	sessions          map[string]*chatSession
+}
+
+func NewChatDispatcher(store ChatStore) *ChatDispatcher {
+	return &ChatDispatcher{
+		store:    store,
+		policy:   DefaultEgressPolicy(),
+		queue:    make(chan []*Alert, 100),
+		done:     make(chan struct{}),
+		sessions: map[string]*chatSession{},
+	}
+}
+
+func (d *ChatDispatcher) Run() {
+	for {
+		select {
+		case <-d.done:
+			return
+		case alerts := <-d.queue:
+			d.dispatch(alerts)
+		}
+	}
+}
+
+func (d *ChatDispatcher) Stop() {
+	close(d.done)
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	for key, s := range d.sessions {
+		s.stop()
+		delete(d.sessions, key)
+	}
+}
+
+// Send queues the alerts for delivery, alerts are dropped when the
+// queue is full so that rule evaluation is never blocked
+func (d *ChatDispatcher) Send(alerts ...*Alert) {
+	if d.store == nil || len(alerts) == 0 {
+		return
+	}
+	select {
+	case d.queue <- alerts:
+	default:
+		zap.L().Warn("chat notification queue is full, dropping alerts", zap.Int("count", len(alerts)))
+	}
+}
+
+// Test connects to the servers of the receiver and sends a test message
+// to its targets
+func (d *ChatDispatcher) Test(ctx context.Context, receiver *Receiver) *model.ApiError {
+	text := fmt.Sprintf("[TEST] SigNoz test notification for channel %s", receiver.Name)
+	for _, conn := range receiverChatConnections(receiver) {
+		if err := testChatConnection(ctx, conn, d.policy, text); err != nil {
+			return &model.ApiError{Typ: model.ErrorInternal, Err: err}
+		}
+	}
+	return nil
+}
+
+func testChatConnection(ctx context.Context, conn chatConnection, policy *EgressPolicy, text string) error {
+	ctx, cancel := context.WithTimeout(ctx, chatDialTimeout)
+	defer cancel()
+	client, err := conn.dial(ctx, policy)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	for _, msg := range chatMessages(conn, text) {
+		if err := client.Send(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// receiverChatConnections returns the irc and xmpp configs of the receiver
+func receiverChatConnections(r *Receiver) []chatConnection {
+	var conns []chatConnection
+	for i := range r.IRCConfigs {
+		conns = append(conns, &r.IRCConfigs[i])
+	}
+	for i := range r.XMPPConfigs {
+		conns = append(conns, &r.XMPPConfigs[i])
+	}
+	return conns
+}
+
+func (d *ChatDispatcher) dispatch(alerts []*Alert) {
+	channels, err := d.chatChannels()
+	if err != nil {
+		zap.L().Error("failed to load chat channels", zap.Error(err))
+		return
+	}
+	if len(channels) == 0 {
+		return
+	}
+
+	for _, alert := range alerts {
+		// alerts without preferred channels go to all the channels
+		names := alert.Receivers
+		if len(names) == 0 {
+			for name := range channels {
+				names = append(names, name)
+			}
+		}
+		for _, name := range names {
+			channel, ok := channels[name]
+			if !ok {
+				continue
+			}
+			text := NewChatText(alert, channel.locale, channel.timezone)
+			for i := range channel.irc {
+				if !alert.Resolved() || channel.irc[i].SendResolved {
+					d.enqueue(&channel.irc[i], text)
+				}
+			}
+			for i := range channel.xmpp {
+				if !alert.Resolved() || channel.xmpp[i].SendResolved {
+					d.enqueue(&channel.xmpp[i], text)
+				}
+			}
+		}
+	}
+}
+
+func (d *ChatDispatcher) enqueue(conn chatConnection, text string) {
+	d.mtx.Lock()
+	s, ok := d.sessions[conn.key()]
+	if !ok {
+		s = newChatSession(conn, d.policy)
+		d.sessions[conn.key()] = s
+		go s.run()
+	}
+	d.mtx.Unlock()
+
+	for _, msg := range chatMessages(conn, text) {
+		s.enqueue(msg)
+	}
+}
+
+// chatChannels returns the irc and xmpp configs by channel name. The
+// channels are cached to avoid reading the store for every batch of
+// alerts, and the connections no channel uses anymore are closed on
+// refresh.
+func (d *ChatDispatcher) chatChannels() (map[string]chatChannel, error) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	if d.channels != nil && time.Since(d.channelsFetchedAt) < chatChannelsRefresh {
+		return d.channels, nil
+	}
+
+	items, apiErr := d.store.GetChannels()
+	if apiErr != nil {
+		return nil, apiErr.Err
+	}
+
+	channels := map[string]chatChannel{}
+	used := map[string]bool{}
+	for _, item := range *items {
+		receiver := Receiver{}
+		if err := json.Unmarshal([]byte(item.Data), &receiver); err != nil {
+			zap.L().Error("failed to parse channel", zap.String("channel", item.Name), zap.Error(err))
+			continue
+		}
+		if len(receiver.IRCConfigs) == 0 && len(receiver.XMPPConfigs) == 0 {
+			continue
+		}
+		channels[item.Name] = chatChannel{
+			irc:      receiver.IRCConfigs,
+			xmpp:     receiver.XMPPConfigs,
+			locale:   ResolveLocale(d.store, receiver.Locale),
+			timezone: receiver.Timezone,
+		}
+		for _, conn := range receiverChatConnections(&receiver) {
+			used[conn.key()] = true
+		}
+	}
+	for key, s := range d.sessions {
+		if !used[key] {
+			s.stop()
+			delete(d.sessions, key)
+		}
+	}
+	d.channels = channels
+	d.channelsFetchedAt = time.Now()
+	return channels, nil
+}
+
+// dialChat opens a tcp connection to the address when the egress policy
+// allows it
+func dialChat(ctx context.Context, policy *EgressPolicy, addr string) (net.Conn, error) {
+	if !policy.Allowed(addr) {
+		return nil, fmt.Errorf("destination %s is not in the notification egress allowlist", addr)
+	}
+	return (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+}
@@ -0,0 +1,219 @@
+package alertManager
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ircMaxText is the longest text of a message, a line of irc is at most
+// 512 bytes with the prefix the server adds when relaying it
+const ircMaxText = 400
+
+// ircClient is a registered connection to an irc server. The channels are
+// joined on the first message sent to them.
+type ircClient struct {
+	conn    net.Conn
+	command string
+
+	mtx    sync.Mutex
+	joined map[string]bool
+	// err is set when the connection is closed by the server
+	err error
+}
+
+func (c *IRCConfig) dial(ctx context.Context, policy *EgressPolicy) (chatClient, error) {
+	conn, err := dialChat(ctx, policy, c.Server)
+	if err != nil {
+		return nil, err
+	}
+	if c.TLS {
+		host, _, _ := net.SplitHostPort(c.Server)
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host, RootCAs: chatRootCAs, MinVersion: tls.VersionTLS12})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client := &ircClient{conn: conn, command: "PRIVMSG", joined: map[string]bool{}}
+	if c.Notice {
+		client.command = "NOTICE"
+	}
+	reader := bufio.NewReader(conn)
+	if err := client.register(reader, c.Nick, c.Password); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	go client.read(reader)
+	return client, nil
+}
+
+// register signs in with the nick, adding underscores while the nick is
+// in use, and waits for the welcome of the server
+func (c *ircClient) register(reader *bufio.Reader, nick, password string) error {
+	if password != "" {
+		if err := c.write("PASS " + password); err != nil {
+			return err
+		}
+	}
+	if err := c.write("NICK " + nick); err != nil {
+		return err
+	}
+	if err := c.write(fmt.Sprintf("USER %s 0 * :SigNoz alerts", nick)); err != nil {
+		return err
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("irc registration failed: %w", err)
+		}
+		_, command, params := parseIRCLine(line)
+		switch command {
+		case "PING":
+			if err := c.write("PONG :" + strings.Join(params, " ")); err != nil {
+				return err
+			}
+		case "001":
+			return nil
+		case "432", "433", "436":
+			// erroneous nick, nick in use or collision
+			if len(nick) > 25 {
+				return fmt.Errorf("irc nick %s is not available", nick)
+			}
+			nick += "_"
+			if err := c.write("NICK " + nick); err != nil {
+				return err
+			}
+		case "464", "465", "ERROR":
+			return fmt.Errorf("irc server refused the connection: %s", strings.Join(params, " "))
+		}
+	}
+}
+
+// read answers the pings of the server until the connection is closed
+func (c *ircClient) read(reader *bufio.Reader) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			c.mtx.Lock()
+			c.err = err
+			c.mtx.Unlock()
+			return
+		}
+		_, command, params := parseIRCLine(line)
+		switch command {
+		case "PING":
+			c.mtx.Lock()
+			c.write("PONG :" + strings.Join(params, " "))
+			c.mtx.Unlock()
+		case "ERROR":
+			c.mtx.Lock()
+			c.err = fmt.Errorf("irc server closed the connection: %s", strings.Join(params, " "))
+			c.mtx.Unlock()
+			c.conn.Close()
+			return
+		case "KICK":
+			// KICK <channel> <nick>, the channel is joined again on the
+			// next message
+			if len(params) > 0 {
+				c.mtx.Lock()
+				delete(c.joined, params[0])
+				c.mtx.Unlock()
+			}
+		}
+	}
+}
+
+func (c *ircClient) Send(msg chatMessage) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+
+	if isIRCChannel(msg.target) && !c.joined[msg.target] {
+		if err := c.write("JOIN " + msg.target); err != nil {
+			return err
+		}
+		c.joined[msg.target] = true
+	}
+	for _, line := range strings.Split(msg.text, "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		if len(line) > ircMaxText {
+			line = truncateUTF8(line, ircMaxText-3) + "..."
+		}
+		if err := c.write(fmt.Sprintf("%s %s :%s", c.command, msg.target, line)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *ircClient) Close() error {
+	c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	c.conn.Write([]byte("QUIT :bye\r\n"))
+	return c.conn.Close()
+}
+
+func (c *ircClient) write(line string) error {
+	c.conn.SetWriteDeadline(time.Now().Add(chatDialTimeout))
+	_, err := c.conn.Write([]byte(strings.NewReplacer("\r", " ", "\n", " ").Replace(line) + "\r\n"))
+	return err
+}
+
+func isIRCChannel(target string) bool {
+	return strings.HasPrefix(target, "#") || strings.HasPrefix(target, "&")
+}
+
+// parseIRCLine splits a line of irc in its prefix, command and params,
+// the trailing param keeps its spaces
+func parseIRCLine(line string) (string, string, []string) {
+	line = strings.TrimRight(line, "\r\n")
+	prefix := ""
+	if strings.HasPrefix(line, ":") {
+		i := strings.Index(line, " ")
+		if i < 0 {
+			return line[1:], "", nil
+		}
+		prefix, line = line[1:i], strings.TrimLeft(line[i+1:], " ")
+	}
+	trailing := ""
+	hasTrailing := false
+	if i := strings.Index(line, " :"); i >= 0 {
+		trailing, line, hasTrailing = line[i+2:], line[:i], true
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return prefix, "", nil
+	}
+	params := fields[1:]
+	if hasTrailing {
+		params = append(params, trailing)
+	}
+	return prefix, strings.ToUpper(fields[0]), params
+}
+
+// truncateUTF8 cuts the text to at most n bytes without splitting a rune
+func truncateUTF8(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	for n > 0 && text[n]&0xC0 == 0x80 {
+		n--
+	}
+	return text[:n]
+}
@@ -0,0 +1,180 @@
+package alertManager
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeIRCServer accepts the irc connections and records the lines sent by
+// the clients, handle answers them
+type fakeIRCServer struct {
+	addr  string
+	lines chan string
+	conns chan net.Conn
+}
+
+func newFakeIRCServer(t *testing.T, handle func(conn net.Conn, line string)) *fakeIRCServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	s := &fakeIRCServer{addr: ln.Addr().String(), lines: make(chan string, 100), conns: make(chan net.Conn, 10)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.conns <- conn
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					s.lines <- scanner.Text()
+					handle(conn, scanner.Text())
+				}
+			}()
+		}
+	}()
+	return s
+}
+
+// welcomeIRC registers the clients as soon as they send USER
+func welcomeIRC(conn net.Conn, line string) {
+	if strings.HasPrefix(line, "USER ") {
+		conn.Write([]byte(":irc.test 001 signoz :Welcome\r\n"))
+	}
+}
+
+// expect waits for the line, skipping the lines received before it, and
+// returns the skipped lines
+func (s *fakeIRCServer) expect(t *testing.T, want string) []string {
+	t.Helper()
+	var skipped []string
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case line := <-s.lines:
+			if line == want {
+				return skipped
+			}
+			skipped = append(skipped, line)
+		case <-timeout:
+			t.Fatalf("the irc server did not receive %q, got %q", want, skipped)
+		}
+	}
+}
+
+func TestIRCRegisterAndSend(t *testing.T) {
+	server := newFakeIRCServer(t, func(conn net.Conn, line string) {
+		switch line {
+		case "NICK signoz":
+			conn.Write([]byte(":irc.test 433 * signoz :Nickname is already in use\r\n"))
+		case "NICK signoz_":
+			conn.Write([]byte("PING :irc.test\r\n:irc.test 001 signoz_ :Welcome\r\n"))
+		}
+	})
+	cfg := &IRCConfig{Server: server.addr, Nick: "signoz", Password: "secret", Targets: []string{"#alerts", "oncall"}, Notice: true}
+
+	client, err := cfg.dial(context.Background(), NewEgressPolicy(""))
+	require.NoError(t, err)
+	defer client.Close()
+	server.expect(t, "PASS secret")
+	server.expect(t, "NICK signoz")
+	server.expect(t, "USER signoz 0 * :SigNoz alerts")
+	// the nick in use gets an underscore
+	server.expect(t, "NICK signoz_")
+	server.expect(t, "PONG :irc.test")
+
+	for _, msg := range chatMessages(cfg, "disk full\n\non db-1") {
+		require.NoError(t, client.Send(msg))
+	}
+	server.expect(t, "JOIN #alerts")
+	server.expect(t, "NOTICE #alerts :disk full")
+	server.expect(t, "NOTICE #alerts :on db-1")
+	// the nicks are not joined
+	assert.Equal(t, []string{"NOTICE oncall :disk full"}, server.expect(t, "NOTICE oncall :on db-1"))
+
+	// the channel is joined once and the long lines are cut
+	require.NoError(t, client.Send(chatMessage{target: "#alerts", text: strings.Repeat("é", 300)}))
+	line := <-server.lines
+	assert.True(t, strings.HasPrefix(line, "NOTICE #alerts :é"), line)
+	assert.True(t, strings.HasSuffix(line, "..."), line)
+	assert.LessOrEqual(t, len(line), len("NOTICE #alerts :")+ircMaxText)
+}
+
+func TestIRCAuthFailure(t *testing.T) {
+	server := newFakeIRCServer(t, func(conn net.Conn, line string) {
+		if strings.HasPrefix(line, "USER ") {
+			conn.Write([]byte(":irc.test 464 signoz :Password incorrect\r\n"))
+		}
+	})
+	cfg := &IRCConfig{Server: server.addr, Nick: "signoz", Password: "wrong", Targets: []string{"#alerts"}}
+
+	_, err := cfg.dial(context.Background(), NewEgressPolicy(""))
+	assert.ErrorContains(t, err, "irc server refused the connection: signoz Password incorrect")
+
+	// the servers out of the egress allowlist are not dialed
+	_, err = cfg.dial(context.Background(), NewEgressPolicy("irc.example.com"))
+	assert.ErrorContains(t, err, "is not in the notification egress allowlist")
+}
+
+func TestChatSessionReconnects(t *testing.T) {
+	server := newFakeIRCServer(t, welcomeIRC)
+	cfg := &IRCConfig{Server: server.addr, Nick: "signoz", Targets: []string{"#alerts"}}
+	session := newChatSession(cfg, NewEgressPolicy(""))
+	defer func() {
+		session.stop()
+		session.client.Close()
+	}()
+
+	require.NoError(t, session.send(chatMessage{target: "#alerts", text: "first"}))
+	server.expect(t, "PRIVMSG #alerts :first")
+
+	// the server closes the connection, the next message reconnects
+	first := <-server.conns
+	first.Write([]byte("ERROR :Closing link\r\n"))
+	first.Close()
+	require.Eventually(t, func() bool {
+		c := session.client.(*ircClient)
+		c.mtx.Lock()
+		defer c.mtx.Unlock()
+		return c.err != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, session.send(chatMessage{target: "#alerts", text: "second"}))
+	select {
+	case <-server.conns:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the session did not reconnect")
+	}
+	// the channel is joined again on the new connection
+	server.expect(t, "JOIN #alerts")
+	server.expect(t, "PRIVMSG #alerts :second")
+}
+
+func TestParseIRCLine(t *testing.T) {
+	for _, tc := range []struct {
+		line    string
+		prefix  string
+		command string
+		params  []string
+	}{
+		{line: "PING :irc.test\r\n", command: "PING", params: []string{"irc.test"}},
+		{line: ":irc.test 001 signoz :Welcome to the network", prefix: "irc.test", command: "001", params: []string{"signoz", "Welcome to the network"}},
+		{line: ":op!op@host kick #alerts signoz :bye", prefix: "op!op@host", command: "KICK", params: []string{"#alerts", "signoz", "bye"}},
+		{line: ":irc.test", prefix: "irc.test"},
+	} {
+		prefix, command, params := parseIRCLine(tc.line)
+		assert.Equal(t, tc.prefix, prefix, tc.line)
+		assert.Equal(t, tc.command, command, tc.line)
+		assert.Equal(t, tc.params, params, tc.line)
+	}
+}
@@ -0,0 +1,322 @@
+package alertManager
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	nsXMPPStream  = "http://etherx.jabber.org/streams"
+	nsXMPPTLS     = "urn:ietf:params:xml:ns:xmpp-tls"
+	nsXMPPSASL    = "urn:ietf:params:xml:ns:xmpp-sasl"
+	nsXMPPBind    = "urn:ietf:params:xml:ns:xmpp-bind"
+	nsXMPPPing    = "urn:xmpp:ping"
+	nsXMPPMUC     = "http://jabber.org/protocol/muc"
+	xmppResource  = "signoz"
+	xmppRoomNick  = "signoz"
+	xmppPortPlain = "5222"
+	xmppPortTLS   = "5223"
+)
+
+// splitJID returns the local part and the domain of the jid, without the
+// resource
+func splitJID(jid string) (string, string) {
+	if i := strings.Index(jid, "/"); i >= 0 {
+		jid = jid[:i]
+	}
+	local, domain := "", jid
+	if i := strings.Index(jid, "@"); i >= 0 {
+		local, domain = jid[:i], jid[i+1:]
+	}
+	if strings.ContainsAny(domain, " @") {
+		return "", ""
+	}
+	return local, domain
+}
+
+type xmppFeatures struct {
+	StartTLS   *struct{} `xml:"urn:ietf:params:xml:ns:xmpp-tls starttls"`
+	Mechanisms struct {
+		Mechanism []string `xml:"mechanism"`
+	} `xml:"urn:ietf:params:xml:ns:xmpp-sasl mechanisms"`
+	Bind *struct{} `xml:"urn:ietf:params:xml:ns:xmpp-bind bind"`
+}
+
+type xmppIQ struct {
+	ID   string    `xml:"id,attr"`
+	Type string    `xml:"type,attr"`
+	From string    `xml:"from,attr"`
+	Ping *struct{} `xml:"urn:xmpp:ping ping"`
+}
+
+// xmppClient is a bound session on an xmpp server. The rooms are joined
+// on the first message sent to them.
+type xmppClient struct {
+	conn    net.Conn
+	decoder *xml.Decoder
+	nick    string
+
+	mtx    sync.Mutex
+	joined map[string]bool
+	// err is set when the stream is closed by the server
+	err error
+}
+
+func (c *XMPPConfig) dial(ctx context.Context, policy *EgressPolicy) (chatClient, error) {
+	local, domain := splitJID(c.JID)
+	addr, err := c.serverAddr(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dialChat(ctx, policy, addr)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{ServerName: domain, RootCAs: chatRootCAs, MinVersion: tls.VersionTLS12}
+	if c.DirectTLS {
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client := &xmppClient{conn: conn, nick: c.Nick, joined: map[string]bool{}}
+	if client.nick == "" {
+		client.nick = xmppRoomNick
+	}
+	if err := client.login(ctx, domain, local, c.Password, c.DirectTLS, tlsConfig); err != nil {
+		client.conn.Close()
+		return nil, err
+	}
+	client.conn.SetDeadline(time.Time{})
+	go client.read()
+	return client, nil
+}
+
+// serverAddr returns the configured server, or the server of the SRV
+// records of the domain, or the domain itself
+func (c *XMPPConfig) serverAddr(ctx context.Context, domain string) (string, error) {
+	if c.Server != "" {
+		return c.Server, nil
+	}
+	service, port := "xmpp-client", xmppPortPlain
+	if c.DirectTLS {
+		service, port = "xmpps-client", xmppPortTLS
+	}
+	_, records, err := net.DefaultResolver.LookupSRV(ctx, service, "tcp", domain)
+	if err == nil && len(records) > 0 {
+		return net.JoinHostPort(strings.TrimSuffix(records[0].Target, "."), strconv.Itoa(int(records[0].Port))), nil
+	}
+	return net.JoinHostPort(domain, port), nil
+}
+
+// login upgrades the stream to tls, authenticates with SASL PLAIN, binds
+// the resource and sends the initial presence. PLAIN is only used over
+// tls, servers without STARTTLS are refused.
+func (c *xmppClient) login(ctx context.Context, domain, local, password string, secure bool, tlsConfig *tls.Config) error {
+	features, err := c.openStream(domain)
+	if err != nil {
+		return err
+	}
+
+	if !secure {
+		if features.StartTLS == nil {
+			return fmt.Errorf("xmpp server %s does not offer STARTTLS", domain)
+		}
+		if err := c.write(fmt.Sprintf("<starttls xmlns='%s'/>", nsXMPPTLS)); err != nil {
+			return err
+		}
+		el, err := c.next()
+		if err != nil {
+			return err
+		}
+		if el.Name.Local != "proceed" {
+			return fmt.Errorf("xmpp server %s refused STARTTLS", domain)
+		}
+		tlsConn := tls.Client(c.conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return err
+		}
+		c.conn = tlsConn
+		if features, err = c.openStream(domain); err != nil {
+			return err
+		}
+	}
+
+	plain := false
+	for _, m := range features.Mechanisms.Mechanism {
+		plain = plain || m == "PLAIN"
+	}
+	if !plain {
+		return fmt.Errorf("xmpp server %s does not offer SASL PLAIN", domain)
+	}
+	auth := base64.StdEncoding.EncodeToString([]byte("\x00" + local + "\x00" + password))
+	if err := c.write(fmt.Sprintf("<auth xmlns='%s' mechanism='PLAIN'>%s</auth>", nsXMPPSASL, auth)); err != nil {
+		return err
+	}
+	el, err := c.next()
+	if err != nil {
+		return err
+	}
+	if el.Name.Local != "success" {
+		c.decoder.Skip()
+		return fmt.Errorf("xmpp authentication failed for %s@%s", local, domain)
+	}
+	c.decoder.Skip()
+
+	if features, err = c.openStream(domain); err != nil {
+		return err
+	}
+	if features.Bind == nil {
+		return fmt.Errorf("xmpp server %s does not offer resource binding", domain)
+	}
+	if err := c.write(fmt.Sprintf("<iq type='set' id='bind'><bind xmlns='%s'><resource>%s</resource></bind></iq>", nsXMPPBind, xmppResource)); err != nil {
+		return err
+	}
+	if el, err = c.next(); err != nil {
+		return err
+	}
+	var iq xmppIQ
+	if err := c.decoder.DecodeElement(&iq, &el); err != nil {
+		return err
+	}
+	if el.Name.Local != "iq" || iq.Type != "result" {
+		return fmt.Errorf("xmpp server %s refused to bind the resource", domain)
+	}
+	return c.write("<presence/>")
+}
+
+// openStream opens a new stream, as required at the start and after tls
+// and authentication, and returns the features of the server
+func (c *xmppClient) openStream(domain string) (*xmppFeatures, error) {
+	err := c.write(fmt.Sprintf("<?xml version='1.0'?><stream:stream to='%s' xmlns='jabber:client' xmlns:stream='%s' version='1.0'>", xmlEscape(domain), nsXMPPStream))
+	if err != nil {
+		return nil, err
+	}
+	c.decoder = xml.NewDecoder(c.conn)
+
+	el, err := c.next()
+	if err != nil {
+		return nil, err
+	}
+	if el.Name.Space != nsXMPPStream || el.Name.Local != "stream" {
+		return nil, fmt.Errorf("unexpected xmpp element %s", el.Name.Local)
+	}
+	if el, err = c.next(); err != nil {
+		return nil, err
+	}
+	if el.Name.Space != nsXMPPStream || el.Name.Local != "features" {
+		return nil, fmt.Errorf("unexpected xmpp element %s", el.Name.Local)
+	}
+	features := &xmppFeatures{}
+	if err := c.decoder.DecodeElement(features, &el); err != nil {
+		return nil, err
+	}
+	return features, nil
+}
+
+// next returns the next element of the stream, stream errors are
+// returned as errors
+func (c *xmppClient) next() (xml.StartElement, error) {
+	for {
+		token, err := c.decoder.Token()
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Space == nsXMPPStream && t.Name.Local == "error" {
+				var streamErr struct {
+					Inner []byte `xml:",innerxml"`
+				}
+				c.decoder.DecodeElement(&streamErr, &t)
+				return t, fmt.Errorf("xmpp stream error: %s", streamErr.Inner)
+			}
+			return t, nil
+		case xml.EndElement:
+			if t.Name.Space == nsXMPPStream && t.Name.Local == "stream" {
+				return xml.StartElement{}, fmt.Errorf("xmpp stream closed by the server")
+			}
+		}
+	}
+}
+
+// read answers the pings of the server until the stream is closed, the
+// other stanzas are ignored
+func (c *xmppClient) read() {
+	for {
+		el, err := c.next()
+		if err != nil {
+			c.mtx.Lock()
+			c.err = err
+			c.mtx.Unlock()
+			return
+		}
+		if el.Name.Local != "iq" {
+			c.decoder.Skip()
+			continue
+		}
+		var iq xmppIQ
+		if err := c.decoder.DecodeElement(&iq, &el); err != nil {
+			continue
+		}
+		if iq.Type == "get" && iq.Ping != nil {
+			c.mtx.Lock()
+			c.write(fmt.Sprintf("<iq type='result' id='%s' to='%s'/>", xmlEscape(iq.ID), xmlEscape(iq.From)))
+			c.mtx.Unlock()
+		}
+	}
+}
+
+func (c *xmppClient) Send(msg chatMessage) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+
+	kind := "chat"
+	if msg.room {
+		kind = "groupchat"
+		if !c.joined[msg.target] {
+			join := fmt.Sprintf("<presence to='%s/%s'><x xmlns='%s'><history maxstanzas='0'/></x></presence>", xmlEscape(msg.target), xmlEscape(c.nick), nsXMPPMUC)
+			if err := c.write(join); err != nil {
+				return err
+			}
+			c.joined[msg.target] = true
+		}
+	}
+	return c.write(fmt.Sprintf("<message to='%s' type='%s'><body>%s</body></message>", xmlEscape(msg.target), kind, xmlEscape(msg.text)))
+}
+
+func (c *xmppClient) Close() error {
+	c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	c.conn.Write([]byte("</stream:stream>"))
+	return c.conn.Close()
+}
+
+func (c *xmppClient) write(data string) error {
+	c.conn.SetWriteDeadline(time.Now().Add(chatDialTimeout))
+	_, err := c.conn.Write([]byte(data))
+	return err
+}
+
+func xmlEscape(text string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(text))
+	return buf.String()
+}
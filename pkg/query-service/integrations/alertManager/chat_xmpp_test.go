@@ -0,0 +1,227 @@
+package alertManager
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// xmppStanza is what the fake server reads of the stanzas of the client
+type xmppStanza struct {
+	XMLName xml.Name
+	To      string `xml:"to,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:"body"`
+	Inner   string `xml:",innerxml"`
+}
+
+// fakeXMPPServer negotiates STARTTLS, SASL PLAIN and the resource binding
+// with the clients and records the stanzas they send after
+type fakeXMPPServer struct {
+	addr     string
+	password string
+	// startTLS is false for the servers refusing tls
+	startTLS bool
+	tls      *tls.Config
+	stanzas  chan xmppStanza
+	conns    chan net.Conn
+}
+
+func newFakeXMPPServer(t *testing.T, password string, startTLS bool) *fakeXMPPServer {
+	// the certificate of the httptest servers is valid for example.com
+	certServer := httptest.NewUnstartedServer(nil)
+	certServer.StartTLS()
+	certServer.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(certServer.Certificate())
+	chatRootCAs = roots
+	t.Cleanup(func() { chatRootCAs = nil })
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	s := &fakeXMPPServer{
+		addr:     ln.Addr().String(),
+		password: password,
+		startTLS: startTLS,
+		tls:      &tls.Config{Certificates: certServer.TLS.Certificates},
+		stanzas:  make(chan xmppStanza, 100),
+		conns:    make(chan net.Conn, 10),
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeXMPPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	dec := xml.NewDecoder(conn)
+	open := func(features string) bool {
+		if _, err := nextXMPPStart(dec); err != nil {
+			return false
+		}
+		fmt.Fprintf(conn, "<?xml version='1.0'?><stream:stream xmlns='jabber:client' xmlns:stream='%s' from='example.com' id='1' version='1.0'><stream:features>%s</stream:features>", nsXMPPStream, features)
+		return true
+	}
+	read := func() (xmppStanza, bool) {
+		start, err := nextXMPPStart(dec)
+		if err != nil {
+			return xmppStanza{}, false
+		}
+		var stanza xmppStanza
+		return stanza, dec.DecodeElement(&stanza, &start) == nil
+	}
+
+	if !s.startTLS {
+		open(fmt.Sprintf("<mechanisms xmlns='%s'><mechanism>PLAIN</mechanism></mechanisms>", nsXMPPSASL))
+		return
+	}
+	if !open(fmt.Sprintf("<starttls xmlns='%s'><required/></starttls>", nsXMPPTLS)) {
+		return
+	}
+	if stanza, ok := read(); !ok || stanza.XMLName.Local != "starttls" {
+		return
+	}
+	fmt.Fprintf(conn, "<proceed xmlns='%s'/>", nsXMPPTLS)
+	tlsConn := tls.Server(conn, s.tls)
+	if err := tlsConn.Handshake(); err != nil {
+		return
+	}
+	conn = tlsConn
+	dec = xml.NewDecoder(conn)
+
+	if !open(fmt.Sprintf("<mechanisms xmlns='%s'><mechanism>SCRAM-SHA-1</mechanism><mechanism>PLAIN</mechanism></mechanisms>", nsXMPPSASL)) {
+		return
+	}
+	auth, ok := read()
+	if !ok {
+		return
+	}
+	credentials, _ := base64.StdEncoding.DecodeString(auth.Inner)
+	if string(credentials) != "\x00signoz\x00"+s.password {
+		fmt.Fprintf(conn, "<failure xmlns='%s'><not-authorized/></failure></stream:stream>", nsXMPPSASL)
+		return
+	}
+	fmt.Fprintf(conn, "<success xmlns='%s'/>", nsXMPPSASL)
+
+	if !open(fmt.Sprintf("<bind xmlns='%s'/>", nsXMPPBind)) {
+		return
+	}
+	if bind, ok := read(); !ok || bind.XMLName.Local != "iq" {
+		return
+	}
+	fmt.Fprintf(conn, "<iq type='result' id='bind'><bind xmlns='%s'><jid>signoz@example.com/%s</jid></bind></iq>", nsXMPPBind, xmppResource)
+
+	s.conns <- conn
+	for {
+		stanza, ok := read()
+		if !ok {
+			return
+		}
+		s.stanzas <- stanza
+	}
+}
+
+// nextXMPPStart returns the next start element sent by the client
+func nextXMPPStart(dec *xml.Decoder) (xml.StartElement, error) {
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start, nil
+		}
+	}
+}
+
+func (s *fakeXMPPServer) expect(t *testing.T) xmppStanza {
+	t.Helper()
+	select {
+	case stanza := <-s.stanzas:
+		return stanza
+	case <-time.After(5 * time.Second):
+		t.Fatal("the xmpp server did not receive a stanza")
+		return xmppStanza{}
+	}
+}
+
+func TestXMPPLoginAndSend(t *testing.T) {
+	server := newFakeXMPPServer(t, "secret", true)
+	cfg := &XMPPConfig{
+		JID:      "signoz@example.com",
+		Password: "secret",
+		Server:   server.addr,
+		To:       []string{"oncall@example.com"},
+		Rooms:    []string{"alerts@conference.example.com"},
+	}
+
+	client, err := cfg.dial(context.Background(), NewEgressPolicy(""))
+	require.NoError(t, err)
+	defer client.Close()
+	assert.Equal(t, "presence", server.expect(t).XMLName.Local)
+
+	for _, msg := range chatMessages(cfg, "disk <full> & slow") {
+		require.NoError(t, client.Send(msg))
+	}
+	direct := server.expect(t)
+	assert.Equal(t, xmppStanza{XMLName: direct.XMLName, To: "oncall@example.com", Type: "chat", Body: "disk <full> & slow", Inner: direct.Inner}, direct)
+	// the room is joined with the nick before the first message
+	join := server.expect(t)
+	assert.Equal(t, "presence", join.XMLName.Local)
+	assert.Equal(t, "alerts@conference.example.com/signoz", join.To)
+	room := server.expect(t)
+	assert.Equal(t, "groupchat", room.Type)
+	assert.Equal(t, "disk <full> & slow", room.Body)
+
+	// the client notices that the server closed the stream
+	conn := <-server.conns
+	io.WriteString(conn, "</stream:stream>")
+	assert.Eventually(t, func() bool {
+		return client.Send(chatMessage{target: "oncall@example.com", text: "again"}) != nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestXMPPAuthFailure(t *testing.T) {
+	server := newFakeXMPPServer(t, "secret", true)
+	cfg := &XMPPConfig{JID: "signoz@example.com", Password: "wrong", Server: server.addr, To: []string{"oncall@example.com"}}
+
+	_, err := cfg.dial(context.Background(), NewEgressPolicy(""))
+	assert.ErrorContains(t, err, "xmpp authentication failed for signoz@example.com")
+
+	// the password is never sent to the servers without tls
+	plain := newFakeXMPPServer(t, "secret", false)
+	cfg.Server, cfg.Password = plain.addr, "secret"
+	_, err = cfg.dial(context.Background(), NewEgressPolicy(""))
+	assert.ErrorContains(t, err, "does not offer STARTTLS")
+}
+
+func TestSplitJID(t *testing.T) {
+	local, domain := splitJID("signoz@example.com/resource")
+	assert.Equal(t, "signoz", local)
+	assert.Equal(t, "example.com", domain)
+	local, domain = splitJID("example.com")
+	assert.Equal(t, "", local)
+	assert.Equal(t, "example.com", domain)
+	_, domain = splitJID("a@b@c")
+	assert.Equal(t, "", domain)
+}
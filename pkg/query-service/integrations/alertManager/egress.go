@@ -0,0 +1,283 @@
+package alertManager
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"sync"
+
+	"go.signoz.io/signoz/pkg/query-service/constants"
+)
+
+// HTTPConfig configures how the integrations of a channel reach their
+// destination, e.g. through a corporate proxy with a private CA
+type HTTPConfig struct {
+	// ProxyURL supports http, https and socks5 proxies
+	ProxyURL string `yaml:"proxy_url,omitempty" json:"proxy_url,omitempty"`
+	// CABundle is a PEM encoded list of certificates trusted in
+	// addition to the system roots
+	CABundle string `yaml:"ca_bundle,omitempty" json:"ca_bundle,omitempty"`
+}
+
+func (c *HTTPConfig) Validate() error {
+	if c.ProxyURL != "" {
+		u, err := neturl.Parse(c.ProxyURL)
+		if err != nil {
+			return fmt.Errorf("invalid proxy url: %w", err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return fmt.Errorf("unsupported proxy scheme %q, expected http, https or socks5", u.Scheme)
+		}
+	}
+	if c.CABundle != "" {
+		if ok := x509.NewCertPool().AppendCertsFromPEM([]byte(c.CABundle)); !ok {
+			return fmt.Errorf("ca bundle does not contain any valid certificate")
+		}
+	}
+	return nil
+}
+
+// alertManagerConfig returns the http_config understood by the alert
+// manager integrations
+func (c *HTTPConfig) alertManagerConfig() map[string]interface{} {
+	cfg := map[string]interface{}{}
+	if c.ProxyURL != "" {
+		cfg["proxy_url"] = c.ProxyURL
+	}
+	if c.CABundle != "" {
+		cfg["tls_config"] = map[string]interface{}{"ca": c.CABundle}
+	}
+	return cfg
+}
+
+// defaultIntegrationURLs are the destinations the alert manager uses
+// when the channel does not override the url
+var defaultIntegrationURLs = map[string]string{
+	"pagerduty_configs": "https://events.pagerduty.com/v2/enqueue",
+	"opsgenie_configs":  "https://api.opsgenie.com/",
+	"victorops_configs": "https://alert.victorops.com/integrations/generic/20131114/alert/",
+	"pushover_configs":  "https://api.pushover.net/1/messages.json",
+	"wechat_configs":    "https://qyapi.weixin.qq.com/cgi-bin/",
+}
+
+// integrations returns the alert manager integrations of the receiver
+// by their config key
+func (r *Receiver) integrations() map[string]interface{} {
+	return map[string]interface{}{
+		"email_configs":     r.EmailConfigs,
+		"pagerduty_configs": r.PagerdutyConfigs,
+		"slack_configs":     r.SlackConfigs,
+		"webhook_configs":   r.WebhookConfigs,
+		"opsgenie_configs":  r.OpsGenieConfigs,
+		"wechat_configs":    r.WechatConfigs,
+		"pushover_configs":  r.PushoverConfigs,
+		"victorops_configs": r.VictorOpsConfigs,
+		"sns_configs":       r.SNSConfigs,
+		"msteams_configs":   r.MSTeamsConfigs,
+	}
+}
+
+// Destinations returns the hosts the alert manager, or query-service for
+// the chat integrations, connects to when delivering notifications for the
+// receiver
+func (r *Receiver) Destinations() []string {
+	hosts := []string{}
+	for _, conn := range receiverChatConnections(r) {
+		hosts = append(hosts, conn.servers()...)
+	}
+	for key, configs := range r.integrations() {
+		list, ok := configs.([]interface{})
+		if !ok {
+			continue
+		}
+		for _, c := range list {
+			cfg, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			found := collectDestinations(cfg, &hosts)
+			if !found && defaultIntegrationURLs[key] != "" {
+				if u, err := neturl.Parse(defaultIntegrationURLs[key]); err == nil {
+					hosts = append(hosts, u.Host)
+				}
+			}
+		}
+	}
+	return hosts
+}
+
+// collectDestinations walks the integration config for url fields and
+// the email smarthost. The alert manager does not expand templates in
+// them, a templated value is checked by its host when it is not templated
+// and kept as it is otherwise so that it is refused.
+func collectDestinations(cfg map[string]interface{}, hosts *[]string) bool {
+	found := false
+	for key, value := range cfg {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			// the proxy and the oauth2 token url of the http config are
+			// reached in addition to the url of the integration
+			if key == "http_config" {
+				collectDestinations(v, hosts)
+				continue
+			}
+			if collectDestinations(v, hosts) {
+				found = true
+			}
+		case string:
+			if v == "" || key != "smarthost" && !strings.HasSuffix(key, "url") {
+				continue
+			}
+			if strings.Contains(v, "{{") {
+				host, ok := templatedHost(v)
+				if !ok || key == "smarthost" {
+					host = v
+				}
+				*hosts = append(*hosts, host)
+				found = true
+			} else if key == "smarthost" {
+				*hosts = append(*hosts, v)
+				found = true
+			} else if u, err := neturl.Parse(v); err == nil && u.Host != "" {
+				*hosts = append(*hosts, u.Host)
+				found = true
+			}
+		}
+	}
+	return found
+}
+
+// templatedHost returns the host of the url when the template starts
+// after it, e.g. in the path or the query
+func templatedHost(v string) (string, bool) {
+	prefix := v[:strings.Index(v, "{{")]
+	u, err := neturl.Parse(prefix)
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+	rest := strings.TrimPrefix(prefix, u.Scheme+"://")
+	if strings.IndexAny(rest, "/?#") < 0 {
+		return "", false
+	}
+	return u.Host, true
+}
+
+// EgressPolicy is the allowlist of outbound destinations notifications
+// can be delivered to. Entries are exact hosts, wildcard domains like
+// *.slack.com or CIDR ranges, an empty policy allows everything.
+type EgressPolicy struct {
+	hosts    map[string]struct{}
+	suffixes []string
+	nets     []*net.IPNet
+	allowed  bool
+}
+
+func NewEgressPolicy(spec string) *EgressPolicy {
+	p := &EgressPolicy{hosts: map[string]struct{}{}}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+		case entry == "*":
+			p.allowed = true
+		case strings.HasPrefix(entry, "*."):
+			p.suffixes = append(p.suffixes, entry[1:])
+		case strings.Contains(entry, "/"):
+			if _, n, err := net.ParseCIDR(entry); err == nil {
+				p.nets = append(p.nets, n)
+			}
+		default:
+			p.hosts[entry] = struct{}{}
+		}
+	}
+	if len(p.hosts) == 0 && len(p.suffixes) == 0 && len(p.nets) == 0 {
+		p.allowed = true
+	}
+	return p
+}
+
+var (
+	defaultEgressPolicy     *EgressPolicy
+	defaultEgressPolicyOnce sync.Once
+)
+
+// DefaultEgressPolicy returns the policy configured with
+// NOTIFICATION_EGRESS_ALLOWLIST
+func DefaultEgressPolicy() *EgressPolicy {
+	defaultEgressPolicyOnce.Do(func() {
+		defaultEgressPolicy = NewEgressPolicy(constants.NotificationEgressAllowlist)
+	})
+	return defaultEgressPolicy
+}
+
+// Allowed checks the host, with or without port, against the allowlist
+func (p *EgressPolicy) Allowed(host string) bool {
+	if p.allowed {
+		return true
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.Trim(host, "[]"))
+
+	if _, ok := p.hosts[host]; ok {
+		return true
+	}
+	for _, suffix := range p.suffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		for _, n := range p.nets {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CheckReceiver verifies that the destinations and the proxy of the
+// receiver are allowed
+func (p *EgressPolicy) CheckReceiver(r *Receiver) error {
+	for _, host := range r.Destinations() {
+		if p.Allowed(host) {
+			continue
+		}
+		if strings.Contains(host, "{{") {
+			return fmt.Errorf("destination %s is templated and can not be checked against the notification egress allowlist", host)
+		}
+		return fmt.Errorf("destination %s is not in the notification egress allowlist", host)
+	}
+	if r.HTTPConfig != nil && r.HTTPConfig.ProxyURL != "" {
+		u, err := neturl.Parse(r.HTTPConfig.ProxyURL)
+		if err == nil && !p.Allowed(u.Host) {
+			return fmt.Errorf("proxy %s is not in the notification egress allowlist", u.Host)
+		}
+	}
+	return nil
+}
+
+// Transport returns an http transport that refuses to connect to hosts
+// outside of the allowlist. When a proxy is set in the environment the
+// connection is made to the proxy, so the proxy has to be allowed.
+func (p *EgressPolicy) Transport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	dial := (&net.Dialer{}).DialContext
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if !p.Allowed(addr) {
+			return nil, fmt.Errorf("destination %s is not in the notification egress allowlist", addr)
+		}
+		return dial(ctx, network, addr)
+	}
+	t.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	return t
+}
@@ -0,0 +1,91 @@
+package alertManager
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckReceiver(t *testing.T) {
+	policy := NewEgressPolicy("hooks.slack.com, *.pagerduty.com, smtp.example.com, 10.0.0.0/8")
+	for _, tc := range []struct {
+		name     string
+		receiver string
+		err      string
+	}{
+		{name: "allowed host", receiver: `{"slack_configs":[{"api_url":"https://hooks.slack.com/services/T0/B0/x"}]}`},
+		{name: "allowed wildcard domain", receiver: `{"pagerduty_configs":[{"url":"https://events.eu.pagerduty.com/v2/enqueue"}]}`},
+		{name: "allowed cidr", receiver: `{"webhook_configs":[{"url":"http://10.1.2.3:8080/alerts"}]}`},
+		{name: "allowed smarthost", receiver: `{"email_configs":[{"to":"oncall@example.com","smarthost":"smtp.example.com:587"}]}`},
+		{
+			name:     "denied host",
+			receiver: `{"webhook_configs":[{"url":"https://attacker.example/hook"}]}`,
+			err:      "destination attacker.example is not in the notification egress allowlist",
+		},
+		{
+			name:     "default url of the integration",
+			receiver: `{"opsgenie_configs":[{"api_key":"x"}]}`,
+			err:      "destination api.opsgenie.com is not in the notification egress allowlist",
+		},
+		{
+			name:     "templated path of an allowed host",
+			receiver: `{"slack_configs":[{"api_url":"https://hooks.slack.com/services/{{ .CommonLabels.team }}"}]}`,
+		},
+		{
+			name:     "templated query of a denied host",
+			receiver: `{"webhook_configs":[{"url":"https://attacker.example/?x={{"}]}`,
+			err:      "destination attacker.example is not in the notification egress allowlist",
+		},
+		{
+			name:     "templated host",
+			receiver: `{"webhook_configs":[{"url":"https://{{ .CommonLabels.host }}/hook"}]}`,
+			err:      "is templated",
+		},
+		{
+			name:     "templated host of an allowed domain",
+			receiver: `{"webhook_configs":[{"url":"https://hooks.slack.com{{ .x }}"}]}`,
+			err:      "is templated",
+		},
+		{
+			name:     "templated smarthost",
+			receiver: `{"email_configs":[{"to":"oncall@example.com","smarthost":"{{ .x }}:25"}]}`,
+			err:      "is templated",
+		},
+		{
+			name:     "nested config",
+			receiver: `{"webhook_configs":[{"url":"https://hooks.slack.com/x","http_config":{"oauth2":{"token_url":"https://attacker.example/token"}}}]}`,
+			err:      "destination attacker.example is not in the notification egress allowlist",
+		},
+		{
+			name:     "proxy of an integration",
+			receiver: `{"pagerduty_configs":[{"routing_key":"x","http_config":{"proxy_url":"http://proxy.example:3128"}}]}`,
+			err:      "destination proxy.example:3128 is not in the notification egress allowlist",
+		},
+		{
+			name:     "proxy of the channel",
+			receiver: `{"http_config":{"proxy_url":"http://proxy.example:3128"},"webhook_configs":[{"url":"https://hooks.slack.com/x"}]}`,
+			err:      "proxy proxy.example:3128 is not in the notification egress allowlist",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &Receiver{}
+			require.NoError(t, json.Unmarshal([]byte(tc.receiver), r))
+			err := policy.CheckReceiver(r)
+			if tc.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
+
+func TestCheckReceiverAllowAll(t *testing.T) {
+	r := &Receiver{}
+	require.NoError(t, json.Unmarshal([]byte(`{"webhook_configs":[{"url":"https://{{ .CommonLabels.host }}/hook"}]}`), r))
+	assert.NoError(t, NewEgressPolicy("").CheckReceiver(r))
+	assert.NoError(t, NewEgressPolicy("*").CheckReceiver(r))
+}
@@ -0,0 +1,282 @@
+package alertManager
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// externalAlertsEndpoint is the push endpoint of the Alertmanager v2 API
+const externalAlertsEndpoint = "api/v2/alerts"
+
+// ExternalAlertmanagersConfig is the file listing the Alertmanager
+// clusters the alerts of the rules are forwarded to
+type ExternalAlertmanagersConfig struct {
+	// Replace sends the alerts only to the external clusters, instead of
+	// the SigNoz channels
+	Replace  bool              `yaml:"replace"`
+	Clusters []ExternalCluster `yaml:"clusters"`
+}
+
+// ExternalCluster is an Alertmanager cluster. Every alert is posted to all
+// of its peers like Prometheus does, the peers gossip and deduplicate the
+// notifications, and the batch is delivered when one peer accepts it.
+type ExternalCluster struct {
+	Name      string     `yaml:"name"`
+	URLs      []string   `yaml:"urls"`
+	BasicAuth *BasicAuth `yaml:"basicAuth"`
+	TLS       *TLSConfig `yaml:"tls"`
+	// Timeout of a request to a peer, ten seconds when not set
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+type BasicAuth struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// PasswordFile is read for the password when set
+	PasswordFile string `yaml:"passwordFile"`
+}
+
+// TLSConfig authenticates the peers with the CA and the query service
+// with the client certificate for mTLS
+type TLSConfig struct {
+	CAFile             string `yaml:"caFile"`
+	CertFile           string `yaml:"certFile"`
+	KeyFile            string `yaml:"keyFile"`
+	ServerName         string `yaml:"serverName"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
+}
+
+func (c *ExternalCluster) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("an alertmanager cluster has no name")
+	}
+	if len(c.URLs) == 0 {
+		return fmt.Errorf("alertmanager cluster %s has no urls", c.Name)
+	}
+	if c.BasicAuth != nil && c.BasicAuth.Username == "" {
+		return fmt.Errorf("alertmanager cluster %s has basic auth without a username", c.Name)
+	}
+	if c.TLS != nil && (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return fmt.Errorf("alertmanager cluster %s needs both a certificate and a key for mTLS", c.Name)
+	}
+	return nil
+}
+
+func (c *TLSConfig) config() (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if c.CAFile != "" {
+		ca, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate in %s", c.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// basicAuthTransport sets the credentials of the cluster on the requests
+type basicAuthTransport struct {
+	username string
+	password string
+	next     http.RoundTripper
+}
+
+func (t *basicAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.SetBasicAuth(t.username, t.password)
+	return t.next.RoundTrip(req)
+}
+
+// client returns the client of the cluster, within the egress allowlist
+func (c *ExternalCluster) client() (*http.Client, error) {
+	transport := DefaultEgressPolicy().Transport()
+	if c.TLS != nil {
+		cfg, err := c.TLS.config()
+		if err != nil {
+			return nil, fmt.Errorf("invalid tls config of alertmanager cluster %s: %w", c.Name, err)
+		}
+		transport.TLSClientConfig = cfg
+	}
+	var rt http.RoundTripper = transport
+	if c.BasicAuth != nil {
+		password := c.BasicAuth.Password
+		if c.BasicAuth.PasswordFile != "" {
+			b, err := os.ReadFile(c.BasicAuth.PasswordFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the password of alertmanager cluster %s: %w", c.Name, err)
+			}
+			password = strings.TrimSpace(string(b))
+		}
+		rt = &basicAuthTransport{username: c.BasicAuth.Username, password: password, next: transport}
+	}
+	return &http.Client{Transport: rt}, nil
+}
+
+// postableAlert is an alert of the Alertmanager v2 API, the SigNoz
+// channels of the alert are left out
+type postableAlert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	StartsAt     time.Time         `json:"startsAt,omitempty"`
+	EndsAt       time.Time         `json:"endsAt,omitempty"`
+	GeneratorURL string            `json:"generatorURL,omitempty"`
+}
+
+func encodePostableAlerts(alerts []*Alert) ([]byte, error) {
+	res := make([]postableAlert, 0, len(alerts))
+	for _, a := range alerts {
+		p := postableAlert{
+			StartsAt:     a.StartsAt,
+			EndsAt:       a.EndsAt,
+			GeneratorURL: a.GeneratorURL,
+		}
+		if a.Labels != nil {
+			p.Labels = a.Labels.Map()
+		}
+		if a.Annotations != nil {
+			p.Annotations = a.Annotations.Map()
+		}
+		res = append(res, p)
+	}
+	return json.Marshal(res)
+}
+
+// ExternalAlertmanagers forwards the alerts to the external clusters,
+// every cluster has its own queue so that one unavailable cluster does
+// not hold up the others
+type ExternalAlertmanagers struct {
+	replace   bool
+	names     []string
+	notifiers map[string]*Notifier
+}
+
+// LoadExternalAlertmanagers reads the clusters of the configuration file,
+// every cluster queues up to queueCapacity alerts, 10000 when not set
+func LoadExternalAlertmanagers(path string, queueCapacity int) (*ExternalAlertmanagers, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the external alertmanagers: %w", err)
+	}
+	var cfg ExternalAlertmanagersConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse the external alertmanagers: %w", err)
+	}
+	return NewExternalAlertmanagers(cfg, queueCapacity)
+}
+
+func NewExternalAlertmanagers(cfg ExternalAlertmanagersConfig, queueCapacity int) (*ExternalAlertmanagers, error) {
+	if len(cfg.Clusters) == 0 {
+		return nil, fmt.Errorf("no external alertmanager clusters")
+	}
+	if queueCapacity <= 0 {
+		queueCapacity = 10000
+	}
+	e := &ExternalAlertmanagers{replace: cfg.Replace, notifiers: map[string]*Notifier{}}
+	for _, c := range cfg.Clusters {
+		if err := c.Validate(); err != nil {
+			return nil, err
+		}
+		if _, ok := e.notifiers[c.Name]; ok {
+			return nil, fmt.Errorf("duplicate alertmanager cluster %s", c.Name)
+		}
+		client, err := c.client()
+		if err != nil {
+			return nil, err
+		}
+		urls := make([]string, 0, len(c.URLs))
+		for _, u := range c.URLs {
+			// the push endpoint is resolved relative to the url
+			if !strings.HasSuffix(u, "/") {
+				u += "/"
+			}
+			urls = append(urls, u)
+		}
+		timeout := c.Timeout
+		if timeout <= 0 {
+			timeout = 10 * time.Second
+		}
+		n, err := NewNotifier(&NotifierOptions{
+			QueueCapacity:    queueCapacity,
+			AlertManagerURLs: urls,
+			Timeout:          timeout,
+			Endpoint:         externalAlertsEndpoint,
+			Client:           client,
+			Encode:           encodePostableAlerts,
+		}, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid urls of alertmanager cluster %s: %w", c.Name, err)
+		}
+		e.names = append(e.names, c.Name)
+		e.notifiers[c.Name] = n
+	}
+	return e, nil
+}
+
+// Replace tells whether the alerts are sent to the external clusters
+// only, instead of the SigNoz channels
+func (e *ExternalAlertmanagers) Replace() bool {
+	return e != nil && e.replace
+}
+
+// Send queues the alerts for every cluster
+func (e *ExternalAlertmanagers) Send(alerts ...*Alert) {
+	if e == nil || len(alerts) == 0 {
+		return
+	}
+	for _, name := range e.names {
+		e.notifiers[name].Send(alerts...)
+	}
+}
+
+func (e *ExternalAlertmanagers) Run() {
+	if e == nil {
+		return
+	}
+	for _, name := range e.names {
+		go e.notifiers[name].Run()
+	}
+}
+
+func (e *ExternalAlertmanagers) Stop() {
+	if e == nil {
+		return
+	}
+	for _, name := range e.names {
+		e.notifiers[name].Stop()
+	}
+}
+
+// Stats returns the state of the queue of every cluster
+func (e *ExternalAlertmanagers) Stats() map[string]NotifierStats {
+	stats := map[string]NotifierStats{}
+	if e == nil {
+		return stats
+	}
+	for _, name := range e.names {
+		stats[name] = e.notifiers[name].Stats()
+	}
+	return stats
+}
@@ -0,0 +1,242 @@
+package alertManager
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// fakeAlertmanager is an Alertmanager v2 API answering with the statuses
+// in order, and with the last one once they are used up
+type fakeAlertmanager struct {
+	*httptest.Server
+
+	mtx      sync.Mutex
+	statuses []int
+	batches  [][]map[string]interface{}
+	auth     []string
+}
+
+func newFakeAlertmanager(t *testing.T, tls bool, statuses ...int) *fakeAlertmanager {
+	a := &fakeAlertmanager{statuses: statuses}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/am/api/v2/alerts" || r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		var batch []map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		user, password, _ := r.BasicAuth()
+
+		a.mtx.Lock()
+		defer a.mtx.Unlock()
+		a.batches = append(a.batches, batch)
+		a.auth = append(a.auth, user+":"+password)
+		status := a.statuses[0]
+		if len(a.statuses) > 1 {
+			a.statuses = a.statuses[1:]
+		}
+		w.WriteHeader(status)
+	})
+	if tls {
+		a.Server = httptest.NewTLSServer(handler)
+	} else {
+		a.Server = httptest.NewServer(handler)
+	}
+	t.Cleanup(a.Close)
+	return a
+}
+
+func (a *fakeAlertmanager) received() ([][]map[string]interface{}, []string) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	return append([][]map[string]interface{}{}, a.batches...), append([]string{}, a.auth...)
+}
+
+func externalTestAlert() *Alert {
+	return &Alert{
+		Labels:       labels.FromMap(map[string]string{"alertname": "HighLatency", "severity": "critical"}),
+		Annotations:  labels.FromMap(map[string]string{"summary": "latency is high"}),
+		StartsAt:     time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		GeneratorURL: "http://signoz/alerts",
+		Receivers:    []string{"oncall"},
+	}
+}
+
+func TestExternalAlertmanagersForward(t *testing.T) {
+	down := newFakeAlertmanager(t, false, http.StatusInternalServerError)
+	up := newFakeAlertmanager(t, false, http.StatusOK)
+	other := newFakeAlertmanager(t, false, http.StatusOK)
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("secret\n"), 0600))
+
+	e, err := NewExternalAlertmanagers(ExternalAlertmanagersConfig{
+		Replace: true,
+		Clusters: []ExternalCluster{
+			{Name: "primary", URLs: []string{down.URL + "/am", up.URL + "/am/"}, BasicAuth: &BasicAuth{Username: "signoz", PasswordFile: passwordFile}},
+			{Name: "secondary", URLs: []string{other.URL + "/am"}},
+		},
+	}, 0)
+	require.NoError(t, err)
+	assert.True(t, e.Replace())
+	e.Run()
+	defer e.Stop()
+	e.Send(externalTestAlert())
+
+	// the batch is delivered when one peer of the cluster accepts it
+	require.Eventually(t, func() bool {
+		stats := e.Stats()
+		return stats["primary"].Sent == 1 && stats["secondary"].Sent == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	stats := e.Stats()
+	assert.Equal(t, 0, stats["primary"].ConsecutiveFailures)
+	assert.Equal(t, 10000, stats["primary"].QueueCapacity)
+	assert.Equal(t, 0, stats["primary"].QueueDepth)
+
+	// every peer gets the alerts of the v2 api with the credentials of
+	// the cluster, without the SigNoz channels
+	for _, peer := range []*fakeAlertmanager{down, up} {
+		batches, auth := peer.received()
+		require.Len(t, batches, 1)
+		assert.Equal(t, []string{"signoz:secret"}, auth)
+		require.Len(t, batches[0], 1)
+		alert := batches[0][0]
+		assert.Equal(t, map[string]interface{}{"alertname": "HighLatency", "severity": "critical"}, alert["labels"])
+		assert.Equal(t, "latency is high", alert["annotations"].(map[string]interface{})["summary"])
+		assert.Equal(t, "2024-03-01T12:00:00Z", alert["startsAt"])
+		assert.Equal(t, "http://signoz/alerts", alert["generatorURL"])
+		assert.NotContains(t, alert, "receivers")
+	}
+	_, auth := other.received()
+	assert.Equal(t, []string{":"}, auth)
+}
+
+func TestExternalAlertmanagersRetry(t *testing.T) {
+	flaky := newFakeAlertmanager(t, false, http.StatusServiceUnavailable, http.StatusOK)
+	e, err := NewExternalAlertmanagers(ExternalAlertmanagersConfig{
+		Clusters: []ExternalCluster{{Name: "flaky", URLs: []string{flaky.URL + "/am"}}},
+	}, 0)
+	require.NoError(t, err)
+	assert.False(t, e.Replace())
+
+	var failed []error
+	var mtx sync.Mutex
+	e.notifiers["flaky"].opts.Failed = func(alerts []*Alert, err error) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		failed = append(failed, err)
+	}
+	e.Run()
+	defer e.Stop()
+	e.Send(externalTestAlert())
+
+	// the rejected batch is kept and retried after the backoff
+	require.Eventually(t, func() bool {
+		return e.Stats()["flaky"].ConsecutiveFailures == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	stats := e.Stats()
+	assert.Equal(t, minBackoff, stats["flaky"].Backoff)
+	assert.Contains(t, stats["flaky"].LastError, "bad response status 503 Service Unavailable")
+	assert.Equal(t, 1, stats["flaky"].QueueDepth)
+
+	require.Eventually(t, func() bool {
+		return e.Stats()["flaky"].Sent == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	stats = e.Stats()
+	assert.Equal(t, 0, stats["flaky"].ConsecutiveFailures)
+	assert.Empty(t, stats["flaky"].LastError)
+	assert.Equal(t, 0, stats["flaky"].QueueDepth)
+
+	batches, _ := flaky.received()
+	require.Len(t, batches, 2)
+	assert.Equal(t, batches[0], batches[1])
+	mtx.Lock()
+	defer mtx.Unlock()
+	require.Len(t, failed, 1)
+	assert.Contains(t, failed[0].Error(), "503")
+}
+
+func TestExternalAlertmanagersTLS(t *testing.T) {
+	secure := newFakeAlertmanager(t, true, http.StatusOK)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: secure.Certificate().Raw}), 0600))
+
+	// the certificate of the httptest servers is valid for example.com
+	e, err := NewExternalAlertmanagers(ExternalAlertmanagersConfig{
+		Clusters: []ExternalCluster{{Name: "secure", URLs: []string{secure.URL + "/am"}, TLS: &TLSConfig{CAFile: caFile, ServerName: "example.com"}}},
+	}, 0)
+	require.NoError(t, err)
+	e.Run()
+	defer e.Stop()
+	e.Send(externalTestAlert())
+	require.Eventually(t, func() bool {
+		return e.Stats()["secure"].Sent == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the peers are not trusted without the CA
+	_, err = NewExternalAlertmanagers(ExternalAlertmanagersConfig{
+		Clusters: []ExternalCluster{{Name: "secure", URLs: []string{secure.URL}, TLS: &TLSConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}}},
+	}, 0)
+	assert.ErrorContains(t, err, "invalid tls config of alertmanager cluster secure")
+}
+
+func TestLoadExternalAlertmanagers(t *testing.T) {
+	load := func(config string) (*ExternalAlertmanagers, error) {
+		path := filepath.Join(t.TempDir(), "alertmanagers.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(config), 0600))
+		return LoadExternalAlertmanagers(path, 5)
+	}
+
+	e, err := load(`
+replace: true
+clusters:
+  - name: primary
+    urls: [http://am-0:9093, http://am-1:9093]
+    basicAuth:
+      username: signoz
+      password: secret
+    timeout: 5s
+`)
+	require.NoError(t, err)
+	assert.True(t, e.Replace())
+	assert.Equal(t, 5, e.Stats()["primary"].QueueCapacity)
+	assert.Equal(t, []string{"http://am-0:9093/api/v2/alerts", "http://am-1:9093/api/v2/alerts"}, []string{
+		e.notifiers["primary"].Alertmanagers()[0].String(),
+		e.notifiers["primary"].Alertmanagers()[1].String(),
+	})
+
+	for config, expected := range map[string]string{
+		`clusters: []`:                         "no external alertmanager clusters",
+		`clusters: [{urls: [http://am:9093]}]`: "an alertmanager cluster has no name",
+		`clusters: [{name: primary}]`:          "alertmanager cluster primary has no urls",
+		`clusters: [{name: a, urls: [http://am:9093]}, {name: a, urls: [http://am:9093]}]`:                     "duplicate alertmanager cluster a",
+		`clusters: [{name: a, urls: [http://am:9093], basicAuth: {password: secret}}]`:                         "has basic auth without a username",
+		`clusters: [{name: a, urls: [http://am:9093], tls: {certFile: cert.pem}}]`:                             "needs both a certificate and a key for mTLS",
+		`clusters: [{name: a, urls: [http://am:9093], basicAuth: {username: signoz, passwordFile: /missing}}]`: "failed to read the password of alertmanager cluster a",
+		`clusters: {`: "failed to parse the external alertmanagers",
+	} {
+		_, err := load(config)
+		assert.ErrorContains(t, err, expected, config)
+	}
+	_, err = LoadExternalAlertmanagers(filepath.Join(t.TempDir(), "missing.yaml"), 0)
+	assert.ErrorContains(t, err, "failed to read the external alertmanagers")
+
+	// without clusters the alerts go to the SigNoz channels only
+	var none *ExternalAlertmanagers
+	none.Send(externalTestAlert())
+	assert.False(t, none.Replace())
+	assert.Empty(t, none.Stats())
+}
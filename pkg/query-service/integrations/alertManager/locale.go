@@ -0,0 +1,357 @@
+package alertManager
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultLocale is used for channels that do not specify a locale
+	DefaultLocale = "en"
+
+	// ValueHistoryAnnotation holds the recent values of an alert, oldest
+	// first and comma separated, the rules set it on the alerts they send
+	ValueHistoryAnnotation = "value_history"
+
+	// valuePrecision is the number of decimals of the alert values in
+	// the notifications
+	valuePrecision = 2
+)
+
+// DurationUnits holds the localized suffixes used when rendering
+// durations, e.g. 1h 5m in english
+type DurationUnits struct {
+	Day    string `json:"day"`
+	Hour   string `json:"hour"`
+	Minute string `json:"minute"`
+	Second string `json:"second"`
+}
+
+// Locale holds the words and formatting rules used by the built-in
+// notification templates. A channel picks a locale by name and
+// the alert manager renders state words, durations and numbers
+// according to it.
+type Locale struct {
+	Name string `json:"name"`
+
+	// Builtin is true for the locales shipped with SigNoz,
+	// they can not be edited or deleted
+	Builtin bool `json:"builtin"`
+
+	// States maps alert states (firing, resolved, pending, no_data)
+	// to the localized word
+	States map[string]string `json:"states"`
+
+	DurationUnits DurationUnits `json:"durationUnits"`
+
+	DecimalSeparator   string `json:"decimalSeparator"`
+	ThousandsSeparator string `json:"thousandsSeparator"`
+
+	// TimeFormat is a go time layout
+	TimeFormat string `json:"timeFormat"`
+}
+
+var builtinLocales = map[string]Locale{
+	"en": {
+		Name:    "en",
+		Builtin: true,
+		States: map[string]string{
+			"firing":   "Firing",
+			"resolved": "Resolved",
+			"pending":  "Pending",
+			"no_data":  "No data",
+		},
+		DurationUnits:      DurationUnits{Day: "d", Hour: "h", Minute: "m", Second: "s"},
+		DecimalSeparator:   ".",
+		ThousandsSeparator: ",",
+		TimeFormat:         "2006-01-02 15:04:05 MST",
+	},
+	"de": {
+		Name:    "de",
+		Builtin: true,
+		States: map[string]string{
+			"firing":   "Ausgelöst",
+			"resolved": "Behoben",
+			"pending":  "Ausstehend",
+			"no_data":  "Keine Daten",
+		},
+		DurationUnits:      DurationUnits{Day: "T", Hour: "Std", Minute: "Min", Second: "s"},
+		DecimalSeparator:   ",",
+		ThousandsSeparator: ".",
+		TimeFormat:         "02.01.2006 15:04:05 MST",
+	},
+	"pt-BR": {
+		Name:    "pt-BR",
+		Builtin: true,
+		States: map[string]string{
+			"firing":   "Disparado",
+			"resolved": "Resolvido",
+			"pending":  "Pendente",
+			"no_data":  "Sem dados",
+		},
+		DurationUnits:      DurationUnits{Day: "d", Hour: "h", Minute: "min", Second: "s"},
+		DecimalSeparator:   ",",
+		ThousandsSeparator: ".",
+		TimeFormat:         "02/01/2006 15:04:05 MST",
+	},
+	"zh-CN": {
+		Name:    "zh-CN",
+		Builtin: true,
+		States: map[string]string{
+			"firing":   "告警中",
+			"resolved": "已恢复",
+			"pending":  "待定",
+			"no_data":  "无数据",
+		},
+		DurationUnits:      DurationUnits{Day: "天", Hour: "小时", Minute: "分钟", Second: "秒"},
+		DecimalSeparator:   ".",
+		ThousandsSeparator: ",",
+		TimeFormat:         "2006-01-02 15:04:05 MST",
+	},
+}
+
+// BuiltinLocales returns the locales shipped with SigNoz
+func BuiltinLocales() []Locale {
+	locales := make([]Locale, 0, len(builtinLocales))
+	for _, l := range builtinLocales {
+		locales = append(locales, l)
+	}
+	return locales
+}
+
+// BuiltinLocale returns the built-in locale with the given name
+func BuiltinLocale(name string) (Locale, bool) {
+	l, ok := builtinLocales[name]
+	return l, ok
+}
+
+// LocaleStore looks up the locales by name, the built-in ones as well as
+// the ones created by the users
+type LocaleStore interface {
+	GetLocale(name string) (*Locale, *model.ApiError)
+}
+
+// ResolveLocale returns the locale of a channel. Channels without a
+// locale, or with a locale that was deleted since, use the default one.
+func ResolveLocale(store LocaleStore, name string) *Locale {
+	if name == "" {
+		name = DefaultLocale
+	}
+	if l, ok := builtinLocales[name]; ok {
+		return &l
+	}
+	if store != nil {
+		l, apiErr := store.GetLocale(name)
+		if apiErr == nil {
+			return l
+		}
+		zap.L().Warn("failed to load the locale of the channel, using the default locale", zap.String("locale", name), zap.Error(apiErr.Err))
+	}
+	l := builtinLocales[DefaultLocale]
+	return &l
+}
+
+// orDefault returns the locale, the default one when it is nil
+func (l *Locale) orDefault() *Locale {
+	if l != nil {
+		return l
+	}
+	d := builtinLocales[DefaultLocale]
+	return &d
+}
+
+// Validate checks that the locale can be used for rendering. Missing
+// words fall back to the default locale so only the name and separators
+// are mandatory.
+func (l *Locale) Validate() error {
+	if l.Name == "" {
+		return fmt.Errorf("locale name is required")
+	}
+	if _, ok := builtinLocales[l.Name]; ok {
+		return fmt.Errorf("locale %s is a built-in locale and can not be changed", l.Name)
+	}
+	if l.DecimalSeparator == "" {
+		return fmt.Errorf("decimal separator is required")
+	}
+	if l.DecimalSeparator == l.ThousandsSeparator {
+		return fmt.Errorf("decimal and thousands separators must be different")
+	}
+	return nil
+}
+
+// StateWord returns the localized word for the given alert state
+func (l *Locale) StateWord(state string) string {
+	if w, ok := l.States[state]; ok && w != "" {
+		return w
+	}
+	if w, ok := builtinLocales[DefaultLocale].States[state]; ok {
+		return w
+	}
+	return state
+}
+
+// FormatNumber formats the value with the given precision using the
+// locale separators
+func (l *Locale) FormatNumber(v float64, precision int) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	s := strconv.FormatFloat(math.Abs(v), 'f', precision, 64)
+	intPart, fracPart, _ := strings.Cut(s, ".")
+
+	var b strings.Builder
+	if v < 0 {
+		b.WriteString("-")
+	}
+	for i, c := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(l.ThousandsSeparator)
+		}
+		b.WriteRune(c)
+	}
+	if fracPart != "" {
+		b.WriteString(l.DecimalSeparator)
+		b.WriteString(fracPart)
+	}
+	return b.String()
+}
+
+// FormatDuration renders the duration with the localized units,
+// e.g. 1d 2h 5m, seconds are only included for durations under an hour
+func (l *Locale) FormatDuration(d time.Duration) string {
+	units := l.DurationUnits
+	if units.Second == "" {
+		units = builtinLocales[DefaultLocale].DurationUnits
+	}
+
+	if d < time.Second {
+		return fmt.Sprintf("0%s", units.Second)
+	}
+
+	days := int64(d / (24 * time.Hour))
+	hours := int64(d/time.Hour) % 24
+	minutes := int64(d/time.Minute) % 60
+	seconds := int64(d/time.Second) % 60
+
+	parts := []string{}
+	if days > 0 {
+		parts = append(parts, fmt.Sprintf("%d%s", days, units.Day))
+	}
+	if hours > 0 {
+		parts = append(parts, fmt.Sprintf("%d%s", hours, units.Hour))
+	}
+	if minutes > 0 {
+		parts = append(parts, fmt.Sprintf("%d%s", minutes, units.Minute))
+	}
+	if seconds > 0 && days == 0 && hours == 0 {
+		parts = append(parts, fmt.Sprintf("%d%s", seconds, units.Second))
+	}
+	return strings.Join(parts, " ")
+}
+
+// FormatTime renders the time in the given timezone using the
+// locale time layout. Invalid timezones fall back to UTC.
+func (l *Locale) FormatTime(t time.Time, timezone string) string {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil || timezone == "" {
+		loc = time.UTC
+	}
+	layout := l.TimeFormat
+	if layout == "" {
+		layout = builtinLocales[DefaultLocale].TimeFormat
+	}
+	return t.In(loc).Format(layout)
+}
+
+// alertStatus returns the state word of the alert in upper case, as in
+// the titles of the notifications
+func (l *Locale) alertStatus(a *Alert) string {
+	if a.Resolved() {
+		return strings.ToUpper(l.StateWord("resolved"))
+	}
+	return strings.ToUpper(l.StateWord("firing"))
+}
+
+// alertDetails returns when the alert started firing, for how long it
+// fired once resolved and its latest value, empty when none is known
+func (l *Locale) alertDetails(a *Alert, timezone string) string {
+	var parts []string
+	if !a.StartsAt.IsZero() {
+		parts = append(parts, l.FormatTime(a.StartsAt, timezone))
+		if a.Resolved() && a.EndsAt.After(a.StartsAt) {
+			parts = append(parts, l.FormatDuration(a.EndsAt.Sub(a.StartsAt)))
+		}
+	}
+	if v, ok := latestValue(a); ok {
+		parts = append(parts, l.FormatNumber(v, valuePrecision))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// latestValue returns the last value of the value history of the alert
+func latestValue(a *Alert) (float64, bool) {
+	history := a.Annotations.Get(ValueHistoryAnnotation)
+	if history == "" {
+		return 0, false
+	}
+	values := strings.Split(history, ",")
+	v, err := strconv.ParseFloat(strings.TrimSpace(values[len(values)-1]), 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// titleTemplate is the default title of the alert manager integrations
+// with the state words of the locale
+func (l *Locale) titleTemplate() string {
+	return fmt.Sprintf(`[{{ if eq .Status "firing" }}{{ %q }}:{{ .Alerts.Firing | len }}{{ else }}{{ %q }}{{ end }}] {{ .GroupLabels.SortedPairs.Values | join " " }}`,
+		strings.ToUpper(l.StateWord("firing")), strings.ToUpper(l.StateWord("resolved")))
+}
+
+// withLocaleTitle sets the localized title on the slack and msteams
+// configs and the localized subject on the email configs that do not
+// define their own
+func withLocaleTitle(key string, configs interface{}, title string) interface{} {
+	list, ok := configs.([]interface{})
+	if !ok {
+		return configs
+	}
+	result := make([]interface{}, 0, len(list))
+	for _, item := range list {
+		cfg, ok := item.(map[string]interface{})
+		if !ok {
+			result = append(result, item)
+			continue
+		}
+		copied := make(map[string]interface{}, len(cfg)+1)
+		for k, v := range cfg {
+			copied[k] = v
+		}
+		switch key {
+		case "slack_configs", "msteams_configs":
+			if v, _ := copied["title"].(string); v == "" {
+				copied["title"] = title
+			}
+		case "email_configs":
+			headers := map[string]interface{}{}
+			if h, ok := copied["headers"].(map[string]interface{}); ok {
+				for k, v := range h {
+					headers[k] = v
+				}
+			}
+			if v, _ := headers["Subject"].(string); v == "" {
+				headers["Subject"] = title
+			}
+			copied["headers"] = headers
+		}
+		result = append(result, copied)
+	}
+	return result
+}
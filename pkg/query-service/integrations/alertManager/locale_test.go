@@ -0,0 +1,141 @@
+package alertManager
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+type fakeLocaleStore map[string]*Locale
+
+func (s fakeLocaleStore) GetLocale(name string) (*Locale, *model.ApiError) {
+	if l, ok := s[name]; ok {
+		return l, nil
+	}
+	return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("locale %s not found", name)}
+}
+
+func TestLocaleStateWord(t *testing.T) {
+	de, _ := BuiltinLocale("de")
+	assert.Equal(t, "Ausgelöst", de.StateWord("firing"))
+	assert.Equal(t, "Behoben", de.StateWord("resolved"))
+
+	// missing words fall back to the default locale, unknown states
+	// are left as is
+	custom := Locale{Name: "custom", States: map[string]string{"firing": "En cours"}}
+	assert.Equal(t, "En cours", custom.StateWord("firing"))
+	assert.Equal(t, "Resolved", custom.StateWord("resolved"))
+	assert.Equal(t, "unknown", custom.StateWord("unknown"))
+}
+
+func TestLocaleFormatNumber(t *testing.T) {
+	en, _ := BuiltinLocale("en")
+	de, _ := BuiltinLocale("de")
+	for _, tc := range []struct {
+		locale    Locale
+		value     float64
+		precision int
+		expected  string
+	}{
+		{locale: en, value: 1234567.891, precision: 2, expected: "1,234,567.89"},
+		{locale: de, value: 1234567.891, precision: 2, expected: "1.234.567,89"},
+		{locale: de, value: -1234.5, precision: 1, expected: "-1.234,5"},
+		{locale: en, value: 999, precision: 0, expected: "999"},
+		{locale: en, value: 0.5, precision: 2, expected: "0.50"},
+		{locale: de, value: math.NaN(), precision: 2, expected: "NaN"},
+		{locale: de, value: math.Inf(1), precision: 2, expected: "+Inf"},
+	} {
+		assert.Equal(t, tc.expected, tc.locale.FormatNumber(tc.value, tc.precision))
+	}
+}
+
+func TestLocaleFormatDuration(t *testing.T) {
+	en, _ := BuiltinLocale("en")
+	de, _ := BuiltinLocale("de")
+	assert.Equal(t, "0s", en.FormatDuration(500*time.Millisecond))
+	assert.Equal(t, "5m 30s", en.FormatDuration(5*time.Minute+30*time.Second))
+	// seconds are left out from an hour on
+	assert.Equal(t, "1h 5m", en.FormatDuration(time.Hour+5*time.Minute+30*time.Second))
+	assert.Equal(t, "2T 3Std", de.FormatDuration(51*time.Hour))
+
+	custom := Locale{Name: "custom"}
+	assert.Equal(t, "1h", custom.FormatDuration(time.Hour))
+}
+
+func TestLocaleFormatTime(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	en, _ := BuiltinLocale("en")
+	de, _ := BuiltinLocale("de")
+	assert.Equal(t, "2024-03-01 12:00:00 UTC", en.FormatTime(ts, ""))
+	assert.Equal(t, "01.03.2024 13:00:00 CET", de.FormatTime(ts, "Europe/Berlin"))
+	assert.Equal(t, "2024-03-01 12:00:00 UTC", en.FormatTime(ts, "Not/AZone"))
+}
+
+func TestResolveLocale(t *testing.T) {
+	store := fakeLocaleStore{"fr": {Name: "fr", DecimalSeparator: ","}}
+	assert.Equal(t, "de", ResolveLocale(store, "de").Name)
+	assert.Equal(t, "fr", ResolveLocale(store, "fr").Name)
+	assert.Equal(t, DefaultLocale, ResolveLocale(store, "").Name)
+	// deleted locales and missing stores use the default locale
+	assert.Equal(t, DefaultLocale, ResolveLocale(store, "it").Name)
+	assert.Equal(t, DefaultLocale, ResolveLocale(nil, "fr").Name)
+}
+
+func TestLocalizedNotifications(t *testing.T) {
+	startsAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	alert := &Alert{
+		Labels: labels.FromMap(map[string]string{"alertname": "HighLatency", "severity": "critical"}),
+		Annotations: labels.FromMap(map[string]string{
+			"summary":              "latency is high",
+			ValueHistoryAnnotation: "1.5,1234.567",
+		}),
+		StartsAt:     startsAt,
+		EndsAt:       startsAt.Add(time.Hour + 5*time.Minute),
+		GeneratorURL: "http://signoz/alerts",
+	}
+	de := ResolveLocale(nil, "de")
+
+	assert.Equal(t,
+		"[BEHOBEN] HighLatency (critical): latency is high (01.03.2024 13:00:00 CET, 1Std 5Min, 1.234,57) http://signoz/alerts",
+		NewChatText(alert, de, "Europe/Berlin"))
+	// without a locale the default one is used
+	assert.Equal(t,
+		"[RESOLVED] HighLatency (critical): latency is high (2024-03-01 12:00:00 UTC, 1h 5m, 1,234.57) http://signoz/alerts",
+		NewChatText(alert, nil, ""))
+
+	msg := NewPushMessage(&PushConfig{}, alert, de, "Europe/Berlin")
+	assert.Equal(t, "[BEHOBEN] HighLatency", msg.Title)
+	assert.Equal(t, "latency is high\n01.03.2024 13:00:00 CET, 1Std 5Min, 1.234,57", msg.Body)
+	assert.Equal(t, "resolved", msg.Data["status"])
+
+	receiver := &Receiver{
+		Name:         "oncall",
+		Timezone:     "Europe/Berlin",
+		SlackConfigs: []interface{}{map[string]interface{}{"channel": "#alerts"}},
+		EmailConfigs: []interface{}{map[string]interface{}{"to": "oncall@example.com"}},
+		IRCConfigs:   []IRCConfig{{Server: "irc.example.com:6697", Nick: "signoz", Targets: []string{"#alerts"}}},
+	}
+	receiver.SetLocale(de)
+	firing := *alert
+	firing.EndsAt = time.Time{}
+	previews := PreviewNotifications(receiver, []*Alert{&firing}, "http://signoz", startsAt)
+	require.Len(t, previews, 3)
+	assert.Contains(t, previews[0].Body, `"text": "[AUSGELÖST:1] HighLatency"`)
+	assert.Equal(t, "[AUSGELÖST:1] HighLatency", previews[1].Subject)
+	assert.Equal(t,
+		"[AUSGELÖST] HighLatency (critical): latency is high (01.03.2024 13:00:00 CET, 1.234,57) http://signoz/alerts",
+		previews[2].Body)
+
+	// the templates of the channel are kept
+	custom := withLocaleTitle("slack_configs", []interface{}{map[string]interface{}{"title": "custom"}}, de.titleTemplate())
+	assert.Equal(t, "custom", custom.([]interface{})[0].(map[string]interface{})["title"])
+	// the default locale keeps the templates of the alert manager
+	receiver.SetLocale(ResolveLocale(nil, DefaultLocale))
+	assert.NotContains(t, receiver.forAlertManager().SlackConfigs.([]interface{})[0], "title")
+}
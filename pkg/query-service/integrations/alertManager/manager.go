@@ -77,7 +77,7 @@ func (m *manager) URLPath(path string) *neturl.URL {
 
 func (m *manager) AddRoute(receiver *Receiver) *model.ApiError {
 
-	receiverString, _ := json.Marshal(receiver)
+	receiverString, _ := json.Marshal(receiver.forAlertManager())
 
 	amURL := prepareAmChannelApiURL()
 	response, err := http.Post(amURL, contentType, bytes.NewBuffer(receiverString))
@@ -95,7 +95,7 @@ func (m *manager) AddRoute(receiver *Receiver) *model.ApiError {
 }
 
 func (m *manager) EditRoute(receiver *Receiver) *model.ApiError {
-	receiverString, _ := json.Marshal(receiver)
+	receiverString, _ := json.Marshal(receiver.forAlertManager())
 
 	amURL := prepareAmChannelApiURL()
 	req, err := http.NewRequest(http.MethodPut, amURL, bytes.NewBuffer(receiverString))
@@ -154,7 +154,7 @@ func (m *manager) DeleteRoute(name string) *model.ApiError {
 
 func (m *manager) TestReceiver(receiver *Receiver) *model.ApiError {
 
-	receiverBytes, _ := json.Marshal(receiver)
+	receiverBytes, _ := json.Marshal(receiver.forAlertManager())
 
 	amTestURL := prepareTestApiURL()
 	response, err := http.Post(amTestURL, contentType, bytes.NewBuffer(receiverBytes))
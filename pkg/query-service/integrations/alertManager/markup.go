@@ -0,0 +1,335 @@
+package alertManager
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// Markup formats the markdown annotations are rendered to
+const (
+	MarkupSlack   = "slack"
+	MarkupMSTeams = "msteams"
+	MarkupHTML    = "html"
+	MarkupPlain   = "plain"
+)
+
+var markupFormats = []string{MarkupSlack, MarkupMSTeams, MarkupHTML, MarkupPlain}
+
+// markdownAnnotations are the annotations written in markdown by the
+// users, they are sent with a rendered copy for every markup format
+// named <annotation>_<format>, e.g. description_slack
+var markdownAnnotations = []string{"summary", "description"}
+
+// inlineMarkdownRe matches, in order of precedence, code spans, links,
+// bold, strikethrough and italic text
+var inlineMarkdownRe = regexp.MustCompile("`([^`]+)`|\\[([^\\]]+)\\]\\(([^)\\s]+)\\)|\\*\\*(.+?)\\*\\*|~~(.+?)~~|\\*([^*\\s][^*]*?)\\*|_([^_\\s][^_]*?)_")
+
+var headingRe = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+
+var listItemRe = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+
+// linkSchemeRe matches the urls rendered as links
+var linkSchemeRe = regexp.MustCompile(`(?i)^(https?://|mailto:)`)
+
+// RenderMarkdown renders the markdown subset supported in annotations,
+// headings, lists, code blocks, links, bold, italic, strikethrough and
+// code spans, to the markup of the format. Text is escaped as required
+// by the format so users do not have to write channel specific syntax.
+func RenderMarkdown(src string, format string) string {
+	var out []string
+	// tight marks the lines joined to the previous one with a single line
+	// break in teams, i.e. the lines of code blocks
+	var tight []bool
+	inCode := false
+	inList := false
+
+	add := func(line string, joined bool) {
+		out = append(out, line)
+		tight = append(tight, joined)
+	}
+
+	closeList := func() {
+		if inList && format == MarkupHTML {
+			add("</ul>", false)
+		}
+		inList = false
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			closeList()
+			add(codeFence(format, !inCode), inCode)
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			add(escapeMarkup(line, format), true)
+			continue
+		}
+
+		if m := listItemRe.FindStringSubmatch(line); m != nil {
+			if !inList && format == MarkupHTML {
+				add("<ul>", false)
+			}
+			inList = true
+			add(listItem(renderInline(m[1], format), format), false)
+			continue
+		}
+		closeList()
+
+		if m := headingRe.FindStringSubmatch(line); m != nil {
+			add(heading(renderInline(m[2], format), len(m[1]), format), false)
+			continue
+		}
+
+		rendered := renderInline(line, format)
+		if format == MarkupHTML && strings.TrimSpace(line) != "" {
+			rendered += "<br>"
+		}
+		add(rendered, false)
+	}
+	closeList()
+	if inCode {
+		add(codeFence(format, false), true)
+	}
+
+	var b strings.Builder
+	for i, line := range out {
+		if i > 0 {
+			b.WriteString("\n")
+			// teams collapses single line breaks outside of code blocks
+			if format == MarkupMSTeams && !tight[i] {
+				b.WriteString("\n")
+			}
+		}
+		b.WriteString(line)
+	}
+	result := b.String()
+	if format == MarkupHTML {
+		result = strings.TrimSuffix(result, "<br>")
+	}
+	return result
+}
+
+func renderInline(text string, format string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range inlineMarkdownRe.FindAllStringSubmatchIndex(text, -1) {
+		start, end := m[0], m[1]
+		group := func(i int) string {
+			if m[2*i] < 0 {
+				return ""
+			}
+			return text[m[2*i]:m[2*i+1]]
+		}
+
+		// underscores inside words, e.g. snake_case labels, are not emphasis
+		if m[14] >= 0 && (start > 0 && isWordByte(text[start-1]) || end < len(text) && isWordByte(text[end])) {
+			continue
+		}
+
+		b.WriteString(escapeMarkup(text[last:start], format))
+		switch {
+		case m[2] >= 0:
+			b.WriteString(inlineCode(group(1), format))
+		case m[4] >= 0:
+			b.WriteString(link(group(2), group(3), format))
+		case m[8] >= 0:
+			b.WriteString(wrap(renderInline(group(4), format), format, "*", "**", "strong"))
+		case m[10] >= 0:
+			b.WriteString(wrap(renderInline(group(5), format), format, "~", "~~", "del"))
+		case m[12] >= 0:
+			b.WriteString(wrap(renderInline(group(6), format), format, "_", "_", "em"))
+		default:
+			b.WriteString(wrap(renderInline(group(7), format), format, "_", "_", "em"))
+		}
+		last = end
+	}
+	b.WriteString(escapeMarkup(text[last:], format))
+	return b.String()
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+// wrap surrounds already rendered text with the markers of the format
+func wrap(text string, format string, slack string, teams string, tag string) string {
+	switch format {
+	case MarkupSlack:
+		return slack + text + slack
+	case MarkupMSTeams:
+		return teams + text + teams
+	case MarkupHTML:
+		return fmt.Sprintf("<%s>%s</%s>", tag, text, tag)
+	default:
+		return text
+	}
+}
+
+func inlineCode(code string, format string) string {
+	switch format {
+	case MarkupSlack, MarkupMSTeams:
+		return "`" + escapeMarkup(code, format) + "`"
+	case MarkupHTML:
+		return "<code>" + html.EscapeString(code) + "</code>"
+	default:
+		return code
+	}
+}
+
+func link(text string, url string, format string) string {
+	// the links to other schemes, e.g. javascript:, are not clickable
+	if !linkSchemeRe.MatchString(url) {
+		return fmt.Sprintf("%s (%s)", escapeMarkup(text, format), escapeMarkup(url, format))
+	}
+	switch format {
+	case MarkupSlack:
+		return fmt.Sprintf("<%s|%s>", escapeMarkup(url, format), escapeMarkup(text, format))
+	case MarkupMSTeams:
+		return fmt.Sprintf("[%s](%s)", escapeMarkup(text, format), url)
+	case MarkupHTML:
+		return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(url), renderInline(text, format))
+	default:
+		return fmt.Sprintf("%s (%s)", text, url)
+	}
+}
+
+func heading(text string, level int, format string) string {
+	switch format {
+	case MarkupSlack:
+		return "*" + text + "*"
+	case MarkupMSTeams:
+		return "**" + text + "**"
+	case MarkupHTML:
+		if level > 3 {
+			level = 3
+		}
+		return fmt.Sprintf("<h%d>%s</h%d>", level, text, level)
+	default:
+		return text
+	}
+}
+
+func listItem(text string, format string) string {
+	switch format {
+	case MarkupSlack:
+		return "• " + text
+	case MarkupHTML:
+		return "<li>" + text + "</li>"
+	default:
+		return "- " + text
+	}
+}
+
+func codeFence(format string, open bool) string {
+	switch format {
+	case MarkupSlack, MarkupMSTeams:
+		return "```"
+	case MarkupHTML:
+		if open {
+			return "<pre><code>"
+		}
+		return "</code></pre>"
+	default:
+		return ""
+	}
+}
+
+// escapeMarkup escapes the characters with a special meaning in the format
+func escapeMarkup(text string, format string) string {
+	switch format {
+	case MarkupSlack:
+		return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+	case MarkupMSTeams, MarkupHTML:
+		return html.EscapeString(text)
+	default:
+		return text
+	}
+}
+
+// withRenderedMarkdown returns the annotations with the markdown
+// annotations rendered for every format. Copies that are identical to the
+// source are left out, templates fall back to the source annotation.
+func withRenderedMarkdown(annotations labels.BaseLabels) labels.BaseLabels {
+	if annotations == nil {
+		return annotations
+	}
+	values := annotations.Map()
+	rendered := map[string]string{}
+	for _, name := range markdownAnnotations {
+		src, ok := values[name]
+		if !ok || src == "" {
+			continue
+		}
+		for _, format := range markupFormats {
+			if out := RenderMarkdown(src, format); out != src {
+				rendered[name+"_"+format] = out
+			}
+		}
+	}
+	if len(rendered) == 0 {
+		return annotations
+	}
+	for k, v := range values {
+		if _, ok := rendered[k]; !ok {
+			rendered[k] = v
+		}
+	}
+	return labels.FromMap(rendered)
+}
+
+// markdownTemplates are the notification templates used for the channels
+// with markdown enabled, by integration and template field
+var markdownTemplates = map[string]map[string]string{
+	"slack_configs": {
+		"text": `{{ range .Alerts }}{{ or .Annotations.description_slack .Annotations.description }}
+{{ end }}`,
+	},
+	"msteams_configs": {
+		"text": `{{ range .Alerts }}{{ or .Annotations.description_msteams .Annotations.description }}
+
+{{ end }}`,
+	},
+	"email_configs": {
+		"html": `{{ range .Alerts }}<p>{{ or .Annotations.description_html .Annotations.description }}</p>{{ end }}`,
+	},
+	"sns_configs": {
+		"message": `{{ range .Alerts }}{{ or .Annotations.summary_plain .Annotations.summary }}
+{{ end }}`,
+	},
+}
+
+// withMarkdownTemplates sets the markdown templates on the configs that
+// do not define their own template for the field
+func withMarkdownTemplates(key string, configs interface{}) interface{} {
+	templates, ok := markdownTemplates[key]
+	list, isList := configs.([]interface{})
+	if !ok || !isList {
+		return configs
+	}
+	result := make([]interface{}, 0, len(list))
+	for _, item := range list {
+		cfg, ok := item.(map[string]interface{})
+		if !ok {
+			result = append(result, item)
+			continue
+		}
+		copied := make(map[string]interface{}, len(cfg)+len(templates))
+		for k, v := range cfg {
+			copied[k] = v
+		}
+		for field, tmpl := range templates {
+			if v, ok := copied[field].(string); !ok || v == "" {
+				copied[field] = tmpl
+			}
+		}
+		result = append(result, copied)
+	}
+	return result
+}
@@ -0,0 +1,126 @@
+package alertManager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		src  string
+		want map[string]string
+	}{
+		{
+			name: "escaping",
+			src:  "latency > 500ms & errors < 1%",
+			want: map[string]string{
+				MarkupSlack:   "latency &gt; 500ms &amp; errors &lt; 1%",
+				MarkupMSTeams: "latency &gt; 500ms &amp; errors &lt; 1%",
+				MarkupHTML:    "latency &gt; 500ms &amp; errors &lt; 1%",
+				MarkupPlain:   "latency > 500ms & errors < 1%",
+			},
+		},
+		{
+			name: "emphasis and code",
+			src:  "load is 5 * 3 and **very** high, see `a<b*c*`",
+			want: map[string]string{
+				MarkupSlack:   "load is 5 * 3 and *very* high, see `a&lt;b*c*`",
+				MarkupMSTeams: "load is 5 * 3 and **very** high, see `a&lt;b*c*`",
+				MarkupHTML:    "load is 5 * 3 and <strong>very</strong> high, see <code>a&lt;b*c*</code>",
+				MarkupPlain:   "load is 5 * 3 and very high, see a<b*c*",
+			},
+		},
+		{
+			name: "underscores in words",
+			src:  "the snake_case_label is _down_ ~~up~~",
+			want: map[string]string{
+				MarkupSlack:   "the snake_case_label is _down_ ~up~",
+				MarkupMSTeams: "the snake_case_label is _down_ ~~up~~",
+				MarkupHTML:    "the snake_case_label is <em>down</em> <del>up</del>",
+				MarkupPlain:   "the snake_case_label is down up",
+			},
+		},
+		{
+			name: "links",
+			src:  "[runbook <1>](https://runbooks.example.com/a?b=1&c=2)",
+			want: map[string]string{
+				MarkupSlack:   "<https://runbooks.example.com/a?b=1&amp;c=2|runbook &lt;1&gt;>",
+				MarkupMSTeams: "[runbook &lt;1&gt;](https://runbooks.example.com/a?b=1&c=2)",
+				MarkupHTML:    `<a href="https://runbooks.example.com/a?b=1&amp;c=2">runbook &lt;1&gt;</a>`,
+				MarkupPlain:   "runbook <1> (https://runbooks.example.com/a?b=1&c=2)",
+			},
+		},
+		{
+			name: "links to other schemes",
+			src:  "[click](javascript:alert(document.cookie))",
+			want: map[string]string{
+				MarkupSlack:   "click (javascript:alert(document.cookie))",
+				MarkupMSTeams: "click (javascript:alert(document.cookie))",
+				MarkupHTML:    "click (javascript:alert(document.cookie))",
+				MarkupPlain:   "click (javascript:alert(document.cookie))",
+			},
+		},
+		{
+			name: "html injection",
+			src:  `<script>alert(1)</script> <img src=x onerror="alert(1)">`,
+			want: map[string]string{
+				MarkupSlack:   `&lt;script&gt;alert(1)&lt;/script&gt; &lt;img src=x onerror="alert(1)"&gt;`,
+				MarkupMSTeams: "&lt;script&gt;alert(1)&lt;/script&gt; &lt;img src=x onerror=&#34;alert(1)&#34;&gt;",
+				MarkupHTML:    "&lt;script&gt;alert(1)&lt;/script&gt; &lt;img src=x onerror=&#34;alert(1)&#34;&gt;",
+				MarkupPlain:   `<script>alert(1)</script> <img src=x onerror="alert(1)">`,
+			},
+		},
+		{
+			name: "blocks",
+			src:  "# Checkout\n- p99 **high**\n- errors\n```\n<b>&\n```",
+			want: map[string]string{
+				MarkupSlack:   "*Checkout*\n• p99 *high*\n• errors\n```\n&lt;b&gt;&amp;\n```",
+				MarkupMSTeams: "**Checkout**\n\n- p99 **high**\n\n- errors\n\n```\n&lt;b&gt;&amp;\n```",
+				MarkupHTML:    "<h1>Checkout</h1>\n<ul>\n<li>p99 <strong>high</strong></li>\n<li>errors</li>\n</ul>\n<pre><code>\n&lt;b&gt;&amp;\n</code></pre>",
+				MarkupPlain:   "Checkout\n- p99 high\n- errors\n\n<b>&\n",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for format, want := range tc.want {
+				assert.Equal(t, want, RenderMarkdown(tc.src, format), format)
+			}
+		})
+	}
+}
+
+func TestWithRenderedMarkdown(t *testing.T) {
+	annotations := withRenderedMarkdown(labels.FromMap(map[string]string{
+		"summary":     "disk full",
+		"description": "**disk** <b>full</b>",
+		"runbook":     "**not rendered**",
+	}))
+	assert.Equal(t, map[string]string{
+		"summary":             "disk full",
+		"description":         "**disk** <b>full</b>",
+		"description_slack":   "*disk* &lt;b&gt;full&lt;/b&gt;",
+		"description_msteams": "**disk** &lt;b&gt;full&lt;/b&gt;",
+		"description_html":    "<strong>disk</strong> &lt;b&gt;full&lt;/b&gt;",
+		"description_plain":   "disk <b>full</b>",
+		"runbook":             "**not rendered**",
+	}, annotations.Map())
+
+	assert.Nil(t, withRenderedMarkdown(nil))
+}
+
+func TestWithMarkdownTemplates(t *testing.T) {
+	configs := []interface{}{
+		map[string]interface{}{"channel": "#alerts"},
+		map[string]interface{}{"channel": "#oncall", "text": "custom"},
+	}
+	result := withMarkdownTemplates("slack_configs", configs).([]interface{})
+	assert.Equal(t, markdownTemplates["slack_configs"]["text"], result[0].(map[string]interface{})["text"])
+	assert.Equal(t, "custom", result[1].(map[string]interface{})["text"])
+	// the configs of the channel are not changed
+	assert.NotContains(t, configs[0].(map[string]interface{}), "text")
+
+	assert.Equal(t, configs, withMarkdownTemplates("webhook_configs", configs))
+}
@@ -22,6 +22,137 @@ type Receiver struct {
 	VictorOpsConfigs interface{} `yaml:"victorops_configs,omitempty" json:"victorops_configs,omitempty"`
 	SNSConfigs       interface{} `yaml:"sns_configs,omitempty" json:"sns_configs,omitempty"`
 	MSTeamsConfigs   interface{} `yaml:"msteams_configs,omitempty" json:"msteams_configs,omitempty"`
+
+	// PushConfigs are delivered by query-service itself, the alert
+	// manager does not know about them
+	PushConfigs []PushConfig `yaml:"push_configs,omitempty" json:"push_configs,omitempty"`
+	// IRCConfigs and XMPPConfigs are delivered by query-service as well,
+	// over connections kept open between the alerts
+	IRCConfigs  []IRCConfig  `yaml:"irc_configs,omitempty" json:"irc_configs,omitempty"`
+	XMPPConfigs []XMPPConfig `yaml:"xmpp_configs,omitempty" json:"xmpp_configs,omitempty"`
+
+	// Locale and Timezone control how the built-in templates render
+	// state words, durations, numbers and timestamps for this channel
+	Locale   string `yaml:"locale,omitempty" json:"locale,omitempty"`
+	Timezone string `yaml:"timezone,omitempty" json:"timezone,omitempty"`
+
+	// Shared makes the channel selectable by the rules of other orgs
+	Shared bool `yaml:"shared,omitempty" json:"shared,omitempty"`
+	// Team owns the channel, only its members can change the channel
+	Team string `yaml:"team,omitempty" json:"team,omitempty"`
+
+	// HTTPConfig sets the proxy and CA bundle used by all the
+	// integrations of the channel
+	HTTPConfig *HTTPConfig `yaml:"http_config,omitempty" json:"http_config,omitempty"`
+
+	// Markdown renders the markdown summary and description of the
+	// alerts in the markup of each integration, integrations with their
+	// own templates are left as is
+	Markdown bool `yaml:"markdown,omitempty" json:"markdown,omitempty"`
+
+	// locale is the resolved Locale, the built-in templates of the
+	// integrations use the default locale when it is not set
+	locale *Locale
+}
+
+// SetLocale sets the locale the notifications of the receiver are
+// rendered with, see ResolveLocale
+func (r *Receiver) SetLocale(l *Locale) {
+	r.locale = l
+}
+
+// Validate checks the query-service specific settings of the receiver
+// and that its destinations are allowed by the egress policy
+func (r *Receiver) Validate(policy *EgressPolicy) error {
+	for _, cfg := range r.PushConfigs {
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, cfg := range r.IRCConfigs {
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, cfg := range r.XMPPConfigs {
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+	}
+	if r.HTTPConfig != nil {
+		if err := r.HTTPConfig.Validate(); err != nil {
+			return err
+		}
+	}
+	return policy.CheckReceiver(r)
+}
+
+// forAlertManager returns a copy of the receiver without the
+// integrations handled by query-service
+func (r *Receiver) forAlertManager() *Receiver {
+	c := *r
+	c.PushConfigs = nil
+	c.IRCConfigs = nil
+	c.XMPPConfigs = nil
+	c.Shared = false
+	c.Team = ""
+	c.HTTPConfig = nil
+	c.Markdown = false
+
+	if r.HTTPConfig != nil {
+		httpConfig := r.HTTPConfig.alertManagerConfig()
+		c.EmailConfigs = withHTTPConfig(c.EmailConfigs, httpConfig)
+		c.PagerdutyConfigs = withHTTPConfig(c.PagerdutyConfigs, httpConfig)
+		c.SlackConfigs = withHTTPConfig(c.SlackConfigs, httpConfig)
+		c.WebhookConfigs = withHTTPConfig(c.WebhookConfigs, httpConfig)
+		c.OpsGenieConfigs = withHTTPConfig(c.OpsGenieConfigs, httpConfig)
+		c.WechatConfigs = withHTTPConfig(c.WechatConfigs, httpConfig)
+		c.PushoverConfigs = withHTTPConfig(c.PushoverConfigs, httpConfig)
+		c.VictorOpsConfigs = withHTTPConfig(c.VictorOpsConfigs, httpConfig)
+		c.SNSConfigs = withHTTPConfig(c.SNSConfigs, httpConfig)
+		c.MSTeamsConfigs = withHTTPConfig(c.MSTeamsConfigs, httpConfig)
+	}
+	if r.locale != nil && r.locale.Name != DefaultLocale {
+		title := r.locale.titleTemplate()
+		c.SlackConfigs = withLocaleTitle("slack_configs", c.SlackConfigs, title)
+		c.MSTeamsConfigs = withLocaleTitle("msteams_configs", c.MSTeamsConfigs, title)
+		c.EmailConfigs = withLocaleTitle("email_configs", c.EmailConfigs, title)
+	}
+	if r.Markdown {
+		c.SlackConfigs = withMarkdownTemplates("slack_configs", c.SlackConfigs)
+		c.MSTeamsConfigs = withMarkdownTemplates("msteams_configs", c.MSTeamsConfigs)
+		c.EmailConfigs = withMarkdownTemplates("email_configs", c.EmailConfigs)
+		c.SNSConfigs = withMarkdownTemplates("sns_configs", c.SNSConfigs)
+	}
+	return &c
+}
+
+// withHTTPConfig sets the http_config on every integration config that
+// does not define its own. Email has no http client and is left as is.
+func withHTTPConfig(configs interface{}, httpConfig map[string]interface{}) interface{} {
+	list, ok := configs.([]interface{})
+	if !ok || len(httpConfig) == 0 {
+		return configs
+	}
+	result := make([]interface{}, 0, len(list))
+	for _, item := range list {
+		cfg, ok := item.(map[string]interface{})
+		if !ok {
+			result = append(result, item)
+			continue
+		}
+		copied := make(map[string]interface{}, len(cfg)+1)
+		for k, v := range cfg {
+			copied[k] = v
+		}
+		if _, ok := copied["http_config"]; !ok {
+			if _, isEmail := copied["smarthost"]; !isEmail {
+				copied["http_config"] = httpConfig
+			}
+		}
+		result = append(result, copied)
+	}
+	return result
 }
 
 type ReceiverResponse struct {
@@ -5,6 +5,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"sync/atomic"
 
 	"net/http"
@@ -17,6 +19,10 @@ import (
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"golang.org/x/net/context/ctxhttp"
 )
@@ -26,6 +32,8 @@ const (
 	contentTypeJSON   = "application/json"
 )
 
+var tracer = otel.Tracer("go.signoz.io/signoz/pkg/query-service/integrations/alertManager")
+
 // Notifier is responsible for dispatching alert notifications to an
 // alert manager service.
 type Notifier struct {
@@ -39,6 +47,39 @@ type Notifier struct {
 
 	alertmanagers *alertmanagerSet
 	logger        log.Logger
+
+	// stats are guarded by mtx
+	stats NotifierStats
+}
+
+const (
+	// DropQueueFull is the drop reason of the alerts shed from a full queue
+	DropQueueFull = "queue_full"
+	// DropBatchTooLarge is the drop reason of the alerts of a batch larger
+	// than the queue
+	DropBatchTooLarge = "batch_too_large"
+
+	defaultMaxBackoff = time.Minute
+	minBackoff        = time.Second
+)
+
+// NotifierStats is the state of the notification queue
+type NotifierStats struct {
+	QueueDepth    int `json:"queueDepth"`
+	QueueCapacity int `json:"queueCapacity"`
+	// Sent is the number of alerts delivered to an alert manager
+	Sent uint64 `json:"sent"`
+	// Collapsed is the number of queued alerts replaced by a newer
+	// notification of the same alert while the queue was backed up
+	Collapsed uint64 `json:"collapsed"`
+	// Dropped is the number of alerts dropped by reason
+	Dropped map[string]uint64 `json:"dropped"`
+	// ConsecutiveFailures is the number of failed sends since the last
+	// delivered batch, the notifier backs off while it is not zero
+	ConsecutiveFailures int           `json:"consecutiveFailures"`
+	Backoff             time.Duration `json:"backoff"`
+	LastError           string        `json:"lastError,omitempty"`
+	LastSuccess         time.Time     `json:"lastSuccess"`
 }
 
 // NotifierOptions are the configurable parameters of a Handler.
@@ -50,6 +91,24 @@ type NotifierOptions struct {
 	AlertManagerURLs []string
 	// timeout limit on requests
 	Timeout time.Duration
+	// Dropped is called with the alerts that were dropped from a full
+	// queue, the lowest priority alerts are dropped first
+	Dropped func(alerts []*Alert)
+	// Delivered is called with the alerts the alert managers accepted
+	Delivered func(alerts []*Alert)
+	// Failed is called with the alerts no alert manager accepted and the
+	// error of the last attempt, before they are retried
+	Failed func(alerts []*Alert, err error)
+	// MaxBackoff caps the wait between the retries of a batch the alert
+	// managers did not accept, one minute when not set
+	MaxBackoff time.Duration
+	// Endpoint is the path the alerts are posted to, relative to the
+	// alert manager urls, v1/alerts when not set
+	Endpoint string
+	// Client sends the requests, a default client when not set
+	Client *http.Client
+	// Encode marshals a batch of alerts, json when not set
+	Encode func(alerts []*Alert) ([]byte, error)
 }
 
 func (opts *NotifierOptions) String() string {
@@ -70,6 +129,14 @@ func NewNotifier(o *NotifierOptions, logger log.Logger) (*Notifier, error) {
 	if logger == nil {
 		logger = log.NewNopLogger()
 	}
+	if o.Endpoint == "" {
+		o.Endpoint = alertPushEndpoint
+	}
+	if o.Encode == nil {
+		o.Encode = func(alerts []*Alert) ([]byte, error) {
+			return json.Marshal(alerts)
+		}
+	}
 
 	n := &Notifier{
 		queue:  make([]*Alert, 0, o.QueueCapacity),
@@ -78,6 +145,7 @@ func NewNotifier(o *NotifierOptions, logger log.Logger) (*Notifier, error) {
 		more:   make(chan struct{}, 1),
 		opts:   o,
 		logger: logger,
+		stats:  NotifierStats{Dropped: map[string]uint64{}},
 	}
 	timeout := o.Timeout
 
@@ -85,7 +153,7 @@ func NewNotifier(o *NotifierOptions, logger log.Logger) (*Notifier, error) {
 		timeout = time.Duration(30 * time.Second)
 	}
 
-	amset, err := newAlertmanagerSet(o.AlertManagerURLs, timeout, logger)
+	amset, err := newAlertmanagerSet(o.AlertManagerURLs, timeout, o.Client, logger)
 	if err != nil {
 		zap.L().Error("failed to parse alert manager urls")
 		return n, err
@@ -131,10 +199,29 @@ func (n *Notifier) Run() {
 		case <-n.more:
 		}
 		alerts := n.nextBatch()
+		if len(alerts) == 0 {
+			continue
+		}
 
-		if !n.sendAll(alerts...) {
-			zap.L().Warn("msg: dropped alerts", zap.Int("count", len(alerts)))
-			// n.metrics.dropped.Add(float64(len(alerts)))
+		if n.sendAll(alerts...) {
+			n.delivered(len(alerts))
+			if n.opts.Delivered != nil {
+				n.opts.Delivered(alerts)
+			}
+		} else {
+			// keep the batch and wait before the next attempt, the queue
+			// sheds the lowest priority alerts while the alert managers
+			// are unavailable
+			backoff := n.requeue(alerts)
+			if n.opts.Failed != nil {
+				n.opts.Failed(alerts, n.sendError())
+			}
+			zap.L().Warn("failed to send alerts, retrying", zap.Int("count", len(alerts)), zap.Duration("backoff", backoff))
+			select {
+			case <-n.ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
 		}
 		// If the queue still has items left, kick off the next iteration.
 		if n.queueLen() > 0 {
@@ -146,32 +233,194 @@ func (n *Notifier) Run() {
 // Send queues the given notification requests for processing.
 // Panics if called on a handler that is not running.
 func (n *Notifier) Send(alerts ...*Alert) {
+	// render the markdown annotations for every channel markup here so
+	// that the channel templates do not have to escape them
+	rendered := make([]*Alert, 0, len(alerts))
+	for _, a := range alerts {
+		c := *a
+		c.Annotations = withRenderedMarkdown(a.Annotations)
+		rendered = append(rendered, &c)
+	}
+	alerts = rendered
+
 	n.mtx.Lock()
 	defer n.mtx.Unlock()
 
 	// Queue capacity should be significantly larger than a single alert
 	// batch could be.
 	if d := len(alerts) - n.opts.QueueCapacity; d > 0 {
+		n.dropped(DropBatchTooLarge, alerts[:d])
 		alerts = alerts[d:]
 
 		level.Warn(n.logger).Log("msg", "Alert batch larger than queue capacity, dropping alerts", "num_dropped", d)
-		//n.metrics.dropped.Add(float64(d))
 	}
 
-	// If the queue is full, remove the oldest alerts in favor
-	// of newer ones.
-	if d := (len(n.queue) + len(alerts)) - n.opts.QueueCapacity; d > 0 {
-		n.queue = n.queue[d:]
-
-		level.Warn(n.logger).Log("msg", "Alert notification queue full, dropping alerts", "num_dropped", d)
-		//n.metrics.dropped.Add(float64(d))
-	}
 	n.queue = append(n.queue, alerts...)
+	// a backed up queue keeps only the latest notification of an alert
+	if len(n.queue) > maxBatchSize {
+		n.queue = n.collapse(n.queue)
+	}
+	n.shed()
 
 	// Notify sending goroutine that there are alerts to be processed.
 	n.setMore()
 }
 
+// requeue puts a batch that was not delivered back in front of the queue
+// and returns the time to wait before the next attempt
+func (n *Notifier) requeue(alerts []*Alert) time.Duration {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	n.queue = n.collapse(append(alerts, n.queue...))
+	n.shed()
+
+	maxBackoff := n.opts.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+	n.stats.ConsecutiveFailures++
+	backoff := minBackoff << min(n.stats.ConsecutiveFailures-1, 16)
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	n.stats.Backoff = backoff
+	return backoff
+}
+
+func (n *Notifier) failed(url string, err error) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	n.stats.LastError = fmt.Sprintf("%s: %v", url, err)
+}
+
+// sendError is the error of the last batch no alert manager accepted
+func (n *Notifier) sendError() error {
+	n.mtx.RLock()
+	defer n.mtx.RUnlock()
+
+	if n.stats.LastError == "" {
+		return fmt.Errorf("no alert manager accepted the alerts")
+	}
+	return fmt.Errorf("%s", n.stats.LastError)
+}
+
+func (n *Notifier) delivered(count int) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	n.stats.Sent += uint64(count)
+	n.stats.ConsecutiveFailures = 0
+	n.stats.Backoff = 0
+	n.stats.LastError = ""
+	n.stats.LastSuccess = time.Now()
+}
+
+// collapse keeps the latest notification of every alert at the position
+// of its first one, n.mtx must be held
+func (n *Notifier) collapse(queue []*Alert) []*Alert {
+	positions := make(map[uint64]int, len(queue))
+	collapsed := queue[:0]
+	for _, a := range queue {
+		if a.Labels == nil {
+			collapsed = append(collapsed, a)
+			continue
+		}
+		h := a.Hash()
+		if i, ok := positions[h]; ok {
+			collapsed[i] = a
+			n.stats.Collapsed++
+			continue
+		}
+		positions[h] = len(collapsed)
+		collapsed = append(collapsed, a)
+	}
+	// clear the tail so that the dropped alerts can be collected
+	for i := len(collapsed); i < len(queue); i++ {
+		queue[i] = nil
+	}
+	return collapsed
+}
+
+// shed drops the alerts over the capacity of the queue, the lowest
+// priority alerts first and the oldest first for the same priority,
+// n.mtx must be held
+func (n *Notifier) shed() {
+	d := len(n.queue) - n.opts.QueueCapacity
+	if d <= 0 {
+		return
+	}
+
+	order := make([]int, len(n.queue))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return alertPriority(n.queue[order[i]]) < alertPriority(n.queue[order[j]])
+	})
+	drop := make(map[int]struct{}, d)
+	for _, i := range order[:d] {
+		drop[i] = struct{}{}
+	}
+
+	dropped := make([]*Alert, 0, d)
+	kept := make([]*Alert, 0, n.opts.QueueCapacity)
+	for i, a := range n.queue {
+		if _, ok := drop[i]; ok {
+			dropped = append(dropped, a)
+			continue
+		}
+		kept = append(kept, a)
+	}
+	n.queue = kept
+	n.dropped(DropQueueFull, dropped)
+
+	level.Warn(n.logger).Log("msg", "Alert notification queue full, dropping the lowest priority alerts", "num_dropped", d)
+}
+
+// severityPriority orders the severities of the alerts, alerts without a
+// known severity have the lowest priority
+var severityPriority = map[string]int{
+	"info":     1,
+	"warning":  2,
+	"error":    3,
+	"critical": 4,
+}
+
+func alertPriority(a *Alert) int {
+	if a.Labels == nil {
+		return 0
+	}
+	return severityPriority[strings.ToLower(a.Labels.Get("severity"))]
+}
+
+// dropped records the dropped alerts, n.mtx must be held
+func (n *Notifier) dropped(reason string, alerts []*Alert) {
+	if len(alerts) == 0 {
+		return
+	}
+	n.stats.Dropped[reason] += uint64(len(alerts))
+	if n.opts.Dropped != nil {
+		n.opts.Dropped(alerts)
+	}
+}
+
+// Stats returns the state of the notification queue
+func (n *Notifier) Stats() NotifierStats {
+	n.mtx.RLock()
+	defer n.mtx.RUnlock()
+
+	stats := n.stats
+	stats.QueueDepth = len(n.queue)
+	stats.QueueCapacity = n.opts.QueueCapacity
+	stats.Dropped = make(map[string]uint64, len(n.stats.Dropped))
+	for reason, count := range n.stats.Dropped {
+		stats.Dropped[reason] = count
+	}
+	return stats
+}
+
 // setMore signals that the alert queue has items.
 func (n *Notifier) setMore() {
 	// If we cannot send on the channel, it means the signal already exists
@@ -192,7 +441,7 @@ func (n *Notifier) Alertmanagers() []*url.URL {
 
 	amset.mtx.RLock()
 	for _, am := range amset.ams {
-		res = append(res, am.URLPath(alertPushEndpoint))
+		res = append(res, am.URLPath(n.opts.Endpoint))
 	}
 	amset.mtx.RUnlock()
 
@@ -203,7 +452,7 @@ func (n *Notifier) Alertmanagers() []*url.URL {
 // It returns true if the alerts could be sent successfully to at least one Alertmanager.
 func (n *Notifier) sendAll(alerts ...*Alert) bool {
 
-	b, err := json.Marshal(alerts)
+	b, err := n.opts.Encode(alerts)
 	if err != nil {
 		zap.L().Error("Encoding alerts failed", zap.Error(err))
 		return false
@@ -227,9 +476,17 @@ func (n *Notifier) sendAll(alerts ...*Alert) bool {
 		defer cancel()
 
 		go func(ams *alertmanagerSet, am Manager) {
-			u := am.URLPath(alertPushEndpoint).String()
+			u := am.URLPath(n.opts.Endpoint).String()
+			ctx, span := tracer.Start(ctx, "alertmanager.send", trace.WithAttributes(
+				attribute.String("alertmanager.url", u),
+				attribute.Int("alertmanager.alerts", len(alerts)),
+			))
+			defer span.End()
 			if err := n.sendOne(ctx, ams.client, u, b); err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
 				zap.L().Error("Error calling alert API", zap.String("alertmanager", u), zap.Int("count", len(alerts)), zap.Error(err))
+				n.failed(u, err)
 			} else {
 				atomic.AddUint64(&numSuccess, 1)
 			}
@@ -283,8 +540,10 @@ type alertmanagerSet struct {
 	logger log.Logger
 }
 
-func newAlertmanagerSet(urls []string, timeout time.Duration, logger log.Logger) (*alertmanagerSet, error) {
-	client := &http.Client{}
+func newAlertmanagerSet(urls []string, timeout time.Duration, client *http.Client, logger log.Logger) (*alertmanagerSet, error) {
+	if client == nil {
+		client = &http.Client{}
+	}
 
 	s := &alertmanagerSet{
 		client:  client,
@@ -0,0 +1,192 @@
+package alertManager
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+	old_ctx "golang.org/x/net/context"
+)
+
+func newTestNotifier(t *testing.T, opts NotifierOptions) *Notifier {
+	if opts.AlertManagerURLs == nil {
+		opts.AlertManagerURLs = []string{"http://alertmanager:9093/api/"}
+	}
+	n, err := NewNotifier(&opts, nil)
+	require.NoError(t, err)
+	return n
+}
+
+func severityAlert(name, severity string) *Alert {
+	return &Alert{Labels: labels.FromMap(map[string]string{"alertname": name, "severity": severity})}
+}
+
+func alertNames(alerts []*Alert) []string {
+	names := make([]string, 0, len(alerts))
+	for _, a := range alerts {
+		names = append(names, a.Name())
+	}
+	return names
+}
+
+func TestNotifierBackoff(t *testing.T) {
+	n := newTestNotifier(t, NotifierOptions{QueueCapacity: 10, MaxBackoff: 5 * time.Second})
+
+	// the wait doubles with every failed attempt up to the max backoff
+	var backoffs []time.Duration
+	for i := 0; i < 5; i++ {
+		backoffs = append(backoffs, n.requeue(nil))
+	}
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}, backoffs)
+	stats := n.Stats()
+	assert.Equal(t, 5, stats.ConsecutiveFailures)
+	assert.Equal(t, 5*time.Second, stats.Backoff)
+
+	// a delivered batch resets the backoff
+	n.failed("http://alertmanager:9093/api/v1/alerts", fmt.Errorf("bad response status 503"))
+	n.delivered(3)
+	stats = n.Stats()
+	assert.Equal(t, 0, stats.ConsecutiveFailures)
+	assert.Equal(t, time.Duration(0), stats.Backoff)
+	assert.Empty(t, stats.LastError)
+	assert.Equal(t, uint64(3), stats.Sent)
+	assert.Equal(t, time.Second, n.requeue(nil))
+
+	// one minute by default, without overflowing after many failures
+	n = newTestNotifier(t, NotifierOptions{QueueCapacity: 10})
+	for i := 0; i < 100; i++ {
+		n.requeue(nil)
+	}
+	assert.Equal(t, defaultMaxBackoff, n.requeue(nil))
+}
+
+func TestNotifierShedsLowestPriority(t *testing.T) {
+	var dropped []*Alert
+	n := newTestNotifier(t, NotifierOptions{
+		QueueCapacity: 3,
+		Dropped:       func(alerts []*Alert) { dropped = append(dropped, alerts...) },
+	})
+
+	n.Send(severityAlert("info-1", "info"), severityAlert("critical", "critical"), severityAlert("warning", "warning"))
+	// the queue is full, the oldest of the lowest priority goes first
+	n.Send(severityAlert("info-2", "info"))
+	assert.Equal(t, []string{"info-1"}, alertNames(dropped))
+	n.Send(severityAlert("error", "ERROR"))
+	assert.Equal(t, []string{"info-1", "info-2"}, alertNames(dropped))
+	// alerts without a severity have the lowest priority
+	n.Send(&Alert{Labels: labels.FromMap(map[string]string{"alertname": "unknown"})})
+	assert.Equal(t, []string{"info-1", "info-2", "unknown"}, alertNames(dropped))
+	// the order of the kept alerts does not change
+	assert.Equal(t, []string{"critical", "warning", "error"}, alertNames(n.queue))
+
+	// the batches larger than the queue lose their first alerts
+	n.Send(severityAlert("a", "critical"), severityAlert("b", "critical"), severityAlert("c", "critical"), severityAlert("d", "critical"), severityAlert("e", "critical"))
+	assert.Equal(t, []string{"a", "b"}, alertNames(dropped[3:5]))
+	assert.Equal(t, []string{"c", "d", "e"}, alertNames(n.queue))
+
+	stats := n.Stats()
+	assert.Equal(t, map[string]uint64{DropQueueFull: 6, DropBatchTooLarge: 2}, stats.Dropped)
+	assert.Equal(t, 3, stats.QueueDepth)
+	assert.Equal(t, 3, stats.QueueCapacity)
+}
+
+func TestNotifierRequeue(t *testing.T) {
+	n := newTestNotifier(t, NotifierOptions{QueueCapacity: 2})
+
+	n.Send(severityAlert("warning", "warning"), severityAlert("info", "info"))
+	batch := n.nextBatch()
+	assert.Empty(t, n.queue)
+
+	// a newer notification of the failed alert replaces it in the batch
+	// that goes back in front of the queue
+	newer := severityAlert("warning", "warning")
+	newer.EndsAt = time.Now()
+	n.Send(newer, severityAlert("critical", "critical"))
+	n.requeue(batch)
+	assert.Equal(t, []string{"warning", "critical"}, alertNames(n.queue))
+	assert.False(t, n.queue[0].EndsAt.IsZero())
+
+	stats := n.Stats()
+	assert.Equal(t, uint64(1), stats.Collapsed)
+	// the requeued batch is shed like the new alerts
+	assert.Equal(t, uint64(1), stats.Dropped[DropQueueFull])
+
+	// the queue only collapses the notifications once it is backed up
+	n = newTestNotifier(t, NotifierOptions{QueueCapacity: 200})
+	n.Send(severityAlert("same", "info"), severityAlert("same", "info"))
+	assert.Len(t, n.queue, 2)
+	for i := 0; i < maxBatchSize; i++ {
+		n.Send(severityAlert(fmt.Sprintf("alert-%d", i), "info"))
+	}
+	assert.Len(t, n.queue, maxBatchSize+1)
+	assert.Equal(t, uint64(1), n.Stats().Collapsed)
+}
+
+func TestNotifierRetriesFailedBatches(t *testing.T) {
+	var (
+		mtx       sync.Mutex
+		attempts  [][]string
+		failed    []error
+		delivered []string
+	)
+	n := newTestNotifier(t, NotifierOptions{
+		QueueCapacity: 10,
+		Do: func(ctx old_ctx.Context, client *http.Client, req *http.Request) (*http.Response, error) {
+			var alerts []struct {
+				Labels map[string]string `json:"labels"`
+			}
+			if err := json.NewDecoder(req.Body).Decode(&alerts); err != nil {
+				return nil, err
+			}
+			var names []string
+			for _, a := range alerts {
+				names = append(names, a.Labels["alertname"])
+			}
+			mtx.Lock()
+			defer mtx.Unlock()
+			attempts = append(attempts, names)
+			status := http.StatusOK
+			if len(attempts) == 1 {
+				status = http.StatusServiceUnavailable
+			}
+			return &http.Response{StatusCode: status, Status: http.StatusText(status), Body: io.NopCloser(strings.NewReader(""))}, nil
+		},
+		Failed: func(alerts []*Alert, err error) {
+			mtx.Lock()
+			defer mtx.Unlock()
+			failed = append(failed, err)
+		},
+		Delivered: func(alerts []*Alert) {
+			mtx.Lock()
+			defer mtx.Unlock()
+			delivered = append(delivered, alertNames(alerts)...)
+		},
+	})
+	go n.Run()
+	defer n.Stop()
+
+	n.Send(severityAlert("HighLatency", "critical"))
+	require.Eventually(t, func() bool {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return len(delivered) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	assert.Equal(t, [][]string{{"HighLatency"}, {"HighLatency"}}, attempts)
+	require.Len(t, failed, 1)
+	assert.EqualError(t, failed[0], "http://alertmanager:9093/api/v1/alerts: bad response status Service Unavailable")
+	stats := n.Stats()
+	assert.Equal(t, uint64(1), stats.Sent)
+	assert.Equal(t, 0, stats.ConsecutiveFailures)
+	assert.Equal(t, 0, stats.QueueDepth)
+}
@@ -0,0 +1,474 @@
+package alertManager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	html_template "html/template"
+	"regexp"
+	"sort"
+	"strings"
+	text_template "text/template"
+	"time"
+	"unicode"
+
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// the integrations a notification can be previewed for
+const (
+	PreviewSlack   = "slack"
+	PreviewEmail   = "email"
+	PreviewWebhook = "webhook"
+	PreviewPush    = "push"
+	PreviewChat    = "chat"
+)
+
+// the default templates of the integrations, as in the alert manager
+const (
+	defaultTitleTemplate     = `[{{ .Status | toUpper }}{{ if eq .Status "firing" }}:{{ .Alerts.Firing | len }}{{ end }}] {{ .GroupLabels.SortedPairs.Values | join " " }}`
+	defaultTitleLinkTemplate = `{{ .ExternalURL }}`
+	defaultEmailHTMLTemplate = `<h2><a href="{{ .ExternalURL }}">[{{ .Status | toUpper }}{{ if eq .Status "firing" }}:{{ .Alerts.Firing | len }}{{ end }}] {{ .GroupLabels.SortedPairs.Values | join " " }}</a></h2>
+{{ range .Alerts }}<table>
+<tr><td><strong>Labels</strong></td></tr>
+{{ range .Labels.SortedPairs }}<tr><td>{{ .Name }} = {{ .Value }}</td></tr>
+{{ end }}{{ if gt (len .Annotations) 0 }}<tr><td><strong>Annotations</strong></td></tr>
+{{ range .Annotations.SortedPairs }}<tr><td>{{ .Name }} = {{ .Value }}</td></tr>
+{{ end }}{{ end }}<tr><td><a href="{{ .GeneratorURL }}">Source</a></td></tr>
+</table>
+{{ end }}`
+)
+
+// defaultSendResolved tells whether the integrations notify the resolved
+// alerts when their config does not say
+var defaultSendResolved = map[string]bool{
+	PreviewSlack:   false,
+	PreviewEmail:   false,
+	PreviewWebhook: true,
+}
+
+// NotificationPreview is what an integration of a channel receives for a
+// notification of the alerts
+type NotificationPreview struct {
+	Integration string `json:"integration"`
+	// Subject is the subject of the emails and the title of the push
+	// notifications
+	Subject     string `json:"subject,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	// Body is the payload sent, the slack message, the html of the email or
+	// the json of the webhook
+	Body string `json:"body,omitempty"`
+	// Skipped tells why the integration is not notified, e.g. it does not
+	// send the resolved alerts
+	Skipped string   `json:"skipped,omitempty"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// PreviewNotifications renders the notifications the integrations of the
+// receiver get for the alerts at the time, grouped by alert name like the
+// routes of the rules. The templates of the channel are used, the defaults
+// of the alert manager otherwise, with the locale set on the receiver.
+func PreviewNotifications(r *Receiver, alerts []*Alert, externalURL string, now time.Time) []NotificationPreview {
+	rendered := make([]*Alert, 0, len(alerts))
+	for _, a := range alerts {
+		c := *a
+		c.Annotations = withRenderedMarkdown(a.Annotations)
+		rendered = append(rendered, &c)
+	}
+	cfg := r.forAlertManager()
+	data := newTemplateData(r.Name, rendered, externalURL, now)
+
+	previews := []NotificationPreview{}
+	for _, c := range configList(cfg.SlackConfigs) {
+		previews = append(previews, previewSlack(c, data))
+	}
+	for _, c := range configList(cfg.EmailConfigs) {
+		previews = append(previews, previewEmail(c, data))
+	}
+	for _, c := range configList(cfg.WebhookConfigs) {
+		previews = append(previews, previewWebhook(c, data))
+	}
+	for i := range r.PushConfigs {
+		for _, a := range rendered {
+			previews = append(previews, previewPush(&r.PushConfigs[i], a, r.locale, r.Timezone))
+		}
+	}
+	if len(r.IRCConfigs) > 0 || len(r.XMPPConfigs) > 0 {
+		for _, a := range rendered {
+			previews = append(previews, NotificationPreview{Integration: PreviewChat, ContentType: "text/plain", Body: NewChatText(a, r.locale, r.Timezone)})
+		}
+	}
+	return previews
+}
+
+func configList(configs interface{}) []map[string]interface{} {
+	list, _ := configs.([]interface{})
+	result := make([]map[string]interface{}, 0, len(list))
+	for _, item := range list {
+		if cfg, ok := item.(map[string]interface{}); ok {
+			result = append(result, cfg)
+		}
+	}
+	return result
+}
+
+func configString(cfg map[string]interface{}, field, fallback string) string {
+	if v, ok := cfg[field].(string); ok && v != "" {
+		return v
+	}
+	return fallback
+}
+
+// skipResolved tells why the resolved alerts are not sent to the
+// integration, empty when they are
+func skipResolved(integration string, cfg map[string]interface{}, data *templateData) string {
+	if data.Status != "resolved" {
+		return ""
+	}
+	send, ok := cfg["send_resolved"].(bool)
+	if !ok {
+		send = defaultSendResolved[integration]
+	}
+	if send {
+		return ""
+	}
+	return "the resolved alerts are not sent, send_resolved is disabled"
+}
+
+// expander executes the templates of an integration config and collects
+// their errors
+type expander struct {
+	data   *templateData
+	errors []string
+}
+
+func (e *expander) text(field, text string) string {
+	out, err := executeText(text, e.data)
+	if err != nil {
+		e.errors = append(e.errors, fmt.Sprintf("%s: %v", field, err))
+	}
+	return out
+}
+
+func (e *expander) html(field, text string) string {
+	tmpl, err := html_template.New(field).Funcs(html_template.FuncMap(templateFuncs)).Funcs(html_template.FuncMap{
+		"safeHtml": func(s string) html_template.HTML { return html_template.HTML(s) },
+	}).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		e.errors = append(e.errors, fmt.Sprintf("%s: %v", field, err))
+		return ""
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, e.data); err != nil {
+		e.errors = append(e.errors, fmt.Sprintf("%s: %v", field, err))
+	}
+	return b.String()
+}
+
+func executeText(text string, data *templateData) (string, error) {
+	tmpl, err := text_template.New("").Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return b.String(), err
+	}
+	return b.String(), nil
+}
+
+func previewSlack(cfg map[string]interface{}, data *templateData) NotificationPreview {
+	p := NotificationPreview{Integration: PreviewSlack, ContentType: "application/json"}
+	if p.Skipped = skipResolved(PreviewSlack, cfg, data); p.Skipped != "" {
+		return p
+	}
+	e := &expander{data: data}
+	title := e.text("title", configString(cfg, "title", defaultTitleTemplate))
+	link := e.text("title_link", configString(cfg, "title_link", defaultTitleLinkTemplate))
+	text := e.text("text", configString(cfg, "text", ""))
+
+	heading := "*" + title + "*"
+	if link != "" {
+		heading = fmt.Sprintf("*<%s|%s>*", link, title)
+	}
+	blocks := []interface{}{
+		map[string]interface{}{"type": "section", "text": map[string]string{"type": "mrkdwn", "text": heading}},
+	}
+	if strings.TrimSpace(text) != "" {
+		blocks = append(blocks, map[string]interface{}{"type": "section", "text": map[string]string{"type": "mrkdwn", "text": text}})
+	}
+	msg := map[string]interface{}{"text": title, "blocks": blocks}
+	for _, field := range []string{"channel", "username", "icon_emoji", "icon_url"} {
+		if v := configString(cfg, field, ""); v != "" {
+			msg[field] = e.text(field, v)
+		}
+	}
+	b, _ := json.MarshalIndent(msg, "", "  ")
+	p.Body = string(b)
+	p.Errors = e.errors
+	return p
+}
+
+func previewEmail(cfg map[string]interface{}, data *templateData) NotificationPreview {
+	p := NotificationPreview{Integration: PreviewEmail, ContentType: "text/html"}
+	if p.Skipped = skipResolved(PreviewEmail, cfg, data); p.Skipped != "" {
+		return p
+	}
+	e := &expander{data: data}
+	subject := defaultTitleTemplate
+	if headers, ok := cfg["headers"].(map[string]interface{}); ok {
+		if s, ok := headers["Subject"].(string); ok && s != "" {
+			subject = s
+		}
+	}
+	p.Subject = e.text("subject", subject)
+	p.Body = e.html("html", configString(cfg, "html", defaultEmailHTMLTemplate))
+	p.Errors = e.errors
+	return p
+}
+
+// webhookMessage is the payload of the webhooks of the alert manager
+type webhookMessage struct {
+	Version           string            `json:"version"`
+	GroupKey          string            `json:"groupKey"`
+	TruncatedAlerts   int               `json:"truncatedAlerts"`
+	Receiver          string            `json:"receiver"`
+	Status            string            `json:"status"`
+	Alerts            []templateAlert   `json:"alerts"`
+	GroupLabels       map[string]string `json:"groupLabels"`
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+	ExternalURL       string            `json:"externalURL"`
+}
+
+func previewWebhook(cfg map[string]interface{}, data *templateData) NotificationPreview {
+	p := NotificationPreview{Integration: PreviewWebhook, ContentType: "application/json"}
+	if p.Skipped = skipResolved(PreviewWebhook, cfg, data); p.Skipped != "" {
+		return p
+	}
+	msg := webhookMessage{
+		Version:           "4",
+		GroupKey:          fmt.Sprintf("{}:%s", templateKV(data.GroupLabels).String()),
+		Receiver:          data.Receiver,
+		Status:            data.Status,
+		Alerts:            data.Alerts,
+		GroupLabels:       data.GroupLabels,
+		CommonLabels:      data.CommonLabels,
+		CommonAnnotations: data.CommonAnnotations,
+		ExternalURL:       data.ExternalURL,
+	}
+	if max, ok := cfg["max_alerts"].(float64); ok && max > 0 && int(max) < len(msg.Alerts) {
+		msg.TruncatedAlerts = len(msg.Alerts) - int(max)
+		msg.Alerts = msg.Alerts[:int(max)]
+	}
+	b, _ := json.MarshalIndent(msg, "", "  ")
+	p.Body = string(b)
+	return p
+}
+
+func previewPush(cfg *PushConfig, a *Alert, locale *Locale, timezone string) NotificationPreview {
+	msg := NewPushMessage(cfg, a, locale, timezone)
+	b, _ := json.MarshalIndent(msg, "", "  ")
+	return NotificationPreview{Integration: PreviewPush, Subject: msg.Title, ContentType: "application/json", Body: string(b)}
+}
+
+// templateKV is a set of labels or annotations in the notification
+// templates
+type templateKV map[string]string
+
+type templatePair struct {
+	Name  string
+	Value string
+}
+
+type templatePairs []templatePair
+
+func (ps templatePairs) Names() []string {
+	names := make([]string, 0, len(ps))
+	for _, p := range ps {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+func (ps templatePairs) Values() []string {
+	values := make([]string, 0, len(ps))
+	for _, p := range ps {
+		values = append(values, p.Value)
+	}
+	return values
+}
+
+// SortedPairs returns the pairs sorted by name, with the alert name first
+func (kv templateKV) SortedPairs() templatePairs {
+	pairs := make(templatePairs, 0, len(kv))
+	for name, value := range kv {
+		pairs = append(pairs, templatePair{Name: name, Value: value})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Name == labels.AlertNameLabel || pairs[j].Name == labels.AlertNameLabel {
+			return pairs[i].Name == labels.AlertNameLabel
+		}
+		return pairs[i].Name < pairs[j].Name
+	})
+	return pairs
+}
+
+func (kv templateKV) Names() []string {
+	return kv.SortedPairs().Names()
+}
+
+func (kv templateKV) Values() []string {
+	return kv.SortedPairs().Values()
+}
+
+// Remove returns a copy of the set without the names
+func (kv templateKV) Remove(names []string) templateKV {
+	c := templateKV{}
+	for k, v := range kv {
+		c[k] = v
+	}
+	for _, name := range names {
+		delete(c, name)
+	}
+	return c
+}
+
+func (kv templateKV) String() string {
+	pairs := kv.SortedPairs()
+	parts := make([]string, 0, len(pairs))
+	for _, p := range pairs {
+		parts = append(parts, fmt.Sprintf("%s=%q", p.Name, p.Value))
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+type templateAlert struct {
+	Status       string     `json:"status"`
+	Labels       templateKV `json:"labels"`
+	Annotations  templateKV `json:"annotations"`
+	StartsAt     time.Time  `json:"startsAt"`
+	EndsAt       time.Time  `json:"endsAt"`
+	GeneratorURL string     `json:"generatorURL"`
+	Fingerprint  string     `json:"fingerprint"`
+}
+
+type templateAlerts []templateAlert
+
+func (as templateAlerts) Firing() []templateAlert {
+	return as.withStatus("firing")
+}
+
+func (as templateAlerts) Resolved() []templateAlert {
+	return as.withStatus("resolved")
+}
+
+func (as templateAlerts) withStatus(status string) []templateAlert {
+	result := []templateAlert{}
+	for _, a := range as {
+		if a.Status == status {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// templateData is the data the notification templates are executed with,
+// the same as in the alert manager
+type templateData struct {
+	Receiver          string
+	Status            string
+	Alerts            templateAlerts
+	GroupLabels       templateKV
+	CommonLabels      templateKV
+	CommonAnnotations templateKV
+	ExternalURL       string
+}
+
+func newTemplateData(receiver string, alerts []*Alert, externalURL string, now time.Time) *templateData {
+	data := &templateData{
+		Receiver:          receiver,
+		Status:            "resolved",
+		Alerts:            templateAlerts{},
+		GroupLabels:       templateKV{},
+		CommonLabels:      templateKV{},
+		CommonAnnotations: templateKV{},
+		ExternalURL:       externalURL,
+	}
+	for i, a := range alerts {
+		ta := templateAlert{
+			Status:       "firing",
+			Labels:       templateKV(a.Labels.Map()),
+			Annotations:  templateKV{},
+			StartsAt:     a.StartsAt,
+			EndsAt:       a.EndsAt,
+			GeneratorURL: a.GeneratorURL,
+			Fingerprint:  fmt.Sprintf("%016x", a.Hash()),
+		}
+		if a.Annotations != nil {
+			ta.Annotations = templateKV(a.Annotations.Map())
+		}
+		if a.ResolvedAt(now) {
+			ta.Status = "resolved"
+		} else {
+			data.Status = "firing"
+			// the firing alerts have no end in the notifications
+			ta.EndsAt = time.Time{}
+		}
+		data.Alerts = append(data.Alerts, ta)
+
+		if i == 0 {
+			for k, v := range ta.Labels {
+				data.CommonLabels[k] = v
+			}
+			for k, v := range ta.Annotations {
+				data.CommonAnnotations[k] = v
+			}
+			if name := a.Name(); name != "" {
+				data.GroupLabels[labels.AlertNameLabel] = name
+			}
+			continue
+		}
+		for k, v := range data.CommonLabels {
+			if ta.Labels[k] != v {
+				delete(data.CommonLabels, k)
+			}
+		}
+		for k, v := range data.CommonAnnotations {
+			if ta.Annotations[k] != v {
+				delete(data.CommonAnnotations, k)
+			}
+		}
+	}
+	return data
+}
+
+// templateFuncs are the functions of the notification templates of the
+// alert manager
+var templateFuncs = text_template.FuncMap{
+	"toUpper": strings.ToUpper,
+	"toLower": strings.ToLower,
+	"title": func(s string) string {
+		runes := []rune(s)
+		for i, r := range runes {
+			if i == 0 || unicode.IsSpace(runes[i-1]) || unicode.IsPunct(runes[i-1]) {
+				runes[i] = unicode.ToTitle(r)
+			}
+		}
+		return string(runes)
+	},
+	"trimSpace": strings.TrimSpace,
+	"join": func(sep string, s []string) string {
+		return strings.Join(s, sep)
+	},
+	"match": regexp.MatchString,
+	"reReplaceAll": func(pattern, repl, text string) string {
+		return regexp.MustCompile(pattern).ReplaceAllString(text, repl)
+	},
+	"stringSlice": func(s ...string) []string {
+		return s
+	},
+	"safeHtml": func(s string) string {
+		return s
+	},
+}
@@ -0,0 +1,336 @@
+package alertManager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.uber.org/zap"
+)
+
+const (
+	PushPlatformAndroid = "android"
+	PushPlatformIOS     = "ios"
+	PushPlatformWeb     = "web"
+
+	PushPriorityHigh   = "high"
+	PushPriorityNormal = "normal"
+
+	// pushChannelsRefresh is how often the push channels are reloaded
+	// from the channel store
+	pushChannelsRefresh = time.Minute
+)
+
+// PushDevice is an app installation registered by a user to receive
+// push notifications
+type PushDevice struct {
+	Id        int       `json:"id" db:"id"`
+	UserId    string    `json:"userId" db:"user_id"`
+	Platform  string    `json:"platform" db:"platform"`
+	Token     string    `json:"token" db:"token"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+func (d *PushDevice) Validate() error {
+	switch d.Platform {
+	case PushPlatformAndroid, PushPlatformIOS, PushPlatformWeb:
+	default:
+		return fmt.Errorf("unsupported platform %q, expected one of android, ios or web", d.Platform)
+	}
+	if d.Token == "" {
+		return fmt.Errorf("device token is required")
+	}
+	return nil
+}
+
+// PushPriority is the delivery priority and sound used for a severity
+type PushPriority struct {
+	Priority string `json:"priority"`
+	// Sound is the name of the sound played by the app, empty
+	// delivers the notification silently
+	Sound string `json:"sound,omitempty"`
+}
+
+var defaultPushPriorities = map[string]PushPriority{
+	"critical": {Priority: PushPriorityHigh, Sound: "critical"},
+	"error":    {Priority: PushPriorityHigh, Sound: "default"},
+	"warning":  {Priority: PushPriorityNormal, Sound: "default"},
+	"info":     {Priority: PushPriorityNormal},
+}
+
+// PushConfig configures the push notification channel. Notifications are
+// delivered to all the devices registered by the listed users and the
+// members of the listed groups.
+type PushConfig struct {
+	SendResolved bool `json:"send_resolved"`
+
+	// Users are the emails of the users to notify
+	Users []string `json:"users,omitempty"`
+	// Groups are the names of the user groups to notify
+	Groups []string `json:"groups,omitempty"`
+
+	// Priorities overrides the priority and sound per severity
+	Priorities map[string]PushPriority `json:"priorities,omitempty"`
+}
+
+func (c *PushConfig) Validate() error {
+	if len(c.Users) == 0 && len(c.Groups) == 0 {
+		return fmt.Errorf("push channel needs at least one user or group")
+	}
+	for severity, p := range c.Priorities {
+		if p.Priority != PushPriorityHigh && p.Priority != PushPriorityNormal {
+			return fmt.Errorf("invalid priority %q for severity %s, expected high or normal", p.Priority, severity)
+		}
+	}
+	return nil
+}
+
+// PriorityFor returns the priority for the severity, falling back to
+// the defaults and finally to normal priority with the default sound
+func (c *PushConfig) PriorityFor(severity string) PushPriority {
+	if p, ok := c.Priorities[severity]; ok {
+		return p
+	}
+	if p, ok := defaultPushPriorities[severity]; ok {
+		return p
+	}
+	return PushPriority{Priority: PushPriorityNormal, Sound: "default"}
+}
+
+// PushMessage is the provider independent content of a push notification
+type PushMessage struct {
+	Title    string
+	Body     string
+	Priority string
+	Sound    string
+	// Link opens the alert in the app
+	Link string
+	Data map[string]string
+}
+
+// NewPushMessage prepares the notification for the alert, the state,
+// times and value are rendered with the locale and timezone of the channel
+func NewPushMessage(cfg *PushConfig, alert *Alert, locale *Locale, timezone string) PushMessage {
+	locale = locale.orDefault()
+	status := "firing"
+	if alert.Resolved() {
+		status = "resolved"
+	}
+	severity := alert.Labels.Get("severity")
+	priority := cfg.PriorityFor(severity)
+
+	body := alert.Annotations.Get("summary")
+	if body == "" {
+		body = alert.Annotations.Get("description")
+	}
+	body = RenderMarkdown(body, MarkupPlain)
+	if details := locale.alertDetails(alert, timezone); details != "" {
+		body = strings.TrimSpace(body + "\n" + details)
+	}
+
+	return PushMessage{
+		Title:    fmt.Sprintf("[%s] %s", locale.alertStatus(alert), alert.Name()),
+		Body:     body,
+		Priority: priority.Priority,
+		Sound:    priority.Sound,
+		Link:     alert.GeneratorURL,
+		Data: map[string]string{
+			"alertname": alert.Name(),
+			"ruleId":    alert.Labels.Get("ruleId"),
+			"severity":  severity,
+			"status":    status,
+			"link":      alert.GeneratorURL,
+		},
+	}
+}
+
+// PushSender delivers a message to a device through a push provider
+type PushSender interface {
+	Send(ctx context.Context, device PushDevice, msg PushMessage) error
+}
+
+// PushStore gives the dispatcher access to the channels, their locales
+// and the registered devices
+type PushStore interface {
+	LocaleStore
+	GetChannels() (*[]model.ChannelItem, *model.ApiError)
+	GetPushDevicesForTargets(users []string, groups []string) ([]PushDevice, *model.ApiError)
+}
+
+// PushDispatcher delivers push notifications for the alerts sent to
+// push channels. Push channels are handled by query-service, the alert
+// manager only knows them as receivers without integrations.
+type PushDispatcher struct {
+	store   PushStore
+	senders map[string]PushSender
+
+	queue chan []*Alert
+	done  chan struct{}
+
+	mtx               sync.Mutex
+	channels          map[string]pushChannel
+	channelsFetchedAt time.Time
+}
+
+// pushChannel holds the push configs of a channel and how its
+// notifications are rendered
+type pushChannel struct {
+	configs  []PushConfig
+	locale   *Locale
+	timezone string
+}
+
+func NewPushDispatcher(store PushStore, senders map[string]PushSender) *PushDispatcher {
+	return &PushDispatcher{
+		store:   store,
+		senders: senders,
+		queue:   make(chan []*Alert, 100),
+		done:    make(chan struct{}),
+	}
+}
+
+// Enabled tells whether any push provider is configured
+func (d *PushDispatcher) Enabled() bool {
+	return len(d.senders) > 0
+}
+
+func (d *PushDispatcher) Run() {
+	for {
+		select {
+		case <-d.done:
+			return
+		case alerts := <-d.queue:
+			d.dispatch(context.Background(), alerts)
+		}
+	}
+}
+
+func (d *PushDispatcher) Stop() {
+	close(d.done)
+}
+
+// Send queues the alerts for delivery, alerts are dropped when the
+// queue is full so that rule evaluation is never blocked
+func (d *PushDispatcher) Send(alerts ...*Alert) {
+	if !d.Enabled() || len(alerts) == 0 {
+		return
+	}
+	select {
+	case d.queue <- alerts:
+	default:
+		zap.L().Warn("push notification queue is full, dropping alerts", zap.Int("count", len(alerts)))
+	}
+}
+
+// Test sends a test notification to the devices of the receiver
+func (d *PushDispatcher) Test(ctx context.Context, receiver *Receiver) *model.ApiError {
+	if !d.Enabled() {
+		return &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("no push provider is configured")}
+	}
+	for _, cfg := range receiver.PushConfigs {
+		msg := PushMessage{
+			Title:    "[TEST] SigNoz push notification",
+			Body:     fmt.Sprintf("This is a test notification for channel %s", receiver.Name),
+			Priority: PushPriorityNormal,
+			Sound:    "default",
+			Data:     map[string]string{"status": "test"},
+		}
+		if err := d.deliver(ctx, cfg, msg); err != nil {
+			return &model.ApiError{Typ: model.ErrorInternal, Err: err}
+		}
+	}
+	return nil
+}
+
+func (d *PushDispatcher) dispatch(ctx context.Context, alerts []*Alert) {
+	channels, err := d.pushChannels()
+	if err != nil {
+		zap.L().Error("failed to load push channels", zap.Error(err))
+		return
+	}
+	if len(channels) == 0 {
+		return
+	}
+
+	for _, alert := range alerts {
+		// alerts without preferred channels go to all the channels
+		names := alert.Receivers
+		if len(names) == 0 {
+			for name := range channels {
+				names = append(names, name)
+			}
+		}
+		for _, name := range names {
+			channel := channels[name]
+			for _, cfg := range channel.configs {
+				if alert.Resolved() && !cfg.SendResolved {
+					continue
+				}
+				if err := d.deliver(ctx, cfg, NewPushMessage(&cfg, alert, channel.locale, channel.timezone)); err != nil {
+					zap.L().Error("failed to send push notification", zap.String("channel", name), zap.String("alert", alert.Name()), zap.Error(err))
+				}
+			}
+		}
+	}
+}
+
+func (d *PushDispatcher) deliver(ctx context.Context, cfg PushConfig, msg PushMessage) error {
+	devices, apiErr := d.store.GetPushDevicesForTargets(cfg.Users, cfg.Groups)
+	if apiErr != nil {
+		return apiErr.Err
+	}
+
+	var errs []string
+	for _, device := range devices {
+		sender, ok := d.senders[device.Platform]
+		if !ok {
+			continue
+		}
+		if err := sender.Send(ctx, device, msg); err != nil {
+			errs = append(errs, fmt.Sprintf("device %d: %v", device.Id, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("push delivery failed for %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// pushChannels returns the push channels by name, the channels are
+// cached to avoid reading the store for every batch of alerts
+func (d *PushDispatcher) pushChannels() (map[string]pushChannel, error) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	if d.channels != nil && time.Since(d.channelsFetchedAt) < pushChannelsRefresh {
+		return d.channels, nil
+	}
+
+	items, apiErr := d.store.GetChannels()
+	if apiErr != nil {
+		return nil, apiErr.Err
+	}
+
+	channels := map[string]pushChannel{}
+	for _, item := range *items {
+		receiver := Receiver{}
+		if err := json.Unmarshal([]byte(item.Data), &receiver); err != nil {
+			zap.L().Error("failed to parse channel", zap.String("channel", item.Name), zap.Error(err))
+			continue
+		}
+		if len(receiver.PushConfigs) > 0 {
+			channels[item.Name] = pushChannel{
+				configs:  receiver.PushConfigs,
+				locale:   ResolveLocale(d.store, receiver.Locale),
+				timezone: receiver.Timezone,
+			}
+		}
+	}
+	d.channels = channels
+	d.channelsFetchedAt = time.Now()
+	return channels, nil
+}
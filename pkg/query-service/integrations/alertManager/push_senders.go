@@ -0,0 +1,257 @@
+package alertManager
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"go.signoz.io/signoz/pkg/query-service/constants"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+	oauthjwt "golang.org/x/oauth2/jwt"
+)
+
+const (
+	fcmScope    = "https://www.googleapis.com/auth/firebase.messaging"
+	fcmTokenURL = "https://oauth2.googleapis.com/token"
+
+	// apple rejects provider tokens older than an hour
+	apnsTokenLifetime = 50 * time.Minute
+)
+
+// NewPushSendersFromEnv returns the push senders by platform for the
+// providers configured through the environment. Android and web devices
+// are reached through FCM, ios devices through APNs and fall back to FCM
+// when APNs is not configured.
+func NewPushSendersFromEnv() map[string]PushSender {
+	senders := map[string]PushSender{}
+
+	if constants.FCMProjectID != "" && constants.FCMCredentialsFile != "" {
+		fcm, err := newFCMSender(constants.FCMProjectID, constants.FCMCredentialsFile)
+		if err != nil {
+			zap.L().Error("failed to configure FCM push notifications", zap.Error(err))
+		} else {
+			senders[PushPlatformAndroid] = fcm
+			senders[PushPlatformWeb] = fcm
+			senders[PushPlatformIOS] = fcm
+		}
+	}
+
+	if constants.APNSKeyFile != "" && constants.APNSKeyID != "" && constants.APNSTeamID != "" {
+		apns, err := newAPNSSender(constants.APNSEndpoint, constants.APNSKeyFile, constants.APNSKeyID, constants.APNSTeamID, constants.APNSTopic)
+		if err != nil {
+			zap.L().Error("failed to configure APNs push notifications", zap.Error(err))
+		} else {
+			senders[PushPlatformIOS] = apns
+		}
+	}
+
+	return senders
+}
+
+// fcmSender sends notifications with the FCM HTTP v1 api
+type fcmSender struct {
+	url    string
+	client *http.Client
+}
+
+func newFCMSender(projectID, credentialsFile string) (*fcmSender, error) {
+	data, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	creds := struct {
+		ClientEmail  string `json:"client_email"`
+		PrivateKey   string `json:"private_key"`
+		PrivateKeyID string `json:"private_key_id"`
+		TokenURI     string `json:"token_uri"`
+	}{}
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("invalid service account credentials: %w", err)
+	}
+	if creds.TokenURI == "" {
+		creds.TokenURI = fcmTokenURL
+	}
+
+	cfg := &oauthjwt.Config{
+		Email:        creds.ClientEmail,
+		PrivateKey:   []byte(creds.PrivateKey),
+		PrivateKeyID: creds.PrivateKeyID,
+		TokenURL:     creds.TokenURI,
+		Scopes:       []string{fcmScope},
+	}
+
+	// both the token exchange and the messages go through the egress policy
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{
+		Transport: DefaultEgressPolicy().Transport(),
+		Timeout:   10 * time.Second,
+	})
+	return &fcmSender{
+		url:    fmt.Sprintf("https://fcm.googleapis.com/v1/projects/%s/messages:send", projectID),
+		client: oauth2.NewClient(ctx, cfg.TokenSource(ctx)),
+	}, nil
+}
+
+func (s *fcmSender) Send(ctx context.Context, device PushDevice, msg PushMessage) error {
+	androidPriority := "NORMAL"
+	apnsPriority := "5"
+	if msg.Priority == PushPriorityHigh {
+		androidPriority = "HIGH"
+		apnsPriority = "10"
+	}
+
+	androidNotification := map[string]interface{}{}
+	aps := map[string]interface{}{}
+	if msg.Sound != "" {
+		androidNotification["sound"] = msg.Sound
+		aps["sound"] = msg.Sound
+	}
+
+	payload := map[string]interface{}{
+		"message": map[string]interface{}{
+			"token": device.Token,
+			"notification": map[string]string{
+				"title": msg.Title,
+				"body":  msg.Body,
+			},
+			"data": msg.Data,
+			"android": map[string]interface{}{
+				"priority":     androidPriority,
+				"notification": androidNotification,
+			},
+			"apns": map[string]interface{}{
+				"headers": map[string]string{"apns-priority": apnsPriority},
+				"payload": map[string]interface{}{"aps": aps},
+			},
+			"webpush": map[string]interface{}{
+				"fcm_options": map[string]string{"link": msg.Link},
+			},
+		},
+	}
+	return postPush(ctx, s.client, s.url, payload, nil)
+}
+
+// apnsSender sends notifications to ios devices with token based
+// authentication
+type apnsSender struct {
+	endpoint string
+	keyID    string
+	teamID   string
+	topic    string
+	key      *ecdsa.PrivateKey
+	client   *http.Client
+
+	mtx         sync.Mutex
+	token       string
+	tokenIssued time.Time
+}
+
+func newAPNSSender(endpoint, keyFile, keyID, teamID, topic string) (*apnsSender, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := jwt.ParseECPrivateKeyFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid APNs key: %w", err)
+	}
+	return &apnsSender{
+		endpoint: endpoint,
+		keyID:    keyID,
+		teamID:   teamID,
+		topic:    topic,
+		key:      key,
+		client:   &http.Client{Transport: DefaultEgressPolicy().Transport(), Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (s *apnsSender) providerToken() (string, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.token != "" && time.Since(s.tokenIssued) < apnsTokenLifetime {
+		return s.token, nil
+	}
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": s.teamID,
+		"iat": now.Unix(),
+	})
+	token.Header["kid"] = s.keyID
+	signed, err := token.SignedString(s.key)
+	if err != nil {
+		return "", err
+	}
+	s.token = signed
+	s.tokenIssued = now
+	return signed, nil
+}
+
+func (s *apnsSender) Send(ctx context.Context, device PushDevice, msg PushMessage) error {
+	token, err := s.providerToken()
+	if err != nil {
+		return err
+	}
+
+	priority := "5"
+	if msg.Priority == PushPriorityHigh {
+		priority = "10"
+	}
+
+	aps := map[string]interface{}{
+		"alert": map[string]string{
+			"title": msg.Title,
+			"body":  msg.Body,
+		},
+	}
+	if msg.Sound != "" {
+		aps["sound"] = msg.Sound
+	}
+	payload := map[string]interface{}{"aps": aps}
+	for k, v := range msg.Data {
+		payload[k] = v
+	}
+
+	headers := map[string]string{
+		"authorization":  "bearer " + token,
+		"apns-topic":     s.topic,
+		"apns-priority":  priority,
+		"apns-push-type": "alert",
+	}
+	return postPush(ctx, s.client, fmt.Sprintf("%s/3/device/%s", s.endpoint, device.Token), payload, headers)
+}
+
+func postPush(ctx context.Context, client *http.Client, url string, payload interface{}, headers map[string]string) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("push provider returned %s: %s", resp.Status, string(respBody))
+	}
+	return nil
+}
@@ -0,0 +1,315 @@
+package alertManager
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// pushRequest is a request received by the fake push provider
+type pushRequest struct {
+	path    string
+	headers http.Header
+	payload map[string]interface{}
+}
+
+// fakePushProvider records the requests and answers them with status
+type fakePushProvider struct {
+	*httptest.Server
+	requests chan pushRequest
+}
+
+func newFakePushProvider(t *testing.T, status int, body string) *fakePushProvider {
+	p := &fakePushProvider{requests: make(chan pushRequest, 10)}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := pushRequest{path: r.URL.Path, headers: r.Header}
+		if err := json.NewDecoder(r.Body).Decode(&req.payload); err != nil {
+			t.Errorf("invalid push payload: %v", err)
+		}
+		p.requests <- req
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *fakePushProvider) expect(t *testing.T) pushRequest {
+	t.Helper()
+	select {
+	case req := <-p.requests:
+		return req
+	default:
+		t.Fatal("the push provider did not receive a request")
+		return pushRequest{}
+	}
+}
+
+func writePEM(t *testing.T, typ string, der []byte) string {
+	path := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600))
+	return path
+}
+
+func testPushMessage() PushMessage {
+	return PushMessage{
+		Title:    "[FIRING] HighLatency",
+		Body:     "latency is high",
+		Priority: PushPriorityHigh,
+		Sound:    "critical",
+		Link:     "http://signoz/alerts",
+		Data:     map[string]string{"alertname": "HighLatency", "status": "firing"},
+	}
+}
+
+func TestFCMSender(t *testing.T) {
+	// the service account is exchanged for an access token first
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "urn:ietf:params:oauth:grant-type:jwt-bearer", r.Form.Get("grant_type"))
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"access_token": "access-token", "token_type": "Bearer", "expires_in": 3600}`)
+	}))
+	defer tokens.Close()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	creds, err := json.Marshal(map[string]string{
+		"client_email": "signoz@project.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    tokens.URL,
+	})
+	require.NoError(t, err)
+	credsFile := filepath.Join(t.TempDir(), "credentials.json")
+	require.NoError(t, os.WriteFile(credsFile, creds, 0600))
+
+	sender, err := newFCMSender("project", credsFile)
+	require.NoError(t, err)
+	assert.Equal(t, "https://fcm.googleapis.com/v1/projects/project/messages:send", sender.url)
+
+	provider := newFakePushProvider(t, http.StatusOK, `{}`)
+	sender.url = provider.URL + "/v1/projects/project/messages:send"
+	require.NoError(t, sender.Send(context.Background(), PushDevice{Platform: PushPlatformAndroid, Token: "device-token"}, testPushMessage()))
+
+	req := provider.expect(t)
+	assert.Equal(t, "Bearer access-token", req.headers.Get("Authorization"))
+	assert.Equal(t, map[string]interface{}{
+		"message": map[string]interface{}{
+			"token":        "device-token",
+			"notification": map[string]interface{}{"title": "[FIRING] HighLatency", "body": "latency is high"},
+			"data":         map[string]interface{}{"alertname": "HighLatency", "status": "firing"},
+			"android": map[string]interface{}{
+				"priority":     "HIGH",
+				"notification": map[string]interface{}{"sound": "critical"},
+			},
+			"apns": map[string]interface{}{
+				"headers": map[string]interface{}{"apns-priority": "10"},
+				"payload": map[string]interface{}{"aps": map[string]interface{}{"sound": "critical"}},
+			},
+			"webpush": map[string]interface{}{
+				"fcm_options": map[string]interface{}{"link": "http://signoz/alerts"},
+			},
+		},
+	}, req.payload)
+
+	// silent notifications have no sound and a normal priority
+	msg := testPushMessage()
+	msg.Priority, msg.Sound = PushPriorityNormal, ""
+	require.NoError(t, sender.Send(context.Background(), PushDevice{Platform: PushPlatformWeb, Token: "device-token"}, msg))
+	message := provider.expect(t).payload["message"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"priority": "NORMAL", "notification": map[string]interface{}{}}, message["android"])
+
+	_, err = newFCMSender("project", filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+	require.NoError(t, os.WriteFile(credsFile, []byte("not json"), 0600))
+	_, err = newFCMSender("project", credsFile)
+	assert.ErrorContains(t, err, "invalid service account credentials")
+}
+
+func TestAPNSSender(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	provider := newFakePushProvider(t, http.StatusOK, "")
+	sender, err := newAPNSSender(provider.URL, writePEM(t, "EC PRIVATE KEY", der), "key-id", "team-id", "io.signoz.app")
+	require.NoError(t, err)
+	require.NoError(t, sender.Send(context.Background(), PushDevice{Platform: PushPlatformIOS, Token: "device-token"}, testPushMessage()))
+
+	req := provider.expect(t)
+	assert.Equal(t, "/3/device/device-token", req.path)
+	assert.Equal(t, "io.signoz.app", req.headers.Get("apns-topic"))
+	assert.Equal(t, "10", req.headers.Get("apns-priority"))
+	assert.Equal(t, "alert", req.headers.Get("apns-push-type"))
+	assert.Equal(t, map[string]interface{}{
+		"aps": map[string]interface{}{
+			"alert": map[string]interface{}{"title": "[FIRING] HighLatency", "body": "latency is high"},
+			"sound": "critical",
+		},
+		"alertname": "HighLatency",
+		"status":    "firing",
+	}, req.payload)
+
+	// the provider token is signed with the key of the team
+	authorization := req.headers.Get("authorization")
+	require.True(t, strings.HasPrefix(authorization, "bearer "), authorization)
+	token, err := jwt.Parse(strings.TrimPrefix(authorization, "bearer "), func(token *jwt.Token) (interface{}, error) {
+		return &key.PublicKey, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ES256", token.Method.Alg())
+	assert.Equal(t, "key-id", token.Header["kid"])
+	assert.Equal(t, "team-id", token.Claims.(jwt.MapClaims)["iss"])
+
+	// the token is reused until it gets old
+	require.NoError(t, sender.Send(context.Background(), PushDevice{Platform: PushPlatformIOS, Token: "device-token"}, testPushMessage()))
+	assert.Equal(t, authorization, provider.expect(t).headers.Get("authorization"))
+	sender.tokenIssued = time.Now().Add(-apnsTokenLifetime)
+	token2, err := sender.providerToken()
+	require.NoError(t, err)
+	assert.NotEqual(t, strings.TrimPrefix(authorization, "bearer "), token2)
+
+	_, err = newAPNSSender(provider.URL, writePEM(t, "EC PRIVATE KEY", []byte("garbage")), "key-id", "team-id", "io.signoz.app")
+	assert.ErrorContains(t, err, "invalid APNs key")
+}
+
+func TestPostPushErrors(t *testing.T) {
+	// the reason given by the provider is kept, up to 1KB
+	provider := newFakePushProvider(t, http.StatusBadRequest, `{"reason": "BadDeviceToken"}`+strings.Repeat(" ", 2048))
+	err := postPush(context.Background(), provider.Client(), provider.URL, map[string]string{}, nil)
+	require.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), `push provider returned 400 Bad Request: {"reason": "BadDeviceToken"}`), err.Error())
+	assert.Len(t, err.Error(), len("push provider returned 400 Bad Request: ")+1024)
+	assert.Equal(t, contentType, provider.expect(t).headers.Get("Content-Type"))
+
+	// unreachable providers fail the delivery
+	provider.Close()
+	err = postPush(context.Background(), provider.Client(), provider.URL, map[string]string{}, nil)
+	assert.Error(t, err)
+
+	// and the providers out of the egress allowlist are not contacted
+	client := &http.Client{Transport: NewEgressPolicy("fcm.googleapis.com").Transport()}
+	err = postPush(context.Background(), client, "http://127.0.0.1:1/send", map[string]string{}, nil)
+	assert.ErrorContains(t, err, "is not in the notification egress allowlist")
+}
+
+type fakePushStore struct {
+	fakeLocaleStore
+	channels []model.ChannelItem
+	devices  map[string][]PushDevice
+}
+
+func (s *fakePushStore) GetChannels() (*[]model.ChannelItem, *model.ApiError) {
+	return &s.channels, nil
+}
+
+func (s *fakePushStore) GetPushDevicesForTargets(users []string, groups []string) ([]PushDevice, *model.ApiError) {
+	var devices []PushDevice
+	for _, user := range users {
+		devices = append(devices, s.devices[user]...)
+	}
+	return devices, nil
+}
+
+// recordingPushSender records the messages and fails for the listed tokens
+type recordingPushSender struct {
+	mtx  sync.Mutex
+	sent map[string][]PushMessage
+	fail map[string]bool
+}
+
+func (s *recordingPushSender) Send(ctx context.Context, device PushDevice, msg PushMessage) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.fail[device.Token] {
+		return fmt.Errorf("unregistered")
+	}
+	s.sent[device.Token] = append(s.sent[device.Token], msg)
+	return nil
+}
+
+func TestPushDispatcher(t *testing.T) {
+	channel := func(name string, receiver Receiver) model.ChannelItem {
+		data, err := json.Marshal(receiver)
+		require.NoError(t, err)
+		return model.ChannelItem{Name: name, Data: string(data)}
+	}
+	store := &fakePushStore{
+		channels: []model.ChannelItem{
+			channel("oncall", Receiver{Name: "oncall", Locale: "de", PushConfigs: []PushConfig{{Users: []string{"oncall@example.com"}}}}),
+			channel("team", Receiver{Name: "team", PushConfigs: []PushConfig{{Users: []string{"team@example.com"}, SendResolved: true}}}),
+			channel("slack", Receiver{Name: "slack", SlackConfigs: []interface{}{map[string]interface{}{"channel": "#alerts"}}}),
+		},
+		devices: map[string][]PushDevice{
+			"oncall@example.com": {{Id: 1, Platform: PushPlatformAndroid, Token: "oncall-android"}},
+			"team@example.com": {
+				{Id: 2, Platform: PushPlatformAndroid, Token: "team-android"},
+				{Id: 3, Platform: PushPlatformIOS, Token: "team-ios"},
+			},
+		},
+	}
+	sender := &recordingPushSender{sent: map[string][]PushMessage{}, fail: map[string]bool{}}
+	// ios is not configured, its devices are skipped
+	dispatcher := NewPushDispatcher(store, map[string]PushSender{PushPlatformAndroid: sender})
+	assert.True(t, dispatcher.Enabled())
+
+	firing := &Alert{
+		Labels:      labels.FromMap(map[string]string{"alertname": "HighLatency", "severity": "critical"}),
+		Annotations: labels.FromMap(map[string]string{"summary": "latency is high"}),
+		StartsAt:    time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+	resolved := *firing
+	resolved.EndsAt = firing.StartsAt.Add(time.Hour)
+	dispatcher.dispatch(context.Background(), []*Alert{firing, &resolved})
+
+	// the resolved alerts only go to the channels sending them
+	require.Len(t, sender.sent["oncall-android"], 1)
+	assert.Equal(t, "[AUSGELÖST] HighLatency", sender.sent["oncall-android"][0].Title)
+	assert.Equal(t, PushPriorityHigh, sender.sent["oncall-android"][0].Priority)
+	require.Len(t, sender.sent["team-android"], 2)
+	assert.Equal(t, "[FIRING] HighLatency", sender.sent["team-android"][0].Title)
+	assert.Equal(t, "[RESOLVED] HighLatency", sender.sent["team-android"][1].Title)
+	assert.Empty(t, sender.sent["team-ios"])
+
+	// the preferred channels of the alert are the only ones notified
+	routed := *firing
+	routed.Receivers = []string{"team"}
+	dispatcher.dispatch(context.Background(), []*Alert{&routed})
+	assert.Len(t, sender.sent["oncall-android"], 1)
+	assert.Len(t, sender.sent["team-android"], 3)
+
+	// the failed devices are reported
+	sender.fail["team-android"] = true
+	apiErr := dispatcher.Test(context.Background(), &Receiver{Name: "team", PushConfigs: []PushConfig{{Users: []string{"team@example.com"}}}})
+	require.NotNil(t, apiErr)
+	assert.Equal(t, model.ErrorInternal, apiErr.Typ)
+	assert.EqualError(t, apiErr.Err, "push delivery failed for device 2: unregistered")
+
+	apiErr = NewPushDispatcher(store, nil).Test(context.Background(), &Receiver{Name: "team"})
+	require.NotNil(t, apiErr)
+	assert.Equal(t, model.ErrorBadData, apiErr.Typ)
+}
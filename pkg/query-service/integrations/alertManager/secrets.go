@@ -0,0 +1,505 @@
+package alertManager
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"go.signoz.io/signoz/pkg/query-service/constants"
+	"go.uber.org/zap"
+)
+
+const (
+	// SecretRedacted replaces the secrets of the channels read through the
+	// API, a secret set to it on edit keeps its stored value
+	SecretRedacted = "[REDACTED]"
+
+	// encryptedPrefix marks the secrets encrypted at rest, followed by the
+	// data key encrypted with the master key and the secret encrypted with
+	// the data key
+	encryptedPrefix = "enc:v1:"
+
+	// secretRefPrefix marks the secrets read from an external store, e.g.
+	// secret://env/SLACK_URL, secret://vault/secret/data/slack#url or
+	// secret://aws/prod/slack#url. Only the references starting with one of
+	// SECRETS_ALLOWED_PREFIXES are read.
+	secretRefPrefix = "secret://"
+
+	// secretRefTTL is how long the values of the external stores are cached
+	secretRefTTL = 5 * time.Minute
+)
+
+// secretKeys are the fields of the integrations holding credentials
+var secretKeys = map[string]bool{
+	"api_url":       true,
+	"webhook_url":   true,
+	"url":           true,
+	"api_key":       true,
+	"api_secret":    true,
+	"routing_key":   true,
+	"service_key":   true,
+	"user_key":      true,
+	"token":         true,
+	"bearer_token":  true,
+	"password":      true,
+	"auth_password": true,
+	"auth_secret":   true,
+	"secret_key":    true,
+	"access_key":    true,
+	"credentials":   true,
+}
+
+// SecretStore encrypts the secrets of the channels at rest with envelope
+// encryption, every secret with its own data key encrypted with the master
+// key, and reads the secrets referenced in the external stores
+type SecretStore struct {
+	// master is nil when no key is configured, the secrets are then
+	// stored as they are
+	master cipher.AEAD
+	err    error
+
+	// allowedRefs are the prefixes of the references that can be read, so
+	// that the users saving channels can not read any secret of the server
+	allowedRefs []string
+	vaultAddr   string
+	vaultToken  string
+	client      *http.Client
+
+	mtx   sync.Mutex
+	cache map[string]cachedSecret
+	aws   *secretsmanager.SecretsManager
+}
+
+type cachedSecret struct {
+	value   string
+	expires time.Time
+}
+
+// NewSecretStore returns a store encrypting with the base64 encoded 32
+// bytes master key, the secrets are not encrypted without a key
+func NewSecretStore(key string) (*SecretStore, error) {
+	s := &SecretStore{
+		allowedRefs: parseAllowedRefs(constants.SecretsAllowedPrefixes),
+		vaultAddr:   strings.TrimSuffix(os.Getenv("VAULT_ADDR"), "/"),
+		vaultToken:  os.Getenv("VAULT_TOKEN"),
+		client:      &http.Client{Timeout: 10 * time.Second},
+		cache:       map[string]cachedSecret{},
+	}
+	if key == "" {
+		return s, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid channel secrets key: %w", err)
+	}
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("invalid channel secrets key, expected 32 bytes, got %d", len(raw))
+	}
+	if s.master, err = newAEAD(raw); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+var (
+	defaultSecretStore     *SecretStore
+	defaultSecretStoreOnce sync.Once
+)
+
+// DefaultSecretStore returns the store configured with
+// CHANNEL_SECRETS_KEY, a store refusing to save the channels when the key
+// is invalid
+func DefaultSecretStore() *SecretStore {
+	defaultSecretStoreOnce.Do(func() {
+		store, err := NewSecretStore(constants.ChannelSecretsKey)
+		if err != nil {
+			zap.L().Error("failed to configure the channel secrets, the channels can not be saved", zap.Error(err))
+			store, _ = NewSecretStore("")
+			store.err = err
+		}
+		defaultSecretStore = store
+	})
+	return defaultSecretStore
+}
+
+// parseAllowedRefs returns the prefixes of the references allowed by the
+// spec. The prefixes of a whole store, e.g. env/, are ignored, the
+// references to environment variables name the prefix of the variables.
+func parseAllowedRefs(spec string) []string {
+	var prefixes []string
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimPrefix(strings.TrimSpace(entry), secretRefPrefix)
+		store, path, _ := strings.Cut(entry, "/")
+		if store == "" || strings.Trim(path, "/") == "" {
+			if entry != "" {
+				zap.L().Warn("ignoring the allowed secret prefix of a whole store", zap.String("prefix", entry))
+			}
+			continue
+		}
+		prefixes = append(prefixes, entry)
+	}
+	return prefixes
+}
+
+// refAllowed tells whether the reference, without its scheme, starts with
+// one of the allowed prefixes
+func (s *SecretStore) refAllowed(name string) bool {
+	if strings.Contains(name, "..") {
+		return false
+	}
+	for _, prefix := range s.allowedRefs {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(aead cipher.AEAD, plain []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+func open(aead cipher.AEAD, data []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted secret is too short")
+	}
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+}
+
+func (s *SecretStore) encrypt(value string) (string, error) {
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", err
+	}
+	wrapped, err := seal(s.master, dataKey)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(aead, []byte(value))
+	if err != nil {
+		return "", err
+	}
+	enc := base64.StdEncoding
+	return encryptedPrefix + enc.EncodeToString(wrapped) + ":" + enc.EncodeToString(ciphertext), nil
+}
+
+func (s *SecretStore) decrypt(value string) (string, error) {
+	if s.master == nil {
+		return "", fmt.Errorf("the channel secrets are encrypted but CHANNEL_SECRETS_KEY is not set")
+	}
+	parts := strings.SplitN(strings.TrimPrefix(value, encryptedPrefix), ":", 2)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("malformed encrypted secret")
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := open(s.master, wrapped)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt the data key of the secret: %w", err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plain, err := open(aead, ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt the secret: %w", err)
+	}
+	return string(plain), nil
+}
+
+// walkSecrets replaces the secrets of the channel json with the result of
+// f, the secrets are the non empty strings of the secret keys
+func walkSecrets(data []byte, f func(value string) (string, error)) ([]byte, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	var walk func(v interface{}) error
+	walk = func(v interface{}) error {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			for k, item := range t {
+				if str, ok := item.(string); ok && str != "" && secretKeys[k] {
+					replaced, err := f(str)
+					if err != nil {
+						return fmt.Errorf("%s: %w", k, err)
+					}
+					t[k] = replaced
+					continue
+				}
+				if err := walk(item); err != nil {
+					return err
+				}
+			}
+		case []interface{}:
+			for _, item := range t {
+				if err := walk(item); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk(v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// Seal encrypts the secrets of the channel json to store it, the
+// references to the external stores are stored as they are
+func (s *SecretStore) Seal(data []byte) ([]byte, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.master == nil {
+		return data, nil
+	}
+	return walkSecrets(data, func(value string) (string, error) {
+		if strings.HasPrefix(value, encryptedPrefix) || strings.HasPrefix(value, secretRefPrefix) {
+			return value, nil
+		}
+		return s.encrypt(value)
+	})
+}
+
+// Open decrypts the secrets of the stored channel json and reads the
+// secrets referenced in the external stores
+func (s *SecretStore) Open(ctx context.Context, data []byte) ([]byte, error) {
+	return walkSecrets(data, func(value string) (string, error) {
+		switch {
+		case strings.HasPrefix(value, encryptedPrefix):
+			return s.decrypt(value)
+		case strings.HasPrefix(value, secretRefPrefix):
+			return s.resolve(ctx, value)
+		}
+		return value, nil
+	})
+}
+
+// RedactSecrets replaces the secrets of the channel json for the API reads
+func RedactSecrets(data []byte) ([]byte, error) {
+	return walkSecrets(data, func(string) (string, error) {
+		return SecretRedacted, nil
+	})
+}
+
+// RedactShared reduces the channel json to its name and the number of
+// configs of each integration, for the orgs the channel is shared with
+func RedactShared(data []byte) ([]byte, error) {
+	var channel map[string]interface{}
+	if err := json.Unmarshal(data, &channel); err != nil {
+		return nil, err
+	}
+	shared := map[string]interface{}{"name": channel["name"]}
+	for k, v := range channel {
+		configs, ok := v.([]interface{})
+		if !ok || !strings.HasSuffix(k, "_configs") {
+			continue
+		}
+		redacted := make([]interface{}, len(configs))
+		for i := range configs {
+			redacted[i] = map[string]interface{}{}
+		}
+		shared[k] = redacted
+	}
+	return json.Marshal(shared)
+}
+
+// RestoreRedacted sets the secrets of the edited channel json left
+// redacted to their value in the stored json
+func RestoreRedacted(data, stored []byte) ([]byte, error) {
+	var storedValue interface{}
+	if err := json.Unmarshal(stored, &storedValue); err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	var restore func(v, stored interface{}) error
+	restore = func(v, stored interface{}) error {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			old, _ := stored.(map[string]interface{})
+			for k, item := range t {
+				if item == SecretRedacted {
+					oldValue, ok := old[k].(string)
+					if !ok {
+						return fmt.Errorf("%s is redacted but has no stored value", k)
+					}
+					t[k] = oldValue
+					continue
+				}
+				if err := restore(item, old[k]); err != nil {
+					return err
+				}
+			}
+		case []interface{}:
+			old, _ := stored.([]interface{})
+			for i, item := range t {
+				var oldItem interface{}
+				if i < len(old) {
+					oldItem = old[i]
+				}
+				if err := restore(item, oldItem); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := restore(v, storedValue); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// resolve reads the secret of the reference from its store
+func (s *SecretStore) resolve(ctx context.Context, ref string) (string, error) {
+	s.mtx.Lock()
+	cached, ok := s.cache[ref]
+	s.mtx.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.value, nil
+	}
+
+	name := strings.TrimPrefix(ref, secretRefPrefix)
+	if !s.refAllowed(name) {
+		return "", fmt.Errorf("secret %s is not allowed, the references have to start with one of SECRETS_ALLOWED_PREFIXES", ref)
+	}
+	store, path, _ := strings.Cut(name, "/")
+	path, key, _ := strings.Cut(path, "#")
+	var value string
+	var err error
+	switch store {
+	case "env":
+		var found bool
+		if value, found = os.LookupEnv(path); !found {
+			err = fmt.Errorf("environment variable %s is not set", path)
+		}
+	case "vault":
+		value, err = s.readVault(ctx, path, key)
+	case "aws":
+		value, err = s.readAWS(ctx, path, key)
+	default:
+		err = fmt.Errorf("unknown secret store %q, expected env, vault or aws", store)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret %s: %w", ref, err)
+	}
+
+	s.mtx.Lock()
+	s.cache[ref] = cachedSecret{value: value, expires: time.Now().Add(secretRefTTL)}
+	s.mtx.Unlock()
+	return value, nil
+}
+
+// readVault reads the key of a secret of the Vault KV engine, version 2
+// nests the keys under data
+func (s *SecretStore) readVault(ctx context.Context, path, key string) (string, error) {
+	if s.vaultAddr == "" {
+		return "", fmt.Errorf("VAULT_ADDR is not set")
+	}
+	if key == "" {
+		return "", fmt.Errorf("the key of the vault secret is required")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.vaultAddr+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", s.vaultToken)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault responded %s", resp.Status)
+	}
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	data := body.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		data = nested
+	}
+	value, ok := data[key].(string)
+	if !ok {
+		return "", fmt.Errorf("key %s not found", key)
+	}
+	return value, nil
+}
+
+// readAWS reads a secret of AWS Secrets Manager with the default
+// credentials, the key selects a field of a json secret
+func (s *SecretStore) readAWS(ctx context.Context, id, key string) (string, error) {
+	s.mtx.Lock()
+	if s.aws == nil {
+		sess, err := session.NewSession()
+		if err != nil {
+			s.mtx.Unlock()
+			return "", err
+		}
+		s.aws = secretsmanager.New(sess)
+	}
+	client := s.aws
+	s.mtx.Unlock()
+
+	out, err := client.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(id)})
+	if err != nil {
+		return "", err
+	}
+	value := aws.StringValue(out.SecretString)
+	if key == "" {
+		return value, nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &fields); err != nil {
+		return "", fmt.Errorf("secret is not json: %w", err)
+	}
+	field, ok := fields[key].(string)
+	if !ok {
+		return "", fmt.Errorf("key %s not found", key)
+	}
+	return field, nil
+}
@@ -0,0 +1,118 @@
+package alertManager
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSecretStore(t *testing.T, allowed string) *SecretStore {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	s, err := NewSecretStore(base64.StdEncoding.EncodeToString(key))
+	require.NoError(t, err)
+	s.allowedRefs = parseAllowedRefs(allowed)
+	return s
+}
+
+func TestSecretStoreSealOpen(t *testing.T) {
+	s := testSecretStore(t, "")
+	channel := `{"name":"oncall","slack_configs":[{"api_url":"https://hooks.slack.com/services/T0/B0/x","channel":"#oncall"}],"email_configs":[{"to":"oncall@example.com","auth_password":"hunter2"}]}`
+
+	stored, err := s.Seal([]byte(channel))
+	require.NoError(t, err)
+	assert.NotContains(t, string(stored), "hooks.slack.com")
+	assert.NotContains(t, string(stored), "hunter2")
+	assert.Contains(t, string(stored), `"channel":"#oncall"`)
+	assert.Contains(t, string(stored), encryptedPrefix)
+
+	// sealing again keeps the encrypted secrets
+	resealed, err := s.Seal(stored)
+	require.NoError(t, err)
+	assert.Equal(t, string(stored), string(resealed))
+
+	opened, err := s.Open(context.Background(), stored)
+	require.NoError(t, err)
+	assert.JSONEq(t, channel, string(opened))
+
+	// the secrets can not be read with another key
+	_, err = testSecretStore(t, "").Open(context.Background(), stored)
+	assert.Error(t, err)
+}
+
+func TestSecretStoreWithoutKey(t *testing.T) {
+	s, err := NewSecretStore("")
+	require.NoError(t, err)
+	channel := `{"name":"oncall","webhook_configs":[{"url":"https://hooks.example.com"}]}`
+	stored, err := s.Seal([]byte(channel))
+	require.NoError(t, err)
+	assert.Equal(t, channel, string(stored))
+
+	_, err = NewSecretStore("c2hvcnQ=")
+	assert.Error(t, err)
+}
+
+func TestRedactSecrets(t *testing.T) {
+	stored := `{"name":"oncall","slack_configs":[{"api_url":"https://hooks.slack.com/services/T0/B0/x","channel":"#oncall"}],"pagerduty_configs":[{"routing_key":"abc"}]}`
+	redacted, err := RedactSecrets([]byte(stored))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":"oncall","slack_configs":[{"api_url":"[REDACTED]","channel":"#oncall"}],"pagerduty_configs":[{"routing_key":"[REDACTED]"}]}`, string(redacted))
+
+	// the secrets left redacted keep their stored value, the changed ones
+	// are replaced
+	edited := strings.Replace(string(redacted), `"#oncall"`, `"#alerts"`, 1)
+	edited = strings.Replace(edited, `{"routing_key":"[REDACTED]"}`, `{"routing_key":"def"}`, 1)
+	restored, err := RestoreRedacted([]byte(edited), []byte(stored))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":"oncall","slack_configs":[{"api_url":"https://hooks.slack.com/services/T0/B0/x","channel":"#alerts"}],"pagerduty_configs":[{"routing_key":"def"}]}`, string(restored))
+
+	// a redacted secret without a stored value is refused
+	_, err = RestoreRedacted([]byte(`{"webhook_configs":[{"url":"[REDACTED]"}]}`), []byte(`{}`))
+	assert.Error(t, err)
+}
+
+func TestRedactShared(t *testing.T) {
+	shared, err := RedactShared([]byte(`{"name":"oncall","team":"payments","slack_configs":[{"api_url":"[REDACTED]","channel":"#oncall"}],"email_configs":[{"to":"a@example.com"},{"to":"b@example.com"}]}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":"oncall","slack_configs":[{}],"email_configs":[{},{}]}`, string(shared))
+}
+
+func TestSecretRefs(t *testing.T) {
+	t.Setenv("SIGNOZ_CHANNEL_SLACK", "https://hooks.slack.com/services/T0/B0/x")
+	t.Setenv("CLICKHOUSE_PASSWORD", "hunter2")
+	s := testSecretStore(t, "env/SIGNOZ_CHANNEL_, env/, secret://vault/secret/data/signoz/")
+
+	opened, err := s.Open(context.Background(), []byte(`{"slack_configs":[{"api_url":"secret://env/SIGNOZ_CHANNEL_SLACK"}]}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"slack_configs":[{"api_url":"https://hooks.slack.com/services/T0/B0/x"}]}`, string(opened))
+
+	// the references are stored as they are
+	stored, err := s.Seal([]byte(`{"slack_configs":[{"api_url":"secret://env/SIGNOZ_CHANNEL_SLACK"}]}`))
+	require.NoError(t, err)
+	assert.Contains(t, string(stored), "secret://env/SIGNOZ_CHANNEL_SLACK")
+
+	for _, ref := range []string{
+		"secret://env/CLICKHOUSE_PASSWORD",
+		"secret://env/VAULT_TOKEN",
+		"secret://vault/secret/data/prod/db#password",
+		"secret://vault/secret/data/signoz/../prod/db#password",
+		"secret://aws/prod/db#password",
+	} {
+		t.Run(ref, func(t *testing.T) {
+			_, err := s.Open(context.Background(), []byte(`{"webhook_configs":[{"url":"`+ref+`"}]}`))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "is not allowed")
+			assert.NotContains(t, err.Error(), "hunter2")
+		})
+	}
+
+	// no reference is allowed by default
+	_, err = testSecretStore(t, "").Open(context.Background(), []byte(`{"webhook_configs":[{"url":"secret://env/SIGNOZ_CHANNEL_SLACK"}]}`))
+	assert.Error(t, err)
+}
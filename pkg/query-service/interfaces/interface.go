@@ -17,8 +17,16 @@ type Reader interface {
 	GetChannel(id string) (*model.ChannelItem, *model.ApiError)
 	GetChannels() (*[]model.ChannelItem, *model.ApiError)
 	DeleteChannel(id string) *model.ApiError
-	CreateChannel(receiver *am.Receiver) (*am.Receiver, *model.ApiError)
+	CreateChannel(receiver *am.Receiver, orgId string) (*am.Receiver, *model.ApiError)
 	EditChannel(receiver *am.Receiver, id string) (*am.Receiver, *model.ApiError)
+	GetLocales() (*[]am.Locale, *model.ApiError)
+	GetLocale(name string) (*am.Locale, *model.ApiError)
+	SaveLocale(locale *am.Locale) (*am.Locale, *model.ApiError)
+	DeleteLocale(name string) *model.ApiError
+	GetPushDevices(userId string) (*[]am.PushDevice, *model.ApiError)
+	RegisterPushDevice(device *am.PushDevice) (*am.PushDevice, *model.ApiError)
+	DeletePushDevice(userId string, id string) *model.ApiError
+	GetPushDevicesForTargets(users []string, groups []string) ([]am.PushDevice, *model.ApiError)
 
 	GetInstantQueryMetricsResult(ctx context.Context, query *model.InstantQueryMetricsParams) (*promql.Result, *stats.QueryStats, *model.ApiError)
 	GetQueryRangeResult(ctx context.Context, query *model.QueryRangeParams) (*promql.Result, *stats.QueryStats, *model.ApiError)
@@ -109,6 +117,10 @@ type Reader interface {
 	GetMetricMetadata(context.Context, string, string) (*v3.MetricMetadataResponse, error)
 
 	AddRuleStateHistory(ctx context.Context, ruleStateHistory []v3.RuleStateHistory) error
+	SetRuleStateHistoryTTL(ctx context.Context, detail, transitions time.Duration) error
+	DeleteRuleStateHistory(ctx context.Context, detailBefore, transitionsBefore int64) error
+	ReadRuleStateChanges(ctx context.Context, start, end int64) ([]v3.RuleStateHistory, error)
+	GetRulesLastFired(ctx context.Context) (map[string]int64, error)
 	GetOverallStateTransitions(ctx context.Context, ruleID string, params *v3.QueryRuleStateHistory) ([]v3.RuleStateTransition, error)
 	ReadRuleStateHistoryByRuleID(ctx context.Context, ruleID string, params *v3.QueryRuleStateHistory) (*v3.RuleStateTimeline, error)
 	GetTotalTriggers(ctx context.Context, ruleID string, params *v3.QueryRuleStateHistory) (uint64, error)
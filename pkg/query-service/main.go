@@ -8,6 +8,11 @@ import (
 	"syscall"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 	"go.signoz.io/signoz/pkg/query-service/app"
 	"go.signoz.io/signoz/pkg/query-service/auth"
 	"go.signoz.io/signoz/pkg/query-service/constants"
@@ -27,6 +32,25 @@ func initZapLog() *zap.Logger {
 	return logger
 }
 
+// initTracerProvider exports the spans of the query service, e.g. of the
+// rule evaluations, to the OTLP endpoint
+func initTracerProvider(res *resource.Resource) (*sdktrace.TracerProvider, error) {
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithInsecure()}
+	if constants.OTLPTarget != "" {
+		opts = append(opts, otlptracegrpc.WithEndpoint(constants.OTLPTarget))
+	}
+	exporter, err := otlptracegrpc.New(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	return tp, nil
+}
+
 func main() {
 	var promConfigPath, skipTopLvlOpsPath string
 
@@ -36,6 +60,7 @@ func main() {
 	// the url used to build link in the alert messages in slack and other systems
 	var ruleRepoURL, cacheConfigPath, fluxInterval string
 	var cluster string
+	var rulesGRPCHostPort string
 
 	var preferSpanMetrics bool
 
@@ -55,6 +80,7 @@ func main() {
 	flag.StringVar(&cluster, "cluster-name", "cluster", "(cluster name - defaults to 'cluster')")
 	flag.IntVar(&maxIdleConns, "max-idle-conns", 50, "(number of connections to maintain in the pool, only used with clickhouse if not set in ClickHouseUrl env var DSN.)")
 	flag.IntVar(&maxOpenConns, "max-open-conns", 100, "(max connections for use at any time, only used with clickhouse if not set in ClickHouseUrl env var DSN.)")
+	flag.StringVar(&rulesGRPCHostPort, "rules.grpc-addr", "", "(address to serve the grpc API of the rules, disabled when empty)")
 	flag.DurationVar(&dialTimeout, "dial-timeout", 5*time.Second, "(the maximum time to establish a connection, only used with clickhouse if not set in ClickHouseUrl env var DSN.)")
 	flag.Parse()
 
@@ -65,6 +91,18 @@ func main() {
 	logger := loggerMgr.Sugar()
 	version.PrintVersion()
 
+	if constants.IsRuleTracingEnabled() {
+		tp, err := initTracerProvider(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String("query-service"),
+		))
+		if err != nil {
+			zap.L().Error("Failed to initialize the tracer provider", zap.Error(err))
+		} else {
+			defer tp.Shutdown(context.Background())
+		}
+	}
+
 	serverOptions := &app.ServerOptions{
 		HTTPHostPort:      constants.HTTPHostPort,
 		PromConfigPath:    promConfigPath,
@@ -79,6 +117,7 @@ func main() {
 		CacheConfigPath:   cacheConfigPath,
 		FluxInterval:      fluxInterval,
 		Cluster:           cluster,
+		RulesGRPCHostPort: rulesGRPCHostPort,
 	}
 
 	// Read the jwt secret key
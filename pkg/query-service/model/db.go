@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -35,6 +36,31 @@ type User struct {
 	ProfilePictureURL string `json:"profilePictureURL" db:"profile_picture_url"`
 	OrgId             string `json:"orgId,omitempty" db:"org_id"`
 	GroupId           string `json:"groupId,omitempty" db:"group_id"`
+	// Teams are the teams of the user, mapped from the groups of the
+	// identity provider on the SSO logins
+	Teams UserTeams `json:"teams,omitempty" db:"teams"`
+}
+
+// UserTeams are the teams of a user, stored comma separated
+type UserTeams []string
+
+func (t *UserTeams) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	}
+	*t = nil
+	if s != "" {
+		*t = strings.Split(s, ",")
+	}
+	return nil
+}
+
+func (t UserTeams) Value() (driver.Value, error) {
+	return strings.Join(t, ","), nil
 }
 
 type ApdexSettings struct {
@@ -87,6 +113,9 @@ type UserPayload struct {
 	Role         string   `json:"role"`
 	Organization string   `json:"organization"`
 	Flags        UserFlag `json:"flags"`
+	// Scopes limits the requests of an API token to the APIs of the
+	// scopes, the requests without scopes reach all the APIs of the role
+	Scopes []string `json:"scopes,omitempty" db:"-"`
 }
 
 type Group struct {
@@ -160,6 +160,22 @@ type ChannelItem struct {
 	Name      string    `json:"name" db:"name"`
 	Type      string    `json:"type" db:"type"`
 	Data      string    `json:"data" db:"data"`
+
+	// OrgId is the org that owns the channel, channels created before
+	// channels were scoped have no org and are visible to everyone
+	OrgId string `json:"orgId" db:"org_id"`
+	// Shared channels can be used by the rules of other orgs
+	Shared bool `json:"shared" db:"shared"`
+}
+
+// VisibleTo tells whether the rules of the org can use the channel
+func (c *ChannelItem) VisibleTo(orgId string) bool {
+	return c.OrgId == "" || c.OrgId == orgId || c.Shared
+}
+
+// OwnedBy tells whether the org can edit or delete the channel
+func (c *ChannelItem) OwnedBy(orgId string) bool {
+	return c.OrgId == "" || c.OrgId == orgId
 }
 
 // AlertDiscovery has info for all active alerts.
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChannelItemAccess(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		channel ChannelItem
+		visible bool
+		owned   bool
+	}{
+		{name: "own channel", channel: ChannelItem{OrgId: "acme"}, visible: true, owned: true},
+		{name: "channel without org", channel: ChannelItem{}, visible: true, owned: true},
+		{name: "channel of another org", channel: ChannelItem{OrgId: "globex"}, visible: false, owned: false},
+		{name: "channel shared by another org", channel: ChannelItem{OrgId: "globex", Shared: true}, visible: true, owned: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.visible, tc.channel.VisibleTo("acme"))
+			assert.Equal(t, tc.owned, tc.channel.OwnedBy("acme"))
+		})
+	}
+}
@@ -509,6 +509,49 @@ const (
 	Cumulative  Temporality = "Cumulative"
 )
 
+// Downsampling selects the samples table a metrics query reads
+type Downsampling string
+
+const (
+	// DownsamplingUnspecified reads the raw samples
+	DownsamplingUnspecified Downsampling = ""
+	// DownsamplingRaw reads the raw samples
+	DownsamplingRaw Downsampling = "raw"
+	// DownsamplingAuto reads the coarsest aggregated samples that fit the
+	// time range and the step of the query
+	DownsamplingAuto Downsampling = "auto"
+	// Downsampling5m and Downsampling30m read the samples aggregated by 5
+	// and 30 minutes when the step of the query is a multiple of it
+	Downsampling5m  Downsampling = "5m"
+	Downsampling30m Downsampling = "30m"
+	// Downsampling1h reads the samples aggregated by 30 minutes in whole
+	// hours, when the step of the query is a multiple of an hour
+	Downsampling1h Downsampling = "1h"
+)
+
+func (d Downsampling) Validate() error {
+	switch d {
+	case DownsamplingUnspecified, DownsamplingRaw, DownsamplingAuto, Downsampling5m, Downsampling30m, Downsampling1h:
+		return nil
+	default:
+		return fmt.Errorf("invalid downsampling: %s", d)
+	}
+}
+
+// Resolution returns the interval of the aggregated samples read by the
+// downsampling, zero for the raw samples and the automatic selection
+func (d Downsampling) Resolution() time.Duration {
+	switch d {
+	case Downsampling5m:
+		return 5 * time.Minute
+	case Downsampling30m:
+		return 30 * time.Minute
+	case Downsampling1h:
+		return time.Hour
+	}
+	return 0
+}
+
 type TimeAggregation string
 
 const (
@@ -698,6 +741,7 @@ type BuilderQuery struct {
 	TimeAggregation    TimeAggregation   `json:"timeAggregation,omitempty"`
 	SpaceAggregation   SpaceAggregation  `json:"spaceAggregation,omitempty"`
 	Functions          []Function        `json:"functions,omitempty"`
+	Downsampling       Downsampling      `json:"downsampling,omitempty"`
 	ShiftBy            int64
 }
 
@@ -770,6 +814,9 @@ func (b *BuilderQuery) Validate(panelType PanelType) error {
 		if b.AggregateAttribute == (AttributeKey{}) && b.AggregateOperator.RequireAttribute(b.DataSource) {
 			return fmt.Errorf("aggregate attribute is required")
 		}
+		if err := b.Downsampling.Validate(); err != nil {
+			return err
+		}
 	}
 
 	if b.Filters != nil {
@@ -1227,6 +1274,181 @@ func (r *QueryRuleStateHistory) Validate() error {
 	return nil
 }
 
+type QueryAlertAnalytics struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	// Step is the interval of the trend in seconds, a day by default
+	Step int64 `json:"step"`
+	// TeamLabel is the label of the rules naming their team
+	TeamLabel string `json:"teamLabel"`
+	// Limit is the number of noisiest label sets returned for every rule
+	Limit int `json:"limit"`
+}
+
+func (q *QueryAlertAnalytics) Validate() error {
+	if q.Start == 0 || q.End == 0 {
+		return fmt.Errorf("start and end are required")
+	}
+	if q.End <= q.Start {
+		return fmt.Errorf("end must be after start")
+	}
+	if q.Step < 0 || q.Limit < 0 {
+		return fmt.Errorf("step and limit must be greater than 0")
+	}
+	if q.Step == 0 {
+		q.Step = 24 * 60 * 60
+	}
+	if q.TeamLabel == "" {
+		q.TeamLabel = "team"
+	}
+	if q.Limit == 0 {
+		q.Limit = 5
+	}
+	return nil
+}
+
+// AlertAnalytics summarizes the alerts of the rules over a time range
+type AlertAnalytics struct {
+	Rules []RuleAnalytics   `json:"rules"`
+	Teams []TeamAnalytics   `json:"teams"`
+	Trend []AnalyticsBucket `json:"trend"`
+}
+
+type RuleAnalytics struct {
+	RuleID   string `json:"ruleID"`
+	RuleName string `json:"ruleName"`
+	Team     string `json:"team"`
+	// Triggers is the number of times an alert of the rule started firing
+	Triggers uint64 `json:"triggers"`
+	// Resolved is the number of alerts that stopped firing
+	Resolved uint64 `json:"resolved"`
+	// MeanTimeToResolve is the average firing time of the resolved
+	// alerts in seconds
+	MeanTimeToResolve float64 `json:"meanTimeToResolve"`
+	// FiringMinutes is the total time the alerts fired
+	FiringMinutes  float64             `json:"firingMinutes"`
+	NoisiestLabels []LabelSetAnalytics `json:"noisiestLabels"`
+	Trend          []AnalyticsBucket   `json:"trend"`
+}
+
+type LabelSetAnalytics struct {
+	Fingerprint   uint64       `json:"fingerprint"`
+	Labels        LabelsString `json:"labels"`
+	Triggers      uint64       `json:"triggers"`
+	FiringMinutes float64      `json:"firingMinutes"`
+}
+
+type TeamAnalytics struct {
+	Team              string  `json:"team"`
+	Rules             int     `json:"rules"`
+	Triggers          uint64  `json:"triggers"`
+	Resolved          uint64  `json:"resolved"`
+	MeanTimeToResolve float64 `json:"meanTimeToResolve"`
+	FiringMinutes     float64 `json:"firingMinutes"`
+}
+
+// AnalyticsBucket is the number of alerts that started firing in the
+// step starting at Timestamp
+type AnalyticsBucket struct {
+	Timestamp int64  `json:"timestamp"`
+	Triggers  uint64 `json:"triggers"`
+}
+
+type QueryNoiseReport struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	// Limit is the number of rules returned, the noisiest first
+	Limit int `json:"limit"`
+}
+
+func (q *QueryNoiseReport) Validate() error {
+	if q.Start == 0 || q.End == 0 {
+		return fmt.Errorf("start and end are required")
+	}
+	if q.End <= q.Start {
+		return fmt.Errorf("end must be after start")
+	}
+	if q.Limit < 0 {
+		return fmt.Errorf("limit must be greater than 0")
+	}
+	return nil
+}
+
+// RuleNoiseScore tells how noisy the alerts of a rule are
+type RuleNoiseScore struct {
+	RuleID   string `json:"ruleID"`
+	RuleName string `json:"ruleName"`
+	// Score is between 0 and 100, the higher the noisier
+	Score float64 `json:"score"`
+	// Alerts is the number of times an alert of the rule started firing
+	Alerts uint64 `json:"alerts"`
+	// TransitionsPerDay is the average number of state changes a day
+	TransitionsPerDay float64 `json:"transitionsPerDay"`
+	// ShortLivedPercent is the percentage of the resolved alerts that
+	// fired for less than five minutes
+	ShortLivedPercent float64 `json:"shortLivedPercent"`
+	// UnacknowledgedPercent is the percentage of the alerts nobody
+	// acknowledged while they fired
+	UnacknowledgedPercent float64  `json:"unacknowledgedPercent"`
+	Suggestions           []string `json:"suggestions"`
+}
+
+// QueryAlertDigest selects the week summarized by the alert quality digest
+type QueryAlertDigest struct {
+	// End is the end of the week, now by default
+	End int64 `json:"end"`
+	// TeamLabel is the label of the rules naming their team
+	TeamLabel string `json:"teamLabel"`
+	// Limit is the number of noisiest rules listed for every team
+	Limit int `json:"limit"`
+}
+
+func (q *QueryAlertDigest) Validate() error {
+	if q.End < 0 || q.Limit < 0 {
+		return fmt.Errorf("end and limit must be greater than 0")
+	}
+	if q.End == 0 {
+		q.End = time.Now().UnixMilli()
+	}
+	if q.TeamLabel == "" {
+		q.TeamLabel = "team"
+	}
+	if q.Limit == 0 {
+		q.Limit = 3
+	}
+	return nil
+}
+
+// AlertDigest is the weekly summary of the quality of the alerts of every
+// team
+type AlertDigest struct {
+	Start int64        `json:"start"`
+	End   int64        `json:"end"`
+	Teams []TeamDigest `json:"teams"`
+}
+
+// TeamDigest summarizes the alerts of the rules of a team, the rules
+// without the team label are under the empty team
+type TeamDigest struct {
+	Team string `json:"team"`
+	// NewAlerts is the number of alerts that started firing in the week
+	NewAlerts     uint64           `json:"newAlerts"`
+	NoisiestRules []RuleNoiseScore `json:"noisiestRules"`
+	// UnacknowledgedPages is the number of alerts that fired in the week
+	// without anybody acknowledging them
+	UnacknowledgedPages uint64 `json:"unacknowledgedPages"`
+	// SilentRules are the rules that did not fire in the last 90 days
+	SilentRules []SilentRule `json:"silentRules"`
+}
+
+type SilentRule struct {
+	RuleID   string `json:"ruleID"`
+	RuleName string `json:"ruleName"`
+	// LastFired is when an alert of the rule last started firing, zero
+	// when none did within the retention of the state history
+	LastFired int64 `json:"lastFired,omitempty"`
+}
+
 type RuleStateHistoryContributor struct {
 	Fingerprint       uint64       `json:"fingerprint" ch:"fingerprint"`
 	Labels            LabelsString `json:"labels" ch:"labels"`
@@ -0,0 +1,265 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	pmodel "github.com/prometheus/common/model"
+	plabels "github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+const (
+	// DefaultAlertCountPoints is the number of points of the series when
+	// the request does not set the step
+	DefaultAlertCountPoints = 100
+	// MaxAlertCountPoints caps the points of a series, like the query range
+	// APIs do
+	MaxAlertCountPoints = 11000
+	// minAlertCountStep is the least default step, the rules are rarely
+	// evaluated more often
+	minAlertCountStep = int64(60 * 1000)
+
+	// AlertCountStateLabel is the label of the series with the state the
+	// alerts are counted in
+	AlertCountStateLabel = "state"
+
+	// historyNormalState is the state of the resolved alerts in the state
+	// history
+	historyNormalState = "normal"
+)
+
+// alertCountStates are the states of the alerts in the state history the
+// series count
+var alertCountStates = []string{StateFiring.String(), "no_data", "muted"}
+
+// AlertCountsRequest selects the alerts counted by the panels, in
+// milliseconds
+type AlertCountsRequest struct {
+	Start int64
+	End   int64
+	// Step is the interval of the points, set from the range when zero
+	Step int64
+	// Matchers is a selector over the labels of the alerts and their rule,
+	// with the rule name as alertname and the rule id as ruleId
+	Matchers string
+	RuleIDs  []string
+	// States are the states counted, firing, no_data and muted when empty
+	States []string
+	// GroupBy are the labels the counts are broken down by beside the
+	// state
+	GroupBy []string
+
+	matchers []*plabels.Matcher
+}
+
+func (r *AlertCountsRequest) Validate() error {
+	if r.Start == 0 || r.End == 0 {
+		return fmt.Errorf("start and end are required")
+	}
+	if r.End <= r.Start {
+		return fmt.Errorf("end must be after start")
+	}
+	if r.Step < 0 {
+		return fmt.Errorf("step must be greater than 0")
+	}
+	if r.Step == 0 {
+		r.Step = max((r.End-r.Start)/DefaultAlertCountPoints, minAlertCountStep)
+	}
+	if (r.End-r.Start)/r.Step+1 > MaxAlertCountPoints {
+		return fmt.Errorf("the step is too small for the range, at most %d points are returned", MaxAlertCountPoints)
+	}
+	for _, s := range r.States {
+		if !slices.Contains(alertCountStates, s) {
+			return fmt.Errorf("state must be one of %v, got %q", alertCountStates, s)
+		}
+	}
+	if len(r.States) == 0 {
+		r.States = alertCountStates
+	}
+	for _, l := range r.GroupBy {
+		if !pmodel.LabelName(l).IsValid() || l == AlertCountStateLabel {
+			return fmt.Errorf("invalid group by label %q", l)
+		}
+	}
+	r.matchers = nil
+	if r.Matchers != "" {
+		matchers, err := parser.ParseMetricSelector(r.Matchers)
+		if err != nil {
+			return fmt.Errorf("invalid matchers: %w", err)
+		}
+		r.matchers = matchers
+	}
+	return nil
+}
+
+// stateInterval is a period an alert was in a state
+type stateInterval struct {
+	ruleID   string
+	ruleName string
+	labels   v3.LabelsString
+	state    string
+	start    int64
+	// end is past the end of the range for the alerts still in the state
+	end int64
+}
+
+// stateIntervals splits the state changes of the alerts, oldest first,
+// into the periods they were not normal. The alerts resolved before their
+// first change of the range are taken as firing since the start.
+func stateIntervals(changes []v3.RuleStateHistory, start, end int64) []stateInterval {
+	type alertKey struct {
+		ruleID      string
+		fingerprint uint64
+	}
+	var intervals []stateInterval
+	open := map[alertKey]int{}
+	seen := map[alertKey]bool{}
+	for _, c := range changes {
+		key := alertKey{c.RuleID, c.Fingerprint}
+		idx, ok := open[key]
+		if ok {
+			intervals[idx].end = c.UnixMilli
+			delete(open, key)
+		} else if !seen[key] && c.State == historyNormalState {
+			intervals = append(intervals, stateInterval{ruleID: c.RuleID, ruleName: c.RuleName, labels: c.Labels, state: StateFiring.String(), start: start, end: c.UnixMilli})
+		}
+		seen[key] = true
+		if c.State != historyNormalState {
+			open[key] = len(intervals)
+			intervals = append(intervals, stateInterval{ruleID: c.RuleID, ruleName: c.RuleName, labels: c.Labels, state: c.State, start: c.UnixMilli})
+		}
+	}
+	for _, idx := range open {
+		intervals[idx].end = end + 1
+	}
+	return intervals
+}
+
+// buildAlertCounts samples the number of alerts in every state at every
+// step of the range. The alerts are matched on their labels with the
+// labels of their rule, the series are labelled with the state and the
+// group by labels.
+func buildAlertCounts(changes []v3.RuleStateHistory, req *AlertCountsRequest, ruleLabels map[string]map[string]string) []*v3.Series {
+	n := int((req.End-req.Start)/req.Step) + 1
+	// counts are the changes of the counts of every series at the points,
+	// summed up when the series are built
+	counts := map[string][]int{}
+	seriesLabels := map[string]map[string]string{}
+
+	for _, in := range stateIntervals(changes, req.Start, req.End) {
+		if len(req.RuleIDs) > 0 && !slices.Contains(req.RuleIDs, in.ruleID) {
+			continue
+		}
+		if !slices.Contains(req.States, in.state) {
+			continue
+		}
+		lbls := map[string]string{}
+		for k, v := range ruleLabels[in.ruleID] {
+			lbls[k] = v
+		}
+		if in.labels != "" {
+			alertLabels := map[string]string{}
+			if err := json.Unmarshal([]byte(in.labels), &alertLabels); err != nil {
+				continue
+			}
+			for k, v := range alertLabels {
+				lbls[k] = v
+			}
+		}
+		lbls[labels.AlertNameLabel] = in.ruleName
+		lbls[labels.AlertRuleIdLabel] = in.ruleID
+		if !matchesAll(req.matchers, lbls) {
+			continue
+		}
+
+		// the points in the interval, the first at or after its start and
+		// the last before its end
+		from := ceilDiv(in.start-req.Start, req.Step)
+		to := ceilDiv(in.end-req.Start, req.Step)
+		from, to = max(from, 0), min(to, int64(n))
+		if from >= to {
+			continue
+		}
+
+		series := map[string]string{AlertCountStateLabel: in.state}
+		key := []string{in.state}
+		for _, l := range req.GroupBy {
+			series[l] = lbls[l]
+			key = append(key, lbls[l])
+		}
+		k := strings.Join(key, "\xff")
+		if counts[k] == nil {
+			counts[k] = make([]int, n+1)
+			seriesLabels[k] = series
+		}
+		counts[k][from]++
+		counts[k][to]--
+	}
+
+	result := make([]*v3.Series, 0, len(counts))
+	for k, deltas := range counts {
+		s := &v3.Series{Labels: seriesLabels[k], Points: make([]v3.Point, 0, n)}
+		names := make([]string, 0, len(s.Labels))
+		for l := range s.Labels {
+			names = append(names, l)
+		}
+		sort.Strings(names)
+		for _, l := range names {
+			s.LabelsArray = append(s.LabelsArray, map[string]string{l: s.Labels[l]})
+		}
+		count := 0
+		for i := 0; i < n; i++ {
+			count += deltas[i]
+			s.Points = append(s.Points, v3.Point{Timestamp: req.Start + int64(i)*req.Step, Value: float64(count)})
+		}
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return labelsText(result[i].Labels) < labelsText(result[j].Labels)
+	})
+	return result
+}
+
+func ceilDiv(a, b int64) int64 {
+	if a <= 0 {
+		return -(-a / b)
+	}
+	return (a + b - 1) / b
+}
+
+// AlertCounts returns the number of alerts of the rules of the org in
+// every state over the range, from the state history, for the dashboard
+// panels
+func (m *Manager) AlertCounts(ctx context.Context, req *AlertCountsRequest) ([]*v3.Series, error) {
+	if m.reader == nil {
+		return nil, fmt.Errorf("the state history is not stored")
+	}
+	changes, err := m.reader.ReadRuleStateChanges(ctx, req.Start, req.End)
+	if err != nil {
+		return nil, err
+	}
+
+	org := tenantOf(ctx)
+	visible := changes[:0]
+	for _, c := range changes {
+		if visibleTo(m.opts.tenants.of(c.RuleID), org) {
+			visible = append(visible, c)
+		}
+	}
+
+	ruleLabels := map[string]map[string]string{}
+	m.mtx.RLock()
+	for id, r := range m.rules {
+		ruleLabels[id] = r.Labels().Map()
+	}
+	m.mtx.RUnlock()
+
+	return buildAlertCounts(visible, req, ruleLabels), nil
+}
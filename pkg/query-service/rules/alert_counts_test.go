@@ -0,0 +1,78 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func TestAlertCountsRequestValidate(t *testing.T) {
+	req := &AlertCountsRequest{Start: 1, End: 1 + 24*3600*1000}
+	require.NoError(t, req.Validate())
+	assert.Equal(t, int64(864000), req.Step)
+	assert.Equal(t, alertCountStates, req.States)
+
+	req = &AlertCountsRequest{Start: 1, End: 1 + 3600*1000}
+	require.NoError(t, req.Validate())
+	assert.Equal(t, minAlertCountStep, req.Step)
+
+	assert.Error(t, (&AlertCountsRequest{End: 10}).Validate())
+	assert.Error(t, (&AlertCountsRequest{Start: 10, End: 5}).Validate())
+	assert.Error(t, (&AlertCountsRequest{Start: 1, End: 1 + 24*3600*1000, Step: 1000}).Validate())
+	assert.Error(t, (&AlertCountsRequest{Start: 1, End: 10, States: []string{"pending"}}).Validate())
+	assert.Error(t, (&AlertCountsRequest{Start: 1, End: 10, GroupBy: []string{"state"}}).Validate())
+	assert.Error(t, (&AlertCountsRequest{Start: 1, End: 10, Matchers: `{service_name=`}).Validate())
+}
+
+func TestBuildAlertCounts(t *testing.T) {
+	changes := []v3.RuleStateHistory{
+		// resolved in the range, it fired since before the range
+		{RuleID: "1", RuleName: "checkout latency", Fingerprint: 1, State: "normal", UnixMilli: 1025, Labels: `{"service_name":"checkout","severity":"critical"}`},
+		{RuleID: "1", RuleName: "checkout latency", Fingerprint: 2, State: "firing", UnixMilli: 1010, Labels: `{"service_name":"cart","severity":"critical"}`},
+		{RuleID: "2", RuleName: "cart errors", Fingerprint: 3, State: "firing", UnixMilli: 1020, Labels: `{"service_name":"cart","severity":"warning"}`},
+		{RuleID: "1", RuleName: "checkout latency", Fingerprint: 2, State: "no_data", UnixMilli: 1030, Labels: `{"service_name":"cart","severity":"critical"}`},
+		{RuleID: "2", RuleName: "cart errors", Fingerprint: 3, State: "normal", UnixMilli: 1040, Labels: `{"service_name":"cart","severity":"warning"}`},
+		// fires until the end
+		{RuleID: "3", RuleName: "disk full", Fingerprint: 4, State: "firing", UnixMilli: 1035},
+	}
+	ruleLabels := map[string]map[string]string{"3": {"team": "infra"}}
+	counts := func(req *AlertCountsRequest) map[string][]float64 {
+		req.Start, req.End, req.Step = 1000, 1050, 10
+		require.NoError(t, req.Validate())
+		res := map[string][]float64{}
+		for _, s := range buildAlertCounts(changes, req, ruleLabels) {
+			var values []float64
+			for _, p := range s.Points {
+				values = append(values, p.Value)
+			}
+			res[labelsText(s.Labels)] = values
+		}
+		return res
+	}
+
+	assert.Equal(t, map[string][]float64{
+		"state=firing":  {1, 2, 3, 1, 1, 1},
+		"state=no_data": {0, 0, 0, 1, 1, 1},
+	}, counts(&AlertCountsRequest{}))
+
+	assert.Equal(t, map[string][]float64{
+		"service_name=cart, state=firing": {0, 1, 2, 1, 0, 0},
+	}, counts(&AlertCountsRequest{Matchers: `{service_name="cart"}`, States: []string{"firing"}, GroupBy: []string{"service_name"}}))
+
+	// the alerts are matched on the labels of their rule
+	assert.Equal(t, map[string][]float64{
+		"state=firing": {0, 0, 0, 0, 1, 1},
+	}, counts(&AlertCountsRequest{Matchers: `{team="infra"}`}))
+
+	assert.Equal(t, map[string][]float64{
+		"severity=critical, state=firing":  {1, 2, 2, 0, 0, 0},
+		"severity=critical, state=no_data": {0, 0, 0, 1, 1, 1},
+	}, counts(&AlertCountsRequest{RuleIDs: []string{"1"}, GroupBy: []string{"severity"}}))
+
+	series := buildAlertCounts(changes, &AlertCountsRequest{Start: 1000, End: 1050, Step: 10, States: []string{"no_data"}}, ruleLabels)
+	require.Len(t, series, 1)
+	assert.Equal(t, []map[string]string{{"state": "no_data"}}, series[0].LabelsArray)
+	assert.Equal(t, int64(1040), series[0].Points[4].Timestamp)
+}
@@ -0,0 +1,215 @@
+package rules
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash"
+	am "go.signoz.io/signoz/pkg/query-service/integrations/alertManager"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// DedupRulesAnnotation lists the rules whose alerts were merged into the
+// notification
+const DedupRulesAnnotation = "dedup_rules"
+
+// dedupRuleLabels are set from the rule, they differ between the alerts of
+// overlapping rules
+var dedupRuleLabels = []string{labels.AlertNameLabel, labels.AlertRuleIdLabel, labels.RuleSourceLabel}
+
+// DedupOptions merges the notifications of the alerts of several rules
+// of an org with the same labels and channels, e.g. after importing
+// overlapping rule packs.
+//
+// The state of the deduplication is kept by every replica for the rules it
+// evaluates, with sharding the alerts of overlapping rules evaluated by
+// different replicas are not merged.
+type DedupOptions struct {
+	// Window is how long a rule keeps contributing to the notifications of
+	// the alert since its last notification, the deduplication is disabled
+	// when zero. It is at least twice the resend delay so that the firing
+	// alerts stay merged.
+	Window time.Duration
+	// IgnoreLabels are left out of the comparison of the labels beside
+	// the labels of the rule, e.g. severity
+	IgnoreLabels []string
+}
+
+// dedupRule is a rule whose alert is merged
+type dedupRule struct {
+	name     string
+	lastSeen time.Time
+}
+
+// dedupGroup is the alerts of the rules with the same labels, the primary
+// rule notifies them
+type dedupGroup struct {
+	primary string
+	rules   map[string]*dedupRule
+}
+
+// alertDedup merges the notifications of the alerts of different rules
+// with the same labels, channels and org. The first rule to fire notifies
+// the alert, the other rules are listed in its notifications until they
+// resolve.
+type alertDedup struct {
+	opts    DedupOptions
+	tenants *ruleTenants
+
+	mtx    sync.Mutex
+	groups map[uint64]*dedupGroup
+	// lastSweep is when the groups of the rules no longer notified were
+	// last dropped
+	lastSweep time.Time
+}
+
+func newAlertDedup(opts DedupOptions, tenants *ruleTenants) *alertDedup {
+	if opts.Window <= 0 {
+		return nil
+	}
+	return &alertDedup{opts: opts, tenants: tenants, groups: map[uint64]*dedupGroup{}}
+}
+
+// key hashes the labels of the alert without the labels of its rule and
+// the ignored labels, with the org of the rule and the channels of the
+// alert. The alerts of rules notifying other channels are not merged so
+// that every channel is notified, nor are the alerts of other orgs.
+func (d *alertDedup) key(a *am.Alert, ruleID string) uint64 {
+	lbls := a.Labels.Map()
+	for _, name := range dedupRuleLabels {
+		delete(lbls, name)
+	}
+	for _, name := range d.opts.IgnoreLabels {
+		delete(lbls, name)
+	}
+	receivers := append([]string{}, a.Receivers...)
+	sort.Strings(receivers)
+
+	b := strconv.AppendUint(nil, labels.FromMap(lbls).Hash(), 16)
+	b = append(b, '\xff')
+	b = append(b, d.tenants.of(ruleID)...)
+	for _, r := range receivers {
+		b = append(b, '\xff')
+		b = append(b, r...)
+	}
+	return xxhash.Sum64(b)
+}
+
+// filter drops the notifications of the alerts merged into the alert of
+// another rule and lists the merged rules in the notifications of the
+// primary ones. The alerts without a rule, e.g. the test alerts, are left
+// as is.
+func (d *alertDedup) filter(alerts []*am.Alert, now time.Time) []*am.Alert {
+	if d == nil || len(alerts) == 0 {
+		return alerts
+	}
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	d.sweep(now)
+
+	res := make([]*am.Alert, 0, len(alerts))
+	for _, a := range alerts {
+		ruleID := a.Labels.Get(labels.AlertRuleIdLabel)
+		if ruleID == "" {
+			res = append(res, a)
+			continue
+		}
+		key := d.key(a, ruleID)
+		g, ok := d.groups[key]
+		if !ok {
+			g = &dedupGroup{rules: map[string]*dedupRule{}}
+			d.groups[key] = g
+		}
+		d.expire(g, now)
+
+		if a.ResolvedAt(now) {
+			_, merged := g.rules[ruleID]
+			merged = merged && g.primary != ruleID
+			delete(g.rules, ruleID)
+			if g.primary == ruleID {
+				g.primary = ""
+			}
+			if len(g.rules) == 0 {
+				delete(d.groups, key)
+			}
+			// the merged alerts were not notified, neither is their end
+			if !merged {
+				res = append(res, a)
+			}
+			continue
+		}
+
+		r, ok := g.rules[ruleID]
+		if !ok {
+			r = &dedupRule{}
+			g.rules[ruleID] = r
+		}
+		r.name = a.Labels.Get(labels.AlertNameLabel)
+		r.lastSeen = now
+		if g.primary == "" {
+			g.primary = ruleID
+		}
+		if g.primary != ruleID {
+			continue
+		}
+		if len(g.rules) > 1 {
+			annotations := map[string]string{}
+			if a.Annotations != nil {
+				annotations = a.Annotations.Map()
+			}
+			annotations[DedupRulesAnnotation] = g.contributors()
+			a.Annotations = labels.FromMap(annotations)
+		}
+		res = append(res, a)
+	}
+	return res
+}
+
+// expire drops the rules of the group not notified within the window
+func (d *alertDedup) expire(g *dedupGroup, now time.Time) {
+	for id, r := range g.rules {
+		if now.Sub(r.lastSeen) > d.opts.Window {
+			delete(g.rules, id)
+		}
+	}
+	if _, ok := g.rules[g.primary]; !ok {
+		g.primary = ""
+	}
+}
+
+// sweep drops the groups whose rules are no longer notified, e.g. deleted
+// rules or rules that stopped without resolving their alerts, at most once
+// per window
+func (d *alertDedup) sweep(now time.Time) {
+	if now.Sub(d.lastSweep) < d.opts.Window {
+		return
+	}
+	d.lastSweep = now
+	for key, g := range d.groups {
+		d.expire(g, now)
+		if len(g.rules) == 0 {
+			delete(d.groups, key)
+		}
+	}
+}
+
+// contributors lists the rules of the group, the primary first
+func (g *dedupGroup) contributors() string {
+	ids := make([]string, 0, len(g.rules))
+	for id := range g.rules {
+		if id != g.primary {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	ids = append([]string{g.primary}, ids...)
+	names := make([]string, 0, len(ids))
+	for _, id := range ids {
+		names = append(names, fmt.Sprintf("%s (%s)", g.rules[id].name, id))
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,134 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	am "go.signoz.io/signoz/pkg/query-service/integrations/alertManager"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+func TestAlertDedup(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	alert := func(ruleID, name, severity string, endsAt time.Time) *am.Alert {
+		return &am.Alert{
+			Labels: labels.FromMap(map[string]string{
+				labels.AlertNameLabel:   name,
+				labels.AlertRuleIdLabel: ruleID,
+				"service":               "checkout",
+				"severity":              severity,
+			}),
+			Annotations: labels.FromMap(map[string]string{"summary": "checkout is slow"}),
+			EndsAt:      endsAt,
+		}
+	}
+	firing := now.Add(time.Hour)
+
+	d := newAlertDedup(DedupOptions{Window: 5 * time.Minute, IgnoreLabels: []string{"severity"}}, nil)
+
+	// the first rule to fire notifies the alert
+	res := d.filter([]*am.Alert{alert("1", "HighLatency", "critical", firing)}, now)
+	require.Len(t, res, 1)
+	assert.False(t, res[0].Annotations.Has(DedupRulesAnnotation))
+
+	// the overlapping rule is merged into it, the severity is ignored
+	res = d.filter([]*am.Alert{
+		alert("7", "CheckoutLatency", "warning", firing),
+		alert("1", "HighLatency", "critical", firing),
+	}, now.Add(time.Minute))
+	require.Len(t, res, 1)
+	assert.Equal(t, "1", res[0].Labels.Get(labels.AlertRuleIdLabel))
+	assert.Equal(t, "HighLatency (1), CheckoutLatency (7)", res[0].Annotations.Get(DedupRulesAnnotation))
+	assert.Equal(t, "checkout is slow", res[0].Annotations.Get("summary"))
+
+	// the alerts with other labels and without a rule are not merged
+	other := alert("7", "CheckoutLatency", "warning", firing)
+	other.Labels = labels.FromMap(map[string]string{labels.AlertNameLabel: "CheckoutLatency", labels.AlertRuleIdLabel: "7", "service": "cart"})
+	test := alert("", "HighLatency", "critical", firing)
+	res = d.filter([]*am.Alert{other, test}, now.Add(time.Minute))
+	assert.Len(t, res, 2)
+
+	// the end of a merged alert is not notified
+	res = d.filter([]*am.Alert{alert("7", "CheckoutLatency", "warning", now.Add(2*time.Minute))}, now.Add(2*time.Minute))
+	assert.Empty(t, res)
+
+	// once the primary rule resolves, the other rule notifies its alert
+	d.filter([]*am.Alert{alert("7", "CheckoutLatency", "warning", firing)}, now.Add(3*time.Minute))
+	res = d.filter([]*am.Alert{alert("1", "HighLatency", "critical", now.Add(4*time.Minute))}, now.Add(4*time.Minute))
+	require.Len(t, res, 1)
+	res = d.filter([]*am.Alert{alert("7", "CheckoutLatency", "warning", firing)}, now.Add(5*time.Minute))
+	require.Len(t, res, 1)
+	assert.Equal(t, "7", res[0].Labels.Get(labels.AlertRuleIdLabel))
+
+	// a rule not notified within the window no longer contributes
+	res = d.filter([]*am.Alert{alert("1", "HighLatency", "critical", firing)}, now.Add(20*time.Minute))
+	require.Len(t, res, 1)
+	assert.Equal(t, "1", res[0].Labels.Get(labels.AlertRuleIdLabel))
+	assert.False(t, res[0].Annotations.Has(DedupRulesAnnotation))
+}
+
+func TestAlertDedupSweep(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	alert := func(ruleID, service string) *am.Alert {
+		return &am.Alert{
+			Labels: labels.FromMap(map[string]string{labels.AlertRuleIdLabel: ruleID, "service": service}),
+			EndsAt: now.Add(time.Hour),
+		}
+	}
+	d := newAlertDedup(DedupOptions{Window: 5 * time.Minute}, nil)
+
+	// the rules are deleted without resolving their alerts
+	d.filter([]*am.Alert{alert("1", "checkout"), alert("2", "cart")}, now)
+	assert.Len(t, d.groups, 2)
+
+	// the groups not notified within the window are dropped by the next
+	// notification of any alert
+	d.filter([]*am.Alert{alert("3", "payment")}, now.Add(10*time.Minute))
+	require.Len(t, d.groups, 1)
+	for _, g := range d.groups {
+		assert.Equal(t, "3", g.primary)
+	}
+}
+
+func TestAlertDedupDisabled(t *testing.T) {
+	d := newAlertDedup(DedupOptions{}, nil)
+	assert.Nil(t, d)
+	alerts := []*am.Alert{{Labels: labels.FromMap(map[string]string{labels.AlertRuleIdLabel: "1"})}}
+	assert.Equal(t, alerts, d.filter(alerts, time.Now()))
+}
+
+func TestAlertDedupChannelsAndOrgs(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	alert := func(ruleID string, receivers ...string) *am.Alert {
+		return &am.Alert{
+			Labels:      labels.FromMap(map[string]string{labels.AlertRuleIdLabel: ruleID, labels.AlertNameLabel: "Latency " + ruleID, "service": "checkout"}),
+			Annotations: labels.FromMap(map[string]string{"summary": "checkout is slow"}),
+			Receivers:   receivers,
+			EndsAt:      now.Add(time.Hour),
+		}
+	}
+	tenants := newRuleTenants()
+	for id, org := range map[string]string{"1": "org-a", "2": "org-a", "3": "org-a", "4": "org-b"} {
+		tenants.set(id, org)
+	}
+	d := newAlertDedup(DedupOptions{Window: 5 * time.Minute}, tenants)
+
+	// the rules notifying other channels are not merged, every channel
+	// gets the alert
+	res := d.filter([]*am.Alert{alert("1", "slack", "email"), alert("2", "pagerduty")}, now)
+	require.Len(t, res, 2)
+	assert.False(t, res[0].Annotations.Has(DedupRulesAnnotation))
+	assert.False(t, res[1].Annotations.Has(DedupRulesAnnotation))
+
+	// the same channels in another order are merged
+	res = d.filter([]*am.Alert{alert("3", "email", "slack"), alert("1", "slack", "email")}, now.Add(time.Minute))
+	require.Len(t, res, 1)
+	assert.Equal(t, "Latency 1 (1), Latency 3 (3)", res[0].Annotations.Get(DedupRulesAnnotation))
+
+	// the alerts of another org are not merged, nor are its rules listed
+	res = d.filter([]*am.Alert{alert("4", "slack", "email")}, now.Add(time.Minute))
+	require.Len(t, res, 1)
+	assert.False(t, res[0].Annotations.Has(DedupRulesAnnotation))
+}
@@ -0,0 +1,323 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	am "go.signoz.io/signoz/pkg/query-service/integrations/alertManager"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+	"go.uber.org/zap"
+)
+
+const (
+	digestPeriod = 7 * 24 * time.Hour
+	// silentRuleAge is how long a rule has not fired before the digest
+	// lists it
+	silentRuleAge = 90 * 24 * time.Hour
+
+	digestCheckInterval = 10 * time.Minute
+	digestTimeout       = 5 * time.Minute
+	digestKey           = "alert-digest"
+	digestAlertName     = "Alert quality digest"
+	// digestAlertDuration is how long the alert carrying a digest is
+	// active, short of the repeat interval of the routes so that it is
+	// sent once
+	digestAlertDuration = 15 * time.Minute
+)
+
+// AlertDigestOptions schedules the weekly alert quality digest
+type AlertDigestOptions struct {
+	// Channel is the notification channel the digests are sent to
+	Channel string
+	Weekday time.Weekday
+	// Hour is the hour of the day the digests are sent, in UTC
+	Hour int
+	// TeamLabel is the label of the rules naming their team
+	TeamLabel string
+}
+
+// ParseWeekday parses the english name of a day of the week
+func ParseWeekday(s string) (time.Weekday, error) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(s, d.String()) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown weekday %q", s)
+}
+
+// digestRule is what the digest takes from a rule
+type digestRule struct {
+	id        string
+	name      string
+	team      string
+	hold      time.Duration
+	createdAt time.Time
+}
+
+// computeAlertDigest summarizes for every team the week of state changes,
+// oldest first, and acknowledgements ending at q.End. lastFired is when
+// an alert of every rule last started firing.
+func computeAlertDigest(changes []v3.RuleStateHistory, acks []AlertAck, lastFired map[string]int64, rules []digestRule, q *v3.QueryAlertDigest) v3.AlertDigest {
+	end := time.UnixMilli(q.End)
+	digest := v3.AlertDigest{Start: end.Add(-digestPeriod).UnixMilli(), End: q.End, Teams: []v3.TeamDigest{}}
+
+	teams := map[string]*v3.TeamDigest{}
+	team := func(name string) *v3.TeamDigest {
+		t, ok := teams[name]
+		if !ok {
+			t = &v3.TeamDigest{Team: name, NoisiestRules: []v3.RuleNoiseScore{}, SilentRules: []v3.SilentRule{}}
+			teams[name] = t
+		}
+		return t
+	}
+
+	ruleTeams := map[string]string{}
+	holds := map[string]time.Duration{}
+	for _, r := range rules {
+		ruleTeams[r.id] = r.team
+		holds[r.id] = r.hold
+		if end.Sub(r.createdAt) < silentRuleAge {
+			continue
+		}
+		last, ok := lastFired[r.id]
+		if ok && end.Sub(time.UnixMilli(last)) < silentRuleAge {
+			continue
+		}
+		team(r.team).SilentRules = append(team(r.team).SilentRules, v3.SilentRule{RuleID: r.id, RuleName: r.name, LastFired: last})
+	}
+
+	// the scores are sorted, the noisiest rules of every team come first
+	scores := computeNoiseScores(changes, acks, &v3.QueryNoiseReport{Start: digest.Start, End: digest.End}, holds)
+	for _, score := range scores {
+		t := team(ruleTeams[score.RuleID])
+		t.NewAlerts += score.Alerts
+		t.UnacknowledgedPages += uint64(math.Round(float64(score.Alerts) * score.UnacknowledgedPercent / 100))
+		if len(t.NoisiestRules) < q.Limit {
+			t.NoisiestRules = append(t.NoisiestRules, score)
+		}
+	}
+
+	for _, t := range teams {
+		sort.Slice(t.SilentRules, func(i, j int) bool {
+			return t.SilentRules[i].RuleName < t.SilentRules[j].RuleName
+		})
+		digest.Teams = append(digest.Teams, *t)
+	}
+	sort.Slice(digest.Teams, func(i, j int) bool {
+		return digest.Teams[i].Team < digest.Teams[j].Team
+	})
+	return digest
+}
+
+// digestText renders the digest of the team for the notification
+func digestText(t v3.TeamDigest, start, end int64) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Week of %s to %s\n", time.UnixMilli(start).UTC().Format("2006-01-02"), time.UnixMilli(end).UTC().Format("2006-01-02"))
+	fmt.Fprintf(&b, "New alerts: %d\n", t.NewAlerts)
+	fmt.Fprintf(&b, "Unacknowledged pages: %d\n", t.UnacknowledgedPages)
+	if len(t.NoisiestRules) > 0 {
+		b.WriteString("\nNoisiest rules:\n")
+		for _, r := range t.NoisiestRules {
+			fmt.Fprintf(&b, "- %s: score %.0f, %d alerts\n", r.RuleName, r.Score, r.Alerts)
+			for _, s := range r.Suggestions {
+				fmt.Fprintf(&b, "  %s\n", s)
+			}
+		}
+	}
+	if len(t.SilentRules) > 0 {
+		b.WriteString("\nRules that did not fire in 90 days:\n")
+		for _, r := range t.SilentRules {
+			fmt.Fprintf(&b, "- %s\n", r.RuleName)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// AlertDigest returns the weekly alert quality summary of every team, of
+// the rules of their org for the requests of a user
+func (m *Manager) AlertDigest(ctx context.Context, q *v3.QueryAlertDigest) (*v3.AlertDigest, error) {
+	if m.reader == nil {
+		return nil, fmt.Errorf("the state history is not stored")
+	}
+	visible, err := m.tenantRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	end := time.UnixMilli(q.End)
+	start := end.Add(-digestPeriod)
+	changes, err := m.reader.ReadRuleStateChanges(ctx, start.UnixMilli(), q.End)
+	if err != nil {
+		return nil, err
+	}
+	acks, err := m.ruleDB.GetAlertAcks(ctx, start)
+	if err != nil {
+		return nil, err
+	}
+	lastFired, err := m.reader.GetRulesLastFired(ctx)
+	if err != nil {
+		return nil, err
+	}
+	storedRules, err := m.ruleDB.GetStoredRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	changes, acks = visibleStateChanges(changes, visible), visibleAcks(acks, visible)
+
+	var rules []digestRule
+	m.mtx.RLock()
+	for _, stored := range storedRules {
+		id := strconv.Itoa(stored.Id)
+		r, ok := m.rules[id]
+		if !ok || (visible != nil && !visible[id]) {
+			continue
+		}
+		dr := digestRule{id: id, name: r.Name(), team: r.Labels().Get(q.TeamLabel)}
+		if stored.CreatedAt != nil {
+			dr.createdAt = *stored.CreatedAt
+		}
+		if r, ok := r.(interface{ HoldDuration() time.Duration }); ok {
+			dr.hold = r.HoldDuration()
+		}
+		rules = append(rules, dr)
+	}
+	m.mtx.RUnlock()
+
+	digest := computeAlertDigest(changes, acks, lastFired, rules, q)
+	return &digest, nil
+}
+
+// SendAlertDigest sends the digest of every team to the channel, as an
+// informational alert of the team
+func (m *Manager) SendAlertDigest(ctx context.Context, channel string, q *v3.QueryAlertDigest) (*v3.AlertDigest, *model.ApiError) {
+	if m.reader == nil {
+		return nil, &model.ApiError{Typ: model.ErrorUnavailable, Err: fmt.Errorf("the state history is not stored")}
+	}
+	if channel == "" {
+		return nil, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("channel is required")}
+	}
+	channels, apiErr := m.reader.GetChannels()
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	found := false
+	for _, c := range *channels {
+		found = found || c.Name == channel
+	}
+	if !found {
+		return nil, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("channel %s does not exist", channel)}
+	}
+
+	digest, err := m.AlertDigest(ctx, q)
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	now := time.Now()
+	alerts := make([]*am.Alert, 0, len(digest.Teams))
+	for _, t := range digest.Teams {
+		lbls := map[string]string{
+			labels.AlertNameLabel: digestAlertName,
+			"severity":            "info",
+		}
+		summary := digestAlertName
+		if t.Team != "" {
+			lbls[q.TeamLabel] = t.Team
+			summary = fmt.Sprintf("%s of team %s", digestAlertName, t.Team)
+		}
+		alerts = append(alerts, &am.Alert{
+			Labels: labels.FromMap(lbls),
+			Annotations: labels.FromMap(map[string]string{
+				"summary":     summary,
+				"description": digestText(t, digest.Start, digest.End),
+			}),
+			StartsAt:     now,
+			EndsAt:       now.Add(digestAlertDuration),
+			GeneratorURL: m.opts.RepoURL,
+			Receivers:    []string{channel},
+		})
+	}
+	if len(alerts) > 0 {
+		m.notifier.Send(alerts...)
+		m.pushDispatcher.Send(alerts...)
+		m.chatDispatcher.Send(alerts...)
+	}
+	return digest, nil
+}
+
+// alertDigest sends the digest every week at the scheduled time
+type alertDigest struct {
+	opts    AlertDigestOptions
+	send    func(ctx context.Context) error
+	sharder *Sharder
+
+	// sent is the last scheduled time the digest was sent for
+	sent time.Time
+
+	done       chan struct{}
+	terminated chan struct{}
+}
+
+func newAlertDigest(opts AlertDigestOptions, sharder *Sharder, send func(ctx context.Context) error) *alertDigest {
+	return &alertDigest{
+		opts:       opts,
+		send:       send,
+		sharder:    sharder,
+		done:       make(chan struct{}),
+		terminated: make(chan struct{}),
+	}
+}
+
+// scheduled returns the last scheduled time of the digest before now
+func (d *alertDigest) scheduled(now time.Time) time.Time {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), d.opts.Hour, 0, 0, 0, time.UTC)
+	day = day.AddDate(0, 0, -((int(now.Weekday()) - int(d.opts.Weekday) + 7) % 7))
+	if day.After(now) {
+		day = day.AddDate(0, 0, -7)
+	}
+	return day
+}
+
+// due tells whether the digest scheduled last is to be sent, digests
+// missed by more than the check interval are skipped
+func (d *alertDigest) due(now time.Time) (time.Time, bool) {
+	at := d.scheduled(now)
+	return at, at.After(d.sent) && now.Sub(at) < 2*digestCheckInterval
+}
+
+func (d *alertDigest) Run(ctx context.Context) {
+	defer close(d.terminated)
+
+	tick := time.NewTicker(digestCheckInterval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-tick.C:
+		}
+		at, ok := d.due(time.Now())
+		if !ok || !d.sharder.Owns(digestKey) {
+			continue
+		}
+		d.sent = at
+		cctx, cancel := context.WithTimeout(ctx, digestTimeout)
+		if err := d.send(cctx); err != nil {
+			zap.L().Error("failed to send the alert quality digest", zap.String("channel", d.opts.Channel), zap.Error(err))
+		}
+		cancel()
+	}
+}
+
+func (d *alertDigest) Stop() {
+	close(d.done)
+	<-d.terminated
+}
@@ -0,0 +1,93 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func TestComputeAlertDigest(t *testing.T) {
+	end := time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	change := func(rule string, fp uint64, state string, at time.Time) v3.RuleStateHistory {
+		return v3.RuleStateHistory{RuleID: rule, RuleName: "rule " + rule, Fingerprint: fp, State: state, StateChanged: true, UnixMilli: at.UnixMilli()}
+	}
+	changes := []v3.RuleStateHistory{
+		change("1", 1, "firing", end.Add(-3*day)),
+		change("1", 1, "normal", end.Add(-3*day+time.Minute)),
+		change("1", 2, "firing", end.Add(-2*day)),
+		change("2", 3, "firing", end.Add(-day)),
+	}
+	acks := []AlertAck{{RuleID: "1", Fingerprint: 2, AckedAt: end.Add(-2*day + time.Minute)}}
+	old := end.Add(-200 * day)
+	rules := []digestRule{
+		{id: "1", name: "rule 1", team: "payments", createdAt: old},
+		{id: "2", name: "rule 2", team: "search", createdAt: old},
+		{id: "3", name: "rule 3", team: "payments", createdAt: old},
+		// too recent to be silent
+		{id: "4", name: "rule 4", team: "payments", createdAt: end.Add(-10 * day)},
+		{id: "5", name: "rule 5", createdAt: old},
+	}
+	lastFired := map[string]int64{
+		"1": end.Add(-2 * day).UnixMilli(),
+		"2": end.Add(-day).UnixMilli(),
+		"3": end.Add(-100 * day).UnixMilli(),
+	}
+	q := &v3.QueryAlertDigest{End: end.UnixMilli()}
+	require.NoError(t, q.Validate())
+
+	digest := computeAlertDigest(changes, acks, lastFired, rules, q)
+	assert.Equal(t, end.Add(-7*day).UnixMilli(), digest.Start)
+	require.Len(t, digest.Teams, 3)
+
+	unassigned := digest.Teams[0]
+	assert.Equal(t, "", unassigned.Team)
+	require.Len(t, unassigned.SilentRules, 1)
+	assert.Equal(t, "5", unassigned.SilentRules[0].RuleID)
+	assert.Zero(t, unassigned.SilentRules[0].LastFired)
+
+	payments := digest.Teams[1]
+	assert.Equal(t, "payments", payments.Team)
+	assert.Equal(t, uint64(2), payments.NewAlerts)
+	assert.Equal(t, uint64(1), payments.UnacknowledgedPages)
+	require.Len(t, payments.NoisiestRules, 1)
+	assert.Equal(t, "1", payments.NoisiestRules[0].RuleID)
+	require.Len(t, payments.SilentRules, 1)
+	assert.Equal(t, "3", payments.SilentRules[0].RuleID)
+	assert.Equal(t, lastFired["3"], payments.SilentRules[0].LastFired)
+
+	search := digest.Teams[2]
+	assert.Equal(t, uint64(1), search.NewAlerts)
+	assert.Equal(t, uint64(1), search.UnacknowledgedPages)
+	assert.Empty(t, search.SilentRules)
+
+	text := digestText(payments, digest.Start, digest.End)
+	assert.Contains(t, text, "Week of 2024-03-04 to 2024-03-11\nNew alerts: 2\nUnacknowledged pages: 1")
+	assert.Contains(t, text, "Rules that did not fire in 90 days:\n- rule 3")
+}
+
+func TestAlertDigestSchedule(t *testing.T) {
+	d := newAlertDigest(AlertDigestOptions{Weekday: time.Monday, Hour: 9}, nil, nil)
+	monday := time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, monday, d.scheduled(monday.Add(5*time.Minute)))
+	assert.Equal(t, monday.AddDate(0, 0, -7), d.scheduled(monday.Add(-time.Minute)))
+	assert.Equal(t, monday, d.scheduled(monday.AddDate(0, 0, 3)))
+
+	at, ok := d.due(monday.Add(5 * time.Minute))
+	assert.True(t, ok)
+	d.sent = at
+	_, ok = d.due(monday.Add(15 * time.Minute))
+	assert.False(t, ok)
+	// a digest missed while the service was down is not sent late
+	_, ok = d.due(monday.AddDate(0, 0, 8))
+	assert.False(t, ok)
+
+	_, err := ParseWeekday("Friday")
+	assert.NoError(t, err)
+	_, err = ParseWeekday("someday")
+	assert.Error(t, err)
+}
@@ -0,0 +1,483 @@
+package rules
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	pmodel "github.com/prometheus/common/model"
+	plabels "github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+const (
+	DefaultAlertListLimit = 100
+	MaxAlertListLimit     = 1000
+
+	// the fields the alert lists are sorted by, prefixed with - for the
+	// descending order
+	AlertSortActiveAt = "activeAt"
+	AlertSortFiredAt  = "firedAt"
+	AlertSortValue    = "value"
+	AlertSortSeverity = "severity"
+	AlertSortRuleName = "ruleName"
+
+	defaultAlertSort = "-" + AlertSortActiveAt
+)
+
+// AlertListQuery selects, sorts and pages the active alerts of the rules
+type AlertListQuery struct {
+	// States are pending or firing, all the active alerts when empty
+	States     []string
+	Severities []string
+	// Matchers is a selector over the labels of the alerts with the rule
+	// name as alertname and the rule id as ruleId
+	Matchers string
+	RuleIDs  []string
+	// MinAge and MaxAge bound how long the alerts are active
+	MinAge time.Duration
+	MaxAge time.Duration
+	// Acked and Silenced select the alerts by their status when set
+	Acked    *bool
+	Silenced *bool
+	// Sort is the field sorted by, -activeAt by default
+	Sort   string
+	Limit  int
+	Cursor string
+	// Fields are the fields of the listed alerts, all when empty
+	Fields []string
+	// GroupBy are the labels the alerts are grouped by, the alertname and
+	// ruleId included. The groups are paged instead of the alerts when it is
+	// set.
+	GroupBy []string
+
+	matchers []*plabels.Matcher
+	after    *alertCursor
+}
+
+// alertListFields are the json fields of the listed alerts
+var alertListFields = []string{
+	"ruleId", "ruleName", "fingerprint", "state", "severity", "labels", "annotations", "value",
+	"activeAt", "firedAt", "generatorURL", "acked", "ackedBy", "ackedAt", "silenced", "silencedBy",
+}
+
+func (q *AlertListQuery) Validate() error {
+	for _, s := range q.States {
+		if s != StatePending.String() && s != StateFiring.String() {
+			return fmt.Errorf("state must be pending or firing, got %q", s)
+		}
+	}
+	if q.MinAge < 0 || q.MaxAge < 0 || (q.MaxAge > 0 && q.MaxAge < q.MinAge) {
+		return fmt.Errorf("invalid age range %s to %s", q.MinAge, q.MaxAge)
+	}
+	if q.Sort == "" {
+		q.Sort = defaultAlertSort
+	}
+	switch strings.TrimPrefix(q.Sort, "-") {
+	case AlertSortActiveAt, AlertSortFiredAt, AlertSortValue, AlertSortSeverity, AlertSortRuleName:
+	default:
+		return fmt.Errorf("unknown sort %q", q.Sort)
+	}
+	if q.Limit < 0 || q.Limit > MaxAlertListLimit {
+		return fmt.Errorf("limit must be between 1 and %d", MaxAlertListLimit)
+	}
+	if q.Limit == 0 {
+		q.Limit = DefaultAlertListLimit
+	}
+	for _, f := range q.Fields {
+		if !slices.Contains(alertListFields, f) {
+			return fmt.Errorf("unknown field %q, expected one of %v", f, alertListFields)
+		}
+	}
+	for _, l := range q.GroupBy {
+		if !pmodel.LabelName(l).IsValid() {
+			return fmt.Errorf("invalid group by label %q", l)
+		}
+	}
+	q.matchers = nil
+	if q.Matchers != "" {
+		matchers, err := parser.ParseMetricSelector(q.Matchers)
+		if err != nil {
+			return fmt.Errorf("invalid matchers: %w", err)
+		}
+		q.matchers = matchers
+	}
+	q.after = nil
+	if q.Cursor != "" {
+		c, err := decodeAlertCursor(q.Cursor)
+		if err != nil || c.Sort != q.Sort {
+			return fmt.Errorf("invalid cursor")
+		}
+		q.after = c
+	}
+	return nil
+}
+
+// ListedAlert is an active alert of a rule with its status
+type ListedAlert struct {
+	RuleID       string            `json:"ruleId"`
+	RuleName     string            `json:"ruleName"`
+	Fingerprint  uint64            `json:"fingerprint"`
+	State        string            `json:"state"`
+	Severity     string            `json:"severity,omitempty"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	Value        float64           `json:"value"`
+	ActiveAt     time.Time         `json:"activeAt"`
+	FiredAt      time.Time         `json:"firedAt,omitempty"`
+	GeneratorURL string            `json:"generatorURL,omitempty"`
+	Acked        bool              `json:"acked"`
+	AckedBy      string            `json:"ackedBy,omitempty"`
+	AckedAt      *time.Time        `json:"ackedAt,omitempty"`
+	Silenced     bool              `json:"silenced"`
+	// SilencedBy are the ids of the maintenances muting the rule
+	SilencedBy []string `json:"silencedBy,omitempty"`
+}
+
+// AlertList is a page of the active alerts
+type AlertList struct {
+	// Alerts are the alerts with the selected fields
+	Alerts []map[string]interface{} `json:"alerts"`
+	// Groups are the groups of the alerts when the query groups them, the
+	// alerts are not listed then
+	Groups []AlertGroup `json:"groups,omitempty"`
+	// Total is the number of alerts selected by the filters, of all the
+	// pages
+	Total int `json:"total"`
+	// TotalGroups is the number of groups of all the pages
+	TotalGroups int    `json:"totalGroups,omitempty"`
+	NextCursor  string `json:"nextCursor,omitempty"`
+}
+
+// AlertGroup is the alerts with the same values of the group by labels,
+// collapsed into their counts and the first of them in the order of the
+// list
+type AlertGroup struct {
+	// Labels are the values of the group by labels, empty when the alerts
+	// do not have the label
+	Labels     map[string]string `json:"labels"`
+	Count      int               `json:"count"`
+	Firing     int               `json:"firing"`
+	Pending    int               `json:"pending"`
+	Acked      int               `json:"acked"`
+	Silenced   int               `json:"silenced"`
+	Severities map[string]int    `json:"severities,omitempty"`
+	// Representative is the first alert of the group with the fields of
+	// the query
+	Representative map[string]interface{} `json:"representative"`
+
+	first *ListedAlert
+}
+
+// alertCursor is the position of the last alert of a page in the order of
+// the list
+type alertCursor struct {
+	Sort        string    `json:"s"`
+	RuleID      string    `json:"r"`
+	RuleName    string    `json:"n,omitempty"`
+	Fingerprint uint64    `json:"f"`
+	Severity    string    `json:"sev,omitempty"`
+	Value       float64   `json:"v,omitempty"`
+	ActiveAt    time.Time `json:"a"`
+	FiredAt     time.Time `json:"fa,omitempty"`
+}
+
+func (c *alertCursor) alert() *ListedAlert {
+	return &ListedAlert{RuleID: c.RuleID, RuleName: c.RuleName, Fingerprint: c.Fingerprint, Severity: c.Severity, Value: c.Value, ActiveAt: c.ActiveAt, FiredAt: c.FiredAt}
+}
+
+func encodeAlertCursor(sort string, a *ListedAlert) string {
+	data, _ := json.Marshal(alertCursor{
+		Sort: sort, RuleID: a.RuleID, RuleName: a.RuleName, Fingerprint: a.Fingerprint,
+		Severity: a.Severity, Value: a.Value, ActiveAt: a.ActiveAt, FiredAt: a.FiredAt,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeAlertCursor(s string) (*alertCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	c := &alertCursor{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// alertLess orders the alerts by the sort field, the ties are broken by
+// the rule and the fingerprint so that the order is total and the cursors
+// are stable
+func alertLess(sortBy string, a, b *ListedAlert) bool {
+	desc := strings.HasPrefix(sortBy, "-")
+	cmp := 0
+	switch strings.TrimPrefix(sortBy, "-") {
+	case AlertSortActiveAt:
+		cmp = a.ActiveAt.Compare(b.ActiveAt)
+	case AlertSortFiredAt:
+		cmp = a.FiredAt.Compare(b.FiredAt)
+	case AlertSortValue:
+		cmp = compareFloat(a.Value, b.Value)
+	case AlertSortSeverity:
+		cmp = severityRanks[a.Severity] - severityRanks[b.Severity]
+	case AlertSortRuleName:
+		cmp = strings.Compare(a.RuleName, b.RuleName)
+	}
+	if cmp != 0 {
+		return (cmp < 0) != desc
+	}
+	if a.RuleID != b.RuleID {
+		return a.RuleID < b.RuleID
+	}
+	return a.Fingerprint < b.Fingerprint
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// matches tells whether the alert is selected by the filters of the query
+func (q *AlertListQuery) matches(a *ListedAlert, now time.Time) bool {
+	if len(q.States) > 0 && !slices.Contains(q.States, a.State) {
+		return false
+	}
+	if len(q.Severities) > 0 && !slices.Contains(q.Severities, a.Severity) {
+		return false
+	}
+	if len(q.RuleIDs) > 0 && !slices.Contains(q.RuleIDs, a.RuleID) {
+		return false
+	}
+	age := now.Sub(a.ActiveAt)
+	if age < q.MinAge || (q.MaxAge > 0 && age > q.MaxAge) {
+		return false
+	}
+	if q.Acked != nil && a.Acked != *q.Acked {
+		return false
+	}
+	if q.Silenced != nil && a.Silenced != *q.Silenced {
+		return false
+	}
+	if len(q.matchers) > 0 && !matchesAll(q.matchers, a.matchLabels()) {
+		return false
+	}
+	return true
+}
+
+// matchLabels returns the labels of the alert with the rule name as
+// alertname and the rule id as ruleId
+func (a *ListedAlert) matchLabels() map[string]string {
+	lbls := make(map[string]string, len(a.Labels)+2)
+	for k, v := range a.Labels {
+		lbls[k] = v
+	}
+	lbls[labels.AlertNameLabel] = a.RuleName
+	lbls[labels.AlertRuleIdLabel] = a.RuleID
+	return lbls
+}
+
+// listAlerts filters, sorts and pages the alerts, the query must be
+// validated first
+func listAlerts(alerts []*ListedAlert, q *AlertListQuery, now time.Time) *AlertList {
+	selected := []*ListedAlert{}
+	for _, a := range alerts {
+		if q.matches(a, now) {
+			selected = append(selected, a)
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		return alertLess(q.Sort, selected[i], selected[j])
+	})
+
+	res := &AlertList{Alerts: []map[string]interface{}{}, Total: len(selected)}
+	if len(q.GroupBy) > 0 {
+		groupAlerts(res, selected, q)
+		return res
+	}
+	page := selected
+	if q.after != nil {
+		after := q.after.alert()
+		start := sort.Search(len(selected), func(i int) bool {
+			return alertLess(q.Sort, after, selected[i])
+		})
+		page = selected[start:]
+	}
+	if len(page) > q.Limit {
+		page = page[:q.Limit]
+		res.NextCursor = encodeAlertCursor(q.Sort, page[len(page)-1])
+	}
+	for _, a := range page {
+		res.Alerts = append(res.Alerts, selectAlertFields(a, q.Fields))
+	}
+	return res
+}
+
+// groupAlerts sets the page of the groups of the sorted alerts on the list.
+// The groups are in the order of their first alert, the cursors are the
+// first alert of the last group of the page.
+func groupAlerts(res *AlertList, sorted []*ListedAlert, q *AlertListQuery) {
+	var groups []*AlertGroup
+	byKey := map[string]*AlertGroup{}
+	for _, a := range sorted {
+		lbls := a.matchLabels()
+		values := make([]string, len(q.GroupBy))
+		for i, l := range q.GroupBy {
+			values[i] = lbls[l]
+		}
+		key, _ := json.Marshal(values)
+		g, ok := byKey[string(key)]
+		if !ok {
+			g = &AlertGroup{Labels: make(map[string]string, len(q.GroupBy)), Severities: map[string]int{}, first: a}
+			for i, l := range q.GroupBy {
+				g.Labels[l] = values[i]
+			}
+			byKey[string(key)] = g
+			groups = append(groups, g)
+		}
+		g.Count++
+		switch a.State {
+		case StateFiring.String():
+			g.Firing++
+		case StatePending.String():
+			g.Pending++
+		}
+		if a.Acked {
+			g.Acked++
+		}
+		if a.Silenced {
+			g.Silenced++
+		}
+		if a.Severity != "" {
+			g.Severities[a.Severity]++
+		}
+	}
+
+	res.TotalGroups = len(groups)
+	page := groups
+	if q.after != nil {
+		after := q.after.alert()
+		start := sort.Search(len(groups), func(i int) bool {
+			return alertLess(q.Sort, after, groups[i].first)
+		})
+		page = groups[start:]
+	}
+	if len(page) > q.Limit {
+		page = page[:q.Limit]
+		res.NextCursor = encodeAlertCursor(q.Sort, page[len(page)-1].first)
+	}
+	res.Groups = make([]AlertGroup, 0, len(page))
+	for _, g := range page {
+		g.Representative = selectAlertFields(g.first, q.Fields)
+		res.Groups = append(res.Groups, *g)
+	}
+}
+
+// selectAlertFields returns the json object of the alert with the fields
+func selectAlertFields(a *ListedAlert, fields []string) map[string]interface{} {
+	data, _ := json.Marshal(a)
+	obj := map[string]interface{}{}
+	// the numbers are kept as they are, the fingerprints do not fit a float
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	_ = dec.Decode(&obj)
+	if len(fields) == 0 {
+		return obj
+	}
+	selected := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		if v, ok := obj[f]; ok {
+			selected[f] = v
+		}
+	}
+	return selected
+}
+
+// ListAlerts returns a page of the active alerts of the rules of the org
+// selected by the query, with their ack and silence status
+func (m *Manager) ListAlerts(ctx context.Context, q *AlertListQuery) (*AlertList, *model.ApiError) {
+	if err := q.Validate(); err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorBadData, Err: err}
+	}
+	maintenances, err := m.ruleDB.GetAllPlannedMaintenance(ctx)
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	now := time.Now()
+
+	var alerts []*ListedAlert
+	oldest := now
+	m.mtx.RLock()
+	for _, rule := range m.rules {
+		org := m.opts.tenants.of(rule.ID())
+		if !visibleTo(org, tenantOf(ctx)) {
+			continue
+		}
+		var silencedBy []string
+		for i := range maintenances {
+			if maintenances[i].appliesTo(org) && maintenances[i].shouldSkip(rule.ID(), now) {
+				silencedBy = append(silencedBy, strconv.FormatInt(maintenances[i].Id, 10))
+			}
+		}
+		for _, a := range rule.ActiveAlerts() {
+			if a.State != StatePending && a.State != StateFiring {
+				continue
+			}
+			la := &ListedAlert{
+				RuleID:       rule.ID(),
+				RuleName:     rule.Name(),
+				Fingerprint:  a.Labels.Hash(),
+				State:        a.State.String(),
+				Severity:     a.Labels.Get("severity"),
+				Labels:       a.Labels.Map(),
+				Annotations:  map[string]string{},
+				Value:        a.Value,
+				ActiveAt:     a.ActiveAt,
+				FiredAt:      a.FiredAt,
+				GeneratorURL: a.GeneratorURL,
+				Silenced:     len(silencedBy) > 0,
+				SilencedBy:   silencedBy,
+			}
+			if a.Annotations != nil {
+				la.Annotations = a.Annotations.Map()
+			}
+			if a.ActiveAt.Before(oldest) {
+				oldest = a.ActiveAt
+			}
+			alerts = append(alerts, la)
+		}
+	}
+	m.mtx.RUnlock()
+
+	// the alerts are acked by the acks since they became active
+	acks, err := m.ruleDB.GetAlertAcks(ctx, oldest)
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	for _, a := range alerts {
+		for i := range acks {
+			ack := &acks[i]
+			if ack.RuleID != a.RuleID || ack.Fingerprint != a.Fingerprint || ack.AckedAt.Before(a.ActiveAt) {
+				continue
+			}
+			if a.AckedAt == nil || ack.AckedAt.After(*a.AckedAt) {
+				a.Acked, a.AckedBy, a.AckedAt = true, ack.AckedBy, &ack.AckedAt
+			}
+		}
+	}
+	return listAlerts(alerts, q, now), nil
+}
@@ -0,0 +1,144 @@
+package rules
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertListQueryValidate(t *testing.T) {
+	q := &AlertListQuery{}
+	require.NoError(t, q.Validate())
+	assert.Equal(t, "-activeAt", q.Sort)
+	assert.Equal(t, DefaultAlertListLimit, q.Limit)
+
+	assert.Error(t, (&AlertListQuery{States: []string{"inactive"}}).Validate())
+	assert.Error(t, (&AlertListQuery{Sort: "labels"}).Validate())
+	assert.Error(t, (&AlertListQuery{Limit: MaxAlertListLimit + 1}).Validate())
+	assert.Error(t, (&AlertListQuery{MinAge: time.Hour, MaxAge: time.Minute}).Validate())
+	assert.Error(t, (&AlertListQuery{Fields: []string{"password"}}).Validate())
+	assert.Error(t, (&AlertListQuery{Matchers: `{severity=`}).Validate())
+	assert.Error(t, (&AlertListQuery{Cursor: "not a cursor"}).Validate())
+
+	// the cursors are of the sort of the list
+	cursor := encodeAlertCursor("-value", &ListedAlert{RuleID: "1"})
+	assert.Error(t, (&AlertListQuery{Sort: "ruleName", Cursor: cursor}).Validate())
+	assert.NoError(t, (&AlertListQuery{Sort: "-value", Cursor: cursor}).Validate())
+}
+
+func TestListAlerts(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	acked := now.Add(-time.Minute)
+	alerts := []*ListedAlert{
+		{RuleID: "1", RuleName: "checkout latency", Fingerprint: 1, State: "firing", Severity: "critical", Labels: map[string]string{"service_name": "checkout"}, Value: 900, ActiveAt: now.Add(-time.Hour)},
+		{RuleID: "1", RuleName: "checkout latency", Fingerprint: 2, State: "pending", Severity: "critical", Labels: map[string]string{"service_name": "cart"}, Value: 700, ActiveAt: now.Add(-time.Minute)},
+		{RuleID: "2", RuleName: "cart errors", Fingerprint: 3, State: "firing", Severity: "warning", Labels: map[string]string{"service_name": "cart"}, Value: 12, ActiveAt: now.Add(-10 * time.Minute), Acked: true, AckedBy: "oncall@example.com", AckedAt: &acked},
+		{RuleID: "3", RuleName: "disk full", Fingerprint: 18446744073709551615, State: "firing", Severity: "error", Value: 95, ActiveAt: now.Add(-2 * time.Hour), Silenced: true, SilencedBy: []string{"4"}},
+	}
+	list := func(q *AlertListQuery) *AlertList {
+		require.NoError(t, q.Validate())
+		return listAlerts(alerts, q, now)
+	}
+	fingerprints := func(l *AlertList) []string {
+		var fps []string
+		for _, a := range l.Alerts {
+			fps = append(fps, string(a["fingerprint"].(json.Number)))
+		}
+		return fps
+	}
+
+	// the latest active first by default
+	res := list(&AlertListQuery{})
+	assert.Equal(t, 4, res.Total)
+	assert.Equal(t, []string{"2", "3", "1", "18446744073709551615"}, fingerprints(res))
+	assert.Empty(t, res.NextCursor)
+
+	assert.Equal(t, []string{"3", "1", "18446744073709551615"}, fingerprints(list(&AlertListQuery{States: []string{"firing"}})))
+	assert.Equal(t, []string{"2", "1"}, fingerprints(list(&AlertListQuery{Severities: []string{"critical"}})))
+	assert.Equal(t, []string{"2", "3"}, fingerprints(list(&AlertListQuery{Matchers: `{service_name="cart"}`})))
+	assert.Equal(t, []string{"3"}, fingerprints(list(&AlertListQuery{Matchers: `{alertname=~"cart.*"}`})))
+	assert.Equal(t, []string{"3", "1"}, fingerprints(list(&AlertListQuery{MinAge: 5 * time.Minute, MaxAge: time.Hour})))
+	assert.Equal(t, []string{"3"}, fingerprints(list(&AlertListQuery{Acked: boolPtr(true)})))
+	assert.Equal(t, []string{"2", "3", "1"}, fingerprints(list(&AlertListQuery{Silenced: boolPtr(false)})))
+	assert.Equal(t, []string{"1", "2", "18446744073709551615", "3"}, fingerprints(list(&AlertListQuery{Sort: "-severity"})))
+	assert.Equal(t, []string{"3", "1", "2", "18446744073709551615"}, fingerprints(list(&AlertListQuery{Sort: "ruleName"})))
+
+	// the pages follow each other through the cursors
+	var pages [][]string
+	q := &AlertListQuery{Sort: "-value", Limit: 3}
+	for {
+		res := list(q)
+		assert.Equal(t, 4, res.Total)
+		pages = append(pages, fingerprints(res))
+		if res.NextCursor == "" {
+			break
+		}
+		q.Cursor = res.NextCursor
+	}
+	assert.Equal(t, [][]string{{"1", "2", "18446744073709551615"}, {"3"}}, pages)
+
+	res = list(&AlertListQuery{RuleIDs: []string{"2"}, Fields: []string{"ruleName", "ackedBy"}})
+	assert.Equal(t, []map[string]interface{}{{"ruleName": "cart errors", "ackedBy": "oncall@example.com"}}, res.Alerts)
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestListAlertsGrouped(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	alerts := []*ListedAlert{
+		{RuleID: "1", RuleName: "checkout latency", Fingerprint: 1, State: "firing", Severity: "critical", Labels: map[string]string{"service_name": "checkout", "severity": "critical"}, ActiveAt: now.Add(-time.Hour), Acked: true},
+		{RuleID: "1", RuleName: "checkout latency", Fingerprint: 2, State: "pending", Severity: "critical", Labels: map[string]string{"service_name": "cart", "severity": "critical"}, ActiveAt: now.Add(-time.Minute)},
+		{RuleID: "2", RuleName: "cart errors", Fingerprint: 3, State: "firing", Severity: "warning", Labels: map[string]string{"service_name": "cart", "severity": "warning"}, ActiveAt: now.Add(-10 * time.Minute), Silenced: true},
+		{RuleID: "3", RuleName: "disk full", Fingerprint: 4, State: "firing", Severity: "error", Labels: map[string]string{"severity": "error"}, ActiveAt: now.Add(-2 * time.Hour)},
+	}
+	list := func(q *AlertListQuery) *AlertList {
+		require.NoError(t, q.Validate())
+		return listAlerts(alerts, q, now)
+	}
+
+	res := list(&AlertListQuery{GroupBy: []string{"service_name"}, Fields: []string{"fingerprint"}})
+	assert.Empty(t, res.Alerts)
+	assert.Equal(t, 4, res.Total)
+	assert.Equal(t, 3, res.TotalGroups)
+	require.Len(t, res.Groups, 3)
+	// the groups are in the order of their latest alert
+	cart := res.Groups[0]
+	assert.Equal(t, map[string]string{"service_name": "cart"}, cart.Labels)
+	assert.Equal(t, 2, cart.Count)
+	assert.Equal(t, 1, cart.Firing)
+	assert.Equal(t, 1, cart.Pending)
+	assert.Equal(t, 1, cart.Silenced)
+	assert.Equal(t, map[string]int{"critical": 1, "warning": 1}, cart.Severities)
+	assert.Equal(t, map[string]interface{}{"fingerprint": json.Number("2")}, cart.Representative)
+	assert.Equal(t, map[string]string{"service_name": "checkout"}, res.Groups[1].Labels)
+	assert.Equal(t, 1, res.Groups[1].Acked)
+	// the alerts without the label are grouped together
+	assert.Equal(t, map[string]string{"service_name": ""}, res.Groups[2].Labels)
+
+	// the groups are paged
+	var pages [][]map[string]string
+	q := &AlertListQuery{GroupBy: []string{"alertname", "severity"}, Sort: "-severity", Limit: 2}
+	for {
+		res := list(q)
+		var page []map[string]string
+		for _, g := range res.Groups {
+			page = append(page, g.Labels)
+		}
+		pages = append(pages, page)
+		if res.NextCursor == "" {
+			break
+		}
+		q.Cursor = res.NextCursor
+	}
+	assert.Equal(t, [][]map[string]string{
+		{{"alertname": "checkout latency", "severity": "critical"}, {"alertname": "disk full", "severity": "error"}},
+		{{"alertname": "cart errors", "severity": "warning"}},
+	}, pages)
+
+	assert.Error(t, (&AlertListQuery{GroupBy: []string{"service.name"}}).Validate())
+}
@@ -0,0 +1,373 @@
+package rules
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+	"go.uber.org/zap"
+)
+
+// DefaultMaxActiveAlerts is the number of active alerts a rule holds in
+// memory when the limit is not configured
+const DefaultMaxActiveAlerts = 50000
+
+// AlertStore holds the active alerts of a rule by fingerprint
+type AlertStore interface {
+	Get(fp uint64) (*Alert, bool)
+	Set(fp uint64, a *Alert)
+	Delete(fp uint64)
+	Len() int
+	// Range calls f for every alert, f can delete the alert it is given
+	Range(f func(fp uint64, a *Alert))
+	Stats() AlertStoreStats
+}
+
+// AlertStoreStats tells how many alerts a store holds and where
+type AlertStoreStats struct {
+	InMemory int
+	Spilled  int
+	// Evicted is the number of alerts pushed out of memory by the limit
+	Evicted uint64
+}
+
+// AlertSpill persists the alerts pushed out of memory by the limit of a
+// store
+type AlertSpill interface {
+	Put(ruleID string, fp uint64, a *Alert) error
+	Get(ruleID string, fp uint64) (*Alert, error)
+	Delete(ruleID string, fp uint64) error
+	// Keys returns the fingerprints of the spilled alerts of the rule
+	Keys(ruleID string) ([]uint64, error)
+	// Clear removes the spilled alerts of a deleted rule
+	Clear(ruleID string) error
+}
+
+type storedAlert struct {
+	fp    uint64
+	alert *Alert
+}
+
+// boundedAlertStore keeps up to max alerts in memory, the least recently
+// used ones are moved to the spill when there is one and dropped
+// otherwise
+type boundedAlertStore struct {
+	ruleID string
+	max    int
+	spill  AlertSpill
+
+	mtx     sync.Mutex
+	items   map[uint64]*list.Element
+	lru     *list.List
+	spilled map[uint64]struct{}
+	evicted uint64
+	warned  bool
+}
+
+// NewAlertStore returns a store holding up to max alerts of the rule in
+// memory, max <= 0 does not limit the store
+func NewAlertStore(ruleID string, max int, spill AlertSpill) AlertStore {
+	s := &boundedAlertStore{
+		ruleID:  ruleID,
+		max:     max,
+		spill:   spill,
+		items:   map[uint64]*list.Element{},
+		lru:     list.New(),
+		spilled: map[uint64]struct{}{},
+	}
+	if spill != nil {
+		keys, err := spill.Keys(ruleID)
+		if err != nil {
+			zap.L().Error("failed to list the spilled alerts", zap.String("ruleid", ruleID), zap.Error(err))
+		}
+		for _, fp := range keys {
+			s.spilled[fp] = struct{}{}
+		}
+	}
+	return s
+}
+
+func (s *boundedAlertStore) Get(fp uint64) (*Alert, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if e, ok := s.items[fp]; ok {
+		s.lru.MoveToFront(e)
+		return e.Value.(*storedAlert).alert, true
+	}
+	if _, ok := s.spilled[fp]; !ok {
+		return nil, false
+	}
+	a, err := s.spill.Get(s.ruleID, fp)
+	if err != nil {
+		zap.L().Error("failed to read a spilled alert", zap.String("ruleid", s.ruleID), zap.Error(err))
+		return nil, false
+	}
+	s.unspill(fp)
+	s.add(fp, a)
+	return a, true
+}
+
+func (s *boundedAlertStore) Set(fp uint64, a *Alert) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if e, ok := s.items[fp]; ok {
+		e.Value.(*storedAlert).alert = a
+		s.lru.MoveToFront(e)
+		return
+	}
+	if _, ok := s.spilled[fp]; ok {
+		s.unspill(fp)
+	}
+	s.add(fp, a)
+}
+
+func (s *boundedAlertStore) Delete(fp uint64) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if e, ok := s.items[fp]; ok {
+		s.lru.Remove(e)
+		delete(s.items, fp)
+	}
+	if _, ok := s.spilled[fp]; ok {
+		s.unspill(fp)
+	}
+}
+
+func (s *boundedAlertStore) Len() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return len(s.items) + len(s.spilled)
+}
+
+func (s *boundedAlertStore) Range(f func(fp uint64, a *Alert)) {
+	s.mtx.Lock()
+	inMemory := make([]storedAlert, 0, len(s.items))
+	for e := s.lru.Front(); e != nil; e = e.Next() {
+		inMemory = append(inMemory, *e.Value.(*storedAlert))
+	}
+	spilled := make([]uint64, 0, len(s.spilled))
+	for fp := range s.spilled {
+		spilled = append(spilled, fp)
+	}
+	s.mtx.Unlock()
+
+	for _, sa := range inMemory {
+		f(sa.fp, sa.alert)
+	}
+
+	// the spilled alerts are read one at a time and written back when f
+	// changed them without deleting them
+	for _, fp := range spilled {
+		a, err := s.spill.Get(s.ruleID, fp)
+		if err != nil {
+			zap.L().Error("failed to read a spilled alert", zap.String("ruleid", s.ruleID), zap.Error(err))
+			continue
+		}
+		f(fp, a)
+
+		s.mtx.Lock()
+		if _, ok := s.spilled[fp]; ok {
+			if err := s.spill.Put(s.ruleID, fp, a); err != nil {
+				zap.L().Error("failed to write a spilled alert", zap.String("ruleid", s.ruleID), zap.Error(err))
+			}
+		}
+		s.mtx.Unlock()
+	}
+}
+
+func (s *boundedAlertStore) Stats() AlertStoreStats {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return AlertStoreStats{InMemory: len(s.items), Spilled: len(s.spilled), Evicted: s.evicted}
+}
+
+// add puts the alert in memory and evicts the least recently used alerts
+// over the limit, s.mtx must be held
+func (s *boundedAlertStore) add(fp uint64, a *Alert) {
+	s.items[fp] = s.lru.PushFront(&storedAlert{fp: fp, alert: a})
+	for s.max > 0 && len(s.items) > s.max {
+		oldest := s.lru.Back()
+		sa := oldest.Value.(*storedAlert)
+		s.lru.Remove(oldest)
+		delete(s.items, sa.fp)
+		s.evicted++
+
+		if s.spill != nil {
+			err := s.spill.Put(s.ruleID, sa.fp, sa.alert)
+			if err == nil {
+				s.spilled[sa.fp] = struct{}{}
+				continue
+			}
+			zap.L().Error("failed to spill an alert, dropping it", zap.String("ruleid", s.ruleID), zap.Error(err))
+		}
+		if !s.warned {
+			s.warned = true
+			zap.L().Warn("rule has more active alerts than the limit, dropping the least recently seen ones",
+				zap.String("ruleid", s.ruleID), zap.Int("limit", s.max))
+		}
+	}
+}
+
+// unspill removes the alert from the spill, s.mtx must be held
+func (s *boundedAlertStore) unspill(fp uint64) {
+	delete(s.spilled, fp)
+	if err := s.spill.Delete(s.ruleID, fp); err != nil {
+		zap.L().Error("failed to delete a spilled alert", zap.String("ruleid", s.ruleID), zap.Error(err))
+	}
+}
+
+// spilledAlert is the encoding of an alert on disk, the labels of an
+// alert are interfaces
+type spilledAlert struct {
+	State             AlertState        `json:"state"`
+	Labels            map[string]string `json:"labels"`
+	Annotations       map[string]string `json:"annotations"`
+	QueryResultLables map[string]string `json:"queryResultLabels"`
+	GeneratorURL      string            `json:"generatorURL"`
+	Receivers         []string          `json:"receivers"`
+	Value             float64           `json:"value"`
+	ActiveAt          time.Time         `json:"activeAt"`
+	FiredAt           time.Time         `json:"firedAt"`
+	ResolvedAt        time.Time         `json:"resolvedAt"`
+	LastSentAt        time.Time         `json:"lastSentAt"`
+	ValidUntil        time.Time         `json:"validUntil"`
+	Missing           bool              `json:"missing"`
+	SnapshotURL       string            `json:"snapshotURL"`
+	Exemplars         string            `json:"exemplars,omitempty"`
+	LogSamples        string            `json:"logSamples,omitempty"`
+	ValueHistory      []ValuePoint      `json:"valueHistory,omitempty"`
+	Breaches          int               `json:"breaches,omitempty"`
+}
+
+func labelsMap(lbls labels.BaseLabels) map[string]string {
+	if lbls == nil {
+		return nil
+	}
+	return lbls.Map()
+}
+
+func newSpilledAlert(a *Alert) spilledAlert {
+	return spilledAlert{
+		State:             a.State,
+		Labels:            labelsMap(a.Labels),
+		Annotations:       labelsMap(a.Annotations),
+		QueryResultLables: labelsMap(a.QueryResultLables),
+		GeneratorURL:      a.GeneratorURL,
+		Receivers:         a.Receivers,
+		Value:             a.Value,
+		ActiveAt:          a.ActiveAt,
+		FiredAt:           a.FiredAt,
+		ResolvedAt:        a.ResolvedAt,
+		LastSentAt:        a.LastSentAt,
+		ValidUntil:        a.ValidUntil,
+		Missing:           a.Missing,
+		SnapshotURL:       a.SnapshotURL,
+		Exemplars:         a.Exemplars,
+		LogSamples:        a.LogSamples,
+		ValueHistory:      a.ValueHistory,
+		Breaches:          a.Breaches,
+	}
+}
+
+func (sa spilledAlert) alert() *Alert {
+	return &Alert{
+		State:             sa.State,
+		Labels:            labels.FromMap(sa.Labels),
+		Annotations:       labels.FromMap(sa.Annotations),
+		QueryResultLables: labels.FromMap(sa.QueryResultLables),
+		GeneratorURL:      sa.GeneratorURL,
+		Receivers:         sa.Receivers,
+		Value:             sa.Value,
+		ActiveAt:          sa.ActiveAt,
+		FiredAt:           sa.FiredAt,
+		ResolvedAt:        sa.ResolvedAt,
+		LastSentAt:        sa.LastSentAt,
+		ValidUntil:        sa.ValidUntil,
+		Missing:           sa.Missing,
+		SnapshotURL:       sa.SnapshotURL,
+		Exemplars:         sa.Exemplars,
+		LogSamples:        sa.LogSamples,
+		ValueHistory:      sa.ValueHistory,
+		Breaches:          sa.Breaches,
+	}
+}
+
+// DiskAlertSpill keeps the spilled alerts in a directory per rule, one
+// file per alert
+type DiskAlertSpill struct {
+	dir string
+}
+
+func NewDiskAlertSpill(dir string) (*DiskAlertSpill, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create the alert spill directory: %w", err)
+	}
+	return &DiskAlertSpill{dir: dir}, nil
+}
+
+func (d *DiskAlertSpill) path(ruleID string, fp uint64) string {
+	return filepath.Join(d.dir, url.PathEscape(ruleID), strconv.FormatUint(fp, 10)+".json")
+}
+
+func (d *DiskAlertSpill) Put(ruleID string, fp uint64, a *Alert) error {
+	b, err := json.Marshal(newSpilledAlert(a))
+	if err != nil {
+		return err
+	}
+	path := d.path(ruleID, fp)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+func (d *DiskAlertSpill) Get(ruleID string, fp uint64) (*Alert, error) {
+	b, err := os.ReadFile(d.path(ruleID, fp))
+	if err != nil {
+		return nil, err
+	}
+	var sa spilledAlert
+	if err := json.Unmarshal(b, &sa); err != nil {
+		return nil, err
+	}
+	return sa.alert(), nil
+}
+
+func (d *DiskAlertSpill) Delete(ruleID string, fp uint64) error {
+	err := os.Remove(d.path(ruleID, fp))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (d *DiskAlertSpill) Clear(ruleID string) error {
+	return os.RemoveAll(filepath.Join(d.dir, url.PathEscape(ruleID)))
+}
+
+func (d *DiskAlertSpill) Keys(ruleID string) ([]uint64, error) {
+	entries, err := os.ReadDir(filepath.Join(d.dir, url.PathEscape(ruleID)))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []uint64
+	for _, e := range entries {
+		fp, err := strconv.ParseUint(strings.TrimSuffix(e.Name(), ".json"), 10, 64)
+		if err != nil {
+			continue
+		}
+		keys = append(keys, fp)
+	}
+	return keys, nil
+}
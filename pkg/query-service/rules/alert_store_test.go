@@ -0,0 +1,89 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+func TestAlertStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	store := NewAlertStore("1", 2, nil)
+
+	store.Set(1, &Alert{Value: 1})
+	store.Set(2, &Alert{Value: 2})
+	_, ok := store.Get(1)
+	require.True(t, ok)
+	store.Set(3, &Alert{Value: 3})
+
+	_, ok = store.Get(2)
+	assert.False(t, ok, "the least recently used alert should be evicted")
+	_, ok = store.Get(1)
+	assert.True(t, ok)
+	_, ok = store.Get(3)
+	assert.True(t, ok)
+	assert.Equal(t, 2, store.Len())
+	assert.Equal(t, AlertStoreStats{InMemory: 2, Evicted: 1}, store.Stats())
+}
+
+func TestAlertStoreRangeDelete(t *testing.T) {
+	store := NewAlertStore("1", 0, nil)
+	for fp := uint64(1); fp <= 5; fp++ {
+		store.Set(fp, &Alert{Value: float64(fp)})
+	}
+
+	store.Range(func(fp uint64, a *Alert) {
+		if fp%2 == 0 {
+			store.Delete(fp)
+		}
+	})
+	assert.Equal(t, 3, store.Len())
+}
+
+func TestAlertStoreSpill(t *testing.T) {
+	spill, err := NewDiskAlertSpill(t.TempDir())
+	require.NoError(t, err)
+
+	activeAt := time.Unix(1700000000, 0).UTC()
+	store := NewAlertStore("rule/1", 1, spill)
+	store.Set(1, &Alert{
+		State:             StateFiring,
+		Labels:            labels.FromStrings("service", "api"),
+		QueryResultLables: labels.FromStrings("service", "api"),
+		Annotations:       labels.FromStrings("summary", "high latency"),
+		ActiveAt:          activeAt,
+		Value:             42,
+	})
+	store.Set(2, &Alert{State: StatePending})
+
+	assert.Equal(t, AlertStoreStats{InMemory: 1, Spilled: 1, Evicted: 1}, store.Stats())
+	assert.Equal(t, 2, store.Len())
+
+	// changes made while ranging over the spilled alerts are kept
+	store.Range(func(fp uint64, a *Alert) {
+		if fp == 1 {
+			a.State = StateInactive
+		}
+	})
+
+	// the spilled alerts survive a new store for the rule
+	store = NewAlertStore("rule/1", 1, spill)
+	assert.Equal(t, AlertStoreStats{Spilled: 1}, store.Stats())
+
+	a, ok := store.Get(1)
+	require.True(t, ok)
+	assert.Equal(t, StateInactive, a.State)
+	assert.Equal(t, "api", a.Labels.Get("service"))
+	assert.Equal(t, "high latency", a.Annotations.Get("summary"))
+	assert.Equal(t, activeAt, a.ActiveAt.UTC())
+	assert.Equal(t, 42.0, a.Value)
+	assert.Equal(t, AlertStoreStats{InMemory: 1}, store.Stats())
+
+	store.Set(3, &Alert{})
+	require.NoError(t, spill.Clear("rule/1"))
+	keys, err := spill.Keys("rule/1")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
@@ -0,0 +1,234 @@
+package rules
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/auth"
+	"go.signoz.io/signoz/pkg/query-service/common"
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+// AlertViewQuery is the filters, the sort and the grouping of a saved view
+// of the alerts list, in the parameters of the list
+type AlertViewQuery struct {
+	States     []string `json:"state,omitempty"`
+	Severities []string `json:"severity,omitempty"`
+	Matchers   string   `json:"match,omitempty"`
+	RuleIDs    []string `json:"ruleId,omitempty"`
+	MinAge     Duration `json:"minAge,omitempty"`
+	MaxAge     Duration `json:"maxAge,omitempty"`
+	Acked      *bool    `json:"acked,omitempty"`
+	Silenced   *bool    `json:"silenced,omitempty"`
+	Sort       string   `json:"sort,omitempty"`
+	Fields     []string `json:"fields,omitempty"`
+	// GroupBy are the labels the alerts of the view are grouped by
+	GroupBy []string `json:"groupBy,omitempty"`
+}
+
+func (q *AlertViewQuery) Scan(src interface{}) error {
+	return scanJSON(src, q)
+}
+
+func (q AlertViewQuery) Value() (driver.Value, error) {
+	return json.Marshal(q)
+}
+
+func (q AlertViewQuery) Validate() error {
+	return q.Apply(&AlertListQuery{}).Validate()
+}
+
+// Apply sets the filters and the sort of the view on the query of the
+// alerts list. The page of the query is kept, as are its fields and its
+// grouping when it sets them.
+func (q AlertViewQuery) Apply(list *AlertListQuery) *AlertListQuery {
+	list.States = q.States
+	list.Severities = q.Severities
+	list.Matchers = q.Matchers
+	list.RuleIDs = q.RuleIDs
+	list.MinAge = time.Duration(q.MinAge)
+	list.MaxAge = time.Duration(q.MaxAge)
+	list.Acked = q.Acked
+	list.Silenced = q.Silenced
+	list.Sort = q.Sort
+	if len(list.Fields) == 0 {
+		list.Fields = q.Fields
+	}
+	if len(list.GroupBy) == 0 {
+		list.GroupBy = q.GroupBy
+	}
+	return list
+}
+
+// AlertView is a named configuration of the alerts list of a user. The
+// personal views are only seen by the user who saved them, the shared
+// views by all the users of the org.
+type AlertView struct {
+	Id          int64          `json:"id" db:"id"`
+	OrgId       string         `json:"orgId" db:"org_id"`
+	Name        string         `json:"name" db:"name"`
+	Description string         `json:"description" db:"description"`
+	Shared      bool           `json:"shared" db:"shared"`
+	Query       AlertViewQuery `json:"query" db:"query"`
+	CreatedAt   time.Time      `json:"createdAt" db:"created_at"`
+	CreatedBy   string         `json:"createdBy" db:"created_by"`
+	UpdatedAt   time.Time      `json:"updatedAt" db:"updated_at"`
+	UpdatedBy   string         `json:"updatedBy" db:"updated_by"`
+}
+
+func (v *AlertView) Validate() error {
+	if strings.TrimSpace(v.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if err := v.Query.Validate(); err != nil {
+		return fmt.Errorf("view %s: %w", v.Name, err)
+	}
+	return nil
+}
+
+// alertViewVisible tells whether the user of the request sees the view,
+// the internal requests see all the views
+func alertViewVisible(ctx context.Context, v *AlertView) bool {
+	if !visibleTo(v.OrgId, tenantOf(ctx)) {
+		return false
+	}
+	user := common.GetUserFromContext(ctx)
+	return v.Shared || user == nil || v.CreatedBy == user.Email
+}
+
+// checkAlertViewOwner refuses the changes of the view by the users who did
+// not save it, the admins may change the shared views too
+func checkAlertViewOwner(ctx context.Context, v *AlertView) *model.ApiError {
+	user := common.GetUserFromContext(ctx)
+	if user == nil || v.CreatedBy == user.Email || (v.Shared && auth.IsAdmin(user)) {
+		return nil
+	}
+	return &model.ApiError{Typ: model.ErrorForbidden, Err: fmt.Errorf("view %d can only be changed by %s", v.Id, v.CreatedBy)}
+}
+
+// ListAlertViews returns the personal views of the user of the request and
+// the shared views of the org
+func (m *Manager) ListAlertViews(ctx context.Context) ([]AlertView, *model.ApiError) {
+	views, err := m.ruleDB.GetAlertViews(ctx)
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	visible := []AlertView{}
+	for i := range views {
+		if alertViewVisible(ctx, &views[i]) {
+			visible = append(visible, views[i])
+		}
+	}
+	return visible, nil
+}
+
+// GetAlertView returns the view with the id when the user of the request
+// sees it
+func (m *Manager) GetAlertView(ctx context.Context, id string) (*AlertView, *model.ApiError) {
+	view, err := m.ruleDB.GetAlertView(ctx, id)
+	if err == nil && !alertViewVisible(ctx, view) {
+		err = sql.ErrNoRows
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("alert view %s not found", id)}
+	}
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	return view, nil
+}
+
+// checkAlertViewName denies a name another view of the user has, or
+// another shared view of the org has
+func (m *Manager) checkAlertViewName(ctx context.Context, view *AlertView, id int64) *model.ApiError {
+	views, apiErr := m.ListAlertViews(ctx)
+	if apiErr != nil {
+		return apiErr
+	}
+	owner := view.CreatedBy
+	if user := common.GetUserFromContext(ctx); user != nil && id == 0 {
+		owner = user.Email
+	}
+	for _, v := range views {
+		if v.Id == id || v.Name != view.Name {
+			continue
+		}
+		if (v.Shared && view.Shared) || v.CreatedBy == owner {
+			return &model.ApiError{Typ: model.ErrorConflict, Err: fmt.Errorf("alert view %s already exists", view.Name)}
+		}
+	}
+	return nil
+}
+
+// CreateAlertView saves the view for the user of the request
+func (m *Manager) CreateAlertView(ctx context.Context, view *AlertView) *model.ApiError {
+	if err := view.Validate(); err != nil {
+		return newApiErrorBadData(err)
+	}
+	if apiErr := m.checkAlertViewName(ctx, view, 0); apiErr != nil {
+		return apiErr
+	}
+	id, err := m.ruleDB.CreateAlertView(ctx, *view)
+	if err != nil {
+		return newApiErrorInternal(err)
+	}
+	saved, apiErr := m.GetAlertView(ctx, strconv.FormatInt(id, 10))
+	if apiErr != nil {
+		return apiErr
+	}
+	*view = *saved
+	return nil
+}
+
+// EditAlertView replaces the view with the id, a personal view may be
+// shared and a shared view made personal again by its owner
+func (m *Manager) EditAlertView(ctx context.Context, view *AlertView, id string) *model.ApiError {
+	if err := view.Validate(); err != nil {
+		return newApiErrorBadData(err)
+	}
+	stored, apiErr := m.GetAlertView(ctx, id)
+	if apiErr != nil {
+		return apiErr
+	}
+	if apiErr := checkAlertViewOwner(ctx, stored); apiErr != nil {
+		return apiErr
+	}
+	if view.Shared != stored.Shared {
+		if user := common.GetUserFromContext(ctx); user != nil && user.Email != stored.CreatedBy {
+			return &model.ApiError{Typ: model.ErrorForbidden, Err: fmt.Errorf("view %s can only be shared or unshared by %s", id, stored.CreatedBy)}
+		}
+	}
+	view.CreatedBy = stored.CreatedBy
+	if apiErr := m.checkAlertViewName(ctx, view, stored.Id); apiErr != nil {
+		return apiErr
+	}
+	if err := m.ruleDB.EditAlertView(ctx, *view, id); err != nil {
+		return newApiErrorInternal(err)
+	}
+	return nil
+}
+
+// DeleteAlertView deletes the view with the id
+func (m *Manager) DeleteAlertView(ctx context.Context, id string) *model.ApiError {
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		return newApiErrorBadData(fmt.Errorf("invalid alert view id %s", id))
+	}
+	stored, apiErr := m.GetAlertView(ctx, id)
+	if apiErr != nil {
+		return apiErr
+	}
+	if apiErr := checkAlertViewOwner(ctx, stored); apiErr != nil {
+		return apiErr
+	}
+	if err := m.ruleDB.DeleteAlertView(ctx, id); err != nil {
+		return newApiErrorInternal(err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package rules
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/auth"
+	"go.signoz.io/signoz/pkg/query-service/constants"
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+func TestAlertViewValidate(t *testing.T) {
+	assert.NoError(t, (&AlertView{Name: "all infra alerts"}).Validate())
+	assert.NoError(t, (&AlertView{Name: "my team's P1s", Query: AlertViewQuery{Severities: []string{"critical"}, Matchers: `{team="payments"}`, GroupBy: []string{"service_name"}}}).Validate())
+	assert.Error(t, (&AlertView{Name: " "}).Validate())
+	assert.Error(t, (&AlertView{Name: "p1", Query: AlertViewQuery{Sort: "labels"}}).Validate())
+	assert.Error(t, (&AlertView{Name: "p1", Query: AlertViewQuery{Matchers: `{team=`}}).Validate())
+	assert.Error(t, (&AlertView{Name: "p1", Query: AlertViewQuery{GroupBy: []string{"service.name"}}}).Validate())
+}
+
+func TestAlertViewQuery(t *testing.T) {
+	acked := false
+	q := AlertViewQuery{States: []string{"firing"}, Matchers: `{team="payments"}`, MinAge: Duration(5 * time.Minute), Acked: &acked, Sort: "-severity", Fields: []string{"ruleName", "labels"}, GroupBy: []string{"service_name"}}
+
+	// the query is stored as the json of the list parameters
+	value, err := q.Value()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"state":["firing"],"match":"{team=\"payments\"}","minAge":"5m0s","acked":false,"sort":"-severity","fields":["ruleName","labels"],"groupBy":["service_name"]}`, string(value.([]byte)))
+	var scanned AlertViewQuery
+	require.NoError(t, scanned.Scan(string(value.([]byte))))
+	assert.Equal(t, q, scanned)
+
+	// the view replaces the filters of the list and keeps its page
+	list := q.Apply(&AlertListQuery{Severities: []string{"warning"}, Limit: 10, Cursor: "next"})
+	assert.Empty(t, list.Severities)
+	assert.Equal(t, []string{"firing"}, list.States)
+	assert.Equal(t, 5*time.Minute, list.MinAge)
+	assert.Equal(t, "-severity", list.Sort)
+	assert.Equal(t, []string{"ruleName", "labels"}, list.Fields)
+	assert.Equal(t, 10, list.Limit)
+	assert.Equal(t, "next", list.Cursor)
+	assert.Equal(t, []string{"ruleId"}, q.Apply(&AlertListQuery{Fields: []string{"ruleId"}}).Fields)
+}
+
+func TestAlertViewOwnership(t *testing.T) {
+	defer func(id string) { auth.AuthCacheObj.AdminGroupId = id }(auth.AuthCacheObj.AdminGroupId)
+	auth.AuthCacheObj.AdminGroupId = "admin"
+	asUser := func(email, org, group string) context.Context {
+		return context.WithValue(context.Background(), constants.ContextUserKey, &model.UserPayload{User: model.User{Email: email, OrgId: org, GroupId: group}})
+	}
+	alice := asUser("alice@example.com", "acme", "viewer")
+	bob := asUser("bob@example.com", "acme", "viewer")
+	admin := asUser("admin@example.com", "acme", "admin")
+
+	personal := &AlertView{Id: 1, OrgId: "acme", Name: "mine", CreatedBy: "alice@example.com"}
+	shared := &AlertView{Id: 2, OrgId: "acme", Name: "infra", Shared: true, CreatedBy: "alice@example.com"}
+
+	// the personal views are only seen by their owner
+	assert.True(t, alertViewVisible(alice, personal))
+	assert.False(t, alertViewVisible(bob, personal))
+	assert.False(t, alertViewVisible(admin, personal))
+	assert.True(t, alertViewVisible(bob, shared))
+	assert.False(t, alertViewVisible(asUser("alice@example.com", "other", "viewer"), shared))
+	assert.True(t, alertViewVisible(context.Background(), personal))
+
+	assert.Nil(t, checkAlertViewOwner(alice, personal))
+	assert.Nil(t, checkAlertViewOwner(alice, shared))
+	assert.NotNil(t, checkAlertViewOwner(bob, shared))
+	// the admins may change the shared views of others
+	assert.Nil(t, checkAlertViewOwner(admin, shared))
+	assert.NotNil(t, checkAlertViewOwner(admin, personal))
+}
@@ -19,6 +19,11 @@ const (
 	// how long before re-sending the alert
 	resolvedRetention = 15 * time.Minute
 
+	// defaultEvalResolution is the step used when the rule does not
+	// set an eval resolution
+	defaultEvalResolution = time.Minute
+	minEvalResolution     = 5 * time.Second
+
 	TestAlertPostFix = "_TEST_ALERT"
 )
 
@@ -111,6 +116,20 @@ type Alert struct {
 	ValidUntil time.Time
 
 	Missing bool
+
+	// SnapshotURL points to the chart rendered when the alert started firing
+	SnapshotURL string
+	// Exemplars are the ids of the example traces of the breach of an
+	// alert of a traces rule, comma separated
+	Exemplars string
+	// LogSamples are the sample log lines of the breach of an alert of a
+	// logs query, one per line
+	LogSamples string
+	// ValueHistory are the values of the latest evaluations of the alert
+	ValueHistory []ValuePoint
+	// Breaches is the number of consecutive evaluations the alert matched
+	// the condition
+	Breaches int
 }
 
 func (a *Alert) needsSending(ts time.Time, resendDelay time.Duration) bool {
@@ -174,6 +193,58 @@ type RuleCondition struct {
 	MatchType      MatchType          `json:"matchType,omitempty"`
 	TargetUnit     string             `json:"targetUnit,omitempty"`
 	SelectedQuery  string             `json:"selectedQueryName,omitempty"`
+	// StreamResults evaluates the rows of a clickhouse query as they are
+	// read instead of loading the whole result, for queries returning a
+	// very large number of series
+	StreamResults bool `yaml:"streamResults,omitempty" json:"streamResults,omitempty"`
+	// Downsampling selects the samples table of the metrics queries of the
+	// rule, it overrides the default of the manager
+	Downsampling v3.Downsampling `yaml:"downsampling,omitempty" json:"downsampling,omitempty"`
+	// RecoveryTarget resolves the alerts only once the value is back past
+	// it rather than past the target, so that the alerts of a value
+	// hovering around the target do not flap. It is in the target unit.
+	RecoveryTarget *float64 `yaml:"recoveryTarget,omitempty" json:"recoveryTarget,omitempty"`
+	// QueryUnits are the units of the builder queries by name, the unit
+	// of a selected formula is inferred from the units of its queries
+	// when the composite query has no unit
+	QueryUnits map[string]string `yaml:"queryUnits,omitempty" json:"queryUnits,omitempty"`
+	// QueryThresholds compare several queries of the rule each with its
+	// own threshold instead of the selected query with the target. The
+	// alerts are labeled with the name of the query.
+	QueryThresholds []QueryThreshold `yaml:"queryThresholds,omitempty" json:"queryThresholds,omitempty"`
+	// ExpectedMembers raises a no data alert for every expected value of
+	// a label that the selected query does not return
+	ExpectedMembers *ExpectedMembers `yaml:"expectedMembers,omitempty" json:"expectedMembers,omitempty"`
+	// Confirmation is a query run only when the selected query breaches,
+	// the alerts fire only for the series it confirms, e.g. a service
+	// that still receives traffic
+	Confirmation *QueryThreshold `yaml:"confirmation,omitempty" json:"confirmation,omitempty"`
+	// Canary compares a canary cohort of the series of the queries with a
+	// baseline cohort instead of the selected query with the target
+	Canary *CanaryAnalysis `yaml:"canary,omitempty" json:"canary,omitempty"`
+	// Anomaly compares the latest value of every series of the selected
+	// query with the values of the series instead of with the target
+	Anomaly *AnomalyDetection `yaml:"anomaly,omitempty" json:"anomaly,omitempty"`
+}
+
+// ExpectedMembers is the set of values of a label expected in the series
+// of the selected query, e.g. the hosts of a cluster. The set is the static
+// values and the values of the label in the series of the discovery query.
+type ExpectedMembers struct {
+	Label  string   `yaml:"label" json:"label"`
+	Values []string `yaml:"values,omitempty" json:"values,omitempty"`
+	Query  string   `yaml:"query,omitempty" json:"query,omitempty"`
+}
+
+// QueryThreshold is the threshold of one query of a rule with query
+// thresholds, the compare op and match type of the rule are used when not
+// set
+type QueryThreshold struct {
+	QueryName  string    `yaml:"queryName" json:"queryName"`
+	Target     *float64  `yaml:"target" json:"target"`
+	CompareOp  CompareOp `yaml:"op,omitempty" json:"op,omitempty"`
+	MatchType  MatchType `yaml:"matchType,omitempty" json:"matchType,omitempty"`
+	TargetUnit string    `yaml:"targetUnit,omitempty" json:"targetUnit,omitempty"`
 }
 
 func (rc *RuleCondition) IsValid() bool {
@@ -183,10 +254,10 @@ func (rc *RuleCondition) IsValid() bool {
 	}
 
 	if rc.QueryType() == v3.QueryTypeBuilder {
-		if rc.Target == nil {
+		if rc.Target == nil && len(rc.QueryThresholds) == 0 && rc.ExpectedMembers == nil && rc.Canary == nil && rc.Anomaly == nil {
 			return false
 		}
-		if rc.CompareOp == "" {
+		if rc.CompareOp == "" && rc.Canary == nil && rc.Anomaly == nil {
 			return false
 		}
 	}
@@ -199,6 +270,38 @@ func (rc *RuleCondition) IsValid() bool {
 	return true
 }
 
+// validateRecoveryTarget checks that the recovery target is short of the
+// target on the side the rule does not alert
+func (rc *RuleCondition) validateRecoveryTarget() error {
+	if rc.RecoveryTarget == nil {
+		return nil
+	}
+	if rc.Target == nil || len(rc.QueryThresholds) > 0 {
+		return fmt.Errorf("the recovery target requires the target of the rule")
+	}
+	// the streamed evaluation and the promql rules do not remember the
+	// series that breached at the last evaluation
+	if rc.QueryType() == v3.QueryTypePromQL {
+		return fmt.Errorf("the recovery target is not supported by the promql rules")
+	}
+	if rc.StreamResults {
+		return fmt.Errorf("the recovery target can not be used with streamed results")
+	}
+	switch rc.CompareOp {
+	case ValueIsAbove:
+		if *rc.RecoveryTarget >= *rc.Target {
+			return fmt.Errorf("the recovery target must be below the target")
+		}
+	case ValueIsBelow:
+		if *rc.RecoveryTarget <= *rc.Target {
+			return fmt.Errorf("the recovery target must be above the target")
+		}
+	default:
+		return fmt.Errorf("the recovery target only applies to the rules alerting above or below the target")
+	}
+	return nil
+}
+
 // QueryType is a short hand method to get query type
 func (rc *RuleCondition) QueryType() v3.QueryType {
 	if rc.CompositeQuery != nil {
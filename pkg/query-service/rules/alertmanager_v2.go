@@ -0,0 +1,489 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	plabels "github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// The types below are the models of the Alertmanager v2 API, served over
+// the alerts of the rules and the planned maintenances so that the tools
+// built for Alertmanager, amtool, karma or the grafana datasource, work
+// against the query service.
+
+const (
+	AMv2AlertActive     = "active"
+	AMv2AlertSuppressed = "suppressed"
+
+	AMv2SilenceActive  = "active"
+	AMv2SilencePending = "pending"
+	AMv2SilenceExpired = "expired"
+)
+
+// startedAt is reported as the start of the alert manager in its status
+var startedAt = time.Now()
+
+// ruleIDsPattern is a regex matcher listing rule ids, e.g. 1|2|3
+var ruleIDsPattern = regexp.MustCompile(`^\d+(\|\d+)*$`)
+
+type AMv2Matcher struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"isRegex"`
+	IsEqual *bool  `json:"isEqual,omitempty"`
+}
+
+func (m AMv2Matcher) equal() bool {
+	return m.IsEqual == nil || *m.IsEqual
+}
+
+type AMv2SilenceStatus struct {
+	State string `json:"state"`
+}
+
+type AMv2Silence struct {
+	ID        string             `json:"id,omitempty"`
+	Matchers  []AMv2Matcher      `json:"matchers"`
+	StartsAt  time.Time          `json:"startsAt"`
+	EndsAt    time.Time          `json:"endsAt"`
+	CreatedBy string             `json:"createdBy"`
+	Comment   string             `json:"comment"`
+	UpdatedAt time.Time          `json:"updatedAt,omitempty"`
+	Status    *AMv2SilenceStatus `json:"status,omitempty"`
+}
+
+func (s *AMv2Silence) Validate() error {
+	if len(s.Matchers) == 0 {
+		return fmt.Errorf("at least one matcher is required")
+	}
+	if s.EndsAt.IsZero() {
+		return fmt.Errorf("endsAt is required")
+	}
+	if !s.StartsAt.IsZero() && s.EndsAt.Before(s.StartsAt) {
+		return fmt.Errorf("endsAt must not be before startsAt")
+	}
+	if s.EndsAt.Before(time.Now()) {
+		return fmt.Errorf("the silence has already expired")
+	}
+	return nil
+}
+
+type AMv2Receiver struct {
+	Name string `json:"name"`
+}
+
+type AMv2AlertStatus struct {
+	State       string   `json:"state"`
+	SilencedBy  []string `json:"silencedBy"`
+	InhibitedBy []string `json:"inhibitedBy"`
+}
+
+type AMv2Alert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	UpdatedAt    time.Time         `json:"updatedAt"`
+	GeneratorURL string            `json:"generatorURL,omitempty"`
+	Fingerprint  string            `json:"fingerprint"`
+	Receivers    []AMv2Receiver    `json:"receivers"`
+	Status       AMv2AlertStatus   `json:"status"`
+}
+
+type AMv2PostableAlert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+}
+
+// AMv2Status is the status of the alert manager, the query service runs
+// as a single ready peer
+type AMv2Status struct {
+	Cluster struct {
+		Status string   `json:"status"`
+		Peers  []string `json:"peers"`
+	} `json:"cluster"`
+	VersionInfo map[string]string `json:"versionInfo"`
+	Config      struct {
+		Original string `json:"original"`
+	} `json:"config"`
+	Uptime time.Time `json:"uptime"`
+}
+
+func NewAMv2Status(version string) *AMv2Status {
+	s := &AMv2Status{
+		VersionInfo: map[string]string{"version": version},
+		Uptime:      startedAt,
+	}
+	s.Cluster.Status = "ready"
+	s.Cluster.Peers = []string{}
+	return s
+}
+
+// ParseAMv2Filter parses the filter of the Alertmanager API, matchers
+// like alertname="HighLatency" or severity=~"critical|error"
+func ParseAMv2Filter(filter []string) ([]*plabels.Matcher, error) {
+	if len(filter) == 0 {
+		return nil, nil
+	}
+	matchers, err := parser.ParseMetricSelector("{" + strings.Join(filter, ",") + "}")
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+	return matchers, nil
+}
+
+// silenceState is the state of the maintenance as a silence
+func silenceState(m *PlannedMaintenance, now time.Time) string {
+	switch {
+	case now.Before(m.Schedule.StartTime):
+		return AMv2SilencePending
+	case now.Before(m.Schedule.EndTime):
+		return AMv2SilenceActive
+	default:
+		return AMv2SilenceExpired
+	}
+}
+
+// silenceFromMaintenance returns the fixed maintenance as a silence
+// matching the ids of the rules it covers, false for the recurring
+// maintenances that have no equivalent in Alertmanager
+func silenceFromMaintenance(m *PlannedMaintenance, now time.Time) (AMv2Silence, bool) {
+	if m.Schedule == nil || m.IsRecurring() || m.Schedule.StartTime.IsZero() || m.Schedule.EndTime.IsZero() {
+		return AMv2Silence{}, false
+	}
+	matcher := AMv2Matcher{Name: labels.AlertRuleIdLabel, Value: ".+", IsRegex: true}
+	if m.AlertIds != nil && len(*m.AlertIds) == 1 {
+		matcher = AMv2Matcher{Name: labels.AlertRuleIdLabel, Value: (*m.AlertIds)[0]}
+	} else if m.AlertIds != nil && len(*m.AlertIds) > 1 {
+		matcher.Value = strings.Join(*m.AlertIds, "|")
+	}
+	comment := m.Description
+	if comment == "" {
+		comment = m.Name
+	}
+	return AMv2Silence{
+		ID:        strconv.FormatInt(m.Id, 10),
+		Matchers:  []AMv2Matcher{matcher},
+		StartsAt:  m.Schedule.StartTime,
+		EndsAt:    m.Schedule.EndTime,
+		CreatedBy: m.CreatedBy,
+		Comment:   comment,
+		UpdatedAt: m.UpdatedAt,
+		Status:    &AMv2SilenceStatus{State: silenceState(m, now)},
+	}, true
+}
+
+// silenceRuleIDs returns the ids of the rules the matchers of the silence
+// select. Maintenances cover whole rules, the matchers can only select
+// rules by their id or name.
+func silenceRuleIDs(matchers []AMv2Matcher, ruleNames map[string]string) ([]string, error) {
+	var ids []string
+	selected := false
+	for _, m := range matchers {
+		if !m.equal() {
+			return nil, fmt.Errorf("negative matchers are not supported, silences select rules by %s or %s", labels.AlertRuleIdLabel, labels.AlertNameLabel)
+		}
+		var matched []string
+		switch {
+		case m.Name == labels.AlertRuleIdLabel && m.IsRegex && m.Value == ".+":
+			continue
+		case m.Name == labels.AlertRuleIdLabel && m.IsRegex && ruleIDsPattern.MatchString(m.Value):
+			matched = strings.Split(m.Value, "|")
+		case m.Name == labels.AlertRuleIdLabel && !m.IsRegex:
+			matched = []string{m.Value}
+		case m.Name == labels.AlertNameLabel && !m.IsRegex:
+			for id, name := range ruleNames {
+				if name == m.Value {
+					matched = append(matched, id)
+				}
+			}
+			if len(matched) == 0 {
+				return nil, fmt.Errorf("no rule named %q", m.Value)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported matcher on %s, silences select rules by %s or %s", m.Name, labels.AlertRuleIdLabel, labels.AlertNameLabel)
+		}
+		if !selected {
+			ids, selected = matched, true
+			continue
+		}
+		// the matchers of a silence all apply
+		var both []string
+		for _, id := range ids {
+			for _, other := range matched {
+				if id == other {
+					both = append(both, id)
+				}
+			}
+		}
+		if len(both) == 0 {
+			return nil, fmt.Errorf("the matchers select no rule")
+		}
+		ids = both
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// maintenanceFromSilence returns the silence as a fixed maintenance
+func maintenanceFromSilence(s *AMv2Silence, ruleNames map[string]string, now time.Time) (*PlannedMaintenance, error) {
+	ids, err := silenceRuleIDs(s.Matchers, ruleNames)
+	if err != nil {
+		return nil, err
+	}
+	start := s.StartsAt
+	if start.IsZero() || start.Before(now) {
+		start = now
+	}
+	name := s.Comment
+	if name == "" {
+		name = fmt.Sprintf("silence by %s", s.CreatedBy)
+	}
+	alertIds := AlertIds(ids)
+	return &PlannedMaintenance{
+		Name:        name,
+		Description: s.Comment,
+		Schedule:    &Schedule{Timezone: "UTC", StartTime: start.UTC(), EndTime: s.EndsAt.UTC()},
+		AlertIds:    &alertIds,
+	}, nil
+}
+
+// ruleNames returns the names of the stored rules by id
+func (m *Manager) ruleNames(ctx context.Context) (map[string]string, error) {
+	storedRules, err := m.ruleDB.GetStoredRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(storedRules))
+	for _, stored := range storedRules {
+		rule, err := parseStoredRule(stored.Data)
+		if err != nil {
+			continue
+		}
+		names[strconv.Itoa(stored.Id)] = rule.AlertName
+	}
+	return names, nil
+}
+
+// AMv2Alerts returns the firing alerts of the rules, the ones of the
+// rules in a maintenance as suppressed by it
+func (m *Manager) AMv2Alerts(ctx context.Context, matchers []*plabels.Matcher, silenced bool) ([]AMv2Alert, error) {
+	maintenances, err := m.ruleDB.GetAllPlannedMaintenance(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	res := []AMv2Alert{}
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	for _, rule := range m.rules {
+		var silencedBy []string
+		org := m.opts.tenants.of(rule.ID())
+		if !visibleTo(org, tenantOf(ctx)) {
+			continue
+		}
+		for i := range maintenances {
+			if maintenances[i].appliesTo(org) && maintenances[i].shouldSkip(rule.ID(), now) {
+				silencedBy = append(silencedBy, strconv.FormatInt(maintenances[i].Id, 10))
+			}
+		}
+		if len(silencedBy) > 0 && !silenced {
+			continue
+		}
+		for _, a := range rule.ActiveAlerts() {
+			if a.State != StateFiring {
+				continue
+			}
+			lbls := a.Labels.Map()
+			if !matchesAll(matchers, lbls) {
+				continue
+			}
+			alert := AMv2Alert{
+				Labels:       lbls,
+				Annotations:  map[string]string{},
+				StartsAt:     a.FiredAt,
+				EndsAt:       a.ValidUntil,
+				UpdatedAt:    a.LastSentAt,
+				GeneratorURL: a.GeneratorURL,
+				Fingerprint:  fmt.Sprintf("%016x", a.Labels.Hash()),
+				Receivers:    []AMv2Receiver{},
+				Status:       AMv2AlertStatus{State: AMv2AlertActive, SilencedBy: []string{}, InhibitedBy: []string{}},
+			}
+			if a.Annotations != nil {
+				alert.Annotations = a.Annotations.Map()
+			}
+			for _, r := range a.Receivers {
+				alert.Receivers = append(alert.Receivers, AMv2Receiver{Name: r})
+			}
+			if len(silencedBy) > 0 {
+				alert.Status.State = AMv2AlertSuppressed
+				alert.Status.SilencedBy = silencedBy
+			}
+			res = append(res, alert)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Fingerprint < res[j].Fingerprint
+	})
+	return res, nil
+}
+
+// PostAMv2Alerts receives the alerts pushed by Prometheus and the other
+// clients of the Alertmanager API like the alerts of any other source
+func (m *Manager) PostAMv2Alerts(ctx context.Context, alerts []AMv2PostableAlert) *model.ApiError {
+	now := time.Now()
+	external := make([]ExternalAlert, 0, len(alerts))
+	for i, a := range alerts {
+		if a.Labels[labels.AlertNameLabel] == "" {
+			return &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("alert %d has no name", i)}
+		}
+		lbls := map[string]string{ExternalSourceLabel: string(InboundSourceAlertmanager)}
+		for k, v := range a.Labels {
+			if v != "" {
+				lbls[k] = v
+			}
+		}
+		external = append(external, ExternalAlert{
+			Labels:       lbls,
+			Annotations:  a.Annotations,
+			StartsAt:     a.StartsAt,
+			EndsAt:       a.EndsAt,
+			Resolved:     !a.EndsAt.IsZero() && !a.EndsAt.After(now),
+			GeneratorURL: a.GeneratorURL,
+		})
+	}
+	return m.ReceiveExternalAlerts(ctx, tenantOf(ctx), InboundSourceAlertmanager, external, nil)
+}
+
+// AMv2Silences returns the fixed maintenances as silences
+func (m *Manager) AMv2Silences(ctx context.Context, matchers []*plabels.Matcher) ([]AMv2Silence, error) {
+	maintenances, err := m.ruleDB.GetAllPlannedMaintenance(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	res := []AMv2Silence{}
+	for i := range maintenances {
+		s, ok := silenceFromMaintenance(&maintenances[i], now)
+		if !ok {
+			continue
+		}
+		if len(matchers) > 0 && !silenceMatches(s, matchers) {
+			continue
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+// silenceMatches tells whether the silence has a matcher equal to every
+// filter, as Alertmanager filters the silences
+func silenceMatches(s AMv2Silence, matchers []*plabels.Matcher) bool {
+	for _, f := range matchers {
+		found := false
+		for _, m := range s.Matchers {
+			isRegex := f.Type == plabels.MatchRegexp || f.Type == plabels.MatchNotRegexp
+			isEqual := f.Type == plabels.MatchEqual || f.Type == plabels.MatchRegexp
+			if m.Name == f.Name && m.Value == f.Value && m.IsRegex == isRegex && m.equal() == isEqual {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// AMv2Silence returns the maintenance with the id as a silence
+func (m *Manager) AMv2Silence(ctx context.Context, id string) (*AMv2Silence, *model.ApiError) {
+	maintenance, apiErr := m.silenceMaintenance(ctx, id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	s, _ := silenceFromMaintenance(maintenance, time.Now())
+	return &s, nil
+}
+
+// silenceMaintenance returns the fixed maintenance with the id
+func (m *Manager) silenceMaintenance(ctx context.Context, id string) (*PlannedMaintenance, *model.ApiError) {
+	maintenances, err := m.ruleDB.GetAllPlannedMaintenance(ctx)
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	for i := range maintenances {
+		if strconv.FormatInt(maintenances[i].Id, 10) != id {
+			continue
+		}
+		if _, ok := silenceFromMaintenance(&maintenances[i], time.Now()); !ok {
+			return nil, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("maintenance %s is recurring and is not a silence", id)}
+		}
+		return &maintenances[i], nil
+	}
+	return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("silence %s not found", id)}
+}
+
+// PutAMv2Silence creates the silence as a maintenance, or updates the
+// maintenance when the silence has an id, and returns its id
+func (m *Manager) PutAMv2Silence(ctx context.Context, s *AMv2Silence) (string, *model.ApiError) {
+	if err := s.Validate(); err != nil {
+		return "", &model.ApiError{Typ: model.ErrorBadData, Err: err}
+	}
+	names, err := m.ruleNames(ctx)
+	if err != nil {
+		return "", newApiErrorInternal(err)
+	}
+	maintenance, err := maintenanceFromSilence(s, names, time.Now())
+	if err != nil {
+		return "", &model.ApiError{Typ: model.ErrorBadData, Err: err}
+	}
+
+	if s.ID == "" {
+		id, err := m.ruleDB.CreatePlannedMaintenance(ctx, *maintenance)
+		if err != nil {
+			return "", newApiErrorInternal(err)
+		}
+		return strconv.FormatInt(id, 10), nil
+	}
+	if _, apiErr := m.silenceMaintenance(ctx, s.ID); apiErr != nil {
+		return "", apiErr
+	}
+	if _, err := m.ruleDB.EditPlannedMaintenance(ctx, *maintenance, s.ID); err != nil {
+		return "", newApiErrorInternal(err)
+	}
+	return s.ID, nil
+}
+
+// ExpireAMv2Silence ends the silence now, it is kept as expired
+func (m *Manager) ExpireAMv2Silence(ctx context.Context, id string) *model.ApiError {
+	maintenance, apiErr := m.silenceMaintenance(ctx, id)
+	if apiErr != nil {
+		return apiErr
+	}
+	now := time.Now().UTC()
+	if silenceState(maintenance, now) == AMv2SilenceExpired {
+		return &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("silence %s already expired", id)}
+	}
+	if maintenance.Schedule.StartTime.After(now) {
+		maintenance.Schedule.StartTime = now
+	}
+	maintenance.Schedule.EndTime = now
+	if _, err := m.ruleDB.EditPlannedMaintenance(ctx, *maintenance, id); err != nil {
+		return newApiErrorInternal(err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSilenceRuleIDs(t *testing.T) {
+	names := map[string]string{"1": "latency", "2": "errors", "3": "latency"}
+	notEqual := false
+
+	tests := []struct {
+		name     string
+		matchers []AMv2Matcher
+		want     []string
+		err      bool
+	}{
+		{name: "rule id", matchers: []AMv2Matcher{{Name: "ruleId", Value: "2"}}, want: []string{"2"}},
+		{name: "rule ids", matchers: []AMv2Matcher{{Name: "ruleId", Value: "3|1", IsRegex: true}}, want: []string{"1", "3"}},
+		{name: "all rules", matchers: []AMv2Matcher{{Name: "ruleId", Value: ".+", IsRegex: true}}},
+		{name: "alertname", matchers: []AMv2Matcher{{Name: "alertname", Value: "latency"}}, want: []string{"1", "3"}},
+		{name: "both apply", matchers: []AMv2Matcher{{Name: "alertname", Value: "latency"}, {Name: "ruleId", Value: "3"}}, want: []string{"3"}},
+		{name: "no rule in both", matchers: []AMv2Matcher{{Name: "alertname", Value: "errors"}, {Name: "ruleId", Value: "3"}}, err: true},
+		{name: "unknown alertname", matchers: []AMv2Matcher{{Name: "alertname", Value: "queue"}}, err: true},
+		{name: "other label", matchers: []AMv2Matcher{{Name: "service", Value: "api"}}, err: true},
+		{name: "negative", matchers: []AMv2Matcher{{Name: "ruleId", Value: "1", IsEqual: &notEqual}}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, err := silenceRuleIDs(tt.matchers, names)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ids)
+		})
+	}
+}
+
+func TestSilenceMaintenance(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := &AMv2Silence{
+		Matchers:  []AMv2Matcher{{Name: "ruleId", Value: "1|2", IsRegex: true}},
+		StartsAt:  now.Add(-time.Minute),
+		EndsAt:    now.Add(time.Hour),
+		CreatedBy: "amtool",
+		Comment:   "db migration",
+	}
+
+	m, err := maintenanceFromSilence(s, nil, now)
+	require.NoError(t, err)
+	assert.Equal(t, "db migration", m.Name)
+	assert.Equal(t, now, m.Schedule.StartTime)
+	assert.Equal(t, AlertIds{"1", "2"}, *m.AlertIds)
+	assert.True(t, m.shouldSkip("2", now.Add(time.Minute)))
+	assert.False(t, m.shouldSkip("3", now.Add(time.Minute)))
+
+	m.Id = 7
+	silence, ok := silenceFromMaintenance(m, now.Add(time.Minute))
+	require.True(t, ok)
+	assert.Equal(t, "7", silence.ID)
+	assert.Equal(t, s.Matchers, silence.Matchers)
+	assert.Equal(t, AMv2SilenceActive, silence.Status.State)
+
+	silence, _ = silenceFromMaintenance(m, now.Add(2*time.Hour))
+	assert.Equal(t, AMv2SilenceExpired, silence.Status.State)
+
+	filter, err := ParseAMv2Filter([]string{`ruleId=~"1|2"`})
+	require.NoError(t, err)
+	assert.True(t, silenceMatches(silence, filter))
+	filter, err = ParseAMv2Filter([]string{`ruleId="1"`})
+	require.NoError(t, err)
+	assert.False(t, silenceMatches(silence, filter))
+
+	// recurring maintenances are not silences
+	m.Schedule.Recurrence = &Recurrence{RepeatType: RepeatTypeDaily}
+	_, ok = silenceFromMaintenance(m, now)
+	assert.False(t, ok)
+}
@@ -0,0 +1,202 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+// alertEpisode is a period during which an alert fired
+type alertEpisode struct {
+	ruleID      string
+	ruleName    string
+	fingerprint uint64
+	labels      v3.LabelsString
+	start       int64
+	end         int64
+	// resolved is false for the alerts still firing at the end of the
+	// range, they fire until the end
+	resolved bool
+}
+
+func (e alertEpisode) duration() int64 {
+	return e.end - e.start
+}
+
+// alertEpisodes splits the state changes of the alerts, oldest first, into
+// their firing episodes. It also returns the number of state changes of
+// every rule.
+func alertEpisodes(changes []v3.RuleStateHistory, end int64) ([]alertEpisode, map[string]int) {
+	type alertKey struct {
+		ruleID      string
+		fingerprint uint64
+	}
+	var episodes []alertEpisode
+	transitions := map[string]int{}
+	firing := map[alertKey]int{}
+	for _, c := range changes {
+		transitions[c.RuleID]++
+		key := alertKey{c.RuleID, c.Fingerprint}
+		idx, ok := firing[key]
+		if c.State == StateFiring.String() {
+			if !ok {
+				firing[key] = len(episodes)
+				episodes = append(episodes, alertEpisode{ruleID: c.RuleID, ruleName: c.RuleName, fingerprint: c.Fingerprint, labels: c.Labels, start: c.UnixMilli})
+			}
+			continue
+		}
+		if ok {
+			episodes[idx].end = c.UnixMilli
+			episodes[idx].resolved = true
+			delete(firing, key)
+		}
+	}
+	for _, idx := range firing {
+		episodes[idx].end = end
+	}
+	return episodes, transitions
+}
+
+// computeAlertAnalytics computes the analytics of the rules from the state
+// changes of their alerts, oldest first. teams maps the rules to their
+// team.
+func computeAlertAnalytics(changes []v3.RuleStateHistory, q *v3.QueryAlertAnalytics, teams map[string]string) *v3.AlertAnalytics {
+	stepMilli := q.Step * 1000
+	bucket := func(ts int64) int64 {
+		return ts - (ts-q.Start)%stepMilli
+	}
+
+	episodes, _ := alertEpisodes(changes, q.End)
+
+	rules := map[string]*v3.RuleAnalytics{}
+	labelSets := map[string]map[uint64]*v3.LabelSetAnalytics{}
+	ruleTrends := map[string]map[int64]uint64{}
+	resolvedMilli := map[string]int64{}
+	trend := map[int64]uint64{}
+	for _, e := range episodes {
+		ra, ok := rules[e.ruleID]
+		if !ok {
+			ra = &v3.RuleAnalytics{RuleID: e.ruleID, RuleName: e.ruleName, Team: teams[e.ruleID]}
+			rules[e.ruleID] = ra
+			labelSets[e.ruleID] = map[uint64]*v3.LabelSetAnalytics{}
+			ruleTrends[e.ruleID] = map[int64]uint64{}
+		}
+		ra.Triggers++
+		ra.FiringMinutes += float64(e.duration()) / 60000
+		if e.resolved {
+			ra.Resolved++
+			resolvedMilli[e.ruleID] += e.duration()
+		}
+		ls, ok := labelSets[e.ruleID][e.fingerprint]
+		if !ok {
+			ls = &v3.LabelSetAnalytics{Fingerprint: e.fingerprint, Labels: e.labels}
+			labelSets[e.ruleID][e.fingerprint] = ls
+		}
+		ls.Triggers++
+		ls.FiringMinutes += float64(e.duration()) / 60000
+		ruleTrends[e.ruleID][bucket(e.start)]++
+		trend[bucket(e.start)]++
+	}
+
+	res := &v3.AlertAnalytics{Rules: []v3.RuleAnalytics{}, Teams: []v3.TeamAnalytics{}}
+	teamStats := map[string]*v3.TeamAnalytics{}
+	teamResolvedMilli := map[string]int64{}
+	for id, ra := range rules {
+		if ra.Resolved > 0 {
+			ra.MeanTimeToResolve = float64(resolvedMilli[id]) / float64(ra.Resolved) / 1000
+		}
+		for _, ls := range labelSets[id] {
+			ra.NoisiestLabels = append(ra.NoisiestLabels, *ls)
+		}
+		sort.Slice(ra.NoisiestLabels, func(i, j int) bool {
+			a, b := ra.NoisiestLabels[i], ra.NoisiestLabels[j]
+			if a.Triggers != b.Triggers {
+				return a.Triggers > b.Triggers
+			}
+			return a.FiringMinutes > b.FiringMinutes
+		})
+		if len(ra.NoisiestLabels) > q.Limit {
+			ra.NoisiestLabels = ra.NoisiestLabels[:q.Limit]
+		}
+		ra.Trend = trendBuckets(ruleTrends[id])
+		res.Rules = append(res.Rules, *ra)
+
+		ts, ok := teamStats[ra.Team]
+		if !ok {
+			ts = &v3.TeamAnalytics{Team: ra.Team}
+			teamStats[ra.Team] = ts
+		}
+		ts.Rules++
+		ts.Triggers += ra.Triggers
+		ts.Resolved += ra.Resolved
+		ts.FiringMinutes += ra.FiringMinutes
+		teamResolvedMilli[ra.Team] += resolvedMilli[id]
+	}
+
+	// the noisiest rules first
+	sort.Slice(res.Rules, func(i, j int) bool {
+		a, b := res.Rules[i], res.Rules[j]
+		if a.Triggers != b.Triggers {
+			return a.Triggers > b.Triggers
+		}
+		return a.RuleID < b.RuleID
+	})
+	for team, ts := range teamStats {
+		if ts.Resolved > 0 {
+			ts.MeanTimeToResolve = float64(teamResolvedMilli[team]) / float64(ts.Resolved) / 1000
+		}
+		res.Teams = append(res.Teams, *ts)
+	}
+	sort.Slice(res.Teams, func(i, j int) bool {
+		a, b := res.Teams[i], res.Teams[j]
+		if a.Triggers != b.Triggers {
+			return a.Triggers > b.Triggers
+		}
+		return a.Team < b.Team
+	})
+	res.Trend = trendBuckets(trend)
+	return res
+}
+
+func trendBuckets(trend map[int64]uint64) []v3.AnalyticsBucket {
+	buckets := make([]v3.AnalyticsBucket, 0, len(trend))
+	for ts, n := range trend {
+		buckets = append(buckets, v3.AnalyticsBucket{Timestamp: ts, Triggers: n})
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].Timestamp < buckets[j].Timestamp
+	})
+	return buckets
+}
+
+// AlertAnalytics computes the alert frequency, the time to resolve and the
+// firing time of the rules and their teams from the state history, for
+// the reviews of the alert quality. The requests of a user only see the
+// rules of their org.
+func (m *Manager) AlertAnalytics(ctx context.Context, q *v3.QueryAlertAnalytics) (*v3.AlertAnalytics, error) {
+	if m.reader == nil {
+		return nil, fmt.Errorf("the state history is not stored")
+	}
+	visible, err := m.tenantRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := m.reader.ReadRuleStateChanges(ctx, q.Start, q.End)
+	if err != nil {
+		return nil, err
+	}
+	changes = visibleStateChanges(changes, visible)
+
+	teams := map[string]string{}
+	m.mtx.RLock()
+	for id, r := range m.rules {
+		if r.Labels().Has(q.TeamLabel) {
+			teams[id] = r.Labels().Get(q.TeamLabel)
+		}
+	}
+	m.mtx.RUnlock()
+
+	return computeAlertAnalytics(changes, q, teams), nil
+}
@@ -0,0 +1,59 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func TestComputeAlertAnalytics(t *testing.T) {
+	const minute = int64(60 * 1000)
+	const start = int64(1700000000000)
+	change := func(rule string, fp uint64, state string, ts int64) v3.RuleStateHistory {
+		return v3.RuleStateHistory{RuleID: rule, RuleName: "rule " + rule, Fingerprint: fp, Labels: v3.LabelsString(`{"fp":"x"}`), State: state, StateChanged: true, UnixMilli: start + ts}
+	}
+	changes := []v3.RuleStateHistory{
+		change("1", 1, "firing", 0),
+		change("2", 3, "firing", 5*minute),
+		change("1", 1, "normal", 10*minute),
+		change("1", 2, "firing", 20*minute),
+		change("1", 1, "firing", 30*minute),
+		change("1", 2, "no_data", 40*minute),
+		change("1", 1, "normal", 50*minute),
+		change("3", 4, "normal", 55*minute),
+	}
+	q := &v3.QueryAlertAnalytics{Start: start, End: start + 120*minute, Step: 60 * 60}
+	require.NoError(t, q.Validate())
+
+	res := computeAlertAnalytics(changes, q, map[string]string{"1": "payments", "2": "payments"})
+
+	// the rule without a trigger is left out
+	require.Len(t, res.Rules, 2)
+	r1 := res.Rules[0]
+	assert.Equal(t, "1", r1.RuleID)
+	assert.Equal(t, "payments", r1.Team)
+	assert.Equal(t, uint64(3), r1.Triggers)
+	assert.Equal(t, uint64(3), r1.Resolved)
+	assert.InDelta(t, float64(50*60)/3, r1.MeanTimeToResolve, 0.001)
+	assert.InDelta(t, 50, r1.FiringMinutes, 0.001)
+	require.Len(t, r1.NoisiestLabels, 2)
+	assert.Equal(t, uint64(1), r1.NoisiestLabels[0].Fingerprint)
+	assert.Equal(t, uint64(2), r1.NoisiestLabels[0].Triggers)
+	assert.Equal(t, []v3.AnalyticsBucket{{Timestamp: start, Triggers: 3}}, r1.Trend)
+
+	// the alert still firing fires until the end
+	r2 := res.Rules[1]
+	assert.Equal(t, uint64(1), r2.Triggers)
+	assert.Equal(t, uint64(0), r2.Resolved)
+	assert.InDelta(t, 115, r2.FiringMinutes, 0.001)
+
+	require.Len(t, res.Teams, 1)
+	assert.Equal(t, "payments", res.Teams[0].Team)
+	assert.Equal(t, 2, res.Teams[0].Rules)
+	assert.Equal(t, uint64(4), res.Teams[0].Triggers)
+	assert.InDelta(t, float64(50*60)/3, res.Teams[0].MeanTimeToResolve, 0.001)
+
+	assert.Equal(t, []v3.AnalyticsBucket{{Timestamp: start, Triggers: 4}}, res.Trend)
+}
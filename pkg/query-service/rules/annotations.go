@@ -0,0 +1,193 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	plabels "github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// AnnotationsRequest selects the alert annotations of a time range
+type AnnotationsRequest struct {
+	Start int64
+	End   int64
+	// Matchers is a selector over the labels of the alerts and their rule,
+	// e.g. {service="api",severity=~"critical|error"}
+	Matchers string
+	RuleID   string
+	Limit    int
+}
+
+func (r *AnnotationsRequest) Validate() error {
+	if r.Start == 0 || r.End == 0 {
+		return fmt.Errorf("start and end are required")
+	}
+	if r.End <= r.Start {
+		return fmt.Errorf("end must be after start")
+	}
+	if r.Limit < 0 {
+		return fmt.Errorf("limit must be greater than 0")
+	}
+	if r.Matchers != "" {
+		if _, err := parser.ParseMetricSelector(r.Matchers); err != nil {
+			return fmt.Errorf("invalid matchers: %w", err)
+		}
+	}
+	return nil
+}
+
+// Annotation marks the period an alert fired, for the dashboard panels
+// to overlay
+type Annotation struct {
+	// Time is when the alert started firing
+	Time int64 `json:"time"`
+	// TimeEnd is when the alert resolved, zero while it fires
+	TimeEnd  int64             `json:"timeEnd,omitempty"`
+	RuleID   string            `json:"ruleId"`
+	RuleName string            `json:"ruleName"`
+	State    string            `json:"state"`
+	Title    string            `json:"title"`
+	Text     string            `json:"text"`
+	Labels   map[string]string `json:"labels"`
+	Tags     []string          `json:"tags"`
+	// Link opens the rule
+	Link string `json:"link,omitempty"`
+}
+
+// ruleAnnotationInfo is what the annotations take from the rule
+type ruleAnnotationInfo struct {
+	labels map[string]string
+	link   string
+}
+
+// buildAnnotations turns the firing episodes of the alerts into
+// annotations, the alerts are matched on their labels with the labels of
+// their rule, the rule name as alertname and the rule id as ruleId
+func buildAnnotations(changes []v3.RuleStateHistory, req *AnnotationsRequest, rules map[string]ruleAnnotationInfo) ([]Annotation, error) {
+	var matchers []*plabels.Matcher
+	if req.Matchers != "" {
+		var err error
+		matchers, err = parser.ParseMetricSelector(req.Matchers)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	episodes, _ := alertEpisodes(changes, req.End)
+	annotations := []Annotation{}
+	for _, e := range episodes {
+		if req.RuleID != "" && e.ruleID != req.RuleID {
+			continue
+		}
+		lbls := map[string]string{}
+		if e.labels != "" {
+			if err := json.Unmarshal([]byte(e.labels), &lbls); err != nil {
+				continue
+			}
+		}
+		info := rules[e.ruleID]
+		matched := map[string]string{}
+		for k, v := range info.labels {
+			matched[k] = v
+		}
+		for k, v := range lbls {
+			matched[k] = v
+		}
+		matched[labels.AlertNameLabel] = e.ruleName
+		matched[labels.AlertRuleIdLabel] = e.ruleID
+		if !matchesAll(matchers, matched) {
+			continue
+		}
+
+		a := Annotation{
+			Time:     e.start,
+			RuleID:   e.ruleID,
+			RuleName: e.ruleName,
+			State:    StateFiring.String(),
+			Title:    fmt.Sprintf("%s fired", e.ruleName),
+			Labels:   lbls,
+			Tags:     []string{"alert"},
+			Link:     info.link,
+		}
+		if e.resolved {
+			a.TimeEnd = e.end
+			a.State = "resolved"
+			a.Text = fmt.Sprintf("resolved after %s", time.Duration(e.duration())*time.Millisecond)
+		}
+		if len(lbls) > 0 {
+			a.Text = strings.TrimSpace(labelsText(lbls) + "\n" + a.Text)
+		}
+		if severity, ok := info.labels["severity"]; ok {
+			a.Tags = append(a.Tags, severity)
+		}
+		annotations = append(annotations, a)
+	}
+
+	sort.SliceStable(annotations, func(i, j int) bool {
+		return annotations[i].Time < annotations[j].Time
+	})
+	if req.Limit > 0 && len(annotations) > req.Limit {
+		// the latest annotations are kept
+		annotations = annotations[len(annotations)-req.Limit:]
+	}
+	return annotations, nil
+}
+
+func matchesAll(matchers []*plabels.Matcher, lbls map[string]string) bool {
+	for _, m := range matchers {
+		if !m.Matches(lbls[m.Name]) {
+			return false
+		}
+	}
+	return true
+}
+
+func labelsText(lbls map[string]string) string {
+	keys := make([]string, 0, len(lbls))
+	for k := range lbls {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+lbls[k])
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// Annotations returns the periods the alerts of the rules fired as
+// annotations the dashboard panels overlay
+func (m *Manager) Annotations(ctx context.Context, req *AnnotationsRequest) ([]Annotation, error) {
+	if m.reader == nil {
+		return nil, fmt.Errorf("the state history is not stored")
+	}
+	visible, err := m.tenantRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := m.reader.ReadRuleStateChanges(ctx, req.Start, req.End)
+	if err != nil {
+		return nil, err
+	}
+	changes = visibleStateChanges(changes, visible)
+
+	rules := map[string]ruleAnnotationInfo{}
+	m.mtx.RLock()
+	for id, r := range m.rules {
+		info := ruleAnnotationInfo{labels: r.Labels().Map()}
+		if g, ok := r.(interface{ GeneratorURL() string }); ok {
+			info.link = g.GeneratorURL()
+		}
+		rules[id] = info
+	}
+	m.mtx.RUnlock()
+
+	return buildAnnotations(changes, req, rules)
+}
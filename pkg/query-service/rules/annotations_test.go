@@ -0,0 +1,105 @@
+package rules
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/constants"
+	"go.signoz.io/signoz/pkg/query-service/interfaces"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func TestBuildAnnotations(t *testing.T) {
+	const minute = int64(60 * 1000)
+	const start = int64(1700000000000)
+	change := func(rule string, fp uint64, lbls string, state string, ts int64) v3.RuleStateHistory {
+		return v3.RuleStateHistory{RuleID: rule, RuleName: "rule " + rule, Fingerprint: fp, Labels: v3.LabelsString(lbls), State: state, StateChanged: true, UnixMilli: start + ts}
+	}
+	changes := []v3.RuleStateHistory{
+		change("1", 1, `{"service":"api"}`, "firing", 0),
+		change("1", 2, `{"service":"web"}`, "firing", minute),
+		change("1", 1, `{"service":"api"}`, "normal", 10*minute),
+		change("2", 3, `{"service":"api"}`, "firing", 20*minute),
+	}
+	rules := map[string]ruleAnnotationInfo{
+		"1": {labels: map[string]string{"severity": "critical"}, link: "http://signoz/alerts/edit?ruleId=1"},
+		"2": {labels: map[string]string{"severity": "warning"}},
+	}
+	req := &AnnotationsRequest{Start: start, End: start + 60*minute}
+	require.NoError(t, req.Validate())
+
+	annotations, err := buildAnnotations(changes, req, rules)
+	require.NoError(t, err)
+	require.Len(t, annotations, 3)
+	assert.Equal(t, start, annotations[0].Time)
+	assert.Equal(t, start+10*minute, annotations[0].TimeEnd)
+	assert.Equal(t, "resolved", annotations[0].State)
+	assert.Equal(t, "rule 1 fired", annotations[0].Title)
+	assert.Equal(t, "service=api\nresolved after 10m0s", annotations[0].Text)
+	assert.Equal(t, []string{"alert", "critical"}, annotations[0].Tags)
+	assert.Equal(t, "http://signoz/alerts/edit?ruleId=1", annotations[0].Link)
+	assert.Zero(t, annotations[1].TimeEnd)
+	assert.Equal(t, "firing", annotations[1].State)
+
+	// the matchers see the labels of the rule and the alert name
+	req.Matchers = `{service="api",severity=~"crit.*"}`
+	annotations, err = buildAnnotations(changes, req, rules)
+	require.NoError(t, err)
+	require.Len(t, annotations, 1)
+	assert.Equal(t, "1", annotations[0].RuleID)
+
+	req.Matchers = `{alertname="rule 2"}`
+	annotations, err = buildAnnotations(changes, req, rules)
+	require.NoError(t, err)
+	require.Len(t, annotations, 1)
+	assert.Equal(t, "2", annotations[0].RuleID)
+
+	req.Matchers, req.RuleID, req.Limit = "", "1", 1
+	annotations, err = buildAnnotations(changes, req, rules)
+	require.NoError(t, err)
+	require.Len(t, annotations, 1)
+	assert.Equal(t, start+minute, annotations[0].Time)
+
+	req.Matchers = `{service=`
+	assert.Error(t, req.Validate())
+}
+
+// stateHistoryReader returns the state changes
+type stateHistoryReader struct {
+	interfaces.Reader
+	changes []v3.RuleStateHistory
+}
+
+func (r *stateHistoryReader) ReadRuleStateChanges(context.Context, int64, int64) ([]v3.RuleStateHistory, error) {
+	return r.changes, nil
+}
+
+func TestAnnotationsScopedByOrg(t *testing.T) {
+	const start = int64(1700000000000)
+	m := &Manager{
+		rules: map[string]Rule{},
+		ruleDB: &bundleDB{rules: []StoredRule{
+			{Id: 1, OrgId: "org-a"},
+			{Id: 2, OrgId: "org-b"},
+		}},
+		reader: &stateHistoryReader{changes: []v3.RuleStateHistory{
+			{RuleID: "1", RuleName: "rule 1", Fingerprint: 1, State: "firing", StateChanged: true, UnixMilli: start},
+			{RuleID: "2", RuleName: "rule 2", Fingerprint: 2, State: "firing", StateChanged: true, UnixMilli: start},
+		}},
+		opts: &ManagerOptions{},
+	}
+	req := &AnnotationsRequest{Start: start, End: start + 60*60*1000}
+
+	orgA := context.WithValue(context.Background(), constants.ContextUserKey, &model.UserPayload{User: model.User{OrgId: "org-a"}})
+	annotations, err := m.Annotations(orgA, req)
+	require.NoError(t, err)
+	require.Len(t, annotations, 1)
+	assert.Equal(t, "1", annotations[0].RuleID)
+
+	annotations, err = m.Annotations(context.Background(), req)
+	require.NoError(t, err)
+	assert.Len(t, annotations, 2)
+}
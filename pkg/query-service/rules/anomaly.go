@@ -0,0 +1,214 @@
+package rules
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+// The sensitivity presets of the anomaly detection
+const (
+	SensitivityLow    = "low"
+	SensitivityMedium = "medium"
+	SensitivityHigh   = "high"
+)
+
+const (
+	DefaultAnomalySensitivity = SensitivityMedium
+	DefaultAnomalyMinPoints   = 10
+)
+
+// anomalyBounds is the number of standard deviations a value may be away
+// from the mean of its series for every sensitivity, the more sensitive
+// the detection the more values are anomalies
+var anomalyBounds = map[string]float64{
+	SensitivityLow:    4,
+	SensitivityMedium: 3,
+	SensitivityHigh:   2,
+}
+
+// AnomalyDetection compares the latest value of every series of the
+// selected query with the values of the series over the eval window
+// instead of with the target. The value is an anomaly when it is more
+// standard deviations away from their mean than the sensitivity allows.
+// The compare op of the rule, when set, restricts the anomalies to the
+// values above or below the mean.
+type AnomalyDetection struct {
+	// Sensitivity is low, medium or high, DefaultAnomalySensitivity when
+	// not set
+	Sensitivity string `yaml:"sensitivity,omitempty" json:"sensitivity,omitempty"`
+	// Overrides set the sensitivity of the series matching their labels,
+	// the first matching override is used
+	Overrides []AnomalyOverride `yaml:"overrides,omitempty" json:"overrides,omitempty"`
+	// Exclude are the label sets of the series the detection ignores, e.g.
+	// a batch job with a known spiky load
+	Exclude []map[string]string `yaml:"exclude,omitempty" json:"exclude,omitempty"`
+	// MinPoints is the number of points a series needs before its latest
+	// value is compared, DefaultAnomalyMinPoints when not set
+	MinPoints int `yaml:"minPoints,omitempty" json:"minPoints,omitempty"`
+}
+
+// AnomalyOverride is the sensitivity of the series matching the labels
+type AnomalyOverride struct {
+	Labels      map[string]string `yaml:"labels" json:"labels"`
+	Sensitivity string            `yaml:"sensitivity" json:"sensitivity"`
+}
+
+func (a *AnomalyDetection) minPoints() int {
+	if a.MinPoints == 0 {
+		return DefaultAnomalyMinPoints
+	}
+	return a.MinPoints
+}
+
+// excluded tells whether the series with the labels is ignored
+func (a *AnomalyDetection) excluded(lbls map[string]string) bool {
+	for _, ex := range a.Exclude {
+		if hasLabels(ex, lbls) {
+			return true
+		}
+	}
+	return false
+}
+
+// bound returns the number of standard deviations allowed for the series
+// with the labels
+func (a *AnomalyDetection) bound(lbls map[string]string) float64 {
+	sensitivity := a.Sensitivity
+	for _, o := range a.Overrides {
+		if hasLabels(o.Labels, lbls) {
+			sensitivity = o.Sensitivity
+			break
+		}
+	}
+	if bound, ok := anomalyBounds[sensitivity]; ok {
+		return bound
+	}
+	return anomalyBounds[DefaultAnomalySensitivity]
+}
+
+func validSensitivity(s string) bool {
+	_, ok := anomalyBounds[s]
+	return ok
+}
+
+func (r *PostableRule) validateAnomaly() []error {
+	a := r.RuleCondition.Anomaly
+	if a == nil {
+		return nil
+	}
+	var errs []error
+	if r.RuleCondition.Canary != nil || len(r.RuleCondition.QueryThresholds) > 0 {
+		errs = append(errs, fmt.Errorf("anomaly detection can not be combined with canary analysis or query thresholds"))
+	}
+	// the score is compared to the bound of the sensitivity, not to a
+	// target, and needs the whole history of the series
+	if r.RuleCondition.Target != nil {
+		errs = append(errs, fmt.Errorf("anomaly detection can not be combined with a target"))
+	}
+	if r.RuleCondition.StreamResults {
+		errs = append(errs, fmt.Errorf("anomaly detection scores the history of the series and can not stream the results"))
+	}
+	if a.Sensitivity != "" && !validSensitivity(a.Sensitivity) {
+		errs = append(errs, fmt.Errorf("sensitivity must be %s, %s or %s", SensitivityLow, SensitivityMedium, SensitivityHigh))
+	}
+	for i, o := range a.Overrides {
+		if len(o.Labels) == 0 {
+			errs = append(errs, fmt.Errorf("sensitivity override %d needs labels", i))
+		}
+		if !validSensitivity(o.Sensitivity) {
+			errs = append(errs, fmt.Errorf("sensitivity of override %d must be %s, %s or %s", i, SensitivityLow, SensitivityMedium, SensitivityHigh))
+		}
+	}
+	for i, ex := range a.Exclude {
+		if len(ex) == 0 {
+			errs = append(errs, fmt.Errorf("exclusion %d needs labels, it would ignore every series", i))
+		}
+	}
+	if a.MinPoints < 0 {
+		errs = append(errs, fmt.Errorf("anomaly min points must not be negative"))
+	}
+	return errs
+}
+
+// anomalyScore returns the number of standard deviations the latest point
+// of the series is away from the mean of the previous points, false when
+// the series has too few points or no variance to compare with
+func anomalyScore(points []v3.Point, minPoints int) (value, mean, stdDev, score float64, ok bool) {
+	if len(points) < minPoints+1 {
+		return 0, 0, 0, 0, false
+	}
+	history, latest := points[:len(points)-1], points[len(points)-1].Value
+	for _, p := range history {
+		mean += p.Value
+	}
+	mean /= float64(len(history))
+	var squares float64
+	for _, p := range history {
+		squares += (p.Value - mean) * (p.Value - mean)
+	}
+	stdDev = math.Sqrt(squares / float64(len(history)))
+	if stdDev == 0 {
+		return 0, 0, 0, 0, false
+	}
+	return latest, mean, stdDev, (latest - mean) / stdDev, true
+}
+
+// anomalyVector returns a sample for every series of the selected query
+// whose latest value is an anomaly, the value of the sample is the score
+// of the value in standard deviations
+func (r *ThresholdRule) anomalyVector(queryResult *v3.Result, ts time.Time) Vector {
+	a := r.ruleCondition.Anomaly
+	if queryResult == nil {
+		return nil
+	}
+	var vector Vector
+	for _, series := range queryResult.Series {
+		if a.excluded(series.Labels) {
+			continue
+		}
+		points := removeGroupinSetPoints(*series)
+		value, mean, stdDev, score, ok := anomalyScore(points, a.minPoints())
+		if !ok {
+			continue
+		}
+		bound := a.bound(series.Labels)
+		switch r.ruleCondition.CompareOp {
+		case ValueIsAbove:
+			ok = score > bound
+		case ValueIsBelow:
+			ok = score < -bound
+		default:
+			ok = math.Abs(score) > bound
+		}
+		if !ok {
+			continue
+		}
+		lbls, lblsNormalized := sampleLabels(series.Labels)
+		vector = append(vector, Sample{
+			Point:        Point{T: ts.UnixMilli(), V: score},
+			Metric:       lblsNormalized,
+			MetricOrig:   lbls,
+			SeriesPoints: points,
+			QueryValues: map[string]float64{
+				"value":  value,
+				"mean":   mean,
+				"stdDev": stdDev,
+			},
+			Threshold: &bound,
+		})
+	}
+	return vector
+}
+
+// hasLabels tells whether the labels have every value of want
+func hasLabels(want, lbls map[string]string) bool {
+	for k, v := range want {
+		if lbls[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,136 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func anomalySeries(lbls map[string]string, values ...float64) *v3.Series {
+	s := &v3.Series{Labels: lbls}
+	for i, v := range values {
+		s.Points = append(s.Points, v3.Point{Timestamp: int64(i) * 60000, Value: v})
+	}
+	return s
+}
+
+func TestAnomalyScore(t *testing.T) {
+	// mean 10 and standard deviation 1
+	history := anomalySeries(nil, 9, 11, 9, 11, 9, 11, 9, 11, 9, 11, 14)
+	value, mean, stdDev, score, ok := anomalyScore(history.Points, 10)
+	require.True(t, ok)
+	assert.Equal(t, 14.0, value)
+	assert.Equal(t, 10.0, mean)
+	assert.Equal(t, 1.0, stdDev)
+	assert.Equal(t, 4.0, score)
+
+	_, _, _, _, ok = anomalyScore(history.Points, 20)
+	assert.False(t, ok, "too few points")
+	_, _, _, _, ok = anomalyScore(anomalySeries(nil, 5, 5, 5, 5, 6).Points, 3)
+	assert.False(t, ok, "no variance")
+}
+
+func TestAnomalyVector(t *testing.T) {
+	values := []float64{9, 11, 9, 11, 9, 11, 9, 11, 9, 11}
+	result := &v3.Result{QueryName: "A", Series: []*v3.Series{
+		// 2.5 standard deviations above the mean
+		anomalySeries(map[string]string{"service": "api"}, append(values, 12.5)...),
+		anomalySeries(map[string]string{"service": "web"}, append(values, 12.5)...),
+		anomalySeries(map[string]string{"service": "batch"}, append(values, 20)...),
+		// 2.5 standard deviations below the mean
+		anomalySeries(map[string]string{"service": "db"}, append(values, 7.5)...),
+	}}
+	ts := time.Now()
+
+	cases := []struct {
+		name    string
+		op      CompareOp
+		anomaly *AnomalyDetection
+		want    []string
+	}{
+		{
+			name:    "medium by default",
+			anomaly: &AnomalyDetection{},
+			want:    []string{"batch"},
+		},
+		{
+			name:    "high preset",
+			anomaly: &AnomalyDetection{Sensitivity: SensitivityHigh},
+			want:    []string{"api", "web", "batch", "db"},
+		},
+		{
+			name: "overrides and exclusions",
+			anomaly: &AnomalyDetection{
+				Sensitivity: SensitivityLow,
+				Overrides:   []AnomalyOverride{{Labels: map[string]string{"service": "web"}, Sensitivity: SensitivityHigh}},
+				Exclude:     []map[string]string{{"service": "batch"}},
+			},
+			want: []string{"web"},
+		},
+		{
+			name:    "values above the mean",
+			op:      ValueIsAbove,
+			anomaly: &AnomalyDetection{Sensitivity: SensitivityHigh, Exclude: []map[string]string{{"service": "batch"}}},
+			want:    []string{"api", "web"},
+		},
+		{
+			name:    "values below the mean",
+			op:      ValueIsBelow,
+			anomaly: &AnomalyDetection{Sensitivity: SensitivityHigh},
+			want:    []string{"db"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &ThresholdRule{ruleCondition: &RuleCondition{CompareOp: tc.op, Anomaly: tc.anomaly}}
+			var got []string
+			for _, smpl := range r.anomalyVector(result, ts) {
+				got = append(got, smpl.Metric.Get("service"))
+				assert.Equal(t, 10.0, smpl.QueryValues["mean"])
+				require.NotNil(t, smpl.Threshold)
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+	// the points of the grouping sets are not part of the series
+	withGroupingSet := anomalySeries(map[string]string{"service": "batch"}, append(values, 20)...)
+	withGroupingSet.Points = append(withGroupingSet.Points, v3.Point{Timestamp: -1, Value: 200})
+	r := &ThresholdRule{ruleCondition: &RuleCondition{Anomaly: &AnomalyDetection{}}}
+	vector := r.anomalyVector(&v3.Result{QueryName: "A", Series: []*v3.Series{withGroupingSet}}, ts)
+	require.Len(t, vector, 1)
+	assert.Equal(t, removeGroupinSetPoints(*withGroupingSet), vector[0].SeriesPoints)
+	assert.Len(t, vector[0].SeriesPoints, 11)
+}
+
+func TestValidateAnomaly(t *testing.T) {
+	rule := func(a *AnomalyDetection) *PostableRule {
+		return &PostableRule{RuleType: RuleTypeThreshold, RuleCondition: &RuleCondition{
+			CompositeQuery: &v3.CompositeQuery{BuilderQueries: map[string]*v3.BuilderQuery{"A": {QueryName: "A"}}},
+			Anomaly:        a,
+		}}
+	}
+	valid := &AnomalyDetection{
+		Sensitivity: SensitivityHigh,
+		Overrides:   []AnomalyOverride{{Labels: map[string]string{"service": "api"}, Sensitivity: SensitivityLow}},
+		Exclude:     []map[string]string{{"service": "batch"}},
+	}
+	assert.Empty(t, rule(valid).validateAnomaly())
+	assert.True(t, rule(valid).RuleCondition.IsValid(), "anomaly rules need no target nor compare op")
+
+	assert.Len(t, rule(&AnomalyDetection{
+		Sensitivity: "extreme",
+		Overrides:   []AnomalyOverride{{Sensitivity: "none"}},
+		Exclude:     []map[string]string{{}},
+		MinPoints:   -1,
+	}).validateAnomaly(), 5)
+
+	// the target and the streamed results can not be used with the scores
+	combined := rule(valid)
+	target := 10.0
+	combined.RuleCondition.Target = &target
+	combined.RuleCondition.StreamResults = true
+	assert.Len(t, combined.validateAnomaly(), 2)
+}
@@ -8,6 +8,7 @@ import (
 	"unicode/utf8"
 
 	"github.com/pkg/errors"
+	"go.signoz.io/signoz/pkg/query-service/constants"
 	"go.signoz.io/signoz/pkg/query-service/model"
 	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
 	"go.uber.org/multierr"
@@ -61,6 +62,27 @@ type PostableRule struct {
 	EvalWindow  Duration  `yaml:"evalWindow,omitempty" json:"evalWindow,omitempty"`
 	Frequency   Duration  `yaml:"frequency,omitempty" json:"frequency,omitempty"`
 
+	// EvalResolution is the step of the series evaluated by the rule,
+	// defaults to one minute when not set
+	EvalResolution Duration `yaml:"evalResolution,omitempty" json:"evalResolution,omitempty"`
+
+	// HoldDuration is how long a series breaches before its alert fires,
+	// the alerts are pending until then. It was ignored by the earlier
+	// releases, see the changelog.
+	HoldDuration Duration `yaml:"for,omitempty" json:"for,omitempty"`
+
+	// Calendars are the event calendars of the org that suspend the rule
+	// or relax its thresholds during their events
+	Calendars []CalendarRef `yaml:"calendars,omitempty" json:"calendars,omitempty"`
+
+	// DeployPolicy suppresses or down-ranks the alerts starting shortly
+	// after a deploy of their service
+	DeployPolicy *DeployPolicy `yaml:"deployPolicy,omitempty" json:"deployPolicy,omitempty"`
+
+	// Runbook is linked from the notifications and embedded in the alert
+	// details for the responders
+	Runbook *Runbook `yaml:"runbook,omitempty" json:"runbook,omitempty"`
+
 	RuleCondition *RuleCondition    `yaml:"condition,omitempty" json:"condition,omitempty"`
 	Labels        map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
 	Annotations   map[string]string `yaml:"annotations,omitempty" json:"annotations,omitempty"`
@@ -72,6 +94,23 @@ type PostableRule struct {
 
 	PreferredChannels []string `json:"preferredChannels,omitempty"`
 
+	// Backend is the name of the query backend the rule is evaluated
+	// against, the default backend when not set
+	Backend string `yaml:"backend,omitempty" json:"backend,omitempty"`
+
+	// Variables are the values of the {{.name}} references in the queries,
+	// labels and annotations of the rule, so that copies of the rule can
+	// watch other environments
+	Variables map[string]string `yaml:"variables,omitempty" json:"variables,omitempty"`
+
+	// QueryQuota limits the raw clickhouse queries of the rule, within
+	// the limits of the server
+	QueryQuota *QueryQuota `yaml:"queryQuota,omitempty" json:"queryQuota,omitempty"`
+
+	// RelabelConfigs rewrite the labels of the series of the rule before
+	// the alerts are created, e.g. to drop the ephemeral labels
+	RelabelConfigs []RelabelConfig `yaml:"relabelConfigs,omitempty" json:"relabelConfigs,omitempty"`
+
 	Version string `json:"version,omitempty"`
 
 	// legacy
@@ -139,7 +178,12 @@ func parseIntoRule(initRule PostableRule, content []byte, kind RuleDataKind) (*P
 		}
 
 		for qLabel, q := range rule.RuleCondition.CompositeQuery.BuilderQueries {
-			if q.AggregateAttribute.Key != "" && q.Expression == "" {
+			if q.QueryName == "" {
+				q.QueryName = qLabel
+			}
+			// a query without an expression is not a formula, e.g. a
+			// count of logs has no aggregate attribute
+			if q.Expression == "" {
 				q.Expression = qLabel
 			}
 		}
@@ -181,17 +225,70 @@ func (r *PostableRule) Validate() error {
 	}
 
 	if r.RuleType == RuleTypeThreshold {
-		if r.RuleCondition.Target == nil {
+		canary := r.RuleCondition.Canary != nil
+		anomaly := r.RuleCondition.Anomaly != nil
+		if r.RuleCondition.Target == nil && len(r.RuleCondition.QueryThresholds) == 0 && r.RuleCondition.ExpectedMembers == nil && !canary && !anomaly {
 			errs = append(errs, errors.Errorf("rule condition missing the threshold"))
 		}
-		if r.RuleCondition.CompareOp == "" {
+		errs = append(errs, r.validateQueryThresholds()...)
+		errs = append(errs, r.validateExpectedMembers()...)
+		errs = append(errs, r.validateConfirmation()...)
+		errs = append(errs, r.validateCanary()...)
+		errs = append(errs, r.validateAnomaly()...)
+		if r.RuleCondition.CompareOp == "" && !canary && !anomaly {
 			errs = append(errs, errors.Errorf("rule condition missing the compare op"))
 		}
-		if r.RuleCondition.MatchType == "" {
+		if r.RuleCondition.MatchType == "" && !canary && !anomaly {
 			errs = append(errs, errors.Errorf("rule condition missing the match option"))
 		}
 	}
 
+	if _, err := compileRelabelConfigs(r.RelabelConfigs); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := r.validateEvalResolution(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if r.HoldDuration < 0 {
+		errs = append(errs, errors.Errorf("for must not be negative"))
+	}
+
+	errs = append(errs, r.validateCalendars()...)
+
+	if r.DeployPolicy != nil {
+		if err := r.DeployPolicy.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if r.Runbook != nil {
+		if err := r.Runbook.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if r.RuleCondition != nil {
+		if err := r.RuleCondition.validateRecoveryTarget(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if r.RuleCondition != nil {
+		if err := r.validateDownsampling(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if r.RuleCondition != nil && r.RuleCondition.CompositeQuery != nil && r.RuleCondition.CompositeQuery.QueryType == v3.QueryTypeBuilder {
+		errs = append(errs, r.validateBuilderQueries()...)
+	}
+
+	if err := validateVariables(r.Variables); err != nil {
+		errs = append(errs, err)
+	}
+
 	for k, v := range r.Labels {
 		if !isValidLabelName(k) {
 			errs = append(errs, errors.Errorf("invalid label name: %s", k))
@@ -212,6 +309,170 @@ func (r *PostableRule) Validate() error {
 	return multierr.Combine(errs...)
 }
 
+// validateBuilderQueries checks the formulas, the selected query and the
+// query units of a builder rule
+func (r *PostableRule) validateBuilderQueries() []error {
+	cq := r.RuleCondition.CompositeQuery
+	errs := validateFormulas(cq)
+	if selected := r.RuleCondition.SelectedQuery; selected != "" {
+		if _, ok := cq.BuilderQueries[selected]; !ok {
+			errs = append(errs, errors.Errorf("selected query %s is not a query of the rule", selected))
+		}
+	}
+	for name := range r.RuleCondition.QueryUnits {
+		if _, ok := cq.BuilderQueries[name]; !ok {
+			errs = append(errs, errors.Errorf("unit of unknown query %s", name))
+		}
+	}
+	return errs
+}
+
+// validateExpectedMembers checks that the expected members name a label
+// and have static values or a known discovery query
+func (r *PostableRule) validateExpectedMembers() []error {
+	m := r.RuleCondition.ExpectedMembers
+	cq := r.RuleCondition.CompositeQuery
+	if m == nil || cq == nil {
+		return nil
+	}
+	var errs []error
+	if m.Label == "" {
+		errs = append(errs, errors.Errorf("expected members missing the label"))
+	}
+	if len(m.Values) == 0 && m.Query == "" {
+		errs = append(errs, errors.Errorf("expected members need values or a discovery query"))
+	}
+	if m.Query != "" {
+		_, builder := cq.BuilderQueries[m.Query]
+		_, clickhouse := cq.ClickHouseQueries[m.Query]
+		if !builder && !clickhouse {
+			errs = append(errs, errors.Errorf("discovery query %s is not a query of the rule", m.Query))
+		}
+	}
+	if len(r.RuleCondition.QueryThresholds) > 0 {
+		errs = append(errs, errors.Errorf("expected members cannot be combined with query thresholds"))
+	}
+	return errs
+}
+
+// validateConfirmation checks that the confirmation is a query of the
+// rule with a target, other than the selected query and not used by the
+// formulas, which run without it
+func (r *PostableRule) validateConfirmation() []error {
+	c := r.RuleCondition.Confirmation
+	cq := r.RuleCondition.CompositeQuery
+	if c == nil || cq == nil {
+		return nil
+	}
+	var errs []error
+	bq, builder := cq.BuilderQueries[c.QueryName]
+	_, clickhouse := cq.ClickHouseQueries[c.QueryName]
+	switch {
+	case !builder && !clickhouse:
+		errs = append(errs, errors.Errorf("confirmation query %s is not a query of the rule", c.QueryName))
+	case builder && isFormula(c.QueryName, bq):
+		errs = append(errs, errors.Errorf("confirmation query %s cannot be a formula", c.QueryName))
+	}
+	if c.QueryName == r.RuleCondition.SelectedQuery {
+		errs = append(errs, errors.Errorf("confirmation query %s cannot be the selected query", c.QueryName))
+	}
+	for name, q := range cq.BuilderQueries {
+		if isFormula(name, q) && formulaUses(q.Expression, c.QueryName) {
+			errs = append(errs, errors.Errorf("confirmation query %s cannot be used by the formula %s", c.QueryName, name))
+		}
+	}
+	if c.Target == nil {
+		errs = append(errs, errors.Errorf("confirmation query %s missing the threshold", c.QueryName))
+	}
+	if len(r.RuleCondition.QueryThresholds) > 0 {
+		errs = append(errs, errors.Errorf("confirmation cannot be combined with query thresholds"))
+	}
+	return errs
+}
+
+// validateQueryThresholds checks that the query thresholds compare known
+// queries, once each, with a target
+func (r *PostableRule) validateQueryThresholds() []error {
+	cq := r.RuleCondition.CompositeQuery
+	if cq == nil {
+		return nil
+	}
+	var errs []error
+	seen := map[string]bool{}
+	for _, t := range r.RuleCondition.QueryThresholds {
+		_, builder := cq.BuilderQueries[t.QueryName]
+		_, clickhouse := cq.ClickHouseQueries[t.QueryName]
+		if !builder && !clickhouse {
+			errs = append(errs, errors.Errorf("threshold of unknown query %s", t.QueryName))
+		}
+		if seen[t.QueryName] {
+			errs = append(errs, errors.Errorf("query %s has more than one threshold", t.QueryName))
+		}
+		seen[t.QueryName] = true
+		if t.Target == nil {
+			errs = append(errs, errors.Errorf("threshold of query %s is missing the target", t.QueryName))
+		}
+	}
+	return errs
+}
+
+// validateDownsampling checks that the data tier of the rule applies to
+// its queries, and that the eval window and the resolution are made of
+// whole aggregated intervals
+func (r *PostableRule) validateDownsampling() error {
+	tier := r.RuleCondition.Downsampling
+	if err := tier.Validate(); err != nil {
+		return err
+	}
+	if tier == v3.DownsamplingUnspecified || tier == v3.DownsamplingRaw {
+		return nil
+	}
+	metrics := false
+	if cq := r.RuleCondition.CompositeQuery; cq != nil && cq.QueryType == v3.QueryTypeBuilder {
+		for _, q := range cq.BuilderQueries {
+			metrics = metrics || q.DataSource == v3.DataSourceMetrics
+		}
+	}
+	if !metrics {
+		return errors.Errorf("the %s data tier only applies to the metrics builder queries", tier)
+	}
+
+	resolution := tier.Resolution()
+	if resolution == 0 {
+		return nil
+	}
+	if window := time.Duration(r.EvalWindow); window > 0 && (window < resolution || window%resolution != 0) {
+		return errors.Errorf("eval window %s must be a multiple of the %s data tier", window, tier)
+	}
+	if step := time.Duration(r.EvalResolution); step > 0 && step%resolution != 0 {
+		return errors.Errorf("eval resolution %s must be a multiple of the %s data tier", step, tier)
+	}
+	return nil
+}
+
+// validateEvalResolution checks that the resolution fits the eval window
+// and does not produce more points than a query is allowed to return
+func (r *PostableRule) validateEvalResolution() error {
+	if r.EvalResolution == 0 {
+		return nil
+	}
+	resolution := time.Duration(r.EvalResolution)
+	window := time.Duration(r.EvalWindow)
+	if resolution < minEvalResolution {
+		return errors.Errorf("eval resolution %s is below the minimum of %s", resolution, minEvalResolution)
+	}
+	if resolution%time.Second != 0 {
+		return errors.Errorf("eval resolution %s must be a whole number of seconds", resolution)
+	}
+	if window > 0 && resolution > window {
+		return errors.Errorf("eval resolution %s is larger than the eval window %s", resolution, window)
+	}
+	if points := int64(window / resolution); points > constants.MaxAllowedPointsInTimeSeries {
+		return errors.Errorf("eval window %s at resolution %s produces %d points, the maximum is %d", window, resolution, points, constants.MaxAllowedPointsInTimeSeries)
+	}
+	return nil
+}
+
 func testTemplateParsing(rl *PostableRule) (errs []error) {
 	if rl.AlertName == "" {
 		// Not an alerting rule.
@@ -219,8 +480,8 @@ func testTemplateParsing(rl *PostableRule) (errs []error) {
 	}
 
 	// Trying to parse templates.
-	tmplData := AlertTemplateData(make(map[string]string), "0", "0")
-	defs := "{{$labels := .Labels}}{{$value := .Value}}{{$threshold := .Threshold}}"
+	tmplData := AlertTemplateData(make(map[string]string), "0", "0", nil)
+	defs := "{{$labels := .Labels}}{{$value := .Value}}{{$threshold := .Threshold}}{{$values := .Values}}"
 	parseTest := func(text string) error {
 		tmpl := NewTemplateExpander(
 			context.TODO(),
@@ -266,4 +527,21 @@ type GettableRule struct {
 	CreatedBy *string    `json:"createBy"`
 	UpdatedAt *time.Time `json:"updateAt"`
 	UpdatedBy *string    `json:"updateBy"`
+
+	Health *RuleHealthStatus `json:"health,omitempty"`
+	// SandboxViolations are why the sandbox rejects the raw clickhouse
+	// queries of a rule stored before them, the rule keeps running and
+	// its queries are checked when it is edited next
+	SandboxViolations []string `json:"sandboxViolations,omitempty"`
+}
+
+// RuleHealthStatus is the evaluation health of an enabled rule
+type RuleHealthStatus struct {
+	Health               RuleHealth    `json:"health"`
+	LastError            string        `json:"lastError,omitempty"`
+	LastErrorCategory    ErrorCategory `json:"lastErrorCategory,omitempty"`
+	LastEvaluation       time.Time     `json:"lastEvaluation"`
+	EvaluationDurationMs int64         `json:"evaluationDurationMs"`
+	EvalStats
+	Breaker *BreakerStatus `json:"breaker,omitempty"`
 }
@@ -0,0 +1,509 @@
+package rules
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+// The actions a rule takes on the events of a calendar it references
+const (
+	// CalendarSuspend skips the evaluations of the rule during the events
+	CalendarSuspend = "suspend"
+	// CalendarRelax multiplies the thresholds of the rule by the factor of
+	// the reference during the events
+	CalendarRelax = "relax"
+)
+
+// CalendarEvent is a known day or window the traffic of an org shifts in,
+// like a sale or a batch run
+type CalendarEvent struct {
+	Name  string    `json:"name"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// CalendarEvents are stored as json in the events column
+type CalendarEvents []CalendarEvent
+
+func (e *CalendarEvents) Scan(src interface{}) error {
+	return scanJSON(src, e)
+}
+
+func (e CalendarEvents) Value() (driver.Value, error) {
+	return json.Marshal(e)
+}
+
+// Calendar is a named set of events of an org the rules can reference. The
+// calendars without an org are shared by all the orgs, a calendar of the
+// org with the same name takes precedence.
+type Calendar struct {
+	Id          int64          `json:"id" db:"id"`
+	OrgId       string         `json:"orgId" db:"org_id"`
+	Name        string         `json:"name" db:"name"`
+	Description string         `json:"description" db:"description"`
+	Events      CalendarEvents `json:"events" db:"events"`
+	CreatedAt   time.Time      `json:"createdAt" db:"created_at"`
+	CreatedBy   string         `json:"createdBy" db:"created_by"`
+	UpdatedAt   time.Time      `json:"updatedAt" db:"updated_at"`
+	UpdatedBy   string         `json:"updatedBy" db:"updated_by"`
+}
+
+func (c *Calendar) Validate() error {
+	if !snippetNameRe.MatchString(c.Name) {
+		return fmt.Errorf("invalid calendar name %q, it must start with a letter and contain only letters, digits, '_', '.' and '-'", c.Name)
+	}
+	if len(c.Events) == 0 {
+		return fmt.Errorf("calendar has no events")
+	}
+	for i, e := range c.Events {
+		if e.Start.IsZero() || e.End.IsZero() {
+			return fmt.Errorf("event %d must have a start and an end", i)
+		}
+		if !e.End.After(e.Start) {
+			return fmt.Errorf("event %d must end after its start", i)
+		}
+	}
+	return nil
+}
+
+// eventAt returns the event of the calendar ts is in, nil when there is none
+func (c *Calendar) eventAt(ts time.Time) *CalendarEvent {
+	for i := range c.Events {
+		if !ts.Before(c.Events[i].Start) && ts.Before(c.Events[i].End) {
+			return &c.Events[i]
+		}
+	}
+	return nil
+}
+
+// CalendarRef is a calendar a rule follows, by name
+type CalendarRef struct {
+	Calendar string `yaml:"calendar" json:"calendar"`
+	Action   string `yaml:"action" json:"action"`
+	// Factor multiplies the thresholds of the rule during the events of
+	// the calendar, for the relax action
+	Factor float64 `yaml:"factor,omitempty" json:"factor,omitempty"`
+}
+
+func (ref CalendarRef) Validate() error {
+	if ref.Calendar == "" {
+		return fmt.Errorf("calendar name is required")
+	}
+	switch ref.Action {
+	case CalendarSuspend:
+		if ref.Factor != 0 {
+			return fmt.Errorf("calendar %s: factor is only used with the %s action", ref.Calendar, CalendarRelax)
+		}
+	case CalendarRelax:
+		if ref.Factor <= 0 || math.IsInf(ref.Factor, 0) || math.IsNaN(ref.Factor) {
+			return fmt.Errorf("calendar %s: factor must be a positive number", ref.Calendar)
+		}
+	default:
+		return fmt.Errorf("calendar %s: action must be %s or %s", ref.Calendar, CalendarSuspend, CalendarRelax)
+	}
+	return nil
+}
+
+func (r *PostableRule) validateCalendars() []error {
+	var errs []error
+	seen := make(map[string]bool, len(r.Calendars))
+	for _, ref := range r.Calendars {
+		if err := ref.Validate(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if seen[ref.Calendar] {
+			errs = append(errs, fmt.Errorf("calendar %s is referenced more than once", ref.Calendar))
+		}
+		seen[ref.Calendar] = true
+	}
+	return errs
+}
+
+// calendarRule is implemented by the rules that follow calendars
+type calendarRule interface {
+	Calendars() []CalendarRef
+}
+
+// CalendarCache holds the calendars of the orgs for the evaluations. The
+// map is replaced as a whole on every update so readers can use it
+// without copying.
+type CalendarCache struct {
+	mtx sync.RWMutex
+	// orgs are the calendars of every org by name
+	orgs map[string]map[string]*Calendar
+	// orgOf returns the org of a rule
+	orgOf func(ruleID string) string
+}
+
+func NewCalendarCache() *CalendarCache {
+	return &CalendarCache{orgs: map[string]map[string]*Calendar{}}
+}
+
+func (c *CalendarCache) set(calendars []Calendar) {
+	orgs := map[string]map[string]*Calendar{}
+	for i := range calendars {
+		cal := &calendars[i]
+		if orgs[cal.OrgId] == nil {
+			orgs[cal.OrgId] = map[string]*Calendar{}
+		}
+		orgs[cal.OrgId][cal.Name] = cal
+	}
+	c.mtx.Lock()
+	c.orgs = orgs
+	c.mtx.Unlock()
+}
+
+// lookup returns the calendar of the org with the name, or the shared one
+func (c *CalendarCache) lookup(org, name string) *Calendar {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	if cal, ok := c.orgs[org][name]; ok {
+		return cal
+	}
+	return c.orgs[""][name]
+}
+
+// apply returns whether the events of the calendars of the rule suspend it
+// at ts and the factor its thresholds are multiplied by. The factors of
+// the calendars relaxing the rule at the same time are multiplied. The
+// references to unknown calendars are ignored.
+func (c *CalendarCache) apply(ruleID string, refs []CalendarRef, ts time.Time) (bool, float64) {
+	factor := 1.0
+	if c == nil || len(refs) == 0 {
+		return false, factor
+	}
+	org := ""
+	if c.orgOf != nil {
+		org = c.orgOf(ruleID)
+	}
+	for _, ref := range refs {
+		cal := c.lookup(org, ref.Calendar)
+		if cal == nil || cal.eventAt(ts) == nil {
+			continue
+		}
+		switch ref.Action {
+		case CalendarSuspend:
+			return true, 1
+		case CalendarRelax:
+			factor *= ref.Factor
+		}
+	}
+	return false, factor
+}
+
+// suspends tells whether the rule is suspended by a calendar at ts
+func (c *CalendarCache) suspends(ruleID string, refs []CalendarRef, ts time.Time) bool {
+	suspended, _ := c.apply(ruleID, refs, ts)
+	return suspended
+}
+
+// factor returns the factor of the thresholds of the rule at ts
+func (c *CalendarCache) factor(ruleID string, refs []CalendarRef, ts time.Time) float64 {
+	_, factor := c.apply(ruleID, refs, ts)
+	return factor
+}
+
+// calendarFactor is the factor of the thresholds of a rule set at the
+// start of its evaluation, it is read without the lock of the rule
+type calendarFactor struct {
+	bits atomic.Uint64
+}
+
+func (f *calendarFactor) set(factor float64) {
+	f.bits.Store(math.Float64bits(factor))
+}
+
+func (f *calendarFactor) get() float64 {
+	bits := f.bits.Load()
+	if bits == 0 {
+		return 1
+	}
+	return math.Float64frombits(bits)
+}
+
+// calendarSuspended tells whether the calendars of the rule suspend its
+// evaluation at ts
+func calendarSuspended(opts *ManagerOptions, rule Rule, ts time.Time) bool {
+	cr, ok := rule.(calendarRule)
+	if !ok {
+		return false
+	}
+	return opts.Calendars.suspends(rule.ID(), cr.Calendars(), ts)
+}
+
+// ParseICal reads the events of an iCalendar file. The all-day events and
+// the times without a timezone are in loc. Only the first occurrence of
+// the recurring events is read, the yearly days should be listed for
+// every year.
+func ParseICal(data []byte, loc *time.Location) ([]CalendarEvent, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	// unfold the lines continued with a leading space or tab
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	events := []CalendarEvent{}
+	var event *CalendarEvent
+	allDay := false
+	for i, line := range lines {
+		name, params, value, ok := icalProperty(line)
+		if !ok {
+			continue
+		}
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			event, allDay = &CalendarEvent{}, false
+		case name == "END" && value == "VEVENT":
+			if event == nil {
+				continue
+			}
+			if event.End.IsZero() && allDay {
+				event.End = event.Start.AddDate(0, 0, 1)
+			}
+			// the events without an end or a start are not windows the
+			// rules can follow
+			if !event.Start.IsZero() && event.End.After(event.Start) {
+				events = append(events, *event)
+			}
+			event = nil
+		case event == nil:
+		case name == "SUMMARY":
+			event.Name = icalUnescape(value)
+		case name == "DTSTART", name == "DTEND":
+			t, date, err := icalTime(params, value, loc)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			if name == "DTSTART" {
+				event.Start, allDay = t, date
+			} else {
+				event.End = t
+			}
+		}
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("the calendar has no events")
+	}
+	return events, nil
+}
+
+// icalProperty splits a content line into its name, its params and its
+// value
+func icalProperty(line string) (string, map[string]string, string, bool) {
+	i := strings.IndexByte(line, ':')
+	if i <= 0 {
+		return "", nil, "", false
+	}
+	head, value := line[:i], line[i+1:]
+	parts := strings.Split(head, ";")
+	params := make(map[string]string, len(parts)-1)
+	for _, p := range parts[1:] {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, value, true
+}
+
+// icalTime parses a DATE or a DATE-TIME value, it tells whether the value
+// is a date
+func icalTime(params map[string]string, value string, loc *time.Location) (time.Time, bool, error) {
+	if tzid, ok := params["TZID"]; ok {
+		l, err := time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("unknown timezone %s", tzid)
+		}
+		loc = l
+	}
+	if params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+var icalEscapes = strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+
+func icalUnescape(value string) string {
+	return icalEscapes.Replace(value)
+}
+
+// ReloadCalendars makes the rules follow the stored calendars
+func (m *Manager) ReloadCalendars(ctx context.Context) error {
+	// the rules of every org are evaluated with the calendars of their org
+	calendars, err := m.ruleDB.GetCalendars(context.Background())
+	if err != nil {
+		return err
+	}
+	m.opts.Calendars.set(calendars)
+	return nil
+}
+
+// ListCalendars returns the calendars visible to the org of the request
+func (m *Manager) ListCalendars(ctx context.Context) ([]Calendar, *model.ApiError) {
+	calendars, err := m.ruleDB.GetCalendars(ctx)
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	return calendars, nil
+}
+
+// checkCalendarName denies a name another calendar of the org has, the
+// org may reuse the name of a shared calendar to replace it
+func (m *Manager) checkCalendarName(ctx context.Context, name string, id int64) *model.ApiError {
+	calendars, err := m.ruleDB.GetCalendars(ctx)
+	if err != nil {
+		return newApiErrorInternal(err)
+	}
+	org := tenantOf(ctx)
+	for _, c := range calendars {
+		if c.Name == name && c.OrgId == org && c.Id != id {
+			return &model.ApiError{Typ: model.ErrorConflict, Err: fmt.Errorf("calendar %s already exists", name)}
+		}
+	}
+	return nil
+}
+
+// CreateCalendar stores the calendar for the org of the request
+func (m *Manager) CreateCalendar(ctx context.Context, cal *Calendar) *model.ApiError {
+	if err := cal.Validate(); err != nil {
+		return newApiErrorBadData(err)
+	}
+	if apiErr := m.checkCalendarName(ctx, cal.Name, 0); apiErr != nil {
+		return apiErr
+	}
+	id, err := m.ruleDB.CreateCalendar(ctx, *cal)
+	if err != nil {
+		return newApiErrorInternal(err)
+	}
+	cal.Id, cal.OrgId = id, tenantOf(ctx)
+	if err := m.ReloadCalendars(ctx); err != nil {
+		return newApiErrorInternal(err)
+	}
+	return nil
+}
+
+// EditCalendar replaces the calendar with the id
+func (m *Manager) EditCalendar(ctx context.Context, cal *Calendar, id string) *model.ApiError {
+	if err := cal.Validate(); err != nil {
+		return newApiErrorBadData(err)
+	}
+	calID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return newApiErrorBadData(fmt.Errorf("invalid calendar id %s", id))
+	}
+	if apiErr := m.checkCalendarName(ctx, cal.Name, calID); apiErr != nil {
+		return apiErr
+	}
+	if err := m.ruleDB.EditCalendar(ctx, *cal, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("calendar %s not found", id)}
+		}
+		return newApiErrorInternal(err)
+	}
+	if err := m.ReloadCalendars(ctx); err != nil {
+		return newApiErrorInternal(err)
+	}
+	return nil
+}
+
+// DeleteCalendar deletes the calendar with the id, the rules referencing
+// it are evaluated as usual again
+func (m *Manager) DeleteCalendar(ctx context.Context, id string) *model.ApiError {
+	if err := m.ruleDB.DeleteCalendar(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("calendar %s not found", id)}
+		}
+		return newApiErrorInternal(err)
+	}
+	if err := m.ReloadCalendars(ctx); err != nil {
+		return newApiErrorInternal(err)
+	}
+	return nil
+}
+
+// CalendarImportRequest imports the events of an iCalendar file into the
+// calendar with the name, replacing the events of the calendar of the org
+// when it exists
+type CalendarImportRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Timezone is the IANA timezone of the all-day events and the times
+	// without a timezone, UTC by default
+	Timezone string `json:"timezone"`
+	// Content is the iCalendar file
+	Content string `json:"content"`
+}
+
+// ImportCalendar creates or replaces the calendar of the org with the
+// events of the iCalendar file
+func (m *Manager) ImportCalendar(ctx context.Context, req *CalendarImportRequest) (*Calendar, *model.ApiError) {
+	loc := time.UTC
+	if req.Timezone != "" {
+		l, err := time.LoadLocation(req.Timezone)
+		if err != nil {
+			return nil, newApiErrorBadData(fmt.Errorf("unknown timezone %s", req.Timezone))
+		}
+		loc = l
+	}
+	events, err := ParseICal([]byte(req.Content), loc)
+	if err != nil {
+		return nil, newApiErrorBadData(fmt.Errorf("invalid calendar file: %w", err))
+	}
+	cal := &Calendar{Name: req.Name, Description: req.Description, Events: events}
+
+	calendars, err := m.ruleDB.GetCalendars(ctx)
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	org := tenantOf(ctx)
+	for _, c := range calendars {
+		if c.Name == req.Name && c.OrgId == org {
+			if cal.Description == "" {
+				cal.Description = c.Description
+			}
+			if apiErr := m.EditCalendar(ctx, cal, strconv.FormatInt(c.Id, 10)); apiErr != nil {
+				return nil, apiErr
+			}
+			cal.Id, cal.OrgId = c.Id, c.OrgId
+			return cal, nil
+		}
+	}
+	if apiErr := m.CreateCalendar(ctx, cal); apiErr != nil {
+		return nil, apiErr
+	}
+	return cal, nil
+}
@@ -0,0 +1,135 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func TestParseICal(t *testing.T) {
+	content := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"SUMMARY:Black Friday\\, US\r\n" +
+		"DTSTART;VALUE=DATE:20261127\r\n" +
+		"DTEND;VALUE=DATE:20261128\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"SUMMARY:Year end\r\n" +
+		"  batch\r\n" +
+		"DTSTART:20261231T220000Z\r\n" +
+		"DTEND:20270101T060000Z\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"SUMMARY:Payroll\r\n" +
+		"DTSTART;TZID=Asia/Kolkata:20261030T090000\r\n" +
+		"DTEND;TZID=Asia/Kolkata:20261030T120000\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"SUMMARY:Holiday\r\n" +
+		"DTSTART;VALUE=DATE:20261225\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"SUMMARY:No end\r\n" +
+		"DTSTART:20261225T100000Z\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	require.NoError(t, err)
+
+	events, err := ParseICal([]byte(content), berlin)
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+
+	assert.Equal(t, "Black Friday, US", events[0].Name)
+	assert.True(t, events[0].Start.Equal(time.Date(2026, 11, 27, 0, 0, 0, 0, berlin)))
+	assert.True(t, events[0].End.Equal(time.Date(2026, 11, 28, 0, 0, 0, 0, berlin)))
+
+	assert.Equal(t, "Year end batch", events[1].Name)
+	assert.True(t, events[1].Start.Equal(time.Date(2026, 12, 31, 22, 0, 0, 0, time.UTC)))
+
+	assert.True(t, events[2].Start.Equal(time.Date(2026, 10, 30, 9, 0, 0, 0, kolkata)))
+
+	// the all-day events without an end last the day
+	assert.True(t, events[3].End.Equal(time.Date(2026, 12, 26, 0, 0, 0, 0, berlin)))
+
+	_, err = ParseICal([]byte("BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n"), nil)
+	assert.Error(t, err)
+}
+
+func TestCalendarRefValidate(t *testing.T) {
+	assert.NoError(t, CalendarRef{Calendar: "sales", Action: CalendarSuspend}.Validate())
+	assert.NoError(t, CalendarRef{Calendar: "sales", Action: CalendarRelax, Factor: 2}.Validate())
+	assert.Error(t, CalendarRef{Calendar: "sales", Action: CalendarRelax}.Validate())
+	assert.Error(t, CalendarRef{Calendar: "sales", Action: CalendarSuspend, Factor: 2}.Validate())
+	assert.Error(t, CalendarRef{Calendar: "sales", Action: "pause"}.Validate())
+	assert.Error(t, CalendarRef{Action: CalendarSuspend}.Validate())
+
+	rule := &PostableRule{Calendars: []CalendarRef{
+		{Calendar: "sales", Action: CalendarSuspend},
+		{Calendar: "sales", Action: CalendarRelax, Factor: 2},
+	}}
+	assert.Len(t, rule.validateCalendars(), 1)
+}
+
+func TestCalendarCache(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 11, d, 0, 0, 0, 0, time.UTC) }
+
+	var nilCache *CalendarCache
+	assert.False(t, nilCache.suspends("1", []CalendarRef{{Calendar: "sales", Action: CalendarSuspend}}, day(27)))
+	assert.Equal(t, 1.0, nilCache.factor("1", nil, day(27)))
+
+	c := NewCalendarCache()
+	c.orgOf = func(ruleID string) string {
+		if ruleID == "1" {
+			return "acme"
+		}
+		return "other"
+	}
+	c.set([]Calendar{
+		{Name: "sales", Events: CalendarEvents{{Name: "Black Friday", Start: day(27), End: day(28)}}},
+		{OrgId: "acme", Name: "sales", Events: CalendarEvents{{Name: "Cyber Monday", Start: day(30), End: day(30).Add(24 * time.Hour)}}},
+		{OrgId: "acme", Name: "batch", Events: CalendarEvents{{Name: "Batch", Start: day(27), End: day(28)}}},
+	})
+
+	relax := []CalendarRef{{Calendar: "sales", Action: CalendarRelax, Factor: 2}}
+	// the calendar of the org replaces the shared one of the same name
+	assert.Equal(t, 1.0, c.factor("1", relax, day(27)))
+	assert.Equal(t, 2.0, c.factor("1", relax, day(30)))
+	assert.Equal(t, 2.0, c.factor("2", relax, day(27)))
+	assert.Equal(t, 1.0, c.factor("2", relax, day(30)))
+
+	// the factors of the calendars with events at the same time multiply
+	both := []CalendarRef{
+		{Calendar: "sales", Action: CalendarRelax, Factor: 2},
+		{Calendar: "batch", Action: CalendarRelax, Factor: 1.5},
+	}
+	assert.Equal(t, 1.5, c.factor("1", both, day(27)))
+
+	suspend := []CalendarRef{{Calendar: "batch", Action: CalendarSuspend}, {Calendar: "missing", Action: CalendarSuspend}}
+	assert.True(t, c.suspends("1", suspend, day(27)))
+	assert.False(t, c.suspends("1", suspend, day(28)))
+	// the other orgs do not see the calendar of the org
+	assert.False(t, c.suspends("2", suspend, day(27)))
+}
+
+func TestThresholdRuleCalendarFactor(t *testing.T) {
+	target := 100.0
+	r := &ThresholdRule{ruleCondition: &RuleCondition{
+		Target: &target,
+		CompositeQuery: &v3.CompositeQuery{
+			QueryType:      v3.QueryTypeBuilder,
+			BuilderQueries: map[string]*v3.BuilderQuery{"A": {QueryName: "A", Expression: "A"}},
+		},
+	}}
+	assert.Equal(t, 100.0, r.targetVal())
+
+	r.calendarFactor.set(2)
+	assert.Equal(t, 200.0, r.targetVal())
+}
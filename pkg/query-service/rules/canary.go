@@ -0,0 +1,368 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// The directions of the change of a metric that are regressions
+const (
+	CanaryIncrease = "increase"
+	CanaryDecrease = "decrease"
+	CanaryEither   = "either"
+)
+
+// The verdicts of a canary analysis
+const (
+	CanaryPass = "pass"
+	CanaryFail = "fail"
+	// CanaryInconclusive is the verdict when a cohort has too few points
+	// for a metric and no other metric failed
+	CanaryInconclusive = "inconclusive"
+)
+
+const (
+	DefaultCanaryConfidence = 0.95
+	DefaultCanaryMinPoints  = 5
+
+	// CanaryMetricLabel is the label of the alerts of a canary rule, it
+	// holds the name of the query of the regressed metric
+	CanaryMetricLabel = "canaryMetric"
+)
+
+// CanaryAnalysis compares the series of a canary cohort with the series of
+// a baseline cohort on the queries of a threshold rule over its eval
+// window. The cohorts are the series whose labels match the label values
+// of the cohort, e.g. a version label grouped by in the queries. A metric
+// regresses when the Mann-Whitney U test finds the cohorts differ with the
+// confidence and the median of the canary moved past the tolerance in the
+// direction of the metric. The rule alerts for every regressed metric.
+type CanaryAnalysis struct {
+	Canary   map[string]string `yaml:"canary" json:"canary"`
+	Baseline map[string]string `yaml:"baseline" json:"baseline"`
+	Metrics  []CanaryMetric    `yaml:"metrics" json:"metrics"`
+	// Confidence of the test, DefaultCanaryConfidence when not set
+	Confidence float64 `yaml:"confidence,omitempty" json:"confidence,omitempty"`
+	// MinPoints is the number of points each cohort needs for a metric to
+	// be compared, DefaultCanaryMinPoints when not set
+	MinPoints int `yaml:"minPoints,omitempty" json:"minPoints,omitempty"`
+}
+
+// CanaryMetric is a query of the rule compared between the cohorts
+type CanaryMetric struct {
+	QueryName string `yaml:"queryName" json:"queryName"`
+	// Direction is the change of the metric that is a regression, e.g. an
+	// increase of the latency or a decrease of the throughput
+	Direction string `yaml:"direction" json:"direction"`
+	// Tolerance is the relative change of the median allowed, 0.1 allows
+	// the canary to be 10% off the baseline
+	Tolerance float64 `yaml:"tolerance,omitempty" json:"tolerance,omitempty"`
+}
+
+func (c *CanaryAnalysis) confidence() float64 {
+	if c.Confidence == 0 {
+		return DefaultCanaryConfidence
+	}
+	return c.Confidence
+}
+
+func (c *CanaryAnalysis) minPoints() int {
+	if c.MinPoints == 0 {
+		return DefaultCanaryMinPoints
+	}
+	return c.MinPoints
+}
+
+func (r *PostableRule) validateCanary() []error {
+	c := r.RuleCondition.Canary
+	if c == nil {
+		return nil
+	}
+	var errs []error
+	if r.RuleCondition.StreamResults {
+		errs = append(errs, fmt.Errorf("canary analysis compares the queries of the cohorts and can not stream the results"))
+	}
+	if len(c.Canary) == 0 || len(c.Baseline) == 0 {
+		errs = append(errs, fmt.Errorf("canary analysis needs the labels of the canary and of the baseline"))
+	} else if hasLabels(c.Canary, c.Baseline) && hasLabels(c.Baseline, c.Canary) {
+		errs = append(errs, fmt.Errorf("canary and baseline select the same series"))
+	}
+	if len(c.Metrics) == 0 {
+		errs = append(errs, fmt.Errorf("canary analysis needs at least one metric"))
+	}
+	if c.Confidence != 0 && (c.Confidence < 0.5 || c.Confidence >= 1) {
+		errs = append(errs, fmt.Errorf("canary confidence must be between 0.5 and 1"))
+	}
+	if c.MinPoints < 0 {
+		errs = append(errs, fmt.Errorf("canary min points must not be negative"))
+	}
+	cq := r.RuleCondition.CompositeQuery
+	seen := map[string]bool{}
+	for _, m := range c.Metrics {
+		if cq != nil {
+			_, builder := cq.BuilderQueries[m.QueryName]
+			_, clickhouse := cq.ClickHouseQueries[m.QueryName]
+			if !builder && !clickhouse {
+				errs = append(errs, fmt.Errorf("canary metric of unknown query %s", m.QueryName))
+			}
+		}
+		if seen[m.QueryName] {
+			errs = append(errs, fmt.Errorf("query %s is compared more than once", m.QueryName))
+		}
+		seen[m.QueryName] = true
+		switch m.Direction {
+		case CanaryIncrease, CanaryDecrease, CanaryEither:
+		default:
+			errs = append(errs, fmt.Errorf("direction of canary metric %s must be %s, %s or %s", m.QueryName, CanaryIncrease, CanaryDecrease, CanaryEither))
+		}
+		if m.Tolerance < 0 {
+			errs = append(errs, fmt.Errorf("tolerance of canary metric %s must not be negative", m.QueryName))
+		}
+	}
+	return errs
+}
+
+// CanaryMetricResult is the comparison of a metric between the cohorts
+type CanaryMetricResult struct {
+	QueryName      string  `json:"queryName"`
+	Verdict        string  `json:"verdict"`
+	CanaryPoints   int     `json:"canaryPoints"`
+	BaselinePoints int     `json:"baselinePoints"`
+	CanaryMedian   float64 `json:"canaryMedian"`
+	BaselineMedian float64 `json:"baselineMedian"`
+	// Change is the relative change of the median of the canary
+	Change float64 `json:"change"`
+	PValue float64 `json:"pValue"`
+}
+
+// CanaryVerdict is the outcome of the last evaluation of a canary rule,
+// the deploy pipelines promote or roll back the canary with it
+type CanaryVerdict struct {
+	RuleID      string               `json:"ruleId"`
+	Verdict     string               `json:"verdict"`
+	EvaluatedAt time.Time            `json:"evaluatedAt"`
+	Metrics     []CanaryMetricResult `json:"metrics"`
+}
+
+// compareCohorts tests whether the canary values regressed from the
+// baseline values
+func compareCohorts(m CanaryMetric, canary, baseline []float64, confidence float64, minPoints int) CanaryMetricResult {
+	res := CanaryMetricResult{QueryName: m.QueryName, CanaryPoints: len(canary), BaselinePoints: len(baseline), PValue: 1}
+	if len(canary) < minPoints || len(baseline) < minPoints {
+		res.Verdict = CanaryInconclusive
+		return res
+	}
+	res.CanaryMedian, res.BaselineMedian = median(canary), median(baseline)
+	switch {
+	case res.BaselineMedian != 0:
+		res.Change = (res.CanaryMedian - res.BaselineMedian) / math.Abs(res.BaselineMedian)
+	case res.CanaryMedian > 0:
+		res.Change = 1
+	case res.CanaryMedian < 0:
+		res.Change = -1
+	}
+
+	z := mannWhitneyZ(canary, baseline)
+	regressed := false
+	switch m.Direction {
+	case CanaryIncrease:
+		res.PValue = 0.5 * math.Erfc(z/math.Sqrt2)
+		regressed = res.Change > m.Tolerance
+	case CanaryDecrease:
+		res.PValue = 0.5 * math.Erfc(-z/math.Sqrt2)
+		regressed = res.Change < -m.Tolerance
+	default:
+		res.PValue = math.Erfc(math.Abs(z) / math.Sqrt2)
+		regressed = math.Abs(res.Change) > m.Tolerance
+	}
+	res.Verdict = CanaryPass
+	if regressed && res.PValue < 1-confidence {
+		res.Verdict = CanaryFail
+	}
+	return res
+}
+
+// mannWhitneyZ returns the z score of the U statistic of the first sample
+// with the normal approximation, corrected for the ties. It is positive
+// when the values of the first sample tend to be larger.
+func mannWhitneyZ(a, b []float64) float64 {
+	type ranked struct {
+		v     float64
+		first bool
+	}
+	all := make([]ranked, 0, len(a)+len(b))
+	for _, v := range a {
+		all = append(all, ranked{v, true})
+	}
+	for _, v := range b {
+		all = append(all, ranked{v, false})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].v < all[j].v })
+
+	n1, n2 := float64(len(a)), float64(len(b))
+	n := n1 + n2
+	var rankSum, ties float64
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].v == all[i].v {
+			j++
+		}
+		// the tied values share the average of their ranks
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if all[k].first {
+				rankSum += rank
+			}
+		}
+		t := float64(j - i)
+		ties += t*t*t - t
+		i = j
+	}
+	u := rankSum - n1*(n1+1)/2
+	mean := n1 * n2 / 2
+	sigma := math.Sqrt(n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1))))
+	if sigma == 0 {
+		return 0
+	}
+	// continuity correction towards the mean
+	d := u - mean
+	switch {
+	case d > 0.5:
+		d -= 0.5
+	case d < -0.5:
+		d += 0.5
+	default:
+		d = 0
+	}
+	return d / sigma
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return quantile(sorted, 0.5)
+}
+
+// cohortValues returns the points of the series of the result matching
+// the labels of the cohort
+func cohortValues(res *v3.Result, cohort map[string]string) []float64 {
+	var values []float64
+	if res == nil {
+		return values
+	}
+	for _, series := range res.Series {
+		if !hasLabels(cohort, series.Labels) {
+			continue
+		}
+		for _, p := range removeGroupinSetPoints(*series) {
+			values = append(values, p.Value)
+		}
+	}
+	return values
+}
+
+// analyzeCanary compares the cohorts on every metric of the analysis
+func analyzeCanary(c *CanaryAnalysis, results []*v3.Result) []CanaryMetricResult {
+	byName := make(map[string]*v3.Result, len(results))
+	for _, res := range results {
+		byName[res.QueryName] = res
+	}
+	metrics := make([]CanaryMetricResult, 0, len(c.Metrics))
+	for _, m := range c.Metrics {
+		res := byName[m.QueryName]
+		metrics = append(metrics, compareCohorts(m, cohortValues(res, c.Canary), cohortValues(res, c.Baseline), c.confidence(), c.minPoints()))
+	}
+	return metrics
+}
+
+// canaryVerdict fails when a metric failed, and is inconclusive when a
+// metric could not be compared
+func canaryVerdict(metrics []CanaryMetricResult) string {
+	verdict := CanaryPass
+	for _, m := range metrics {
+		switch m.Verdict {
+		case CanaryFail:
+			return CanaryFail
+		case CanaryInconclusive:
+			verdict = CanaryInconclusive
+		}
+	}
+	return verdict
+}
+
+// canaryVector runs the canary analysis of the rule and returns a sample
+// for every regressed metric, the value of the sample is the change of
+// the median in percent
+func (r *ThresholdRule) canaryVector(results []*v3.Result, ts time.Time) Vector {
+	c := r.ruleCondition.Canary
+	metrics := analyzeCanary(c, results)
+
+	points := 0
+	for _, m := range metrics {
+		points += m.CanaryPoints + m.BaselinePoints
+	}
+	if points > 0 {
+		r.lastTimestampWithDatapoints = time.Now()
+	}
+
+	r.mtx.Lock()
+	r.canaryVerdict = &CanaryVerdict{RuleID: r.ID(), Verdict: canaryVerdict(metrics), EvaluatedAt: ts, Metrics: metrics}
+	r.mtx.Unlock()
+
+	var vector Vector
+	for i, m := range metrics {
+		if m.Verdict != CanaryFail {
+			continue
+		}
+		lbls := labels.NewBuilder(labels.FromMap(c.Canary)).Set(CanaryMetricLabel, m.QueryName).Labels()
+		tolerance := c.Metrics[i].Tolerance * 100
+		vector = append(vector, Sample{
+			Point:      Point{T: ts.UnixMilli(), V: m.Change * 100},
+			Metric:     lbls,
+			MetricOrig: lbls,
+			QueryValues: map[string]float64{
+				"canary":   m.CanaryMedian,
+				"baseline": m.BaselineMedian,
+				"pValue":   m.PValue,
+			},
+			Threshold: &tolerance,
+			Unit:      "percent",
+		})
+	}
+	return vector
+}
+
+// CanaryVerdict returns the verdict of the last evaluation of the canary
+// rule, nil before its first evaluation
+func (r *ThresholdRule) CanaryVerdict() *CanaryVerdict {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.canaryVerdict
+}
+
+// CanaryVerdict returns the verdict of the last evaluation of the canary
+// rule with the id
+func (m *Manager) CanaryVerdict(ctx context.Context, id string) (*CanaryVerdict, *model.ApiError) {
+	m.mtx.RLock()
+	rule, ok := m.rules[id]
+	m.mtx.RUnlock()
+	if !ok {
+		return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("rule %s is not running", id)}
+	}
+	tr, ok := rule.(*ThresholdRule)
+	if !ok || tr.Condition() == nil || tr.Condition().Canary == nil {
+		return nil, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("rule %s is not a canary analysis", id)}
+	}
+	verdict := tr.CanaryVerdict()
+	if verdict == nil {
+		return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("rule %s was not evaluated yet", id)}
+	}
+	return verdict, nil
+}
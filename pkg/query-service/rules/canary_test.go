@@ -0,0 +1,106 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func cohortSeries(version string, values ...float64) *v3.Series {
+	s := &v3.Series{Labels: map[string]string{"version": version}}
+	for i, v := range values {
+		s.Points = append(s.Points, v3.Point{Timestamp: int64(i) * 60000, Value: v})
+	}
+	return s
+}
+
+func TestMannWhitneyZ(t *testing.T) {
+	low := []float64{1, 2, 3, 4, 5, 6, 7, 8}
+	high := []float64{11, 12, 13, 14, 15, 16, 17, 18}
+	assert.Greater(t, mannWhitneyZ(high, low), 3.0)
+	assert.Less(t, mannWhitneyZ(low, high), -3.0)
+	assert.Equal(t, 0.0, mannWhitneyZ(low, low))
+	// all the values tied
+	assert.Equal(t, 0.0, mannWhitneyZ([]float64{1, 1}, []float64{1, 1}))
+}
+
+func TestCompareCohorts(t *testing.T) {
+	baseline := []float64{100, 102, 98, 101, 99, 100, 103, 97}
+	slower := []float64{130, 128, 135, 131, 129, 133, 127, 132}
+
+	res := compareCohorts(CanaryMetric{QueryName: "A", Direction: CanaryIncrease, Tolerance: 0.1}, slower, baseline, DefaultCanaryConfidence, DefaultCanaryMinPoints)
+	assert.Equal(t, CanaryFail, res.Verdict)
+	assert.InDelta(t, 0.305, res.Change, 0.001)
+	assert.Less(t, res.PValue, 0.01)
+
+	// the change is within the tolerance
+	res = compareCohorts(CanaryMetric{QueryName: "A", Direction: CanaryIncrease, Tolerance: 0.5}, slower, baseline, DefaultCanaryConfidence, DefaultCanaryMinPoints)
+	assert.Equal(t, CanaryPass, res.Verdict)
+
+	// a slower canary does not regress a metric that regresses by decreasing
+	res = compareCohorts(CanaryMetric{QueryName: "A", Direction: CanaryDecrease}, slower, baseline, DefaultCanaryConfidence, DefaultCanaryMinPoints)
+	assert.Equal(t, CanaryPass, res.Verdict)
+
+	res = compareCohorts(CanaryMetric{QueryName: "A", Direction: CanaryEither}, baseline, slower, DefaultCanaryConfidence, DefaultCanaryMinPoints)
+	assert.Equal(t, CanaryFail, res.Verdict)
+
+	res = compareCohorts(CanaryMetric{QueryName: "A", Direction: CanaryIncrease}, slower[:3], baseline, DefaultCanaryConfidence, DefaultCanaryMinPoints)
+	assert.Equal(t, CanaryInconclusive, res.Verdict)
+}
+
+func TestAnalyzeCanary(t *testing.T) {
+	c := &CanaryAnalysis{
+		Canary:   map[string]string{"version": "v2"},
+		Baseline: map[string]string{"version": "v1"},
+		Metrics: []CanaryMetric{
+			{QueryName: "latency", Direction: CanaryIncrease, Tolerance: 0.1},
+			{QueryName: "errors", Direction: CanaryIncrease},
+		},
+	}
+	results := []*v3.Result{
+		{QueryName: "latency", Series: []*v3.Series{
+			cohortSeries("v1", 100, 102, 98, 101, 99, 100),
+			cohortSeries("v2", 150, 148, 155, 151, 149, 153),
+		}},
+		{QueryName: "errors", Series: []*v3.Series{
+			cohortSeries("v1", 1, 2, 1, 2, 1, 2),
+			cohortSeries("v2", 2, 1, 2, 1, 2, 1),
+		}},
+	}
+	metrics := analyzeCanary(c, results)
+	require.Len(t, metrics, 2)
+	assert.Equal(t, CanaryFail, metrics[0].Verdict)
+	assert.Equal(t, 6, metrics[0].CanaryPoints)
+	assert.Equal(t, CanaryPass, metrics[1].Verdict)
+	assert.Equal(t, CanaryFail, canaryVerdict(metrics))
+
+	assert.Equal(t, CanaryInconclusive, canaryVerdict([]CanaryMetricResult{{Verdict: CanaryPass}, {Verdict: CanaryInconclusive}}))
+	assert.Equal(t, CanaryPass, canaryVerdict([]CanaryMetricResult{{Verdict: CanaryPass}}))
+}
+
+func TestValidateCanary(t *testing.T) {
+	rule := func(c *CanaryAnalysis) *PostableRule {
+		return &PostableRule{RuleCondition: &RuleCondition{
+			CompositeQuery: &v3.CompositeQuery{BuilderQueries: map[string]*v3.BuilderQuery{"A": {QueryName: "A"}}},
+			Canary:         c,
+		}}
+	}
+	valid := &CanaryAnalysis{
+		Canary:   map[string]string{"version": "v2"},
+		Baseline: map[string]string{"version": "v1"},
+		Metrics:  []CanaryMetric{{QueryName: "A", Direction: CanaryIncrease}},
+	}
+	assert.Empty(t, rule(valid).validateCanary())
+
+	assert.Len(t, rule(&CanaryAnalysis{
+		Canary:   map[string]string{"version": "v1"},
+		Baseline: map[string]string{"version": "v1"},
+		Metrics:  []CanaryMetric{{QueryName: "B", Direction: "up"}},
+	}).validateCanary(), 3)
+
+	streamed := rule(valid)
+	streamed.RuleCondition.StreamResults = true
+	assert.Len(t, streamed.validateCanary(), 1)
+}
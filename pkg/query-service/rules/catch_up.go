@@ -0,0 +1,110 @@
+package rules
+
+import (
+	"sync"
+	"time"
+)
+
+// maxCatchUpEvaluations bounds the evaluations replayed at once however
+// long the lookback is compared to the frequency of the rule
+const maxCatchUpEvaluations = 60
+
+// EvalStats counts the evaluations of a task that did not run at their
+// scheduled time and the ones that were re-evaluated later
+type EvalStats struct {
+	MissedEvaluations    int64     `json:"missedEvaluations"`
+	CaughtUpEvaluations  int64     `json:"caughtUpEvaluations"`
+	LastMissedEvaluation time.Time `json:"lastMissedEvaluation"`
+}
+
+// evalTracker records the evaluation stats of a task and holds the
+// circuit breakers of its rules
+type evalTracker struct {
+	mtx      sync.Mutex
+	stats    EvalStats
+	breakers map[string]*circuitBreaker
+	// muted is the rules in a planned maintenance
+	muted map[string]bool
+}
+
+// breaker returns the circuit breaker of the rule
+func (t *evalTracker) breaker(ruleID string, opts *ManagerOptions) *circuitBreaker {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.breakers == nil {
+		t.breakers = map[string]*circuitBreaker{}
+	}
+	b, ok := t.breakers[ruleID]
+	if !ok {
+		b = newCircuitBreaker(opts.BreakerThreshold, opts.BreakerMaxBackoff)
+		t.breakers[ruleID] = b
+	}
+	return b
+}
+
+// BreakerStatus returns the circuit breaker state of the rule
+func (t *evalTracker) BreakerStatus(ruleID string) BreakerStatus {
+	t.mtx.Lock()
+	b, ok := t.breakers[ruleID]
+	t.mtx.Unlock()
+	if !ok {
+		return BreakerStatus{State: BreakerClosed}
+	}
+	return b.status()
+}
+
+// setMuted records whether the rule is in a planned maintenance and
+// returns true when it just entered one
+func (t *evalTracker) setMuted(ruleID string, muted bool) bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.muted == nil {
+		t.muted = map[string]bool{}
+	}
+	entered := muted && !t.muted[ruleID]
+	if muted {
+		t.muted[ruleID] = true
+	} else {
+		delete(t.muted, ruleID)
+	}
+	return entered
+}
+
+func (t *evalTracker) recordMissed(n int64, ts time.Time) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.stats.MissedEvaluations += n
+	t.stats.LastMissedEvaluation = ts
+}
+
+func (t *evalTracker) recordCaughtUp(n int64) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.stats.CaughtUpEvaluations += n
+}
+
+// EvalStats returns the evaluation stats of the task
+func (t *evalTracker) EvalStats() EvalStats {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.stats
+}
+
+// catchUpTimestamps returns, oldest first, the scheduled timestamps of the
+// count evaluations before next that are within the lookback
+func catchUpTimestamps(next time.Time, count int64, frequency, lookback time.Duration) []time.Time {
+	if lookback <= 0 || frequency <= 0 || count <= 0 {
+		return nil
+	}
+	if n := int64(lookback / frequency); n < count {
+		count = n
+	}
+	if count > maxCatchUpEvaluations {
+		count = maxCatchUpEvaluations
+	}
+	timestamps := make([]time.Time, 0, count)
+	for i := count; i > 0; i-- {
+		timestamps = append(timestamps, next.Add(-time.Duration(i)*frequency))
+	}
+	return timestamps
+}
@@ -0,0 +1,58 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatchUpTimestamps(t *testing.T) {
+	next := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name      string
+		count     int64
+		frequency time.Duration
+		lookback  time.Duration
+		expected  []time.Time
+	}{
+		{name: "disabled", count: 3, frequency: time.Minute},
+		{name: "nothing missed", count: 0, frequency: time.Minute, lookback: time.Hour},
+		{
+			name:      "all missed within lookback",
+			count:     3,
+			frequency: time.Minute,
+			lookback:  time.Hour,
+			expected:  []time.Time{next.Add(-3 * time.Minute), next.Add(-2 * time.Minute), next.Add(-time.Minute)},
+		},
+		{
+			name:      "bounded by lookback",
+			count:     30,
+			frequency: time.Minute,
+			lookback:  2 * time.Minute,
+			expected:  []time.Time{next.Add(-2 * time.Minute), next.Add(-time.Minute)},
+		},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, catchUpTimestamps(next, c.count, c.frequency, c.lookback), c.name)
+	}
+
+	// the replay is bounded however long the lookback
+	assert.Len(t, catchUpTimestamps(next, 1000, time.Second, 24*time.Hour), maxCatchUpEvaluations)
+}
+
+func TestEvalTracker(t *testing.T) {
+	var tracker evalTracker
+	ts := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tracker.recordMissed(3, ts)
+	tracker.recordCaughtUp(2)
+	tracker.recordMissed(1, ts.Add(time.Hour))
+
+	assert.Equal(t, EvalStats{
+		MissedEvaluations:    4,
+		CaughtUpEvaluations:  2,
+		LastMissedEvaluation: ts.Add(time.Hour),
+	}, tracker.EvalStats())
+}
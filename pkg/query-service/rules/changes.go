@@ -0,0 +1,69 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+const (
+	ChangeDeployment = "deployment"
+	ChangeK8sEvent   = "k8s_event"
+	ChangeConfig     = "config"
+)
+
+// ChangeEvent is a change made to the monitored systems, a deployment, a
+// kubernetes event or a config change, posted by the CI/CD pipelines and
+// the event exporters of the clusters
+type ChangeEvent struct {
+	Id          int64  `json:"id"`
+	Kind        string `json:"kind"`
+	Source      string `json:"source"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	// Labels identify what was changed, e.g. the service and the
+	// environment, they are matched against the labels of the alerts
+	Labels    map[string]string `json:"labels"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+func (e *ChangeEvent) Validate() error {
+	switch e.Kind {
+	case ChangeDeployment, ChangeK8sEvent, ChangeConfig:
+	default:
+		return fmt.Errorf("kind must be one of %s, %s, %s", ChangeDeployment, ChangeK8sEvent, ChangeConfig)
+	}
+	if e.Title == "" {
+		return fmt.Errorf("title is required")
+	}
+	if e.Labels == nil {
+		e.Labels = map[string]string{}
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+	return nil
+}
+
+// AddChangeEvent records the change
+func (m *Manager) AddChangeEvent(ctx context.Context, e *ChangeEvent) (*ChangeEvent, *model.ApiError) {
+	if err := e.Validate(); err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorBadData, Err: err}
+	}
+	id, err := m.ruleDB.AddChangeEvent(ctx, *e)
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	e.Id = id
+	m.opts.changes.invalidate()
+	m.opts.deploys.invalidate()
+	return e, nil
+}
+
+// ChangeEvents returns the changes made between start and end, oldest
+// first
+func (m *Manager) ChangeEvents(ctx context.Context, start, end time.Time) ([]ChangeEvent, error) {
+	return m.ruleDB.GetChangeEvents(ctx, start, end)
+}
@@ -0,0 +1,324 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	am "go.signoz.io/signoz/pkg/query-service/integrations/alertManager"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.signoz.io/signoz/pkg/query-service/utils"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+	"go.uber.org/zap"
+)
+
+const (
+	// ChaosStormLabel identifies the synthetic alerts of a storm
+	ChaosStormLabel = "chaos_storm"
+	chaosAlertName  = "ChaosStorm"
+
+	MaxStormRate        = 1000
+	MaxStormCardinality = 10000
+	MaxStormDuration    = 30 * time.Minute
+
+	// chaosStormTick is how often a batch of the storm is sent
+	chaosStormTick = 100 * time.Millisecond
+)
+
+var errNoStorm = errors.New("no alert storm was started")
+
+// StormRequest starts a storm of synthetic alerts sent through the alert
+// manager to sandbox channels
+type StormRequest struct {
+	// Rate is the number of alerts sent per second
+	Rate float64 `json:"rate"`
+	// Cardinality is the number of distinct alerts, the alerts are sent
+	// again once all of them were sent
+	Cardinality int      `json:"cardinality"`
+	DurationMs  int64    `json:"durationMs"`
+	Channels    []string `json:"channels"`
+	// Labels are added to the alerts, e.g. to exercise the routes and
+	// the grouping of the alert manager
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Validate checks the limits of the storm and that it only targets the
+// sandbox channels
+func (r *StormRequest) Validate(sandbox []string) error {
+	if r.Rate <= 0 || r.Rate > MaxStormRate {
+		return fmt.Errorf("rate must be between 0 and %d alerts per second", MaxStormRate)
+	}
+	if r.Cardinality <= 0 || r.Cardinality > MaxStormCardinality {
+		return fmt.Errorf("cardinality must be between 1 and %d", MaxStormCardinality)
+	}
+	if r.DurationMs <= 0 || time.Duration(r.DurationMs)*time.Millisecond > MaxStormDuration {
+		return fmt.Errorf("duration must be positive and at most %s", MaxStormDuration)
+	}
+	if len(r.Channels) == 0 {
+		return fmt.Errorf("at least one channel is required")
+	}
+	for _, channel := range r.Channels {
+		if !slices.Contains(sandbox, channel) {
+			return fmt.Errorf("channel %s is not a sandbox channel", channel)
+		}
+	}
+	for name := range r.Labels {
+		switch name {
+		case labels.AlertNameLabel, ChaosStormLabel, "series":
+			return fmt.Errorf("label %s is set by the storm", name)
+		}
+	}
+	return nil
+}
+
+// StormStatus is the progress of a storm and what the notification
+// pipeline did with its alerts
+type StormStatus struct {
+	Id          string    `json:"id"`
+	Running     bool      `json:"running"`
+	Rate        float64   `json:"rate"`
+	Cardinality int       `json:"cardinality"`
+	Channels    []string  `json:"channels"`
+	StartedAt   time.Time `json:"startedAt"`
+	Until       time.Time `json:"until"`
+	EndedAt     time.Time `json:"endedAt,omitempty"`
+	// Sent is the number of alerts sent to the notifier, Delivered and
+	// Dropped the number of them the alert manager accepted and the
+	// notifier dropped
+	Sent      uint64 `json:"sent"`
+	Delivered uint64 `json:"delivered"`
+	Dropped   uint64 `json:"dropped"`
+	// Notifier is the state of the notification queue
+	Notifier *am.NotifierStats `json:"notifier,omitempty"`
+}
+
+// alertStorm sends synthetic alerts at a fixed rate until its end or until
+// it is stopped, and resolves them at the end
+type alertStorm struct {
+	id        string
+	req       StormRequest
+	startedAt time.Time
+	until     time.Time
+
+	sent      atomic.Uint64
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+
+	mtx     sync.Mutex
+	endedAt time.Time
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func newAlertStorm(id string, req StormRequest, now time.Time) *alertStorm {
+	return &alertStorm{
+		id:        id,
+		req:       req,
+		startedAt: now,
+		until:     now.Add(time.Duration(req.DurationMs) * time.Millisecond),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+func (s *alertStorm) alert(series int, now time.Time, resolved bool) *am.Alert {
+	lbls := map[string]string{}
+	for k, v := range s.req.Labels {
+		lbls[k] = v
+	}
+	lbls[labels.AlertNameLabel] = chaosAlertName
+	lbls[ChaosStormLabel] = s.id
+	lbls["series"] = strconv.Itoa(series)
+
+	a := &am.Alert{
+		Labels:      labels.FromMap(lbls),
+		Annotations: labels.FromMap(map[string]string{"summary": fmt.Sprintf("synthetic alert %d of the chaos storm %s", series, s.id)}),
+		StartsAt:    s.startedAt,
+		// the alerts keep firing until the storm resolves them
+		EndsAt:    s.until.Add(time.Minute),
+		Receivers: s.req.Channels,
+	}
+	if resolved {
+		a.EndsAt = now
+	}
+	return a
+}
+
+func (s *alertStorm) run(send func(alerts ...*am.Alert)) {
+	defer close(s.done)
+
+	tick := time.NewTicker(chaosStormTick)
+	defer tick.Stop()
+	end := time.NewTimer(time.Until(s.until))
+	defer end.Stop()
+
+	perTick := s.req.Rate * chaosStormTick.Seconds()
+	var due float64
+	next := 0
+loop:
+	for {
+		select {
+		case <-s.stop:
+			break loop
+		case <-end.C:
+			break loop
+		case now := <-tick.C:
+			due += perTick
+			n := int(due)
+			due -= float64(n)
+			if n == 0 {
+				continue
+			}
+			batch := make([]*am.Alert, 0, n)
+			for i := 0; i < n; i++ {
+				batch = append(batch, s.alert(next%s.req.Cardinality, now, false))
+				next++
+			}
+			send(batch...)
+			s.sent.Add(uint64(n))
+		}
+	}
+
+	// the alerts sent are resolved so that the channels see the storm end
+	now := time.Now()
+	resolved := make([]*am.Alert, 0, min(next, s.req.Cardinality))
+	for i := 0; i < next && i < s.req.Cardinality; i++ {
+		resolved = append(resolved, s.alert(i, now, true))
+	}
+	if len(resolved) > 0 {
+		send(resolved...)
+	}
+	s.mtx.Lock()
+	s.endedAt = now
+	s.mtx.Unlock()
+	zap.L().Info("the alert storm ended", zap.String("id", s.id), zap.Uint64("sent", s.sent.Load()))
+}
+
+func (s *alertStorm) status() StormStatus {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return StormStatus{
+		Id:          s.id,
+		Running:     s.endedAt.IsZero(),
+		Rate:        s.req.Rate,
+		Cardinality: s.req.Cardinality,
+		Channels:    s.req.Channels,
+		StartedAt:   s.startedAt,
+		Until:       s.until,
+		EndedAt:     s.endedAt,
+		Sent:        s.sent.Load(),
+		Delivered:   s.delivered.Load(),
+		Dropped:     s.dropped.Load(),
+	}
+}
+
+// chaosRunner runs one alert storm at a time and keeps the last one to
+// report on it
+type chaosRunner struct {
+	mtx   sync.Mutex
+	storm *alertStorm
+}
+
+func (c *chaosRunner) current() *alertStorm {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.storm
+}
+
+// observe counts the alerts of the current storm delivered or dropped by
+// the notifier
+func (c *chaosRunner) observe(alerts []*am.Alert, status string) {
+	if c == nil {
+		return
+	}
+	storm := c.current()
+	if storm == nil {
+		return
+	}
+	var n uint64
+	for _, a := range alerts {
+		if a.Labels != nil && a.Labels.Get(ChaosStormLabel) == storm.id {
+			n++
+		}
+	}
+	if n == 0 {
+		return
+	}
+	if status == NotificationDelivered {
+		storm.delivered.Add(n)
+	} else {
+		storm.dropped.Add(n)
+	}
+}
+
+// start starts the storm unless one is running
+func (c *chaosRunner) start(storm *alertStorm, send func(alerts ...*am.Alert)) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.storm != nil && c.storm.status().Running {
+		return false
+	}
+	c.storm = storm
+	go storm.run(send)
+	return true
+}
+
+// StartStorm sends a storm of synthetic alerts through the alert manager
+// to the sandbox channels, to validate the capacity and the rate limits
+// of the notification pipeline
+func (m *Manager) StartStorm(req *StormRequest) (*StormStatus, *model.ApiError) {
+	if len(m.opts.ChaosChannels) == 0 {
+		return nil, &model.ApiError{Typ: model.ErrorForbidden, Err: fmt.Errorf("the chaos mode is disabled, no sandbox channels are set")}
+	}
+	if err := req.Validate(m.opts.ChaosChannels); err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorBadData, Err: err}
+	}
+	id, err := utils.RandomHex(8)
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+
+	storm := newAlertStorm(id, *req, time.Now())
+	if !m.opts.chaos.start(storm, m.sendStormAlerts) {
+		return nil, &model.ApiError{Typ: model.ErrorConflict, Err: fmt.Errorf("an alert storm is already running")}
+	}
+	zap.L().Info("an alert storm was started", zap.String("id", id), zap.Float64("rate", req.Rate), zap.Int("cardinality", req.Cardinality), zap.Strings("channels", req.Channels))
+	return m.StormStatus()
+}
+
+// StopStorm stops the running storm and resolves its alerts
+func (m *Manager) StopStorm() (*StormStatus, *model.ApiError) {
+	storm := m.opts.chaos.current()
+	if storm == nil {
+		return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: errNoStorm}
+	}
+	storm.stopOnce.Do(func() { close(storm.stop) })
+	<-storm.done
+	return m.StormStatus()
+}
+
+// StormStatus returns the status of the running or the last storm
+func (m *Manager) StormStatus() (*StormStatus, *model.ApiError) {
+	storm := m.opts.chaos.current()
+	if storm == nil {
+		return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: errNoStorm}
+	}
+	status := storm.status()
+	if m.notifier != nil {
+		stats := m.notifier.Stats()
+		status.Notifier = &stats
+	}
+	return &status, nil
+}
+
+func (m *Manager) sendStormAlerts(alerts ...*am.Alert) {
+	m.notifier.Send(alerts...)
+	m.pushDispatcher.Send(alerts...)
+	m.chatDispatcher.Send(alerts...)
+}
@@ -0,0 +1,105 @@
+package rules
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	am "go.signoz.io/signoz/pkg/query-service/integrations/alertManager"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+func TestStormRequestValidate(t *testing.T) {
+	sandbox := []string{"sandbox-slack", "sandbox-webhook"}
+	valid := func() StormRequest {
+		return StormRequest{Rate: 10, Cardinality: 5, DurationMs: 1000, Channels: []string{"sandbox-slack"}}
+	}
+
+	req := valid()
+	assert.NoError(t, req.Validate(sandbox))
+
+	cases := map[string]func(r *StormRequest){
+		"no rate":               func(r *StormRequest) { r.Rate = 0 },
+		"rate above max":        func(r *StormRequest) { r.Rate = MaxStormRate + 1 },
+		"no cardinality":        func(r *StormRequest) { r.Cardinality = 0 },
+		"cardinality above max": func(r *StormRequest) { r.Cardinality = MaxStormCardinality + 1 },
+		"no duration":           func(r *StormRequest) { r.DurationMs = 0 },
+		"duration above max":    func(r *StormRequest) { r.DurationMs = (MaxStormDuration + time.Second).Milliseconds() },
+		"no channels":           func(r *StormRequest) { r.Channels = nil },
+		"production channel":    func(r *StormRequest) { r.Channels = []string{"sandbox-slack", "oncall-pagerduty"} },
+		"storm label":           func(r *StormRequest) { r.Labels = map[string]string{ChaosStormLabel: "x"} },
+		"alert name label":      func(r *StormRequest) { r.Labels = map[string]string{labels.AlertNameLabel: "x"} },
+	}
+	for name, change := range cases {
+		req := valid()
+		change(&req)
+		assert.Error(t, req.Validate(sandbox), name)
+	}
+}
+
+func TestAlertStorm(t *testing.T) {
+	var mtx sync.Mutex
+	var batches [][]*am.Alert
+	send := func(alerts ...*am.Alert) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		batches = append(batches, alerts)
+	}
+
+	runner := &chaosRunner{}
+	req := StormRequest{Rate: 100, Cardinality: 3, DurationMs: 500, Channels: []string{"sandbox"}, Labels: map[string]string{"severity": "critical"}}
+	storm := newAlertStorm("abc", req, time.Now())
+	require.True(t, runner.start(storm, send))
+
+	// a second storm is refused while the first one runs
+	assert.False(t, runner.start(newAlertStorm("def", req, time.Now()), send))
+
+	<-storm.done
+	status := storm.status()
+	assert.False(t, status.Running)
+	assert.Greater(t, status.Sent, uint64(req.Cardinality))
+
+	mtx.Lock()
+	require.NotEmpty(t, batches)
+	firing := batches[:len(batches)-1]
+	resolved := batches[len(batches)-1]
+	mtx.Unlock()
+
+	var sent uint64
+	series := map[string]int{}
+	for _, batch := range firing {
+		for _, a := range batch {
+			sent++
+			series[a.Labels.Get("series")]++
+			assert.Equal(t, "abc", a.Labels.Get(ChaosStormLabel))
+			assert.Equal(t, "critical", a.Labels.Get("severity"))
+			assert.Equal(t, []string{"sandbox"}, a.Receivers)
+		}
+	}
+	assert.Equal(t, status.Sent, sent)
+	// the alerts cycle through the cardinality
+	assert.Len(t, series, req.Cardinality)
+
+	// the alerts sent are resolved at the end
+	require.Len(t, resolved, req.Cardinality)
+	for _, a := range resolved {
+		assert.False(t, a.EndsAt.After(status.EndedAt))
+	}
+
+	runner.observe(firing[0], NotificationDelivered)
+	runner.observe(resolved, NotificationDropped)
+	other := newAlertStorm("other", req, time.Now())
+	runner.observe([]*am.Alert{other.alert(0, time.Now(), false)}, NotificationDropped)
+	status = storm.status()
+	assert.Equal(t, uint64(len(firing[0])), status.Delivered)
+	assert.Equal(t, uint64(req.Cardinality), status.Dropped)
+
+	// a new storm starts once the last one ended
+	next := newAlertStorm("def", req, time.Now())
+	require.True(t, runner.start(next, send))
+	next.stopOnce.Do(func() { close(next.stop) })
+	<-next.done
+	assert.False(t, next.status().Running)
+}
@@ -0,0 +1,64 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/common"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// AcknowledgeRuleAlerts acknowledges the firing alert of the rule with the
+// fingerprint, or all its firing alerts when the fingerprint is zero, and
+// returns the number of alerts acknowledged
+func (m *Manager) AcknowledgeRuleAlerts(ctx context.Context, ruleID string, fingerprint uint64) (int, *model.ApiError) {
+	var fps []uint64
+	m.mtx.RLock()
+	rule, ok := m.rules[ruleID]
+	if ok {
+		for _, a := range rule.ActiveAlerts() {
+			fp := a.Labels.Hash()
+			if a.State == StateFiring && (fingerprint == 0 || fp == fingerprint) {
+				fps = append(fps, fp)
+			}
+		}
+	}
+	m.mtx.RUnlock()
+	if !ok {
+		return 0, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("rule %s not found", ruleID)}
+	}
+
+	for _, fp := range fps {
+		ack, err := m.ruleDB.AcknowledgeAlert(ctx, ruleID, fp)
+		if err != nil {
+			return 0, newApiErrorInternal(err)
+		}
+		m.opts.Events.Publish(m.ackEvent(ack))
+	}
+	return len(fps), nil
+}
+
+// MuteRule mutes the alerts of the rule from now for the duration with a
+// planned maintenance, which is listed as a silence, and returns its id
+func (m *Manager) MuteRule(ctx context.Context, ruleID string, d time.Duration, comment string) (string, *model.ApiError) {
+	if d <= 0 {
+		return "", &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("invalid mute duration %s", d)}
+	}
+	if _, err := m.ruleDB.GetStoredRule(ctx, ruleID); err != nil {
+		return "", &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("rule %s not found", ruleID)}
+	}
+
+	now := time.Now().UTC()
+	silence := &AMv2Silence{
+		Matchers: []AMv2Matcher{{Name: labels.AlertRuleIdLabel, Value: ruleID}},
+		StartsAt: now,
+		EndsAt:   now.Add(d),
+		Comment:  comment,
+	}
+	if user := common.GetUserFromContext(ctx); user != nil {
+		silence.CreatedBy = user.Email
+	}
+	return m.PutAMv2Silence(ctx, silence)
+}
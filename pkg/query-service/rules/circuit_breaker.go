@@ -0,0 +1,144 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+type BreakerState string
+
+const (
+	// BreakerClosed evaluates the rule every interval
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen skips the evaluations until the backoff expires
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen lets a single evaluation probe the query
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+const (
+	// DefaultBreakerThreshold is the number of consecutive failed
+	// evaluations that open the circuit of a rule
+	DefaultBreakerThreshold = 3
+	// DefaultBreakerMaxBackoff caps the time between the probes of an
+	// open circuit
+	DefaultBreakerMaxBackoff = time.Hour
+)
+
+// BreakerStatus is the circuit breaker state of a rule
+type BreakerStatus struct {
+	State               BreakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutiveFailures"`
+	OpenedAt            *time.Time   `json:"openedAt,omitempty"`
+	NextAttempt         *time.Time   `json:"nextAttempt,omitempty"`
+}
+
+// circuitBreaker stops evaluating a rule whose query keeps failing. After
+// threshold consecutive failures the circuit opens and the rule is probed
+// after a backoff that doubles with every failed probe, starting at the
+// frequency of the rule. A successful evaluation closes the circuit.
+type circuitBreaker struct {
+	threshold  int
+	maxBackoff time.Duration
+
+	mtx         sync.Mutex
+	state       BreakerState
+	failures    int
+	backoff     time.Duration
+	openedAt    time.Time
+	nextAttempt time.Time
+}
+
+func newCircuitBreaker(threshold int, maxBackoff time.Duration) *circuitBreaker {
+	if threshold <= 0 {
+		threshold = DefaultBreakerThreshold
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultBreakerMaxBackoff
+	}
+	return &circuitBreaker{threshold: threshold, maxBackoff: maxBackoff, state: BreakerClosed}
+}
+
+// allow tells whether the rule is evaluated at now, an open circuit lets
+// one probe through once the backoff expired
+func (b *circuitBreaker) allow(now time.Time) bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if now.Before(b.nextAttempt) {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		return true
+	case BreakerHalfOpen:
+		// a probe is in flight
+		return false
+	default:
+		return true
+	}
+}
+
+func (b *circuitBreaker) success() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.state = BreakerClosed
+	b.failures = 0
+	b.backoff = 0
+	b.openedAt = time.Time{}
+	b.nextAttempt = time.Time{}
+}
+
+// failure records a failed evaluation, errors from canceling the
+// evaluation, e.g. on shutdown, are not the fault of the query
+func (b *circuitBreaker) failure(now time.Time, frequency time.Duration, err error) {
+	if errors.Is(err, context.Canceled) {
+		b.abort()
+		return
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.failures++
+	switch {
+	case b.state == BreakerHalfOpen:
+		b.backoff *= 2
+	case b.failures >= b.threshold:
+		b.backoff = frequency
+		b.openedAt = now
+	default:
+		return
+	}
+	if b.backoff > b.maxBackoff {
+		b.backoff = b.maxBackoff
+	}
+	b.state = BreakerOpen
+	b.nextAttempt = now.Add(b.backoff)
+}
+
+// abort returns a probe that did not run to the open state, the next
+// evaluation probes again
+func (b *circuitBreaker) abort() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if b.state == BreakerHalfOpen {
+		b.state = BreakerOpen
+	}
+}
+
+func (b *circuitBreaker) status() BreakerStatus {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	status := BreakerStatus{State: b.state, ConsecutiveFailures: b.failures}
+	if !b.openedAt.IsZero() {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	if !b.nextAttempt.IsZero() {
+		nextAttempt := b.nextAttempt
+		status.NextAttempt = &nextAttempt
+	}
+	return status
+}
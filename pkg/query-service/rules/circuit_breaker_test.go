@@ -0,0 +1,71 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	b := newCircuitBreaker(3, 10*time.Minute)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	freq := time.Minute
+	errQuery := errors.New("too many simultaneous queries")
+
+	// failures below the threshold keep the circuit closed
+	for i := 0; i < 2; i++ {
+		assert.True(t, b.allow(now))
+		b.failure(now, freq, errQuery)
+		now = now.Add(freq)
+	}
+	assert.Equal(t, BreakerClosed, b.status().State)
+
+	// the third failure opens the circuit for one interval
+	assert.True(t, b.allow(now))
+	b.failure(now, freq, errQuery)
+	status := b.status()
+	assert.Equal(t, BreakerOpen, status.State)
+	assert.Equal(t, 3, status.ConsecutiveFailures)
+	assert.Equal(t, now.Add(freq), *status.NextAttempt)
+	assert.False(t, b.allow(now.Add(30*time.Second)))
+
+	// a failed probe doubles the backoff up to the maximum
+	backoffs := []time.Duration{2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 10 * time.Minute, 10 * time.Minute}
+	for _, backoff := range backoffs {
+		now = *b.status().NextAttempt
+		assert.True(t, b.allow(now))
+		assert.Equal(t, BreakerHalfOpen, b.status().State)
+		// only a single probe is let through
+		assert.False(t, b.allow(now))
+		b.failure(now, freq, errQuery)
+		assert.Equal(t, now.Add(backoff), *b.status().NextAttempt)
+	}
+
+	// a canceled probe does not count and is retried
+	now = *b.status().NextAttempt
+	assert.True(t, b.allow(now))
+	b.failure(now, freq, context.Canceled)
+	assert.Equal(t, BreakerOpen, b.status().State)
+	assert.Equal(t, 8, b.status().ConsecutiveFailures)
+	assert.True(t, b.allow(now))
+
+	// a successful probe closes the circuit
+	b.success()
+	assert.Equal(t, BreakerStatus{State: BreakerClosed}, b.status())
+	assert.True(t, b.allow(now))
+}
+
+func TestEvalTrackerBreakers(t *testing.T) {
+	var tracker evalTracker
+	opts := &ManagerOptions{BreakerThreshold: 1}
+
+	assert.Equal(t, BreakerStatus{State: BreakerClosed}, tracker.BreakerStatus("1"))
+
+	tracker.breaker("1", opts).failure(time.Now(), time.Minute, errors.New("bad query"))
+	assert.Equal(t, BreakerOpen, tracker.BreakerStatus("1").State)
+	assert.Equal(t, BreakerClosed, tracker.BreakerStatus("2").State)
+	assert.Same(t, tracker.breaker("1", opts), tracker.breaker("1", opts))
+}
@@ -0,0 +1,218 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.uber.org/multierr"
+)
+
+// ClickHouseSandbox restricts the raw clickhouse queries of the threshold
+// rules so that a bad alert query can not degrade the cluster. The limits
+// cap the query quota of every rule, zero limits are unlimited.
+type ClickHouseSandbox struct {
+	MaxExecutionTime time.Duration
+	MaxRowsToRead    uint64
+	MaxMemoryUsage   uint64
+	// ReadOnly runs the queries with readonly=2, clickhouse refuses the
+	// statements that write or change the schema
+	ReadOnly bool
+	// Profile is the clickhouse settings profile the queries run with,
+	// its constraints restrict the queries further
+	Profile string
+}
+
+// QueryQuota limits the resources of one raw clickhouse query of a rule,
+// within the limits of the sandbox
+type QueryQuota struct {
+	MaxExecutionTime Duration `yaml:"maxExecutionTime,omitempty" json:"maxExecutionTime,omitempty"`
+	MaxRowsToRead    uint64   `yaml:"maxRowsToRead,omitempty" json:"maxRowsToRead,omitempty"`
+	MaxMemoryUsage   uint64   `yaml:"maxMemoryUsage,omitempty" json:"maxMemoryUsage,omitempty"`
+}
+
+// bannedClickHouseFunctions read or write outside of the telemetry tables,
+// or stall the server
+var bannedClickHouseFunctions = []string{
+	"url", "urlCluster", "file", "fileCluster", "remote", "remoteSecure",
+	"cluster", "clusterAllReplicas", "s3", "s3Cluster", "gcs", "azureBlobStorage",
+	"hdfs", "hdfsCluster", "mysql", "postgresql", "mongodb", "redis", "sqlite",
+	"jdbc", "odbc", "executable", "input", "sleep", "sleepEachRow",
+}
+
+var (
+	bannedClickHouseFunctionRe = regexp.MustCompile(`(?i)\b(` + strings.Join(bannedClickHouseFunctions, "|") + `)\s*\(`)
+	// the clauses that write the result or override the limits
+	bannedClickHouseClauseRe = regexp.MustCompile(`(?i)\b(INTO\s+OUTFILE|SETTINGS)\b`)
+	clickHouseStatementRe    = regexp.MustCompile(`^[\s(]*(?i:SELECT|WITH)\b`)
+	clickHouseCommentRe      = regexp.MustCompile(`(?s)--[^\n]*|/\*.*?\*/`)
+	clickHouseStringRe       = regexp.MustCompile(`'(?:[^'\\]|\\.|'')*'`)
+	queryTemplateActionRe    = regexp.MustCompile(`\{\{.*?\}\}`)
+	// the variables of the start of the range of the evaluation
+	startTimeVariableRe = regexp.MustCompile(`\{\{\s*\.(start_timestamp|start_timestamp_ms|start_timestamp_nano|start_datetime|SIGNOZ_START_TIME)\s*\}\}`)
+)
+
+// ValidateClickHouseQuery checks that the raw query of a rule is a single
+// read only statement, that it filters on the start of the evaluation
+// range and that it does not use the banned functions
+func ValidateClickHouseQuery(query string) error {
+	if !startTimeVariableRe.MatchString(query) {
+		return fmt.Errorf("the query must filter on the start of the evaluation range, e.g. timestamp >= {{.start_timestamp_nano}}")
+	}
+	// the templates, strings and comments can hold anything
+	stripped := queryTemplateActionRe.ReplaceAllString(query, "0")
+	stripped = clickHouseStringRe.ReplaceAllString(stripped, "''")
+	stripped = clickHouseCommentRe.ReplaceAllString(stripped, " ")
+
+	if !clickHouseStatementRe.MatchString(stripped) {
+		return fmt.Errorf("the query must be a SELECT statement")
+	}
+	if i := strings.Index(stripped, ";"); i >= 0 && strings.TrimSpace(stripped[i+1:]) != "" {
+		return fmt.Errorf("the query must be a single statement")
+	}
+	if m := bannedClickHouseFunctionRe.FindStringSubmatch(stripped); m != nil {
+		return fmt.Errorf("the function %s is not allowed in the queries of the rules", m[1])
+	}
+	if m := bannedClickHouseClauseRe.FindStringSubmatch(stripped); m != nil {
+		return fmt.Errorf("the %s clause is not allowed in the queries of the rules", strings.ToUpper(strings.Join(strings.Fields(m[1]), " ")))
+	}
+	return nil
+}
+
+// validateClickHouseQueries checks the raw queries of a rule
+func validateClickHouseQueries(cq *v3.CompositeQuery) []error {
+	names := make([]string, 0, len(cq.ClickHouseQueries))
+	for name := range cq.ClickHouseQueries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		q := cq.ClickHouseQueries[name]
+		if q.Disabled {
+			continue
+		}
+		if err := ValidateClickHouseQuery(q.Query); err != nil {
+			errs = append(errs, fmt.Errorf("query %s: %w", name, err))
+		}
+	}
+	return errs
+}
+
+// clickHouseQueries returns the raw clickhouse queries of the rule by name
+func (r *PostableRule) clickHouseQueries() map[string]string {
+	if r.RuleCondition == nil || r.RuleCondition.CompositeQuery == nil || r.RuleCondition.CompositeQuery.QueryType != v3.QueryTypeClickHouseSQL {
+		return nil
+	}
+	queries := map[string]string{}
+	for name, q := range r.RuleCondition.CompositeQuery.ClickHouseQueries {
+		if !q.Disabled {
+			queries[name] = q.Query
+		}
+	}
+	return queries
+}
+
+// sandboxViolations returns why the sandbox rejects the raw clickhouse
+// queries of the rule. The sandbox applies to the queries written by the
+// users, the stored rules are loaded as they are so that the rules saved
+// before it keep running after an upgrade.
+func (r *PostableRule) sandboxViolations() []error {
+	if r.clickHouseQueries() == nil {
+		return nil
+	}
+	return validateClickHouseQueries(r.RuleCondition.CompositeQuery)
+}
+
+// validateSandbox checks the raw clickhouse queries of a created, edited
+// or tested rule
+func (r *PostableRule) validateSandbox() error {
+	return multierr.Combine(r.sandboxViolations()...)
+}
+
+// validatePatchedSandbox checks the raw clickhouse queries of a patched
+// rule when the patch changes them, the patches of the other fields, e.g.
+// disabling the rule, keep the queries stored before the sandbox
+func (r *PostableRule) validatePatchedSandbox(stored map[string]string) error {
+	if reflect.DeepEqual(r.clickHouseQueries(), stored) {
+		return nil
+	}
+	return r.validateSandbox()
+}
+
+// sandboxViolationTexts returns the sandbox violations of the stored rule
+// for the rules API
+func sandboxViolationTexts(r *PostableRule) []string {
+	var texts []string
+	for _, err := range r.sandboxViolations() {
+		texts = append(texts, err.Error())
+	}
+	return texts
+}
+
+// effectiveQuota returns the quota of the rule capped by the limits of
+// the sandbox
+func (s *ClickHouseSandbox) effectiveQuota(quota *QueryQuota) QueryQuota {
+	q := QueryQuota{}
+	if quota != nil {
+		q = *quota
+	}
+	if s == nil {
+		return q
+	}
+	if s.MaxExecutionTime > 0 && (q.MaxExecutionTime == 0 || time.Duration(q.MaxExecutionTime) > s.MaxExecutionTime) {
+		q.MaxExecutionTime = Duration(s.MaxExecutionTime)
+	}
+	q.MaxRowsToRead = minLimit(q.MaxRowsToRead, s.MaxRowsToRead)
+	q.MaxMemoryUsage = minLimit(q.MaxMemoryUsage, s.MaxMemoryUsage)
+	return q
+}
+
+// minLimit returns the lowest of the limits, zero is unlimited
+func minLimit(a, b uint64) uint64 {
+	if a == 0 || (b > 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// sandboxContext returns the context the raw clickhouse queries of the
+// rule run with. The execution time is enforced with the deadline of the
+// context, clickhouse-go derives max_execution_time from it.
+func (r *ThresholdRule) sandboxContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.ruleCondition.QueryType() != v3.QueryTypeClickHouseSQL {
+		return ctx, func() {}
+	}
+	quota := r.opts.Sandbox.effectiveQuota(r.queryQuota)
+
+	settings := clickhouse.Settings{}
+	if quota.MaxRowsToRead > 0 {
+		settings["max_rows_to_read"] = quota.MaxRowsToRead
+		settings["read_overflow_mode"] = "throw"
+	}
+	if quota.MaxMemoryUsage > 0 {
+		settings["max_memory_usage"] = quota.MaxMemoryUsage
+	}
+	if s := r.opts.Sandbox; s != nil {
+		if s.Profile != "" {
+			settings["profile"] = s.Profile
+		}
+		if s.ReadOnly {
+			settings["readonly"] = 2
+		}
+	}
+	if len(settings) > 0 {
+		ctx = clickhouse.Context(ctx, clickhouse.WithSettings(settings))
+	}
+	if quota.MaxExecutionTime > 0 {
+		return context.WithTimeout(ctx, time.Duration(quota.MaxExecutionTime))
+	}
+	return ctx, func() {}
+}
@@ -0,0 +1,122 @@
+package rules
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateClickHouseQuery(t *testing.T) {
+	cases := []struct {
+		name  string
+		query string
+		err   string
+	}{
+		{
+			name:  "select",
+			query: "SELECT count() AS value FROM signoz_logs.distributed_logs WHERE timestamp >= {{.start_timestamp_nano}} AND body LIKE '%url(%'",
+		},
+		{
+			name:  "with and trailing semicolon",
+			query: "WITH 1 AS x SELECT x FROM t WHERE ts > {{ .start_timestamp_ms }};",
+		},
+		{
+			name:  "no time filter",
+			query: "SELECT count() FROM signoz_logs.distributed_logs",
+			err:   "start of the evaluation range",
+		},
+		{
+			name:  "write",
+			query: "INSERT INTO t SELECT * FROM t WHERE ts > {{.start_timestamp}}",
+			err:   "SELECT statement",
+		},
+		{
+			name:  "several statements",
+			query: "SELECT 1 WHERE ts > {{.start_timestamp}}; DROP TABLE t",
+			err:   "single statement",
+		},
+		{
+			name:  "banned function",
+			query: "SELECT * FROM URL ('http://example.com', CSV) WHERE ts > {{.start_timestamp}}",
+			err:   "function URL",
+		},
+		{
+			name:  "banned function in comment",
+			query: "SELECT 1 WHERE ts > {{.start_timestamp}} -- sleep(3)",
+		},
+		{
+			name:  "settings",
+			query: "SELECT 1 WHERE ts > {{.start_timestamp}} SETTINGS max_rows_to_read = 0",
+			err:   "SETTINGS clause",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := ValidateClickHouseQuery(c.query)
+			if c.err == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), c.err)
+			}
+		})
+	}
+}
+
+func TestClickHouseSandboxEffectiveQuota(t *testing.T) {
+	sandbox := &ClickHouseSandbox{MaxExecutionTime: 30 * time.Second, MaxRowsToRead: 1000}
+
+	q := sandbox.effectiveQuota(nil)
+	assert.Equal(t, Duration(30*time.Second), q.MaxExecutionTime)
+	assert.Equal(t, uint64(1000), q.MaxRowsToRead)
+	assert.Equal(t, uint64(0), q.MaxMemoryUsage)
+
+	// the quota of the rule can only lower the limits
+	q = sandbox.effectiveQuota(&QueryQuota{MaxExecutionTime: Duration(time.Minute), MaxRowsToRead: 10, MaxMemoryUsage: 1 << 30})
+	assert.Equal(t, Duration(30*time.Second), q.MaxExecutionTime)
+	assert.Equal(t, uint64(10), q.MaxRowsToRead)
+	assert.Equal(t, uint64(1<<30), q.MaxMemoryUsage)
+
+	var none *ClickHouseSandbox
+	assert.Equal(t, QueryQuota{MaxRowsToRead: 5}, none.effectiveQuota(&QueryQuota{MaxRowsToRead: 5}))
+}
+
+func TestClickHouseSandboxStoredRules(t *testing.T) {
+	// a rule saved before the sandbox, its query has no time filter
+	stored := `{"alert": "legacy", "condition": {"compositeQuery": {"queryType": "clickhouse_sql",
+		"chQueries": {"A": {"query": "SELECT count() AS value FROM signoz_logs.distributed_logs"}}},
+		"op": "1", "matchType": "1", "target": 10}}`
+
+	// the stored rules are loaded as they are
+	rule, err := parseStoredRule(stored)
+	require.NoError(t, err)
+	err = rule.validateSandbox()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "query A: the query must filter on the start of the evaluation range")
+
+	// the patches that keep the queries are accepted
+	queries := rule.clickHouseQueries()
+	rule.Disabled = true
+	assert.NoError(t, rule.validatePatchedSandbox(queries))
+	rule.RuleCondition.CompositeQuery.ClickHouseQueries["A"].Query = "SELECT 1 AS value"
+	assert.Error(t, rule.validatePatchedSandbox(queries))
+	rule.RuleCondition.CompositeQuery.ClickHouseQueries["A"].Query = "SELECT 1 AS value WHERE ts > {{.start_timestamp}}"
+	assert.NoError(t, rule.validatePatchedSandbox(queries))
+
+	// the rules API flags them
+	m := &Manager{
+		rules:  map[string]Rule{},
+		ruleDB: &bundleDB{rules: []StoredRule{{Id: 1, Data: stored}}},
+		opts:   &ManagerOptions{},
+	}
+	rules, err := m.ListRuleStates(context.Background())
+	require.NoError(t, err)
+	require.Len(t, rules.Rules, 1)
+	assert.Equal(t, []string{"query A: the query must filter on the start of the evaluation range, e.g. timestamp >= {{.start_timestamp_nano}}"}, rules.Rules[0].SandboxViolations)
+
+	// and the new rules are refused
+	_, err = m.CreateRule(context.Background(), stored)
+	assert.ErrorContains(t, err, "the query must filter on the start of the evaluation range")
+}
@@ -0,0 +1,310 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+// CloudWatchDimension is a dimension of the metric of an alarm
+type CloudWatchDimension struct {
+	Name  string `json:"Name"`
+	Value string `json:"Value"`
+}
+
+// CloudWatchAlarm is a metric alarm, in the format of the MetricAlarms of
+// aws cloudwatch describe-alarms
+type CloudWatchAlarm struct {
+	AlarmName          string                `json:"AlarmName"`
+	AlarmDescription   string                `json:"AlarmDescription,omitempty"`
+	Namespace          string                `json:"Namespace,omitempty"`
+	MetricName         string                `json:"MetricName,omitempty"`
+	Statistic          string                `json:"Statistic,omitempty"`
+	ExtendedStatistic  string                `json:"ExtendedStatistic,omitempty"`
+	Dimensions         []CloudWatchDimension `json:"Dimensions,omitempty"`
+	Period             int64                 `json:"Period,omitempty"`
+	EvaluationPeriods  int64                 `json:"EvaluationPeriods,omitempty"`
+	DatapointsToAlarm  int64                 `json:"DatapointsToAlarm,omitempty"`
+	Threshold          float64               `json:"Threshold"`
+	ComparisonOperator string                `json:"ComparisonOperator"`
+	TreatMissingData   string                `json:"TreatMissingData,omitempty"`
+	// Metrics is set for the metric math alarms, which are not converted
+	Metrics json.RawMessage `json:"Metrics,omitempty"`
+}
+
+// CloudWatchImportRequest selects the alarms converted into threshold
+// rules. The alarms are fetched from cloudwatch with the credentials, the
+// default credentials of the query service when not set, assuming the role
+// when set, unless the alarms are given.
+type CloudWatchImportRequest struct {
+	Region          string `json:"region"`
+	AccessKeyID     string `json:"accessKeyId,omitempty"`
+	SecretAccessKey string `json:"secretAccessKey,omitempty"`
+	SessionToken    string `json:"sessionToken,omitempty"`
+	RoleARN         string `json:"roleArn,omitempty"`
+	ExternalID      string `json:"externalId,omitempty"`
+	// AlarmNamePrefix selects the alarms fetched by name
+	AlarmNamePrefix string `json:"alarmNamePrefix,omitempty"`
+
+	Alarms []CloudWatchAlarm `json:"alarms,omitempty"`
+
+	// DryRun returns the rules without creating them
+	DryRun bool `json:"dryRun"`
+	// Enable creates the rules enabled, they are created disabled
+	// otherwise so that they do not alert alongside the alarms
+	Enable            bool     `json:"enable"`
+	PreferredChannels []string `json:"preferredChannels,omitempty"`
+}
+
+// CloudWatchConversion is the rule converted from an alarm, or the reason
+// the alarm was not converted
+type CloudWatchConversion struct {
+	AlarmName string        `json:"alarmName"`
+	Rule      *PostableRule `json:"rule,omitempty"`
+	RuleID    string        `json:"ruleId,omitempty"`
+	Warnings  []string      `json:"warnings,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
+
+var (
+	cloudWatchSnakeCase = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+	cloudWatchUnsafe    = regexp.MustCompile(`[^a-zA-Z0-9:_]`)
+	cloudWatchUnderline = regexp.MustCompile(`__+`)
+)
+
+// cloudWatchName converts the name the way the prometheus cloudwatch
+// exporter of the aws integrations does, CPUUtilization is cpuutilization
+// and BinLogDiskUsage is bin_log_disk_usage
+func cloudWatchName(s string) string {
+	s = strings.ToLower(cloudWatchSnakeCase.ReplaceAllString(s, "${1}_${2}"))
+	return cloudWatchUnderline.ReplaceAllString(cloudWatchUnsafe.ReplaceAllString(s, "_"), "_")
+}
+
+// cloudWatchMetricName is the name of the ingested metric of the
+// statistic, aws_rds_cpuutilization_average for the Average of the
+// CPUUtilization of AWS/RDS
+func cloudWatchMetricName(namespace, metric, statistic string) string {
+	base := strings.ToLower(namespace) + "_" + cloudWatchName(metric)
+	base = cloudWatchUnderline.ReplaceAllString(cloudWatchUnsafe.ReplaceAllString(base, "_"), "_")
+	return base + "_" + cloudWatchName(statistic)
+}
+
+// ConvertCloudWatchAlarm returns the threshold rule equivalent to the
+// alarm, with the approximations made
+func ConvertCloudWatchAlarm(alarm CloudWatchAlarm) (*PostableRule, []string, error) {
+	if len(alarm.Metrics) > 0 || alarm.MetricName == "" {
+		return nil, nil, fmt.Errorf("metric math alarms are not supported")
+	}
+	statistic := alarm.Statistic
+	if alarm.ExtendedStatistic != "" {
+		statistic = alarm.ExtendedStatistic
+	}
+	if statistic == "" {
+		return nil, nil, fmt.Errorf("the alarm has no statistic")
+	}
+
+	var warnings []string
+	var op CompareOp
+	switch alarm.ComparisonOperator {
+	case "GreaterThanThreshold":
+		op = ValueIsAbove
+	case "GreaterThanOrEqualToThreshold":
+		op = ValueIsAbove
+		warnings = append(warnings, "greater than or equal to is converted to greater than")
+	case "LessThanThreshold":
+		op = ValueIsBelow
+	case "LessThanOrEqualToThreshold":
+		op = ValueIsBelow
+		warnings = append(warnings, "less than or equal to is converted to less than")
+	default:
+		return nil, nil, fmt.Errorf("comparison operator %q is not supported", alarm.ComparisonOperator)
+	}
+
+	period := alarm.Period
+	if period < 60 {
+		if period > 0 {
+			warnings = append(warnings, fmt.Sprintf("the period of %ds is raised to 60s", period))
+		}
+		period = 60
+	}
+	evaluations := alarm.EvaluationPeriods
+	if evaluations <= 0 {
+		evaluations = 1
+	}
+	matchType := AllTheTimes
+	switch datapoints := alarm.DatapointsToAlarm; {
+	case datapoints <= 0 || datapoints == evaluations:
+	case datapoints == 1:
+		matchType = AtleastOnce
+	default:
+		warnings = append(warnings, fmt.Sprintf("%d out of %d datapoints is converted to all the datapoints", datapoints, evaluations))
+	}
+	window := time.Duration(period*evaluations) * time.Second
+
+	metric := cloudWatchMetricName(alarm.Namespace, alarm.MetricName, statistic)
+	query := &v3.BuilderQuery{
+		QueryName:          "A",
+		StepInterval:       period,
+		DataSource:         v3.DataSourceMetrics,
+		AggregateOperator:  v3.AggregateOperatorAvg,
+		AggregateAttribute: v3.AttributeKey{Key: metric, DataType: v3.AttributeKeyDataTypeFloat64, IsColumn: true},
+		TimeAggregation:    v3.TimeAggregationAvg,
+		SpaceAggregation:   v3.SpaceAggregationAvg,
+		Filters:            &v3.FilterSet{Operator: "AND", Items: []v3.FilterItem{}},
+		Expression:         "A",
+	}
+	for _, d := range alarm.Dimensions {
+		key := v3.AttributeKey{Key: cloudWatchName(d.Name), DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag}
+		query.Filters.Items = append(query.Filters.Items, v3.FilterItem{Key: key, Value: d.Value, Operator: v3.FilterOperatorEqual})
+		query.GroupBy = append(query.GroupBy, key)
+	}
+
+	threshold := alarm.Threshold
+	condition := &RuleCondition{
+		CompositeQuery: &v3.CompositeQuery{
+			QueryType:      v3.QueryTypeBuilder,
+			PanelType:      v3.PanelTypeGraph,
+			BuilderQueries: map[string]*v3.BuilderQuery{"A": query},
+		},
+		CompareOp:     op,
+		Target:        &threshold,
+		MatchType:     matchType,
+		SelectedQuery: "A",
+	}
+	switch alarm.TreatMissingData {
+	case "breaching":
+		condition.AlertOnAbsent = true
+		condition.AbsentFor = uint64(window / time.Minute)
+	case "", "missing", "notBreaching", "ignore":
+	default:
+		warnings = append(warnings, fmt.Sprintf("missing data treated as %q is ignored", alarm.TreatMissingData))
+	}
+
+	description := alarm.AlarmDescription
+	if description == "" {
+		description = fmt.Sprintf("%s of %s %s is %s %v", statistic, alarm.Namespace, alarm.MetricName, ResolveCompareOp(op), alarm.Threshold)
+	}
+	return &PostableRule{
+		AlertName:      alarm.AlarmName,
+		AlertType:      AlertTypeMetric,
+		RuleType:       RuleTypeThreshold,
+		EvalWindow:     Duration(window),
+		Frequency:      Duration(time.Minute),
+		RuleCondition:  condition,
+		Labels:         map[string]string{"severity": "warning"},
+		Annotations:    map[string]string{"description": description, "summary": "The value of " + metric + " is {{$value}}, the threshold is {{$threshold}}"},
+		Version:        "v4",
+		EvalResolution: Duration(time.Duration(period) * time.Second),
+	}, warnings, nil
+}
+
+// fetchCloudWatchAlarms reads the metric alarms of the region
+func fetchCloudWatchAlarms(ctx context.Context, req *CloudWatchImportRequest) ([]CloudWatchAlarm, error) {
+	config := aws.NewConfig().WithRegion(req.Region)
+	if req.AccessKeyID != "" {
+		config = config.WithCredentials(credentials.NewStaticCredentials(req.AccessKeyID, req.SecretAccessKey, req.SessionToken))
+	}
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return nil, err
+	}
+	client := cloudwatch.New(sess)
+	if req.RoleARN != "" {
+		client = cloudwatch.New(sess, &aws.Config{Credentials: stscreds.NewCredentials(sess, req.RoleARN, func(p *stscreds.AssumeRoleProvider) {
+			if req.ExternalID != "" {
+				p.ExternalID = aws.String(req.ExternalID)
+			}
+		})})
+	}
+
+	input := &cloudwatch.DescribeAlarmsInput{AlarmTypes: []*string{aws.String(cloudwatch.AlarmTypeMetricAlarm)}}
+	if req.AlarmNamePrefix != "" {
+		input.AlarmNamePrefix = aws.String(req.AlarmNamePrefix)
+	}
+	var alarms []CloudWatchAlarm
+	err = client.DescribeAlarmsPagesWithContext(ctx, input, func(page *cloudwatch.DescribeAlarmsOutput, _ bool) bool {
+		for _, a := range page.MetricAlarms {
+			alarm := CloudWatchAlarm{
+				AlarmName:          aws.StringValue(a.AlarmName),
+				AlarmDescription:   aws.StringValue(a.AlarmDescription),
+				Namespace:          aws.StringValue(a.Namespace),
+				MetricName:         aws.StringValue(a.MetricName),
+				Statistic:          aws.StringValue(a.Statistic),
+				ExtendedStatistic:  aws.StringValue(a.ExtendedStatistic),
+				Period:             aws.Int64Value(a.Period),
+				EvaluationPeriods:  aws.Int64Value(a.EvaluationPeriods),
+				DatapointsToAlarm:  aws.Int64Value(a.DatapointsToAlarm),
+				Threshold:          aws.Float64Value(a.Threshold),
+				ComparisonOperator: aws.StringValue(a.ComparisonOperator),
+				TreatMissingData:   aws.StringValue(a.TreatMissingData),
+			}
+			for _, d := range a.Dimensions {
+				alarm.Dimensions = append(alarm.Dimensions, CloudWatchDimension{Name: aws.StringValue(d.Name), Value: aws.StringValue(d.Value)})
+			}
+			if len(a.Metrics) > 0 {
+				alarm.Metrics, _ = json.Marshal(a.Metrics)
+			}
+			alarms = append(alarms, alarm)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return alarms, nil
+}
+
+// ImportCloudWatchAlarms converts the cloudwatch alarms into threshold
+// rules over the metrics ingested by the aws integrations and creates them
+// unless it is a dry run
+func (m *Manager) ImportCloudWatchAlarms(ctx context.Context, req *CloudWatchImportRequest) ([]CloudWatchConversion, *model.ApiError) {
+	alarms := req.Alarms
+	if len(alarms) == 0 {
+		if req.Region == "" {
+			return nil, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("region is required to fetch the alarms")}
+		}
+		var err error
+		if alarms, err = fetchCloudWatchAlarms(ctx, req); err != nil {
+			return nil, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("failed to fetch the cloudwatch alarms: %w", err)}
+		}
+	}
+
+	conversions := make([]CloudWatchConversion, 0, len(alarms))
+	for _, alarm := range alarms {
+		c := CloudWatchConversion{AlarmName: alarm.AlarmName}
+		rule, warnings, err := ConvertCloudWatchAlarm(alarm)
+		c.Warnings = warnings
+		if err != nil {
+			c.Error = err.Error()
+			conversions = append(conversions, c)
+			continue
+		}
+		rule.Disabled = !req.Enable
+		rule.PreferredChannels = req.PreferredChannels
+		c.Rule = rule
+
+		if !req.DryRun {
+			data, err := json.Marshal(rule)
+			if err != nil {
+				c.Error = err.Error()
+			} else if created, err := m.CreateRule(ctx, string(data)); err != nil {
+				c.Error = err.Error()
+			} else {
+				c.RuleID = created.Id
+			}
+		}
+		conversions = append(conversions, c)
+	}
+	return conversions, nil
+}
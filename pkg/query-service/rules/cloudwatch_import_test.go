@@ -0,0 +1,67 @@
+package rules
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func TestCloudWatchMetricName(t *testing.T) {
+	assert.Equal(t, "aws_rds_cpuutilization_average", cloudWatchMetricName("AWS/RDS", "CPUUtilization", "Average"))
+	assert.Equal(t, "aws_rds_bin_log_disk_usage_sample_count", cloudWatchMetricName("AWS/RDS", "BinLogDiskUsage", "SampleCount"))
+	assert.Equal(t, "aws_elb_latency_p99_9", cloudWatchMetricName("AWS/ELB", "Latency", "p99.9"))
+	assert.Equal(t, "dbinstance_identifier", cloudWatchName("DBInstanceIdentifier"))
+}
+
+func TestConvertCloudWatchAlarm(t *testing.T) {
+	// the format of aws cloudwatch describe-alarms
+	var alarm CloudWatchAlarm
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"AlarmName": "rds-cpu-high",
+		"Namespace": "AWS/RDS",
+		"MetricName": "CPUUtilization",
+		"Statistic": "Average",
+		"Dimensions": [{"Name": "DBInstanceIdentifier", "Value": "orders"}],
+		"Period": 300,
+		"EvaluationPeriods": 3,
+		"DatapointsToAlarm": 2,
+		"Threshold": 80,
+		"ComparisonOperator": "GreaterThanOrEqualToThreshold",
+		"TreatMissingData": "breaching"
+	}`), &alarm))
+
+	rule, warnings, err := ConvertCloudWatchAlarm(alarm)
+	require.NoError(t, err)
+	assert.Len(t, warnings, 2)
+	assert.Equal(t, "rds-cpu-high", rule.AlertName)
+	assert.Equal(t, Duration(15*time.Minute), rule.EvalWindow)
+	assert.Equal(t, ValueIsAbove, rule.RuleCondition.CompareOp)
+	assert.Equal(t, AllTheTimes, rule.RuleCondition.MatchType)
+	assert.Equal(t, 80.0, *rule.RuleCondition.Target)
+	assert.True(t, rule.RuleCondition.AlertOnAbsent)
+	assert.Equal(t, uint64(15), rule.RuleCondition.AbsentFor)
+
+	query := rule.RuleCondition.CompositeQuery.BuilderQueries["A"]
+	assert.Equal(t, "aws_rds_cpuutilization_average", query.AggregateAttribute.Key)
+	assert.Equal(t, int64(300), query.StepInterval)
+	require.Len(t, query.Filters.Items, 1)
+	assert.Equal(t, "dbinstance_identifier", query.Filters.Items[0].Key.Key)
+	assert.Equal(t, "orders", query.Filters.Items[0].Value)
+	assert.Equal(t, v3.FilterOperatorEqual, query.Filters.Items[0].Operator)
+
+	// the converted rule is valid
+	data, err := json.Marshal(rule)
+	require.NoError(t, err)
+	_, err = ParsePostableRule(data)
+	require.NoError(t, err)
+
+	_, _, err = ConvertCloudWatchAlarm(CloudWatchAlarm{AlarmName: "math", Metrics: json.RawMessage(`[{"Id":"m1"}]`)})
+	assert.Error(t, err)
+	alarm.ComparisonOperator = "LessThanLowerOrGreaterThanUpperThreshold"
+	_, _, err = ConvertCloudWatchAlarm(alarm)
+	assert.Error(t, err)
+}
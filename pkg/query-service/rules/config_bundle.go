@@ -0,0 +1,461 @@
+package rules
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	am "go.signoz.io/signoz/pkg/query-service/integrations/alertManager"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.uber.org/zap"
+)
+
+// ConfigBundleVersion is the version of the alerting config bundles, the
+// bundles of later versions are refused on import
+const ConfigBundleVersion = 1
+
+const (
+	ConflictFail      = "fail"
+	ConflictSkip      = "skip"
+	ConflictOverwrite = "overwrite"
+	ConflictRename    = "rename"
+)
+
+const (
+	ImportCreated = "created"
+	ImportUpdated = "updated"
+	ImportRenamed = "renamed"
+	ImportSkipped = "skipped"
+)
+
+// ConfigBundle is the whole alerting config of an environment. The objects
+// are matched by name on import, as their ids differ between environments.
+// The routes of the alerts are the preferred channels of the rules and the
+// silences are the fixed maintenances, so they are part of the bundle.
+type ConfigBundle struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exportedAt"`
+
+	Templates    []TemplateSnippet   `json:"templates"`
+	Channels     []am.Receiver       `json:"channels"`
+	Rules        []PostableRule      `json:"rules"`
+	Maintenances []BundleMaintenance `json:"maintenances"`
+}
+
+// BundleMaintenance is a maintenance of the bundle, it names the rules it
+// silences instead of their ids
+type BundleMaintenance struct {
+	PlannedMaintenance
+	Rules []string `json:"rules,omitempty"`
+}
+
+// ConfigImportRequest imports a bundle, Conflict is what is done with the
+// objects whose name is taken, the import fails when it is not set
+type ConfigImportRequest struct {
+	Bundle   ConfigBundle `json:"bundle"`
+	Conflict string       `json:"conflict"`
+	// DryRun returns what the import would do without changing anything
+	DryRun bool `json:"dryRun"`
+}
+
+func (r *ConfigImportRequest) Validate() error {
+	if r.Bundle.Version == 0 || r.Bundle.Version > ConfigBundleVersion {
+		return fmt.Errorf("unsupported bundle version %d, expected at most %d", r.Bundle.Version, ConfigBundleVersion)
+	}
+	switch r.Conflict {
+	case "":
+		r.Conflict = ConflictFail
+	case ConflictFail, ConflictSkip, ConflictOverwrite, ConflictRename:
+	default:
+		return fmt.Errorf("unknown conflict resolution %q, expected %s, %s, %s or %s", r.Conflict, ConflictFail, ConflictSkip, ConflictOverwrite, ConflictRename)
+	}
+	return nil
+}
+
+// ConfigImportResult is what the import did with an object of the bundle
+type ConfigImportResult struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Action string `json:"action,omitempty"`
+	// ImportedAs is the name of the renamed object
+	ImportedAs string `json:"importedAs,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// ExportConfig returns the alerting config visible to the org of the
+// request. The secrets of the channels are redacted unless withSecrets.
+func (m *Manager) ExportConfig(ctx context.Context, withSecrets bool) (*ConfigBundle, *model.ApiError) {
+	bundle := &ConfigBundle{Version: ConfigBundleVersion, ExportedAt: time.Now().UTC()}
+
+	snippets, err := m.ruleDB.GetTemplateSnippets(ctx)
+	if err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorInternal, Err: err}
+	}
+	for _, s := range snippets {
+		bundle.Templates = append(bundle.Templates, TemplateSnippet{Name: s.Name, Description: s.Description, Content: s.Content})
+	}
+
+	channels, apiErr := m.ownChannels(ctx)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	for _, c := range channels {
+		data := []byte(c.Data)
+		if !withSecrets {
+			if data, err = am.RedactSecrets(data); err != nil {
+				return nil, &model.ApiError{Typ: model.ErrorInternal, Err: err}
+			}
+		}
+		receiver := am.Receiver{}
+		if err := json.Unmarshal(data, &receiver); err != nil {
+			return nil, &model.ApiError{Typ: model.ErrorInternal, Err: fmt.Errorf("invalid channel %s: %w", c.Name, err)}
+		}
+		bundle.Channels = append(bundle.Channels, receiver)
+	}
+
+	storedRules, err := m.ruleDB.GetStoredRules(ctx)
+	if err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorInternal, Err: err}
+	}
+	org := tenantOf(ctx)
+	ruleNames := map[string]string{}
+	for _, rec := range storedRules {
+		if !visibleTo(rec.OrgId, org) {
+			continue
+		}
+		rule := PostableRule{}
+		if err := json.Unmarshal([]byte(rec.Data), &rule); err != nil {
+			zap.L().Error("failed to parse the stored rule for the export", zap.Int("id", rec.Id), zap.Error(err))
+			continue
+		}
+		ruleNames[strconv.Itoa(rec.Id)] = rule.AlertName
+		bundle.Rules = append(bundle.Rules, rule)
+	}
+
+	maintenances, err := m.ruleDB.GetAllPlannedMaintenance(ctx)
+	if err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorInternal, Err: err}
+	}
+	for _, mw := range maintenances {
+		bm := BundleMaintenance{PlannedMaintenance: PlannedMaintenance{Name: mw.Name, Description: mw.Description, Schedule: mw.Schedule}}
+		if mw.AlertIds != nil {
+			for _, id := range *mw.AlertIds {
+				if name, ok := ruleNames[id]; ok {
+					bm.Rules = append(bm.Rules, name)
+				}
+			}
+		}
+		bundle.Maintenances = append(bundle.Maintenances, bm)
+	}
+	return bundle, nil
+}
+
+// ownChannels returns the channels the org of the request can change
+func (m *Manager) ownChannels(ctx context.Context) ([]model.ChannelItem, *model.ApiError) {
+	if m.reader == nil {
+		return nil, nil
+	}
+	channels, apiErr := m.reader.GetChannels()
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	org := tenantOf(ctx)
+	own := []model.ChannelItem{}
+	for _, c := range *channels {
+		if org == "" || c.OwnedBy(org) {
+			own = append(own, c)
+		}
+	}
+	return own, nil
+}
+
+// configImport resolves the names of the objects of a kind against the
+// existing ones
+type configImport struct {
+	req     *ConfigImportRequest
+	kind    string
+	taken   map[string]string
+	results []*ConfigImportResult
+}
+
+// resolve returns the result of importing the object of the name, with
+// the id of the object to overwrite if any
+func (c *configImport) resolve(name string) (*ConfigImportResult, string) {
+	res := &ConfigImportResult{Kind: c.kind, Name: name, Action: ImportCreated}
+	c.results = append(c.results, res)
+	id, ok := c.taken[name]
+	if !ok {
+		c.taken[name] = ""
+		return res, ""
+	}
+	switch c.req.Conflict {
+	case ConflictSkip:
+		res.Action = ImportSkipped
+	case ConflictOverwrite:
+		res.Action = ImportUpdated
+		return res, id
+	case ConflictRename:
+		res.Action = ImportRenamed
+		for i := 2; ; i++ {
+			renamed := fmt.Sprintf("%s-%d", name, i)
+			if _, ok := c.taken[renamed]; !ok {
+				res.ImportedAs = renamed
+				c.taken[renamed] = ""
+				break
+			}
+		}
+	default:
+		res.Action = ""
+		res.Error = fmt.Sprintf("%s %s already exists", c.kind, name)
+	}
+	return res, ""
+}
+
+// importedName is the name the object is imported with
+func (r *ConfigImportResult) importedName() string {
+	if r.ImportedAs != "" {
+		return r.ImportedAs
+	}
+	return r.Name
+}
+
+func (r *ConfigImportResult) written() bool {
+	return r.Error == "" && (r.Action == ImportCreated || r.Action == ImportUpdated || r.Action == ImportRenamed)
+}
+
+// ImportConfig imports the bundle into the org of the request, the
+// templates, channels, rules and maintenances are imported in that order
+// so that the later ones find the earlier. Nothing is imported when a
+// name is taken and the conflicts fail the import.
+func (m *Manager) ImportConfig(ctx context.Context, req *ConfigImportRequest) ([]ConfigImportResult, *model.ApiError) {
+	if err := req.Validate(); err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorBadData, Err: err}
+	}
+	bundle := &req.Bundle
+
+	snippets, err := m.ruleDB.GetTemplateSnippets(ctx)
+	if err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorInternal, Err: err}
+	}
+	templates := &configImport{req: req, kind: "template", taken: map[string]string{}}
+	for _, s := range snippets {
+		templates.taken[s.Name] = strconv.FormatInt(s.Id, 10)
+	}
+
+	existingChannels, apiErr := m.ownChannels(ctx)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	channels := &configImport{req: req, kind: "channel", taken: map[string]string{}}
+	for _, c := range existingChannels {
+		channels.taken[c.Name] = strconv.Itoa(c.Id)
+	}
+
+	storedRules, err := m.ruleDB.GetStoredRules(ctx)
+	if err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorInternal, Err: err}
+	}
+	rules := &configImport{req: req, kind: "rule", taken: map[string]string{}}
+	org := tenantOf(ctx)
+	for _, rec := range storedRules {
+		rule := PostableRule{}
+		if visibleTo(rec.OrgId, org) && json.Unmarshal([]byte(rec.Data), &rule) == nil {
+			rules.taken[rule.AlertName] = strconv.Itoa(rec.Id)
+		}
+	}
+
+	existingMaintenances, err := m.ruleDB.GetAllPlannedMaintenance(ctx)
+	if err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorInternal, Err: err}
+	}
+	maintenances := &configImport{req: req, kind: "maintenance", taken: map[string]string{}}
+	for _, mw := range existingMaintenances {
+		maintenances.taken[mw.Name] = strconv.FormatInt(mw.Id, 10)
+	}
+
+	type planned struct {
+		res *ConfigImportResult
+		id  string
+	}
+	plan := func(c *configImport, name string) planned {
+		res, id := c.resolve(name)
+		return planned{res: res, id: id}
+	}
+	var plannedTemplates, plannedChannels, plannedRules, plannedMaintenances []planned
+	for _, s := range bundle.Templates {
+		plannedTemplates = append(plannedTemplates, plan(templates, s.Name))
+	}
+	for _, c := range bundle.Channels {
+		plannedChannels = append(plannedChannels, plan(channels, c.Name))
+	}
+	for _, r := range bundle.Rules {
+		plannedRules = append(plannedRules, plan(rules, r.AlertName))
+	}
+	for _, mw := range bundle.Maintenances {
+		plannedMaintenances = append(plannedMaintenances, plan(maintenances, mw.Name))
+	}
+
+	// the imported rules keep their channels and the maintenances their
+	// rules when they are renamed
+	channelNames := map[string]string{}
+	for i, c := range bundle.Channels {
+		channelNames[c.Name] = plannedChannels[i].res.importedName()
+	}
+	ruleNames := map[string]string{}
+	for i, rule := range bundle.Rules {
+		ruleNames[rule.AlertName] = plannedRules[i].res.importedName()
+	}
+	for i, mw := range bundle.Maintenances {
+		for _, name := range mw.Rules {
+			if _, ok := ruleNames[name]; !ok && rules.taken[name] == "" {
+				plannedMaintenances[i].res.Error = fmt.Sprintf("rule %s not found", name)
+			}
+		}
+	}
+
+	for _, c := range []*configImport{templates, channels, rules, maintenances} {
+		for _, res := range c.results {
+			if res.Error != "" && req.Conflict == ConflictFail {
+				return importResults(templates, channels, rules, maintenances), &model.ApiError{Typ: model.ErrorConflict, Err: fmt.Errorf("the bundle can not be imported as is, pick how the conflicts are resolved")}
+			}
+		}
+	}
+
+	if req.DryRun {
+		return importResults(templates, channels, rules, maintenances), nil
+	}
+
+	for i, s := range bundle.Templates {
+		p := plannedTemplates[i]
+		if !p.res.written() {
+			continue
+		}
+		s.Name = p.res.importedName()
+		if err := s.Validate(); err != nil {
+			p.res.Error = err.Error()
+			continue
+		}
+		if p.id != "" {
+			err = m.ruleDB.EditTemplateSnippet(ctx, s, p.id)
+		} else {
+			_, err = m.ruleDB.CreateTemplateSnippet(ctx, s)
+		}
+		if err != nil {
+			p.res.Error = err.Error()
+		}
+	}
+	if len(bundle.Templates) > 0 {
+		if err := m.ReloadTemplateSnippets(ctx); err != nil {
+			zap.L().Error("failed to reload the template snippets after the import", zap.Error(err))
+		}
+	}
+
+	for i, receiver := range bundle.Channels {
+		p := plannedChannels[i]
+		if !p.res.written() {
+			continue
+		}
+		if m.reader == nil {
+			p.res.Error = "channels are not available"
+			continue
+		}
+		receiver.Name = p.res.importedName()
+		var apiErr *model.ApiError
+		if p.id != "" {
+			// the redacted secrets are kept from the overwritten channel
+			_, apiErr = m.reader.EditChannel(&receiver, p.id)
+		} else if redacted(receiver) {
+			p.res.Error = "the secrets of the channel are redacted, export it with its secrets or create it first"
+			continue
+		} else {
+			_, apiErr = m.reader.CreateChannel(&receiver, org)
+		}
+		if apiErr != nil {
+			p.res.Error = apiErr.Error()
+		}
+	}
+
+	for i, rule := range bundle.Rules {
+		p := plannedRules[i]
+		if !p.res.written() {
+			continue
+		}
+		rule.AlertName = p.res.importedName()
+		rule.PreferredChannels = nil
+		for _, name := range bundle.Rules[i].PreferredChannels {
+			if renamed, ok := channelNames[name]; ok {
+				name = renamed
+			}
+			rule.PreferredChannels = append(rule.PreferredChannels, name)
+		}
+		data, err := json.Marshal(rule)
+		if err != nil {
+			p.res.Error = err.Error()
+			continue
+		}
+		if p.id != "" {
+			err = m.EditRule(ctx, string(data), p.id)
+		} else {
+			var created *GettableRule
+			if created, err = m.CreateRule(ctx, string(data)); err == nil {
+				rules.taken[rule.AlertName] = created.Id
+			}
+		}
+		if err != nil {
+			p.res.Error = err.Error()
+		}
+	}
+
+	for i, bm := range bundle.Maintenances {
+		p := plannedMaintenances[i]
+		if !p.res.written() {
+			continue
+		}
+		// the ids of the rules created by the import are known now
+		ids := AlertIds{}
+		for _, name := range bm.Rules {
+			if renamed, ok := ruleNames[name]; ok {
+				name = renamed
+			}
+			if id := rules.taken[name]; id != "" {
+				ids = append(ids, id)
+			}
+		}
+		mw := bm.PlannedMaintenance
+		mw.Name = p.res.importedName()
+		mw.AlertIds = &ids
+		if err := mw.Validate(); err != nil {
+			p.res.Error = err.Error()
+			continue
+		}
+		if p.id != "" {
+			_, err = m.ruleDB.EditPlannedMaintenance(ctx, mw, p.id)
+		} else {
+			_, err = m.ruleDB.CreatePlannedMaintenance(ctx, mw)
+		}
+		if err != nil {
+			p.res.Error = err.Error()
+		}
+	}
+
+	return importResults(templates, channels, rules, maintenances), nil
+}
+
+func importResults(imports ...*configImport) []ConfigImportResult {
+	results := []ConfigImportResult{}
+	for _, c := range imports {
+		for _, res := range c.results {
+			results = append(results, *res)
+		}
+	}
+	return results
+}
+
+// redacted tells whether the secrets of the channel were redacted on export
+func redacted(receiver am.Receiver) bool {
+	data, err := json.Marshal(receiver)
+	return err == nil && bytes.Contains(data, []byte(strconv.Quote(am.SecretRedacted)))
+}
@@ -0,0 +1,104 @@
+package rules
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+// bundleDB keeps the rules, snippets and maintenances in memory
+type bundleDB struct {
+	RuleDB
+	rules        []StoredRule
+	snippets     []TemplateSnippet
+	maintenances []PlannedMaintenance
+}
+
+func (db *bundleDB) GetStoredRules(context.Context) ([]StoredRule, error) {
+	return db.rules, nil
+}
+
+func (db *bundleDB) GetTemplateSnippets(context.Context) ([]TemplateSnippet, error) {
+	return db.snippets, nil
+}
+
+func (db *bundleDB) CreateTemplateSnippet(_ context.Context, s TemplateSnippet) (int64, error) {
+	s.Id = int64(len(db.snippets) + 1)
+	db.snippets = append(db.snippets, s)
+	return s.Id, nil
+}
+
+func (db *bundleDB) EditTemplateSnippet(_ context.Context, s TemplateSnippet, id string) error {
+	i, _ := strconv.Atoi(id)
+	s.Id = int64(i)
+	db.snippets[i-1] = s
+	return nil
+}
+
+func (db *bundleDB) GetAllPlannedMaintenance(context.Context) ([]PlannedMaintenance, error) {
+	return db.maintenances, nil
+}
+
+func (db *bundleDB) CreatePlannedMaintenance(_ context.Context, mw PlannedMaintenance) (int64, error) {
+	mw.Id = int64(len(db.maintenances) + 1)
+	db.maintenances = append(db.maintenances, mw)
+	return mw.Id, nil
+}
+
+func TestConfigBundle(t *testing.T) {
+	db := &bundleDB{
+		rules:        []StoredRule{{Id: 7, Data: `{"alert":"high latency","preferredChannels":["oncall"]}`}},
+		snippets:     []TemplateSnippet{{Id: 1, Name: "footer", Content: "runbook"}},
+		maintenances: []PlannedMaintenance{{Id: 1, Name: "release", Schedule: &Schedule{Timezone: "UTC"}, AlertIds: &AlertIds{"7"}}},
+	}
+	m := &Manager{ruleDB: db, opts: &ManagerOptions{Snippets: NewSnippetCache()}}
+	ctx := context.Background()
+
+	bundle, apiErr := m.ExportConfig(ctx, false)
+	require.Nil(t, apiErr)
+	assert.Equal(t, ConfigBundleVersion, bundle.Version)
+	require.Len(t, bundle.Rules, 1)
+	assert.Equal(t, []string{"oncall"}, bundle.Rules[0].PreferredChannels)
+	// the maintenances name their rules, the ids differ between environments
+	require.Len(t, bundle.Maintenances, 1)
+	assert.Equal(t, []string{"high latency"}, bundle.Maintenances[0].Rules)
+	assert.Nil(t, bundle.Maintenances[0].AlertIds)
+
+	// the conflicts fail the import unless they are resolved
+	_, apiErr = m.ImportConfig(ctx, &ConfigImportRequest{Bundle: *bundle})
+	require.NotNil(t, apiErr)
+	assert.Equal(t, model.ErrorConflict, apiErr.Typ)
+
+	bundle.Templates[0].Content = "see the runbook"
+	results, apiErr := m.ImportConfig(ctx, &ConfigImportRequest{Bundle: *bundle, Conflict: ConflictOverwrite, DryRun: true})
+	require.Nil(t, apiErr)
+	assert.Equal(t, ImportUpdated, results[0].Action)
+	assert.Equal(t, "runbook", db.snippets[0].Content)
+
+	// the renamed maintenances keep silencing their rules
+	bundle.Rules = nil
+	results, apiErr = m.ImportConfig(ctx, &ConfigImportRequest{Bundle: *bundle, Conflict: ConflictRename})
+	require.Nil(t, apiErr)
+	assert.Equal(t, []ConfigImportResult{
+		{Kind: "template", Name: "footer", Action: ImportRenamed, ImportedAs: "footer-2"},
+		{Kind: "maintenance", Name: "release", Action: ImportRenamed, ImportedAs: "release-2"},
+	}, results)
+	require.Len(t, db.maintenances, 2)
+	assert.Equal(t, "release-2", db.maintenances[1].Name)
+	assert.Equal(t, AlertIds{"7"}, *db.maintenances[1].AlertIds)
+	assert.Len(t, db.snippets, 2)
+
+	// the maintenances of unknown rules are not imported
+	bundle.Maintenances[0].Rules = []string{"gone"}
+	results, apiErr = m.ImportConfig(ctx, &ConfigImportRequest{Bundle: *bundle, Conflict: ConflictSkip})
+	require.Nil(t, apiErr)
+	assert.Equal(t, "rule gone not found", results[1].Error)
+	assert.Len(t, db.maintenances, 2)
+
+	_, apiErr = m.ImportConfig(ctx, &ConfigImportRequest{Bundle: ConfigBundle{Version: ConfigBundleVersion + 1}})
+	assert.Equal(t, model.ErrorBadData, apiErr.Typ)
+}
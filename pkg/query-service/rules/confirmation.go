@@ -0,0 +1,104 @@
+package rules
+
+import (
+	"context"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.uber.org/zap"
+)
+
+// splitConfirmation splits the confirmation query out of the queries of
+// the rule, it returns the params of the other queries and the params of
+// the confirmation query, nil when the rule has no confirmation
+func (r *ThresholdRule) splitConfirmation(params *v3.QueryRangeParamsV3) (*v3.QueryRangeParamsV3, *v3.QueryRangeParamsV3) {
+	c := r.ruleCondition.Confirmation
+	if c == nil {
+		return params, nil
+	}
+
+	cq := params.CompositeQuery
+	main, confirmation := *cq, *cq
+	main.BuilderQueries = make(map[string]*v3.BuilderQuery, len(cq.BuilderQueries))
+	main.ClickHouseQueries = make(map[string]*v3.ClickHouseQuery, len(cq.ClickHouseQueries))
+	confirmation.BuilderQueries = map[string]*v3.BuilderQuery{}
+	confirmation.ClickHouseQueries = map[string]*v3.ClickHouseQuery{}
+	for name, q := range cq.BuilderQueries {
+		if name == c.QueryName {
+			confirmation.BuilderQueries[name] = q
+		} else {
+			main.BuilderQueries[name] = q
+		}
+	}
+	for name, q := range cq.ClickHouseQueries {
+		if name == c.QueryName {
+			confirmation.ClickHouseQueries[name] = q
+		} else {
+			main.ClickHouseQueries[name] = q
+		}
+	}
+
+	mainParams, confirmParams := *params, *params
+	mainParams.CompositeQuery = &main
+	confirmParams.CompositeQuery = &confirmation
+	return &mainParams, &confirmParams
+}
+
+// confirm runs the confirmation query when samples breach and keeps the
+// samples with a series of the confirmation query that matches the
+// threshold of the confirmation. A series of the confirmation confirms
+// the samples having all its labels, so that the confirmation can group
+// by fewer keys than the selected query.
+func (r *ThresholdRule) confirm(ctx context.Context, vector Vector, params *v3.QueryRangeParamsV3, ch clickhouse.Conn, cache *QueryCache) (Vector, error) {
+	if params == nil || len(vector) == 0 {
+		return vector, nil
+	}
+	c := *r.ruleCondition.Confirmation
+
+	results, err := r.queryResults(ctx, params, ch, cache)
+	if err != nil {
+		r.SetHealth(HealthBad)
+		return nil, err
+	}
+	cond, _ := r.queryCondition(c)
+	var confirmed []map[string]string
+	for _, res := range results {
+		if res.QueryName != c.QueryName {
+			continue
+		}
+		for _, series := range res.Series {
+			if _, ok := shouldAlertWith(*series, cond); ok {
+				confirmed = append(confirmed, series.Labels)
+			}
+		}
+	}
+
+	kept := vector[:0]
+	for _, smpl := range vector {
+		if smpl.IsMissing || confirms(confirmed, smpl) {
+			kept = append(kept, smpl)
+		}
+	}
+	if dropped := len(vector) - len(kept); dropped > 0 {
+		zap.L().Debug("breaches not confirmed", zap.String("ruleid", r.ID()), zap.Int("count", dropped))
+	}
+	return kept, nil
+}
+
+// confirms tells whether one of the confirmed series has all its labels
+// in the labels of the sample
+func confirms(confirmed []map[string]string, smpl Sample) bool {
+	for _, lbls := range confirmed {
+		match := true
+		for name, value := range lbls {
+			if smpl.MetricOrig.Get(name) != value {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+func TestThresholdRuleConfirmation(t *testing.T) {
+	rule, err := ParsePostableRule([]byte(`{
+		"alert": "error rate",
+		"ruleType": "threshold_rule",
+		"condition": {
+			"compositeQuery": {
+				"queryType": "builder",
+				"panelType": "graph",
+				"builderQueries": {
+					"A": {"queryName": "A", "dataSource": "traces", "aggregateOperator": "count", "stepInterval": 60},
+					"B": {"queryName": "B", "dataSource": "traces", "aggregateOperator": "count", "stepInterval": 60},
+					"Z": {"queryName": "Z", "dataSource": "traces", "aggregateOperator": "rate", "stepInterval": 60}
+				}
+			},
+			"confirmation": {"queryName": "Z", "target": 1, "op": "1", "matchType": "3"},
+			"op": "1",
+			"target": 5,
+			"matchType": "1"
+		}
+	}`))
+	require.NoError(t, err)
+	require.NoError(t, rule.Validate())
+
+	tr, err := NewThresholdRule("1", rule, ThresholdRuleOpts{}, nil, nil)
+	require.NoError(t, err)
+	// the confirmation is never the default selected query
+	assert.Equal(t, "B", tr.GetSelectedQuery())
+
+	params, confirmParams := tr.splitConfirmation(tr.prepareQueryRange(time.Unix(1717205940, 0)))
+	assert.Len(t, params.CompositeQuery.BuilderQueries, 2)
+	assert.NotContains(t, params.CompositeQuery.BuilderQueries, "Z")
+	assert.Len(t, confirmParams.CompositeQuery.BuilderQueries, 1)
+	assert.Contains(t, confirmParams.CompositeQuery.BuilderQueries, "Z")
+	assert.Len(t, rule.RuleCondition.CompositeQuery.BuilderQueries, 3)
+
+	confirmed := []map[string]string{{"service": "cart"}}
+	assert.True(t, confirms(confirmed, Sample{MetricOrig: labels.FromStrings("service", "cart", "route", "/pay")}))
+	assert.False(t, confirms(confirmed, Sample{MetricOrig: labels.FromStrings("service", "web")}))
+	assert.True(t, confirms([]map[string]string{{}}, Sample{MetricOrig: labels.FromStrings("service", "web")}))
+	assert.False(t, confirms(nil, Sample{}))
+
+	rule.RuleCondition.CompositeQuery.BuilderQueries["F1"] = &v3.BuilderQuery{QueryName: "F1", Expression: "A/Z"}
+	assert.Error(t, rule.Validate())
+}
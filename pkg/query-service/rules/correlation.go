@@ -0,0 +1,325 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	am "go.signoz.io/signoz/pkg/query-service/integrations/alertManager"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultCorrelationWindow is how far apart two alerts can start to be
+	// related by time
+	DefaultCorrelationWindow = 15 * time.Minute
+	// DefaultCorrelationLookback is how long before an alert started the
+	// changes made are related to it
+	DefaultCorrelationLookback = time.Hour
+
+	maxRelatedAlerts  = 10
+	maxRelatedChanges = 10
+	// maxAnnotatedRelated is the number of related alerts and changes
+	// listed in the annotation of a notification
+	maxAnnotatedRelated = 3
+	// possiblyRelatedAnnotation lists the related alerts and changes in
+	// the notifications
+	possiblyRelatedAnnotation = "possibly_related"
+	changeCacheTTL            = 30 * time.Second
+)
+
+// CorrelationOptions configures the suggestions of the alerts and
+// changes related to a firing alert
+type CorrelationOptions struct {
+	// Window is how far apart two alerts can start to be related by time,
+	// DefaultCorrelationWindow when not set
+	Window time.Duration
+	// Lookback is how long before an alert started the changes made are
+	// related to it, DefaultCorrelationLookback when not set
+	Lookback time.Duration
+	// Annotate lists the related alerts and changes in the notifications
+	// of the firing alerts
+	Annotate bool
+}
+
+// RelatedAlert is an active alert related to the alert, by the labels
+// they share or by starting within the window of it
+type RelatedAlert struct {
+	RuleID       string            `json:"ruleId"`
+	RuleName     string            `json:"ruleName"`
+	Fingerprint  uint64            `json:"fingerprint"`
+	Labels       map[string]string `json:"labels"`
+	State        string            `json:"state"`
+	ActiveAt     time.Time         `json:"activeAt"`
+	SharedLabels map[string]string `json:"sharedLabels"`
+	Score        float64           `json:"score"`
+}
+
+// RelatedChange is a change made around the start of the alert to what
+// the alert is about
+type RelatedChange struct {
+	ChangeEvent
+	SharedLabels map[string]string `json:"sharedLabels"`
+	Score        float64           `json:"score"`
+}
+
+// AlertCorrelation is the alert with the alerts and changes possibly
+// related to it, the most related first
+type AlertCorrelation struct {
+	Alert   RelatedAlert    `json:"alert"`
+	Alerts  []RelatedAlert  `json:"alerts"`
+	Changes []RelatedChange `json:"changes"`
+}
+
+// proximity is 1 for two times at the same instant down to 0 for two
+// times window apart or more
+func proximity(a, b time.Time, window time.Duration) float64 {
+	if window <= 0 {
+		return 0
+	}
+	d := math.Abs(float64(a.Sub(b)))
+	if d >= float64(window) {
+		return 0
+	}
+	return 1 - d/float64(window)
+}
+
+// correlate scores the alerts and the changes by how related they are to
+// the alert. Every shared label scores a point and the time proximity up
+// to one more. The alerts and changes sharing no labels are related only
+// when close in time, the changes to no labels in particular when made
+// within the lookback.
+func correlate(alert RelatedAlert, alerts []RelatedAlert, changes []ChangeEvent, opts CorrelationOptions) *AlertCorrelation {
+	lbls := correlationLabels(alert.Labels)
+	res := &AlertCorrelation{Alert: alert, Alerts: []RelatedAlert{}, Changes: []RelatedChange{}}
+
+	for _, a := range alerts {
+		if a.RuleID == alert.RuleID && a.Fingerprint == alert.Fingerprint {
+			continue
+		}
+		shared := sharedLabels(lbls, a.Labels)
+		near := proximity(alert.ActiveAt, a.ActiveAt, opts.Window)
+		if len(shared) == 0 && near == 0 {
+			continue
+		}
+		a.SharedLabels = shared
+		a.Score = float64(len(shared)) + near
+		res.Alerts = append(res.Alerts, a)
+	}
+	sort.SliceStable(res.Alerts, func(i, j int) bool {
+		if res.Alerts[i].Score != res.Alerts[j].Score {
+			return res.Alerts[i].Score > res.Alerts[j].Score
+		}
+		return res.Alerts[i].ActiveAt.Before(res.Alerts[j].ActiveAt)
+	})
+	if len(res.Alerts) > maxRelatedAlerts {
+		res.Alerts = res.Alerts[:maxRelatedAlerts]
+	}
+
+	for _, c := range changes {
+		if c.Timestamp.Before(alert.ActiveAt.Add(-opts.Lookback)) || c.Timestamp.After(alert.ActiveAt.Add(opts.Window)) {
+			continue
+		}
+		shared := sharedLabels(lbls, c.Labels)
+		if len(shared) == 0 && len(c.Labels) > 0 {
+			continue
+		}
+		res.Changes = append(res.Changes, RelatedChange{
+			ChangeEvent:  c,
+			SharedLabels: shared,
+			Score:        float64(len(shared)) + proximity(alert.ActiveAt, c.Timestamp, opts.Lookback),
+		})
+	}
+	sort.SliceStable(res.Changes, func(i, j int) bool {
+		if res.Changes[i].Score != res.Changes[j].Score {
+			return res.Changes[i].Score > res.Changes[j].Score
+		}
+		return res.Changes[i].Timestamp.After(res.Changes[j].Timestamp)
+	})
+	if len(res.Changes) > maxRelatedChanges {
+		res.Changes = res.Changes[:maxRelatedChanges]
+	}
+	return res
+}
+
+// possiblyRelated describes the most related alerts and changes in one
+// line, empty when there are none
+func possiblyRelated(c *AlertCorrelation) string {
+	var items []string
+	for i, a := range c.Alerts {
+		if i == maxAnnotatedRelated {
+			break
+		}
+		items = append(items, fmt.Sprintf("alert %s%s", a.RuleName, formatLabels(a.SharedLabels)))
+	}
+	for i, ch := range c.Changes {
+		if i == maxAnnotatedRelated {
+			break
+		}
+		items = append(items, fmt.Sprintf("%s %s at %s%s", ch.Kind, ch.Title, ch.Timestamp.UTC().Format(time.RFC3339), formatLabels(ch.SharedLabels)))
+	}
+	return strings.Join(items, "; ")
+}
+
+func formatLabels(lbls map[string]string) string {
+	if len(lbls) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(lbls))
+	for k, v := range lbls {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return " (" + strings.Join(pairs, ", ") + ")"
+}
+
+// changeCache keeps the recent changes for the notifications, not to
+// query the rule db every time alerts are sent
+type changeCache struct {
+	db       RuleDB
+	lookback time.Duration
+
+	mtx     sync.Mutex
+	fetched time.Time
+	changes []ChangeEvent
+}
+
+func newChangeCache(db RuleDB, lookback time.Duration) *changeCache {
+	return &changeCache{db: db, lookback: lookback}
+}
+
+// recent returns the changes made within the lookback and the window
+func (c *changeCache) recent(ctx context.Context, window time.Duration, now time.Time) []ChangeEvent {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if now.Sub(c.fetched) < changeCacheTTL {
+		return c.changes
+	}
+	changes, err := c.db.GetChangeEvents(ctx, now.Add(-c.lookback-window), now.Add(window))
+	if err != nil {
+		zap.L().Error("failed to get the recent changes", zap.Error(err))
+		return c.changes
+	}
+	c.changes, c.fetched = changes, now
+	return c.changes
+}
+
+func (c *changeCache) invalidate() {
+	if c == nil {
+		return
+	}
+	c.mtx.Lock()
+	c.fetched = time.Time{}
+	c.mtx.Unlock()
+}
+
+// relatedAlert returns the alert as a related alert
+func relatedAlert(rule Rule, a *Alert) RelatedAlert {
+	return RelatedAlert{
+		RuleID:      rule.ID(),
+		RuleName:    rule.Name(),
+		Fingerprint: a.Labels.Hash(),
+		Labels:      a.Labels.Map(),
+		State:       a.State.String(),
+		ActiveAt:    a.ActiveAt,
+	}
+}
+
+// activeAlerts returns the pending and firing alerts of the rules by the
+// org of their rule, the alerts are only related to the alerts of their
+// org. The caller holds the lock of the manager.
+func (m *Manager) activeAlerts() map[string][]RelatedAlert {
+	alerts := map[string][]RelatedAlert{}
+	for _, rule := range m.rules {
+		org := m.opts.tenants.of(rule.ID())
+		for _, a := range rule.ActiveAlerts() {
+			if a.State == StateInactive {
+				continue
+			}
+			alerts[org] = append(alerts[org], relatedAlert(rule, a))
+		}
+	}
+	return alerts
+}
+
+// RelatedToAlert returns the active alerts and the recent changes
+// possibly related to the active alert of the rule with the fingerprint
+func (m *Manager) RelatedToAlert(ctx context.Context, ruleID string, fingerprint uint64) (*AlertCorrelation, *model.ApiError) {
+	var (
+		alert  RelatedAlert
+		found  bool
+		alerts []RelatedAlert
+	)
+	org := m.opts.tenants.of(ruleID)
+	m.mtx.RLock()
+	if rule, ok := m.rules[ruleID]; ok && visibleTo(org, tenantOf(ctx)) {
+		for _, a := range rule.ActiveAlerts() {
+			if a.Labels.Hash() == fingerprint && a.State != StateInactive {
+				alert, found = relatedAlert(rule, a), true
+				break
+			}
+		}
+	}
+	if found {
+		alerts = m.activeAlerts()[org]
+	}
+	m.mtx.RUnlock()
+	if !found {
+		return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("alert %d of rule %s is not active", fingerprint, ruleID)}
+	}
+
+	opts := m.opts.Correlation
+	changes, err := m.ruleDB.GetChangeEvents(ctx, alert.ActiveAt.Add(-opts.Lookback), alert.ActiveAt.Add(opts.Window))
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	return correlate(alert, alerts, changes, opts), nil
+}
+
+// annotateRelated adds the possibly related alerts and changes to the
+// annotations of the firing alerts. The alerts are sent from the tasks
+// while the manager may be waiting on them with its lock held, the
+// annotations are then left out.
+func (m *Manager) annotateRelated(ctx context.Context, alerts []*am.Alert) {
+	if !m.opts.Correlation.Annotate || m.opts.changes == nil || len(alerts) == 0 {
+		return
+	}
+	if !m.mtx.TryRLock() {
+		zap.L().Debug("skipping the related alerts of the notifications, the rules are being updated")
+		return
+	}
+	active := m.activeAlerts()
+	m.mtx.RUnlock()
+
+	now := time.Now()
+	changes := m.opts.changes.recent(ctx, m.opts.Correlation.Window, now)
+	for _, a := range alerts {
+		if a.Resolved() {
+			continue
+		}
+		alert := RelatedAlert{
+			RuleID:      a.Labels.Get(labels.AlertRuleIdLabel),
+			RuleName:    a.Labels.Get(labels.AlertNameLabel),
+			Fingerprint: a.Labels.Hash(),
+			Labels:      a.Labels.Map(),
+			ActiveAt:    a.StartsAt,
+		}
+		org := m.opts.tenants.of(alert.RuleID)
+		related := possiblyRelated(correlate(alert, active[org], changes, m.opts.Correlation))
+		if related == "" {
+			continue
+		}
+		annotations := map[string]string{}
+		if a.Annotations != nil {
+			annotations = a.Annotations.Map()
+		}
+		annotations[possiblyRelatedAnnotation] = related
+		a.Annotations = labels.FromMap(annotations)
+	}
+}
@@ -0,0 +1,111 @@
+package rules
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/constants"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+func TestCorrelate(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	opts := CorrelationOptions{Window: 15 * time.Minute, Lookback: time.Hour}
+	alert := RelatedAlert{RuleID: "1", RuleName: "latency", Fingerprint: 1, ActiveAt: now,
+		Labels: map[string]string{"alertname": "latency", "service": "api", "env": "prod", "severity": "critical"}}
+	alerts := []RelatedAlert{
+		alert,
+		{RuleID: "2", RuleName: "errors", Fingerprint: 2, ActiveAt: now.Add(-2 * time.Hour),
+			Labels: map[string]string{"alertname": "errors", "service": "api", "env": "prod", "severity": "critical"}},
+		{RuleID: "3", RuleName: "disk", Fingerprint: 3, ActiveAt: now.Add(5 * time.Minute),
+			Labels: map[string]string{"alertname": "disk", "host": "db-1"}},
+		// unrelated by labels and time
+		{RuleID: "4", RuleName: "queue", Fingerprint: 4, ActiveAt: now.Add(-time.Hour),
+			Labels: map[string]string{"alertname": "queue", "service": "worker"}},
+		// the same severity alone does not relate alerts
+		{RuleID: "5", RuleName: "cpu", Fingerprint: 5, ActiveAt: now.Add(-time.Hour),
+			Labels: map[string]string{"alertname": "cpu", "severity": "critical"}},
+	}
+	changes := []ChangeEvent{
+		{Id: 1, Kind: ChangeDeployment, Title: "api v1.2.0", Timestamp: now.Add(-10 * time.Minute), Labels: map[string]string{"service": "api", "env": "prod"}},
+		{Id: 2, Kind: ChangeDeployment, Title: "worker v3", Timestamp: now.Add(-5 * time.Minute), Labels: map[string]string{"service": "worker"}},
+		{Id: 3, Kind: ChangeConfig, Title: "feature flags", Timestamp: now.Add(-30 * time.Minute), Labels: map[string]string{}},
+		// too old
+		{Id: 4, Kind: ChangeDeployment, Title: "api v1.1.0", Timestamp: now.Add(-2 * time.Hour), Labels: map[string]string{"service": "api"}},
+	}
+
+	c := correlate(alert, alerts, changes, opts)
+	require.Len(t, c.Alerts, 2)
+	assert.Equal(t, "2", c.Alerts[0].RuleID)
+	assert.Equal(t, map[string]string{"service": "api", "env": "prod"}, c.Alerts[0].SharedLabels)
+	assert.Equal(t, 2.0, c.Alerts[0].Score)
+	assert.Equal(t, "3", c.Alerts[1].RuleID)
+	assert.Empty(t, c.Alerts[1].SharedLabels)
+	assert.InDelta(t, 2.0/3, c.Alerts[1].Score, 1e-9)
+
+	require.Len(t, c.Changes, 2)
+	assert.Equal(t, int64(1), c.Changes[0].Id)
+	assert.InDelta(t, 2+5.0/6, c.Changes[0].Score, 1e-9)
+	assert.Equal(t, int64(3), c.Changes[1].Id)
+
+	assert.Equal(t, "alert errors (env=prod, service=api); alert disk; "+
+		"deployment api v1.2.0 at 2024-03-01T11:50:00Z (env=prod, service=api); config feature flags at 2024-03-01T11:30:00Z",
+		possiblyRelated(c))
+	assert.Empty(t, possiblyRelated(correlate(alert, nil, nil, opts)))
+}
+
+func TestChangeEventValidate(t *testing.T) {
+	e := ChangeEvent{Kind: "release", Title: "api v1"}
+	assert.Error(t, e.Validate())
+
+	e.Kind = ChangeK8sEvent
+	require.NoError(t, e.Validate())
+	assert.NotNil(t, e.Labels)
+	assert.False(t, e.Timestamp.IsZero())
+
+	e.Title = ""
+	assert.Error(t, e.Validate())
+}
+
+// noChangesDB has no change events
+type noChangesDB struct {
+	RuleDB
+}
+
+func (db *noChangesDB) GetChangeEvents(context.Context, time.Time, time.Time) ([]ChangeEvent, error) {
+	return nil, nil
+}
+
+func TestRelatedToAlertScopedByOrg(t *testing.T) {
+	lbls := map[string]string{"service": "api"}
+	tenants := newRuleTenants()
+	tenants.set("1", "org-a")
+	tenants.set("2", "org-a")
+	tenants.set("3", "org-b")
+	m := &Manager{
+		rules: map[string]Rule{
+			"1": firingRule(t, "1", lbls),
+			"2": firingRule(t, "2", lbls),
+			"3": firingRule(t, "3", lbls),
+		},
+		ruleDB: &noChangesDB{},
+		opts:   &ManagerOptions{tenants: tenants, Correlation: CorrelationOptions{Window: time.Hour, Lookback: time.Hour}},
+	}
+	orgA := context.WithValue(context.Background(), constants.ContextUserKey, &model.UserPayload{User: model.User{OrgId: "org-a"}})
+	fp := labels.FromMap(lbls).Hash()
+
+	// the alert of the other org shares the labels but is not related
+	correlation, apiErr := m.RelatedToAlert(orgA, "1", fp)
+	require.Nil(t, apiErr)
+	require.Len(t, correlation.Alerts, 1)
+	assert.Equal(t, "2", correlation.Alerts[0].RuleID)
+
+	// the alerts of the other orgs are not found
+	_, apiErr = m.RelatedToAlert(orgA, "3", fp)
+	require.NotNil(t, apiErr)
+	assert.Equal(t, model.ErrorNotFound, apiErr.Typ)
+}
@@ -0,0 +1,317 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.uber.org/zap"
+)
+
+const (
+	CostByRule      = "rule"
+	CostByTeam      = "team"
+	CostByQueryType = "query_type"
+
+	// costFlushInterval is how often the hourly query costs are written
+	// to the rule db
+	costFlushInterval = time.Minute
+	// costRetention is how long the hourly query costs are kept
+	costRetention = 90 * 24 * time.Hour
+	// defaultCostTeamLabel names the team of a rule when the team
+	// ownership of the rules is not set up
+	defaultCostTeamLabel = "team"
+)
+
+// HourlyQueryCost is the data read by the evaluations of a rule in an hour
+type HourlyQueryCost struct {
+	RuleId      string
+	Hour        time.Time
+	Evaluations int64
+	ReadRows    uint64
+	ReadBytes   uint64
+}
+
+// CostReportRequest selects the period of the cost report and how the
+// costs are grouped
+type CostReportRequest struct {
+	Start   int64
+	End     int64
+	GroupBy string
+	Limit   int
+}
+
+func (r *CostReportRequest) Validate() error {
+	if r.Start == 0 || r.End == 0 {
+		return fmt.Errorf("start and end are required")
+	}
+	if r.End <= r.Start {
+		return fmt.Errorf("end must be after start")
+	}
+	if r.Limit < 0 {
+		return fmt.Errorf("limit must be greater than 0")
+	}
+	switch r.GroupBy {
+	case "":
+		r.GroupBy = CostByRule
+	case CostByRule, CostByTeam, CostByQueryType:
+	default:
+		return fmt.Errorf("groupBy must be one of %s, %s, %s", CostByRule, CostByTeam, CostByQueryType)
+	}
+	return nil
+}
+
+// CostReportEntry is the data read by the evaluations of the rules of a
+// group. The rule entries also name the team and the query type of the
+// rule.
+type CostReportEntry struct {
+	Group       string `json:"group"`
+	RuleName    string `json:"ruleName,omitempty"`
+	Team        string `json:"team,omitempty"`
+	QueryType   string `json:"queryType,omitempty"`
+	Rules       int    `json:"rules"`
+	Evaluations int64  `json:"evaluations"`
+	ReadRows    uint64 `json:"readRows"`
+	ReadBytes   uint64 `json:"readBytes"`
+	// ShareOfBytes is the fraction of the bytes read by all the rules
+	ShareOfBytes float64 `json:"shareOfBytes"`
+}
+
+// CostReport is the data read by the rule evaluations over a period, the
+// most expensive groups first
+type CostReport struct {
+	Start       time.Time         `json:"start"`
+	End         time.Time         `json:"end"`
+	GroupBy     string            `json:"groupBy"`
+	Evaluations int64             `json:"evaluations"`
+	ReadRows    uint64            `json:"readRows"`
+	ReadBytes   uint64            `json:"readBytes"`
+	Entries     []CostReportEntry `json:"entries"`
+}
+
+type hourlyCostKey struct {
+	ruleID string
+	hour   time.Time
+}
+
+// costRecorder adds up the query cost of the evaluations by rule and hour
+// and writes it to the rule db in the background. The replicas of a
+// sharded setup add the costs of the rules they evaluate.
+type costRecorder struct {
+	db RuleDB
+
+	mtx     sync.Mutex
+	pending map[hourlyCostKey]*HourlyQueryCost
+
+	done       chan struct{}
+	terminated chan struct{}
+}
+
+func newCostRecorder(db RuleDB) *costRecorder {
+	return &costRecorder{
+		db:         db,
+		pending:    map[hourlyCostKey]*HourlyQueryCost{},
+		done:       make(chan struct{}),
+		terminated: make(chan struct{}),
+	}
+}
+
+func (c *costRecorder) record(ruleID string, cost QueryCost) {
+	if c == nil {
+		return
+	}
+	key := hourlyCostKey{ruleID: ruleID, hour: cost.Timestamp.UTC().Truncate(time.Hour)}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	hourly, ok := c.pending[key]
+	if !ok {
+		hourly = &HourlyQueryCost{RuleId: ruleID, Hour: key.hour}
+		c.pending[key] = hourly
+	}
+	hourly.Evaluations++
+	hourly.ReadRows += cost.ReadRows
+	hourly.ReadBytes += cost.ReadBytes
+}
+
+// flush writes the costs added since the last flush, they are kept for
+// the next flush when the write fails
+func (c *costRecorder) flush(ctx context.Context) {
+	c.mtx.Lock()
+	pending := c.pending
+	c.pending = map[hourlyCostKey]*HourlyQueryCost{}
+	c.mtx.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	costs := make([]HourlyQueryCost, 0, len(pending))
+	for _, hourly := range pending {
+		costs = append(costs, *hourly)
+	}
+	if err := c.db.AddQueryCosts(ctx, costs, time.Now().Add(-costRetention)); err != nil {
+		zap.L().Error("failed to write the query costs of the rules", zap.Error(err))
+		c.mtx.Lock()
+		defer c.mtx.Unlock()
+		for key, hourly := range pending {
+			if p, ok := c.pending[key]; ok {
+				p.Evaluations += hourly.Evaluations
+				p.ReadRows += hourly.ReadRows
+				p.ReadBytes += hourly.ReadBytes
+				continue
+			}
+			c.pending[key] = hourly
+		}
+	}
+}
+
+func (c *costRecorder) Run(ctx context.Context) {
+	defer close(c.terminated)
+
+	tick := time.NewTicker(costFlushInterval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			// the costs of the last minute are written before stopping
+			c.flush(context.Background())
+			return
+		case <-tick.C:
+		}
+		c.flush(ctx)
+	}
+}
+
+func (c *costRecorder) Stop() {
+	close(c.done)
+	<-c.terminated
+}
+
+// costRuleInfo is what the cost report takes from a rule
+type costRuleInfo struct {
+	name      string
+	team      string
+	queryType string
+}
+
+// CostReport returns the data read by the evaluations of the rules over
+// the period, grouped by rule, team or query type
+func (m *Manager) CostReport(ctx context.Context, req *CostReportRequest) (*CostReport, *model.ApiError) {
+	if err := req.Validate(); err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorBadData, Err: err}
+	}
+	start, end := time.UnixMilli(req.Start), time.UnixMilli(req.End)
+	// the hour holding the start is included
+	costs, err := m.ruleDB.GetQueryCosts(ctx, start.UTC().Truncate(time.Hour), end)
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	infos, err := m.costRuleInfos(ctx)
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	visible, err := m.tenantRules(ctx)
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+
+	report := buildCostReport(costs, infos, visible, req.GroupBy, req.Limit)
+	report.Start, report.End = start, end
+	return report, nil
+}
+
+// costRuleInfos returns the name, team and query type of the stored rules
+func (m *Manager) costRuleInfos(ctx context.Context) (map[string]costRuleInfo, error) {
+	storedRules, err := m.ruleDB.GetStoredRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	teamLabel := m.opts.OwnerTeamLabel
+	if teamLabel == "" {
+		teamLabel = defaultCostTeamLabel
+	}
+	infos := map[string]costRuleInfo{}
+	for _, rec := range storedRules {
+		rule := PostableRule{}
+		if err := json.Unmarshal([]byte(rec.Data), &rule); err != nil {
+			continue
+		}
+		info := costRuleInfo{name: rule.AlertName, team: rule.Labels[teamLabel]}
+		if rule.RuleCondition != nil && rule.RuleCondition.CompositeQuery != nil {
+			info.queryType = string(rule.RuleCondition.CompositeQuery.QueryType)
+		}
+		infos[strconv.Itoa(rec.Id)] = info
+	}
+	return infos, nil
+}
+
+// buildCostReport adds up the hourly costs of the rules by group, the
+// rules not in visible are left out unless visible is nil. The costs of
+// the deleted rules are kept in the report with an unknown team and query
+// type.
+func buildCostReport(costs []HourlyQueryCost, infos map[string]costRuleInfo, visible map[string]bool, groupBy string, limit int) *CostReport {
+	report := &CostReport{GroupBy: groupBy, Entries: []CostReportEntry{}}
+	entries := map[string]*CostReportEntry{}
+	rules := map[string]map[string]struct{}{}
+	for _, c := range costs {
+		if visible != nil && !visible[c.RuleId] {
+			continue
+		}
+		info, ok := infos[c.RuleId]
+		if !ok {
+			info = costRuleInfo{name: "deleted rule " + c.RuleId}
+		}
+		group := c.RuleId
+		switch groupBy {
+		case CostByTeam:
+			group = info.team
+		case CostByQueryType:
+			group = info.queryType
+		}
+		if group == "" {
+			group = "unknown"
+		}
+
+		entry, ok := entries[group]
+		if !ok {
+			entry = &CostReportEntry{Group: group}
+			if groupBy == CostByRule {
+				entry.RuleName, entry.Team, entry.QueryType = info.name, info.team, info.queryType
+			}
+			entries[group] = entry
+			rules[group] = map[string]struct{}{}
+		}
+		rules[group][c.RuleId] = struct{}{}
+		entry.Evaluations += c.Evaluations
+		entry.ReadRows += c.ReadRows
+		entry.ReadBytes += c.ReadBytes
+
+		report.Evaluations += c.Evaluations
+		report.ReadRows += c.ReadRows
+		report.ReadBytes += c.ReadBytes
+	}
+
+	for group, entry := range entries {
+		entry.Rules = len(rules[group])
+		if report.ReadBytes > 0 {
+			entry.ShareOfBytes = float64(entry.ReadBytes) / float64(report.ReadBytes)
+		}
+		report.Entries = append(report.Entries, *entry)
+	}
+	sort.Slice(report.Entries, func(i, j int) bool {
+		a, b := report.Entries[i], report.Entries[j]
+		if a.ReadBytes != b.ReadBytes {
+			return a.ReadBytes > b.ReadBytes
+		}
+		return a.Group < b.Group
+	})
+	if limit > 0 && len(report.Entries) > limit {
+		report.Entries = report.Entries[:limit]
+	}
+	return report
+}
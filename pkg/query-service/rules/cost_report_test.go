@@ -0,0 +1,98 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// costDB keeps the hourly query costs in memory
+type costDB struct {
+	RuleDB
+	costs []HourlyQueryCost
+	err   error
+}
+
+func (db *costDB) AddQueryCosts(_ context.Context, costs []HourlyQueryCost, _ time.Time) error {
+	if db.err != nil {
+		return db.err
+	}
+	db.costs = append(db.costs, costs...)
+	return nil
+}
+
+func TestCostRecorder(t *testing.T) {
+	db := &costDB{err: errors.New("db is down")}
+	c := newCostRecorder(db)
+	hour := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	c.record("1", QueryCost{Timestamp: hour.Add(time.Minute), ReadRows: 10, ReadBytes: 100})
+	c.record("1", QueryCost{Timestamp: hour.Add(59 * time.Minute), ReadRows: 20, ReadBytes: 200})
+	c.record("2", QueryCost{Timestamp: hour.Add(time.Hour), ReadRows: 5, ReadBytes: 50})
+
+	// the costs are kept when they cannot be written
+	c.flush(context.Background())
+	assert.Empty(t, db.costs)
+	c.record("2", QueryCost{Timestamp: hour.Add(time.Hour), ReadRows: 5, ReadBytes: 50})
+
+	db.err = nil
+	c.flush(context.Background())
+	require.Len(t, db.costs, 2)
+	costs := map[string]HourlyQueryCost{}
+	for _, cost := range db.costs {
+		costs[cost.RuleId] = cost
+	}
+	assert.Equal(t, HourlyQueryCost{RuleId: "1", Hour: hour, Evaluations: 2, ReadRows: 30, ReadBytes: 300}, costs["1"])
+	assert.Equal(t, HourlyQueryCost{RuleId: "2", Hour: hour.Add(time.Hour), Evaluations: 2, ReadRows: 10, ReadBytes: 100}, costs["2"])
+
+	c.flush(context.Background())
+	assert.Len(t, db.costs, 2)
+}
+
+func TestBuildCostReport(t *testing.T) {
+	hour := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	costs := []HourlyQueryCost{
+		{RuleId: "1", Hour: hour, Evaluations: 60, ReadRows: 600, ReadBytes: 6000},
+		{RuleId: "2", Hour: hour, Evaluations: 60, ReadRows: 100, ReadBytes: 1000},
+		{RuleId: "1", Hour: hour.Add(time.Hour), Evaluations: 60, ReadRows: 600, ReadBytes: 6000},
+		{RuleId: "3", Hour: hour, Evaluations: 10, ReadRows: 300, ReadBytes: 3000},
+		{RuleId: "9", Hour: hour, Evaluations: 5, ReadRows: 100, ReadBytes: 1000},
+	}
+	infos := map[string]costRuleInfo{
+		"1": {name: "High error rate", team: "payments", queryType: "builder"},
+		"2": {name: "Slow checkout", team: "payments", queryType: "clickhouse_sql"},
+		"3": {name: "Disk usage", team: "infra", queryType: "promql"},
+	}
+
+	report := buildCostReport(costs, infos, nil, CostByRule, 0)
+	assert.Equal(t, uint64(17000), report.ReadBytes)
+	assert.Equal(t, int64(195), report.Evaluations)
+	require.Len(t, report.Entries, 4)
+	assert.Equal(t, CostReportEntry{
+		Group: "1", RuleName: "High error rate", Team: "payments", QueryType: "builder",
+		Rules: 1, Evaluations: 120, ReadRows: 1200, ReadBytes: 12000, ShareOfBytes: 12000.0 / 17000,
+	}, report.Entries[0])
+	// the costs of the deleted rules are still reported
+	assert.Equal(t, "deleted rule 9", report.Entries[3].RuleName)
+
+	report = buildCostReport(costs, infos, nil, CostByTeam, 2)
+	require.Len(t, report.Entries, 2)
+	assert.Equal(t, "payments", report.Entries[0].Group)
+	assert.Equal(t, 2, report.Entries[0].Rules)
+	assert.Equal(t, uint64(13000), report.Entries[0].ReadBytes)
+	assert.Equal(t, "infra", report.Entries[1].Group)
+
+	report = buildCostReport(costs, infos, map[string]bool{"2": true, "3": true}, CostByQueryType, 0)
+	assert.Equal(t, uint64(4000), report.ReadBytes)
+	require.Len(t, report.Entries, 2)
+	assert.Equal(t, "promql", report.Entries[0].Group)
+
+	req := &CostReportRequest{Start: 1, End: 2}
+	require.NoError(t, req.Validate())
+	assert.Equal(t, CostByRule, req.GroupBy)
+	assert.Error(t, (&CostReportRequest{Start: 1, End: 2, GroupBy: "channel"}).Validate())
+}
@@ -0,0 +1,80 @@
+package rules
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"go.signoz.io/signoz/pkg/query-service/converter"
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+// ReloadCustomUnits makes the thresholds and the notifications use the
+// stored custom units
+func (m *Manager) ReloadCustomUnits(ctx context.Context) error {
+	units, err := m.ruleDB.GetCustomUnits(ctx)
+	if err != nil {
+		return err
+	}
+	converter.SetCustomUnits(units)
+	return nil
+}
+
+// CustomUnits returns the custom units
+func (m *Manager) CustomUnits(ctx context.Context) ([]converter.CustomUnit, *model.ApiError) {
+	units, err := m.ruleDB.GetCustomUnits(ctx)
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	return units, nil
+}
+
+// CreateCustomUnit stores the custom unit, its base is a built-in unit, an
+// other custom unit or a new base shared by the custom units
+func (m *Manager) CreateCustomUnit(ctx context.Context, unit *converter.CustomUnit) *model.ApiError {
+	if err := unit.Validate(); err != nil {
+		return &model.ApiError{Typ: model.ErrorBadData, Err: err}
+	}
+	units, err := m.ruleDB.GetCustomUnits(ctx)
+	if err != nil {
+		return newApiErrorInternal(err)
+	}
+	if err := checkCustomUnit(units, unit); err != nil {
+		return err
+	}
+	if err := m.ruleDB.CreateCustomUnit(ctx, *unit); err != nil {
+		return newApiErrorInternal(err)
+	}
+	converter.SetCustomUnits(append(units, *unit))
+	return nil
+}
+
+// checkCustomUnit denies a unit with the name of a stored one, or used as
+// the base of a stored one which would make a cycle of bases
+func checkCustomUnit(units []converter.CustomUnit, unit *converter.CustomUnit) *model.ApiError {
+	for _, u := range units {
+		if u.Name == unit.Name {
+			return &model.ApiError{Typ: model.ErrorConflict, Err: fmt.Errorf("unit %s already exists", unit.Name)}
+		}
+		if u.Base == unit.Name {
+			return &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("unit %s is the base of the unit %s", unit.Name, u.Name)}
+		}
+	}
+	return nil
+}
+
+// DeleteCustomUnit deletes the custom unit, the rules using it compare and
+// show their values without the unit
+func (m *Manager) DeleteCustomUnit(ctx context.Context, name string) *model.ApiError {
+	if err := m.ruleDB.DeleteCustomUnit(ctx, name); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("unit %s not found", name)}
+		}
+		return newApiErrorInternal(err)
+	}
+	if err := m.ReloadCustomUnits(ctx); err != nil {
+		return newApiErrorInternal(err)
+	}
+	return nil
+}
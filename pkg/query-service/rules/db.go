@@ -2,7 +2,9 @@ package rules
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -11,6 +13,7 @@ import (
 	"github.com/jmoiron/sqlx"
 	"go.signoz.io/signoz/pkg/query-service/auth"
 	"go.signoz.io/signoz/pkg/query-service/common"
+	"go.signoz.io/signoz/pkg/query-service/converter"
 	"go.signoz.io/signoz/pkg/query-service/model"
 	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
 	"go.uber.org/zap"
@@ -48,8 +51,199 @@ type RuleDB interface {
 	// GetAllPlannedMaintenance fetches the maintenance definitions from db
 	GetAllPlannedMaintenance(ctx context.Context) ([]PlannedMaintenance, error)
 
+	// GetTemplateSnippets fetches the stored template snippets
+	GetTemplateSnippets(ctx context.Context) ([]TemplateSnippet, error)
+
+	// GetTemplateSnippetByID fetches the template snippet by id
+	GetTemplateSnippetByID(ctx context.Context, id string) (*TemplateSnippet, error)
+
+	// CreateTemplateSnippet stores the template snippet in db
+	CreateTemplateSnippet(ctx context.Context, snippet TemplateSnippet) (int64, error)
+
+	// EditTemplateSnippet updates the given template snippet in db
+	EditTemplateSnippet(ctx context.Context, snippet TemplateSnippet, id string) error
+
+	// DeleteTemplateSnippet deletes the given template snippet from db
+	DeleteTemplateSnippet(ctx context.Context, id string) error
+
+	// HeartbeatEvaluator records that the rule evaluator replica is alive
+	HeartbeatEvaluator(ctx context.Context, id string, ts time.Time) error
+
+	// GetLiveEvaluators fetches the ids of the replicas alive since the given time
+	GetLiveEvaluators(ctx context.Context, since time.Time) ([]string, error)
+
+	// DeleteEvaluator removes the replica from the evaluators
+	DeleteEvaluator(ctx context.Context, id string) error
+
+	// AddRuleEvaluation records the evaluation of a rule and keeps the last
+	// keep evaluations of the rule
+	AddRuleEvaluation(ctx context.Context, record EvaluationRecord, keep int) error
+
+	// GetRuleEvaluations fetches the last evaluations of the rule, latest first
+	GetRuleEvaluations(ctx context.Context, ruleId string, limit int) ([]EvaluationRecord, error)
+
+	// DeleteRuleEvaluations removes the evaluation log of the rule
+	DeleteRuleEvaluations(ctx context.Context, ruleId string) error
+
+	// GetStateHistoryRetention fetches the state history retention set
+	// through the api, nil when it was never set
+	GetStateHistoryRetention(ctx context.Context) (*StateHistoryRetention, error)
+
+	// SetStateHistoryRetention stores the state history retention
+	SetStateHistoryRetention(ctx context.Context, retention StateHistoryRetention) error
+
+	// AcknowledgeAlert records that the firing alert was acknowledged
+	AcknowledgeAlert(ctx context.Context, ruleId string, fingerprint uint64) (*AlertAck, error)
+
+	// GetAlertAcks fetches the acknowledgements made since the given time
+	GetAlertAcks(ctx context.Context, since time.Time) ([]AlertAck, error)
+
+	// AddNotifications records the notifications and removes the ones
+	// sent before the given time
+	AddNotifications(ctx context.Context, records []NotificationRecord, before time.Time) error
+
+	// GetNotifications fetches the notifications selected by the filter,
+	// oldest first
+	GetNotifications(ctx context.Context, filter NotificationFilter) ([]NotificationRecord, error)
+
+	// GetSeriesActivity fetches whether the queries of the rules return
+	// series
+	GetSeriesActivity(ctx context.Context) ([]SeriesActivity, error)
+
+	// SetSeriesActivity stores whether the query of the rule returns series
+	SetSeriesActivity(ctx context.Context, activity SeriesActivity) error
+
+	// DeleteSeriesActivity removes the series activity of the rule
+	DeleteSeriesActivity(ctx context.Context, ruleId string) error
+
+	// CreateIncident stores the incident with its alerts and returns its id
+	CreateIncident(ctx context.Context, incident *Incident) (int64, error)
+
+	// GetIncident fetches the incident with its alerts by id
+	GetIncident(ctx context.Context, id int64) (*Incident, error)
+
+	// GetIncidents fetches the incidents with the given statuses, all of
+	// them when none is given, latest first
+	GetIncidents(ctx context.Context, statuses ...string) ([]Incident, error)
+
+	// EditIncident updates the incident, its alerts are left unchanged
+	EditIncident(ctx context.Context, incident *Incident) error
+
+	// SetIncidentAlerts adds the alerts to the incident or updates their
+	// state when they are in it
+	SetIncidentAlerts(ctx context.Context, id int64, alerts []IncidentAlert) error
+
+	// AddChangeEvent stores the change and returns its id
+	AddChangeEvent(ctx context.Context, event ChangeEvent) (int64, error)
+
+	// GetChangeEvents fetches the changes made between start and end,
+	// oldest first
+	GetChangeEvents(ctx context.Context, start, end time.Time) ([]ChangeEvent, error)
+
+	// CreateShareLink stores the share link and returns its id
+	CreateShareLink(ctx context.Context, link ShareLink) (int64, error)
+
+	// GetShareLink fetches the share link by the hash of its token
+	GetShareLink(ctx context.Context, tokenHash string) (*ShareLink, error)
+
+	// GetShareLinks fetches the share links of the rule expiring after
+	// the given time, latest first
+	GetShareLinks(ctx context.Context, ruleId string, after time.Time) ([]ShareLink, error)
+
+	// DeleteShareLink removes the share link of the rule
+	DeleteShareLink(ctx context.Context, ruleId string, id int64) error
+
+	// AddQueryCosts adds the query costs to the hourly costs of the rules
+	// and removes the costs of the hours before the given time
+	AddQueryCosts(ctx context.Context, costs []HourlyQueryCost, before time.Time) error
+
+	// GetQueryCosts fetches the hourly costs of the rules between start
+	// and end
+	GetQueryCosts(ctx context.Context, start, end time.Time) ([]HourlyQueryCost, error)
+
+	// GetCustomUnits fetches the units defined by the users
+	GetCustomUnits(ctx context.Context) ([]converter.CustomUnit, error)
+
+	// CreateCustomUnit stores the custom unit
+	CreateCustomUnit(ctx context.Context, unit converter.CustomUnit) error
+
+	// DeleteCustomUnit deletes the custom unit with the name, it returns
+	// sql.ErrNoRows when there is no such unit
+	DeleteCustomUnit(ctx context.Context, name string) error
+
+	// GetFormatSettings fetches the format settings of the orgs
+	GetFormatSettings(ctx context.Context) ([]FormatSettings, error)
+
+	// SetFormatSettings stores the format settings of their org
+	SetFormatSettings(ctx context.Context, settings FormatSettings) error
+
+	// DeleteFormatSettings deletes the format settings of the org, it
+	// returns sql.ErrNoRows when the org has none
+	DeleteFormatSettings(ctx context.Context, orgId string) error
+
+	// GetCalendars fetches the event calendars visible to the org of the
+	// request
+	GetCalendars(ctx context.Context) ([]Calendar, error)
+
+	// CreateCalendar stores the calendar for the org of the request
+	CreateCalendar(ctx context.Context, calendar Calendar) (int64, error)
+
+	// EditCalendar updates the calendar, it returns sql.ErrNoRows when the
+	// org of the request does not see the calendar
+	EditCalendar(ctx context.Context, calendar Calendar, id string) error
+
+	// DeleteCalendar deletes the calendar, it returns sql.ErrNoRows when
+	// the org of the request does not see the calendar
+	DeleteCalendar(ctx context.Context, id string) error
+
+	// GetServiceEntries fetches the service catalog visible to the org of
+	// the request
+	GetServiceEntries(ctx context.Context) ([]ServiceEntry, error)
+
+	// CreateServiceEntry adds the service to the catalog of the org of the
+	// request
+	CreateServiceEntry(ctx context.Context, entry ServiceEntry) (int64, error)
+
+	// EditServiceEntry updates the catalog entry, it returns sql.ErrNoRows
+	// when the org of the request does not see the entry
+	EditServiceEntry(ctx context.Context, entry ServiceEntry, id string) error
+
+	// DeleteServiceEntry deletes the catalog entry, it returns
+	// sql.ErrNoRows when the org of the request does not see the entry
+	DeleteServiceEntry(ctx context.Context, id string) error
+
+	// GetAlertViews fetches the saved views of the alerts list of the org
+	// of the request, personal and shared
+	GetAlertViews(ctx context.Context) ([]AlertView, error)
+
+	// GetAlertView fetches the saved view, it returns sql.ErrNoRows when
+	// the org of the request does not see the view
+	GetAlertView(ctx context.Context, id string) (*AlertView, error)
+
+	// CreateAlertView saves the view for the user of the request
+	CreateAlertView(ctx context.Context, view AlertView) (int64, error)
+
+	// EditAlertView updates the saved view
+	EditAlertView(ctx context.Context, view AlertView, id string) error
+
+	// DeleteAlertView deletes the saved view
+	DeleteAlertView(ctx context.Context, id string) error
+
 	// used for internal telemetry
 	GetAlertsInfo(ctx context.Context) (*model.AlertsInfo, error)
+
+	// CreateChartSnapshot stores the png of the chart snapshot and removes
+	// the snapshots created before the given time
+	CreateChartSnapshot(ctx context.Context, id string, png []byte, createdAt, before time.Time) error
+
+	// GetChartSnapshot fetches the png of the chart snapshot created after
+	// the given time
+	GetChartSnapshot(ctx context.Context, id string, after time.Time) ([]byte, error)
+
+	// AssignLegacyOrg assigns the rules, maintenances and the other
+	// objects stored before they were scoped by org to the org, it
+	// returns the number of objects assigned
+	AssignLegacyOrg(ctx context.Context, orgId string) (int64, error)
 }
 
 type StoredRule struct {
@@ -59,6 +253,29 @@ type StoredRule struct {
 	UpdatedAt *time.Time `json:"updated_at" db:"updated_at"`
 	UpdatedBy *string    `json:"updated_by" db:"updated_by"`
 	Data      string     `json:"data" db:"data"`
+	// OrgId is the org owning the rule, empty for the rules created
+	// before the rules were scoped by org
+	OrgId string `json:"org_id" db:"org_id"`
+}
+
+// SeriesActivity tells since when the query of a rule returns no series
+type SeriesActivity struct {
+	RuleID string `json:"ruleId" db:"rule_id"`
+	// EmptySince is the first of the consecutive evaluations that returned
+	// no series, nil when the last evaluation returned series
+	EmptySince *time.Time `json:"emptySince,omitempty" db:"empty_since"`
+	// LastSeriesAt is the last evaluation known to return series
+	LastSeriesAt *time.Time `json:"lastSeriesAt,omitempty" db:"last_series_at"`
+	// AutoDisabledAt is when the rule was disabled for returning no series
+	AutoDisabledAt *time.Time `json:"autoDisabledAt,omitempty" db:"auto_disabled_at"`
+}
+
+// AlertAck records that somebody acknowledged a firing alert
+type AlertAck struct {
+	RuleID      string    `json:"ruleId"`
+	Fingerprint uint64    `json:"fingerprint"`
+	AckedAt     time.Time `json:"ackedAt"`
+	AckedBy     string    `json:"ackedBy"`
 }
 
 type Tx interface {
@@ -68,14 +285,39 @@ type Tx interface {
 
 type ruleDB struct {
 	*sqlx.DB
+	// postgres is set when the rules are stored in postgres instead of
+	// the local sqlite file
+	postgres bool
 }
 
 // todo: move init methods for creating tables
 
 func NewRuleDB(db *sqlx.DB) RuleDB {
 	return &ruleDB{
-		db,
+		DB:       db,
+		postgres: db != nil && db.DriverName() == "postgres",
+	}
+}
+
+// execQueryer is the db or the transaction an insert runs in
+type execQueryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// insert runs the insert query and returns the id of the new row, through
+// RETURNING on postgres whose driver does not support LastInsertId
+func (r *ruleDB) insert(q execQueryer, query string, args ...interface{}) (int64, error) {
+	if r.postgres {
+		var id int64
+		err := q.QueryRow(query+" RETURNING id", args...).Scan(&id)
+		return id, err
 	}
+	result, err := q.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
 }
 
 // CreateRuleTx stores a given rule in db and returns task name,
@@ -83,9 +325,9 @@ func NewRuleDB(db *sqlx.DB) RuleDB {
 func (r *ruleDB) CreateRuleTx(ctx context.Context, rule string) (int64, Tx, error) {
 	var lastInsertId int64
 
-	var userEmail string
+	var userEmail, orgId string
 	if user := common.GetUserFromContext(ctx); user != nil {
-		userEmail = user.Email
+		userEmail, orgId = user.Email, user.OrgId
 	}
 	createdAt := time.Now()
 	updatedAt := time.Now()
@@ -94,25 +336,9 @@ func (r *ruleDB) CreateRuleTx(ctx context.Context, rule string) (int64, Tx, erro
 		return lastInsertId, nil, err
 	}
 
-	stmt, err := tx.Prepare(`INSERT into rules (created_at, created_by, updated_at, updated_by, data) VALUES($1,$2,$3,$4,$5);`)
-	if err != nil {
-		zap.L().Error("Error in preparing statement for INSERT to rules", zap.Error(err))
-		tx.Rollback()
-		return lastInsertId, nil, err
-	}
-
-	defer stmt.Close()
-
-	result, err := stmt.Exec(createdAt, userEmail, updatedAt, userEmail, rule)
-	if err != nil {
-		zap.L().Error("Error in Executing prepared statement for INSERT to rules", zap.Error(err))
-		tx.Rollback() // return an error too, we may want to wrap them
-		return lastInsertId, nil, err
-	}
-
-	lastInsertId, err = result.LastInsertId()
+	lastInsertId, err = r.insert(tx, `INSERT into rules (created_at, created_by, updated_at, updated_by, data, org_id) VALUES($1,$2,$3,$4,$5,$6)`, createdAt, userEmail, updatedAt, userEmail, rule, orgId)
 	if err != nil {
-		zap.L().Error("Error in getting last insert id for INSERT to rules\n", zap.Error(err))
+		zap.L().Error("Error in Executing statement for INSERT to rules", zap.Error(err))
 		tx.Rollback() // return an error too, we may want to wrap them
 		return lastInsertId, nil, err
 	}
@@ -193,7 +419,7 @@ func (r *ruleDB) GetStoredRules(ctx context.Context) ([]StoredRule, error) {
 
 	rules := []StoredRule{}
 
-	query := "SELECT id, created_at, created_by, updated_at, updated_by, data FROM rules"
+	query := "SELECT id, created_at, created_by, updated_at, updated_by, data, org_id FROM rules"
 
 	err := r.Select(&rules, query)
 
@@ -213,8 +439,11 @@ func (r *ruleDB) GetStoredRule(ctx context.Context, id string) (*StoredRule, err
 
 	rule := &StoredRule{}
 
-	query := fmt.Sprintf("SELECT id, created_at, created_by, updated_at, updated_by, data FROM rules WHERE id=%d", intId)
+	query := fmt.Sprintf("SELECT id, created_at, created_by, updated_at, updated_by, data, org_id FROM rules WHERE id=%d", intId)
 	err = r.Get(rule, query)
+	if err == nil && !visibleTo(rule.OrgId, tenantOf(ctx)) {
+		err = sql.ErrNoRows
+	}
 
 	// zap.L().Info(query)
 
@@ -229,7 +458,7 @@ func (r *ruleDB) GetStoredRule(ctx context.Context, id string) (*StoredRule, err
 func (r *ruleDB) GetAllPlannedMaintenance(ctx context.Context) ([]PlannedMaintenance, error) {
 	maintenances := []PlannedMaintenance{}
 
-	query := "SELECT id, name, description, schedule, alert_ids, created_at, created_by, updated_at, updated_by FROM planned_maintenance"
+	query := "SELECT id, name, description, schedule, alert_ids, created_at, created_by, updated_at, updated_by, org_id FROM planned_maintenance"
 
 	err := r.Select(&maintenances, query)
 
@@ -238,14 +467,25 @@ func (r *ruleDB) GetAllPlannedMaintenance(ctx context.Context) ([]PlannedMainten
 		return nil, err
 	}
 
-	return maintenances, nil
+	// the requests of a user only see the maintenances of their org
+	org := tenantOf(ctx)
+	visible := maintenances[:0]
+	for _, m := range maintenances {
+		if visibleTo(m.OrgId, org) {
+			visible = append(visible, m)
+		}
+	}
+	return visible, nil
 }
 
 func (r *ruleDB) GetPlannedMaintenanceByID(ctx context.Context, id string) (*PlannedMaintenance, error) {
 	maintenance := &PlannedMaintenance{}
 
-	query := "SELECT id, name, description, schedule, alert_ids, created_at, created_by, updated_at, updated_by FROM planned_maintenance WHERE id=$1"
+	query := "SELECT id, name, description, schedule, alert_ids, created_at, created_by, updated_at, updated_by, org_id FROM planned_maintenance WHERE id=$1"
 	err := r.Get(maintenance, query, id)
+	if err == nil && !visibleTo(maintenance.OrgId, tenantOf(ctx)) {
+		err = sql.ErrNoRows
+	}
 
 	if err != nil {
 		zap.L().Error("Error in processing sql query", zap.Error(err))
@@ -262,20 +502,24 @@ func (r *ruleDB) CreatePlannedMaintenance(ctx context.Context, maintenance Plann
 	maintenance.CreatedAt = time.Now()
 	maintenance.UpdatedBy = email
 	maintenance.UpdatedAt = time.Now()
+	maintenance.OrgId = tenantOf(ctx)
 
-	query := "INSERT INTO planned_maintenance (name, description, schedule, alert_ids, created_at, created_by, updated_at, updated_by) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)"
+	query := "INSERT INTO planned_maintenance (name, description, schedule, alert_ids, created_at, created_by, updated_at, updated_by, org_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)"
 
-	result, err := r.Exec(query, maintenance.Name, maintenance.Description, maintenance.Schedule, maintenance.AlertIds, maintenance.CreatedAt, maintenance.CreatedBy, maintenance.UpdatedAt, maintenance.UpdatedBy)
+	id, err := r.insert(r.DB, query, maintenance.Name, maintenance.Description, maintenance.Schedule, maintenance.AlertIds, maintenance.CreatedAt, maintenance.CreatedBy, maintenance.UpdatedAt, maintenance.UpdatedBy, maintenance.OrgId)
 
 	if err != nil {
 		zap.L().Error("Error in processing sql query", zap.Error(err))
 		return 0, err
 	}
 
-	return result.LastInsertId()
+	return id, nil
 }
 
 func (r *ruleDB) DeletePlannedMaintenance(ctx context.Context, id string) (string, error) {
+	if _, err := r.GetPlannedMaintenanceByID(ctx, id); err != nil {
+		return "", err
+	}
 	query := "DELETE FROM planned_maintenance WHERE id=$1"
 	_, err := r.Exec(query, id)
 
@@ -288,6 +532,9 @@ func (r *ruleDB) DeletePlannedMaintenance(ctx context.Context, id string) (strin
 }
 
 func (r *ruleDB) EditPlannedMaintenance(ctx context.Context, maintenance PlannedMaintenance, id string) (string, error) {
+	if _, err := r.GetPlannedMaintenanceByID(ctx, id); err != nil {
+		return "", err
+	}
 	email, _ := auth.GetEmailFromJwt(ctx)
 	maintenance.UpdatedBy = email
 	maintenance.UpdatedAt = time.Now()
@@ -303,6 +550,143 @@ func (r *ruleDB) EditPlannedMaintenance(ctx context.Context, maintenance Planned
 	return "", nil
 }
 
+func (r *ruleDB) GetTemplateSnippets(ctx context.Context) ([]TemplateSnippet, error) {
+	snippets := []TemplateSnippet{}
+
+	query := "SELECT id, name, description, content, created_at, created_by, updated_at, updated_by FROM template_snippets ORDER BY name"
+
+	err := r.Select(&snippets, query)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+func (r *ruleDB) GetTemplateSnippetByID(ctx context.Context, id string) (*TemplateSnippet, error) {
+	snippet := &TemplateSnippet{}
+
+	query := "SELECT id, name, description, content, created_at, created_by, updated_at, updated_by FROM template_snippets WHERE id=$1"
+	err := r.Get(snippet, query, id)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return nil, err
+	}
+
+	return snippet, nil
+}
+
+func (r *ruleDB) CreateTemplateSnippet(ctx context.Context, snippet TemplateSnippet) (int64, error) {
+	email, _ := auth.GetEmailFromJwt(ctx)
+	snippet.CreatedBy = email
+	snippet.CreatedAt = time.Now()
+	snippet.UpdatedBy = email
+	snippet.UpdatedAt = time.Now()
+
+	query := "INSERT INTO template_snippets (name, description, content, created_at, created_by, updated_at, updated_by) VALUES ($1, $2, $3, $4, $5, $6, $7)"
+
+	id, err := r.insert(r.DB, query, snippet.Name, snippet.Description, snippet.Content, snippet.CreatedAt, snippet.CreatedBy, snippet.UpdatedAt, snippet.UpdatedBy)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (r *ruleDB) EditTemplateSnippet(ctx context.Context, snippet TemplateSnippet, id string) error {
+	email, _ := auth.GetEmailFromJwt(ctx)
+	snippet.UpdatedBy = email
+	snippet.UpdatedAt = time.Now()
+
+	query := "UPDATE template_snippets SET name=$1, description=$2, content=$3, updated_at=$4, updated_by=$5 WHERE id=$6"
+	_, err := r.Exec(query, snippet.Name, snippet.Description, snippet.Content, snippet.UpdatedAt, snippet.UpdatedBy, id)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *ruleDB) DeleteTemplateSnippet(ctx context.Context, id string) error {
+	query := "DELETE FROM template_snippets WHERE id=$1"
+	_, err := r.Exec(query, id)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *ruleDB) CreateChartSnapshot(ctx context.Context, id string, png []byte, createdAt, before time.Time) error {
+	if _, err := r.Exec("DELETE FROM alert_chart_snapshots WHERE created_at < $1", before); err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return err
+	}
+
+	query := "INSERT INTO alert_chart_snapshots (id, png, created_at) VALUES ($1, $2, $3)"
+
+	if _, err := r.Exec(query, id, png, createdAt); err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r *ruleDB) GetChartSnapshot(ctx context.Context, id string, after time.Time) ([]byte, error) {
+	var png []byte
+
+	query := "SELECT png FROM alert_chart_snapshots WHERE id=$1 AND created_at > $2"
+
+	err := r.Get(&png, query, id, after)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			zap.L().Error("Error in processing sql query", zap.Error(err))
+		}
+		return nil, err
+	}
+	return png, nil
+}
+
+// legacyOrgTables are the tables of the objects scoped by org
+var legacyOrgTables = []string{
+	"rules",
+	"planned_maintenance",
+	"incidents",
+	"alert_share_links",
+	"rule_calendars",
+	"service_catalog",
+	"alert_views",
+}
+
+func (r *ruleDB) AssignLegacyOrg(ctx context.Context, orgId string) (int64, error) {
+	tx, err := r.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	var assigned int64
+	for _, table := range legacyOrgTables {
+		res, err := tx.Exec(fmt.Sprintf("UPDATE %s SET org_id=$1 WHERE org_id=''", table), orgId)
+		if err != nil {
+			zap.L().Error("Error in processing sql query", zap.String("table", table), zap.Error(err))
+			tx.Rollback()
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		assigned += n
+	}
+
+	return assigned, tx.Commit()
+}
+
 func (r *ruleDB) GetAlertsInfo(ctx context.Context) (*model.AlertsInfo, error) {
 	alertsInfo := model.AlertsInfo{}
 	// fetch alerts from rules db
@@ -351,3 +735,1091 @@ func (r *ruleDB) GetAlertsInfo(ctx context.Context) (*model.AlertsInfo, error) {
 	alertsInfo.AlertNames = alertNames
 	return &alertsInfo, nil
 }
+
+func (r *ruleDB) HeartbeatEvaluator(ctx context.Context, id string, ts time.Time) error {
+	query := "INSERT INTO rule_evaluators (id, heartbeat_at) VALUES ($1, $2) ON CONFLICT(id) DO UPDATE SET heartbeat_at=excluded.heartbeat_at"
+
+	_, err := r.Exec(query, id, ts)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *ruleDB) GetLiveEvaluators(ctx context.Context, since time.Time) ([]string, error) {
+	ids := []string{}
+
+	query := "SELECT id FROM rule_evaluators WHERE heartbeat_at >= $1 ORDER BY id"
+
+	err := r.Select(&ids, query, since)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func (r *ruleDB) DeleteEvaluator(ctx context.Context, id string) error {
+	query := "DELETE FROM rule_evaluators WHERE id=$1"
+
+	_, err := r.Exec(query, id)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *ruleDB) AddRuleEvaluation(ctx context.Context, record EvaluationRecord, keep int) error {
+	samples, err := json.Marshal(record.Samples)
+	if err != nil {
+		return err
+	}
+
+	query := "INSERT INTO rule_evaluations (rule_id, evaluated_at, duration_ms, query, series, samples, error) VALUES ($1, $2, $3, $4, $5, $6, $7)"
+	_, err = r.Exec(query, record.RuleId, record.EvaluatedAt, record.DurationMs, record.Query, record.Series, string(samples), record.Error)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return err
+	}
+
+	query = "DELETE FROM rule_evaluations WHERE rule_id=$1 AND id NOT IN (SELECT id FROM rule_evaluations WHERE rule_id=$1 ORDER BY id DESC LIMIT $2)"
+	_, err = r.Exec(query, record.RuleId, keep)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *ruleDB) GetRuleEvaluations(ctx context.Context, ruleId string, limit int) ([]EvaluationRecord, error) {
+	rows := []struct {
+		EvaluationRecord
+		Samples string `db:"samples"`
+	}{}
+
+	query := "SELECT id, rule_id, evaluated_at, duration_ms, query, series, samples, error FROM rule_evaluations WHERE rule_id=$1 ORDER BY id DESC LIMIT $2"
+
+	err := r.Select(&rows, query, ruleId, limit)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return nil, err
+	}
+
+	records := make([]EvaluationRecord, 0, len(rows))
+	for _, row := range rows {
+		record := row.EvaluationRecord
+		if err := json.Unmarshal([]byte(row.Samples), &record.Samples); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (r *ruleDB) DeleteRuleEvaluations(ctx context.Context, ruleId string) error {
+	query := "DELETE FROM rule_evaluations WHERE rule_id=$1"
+
+	_, err := r.Exec(query, ruleId)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *ruleDB) GetStateHistoryRetention(ctx context.Context) (*StateHistoryRetention, error) {
+	var stored []struct {
+		Detail      int64 `db:"detail_seconds"`
+		Transitions int64 `db:"transitions_seconds"`
+	}
+
+	query := "SELECT detail_seconds, transitions_seconds FROM rule_state_history_retention WHERE id=1"
+
+	err := r.Select(&stored, query)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return nil, err
+	}
+	if len(stored) == 0 {
+		return nil, nil
+	}
+
+	return &StateHistoryRetention{
+		Detail:      Duration(time.Duration(stored[0].Detail) * time.Second),
+		Transitions: Duration(time.Duration(stored[0].Transitions) * time.Second),
+	}, nil
+}
+
+func (r *ruleDB) SetStateHistoryRetention(ctx context.Context, retention StateHistoryRetention) error {
+	query := "INSERT INTO rule_state_history_retention (id, detail_seconds, transitions_seconds, updated_at) VALUES (1, $1, $2, $3) ON CONFLICT(id) DO UPDATE SET detail_seconds=excluded.detail_seconds, transitions_seconds=excluded.transitions_seconds, updated_at=excluded.updated_at"
+
+	_, err := r.Exec(query, int64(time.Duration(retention.Detail).Seconds()), int64(time.Duration(retention.Transitions).Seconds()), time.Now())
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *ruleDB) AcknowledgeAlert(ctx context.Context, ruleId string, fingerprint uint64) (*AlertAck, error) {
+	ack := AlertAck{RuleID: ruleId, Fingerprint: fingerprint, AckedAt: time.Now()}
+	if user := common.GetUserFromContext(ctx); user != nil {
+		ack.AckedBy = user.Email
+	}
+
+	query := "INSERT INTO rule_alert_acks (rule_id, fingerprint, acked_at, acked_by) VALUES ($1, $2, $3, $4)"
+
+	_, err := r.Exec(query, ack.RuleID, strconv.FormatUint(ack.Fingerprint, 10), ack.AckedAt, ack.AckedBy)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return nil, err
+	}
+
+	return &ack, nil
+}
+
+func (r *ruleDB) GetAlertAcks(ctx context.Context, since time.Time) ([]AlertAck, error) {
+	stored := []struct {
+		RuleID      string    `db:"rule_id"`
+		Fingerprint string    `db:"fingerprint"`
+		AckedAt     time.Time `db:"acked_at"`
+		AckedBy     string    `db:"acked_by"`
+	}{}
+
+	query := "SELECT rule_id, fingerprint, acked_at, acked_by FROM rule_alert_acks WHERE acked_at >= $1 ORDER BY acked_at"
+
+	err := r.Select(&stored, query, since)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return nil, err
+	}
+
+	acks := make([]AlertAck, 0, len(stored))
+	for _, s := range stored {
+		fingerprint, err := strconv.ParseUint(s.Fingerprint, 10, 64)
+		if err != nil {
+			continue
+		}
+		acks = append(acks, AlertAck{RuleID: s.RuleID, Fingerprint: fingerprint, AckedAt: s.AckedAt, AckedBy: s.AckedBy})
+	}
+
+	return acks, nil
+}
+
+func (r *ruleDB) AddNotifications(ctx context.Context, records []NotificationRecord, before time.Time) error {
+	tx, err := r.Begin()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("INSERT INTO rule_notifications (rule_id, alert_name, fingerprint, state, status, receivers, labels, sent_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)")
+	if err != nil {
+		zap.L().Error("Error in preparing statement for INSERT to rule_notifications", zap.Error(err))
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, record := range records {
+		receivers, err := json.Marshal(record.Receivers)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		_, err = stmt.Exec(record.RuleId, record.AlertName, strconv.FormatUint(record.Fingerprint, 10), record.State, record.Status, string(receivers), record.Labels, record.SentAt)
+		if err != nil {
+			zap.L().Error("Error in Executing prepared statement for INSERT to rule_notifications", zap.Error(err))
+			tx.Rollback()
+			return err
+		}
+	}
+
+	_, err = tx.Exec("DELETE FROM rule_notifications WHERE sent_at < $1", before)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *ruleDB) GetNotifications(ctx context.Context, filter NotificationFilter) ([]NotificationRecord, error) {
+	rows := []struct {
+		RuleId      string    `db:"rule_id"`
+		AlertName   string    `db:"alert_name"`
+		Fingerprint string    `db:"fingerprint"`
+		State       string    `db:"state"`
+		Status      string    `db:"status"`
+		Receivers   string    `db:"receivers"`
+		Labels      string    `db:"labels"`
+		SentAt      time.Time `db:"sent_at"`
+	}{}
+
+	query := "SELECT rule_id, alert_name, fingerprint, state, status, receivers, labels, sent_at FROM rule_notifications WHERE sent_at >= $1 AND sent_at < $2"
+	args := []interface{}{filter.Start, filter.End}
+	if filter.RuleId != "" {
+		args = append(args, filter.RuleId)
+		query += fmt.Sprintf(" AND rule_id=$%d", len(args))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		query += fmt.Sprintf(" AND status=$%d", len(args))
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY sent_at, id LIMIT $%d", len(args))
+
+	err := r.Select(&rows, query, args...)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return nil, err
+	}
+
+	records := make([]NotificationRecord, 0, len(rows))
+	for _, row := range rows {
+		record := NotificationRecord{
+			RuleId:    row.RuleId,
+			AlertName: row.AlertName,
+			State:     row.State,
+			Status:    row.Status,
+			Labels:    row.Labels,
+			SentAt:    row.SentAt,
+		}
+		record.Fingerprint, _ = strconv.ParseUint(row.Fingerprint, 10, 64)
+		if err := json.Unmarshal([]byte(row.Receivers), &record.Receivers); err != nil {
+			zap.L().Error("failed to unmarshal the receivers of a notification", zap.Error(err))
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func (r *ruleDB) GetSeriesActivity(ctx context.Context) ([]SeriesActivity, error) {
+	activity := []SeriesActivity{}
+
+	query := "SELECT rule_id, empty_since, last_series_at, auto_disabled_at FROM rule_series_activity"
+
+	err := r.Select(&activity, query)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return nil, err
+	}
+
+	return activity, nil
+}
+
+func (r *ruleDB) SetSeriesActivity(ctx context.Context, activity SeriesActivity) error {
+	query := "INSERT INTO rule_series_activity (rule_id, empty_since, last_series_at, auto_disabled_at) VALUES ($1, $2, $3, $4) ON CONFLICT(rule_id) DO UPDATE SET empty_since=excluded.empty_since, last_series_at=excluded.last_series_at, auto_disabled_at=excluded.auto_disabled_at"
+
+	_, err := r.Exec(query, activity.RuleID, activity.EmptySince, activity.LastSeriesAt, activity.AutoDisabledAt)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *ruleDB) DeleteSeriesActivity(ctx context.Context, ruleId string) error {
+	query := "DELETE FROM rule_series_activity WHERE rule_id = $1"
+
+	_, err := r.Exec(query, ruleId)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+type storedIncident struct {
+	Id         int64      `db:"id"`
+	Title      string     `db:"title"`
+	Status     string     `db:"status"`
+	Severity   string     `db:"severity"`
+	Assignee   string     `db:"assignee"`
+	Labels     string     `db:"labels"`
+	Auto       bool       `db:"auto"`
+	CreatedAt  time.Time  `db:"created_at"`
+	CreatedBy  string     `db:"created_by"`
+	UpdatedAt  time.Time  `db:"updated_at"`
+	ResolvedAt *time.Time `db:"resolved_at"`
+	OrgId      string     `db:"org_id"`
+}
+
+type storedIncidentAlert struct {
+	IncidentId  int64      `db:"incident_id"`
+	RuleId      string     `db:"rule_id"`
+	RuleName    string     `db:"rule_name"`
+	Fingerprint string     `db:"fingerprint"`
+	Labels      string     `db:"labels"`
+	State       string     `db:"state"`
+	AddedAt     time.Time  `db:"added_at"`
+	ResolvedAt  *time.Time `db:"resolved_at"`
+}
+
+func (r *ruleDB) CreateIncident(ctx context.Context, incident *Incident) (int64, error) {
+	labels, err := json.Marshal(incident.Labels)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := r.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	query := "INSERT INTO incidents (title, status, severity, assignee, labels, auto, created_at, created_by, updated_at, resolved_at, org_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)"
+
+	id, err := r.insert(tx, query, incident.Title, incident.Status, incident.Severity, incident.Assignee, string(labels), incident.Auto, incident.CreatedAt, incident.CreatedBy, incident.UpdatedAt, incident.ResolvedAt, incident.OrgId)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		tx.Rollback()
+		return 0, err
+	}
+	if err := setIncidentAlerts(tx, id, incident.Alerts); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+func (r *ruleDB) GetIncident(ctx context.Context, id int64) (*Incident, error) {
+	stored := storedIncident{}
+
+	query := "SELECT id, title, status, severity, assignee, labels, auto, created_at, created_by, updated_at, resolved_at, org_id FROM incidents WHERE id=$1"
+
+	err := r.Get(&stored, query, id)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return nil, err
+	}
+
+	alerts := []storedIncidentAlert{}
+	query = "SELECT incident_id, rule_id, rule_name, fingerprint, labels, state, added_at, resolved_at FROM incident_alerts WHERE incident_id=$1 ORDER BY added_at"
+
+	err = r.Select(&alerts, query, id)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return nil, err
+	}
+
+	incident := newIncidentFromDB(stored, alerts)
+	return &incident, nil
+}
+
+func (r *ruleDB) GetIncidents(ctx context.Context, statuses ...string) ([]Incident, error) {
+	stored := []storedIncident{}
+	alerts := []storedIncidentAlert{}
+
+	filter := ""
+	args := make([]interface{}, 0, len(statuses))
+	if len(statuses) > 0 {
+		params := make([]string, len(statuses))
+		for i, status := range statuses {
+			params[i] = fmt.Sprintf("$%d", i+1)
+			args = append(args, status)
+		}
+		filter = fmt.Sprintf(" WHERE status IN (%s)", strings.Join(params, ", "))
+	}
+
+	query := "SELECT id, title, status, severity, assignee, labels, auto, created_at, created_by, updated_at, resolved_at, org_id FROM incidents" + filter + " ORDER BY id DESC"
+
+	err := r.Select(&stored, query, args...)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return nil, err
+	}
+
+	query = "SELECT incident_id, rule_id, rule_name, fingerprint, labels, state, added_at, resolved_at FROM incident_alerts WHERE incident_id IN (SELECT id FROM incidents" + filter + ") ORDER BY added_at"
+
+	err = r.Select(&alerts, query, args...)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return nil, err
+	}
+
+	byIncident := map[int64][]storedIncidentAlert{}
+	for _, a := range alerts {
+		byIncident[a.IncidentId] = append(byIncident[a.IncidentId], a)
+	}
+	incidents := make([]Incident, 0, len(stored))
+	for _, s := range stored {
+		incidents = append(incidents, newIncidentFromDB(s, byIncident[s.Id]))
+	}
+
+	return incidents, nil
+}
+
+func (r *ruleDB) EditIncident(ctx context.Context, incident *Incident) error {
+	labels, err := json.Marshal(incident.Labels)
+	if err != nil {
+		return err
+	}
+
+	query := "UPDATE incidents SET title=$1, status=$2, severity=$3, assignee=$4, labels=$5, updated_at=$6, resolved_at=$7 WHERE id=$8"
+
+	_, err = r.Exec(query, incident.Title, incident.Status, incident.Severity, incident.Assignee, string(labels), incident.UpdatedAt, incident.ResolvedAt, incident.Id)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *ruleDB) SetIncidentAlerts(ctx context.Context, id int64, alerts []IncidentAlert) error {
+	tx, err := r.Begin()
+	if err != nil {
+		return err
+	}
+	if err := setIncidentAlerts(tx, id, alerts); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func setIncidentAlerts(tx *sql.Tx, id int64, alerts []IncidentAlert) error {
+	stmt, err := tx.Prepare("INSERT INTO incident_alerts (incident_id, rule_id, rule_name, fingerprint, labels, state, added_at, resolved_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT(incident_id, rule_id, fingerprint) DO UPDATE SET state=excluded.state, resolved_at=excluded.resolved_at")
+	if err != nil {
+		zap.L().Error("Error in preparing statement for INSERT to incident_alerts", zap.Error(err))
+		return err
+	}
+	defer stmt.Close()
+
+	for _, a := range alerts {
+		labels, err := json.Marshal(a.Labels)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.Exec(id, a.RuleID, a.RuleName, strconv.FormatUint(a.Fingerprint, 10), string(labels), a.State, a.AddedAt, a.ResolvedAt); err != nil {
+			zap.L().Error("Error in processing sql query", zap.Error(err))
+			return err
+		}
+	}
+	return nil
+}
+
+func newIncidentFromDB(s storedIncident, alerts []storedIncidentAlert) Incident {
+	incident := Incident{
+		Id:         s.Id,
+		Title:      s.Title,
+		Status:     s.Status,
+		Severity:   s.Severity,
+		Assignee:   s.Assignee,
+		Labels:     map[string]string{},
+		Auto:       s.Auto,
+		CreatedAt:  s.CreatedAt,
+		CreatedBy:  s.CreatedBy,
+		UpdatedAt:  s.UpdatedAt,
+		ResolvedAt: s.ResolvedAt,
+		OrgId:      s.OrgId,
+		Alerts:     make([]IncidentAlert, 0, len(alerts)),
+	}
+	if err := json.Unmarshal([]byte(s.Labels), &incident.Labels); err != nil {
+		zap.L().Error("failed to unmarshal the labels of an incident", zap.Int64("id", s.Id), zap.Error(err))
+	}
+	for _, a := range alerts {
+		alert := IncidentAlert{
+			RuleID:     a.RuleId,
+			RuleName:   a.RuleName,
+			Labels:     map[string]string{},
+			State:      a.State,
+			AddedAt:    a.AddedAt,
+			ResolvedAt: a.ResolvedAt,
+		}
+		alert.Fingerprint, _ = strconv.ParseUint(a.Fingerprint, 10, 64)
+		if err := json.Unmarshal([]byte(a.Labels), &alert.Labels); err != nil {
+			zap.L().Error("failed to unmarshal the labels of an incident alert", zap.Int64("id", s.Id), zap.Error(err))
+		}
+		incident.Alerts = append(incident.Alerts, alert)
+	}
+	return incident
+}
+
+func (r *ruleDB) AddChangeEvent(ctx context.Context, event ChangeEvent) (int64, error) {
+	labels, err := json.Marshal(event.Labels)
+	if err != nil {
+		return 0, err
+	}
+
+	query := "INSERT INTO change_events (kind, source, title, description, labels, timestamp, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)"
+
+	id, err := r.insert(r.DB, query, event.Kind, event.Source, event.Title, event.Description, string(labels), event.Timestamp, time.Now())
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (r *ruleDB) GetChangeEvents(ctx context.Context, start, end time.Time) ([]ChangeEvent, error) {
+	rows := []struct {
+		Id          int64     `db:"id"`
+		Kind        string    `db:"kind"`
+		Source      string    `db:"source"`
+		Title       string    `db:"title"`
+		Description string    `db:"description"`
+		Labels      string    `db:"labels"`
+		Timestamp   time.Time `db:"timestamp"`
+	}{}
+
+	query := "SELECT id, kind, source, title, description, labels, timestamp FROM change_events WHERE timestamp >= $1 AND timestamp <= $2 ORDER BY timestamp, id"
+
+	err := r.Select(&rows, query, start, end)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return nil, err
+	}
+
+	events := make([]ChangeEvent, 0, len(rows))
+	for _, row := range rows {
+		event := ChangeEvent{
+			Id:          row.Id,
+			Kind:        row.Kind,
+			Source:      row.Source,
+			Title:       row.Title,
+			Description: row.Description,
+			Labels:      map[string]string{},
+			Timestamp:   row.Timestamp,
+		}
+		if err := json.Unmarshal([]byte(row.Labels), &event.Labels); err != nil {
+			zap.L().Error("failed to unmarshal the labels of a change event", zap.Int64("id", row.Id), zap.Error(err))
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// storedShareLink is a row of the alert_share_links table
+type storedShareLink struct {
+	Id          int64     `db:"id"`
+	TokenHash   string    `db:"token_hash"`
+	RuleId      string    `db:"rule_id"`
+	Fingerprint string    `db:"fingerprint"`
+	OrgId       string    `db:"org_id"`
+	ExpiresAt   time.Time `db:"expires_at"`
+	CreatedAt   time.Time `db:"created_at"`
+	CreatedBy   string    `db:"created_by"`
+}
+
+func (s storedShareLink) shareLink() ShareLink {
+	link := ShareLink{
+		Id:        s.Id,
+		RuleID:    s.RuleId,
+		TokenHash: s.TokenHash,
+		OrgId:     s.OrgId,
+		ExpiresAt: s.ExpiresAt,
+		CreatedAt: s.CreatedAt,
+		CreatedBy: s.CreatedBy,
+	}
+	link.Fingerprint, _ = strconv.ParseUint(s.Fingerprint, 10, 64)
+	return link
+}
+
+func (r *ruleDB) CreateShareLink(ctx context.Context, link ShareLink) (int64, error) {
+	var fingerprint string
+	if link.Fingerprint != 0 {
+		fingerprint = strconv.FormatUint(link.Fingerprint, 10)
+	}
+
+	query := "INSERT INTO alert_share_links (token_hash, rule_id, fingerprint, org_id, expires_at, created_at, created_by) VALUES ($1, $2, $3, $4, $5, $6, $7)"
+
+	id, err := r.insert(r.DB, query, link.TokenHash, link.RuleID, fingerprint, link.OrgId, link.ExpiresAt, link.CreatedAt, link.CreatedBy)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (r *ruleDB) GetShareLink(ctx context.Context, tokenHash string) (*ShareLink, error) {
+	stored := storedShareLink{}
+
+	query := "SELECT id, token_hash, rule_id, fingerprint, org_id, expires_at, created_at, created_by FROM alert_share_links WHERE token_hash=$1"
+
+	err := r.Get(&stored, query, tokenHash)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			zap.L().Error("Error in processing sql query", zap.Error(err))
+		}
+		return nil, err
+	}
+
+	link := stored.shareLink()
+	return &link, nil
+}
+
+func (r *ruleDB) GetShareLinks(ctx context.Context, ruleId string, after time.Time) ([]ShareLink, error) {
+	stored := []storedShareLink{}
+
+	query := "SELECT id, token_hash, rule_id, fingerprint, org_id, expires_at, created_at, created_by FROM alert_share_links WHERE rule_id=$1 AND expires_at > $2 ORDER BY created_at DESC, id DESC"
+
+	err := r.Select(&stored, query, ruleId, after)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return nil, err
+	}
+
+	links := make([]ShareLink, 0, len(stored))
+	for _, s := range stored {
+		links = append(links, s.shareLink())
+	}
+	return links, nil
+}
+
+func (r *ruleDB) DeleteShareLink(ctx context.Context, ruleId string, id int64) error {
+	query := "DELETE FROM alert_share_links WHERE rule_id=$1 AND id=$2"
+
+	res, err := r.Exec(query, ruleId, id)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *ruleDB) AddQueryCosts(ctx context.Context, costs []HourlyQueryCost, before time.Time) error {
+	tx, err := r.Begin()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO rule_query_costs (rule_id, hour, evaluations, read_rows, read_bytes) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (rule_id, hour) DO UPDATE SET evaluations = rule_query_costs.evaluations + excluded.evaluations,
+		read_rows = rule_query_costs.read_rows + excluded.read_rows, read_bytes = rule_query_costs.read_bytes + excluded.read_bytes`)
+	if err != nil {
+		zap.L().Error("Error in preparing statement for INSERT to rule_query_costs", zap.Error(err))
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, c := range costs {
+		_, err = stmt.Exec(c.RuleId, c.Hour, c.Evaluations, int64(c.ReadRows), int64(c.ReadBytes))
+		if err != nil {
+			zap.L().Error("Error in Executing prepared statement for INSERT to rule_query_costs", zap.Error(err))
+			tx.Rollback()
+			return err
+		}
+	}
+
+	_, err = tx.Exec("DELETE FROM rule_query_costs WHERE hour < $1", before)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *ruleDB) GetQueryCosts(ctx context.Context, start, end time.Time) ([]HourlyQueryCost, error) {
+	rows := []struct {
+		RuleId      string    `db:"rule_id"`
+		Hour        time.Time `db:"hour"`
+		Evaluations int64     `db:"evaluations"`
+		ReadRows    int64     `db:"read_rows"`
+		ReadBytes   int64     `db:"read_bytes"`
+	}{}
+
+	query := "SELECT rule_id, hour, evaluations, read_rows, read_bytes FROM rule_query_costs WHERE hour >= $1 AND hour <= $2 ORDER BY hour, rule_id"
+
+	err := r.Select(&rows, query, start, end)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return nil, err
+	}
+
+	costs := make([]HourlyQueryCost, 0, len(rows))
+	for _, row := range rows {
+		costs = append(costs, HourlyQueryCost{
+			RuleId:      row.RuleId,
+			Hour:        row.Hour,
+			Evaluations: row.Evaluations,
+			ReadRows:    uint64(row.ReadRows),
+			ReadBytes:   uint64(row.ReadBytes),
+		})
+	}
+	return costs, nil
+}
+
+func (r *ruleDB) GetCustomUnits(ctx context.Context) ([]converter.CustomUnit, error) {
+	units := []converter.CustomUnit{}
+
+	query := "SELECT name, base, factor, symbol FROM custom_units ORDER BY name"
+
+	err := r.Select(&units, query)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return nil, err
+	}
+
+	return units, nil
+}
+
+func (r *ruleDB) CreateCustomUnit(ctx context.Context, unit converter.CustomUnit) error {
+	email, _ := auth.GetEmailFromJwt(ctx)
+
+	query := "INSERT INTO custom_units (name, base, factor, symbol, created_at, created_by) VALUES ($1, $2, $3, $4, $5, $6)"
+
+	_, err := r.Exec(query, unit.Name, unit.Base, unit.Factor, unit.Symbol, time.Now(), email)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *ruleDB) DeleteCustomUnit(ctx context.Context, name string) error {
+	res, err := r.Exec("DELETE FROM custom_units WHERE name=$1", name)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *ruleDB) GetFormatSettings(ctx context.Context) ([]FormatSettings, error) {
+	rows := []struct {
+		OrgId     string    `db:"org_id"`
+		Data      string    `db:"data"`
+		UpdatedAt time.Time `db:"updated_at"`
+		UpdatedBy string    `db:"updated_by"`
+	}{}
+
+	err := r.Select(&rows, "SELECT org_id, data, updated_at, updated_by FROM org_format_settings ORDER BY org_id")
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return nil, err
+	}
+
+	settings := make([]FormatSettings, 0, len(rows))
+	for _, row := range rows {
+		s := FormatSettings{}
+		if err := json.Unmarshal([]byte(row.Data), &s); err != nil {
+			zap.L().Error("failed to unmarshal the format settings of an org", zap.String("org", row.OrgId), zap.Error(err))
+			continue
+		}
+		s.OrgId, s.UpdatedAt, s.UpdatedBy = row.OrgId, row.UpdatedAt, row.UpdatedBy
+		settings = append(settings, s)
+	}
+	return settings, nil
+}
+
+func (r *ruleDB) SetFormatSettings(ctx context.Context, settings FormatSettings) error {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO org_format_settings (org_id, data, updated_at, updated_by) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (org_id) DO UPDATE SET data = excluded.data, updated_at = excluded.updated_at, updated_by = excluded.updated_by`
+
+	_, err = r.Exec(query, settings.OrgId, string(data), settings.UpdatedAt, settings.UpdatedBy)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *ruleDB) DeleteFormatSettings(ctx context.Context, orgId string) error {
+	res, err := r.Exec("DELETE FROM org_format_settings WHERE org_id=$1", orgId)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *ruleDB) GetCalendars(ctx context.Context) ([]Calendar, error) {
+	calendars := []Calendar{}
+
+	query := "SELECT id, org_id, name, description, events, created_at, created_by, updated_at, updated_by FROM rule_calendars ORDER BY name"
+
+	err := r.Select(&calendars, query)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return nil, err
+	}
+
+	// the requests of a user only see the calendars of their org
+	org := tenantOf(ctx)
+	visible := calendars[:0]
+	for _, c := range calendars {
+		if visibleTo(c.OrgId, org) {
+			visible = append(visible, c)
+		}
+	}
+	return visible, nil
+}
+
+func (r *ruleDB) getCalendarByID(ctx context.Context, id string) (*Calendar, error) {
+	calendar := &Calendar{}
+
+	query := "SELECT id, org_id, name, description, events, created_at, created_by, updated_at, updated_by FROM rule_calendars WHERE id=$1"
+	err := r.Get(calendar, query, id)
+	if err == nil && !visibleTo(calendar.OrgId, tenantOf(ctx)) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return calendar, nil
+}
+
+func (r *ruleDB) CreateCalendar(ctx context.Context, calendar Calendar) (int64, error) {
+	email, _ := auth.GetEmailFromJwt(ctx)
+	calendar.CreatedBy = email
+	calendar.CreatedAt = time.Now()
+	calendar.UpdatedBy = email
+	calendar.UpdatedAt = time.Now()
+	calendar.OrgId = tenantOf(ctx)
+
+	query := "INSERT INTO rule_calendars (org_id, name, description, events, created_at, created_by, updated_at, updated_by) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)"
+
+	id, err := r.insert(r.DB, query, calendar.OrgId, calendar.Name, calendar.Description, calendar.Events, calendar.CreatedAt, calendar.CreatedBy, calendar.UpdatedAt, calendar.UpdatedBy)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (r *ruleDB) EditCalendar(ctx context.Context, calendar Calendar, id string) error {
+	if _, err := r.getCalendarByID(ctx, id); err != nil {
+		return err
+	}
+	email, _ := auth.GetEmailFromJwt(ctx)
+	calendar.UpdatedBy = email
+	calendar.UpdatedAt = time.Now()
+
+	query := "UPDATE rule_calendars SET name=$1, description=$2, events=$3, updated_at=$4, updated_by=$5 WHERE id=$6"
+	_, err := r.Exec(query, calendar.Name, calendar.Description, calendar.Events, calendar.UpdatedAt, calendar.UpdatedBy, id)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *ruleDB) DeleteCalendar(ctx context.Context, id string) error {
+	if _, err := r.getCalendarByID(ctx, id); err != nil {
+		return err
+	}
+	_, err := r.Exec("DELETE FROM rule_calendars WHERE id=$1", id)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *ruleDB) GetServiceEntries(ctx context.Context) ([]ServiceEntry, error) {
+	entries := []ServiceEntry{}
+
+	query := "SELECT id, org_id, service, team, channels, runbook, created_at, created_by, updated_at, updated_by FROM service_catalog ORDER BY service"
+
+	err := r.Select(&entries, query)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return nil, err
+	}
+
+	// the requests of a user only see the catalog of their org
+	org := tenantOf(ctx)
+	visible := entries[:0]
+	for _, e := range entries {
+		if visibleTo(e.OrgId, org) {
+			visible = append(visible, e)
+		}
+	}
+	return visible, nil
+}
+
+func (r *ruleDB) getServiceEntryByID(ctx context.Context, id string) (*ServiceEntry, error) {
+	entry := &ServiceEntry{}
+
+	query := "SELECT id, org_id, service, team, channels, runbook, created_at, created_by, updated_at, updated_by FROM service_catalog WHERE id=$1"
+	err := r.Get(entry, query, id)
+	if err == nil && !visibleTo(entry.OrgId, tenantOf(ctx)) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+func (r *ruleDB) CreateServiceEntry(ctx context.Context, entry ServiceEntry) (int64, error) {
+	email, _ := auth.GetEmailFromJwt(ctx)
+	entry.CreatedBy = email
+	entry.CreatedAt = time.Now()
+	entry.UpdatedBy = email
+	entry.UpdatedAt = time.Now()
+	entry.OrgId = tenantOf(ctx)
+
+	query := "INSERT INTO service_catalog (org_id, service, team, channels, runbook, created_at, created_by, updated_at, updated_by) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)"
+
+	id, err := r.insert(r.DB, query, entry.OrgId, entry.Service, entry.Team, entry.Channels, entry.Runbook, entry.CreatedAt, entry.CreatedBy, entry.UpdatedAt, entry.UpdatedBy)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (r *ruleDB) EditServiceEntry(ctx context.Context, entry ServiceEntry, id string) error {
+	if _, err := r.getServiceEntryByID(ctx, id); err != nil {
+		return err
+	}
+	email, _ := auth.GetEmailFromJwt(ctx)
+	entry.UpdatedBy = email
+	entry.UpdatedAt = time.Now()
+
+	query := "UPDATE service_catalog SET service=$1, team=$2, channels=$3, runbook=$4, updated_at=$5, updated_by=$6 WHERE id=$7"
+	_, err := r.Exec(query, entry.Service, entry.Team, entry.Channels, entry.Runbook, entry.UpdatedAt, entry.UpdatedBy, id)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *ruleDB) DeleteServiceEntry(ctx context.Context, id string) error {
+	if _, err := r.getServiceEntryByID(ctx, id); err != nil {
+		return err
+	}
+	_, err := r.Exec("DELETE FROM service_catalog WHERE id=$1", id)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *ruleDB) GetAlertViews(ctx context.Context) ([]AlertView, error) {
+	views := []AlertView{}
+
+	query := "SELECT id, org_id, name, description, shared, query, created_at, created_by, updated_at, updated_by FROM alert_views ORDER BY name"
+
+	err := r.Select(&views, query)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return nil, err
+	}
+
+	org := tenantOf(ctx)
+	visible := views[:0]
+	for _, v := range views {
+		if visibleTo(v.OrgId, org) {
+			visible = append(visible, v)
+		}
+	}
+	return visible, nil
+}
+
+func (r *ruleDB) GetAlertView(ctx context.Context, id string) (*AlertView, error) {
+	view := &AlertView{}
+
+	query := "SELECT id, org_id, name, description, shared, query, created_at, created_by, updated_at, updated_by FROM alert_views WHERE id=$1"
+	err := r.Get(view, query, id)
+	if err == nil && !visibleTo(view.OrgId, tenantOf(ctx)) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return view, nil
+}
+
+func (r *ruleDB) CreateAlertView(ctx context.Context, view AlertView) (int64, error) {
+	var email string
+	if user := common.GetUserFromContext(ctx); user != nil {
+		email = user.Email
+	}
+	view.CreatedBy = email
+	view.CreatedAt = time.Now()
+	view.UpdatedBy = email
+	view.UpdatedAt = time.Now()
+	view.OrgId = tenantOf(ctx)
+
+	query := "INSERT INTO alert_views (org_id, name, description, shared, query, created_at, created_by, updated_at, updated_by) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)"
+
+	id, err := r.insert(r.DB, query, view.OrgId, view.Name, view.Description, view.Shared, view.Query, view.CreatedAt, view.CreatedBy, view.UpdatedAt, view.UpdatedBy)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (r *ruleDB) EditAlertView(ctx context.Context, view AlertView, id string) error {
+	if _, err := r.GetAlertView(ctx, id); err != nil {
+		return err
+	}
+	if user := common.GetUserFromContext(ctx); user != nil {
+		view.UpdatedBy = user.Email
+	}
+	view.UpdatedAt = time.Now()
+
+	query := "UPDATE alert_views SET name=$1, description=$2, shared=$3, query=$4, updated_at=$5, updated_by=$6 WHERE id=$7"
+	_, err := r.Exec(query, view.Name, view.Description, view.Shared, view.Query, view.UpdatedAt, view.UpdatedBy, id)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *ruleDB) DeleteAlertView(ctx context.Context, id string) error {
+	if _, err := r.GetAlertView(ctx, id); err != nil {
+		return err
+	}
+	_, err := r.Exec("DELETE FROM alert_views WHERE id=$1", id)
+	if err != nil {
+		zap.L().Error("Error in processing sql query", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
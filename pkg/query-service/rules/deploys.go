@@ -0,0 +1,244 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// The actions a rule takes on its alerts starting shortly after a deploy
+// of their service
+const (
+	// DeploySuppress holds back the notifications of the alerts until the
+	// window after the deploy is over, the alerts still firing then are
+	// sent
+	DeploySuppress = "suppress"
+	// DeployDownrank lowers the severity of the alerts by one level
+	DeployDownrank = "downrank"
+)
+
+const (
+	// DefaultDeployWindow is how long after a deploy of their service the
+	// alerts starting are tagged with it
+	DefaultDeployWindow = 30 * time.Minute
+	// MaxDeployWindow bounds the window of the deploy policies
+	MaxDeployWindow = 6 * time.Hour
+
+	// The annotations describing the deploy an alert started after
+	DeployAnnotation   = "deploy"
+	DeployAtAnnotation = "deploy_at"
+	// DownrankedLabel marks the alerts whose severity was lowered after a
+	// deploy, it holds the original severity
+	DownrankedLabel = "deploy_downranked"
+)
+
+// severityLabels are the labels holding the severity of an alert, in the
+// order they are looked up
+var severityLabels = []string{"severity", "priority"}
+
+// DeployPolicy is how a rule treats its alerts starting within the window
+// after a deploy of their service
+type DeployPolicy struct {
+	Action string `yaml:"action" json:"action"`
+	// Window is how long after the deploy the policy applies,
+	// DefaultDeployWindow when not set
+	Window Duration `yaml:"window,omitempty" json:"window,omitempty"`
+}
+
+func (p *DeployPolicy) Validate() error {
+	switch p.Action {
+	case DeploySuppress, DeployDownrank:
+	default:
+		return fmt.Errorf("deploy policy action must be %s or %s", DeploySuppress, DeployDownrank)
+	}
+	if p.Window < 0 || time.Duration(p.Window) > MaxDeployWindow {
+		return fmt.Errorf("deploy policy window must be between 0 and %s", MaxDeployWindow)
+	}
+	return nil
+}
+
+func (p *DeployPolicy) window() time.Duration {
+	if p == nil || p.Window == 0 {
+		return DefaultDeployWindow
+	}
+	return time.Duration(p.Window)
+}
+
+// deployAwareRule is implemented by the rules with a deploy policy
+type deployAwareRule interface {
+	DeployPolicy() *DeployPolicy
+}
+
+// DeployMarker registers a deploy of a service, it is recorded as a
+// deployment change
+type DeployMarker struct {
+	Service     string            `json:"service"`
+	Version     string            `json:"version,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	Title       string            `json:"title,omitempty"`
+	Source      string            `json:"source,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Timestamp   time.Time         `json:"timestamp,omitempty"`
+}
+
+// changeEvent returns the deployment change of the marker
+func (d *DeployMarker) changeEvent() (*ChangeEvent, error) {
+	if d.Service == "" {
+		return nil, fmt.Errorf("service is required")
+	}
+	lbls := make(map[string]string, len(d.Labels)+3)
+	for k, v := range d.Labels {
+		lbls[k] = v
+	}
+	lbls["service_name"] = d.Service
+	if d.Version != "" {
+		lbls["version"] = d.Version
+	}
+	if d.Environment != "" {
+		lbls["deployment_environment"] = d.Environment
+	}
+	title := d.Title
+	if title == "" {
+		title = "deploy " + d.Service
+		if d.Version != "" {
+			title += " " + d.Version
+		}
+	}
+	return &ChangeEvent{
+		Kind:        ChangeDeployment,
+		Source:      d.Source,
+		Title:       title,
+		Description: d.Description,
+		Labels:      lbls,
+		Timestamp:   d.Timestamp,
+	}, nil
+}
+
+// AddDeployMarker records the deploy of the service
+func (m *Manager) AddDeployMarker(ctx context.Context, d *DeployMarker) (*ChangeEvent, *model.ApiError) {
+	e, err := d.changeEvent()
+	if err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorBadData, Err: err}
+	}
+	return m.AddChangeEvent(ctx, e)
+}
+
+// lastDeploy returns the latest deploy of the service made within the
+// window before the alert became active, nil when there is none
+func lastDeploy(changes []ChangeEvent, service string, activeAt time.Time, window time.Duration) *ChangeEvent {
+	var last *ChangeEvent
+	for i := range changes {
+		c := &changes[i]
+		if c.Kind != ChangeDeployment || serviceName(labels.FromMap(c.Labels)) != service {
+			continue
+		}
+		if c.Timestamp.After(activeAt) || !activeAt.Before(c.Timestamp.Add(window)) {
+			continue
+		}
+		if last == nil || c.Timestamp.After(last.Timestamp) {
+			last = c
+		}
+	}
+	return last
+}
+
+// downrank returns the severity one level below, the lowest severity and
+// the unknown ones are kept
+func downrank(severity string) string {
+	rank := severityRanks[severity]
+	if rank <= 1 {
+		return severity
+	}
+	for s, r := range severityRanks {
+		if r == rank-1 {
+			return s
+		}
+	}
+	return severity
+}
+
+// applyDeploys tags the alerts that started within the window after a
+// deploy of their service and applies the deploy policy of the rule to
+// them, the alerts held back are left out of the returned ones
+func applyDeploys(policy *DeployPolicy, changes []ChangeEvent, alerts []*Alert, now time.Time) []*Alert {
+	if len(changes) == 0 {
+		return alerts
+	}
+	window := policy.window()
+	sent := alerts[:0]
+	for _, a := range alerts {
+		service := serviceName(labels.FromMap(a.Labels.Map()))
+		if service == "" {
+			sent = append(sent, a)
+			continue
+		}
+		deploy := lastDeploy(changes, service, a.ActiveAt, window)
+		if deploy == nil {
+			sent = append(sent, a)
+			continue
+		}
+		if policy != nil && policy.Action == DeploySuppress && a.State == StateFiring && now.Before(deploy.Timestamp.Add(window)) {
+			continue
+		}
+
+		a.Annotations = labels.NewBuilder(labels.FromMap(a.Annotations.Map())).
+			Set(DeployAnnotation, deployDescription(deploy)).
+			Set(DeployAtAnnotation, deploy.Timestamp.UTC().Format(time.RFC3339)).
+			Labels()
+		if policy != nil && policy.Action == DeployDownrank {
+			a.Labels = downrankLabels(a.Labels)
+		}
+		sent = append(sent, a)
+	}
+	return sent
+}
+
+func deployDescription(d *ChangeEvent) string {
+	desc := d.Title
+	if d.Source != "" {
+		desc += " from " + d.Source
+	}
+	return desc
+}
+
+// downrankLabels lowers the severity label of the alert by one level
+func downrankLabels(lbls labels.BaseLabels) labels.BaseLabels {
+	for _, name := range severityLabels {
+		severity := lbls.Get(name)
+		if severity == "" {
+			continue
+		}
+		lowered := downrank(severity)
+		if lowered == severity {
+			return lbls
+		}
+		return labels.NewBuilder(labels.FromMap(lbls.Map())).
+			Set(name, lowered).
+			Set(DownrankedLabel, severity).
+			Labels()
+	}
+	return lbls
+}
+
+// deployNotifyFunc applies the deploys of the services to the alerts of
+// the rule before they are sent
+func deployNotifyFunc(opts *ManagerOptions, rule Rule, notify NotifyFunc) NotifyFunc {
+	if opts.deploys == nil {
+		return notify
+	}
+	var policy *DeployPolicy
+	if r, ok := rule.(deployAwareRule); ok {
+		policy = r.DeployPolicy()
+	}
+	return func(ctx context.Context, expr string, alerts ...*Alert) {
+		if len(alerts) > 0 {
+			now := time.Now()
+			alerts = applyDeploys(policy, opts.deploys.recent(ctx, 0, now), alerts, now)
+		}
+		notify(ctx, expr, alerts...)
+	}
+}
@@ -0,0 +1,85 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+func TestDeployMarkerChangeEvent(t *testing.T) {
+	_, err := (&DeployMarker{}).changeEvent()
+	assert.Error(t, err)
+
+	e, err := (&DeployMarker{Service: "checkout", Version: "v1.2.0", Environment: "prod", Source: "github"}).changeEvent()
+	require.NoError(t, err)
+	assert.Equal(t, ChangeDeployment, e.Kind)
+	assert.Equal(t, "deploy checkout v1.2.0", e.Title)
+	assert.Equal(t, map[string]string{"service_name": "checkout", "version": "v1.2.0", "deployment_environment": "prod"}, e.Labels)
+	assert.NoError(t, e.Validate())
+}
+
+func TestDeployPolicyValidate(t *testing.T) {
+	assert.NoError(t, (&DeployPolicy{Action: DeploySuppress}).Validate())
+	assert.NoError(t, (&DeployPolicy{Action: DeployDownrank, Window: Duration(time.Hour)}).Validate())
+	assert.Error(t, (&DeployPolicy{Action: "mute"}).Validate())
+	assert.Error(t, (&DeployPolicy{Action: DeploySuppress, Window: Duration(7 * time.Hour)}).Validate())
+}
+
+func TestApplyDeploys(t *testing.T) {
+	deployAt := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	changes := []ChangeEvent{
+		{Kind: ChangeDeployment, Title: "deploy checkout v2", Source: "github", Labels: map[string]string{"service_name": "checkout"}, Timestamp: deployAt},
+		{Kind: ChangeConfig, Title: "flag flip", Labels: map[string]string{"service_name": "cart"}, Timestamp: deployAt},
+	}
+	alerts := func() []*Alert {
+		return []*Alert{
+			// started after the deploy of its service
+			{State: StateFiring, ActiveAt: deployAt.Add(5 * time.Minute), Labels: labels.FromMap(map[string]string{"service.name": "checkout", "severity": "critical"}), Annotations: labels.FromMap(nil)},
+			// started before the deploy
+			{State: StateFiring, ActiveAt: deployAt.Add(-time.Minute), Labels: labels.FromMap(map[string]string{"service_name": "checkout", "severity": "critical"}), Annotations: labels.FromMap(nil)},
+			// no deploy of its service, only a config change
+			{State: StateFiring, ActiveAt: deployAt.Add(5 * time.Minute), Labels: labels.FromMap(map[string]string{"service_name": "cart"}), Annotations: labels.FromMap(nil)},
+		}
+	}
+	now := deployAt.Add(10 * time.Minute)
+
+	// without a policy the alerts are only tagged
+	sent := applyDeploys(nil, changes, alerts(), now)
+	require.Len(t, sent, 3)
+	assert.Equal(t, "deploy checkout v2 from github", sent[0].Annotations.Get(DeployAnnotation))
+	assert.Equal(t, "2026-10-15T12:00:00Z", sent[0].Annotations.Get(DeployAtAnnotation))
+	assert.Equal(t, "critical", sent[0].Labels.Get("severity"))
+	assert.Empty(t, sent[1].Annotations.Get(DeployAnnotation))
+	assert.Empty(t, sent[2].Annotations.Get(DeployAnnotation))
+
+	// the alert is held back within the window and sent after it
+	suppress := &DeployPolicy{Action: DeploySuppress}
+	sent = applyDeploys(suppress, changes, alerts(), now)
+	require.Len(t, sent, 2)
+	assert.Equal(t, deployAt.Add(-time.Minute), sent[0].ActiveAt)
+	sent = applyDeploys(suppress, changes, alerts(), deployAt.Add(DefaultDeployWindow))
+	require.Len(t, sent, 3)
+	assert.NotEmpty(t, sent[0].Annotations.Get(DeployAnnotation))
+
+	// the alerts starting past the window are not tagged
+	sent = applyDeploys(&DeployPolicy{Action: DeploySuppress, Window: Duration(time.Minute)}, changes, alerts(), now)
+	require.Len(t, sent, 3)
+	assert.Empty(t, sent[0].Annotations.Get(DeployAnnotation))
+
+	sent = applyDeploys(&DeployPolicy{Action: DeployDownrank}, changes, alerts(), now)
+	require.Len(t, sent, 3)
+	assert.Equal(t, "error", sent[0].Labels.Get("severity"))
+	assert.Equal(t, "critical", sent[0].Labels.Get(DownrankedLabel))
+	assert.Equal(t, "critical", sent[1].Labels.Get("severity"))
+}
+
+func TestDownrank(t *testing.T) {
+	assert.Equal(t, "error", downrank("critical"))
+	assert.Equal(t, "warning", downrank("error"))
+	assert.Equal(t, "info", downrank("warning"))
+	assert.Equal(t, "info", downrank("info"))
+	assert.Equal(t, "P1", downrank("P1"))
+}
@@ -0,0 +1,287 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrEvalInProgress is returned when the previous evaluation of the
+	// rule is still queued or running
+	ErrEvalInProgress = errors.New("previous evaluation of the rule is still in progress")
+	// ErrEvalPoolStopped is returned when the pool no longer runs evaluations
+	ErrEvalPoolStopped = errors.New("rule evaluation pool is stopped")
+)
+
+const DefaultEvalWorkers = 10
+
+const (
+	EvalJobQueued  = "queued"
+	EvalJobRunning = "running"
+)
+
+// EvalPoolJob is an evaluation waiting for or running in a worker of the
+// pool, Since is when it was queued or started
+type EvalPoolJob struct {
+	RuleID string    `json:"ruleId"`
+	Tenant string    `json:"tenant,omitempty"`
+	State  string    `json:"state"`
+	Since  time.Time `json:"since"`
+}
+
+// evalJob is a single rule evaluation waiting for or running in a worker
+type evalJob struct {
+	key     string
+	tenant  string
+	ctx     context.Context
+	timeout time.Duration
+	fn      func(ctx context.Context)
+	done    chan struct{}
+	// queued and started are when the job was submitted and picked by a
+	// worker
+	queued  time.Time
+	started time.Time
+}
+
+// EvalPool evaluates rules on a bounded number of workers so that a slow
+// query only holds up its own worker. Evaluations are started in the order
+// they are submitted and a rule can have a single evaluation queued or
+// running at a time, which keeps rules with short frequencies or slow
+// queries from taking the place of the others. The workers are shared
+// fairly between the tenants of the rules: the next evaluation is the
+// oldest one of the tenant running the fewest evaluations, and of the
+// tenant served the longest ago among them.
+type EvalPool struct {
+	workers int
+
+	// tenantOf returns the tenant of the rule of a key, tenantWorkers
+	// caps the evaluations running for a tenant when positive
+	tenantOf      func(key string) string
+	tenantWorkers int
+
+	mtx     sync.Mutex
+	cond    *sync.Cond
+	queue   []*evalJob
+	pending map[string]struct{}
+	running map[string]int
+	// active holds the jobs running on the workers by key
+	active map[string]*evalJob
+	// turns holds the sequence of the last evaluation started by tenant
+	turns   map[string]uint64
+	turn    uint64
+	started bool
+	stopped bool
+
+	wg sync.WaitGroup
+}
+
+func NewEvalPool(workers int) *EvalPool {
+	if workers <= 0 {
+		workers = DefaultEvalWorkers
+	}
+	p := &EvalPool{
+		workers: workers,
+		pending: map[string]struct{}{},
+		running: map[string]int{},
+		active:  map[string]*evalJob{},
+		turns:   map[string]uint64{},
+	}
+	p.cond = sync.NewCond(&p.mtx)
+	return p
+}
+
+// Start starts the workers, evaluations submitted before are queued
+func (p *EvalPool) Start() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.started || p.stopped {
+		return
+	}
+	p.started = true
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+}
+
+// SetTenants shares the workers between the tenants of the rules, at most
+// workers evaluations of a tenant run at a time when positive
+func (p *EvalPool) SetTenants(tenantOf func(key string) string, workers int) {
+	if p == nil {
+		return
+	}
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.tenantOf = tenantOf
+	p.tenantWorkers = workers
+}
+
+// Stop waits for the running evaluations to finish, queued evaluations
+// whose context is done are dropped
+func (p *EvalPool) Stop() {
+	p.mtx.Lock()
+	p.stopped = true
+	p.cond.Broadcast()
+	p.mtx.Unlock()
+	p.wg.Wait()
+}
+
+// Run evaluates fn on a worker with a context that times out after the
+// timeout, and waits for it to finish or for ctx to be done. A nil pool
+// evaluates fn on the calling goroutine.
+func (p *EvalPool) Run(ctx context.Context, key string, timeout time.Duration, fn func(ctx context.Context)) error {
+	job := &evalJob{key: key, ctx: ctx, timeout: timeout, fn: fn, done: make(chan struct{}), queued: time.Now()}
+	if p == nil {
+		job.run()
+		return nil
+	}
+
+	p.mtx.Lock()
+	if p.stopped {
+		p.mtx.Unlock()
+		return ErrEvalPoolStopped
+	}
+	if _, ok := p.pending[key]; ok {
+		p.mtx.Unlock()
+		return ErrEvalInProgress
+	}
+	p.pending[key] = struct{}{}
+	if p.tenantOf != nil {
+		job.tenant = p.tenantOf(key)
+	}
+	p.queue = append(p.queue, job)
+	p.cond.Signal()
+	p.mtx.Unlock()
+
+	select {
+	case <-job.done:
+		return nil
+	case <-ctx.Done():
+		// the job is skipped by the worker if it has not started, or its
+		// context is canceled if it has
+		return ctx.Err()
+	}
+}
+
+// Queued returns the number of evaluations waiting for a worker
+func (p *EvalPool) Queued() int {
+	if p == nil {
+		return 0
+	}
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return len(p.queue)
+}
+
+// Busy tells if an evaluation of the key is queued or running
+func (p *EvalPool) Busy(key string) bool {
+	if p == nil {
+		return false
+	}
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	_, ok := p.pending[key]
+	return ok
+}
+
+// Jobs returns the evaluations waiting for a worker in the order they
+// were submitted, followed by the running ones
+func (p *EvalPool) Jobs() []EvalPoolJob {
+	if p == nil {
+		return nil
+	}
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	jobs := make([]EvalPoolJob, 0, len(p.queue)+len(p.active))
+	for _, job := range p.queue {
+		jobs = append(jobs, EvalPoolJob{RuleID: job.key, Tenant: job.tenant, State: EvalJobQueued, Since: job.queued})
+	}
+	running := make([]EvalPoolJob, 0, len(p.active))
+	for _, job := range p.active {
+		running = append(running, EvalPoolJob{RuleID: job.key, Tenant: job.tenant, State: EvalJobRunning, Since: job.started})
+	}
+	sort.Slice(running, func(i, j int) bool { return running[i].Since.Before(running[j].Since) })
+	return append(jobs, running...)
+}
+
+// next returns the index of the next job in the queue, -1 when none can
+// start because the tenants of the jobs are at their cap. The lock is held.
+func (p *EvalPool) next() int {
+	next := -1
+	for i, job := range p.queue {
+		running := p.running[job.tenant]
+		if p.tenantWorkers > 0 && running >= p.tenantWorkers {
+			continue
+		}
+		if next < 0 {
+			next = i
+			continue
+		}
+		best := p.queue[next].tenant
+		if running < p.running[best] || running == p.running[best] && p.turns[job.tenant] < p.turns[best] {
+			next = i
+		}
+	}
+	return next
+}
+
+func (p *EvalPool) work() {
+	defer p.wg.Done()
+	for {
+		p.mtx.Lock()
+		i := p.next()
+		for i < 0 {
+			if len(p.queue) == 0 && p.stopped {
+				p.mtx.Unlock()
+				return
+			}
+			p.cond.Wait()
+			i = p.next()
+		}
+		job := p.queue[i]
+		copy(p.queue[i:], p.queue[i+1:])
+		p.queue[len(p.queue)-1] = nil
+		p.queue = p.queue[:len(p.queue)-1]
+		p.running[job.tenant]++
+		job.started = time.Now()
+		p.active[job.key] = job
+		p.turn++
+		p.turns[job.tenant] = p.turn
+		p.mtx.Unlock()
+
+		if job.ctx.Err() == nil {
+			job.run()
+		}
+
+		p.mtx.Lock()
+		delete(p.pending, job.key)
+		delete(p.active, job.key)
+		if p.running[job.tenant]--; p.running[job.tenant] == 0 {
+			delete(p.running, job.tenant)
+		}
+		// a job of a tenant at its cap may start now
+		p.cond.Broadcast()
+		p.mtx.Unlock()
+		close(job.done)
+	}
+}
+
+func (j *evalJob) run() {
+	ctx := j.ctx
+	if j.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.timeout)
+		defer cancel()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			zap.L().Error("panic in rule evaluation", zap.String("rule", j.key), zap.Any("panic", r))
+		}
+	}()
+	j.fn(ctx)
+}
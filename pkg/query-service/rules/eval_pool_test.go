@@ -0,0 +1,183 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvalPoolBoundsConcurrency(t *testing.T) {
+	pool := NewEvalPool(2)
+	pool.Start()
+	defer pool.Stop()
+
+	var running, maxRunning int32
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := pool.Run(context.Background(), fmt.Sprintf("rule-%d", i), time.Second, func(ctx context.Context) {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					m := atomic.LoadInt32(&maxRunning)
+					if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+						break
+					}
+				}
+				time.Sleep(20 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+			})
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), maxRunning)
+}
+
+func TestEvalPoolSlowRule(t *testing.T) {
+	pool := NewEvalPool(2)
+	pool.Start()
+	defer pool.Stop()
+
+	// the slow rule holds one worker until its timeout
+	slowStarted := make(chan struct{})
+	slowDone := make(chan error, 1)
+	go func() {
+		slowDone <- pool.Run(context.Background(), "slow", 200*time.Millisecond, func(ctx context.Context) {
+			close(slowStarted)
+			<-ctx.Done()
+		})
+	}()
+	<-slowStarted
+
+	// another evaluation of the slow rule is not queued behind it
+	err := pool.Run(context.Background(), "slow", time.Second, func(ctx context.Context) {})
+	assert.ErrorIs(t, err, ErrEvalInProgress)
+
+	// the other rules are evaluated on the free worker
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		err := pool.Run(context.Background(), fmt.Sprintf("fast-%d", i), time.Second, func(ctx context.Context) {})
+		assert.NoError(t, err)
+	}
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+
+	assert.NoError(t, <-slowDone)
+}
+
+func TestEvalPoolCanceledWhileQueued(t *testing.T) {
+	pool := NewEvalPool(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	called := false
+	err := pool.Run(ctx, "rule", time.Second, func(ctx context.Context) { called = true })
+	assert.ErrorIs(t, err, context.Canceled)
+
+	pool.Start()
+	pool.Stop()
+	assert.False(t, called)
+
+	err = pool.Run(context.Background(), "rule", time.Second, func(ctx context.Context) {})
+	assert.ErrorIs(t, err, ErrEvalPoolStopped)
+}
+
+func TestEvalPoolFairShare(t *testing.T) {
+	pool := NewEvalPool(1)
+	pool.SetTenants(func(key string) string { return strings.Split(key, "-")[0] }, 0)
+
+	var mtx sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	// the evaluations are queued before the worker starts, the ones of
+	// org a first
+	for i, key := range []string{"a-1", "a-2", "a-3", "b-1"} {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			err := pool.Run(context.Background(), key, time.Second, func(ctx context.Context) {
+				mtx.Lock()
+				order = append(order, key)
+				mtx.Unlock()
+			})
+			assert.NoError(t, err)
+		}(key)
+		for pool.Queued() != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	pool.Start()
+	defer pool.Stop()
+	wg.Wait()
+
+	assert.Equal(t, []string{"a-1", "b-1", "a-2", "a-3"}, order)
+}
+
+func TestEvalPoolTenantWorkers(t *testing.T) {
+	pool := NewEvalPool(4)
+	pool.SetTenants(func(key string) string { return strings.Split(key, "-")[0] }, 1)
+	pool.Start()
+	defer pool.Stop()
+
+	var running, maxRunning int32
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := pool.Run(context.Background(), fmt.Sprintf("a-%d", i), time.Second, func(ctx context.Context) {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					m := atomic.LoadInt32(&maxRunning)
+					if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+						break
+					}
+				}
+				time.Sleep(20 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+			})
+			assert.NoError(t, err)
+		}(i)
+	}
+
+	// the other orgs are not held up by the org at its cap
+	start := time.Now()
+	err := pool.Run(context.Background(), "b-1", time.Second, func(ctx context.Context) {})
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), 20*time.Millisecond)
+
+	wg.Wait()
+	assert.Equal(t, int32(1), maxRunning)
+}
+
+func TestEvalPoolJobs(t *testing.T) {
+	pool := NewEvalPool(1)
+	pool.Start()
+	defer pool.Stop()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	go pool.Run(context.Background(), "slow", time.Second, func(ctx context.Context) {
+		close(started)
+		<-release
+	})
+	<-started
+	go pool.Run(context.Background(), "queued", time.Second, func(ctx context.Context) {})
+	assert.Eventually(t, func() bool { return pool.Queued() == 1 }, time.Second, time.Millisecond)
+
+	jobs := pool.Jobs()
+	assert.Len(t, jobs, 2)
+	assert.Equal(t, []string{"queued", "slow"}, []string{jobs[0].RuleID, jobs[1].RuleID})
+	assert.Equal(t, []string{EvalJobQueued, EvalJobRunning}, []string{jobs[0].State, jobs[1].State})
+	assert.True(t, pool.Busy("slow"))
+
+	close(release)
+	assert.Eventually(t, func() bool { return len(pool.Jobs()) == 0 }, time.Second, time.Millisecond)
+	assert.False(t, pool.Busy("slow"))
+}
@@ -0,0 +1,139 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	pql "github.com/prometheus/prometheus/promql"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxEvaluationSamples caps the series sampled into an evaluation record
+	maxEvaluationSamples = 20
+	// DefaultEvaluationsLimit is the number of evaluations returned when the
+	// request does not set a limit
+	DefaultEvaluationsLimit = 20
+)
+
+// EvaluationSample is the latest value of a series returned by the query
+// of a rule
+type EvaluationSample struct {
+	Labels    map[string]string `json:"labels"`
+	Timestamp int64             `json:"timestamp"`
+	Value     float64           `json:"value"`
+}
+
+// EvaluationRecord is an entry of the evaluation log of a rule, it holds
+// what the rule queried and saw so that users can find out why a rule
+// did or did not fire at a given time
+type EvaluationRecord struct {
+	Id          int64              `json:"id" db:"id"`
+	RuleId      string             `json:"ruleId" db:"rule_id"`
+	EvaluatedAt time.Time          `json:"evaluatedAt" db:"evaluated_at"`
+	DurationMs  int64              `json:"durationMs" db:"duration_ms"`
+	Query       string             `json:"query" db:"query"`
+	Series      int                `json:"series" db:"series"`
+	Samples     []EvaluationSample `json:"samples" db:"-"`
+	Error       string             `json:"error,omitempty" db:"error"`
+}
+
+// evaluationDetails is what a rule saw on its last evaluation
+type evaluationDetails struct {
+	query   string
+	series  int
+	samples []EvaluationSample
+}
+
+// evaluationDetailer is implemented by the rules that keep the details
+// of their last evaluation
+type evaluationDetailer interface {
+	lastEvaluationDetails() evaluationDetails
+}
+
+// renderedQuery returns the queries of the rule as they were run, with
+// the template variables replaced
+func renderedQuery(cq *v3.CompositeQuery) string {
+	var queries []string
+	switch cq.QueryType {
+	case v3.QueryTypeClickHouseSQL:
+		for name, q := range cq.ClickHouseQueries {
+			queries = append(queries, fmt.Sprintf("%s: %s", name, q.Query))
+		}
+	case v3.QueryTypePromQL:
+		for name, q := range cq.PromQueries {
+			queries = append(queries, fmt.Sprintf("%s: %s", name, q.Query))
+		}
+	default:
+		b, err := json.Marshal(cq.BuilderQueries)
+		if err != nil {
+			return ""
+		}
+		return string(b)
+	}
+	sort.Strings(queries)
+	return strings.Join(queries, "\n")
+}
+
+func seriesSamples(series []*v3.Series) []EvaluationSample {
+	samples := []EvaluationSample{}
+	for _, s := range series {
+		if len(samples) == maxEvaluationSamples {
+			break
+		}
+		if len(s.Points) == 0 {
+			continue
+		}
+		last := s.Points[len(s.Points)-1]
+		samples = append(samples, EvaluationSample{Labels: s.Labels, Timestamp: last.Timestamp, Value: last.Value})
+	}
+	return samples
+}
+
+func matrixSamples(matrix pql.Matrix) []EvaluationSample {
+	samples := []EvaluationSample{}
+	for _, s := range matrix {
+		if len(samples) == maxEvaluationSamples {
+			break
+		}
+		if len(s.Floats) == 0 {
+			continue
+		}
+		last := s.Floats[len(s.Floats)-1]
+		samples = append(samples, EvaluationSample{Labels: s.Metric.Map(), Timestamp: last.T, Value: last.F})
+	}
+	return samples
+}
+
+// recordEvaluation adds the evaluation of the rule at ts to its log, the
+// log keeps the last opts.EvalLogSize evaluations of every rule
+func recordEvaluation(ctx context.Context, db RuleDB, opts *ManagerOptions, rule Rule, ts time.Time, duration time.Duration, err error) {
+	if opts.EvalLogSize <= 0 || db == nil {
+		return
+	}
+	record := EvaluationRecord{
+		RuleId:      rule.ID(),
+		EvaluatedAt: ts,
+		DurationMs:  duration.Milliseconds(),
+		Samples:     []EvaluationSample{},
+	}
+	if r, ok := rule.(evaluationDetailer); ok {
+		details := r.lastEvaluationDetails()
+		record.Query = details.query
+		record.Series = details.series
+		if details.samples != nil {
+			record.Samples = details.samples
+		}
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if err := db.AddRuleEvaluation(ctx, record, opts.EvalLogSize); err != nil {
+		zap.L().Error("failed to record the rule evaluation", zap.String("ruleid", rule.ID()), zap.Error(err))
+	}
+}
@@ -0,0 +1,105 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+type evaluationLogDB struct {
+	RuleDB
+	keep    int
+	records []EvaluationRecord
+}
+
+func (db *evaluationLogDB) AddRuleEvaluation(ctx context.Context, record EvaluationRecord, keep int) error {
+	db.keep = keep
+	db.records = append(db.records, record)
+	return nil
+}
+
+func TestRenderedQuery(t *testing.T) {
+	cq := &v3.CompositeQuery{
+		QueryType: v3.QueryTypeClickHouseSQL,
+		ClickHouseQueries: map[string]*v3.ClickHouseQuery{
+			"B": {Query: "SELECT 2"},
+			"A": {Query: "SELECT 1"},
+		},
+	}
+	assert.Equal(t, "A: SELECT 1\nB: SELECT 2", renderedQuery(cq))
+
+	cq = &v3.CompositeQuery{
+		QueryType:   v3.QueryTypePromQL,
+		PromQueries: map[string]*v3.PromQuery{"A": {Query: "up == 0"}},
+	}
+	assert.Equal(t, "A: up == 0", renderedQuery(cq))
+}
+
+func TestSeriesSamples(t *testing.T) {
+	series := []*v3.Series{{Labels: map[string]string{"service": "empty"}}}
+	for i := 0; i < 2*maxEvaluationSamples; i++ {
+		series = append(series, &v3.Series{
+			Labels: map[string]string{"service": "frontend"},
+			Points: []v3.Point{{Timestamp: 1, Value: 1}, {Timestamp: 2, Value: float64(i)}},
+		})
+	}
+
+	samples := seriesSamples(series)
+	require.Len(t, samples, maxEvaluationSamples)
+	assert.Equal(t, EvaluationSample{Labels: map[string]string{"service": "frontend"}, Timestamp: 2, Value: 0}, samples[0])
+}
+
+func TestRecordEvaluation(t *testing.T) {
+	target := 10.0
+	postableRule := PostableRule{
+		AlertName:  "High error rate",
+		AlertType:  "METRIC_BASED_ALERT",
+		RuleType:   RuleTypeThreshold,
+		EvalWindow: Duration(5 * time.Minute),
+		Frequency:  Duration(1 * time.Minute),
+		RuleCondition: &RuleCondition{
+			CompositeQuery: &v3.CompositeQuery{
+				QueryType: v3.QueryTypeClickHouseSQL,
+				ClickHouseQueries: map[string]*v3.ClickHouseQuery{
+					"A": {Query: "SELECT 1"},
+				},
+			},
+			CompareOp: ValueIsAbove,
+			MatchType: AtleastOnce,
+			Target:    &target,
+		},
+	}
+	rule, err := NewThresholdRule("3", &postableRule, ThresholdRuleOpts{}, nil, nil)
+	require.NoError(t, err)
+	rule.lastEvaluation = evaluationDetails{
+		query:   "A: SELECT 1",
+		series:  1,
+		samples: []EvaluationSample{{Labels: map[string]string{"service": "frontend"}, Timestamp: 1, Value: 5}},
+	}
+
+	db := &evaluationLogDB{}
+	ts := time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC)
+
+	// the log is disabled by default
+	recordEvaluation(context.Background(), db, &ManagerOptions{}, rule, ts, time.Second, nil)
+	assert.Empty(t, db.records)
+
+	opts := &ManagerOptions{EvalLogSize: 50}
+	recordEvaluation(context.Background(), db, opts, rule, ts, 1500*time.Millisecond, errors.New("query timed out"))
+	require.Len(t, db.records, 1)
+	assert.Equal(t, 50, db.keep)
+	assert.Equal(t, EvaluationRecord{
+		RuleId:      "3",
+		EvaluatedAt: ts,
+		DurationMs:  1500,
+		Query:       "A: SELECT 1",
+		Series:      1,
+		Samples:     rule.lastEvaluation.samples,
+		Error:       "query timed out",
+	}, db.records[0])
+}
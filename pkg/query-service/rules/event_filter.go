@@ -0,0 +1,64 @@
+package rules
+
+import (
+	"fmt"
+	"slices"
+
+	plabels "github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// EventFilter selects the events streamed to a subscriber, all the events
+// are selected when it is empty
+type EventFilter struct {
+	// Matchers is a selector over the labels of the alerts with the rule
+	// name as alertname and the rule id as ruleId, e.g.
+	// {service_name="checkout",severity=~"critical|error"}. The health
+	// events are matched on the labels of the rule.
+	Matchers string
+	RuleIDs  []string
+	Types    []EventType
+
+	matchers []*plabels.Matcher
+}
+
+func (f *EventFilter) Validate() error {
+	for _, t := range f.Types {
+		switch t {
+		case EventPending, EventFiring, EventResolved, EventAcked, EventSilenced, EventHealth:
+		default:
+			return fmt.Errorf("unknown event type %q", t)
+		}
+	}
+	f.matchers = nil
+	if f.Matchers != "" {
+		matchers, err := parser.ParseMetricSelector(f.Matchers)
+		if err != nil {
+			return fmt.Errorf("invalid matchers: %w", err)
+		}
+		f.matchers = matchers
+	}
+	return nil
+}
+
+// Matches tells whether the event is selected, the filter must be
+// validated first
+func (f *EventFilter) Matches(e AlertEvent) bool {
+	if len(f.RuleIDs) > 0 && !slices.Contains(f.RuleIDs, e.RuleID) {
+		return false
+	}
+	if len(f.Types) > 0 && !slices.Contains(f.Types, e.Type) {
+		return false
+	}
+	if len(f.matchers) == 0 {
+		return true
+	}
+	lbls := make(map[string]string, len(e.Labels)+2)
+	for k, v := range e.Labels {
+		lbls[k] = v
+	}
+	lbls[labels.AlertNameLabel] = e.RuleName
+	lbls[labels.AlertRuleIdLabel] = e.RuleID
+	return matchesAll(f.matchers, lbls)
+}
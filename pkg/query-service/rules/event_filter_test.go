@@ -0,0 +1,40 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventFilter(t *testing.T) {
+	assert.Error(t, (&EventFilter{Types: []EventType{"exploded"}}).Validate())
+	assert.Error(t, (&EventFilter{Matchers: `{service_name=}`}).Validate())
+
+	firing := AlertEvent{Type: EventFiring, RuleID: "1", RuleName: "checkout latency", Labels: map[string]string{"service_name": "checkout", "severity": "critical"}}
+	resolved := AlertEvent{Type: EventResolved, RuleID: "2", RuleName: "cart errors", Labels: map[string]string{"service_name": "cart", "severity": "warning"}}
+	health := AlertEvent{Type: EventHealth, RuleID: "2", RuleName: "cart errors", Labels: map[string]string{"team": "payments"}, Health: HealthBad}
+
+	all := &EventFilter{}
+	require.NoError(t, all.Validate())
+	assert.True(t, all.Matches(firing))
+	assert.True(t, all.Matches(health))
+
+	f := &EventFilter{Matchers: `{severity=~"critical|error"}`}
+	require.NoError(t, f.Validate())
+	assert.True(t, f.Matches(firing))
+	assert.False(t, f.Matches(resolved))
+
+	// the alerts are matched on the name and the id of their rule too
+	f = &EventFilter{Matchers: `{alertname="cart errors",ruleId="2"}`}
+	require.NoError(t, f.Validate())
+	assert.False(t, f.Matches(firing))
+	assert.True(t, f.Matches(resolved))
+	assert.True(t, f.Matches(health))
+
+	f = &EventFilter{RuleIDs: []string{"2"}, Types: []EventType{EventHealth}}
+	require.NoError(t, f.Validate())
+	assert.False(t, f.Matches(firing))
+	assert.False(t, f.Matches(resolved))
+	assert.True(t, f.Matches(health))
+}
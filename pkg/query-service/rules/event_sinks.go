@@ -0,0 +1,162 @@
+package rules
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	am "go.signoz.io/signoz/pkg/query-service/integrations/alertManager"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// EventSinkConfig is an entry of the event sinks configuration file
+type EventSinkConfig struct {
+	Name string `yaml:"name"`
+	// Type is webhook, kafka, otlp, statuspage or status
+	Type string `yaml:"type"`
+	// URL is the webhook, the kafka rest proxy the events are produced
+	// through, the otlp http endpoint of the collector, the statuspage page
+	// (https://api.statuspage.io/v1/pages/<id>) or the endpoint of a
+	// generic status provider
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	// Topic is the kafka topic of the events
+	Topic string `yaml:"topic"`
+	// Events are the types of the events sent, all of them when empty
+	Events []EventType `yaml:"events"`
+	// Components are the components of the status page flipped from the
+	// alerts of the rules
+	Components []StatusComponentConfig `yaml:"components"`
+}
+
+// RegisterSinks adds the sinks of the configuration file to the bus
+func (b *EventBus) RegisterSinks(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read the event sinks: %w", err)
+	}
+	var configs []EventSinkConfig
+	if err := yaml.Unmarshal(data, &configs); err != nil {
+		return fmt.Errorf("failed to parse the event sinks: %w", err)
+	}
+
+	sinks := make([]EventSink, 0, len(configs))
+	for _, c := range configs {
+		sink, err := newEventSink(c)
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, sink)
+	}
+	for i, sink := range sinks {
+		b.AddSink(sink, configs[i].Events...)
+	}
+	return nil
+}
+
+func newEventSink(c EventSinkConfig) (EventSink, error) {
+	if c.URL == "" {
+		return nil, fmt.Errorf("event sink %s has no url", c.Name)
+	}
+	for _, t := range c.Events {
+		switch t {
+		case EventPending, EventFiring, EventResolved, EventAcked, EventSilenced:
+		default:
+			return nil, fmt.Errorf("event sink %s has an unknown event type %q", c.Name, t)
+		}
+	}
+	client := &http.Client{Transport: am.DefaultEgressPolicy().Transport()}
+	switch c.Type {
+	case "webhook":
+		return &webhookSink{name: c.Name, url: c.URL, headers: c.Headers, client: client}, nil
+	case "kafka":
+		if c.Topic == "" {
+			return nil, fmt.Errorf("event sink %s has no topic", c.Name)
+		}
+		return &kafkaSink{name: c.Name, url: strings.TrimSuffix(c.URL, "/"), topic: c.Topic, headers: c.Headers, client: client}, nil
+	case "otlp":
+		return newOTLPSink(c, client), nil
+	case "statuspage", "status":
+		return newStatusPageSink(c, client)
+	}
+	return nil, fmt.Errorf("event sink %s has an unknown type %q", c.Name, c.Type)
+}
+
+// webhookSink posts the events as {"events": [...]}
+type webhookSink struct {
+	name    string
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func (s *webhookSink) Name() string {
+	return s.name
+}
+
+func (s *webhookSink) Send(ctx context.Context, events []AlertEvent) error {
+	body, err := json.Marshal(map[string]interface{}{"events": events})
+	if err != nil {
+		return err
+	}
+	return postEvents(ctx, s.client, s.url, "application/json", s.headers, body)
+}
+
+// kafkaSink produces the events to a topic through the kafka rest proxy,
+// keyed by the fingerprint of the alert so that the events of an alert
+// stay ordered
+type kafkaSink struct {
+	name    string
+	url     string
+	topic   string
+	headers map[string]string
+	client  *http.Client
+}
+
+func (s *kafkaSink) Name() string {
+	return s.name
+}
+
+type kafkaRecord struct {
+	Key   string     `json:"key"`
+	Value AlertEvent `json:"value"`
+}
+
+func (s *kafkaSink) Send(ctx context.Context, events []AlertEvent) error {
+	records := make([]kafkaRecord, 0, len(events))
+	for _, e := range events {
+		records = append(records, kafkaRecord{Key: strconv.FormatUint(e.Fingerprint, 10), Value: e})
+	}
+	body, err := json.Marshal(map[string]interface{}{"records": records})
+	if err != nil {
+		return err
+	}
+	return postEvents(ctx, s.client, s.url+"/topics/"+s.topic, "application/vnd.kafka.json.v2+json", s.headers, body)
+}
+
+func postEvents(ctx context.Context, client *http.Client, url, contentType string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,334 @@
+package rules
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// EventType is the kind of state transition of an alert
+type EventType string
+
+const (
+	EventPending  EventType = "pending"
+	EventFiring   EventType = "firing"
+	EventResolved EventType = "resolved"
+	EventAcked    EventType = "acked"
+	// EventSilenced is published for the active alerts of a rule when a
+	// planned maintenance starts muting it
+	EventSilenced EventType = "silenced"
+	// EventHealth is published when the evaluations of a rule start or
+	// stop failing
+	EventHealth EventType = "health"
+
+	defaultEventBuffer = 1000
+)
+
+// AlertEvent is a state transition of an alert, published on the event bus
+// independently of the notification routing
+type AlertEvent struct {
+	Type        EventType         `json:"type"`
+	RuleID      string            `json:"ruleId"`
+	RuleName    string            `json:"ruleName"`
+	Fingerprint uint64            `json:"fingerprint"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Value       float64           `json:"value"`
+	Timestamp   time.Time         `json:"timestamp"`
+	// Actor is who acknowledged the alert
+	Actor string `json:"actor,omitempty"`
+	// Health and Error are the health of the rule and its last error with
+	// its category, for the health events
+	Health        RuleHealth    `json:"health,omitempty"`
+	Error         string        `json:"error,omitempty"`
+	ErrorCategory ErrorCategory `json:"errorCategory,omitempty"`
+}
+
+// newAlertEvent returns the event of the alert of the rule
+func newAlertEvent(typ EventType, rule Rule, a *Alert, ts time.Time) AlertEvent {
+	e := AlertEvent{
+		Type:        typ,
+		RuleID:      rule.ID(),
+		RuleName:    rule.Name(),
+		Fingerprint: a.Labels.Hash(),
+		Labels:      a.Labels.Map(),
+		Value:       a.Value,
+		Timestamp:   ts,
+	}
+	if a.Annotations != nil {
+		e.Annotations = a.Annotations.Map()
+	}
+	return e
+}
+
+// EventSubscription receives the events of the bus until it is closed
+type EventSubscription struct {
+	C <-chan AlertEvent
+
+	ch      chan AlertEvent
+	bus     *EventBus
+	id      int
+	dropped atomic.Uint64
+}
+
+// Dropped returns the number of events dropped while the subscriber was
+// behind
+func (s *EventSubscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close stops the subscription, C is closed
+func (s *EventSubscription) Close() {
+	s.bus.unsubscribe(s.id)
+}
+
+// EventBus fans out the state transitions of the alerts to the subscribers.
+// Every subscriber has its own buffer, the events are dropped for the
+// subscribers that are behind so that the evaluations never wait on them.
+type EventBus struct {
+	mtx    sync.RWMutex
+	subs   map[int]*EventSubscription
+	nextID int
+	closed bool
+
+	sinks sync.WaitGroup
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subs: map[int]*EventSubscription{}}
+}
+
+// Subscribe returns a subscription holding up to buffer events
+func (b *EventBus) Subscribe(buffer int) *EventSubscription {
+	if buffer <= 0 {
+		buffer = defaultEventBuffer
+	}
+	ch := make(chan AlertEvent, buffer)
+	sub := &EventSubscription{C: ch, ch: ch, bus: b}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if b.closed {
+		close(ch)
+		return sub
+	}
+	b.nextID++
+	sub.id = b.nextID
+	b.subs[sub.id] = sub
+	return sub
+}
+
+func (b *EventBus) unsubscribe(id int) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if sub, ok := b.subs[id]; ok {
+		delete(b.subs, id)
+		close(sub.ch)
+	}
+}
+
+// Publish sends the events to the subscribers without waiting
+func (b *EventBus) Publish(events ...AlertEvent) {
+	if b == nil || len(events) == 0 {
+		return
+	}
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+	for _, sub := range b.subs {
+		for _, e := range events {
+			select {
+			case sub.ch <- e:
+			default:
+				sub.dropped.Add(1)
+			}
+		}
+	}
+}
+
+// Stop closes the subscriptions and waits for the sinks to send the
+// events they hold
+func (b *EventBus) Stop() {
+	if b == nil {
+		return
+	}
+	b.mtx.Lock()
+	b.closed = true
+	for id, sub := range b.subs {
+		delete(b.subs, id)
+		close(sub.ch)
+	}
+	b.mtx.Unlock()
+	b.sinks.Wait()
+}
+
+// alertStates returns the state of the active alerts of the rule by
+// fingerprint
+func alertStates(rule Rule) map[uint64]Alert {
+	states := map[uint64]Alert{}
+	for _, a := range rule.ActiveAlerts() {
+		states[a.Labels.Hash()] = *a
+	}
+	return states
+}
+
+// transitionEvents compares the active alerts of the rule after an
+// evaluation to their state before it
+func transitionEvents(rule Rule, before map[uint64]Alert, ts time.Time) []AlertEvent {
+	var events []AlertEvent
+	active := map[uint64]struct{}{}
+	for _, a := range rule.ActiveAlerts() {
+		if a.State == StateInactive {
+			continue
+		}
+		fp := a.Labels.Hash()
+		active[fp] = struct{}{}
+		prev, ok := before[fp]
+		switch {
+		case a.State == StatePending && !ok:
+			events = append(events, newAlertEvent(EventPending, rule, a, ts))
+		case a.State == StateFiring && (!ok || prev.State != StateFiring):
+			events = append(events, newAlertEvent(EventFiring, rule, a, ts))
+		}
+	}
+	for fp, prev := range before {
+		if _, ok := active[fp]; ok || prev.State != StateFiring {
+			continue
+		}
+		events = append(events, newAlertEvent(EventResolved, rule, &prev, ts))
+	}
+	return events
+}
+
+// publishTransitions publishes the state transitions of the alerts of the
+// rule during an evaluation
+func publishTransitions(opts *ManagerOptions, rule Rule, before map[uint64]Alert, ts time.Time) {
+	if opts.Events == nil {
+		return
+	}
+	events := transitionEvents(rule, before, ts)
+	if len(events) > 0 {
+		zap.L().Debug("publishing alert transitions", zap.String("ruleid", rule.ID()), zap.Int("events", len(events)))
+	}
+	opts.Events.Publish(events...)
+}
+
+// publishSilenced publishes the active alerts of the rule as silenced
+func publishSilenced(opts *ManagerOptions, rule Rule, ts time.Time) {
+	if opts.Events == nil {
+		return
+	}
+	var events []AlertEvent
+	for _, a := range rule.ActiveAlerts() {
+		events = append(events, newAlertEvent(EventSilenced, rule, a, ts))
+	}
+	opts.Events.Publish(events...)
+}
+
+// publishHealthChange publishes the health of the rule when an evaluation
+// changed it. The first successful evaluation of a rule is not a change.
+func publishHealthChange(opts *ManagerOptions, rule Rule, before RuleHealth, ts time.Time) {
+	health := rule.Health()
+	if opts.Events == nil || health == before || (before == HealthUnknown && health == HealthGood) {
+		return
+	}
+	e := AlertEvent{
+		Type:      EventHealth,
+		RuleID:    rule.ID(),
+		RuleName:  rule.Name(),
+		Labels:    rule.Labels().Map(),
+		Health:    health,
+		Timestamp: ts,
+	}
+	if err := rule.LastError(); err != nil && health == HealthBad {
+		e.Error = err.Error()
+		e.ErrorCategory = ErrorCategoryOf(err)
+	}
+	opts.Events.Publish(e)
+}
+
+// EventSink delivers the events of the bus to an external system
+type EventSink interface {
+	Name() string
+	Send(ctx context.Context, events []AlertEvent) error
+}
+
+const (
+	eventSinkBatchSize     = 100
+	eventSinkFlushInterval = time.Second
+	eventSinkRetries       = 3
+	eventSinkRetryBackoff  = time.Second
+	eventSinkTimeout       = 10 * time.Second
+)
+
+// AddSink subscribes the sink to the events of the given types, all the
+// events when none is given. The events are sent in batches, a batch the
+// sink fails to take is retried and then dropped.
+func (b *EventBus) AddSink(sink EventSink, types ...EventType) {
+	sub := b.Subscribe(0)
+	wanted := map[EventType]bool{}
+	for _, t := range types {
+		wanted[t] = true
+	}
+
+	b.sinks.Add(1)
+	go func() {
+		defer b.sinks.Done()
+		tick := time.NewTicker(eventSinkFlushInterval)
+		defer tick.Stop()
+
+		var batch []AlertEvent
+		for {
+			select {
+			case e, ok := <-sub.C:
+				if !ok {
+					sendToSink(sink, batch)
+					return
+				}
+				if len(wanted) > 0 && !wanted[e.Type] {
+					continue
+				}
+				batch = append(batch, e)
+				if len(batch) < eventSinkBatchSize {
+					continue
+				}
+			case <-tick.C:
+			}
+			sendToSink(sink, batch)
+			batch = nil
+		}
+	}()
+}
+
+func sendToSink(sink EventSink, events []AlertEvent) {
+	if len(events) == 0 {
+		return
+	}
+	var err error
+	for attempt := 0; attempt < eventSinkRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(eventSinkRetryBackoff << (attempt - 1))
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), eventSinkTimeout)
+		err = sink.Send(ctx, events)
+		cancel()
+		if err == nil {
+			return
+		}
+	}
+	zap.L().Error("failed to send the alert events, dropping them", zap.String("sink", sink.Name()), zap.Int("events", len(events)), zap.Error(err))
+}
+
+// SubscribeEvents subscribes to the events of the alerts of all the rules,
+// the subscribers serving an org filter them with AlertEventVisible
+func (m *Manager) SubscribeEvents(buffer int) *EventSubscription {
+	return m.opts.Events.Subscribe(buffer)
+}
+
+// AlertEventVisible tells whether the event is of a rule of the org of the
+// request
+func (m *Manager) AlertEventVisible(ctx context.Context, e AlertEvent) bool {
+	return visibleTo(m.opts.tenants.of(e.RuleID), tenantOf(ctx))
+}
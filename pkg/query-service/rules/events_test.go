@@ -0,0 +1,176 @@
+package rules
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+type eventsRule struct {
+	Rule
+	alerts []*Alert
+	health RuleHealth
+	err    error
+}
+
+func (r *eventsRule) ID() string             { return "1" }
+func (r *eventsRule) Name() string           { return "high latency" }
+func (r *eventsRule) ActiveAlerts() []*Alert { return r.alerts }
+func (r *eventsRule) Health() RuleHealth     { return r.health }
+func (r *eventsRule) LastError() error       { return r.err }
+func (r *eventsRule) Labels() labels.BaseLabels {
+	return labels.FromMap(map[string]string{"team": "payments"})
+}
+
+func TestTransitionEvents(t *testing.T) {
+	api := labels.FromMap(map[string]string{"service": "api"})
+	web := labels.FromMap(map[string]string{"service": "web"})
+	db := labels.FromMap(map[string]string{"service": "db"})
+	ts := time.Now()
+
+	rule := &eventsRule{alerts: []*Alert{
+		{State: StatePending, Labels: api},
+		{State: StateFiring, Labels: web},
+	}}
+	events := transitionEvents(rule, map[uint64]Alert{}, ts)
+	require.Len(t, events, 2)
+	assert.Equal(t, EventPending, events[0].Type)
+	assert.Equal(t, api.Hash(), events[0].Fingerprint)
+	assert.Equal(t, EventFiring, events[1].Type)
+	assert.Equal(t, "1", events[1].RuleID)
+	assert.Equal(t, map[string]string{"service": "web"}, events[1].Labels)
+
+	// an alert still pending or still firing has no transition
+	before := alertStates(rule)
+	assert.Empty(t, transitionEvents(rule, before, ts))
+
+	// api fires, web resolves and db is pending, a pending alert that goes
+	// away never fired so it does not resolve
+	rule.alerts = []*Alert{
+		{State: StateFiring, Labels: api, Value: 2},
+		{State: StateInactive, Labels: web},
+		{State: StatePending, Labels: db},
+	}
+	events = transitionEvents(rule, before, ts)
+	require.Len(t, events, 3)
+	assert.Equal(t, EventFiring, events[0].Type)
+	assert.Equal(t, 2.0, events[0].Value)
+	assert.Equal(t, EventPending, events[1].Type)
+	assert.Equal(t, EventResolved, events[2].Type)
+	assert.Equal(t, web.Hash(), events[2].Fingerprint)
+
+	before = alertStates(rule)
+	rule.alerts = nil
+	events = transitionEvents(rule, before, ts)
+	require.Len(t, events, 1)
+	assert.Equal(t, EventResolved, events[0].Type)
+	assert.Equal(t, api.Hash(), events[0].Fingerprint)
+}
+
+func TestPublishHealthChange(t *testing.T) {
+	opts := &ManagerOptions{Events: NewEventBus()}
+	defer opts.Events.Stop()
+	sub := opts.Events.Subscribe(10)
+	ts := time.Now()
+
+	// the first successful evaluation is not a change
+	rule := &eventsRule{health: HealthGood}
+	publishHealthChange(opts, rule, HealthUnknown, ts)
+	publishHealthChange(opts, rule, HealthGood, ts)
+
+	rule.health, rule.err = HealthBad, errors.New("query timed out")
+	publishHealthChange(opts, rule, HealthGood, ts)
+	rule.health, rule.err = HealthGood, nil
+	publishHealthChange(opts, rule, HealthBad, ts)
+
+	e := <-sub.C
+	assert.Equal(t, EventHealth, e.Type)
+	assert.Equal(t, HealthBad, e.Health)
+	assert.Equal(t, "query timed out", e.Error)
+	assert.Equal(t, map[string]string{"team": "payments"}, e.Labels)
+	e = <-sub.C
+	assert.Equal(t, HealthGood, e.Health)
+	assert.Empty(t, e.Error)
+	assert.Empty(t, sub.C)
+}
+
+func TestEventBusSubscribe(t *testing.T) {
+	bus := NewEventBus()
+	sub := bus.Subscribe(1)
+	bus.Publish(AlertEvent{Type: EventFiring}, AlertEvent{Type: EventResolved})
+
+	e := <-sub.C
+	assert.Equal(t, EventFiring, e.Type)
+	assert.Equal(t, uint64(1), sub.Dropped())
+
+	sub.Close()
+	_, ok := <-sub.C
+	assert.False(t, ok)
+	bus.Stop()
+}
+
+func TestEventBusWebhookSink(t *testing.T) {
+	var mtx sync.Mutex
+	var received []AlertEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Events []AlertEvent `json:"events"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "secret", r.Header.Get("X-Token"))
+		mtx.Lock()
+		received = append(received, body.Events...)
+		mtx.Unlock()
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "sinks.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+- name: automation
+  type: webhook
+  url: `+srv.URL+`
+  headers:
+    X-Token: secret
+  events: [firing, resolved]
+`), 0o600))
+
+	bus := NewEventBus()
+	require.NoError(t, bus.RegisterSinks(path))
+	bus.Publish(
+		AlertEvent{Type: EventPending, RuleID: "1"},
+		AlertEvent{Type: EventFiring, RuleID: "1", Fingerprint: 42},
+		AlertEvent{Type: EventResolved, RuleID: "1", Fingerprint: 42},
+	)
+	// stopping sends the events the sinks hold
+	bus.Stop()
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	require.Len(t, received, 2)
+	assert.Equal(t, EventFiring, received[0].Type)
+	assert.Equal(t, uint64(42), received[0].Fingerprint)
+	assert.Equal(t, EventResolved, received[1].Type)
+}
+
+func TestNewEventSink(t *testing.T) {
+	_, err := newEventSink(EventSinkConfig{Name: "k", Type: "kafka", URL: "http://proxy"})
+	assert.Error(t, err)
+	_, err = newEventSink(EventSinkConfig{Name: "w", Type: "webhook", URL: "http://hook", Events: []EventType{"inhibited"}})
+	assert.Error(t, err)
+	_, err = newEventSink(EventSinkConfig{Name: "s", Type: "sns", URL: "http://sns"})
+	assert.Error(t, err)
+
+	sink, err := newEventSink(EventSinkConfig{Name: "k", Type: "kafka", URL: "http://proxy/", Topic: "alerts"})
+	require.NoError(t, err)
+	assert.Equal(t, "http://proxy", sink.(*kafkaSink).url)
+}
@@ -0,0 +1,166 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	tracesV3 "go.signoz.io/signoz/pkg/query-service/app/traces/v3"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+	"go.uber.org/zap"
+)
+
+// TraceExemplarsAnnotation holds the ids of the example traces of an
+// alert of a traces rule, comma separated
+const TraceExemplarsAnnotation = "exemplar_traces"
+
+// TraceExemplar is a span of the breach window picked as an example of the
+// alert, the failed spans first and then the slowest
+type TraceExemplar struct {
+	TraceID      string
+	SpanID       string
+	ServiceName  string
+	Name         string
+	DurationNano uint64
+	HasError     bool
+}
+
+// exemplarColumns are the columns of the span index table the exemplars
+// are read from
+var exemplarColumns = []v3.AttributeKey{
+	{Key: "serviceName", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag, IsColumn: true},
+	{Key: "name", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag, IsColumn: true},
+	{Key: "durationNano", DataType: v3.AttributeKeyDataTypeFloat64, Type: v3.AttributeKeyTypeTag, IsColumn: true},
+	{Key: "hasError", DataType: v3.AttributeKeyDataTypeBool, Type: v3.AttributeKeyTypeTag, IsColumn: true},
+}
+
+// traceExemplars returns the failed and then the slowest spans of the
+// selected query for the labels of the alert in the eval window at ts,
+// at most one span per trace
+func (r *ThresholdRule) traceExemplars(ctx context.Context, lbls labels.Labels, ts time.Time) ([]TraceExemplar, error) {
+	limit := r.opts.TraceExemplars
+	selectedQuery := r.GetSelectedQuery()
+	q, ok := r.ruleCondition.CompositeQuery.BuilderQueries[selectedQuery]
+	if !ok || q.DataSource != v3.DataSourceTraces {
+		return nil, nil
+	}
+
+	spanKeys, err := r.reader.GetSpanAttributeKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]v3.AttributeKey, len(spanKeys)+len(exemplarColumns))
+	for name, key := range spanKeys {
+		keys[name] = key
+	}
+	for _, key := range exemplarColumns {
+		if _, ok := keys[key.Key]; !ok {
+			keys[key.Key] = key
+		}
+	}
+
+	params := r.prepareQueryRange(ts)
+	filters := r.fetchFilters(selectedQuery, labels.NewBuilder(lbls).Del(QueryNameLabel).Labels())
+	failed := append(append([]v3.FilterItem{}, filters...), v3.FilterItem{
+		Key:      keys["hasError"],
+		Operator: v3.FilterOperatorEqual,
+		Value:    true,
+	})
+
+	var exemplars []TraceExemplar
+	seen := map[string]bool{}
+	for _, items := range [][]v3.FilterItem{failed, filters} {
+		mq := &v3.BuilderQuery{
+			QueryName:         selectedQuery,
+			DataSource:        v3.DataSourceTraces,
+			AggregateOperator: v3.AggregateOperatorNoOp,
+			StepInterval:      q.StepInterval,
+			Filters:           &v3.FilterSet{Operator: "AND", Items: items},
+			SelectColumns:     exemplarColumns,
+			OrderBy:           []v3.OrderBy{{ColumnName: "durationNano", Order: "desc"}},
+			Limit:             uint64(limit),
+		}
+		if mq.StepInterval == 0 {
+			mq.StepInterval = 60
+		}
+		query, err := tracesV3.PrepareTracesQuery(params.Start, params.End, v3.PanelTypeList, mq, keys, tracesV3.Options{})
+		if err != nil {
+			return nil, err
+		}
+		rows, err := r.reader.GetListResultV3(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			e := exemplarFromRow(row)
+			if e.TraceID == "" || seen[e.TraceID] {
+				continue
+			}
+			seen[e.TraceID] = true
+			exemplars = append(exemplars, e)
+			if len(exemplars) == limit {
+				return exemplars, nil
+			}
+		}
+	}
+	return exemplars, nil
+}
+
+func exemplarFromRow(row *v3.Row) TraceExemplar {
+	e := TraceExemplar{
+		TraceID:     rowString(row.Data["traceID"]),
+		SpanID:      rowString(row.Data["spanID"]),
+		ServiceName: rowString(row.Data["serviceName"]),
+		Name:        rowString(row.Data["name"]),
+	}
+	if v := reflect.Indirect(reflect.ValueOf(row.Data["durationNano"])); v.IsValid() {
+		switch {
+		case v.CanUint():
+			e.DurationNano = v.Uint()
+		case v.CanInt():
+			e.DurationNano = uint64(v.Int())
+		case v.CanFloat():
+			e.DurationNano = uint64(v.Float())
+		}
+	}
+	if v := reflect.Indirect(reflect.ValueOf(row.Data["hasError"])); v.IsValid() && v.Kind() == reflect.Bool {
+		e.HasError = v.Bool()
+	}
+	return e
+}
+
+// rowString returns the value of a column of a row, the values of the
+// rows are pointers to the scanned values
+func rowString(value interface{}) string {
+	v := reflect.Indirect(reflect.ValueOf(value))
+	if !v.IsValid() {
+		return ""
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// attachExemplars adds the example traces of the breach to the
+// annotations of the alert of a traces rule when it starts firing
+func (r *ThresholdRule) attachExemplars(ctx context.Context, a *Alert, ts time.Time) {
+	if r.typ != AlertTypeTraces || r.opts.TraceExemplars <= 0 || r.reader == nil || a.Missing ||
+		r.ruleCondition.QueryType() != v3.QueryTypeBuilder {
+		return
+	}
+	exemplars, err := r.traceExemplars(ctx, labels.FromMap(a.QueryResultLables.Map()), ts)
+	if err != nil {
+		zap.L().Error("failed to read the exemplar traces of the alert", zap.String("ruleid", r.ID()), zap.Error(err))
+		return
+	}
+	if len(exemplars) == 0 {
+		return
+	}
+	ids := make([]string, 0, len(exemplars))
+	for _, e := range exemplars {
+		ids = append(ids, e.TraceID)
+	}
+	a.Exemplars = strings.Join(ids, ",")
+	a.Annotations = labels.NewBuilder(labels.FromMap(a.Annotations.Map())).Set(TraceExemplarsAnnotation, a.Exemplars).Labels()
+}
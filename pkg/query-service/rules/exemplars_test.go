@@ -0,0 +1,120 @@
+package rules
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/interfaces"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+type exemplarReader struct {
+	interfaces.Reader
+	queries []string
+	rows    [][]*v3.Row
+	// onQuery is called with every query when set
+	onQuery func()
+}
+
+func (r *exemplarReader) GetSpanAttributeKeys(ctx context.Context) (map[string]v3.AttributeKey, error) {
+	return map[string]v3.AttributeKey{
+		"http.route": {Key: "http.route", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag},
+	}, nil
+}
+
+func (r *exemplarReader) GetListResultV3(ctx context.Context, query string) ([]*v3.Row, error) {
+	r.queries = append(r.queries, query)
+	if r.onQuery != nil {
+		r.onQuery()
+	}
+	rows := r.rows[0]
+	r.rows = r.rows[1:]
+	return rows, nil
+}
+
+func exemplarRow(traceID string, duration uint64, hasError bool) *v3.Row {
+	return &v3.Row{Data: map[string]interface{}{
+		"traceID":      &traceID,
+		"durationNano": &duration,
+		"hasError":     &hasError,
+	}}
+}
+
+func TestThresholdRuleTraceExemplars(t *testing.T) {
+	rule, err := ParsePostableRule([]byte(`{
+		"alert": "slow route",
+		"alertType": "TRACES_BASED_ALERT",
+		"ruleType": "threshold_rule",
+		"evalWindow": "5m",
+		"condition": {
+			"compositeQuery": {
+				"queryType": "builder",
+				"panelType": "graph",
+				"builderQueries": {
+					"A": {
+						"queryName": "A", "dataSource": "traces", "aggregateOperator": "p99", "stepInterval": 60,
+						"aggregateAttribute": {"key": "durationNano", "dataType": "float64", "isColumn": true},
+						"groupBy": [{"key": "http.route", "dataType": "string", "type": "tag"}]
+					}
+				}
+			},
+			"op": "1",
+			"target": 500,
+			"matchType": "1"
+		}
+	}`))
+	require.NoError(t, err)
+
+	reader := &exemplarReader{rows: [][]*v3.Row{
+		{exemplarRow("t1", 900, true)},
+		{exemplarRow("t2", 3000, false), exemplarRow("t1", 900, true), exemplarRow("t3", 2000, false)},
+	}}
+	tr, err := NewThresholdRule("1", rule, ThresholdRuleOpts{TraceExemplars: 2}, nil, reader)
+	require.NoError(t, err)
+
+	lbls := labels.FromMap(map[string]string{"http.route": "/checkout"})
+	exemplars, err := tr.traceExemplars(context.Background(), lbls, time.Unix(1717205940, 0))
+	require.NoError(t, err)
+
+	require.Len(t, exemplars, 2)
+	assert.Equal(t, "t1", exemplars[0].TraceID)
+	assert.True(t, exemplars[0].HasError)
+	assert.Equal(t, "t2", exemplars[1].TraceID)
+	assert.Equal(t, uint64(3000), exemplars[1].DurationNano)
+
+	require.Len(t, reader.queries, 2)
+	for _, query := range reader.queries {
+		assert.Contains(t, query, "stringTagMap['http.route'] = '/checkout'")
+		assert.Contains(t, query, "`durationNano` desc")
+		assert.Contains(t, query, "LIMIT 2")
+	}
+	assert.True(t, strings.Contains(reader.queries[0], "hasError = true"), reader.queries[0])
+
+	a := &Alert{QueryResultLables: lbls, Annotations: labels.Labels{}}
+	reader.rows = [][]*v3.Row{{exemplarRow("t4", 100, true), exemplarRow("t5", 90, true)}}
+	tr.attachExemplars(context.Background(), a, time.Unix(1717205940, 0))
+	assert.Equal(t, "t4,t5", a.Exemplars)
+	assert.Equal(t, "t4,t5", a.Annotations.Map()[TraceExemplarsAnnotation])
+
+	// the alerts that started firing are enriched without the rule lock
+	firedAt := time.Unix(1717205940, 0)
+	tr.active.Set(1, &Alert{QueryResultLables: lbls, Annotations: labels.Labels{}, State: StateFiring, FiredAt: firedAt})
+	reader.rows = [][]*v3.Row{{exemplarRow("t6", 100, true)}, {}}
+	reader.onQuery = func() {
+		if !tr.mtx.TryLock() {
+			t.Error("the exemplars are read under the rule lock")
+			return
+		}
+		tr.mtx.Unlock()
+	}
+	tr.enrichFiring(context.Background(), []firingAlert{{fp: 1, firedAt: firedAt, alert: &Alert{QueryResultLables: lbls, Annotations: labels.Labels{}}}}, firedAt)
+	a, ok := tr.active.Get(1)
+	require.True(t, ok)
+	assert.Equal(t, "t6", a.Exemplars)
+	assert.Equal(t, "t6", a.Annotations.Map()[TraceExemplarsAnnotation])
+}
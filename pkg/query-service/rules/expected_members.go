@@ -0,0 +1,80 @@
+package rules
+
+import (
+	"sort"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/constants"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// expectedMembers returns the expected values of the label of the
+// expected members, the static values and the values discovered by the
+// discovery query
+func (r *ThresholdRule) expectedMembers(results []*v3.Result) []string {
+	m := r.ruleCondition.ExpectedMembers
+	expected := map[string]bool{}
+	for _, v := range m.Values {
+		expected[v] = true
+	}
+	if m.Query != "" {
+		for _, res := range results {
+			if res.QueryName != m.Query {
+				continue
+			}
+			for _, series := range res.Series {
+				if v, ok := series.Labels[m.Label]; ok && v != "" {
+					expected[v] = true
+				}
+			}
+		}
+	}
+	members := make([]string, 0, len(expected))
+	for v := range expected {
+		members = append(members, v)
+	}
+	sort.Strings(members)
+	return members
+}
+
+// missingMembersVector returns a no data sample for every expected member
+// the selected query did not return, labeled with the member
+func (r *ThresholdRule) missingMembersVector(results []*v3.Result, queryResult *v3.Result) Vector {
+	m := r.ruleCondition.ExpectedMembers
+	if m == nil || m.Label == "" {
+		return nil
+	}
+
+	reported := map[string]bool{}
+	if queryResult != nil {
+		for _, series := range queryResult.Series {
+			if v, ok := series.Labels[m.Label]; ok {
+				reported[v] = true
+			}
+		}
+	}
+
+	// the last seen time is only kept for the current members
+	now := time.Now()
+	lastSeen := map[string]time.Time{}
+	var vector Vector
+	for _, member := range r.expectedMembers(results) {
+		if reported[member] {
+			lastSeen[member] = now
+			continue
+		}
+		lbls := labels.NewBuilder(labels.Labels{}).Set(m.Label, member)
+		if seen, ok := r.memberLastSeen[member]; ok {
+			lastSeen[member] = seen
+			lbls.Set("lastSeen", seen.Format(constants.AlertTimeFormat))
+		}
+		vector = append(vector, Sample{
+			Metric:     lbls.Labels(),
+			MetricOrig: labels.FromMap(map[string]string{m.Label: member}),
+			IsMissing:  true,
+		})
+	}
+	r.memberLastSeen = lastSeen
+	return vector
+}
@@ -0,0 +1,62 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func TestMissingMembersVector(t *testing.T) {
+	rule, err := ParsePostableRule([]byte(`{
+		"alert": "kafka brokers",
+		"ruleType": "threshold_rule",
+		"condition": {
+			"compositeQuery": {
+				"queryType": "builder",
+				"panelType": "graph",
+				"builderQueries": {
+					"A": {"queryName": "A", "dataSource": "metrics", "aggregateOperator": "count", "stepInterval": 60},
+					"B": {"queryName": "B", "dataSource": "metrics", "aggregateOperator": "count", "stepInterval": 60}
+				}
+			},
+			"expectedMembers": {"label": "broker", "values": ["b1", "b2"], "query": "B"},
+			"op": "1",
+			"matchType": "1"
+		}
+	}`))
+	require.NoError(t, err)
+	require.NoError(t, rule.Validate())
+
+	tr, err := NewThresholdRule("1", rule, ThresholdRuleOpts{}, nil, nil)
+	require.NoError(t, err)
+
+	series := func(brokers ...string) []*v3.Series {
+		var s []*v3.Series
+		for _, b := range brokers {
+			s = append(s, &v3.Series{Labels: map[string]string{"broker": b}, Points: []v3.Point{{Timestamp: 1, Value: 1}}})
+		}
+		return s
+	}
+	selected := &v3.Result{QueryName: "A", Series: series("b1", "b2", "b3")}
+	results := []*v3.Result{selected, {QueryName: "B", Series: series("b3", "b4")}}
+
+	vector := tr.missingMembersVector(results, selected)
+	require.Len(t, vector, 1)
+	assert.Equal(t, "b4", vector[0].Metric.Get("broker"))
+	assert.True(t, vector[0].IsMissing)
+	assert.Empty(t, vector[0].Metric.Get("lastSeen"))
+
+	// b2 stops reporting
+	selected = &v3.Result{QueryName: "A", Series: series("b1", "b3")}
+	results[0] = selected
+	vector = tr.missingMembersVector(results, selected)
+	require.Len(t, vector, 2)
+	assert.Equal(t, "b2", vector[0].Metric.Get("broker"))
+	assert.NotEmpty(t, vector[0].Metric.Get("lastSeen"))
+	assert.Equal(t, "b4", vector[1].Metric.Get("broker"))
+
+	rule.RuleCondition.ExpectedMembers.Query = "C"
+	assert.Error(t, rule.Validate())
+}
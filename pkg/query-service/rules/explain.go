@@ -0,0 +1,152 @@
+package rules
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+)
+
+// ExplainRequest is the request of the explain api
+type ExplainRequest struct {
+	// Timestamp is the evaluation time in unix milliseconds, now when
+	// not set
+	Timestamp int64 `json:"timestamp"`
+}
+
+// SeriesExplanation is the decision of the rule for a series
+type SeriesExplanation struct {
+	Labels map[string]string `json:"labels"`
+	Points int               `json:"points"`
+	// Value is the value of the series compared to the threshold, unset
+	// when the series has no valid points
+	Value       *float64  `json:"value,omitempty"`
+	CompareOp   CompareOp `json:"op"`
+	Threshold   float64   `json:"threshold"`
+	ShouldAlert bool      `json:"shouldAlert"`
+}
+
+// Explanation is a trace of the decision of a rule at a given time, it
+// tells for every series returned by the selected query whether it
+// matched the condition of the rule
+type Explanation struct {
+	RuleId        string    `json:"ruleId"`
+	RuleType      RuleType  `json:"ruleType"`
+	Timestamp     time.Time `json:"timestamp"`
+	Start         int64     `json:"start"`
+	End           int64     `json:"end"`
+	Query         string    `json:"query"`
+	SelectedQuery string    `json:"selectedQuery"`
+	MatchType     MatchType `json:"matchType"`
+	CompareOp     CompareOp `json:"op"`
+	Target        *float64  `json:"target"`
+	TargetUnit    string    `json:"targetUnit"`
+	Unit          string    `json:"unit"`
+	// Threshold is the target converted to the unit of the query
+	Threshold float64             `json:"threshold"`
+	Series    []SeriesExplanation `json:"series"`
+	// Alerting is the number of series matching the condition
+	Alerting int `json:"alerting"`
+}
+
+// Explainer is implemented by the rules that can explain their decision
+type Explainer interface {
+	// Explain runs the query of the rule for ts and returns the decision
+	// for every series, it does not change the state of the rule
+	Explain(ctx context.Context, ts time.Time, queriers *Queriers) (*Explanation, error)
+}
+
+func newExplanation(rule Rule, selectedQuery, unit string, threshold float64, ts time.Time, start, end int64, query string) *Explanation {
+	cond := rule.Condition()
+	if cond == nil {
+		cond = &RuleCondition{}
+	}
+	return &Explanation{
+		RuleId:        rule.ID(),
+		RuleType:      rule.Type(),
+		Timestamp:     ts,
+		Start:         start,
+		End:           end,
+		Query:         query,
+		SelectedQuery: selectedQuery,
+		MatchType:     cond.MatchType,
+		CompareOp:     cond.CompareOp,
+		Target:        cond.Target,
+		TargetUnit:    cond.TargetUnit,
+		Unit:          unit,
+		Threshold:     threshold,
+		Series:        []SeriesExplanation{},
+	}
+}
+
+func (e *Explanation) addSeries(lbls map[string]string, values []float64, shouldAlert bool) {
+	series := SeriesExplanation{
+		Labels:      lbls,
+		Points:      len(values),
+		CompareOp:   e.CompareOp,
+		Threshold:   e.Threshold,
+		ShouldAlert: shouldAlert,
+	}
+	if v := explainValue(e.MatchType, e.CompareOp, values); !math.IsNaN(v) && !math.IsInf(v, 0) {
+		series.Value = &v
+	}
+	if shouldAlert {
+		e.Alerting++
+	}
+	e.Series = append(e.Series, series)
+}
+
+// sortSeries lists the alerting series first
+func (e *Explanation) sortSeries() {
+	sort.SliceStable(e.Series, func(i, j int) bool {
+		return e.Series[i].ShouldAlert && !e.Series[j].ShouldAlert
+	})
+}
+
+// explainValue reduces the values of a series the way the match type
+// looks at them: the value closest to the threshold side for at least
+// once, the value keeping the series from firing for all the times, the
+// average or the sum otherwise. Equality conditions use the latest value.
+func explainValue(matchType MatchType, op CompareOp, values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	var valid []float64
+	for _, v := range values {
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			valid = append(valid, v)
+		}
+	}
+	if len(valid) == 0 {
+		return math.NaN()
+	}
+
+	max, min, sum := math.Inf(-1), math.Inf(1), 0.0
+	for _, v := range valid {
+		max = math.Max(max, v)
+		min = math.Min(min, v)
+		sum += v
+	}
+
+	switch matchType {
+	case OnAverage:
+		return sum / float64(len(valid))
+	case InTotal:
+		return sum
+	case AtleastOnce:
+		switch op {
+		case ValueIsAbove:
+			return max
+		case ValueIsBelow:
+			return min
+		}
+	case AllTheTimes:
+		switch op {
+		case ValueIsAbove:
+			return min
+		case ValueIsBelow:
+			return max
+		}
+	}
+	return valid[len(valid)-1]
+}
@@ -0,0 +1,103 @@
+package rules
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+type explainQuerier struct {
+	tracingQuerier
+	results []*v3.Result
+}
+
+func (q *explainQuerier) QueryRange(context.Context, *v3.QueryRangeParamsV3, map[string]v3.AttributeKey) ([]*v3.Result, map[string]error, error) {
+	return q.results, nil, nil
+}
+
+func TestExplainValue(t *testing.T) {
+	values := []float64{4, 10, math.NaN(), 1, 6}
+	cases := []struct {
+		matchType MatchType
+		op        CompareOp
+		expected  float64
+	}{
+		{AtleastOnce, ValueIsAbove, 10},
+		{AtleastOnce, ValueIsBelow, 1},
+		{AllTheTimes, ValueIsAbove, 1},
+		{AllTheTimes, ValueIsBelow, 10},
+		{AllTheTimes, ValueIsEq, 6},
+		{OnAverage, ValueIsAbove, 5.25},
+		{InTotal, ValueIsBelow, 21},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, explainValue(c.matchType, c.op, values), "match type %s op %s", c.matchType, c.op)
+	}
+	assert.True(t, math.IsNaN(explainValue(OnAverage, ValueIsAbove, []float64{math.Inf(1)})))
+}
+
+func TestThresholdRuleExplain(t *testing.T) {
+	target := 500.0
+	postableRule := PostableRule{
+		AlertName:  "Slow endpoints",
+		AlertType:  "METRIC_BASED_ALERT",
+		RuleType:   RuleTypeThreshold,
+		EvalWindow: Duration(5 * time.Minute),
+		Frequency:  Duration(1 * time.Minute),
+		RuleCondition: &RuleCondition{
+			CompositeQuery: &v3.CompositeQuery{
+				QueryType: v3.QueryTypeClickHouseSQL,
+				ClickHouseQueries: map[string]*v3.ClickHouseQuery{
+					"A": {Query: "SELECT 1"},
+				},
+				Unit: "s",
+			},
+			CompareOp:  ValueIsAbove,
+			MatchType:  OnAverage,
+			Target:     &target,
+			TargetUnit: "ms",
+		},
+	}
+	rule, err := NewThresholdRule("5", &postableRule, ThresholdRuleOpts{}, nil, nil)
+	require.NoError(t, err)
+	rule.querier = &explainQuerier{results: []*v3.Result{{
+		QueryName: "A",
+		Series: []*v3.Series{
+			{Labels: map[string]string{"endpoint": "/login"}, Points: []v3.Point{{Timestamp: 1, Value: 0.2}, {Timestamp: 2, Value: 0.4}}},
+			{Labels: map[string]string{"endpoint": "/search"}, Points: []v3.Point{{Timestamp: 1, Value: 0.6}, {Timestamp: 2, Value: 0.8}}},
+			{Labels: map[string]string{"endpoint": "/health"}},
+		},
+	}}}
+
+	ts := time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC)
+	explanation, err := rule.Explain(context.Background(), ts, &Queriers{})
+	require.NoError(t, err)
+
+	assert.Equal(t, "5", explanation.RuleId)
+	assert.Equal(t, "A", explanation.SelectedQuery)
+	assert.Equal(t, "A: SELECT 1", explanation.Query)
+	assert.Equal(t, 0.5, explanation.Threshold)
+	assert.Equal(t, 1, explanation.Alerting)
+	require.Len(t, explanation.Series, 3)
+
+	assert.Equal(t, "/search", explanation.Series[0].Labels["endpoint"])
+	assert.True(t, explanation.Series[0].ShouldAlert)
+	assert.InDelta(t, 0.7, *explanation.Series[0].Value, 1e-9)
+	assert.Equal(t, ValueIsAbove, explanation.Series[0].CompareOp)
+	assert.Equal(t, 0.5, explanation.Series[0].Threshold)
+
+	assert.False(t, explanation.Series[1].ShouldAlert)
+	assert.InDelta(t, 0.3, *explanation.Series[1].Value, 1e-9)
+
+	assert.Equal(t, 0, explanation.Series[2].Points)
+	assert.Nil(t, explanation.Series[2].Value)
+
+	// explaining does not change the state of the rule
+	assert.Equal(t, 0, rule.SamplesReturned())
+	assert.Empty(t, rule.ActiveAlerts())
+}
@@ -0,0 +1,251 @@
+package rules
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+const (
+	ExportStateHistory  = "state_history"
+	ExportNotifications = "notifications"
+
+	ExportCSV    = "csv"
+	ExportJSON   = "json"
+	ExportNDJSON = "ndjson"
+	// ExportParquet is not written until a maintained parquet library is
+	// a dependency, the ndjson files load in the tools reading parquet
+	ExportParquet = "parquet"
+
+	// maxExportRows caps the rows of an export, the range has to be split
+	// beyond it
+	maxExportRows = 100000
+)
+
+// ErrExportParquet is returned for the parquet exports
+var ErrExportParquet = fmt.Errorf("the parquet export is not supported yet, export %s and convert it, e.g. with duckdb", ExportNDJSON)
+
+// ExportRequest selects the state history or the notifications exported
+type ExportRequest struct {
+	Source string `json:"source"`
+	Format string `json:"format"`
+	Start  int64  `json:"start"`
+	End    int64  `json:"end"`
+	RuleID string `json:"ruleId"`
+	// State filters the state history by the state of the alerts
+	State string `json:"state"`
+	// Status filters the notifications, delivered or dropped
+	Status string `json:"status"`
+	// Filters filters the state history by the labels of the alerts
+	Filters *v3.FilterSet `json:"filters"`
+}
+
+func (r *ExportRequest) Validate() error {
+	switch r.Source {
+	case ExportStateHistory, ExportNotifications:
+	default:
+		return fmt.Errorf("unknown export source %q, expected %s or %s", r.Source, ExportStateHistory, ExportNotifications)
+	}
+	switch r.Format {
+	case ExportCSV, ExportJSON, ExportNDJSON:
+	case ExportParquet:
+		return ErrExportParquet
+	default:
+		return fmt.Errorf("unknown export format %q, expected %s, %s or %s", r.Format, ExportCSV, ExportJSON, ExportNDJSON)
+	}
+	if r.Start == 0 || r.End == 0 {
+		return fmt.Errorf("start and end are required")
+	}
+	if r.End <= r.Start {
+		return fmt.Errorf("end must be after start")
+	}
+	return nil
+}
+
+// ContentType returns the media type of the exported file
+func (r *ExportRequest) ContentType() string {
+	switch r.Format {
+	case ExportCSV:
+		return "text/csv"
+	case ExportNDJSON:
+		return "application/x-ndjson"
+	}
+	return "application/json"
+}
+
+// FileName returns the name of the exported file
+func (r *ExportRequest) FileName() string {
+	return fmt.Sprintf("%s-%d-%d.%s", r.Source, r.Start, r.End, r.Format)
+}
+
+// exportTable is the exported rows, the values are in the order of the
+// columns
+type exportTable struct {
+	columns []string
+	rows    [][]interface{}
+}
+
+func stateHistoryTable(items []v3.RuleStateHistory) *exportTable {
+	t := &exportTable{columns: []string{
+		"rule_id", "rule_name", "overall_state", "overall_state_changed", "state",
+		"state_changed", "timestamp", "labels", "fingerprint", "value",
+	}}
+	for _, item := range items {
+		t.rows = append(t.rows, []interface{}{
+			item.RuleID, item.RuleName, item.OverallState, item.OverallStateChanged, item.State, item.StateChanged,
+			time.UnixMilli(item.UnixMilli).UTC(), string(item.Labels), strconv.FormatUint(item.Fingerprint, 10), item.Value,
+		})
+	}
+	return t
+}
+
+func notificationsTable(records []NotificationRecord) *exportTable {
+	t := &exportTable{columns: []string{
+		"rule_id", "alert_name", "fingerprint", "state", "status", "receivers", "labels", "sent_at",
+	}}
+	for _, r := range records {
+		t.rows = append(t.rows, []interface{}{
+			r.RuleId, r.AlertName, strconv.FormatUint(r.Fingerprint, 10), r.State, r.Status,
+			strings.Join(r.Receivers, ","), r.Labels, r.SentAt.UTC(),
+		})
+	}
+	return t
+}
+
+// write writes the table in the format
+func (t *exportTable) write(w io.Writer, format string) error {
+	switch format {
+	case ExportCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(t.columns); err != nil {
+			return err
+		}
+		for _, row := range t.rows {
+			record := make([]string, len(row))
+			for i, v := range row {
+				record[i] = csvValue(v)
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case ExportJSON:
+		objects := make([]map[string]interface{}, 0, len(t.rows))
+		for _, row := range t.rows {
+			objects = append(objects, t.object(row))
+		}
+		return json.NewEncoder(w).Encode(objects)
+	case ExportNDJSON:
+		// one object per line, the files can be streamed into the tools
+		// reading the json lines
+		enc := json.NewEncoder(w)
+		for _, row := range t.rows {
+			if err := enc.Encode(t.object(row)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown export format %q", format)
+}
+
+// object returns the row keyed by the names of the columns
+func (t *exportTable) object(row []interface{}) map[string]interface{} {
+	object := make(map[string]interface{}, len(row))
+	for i, v := range row {
+		object[t.columns[i]] = v
+	}
+	return object
+}
+
+func csvValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
+
+// Export writes the state history or the notification log selected by
+// the request to w, for the incident reviews in external tools
+func (m *Manager) Export(ctx context.Context, req *ExportRequest, w io.Writer) *model.ApiError {
+	// the requests of a user only export the rules of their org
+	visible, err := m.tenantRules(ctx)
+	if err != nil {
+		return newApiErrorInternal(err)
+	}
+	if visible != nil && req.RuleID != "" && !visible[req.RuleID] {
+		return &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("rule %s not found", req.RuleID)}
+	}
+
+	var table *exportTable
+	switch req.Source {
+	case ExportStateHistory:
+		if m.reader == nil {
+			return &model.ApiError{Typ: model.ErrorUnavailable, Err: fmt.Errorf("the state history is not stored")}
+		}
+		timeline, err := m.reader.ReadRuleStateHistoryByRuleID(ctx, req.RuleID, &v3.QueryRuleStateHistory{
+			Start:   req.Start,
+			End:     req.End,
+			State:   req.State,
+			Filters: req.Filters,
+			Limit:   maxExportRows,
+			Order:   "asc",
+		})
+		if err != nil {
+			return newApiErrorInternal(err)
+		}
+		items := timeline.Items
+		if visible != nil {
+			items = make([]v3.RuleStateHistory, 0, len(timeline.Items))
+			for _, item := range timeline.Items {
+				if visible[item.RuleID] {
+					items = append(items, item)
+				}
+			}
+		}
+		table = stateHistoryTable(items)
+	case ExportNotifications:
+		records, err := m.ruleDB.GetNotifications(ctx, NotificationFilter{
+			Start:  time.UnixMilli(req.Start),
+			End:    time.UnixMilli(req.End),
+			RuleId: req.RuleID,
+			Status: req.Status,
+			Limit:  maxExportRows,
+		})
+		if err != nil {
+			return newApiErrorInternal(err)
+		}
+		if visible != nil {
+			scoped := records[:0]
+			for _, record := range records {
+				if visible[record.RuleId] {
+					scoped = append(scoped, record)
+				}
+			}
+			records = scoped
+		}
+		table = notificationsTable(records)
+	}
+
+	if err := table.write(w, req.Format); err != nil {
+		return newApiErrorInternal(err)
+	}
+	return nil
+}
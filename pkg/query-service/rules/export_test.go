@@ -0,0 +1,84 @@
+package rules
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	am "go.signoz.io/signoz/pkg/query-service/integrations/alertManager"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+func TestExportStateHistory(t *testing.T) {
+	items := []v3.RuleStateHistory{
+		{RuleID: "1", RuleName: "high latency", OverallState: "firing", OverallStateChanged: true, State: "firing", StateChanged: true, UnixMilli: 1700000000000, Labels: `{"service":"api"}`, Fingerprint: 42, Value: 1.5},
+	}
+	table := stateHistoryTable(items)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, table.write(buf, ExportCSV))
+	assert.Equal(t, "rule_id,rule_name,overall_state,overall_state_changed,state,state_changed,timestamp,labels,fingerprint,value\n"+
+		`1,high latency,firing,true,firing,true,2023-11-14T22:13:20Z,"{""service"":""api""}",42,1.5`+"\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, table.write(buf, ExportJSON))
+	var objects []map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &objects))
+	require.Len(t, objects, 1)
+	assert.Equal(t, "42", objects[0]["fingerprint"])
+	assert.Equal(t, "2023-11-14T22:13:20Z", objects[0]["timestamp"])
+
+	// the json lines have one object per row
+	table.rows = append(table.rows, table.rows[0])
+	buf.Reset()
+	require.NoError(t, table.write(buf, ExportNDJSON))
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	var object map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &object))
+	assert.Equal(t, "high latency", object["rule_name"])
+	assert.Equal(t, 1.5, object["value"])
+}
+
+func TestExportRequestValidate(t *testing.T) {
+	req := ExportRequest{Source: ExportNotifications, Format: ExportNDJSON, Start: 1, End: 2}
+	assert.NoError(t, req.Validate())
+	assert.Equal(t, "notifications-1-2.ndjson", req.FileName())
+	assert.Equal(t, "application/x-ndjson", req.ContentType())
+
+	// the parquet export is not written yet
+	req.Format = ExportParquet
+	assert.ErrorIs(t, req.Validate(), ErrExportParquet)
+	req.Format = "xlsx"
+	assert.ErrorContains(t, req.Validate(), "unknown export format")
+	req.Format, req.Source = ExportCSV, "alerts"
+	assert.Error(t, req.Validate())
+	req.Source, req.End = ExportStateHistory, 1
+	assert.Error(t, req.Validate())
+}
+
+func TestNotificationRecords(t *testing.T) {
+	now := time.Now()
+	alerts := []*am.Alert{
+		{Labels: labels.FromMap(map[string]string{labels.AlertNameLabel: "high latency", labels.AlertRuleIdLabel: "1"}), Receivers: []string{"slack"}},
+		{Labels: labels.FromMap(map[string]string{labels.AlertNameLabel: "high latency", labels.AlertRuleIdLabel: "1"}), EndsAt: now.Add(-time.Second)},
+	}
+	records := newNotificationRecords(alerts, NotificationDelivered, now)
+	require.Len(t, records, 2)
+	assert.Equal(t, "1", records[0].RuleId)
+	assert.Equal(t, "high latency", records[0].AlertName)
+	assert.Equal(t, "firing", records[0].State)
+	assert.Equal(t, []string{"slack"}, records[0].Receivers)
+	assert.Equal(t, NotificationDelivered, records[0].Status)
+	assert.Equal(t, "resolved", records[1].State)
+
+	table := notificationsTable(records)
+	buf := &bytes.Buffer{}
+	require.NoError(t, table.write(buf, ExportCSV))
+	assert.Contains(t, buf.String(), "1,high latency,")
+}
@@ -0,0 +1,186 @@
+package rules
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/common"
+	"go.signoz.io/signoz/pkg/query-service/formatter"
+	am "go.signoz.io/signoz/pkg/query-service/integrations/alertManager"
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+// FormatSettings are the options the values and the thresholds of the
+// alerts of an org are formatted with, in the alerts, the notifications
+// and their templates. The settings without an org apply to the orgs
+// without their own. The templates doing math on the formatted $value
+// should use $values once the separators are changed.
+type FormatSettings struct {
+	OrgId string `json:"orgId"`
+	// Locale is a notification locale whose separators are used when the
+	// settings do not set them
+	Locale string `json:"locale,omitempty"`
+	formatter.Options
+	UpdatedAt time.Time `json:"updatedAt"`
+	UpdatedBy string    `json:"updatedBy"`
+}
+
+func (s *FormatSettings) Validate() error {
+	return s.Options.Validate()
+}
+
+// FormatCache holds the format options of the orgs for the rules. The
+// map is replaced as a whole on every update so readers can use it
+// without copying.
+type FormatCache struct {
+	mtx  sync.RWMutex
+	orgs map[string]*formatter.Options
+	// orgOf returns the org of a rule
+	orgOf func(ruleID string) string
+}
+
+func NewFormatCache() *FormatCache {
+	return &FormatCache{orgs: map[string]*formatter.Options{}}
+}
+
+func (c *FormatCache) set(orgs map[string]*formatter.Options) {
+	c.mtx.Lock()
+	c.orgs = orgs
+	c.mtx.Unlock()
+}
+
+// forOrg returns the format options of the org, nil when neither the org
+// nor the default settings are set
+func (c *FormatCache) forOrg(org string) *formatter.Options {
+	if c == nil {
+		return nil
+	}
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	if opts, ok := c.orgs[org]; ok {
+		return opts
+	}
+	return c.orgs[""]
+}
+
+// forRule returns the format options of the org of the rule
+func (c *FormatCache) forRule(ruleID string) *formatter.Options {
+	if c == nil {
+		return nil
+	}
+	org := ""
+	if c.orgOf != nil {
+		org = c.orgOf(ruleID)
+	}
+	return c.forOrg(org)
+}
+
+// localeOptions fills the separators of the options the settings do not
+// set from their locale
+func (m *Manager) localeOptions(s *FormatSettings) (*formatter.Options, error) {
+	opts := s.Options
+	if s.Locale == "" || (opts.DecimalSeparator != "" && opts.ThousandsSeparator != "") {
+		return &opts, nil
+	}
+	locale, ok := am.BuiltinLocale(s.Locale)
+	if !ok {
+		if m.reader == nil {
+			return nil, fmt.Errorf("unknown locale %s", s.Locale)
+		}
+		l, apiErr := m.reader.GetLocale(s.Locale)
+		if apiErr != nil {
+			return nil, fmt.Errorf("unknown locale %s", s.Locale)
+		}
+		locale = *l
+	}
+	if opts.DecimalSeparator == "" {
+		opts.DecimalSeparator = locale.DecimalSeparator
+	}
+	if opts.ThousandsSeparator == "" {
+		opts.ThousandsSeparator = locale.ThousandsSeparator
+	}
+	return &opts, nil
+}
+
+// ReloadFormatSettings makes the rules use the stored format settings
+func (m *Manager) ReloadFormatSettings(ctx context.Context) error {
+	settings, err := m.ruleDB.GetFormatSettings(ctx)
+	if err != nil {
+		return err
+	}
+	orgs := make(map[string]*formatter.Options, len(settings))
+	for i := range settings {
+		opts, err := m.localeOptions(&settings[i])
+		if err != nil {
+			return fmt.Errorf("format settings of org %q: %w", settings[i].OrgId, err)
+		}
+		orgs[settings[i].OrgId] = opts
+	}
+	m.opts.Formats.set(orgs)
+	return nil
+}
+
+// FormatSettings returns the format settings of the org of the request,
+// the default settings when the org has none
+func (m *Manager) FormatSettings(ctx context.Context) (*FormatSettings, *model.ApiError) {
+	settings, err := m.ruleDB.GetFormatSettings(ctx)
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	org := tenantOf(ctx)
+	var fallback *FormatSettings
+	for i := range settings {
+		switch settings[i].OrgId {
+		case org:
+			return &settings[i], nil
+		case "":
+			fallback = &settings[i]
+		}
+	}
+	if fallback != nil {
+		return fallback, nil
+	}
+	return &FormatSettings{OrgId: org}, nil
+}
+
+// SetFormatSettings stores the format settings of the org of the request
+func (m *Manager) SetFormatSettings(ctx context.Context, settings *FormatSettings) *model.ApiError {
+	if err := settings.Validate(); err != nil {
+		return &model.ApiError{Typ: model.ErrorBadData, Err: err}
+	}
+	if _, err := m.localeOptions(settings); err != nil {
+		return &model.ApiError{Typ: model.ErrorBadData, Err: err}
+	}
+	settings.OrgId = tenantOf(ctx)
+	settings.UpdatedAt = time.Now()
+	settings.UpdatedBy = ""
+	if user := common.GetUserFromContext(ctx); user != nil {
+		settings.UpdatedBy = user.Email
+	}
+	if err := m.ruleDB.SetFormatSettings(ctx, *settings); err != nil {
+		return newApiErrorInternal(err)
+	}
+	if err := m.ReloadFormatSettings(ctx); err != nil {
+		return newApiErrorInternal(err)
+	}
+	return nil
+}
+
+// DeleteFormatSettings deletes the format settings of the org of the
+// request, its values are formatted with the default settings again
+func (m *Manager) DeleteFormatSettings(ctx context.Context) *model.ApiError {
+	if err := m.ruleDB.DeleteFormatSettings(ctx, tenantOf(ctx)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("the org has no format settings")}
+		}
+		return newApiErrorInternal(err)
+	}
+	if err := m.ReloadFormatSettings(ctx); err != nil {
+		return newApiErrorInternal(err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/formatter"
+)
+
+func TestFormatCache(t *testing.T) {
+	var nilCache *FormatCache
+	assert.Nil(t, nilCache.forRule("1"))
+
+	c := NewFormatCache()
+	c.orgOf = func(ruleID string) string {
+		if ruleID == "1" {
+			return "acme"
+		}
+		return "other"
+	}
+	assert.Nil(t, c.forRule("1"))
+
+	acme := &formatter.Options{DecimalSeparator: ","}
+	fallback := &formatter.Options{Bytes: formatter.BytesIEC}
+	c.set(map[string]*formatter.Options{"acme": acme, "": fallback})
+	assert.Same(t, acme, c.forRule("1"))
+	assert.Same(t, fallback, c.forRule("2"))
+}
+
+func TestLocaleOptions(t *testing.T) {
+	m := &Manager{}
+
+	opts, err := m.localeOptions(&FormatSettings{Locale: "de", Options: formatter.Options{MaxSignificantDigits: 3}})
+	require.NoError(t, err)
+	assert.Equal(t, formatter.Options{DecimalSeparator: ",", ThousandsSeparator: ".", MaxSignificantDigits: 3}, *opts)
+
+	// the separators of the settings take precedence over the locale
+	opts, err = m.localeOptions(&FormatSettings{Locale: "de", Options: formatter.Options{ThousandsSeparator: " "}})
+	require.NoError(t, err)
+	assert.Equal(t, formatter.Options{DecimalSeparator: ",", ThousandsSeparator: " "}, *opts)
+
+	_, err = m.localeOptions(&FormatSettings{Locale: "xx"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,280 @@
+package rules
+
+import (
+	"fmt"
+
+	"github.com/SigNoz/govaluate"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/postprocess"
+)
+
+// isFormula tells whether the builder query is a formula over the other
+// queries, like A/B*100. Formulas are told apart from queries by an
+// expression other than the query name.
+func isFormula(name string, q *v3.BuilderQuery) bool {
+	return q.Expression != "" && q.Expression != name
+}
+
+// validateFormulas checks the formulas of the builder queries of the rule.
+// A formula can only use the queries of the rule, not other formulas, and
+// the group by of its queries must allow joining their series.
+func validateFormulas(cq *v3.CompositeQuery) []error {
+	var errs []error
+	for name, q := range cq.BuilderQueries {
+		if !isFormula(name, q) {
+			continue
+		}
+		expression, err := govaluate.NewEvaluableExpressionWithFunctions(q.Expression, postprocess.EvalFuncs())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid formula %s of query %s: %v", q.Expression, name, err))
+			continue
+		}
+
+		groupKeys := map[string]interface{}{}
+		for _, v := range expression.Vars() {
+			varQuery, ok := cq.BuilderQueries[v]
+			if !ok {
+				errs = append(errs, fmt.Errorf("formula %s uses the unknown query %s", name, v))
+				continue
+			}
+			if isFormula(v, varQuery) {
+				errs = append(errs, fmt.Errorf("formula %s uses the formula %s, formulas can only use queries", name, v))
+				continue
+			}
+			keys := []string{}
+			for _, key := range varQuery.GroupBy {
+				keys = append(keys, key.Key)
+			}
+			groupKeys[v] = keys
+		}
+		if len(groupKeys) == 0 {
+			continue
+		}
+		if can, _, err := expression.CanJoin(groupKeys); err != nil || !can {
+			errs = append(errs, fmt.Errorf("the queries of formula %s can not be joined, they need the same group by or a subset of it", name))
+		}
+	}
+	return errs
+}
+
+// formulaUses tells whether the formula uses the query
+func formulaUses(formula, query string) bool {
+	expression, err := govaluate.NewEvaluableExpressionWithFunctions(formula, postprocess.EvalFuncs())
+	if err != nil {
+		return false
+	}
+	for _, v := range expression.Vars() {
+		if v == query {
+			return true
+		}
+	}
+	return false
+}
+
+// unitValue is the unit of a formula or of an operand of a formula. The
+// ratios of queries of the same unit are percentunit, scaled by 100 for
+// percent.
+type unitValue struct {
+	unit  string
+	scale float64
+	// constant marks the numbers of the formula
+	constant bool
+	// unknown marks the values whose unit can not be inferred
+	unknown bool
+}
+
+const (
+	unitPercent     = "percent"
+	unitPercentUnit = "percentunit"
+)
+
+func queryUnit(unit string) unitValue {
+	if unit == unitPercent {
+		return unitValue{unit: unitPercentUnit, scale: 100}
+	}
+	return unitValue{unit: unit, scale: 1}
+}
+
+func (u unitValue) String() string {
+	switch {
+	case u.unknown || u.constant:
+		return ""
+	case u.unit == unitPercentUnit && u.scale == 100:
+		return unitPercent
+	case u.scale == 1:
+		return u.unit
+	}
+	// a scaled unit, like ms/1000, has no name
+	return ""
+}
+
+// FormulaUnit infers the unit of the formula from the units of its
+// queries. The sum and difference of queries of the same unit keep the
+// unit, a ratio of queries of the same unit is percentunit and percent
+// when multiplied by 100, and numbers scale the unit. The unit is empty
+// when it can not be inferred, e.g. for the functions.
+func FormulaUnit(formula string, units map[string]string) string {
+	expression, err := govaluate.NewEvaluableExpressionWithFunctions(formula, postprocess.EvalFuncs())
+	if err != nil {
+		return ""
+	}
+	p := &unitParser{tokens: expression.Tokens(), units: units}
+	u := p.expr()
+	if p.pos != len(p.tokens) {
+		return ""
+	}
+	return u.String()
+}
+
+// unitParser walks the tokens of the formula with the precedence of the
+// arithmetic operators
+type unitParser struct {
+	tokens []govaluate.ExpressionToken
+	pos    int
+	units  map[string]string
+}
+
+func (p *unitParser) peek() (govaluate.ExpressionToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return govaluate.ExpressionToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *unitParser) modifier(ops ...string) (string, bool) {
+	t, ok := p.peek()
+	if !ok || t.Kind != govaluate.MODIFIER {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.Value == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+// expr is a sum of terms
+func (p *unitParser) expr() unitValue {
+	u := p.term()
+	for {
+		if _, ok := p.modifier("+", "-"); !ok {
+			return u
+		}
+		u = addUnits(u, p.term())
+	}
+}
+
+// term is a product of factors
+func (p *unitParser) term() unitValue {
+	u := p.factor()
+	for {
+		op, ok := p.modifier("*", "/", "%", "**")
+		if !ok {
+			return u
+		}
+		v := p.factor()
+		switch op {
+		case "*":
+			u = multiplyUnits(u, v)
+		case "/":
+			u = divideUnits(u, v)
+		default:
+			u = unitValue{unknown: true}
+		}
+	}
+}
+
+func (p *unitParser) factor() unitValue {
+	t, ok := p.peek()
+	if !ok {
+		return unitValue{unknown: true}
+	}
+	p.pos++
+	switch t.Kind {
+	case govaluate.PREFIX:
+		return p.factor()
+	case govaluate.NUMERIC:
+		n, _ := t.Value.(float64)
+		return unitValue{constant: true, scale: n}
+	case govaluate.VARIABLE:
+		name, _ := t.Value.(string)
+		return queryUnit(p.units[name])
+	case govaluate.CLAUSE:
+		u := p.expr()
+		p.closeClause()
+		return u
+	case govaluate.FUNCTION:
+		// the arguments are walked to find the end of the call, the
+		// functions change the unit
+		if t, ok := p.peek(); ok && t.Kind == govaluate.CLAUSE {
+			p.pos++
+			for {
+				p.expr()
+				if t, ok := p.peek(); ok && t.Kind == govaluate.SEPARATOR {
+					p.pos++
+					continue
+				}
+				break
+			}
+			p.closeClause()
+		}
+		return unitValue{unknown: true}
+	}
+	return unitValue{unknown: true}
+}
+
+func (p *unitParser) closeClause() {
+	if t, ok := p.peek(); ok && t.Kind == govaluate.CLAUSE_CLOSE {
+		p.pos++
+	}
+}
+
+func addUnits(a, b unitValue) unitValue {
+	switch {
+	case a.unknown || b.unknown:
+		return unitValue{unknown: true}
+	case a.constant && b.constant:
+		return a
+	case a.constant:
+		return b
+	case b.constant:
+		return a
+	case a.unit == b.unit && a.scale == b.scale:
+		return a
+	}
+	return unitValue{unknown: true}
+}
+
+func multiplyUnits(a, b unitValue) unitValue {
+	switch {
+	case a.unknown || b.unknown:
+		return unitValue{unknown: true}
+	case a.constant && b.constant:
+		return unitValue{constant: true, scale: a.scale * b.scale}
+	case a.constant:
+		return unitValue{unit: b.unit, scale: b.scale * a.scale}
+	case b.constant:
+		return unitValue{unit: a.unit, scale: a.scale * b.scale}
+	}
+	// the product of two queries has no unit of its own
+	return unitValue{unknown: true}
+}
+
+func divideUnits(a, b unitValue) unitValue {
+	switch {
+	case a.unknown || b.unknown || (b.constant && b.scale == 0):
+		return unitValue{unknown: true}
+	case a.constant && b.constant:
+		return unitValue{constant: true, scale: a.scale / b.scale}
+	case b.constant:
+		return unitValue{unit: a.unit, scale: a.scale / b.scale}
+	case a.constant:
+		return unitValue{unknown: true}
+	case a.unit == b.unit:
+		// the ratio of queries of the same unit, e.g. errors over requests
+		return unitValue{unit: unitPercentUnit, scale: a.scale / b.scale}
+	}
+	return unitValue{unknown: true}
+}
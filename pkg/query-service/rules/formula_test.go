@@ -0,0 +1,102 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func TestFormulaUnit(t *testing.T) {
+	units := map[string]string{"A": "reqps", "B": "reqps", "C": "ms", "D": "percentunit"}
+	cases := []struct {
+		formula string
+		unit    string
+	}{
+		{"A/B*100", "percent"},
+		{"100 * A / B", "percent"},
+		{"A/B", "percentunit"},
+		{"(A - B) / B * 100", "percent"},
+		{"A-B", "reqps"},
+		{"A+B+1", "reqps"},
+		{"-A", "reqps"},
+		{"D*100", "percent"},
+		{"A/C", ""},
+		{"A*B", ""},
+		{"C/1000", ""},
+		{"A+C", ""},
+		{"sqrt(A)", ""},
+		{"A/B*100 + log10(C)", ""},
+		{"A/E", ""},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.unit, FormulaUnit(c.formula, units), c.formula)
+	}
+}
+
+func TestValidateFormulas(t *testing.T) {
+	query := func(name string, groupBy ...string) *v3.BuilderQuery {
+		q := &v3.BuilderQuery{QueryName: name, Expression: name, DataSource: v3.DataSourceMetrics}
+		for _, key := range groupBy {
+			q.GroupBy = append(q.GroupBy, v3.AttributeKey{Key: key})
+		}
+		return q
+	}
+	formula := func(name, expression string) *v3.BuilderQuery {
+		return &v3.BuilderQuery{QueryName: name, Expression: expression}
+	}
+
+	cq := &v3.CompositeQuery{BuilderQueries: map[string]*v3.BuilderQuery{
+		"A":  query("A", "service"),
+		"B":  query("B", "service"),
+		"F1": formula("F1", "A/B*100"),
+	}}
+	assert.Empty(t, validateFormulas(cq))
+
+	cq.BuilderQueries["F2"] = formula("F2", "F1 - C")
+	cq.BuilderQueries["F3"] = formula("F3", "A/")
+	assert.Len(t, validateFormulas(cq), 3)
+
+	cq = &v3.CompositeQuery{BuilderQueries: map[string]*v3.BuilderQuery{
+		"A":  query("A", "service"),
+		"B":  query("B", "host"),
+		"F1": formula("F1", "A/B"),
+	}}
+	assert.Len(t, validateFormulas(cq), 1)
+}
+
+func TestThresholdRuleFormulaUnit(t *testing.T) {
+	rule, err := ParsePostableRule([]byte(`{
+		"alert": "error rate",
+		"ruleType": "threshold_rule",
+		"condition": {
+			"compositeQuery": {
+				"queryType": "builder",
+				"panelType": "graph",
+				"builderQueries": {
+					"A": {"queryName": "A", "dataSource": "traces", "aggregateOperator": "count", "stepInterval": 60},
+					"B": {"queryName": "B", "dataSource": "traces", "aggregateOperator": "count", "stepInterval": 60},
+					"F1": {"queryName": "F1", "expression": "A/B*100"}
+				}
+			},
+			"queryUnits": {"A": "cpm", "B": "cpm"},
+			"selectedQueryName": "F1",
+			"op": "1",
+			"target": 5,
+			"targetUnit": "percent",
+			"matchType": "1"
+		}
+	}`))
+	require.NoError(t, err)
+	// queries without an aggregate attribute are not formulas
+	assert.Equal(t, "A", rule.RuleCondition.CompositeQuery.BuilderQueries["A"].Expression)
+
+	tr, err := NewThresholdRule("1", rule, ThresholdRuleOpts{}, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "percent", tr.Unit())
+	assert.Equal(t, 5.0, tr.targetVal())
+
+	rule.RuleCondition.SelectedQuery = "F2"
+	assert.Error(t, rule.Validate())
+}
@@ -0,0 +1,180 @@
+package rules
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	am "go.signoz.io/signoz/pkg/query-service/integrations/alertManager"
+)
+
+// staleEvaluationIntervals is the number of intervals of its task a rule
+// may go without an evaluation before its evaluations are stale
+const staleEvaluationIntervals = 3
+
+// RulesHealthSummary is the health of the evaluations of all the rules and
+// of the notifications, for the status pages of the operations
+type RulesHealthSummary struct {
+	// Healthy is false when a rule is stale or failing, or the
+	// notifications are failing or dropped
+	Healthy bool `json:"healthy"`
+	Total   int  `json:"total"`
+	// Health is the number of rules by the health of their last
+	// evaluation
+	Health          map[RuleHealth]int `json:"health"`
+	SchedulerPaused bool               `json:"schedulerPaused"`
+	// Stale are the rules not evaluated for several intervals, e.g. behind
+	// a saturated worker pool. They are not checked while the scheduler is
+	// paused.
+	Stale []StaleEvaluation `json:"stale"`
+	// Failing are the rules whose query failed several evaluations in a row
+	Failing  []FailingRule     `json:"failing"`
+	Notifier NotifierBacklog   `json:"notifier"`
+	External []NotifierBacklog `json:"external,omitempty"`
+}
+
+// StaleEvaluation is a rule whose last evaluation is older than several
+// intervals of its task
+type StaleEvaluation struct {
+	RuleID         string    `json:"ruleId"`
+	RuleName       string    `json:"ruleName"`
+	LastEvaluation time.Time `json:"lastEvaluation"`
+	FrequencyMs    int64     `json:"frequencyMs"`
+	// BehindMs is how long the evaluation is overdue
+	BehindMs int64 `json:"behindMs"`
+}
+
+// FailingRule is a rule whose query keeps failing
+type FailingRule struct {
+	RuleID              string        `json:"ruleId"`
+	RuleName            string        `json:"ruleName"`
+	LastError           string        `json:"lastError"`
+	LastErrorCategory   ErrorCategory `json:"lastErrorCategory,omitempty"`
+	ConsecutiveFailures int           `json:"consecutiveFailures"`
+	Breaker             BreakerState  `json:"breaker"`
+	LastEvaluation      time.Time     `json:"lastEvaluation"`
+}
+
+// NotifierBacklog is the backlog of the notifications sent to an alert
+// manager
+type NotifierBacklog struct {
+	// Name is the name of the external alert manager, empty for the one of
+	// the rules
+	Name                string `json:"name,omitempty"`
+	QueueDepth          int    `json:"queueDepth"`
+	QueueCapacity       int    `json:"queueCapacity"`
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+	// Dropped is the number of notifications dropped since the start
+	Dropped uint64 `json:"dropped"`
+}
+
+func notifierBacklog(name string, stats am.NotifierStats) NotifierBacklog {
+	b := NotifierBacklog{Name: name, QueueDepth: stats.QueueDepth, QueueCapacity: stats.QueueCapacity, ConsecutiveFailures: stats.ConsecutiveFailures}
+	for _, n := range stats.Dropped {
+		b.Dropped += n
+	}
+	return b
+}
+
+func (b NotifierBacklog) healthy() bool {
+	return b.ConsecutiveFailures == 0 && b.Dropped == 0
+}
+
+// ruleHealthEntry is a rule with the health of its evaluations and the
+// interval of its task
+type ruleHealthEntry struct {
+	id        string
+	name      string
+	status    *RuleHealthStatus
+	frequency time.Duration
+}
+
+// summarizeRulesHealth counts the rules by health and lists the stale and
+// the failing ones, a rule fails once its query failed threshold
+// evaluations in a row
+func summarizeRulesHealth(entries []ruleHealthEntry, now time.Time, threshold int, paused bool) *RulesHealthSummary {
+	s := &RulesHealthSummary{
+		Total:           len(entries),
+		Health:          map[RuleHealth]int{HealthGood: 0, HealthBad: 0, HealthUnknown: 0},
+		SchedulerPaused: paused,
+		Stale:           []StaleEvaluation{},
+		Failing:         []FailingRule{},
+	}
+	for _, e := range entries {
+		s.Health[e.status.Health]++
+
+		last := e.status.LastEvaluation
+		if !paused && e.frequency > 0 && !last.IsZero() {
+			if behind := now.Sub(last) - staleEvaluationIntervals*e.frequency; behind > 0 {
+				s.Stale = append(s.Stale, StaleEvaluation{
+					RuleID:         e.id,
+					RuleName:       e.name,
+					LastEvaluation: last,
+					FrequencyMs:    e.frequency.Milliseconds(),
+					BehindMs:       behind.Milliseconds(),
+				})
+			}
+		}
+
+		if e.status.Health != HealthBad || e.status.Breaker == nil {
+			continue
+		}
+		if b := e.status.Breaker; b.ConsecutiveFailures >= threshold || b.State != BreakerClosed {
+			s.Failing = append(s.Failing, FailingRule{
+				RuleID:              e.id,
+				RuleName:            e.name,
+				LastError:           e.status.LastError,
+				LastErrorCategory:   e.status.LastErrorCategory,
+				ConsecutiveFailures: b.ConsecutiveFailures,
+				Breaker:             b.State,
+				LastEvaluation:      last,
+			})
+		}
+	}
+	sort.Slice(s.Stale, func(i, j int) bool {
+		return s.Stale[i].BehindMs > s.Stale[j].BehindMs
+	})
+	sort.Slice(s.Failing, func(i, j int) bool {
+		return s.Failing[i].ConsecutiveFailures > s.Failing[j].ConsecutiveFailures
+	})
+	s.Healthy = len(s.Stale) == 0 && len(s.Failing) == 0
+	return s
+}
+
+// RulesHealthSummary returns the health of the evaluations of the rules of
+// the org and the backlog of the notifications
+func (m *Manager) RulesHealthSummary(ctx context.Context) *RulesHealthSummary {
+	org := tenantOf(ctx)
+	var entries []ruleHealthEntry
+	m.mtx.RLock()
+	for id, rule := range m.rules {
+		if !visibleTo(m.opts.tenants.of(id), org) {
+			continue
+		}
+		e := ruleHealthEntry{id: id, name: rule.Name(), status: m.ruleHealth(rule)}
+		if t, ok := m.tasks[prepareTaskName(id)].(scheduledTask); ok {
+			e.frequency = t.Interval()
+		}
+		entries = append(entries, e)
+	}
+	m.mtx.RUnlock()
+
+	threshold := m.opts.BreakerThreshold
+	if threshold <= 0 {
+		threshold = DefaultBreakerThreshold
+	}
+	s := summarizeRulesHealth(entries, time.Now(), threshold, m.opts.scheduler.isPaused())
+
+	s.Notifier = notifierBacklog("", m.NotifierStats())
+	s.Healthy = s.Healthy && s.Notifier.healthy()
+	external := m.ExternalAlertmanagerStats()
+	for name, stats := range external {
+		b := notifierBacklog(name, stats)
+		s.External = append(s.External, b)
+		s.Healthy = s.Healthy && b.healthy()
+	}
+	sort.Slice(s.External, func(i, j int) bool {
+		return s.External[i].Name < s.External[j].Name
+	})
+	return s
+}
@@ -0,0 +1,48 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	am "go.signoz.io/signoz/pkg/query-service/integrations/alertManager"
+)
+
+func TestSummarizeRulesHealth(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	entries := []ruleHealthEntry{
+		{id: "1", name: "checkout latency", frequency: time.Minute, status: &RuleHealthStatus{Health: HealthGood, LastEvaluation: now.Add(-30 * time.Second), Breaker: &BreakerStatus{State: BreakerClosed}}},
+		// behind by more than three intervals
+		{id: "2", name: "cart errors", frequency: time.Minute, status: &RuleHealthStatus{Health: HealthGood, LastEvaluation: now.Add(-5 * time.Minute), Breaker: &BreakerStatus{State: BreakerClosed}}},
+		{id: "3", name: "disk full", frequency: time.Minute, status: &RuleHealthStatus{Health: HealthBad, LastError: "query timed out", LastEvaluation: now.Add(-time.Minute), Breaker: &BreakerStatus{State: BreakerOpen, ConsecutiveFailures: 5}}},
+		// a single failure is not persistent
+		{id: "4", name: "queue lag", frequency: time.Minute, status: &RuleHealthStatus{Health: HealthBad, LastError: "connection reset", LastEvaluation: now.Add(-time.Minute), Breaker: &BreakerStatus{State: BreakerClosed, ConsecutiveFailures: 1}}},
+		{id: "5", name: "new rule", frequency: time.Minute, status: &RuleHealthStatus{Health: HealthUnknown}},
+	}
+
+	s := summarizeRulesHealth(entries, now, DefaultBreakerThreshold, false)
+	assert.False(t, s.Healthy)
+	assert.Equal(t, 5, s.Total)
+	assert.Equal(t, map[RuleHealth]int{HealthGood: 2, HealthBad: 2, HealthUnknown: 1}, s.Health)
+	require.Len(t, s.Stale, 1)
+	assert.Equal(t, StaleEvaluation{RuleID: "2", RuleName: "cart errors", LastEvaluation: now.Add(-5 * time.Minute), FrequencyMs: 60000, BehindMs: 120000}, s.Stale[0])
+	require.Len(t, s.Failing, 1)
+	assert.Equal(t, "3", s.Failing[0].RuleID)
+	assert.Equal(t, "query timed out", s.Failing[0].LastError)
+	assert.Equal(t, BreakerOpen, s.Failing[0].Breaker)
+
+	// the evaluations are not stale while the scheduler is paused
+	s = summarizeRulesHealth(entries[:2], now, DefaultBreakerThreshold, true)
+	assert.True(t, s.Healthy)
+	assert.True(t, s.SchedulerPaused)
+	assert.Empty(t, s.Stale)
+}
+
+func TestNotifierBacklog(t *testing.T) {
+	b := notifierBacklog("", am.NotifierStats{QueueDepth: 10, QueueCapacity: 100, Dropped: map[string]uint64{am.DropQueueFull: 2, am.DropBatchTooLarge: 1}})
+	assert.Equal(t, NotifierBacklog{QueueDepth: 10, QueueCapacity: 100, Dropped: 3}, b)
+	assert.False(t, b.healthy())
+	assert.True(t, notifierBacklog("eu", am.NotifierStats{QueueDepth: 10}).healthy())
+	assert.False(t, notifierBacklog("eu", am.NotifierStats{ConsecutiveFailures: 1}).healthy())
+}
@@ -0,0 +1,195 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultHistoryDetailRetention is how long every item of the state
+	// history is kept
+	DefaultHistoryDetailRetention = 30 * 24 * time.Hour
+	// DefaultHistoryTransitionsRetention is how long the state changes
+	// are kept
+	DefaultHistoryTransitionsRetention = 365 * 24 * time.Hour
+
+	historyCleanupInterval = 24 * time.Hour
+	historyCleanupTimeout  = 5 * time.Minute
+	// historyCleanupKey places the cleanup on the sharding ring so that
+	// only one replica runs it
+	historyCleanupKey = "state-history-cleanup"
+)
+
+// StateHistoryRetention is how long the state history of the rules is kept
+type StateHistoryRetention struct {
+	// Detail is how long every item is kept, the items without a state
+	// change are dropped after it
+	Detail Duration `json:"detail"`
+	// Transitions is how long the state changes are kept
+	Transitions Duration `json:"transitions"`
+}
+
+func (r StateHistoryRetention) Validate() error {
+	if time.Duration(r.Detail) < 24*time.Hour {
+		return fmt.Errorf("the detail retention must be at least a day")
+	}
+	if r.Transitions < r.Detail {
+		return fmt.Errorf("the transitions retention can not be shorter than the detail retention")
+	}
+	return nil
+}
+
+// cutoffs returns the unix milli before which the items without a state
+// change and all the items are dropped
+func (r StateHistoryRetention) cutoffs(now time.Time) (int64, int64) {
+	return now.Add(-time.Duration(r.Detail)).UnixMilli(), now.Add(-time.Duration(r.Transitions)).UnixMilli()
+}
+
+// historyRetentionStore expires the state history, implemented by the
+// reader
+type historyRetentionStore interface {
+	SetRuleStateHistoryTTL(ctx context.Context, detail, transitions time.Duration) error
+	DeleteRuleStateHistory(ctx context.Context, detailBefore, transitionsBefore int64) error
+}
+
+// historyRetention keeps the state history within its retention. The
+// retention is set as the TTL of the table when it changes, a daily
+// cleanup also deletes the expired items for the tables whose TTL was
+// never set or is not merged yet.
+type historyRetention struct {
+	store   historyRetentionStore
+	ruleDB  RuleDB
+	sharder *Sharder
+
+	mtx       sync.RWMutex
+	retention StateHistoryRetention
+
+	done       chan struct{}
+	terminated chan struct{}
+}
+
+func newHistoryRetention(store historyRetentionStore, ruleDB RuleDB, sharder *Sharder, retention StateHistoryRetention) *historyRetention {
+	if retention.Detail <= 0 {
+		retention.Detail = Duration(DefaultHistoryDetailRetention)
+	}
+	if retention.Transitions <= 0 {
+		retention.Transitions = Duration(DefaultHistoryTransitionsRetention)
+	}
+	return &historyRetention{
+		store:      store,
+		ruleDB:     ruleDB,
+		sharder:    sharder,
+		retention:  retention,
+		done:       make(chan struct{}),
+		terminated: make(chan struct{}),
+	}
+}
+
+// load replaces the configured retention with the one set through the
+// api, if any
+func (h *historyRetention) load(ctx context.Context) {
+	stored, err := h.ruleDB.GetStateHistoryRetention(ctx)
+	if err != nil {
+		zap.L().Error("failed to get the state history retention", zap.Error(err))
+		return
+	}
+	if stored == nil {
+		return
+	}
+	h.mtx.Lock()
+	h.retention = *stored
+	h.mtx.Unlock()
+}
+
+func (h *historyRetention) get() StateHistoryRetention {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+	return h.retention
+}
+
+// set applies the retention to the table and stores it
+func (h *historyRetention) set(ctx context.Context, retention StateHistoryRetention) error {
+	if err := retention.Validate(); err != nil {
+		return err
+	}
+	if err := h.store.SetRuleStateHistoryTTL(ctx, time.Duration(retention.Detail), time.Duration(retention.Transitions)); err != nil {
+		return err
+	}
+	if err := h.ruleDB.SetStateHistoryRetention(ctx, retention); err != nil {
+		return err
+	}
+	h.mtx.Lock()
+	h.retention = retention
+	h.mtx.Unlock()
+	return nil
+}
+
+// cleanup deletes the state history past its retention
+func (h *historyRetention) cleanup(ctx context.Context, now time.Time) error {
+	detailBefore, transitionsBefore := h.get().cutoffs(now)
+	return h.store.DeleteRuleStateHistory(ctx, detailBefore, transitionsBefore)
+}
+
+func (h *historyRetention) Run(ctx context.Context) {
+	defer close(h.terminated)
+
+	h.load(ctx)
+
+	tick := time.NewTicker(historyCleanupInterval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-h.done:
+			return
+		case <-tick.C:
+		}
+		if !h.sharder.Owns(historyCleanupKey) {
+			continue
+		}
+		cctx, cancel := context.WithTimeout(ctx, historyCleanupTimeout)
+		if err := h.cleanup(cctx, time.Now()); err != nil {
+			zap.L().Error("failed to clean up the state history", zap.Error(err))
+		}
+		cancel()
+	}
+}
+
+func (h *historyRetention) Stop() {
+	close(h.done)
+	<-h.terminated
+}
+
+// StateHistoryRetention returns how long the state history is kept
+func (m *Manager) StateHistoryRetention() (StateHistoryRetention, error) {
+	if m.opts.historyRetention == nil {
+		return StateHistoryRetention{}, fmt.Errorf("the state history is not stored")
+	}
+	return m.opts.historyRetention.get(), nil
+}
+
+// SetStateHistoryRetention changes how long the state history is kept,
+// the items past the new retention are deleted in the background
+func (m *Manager) SetStateHistoryRetention(ctx context.Context, retention StateHistoryRetention) error {
+	h := m.opts.historyRetention
+	if h == nil {
+		return fmt.Errorf("the state history is not stored")
+	}
+	if err := h.set(ctx, retention); err != nil {
+		return err
+	}
+	zap.L().Info("the state history retention was changed", zap.Duration("detail", time.Duration(retention.Detail)), zap.Duration("transitions", time.Duration(retention.Transitions)))
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), historyCleanupTimeout)
+		defer cancel()
+		if err := h.cleanup(ctx, time.Now()); err != nil {
+			zap.L().Error("failed to clean up the state history", zap.Error(err))
+		}
+	}()
+	return nil
+}
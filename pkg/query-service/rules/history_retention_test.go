@@ -0,0 +1,71 @@
+package rules
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type retentionStoreStub struct {
+	detail, transitions             time.Duration
+	detailBefore, transitionsBefore int64
+}
+
+func (s *retentionStoreStub) SetRuleStateHistoryTTL(ctx context.Context, detail, transitions time.Duration) error {
+	s.detail, s.transitions = detail, transitions
+	return nil
+}
+
+func (s *retentionStoreStub) DeleteRuleStateHistory(ctx context.Context, detailBefore, transitionsBefore int64) error {
+	s.detailBefore, s.transitionsBefore = detailBefore, transitionsBefore
+	return nil
+}
+
+type retentionDB struct {
+	RuleDB
+	stored *StateHistoryRetention
+}
+
+func (db *retentionDB) GetStateHistoryRetention(ctx context.Context) (*StateHistoryRetention, error) {
+	return db.stored, nil
+}
+
+func (db *retentionDB) SetStateHistoryRetention(ctx context.Context, retention StateHistoryRetention) error {
+	db.stored = &retention
+	return nil
+}
+
+func TestStateHistoryRetentionValidate(t *testing.T) {
+	day := Duration(24 * time.Hour)
+	assert.NoError(t, StateHistoryRetention{Detail: day, Transitions: day}.Validate())
+	assert.Error(t, StateHistoryRetention{Detail: Duration(time.Hour), Transitions: day}.Validate())
+	assert.Error(t, StateHistoryRetention{Detail: 2 * day, Transitions: day}.Validate())
+}
+
+func TestStateHistoryRetention(t *testing.T) {
+	store := &retentionStoreStub{}
+	db := &retentionDB{}
+	h := newHistoryRetention(store, db, nil, StateHistoryRetention{})
+	assert.Equal(t, Duration(DefaultHistoryDetailRetention), h.get().Detail)
+	assert.Equal(t, Duration(DefaultHistoryTransitionsRetention), h.get().Transitions)
+
+	now := time.Now()
+	require.NoError(t, h.cleanup(context.Background(), now))
+	assert.Equal(t, now.Add(-DefaultHistoryDetailRetention).UnixMilli(), store.detailBefore)
+	assert.Equal(t, now.Add(-DefaultHistoryTransitionsRetention).UnixMilli(), store.transitionsBefore)
+
+	retention := StateHistoryRetention{Detail: Duration(7 * 24 * time.Hour), Transitions: Duration(90 * 24 * time.Hour)}
+	require.Error(t, h.set(context.Background(), StateHistoryRetention{}))
+	require.NoError(t, h.set(context.Background(), retention))
+	assert.Equal(t, 7*24*time.Hour, store.detail)
+	assert.Equal(t, 90*24*time.Hour, store.transitions)
+	assert.Equal(t, retention, *db.stored)
+
+	// the stored retention wins over the configured one
+	h = newHistoryRetention(store, db, nil, StateHistoryRetention{})
+	h.load(context.Background())
+	assert.Equal(t, retention, h.get())
+}
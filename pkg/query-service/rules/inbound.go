@@ -0,0 +1,426 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// InboundSource identifies the format of an inbound alert webhook
+type InboundSource string
+
+const (
+	InboundSourceAlertmanager InboundSource = "alertmanager"
+	InboundSourceGrafana      InboundSource = "grafana"
+	InboundSourceCloudWatch   InboundSource = "cloudwatch"
+	InboundSourceAzure        InboundSource = "azure"
+	InboundSourceGCP          InboundSource = "gcp"
+	// the passive checks of nagios and zabbix are not webhooks, they are
+	// read by ParseNRDPCheckResults and ParseZabbixSenderData
+	InboundSourceNagios InboundSource = "nagios"
+	InboundSourceZabbix InboundSource = "zabbix"
+
+	// ExternalSourceLabel is added to the alerts received from other
+	// systems with the name of the source
+	ExternalSourceLabel = "external_source"
+)
+
+// ExternalAlert is an alert raised by a system other than the SigNoz rules
+type ExternalAlert struct {
+	Labels       map[string]string
+	Annotations  map[string]string
+	StartsAt     time.Time
+	EndsAt       time.Time
+	Resolved     bool
+	GeneratorURL string
+}
+
+// externalStateKey identifies an alert received from another system
+type externalStateKey struct {
+	org string
+	fp  uint64
+}
+
+// externalRuleID is the rule id the state history of the alerts of the
+// source received for the org is recorded with
+func externalRuleID(org string, source InboundSource) string {
+	if org == "" {
+		return fmt.Sprintf("external_%s", source)
+	}
+	return fmt.Sprintf("external_%s_%s", org, source)
+}
+
+// SNSSubscriptionConfirmation is returned when the payload is the
+// confirmation request sent by AWS SNS before the first notification
+type SNSSubscriptionConfirmation struct {
+	TopicArn     string
+	SubscribeURL string
+}
+
+func (s *SNSSubscriptionConfirmation) Error() string {
+	return fmt.Sprintf("sns subscription confirmation for topic %s", s.TopicArn)
+}
+
+// ParseInboundAlerts converts the webhook payload of the source into alerts
+func ParseInboundAlerts(source InboundSource, body []byte) ([]ExternalAlert, error) {
+	var alerts []ExternalAlert
+	var err error
+
+	switch source {
+	case InboundSourceAlertmanager:
+		alerts, err = parseAlertmanagerWebhook(body)
+	case InboundSourceGrafana:
+		alerts, err = parseGrafanaWebhook(body)
+	case InboundSourceCloudWatch:
+		alerts, err = parseCloudWatchWebhook(body)
+	case InboundSourceAzure:
+		alerts, err = parseAzureWebhook(body)
+	case InboundSourceGCP:
+		alerts, err = parseGCPWebhook(body)
+	default:
+		return nil, fmt.Errorf("unsupported alert source %q", source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range alerts {
+		if alerts[i].Labels[labels.AlertNameLabel] == "" {
+			return nil, fmt.Errorf("alert %d has no name", i)
+		}
+		for k, v := range alerts[i].Labels {
+			if v == "" {
+				delete(alerts[i].Labels, k)
+			}
+		}
+		for k, v := range alerts[i].Annotations {
+			if v == "" {
+				delete(alerts[i].Annotations, k)
+			}
+		}
+		alerts[i].Labels[ExternalSourceLabel] = string(source)
+	}
+	return alerts, nil
+}
+
+type alertmanagerWebhook struct {
+	ExternalURL string `json:"externalURL"`
+	Alerts      []struct {
+		Status       string            `json:"status"`
+		Labels       map[string]string `json:"labels"`
+		Annotations  map[string]string `json:"annotations"`
+		StartsAt     time.Time         `json:"startsAt"`
+		EndsAt       time.Time         `json:"endsAt"`
+		GeneratorURL string            `json:"generatorURL"`
+	} `json:"alerts"`
+}
+
+func parseAlertmanagerWebhook(body []byte) ([]ExternalAlert, error) {
+	payload := alertmanagerWebhook{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid alertmanager payload: %w", err)
+	}
+
+	alerts := make([]ExternalAlert, 0, len(payload.Alerts))
+	for _, a := range payload.Alerts {
+		alert := ExternalAlert{
+			Labels:       copyStringMap(a.Labels),
+			Annotations:  copyStringMap(a.Annotations),
+			StartsAt:     a.StartsAt,
+			Resolved:     a.Status == "resolved",
+			GeneratorURL: a.GeneratorURL,
+		}
+		// alertmanager sends the zero time for alerts that are still firing
+		if alert.Resolved && a.EndsAt.After(a.StartsAt) {
+			alert.EndsAt = a.EndsAt
+		}
+		if alert.GeneratorURL == "" {
+			alert.GeneratorURL = payload.ExternalURL
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, nil
+}
+
+type grafanaLegacyWebhook struct {
+	RuleName    string            `json:"ruleName"`
+	RuleURL     string            `json:"ruleUrl"`
+	State       string            `json:"state"`
+	Title       string            `json:"title"`
+	Message     string            `json:"message"`
+	Tags        map[string]string `json:"tags"`
+	EvalMatches []struct {
+		Metric string            `json:"metric"`
+		Value  float64           `json:"value"`
+		Tags   map[string]string `json:"tags"`
+	} `json:"evalMatches"`
+}
+
+// parseGrafanaWebhook accepts both the unified alerting payload, which
+// follows the alertmanager format, and the legacy dashboard alerts
+func parseGrafanaWebhook(body []byte) ([]ExternalAlert, error) {
+	probe := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &probe); err != nil {
+		return nil, fmt.Errorf("invalid grafana payload: %w", err)
+	}
+	if _, ok := probe["alerts"]; ok {
+		return parseAlertmanagerWebhook(body)
+	}
+
+	payload := grafanaLegacyWebhook{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid grafana payload: %w", err)
+	}
+
+	switch payload.State {
+	case "alerting", "ok", "no_data":
+	default:
+		// paused and pending do not change the alert
+		return nil, nil
+	}
+
+	newAlert := func(extra map[string]string) ExternalAlert {
+		lbls := copyStringMap(payload.Tags)
+		for k, v := range extra {
+			lbls[k] = v
+		}
+		lbls[labels.AlertNameLabel] = payload.RuleName
+		return ExternalAlert{
+			Labels: lbls,
+			Annotations: map[string]string{
+				labels.AlertSummaryLabel:     payload.Title,
+				labels.AlertDescriptionLabel: payload.Message,
+			},
+			StartsAt:     time.Now(),
+			Resolved:     payload.State == "ok",
+			GeneratorURL: payload.RuleURL,
+		}
+	}
+
+	if len(payload.EvalMatches) == 0 {
+		return []ExternalAlert{newAlert(nil)}, nil
+	}
+	alerts := make([]ExternalAlert, 0, len(payload.EvalMatches))
+	for _, m := range payload.EvalMatches {
+		extra := copyStringMap(m.Tags)
+		if m.Metric != "" {
+			extra["metric"] = m.Metric
+		}
+		alert := newAlert(extra)
+		alert.Annotations["value"] = fmt.Sprintf("%v", m.Value)
+		alerts = append(alerts, alert)
+	}
+	return alerts, nil
+}
+
+type snsEnvelope struct {
+	Type         string `json:"Type"`
+	TopicArn     string `json:"TopicArn"`
+	Message      string `json:"Message"`
+	SubscribeURL string `json:"SubscribeURL"`
+}
+
+type cloudWatchAlarm struct {
+	AlarmName        string `json:"AlarmName"`
+	AlarmDescription string `json:"AlarmDescription"`
+	AWSAccountId     string `json:"AWSAccountId"`
+	NewStateValue    string `json:"NewStateValue"`
+	NewStateReason   string `json:"NewStateReason"`
+	StateChangeTime  string `json:"StateChangeTime"`
+	Region           string `json:"Region"`
+	AlarmArn         string `json:"AlarmArn"`
+	Trigger          struct {
+		MetricName string `json:"MetricName"`
+		Namespace  string `json:"Namespace"`
+		Dimensions []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"Dimensions"`
+	} `json:"Trigger"`
+}
+
+// parseCloudWatchWebhook handles CloudWatch alarms delivered through an
+// SNS http subscription
+func parseCloudWatchWebhook(body []byte) ([]ExternalAlert, error) {
+	envelope := snsEnvelope{}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, fmt.Errorf("invalid sns payload: %w", err)
+	}
+	switch envelope.Type {
+	case "SubscriptionConfirmation":
+		return nil, &SNSSubscriptionConfirmation{TopicArn: envelope.TopicArn, SubscribeURL: envelope.SubscribeURL}
+	case "Notification":
+	default:
+		return nil, fmt.Errorf("unsupported sns message type %q", envelope.Type)
+	}
+
+	alarm := cloudWatchAlarm{}
+	if err := json.Unmarshal([]byte(envelope.Message), &alarm); err != nil {
+		return nil, fmt.Errorf("invalid cloudwatch alarm: %w", err)
+	}
+	// insufficient data does not tell whether the alarm is breaching
+	if alarm.NewStateValue != "ALARM" && alarm.NewStateValue != "OK" {
+		return nil, nil
+	}
+
+	lbls := map[string]string{
+		labels.AlertNameLabel: alarm.AlarmName,
+		"aws_account_id":      alarm.AWSAccountId,
+		"region":              alarm.Region,
+		"namespace":           alarm.Trigger.Namespace,
+		"metric_name":         alarm.Trigger.MetricName,
+	}
+	for _, d := range alarm.Trigger.Dimensions {
+		lbls[normalizeLabelName(d.Name)] = d.Value
+	}
+
+	changedAt, err := time.Parse("2006-01-02T15:04:05.000-0700", alarm.StateChangeTime)
+	if err != nil {
+		changedAt = time.Now()
+	}
+	alert := ExternalAlert{
+		Labels: lbls,
+		Annotations: map[string]string{
+			labels.AlertSummaryLabel:     alarm.NewStateReason,
+			labels.AlertDescriptionLabel: alarm.AlarmDescription,
+		},
+		StartsAt: changedAt,
+		Resolved: alarm.NewStateValue == "OK",
+	}
+	if alert.Resolved {
+		alert.EndsAt = changedAt
+	}
+	return []ExternalAlert{alert}, nil
+}
+
+type azureCommonAlert struct {
+	SchemaID string `json:"schemaId"`
+	Data     struct {
+		Essentials struct {
+			AlertID           string   `json:"alertId"`
+			AlertRule         string   `json:"alertRule"`
+			Severity          string   `json:"severity"`
+			SignalType        string   `json:"signalType"`
+			MonitorCondition  string   `json:"monitorCondition"`
+			MonitoringService string   `json:"monitoringService"`
+			AlertTargetIDs    []string `json:"alertTargetIDs"`
+			FiredDateTime     string   `json:"firedDateTime"`
+			ResolvedDateTime  string   `json:"resolvedDateTime"`
+			Description       string   `json:"description"`
+		} `json:"essentials"`
+	} `json:"data"`
+}
+
+var azureSeverities = map[string]string{
+	"Sev0": "critical",
+	"Sev1": "critical",
+	"Sev2": "error",
+	"Sev3": "warning",
+	"Sev4": "info",
+}
+
+// parseAzureWebhook handles the Azure Monitor common alert schema
+func parseAzureWebhook(body []byte) ([]ExternalAlert, error) {
+	payload := azureCommonAlert{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid azure monitor payload: %w", err)
+	}
+	if payload.SchemaID != "azureMonitorCommonAlertSchema" {
+		return nil, fmt.Errorf("unsupported azure monitor schema %q, enable the common alert schema on the action group", payload.SchemaID)
+	}
+
+	e := payload.Data.Essentials
+	lbls := map[string]string{
+		labels.AlertNameLabel: e.AlertRule,
+		"severity":            azureSeverities[e.Severity],
+		"signal_type":         e.SignalType,
+		"monitoring_service":  e.MonitoringService,
+		"alert_id":            e.AlertID,
+	}
+	if len(e.AlertTargetIDs) > 0 {
+		lbls["target"] = strings.Join(e.AlertTargetIDs, ",")
+	}
+
+	alert := ExternalAlert{
+		Labels: lbls,
+		Annotations: map[string]string{
+			labels.AlertDescriptionLabel: e.Description,
+		},
+		Resolved: e.MonitorCondition == "Resolved",
+	}
+	if t, err := time.Parse(time.RFC3339, e.FiredDateTime); err == nil {
+		alert.StartsAt = t
+	}
+	if t, err := time.Parse(time.RFC3339, e.ResolvedDateTime); err == nil && alert.Resolved {
+		alert.EndsAt = t
+	}
+	return []ExternalAlert{alert}, nil
+}
+
+type gcpIncidentWebhook struct {
+	Incident struct {
+		IncidentID    string `json:"incident_id"`
+		URL           string `json:"url"`
+		State         string `json:"state"`
+		StartedAt     int64  `json:"started_at"`
+		EndedAt       int64  `json:"ended_at"`
+		Summary       string `json:"summary"`
+		PolicyName    string `json:"policy_name"`
+		ConditionName string `json:"condition_name"`
+		Severity      string `json:"severity"`
+		ProjectID     string `json:"scoping_project_id"`
+		Resource      struct {
+			Type   string            `json:"type"`
+			Labels map[string]string `json:"labels"`
+		} `json:"resource"`
+		PolicyUserLabels map[string]string `json:"policy_user_labels"`
+	} `json:"incident"`
+}
+
+// parseGCPWebhook handles Google Cloud Monitoring incidents
+func parseGCPWebhook(body []byte) ([]ExternalAlert, error) {
+	payload := gcpIncidentWebhook{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid cloud monitoring payload: %w", err)
+	}
+
+	inc := payload.Incident
+	lbls := copyStringMap(inc.PolicyUserLabels)
+	for k, v := range inc.Resource.Labels {
+		lbls[normalizeLabelName(k)] = v
+	}
+	lbls[labels.AlertNameLabel] = inc.PolicyName
+	lbls["condition"] = inc.ConditionName
+	lbls["project_id"] = inc.ProjectID
+	lbls["resource_type"] = inc.Resource.Type
+	if inc.Severity != "" && inc.Severity != "No severity" {
+		lbls["severity"] = strings.ToLower(inc.Severity)
+	}
+
+	alert := ExternalAlert{
+		Labels: lbls,
+		Annotations: map[string]string{
+			labels.AlertSummaryLabel: inc.Summary,
+		},
+		Resolved:     inc.State == "closed",
+		GeneratorURL: inc.URL,
+	}
+	if inc.StartedAt > 0 {
+		alert.StartsAt = time.Unix(inc.StartedAt, 0)
+	}
+	if inc.EndedAt > 0 && alert.Resolved {
+		alert.EndsAt = time.Unix(inc.EndedAt, 0)
+	}
+	return []ExternalAlert{alert}, nil
+}
+
+func copyStringMap(m map[string]string) map[string]string {
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,176 @@
+package rules
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseInboundAlerts(t *testing.T) {
+	cases := []struct {
+		name     string
+		source   InboundSource
+		body     string
+		expected []ExternalAlert
+		wantErr  bool
+	}{
+		{
+			name:   "alertmanager firing and resolved",
+			source: InboundSourceAlertmanager,
+			body: `{"version":"4","status":"firing","externalURL":"http://am:9093","alerts":[
+				{"status":"firing","labels":{"alertname":"HighCPU","instance":"a"},"annotations":{"summary":"cpu"},"startsAt":"2024-01-01T00:00:00Z","endsAt":"0001-01-01T00:00:00Z"},
+				{"status":"resolved","labels":{"alertname":"HighCPU","instance":"b"},"annotations":{},"startsAt":"2024-01-01T00:00:00Z","endsAt":"2024-01-01T00:10:00Z","generatorURL":"http://prom/graph"}]}`,
+			expected: []ExternalAlert{
+				{
+					Labels:       map[string]string{"alertname": "HighCPU", "instance": "a", ExternalSourceLabel: "alertmanager"},
+					Annotations:  map[string]string{"summary": "cpu"},
+					StartsAt:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+					GeneratorURL: "http://am:9093",
+				},
+				{
+					Labels:       map[string]string{"alertname": "HighCPU", "instance": "b", ExternalSourceLabel: "alertmanager"},
+					Annotations:  map[string]string{},
+					StartsAt:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+					EndsAt:       time.Date(2024, 1, 1, 0, 10, 0, 0, time.UTC),
+					Resolved:     true,
+					GeneratorURL: "http://prom/graph",
+				},
+			},
+		},
+		{
+			name:    "alert without name",
+			source:  InboundSourceAlertmanager,
+			body:    `{"alerts":[{"status":"firing","labels":{"instance":"a"}}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown source",
+			source:  InboundSource("nagios"),
+			body:    `{}`,
+			wantErr: true,
+		},
+		{
+			name:   "cloudwatch alarm",
+			source: InboundSourceCloudWatch,
+			body:   `{"Type":"Notification","TopicArn":"arn:aws:sns:us-east-1:123:alarms","Message":"{\"AlarmName\":\"HighLatency\",\"AWSAccountId\":\"123\",\"NewStateValue\":\"ALARM\",\"NewStateReason\":\"Threshold Crossed\",\"StateChangeTime\":\"2024-01-01T00:00:00.000+0000\",\"Region\":\"US East (N. Virginia)\",\"Trigger\":{\"MetricName\":\"Latency\",\"Namespace\":\"AWS/ELB\",\"Dimensions\":[{\"name\":\"LoadBalancerName\",\"value\":\"web\"}]}}"}`,
+			expected: []ExternalAlert{
+				{
+					Labels: map[string]string{
+						"alertname":         "HighLatency",
+						"aws_account_id":    "123",
+						"region":            "US East (N. Virginia)",
+						"namespace":         "AWS/ELB",
+						"metric_name":       "Latency",
+						"LoadBalancerName":  "web",
+						ExternalSourceLabel: "cloudwatch",
+					},
+					Annotations: map[string]string{"summary": "Threshold Crossed"},
+					StartsAt:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.FixedZone("", 0)),
+				},
+			},
+		},
+		{
+			name:   "azure monitor resolved",
+			source: InboundSourceAzure,
+			body:   `{"schemaId":"azureMonitorCommonAlertSchema","data":{"essentials":{"alertId":"id1","alertRule":"CPU","severity":"Sev3","signalType":"Metric","monitorCondition":"Resolved","monitoringService":"Platform","alertTargetIDs":["vm1"],"firedDateTime":"2024-01-01T00:00:00Z","resolvedDateTime":"2024-01-01T01:00:00Z","description":"cpu high"}}}`,
+			expected: []ExternalAlert{
+				{
+					Labels: map[string]string{
+						"alertname":          "CPU",
+						"severity":           "warning",
+						"signal_type":        "Metric",
+						"monitoring_service": "Platform",
+						"alert_id":           "id1",
+						"target":             "vm1",
+						ExternalSourceLabel:  "azure",
+					},
+					Annotations: map[string]string{"description": "cpu high"},
+					StartsAt:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+					EndsAt:      time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC),
+					Resolved:    true,
+				},
+			},
+		},
+		{
+			name:   "gcp incident",
+			source: InboundSourceGCP,
+			body:   `{"version":"1.2","incident":{"incident_id":"i1","url":"https://console.cloud.google.com/i1","state":"open","started_at":1704067200,"summary":"uptime check failed","policy_name":"Uptime","condition_name":"check","severity":"Critical","scoping_project_id":"p1","resource":{"type":"uptime_url","labels":{"host":"example.com"}}}}`,
+			expected: []ExternalAlert{
+				{
+					Labels: map[string]string{
+						"alertname":         "Uptime",
+						"condition":         "check",
+						"project_id":        "p1",
+						"resource_type":     "uptime_url",
+						"severity":          "critical",
+						"host":              "example.com",
+						ExternalSourceLabel: "gcp",
+					},
+					Annotations:  map[string]string{"summary": "uptime check failed"},
+					StartsAt:     time.Unix(1704067200, 0),
+					GeneratorURL: "https://console.cloud.google.com/i1",
+				},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			alerts, err := ParseInboundAlerts(c.source, []byte(c.body))
+			if c.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, alerts, len(c.expected))
+			for i := range alerts {
+				assert.Equal(t, c.expected[i].Labels, alerts[i].Labels)
+				assert.Equal(t, c.expected[i].Annotations, alerts[i].Annotations)
+				assert.True(t, c.expected[i].StartsAt.Equal(alerts[i].StartsAt), "startsAt %s != %s", c.expected[i].StartsAt, alerts[i].StartsAt)
+				assert.True(t, c.expected[i].EndsAt.Equal(alerts[i].EndsAt), "endsAt %s != %s", c.expected[i].EndsAt, alerts[i].EndsAt)
+				assert.Equal(t, c.expected[i].Resolved, alerts[i].Resolved)
+				assert.Equal(t, c.expected[i].GeneratorURL, alerts[i].GeneratorURL)
+			}
+		})
+	}
+}
+
+func TestParseInboundAlertsGrafanaLegacy(t *testing.T) {
+	body := `{"ruleName":"Disk","ruleUrl":"http://grafana/d/1","state":"alerting","title":"[Alerting] Disk","message":"disk full","tags":{"team":"infra"},
+		"evalMatches":[{"metric":"disk_used","value":95,"tags":{"host":"h1"}}]}`
+
+	alerts, err := ParseInboundAlerts(InboundSourceGrafana, []byte(body))
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, map[string]string{
+		"alertname":         "Disk",
+		"team":              "infra",
+		"host":              "h1",
+		"metric":            "disk_used",
+		ExternalSourceLabel: "grafana",
+	}, alerts[0].Labels)
+	assert.Equal(t, "95", alerts[0].Annotations["value"])
+	assert.False(t, alerts[0].Resolved)
+
+	// pending alerts are ignored
+	alerts, err = ParseInboundAlerts(InboundSourceGrafana, []byte(`{"ruleName":"Disk","state":"pending"}`))
+	require.NoError(t, err)
+	assert.Empty(t, alerts)
+}
+
+func TestParseInboundAlertsSNSConfirmation(t *testing.T) {
+	body := `{"Type":"SubscriptionConfirmation","TopicArn":"arn:aws:sns:us-east-1:123:alarms","SubscribeURL":"https://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription"}`
+
+	_, err := ParseInboundAlerts(InboundSourceCloudWatch, []byte(body))
+	var confirmation *SNSSubscriptionConfirmation
+	require.True(t, errors.As(err, &confirmation))
+	assert.Equal(t, "https://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription", confirmation.SubscribeURL)
+}
+
+func TestExternalRuleID(t *testing.T) {
+	assert.Equal(t, "external_grafana", externalRuleID("", InboundSourceGrafana))
+	assert.Equal(t, "external_org-a_grafana", externalRuleID("org-a", InboundSourceGrafana))
+}
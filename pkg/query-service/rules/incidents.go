@@ -0,0 +1,611 @@
+package rules
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/common"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+	"go.uber.org/zap"
+)
+
+const (
+	IncidentOpen         = "open"
+	IncidentAcknowledged = "acknowledged"
+	IncidentResolved     = "resolved"
+
+	incidentAlertFiring   = "firing"
+	incidentAlertResolved = "resolved"
+
+	// DefaultIncidentWindow is how long after the last alert of an
+	// incident the correlated alerts join it
+	DefaultIncidentWindow = 30 * time.Minute
+)
+
+// correlationIgnoredLabels are the labels every alert has, sharing them
+// does not correlate the alerts
+var correlationIgnoredLabels = map[string]bool{
+	labels.AlertNameLabel:        true,
+	labels.AlertRuleIdLabel:      true,
+	labels.RuleSourceLabel:       true,
+	labels.RuleThresholdLabel:    true,
+	labels.AlertSummaryLabel:     true,
+	labels.AlertDescriptionLabel: true,
+	"severity":                   true,
+	"priority":                   true,
+}
+
+// severityRanks orders the severities, the severity of an incident is the
+// highest of its alerts
+var severityRanks = map[string]int{
+	"info":     1,
+	"warning":  2,
+	"error":    3,
+	"critical": 4,
+}
+
+// IncidentOptions configures the incidents created from the firing alerts
+type IncidentOptions struct {
+	// AutoSeverities are the values of the severity or priority label of
+	// the alerts that open an incident, e.g. critical or P1. Alerts with
+	// other severities only join the open incidents they correlate with.
+	AutoSeverities []string
+	// Window is how long after the last alert of an incident the
+	// correlated alerts join it, DefaultIncidentWindow when not set
+	Window time.Duration
+}
+
+// Incident groups the correlated alerts of an outage
+type Incident struct {
+	Id       int64  `json:"id"`
+	Title    string `json:"title"`
+	Status   string `json:"status"`
+	Severity string `json:"severity"`
+	Assignee string `json:"assignee"`
+	// Labels are the labels shared by the alerts of the incident
+	Labels map[string]string `json:"labels"`
+	// Auto tells whether the incident was opened by a firing alert, it is
+	// resolved with its alerts then
+	Auto       bool       `json:"auto"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	CreatedBy  string     `json:"createdBy,omitempty"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+	// OrgId is the org of the rules of the alerts of the incident
+	OrgId  string          `json:"orgId"`
+	Alerts []IncidentAlert `json:"alerts"`
+}
+
+type IncidentAlert struct {
+	RuleID      string            `json:"ruleId"`
+	RuleName    string            `json:"ruleName"`
+	Fingerprint uint64            `json:"fingerprint"`
+	Labels      map[string]string `json:"labels"`
+	// State is firing or resolved
+	State      string     `json:"state"`
+	AddedAt    time.Time  `json:"addedAt"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+}
+
+// IncidentAlertRef identifies an active alert added to an incident
+type IncidentAlertRef struct {
+	RuleID      string `json:"ruleId"`
+	Fingerprint uint64 `json:"fingerprint"`
+}
+
+// PostableIncident is an incident opened by hand
+type PostableIncident struct {
+	Title    string             `json:"title"`
+	Severity string             `json:"severity"`
+	Assignee string             `json:"assignee"`
+	Alerts   []IncidentAlertRef `json:"alerts"`
+}
+
+func (p *PostableIncident) Validate() error {
+	if p.Title == "" {
+		return fmt.Errorf("title is required")
+	}
+	if _, ok := severityRanks[p.Severity]; !ok && p.Severity != "" {
+		return fmt.Errorf("unknown severity %q", p.Severity)
+	}
+	return nil
+}
+
+// IncidentUpdate changes the fields of an incident that are set
+type IncidentUpdate struct {
+	Title    *string `json:"title"`
+	Status   *string `json:"status"`
+	Severity *string `json:"severity"`
+	Assignee *string `json:"assignee"`
+}
+
+func (u *IncidentUpdate) Validate() error {
+	if u.Title != nil && *u.Title == "" {
+		return fmt.Errorf("title can not be empty")
+	}
+	if u.Status != nil {
+		switch *u.Status {
+		case IncidentOpen, IncidentAcknowledged, IncidentResolved:
+		default:
+			return fmt.Errorf("unknown status %q, expected %s, %s or %s", *u.Status, IncidentOpen, IncidentAcknowledged, IncidentResolved)
+		}
+	}
+	if u.Severity != nil {
+		if _, ok := severityRanks[*u.Severity]; !ok {
+			return fmt.Errorf("unknown severity %q", *u.Severity)
+		}
+	}
+	return nil
+}
+
+// apply changes the incident
+func (u *IncidentUpdate) apply(incident *Incident, now time.Time) {
+	if u.Title != nil {
+		incident.Title = *u.Title
+	}
+	if u.Severity != nil {
+		incident.Severity = *u.Severity
+	}
+	if u.Assignee != nil {
+		incident.Assignee = *u.Assignee
+	}
+	if u.Status != nil && *u.Status != incident.Status {
+		incident.Status = *u.Status
+		incident.ResolvedAt = nil
+		if incident.Status == IncidentResolved {
+			incident.ResolvedAt = &now
+		}
+	}
+	incident.UpdatedAt = now
+}
+
+// correlationLabels returns the labels of the alert an incident can share
+func correlationLabels(lbls map[string]string) map[string]string {
+	shared := map[string]string{}
+	for k, v := range lbls {
+		if !correlationIgnoredLabels[k] && !strings.HasPrefix(k, "__") {
+			shared[k] = v
+		}
+	}
+	return shared
+}
+
+// sharedLabels returns the labels of the incident the alert has too
+func sharedLabels(incident map[string]string, alert map[string]string) map[string]string {
+	shared := map[string]string{}
+	for k, v := range incident {
+		if alert[k] == v {
+			shared[k] = v
+		}
+	}
+	return shared
+}
+
+// correlatedIncident returns the incident the firing alert belongs to,
+// the one it is in or the last updated one it fired within the window of
+// and shares labels with, nil when there is none
+func correlatedIncident(incidents []Incident, e AlertEvent, window time.Duration) *Incident {
+	for i := range incidents {
+		for _, a := range incidents[i].Alerts {
+			if a.RuleID == e.RuleID && a.Fingerprint == e.Fingerprint {
+				return &incidents[i]
+			}
+		}
+	}
+	var found *Incident
+	for i := range incidents {
+		inc := &incidents[i]
+		if inc.Status == IncidentResolved || e.Timestamp.Sub(inc.UpdatedAt) > window {
+			continue
+		}
+		if len(sharedLabels(inc.Labels, e.Labels)) == 0 {
+			continue
+		}
+		if found == nil || inc.UpdatedAt.After(found.UpdatedAt) {
+			found = inc
+		}
+	}
+	return found
+}
+
+// opensIncident tells whether the alert opens an incident
+func opensIncident(e AlertEvent, severities []string) bool {
+	for _, s := range severities {
+		if strings.EqualFold(e.Labels["severity"], s) || strings.EqualFold(e.Labels["priority"], s) {
+			return true
+		}
+	}
+	return false
+}
+
+func higherSeverity(a, b string) string {
+	if severityRanks[b] > severityRanks[a] {
+		return b
+	}
+	return a
+}
+
+func newIncidentAlert(e AlertEvent) IncidentAlert {
+	return IncidentAlert{
+		RuleID:      e.RuleID,
+		RuleName:    e.RuleName,
+		Fingerprint: e.Fingerprint,
+		Labels:      e.Labels,
+		State:       incidentAlertFiring,
+		AddedAt:     e.Timestamp,
+	}
+}
+
+// incidentCorrelator groups the firing alerts published on the event bus
+// into incidents. The events are published by the replica evaluating the
+// rule, every replica correlates the alerts of its rules. The alerts only
+// join the incidents of the org of their rule.
+type incidentCorrelator struct {
+	db      RuleDB
+	opts    IncidentOptions
+	sub     *EventSubscription
+	tenants *ruleTenants
+
+	terminated chan struct{}
+}
+
+func newIncidentCorrelator(db RuleDB, opts IncidentOptions, bus *EventBus, tenants *ruleTenants) *incidentCorrelator {
+	if opts.Window <= 0 {
+		opts.Window = DefaultIncidentWindow
+	}
+	return &incidentCorrelator{
+		db:         db,
+		opts:       opts,
+		sub:        bus.Subscribe(0),
+		tenants:    tenants,
+		terminated: make(chan struct{}),
+	}
+}
+
+func (c *incidentCorrelator) Run(ctx context.Context) {
+	defer close(c.terminated)
+	for e := range c.sub.C {
+		if e.Type != EventFiring && e.Type != EventResolved {
+			continue
+		}
+		if err := c.handle(ctx, e); err != nil {
+			zap.L().Error("failed to correlate the alert into an incident", zap.String("ruleid", e.RuleID), zap.Error(err))
+		}
+	}
+}
+
+func (c *incidentCorrelator) Stop() {
+	c.sub.Close()
+	<-c.terminated
+}
+
+func (c *incidentCorrelator) handle(ctx context.Context, e AlertEvent) error {
+	all, err := c.db.GetIncidents(ctx, IncidentOpen, IncidentAcknowledged)
+	if err != nil {
+		return err
+	}
+	org := c.tenants.of(e.RuleID)
+	incidents := make([]Incident, 0, len(all))
+	for _, incident := range all {
+		if incident.OrgId == org {
+			incidents = append(incidents, incident)
+		}
+	}
+	if e.Type == EventResolved {
+		return c.resolve(ctx, incidents, e)
+	}
+
+	incident := correlatedIncident(incidents, e, c.opts.Window)
+	if incident == nil {
+		if !opensIncident(e, c.opts.AutoSeverities) {
+			return nil
+		}
+		severity := e.Labels["severity"]
+		if _, ok := severityRanks[severity]; !ok {
+			severity = "critical"
+		}
+		_, err := c.db.CreateIncident(ctx, &Incident{
+			Title:     e.RuleName,
+			Status:    IncidentOpen,
+			Severity:  severity,
+			Labels:    correlationLabels(e.Labels),
+			Auto:      true,
+			CreatedAt: e.Timestamp,
+			UpdatedAt: e.Timestamp,
+			OrgId:     org,
+			Alerts:    []IncidentAlert{newIncidentAlert(e)},
+		})
+		return err
+	}
+
+	if err := c.db.SetIncidentAlerts(ctx, incident.Id, []IncidentAlert{newIncidentAlert(e)}); err != nil {
+		return err
+	}
+	incident.Labels = sharedLabels(incident.Labels, e.Labels)
+	incident.Severity = higherSeverity(incident.Severity, e.Labels["severity"])
+	incident.UpdatedAt = e.Timestamp
+	return c.db.EditIncident(ctx, incident)
+}
+
+// resolve marks the alert resolved in its incidents, the incidents opened
+// by an alert are resolved with their last alert
+func (c *incidentCorrelator) resolve(ctx context.Context, incidents []Incident, e AlertEvent) error {
+	for i := range incidents {
+		incident := &incidents[i]
+		member, firing := false, 0
+		for j := range incident.Alerts {
+			a := &incident.Alerts[j]
+			if a.RuleID == e.RuleID && a.Fingerprint == e.Fingerprint && a.State == incidentAlertFiring {
+				member = true
+				a.State = incidentAlertResolved
+				a.ResolvedAt = &e.Timestamp
+				if err := c.db.SetIncidentAlerts(ctx, incident.Id, []IncidentAlert{*a}); err != nil {
+					return err
+				}
+			}
+			if a.State == incidentAlertFiring {
+				firing++
+			}
+		}
+		if !member {
+			continue
+		}
+		incident.UpdatedAt = e.Timestamp
+		if incident.Auto && firing == 0 {
+			incident.Status = IncidentResolved
+			incident.ResolvedAt = &e.Timestamp
+		}
+		if err := c.db.EditIncident(ctx, incident); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// activeIncidentAlert returns the active alert of the rule with the
+// fingerprint as an incident alert, the rule is of the org
+func (m *Manager) activeIncidentAlert(ref IncidentAlertRef, org string, now time.Time) (IncidentAlert, bool) {
+	m.mtx.RLock()
+	rule, ok := m.rules[ref.RuleID]
+	m.mtx.RUnlock()
+	if !ok || m.opts.tenants.of(ref.RuleID) != org {
+		return IncidentAlert{}, false
+	}
+	for _, a := range rule.ActiveAlerts() {
+		if a.Labels.Hash() == ref.Fingerprint {
+			alert := newIncidentAlert(newAlertEvent(EventFiring, rule, a, now))
+			if a.State == StateInactive {
+				alert.State = incidentAlertResolved
+				alert.ResolvedAt = &now
+			}
+			return alert, true
+		}
+	}
+	return IncidentAlert{}, false
+}
+
+func (m *Manager) incidentAlerts(refs []IncidentAlertRef, org string, now time.Time) ([]IncidentAlert, *model.ApiError) {
+	alerts := make([]IncidentAlert, 0, len(refs))
+	for _, ref := range refs {
+		alert, ok := m.activeIncidentAlert(ref, org, now)
+		if !ok {
+			return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("alert %d of rule %s is not active", ref.Fingerprint, ref.RuleID)}
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, nil
+}
+
+// CreateIncident opens an incident by hand with the given active alerts
+// of the org of the request
+func (m *Manager) CreateIncident(ctx context.Context, p *PostableIncident) (*Incident, *model.ApiError) {
+	now := time.Now()
+	org := tenantOf(ctx)
+	alerts, apiErr := m.incidentAlerts(p.Alerts, org, now)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	incident := &Incident{
+		Title:     p.Title,
+		Status:    IncidentOpen,
+		Severity:  p.Severity,
+		Assignee:  p.Assignee,
+		Labels:    map[string]string{},
+		CreatedAt: now,
+		UpdatedAt: now,
+		OrgId:     org,
+		Alerts:    alerts,
+	}
+	if user := common.GetUserFromContext(ctx); user != nil {
+		incident.CreatedBy = user.Email
+	}
+	for i, a := range alerts {
+		if i == 0 {
+			incident.Labels = correlationLabels(a.Labels)
+		} else {
+			incident.Labels = sharedLabels(incident.Labels, a.Labels)
+		}
+		if p.Severity == "" {
+			incident.Severity = higherSeverity(incident.Severity, a.Labels["severity"])
+		}
+	}
+	if incident.Severity == "" {
+		incident.Severity = "critical"
+	}
+
+	id, err := m.ruleDB.CreateIncident(ctx, incident)
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	incident.Id = id
+	return incident, nil
+}
+
+// Incidents returns the incidents of the org of the request with the
+// given statuses, latest first
+func (m *Manager) Incidents(ctx context.Context, statuses ...string) ([]Incident, error) {
+	incidents, err := m.ruleDB.GetIncidents(ctx, statuses...)
+	if err != nil {
+		return nil, err
+	}
+	org := tenantOf(ctx)
+	scoped := make([]Incident, 0, len(incidents))
+	for _, incident := range incidents {
+		if visibleTo(incident.OrgId, org) {
+			scoped = append(scoped, incident)
+		}
+	}
+	return scoped, nil
+}
+
+// GetIncident returns the incident with its alerts, the incidents of the
+// other orgs are not found
+func (m *Manager) GetIncident(ctx context.Context, id int64) (*Incident, *model.ApiError) {
+	incident, err := m.ruleDB.GetIncident(ctx, id)
+	if err == nil && !visibleTo(incident.OrgId, tenantOf(ctx)) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("incident %d not found", id)}
+		}
+		return nil, newApiErrorInternal(err)
+	}
+	return incident, nil
+}
+
+// UpdateIncident changes the status, severity, assignee or title of the
+// incident
+func (m *Manager) UpdateIncident(ctx context.Context, id int64, u *IncidentUpdate) (*Incident, *model.ApiError) {
+	incident, apiErr := m.GetIncident(ctx, id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	u.apply(incident, time.Now())
+	if err := m.ruleDB.EditIncident(ctx, incident); err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	return incident, nil
+}
+
+// AddIncidentAlerts adds the active alerts to the incident
+func (m *Manager) AddIncidentAlerts(ctx context.Context, id int64, refs []IncidentAlertRef) (*Incident, *model.ApiError) {
+	incident, apiErr := m.GetIncident(ctx, id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	now := time.Now()
+	alerts, apiErr := m.incidentAlerts(refs, incident.OrgId, now)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if err := m.ruleDB.SetIncidentAlerts(ctx, id, alerts); err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	incident.UpdatedAt = now
+	if err := m.ruleDB.EditIncident(ctx, incident); err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	return m.GetIncident(ctx, id)
+}
+
+// IncidentTimelineItem is a change of the incident or of one of its alerts
+type IncidentTimelineItem struct {
+	Time     int64             `json:"time"`
+	RuleID   string            `json:"ruleId,omitempty"`
+	RuleName string            `json:"ruleName,omitempty"`
+	State    string            `json:"state"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Text     string            `json:"text"`
+}
+
+// incidentTimeline merges the state changes of the alerts of the incident,
+// oldest first, with its own changes. The state history holds the labels
+// of the query result, an alert of the incident matches the changes of
+// its rule whose labels it has.
+func incidentTimeline(incident *Incident, changes []v3.RuleStateHistory) []IncidentTimelineItem {
+	timeline := []IncidentTimelineItem{{
+		Time:  incident.CreatedAt.UnixMilli(),
+		State: IncidentOpen,
+		Text:  "incident opened",
+	}}
+	for _, c := range changes {
+		lbls := map[string]string{}
+		if c.Labels != "" {
+			if err := json.Unmarshal([]byte(c.Labels), &lbls); err != nil {
+				continue
+			}
+		}
+		for _, a := range incident.Alerts {
+			if a.RuleID != c.RuleID || !containsLabels(a.Labels, lbls) {
+				continue
+			}
+			timeline = append(timeline, IncidentTimelineItem{
+				Time:     c.UnixMilli,
+				RuleID:   c.RuleID,
+				RuleName: c.RuleName,
+				State:    c.State,
+				Labels:   lbls,
+				Text:     fmt.Sprintf("%s is %s", c.RuleName, c.State),
+			})
+			break
+		}
+	}
+	if incident.ResolvedAt != nil {
+		timeline = append(timeline, IncidentTimelineItem{
+			Time:  incident.ResolvedAt.UnixMilli(),
+			State: IncidentResolved,
+			Text:  "incident resolved",
+		})
+	}
+	sort.SliceStable(timeline, func(i, j int) bool {
+		return timeline[i].Time < timeline[j].Time
+	})
+	return timeline
+}
+
+func containsLabels(lbls, subset map[string]string) bool {
+	for k, v := range subset {
+		if lbls[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// IncidentTimeline returns the state changes of the alerts of the
+// incident with its own changes, oldest first
+func (m *Manager) IncidentTimeline(ctx context.Context, id int64) ([]IncidentTimelineItem, *model.ApiError) {
+	incident, apiErr := m.GetIncident(ctx, id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if m.reader == nil {
+		return nil, &model.ApiError{Typ: model.ErrorUnavailable, Err: fmt.Errorf("the state history is not stored")}
+	}
+
+	start := incident.CreatedAt
+	for _, a := range incident.Alerts {
+		if a.AddedAt.Before(start) {
+			start = a.AddedAt
+		}
+	}
+	end := time.Now()
+	if incident.ResolvedAt != nil {
+		end = *incident.ResolvedAt
+	}
+	changes, err := m.reader.ReadRuleStateChanges(ctx, start.UnixMilli(), end.UnixMilli())
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	return incidentTimeline(incident, changes), nil
+}
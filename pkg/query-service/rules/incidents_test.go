@@ -0,0 +1,257 @@
+package rules
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/constants"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// incidentsDB keeps the incidents in memory
+type incidentsDB struct {
+	RuleDB
+	incidents []*Incident
+}
+
+func (db *incidentsDB) CreateIncident(_ context.Context, incident *Incident) (int64, error) {
+	stored := *incident
+	stored.Id = int64(len(db.incidents) + 1)
+	db.incidents = append(db.incidents, &stored)
+	return stored.Id, nil
+}
+
+func (db *incidentsDB) GetIncident(_ context.Context, id int64) (*Incident, error) {
+	if id < 1 || int(id) > len(db.incidents) {
+		return nil, sql.ErrNoRows
+	}
+	c := *db.incidents[id-1]
+	return &c, nil
+}
+
+func (db *incidentsDB) GetIncidents(_ context.Context, statuses ...string) ([]Incident, error) {
+	var incidents []Incident
+	for _, inc := range db.incidents {
+		for _, s := range statuses {
+			if inc.Status == s {
+				c := *inc
+				c.Alerts = append([]IncidentAlert{}, inc.Alerts...)
+				incidents = append(incidents, c)
+				break
+			}
+		}
+	}
+	return incidents, nil
+}
+
+func (db *incidentsDB) EditIncident(_ context.Context, incident *Incident) error {
+	alerts := db.incidents[incident.Id-1].Alerts
+	*db.incidents[incident.Id-1] = *incident
+	db.incidents[incident.Id-1].Alerts = alerts
+	return nil
+}
+
+func (db *incidentsDB) SetIncidentAlerts(_ context.Context, id int64, alerts []IncidentAlert) error {
+	inc := db.incidents[id-1]
+	for _, a := range alerts {
+		found := false
+		for i := range inc.Alerts {
+			if inc.Alerts[i].RuleID == a.RuleID && inc.Alerts[i].Fingerprint == a.Fingerprint {
+				inc.Alerts[i].State, inc.Alerts[i].ResolvedAt = a.State, a.ResolvedAt
+				found = true
+			}
+		}
+		if !found {
+			inc.Alerts = append(inc.Alerts, a)
+		}
+	}
+	return nil
+}
+
+func TestIncidentCorrelation(t *testing.T) {
+	db := &incidentsDB{}
+	c := &incidentCorrelator{db: db, opts: IncidentOptions{AutoSeverities: []string{"critical", "P1"}, Window: 30 * time.Minute}}
+	ctx := context.Background()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	event := func(typ EventType, rule string, fp uint64, at time.Duration, lbls map[string]string) AlertEvent {
+		return AlertEvent{Type: typ, RuleID: rule, RuleName: "rule " + rule, Fingerprint: fp, Labels: lbls, Timestamp: now.Add(at)}
+	}
+
+	// a warning does not open an incident
+	require.NoError(t, c.handle(ctx, event(EventFiring, "1", 1, 0, map[string]string{"alertname": "rule 1", "service": "api", "severity": "warning"})))
+	assert.Empty(t, db.incidents)
+
+	require.NoError(t, c.handle(ctx, event(EventFiring, "2", 2, 0, map[string]string{"alertname": "rule 2", "service": "api", "region": "eu", "severity": "critical"})))
+	require.Len(t, db.incidents, 1)
+	incident := db.incidents[0]
+	assert.Equal(t, "rule 2", incident.Title)
+	assert.Equal(t, "critical", incident.Severity)
+	assert.True(t, incident.Auto)
+	assert.Equal(t, map[string]string{"service": "api", "region": "eu"}, incident.Labels)
+
+	// a correlated alert joins, the shared labels narrow
+	require.NoError(t, c.handle(ctx, event(EventFiring, "3", 3, 10*time.Minute, map[string]string{"alertname": "rule 3", "service": "api", "region": "us", "severity": "warning"})))
+	require.Len(t, db.incidents, 1)
+	assert.Len(t, incident.Alerts, 2)
+	assert.Equal(t, map[string]string{"service": "api"}, incident.Labels)
+
+	// an unrelated alert and one past the window open their own incident
+	require.NoError(t, c.handle(ctx, event(EventFiring, "4", 4, 15*time.Minute, map[string]string{"service": "db", "priority": "p1"})))
+	require.NoError(t, c.handle(ctx, event(EventFiring, "5", 5, 2*time.Hour, map[string]string{"service": "api", "severity": "critical"})))
+	require.Len(t, db.incidents, 3)
+	assert.Equal(t, "critical", db.incidents[1].Severity)
+
+	// the incident opened by an alert resolves with its last alert
+	require.NoError(t, c.handle(ctx, event(EventResolved, "2", 2, 20*time.Minute, nil)))
+	assert.Equal(t, IncidentOpen, incident.Status)
+	require.NoError(t, c.handle(ctx, event(EventResolved, "3", 3, 25*time.Minute, nil)))
+	assert.Equal(t, IncidentResolved, incident.Status)
+	assert.Equal(t, now.Add(25*time.Minute), *incident.ResolvedAt)
+}
+
+func TestIncidentCorrelationScopedByOrg(t *testing.T) {
+	db := &incidentsDB{}
+	tenants := newRuleTenants()
+	tenants.set("1", "org-a")
+	tenants.set("2", "org-b")
+	c := &incidentCorrelator{db: db, opts: IncidentOptions{AutoSeverities: []string{"critical"}, Window: 30 * time.Minute}, tenants: tenants}
+	ctx := context.Background()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	lbls := map[string]string{"service": "api", "severity": "critical"}
+
+	// the alerts of the orgs share the labels but not the incidents
+	require.NoError(t, c.handle(ctx, AlertEvent{Type: EventFiring, RuleID: "1", Fingerprint: 1, Labels: lbls, Timestamp: now}))
+	require.NoError(t, c.handle(ctx, AlertEvent{Type: EventFiring, RuleID: "2", Fingerprint: 1, Labels: lbls, Timestamp: now}))
+	require.Len(t, db.incidents, 2)
+	assert.Equal(t, "org-a", db.incidents[0].OrgId)
+	assert.Equal(t, "org-b", db.incidents[1].OrgId)
+	assert.Len(t, db.incidents[0].Alerts, 1)
+
+	// the resolved alert of an org does not resolve the incident of the other
+	require.NoError(t, c.handle(ctx, AlertEvent{Type: EventResolved, RuleID: "2", Fingerprint: 1, Timestamp: now.Add(time.Minute)}))
+	assert.Equal(t, IncidentOpen, db.incidents[0].Status)
+	assert.Equal(t, IncidentResolved, db.incidents[1].Status)
+}
+
+// firingRule returns a rule with a firing alert with the labels
+func firingRule(t *testing.T, id string, lbls map[string]string) *ThresholdRule {
+	target := 10.0
+	rule, err := NewThresholdRule(id, &PostableRule{
+		AlertName: "rule " + id,
+		RuleCondition: &RuleCondition{
+			CompositeQuery: &v3.CompositeQuery{
+				QueryType:         v3.QueryTypeClickHouseSQL,
+				ClickHouseQueries: map[string]*v3.ClickHouseQuery{"A": {Query: "SELECT 1"}},
+			},
+			CompareOp: ValueIsAbove,
+			MatchType: AtleastOnce,
+			Target:    &target,
+		},
+	}, ThresholdRuleOpts{}, nil, nil)
+	require.NoError(t, err)
+	alertLabels := labels.FromMap(lbls)
+	rule.active.Set(alertLabels.Hash(), &Alert{State: StateFiring, Labels: alertLabels, ActiveAt: time.Now()})
+	return rule
+}
+
+func TestIncidentsScopedByOrg(t *testing.T) {
+	lbls := map[string]string{"service": "api", "severity": "critical"}
+	tenants := newRuleTenants()
+	tenants.set("1", "org-a")
+	tenants.set("2", "org-b")
+	db := &incidentsDB{incidents: []*Incident{
+		{Id: 1, Title: "a", Status: IncidentOpen, OrgId: "org-a"},
+		{Id: 2, Title: "b", Status: IncidentOpen, OrgId: "org-b"},
+		{Id: 3, Title: "legacy", Status: IncidentOpen},
+	}}
+	m := &Manager{
+		rules:  map[string]Rule{"1": firingRule(t, "1", lbls), "2": firingRule(t, "2", lbls)},
+		ruleDB: db,
+		opts:   &ManagerOptions{tenants: tenants},
+	}
+	orgA := context.WithValue(context.Background(), constants.ContextUserKey, &model.UserPayload{User: model.User{OrgId: "org-a"}})
+
+	incidents, err := m.Incidents(orgA, IncidentOpen)
+	require.NoError(t, err)
+	require.Len(t, incidents, 1)
+	assert.Equal(t, "a", incidents[0].Title)
+	// the internal requests see all the incidents
+	incidents, err = m.Incidents(context.Background(), IncidentOpen)
+	require.NoError(t, err)
+	assert.Len(t, incidents, 3)
+
+	// the incidents of the other orgs are not found
+	_, apiErr := m.GetIncident(orgA, 2)
+	require.NotNil(t, apiErr)
+	assert.Equal(t, model.ErrorNotFound, apiErr.Typ)
+	title := "renamed"
+	_, apiErr = m.UpdateIncident(orgA, 2, &IncidentUpdate{Title: &title})
+	require.NotNil(t, apiErr)
+	assert.Equal(t, model.ErrorNotFound, apiErr.Typ)
+	assert.Equal(t, "b", db.incidents[1].Title)
+	_, apiErr = m.IncidentTimeline(orgA, 3)
+	require.NotNil(t, apiErr)
+	assert.Equal(t, model.ErrorNotFound, apiErr.Typ)
+
+	// the incidents are opened with the alerts of the org only
+	fp := labels.FromMap(lbls).Hash()
+	_, apiErr = m.CreateIncident(orgA, &PostableIncident{Title: "outage", Alerts: []IncidentAlertRef{{RuleID: "2", Fingerprint: fp}}})
+	require.NotNil(t, apiErr)
+	assert.Equal(t, model.ErrorNotFound, apiErr.Typ)
+	incident, apiErr := m.CreateIncident(orgA, &PostableIncident{Title: "outage", Alerts: []IncidentAlertRef{{RuleID: "1", Fingerprint: fp}}})
+	require.Nil(t, apiErr)
+	assert.Equal(t, "org-a", incident.OrgId)
+	_, apiErr = m.AddIncidentAlerts(orgA, incident.Id, []IncidentAlertRef{{RuleID: "2", Fingerprint: fp}})
+	require.NotNil(t, apiErr)
+	assert.Equal(t, model.ErrorNotFound, apiErr.Typ)
+}
+
+func TestIncidentTimeline(t *testing.T) {
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	resolved := created.Add(time.Hour)
+	incident := &Incident{
+		CreatedAt:  created,
+		ResolvedAt: &resolved,
+		Alerts: []IncidentAlert{
+			{RuleID: "1", Labels: map[string]string{"alertname": "latency", "service": "api", "severity": "critical"}},
+		},
+	}
+	changes := []v3.RuleStateHistory{
+		{RuleID: "1", RuleName: "latency", State: "firing", UnixMilli: created.Add(-time.Minute).UnixMilli(), Labels: `{"service":"api"}`},
+		{RuleID: "1", RuleName: "latency", State: "firing", UnixMilli: created.Add(time.Minute).UnixMilli(), Labels: `{"service":"web"}`},
+		{RuleID: "2", RuleName: "errors", State: "firing", UnixMilli: created.Add(time.Minute).UnixMilli(), Labels: `{"service":"api"}`},
+		{RuleID: "1", RuleName: "latency", State: "normal", UnixMilli: created.Add(30 * time.Minute).UnixMilli(), Labels: `{"service":"api"}`},
+	}
+
+	timeline := incidentTimeline(incident, changes)
+	require.Len(t, timeline, 4)
+	assert.Equal(t, "latency is firing", timeline[0].Text)
+	assert.Equal(t, "incident opened", timeline[1].Text)
+	assert.Equal(t, "normal", timeline[2].State)
+	assert.Equal(t, "incident resolved", timeline[3].Text)
+}
+
+func TestIncidentUpdate(t *testing.T) {
+	status, severity := "resolved", "urgent"
+	u := IncidentUpdate{Status: &status, Severity: &severity}
+	assert.Error(t, u.Validate())
+
+	severity = "error"
+	require.NoError(t, u.Validate())
+	incident := &Incident{Status: IncidentOpen, Severity: "critical"}
+	now := time.Now()
+	u.apply(incident, now)
+	assert.Equal(t, IncidentResolved, incident.Status)
+	assert.Equal(t, "error", incident.Severity)
+	assert.Equal(t, now, *incident.ResolvedAt)
+
+	status = IncidentOpen
+	u.apply(incident, now)
+	assert.Nil(t, incident.ResolvedAt)
+}
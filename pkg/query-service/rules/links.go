@@ -0,0 +1,104 @@
+package rules
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+const (
+	RelatedChartAnnotation  = "related_chart"
+	RelatedLogsAnnotation   = "related_logs"
+	RelatedTracesAnnotation = "related_traces"
+
+	// deepLinkPadding is the time shown around the eval window in the
+	// chart link so that the transition into firing is visible
+	deepLinkPadding = 30 * time.Minute
+)
+
+// serviceLabels are the label names that identify the service of a series
+var serviceLabels = []string{"service.name", "service_name", "serviceName"}
+
+// addDeepLinks adds links to the pre-filtered views of the alert that are
+// not already present in the annotations: the rule chart around the time
+// the alert became active, the logs with the labels of the alert and the
+// traces of its service.
+func (r *ThresholdRule) addDeepLinks(annotations labels.Labels, ts time.Time, activeAt time.Time, lbls labels.Labels) labels.Labels {
+	host := r.hostFromSource()
+	if host == "" {
+		return annotations
+	}
+
+	if annotations.Get(RelatedChartAnnotation) == "" {
+		if link := r.prepareLinkToChart(activeAt); link != "" {
+			annotations = append(annotations, labels.Label{Name: RelatedChartAnnotation, Value: link})
+		}
+	}
+
+	if annotations.Get(RelatedLogsAnnotation) == "" && len(lbls) > 0 {
+		filters := labelFilters(lbls)
+		link := r.logsExplorerParams(ts, filters)
+		annotations = append(annotations, labels.Label{Name: RelatedLogsAnnotation, Value: fmt.Sprintf("%s/logs/logs-explorer?%s", host, link)})
+	}
+
+	if annotations.Get(RelatedTracesAnnotation) == "" {
+		if service := serviceName(lbls); service != "" {
+			filters := []v3.FilterItem{
+				{
+					Key:      v3.AttributeKey{Key: "serviceName", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag, IsColumn: true},
+					Operator: v3.FilterOperatorEqual,
+					Value:    service,
+				},
+			}
+			link := r.tracesExplorerParams(ts, filters)
+			annotations = append(annotations, labels.Label{Name: RelatedTracesAnnotation, Value: fmt.Sprintf("%s/traces-explorer?%s", host, link)})
+		}
+	}
+
+	return annotations
+}
+
+// prepareLinkToChart links to the rule page with the time range set to the
+// eval window at activeAt and some padding around it
+func (r *ThresholdRule) prepareLinkToChart(activeAt time.Time) string {
+	generatorURL, err := url.Parse(r.GeneratorURL())
+	if err != nil || generatorURL.Host == "" {
+		return ""
+	}
+	start := activeAt.Add(-r.evalWindow - deepLinkPadding)
+	end := activeAt.Add(deepLinkPadding)
+
+	query := generatorURL.Query()
+	query.Set("startTime", fmt.Sprintf("%d", start.UnixMilli()))
+	query.Set("endTime", fmt.Sprintf("%d", end.UnixMilli()))
+	generatorURL.RawQuery = query.Encode()
+	return generatorURL.String()
+}
+
+// labelFilters converts the labels of the alert to equality filters
+func labelFilters(lbls labels.Labels) []v3.FilterItem {
+	filters := make([]v3.FilterItem, 0, len(lbls))
+	for _, l := range lbls {
+		if l.Name == labels.MetricNameLabel || l.Name == labels.TemporalityLabel {
+			continue
+		}
+		filters = append(filters, v3.FilterItem{
+			Key:      v3.AttributeKey{Key: l.Name},
+			Operator: v3.FilterOperatorEqual,
+			Value:    l.Value,
+		})
+	}
+	return filters
+}
+
+func serviceName(lbls labels.Labels) string {
+	for _, name := range serviceLabels {
+		if v := lbls.Get(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,134 @@
+package rules
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	logsV3 "go.signoz.io/signoz/pkg/query-service/app/logs/v3"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+	"go.uber.org/zap"
+)
+
+const (
+	// LogSamplesAnnotation holds the sample log lines of the breach of an
+	// alert of a logs query, one per line
+	LogSamplesAnnotation = "sample_logs"
+
+	// maxLogSampleLength is the length of a sample log line after which
+	// it is truncated
+	maxLogSampleLength = 300
+)
+
+// logSamples returns the latest log lines matching the selected logs query
+// for the labels of the alert in the eval window at ts
+func (r *ThresholdRule) logSamples(ctx context.Context, lbls labels.Labels, ts time.Time) ([]string, error) {
+	selectedQuery := r.GetSelectedQuery()
+	q, ok := r.ruleCondition.CompositeQuery.BuilderQueries[selectedQuery]
+	if !ok || q.DataSource != v3.DataSourceLogs || isFormula(selectedQuery, q) {
+		return nil, nil
+	}
+
+	params := r.prepareQueryRange(ts)
+	filters := r.fetchFilters(selectedQuery, labels.NewBuilder(lbls).Del(QueryNameLabel).Labels())
+	mq := &v3.BuilderQuery{
+		QueryName:         selectedQuery,
+		DataSource:        v3.DataSourceLogs,
+		AggregateOperator: v3.AggregateOperatorNoOp,
+		StepInterval:      q.StepInterval,
+		Filters:           &v3.FilterSet{Operator: "AND", Items: typedFilters(q, filters)},
+		OrderBy:           []v3.OrderBy{{ColumnName: "timestamp", Order: "desc"}},
+		PageSize:          uint64(r.opts.LogSamples),
+	}
+	query, err := logsV3.PrepareLogsQuery(params.Start, params.End, v3.QueryTypeBuilder, v3.PanelTypeList, mq, logsV3.Options{})
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.reader.GetListResultV3(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([]string, 0, len(rows))
+	for _, row := range rows {
+		line := sanitizeLogLine(rowString(row.Data["body"]))
+		if line == "" {
+			continue
+		}
+		if severity := rowString(row.Data["severity_text"]); severity != "" {
+			line = severity + " " + line
+		}
+		if !row.Timestamp.IsZero() {
+			line = row.Timestamp.UTC().Format(time.RFC3339) + " " + line
+		}
+		samples = append(samples, line)
+	}
+	return samples, nil
+}
+
+// typedFilters sets the type of the label filters, which only have a
+// name, from the group by of the query. The labels of the alert are the
+// group by keys, or attributes when the key is not known.
+func typedFilters(q *v3.BuilderQuery, filters []v3.FilterItem) []v3.FilterItem {
+	typed := make([]v3.FilterItem, 0, len(filters))
+	for _, item := range filters {
+		if item.Key.Type == v3.AttributeKeyTypeUnspecified && item.Key.DataType == v3.AttributeKeyDataTypeUnspecified {
+			item.Key.Type = v3.AttributeKeyTypeTag
+			item.Key.DataType = v3.AttributeKeyDataTypeString
+			for _, key := range q.GroupBy {
+				if key.Key == item.Key.Key {
+					item.Key = key
+					break
+				}
+			}
+		}
+		typed = append(typed, item)
+	}
+	return typed
+}
+
+// sanitizeLogLine makes the log line safe to embed in the notifications,
+// the invalid utf-8 and the control characters are replaced and the line
+// is truncated
+func sanitizeLogLine(line string) string {
+	line = strings.ToValidUTF8(line, "�")
+	line = strings.Map(func(c rune) rune {
+		switch {
+		case c == '\n' || c == '\r' || c == '\t':
+			return ' '
+		case unicode.IsControl(c):
+			return -1
+		}
+		return c
+	}, line)
+	line = strings.TrimSpace(line)
+	if len(line) <= maxLogSampleLength {
+		return line
+	}
+	n := maxLogSampleLength - len("…")
+	for n > 0 && !utf8.RuneStart(line[n]) {
+		n--
+	}
+	return line[:n] + "…"
+}
+
+// attachLogSamples adds the sample log lines of the breach to the
+// annotations of the alert of a logs query when it starts firing
+func (r *ThresholdRule) attachLogSamples(ctx context.Context, a *Alert, ts time.Time) {
+	if r.opts.LogSamples <= 0 || r.reader == nil || a.Missing || r.ruleCondition.QueryType() != v3.QueryTypeBuilder {
+		return
+	}
+	samples, err := r.logSamples(ctx, labels.FromMap(a.QueryResultLables.Map()), ts)
+	if err != nil {
+		zap.L().Error("failed to read the sample logs of the alert", zap.String("ruleid", r.ID()), zap.Error(err))
+		return
+	}
+	if len(samples) == 0 {
+		return
+	}
+	a.LogSamples = strings.Join(samples, "\n")
+	a.Annotations = labels.NewBuilder(labels.FromMap(a.Annotations.Map())).Set(LogSamplesAnnotation, a.LogSamples).Labels()
+}
@@ -0,0 +1,93 @@
+package rules
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+func logRow(ts int64, severity, body string) *v3.Row {
+	return &v3.Row{Timestamp: time.Unix(ts, 0), Data: map[string]interface{}{
+		"severity_text": &severity,
+		"body":          &body,
+	}}
+}
+
+func TestThresholdRuleLogSamples(t *testing.T) {
+	rule, err := ParsePostableRule([]byte(`{
+		"alert": "payment errors",
+		"alertType": "LOGS_BASED_ALERT",
+		"ruleType": "threshold_rule",
+		"evalWindow": "5m",
+		"condition": {
+			"compositeQuery": {
+				"queryType": "builder",
+				"panelType": "graph",
+				"builderQueries": {
+					"A": {
+						"queryName": "A", "dataSource": "logs", "aggregateOperator": "count", "stepInterval": 60,
+						"filters": {"op": "AND", "items": [{"key": {"key": "severity_text", "type": "", "isColumn": true}, "op": "=", "value": "ERROR"}]},
+						"groupBy": [{"key": "service.name", "dataType": "string", "type": "resource"}]
+					}
+				}
+			},
+			"op": "1",
+			"target": 10,
+			"matchType": "1"
+		}
+	}`))
+	require.NoError(t, err)
+
+	reader := &exemplarReader{rows: [][]*v3.Row{{
+		logRow(1717205900, "ERROR", "payment failed:\n\tcard declined"),
+		logRow(1717205880, "ERROR", strings.Repeat("é", 400)),
+		logRow(1717205870, "ERROR", ""),
+	}}}
+	tr, err := NewThresholdRule("1", rule, ThresholdRuleOpts{LogSamples: 5}, nil, reader)
+	require.NoError(t, err)
+
+	a := &Alert{QueryResultLables: labels.FromMap(map[string]string{"service.name": "payments"}), Annotations: labels.Labels{}}
+	tr.attachLogSamples(context.Background(), a, time.Unix(1717205940, 0))
+
+	require.Len(t, reader.queries, 1)
+	assert.Contains(t, reader.queries[0], "resources_string_value[indexOf(resources_string_key, 'service.name')] = 'payments'")
+	assert.Contains(t, reader.queries[0], "LIMIT 5")
+
+	lines := strings.Split(a.LogSamples, "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, "2024-06-01T01:38:20Z ERROR payment failed:  card declined", lines[0])
+	assert.True(t, utf8.ValidString(lines[1]))
+	assert.True(t, strings.HasSuffix(lines[1], "…"))
+	assert.Equal(t, a.LogSamples, a.Annotations.Map()[LogSamplesAnnotation])
+
+	// the alerts that started firing are enriched without the rule lock
+	firedAt := time.Unix(1717205940, 0)
+	lbls := labels.FromMap(map[string]string{"service.name": "payments"})
+	tr.active.Set(1, &Alert{QueryResultLables: lbls, Annotations: labels.Labels{}, State: StateFiring, FiredAt: firedAt})
+	reader.rows = [][]*v3.Row{{logRow(1717205900, "ERROR", "timeout")}}
+	reader.onQuery = func() {
+		if !tr.mtx.TryLock() {
+			t.Error("the sample logs are read under the rule lock")
+			return
+		}
+		tr.mtx.Unlock()
+	}
+	tr.enrichFiring(context.Background(), []firingAlert{{fp: 1, firedAt: firedAt, alert: &Alert{QueryResultLables: lbls, Annotations: labels.Labels{}}}}, firedAt)
+	a, ok := tr.active.Get(1)
+	require.True(t, ok)
+	assert.Equal(t, "2024-06-01T01:38:20Z ERROR timeout", a.LogSamples)
+	assert.Equal(t, a.LogSamples, a.Annotations.Map()[LogSamplesAnnotation])
+}
+
+func TestSanitizeLogLine(t *testing.T) {
+	assert.Equal(t, "a b", sanitizeLogLine("a\x00\x1b\nb"))
+	assert.Equal(t, "bad �", sanitizeLogLine("bad \xff"))
+	assert.LessOrEqual(t, len(sanitizeLogLine(strings.Repeat("x", 1000))), maxLogSampleLength)
+}
@@ -29,19 +29,29 @@ type PlannedMaintenance struct {
 	CreatedBy   string    `json:"createdBy" db:"created_by"`
 	UpdatedAt   time.Time `json:"updatedAt" db:"updated_at"`
 	UpdatedBy   string    `json:"updatedBy" db:"updated_by"`
+	OrgId       string    `json:"orgId" db:"org_id"`
 	Status      string    `json:"status"`
 	Kind        string    `json:"kind"`
 }
 
 type AlertIds []string
 
-func (a *AlertIds) Scan(src interface{}) error {
-	if data, ok := src.([]byte); ok {
-		return json.Unmarshal(data, a)
+// scanJSON decodes a json column, sqlite returns it as bytes and postgres
+// as a string
+func scanJSON(src interface{}, v interface{}) error {
+	switch data := src.(type) {
+	case []byte:
+		return json.Unmarshal(data, v)
+	case string:
+		return json.Unmarshal([]byte(data), v)
 	}
 	return nil
 }
 
+func (a *AlertIds) Scan(src interface{}) error {
+	return scanJSON(src, a)
+}
+
 func (a *AlertIds) Value() (driver.Value, error) {
 	return json.Marshal(a)
 }
@@ -54,10 +64,7 @@ type Schedule struct {
 }
 
 func (s *Schedule) Scan(src interface{}) error {
-	if data, ok := src.([]byte); ok {
-		return json.Unmarshal(data, s)
-	}
-	return nil
+	return scanJSON(src, s)
 }
 
 func (s *Schedule) Value() (driver.Value, error) {
@@ -93,10 +100,7 @@ type Recurrence struct {
 }
 
 func (r *Recurrence) Scan(src interface{}) error {
-	if data, ok := src.([]byte); ok {
-		return json.Unmarshal(data, r)
-	}
-	return nil
+	return scanJSON(src, r)
 }
 
 func (r *Recurrence) Value() (driver.Value, error) {
@@ -228,3 +228,24 @@ func TestShouldSkipMaintenance(t *testing.T) {
 		}
 	}
 }
+
+func TestMaintenanceScanJSON(t *testing.T) {
+	// sqlite returns the json columns as bytes and postgres as strings
+	for _, src := range []interface{}{[]byte(`["1","2"]`), `["1","2"]`} {
+		ids := AlertIds{}
+		if err := ids.Scan(src); err != nil {
+			t.Fatal(err)
+		}
+		if len(ids) != 2 || ids[1] != "2" {
+			t.Errorf("unexpected alert ids %v scanned from %T", ids, src)
+		}
+	}
+
+	schedule := Schedule{}
+	if err := schedule.Scan(`{"timezone":"UTC"}`); err != nil {
+		t.Fatal(err)
+	}
+	if schedule.Timezone != "UTC" {
+		t.Errorf("unexpected timezone %q", schedule.Timezone)
+	}
+}
@@ -2,8 +2,10 @@ package rules
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"sort"
 	"strconv"
 	"strings"
@@ -12,15 +14,18 @@ import (
 
 	"github.com/google/uuid"
 
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 
 	"errors"
 
 	"github.com/jmoiron/sqlx"
 
+	"go.signoz.io/signoz/pkg/query-service/auth"
 	am "go.signoz.io/signoz/pkg/query-service/integrations/alertManager"
 	"go.signoz.io/signoz/pkg/query-service/interfaces"
 	"go.signoz.io/signoz/pkg/query-service/model"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
 	"go.signoz.io/signoz/pkg/query-service/telemetry"
 	"go.signoz.io/signoz/pkg/query-service/utils/labels"
 )
@@ -73,6 +78,152 @@ type ManagerOptions struct {
 
 	EvalDelay time.Duration
 
+	// Snapshots stores the chart snapshots rendered for firing alerts
+	Snapshots *SnapshotStore
+
+	// Snippets holds the stored template snippets
+	Snippets *SnippetCache
+	// Formats holds the format settings of the orgs
+	Formats *FormatCache
+
+	// Calendars holds the event calendars of the orgs the rules follow
+	Calendars *CalendarCache
+	// Services is the service catalog the alerts inherit their team,
+	// channels and runbook from
+	Services *ServiceCatalog
+
+	// EvalWorkers is the number of rules evaluated concurrently
+	EvalWorkers int
+	// EvalTenantWorkers is the number of rules of an org evaluated
+	// concurrently, the workers are shared fairly between the orgs
+	EvalTenantWorkers int
+	// EvalTimeout is the maximum duration of a rule evaluation, the
+	// frequency of the rule is used when it is not set or longer
+	EvalTimeout time.Duration
+	// EvalPool runs the rule evaluations of all tasks
+	EvalPool *EvalPool
+	// StateHistory writes the state history of all rules in batches
+	StateHistory *StateHistoryWriter
+	// StateHistoryRetention is how long the state history is kept when
+	// it was not set through the api
+	StateHistoryRetention StateHistoryRetention
+	// Events publishes the state transitions of the alerts to the
+	// subscribers and sinks, independently of the notifications
+	Events *EventBus
+	// MaxActiveAlerts is the number of active alerts a rule holds in
+	// memory, DefaultMaxActiveAlerts when not set
+	MaxActiveAlerts int
+	// AlertSpill keeps the active alerts over the limit, they are
+	// dropped when nil
+	AlertSpill AlertSpill
+	// MaxSeries fails the evaluations of the threshold rules whose
+	// selected query returns more series, streamed evaluations are
+	// aborted as soon as it is reached. Zero is unlimited.
+	MaxSeries int
+	// ClickHouseSandbox restricts the raw clickhouse queries of the
+	// threshold rules when set
+	ClickHouseSandbox *ClickHouseSandbox
+	// TraceExemplars is the number of example traces attached to the
+	// alerts of the traces rules, zero disables them
+	TraceExemplars int
+	// LogSamples is the number of log lines attached to the alerts of the
+	// logs queries, zero disables them
+	LogSamples int
+	// Downsampling lets the threshold rules read the aggregated samples
+	// tables picked by their window and resolution, it requires the
+	// tables to exist
+	Downsampling bool
+	// BreakerThreshold is the number of consecutive failed evaluations
+	// after which a rule is backed off
+	BreakerThreshold int
+	// BreakerMaxBackoff caps the backoff of a failing rule
+	BreakerMaxBackoff time.Duration
+	// EvalCatchUpLookback is how far back the missed evaluations of a
+	// rule are re-evaluated, zero disables the catch up
+	EvalCatchUpLookback time.Duration
+	// EvalLogSize is the number of evaluations, with their query and a
+	// sample of the result, kept for every rule, zero disables the log
+	EvalLogSize int
+	// QueryCostWarnRows and QueryCostMaxRows are the budgets of the rows
+	// scanned by one evaluation of a rule, rules over the max are not
+	// saved, zero disables the check
+	QueryCostWarnRows uint64
+	QueryCostMaxRows  uint64
+	// WarmUp is how long after the start the rules are evaluated without
+	// sending their alerts, zero disables the warm up
+	WarmUp     time.Duration
+	WarmUpMode WarmUpMode
+
+	// AlertDigest sends the weekly alert quality digest of every team
+	// when set
+	AlertDigest *AlertDigestOptions
+	// StaleRules detects the rules whose query stopped returning series
+	StaleRules StaleRuleOptions
+	// Incidents groups the correlated firing alerts into incidents
+	Incidents IncidentOptions
+	// LegacyOrg is the org the rules, maintenances and the other objects
+	// stored before they were scoped by org are assigned to on start, the
+	// only org of the install. They are only seen by the internal requests
+	// when it is not set.
+	LegacyOrg string
+	// Correlation suggests the alerts and changes related to a firing
+	// alert
+	Correlation CorrelationOptions
+	// Dedup merges the notifications of the alerts of overlapping rules
+	Dedup DedupOptions
+	// ExternalAlertmanagers forwards the alerts of the rules to the
+	// Alertmanager clusters of the org when set
+	ExternalAlertmanagers *am.ExternalAlertmanagers
+	// RemoteWrite writes the results of the recording rules to an
+	// external TSDB with the Prometheus remote write protocol when set
+	RemoteWrite *RemoteWriter
+
+	// TeamQuotas limits the rules, active alerts and notifications of
+	// the teams when set
+	TeamQuotas *TeamQuotas
+	// OwnerTeamLabel is the label naming the team owning a rule, only
+	// the members of the team can change the rule. Empty disables the
+	// team ownership of the rules.
+	OwnerTeamLabel string
+	// ChaosChannels are the sandbox channels the synthetic alert storms
+	// can be sent to, empty disables the storms
+	ChaosChannels []string
+
+	// Sharding splits the rules across the replicas sharing the rule
+	// db when set, every replica evaluates all rules otherwise
+	Sharding *ShardOptions
+	sharder  *Sharder
+
+	metrics *ruleMetrics
+	costs   *queryCostTracker
+	// costReport keeps the hourly query costs of the rules for the cost
+	// report
+	costReport *costRecorder
+	profiler   *evalProfiler
+	warmUp     *warmUp
+	// scheduler pauses the scheduled evaluations of the tasks
+	scheduler *schedulerPause
+	chaos     *chaosRunner
+
+	historyRetention *historyRetention
+	notificationLog  *notificationLog
+	// notificationFailures are the rules whose alerts the alert managers
+	// do not accept
+	notificationFailures *notificationFailures
+	dedup                *alertDedup
+	alertDigest          *alertDigest
+	seriesActivity       *seriesActivity
+	staleRules           *staleRuleChecker
+	tuning               *tuningAdvisor
+	incidents            *incidentCorrelator
+	changes              *changeCache
+	// deploys are the recent changes the deploy policies of the rules
+	// are applied with
+	deploys *changeCache
+	// runbooks checks the external runbooks of the rules
+	runbooks *runbookChecker
+	tenants  *ruleTenants
+
 	PrepareTaskFunc func(opts PrepareTaskOptions) (Task, error)
 }
 
@@ -81,10 +232,25 @@ type Manager struct {
 	opts  *ManagerOptions
 	tasks map[string]Task
 	rules map[string]Rule
-	mtx   sync.RWMutex
-	block chan struct{}
+	// digests identifies the definition run by every task
+	digests map[string]string
+	mtx     sync.RWMutex
+	block   chan struct{}
 	// Notifier sends messages through alert manager
 	notifier *am.Notifier
+	// pushDispatcher delivers the push notification channels
+	pushDispatcher *am.PushDispatcher
+	// chatDispatcher delivers the irc and xmpp channels
+	chatDispatcher *am.ChatDispatcher
+
+	// externalStates holds the last state of the alerts received from
+	// other systems by org and fingerprint, used to record state changes
+	externalStates   map[externalStateKey]string
+	externalStatesMu sync.Mutex
+
+	// closedPages holds the firing alerts whose page was resolved in the
+	// on-call tool
+	closedPages closedPages
 
 	// datastore to store alert definitions
 	ruleDB RuleDB
@@ -107,15 +273,74 @@ func defaultOptions(o *ManagerOptions) *ManagerOptions {
 	if o.ResendDelay == time.Duration(0) {
 		o.ResendDelay = 1 * time.Minute
 	}
+	if o.Dedup.Window > 0 && o.Dedup.Window < 2*o.ResendDelay {
+		o.Dedup.Window = 2 * o.ResendDelay
+	}
 	if o.Logger == nil {
 		o.Logger = zap.L()
 	}
 	if o.PrepareTaskFunc == nil {
 		o.PrepareTaskFunc = defaultPrepareTaskFunc
 	}
+	if o.Snapshots == nil {
+		o.Snapshots = NewSnapshotStore()
+	}
+	if o.Snippets == nil {
+		o.Snippets = NewSnippetCache()
+	}
+	if o.EvalPool == nil {
+		o.EvalPool = NewEvalPool(o.EvalWorkers)
+	}
+	o.tenants = newRuleTenants()
+	o.dedup = newAlertDedup(o.Dedup, o.tenants)
+	if o.Formats == nil {
+		o.Formats = NewFormatCache()
+	}
+	o.Formats.orgOf = o.tenants.of
+	if o.Calendars == nil {
+		o.Calendars = NewCalendarCache()
+	}
+	o.Calendars.orgOf = o.tenants.of
+	if o.Services == nil {
+		o.Services = NewServiceCatalog()
+	}
+	o.Services.orgOf = o.tenants.of
+	o.runbooks = newRunbookChecker()
+	if o.OwnerTeamLabel != "" {
+		o.Services.teamLabel = o.OwnerTeamLabel
+	}
+	o.scheduler = &schedulerPause{}
+	o.EvalPool.SetTenants(o.tenants.of, o.EvalTenantWorkers)
+	if o.MaxActiveAlerts <= 0 {
+		o.MaxActiveAlerts = DefaultMaxActiveAlerts
+	}
+	if o.Events == nil {
+		o.Events = NewEventBus()
+	}
+	if o.Correlation.Window <= 0 {
+		o.Correlation.Window = DefaultCorrelationWindow
+	}
+	if o.Correlation.Lookback <= 0 {
+		o.Correlation.Lookback = DefaultCorrelationLookback
+	}
+	if o.StaleRules.After <= 0 {
+		o.StaleRules.After = DefaultStaleRuleAfter
+	}
+	if o.StateHistory == nil && o.Reader != nil {
+		o.StateHistory = NewStateHistoryWriter(o.Reader, 0, 0, 0)
+	}
 	return o
 }
 
+// evalTimeout returns the timeout of an evaluation of a rule with the
+// frequency, evaluations never run into the next one
+func (o *ManagerOptions) evalTimeout(frequency time.Duration) time.Duration {
+	if o.EvalTimeout > 0 && (frequency <= 0 || o.EvalTimeout < frequency) {
+		return o.EvalTimeout
+	}
+	return frequency
+}
+
 func defaultPrepareTaskFunc(opts PrepareTaskOptions) (Task, error) {
 
 	rules := make([]Rule, 0)
@@ -128,7 +353,20 @@ func defaultPrepareTaskFunc(opts PrepareTaskOptions) (Task, error) {
 			ruleId,
 			opts.Rule,
 			ThresholdRuleOpts{
-				EvalDelay: opts.ManagerOpts.EvalDelay,
+				EvalDelay:       opts.ManagerOpts.EvalDelay,
+				Snapshots:       opts.ManagerOpts.Snapshots,
+				Snippets:        opts.ManagerOpts.Snippets,
+				Formats:         opts.ManagerOpts.Formats,
+				Calendars:       opts.ManagerOpts.Calendars,
+				Services:        opts.ManagerOpts.Services,
+				History:         opts.ManagerOpts.StateHistory,
+				MaxActiveAlerts: opts.ManagerOpts.MaxActiveAlerts,
+				AlertSpill:      opts.ManagerOpts.AlertSpill,
+				MaxSeries:       opts.ManagerOpts.MaxSeries,
+				Downsampling:    opts.ManagerOpts.Downsampling,
+				Sandbox:         opts.ManagerOpts.ClickHouseSandbox,
+				TraceExemplars:  opts.ManagerOpts.TraceExemplars,
+				LogSamples:      opts.ManagerOpts.LogSamples,
 			},
 			opts.FF,
 			opts.Reader,
@@ -150,7 +388,15 @@ func defaultPrepareTaskFunc(opts PrepareTaskOptions) (Task, error) {
 			ruleId,
 			opts.Rule,
 			opts.Logger,
-			PromRuleOpts{},
+			PromRuleOpts{
+				Snippets:        opts.ManagerOpts.Snippets,
+				Formats:         opts.ManagerOpts.Formats,
+				Calendars:       opts.ManagerOpts.Calendars,
+				Services:        opts.ManagerOpts.Services,
+				History:         opts.ManagerOpts.StateHistory,
+				MaxActiveAlerts: opts.ManagerOpts.MaxActiveAlerts,
+				AlertSpill:      opts.ManagerOpts.AlertSpill,
+			},
 			opts.Reader,
 		)
 
@@ -175,6 +421,63 @@ func defaultPrepareTaskFunc(opts PrepareTaskOptions) (Task, error) {
 func NewManager(o *ManagerOptions) (*Manager, error) {
 
 	o = defaultOptions(o)
+	o.metrics = newRuleMetrics()
+	o.costs = newQueryCostTracker()
+	o.chaos = &chaosRunner{}
+	o.profiler = newEvalProfiler()
+	o.warmUp = newWarmUp(o.WarmUp, o.WarmUpMode)
+	if o.StateHistory != nil {
+		o.metrics.registerStateHistory(o.StateHistory)
+	}
+	db := NewRuleDB(o.DBConn)
+
+	if auth.JwtSecret != "" {
+		o.Snapshots.key = snapshotKey(auth.JwtSecret)
+	}
+	if o.DBConn != nil {
+		o.Snapshots.db = db
+		o.seriesActivity = newSeriesActivity(db)
+		o.incidents = newIncidentCorrelator(db, o.Incidents, o.Events, o.tenants)
+		o.changes = newChangeCache(db, o.Correlation.Lookback)
+		o.deploys = newChangeCache(db, MaxDeployWindow)
+		o.costReport = newCostRecorder(db)
+		o.notificationLog = newNotificationLog(db, func() time.Duration {
+			if o.historyRetention == nil {
+				return DefaultHistoryDetailRetention
+			}
+			return time.Duration(o.historyRetention.get().Detail)
+		})
+	}
+	dropped := o.NotifierOpts.Dropped
+	o.NotifierOpts.Dropped = func(alerts []*am.Alert) {
+		o.metrics.notificationsDropped(alerts)
+		o.chaos.observe(alerts, NotificationDropped)
+		if o.notificationLog != nil {
+			o.notificationLog.add(alerts, NotificationDropped)
+		}
+		if dropped != nil {
+			dropped(alerts)
+		}
+	}
+	o.notificationFailures = newNotificationFailures()
+	failed := o.NotifierOpts.Failed
+	o.NotifierOpts.Failed = func(alerts []*am.Alert, err error) {
+		o.notificationFailures.failed(alerts, err, time.Now())
+		if failed != nil {
+			failed(alerts, err)
+		}
+	}
+	delivered := o.NotifierOpts.Delivered
+	o.NotifierOpts.Delivered = func(alerts []*am.Alert) {
+		o.notificationFailures.delivered(alerts)
+		o.chaos.observe(alerts, NotificationDelivered)
+		if o.notificationLog != nil {
+			o.notificationLog.add(alerts, NotificationDelivered)
+		}
+		if delivered != nil {
+			delivered(alerts)
+		}
+	}
 	// here we just initiate notifier, it will be started
 	// in run()
 	notifier, err := am.NewNotifier(&o.NotifierOpts, nil)
@@ -183,15 +486,30 @@ func NewManager(o *ManagerOptions) (*Manager, error) {
 		// should not be down because alert manager is not available
 		return nil, err
 	}
+	o.metrics.registry.MustRegister(&notifierCollector{notifier: notifier})
 
-	db := NewRuleDB(o.DBConn)
+	if o.Sharding != nil {
+		o.sharder = NewSharder(*o.Sharding, db)
+	}
+	if o.Reader != nil {
+		o.historyRetention = newHistoryRetention(o.Reader, db, o.sharder, o.StateHistoryRetention)
+	}
 
 	telemetry.GetInstance().SetAlertsInfoCallback(db.GetAlertsInfo)
 
+	pushSenders := map[string]am.PushSender{}
+	if o.Reader != nil {
+		pushSenders = am.NewPushSendersFromEnv()
+	}
+
 	m := &Manager{
 		tasks:           map[string]Task{},
 		rules:           map[string]Rule{},
 		notifier:        notifier,
+		pushDispatcher:  am.NewPushDispatcher(o.Reader, pushSenders),
+		chatDispatcher:  am.NewChatDispatcher(o.Reader),
+		digests:         map[string]string{},
+		externalStates:  map[externalStateKey]string{},
 		ruleDB:          db,
 		opts:            o,
 		block:           make(chan struct{}),
@@ -200,6 +518,25 @@ func NewManager(o *ManagerOptions) (*Manager, error) {
 		reader:          o.Reader,
 		prepareTaskFunc: o.PrepareTaskFunc,
 	}
+	if o.AlertDigest != nil && o.Reader != nil {
+		digestOpts := *o.AlertDigest
+		o.alertDigest = newAlertDigest(digestOpts, o.sharder, func(ctx context.Context) error {
+			q := &v3.QueryAlertDigest{TeamLabel: digestOpts.TeamLabel}
+			if err := q.Validate(); err != nil {
+				return err
+			}
+			if _, apiErr := m.SendAlertDigest(ctx, digestOpts.Channel, q); apiErr != nil {
+				return apiErr.Err
+			}
+			return nil
+		})
+	}
+	if o.StaleRules.AutoDisable && o.seriesActivity != nil {
+		o.staleRules = newStaleRuleChecker(o.sharder, m.disableStaleRules)
+	}
+	o.tuning = newTuningAdvisor(o.sharder)
+	o.tuning.analyze = m.AnalyzeTuning
+	o.metrics.registry.MustRegister(&ruleStateCollector{manager: m}, &alertsCollector{manager: m})
 	return m, nil
 }
 
@@ -210,6 +547,11 @@ func (m *Manager) Start() {
 	m.run()
 }
 
+// MetricsHandler serves the self metrics of the rules
+func (m *Manager) MetricsHandler() http.Handler {
+	return m.opts.metrics.handler()
+}
+
 func (m *Manager) RuleDB() RuleDB {
 	return m.ruleDB
 }
@@ -223,6 +565,32 @@ func (m *Manager) Pause(b bool) {
 }
 
 func (m *Manager) initiate() error {
+	if m.opts.LegacyOrg != "" {
+		if n, err := m.ruleDB.AssignLegacyOrg(context.Background(), m.opts.LegacyOrg); err != nil {
+			zap.L().Error("failed to assign the objects without an org", zap.String("org", m.opts.LegacyOrg), zap.Error(err))
+		} else if n > 0 {
+			zap.L().Info("assigned the objects stored without an org", zap.String("org", m.opts.LegacyOrg), zap.Int64("count", n))
+		}
+	}
+	if err := m.ReloadTemplateSnippets(context.Background()); err != nil {
+		zap.L().Error("failed to load template snippets", zap.Error(err))
+	}
+	if err := m.ReloadCustomUnits(context.Background()); err != nil {
+		zap.L().Error("failed to load custom units", zap.Error(err))
+	}
+	if err := m.ReloadFormatSettings(context.Background()); err != nil {
+		zap.L().Error("failed to load format settings", zap.Error(err))
+	}
+	if err := m.ReloadCalendars(context.Background()); err != nil {
+		zap.L().Error("failed to load calendars", zap.Error(err))
+	}
+	if err := m.ReloadServiceCatalog(context.Background()); err != nil {
+		zap.L().Error("failed to load the service catalog", zap.Error(err))
+	}
+	if m.opts.seriesActivity != nil {
+		m.opts.seriesActivity.load(context.Background())
+	}
+
 	storedRules, err := m.ruleDB.GetStoredRules(context.Background())
 	if err != nil {
 		return err
@@ -230,31 +598,17 @@ func (m *Manager) initiate() error {
 	if len(storedRules) == 0 {
 		return nil
 	}
+	m.setTenants(storedRules)
 	var loadErrors []error
 
 	for _, rec := range storedRules {
 		taskName := fmt.Sprintf("%d-groupname", rec.Id)
-		parsedRule, err := ParsePostableRule([]byte(rec.Data))
-
+		parsedRule, err := parseStoredRule(rec.Data)
 		if err != nil {
-			if errors.Is(err, ErrFailedToParseJSON) {
-				zap.L().Info("failed to load rule in json format, trying yaml now:", zap.String("name", taskName))
-
-				// see if rule is stored in yaml format
-				parsedRule, err = parsePostableRule([]byte(rec.Data), RuleDataKindYaml)
-
-				if err != nil {
-					zap.L().Error("failed to parse and initialize yaml rule", zap.String("name", taskName), zap.Error(err))
-					// just one rule is being parsed so expect just one error
-					loadErrors = append(loadErrors, err)
-					continue
-				}
-			} else {
-				zap.L().Error("failed to parse and initialize rule", zap.String("name", taskName), zap.Error(err))
-				// just one rule is being parsed so expect just one error
-				loadErrors = append(loadErrors, err)
-				continue
-			}
+			zap.L().Error("failed to parse and initialize rule", zap.String("name", taskName), zap.Error(err))
+			// just one rule is being parsed so expect just one error
+			loadErrors = append(loadErrors, err)
+			continue
 		}
 		if !parsedRule.Disabled {
 			err := m.addTask(parsedRule, taskName)
@@ -275,6 +629,41 @@ func (m *Manager) initiate() error {
 func (m *Manager) run() {
 	// initiate notifier
 	go m.notifier.Run()
+	go m.pushDispatcher.Run()
+	go m.chatDispatcher.Run()
+	m.opts.ExternalAlertmanagers.Run()
+	if m.opts.RemoteWrite != nil {
+		go m.opts.RemoteWrite.Run(context.Background())
+	}
+	m.opts.EvalPool.Start()
+	m.opts.warmUp.start(time.Now())
+	if m.opts.StateHistory != nil {
+		go m.opts.StateHistory.Run()
+	}
+	if m.opts.sharder != nil {
+		go m.opts.sharder.Run(m.opts.Context)
+	}
+	if m.opts.historyRetention != nil {
+		go m.opts.historyRetention.Run(m.opts.Context)
+	}
+	if m.opts.notificationLog != nil {
+		go m.opts.notificationLog.Run()
+	}
+	if m.opts.costReport != nil {
+		go m.opts.costReport.Run(m.opts.Context)
+	}
+	if m.opts.alertDigest != nil {
+		go m.opts.alertDigest.Run(m.opts.Context)
+	}
+	if m.opts.staleRules != nil {
+		go m.opts.staleRules.Run(m.opts.Context)
+	}
+	if m.reader != nil {
+		go m.opts.tuning.Run(m.opts.Context)
+	}
+	if m.opts.incidents != nil {
+		go m.opts.incidents.Run(m.opts.Context)
+	}
 
 	// initiate blocked tasks
 	close(m.block)
@@ -290,6 +679,39 @@ func (m *Manager) Stop() {
 	for _, t := range m.tasks {
 		t.Stop()
 	}
+	if m.opts.sharder != nil {
+		m.opts.sharder.Stop()
+	}
+	if m.opts.historyRetention != nil {
+		m.opts.historyRetention.Stop()
+	}
+	if m.opts.notificationLog != nil {
+		m.opts.notificationLog.Stop()
+	}
+	if m.opts.costReport != nil {
+		m.opts.costReport.Stop()
+	}
+	if m.opts.alertDigest != nil {
+		m.opts.alertDigest.Stop()
+	}
+	if m.opts.staleRules != nil {
+		m.opts.staleRules.Stop()
+	}
+	if m.reader != nil {
+		m.opts.tuning.Stop()
+	}
+	m.opts.EvalPool.Stop()
+	if m.opts.incidents != nil {
+		m.opts.incidents.Stop()
+	}
+	m.opts.Events.Stop()
+	if m.opts.StateHistory != nil {
+		m.opts.StateHistory.Stop()
+	}
+	m.pushDispatcher.Stop()
+	m.chatDispatcher.Stop()
+	m.opts.ExternalAlertmanagers.Stop()
+	m.opts.RemoteWrite.Stop()
 
 	zap.L().Info("Rule manager stopped")
 }
@@ -304,6 +726,35 @@ func (m *Manager) EditRule(ctx context.Context, ruleStr string, id string) error
 		return err
 	}
 
+	if err := parsedRule.validateSandbox(); err != nil {
+		return err
+	}
+
+	if err := m.validateChannels(tenantOf(ctx), parsedRule.PreferredChannels); err != nil {
+		return err
+	}
+
+	if err := m.validateBackend(parsedRule); err != nil {
+		return err
+	}
+
+	if err := m.checkQueryCost(ctx, parsedRule); err != nil {
+		return err
+	}
+
+	if err := m.checkTeamQuota(ctx, parsedRule, id); err != nil {
+		return err
+	}
+
+	// the rules of the other orgs are not found
+	storedRule, err := m.ruleDB.GetStoredRule(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := m.checkRuleOwner(ctx, storedRule, parsedRule); err != nil {
+		return err
+	}
+
 	taskName, _, err := m.ruleDB.EditRuleTx(ctx, ruleStr, id)
 	if err != nil {
 		return err
@@ -356,7 +807,9 @@ func (m *Manager) editTask(rule *PostableRule, taskName string) error {
 
 	if ok {
 		oldTask.Stop()
-		newTask.CopyState(oldTask)
+		if err := newTask.CopyState(oldTask); err != nil {
+			zap.L().Error("failed to copy the state of the rule task", zap.String("name", taskName), zap.Error(err))
+		}
 	}
 	go func() {
 		// Wait with starting evaluation until the rule manager
@@ -367,6 +820,7 @@ func (m *Manager) editTask(rule *PostableRule, taskName string) error {
 	}()
 
 	m.tasks[taskName] = newTask
+	m.digests[taskName] = ruleDigest(rule)
 	return nil
 }
 
@@ -378,16 +832,32 @@ func (m *Manager) DeleteRule(ctx context.Context, id string) error {
 		return fmt.Errorf("delete rule received an rule id in invalid format, must be a number")
 	}
 
+	storedRule, err := m.ruleDB.GetStoredRule(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := m.checkRuleOwner(ctx, storedRule, nil); err != nil {
+		return err
+	}
+
 	taskName := prepareTaskName(int64(idInt))
 	if !m.opts.DisableRules {
 		m.deleteTask(taskName)
 	}
+	m.opts.tenants.forget(id)
 
 	if _, _, err := m.ruleDB.DeleteRuleTx(ctx, id); err != nil {
 		zap.L().Error("failed to delete the rule from rule db", zap.String("id", id), zap.Error(err))
 		return err
 	}
 
+	if err := m.ruleDB.DeleteRuleEvaluations(ctx, id); err != nil {
+		zap.L().Error("failed to delete the evaluation log of the rule", zap.String("id", id), zap.Error(err))
+	}
+	if m.opts.seriesActivity != nil {
+		m.opts.seriesActivity.forget(ctx, id)
+	}
+
 	return nil
 }
 
@@ -400,7 +870,19 @@ func (m *Manager) deleteTask(taskName string) {
 	if ok {
 		oldg.Stop()
 		delete(m.tasks, taskName)
+		delete(m.digests, taskName)
 		delete(m.rules, ruleIdFromTaskName(taskName))
+		m.opts.metrics.forget(ruleIdFromTaskName(taskName))
+		m.opts.costs.forget(ruleIdFromTaskName(taskName))
+		if m.opts.TeamQuotas != nil {
+			m.opts.TeamQuotas.forget(ruleIdFromTaskName(taskName))
+		}
+		m.opts.profiler.forget(ruleIdFromTaskName(taskName))
+		if m.opts.AlertSpill != nil {
+			if err := m.opts.AlertSpill.Clear(ruleIdFromTaskName(taskName)); err != nil {
+				zap.L().Error("failed to clear the spilled alerts", zap.String("name", taskName), zap.Error(err))
+			}
+		}
 		zap.L().Debug("rule task deleted", zap.String("name", taskName))
 	} else {
 		zap.L().Info("rule not found for deletion", zap.String("name", taskName))
@@ -416,11 +898,36 @@ func (m *Manager) CreateRule(ctx context.Context, ruleStr string) (*GettableRule
 		return nil, err
 	}
 
+	if err := parsedRule.validateSandbox(); err != nil {
+		return nil, err
+	}
+
+	if err := m.validateChannels(tenantOf(ctx), parsedRule.PreferredChannels); err != nil {
+		return nil, err
+	}
+
+	if err := m.validateBackend(parsedRule); err != nil {
+		return nil, err
+	}
+
+	if err := m.checkQueryCost(ctx, parsedRule); err != nil {
+		return nil, err
+	}
+
+	if ruleStr, err = m.setRuleOwner(ctx, parsedRule, ruleStr); err != nil {
+		return nil, err
+	}
+
+	if err := m.checkTeamQuota(ctx, parsedRule, ""); err != nil {
+		return nil, err
+	}
+
 	lastInsertId, tx, err := m.ruleDB.CreateRuleTx(ctx, ruleStr)
 	taskName := prepareTaskName(lastInsertId)
 	if err != nil {
 		return nil, err
 	}
+	m.opts.tenants.set(strconv.FormatInt(lastInsertId, 10), tenantOf(ctx))
 	if !m.opts.DisableRules {
 		if err := m.addTask(parsedRule, taskName); err != nil {
 			tx.Rollback()
@@ -479,6 +986,7 @@ func (m *Manager) addTask(rule *PostableRule, taskName string) error {
 	}()
 
 	m.tasks[taskName] = newTask
+	m.digests[taskName] = ruleDigest(rule)
 	return nil
 }
 
@@ -555,8 +1063,13 @@ type NotifyFunc func(ctx context.Context, expr string, alerts ...*Alert)
 // prepareNotifyFunc implements the NotifyFunc for a Notifier.
 func (m *Manager) prepareNotifyFunc() NotifyFunc {
 	return func(ctx context.Context, expr string, alerts ...*Alert) {
+		_, span := startSpan(ctx, "rule.notify", attribute.Int("rule.notify.alerts", len(alerts)))
+		defer span.End()
+
 		var res []*am.Alert
 
+		alerts = m.closedPages.filter(alerts)
+		alerts = m.filterTeamQuotas(alerts)
 		for _, alert := range alerts {
 			generatorURL := alert.GeneratorURL
 			if generatorURL == "" {
@@ -566,7 +1079,7 @@ func (m *Manager) prepareNotifyFunc() NotifyFunc {
 			a := &am.Alert{
 				StartsAt:     alert.FiredAt,
 				Labels:       alert.Labels,
-				Annotations:  alert.Annotations,
+				Annotations:  valueHistoryAnnotations(alert),
 				GeneratorURL: generatorURL,
 				Receivers:    alert.Receivers,
 			}
@@ -578,8 +1091,15 @@ func (m *Manager) prepareNotifyFunc() NotifyFunc {
 			res = append(res, a)
 		}
 
-		if len(alerts) > 0 {
-			m.notifier.Send(res...)
+		res = m.opts.dedup.filter(res, time.Now())
+		if len(res) > 0 {
+			m.annotateRelated(ctx, res)
+			m.opts.ExternalAlertmanagers.Send(res...)
+			if !m.opts.ExternalAlertmanagers.Replace() {
+				m.notifier.Send(res...)
+				m.pushDispatcher.Send(res...)
+				m.chatDispatcher.Send(res...)
+			}
 		}
 	}
 }
@@ -605,7 +1125,11 @@ func (m *Manager) ListRuleStates(ctx context.Context) (*GettableRules, error) {
 	// initiate response object
 	resp := make([]*GettableRule, 0)
 
+	org := tenantOf(ctx)
 	for _, s := range storedRules {
+		if !visibleTo(s.OrgId, org) {
+			continue
+		}
 
 		ruleResponse := &GettableRule{}
 		if err := json.Unmarshal([]byte(s.Data), ruleResponse); err != nil { // Parse []byte to go struct pointer
@@ -614,6 +1138,7 @@ func (m *Manager) ListRuleStates(ctx context.Context) (*GettableRules, error) {
 		}
 
 		ruleResponse.Id = fmt.Sprintf("%d", s.Id)
+		ruleResponse.SandboxViolations = sandboxViolationTexts(&ruleResponse.PostableRule)
 
 		// fetch state of rule from memory
 		if rm, ok := m.rules[ruleResponse.Id]; !ok {
@@ -621,6 +1146,7 @@ func (m *Manager) ListRuleStates(ctx context.Context) (*GettableRules, error) {
 			ruleResponse.Disabled = true
 		} else {
 			ruleResponse.State = rm.State()
+			ruleResponse.Health = m.ruleHealth(rm)
 		}
 		ruleResponse.CreatedAt = s.CreatedAt
 		ruleResponse.CreatedBy = s.CreatedBy
@@ -632,6 +1158,238 @@ func (m *Manager) ListRuleStates(ctx context.Context) (*GettableRules, error) {
 	return &GettableRules{Rules: resp}, nil
 }
 
+// ReceiveExternalAlerts sends the alerts raised by other systems through
+// the notify pipeline of the rules, where they are deduplicated and
+// routed and silenced like the alerts of the SigNoz rules, and records
+// their state changes in the history. The alerts belong to an org and go
+// to the given channels, which must be visible to it, or to all the
+// channels visible to it when empty. The firing alerts are not sent during a
+// maintenance of the org covering all its alerts or the source.
+func (m *Manager) ReceiveExternalAlerts(ctx context.Context, org string, source InboundSource, alerts []ExternalAlert, channels []string) *model.ApiError {
+	if len(alerts) == 0 {
+		return nil
+	}
+	// without an org the alerts could be sent to the channels of any org
+	if org == "" {
+		return &model.ApiError{Typ: model.ErrorForbidden, Err: fmt.Errorf("the external alerts must belong to an org")}
+	}
+
+	if err := m.validateChannels(org, channels); err != nil {
+		return &model.ApiError{Typ: model.ErrorForbidden, Err: err}
+	}
+	if len(channels) == 0 {
+		visible, err := m.visibleChannels(org)
+		if err != nil {
+			return &model.ApiError{Typ: model.ErrorInternal, Err: err}
+		}
+		if len(visible) == 0 {
+			return &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("org %s has no channels to send the alerts to", org)}
+		}
+		channels = visible
+	}
+
+	ruleID := externalRuleID(org, source)
+	m.opts.tenants.set(ruleID, org)
+	maintenance, err := m.ruleDB.GetAllPlannedMaintenance(ctx)
+	if err != nil {
+		zap.L().Error("failed to get the planned maintenances", zap.Error(err))
+	}
+	now := time.Now()
+	muted := false
+	for _, mw := range maintenance {
+		if mw.appliesTo(org) && mw.shouldSkip(ruleID, now) {
+			muted = true
+			break
+		}
+	}
+
+	res := make([]*Alert, 0, len(alerts))
+	history := []v3.RuleStateHistory{}
+
+	m.externalStatesMu.Lock()
+	for _, ext := range alerts {
+		lbls := labels.FromMap(ext.Labels)
+		a := &Alert{
+			Labels:       lbls,
+			Annotations:  labels.FromMap(ext.Annotations),
+			FiredAt:      ext.StartsAt,
+			ValidUntil:   ext.EndsAt,
+			GeneratorURL: ext.GeneratorURL,
+			Receivers:    channels,
+		}
+		if a.FiredAt.IsZero() {
+			a.FiredAt = now
+		}
+		state := "firing"
+		if ext.Resolved {
+			state = "normal"
+			a.ResolvedAt = ext.EndsAt
+			if a.ResolvedAt.IsZero() {
+				a.ResolvedAt = now
+			}
+		}
+		if !muted || ext.Resolved {
+			res = append(res, a)
+		}
+
+		fp := lbls.Hash()
+		key := externalStateKey{org: org, fp: fp}
+		prev, seen := m.externalStates[key]
+		if prev == state || (!seen && ext.Resolved) {
+			continue
+		}
+		if ext.Resolved {
+			delete(m.externalStates, key)
+		} else {
+			m.externalStates[key] = state
+		}
+
+		labelsJSON, _ := json.Marshal(lbls)
+		history = append(history, v3.RuleStateHistory{
+			RuleID:              ruleID,
+			RuleName:            lbls.Get(labels.AlertNameLabel),
+			OverallState:        state,
+			OverallStateChanged: true,
+			State:               state,
+			StateChanged:        true,
+			UnixMilli:           now.UnixMilli(),
+			Labels:              v3.LabelsString(labelsJSON),
+			Fingerprint:         fp,
+		})
+	}
+	m.externalStatesMu.Unlock()
+
+	m.prepareNotifyFunc()(ctx, "", res...)
+
+	if m.reader != nil {
+		writeStateHistory(ctx, m.opts.StateHistory, m.reader, history)
+	}
+	return nil
+}
+
+// validateChannels rejects channels owned by another org unless they
+// are shared. Requests without an org, e.g. from the alert manager, are
+// not scoped.
+func (m *Manager) validateChannels(org string, names []string) error {
+	if org == "" || m.reader == nil || len(names) == 0 {
+		return nil
+	}
+
+	channels, apiErr := m.reader.GetChannels()
+	if apiErr != nil {
+		return apiErr.Err
+	}
+	byName := make(map[string]model.ChannelItem, len(*channels))
+	for _, channel := range *channels {
+		byName[channel.Name] = channel
+	}
+
+	for _, name := range names {
+		if channel, ok := byName[name]; ok && !channel.VisibleTo(org) {
+			return fmt.Errorf("channel %s belongs to another org and is not shared", name)
+		}
+	}
+	return nil
+}
+
+// visibleChannels returns the names of the channels the org can send to
+func (m *Manager) visibleChannels(org string) ([]string, error) {
+	if m.reader == nil {
+		return nil, nil
+	}
+	channels, apiErr := m.reader.GetChannels()
+	if apiErr != nil {
+		return nil, apiErr.Err
+	}
+	var names []string
+	for _, channel := range *channels {
+		if channel.VisibleTo(org) {
+			names = append(names, channel.Name)
+		}
+	}
+	return names, nil
+}
+
+// validateBackend denies saving a rule whose query backend is not
+// registered or does not serve its queries
+func (m *Manager) validateBackend(rule *PostableRule) error {
+	if rule.Backend == "" || m.opts.Queriers == nil {
+		return nil
+	}
+	_, err := m.opts.Queriers.backendFor(rule.Backend, rule.RuleType)
+	return err
+}
+
+// TestPushChannel sends a test notification to the devices of a push channel
+func (m *Manager) TestPushChannel(ctx context.Context, receiver *am.Receiver) *model.ApiError {
+	return m.pushDispatcher.Test(ctx, receiver)
+}
+
+// TestChatChannel sends a test message to the targets of an irc or xmpp
+// channel
+func (m *Manager) TestChatChannel(ctx context.Context, receiver *am.Receiver) *model.ApiError {
+	return m.chatDispatcher.Test(ctx, receiver)
+}
+
+// NotifierStats returns the state of the queue of the notifications sent
+// to the alert manager
+func (m *Manager) NotifierStats() am.NotifierStats {
+	return m.notifier.Stats()
+}
+
+// ExternalAlertmanagerStats returns the state of the queue of every
+// external Alertmanager cluster
+func (m *Manager) ExternalAlertmanagerStats() map[string]am.NotifierStats {
+	return m.opts.ExternalAlertmanagers.Stats()
+}
+
+// QueryBackends returns the names of the query backends the rules can
+// select
+func (m *Manager) QueryBackends() []string {
+	return m.opts.Queriers.Backends()
+}
+
+// ReloadTemplateSnippets refreshes the snippets used by the rules, it is
+// called after every change so that the rules pick up the new content on
+// their next evaluation
+func (m *Manager) ReloadTemplateSnippets(ctx context.Context) error {
+	snippets, err := m.ruleDB.GetTemplateSnippets(ctx)
+	if err != nil {
+		return err
+	}
+	m.opts.Snippets.Set(snippets)
+	return nil
+}
+
+// PreviewTemplate renders the template of the request, with the latest
+// alert of the rule when a rule id is given
+func (m *Manager) PreviewTemplate(ctx context.Context, req *TemplatePreviewRequest) (*TemplatePreviewResponse, *model.ApiError) {
+	var sample TemplatePreviewSample
+	format := m.opts.Formats.forOrg(tenantOf(ctx))
+	if req.Sample != nil {
+		sample = *req.Sample
+	}
+	if req.RuleId != "" {
+		m.mtx.RLock()
+		rule, ok := m.rules[req.RuleId]
+		m.mtx.RUnlock()
+		if !ok {
+			return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("rule %s not found or disabled", req.RuleId)}
+		}
+		sample = previewSampleFromRule(rule, format)
+	}
+	return PreviewTemplate(ctx, req.Template, sample, m.opts.Snippets.All(), format, time.Now()), nil
+}
+
+// GetChartSnapshot returns the png rendered for a firing alert when the
+// signature of its url is valid
+func (m *Manager) GetChartSnapshot(ctx context.Context, id, expires, signature string) ([]byte, bool) {
+	if !m.opts.Snapshots.Verify(id, expires, signature, time.Now()) {
+		return nil, false
+	}
+	return m.opts.Snapshots.Get(ctx, id)
+}
+
 func (m *Manager) GetRule(ctx context.Context, id string) (*GettableRule, error) {
 	s, err := m.ruleDB.GetStoredRule(ctx, id)
 	if err != nil {
@@ -642,12 +1400,14 @@ func (m *Manager) GetRule(ctx context.Context, id string) (*GettableRule, error)
 		return nil, err
 	}
 	r.Id = fmt.Sprintf("%d", s.Id)
+	r.SandboxViolations = sandboxViolationTexts(&r.PostableRule)
 	// fetch state of rule from memory
 	if rm, ok := m.rules[r.Id]; !ok {
 		r.State = StateDisabled
 		r.Disabled = true
 	} else {
 		r.State = rm.State()
+		r.Health = m.ruleHealth(rm)
 	}
 	r.CreatedAt = s.CreatedAt
 	r.CreatedBy = s.CreatedBy
@@ -657,6 +1417,39 @@ func (m *Manager) GetRule(ctx context.Context, id string) (*GettableRule, error)
 	return r, nil
 }
 
+// GetRuleEvaluations returns the last evaluations of the rule recorded in
+// the evaluation log, latest first
+func (m *Manager) GetRuleEvaluations(ctx context.Context, id string, limit int) ([]EvaluationRecord, error) {
+	if limit <= 0 {
+		limit = DefaultEvaluationsLimit
+	}
+	return m.ruleDB.GetRuleEvaluations(ctx, id, limit)
+}
+
+// ruleHealth returns the evaluation health of the rule and the missed
+// evaluations of its task
+func (m *Manager) ruleHealth(rule Rule) *RuleHealthStatus {
+	health := &RuleHealthStatus{
+		Health:               rule.Health(),
+		LastEvaluation:       rule.GetEvaluationTimestamp(),
+		EvaluationDurationMs: rule.GetEvaluationDuration().Milliseconds(),
+	}
+	if err := rule.LastError(); err != nil {
+		health.LastError = err.Error()
+		health.LastErrorCategory = ErrorCategoryOf(err)
+	} else if nf := m.opts.notificationFailures.get(rule.ID()); nf != nil {
+		// the notifications failing do not make the evaluations bad
+		health.LastError = nf.err.Error()
+		health.LastErrorCategory = nf.err.Category
+	}
+	if task, ok := m.tasks[prepareTaskName(rule.ID())]; ok {
+		health.EvalStats = task.EvalStats()
+		breaker := task.BreakerStatus(rule.ID())
+		health.Breaker = &breaker
+	}
+	return health
+}
+
 // syncRuleStateWithTask ensures that the state of a stored rule matches
 // the task state. For example - if a stored rule is disabled, then
 // there is no task running against it.
@@ -713,12 +1506,27 @@ func (m *Manager) PatchRule(ctx context.Context, ruleStr string, ruleId string)
 		return nil, err
 	}
 
+	// the patch is decoded into the queries of the stored rule
+	storedQueries := storedRule.clickHouseQueries()
+
 	// patchedRule is combo of stored rule and patch received in the request
 	patchedRule, err := parseIntoRule(storedRule, []byte(ruleStr), "json")
 	if err != nil {
 		return nil, err
 	}
 
+	if err := patchedRule.validatePatchedSandbox(storedQueries); err != nil {
+		return nil, err
+	}
+
+	if err := m.validateChannels(tenantOf(ctx), patchedRule.PreferredChannels); err != nil {
+		return nil, err
+	}
+
+	if err := m.checkRuleOwner(ctx, storedJSON, patchedRule); err != nil {
+		return nil, err
+	}
+
 	// deploy or un-deploy task according to patched (new) rule state
 	if err := m.syncRuleStateWithTask(taskName, patchedRule); err != nil {
 		zap.L().Error("failed to sync stored rule state with the task", zap.String("taskName", taskName), zap.Error(err))
@@ -760,6 +1568,139 @@ func (m *Manager) PatchRule(ctx context.Context, ruleStr string, ruleId string)
 	return &response, nil
 }
 
+// ExplainRule re-runs the stored rule for ts and returns the decision of
+// the rule for every series. The rule is built from its definition, the
+// state of the running rule is not changed.
+func (m *Manager) ExplainRule(ctx context.Context, id string, ts time.Time) (*Explanation, *model.ApiError) {
+	rule, apiErr := m.storedRule(ctx, id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	explainer, ok := rule.(Explainer)
+	if !ok {
+		return nil, newApiErrorBadData(fmt.Errorf("rules of type %s cannot be explained", rule.Type()))
+	}
+	queriers, err := m.opts.Queriers.For(rule)
+	if err != nil {
+		return nil, newApiErrorBadData(err)
+	}
+	explanation, err := explainer.Explain(ctx, ts, queriers)
+	if err != nil {
+		zap.L().Error("explaining rule failed", zap.String("ruleid", id), zap.Error(err))
+		return nil, newApiErrorInternal(err)
+	}
+	return explanation, nil
+}
+
+// storedRule builds the rule from its stored definition, apart from the
+// running rule
+func (m *Manager) storedRule(ctx context.Context, id string) (Rule, *model.ApiError) {
+	s, err := m.ruleDB.GetStoredRule(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("rule %s not found", id)}
+		}
+		return nil, newApiErrorInternal(err)
+	}
+	parsedRule, err := ParsePostableRule([]byte(s.Data))
+	if err != nil {
+		return nil, newApiErrorBadData(err)
+	}
+	rule, err := m.newRule(id, parsedRule)
+	if err != nil {
+		return nil, newApiErrorBadData(err)
+	}
+	return rule, nil
+}
+
+// newRule builds a rule that is not evaluated by a task, e.g. to explain
+// or estimate it
+func (m *Manager) newRule(id string, parsedRule *PostableRule) (Rule, error) {
+	switch parsedRule.RuleType {
+	case RuleTypeThreshold:
+		return NewThresholdRule(id, parsedRule, ThresholdRuleOpts{Sandbox: m.opts.ClickHouseSandbox}, m.featureFlags, m.reader)
+	case RuleTypeProm:
+		return NewPromRule(id, parsedRule, m.logger, PromRuleOpts{}, m.reader)
+	default:
+		return nil, fmt.Errorf("failed to derive ruletype with given information")
+	}
+}
+
+// EstimateRuleCost estimates the cost of one evaluation of the rule and
+// compares it to the query cost budget
+func (m *Manager) EstimateRuleCost(ctx context.Context, rule Rule) (*CostEstimate, *model.ApiError) {
+	estimator, ok := rule.(CostEstimator)
+	if !ok {
+		return nil, newApiErrorBadData(fmt.Errorf("the query cost of rules of type %s cannot be estimated", rule.Type()))
+	}
+	queriers, err := m.opts.Queriers.For(rule)
+	if err != nil {
+		return nil, newApiErrorBadData(err)
+	}
+	estimate, err := estimator.EstimateCost(ctx, time.Now(), queriers)
+	if err != nil {
+		return nil, newApiErrorBadData(err)
+	}
+	estimate.applyBudget(m.opts.QueryCostWarnRows, m.opts.QueryCostMaxRows)
+	return estimate, nil
+}
+
+// EstimatePostableRuleCost estimates the cost of a rule before it is saved
+func (m *Manager) EstimatePostableRuleCost(ctx context.Context, ruleStr string) (*CostEstimate, *model.ApiError) {
+	parsedRule, err := ParsePostableRule([]byte(ruleStr))
+	if err != nil {
+		return nil, newApiErrorBadData(err)
+	}
+	if err := parsedRule.validateSandbox(); err != nil {
+		return nil, newApiErrorBadData(err)
+	}
+	rule, err := m.newRule("", parsedRule)
+	if err != nil {
+		return nil, newApiErrorBadData(err)
+	}
+	return m.EstimateRuleCost(ctx, rule)
+}
+
+// EstimateStoredRuleCost estimates the cost of a saved rule
+func (m *Manager) EstimateStoredRuleCost(ctx context.Context, id string) (*CostEstimate, *model.ApiError) {
+	rule, apiErr := m.storedRule(ctx, id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	return m.EstimateRuleCost(ctx, rule)
+}
+
+// GetRuleQueryCost returns the data read by the queries of the last
+// evaluations of the rule
+func (m *Manager) GetRuleQueryCost(id string) QueryCostStats {
+	return m.opts.costs.stats(id)
+}
+
+// checkQueryCost denies saving a rule whose estimated cost exceeds the
+// limit. The rule is saved when its cost cannot be estimated.
+func (m *Manager) checkQueryCost(ctx context.Context, parsedRule *PostableRule) error {
+	if m.opts.QueryCostWarnRows == 0 && m.opts.QueryCostMaxRows == 0 {
+		return nil
+	}
+	rule, err := m.newRule("", parsedRule)
+	if err != nil {
+		return err
+	}
+	estimate, apiErr := m.EstimateRuleCost(ctx, rule)
+	if apiErr != nil {
+		zap.L().Debug("failed to estimate the query cost of the rule", zap.String("rule", parsedRule.AlertName), zap.Error(apiErr.Err))
+		return nil
+	}
+	switch estimate.Verdict {
+	case CostDeny:
+		return errors.New(estimate.Message)
+	case CostWarn:
+		zap.L().Warn("rule exceeds the query cost budget", zap.String("rule", parsedRule.AlertName), zap.Uint64("rows", estimate.Rows), zap.Uint64("budget", estimate.WarnRows))
+	}
+	return nil
+}
+
 // TestNotification prepares a dummy rule for given rule parameters and
 // sends a test notification. returns alert count and error (if any)
 func (m *Manager) TestNotification(ctx context.Context, ruleStr string) (int, *model.ApiError) {
@@ -770,6 +1711,10 @@ func (m *Manager) TestNotification(ctx context.Context, ruleStr string) (int, *m
 		return 0, newApiErrorBadData(err)
 	}
 
+	if err := parsedRule.validateSandbox(); err != nil {
+		return 0, newApiErrorBadData(err)
+	}
+
 	var alertname = parsedRule.AlertName
 	if alertname == "" {
 		// alertname is not mandatory for testing, so picking
@@ -785,7 +1730,11 @@ func (m *Manager) TestNotification(ctx context.Context, ruleStr string) (int, *m
 	if parsedRule.RuleType == RuleTypeThreshold {
 
 		// add special labels for test alerts
-		parsedRule.Annotations[labels.AlertSummaryLabel] = fmt.Sprintf("The rule threshold is set to %.4f, and the observed metric value is {{$value}}.", *parsedRule.RuleCondition.Target)
+		if parsedRule.RuleCondition.Target != nil {
+			parsedRule.Annotations[labels.AlertSummaryLabel] = fmt.Sprintf("The rule threshold is set to %.4f, and the observed metric value is {{$value}}.", *parsedRule.RuleCondition.Target)
+		} else {
+			parsedRule.Annotations[labels.AlertSummaryLabel] = "The rule threshold is set to {{$threshold}}, and the observed metric value is {{$value}}."
+		}
 		parsedRule.Labels[labels.RuleSourceLabel] = ""
 		parsedRule.Labels[labels.AlertRuleIdLabel] = ""
 
@@ -796,6 +1745,9 @@ func (m *Manager) TestNotification(ctx context.Context, ruleStr string) (int, *m
 			ThresholdRuleOpts{
 				SendUnmatched: true,
 				SendAlways:    true,
+				Snapshots:     m.opts.Snapshots,
+				Snippets:      m.opts.Snippets,
+				Sandbox:       m.opts.ClickHouseSandbox,
 			},
 			m.featureFlags,
 			m.reader,
@@ -815,6 +1767,7 @@ func (m *Manager) TestNotification(ctx context.Context, ruleStr string) (int, *m
 			m.logger,
 			PromRuleOpts{
 				SendAlways: true,
+				Snippets:   m.opts.Snippets,
 			},
 			m.reader,
 		)
@@ -830,7 +1783,11 @@ func (m *Manager) TestNotification(ctx context.Context, ruleStr string) (int, *m
 	// set timestamp to current utc time
 	ts := time.Now().UTC()
 
-	count, err := rule.Eval(ctx, ts, m.opts.Queriers)
+	queriers, err := m.opts.Queriers.For(rule)
+	if err != nil {
+		return 0, newApiErrorBadData(err)
+	}
+	count, err := rule.Eval(ctx, ts, queriers)
 	if err != nil {
 		zap.L().Error("evaluating rule failed", zap.String("rule", rule.Name()), zap.Error(err))
 		return 0, newApiErrorInternal(fmt.Errorf("rule evaluation failed"))
@@ -0,0 +1,344 @@
+package rules
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	am "go.signoz.io/signoz/pkg/query-service/integrations/alertManager"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+const metricsNamespace = "signoz_rule"
+
+var ruleLabelNames = []string{"rule_id", "rule_name"}
+
+// ruleMetrics are the self metrics of the rules, they let operators alert
+// on the alerting pipeline itself. Evaluation durations and failure
+// counts are recorded as they happen, the state of the rules is read
+// from the manager when the metrics are scraped.
+type ruleMetrics struct {
+	registry *prometheus.Registry
+
+	evalDuration         *prometheus.HistogramVec
+	evalFailures         *prometheus.CounterVec
+	notificationFailures *prometheus.CounterVec
+	queryReadRows        *prometheus.CounterVec
+	queryReadBytes       *prometheus.CounterVec
+}
+
+func newRuleMetrics() *ruleMetrics {
+	m := &ruleMetrics{
+		registry: prometheus.NewRegistry(),
+		evalDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "evaluation_duration_seconds",
+			Help:      "The duration of rule evaluations.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+		}, ruleLabelNames),
+		evalFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "evaluation_failures_total",
+			Help:      "The number of failed rule evaluations.",
+		}, ruleLabelNames),
+		notificationFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "notification_failures_total",
+			Help:      "The number of alerts of the rule that could not be sent to the alert manager.",
+		}, ruleLabelNames),
+		queryReadRows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_read_rows_total",
+			Help:      "The number of rows read by the queries of the rule.",
+		}, ruleLabelNames),
+		queryReadBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_read_bytes_total",
+			Help:      "The number of bytes read by the queries of the rule.",
+		}, ruleLabelNames),
+	}
+	m.registry.MustRegister(
+		m.evalDuration,
+		m.evalFailures,
+		m.notificationFailures,
+		m.queryReadRows,
+		m.queryReadBytes,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+func (m *ruleMetrics) observeEvaluation(rule Rule, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.evalDuration.WithLabelValues(rule.ID(), rule.Name()).Observe(duration.Seconds())
+}
+
+func (m *ruleMetrics) evaluationFailed(rule Rule) {
+	if m == nil {
+		return
+	}
+	m.evalFailures.WithLabelValues(rule.ID(), rule.Name()).Inc()
+}
+
+func (m *ruleMetrics) observeQueryCost(rule Rule, cost QueryCost) {
+	if m == nil {
+		return
+	}
+	m.queryReadRows.WithLabelValues(rule.ID(), rule.Name()).Add(float64(cost.ReadRows))
+	m.queryReadBytes.WithLabelValues(rule.ID(), rule.Name()).Add(float64(cost.ReadBytes))
+}
+
+// notificationsDropped counts the alerts that were not delivered by rule
+func (m *ruleMetrics) notificationsDropped(alerts []*am.Alert) {
+	for _, a := range alerts {
+		if a.Labels == nil {
+			continue
+		}
+		lbls := a.Labels.Map()
+		ruleID := lbls[labels.AlertRuleIdLabel]
+		if ruleID == "" {
+			continue
+		}
+		m.notificationFailures.WithLabelValues(ruleID, lbls[labels.AlertNameLabel]).Inc()
+	}
+}
+
+// forget removes the series of a deleted rule
+func (m *ruleMetrics) forget(ruleID string) {
+	if m == nil {
+		return
+	}
+	match := prometheus.Labels{"rule_id": ruleID}
+	m.evalDuration.DeletePartialMatch(match)
+	m.evalFailures.DeletePartialMatch(match)
+	m.notificationFailures.DeletePartialMatch(match)
+	m.queryReadRows.DeletePartialMatch(match)
+	m.queryReadBytes.DeletePartialMatch(match)
+}
+
+// registerStateHistory exposes the backlog of the state history writer
+func (m *ruleMetrics) registerStateHistory(w *StateHistoryWriter) {
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "state_history_pending",
+			Help:      "The number of state history items waiting to be written.",
+		}, func() float64 { return float64(w.Pending()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "state_history_dropped_total",
+			Help:      "The number of state history items dropped because the writes failed or fell behind.",
+		}, func() float64 { return float64(w.Dropped()) }),
+	)
+}
+
+func (m *ruleMetrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+var (
+	ruleAlertsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "alerts"),
+		"The number of active alerts of the rule by state.",
+		append(ruleLabelNames, "state"), nil,
+	)
+	ruleActiveAlertsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "active_alerts"),
+		"The number of pending and firing alerts of the rule.",
+		ruleLabelNames, nil,
+	)
+	ruleSamplesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "samples_returned"),
+		"The number of series returned by the query of the rule on its last evaluation.",
+		ruleLabelNames, nil,
+	)
+	ruleLastEvaluationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "last_evaluation_timestamp_seconds"),
+		"The timestamp of the last evaluation of the rule.",
+		ruleLabelNames, nil,
+	)
+	ruleAlertStoreDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "alert_store_alerts"),
+		"The number of alerts held by the alert store of the rule by location.",
+		append(ruleLabelNames, "location"), nil,
+	)
+	ruleAlertStoreEvictionsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "alert_store_evictions_total"),
+		"The number of alerts pushed out of memory by the limit of the alert store.",
+		ruleLabelNames, nil,
+	)
+)
+
+var (
+	notifierQueueDepthDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "notifier", "queue_depth"),
+		"The number of notifications waiting to be sent to the alert manager.",
+		nil, nil,
+	)
+	notifierQueueCapacityDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "notifier", "queue_capacity"),
+		"The capacity of the queue of notifications sent to the alert manager.",
+		nil, nil,
+	)
+	notifierSentDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "notifier", "sent_total"),
+		"The number of notifications delivered to the alert manager.",
+		nil, nil,
+	)
+	notifierDroppedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "notifier", "dropped_total"),
+		"The number of notifications dropped by reason.",
+		[]string{"reason"}, nil,
+	)
+	notifierFailuresDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "notifier", "consecutive_failures"),
+		"The number of failed sends to the alert manager since the last delivered batch.",
+		nil, nil,
+	)
+)
+
+// notifierCollector collects the state of the notification queue
+type notifierCollector struct {
+	notifier *am.Notifier
+}
+
+func (c *notifierCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- notifierQueueDepthDesc
+	ch <- notifierQueueCapacityDesc
+	ch <- notifierSentDesc
+	ch <- notifierDroppedDesc
+	ch <- notifierFailuresDesc
+}
+
+func (c *notifierCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.notifier.Stats()
+	ch <- prometheus.MustNewConstMetric(notifierQueueDepthDesc, prometheus.GaugeValue, float64(stats.QueueDepth))
+	ch <- prometheus.MustNewConstMetric(notifierQueueCapacityDesc, prometheus.GaugeValue, float64(stats.QueueCapacity))
+	ch <- prometheus.MustNewConstMetric(notifierSentDesc, prometheus.CounterValue, float64(stats.Sent))
+	for _, reason := range []string{am.DropQueueFull, am.DropBatchTooLarge} {
+		ch <- prometheus.MustNewConstMetric(notifierDroppedDesc, prometheus.CounterValue, float64(stats.Dropped[reason]), reason)
+	}
+	ch <- prometheus.MustNewConstMetric(notifierFailuresDesc, prometheus.GaugeValue, float64(stats.ConsecutiveFailures))
+}
+
+// ruleStateCollector collects the state of the rules of the manager
+type ruleStateCollector struct {
+	manager *Manager
+}
+
+func (c *ruleStateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- ruleAlertsDesc
+	ch <- ruleActiveAlertsDesc
+	ch <- ruleSamplesDesc
+	ch <- ruleLastEvaluationDesc
+	ch <- ruleAlertStoreDesc
+	ch <- ruleAlertStoreEvictionsDesc
+}
+
+func (c *ruleStateCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, rule := range c.manager.Rules() {
+		id, name := rule.ID(), rule.Name()
+
+		counts := map[AlertState]int{StatePending: 0, StateFiring: 0}
+		for _, a := range rule.ActiveAlerts() {
+			counts[a.State]++
+		}
+		ch <- prometheus.MustNewConstMetric(ruleAlertsDesc, prometheus.GaugeValue, float64(counts[StatePending]), id, name, StatePending.String())
+		ch <- prometheus.MustNewConstMetric(ruleAlertsDesc, prometheus.GaugeValue, float64(counts[StateFiring]), id, name, StateFiring.String())
+		ch <- prometheus.MustNewConstMetric(ruleActiveAlertsDesc, prometheus.GaugeValue, float64(counts[StatePending]+counts[StateFiring]), id, name)
+
+		if r, ok := rule.(interface{ SamplesReturned() int }); ok {
+			ch <- prometheus.MustNewConstMetric(ruleSamplesDesc, prometheus.GaugeValue, float64(r.SamplesReturned()), id, name)
+		}
+		if ts := rule.GetEvaluationTimestamp(); !ts.IsZero() {
+			ch <- prometheus.MustNewConstMetric(ruleLastEvaluationDesc, prometheus.GaugeValue, float64(ts.UnixNano())/1e9, id, name)
+		}
+		if r, ok := rule.(interface{ AlertStoreStats() AlertStoreStats }); ok {
+			stats := r.AlertStoreStats()
+			ch <- prometheus.MustNewConstMetric(ruleAlertStoreDesc, prometheus.GaugeValue, float64(stats.InMemory), id, name, "memory")
+			ch <- prometheus.MustNewConstMetric(ruleAlertStoreDesc, prometheus.GaugeValue, float64(stats.Spilled), id, name, "spill")
+			ch <- prometheus.MustNewConstMetric(ruleAlertStoreEvictionsDesc, prometheus.CounterValue, float64(stats.Evicted), id, name)
+		}
+	}
+}
+
+const (
+	// alertsMetricName and alertsForStateMetricName are the metrics
+	// Prometheus exposes the active alerts of its rules with
+	alertsMetricName         = "ALERTS"
+	alertsForStateMetricName = "ALERTS_FOR_STATE"
+	alertStateLabel          = "alertstate"
+)
+
+// alertsCollector exposes the pending and firing alerts of the rules as
+// Prometheus does, ALERTS with the state of every alert and
+// ALERTS_FOR_STATE with the time it became active, so that the states can
+// be scraped, recorded and graphed. The label names of the alerts differ,
+// the collector is unchecked.
+type alertsCollector struct {
+	manager *Manager
+}
+
+func (c *alertsCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (c *alertsCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, rule := range c.manager.Rules() {
+		for _, a := range rule.ActiveAlerts() {
+			if a.State != StatePending && a.State != StateFiring {
+				continue
+			}
+			names, values := alertMetricLabels(a.Labels.Map())
+
+			forState := prometheus.NewDesc(alertsForStateMetricName, "The time the alert of the rule became active.", names, nil)
+			ch <- prometheus.MustNewConstMetric(forState, prometheus.GaugeValue, float64(a.ActiveAt.UnixNano())/1e9, values...)
+
+			names = append(names, alertStateLabel)
+			values = append(values, a.State.String())
+			alerts := prometheus.NewDesc(alertsMetricName, "The pending and firing alerts of the rules.", names, nil)
+			ch <- prometheus.MustNewConstMetric(alerts, prometheus.GaugeValue, 1, values...)
+		}
+	}
+}
+
+// alertMetricLabels returns the labels of the alert as valid Prometheus
+// label names, e.g. service.name as service_name, the reserved labels and
+// the state are left out
+func alertMetricLabels(lbls map[string]string) ([]string, []string) {
+	keys := make([]string, 0, len(lbls))
+	for k := range lbls {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	seen := map[string]bool{alertStateLabel: true}
+	names := make([]string, 0, len(keys)+1)
+	values := make([]string, 0, len(keys)+1)
+	for _, k := range keys {
+		name := sanitizeLabelName(k)
+		if name == "" || strings.HasPrefix(name, "__") || seen[name] || lbls[k] == "" {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+		values = append(values, lbls[k])
+	}
+	return names, values
+}
+
+func sanitizeLabelName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !(c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || (i > 0 && '0' <= c && c <= '9')) {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,181 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/featureManager"
+	am "go.signoz.io/signoz/pkg/query-service/integrations/alertManager"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+func TestRuleMetrics(t *testing.T) {
+	target := 10.0
+	postableRule := PostableRule{
+		AlertName:  "High error rate",
+		AlertType:  "METRIC_BASED_ALERT",
+		RuleType:   RuleTypeThreshold,
+		EvalWindow: Duration(5 * time.Minute),
+		Frequency:  Duration(1 * time.Minute),
+		RuleCondition: &RuleCondition{
+			CompositeQuery: &v3.CompositeQuery{
+				QueryType: v3.QueryTypeClickHouseSQL,
+				ClickHouseQueries: map[string]*v3.ClickHouseQuery{
+					"A": {Query: "SELECT 1"},
+				},
+			},
+			CompareOp: ValueIsAbove,
+			MatchType: AtleastOnce,
+			Target:    &target,
+		},
+	}
+	rule, err := NewThresholdRule("7", &postableRule, ThresholdRuleOpts{}, featureManager.StartManager(), nil)
+	require.NoError(t, err)
+	rule.active.Set(1, &Alert{State: StateFiring})
+	rule.active.Set(2, &Alert{State: StateFiring})
+	rule.active.Set(3, &Alert{State: StatePending})
+	rule.samplesReturned = 4
+	rule.SetEvaluationTimestamp(time.Unix(1700000000, 0))
+
+	metrics := newRuleMetrics()
+	manager := &Manager{rules: map[string]Rule{"7": rule}, opts: &ManagerOptions{metrics: metrics}}
+	metrics.registry.MustRegister(&ruleStateCollector{manager: manager})
+
+	metrics.observeEvaluation(rule, 2*time.Second)
+	metrics.evaluationFailed(rule)
+	metrics.notificationsDropped([]*am.Alert{
+		{Labels: labels.FromMap(map[string]string{labels.AlertRuleIdLabel: "7", labels.AlertNameLabel: "High error rate"})},
+		{Labels: labels.FromMap(map[string]string{labels.AlertNameLabel: "external"})},
+	})
+
+	expected := `
+# HELP signoz_rule_active_alerts The number of pending and firing alerts of the rule.
+# TYPE signoz_rule_active_alerts gauge
+signoz_rule_active_alerts{rule_id="7",rule_name="High error rate"} 3
+# HELP signoz_rule_alerts The number of active alerts of the rule by state.
+# TYPE signoz_rule_alerts gauge
+signoz_rule_alerts{rule_id="7",rule_name="High error rate",state="firing"} 2
+signoz_rule_alerts{rule_id="7",rule_name="High error rate",state="pending"} 1
+# HELP signoz_rule_evaluation_failures_total The number of failed rule evaluations.
+# TYPE signoz_rule_evaluation_failures_total counter
+signoz_rule_evaluation_failures_total{rule_id="7",rule_name="High error rate"} 1
+# HELP signoz_rule_last_evaluation_timestamp_seconds The timestamp of the last evaluation of the rule.
+# TYPE signoz_rule_last_evaluation_timestamp_seconds gauge
+signoz_rule_last_evaluation_timestamp_seconds{rule_id="7",rule_name="High error rate"} 1.7e+09
+# HELP signoz_rule_notification_failures_total The number of alerts of the rule that could not be sent to the alert manager.
+# TYPE signoz_rule_notification_failures_total counter
+signoz_rule_notification_failures_total{rule_id="7",rule_name="High error rate"} 1
+# HELP signoz_rule_samples_returned The number of series returned by the query of the rule on its last evaluation.
+# TYPE signoz_rule_samples_returned gauge
+signoz_rule_samples_returned{rule_id="7",rule_name="High error rate"} 4
+`
+	err = testutil.GatherAndCompare(metrics.registry, strings.NewReader(expected),
+		"signoz_rule_active_alerts",
+		"signoz_rule_alerts",
+		"signoz_rule_evaluation_failures_total",
+		"signoz_rule_last_evaluation_timestamp_seconds",
+		"signoz_rule_notification_failures_total",
+		"signoz_rule_samples_returned",
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.evalDuration))
+
+	// the series of deleted rules are removed
+	metrics.forget("7")
+	assert.Equal(t, 0, testutil.CollectAndCount(metrics.evalDuration))
+	assert.Equal(t, 0, testutil.CollectAndCount(metrics.evalFailures))
+
+	var nilMetrics *ruleMetrics
+	nilMetrics.evaluationFailed(rule)
+	nilMetrics.observeEvaluation(rule, time.Second)
+}
+
+func TestNotifierMetrics(t *testing.T) {
+	var dropped []*am.Alert
+	notifier, err := am.NewNotifier(&am.NotifierOptions{
+		QueueCapacity:    2,
+		AlertManagerURLs: []string{"http://alertmanager:9093"},
+		Dropped:          func(alerts []*am.Alert) { dropped = append(dropped, alerts...) },
+	}, nil)
+	require.NoError(t, err)
+
+	alert := func(name, severity string) *am.Alert {
+		return &am.Alert{Labels: labels.FromStrings(labels.AlertNameLabel, name, "severity", severity)}
+	}
+	// the queue is never drained, the lowest priority alert is shed
+	notifier.Send(alert("disk", "critical"), alert("latency", "info"))
+	notifier.Send(alert("errors", "warning"))
+
+	require.Len(t, dropped, 1)
+	assert.Equal(t, "latency", dropped[0].Name())
+
+	metrics := newRuleMetrics()
+	metrics.registry.MustRegister(&notifierCollector{notifier: notifier})
+
+	expected := `
+# HELP signoz_rule_notifier_dropped_total The number of notifications dropped by reason.
+# TYPE signoz_rule_notifier_dropped_total counter
+signoz_rule_notifier_dropped_total{reason="batch_too_large"} 0
+signoz_rule_notifier_dropped_total{reason="queue_full"} 1
+# HELP signoz_rule_notifier_queue_depth The number of notifications waiting to be sent to the alert manager.
+# TYPE signoz_rule_notifier_queue_depth gauge
+signoz_rule_notifier_queue_depth 2
+`
+	err = testutil.GatherAndCompare(metrics.registry, strings.NewReader(expected),
+		"signoz_rule_notifier_dropped_total",
+		"signoz_rule_notifier_queue_depth",
+	)
+	assert.NoError(t, err)
+}
+
+func TestAlertsMetrics(t *testing.T) {
+	target := 10.0
+	postableRule := PostableRule{
+		AlertName:  "High error rate",
+		AlertType:  "METRIC_BASED_ALERT",
+		RuleType:   RuleTypeThreshold,
+		EvalWindow: Duration(5 * time.Minute),
+		Frequency:  Duration(1 * time.Minute),
+		RuleCondition: &RuleCondition{
+			CompositeQuery: &v3.CompositeQuery{
+				QueryType: v3.QueryTypeClickHouseSQL,
+				ClickHouseQueries: map[string]*v3.ClickHouseQuery{
+					"A": {Query: "SELECT 1"},
+				},
+			},
+			CompareOp: ValueIsAbove,
+			MatchType: AtleastOnce,
+			Target:    &target,
+		},
+	}
+	rule, err := NewThresholdRule("7", &postableRule, ThresholdRuleOpts{}, featureManager.StartManager(), nil)
+	require.NoError(t, err)
+	lbls := func(service string) labels.Labels {
+		return labels.FromMap(map[string]string{labels.AlertNameLabel: "High error rate", labels.AlertRuleIdLabel: "7", "service.name": service, "__internal": "x"})
+	}
+	rule.active.Set(1, &Alert{State: StateFiring, Labels: lbls("api"), ActiveAt: time.Unix(1700000000, 0)})
+	rule.active.Set(2, &Alert{State: StatePending, Labels: lbls("web"), ActiveAt: time.Unix(1700000060, 0)})
+	rule.active.Set(3, &Alert{State: StateInactive, Labels: lbls("db")})
+
+	metrics := newRuleMetrics()
+	manager := &Manager{rules: map[string]Rule{"7": rule}, opts: &ManagerOptions{metrics: metrics}}
+	metrics.registry.MustRegister(&alertsCollector{manager: manager})
+
+	expected := `
+# HELP ALERTS The pending and firing alerts of the rules.
+# TYPE ALERTS gauge
+ALERTS{alertname="High error rate",alertstate="firing",ruleId="7",service_name="api"} 1
+ALERTS{alertname="High error rate",alertstate="pending",ruleId="7",service_name="web"} 1
+# HELP ALERTS_FOR_STATE The time the alert of the rule became active.
+# TYPE ALERTS_FOR_STATE gauge
+ALERTS_FOR_STATE{alertname="High error rate",ruleId="7",service_name="api"} 1.7e+09
+ALERTS_FOR_STATE{alertname="High error rate",ruleId="7",service_name="web"} 1.70000006e+09
+`
+	err = testutil.GatherAndCompare(metrics.registry, strings.NewReader(expected), "ALERTS", "ALERTS_FOR_STATE")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,191 @@
+package rules
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+const (
+	// shortLivedAlert is the firing time under which an alert is likely
+	// noise
+	shortLivedAlert = 5 * time.Minute
+	// flappingTransitionsPerDay is the number of state changes a day at
+	// which a rule gets the highest flapping score
+	flappingTransitionsPerDay = 24
+)
+
+// computeNoiseScores scores the rules from the state changes of their
+// alerts, oldest first, and the acknowledgements of the alerts. The score
+// adds the flapping of the rule for 40 points, the share of the short
+// lived alerts for 40 points and the share of the alerts never
+// acknowledged for 20 points. holds are the for durations of the rules.
+func computeNoiseScores(changes []v3.RuleStateHistory, acks []AlertAck, q *v3.QueryNoiseReport, holds map[string]time.Duration) []v3.RuleNoiseScore {
+	type alertKey struct {
+		ruleID      string
+		fingerprint uint64
+	}
+	ackTimes := map[alertKey][]int64{}
+	for _, ack := range acks {
+		key := alertKey{ack.RuleID, ack.Fingerprint}
+		ackTimes[key] = append(ackTimes[key], ack.AckedAt.UnixMilli())
+	}
+	acknowledged := func(e alertEpisode) bool {
+		for _, ts := range ackTimes[alertKey{e.ruleID, e.fingerprint}] {
+			if ts >= e.start && ts <= e.end {
+				return true
+			}
+		}
+		return false
+	}
+
+	type ruleNoise struct {
+		name           string
+		alerts         int
+		resolved       int
+		shortLived     int
+		unacknowledged int
+	}
+	episodes, transitions := alertEpisodes(changes, q.End)
+	noise := map[string]*ruleNoise{}
+	for _, e := range episodes {
+		rn, ok := noise[e.ruleID]
+		if !ok {
+			rn = &ruleNoise{name: e.ruleName}
+			noise[e.ruleID] = rn
+		}
+		rn.alerts++
+		if e.resolved {
+			rn.resolved++
+			if e.duration() < shortLivedAlert.Milliseconds() {
+				rn.shortLived++
+			}
+		}
+		if !acknowledged(e) {
+			rn.unacknowledged++
+		}
+	}
+
+	days := float64(q.End-q.Start) / float64((24 * time.Hour).Milliseconds())
+	scores := make([]v3.RuleNoiseScore, 0, len(noise))
+	for id, rn := range noise {
+		score := v3.RuleNoiseScore{
+			RuleID:                id,
+			RuleName:              rn.name,
+			Alerts:                uint64(rn.alerts),
+			TransitionsPerDay:     float64(transitions[id]) / days,
+			UnacknowledgedPercent: 100 * float64(rn.unacknowledged) / float64(rn.alerts),
+			Suggestions:           []string{},
+		}
+		if rn.resolved > 0 {
+			score.ShortLivedPercent = 100 * float64(rn.shortLived) / float64(rn.resolved)
+		}
+		score.Score = 40*math.Min(score.TransitionsPerDay/flappingTransitionsPerDay, 1) +
+			40*score.ShortLivedPercent/100 +
+			20*score.UnacknowledgedPercent/100
+
+		if score.ShortLivedPercent >= 50 && holds[id] < shortLivedAlert {
+			score.Suggestions = append(score.Suggestions, fmt.Sprintf("raise `for` from %s to at least %s, %.0f%% of the alerts resolve sooner", holds[id], shortLivedAlert, score.ShortLivedPercent))
+		}
+		if score.TransitionsPerDay >= flappingTransitionsPerDay/2 {
+			score.Suggestions = append(score.Suggestions, "add hysteresis, resolve the alerts at a recovery threshold short of the alert threshold so that they do not flap around it")
+		}
+		if score.UnacknowledgedPercent >= 80 {
+			score.Suggestions = append(score.Suggestions, "the alerts are rarely acknowledged, review whether they are actionable or lower their severity")
+		}
+		scores = append(scores, score)
+	}
+
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Score != scores[j].Score {
+			return scores[i].Score > scores[j].Score
+		}
+		return scores[i].RuleID < scores[j].RuleID
+	})
+	if q.Limit > 0 && len(scores) > q.Limit {
+		scores = scores[:q.Limit]
+	}
+	return scores
+}
+
+// NoiseReport ranks the rules by the noise of their alerts, with the
+// changes that would make them quieter. The requests of a user only see
+// the rules of their org.
+func (m *Manager) NoiseReport(ctx context.Context, q *v3.QueryNoiseReport) ([]v3.RuleNoiseScore, error) {
+	if m.reader == nil {
+		return nil, fmt.Errorf("the state history is not stored")
+	}
+	visible, err := m.tenantRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := m.reader.ReadRuleStateChanges(ctx, q.Start, q.End)
+	if err != nil {
+		return nil, err
+	}
+	acks, err := m.ruleDB.GetAlertAcks(ctx, time.UnixMilli(q.Start))
+	if err != nil {
+		return nil, err
+	}
+	changes, acks = visibleStateChanges(changes, visible), visibleAcks(acks, visible)
+
+	holds := map[string]time.Duration{}
+	m.mtx.RLock()
+	for id, r := range m.rules {
+		if r, ok := r.(interface{ HoldDuration() time.Duration }); ok {
+			holds[id] = r.HoldDuration()
+		}
+	}
+	m.mtx.RUnlock()
+
+	return computeNoiseScores(changes, acks, q, holds), nil
+}
+
+// AcknowledgeAlert records that the alert of the rule with the fingerprint
+// was acknowledged
+func (m *Manager) AcknowledgeAlert(ctx context.Context, ruleId string, fingerprint uint64) (*AlertAck, *model.ApiError) {
+	if _, err := m.ruleDB.GetStoredRule(ctx, ruleId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("rule %s not found", ruleId)}
+		}
+		return nil, newApiErrorInternal(err)
+	}
+	ack, err := m.ruleDB.AcknowledgeAlert(ctx, ruleId, fingerprint)
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	m.opts.Events.Publish(m.ackEvent(ack))
+	return ack, nil
+}
+
+// ackEvent returns the event of the acknowledgement, with the labels of
+// the alert when it is still active
+func (m *Manager) ackEvent(ack *AlertAck) AlertEvent {
+	e := AlertEvent{
+		Type:        EventAcked,
+		RuleID:      ack.RuleID,
+		Fingerprint: ack.Fingerprint,
+		Timestamp:   ack.AckedAt,
+	}
+	m.mtx.RLock()
+	rule, ok := m.rules[ack.RuleID]
+	m.mtx.RUnlock()
+	if ok {
+		e.RuleName = rule.Name()
+		for _, a := range rule.ActiveAlerts() {
+			if a.Labels.Hash() == ack.Fingerprint {
+				e = newAlertEvent(EventAcked, rule, a, ack.AckedAt)
+				break
+			}
+		}
+	}
+	e.Actor = ack.AckedBy
+	return e
+}
@@ -0,0 +1,54 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func TestComputeNoiseScores(t *testing.T) {
+	const minute = int64(60 * 1000)
+	const start = int64(1700000000000)
+	change := func(rule string, fp uint64, state string, ts int64) v3.RuleStateHistory {
+		return v3.RuleStateHistory{RuleID: rule, RuleName: "rule " + rule, Fingerprint: fp, State: state, StateChanged: true, UnixMilli: start + ts}
+	}
+
+	var changes []v3.RuleStateHistory
+	// rule 1 flaps, its alerts last a minute
+	for i := int64(0); i < 20; i++ {
+		changes = append(changes, change("1", 1, "firing", i*60*minute), change("1", 1, "normal", i*60*minute+minute))
+	}
+	// rule 2 fires once for an hour and is acknowledged
+	changes = append(changes, change("2", 2, "firing", 30*minute), change("2", 2, "normal", 90*minute))
+	acks := []AlertAck{
+		{RuleID: "2", Fingerprint: 2, AckedAt: time.UnixMilli(start + 40*minute)},
+		// before the alert fired
+		{RuleID: "1", Fingerprint: 1, AckedAt: time.UnixMilli(start - minute)},
+	}
+
+	q := &v3.QueryNoiseReport{Start: start, End: start + 24*60*minute}
+	require.NoError(t, q.Validate())
+	scores := computeNoiseScores(changes, acks, q, map[string]time.Duration{"1": time.Minute, "2": 10 * time.Minute})
+
+	require.Len(t, scores, 2)
+	assert.Equal(t, "1", scores[0].RuleID)
+	assert.Equal(t, uint64(20), scores[0].Alerts)
+	assert.InDelta(t, 40, scores[0].TransitionsPerDay, 0.001)
+	assert.InDelta(t, 100, scores[0].ShortLivedPercent, 0.001)
+	assert.InDelta(t, 100, scores[0].UnacknowledgedPercent, 0.001)
+	assert.InDelta(t, 100, scores[0].Score, 0.001)
+	assert.Len(t, scores[0].Suggestions, 3)
+	assert.Contains(t, scores[0].Suggestions[0], "raise `for` from 1m0s to at least 5m0s")
+
+	assert.Equal(t, "2", scores[1].RuleID)
+	assert.InDelta(t, 0, scores[1].ShortLivedPercent, 0.001)
+	assert.InDelta(t, 0, scores[1].UnacknowledgedPercent, 0.001)
+	assert.InDelta(t, 40*2.0/24, scores[1].Score, 0.001)
+	assert.Empty(t, scores[1].Suggestions)
+
+	q.Limit = 1
+	assert.Len(t, computeNoiseScores(changes, acks, q, nil), 1)
+}
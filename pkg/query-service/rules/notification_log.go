@@ -0,0 +1,116 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	am "go.signoz.io/signoz/pkg/query-service/integrations/alertManager"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+	"go.uber.org/zap"
+)
+
+const (
+	NotificationDelivered = "delivered"
+	NotificationDropped   = "dropped"
+
+	notificationLogBuffer = 1000
+)
+
+// NotificationRecord is a notification of an alert the alert manager
+// accepted or the notifier dropped
+type NotificationRecord struct {
+	RuleId      string `json:"ruleId"`
+	AlertName   string `json:"alertName"`
+	Fingerprint uint64 `json:"fingerprint"`
+	// State is firing or resolved
+	State string `json:"state"`
+	// Status is delivered or dropped
+	Status    string    `json:"status"`
+	Receivers []string  `json:"receivers"`
+	Labels    string    `json:"labels"`
+	SentAt    time.Time `json:"sentAt"`
+}
+
+// NotificationFilter selects the notifications of the log
+type NotificationFilter struct {
+	Start  time.Time
+	End    time.Time
+	RuleId string
+	Status string
+	Limit  int
+}
+
+func newNotificationRecords(alerts []*am.Alert, status string, now time.Time) []NotificationRecord {
+	records := make([]NotificationRecord, 0, len(alerts))
+	for _, a := range alerts {
+		state := "firing"
+		if a.ResolvedAt(now) {
+			state = "resolved"
+		}
+		lbls, _ := json.Marshal(a.Labels.Map())
+		records = append(records, NotificationRecord{
+			RuleId:      a.Labels.Get(labels.AlertRuleIdLabel),
+			AlertName:   a.Name(),
+			Fingerprint: a.Hash(),
+			State:       state,
+			Status:      status,
+			Receivers:   a.Receivers,
+			Labels:      string(lbls),
+			SentAt:      now,
+		})
+	}
+	return records
+}
+
+// notificationLog writes the notifications to the rule db in the
+// background so that the notifier never waits on the db. The records are
+// dropped when the writes fall behind. The log is kept as long as the
+// detailed state history.
+type notificationLog struct {
+	db        RuleDB
+	retention func() time.Duration
+
+	records    chan []NotificationRecord
+	done       chan struct{}
+	terminated chan struct{}
+}
+
+func newNotificationLog(db RuleDB, retention func() time.Duration) *notificationLog {
+	return &notificationLog{
+		db:         db,
+		retention:  retention,
+		records:    make(chan []NotificationRecord, notificationLogBuffer),
+		done:       make(chan struct{}),
+		terminated: make(chan struct{}),
+	}
+}
+
+// add records the notifications of the alerts
+func (l *notificationLog) add(alerts []*am.Alert, status string) {
+	select {
+	case l.records <- newNotificationRecords(alerts, status, time.Now()):
+	default:
+		zap.L().Warn("the notification log is behind, dropping records", zap.Int("count", len(alerts)))
+	}
+}
+
+func (l *notificationLog) Run() {
+	defer close(l.terminated)
+	for {
+		select {
+		case <-l.done:
+			return
+		case records := <-l.records:
+			before := time.Now().Add(-l.retention())
+			if err := l.db.AddNotifications(context.Background(), records, before); err != nil {
+				zap.L().Error("failed to write the notification log", zap.Error(err))
+			}
+		}
+	}
+}
+
+func (l *notificationLog) Stop() {
+	close(l.done)
+	<-l.terminated
+}
@@ -0,0 +1,177 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/formatter"
+	am "go.signoz.io/signoz/pkg/query-service/integrations/alertManager"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// previewAlertAge is how long the sample alert of a preview has been firing
+const previewAlertAge = 5 * time.Minute
+
+// NotificationPreviewRequest renders the notifications of a rule, saved or
+// not, so that their formatting can be checked before the rule is enabled
+type NotificationPreviewRequest struct {
+	Rule json.RawMessage `json:"rule"`
+	// Channels are the channels previewed, the preferred channels of the
+	// rule when empty, or all the channels when it has none
+	Channels []string `json:"channels,omitempty"`
+	// Sample is the alert data the templates of the rule are rendered
+	// with, an alert at the threshold of the rule when not set
+	Sample *TemplatePreviewSample `json:"sample,omitempty"`
+}
+
+// ChannelNotificationPreview is what the integrations of a channel receive
+// when the sample alert fires and when it resolves
+type ChannelNotificationPreview struct {
+	Channel  string                   `json:"channel"`
+	Firing   []am.NotificationPreview `json:"firing"`
+	Resolved []am.NotificationPreview `json:"resolved"`
+}
+
+type NotificationPreviewResponse struct {
+	Sample      TemplatePreviewSample `json:"sample"`
+	Labels      map[string]string     `json:"labels"`
+	Annotations map[string]string     `json:"annotations"`
+	// TemplateErrors are the errors of the templates of the labels and the
+	// annotations of the rule, e.g. by annotations.summary
+	TemplateErrors map[string][]TemplateError   `json:"templateErrors,omitempty"`
+	Channels       []ChannelNotificationPreview `json:"channels"`
+}
+
+// defaultPreviewSample is an alert with the value at the threshold of the
+// rule
+func defaultPreviewSample(rule *PostableRule, format *formatter.Options) TemplatePreviewSample {
+	var unit string
+	var target float64
+	if rule.RuleCondition != nil {
+		if rule.RuleCondition.CompositeQuery != nil {
+			unit = rule.RuleCondition.CompositeQuery.Unit
+		}
+		if rule.RuleCondition.Target != nil {
+			target = *rule.RuleCondition.Target
+		}
+	}
+	threshold := formatter.Format(target, unit, format)
+	return TemplatePreviewSample{Labels: map[string]string{}, Value: threshold, Threshold: threshold}
+}
+
+// previewAlerts renders the labels and the annotations of the rule with the
+// sample and returns its alert firing and resolved
+func previewAlerts(ctx context.Context, rule *PostableRule, sample TemplatePreviewSample, snippets map[string]string, format *formatter.Options, generatorURL string, now time.Time) (*NotificationPreviewResponse, []*am.Alert) {
+	resp := &NotificationPreviewResponse{
+		Sample:         sample,
+		Labels:         map[string]string{},
+		Annotations:    map[string]string{},
+		TemplateErrors: map[string][]TemplateError{},
+		Channels:       []ChannelNotificationPreview{},
+	}
+	expand := func(name, text string) string {
+		res := PreviewTemplate(ctx, text, sample, snippets, format, now)
+		if len(res.Errors) > 0 {
+			resp.TemplateErrors[name] = res.Errors
+			return fmt.Sprintf("<error expanding template: %s>", res.Errors[0].Message)
+		}
+		return res.Result
+	}
+
+	for k, v := range sample.Labels {
+		resp.Labels[k] = v
+	}
+	for k, v := range rule.Labels {
+		resp.Labels[k] = expand("labels."+k, v)
+	}
+	resp.Labels[labels.AlertNameLabel] = rule.AlertName
+	for k, v := range rule.Annotations {
+		resp.Annotations[k] = expand("annotations."+k, v)
+	}
+
+	firing := &am.Alert{
+		Labels:       labels.FromMap(resp.Labels),
+		Annotations:  labels.FromMap(resp.Annotations),
+		StartsAt:     now.Add(-previewAlertAge),
+		EndsAt:       now.Add(previewAlertAge),
+		GeneratorURL: generatorURL,
+	}
+	resolved := *firing
+	resolved.EndsAt = now
+	return resp, []*am.Alert{firing, &resolved}
+}
+
+// PreviewNotifications renders what every selected channel receives when
+// an alert of the rule fires and when it resolves
+func (m *Manager) PreviewNotifications(ctx context.Context, req *NotificationPreviewRequest) (*NotificationPreviewResponse, *model.ApiError) {
+	if len(req.Rule) == 0 {
+		return nil, newApiErrorBadData(fmt.Errorf("rule is required"))
+	}
+	rule, err := ParsePostableRule(req.Rule)
+	if err != nil {
+		return nil, newApiErrorBadData(err)
+	}
+
+	if m.reader == nil {
+		return nil, &model.ApiError{Typ: model.ErrorUnavailable, Err: fmt.Errorf("the channels are not stored")}
+	}
+	org := tenantOf(ctx)
+	channelItems, apiErr := m.reader.GetChannels()
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	byName := map[string]model.ChannelItem{}
+	var all []string
+	for _, c := range *channelItems {
+		if org == "" || c.VisibleTo(org) {
+			byName[c.Name] = c
+			all = append(all, c.Name)
+		}
+	}
+	selected := req.Channels
+	if len(selected) == 0 {
+		selected = rule.PreferredChannels
+	}
+	selected = routedChannels(selected, all)
+	for _, name := range selected {
+		if _, ok := byName[name]; !ok {
+			return nil, newApiErrorBadData(fmt.Errorf("unknown channel %s", name))
+		}
+	}
+
+	format := m.opts.Formats.forOrg(org)
+	sample := defaultPreviewSample(rule, format)
+	if req.Sample != nil {
+		sample = *req.Sample
+		if sample.Labels == nil {
+			sample.Labels = map[string]string{}
+		}
+	}
+	generatorURL := prepareRuleGeneratorURL("", rule.Source)
+	if generatorURL == "" {
+		generatorURL = m.opts.RepoURL
+	}
+	now := time.Now()
+	resp, alerts := previewAlerts(ctx, rule, sample, m.opts.Snippets.All(), format, generatorURL, now)
+
+	for _, name := range selected {
+		receiver := am.Receiver{}
+		if err := json.Unmarshal([]byte(byName[name].Data), &receiver); err != nil {
+			return nil, newApiErrorInternal(fmt.Errorf("invalid channel %s: %w", name, err))
+		}
+		receiver.Name = name
+		receiver.SetLocale(am.ResolveLocale(m.reader, receiver.Locale))
+		resp.Channels = append(resp.Channels, ChannelNotificationPreview{
+			Channel:  name,
+			Firing:   am.PreviewNotifications(&receiver, alerts[:1], m.opts.RepoURL, now),
+			Resolved: am.PreviewNotifications(&receiver, alerts[1:], m.opts.RepoURL, now),
+		})
+	}
+	if len(resp.TemplateErrors) == 0 {
+		resp.TemplateErrors = nil
+	}
+	return resp, nil
+}
@@ -0,0 +1,84 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	am "go.signoz.io/signoz/pkg/query-service/integrations/alertManager"
+)
+
+func TestPreviewNotifications(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	rule := &PostableRule{
+		AlertName:   "checkout latency",
+		Labels:      map[string]string{"severity": "critical"},
+		Annotations: map[string]string{"summary": "latency of {{$labels.service}} is {{$value}}", "description": "{{ .Broken"},
+	}
+	sample := TemplatePreviewSample{Labels: map[string]string{"service": "checkout"}, Value: "850ms", Threshold: "500ms"}
+
+	resp, alerts := previewAlerts(context.Background(), rule, sample, nil, nil, "https://signoz.example.com/alerts/edit", now)
+	assert.Equal(t, map[string]string{"alertname": "checkout latency", "severity": "critical", "service": "checkout"}, resp.Labels)
+	assert.Equal(t, "latency of checkout is 850ms", resp.Annotations["summary"])
+	require.Contains(t, resp.TemplateErrors, "annotations.description")
+	require.Len(t, alerts, 2)
+	assert.False(t, alerts[0].ResolvedAt(now))
+	assert.True(t, alerts[1].ResolvedAt(now))
+
+	receiver := &am.Receiver{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"name": "oncall",
+		"slack_configs": [{"channel": "#alerts", "text": "{{ range .Alerts }}{{ .Annotations.summary }}{{ end }}", "send_resolved": true}],
+		"email_configs": [{"to": "oncall@example.com"}],
+		"webhook_configs": [{"url": "https://hooks.example.com/signoz"}]
+	}`), receiver))
+
+	firing := am.PreviewNotifications(receiver, alerts[:1], "https://signoz.example.com", now)
+	require.Len(t, firing, 3)
+
+	slack := firing[0]
+	assert.Equal(t, am.PreviewSlack, slack.Integration)
+	assert.Empty(t, slack.Errors)
+	msg := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(slack.Body), &msg))
+	assert.Equal(t, "[FIRING:1] checkout latency", msg["text"])
+	assert.Equal(t, "#alerts", msg["channel"])
+	blocks := msg["blocks"].([]interface{})
+	require.Len(t, blocks, 2)
+	assert.Equal(t, "latency of checkout is 850ms", blocks[1].(map[string]interface{})["text"].(map[string]interface{})["text"])
+
+	email := firing[1]
+	assert.Equal(t, am.PreviewEmail, email.Integration)
+	assert.Equal(t, "[FIRING:1] checkout latency", email.Subject)
+	assert.Contains(t, email.Body, "summary = latency of checkout is 850ms")
+
+	webhook := firing[2]
+	payload := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(webhook.Body), &payload))
+	assert.Equal(t, "firing", payload["status"])
+	assert.Equal(t, "oncall", payload["receiver"])
+	assert.Equal(t, map[string]interface{}{"alertname": "checkout latency"}, payload["groupLabels"])
+
+	// the email does not send the resolved alerts by default
+	resolved := am.PreviewNotifications(receiver, alerts[1:], "https://signoz.example.com", now)
+	require.Len(t, resolved, 3)
+	assert.Empty(t, resolved[0].Skipped)
+	assert.NotEmpty(t, resolved[1].Skipped)
+	assert.Empty(t, resolved[1].Body)
+	assert.Contains(t, resolved[2].Body, `"status": "resolved"`)
+}
+
+func TestPreviewNotificationsTemplateErrors(t *testing.T) {
+	receiver := &am.Receiver{}
+	require.NoError(t, json.Unmarshal([]byte(`{"name": "oncall", "slack_configs": [{"title": "{{ .Status | missing }}"}]}`), receiver))
+
+	now := time.Now()
+	_, alerts := previewAlerts(context.Background(), &PostableRule{AlertName: "disk full"}, TemplatePreviewSample{}, nil, nil, "", now)
+	previews := am.PreviewNotifications(receiver, alerts[:1], "", now)
+	require.Len(t, previews, 1)
+	require.Len(t, previews[0].Errors, 1)
+	assert.Contains(t, previews[0].Errors[0], "title")
+}
@@ -0,0 +1,105 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+)
+
+const (
+	// otlpServiceName is the service.name of the resource of the alert
+	// event logs
+	otlpServiceName = "signoz-alerts"
+	// otlpAttributePrefix prefixes the attributes of the alert event logs
+	otlpAttributePrefix = "signoz.alert."
+)
+
+// otlpSink exports the events as OTLP log records over http, to the
+// collector of SigNoz so that they are searchable with the logs and can
+// drive log based rules. The attributes are signoz.alert.event,
+// signoz.alert.rule_id, signoz.alert.rule_name, signoz.alert.fingerprint,
+// signoz.alert.value, signoz.alert.actor, and the labels and annotations
+// of the alert as signoz.alert.label.<name> and
+// signoz.alert.annotation.<name>.
+type otlpSink struct {
+	name    string
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// newOTLPSink posts to the url when it is the logs endpoint, or to
+// {url}/v1/logs
+func newOTLPSink(c EventSinkConfig, client *http.Client) *otlpSink {
+	url := strings.TrimSuffix(c.URL, "/")
+	if !strings.HasSuffix(url, "/v1/logs") {
+		url += "/v1/logs"
+	}
+	return &otlpSink{name: c.Name, url: url, headers: c.Headers, client: client}
+}
+
+func (s *otlpSink) Name() string {
+	return s.name
+}
+
+func (s *otlpSink) Send(ctx context.Context, events []AlertEvent) error {
+	body, err := plogotlp.NewExportRequestFromLogs(eventLogs(events, time.Now())).MarshalProto()
+	if err != nil {
+		return fmt.Errorf("failed to encode the alert event logs: %w", err)
+	}
+	return postEvents(ctx, s.client, s.url, "application/x-protobuf", s.headers, body)
+}
+
+// eventSeverity is the severity of the log record of the event
+func eventSeverity(t EventType) plog.SeverityNumber {
+	switch t {
+	case EventFiring:
+		return plog.SeverityNumberError
+	case EventPending:
+		return plog.SeverityNumberWarn
+	}
+	return plog.SeverityNumberInfo
+}
+
+// eventLogs returns the log records of the events
+func eventLogs(events []AlertEvent, now time.Time) plog.Logs {
+	logs := plog.NewLogs()
+	rl := logs.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("service.name", otlpServiceName)
+	sl := rl.ScopeLogs().AppendEmpty()
+	sl.Scope().SetName("signoz.rules")
+
+	for _, e := range events {
+		record := sl.LogRecords().AppendEmpty()
+		record.SetTimestamp(pcommon.NewTimestampFromTime(e.Timestamp))
+		record.SetObservedTimestamp(pcommon.NewTimestampFromTime(now))
+		severity := eventSeverity(e.Type)
+		record.SetSeverityNumber(severity)
+		record.SetSeverityText(severity.String())
+		record.Body().SetStr(fmt.Sprintf("alert %s %s", e.RuleName, e.Type))
+
+		attrs := record.Attributes()
+		attrs.PutStr(otlpAttributePrefix+"event", string(e.Type))
+		attrs.PutStr(otlpAttributePrefix+"rule_id", e.RuleID)
+		attrs.PutStr(otlpAttributePrefix+"rule_name", e.RuleName)
+		attrs.PutStr(otlpAttributePrefix+"fingerprint", strconv.FormatUint(e.Fingerprint, 10))
+		attrs.PutDouble(otlpAttributePrefix+"value", e.Value)
+		if e.Actor != "" {
+			attrs.PutStr(otlpAttributePrefix+"actor", e.Actor)
+		}
+		for k, v := range e.Labels {
+			attrs.PutStr(otlpAttributePrefix+"label."+k, v)
+		}
+		for k, v := range e.Annotations {
+			attrs.PutStr(otlpAttributePrefix+"annotation."+k, v)
+		}
+	}
+	return logs
+}
@@ -0,0 +1,62 @@
+package rules
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+)
+
+func TestOTLPSink(t *testing.T) {
+	var received plogotlp.ExportRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/logs", r.URL.Path)
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(r.Body)
+		received = plogotlp.NewExportRequest()
+		require.NoError(t, received.UnmarshalProto(body))
+	}))
+	defer srv.Close()
+
+	sink, err := newEventSink(EventSinkConfig{Name: "logs", Type: "otlp", URL: srv.URL})
+	require.NoError(t, err)
+
+	ts := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, sink.Send(context.Background(), []AlertEvent{{
+		Type:        EventFiring,
+		RuleID:      "7",
+		RuleName:    "HighLatency",
+		Fingerprint: 42,
+		Labels:      map[string]string{"service": "api"},
+		Annotations: map[string]string{"summary": "p99 over 2s"},
+		Value:       2.5,
+		Timestamp:   ts,
+	}}))
+
+	logs := received.Logs()
+	require.Equal(t, 1, logs.LogRecordCount())
+	rl := logs.ResourceLogs().At(0)
+	service, _ := rl.Resource().Attributes().Get("service.name")
+	assert.Equal(t, "signoz-alerts", service.Str())
+
+	record := rl.ScopeLogs().At(0).LogRecords().At(0)
+	assert.Equal(t, ts, record.Timestamp().AsTime())
+	assert.Equal(t, plog.SeverityNumberError, record.SeverityNumber())
+	assert.Equal(t, "alert HighLatency firing", record.Body().Str())
+	assert.Equal(t, map[string]interface{}{
+		"signoz.alert.event":              "firing",
+		"signoz.alert.rule_id":            "7",
+		"signoz.alert.rule_name":          "HighLatency",
+		"signoz.alert.fingerprint":        "42",
+		"signoz.alert.value":              2.5,
+		"signoz.alert.label.service":      "api",
+		"signoz.alert.annotation.summary": "p99 over 2s",
+	}, record.Attributes().AsRaw())
+}
@@ -0,0 +1,281 @@
+package rules
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"go.signoz.io/signoz/pkg/query-service/constants"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+	"go.uber.org/zap"
+)
+
+// PagingProvider is an on-call tool the alerts are paged to through the
+// channels
+type PagingProvider string
+
+const (
+	PagingPagerDuty PagingProvider = "pagerduty"
+	PagingOpsgenie  PagingProvider = "opsgenie"
+)
+
+// PageAction is what was done to the page in the on-call tool
+type PageAction string
+
+const (
+	PageAcknowledged PageAction = "acknowledged"
+	PageResolved     PageAction = "resolved"
+)
+
+// PageUpdate is the acknowledgement or the resolution of a page. The alerts
+// of the page are selected by the labels the default templates of the
+// pagerduty and opsgenie channels write in the page.
+type PageUpdate struct {
+	Provider PagingProvider
+	Action   PageAction
+	Labels   map[string]string
+	// Actor is the user of the on-call tool
+	Actor string
+}
+
+var (
+	// pagerDutyTitle is the description of the pagerduty channel,
+	// [FIRING:2] alertname for job (label="value", ...)
+	pagerDutyTitle = regexp.MustCompile(`^\[[A-Z]+(?::\d+)?\] (\S+)`)
+	pagerDutyLabel = regexp.MustCompile(`([a-zA-Z_][a-zA-Z0-9_]*)="((?:[^"\\]|\\.)*)"`)
+	// opsgenieLabel is a label line of the description of the opsgenie
+	// channel, - name = value
+	opsgenieLabel = regexp.MustCompile(`^\s*- ([a-zA-Z_][a-zA-Z0-9_]*) = (.*)$`)
+)
+
+type pagerDutyWebhook struct {
+	Event struct {
+		EventType string `json:"event_type"`
+		Agent     *struct {
+			Summary string `json:"summary"`
+		} `json:"agent"`
+		Data struct {
+			Title string `json:"title"`
+		} `json:"data"`
+	} `json:"event"`
+}
+
+type opsgenieWebhook struct {
+	Action string `json:"action"`
+	Alert  struct {
+		Message     string            `json:"message"`
+		Description string            `json:"description"`
+		Details     map[string]string `json:"details"`
+		Username    string            `json:"username"`
+	} `json:"alert"`
+}
+
+// ParsePageUpdates converts the webhook payload of the provider into page
+// updates, the events other than acknowledgements and resolutions are
+// ignored
+func ParsePageUpdates(provider PagingProvider, body []byte) ([]PageUpdate, error) {
+	switch provider {
+	case PagingPagerDuty:
+		return parsePagerDutyWebhook(body)
+	case PagingOpsgenie:
+		return parseOpsgenieWebhook(body)
+	}
+	return nil, fmt.Errorf("unsupported paging provider %q", provider)
+}
+
+// parsePagerDutyWebhook reads the v3 webhooks of pagerduty
+func parsePagerDutyWebhook(body []byte) ([]PageUpdate, error) {
+	var hook pagerDutyWebhook
+	if err := json.Unmarshal(body, &hook); err != nil {
+		return nil, fmt.Errorf("invalid pagerduty webhook: %w", err)
+	}
+
+	update := PageUpdate{Provider: PagingPagerDuty}
+	switch hook.Event.EventType {
+	case "incident.acknowledged":
+		update.Action = PageAcknowledged
+	case "incident.resolved":
+		update.Action = PageResolved
+	default:
+		return nil, nil
+	}
+	if hook.Event.Agent != nil {
+		update.Actor = hook.Event.Agent.Summary
+	}
+
+	match := pagerDutyTitle.FindStringSubmatch(hook.Event.Data.Title)
+	if match == nil {
+		return nil, nil
+	}
+	update.Labels = map[string]string{labels.AlertNameLabel: match[1]}
+	for _, l := range pagerDutyLabel.FindAllStringSubmatch(hook.Event.Data.Title, -1) {
+		update.Labels[l[1]] = strings.ReplaceAll(l[2], `\"`, `"`)
+	}
+	return []PageUpdate{update}, nil
+}
+
+// parseOpsgenieWebhook reads the webhooks of the opsgenie integration, one
+// update is returned for every alert listed in the description
+func parseOpsgenieWebhook(body []byte) ([]PageUpdate, error) {
+	var hook opsgenieWebhook
+	if err := json.Unmarshal(body, &hook); err != nil {
+		return nil, fmt.Errorf("invalid opsgenie webhook: %w", err)
+	}
+
+	var action PageAction
+	switch hook.Action {
+	case "Acknowledge":
+		action = PageAcknowledged
+	case "Close":
+		action = PageResolved
+	default:
+		return nil, nil
+	}
+
+	var sets []map[string]string
+	var current map[string]string
+	scanner := bufio.NewScanner(strings.NewReader(hook.Alert.Description))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.TrimSpace(line) == "Labels:":
+			current = map[string]string{}
+			sets = append(sets, current)
+		case strings.TrimSpace(line) == "Annotations:":
+			current = nil
+		case current != nil:
+			if l := opsgenieLabel.FindStringSubmatch(line); l != nil {
+				current[l[1]] = strings.TrimSpace(l[2])
+			}
+		}
+	}
+	if len(sets) == 0 {
+		sets = append(sets, map[string]string{})
+	}
+
+	updates := make([]PageUpdate, 0, len(sets))
+	for _, set := range sets {
+		// the details are free form, only the rule id is read from them
+		if id := hook.Alert.Details[labels.AlertRuleIdLabel]; id != "" {
+			set[labels.AlertRuleIdLabel] = id
+		}
+		if set[labels.AlertNameLabel] == "" && hook.Alert.Message != "" {
+			set[labels.AlertNameLabel] = hook.Alert.Message
+		}
+		updates = append(updates, PageUpdate{
+			Provider: PagingOpsgenie,
+			Action:   action,
+			Labels:   set,
+			Actor:    hook.Alert.Username,
+		})
+	}
+	return updates, nil
+}
+
+// matchesPage tells whether the alert is one of the alerts of the page, the
+// page has to name the rule or the alert
+func matchesPage(lbls labels.BaseLabels, page map[string]string) bool {
+	if page[labels.AlertRuleIdLabel] == "" && page[labels.AlertNameLabel] == "" {
+		return false
+	}
+	for k, v := range page {
+		if lbls.Get(k) != v {
+			return false
+		}
+	}
+	return true
+}
+
+// closedPages holds the fingerprints of the firing alerts whose page was
+// resolved in the on-call tool. They are not notified again while they
+// keep firing, so the page is not opened again, until they resolve.
+type closedPages struct {
+	mtx sync.Mutex
+	fps map[uint64]struct{}
+}
+
+func (c *closedPages) close(fp uint64) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.fps == nil {
+		c.fps = map[uint64]struct{}{}
+	}
+	c.fps[fp] = struct{}{}
+}
+
+// filter drops the firing alerts of the closed pages and forgets the
+// alerts that resolved
+func (c *closedPages) filter(alerts []*Alert) []*Alert {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if len(c.fps) == 0 {
+		return alerts
+	}
+	res := make([]*Alert, 0, len(alerts))
+	for _, a := range alerts {
+		fp := a.Labels.Hash()
+		if _, ok := c.fps[fp]; ok {
+			if a.ResolvedAt.IsZero() {
+				continue
+			}
+			delete(c.fps, fp)
+		}
+		res = append(res, a)
+	}
+	return res
+}
+
+// SyncPages applies the acknowledgements and resolutions made in the
+// on-call tool to the firing alerts of the pages, the alerts are
+// acknowledged and the ones of a resolved page are not notified again
+// until they resolve. Only the alerts of the rules of the org of the
+// inbound token are updated. It returns the number of alerts updated.
+func (m *Manager) SyncPages(ctx context.Context, org string, updates []PageUpdate) (int, *model.ApiError) {
+	if org == "" {
+		return 0, &model.ApiError{Typ: model.ErrorForbidden, Err: fmt.Errorf("the inbound alerts token is not scoped to an org")}
+	}
+	type pagedAlert struct {
+		ruleID string
+		fp     uint64
+	}
+
+	updated := 0
+	for _, u := range updates {
+		var paged []pagedAlert
+		m.mtx.RLock()
+		for id, rule := range m.rules {
+			if m.opts.tenants.of(id) != org {
+				continue
+			}
+			for _, a := range rule.ActiveAlerts() {
+				if a.State == StateFiring && matchesPage(a.Labels, u.Labels) {
+					paged = append(paged, pagedAlert{ruleID: id, fp: a.Labels.Hash()})
+				}
+			}
+		}
+		m.mtx.RUnlock()
+
+		actor := fmt.Sprintf("%s:%s", u.Provider, u.Actor)
+		actx := context.WithValue(ctx, constants.ContextUserKey, &model.UserPayload{User: model.User{Email: actor, OrgId: org}})
+		for _, p := range paged {
+			if u.Action == PageResolved {
+				m.closedPages.close(p.fp)
+			}
+			ack, err := m.ruleDB.AcknowledgeAlert(actx, p.ruleID, p.fp)
+			if err != nil {
+				return updated, newApiErrorInternal(err)
+			}
+			m.opts.Events.Publish(m.ackEvent(ack))
+			updated++
+		}
+		if len(paged) == 0 {
+			zap.L().Debug("no firing alert for the page", zap.String("provider", string(u.Provider)), zap.Any("labels", u.Labels))
+		}
+	}
+	return updated, nil
+}
@@ -0,0 +1,126 @@
+package rules
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/common"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+func TestParsePageUpdates(t *testing.T) {
+	pd := `{"event":{"event_type":"incident.acknowledged","agent":{"summary":"Jane"},
+		"data":{"title":"[FIRING:1] HighLatency for  (ruleId=\"7\", service=\"api\")"}}}`
+	updates, err := ParsePageUpdates(PagingPagerDuty, []byte(pd))
+	require.NoError(t, err)
+	require.Len(t, updates, 1)
+	assert.Equal(t, PageAcknowledged, updates[0].Action)
+	assert.Equal(t, "Jane", updates[0].Actor)
+	assert.Equal(t, map[string]string{"alertname": "HighLatency", "ruleId": "7", "service": "api"}, updates[0].Labels)
+
+	updates, err = ParsePageUpdates(PagingPagerDuty, []byte(`{"event":{"event_type":"incident.triggered","data":{"title":"[FIRING:1] HighLatency"}}}`))
+	require.NoError(t, err)
+	assert.Empty(t, updates)
+
+	og := `{"action":"Close","alert":{"message":"HighLatency","username":"john@example.com","details":{"ruleId":"7","firing":"1"},
+		"description":"Alerts Firing:\n - Message: slow\nLabels:\n   - service = api\n   Annotations:\n   - summary = slow\n - Message: slow\nLabels:\n   - service = web\n   Annotations:\n"}}`
+	updates, err = ParsePageUpdates(PagingOpsgenie, []byte(og))
+	require.NoError(t, err)
+	require.Len(t, updates, 2)
+	assert.Equal(t, PageResolved, updates[0].Action)
+	assert.Equal(t, "john@example.com", updates[0].Actor)
+	assert.Equal(t, map[string]string{"alertname": "HighLatency", "ruleId": "7", "service": "api"}, updates[0].Labels)
+	assert.Equal(t, "web", updates[1].Labels["service"])
+
+	_, err = ParsePageUpdates("victorops", []byte(`{}`))
+	assert.Error(t, err)
+}
+
+func TestMatchesPage(t *testing.T) {
+	lbls := labels.FromMap(map[string]string{"alertname": "HighLatency", "ruleId": "7", "service": "api"})
+	assert.True(t, matchesPage(lbls, map[string]string{"alertname": "HighLatency", "service": "api"}))
+	assert.False(t, matchesPage(lbls, map[string]string{"alertname": "HighLatency", "service": "web"}))
+	assert.False(t, matchesPage(lbls, map[string]string{"service": "api"}))
+}
+
+func TestClosedPages(t *testing.T) {
+	firing := &Alert{Labels: labels.FromMap(map[string]string{"alertname": "HighLatency"})}
+	other := &Alert{Labels: labels.FromMap(map[string]string{"alertname": "Errors"})}
+
+	var c closedPages
+	assert.Len(t, c.filter([]*Alert{firing, other}), 2)
+
+	c.close(firing.Labels.Hash())
+	assert.Equal(t, []*Alert{other}, c.filter([]*Alert{firing, other}))
+
+	// the resolution is sent and the alert notified again when it fires
+	resolved := &Alert{Labels: firing.Labels, ResolvedAt: time.Now()}
+	assert.Equal(t, []*Alert{resolved}, c.filter([]*Alert{resolved}))
+	assert.Equal(t, []*Alert{firing}, c.filter([]*Alert{firing}))
+}
+
+// pageAckDB records the acknowledgements and the org of their actor
+type pageAckDB struct {
+	RuleDB
+	acks []AlertAck
+	orgs []string
+}
+
+func (db *pageAckDB) AcknowledgeAlert(ctx context.Context, ruleId string, fingerprint uint64) (*AlertAck, error) {
+	user := common.GetUserFromContext(ctx)
+	ack := AlertAck{RuleID: ruleId, Fingerprint: fingerprint, AckedBy: user.Email, AckedAt: time.Now()}
+	db.acks = append(db.acks, ack)
+	db.orgs = append(db.orgs, user.OrgId)
+	return &ack, nil
+}
+
+func TestSyncPagesScopedByOrg(t *testing.T) {
+	newRule := func(id string) *ThresholdRule {
+		target := 10.0
+		rule, err := NewThresholdRule(id, &PostableRule{
+			AlertName: "HighLatency",
+			RuleCondition: &RuleCondition{
+				CompositeQuery: &v3.CompositeQuery{
+					QueryType:         v3.QueryTypeClickHouseSQL,
+					ClickHouseQueries: map[string]*v3.ClickHouseQuery{"A": {Query: "SELECT 1"}},
+				},
+				CompareOp: ValueIsAbove,
+				MatchType: AtleastOnce,
+				Target:    &target,
+			},
+		}, ThresholdRuleOpts{}, nil, nil)
+		require.NoError(t, err)
+		// the alerts of both orgs have the same labels
+		rule.active.Set(1, &Alert{State: StateFiring, Labels: labels.FromMap(map[string]string{"alertname": "HighLatency", "service": "api"})})
+		return rule
+	}
+	tenants := newRuleTenants()
+	tenants.set("7", "org-a")
+	tenants.set("8", "org-b")
+	db := &pageAckDB{}
+	m := &Manager{
+		rules:  map[string]Rule{"7": newRule("7"), "8": newRule("8")},
+		ruleDB: db,
+		opts:   &ManagerOptions{tenants: tenants},
+	}
+
+	updates := []PageUpdate{{Provider: PagingPagerDuty, Action: PageAcknowledged, Actor: "Jane", Labels: map[string]string{"alertname": "HighLatency", "service": "api"}}}
+	updated, apiErr := m.SyncPages(context.Background(), "org-a", updates)
+	require.Nil(t, apiErr)
+	assert.Equal(t, 1, updated)
+	require.Len(t, db.acks, 1)
+	assert.Equal(t, "7", db.acks[0].RuleID)
+	assert.Equal(t, "pagerduty:Jane", db.acks[0].AckedBy)
+	assert.Equal(t, []string{"org-a"}, db.orgs)
+
+	// the tokens without an org update nothing
+	_, apiErr = m.SyncPages(context.Background(), "", updates)
+	require.NotNil(t, apiErr)
+	assert.Equal(t, model.ErrorForbidden, apiErr.Typ)
+	assert.Len(t, db.acks, 1)
+}
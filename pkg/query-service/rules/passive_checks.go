@@ -0,0 +1,181 @@
+package rules
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// the states of the nagios plugins
+const (
+	checkStateOK       = 0
+	checkStateWarning  = 1
+	checkStateCritical = 2
+	checkStateUnknown  = 3
+)
+
+// PassiveCheckResult is the result of a check run by nagios, or an item
+// sent by zabbix sender, outside SigNoz
+type PassiveCheckResult struct {
+	Host string
+	// Service is empty for the host checks of nagios
+	Service string
+	// State is the state of the nagios plugins, 0 ok, 1 warning, 2
+	// critical and 3 unknown
+	State  int
+	Output string
+	Time   time.Time
+}
+
+// Alert returns the alert of the check, resolved when the check is ok.
+// The alert is named after the service, or the item key of zabbix, and
+// the severity is warning or critical.
+func (c PassiveCheckResult) Alert(source InboundSource) ExternalAlert {
+	name := c.Service
+	if name == "" {
+		name = "host check"
+	}
+	severity := "warning"
+	if c.State == checkStateCritical {
+		severity = "critical"
+	}
+	alert := ExternalAlert{
+		Labels: map[string]string{
+			labels.AlertNameLabel: name,
+			"host":                c.Host,
+			"severity":            severity,
+			ExternalSourceLabel:   string(source),
+		},
+		Annotations: map[string]string{},
+		StartsAt:    c.Time,
+		Resolved:    c.State == checkStateOK,
+	}
+	if c.Service != "" {
+		alert.Labels["service"] = c.Service
+	}
+	if c.Output != "" {
+		alert.Annotations["summary"] = c.Output
+	}
+	if c.State == checkStateUnknown {
+		alert.Annotations["description"] = "the state of the check is unknown"
+	}
+	if alert.Resolved {
+		alert.EndsAt = c.Time
+	}
+	return alert
+}
+
+type nrdpCheckResultsXML struct {
+	Results []struct {
+		Hostname    string `xml:"hostname"`
+		Servicename string `xml:"servicename"`
+		State       string `xml:"state"`
+		Output      string `xml:"output"`
+	} `xml:"checkresult"`
+}
+
+type nrdpCheckResultsJSON struct {
+	Results []struct {
+		Hostname    string `json:"hostname"`
+		Servicename string `json:"servicename"`
+		State       string `json:"state"`
+		Output      string `json:"output"`
+	} `json:"checkresults"`
+}
+
+// ParseNRDPCheckResults reads the check results of the submitcheck command
+// of NRDP, sent in the XMLDATA or the JSONDATA field of the form
+func ParseNRDPCheckResults(form url.Values, now time.Time) ([]PassiveCheckResult, error) {
+	if cmd := form.Get("cmd"); cmd != "" && cmd != "submitcheck" {
+		return nil, fmt.Errorf("unsupported nrdp command %q", cmd)
+	}
+
+	type result struct{ host, service, state, output string }
+	var raw []result
+	switch {
+	case form.Get("XMLDATA") != "":
+		var data nrdpCheckResultsXML
+		if err := xml.Unmarshal([]byte(form.Get("XMLDATA")), &data); err != nil {
+			return nil, fmt.Errorf("invalid nrdp xml data: %w", err)
+		}
+		for _, r := range data.Results {
+			raw = append(raw, result{r.Hostname, r.Servicename, r.State, r.Output})
+		}
+	case form.Get("JSONDATA") != "":
+		var data nrdpCheckResultsJSON
+		if err := json.Unmarshal([]byte(form.Get("JSONDATA")), &data); err != nil {
+			return nil, fmt.Errorf("invalid nrdp json data: %w", err)
+		}
+		for _, r := range data.Results {
+			raw = append(raw, result{r.Hostname, r.Servicename, r.State, r.Output})
+		}
+	default:
+		return nil, fmt.Errorf("no nrdp check results")
+	}
+
+	results := make([]PassiveCheckResult, 0, len(raw))
+	for i, r := range raw {
+		state, err := strconv.Atoi(strings.TrimSpace(r.state))
+		if err != nil || state < checkStateOK || state > checkStateUnknown {
+			return nil, fmt.Errorf("check result %d has an invalid state %q", i, r.state)
+		}
+		if r.host == "" {
+			return nil, fmt.Errorf("check result %d has no host", i)
+		}
+		results = append(results, PassiveCheckResult{
+			Host:    r.host,
+			Service: r.service,
+			State:   state,
+			// the output of the plugins is followed by |perfdata
+			Output: strings.TrimSpace(strings.SplitN(r.output, "|", 2)[0]),
+			Time:   now,
+		})
+	}
+	return results, nil
+}
+
+type zabbixSenderData struct {
+	Request string `json:"request"`
+	Data    []struct {
+		Host  string          `json:"host"`
+		Key   string          `json:"key"`
+		Value json.RawMessage `json:"value"`
+		Clock int64           `json:"clock"`
+	} `json:"data"`
+}
+
+// ParseZabbixSenderData reads the items of the sender data request of
+// zabbix sender. The value of an item is the state of the nagios plugins,
+// the items with another value are returned as failed.
+func ParseZabbixSenderData(body []byte, now time.Time) ([]PassiveCheckResult, int, error) {
+	var data zabbixSenderData
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, 0, fmt.Errorf("invalid zabbix sender data: %w", err)
+	}
+	if data.Request != "sender data" {
+		return nil, 0, fmt.Errorf("unsupported zabbix request %q", data.Request)
+	}
+
+	results := make([]PassiveCheckResult, 0, len(data.Data))
+	failed := 0
+	for _, item := range data.Data {
+		value := strings.Trim(string(item.Value), `"`)
+		state, err := strconv.Atoi(value)
+		if err != nil || state < checkStateOK || state > checkStateUnknown || item.Host == "" || item.Key == "" {
+			failed++
+			continue
+		}
+		ts := now
+		if item.Clock > 0 {
+			ts = time.Unix(item.Clock, 0)
+		}
+		results = append(results, PassiveCheckResult{Host: item.Host, Service: item.Key, State: state, Time: ts})
+	}
+	return results, failed, nil
+}
@@ -0,0 +1,86 @@
+package rules
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNRDPCheckResults(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// the xml of send_nrdp
+	form := url.Values{
+		"cmd": {"submitcheck"},
+		"XMLDATA": {`<?xml version='1.0'?>
+<checkresults>
+  <checkresult type="service">
+    <hostname>db-1</hostname>
+    <servicename>Disk Usage</servicename>
+    <state>2</state>
+    <output>DISK CRITICAL - 97% used|/=97%;80;90</output>
+  </checkresult>
+  <checkresult type="host">
+    <hostname>db-1</hostname>
+    <state>0</state>
+    <output>PING OK</output>
+  </checkresult>
+</checkresults>`},
+	}
+	results, err := ParseNRDPCheckResults(form, now)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, PassiveCheckResult{Host: "db-1", Service: "Disk Usage", State: 2, Output: "DISK CRITICAL - 97% used", Time: now}, results[0])
+	assert.Equal(t, PassiveCheckResult{Host: "db-1", State: 0, Output: "PING OK", Time: now}, results[1])
+
+	form = url.Values{"JSONDATA": {`{"checkresults":[{"checkresult":{"type":"service"},"hostname":"web-1","servicename":"HTTP","state":"1","output":"slow"}]}`}}
+	results, err = ParseNRDPCheckResults(form, now)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, 1, results[0].State)
+
+	_, err = ParseNRDPCheckResults(url.Values{"JSONDATA": {`{"checkresults":[{"hostname":"web-1","state":"7"}]}`}}, now)
+	assert.Error(t, err)
+	_, err = ParseNRDPCheckResults(url.Values{"cmd": {"submitcmd"}}, now)
+	assert.Error(t, err)
+}
+
+func TestParseZabbixSenderData(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	results, failed, err := ParseZabbixSenderData([]byte(`{"request":"sender data","data":[
+		{"host":"db-1","key":"backup.status","value":"2","clock":1709294400},
+		{"host":"db-1","key":"replication.status","value":0},
+		{"host":"db-1","key":"free.space","value":"12.5"}
+	]}`), now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, failed)
+	require.Len(t, results, 2)
+	assert.Equal(t, PassiveCheckResult{Host: "db-1", Service: "backup.status", State: 2, Time: time.Unix(1709294400, 0)}, results[0])
+	assert.Equal(t, now, results[1].Time)
+
+	_, _, err = ParseZabbixSenderData([]byte(`{"request":"active checks"}`), now)
+	assert.Error(t, err)
+}
+
+func TestPassiveCheckAlert(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	alert := PassiveCheckResult{Host: "db-1", Service: "Disk Usage", State: 2, Output: "97% used", Time: now}.Alert(InboundSourceNagios)
+	assert.Equal(t, map[string]string{
+		"alertname":       "Disk Usage",
+		"host":            "db-1",
+		"service":         "Disk Usage",
+		"severity":        "critical",
+		"external_source": "nagios",
+	}, alert.Labels)
+	assert.Equal(t, "97% used", alert.Annotations["summary"])
+	assert.False(t, alert.Resolved)
+
+	alert = PassiveCheckResult{Host: "db-1", State: 0, Time: now}.Alert(InboundSourceNagios)
+	assert.Equal(t, "host check", alert.Labels["alertname"])
+	assert.Equal(t, "warning", alert.Labels["severity"])
+	assert.True(t, alert.Resolved)
+	assert.Equal(t, now, alert.EndsAt)
+}
@@ -0,0 +1,230 @@
+package rules
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+// postgresSchema holds the tables of the rules metadata, the same as the
+// sqlite tables with the postgres types
+var postgresSchema = []string{
+	`CREATE TABLE IF NOT EXISTS rules (
+		id BIGSERIAL PRIMARY KEY,
+		created_at TIMESTAMPTZ,
+		created_by TEXT,
+		updated_at TIMESTAMPTZ NOT NULL,
+		updated_by TEXT,
+		deleted INTEGER DEFAULT 0,
+		data TEXT NOT NULL,
+		org_id TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE TABLE IF NOT EXISTS planned_maintenance (
+		id BIGSERIAL PRIMARY KEY,
+		name TEXT NOT NULL,
+		description TEXT,
+		alert_ids TEXT,
+		schedule TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL,
+		created_by TEXT NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL,
+		updated_by TEXT NOT NULL,
+		org_id TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE TABLE IF NOT EXISTS alert_chart_snapshots (
+		id TEXT PRIMARY KEY,
+		png BYTEA NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_alert_chart_snapshots_created_at ON alert_chart_snapshots (created_at)`,
+	`CREATE TABLE IF NOT EXISTS template_snippets (
+		id BIGSERIAL PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		description TEXT NOT NULL DEFAULT '',
+		content TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL,
+		created_by TEXT NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL,
+		updated_by TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS rule_evaluators (
+		id TEXT PRIMARY KEY,
+		heartbeat_at TIMESTAMPTZ NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS rule_evaluations (
+		id BIGSERIAL PRIMARY KEY,
+		rule_id TEXT NOT NULL,
+		evaluated_at TIMESTAMPTZ NOT NULL,
+		duration_ms BIGINT NOT NULL,
+		query TEXT NOT NULL,
+		series BIGINT NOT NULL,
+		samples TEXT NOT NULL,
+		error TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_rule_evaluations_rule_id ON rule_evaluations (rule_id)`,
+	`CREATE TABLE IF NOT EXISTS rule_state_history_retention (
+		id INTEGER PRIMARY KEY,
+		detail_seconds BIGINT NOT NULL,
+		transitions_seconds BIGINT NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS rule_alert_acks (
+		id BIGSERIAL PRIMARY KEY,
+		rule_id TEXT NOT NULL,
+		fingerprint TEXT NOT NULL,
+		acked_at TIMESTAMPTZ NOT NULL,
+		acked_by TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_rule_alert_acks_acked_at ON rule_alert_acks (acked_at)`,
+	`CREATE TABLE IF NOT EXISTS rule_notifications (
+		id BIGSERIAL PRIMARY KEY,
+		rule_id TEXT NOT NULL,
+		alert_name TEXT NOT NULL,
+		fingerprint TEXT NOT NULL,
+		state TEXT NOT NULL,
+		status TEXT NOT NULL,
+		receivers TEXT NOT NULL,
+		labels TEXT NOT NULL,
+		sent_at TIMESTAMPTZ NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_rule_notifications_sent_at ON rule_notifications (sent_at)`,
+	`CREATE TABLE IF NOT EXISTS rule_series_activity (
+		rule_id TEXT PRIMARY KEY,
+		empty_since TIMESTAMPTZ,
+		last_series_at TIMESTAMPTZ,
+		auto_disabled_at TIMESTAMPTZ
+	)`,
+	`CREATE TABLE IF NOT EXISTS incidents (
+		id BIGSERIAL PRIMARY KEY,
+		title TEXT NOT NULL,
+		status TEXT NOT NULL,
+		severity TEXT NOT NULL,
+		assignee TEXT NOT NULL DEFAULT '',
+		labels TEXT NOT NULL,
+		auto BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMPTZ NOT NULL,
+		created_by TEXT NOT NULL DEFAULT '',
+		updated_at TIMESTAMPTZ NOT NULL,
+		resolved_at TIMESTAMPTZ,
+		org_id TEXT NOT NULL DEFAULT ''
+	)`,
+	// the incidents were stored without their org at first
+	`ALTER TABLE incidents ADD COLUMN IF NOT EXISTS org_id TEXT NOT NULL DEFAULT ''`,
+	`CREATE INDEX IF NOT EXISTS idx_incidents_status ON incidents (status)`,
+	`CREATE TABLE IF NOT EXISTS incident_alerts (
+		incident_id BIGINT NOT NULL REFERENCES incidents(id) ON DELETE CASCADE,
+		rule_id TEXT NOT NULL,
+		rule_name TEXT NOT NULL,
+		fingerprint TEXT NOT NULL,
+		labels TEXT NOT NULL,
+		state TEXT NOT NULL,
+		added_at TIMESTAMPTZ NOT NULL,
+		resolved_at TIMESTAMPTZ,
+		PRIMARY KEY (incident_id, rule_id, fingerprint)
+	)`,
+	`CREATE TABLE IF NOT EXISTS change_events (
+		id BIGSERIAL PRIMARY KEY,
+		kind TEXT NOT NULL,
+		source TEXT NOT NULL,
+		title TEXT NOT NULL,
+		description TEXT NOT NULL,
+		labels TEXT NOT NULL,
+		timestamp TIMESTAMPTZ NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_change_events_timestamp ON change_events (timestamp)`,
+	`CREATE TABLE IF NOT EXISTS alert_share_links (
+		id BIGSERIAL PRIMARY KEY,
+		token_hash TEXT NOT NULL UNIQUE,
+		rule_id TEXT NOT NULL,
+		fingerprint TEXT NOT NULL DEFAULT '',
+		org_id TEXT NOT NULL DEFAULT '',
+		expires_at TIMESTAMPTZ NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL,
+		created_by TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_alert_share_links_rule_id ON alert_share_links (rule_id)`,
+	`CREATE TABLE IF NOT EXISTS rule_query_costs (
+		rule_id TEXT NOT NULL,
+		hour TIMESTAMPTZ NOT NULL,
+		evaluations BIGINT NOT NULL,
+		read_rows BIGINT NOT NULL,
+		read_bytes BIGINT NOT NULL,
+		PRIMARY KEY (rule_id, hour)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_rule_query_costs_hour ON rule_query_costs (hour)`,
+	`CREATE TABLE IF NOT EXISTS custom_units (
+		name TEXT PRIMARY KEY,
+		base TEXT NOT NULL,
+		factor DOUBLE PRECISION NOT NULL,
+		symbol TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL,
+		created_by TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS org_format_settings (
+		org_id TEXT PRIMARY KEY,
+		data TEXT NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL,
+		updated_by TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS rule_calendars (
+		id BIGSERIAL PRIMARY KEY,
+		org_id TEXT NOT NULL DEFAULT '',
+		name TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		events TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL,
+		created_by TEXT NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL,
+		updated_by TEXT NOT NULL,
+		UNIQUE (org_id, name)
+	)`,
+	`CREATE TABLE IF NOT EXISTS service_catalog (
+		id BIGSERIAL PRIMARY KEY,
+		org_id TEXT NOT NULL DEFAULT '',
+		service TEXT NOT NULL,
+		team TEXT NOT NULL DEFAULT '',
+		channels TEXT NOT NULL,
+		runbook TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL,
+		created_by TEXT NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL,
+		updated_by TEXT NOT NULL,
+		UNIQUE (org_id, service)
+	)`,
+	`CREATE TABLE IF NOT EXISTS alert_views (
+		id BIGSERIAL PRIMARY KEY,
+		org_id TEXT NOT NULL DEFAULT '',
+		name TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		shared BOOLEAN NOT NULL DEFAULT FALSE,
+		query TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL,
+		created_by TEXT NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL,
+		updated_by TEXT NOT NULL
+	)`,
+}
+
+// OpenPostgres connects to the postgres database of the rules metadata and
+// creates its tables. The replicas of query-service sharing the database
+// share the rules, the maintenances and the alert state, instead of each
+// depending on its local sqlite file.
+func OpenPostgres(dsn string) (*sqlx.DB, error) {
+	db, err := sqlx.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the rules database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to the rules database: %w", err)
+	}
+	for _, stmt := range postgresSchema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("error in creating the rules tables: %w", err)
+		}
+	}
+	return db, nil
+}
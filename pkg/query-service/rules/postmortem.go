@@ -0,0 +1,406 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// The formats of the timeline exports
+const (
+	TimelineMarkdown = "markdown"
+	TimelineJSON     = "json"
+)
+
+// The kinds of the entries of a timeline export
+const (
+	TimelineIncident     = "incident"
+	TimelineState        = "state"
+	TimelineNotification = "notification"
+	TimelineAck          = "ack"
+	TimelineSilence      = "silence"
+	TimelineDeploy       = "deploy"
+)
+
+// TimelineEntry is an event of the timeline of an alert or an incident
+type TimelineEntry struct {
+	Time     time.Time         `json:"time"`
+	Kind     string            `json:"kind"`
+	RuleID   string            `json:"ruleId,omitempty"`
+	RuleName string            `json:"ruleName,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Text     string            `json:"text"`
+}
+
+// QuerySnapshot is the query and the condition of a rule of the timeline
+// at the time of the export, with the chart rendered when its alert
+// started firing while the chart is kept
+type QuerySnapshot struct {
+	RuleID           string         `json:"ruleId"`
+	RuleName         string         `json:"ruleName"`
+	EvalWindow       Duration       `json:"evalWindow,omitempty"`
+	Frequency        Duration       `json:"frequency,omitempty"`
+	Condition        *RuleCondition `json:"condition,omitempty"`
+	ChartSnapshotURL string         `json:"chartSnapshotUrl,omitempty"`
+}
+
+// TimelineExport is the timeline of a resolved alert or incident for the
+// postmortems
+type TimelineExport struct {
+	Title      string          `json:"title"`
+	IncidentID int64           `json:"incidentId,omitempty"`
+	Start      time.Time       `json:"start"`
+	End        time.Time       `json:"end"`
+	Alerts     []IncidentAlert `json:"alerts"`
+	Entries    []TimelineEntry `json:"entries"`
+	Queries    []QuerySnapshot `json:"queries"`
+}
+
+// timelineSources are the records the timeline is built from
+type timelineSources struct {
+	incident      *Incident
+	alerts        []IncidentAlert
+	changes       []v3.RuleStateHistory
+	notifications []NotificationRecord
+	acks          []AlertAck
+	maintenances  []PlannedMaintenance
+	deploys       []ChangeEvent
+	start, end    time.Time
+}
+
+// buildTimeline merges the records of the alerts, oldest first. The state
+// history holds the labels of the query result, an alert matches the
+// changes of its rule whose labels it has. The deploys are the ones of the
+// services of the alerts made from DefaultDeployWindow before the start to
+// the end.
+func buildTimeline(title string, src timelineSources) *TimelineExport {
+	export := &TimelineExport{Title: title, Alerts: src.alerts, Entries: []TimelineEntry{}, Queries: []QuerySnapshot{}}
+	add := func(e TimelineEntry) {
+		export.Entries = append(export.Entries, e)
+	}
+
+	if inc := src.incident; inc != nil {
+		export.IncidentID = inc.Id
+		add(TimelineEntry{Time: inc.CreatedAt, Kind: TimelineIncident, Text: fmt.Sprintf("incident opened with severity %s", inc.Severity)})
+		if inc.ResolvedAt != nil {
+			add(TimelineEntry{Time: *inc.ResolvedAt, Kind: TimelineIncident, Text: "incident resolved"})
+		}
+	}
+
+	// the times the alerts changed state, the maintenances silencing the
+	// rules are looked up at them
+	ruleTimes := map[string][]time.Time{}
+	for _, c := range src.changes {
+		lbls := map[string]string{}
+		if c.Labels != "" {
+			if err := json.Unmarshal([]byte(c.Labels), &lbls); err != nil {
+				continue
+			}
+		}
+		for _, a := range src.alerts {
+			if a.RuleID != c.RuleID || !containsLabels(a.Labels, lbls) {
+				continue
+			}
+			ts := time.UnixMilli(c.UnixMilli).UTC()
+			ruleTimes[c.RuleID] = append(ruleTimes[c.RuleID], ts)
+			add(TimelineEntry{Time: ts, Kind: TimelineState, RuleID: c.RuleID, RuleName: c.RuleName, Labels: lbls,
+				Text: fmt.Sprintf("%s is %s with value %g", c.RuleName, c.State, c.Value)})
+			break
+		}
+	}
+
+	for _, n := range src.notifications {
+		for _, a := range src.alerts {
+			if a.RuleID != n.RuleId || a.Fingerprint != n.Fingerprint {
+				continue
+			}
+			text := fmt.Sprintf("%s notification %s", n.State, n.Status)
+			if len(n.Receivers) > 0 {
+				text += " to " + strings.Join(n.Receivers, ", ")
+			}
+			add(TimelineEntry{Time: n.SentAt.UTC(), Kind: TimelineNotification, RuleID: n.RuleId, RuleName: a.RuleName, Text: text})
+			break
+		}
+	}
+
+	for _, ack := range src.acks {
+		if ack.AckedAt.After(src.end) {
+			continue
+		}
+		for _, a := range src.alerts {
+			if a.RuleID != ack.RuleID || a.Fingerprint != ack.Fingerprint {
+				continue
+			}
+			by := ack.AckedBy
+			if by == "" {
+				by = "unknown user"
+			}
+			add(TimelineEntry{Time: ack.AckedAt.UTC(), Kind: TimelineAck, RuleID: ack.RuleID, RuleName: a.RuleName, Text: "acknowledged by " + by})
+			break
+		}
+	}
+
+	ruleNames := map[string]string{}
+	for _, a := range src.alerts {
+		ruleNames[a.RuleID] = a.RuleName
+	}
+	for i := range src.maintenances {
+		mw := &src.maintenances[i]
+		for ruleID, name := range ruleNames {
+			for _, ts := range ruleTimes[ruleID] {
+				if mw.shouldSkip(ruleID, ts) {
+					add(TimelineEntry{Time: ts.UTC(), Kind: TimelineSilence, RuleID: ruleID, RuleName: name, Text: fmt.Sprintf("silenced by maintenance %s", mw.Name)})
+					break
+				}
+			}
+		}
+	}
+
+	services := map[string]bool{}
+	for _, a := range src.alerts {
+		if s := serviceName(labels.FromMap(a.Labels)); s != "" {
+			services[s] = true
+		}
+	}
+	for _, d := range src.deploys {
+		if d.Kind != ChangeDeployment || !services[serviceName(labels.FromMap(d.Labels))] {
+			continue
+		}
+		if d.Timestamp.Before(src.start.Add(-DefaultDeployWindow)) || d.Timestamp.After(src.end) {
+			continue
+		}
+		add(TimelineEntry{Time: d.Timestamp.UTC(), Kind: TimelineDeploy, Labels: d.Labels, Text: deployDescription(&d)})
+	}
+
+	sort.SliceStable(export.Entries, func(i, j int) bool {
+		return export.Entries[i].Time.Before(export.Entries[j].Time)
+	})
+	// the timeline spans its events, the range the records were read in
+	// when there are none
+	export.Start, export.End = src.start.UTC(), src.end.UTC()
+	if n := len(export.Entries); n > 0 {
+		export.Start, export.End = export.Entries[0].Time, export.Entries[n-1].Time
+	}
+	return export
+}
+
+// Markdown renders the timeline for a postmortem document
+func (e *TimelineExport) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Timeline: %s\n\n", e.Title)
+	fmt.Fprintf(&b, "- Start: %s\n", e.Start.Format(time.RFC3339))
+	fmt.Fprintf(&b, "- End: %s\n", e.End.Format(time.RFC3339))
+	fmt.Fprintf(&b, "- Duration: %s\n", e.End.Sub(e.Start).Round(time.Second))
+
+	b.WriteString("\n## Alerts\n\n| Rule | Fingerprint | Labels |\n| --- | --- | --- |\n")
+	for _, a := range e.Alerts {
+		fmt.Fprintf(&b, "| %s | %d | %s |\n", markdownCell(a.RuleName), a.Fingerprint, markdownCell(labelsText(a.Labels)))
+	}
+
+	b.WriteString("\n## Events\n\n| Time (UTC) | Kind | Event |\n| --- | --- | --- |\n")
+	for _, entry := range e.Entries {
+		fmt.Fprintf(&b, "| %s | %s | %s |\n", entry.Time.UTC().Format("2006-01-02 15:04:05"), entry.Kind, markdownCell(entry.Text))
+	}
+
+	if len(e.Queries) > 0 {
+		b.WriteString("\n## Queries\n")
+		for _, q := range e.Queries {
+			fmt.Fprintf(&b, "\n### %s\n\n", q.RuleName)
+			if q.EvalWindow != 0 {
+				fmt.Fprintf(&b, "Evaluated every %s over %s.\n\n", time.Duration(q.Frequency), time.Duration(q.EvalWindow))
+			}
+			if q.ChartSnapshotURL != "" {
+				fmt.Fprintf(&b, "![chart](%s)\n\n", q.ChartSnapshotURL)
+			}
+			if q.Condition != nil {
+				condition, _ := json.MarshalIndent(q.Condition, "", "  ")
+				fmt.Fprintf(&b, "```json\n%s\n```\n", condition)
+			}
+		}
+	}
+	return b.String()
+}
+
+// Encode returns the timeline in the format with its media type, markdown
+// when the format is not set
+func (e *TimelineExport) Encode(format string) ([]byte, string, error) {
+	switch format {
+	case "", TimelineMarkdown:
+		return []byte(e.Markdown()), "text/markdown; charset=utf-8", nil
+	case TimelineJSON:
+		data, err := json.MarshalIndent(e, "", "  ")
+		return data, "application/json", err
+	}
+	return nil, "", fmt.Errorf("unknown timeline format %q, expected %s or %s", format, TimelineMarkdown, TimelineJSON)
+}
+
+// markdownCell escapes the text for a markdown table cell
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(s)
+}
+
+// timelineExport fetches the records of the alerts between start and end
+// and builds their timeline
+func (m *Manager) timelineExport(ctx context.Context, title string, incident *Incident, alerts []IncidentAlert, start, end time.Time) (*TimelineExport, *model.ApiError) {
+	if m.reader == nil {
+		return nil, &model.ApiError{Typ: model.ErrorUnavailable, Err: fmt.Errorf("the state history is not stored")}
+	}
+	src := timelineSources{incident: incident, alerts: alerts, start: start, end: end}
+
+	changes, err := m.reader.ReadRuleStateChanges(ctx, start.UnixMilli(), end.UnixMilli())
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	src.changes = changes
+
+	rules := map[string]bool{}
+	for _, a := range alerts {
+		if rules[a.RuleID] {
+			continue
+		}
+		rules[a.RuleID] = true
+		records, err := m.ruleDB.GetNotifications(ctx, NotificationFilter{Start: start, End: end, RuleId: a.RuleID, Limit: maxExportRows})
+		if err != nil {
+			return nil, newApiErrorInternal(err)
+		}
+		src.notifications = append(src.notifications, records...)
+	}
+	if src.acks, err = m.ruleDB.GetAlertAcks(ctx, start); err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	if src.maintenances, err = m.ruleDB.GetAllPlannedMaintenance(ctx); err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	if src.deploys, err = m.ruleDB.GetChangeEvents(ctx, start.Add(-DefaultDeployWindow), end); err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+
+	export := buildTimeline(title, src)
+	for ruleID := range rules {
+		if q := m.querySnapshot(ctx, ruleID, alerts); q != nil {
+			export.Queries = append(export.Queries, *q)
+		}
+	}
+	sort.Slice(export.Queries, func(i, j int) bool {
+		return export.Queries[i].RuleID < export.Queries[j].RuleID
+	})
+	return export, nil
+}
+
+// querySnapshot returns the query of the stored rule and the chart of its
+// alert when the alert is still held, nil when the rule was deleted
+func (m *Manager) querySnapshot(ctx context.Context, ruleID string, alerts []IncidentAlert) *QuerySnapshot {
+	stored, err := m.ruleDB.GetStoredRule(ctx, ruleID)
+	if err != nil {
+		return nil
+	}
+	rule, err := ParsePostableRule([]byte(stored.Data))
+	if err != nil {
+		return nil
+	}
+	q := &QuerySnapshot{RuleID: ruleID, RuleName: rule.AlertName, EvalWindow: rule.EvalWindow, Frequency: rule.Frequency, Condition: rule.RuleCondition}
+
+	m.mtx.RLock()
+	active, ok := m.rules[ruleID]
+	m.mtx.RUnlock()
+	if !ok {
+		return q
+	}
+	for _, a := range active.ActiveAlerts() {
+		if a.SnapshotURL == "" {
+			continue
+		}
+		for _, ia := range alerts {
+			if ia.RuleID == ruleID && ia.Fingerprint == a.Labels.Hash() {
+				q.ChartSnapshotURL = a.SnapshotURL
+				return q
+			}
+		}
+	}
+	return q
+}
+
+// AlertTimelineExport returns the timeline of the alert of the rule with
+// the fingerprint between start and end. The labels of the alert are read
+// from its notifications, or from the alert when it is still active.
+func (m *Manager) AlertTimelineExport(ctx context.Context, ruleID string, fingerprint uint64, start, end time.Time) (*TimelineExport, *model.ApiError) {
+	if !end.After(start) {
+		return nil, newApiErrorBadData(fmt.Errorf("end must be after start"))
+	}
+	alert := IncidentAlert{RuleID: ruleID, Fingerprint: fingerprint}
+	found := false
+
+	m.mtx.RLock()
+	if rule, ok := m.rules[ruleID]; ok {
+		alert.RuleName = rule.Name()
+		for _, a := range rule.ActiveAlerts() {
+			if a.Labels.Hash() == fingerprint {
+				alert.Labels, alert.State, alert.AddedAt, found = a.Labels.Map(), a.State.String(), a.ActiveAt, true
+				break
+			}
+		}
+	}
+	m.mtx.RUnlock()
+
+	if !found {
+		records, err := m.ruleDB.GetNotifications(ctx, NotificationFilter{Start: start, End: end, RuleId: ruleID, Limit: maxExportRows})
+		if err != nil {
+			return nil, newApiErrorInternal(err)
+		}
+		for _, n := range records {
+			if n.Fingerprint != fingerprint {
+				continue
+			}
+			lbls := map[string]string{}
+			if err := json.Unmarshal([]byte(n.Labels), &lbls); err != nil {
+				continue
+			}
+			alert.Labels, alert.State, found = lbls, n.State, true
+			if alert.RuleName == "" {
+				alert.RuleName = n.AlertName
+			}
+			if alert.AddedAt.IsZero() || n.SentAt.Before(alert.AddedAt) {
+				alert.AddedAt = n.SentAt
+			}
+		}
+	}
+	if !found {
+		return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("alert %d of rule %s has no activity in the range", fingerprint, ruleID)}
+	}
+
+	title := alert.RuleName
+	if title == "" {
+		title = "rule " + ruleID
+	}
+	if s := serviceName(labels.FromMap(alert.Labels)); s != "" {
+		title += " on " + s
+	}
+	return m.timelineExport(ctx, title, nil, []IncidentAlert{alert}, start, end)
+}
+
+// IncidentTimelineExport returns the timeline of the incident and of its
+// alerts
+func (m *Manager) IncidentTimelineExport(ctx context.Context, id int64) (*TimelineExport, *model.ApiError) {
+	incident, apiErr := m.GetIncident(ctx, id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	start := incident.CreatedAt
+	for _, a := range incident.Alerts {
+		if a.AddedAt.Before(start) {
+			start = a.AddedAt
+		}
+	}
+	end := time.Now()
+	if incident.ResolvedAt != nil {
+		end = *incident.ResolvedAt
+	}
+	return m.timelineExport(ctx, incident.Title, incident, incident.Alerts, start, end)
+}
@@ -0,0 +1,103 @@
+package rules
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func TestBuildTimeline(t *testing.T) {
+	at := func(min int) time.Time { return time.Date(2026, 10, 15, 12, min, 0, 0, time.UTC) }
+	alert := IncidentAlert{
+		RuleID:      "7",
+		RuleName:    "checkout latency",
+		Fingerprint: 42,
+		Labels:      map[string]string{"service_name": "checkout", "alertname": "checkout latency"},
+	}
+	resolved := at(40)
+	src := timelineSources{
+		incident: &Incident{Id: 3, Severity: "critical", CreatedAt: at(6), ResolvedAt: &resolved},
+		alerts:   []IncidentAlert{alert},
+		changes: []v3.RuleStateHistory{
+			{RuleID: "7", RuleName: "checkout latency", State: "firing", UnixMilli: at(5).UnixMilli(), Labels: `{"service_name":"checkout"}`, Value: 812},
+			// another series of the rule
+			{RuleID: "7", RuleName: "checkout latency", State: "firing", UnixMilli: at(5).UnixMilli(), Labels: `{"service_name":"cart"}`},
+			{RuleID: "7", RuleName: "checkout latency", State: "normal", UnixMilli: at(38).UnixMilli(), Labels: `{"service_name":"checkout"}`, Value: 120},
+		},
+		notifications: []NotificationRecord{
+			{RuleId: "7", Fingerprint: 42, State: "firing", Status: "delivered", Receivers: []string{"payments-slack"}, SentAt: at(6)},
+			{RuleId: "7", Fingerprint: 43, State: "firing", Status: "delivered", SentAt: at(6)},
+		},
+		acks: []AlertAck{
+			{RuleID: "7", Fingerprint: 42, AckedAt: at(10), AckedBy: "oncall@example.com"},
+			{RuleID: "7", Fingerprint: 42, AckedAt: at(50)},
+		},
+		maintenances: []PlannedMaintenance{
+			{Name: "db migration", AlertIds: &AlertIds{"7"}, Schedule: &Schedule{Timezone: "UTC", StartTime: at(30), EndTime: at(45)}},
+			{Name: "other rule", AlertIds: &AlertIds{"8"}, Schedule: &Schedule{Timezone: "UTC", StartTime: at(0), EndTime: at(59)}},
+		},
+		deploys: []ChangeEvent{
+			{Kind: ChangeDeployment, Title: "deploy checkout v2", Source: "github", Labels: map[string]string{"service_name": "checkout"}, Timestamp: at(1)},
+			{Kind: ChangeDeployment, Title: "deploy cart v9", Labels: map[string]string{"service_name": "cart"}, Timestamp: at(2)},
+			{Kind: ChangeConfig, Title: "flag flip", Labels: map[string]string{"service_name": "checkout"}, Timestamp: at(3)},
+		},
+		start: at(5),
+		end:   at(40),
+	}
+
+	export := buildTimeline("checkout outage", src)
+	assert.Equal(t, int64(3), export.IncidentID)
+	kinds := []string{}
+	texts := []string{}
+	for _, e := range export.Entries {
+		kinds = append(kinds, e.Kind)
+		texts = append(texts, e.Text)
+	}
+	assert.Equal(t, []string{TimelineDeploy, TimelineState, TimelineIncident, TimelineNotification, TimelineAck, TimelineState, TimelineSilence, TimelineIncident}, kinds)
+	assert.Equal(t, []string{
+		"deploy checkout v2 from github",
+		"checkout latency is firing with value 812",
+		"incident opened with severity critical",
+		"firing notification delivered to payments-slack",
+		"acknowledged by oncall@example.com",
+		"checkout latency is normal with value 120",
+		"silenced by maintenance db migration",
+		"incident resolved",
+	}, texts)
+	assert.Equal(t, at(1), export.Start)
+	assert.Equal(t, at(40), export.End)
+
+	md := export.Markdown()
+	assert.Contains(t, md, "# Timeline: checkout outage")
+	assert.Contains(t, md, "| 2026-10-15 12:10:00 | ack | acknowledged by oncall@example.com |")
+	assert.Contains(t, md, "| checkout latency | 42 | alertname=checkout latency, service_name=checkout |")
+}
+
+func TestTimelineExportEncode(t *testing.T) {
+	export := &TimelineExport{
+		Title:   "a | b",
+		Entries: []TimelineEntry{{Time: time.Unix(0, 0).UTC(), Kind: TimelineState, Text: "line one\nline two"}},
+		Queries: []QuerySnapshot{{RuleName: "latency", EvalWindow: Duration(5 * time.Minute), Frequency: Duration(time.Minute), Condition: &RuleCondition{}}},
+	}
+
+	data, contentType, err := export.Encode("")
+	require.NoError(t, err)
+	assert.Equal(t, "text/markdown; charset=utf-8", contentType)
+	assert.Contains(t, string(data), "| 1970-01-01 00:00:00 | state | line one line two |")
+	assert.Contains(t, string(data), "Evaluated every 1m0s over 5m0s.")
+	assert.Contains(t, string(data), "```json\n{")
+
+	data, contentType, err = export.Encode(TimelineJSON)
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", contentType)
+	decoded := TimelineExport{}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, "a | b", decoded.Title)
+
+	_, _, err = export.Encode("pdf")
+	assert.Error(t, err)
+}
@@ -0,0 +1,298 @@
+package rules
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+const (
+	// maxProfileHistory is the number of evaluation durations kept for
+	// every rule
+	maxProfileHistory = 60
+	// DefaultSlowestRulesLimit is the number of rules returned by the
+	// profile api when the request does not set a limit
+	DefaultSlowestRulesLimit = 10
+	// DefaultProfileWait is how long a profile request waits for the next
+	// evaluation of the rule
+	DefaultProfileWait = 5 * time.Minute
+	// MaxProfileWait caps the wait of a profile request
+	MaxProfileWait = 15 * time.Minute
+)
+
+var (
+	errProfileInProgress = errors.New("a profile of the rule is already in progress")
+	errProfileTimeout    = errors.New("the rule was not evaluated in time")
+)
+
+// RuleProfile is the recent evaluation time of a rule
+type RuleProfile struct {
+	RuleId      string   `json:"ruleId"`
+	RuleName    string   `json:"ruleName"`
+	RuleType    RuleType `json:"ruleType"`
+	Evaluations int      `json:"evaluations"`
+	LastMs      int64    `json:"lastMs"`
+	AvgMs       int64    `json:"avgMs"`
+	MaxMs       int64    `json:"maxMs"`
+	// Series is the number of series returned on the last evaluation
+	Series int `json:"series"`
+	// QueryFingerprints identify the queries of the rule by name with
+	// their literals removed, rules running the same query share them
+	QueryFingerprints map[string]string `json:"queryFingerprints"`
+}
+
+// profileRequest is a cpu profile waiting for the next evaluation of a
+// rule
+type profileRequest struct {
+	buf  bytes.Buffer
+	done chan error
+}
+
+// evalProfiler keeps the recent evaluation durations of the rules and
+// runs the cpu profiles requested for a single evaluation
+type evalProfiler struct {
+	mtx       sync.Mutex
+	durations map[string][]time.Duration
+	pending   map[string]*profileRequest
+}
+
+func newEvalProfiler() *evalProfiler {
+	return &evalProfiler{
+		durations: map[string][]time.Duration{},
+		pending:   map[string]*profileRequest{},
+	}
+}
+
+func (p *evalProfiler) record(ruleID string, d time.Duration) {
+	if p == nil {
+		return
+	}
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	durations := append(p.durations[ruleID], d)
+	if len(durations) > maxProfileHistory {
+		durations = durations[len(durations)-maxProfileHistory:]
+	}
+	p.durations[ruleID] = durations
+}
+
+func (p *evalProfiler) forget(ruleID string) {
+	if p == nil {
+		return
+	}
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	delete(p.durations, ruleID)
+}
+
+// begin labels the goroutine evaluating the rule so that cpu profiles
+// can be filtered by rule, and starts the cpu profile requested for the
+// rule. The returned func stops the profile.
+func (p *evalProfiler) begin(ctx context.Context, rule Rule) (context.Context, func()) {
+	ctx = pprof.WithLabels(ctx, pprof.Labels("rule_id", rule.ID(), "rule_name", rule.Name()))
+	pprof.SetGoroutineLabels(ctx)
+	if p == nil {
+		return ctx, func() {}
+	}
+
+	p.mtx.Lock()
+	req, ok := p.pending[rule.ID()]
+	if ok {
+		delete(p.pending, rule.ID())
+	}
+	p.mtx.Unlock()
+	if !ok {
+		return ctx, func() {}
+	}
+
+	if err := pprof.StartCPUProfile(&req.buf); err != nil {
+		req.done <- fmt.Errorf("failed to start the cpu profile: %w", err)
+		return ctx, func() {}
+	}
+	return ctx, func() {
+		pprof.StopCPUProfile()
+		req.done <- nil
+	}
+}
+
+// profile waits for the next evaluation of the rule and returns the cpu
+// profile taken during it
+func (p *evalProfiler) profile(ctx context.Context, ruleID string, wait time.Duration) ([]byte, error) {
+	req := &profileRequest{done: make(chan error, 1)}
+
+	p.mtx.Lock()
+	if _, ok := p.pending[ruleID]; ok {
+		p.mtx.Unlock()
+		return nil, errProfileInProgress
+	}
+	p.pending[ruleID] = req
+	p.mtx.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case err := <-req.done:
+		if err != nil {
+			return nil, err
+		}
+		return req.buf.Bytes(), nil
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+
+	p.mtx.Lock()
+	if p.pending[ruleID] == req {
+		delete(p.pending, ruleID)
+		p.mtx.Unlock()
+		return nil, errProfileTimeout
+	}
+	p.mtx.Unlock()
+
+	// the evaluation started meanwhile, wait for its profile
+	if err := <-req.done; err != nil {
+		return nil, err
+	}
+	return req.buf.Bytes(), nil
+}
+
+func (p *evalProfiler) profiles(rules []Rule) []RuleProfile {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	profiles := []RuleProfile{}
+	for _, rule := range rules {
+		durations := p.durations[rule.ID()]
+		if len(durations) == 0 {
+			continue
+		}
+		profile := RuleProfile{
+			RuleId:            rule.ID(),
+			RuleName:          rule.Name(),
+			RuleType:          rule.Type(),
+			Evaluations:       len(durations),
+			LastMs:            durations[len(durations)-1].Milliseconds(),
+			QueryFingerprints: ruleQueryFingerprints(rule),
+		}
+		var total time.Duration
+		for _, d := range durations {
+			total += d
+			if d.Milliseconds() > profile.MaxMs {
+				profile.MaxMs = d.Milliseconds()
+			}
+		}
+		profile.AvgMs = (total / time.Duration(len(durations))).Milliseconds()
+		if r, ok := rule.(interface{ SamplesReturned() int }); ok {
+			profile.Series = r.SamplesReturned()
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles
+}
+
+var (
+	// sqlStringLiteral matches the string literals of clickhouse, double
+	// quotes are identifiers
+	sqlStringLiteral = regexp.MustCompile(`'(?:[^'\\]|\\.)*'`)
+	// promStringLiteral matches the string literals of promql
+	promStringLiteral = regexp.MustCompile(`'(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*"`)
+	queryNumber       = regexp.MustCompile(`\b\d+(?:\.\d+)?`)
+	querySpaces       = regexp.MustCompile(`\s+`)
+)
+
+// queryFingerprint hashes the query with its literals replaced, the
+// queries that only differ in their time range or filter values share a
+// fingerprint. Strings are only replaced when literals is set.
+func queryFingerprint(query string, literals *regexp.Regexp) string {
+	normalized := query
+	if literals != nil {
+		normalized = literals.ReplaceAllString(normalized, "?")
+	}
+	normalized = queryNumber.ReplaceAllString(normalized, "?")
+	normalized = strings.TrimSpace(querySpaces.ReplaceAllString(normalized, " "))
+	h := fnv.New64a()
+	h.Write([]byte(strings.ToLower(normalized)))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+func ruleQueryFingerprints(rule Rule) map[string]string {
+	fingerprints := map[string]string{}
+	cond := rule.Condition()
+	if cond == nil || cond.CompositeQuery == nil {
+		return fingerprints
+	}
+	cq := cond.CompositeQuery
+	for name, q := range cq.ClickHouseQueries {
+		fingerprints[name] = queryFingerprint(q.Query, sqlStringLiteral)
+	}
+	for name, q := range cq.PromQueries {
+		fingerprints[name] = queryFingerprint(q.Query, promStringLiteral)
+	}
+	for name, q := range cq.BuilderQueries {
+		b, err := json.Marshal(q)
+		if err != nil {
+			continue
+		}
+		fingerprints[name] = queryFingerprint(string(b), nil)
+	}
+	return fingerprints
+}
+
+// SlowestRules returns the rules with the longest average evaluation time
+// over their recent evaluations
+func (m *Manager) SlowestRules(limit int) []RuleProfile {
+	if limit <= 0 {
+		limit = DefaultSlowestRulesLimit
+	}
+	profiles := m.opts.profiler.profiles(m.Rules())
+	sort.Slice(profiles, func(i, j int) bool {
+		if profiles[i].AvgMs != profiles[j].AvgMs {
+			return profiles[i].AvgMs > profiles[j].AvgMs
+		}
+		return profiles[i].RuleId < profiles[j].RuleId
+	})
+	if len(profiles) > limit {
+		profiles = profiles[:limit]
+	}
+	return profiles
+}
+
+// ProfileRule takes a cpu profile during the next evaluation of the rule.
+// The profile covers the whole process, the samples of the rule carry its
+// rule_id label.
+func (m *Manager) ProfileRule(ctx context.Context, id string, wait time.Duration) ([]byte, *model.ApiError) {
+	m.mtx.RLock()
+	_, ok := m.rules[id]
+	m.mtx.RUnlock()
+	if !ok {
+		return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("rule %s is not running", id)}
+	}
+	if wait <= 0 {
+		wait = DefaultProfileWait
+	}
+	if wait > MaxProfileWait {
+		wait = MaxProfileWait
+	}
+
+	profile, err := m.opts.profiler.profile(ctx, id, wait)
+	switch {
+	case errors.Is(err, errProfileInProgress):
+		return nil, &model.ApiError{Typ: model.ErrorConflict, Err: err}
+	case errors.Is(err, errProfileTimeout):
+		return nil, &model.ApiError{Typ: model.ErrorTimeout, Err: err}
+	case err != nil:
+		return nil, newApiErrorInternal(err)
+	}
+	return profile, nil
+}
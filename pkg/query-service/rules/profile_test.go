@@ -0,0 +1,82 @@
+package rules
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func TestQueryFingerprint(t *testing.T) {
+	a := queryFingerprint("SELECT count() FROM signoz_traces.distributed_signoz_index_v2 WHERE serviceName = 'api' AND timestamp > 1700000000", sqlStringLiteral)
+	b := queryFingerprint("SELECT  count() FROM signoz_traces.distributed_signoz_index_v2\nWHERE serviceName = 'web' AND timestamp > 1700000600", sqlStringLiteral)
+	c := queryFingerprint("SELECT count() FROM signoz_traces.distributed_signoz_index_v2 WHERE \"serviceName\" = 'api'", sqlStringLiteral)
+	assert.Equal(t, a, b)
+	assert.NotEqual(t, a, c)
+
+	assert.Equal(t,
+		queryFingerprint(`sum(rate(http_requests_total{service="api"}[5m]))`, promStringLiteral),
+		queryFingerprint(`sum(rate(http_requests_total{service="web"}[1m]))`, promStringLiteral),
+	)
+}
+
+func TestSlowestRules(t *testing.T) {
+	newRule := func(id string) *ThresholdRule {
+		r, err := NewThresholdRule(id, reloadTestRule("rule "+id, v3.QueryTypeClickHouseSQL), ThresholdRuleOpts{}, nil, nil)
+		require.NoError(t, err)
+		return r
+	}
+	manager := &Manager{
+		rules: map[string]Rule{"1": newRule("1"), "2": newRule("2"), "3": newRule("3")},
+		opts:  &ManagerOptions{profiler: newEvalProfiler()},
+	}
+	manager.opts.profiler.record("1", 100*time.Millisecond)
+	manager.opts.profiler.record("2", 3*time.Second)
+	manager.opts.profiler.record("2", time.Second)
+	manager.opts.profiler.record("3", 500*time.Millisecond)
+
+	profiles := manager.SlowestRules(2)
+	require.Len(t, profiles, 2)
+	assert.Equal(t, "2", profiles[0].RuleId)
+	assert.Equal(t, 2, profiles[0].Evaluations)
+	assert.Equal(t, int64(2000), profiles[0].AvgMs)
+	assert.Equal(t, int64(3000), profiles[0].MaxMs)
+	assert.Equal(t, int64(1000), profiles[0].LastMs)
+	assert.Contains(t, profiles[0].QueryFingerprints, "A")
+	assert.Equal(t, "3", profiles[1].RuleId)
+}
+
+func TestProfileRuleEvaluation(t *testing.T) {
+	rule, err := NewThresholdRule("4", reloadTestRule("profiled", v3.QueryTypeClickHouseSQL), ThresholdRuleOpts{}, nil, nil)
+	require.NoError(t, err)
+	profiler := newEvalProfiler()
+
+	type result struct {
+		profile []byte
+		err     error
+	}
+	results := make(chan result, 1)
+	go func() {
+		profile, err := profiler.profile(context.Background(), rule.ID(), 5*time.Second)
+		results <- result{profile, err}
+	}()
+
+	// the request is picked up by the next evaluation of the rule
+	require.Eventually(t, func() bool {
+		profiler.mtx.Lock()
+		defer profiler.mtx.Unlock()
+		return profiler.pending[rule.ID()] != nil
+	}, time.Second, 10*time.Millisecond)
+
+	_, stop := profiler.begin(context.Background(), rule)
+	_, err = profiler.profile(context.Background(), rule.ID(), time.Millisecond)
+	assert.ErrorIs(t, err, errProfileTimeout)
+	stop()
+
+	res := <-results
+	require.NoError(t, res.err)
+	assert.NotEmpty(t, res.profile)
+}
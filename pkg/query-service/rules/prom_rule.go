@@ -8,6 +8,7 @@ import (
 	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 
 	plabels "github.com/prometheus/prometheus/model/labels"
@@ -26,6 +27,28 @@ type PromRuleOpts struct {
 	// SendAlways will send alert irresepective of resendDelay
 	// or other params
 	SendAlways bool
+
+	// Snippets are the stored templates the annotations can include
+	Snippets *SnippetCache
+
+	// Formats are the format options of the values by org
+	Formats *FormatCache
+
+	// Calendars are the event calendars that suspend or relax the rule
+	Calendars *CalendarCache
+
+	// Services is the service catalog the alerts inherit their team,
+	// channels and runbook from
+	Services *ServiceCatalog
+
+	// History writes the state history in batches, the history is
+	// written on every evaluation when nil
+	History *StateHistoryWriter
+
+	// MaxActiveAlerts is the number of active alerts held in memory, the
+	// least recently seen ones go to AlertSpill or are dropped beyond it
+	MaxActiveAlerts int
+	AlertSpill      AlertSpill
 }
 
 type PromRule struct {
@@ -34,12 +57,24 @@ type PromRule struct {
 	source        string
 	ruleCondition *RuleCondition
 
-	evalWindow   time.Duration
-	holdDuration time.Duration
+	evalWindow time.Duration
+	// evalResolution is the step of the evaluated series
+	evalResolution time.Duration
+	holdDuration   time.Duration
+	// calendars suspend the rule or relax its threshold during their
+	// events, calendarFactor is the factor of the current evaluation
+	calendars      []CalendarRef
+	calendarFactor calendarFactor
+	// deployPolicy applies to the alerts starting after a deploy
+	deployPolicy *DeployPolicy
+	runbook      *Runbook
 	labels       plabels.Labels
 	annotations  plabels.Labels
+	// variables are substituted in the query on every evaluation
+	variables map[string]string
 
 	preferredChannels []string
+	backend           string
 
 	mtx                 sync.Mutex
 	evaluationDuration  time.Duration
@@ -49,8 +84,15 @@ type PromRule struct {
 
 	lastError error
 
+	// samplesReturned is the number of series returned by the query
+	// on the last evaluation
+	samplesReturned int
+	// lastEvaluation is what the rule queried and saw on the last
+	// evaluation, for the evaluation log
+	lastEvaluation evaluationDetails
+
 	// map of active alerts
-	active map[uint64]*Alert
+	active AlertStore
 
 	logger *zap.Logger
 	opts   PromRuleOpts
@@ -78,11 +120,18 @@ func NewPromRule(
 		source:            postableRule.Source,
 		ruleCondition:     postableRule.RuleCondition,
 		evalWindow:        time.Duration(postableRule.EvalWindow),
-		labels:            plabels.FromMap(postableRule.Labels),
-		annotations:       plabels.FromMap(postableRule.Annotations),
+		evalResolution:    time.Duration(postableRule.EvalResolution),
+		holdDuration:      time.Duration(postableRule.HoldDuration),
+		calendars:         postableRule.Calendars,
+		deployPolicy:      postableRule.DeployPolicy,
+		runbook:           postableRule.Runbook,
+		labels:            plabels.FromMap(substituteVariablesMap(postableRule.Labels, postableRule.Variables)),
+		annotations:       plabels.FromMap(substituteVariablesMap(postableRule.Annotations, postableRule.Variables)),
+		variables:         postableRule.Variables,
 		preferredChannels: postableRule.PreferredChannels,
+		backend:           postableRule.Backend,
 		health:            HealthUnknown,
-		active:            map[uint64]*Alert{},
+		active:            NewAlertStore(id, opts.MaxActiveAlerts, opts.AlertSpill),
 		logger:            logger,
 		opts:              opts,
 	}
@@ -91,6 +140,9 @@ func NewPromRule(
 	if int64(p.evalWindow) == 0 {
 		p.evalWindow = 5 * time.Minute
 	}
+	if p.evalResolution == 0 {
+		p.evalResolution = defaultEvalResolution
+	}
 	query, err := p.getPqlQuery()
 
 	if err != nil {
@@ -123,15 +175,14 @@ func (r *PromRule) targetVal() float64 {
 		return 0
 	}
 
-	// get the converter for the target unit
-	unitConverter := converter.FromUnit(converter.Unit(r.ruleCondition.TargetUnit))
-	// convert the target value to the y-axis unit
-	value := unitConverter.Convert(converter.Value{
+	// convert the target value to the y-axis unit, the custom units are
+	// converted through their base
+	value := converter.Convert(converter.Value{
 		F: *r.ruleCondition.Target,
 		U: converter.Unit(r.ruleCondition.TargetUnit),
 	}, converter.Unit(r.Unit()))
 
-	return value.F
+	return value.F * r.calendarFactor.get()
 }
 
 func (r *PromRule) Type() RuleType {
@@ -146,10 +197,18 @@ func (r *PromRule) PreferredChannels() []string {
 	return r.preferredChannels
 }
 
+// Backend returns the name of the query backend the rule is evaluated
+// against
+func (r *PromRule) Backend() string {
+	return r.backend
+}
+
+// SetLastError sets the error of the last evaluation, the errors without a
+// category are classified from their cause
 func (r *PromRule) SetLastError(err error) {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
-	r.lastError = err
+	r.lastError = categorize(err)
 }
 
 func (r *PromRule) LastError() error {
@@ -164,6 +223,20 @@ func (r *PromRule) SetHealth(health RuleHealth) {
 	r.health = health
 }
 
+// SamplesReturned returns the number of series returned by the query on
+// the last evaluation
+func (r *PromRule) SamplesReturned() int {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.samplesReturned
+}
+
+func (r *PromRule) lastEvaluationDetails() evaluationDetails {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.lastEvaluation
+}
+
 func (r *PromRule) Health() RuleHealth {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
@@ -181,6 +254,21 @@ func (r *PromRule) HoldDuration() time.Duration {
 	return r.holdDuration
 }
 
+// Calendars returns the calendars the rule follows
+func (r *PromRule) Calendars() []CalendarRef {
+	return r.calendars
+}
+
+// DeployPolicy returns the deploy policy of the rule, nil when not set
+func (r *PromRule) DeployPolicy() *DeployPolicy {
+	return r.deployPolicy
+}
+
+// Runbook returns the runbook of the rule, nil when not set
+func (r *PromRule) Runbook() *Runbook {
+	return r.runbook
+}
+
 func (r *PromRule) EvalWindow() time.Duration {
 	return r.evalWindow
 }
@@ -221,11 +309,11 @@ func (r *PromRule) GetEvaluationTimestamp() time.Time {
 func (r *PromRule) State() AlertState {
 
 	maxState := StateInactive
-	for _, a := range r.active {
+	r.active.Range(func(_ uint64, a *Alert) {
 		if a.State > maxState {
 			maxState = a.State
 		}
-	}
+	})
 	return maxState
 }
 
@@ -233,15 +321,21 @@ func (r *PromRule) currentAlerts() []*Alert {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
-	alerts := make([]*Alert, 0, len(r.active))
+	alerts := make([]*Alert, 0, r.active.Len())
 
-	for _, a := range r.active {
+	r.active.Range(func(_ uint64, a *Alert) {
 		anew := *a
 		alerts = append(alerts, &anew)
-	}
+	})
 	return alerts
 }
 
+// AlertStoreStats returns the number of alerts the rule holds in memory
+// and in the spill
+func (r *PromRule) AlertStoreStats() AlertStoreStats {
+	return r.active.Stats()
+}
+
 func (r *PromRule) ActiveAlerts() []*Alert {
 	var res []*Alert
 	for _, a := range r.currentAlerts() {
@@ -267,9 +361,9 @@ func (r *PromRule) ForEachActiveAlert(f func(*Alert)) {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
-	for _, a := range r.active {
+	r.active.Range(func(_ uint64, a *Alert) {
 		f(a)
-	}
+	})
 }
 
 func (r *PromRule) SendAlerts(ctx context.Context, ts time.Time, resendDelay time.Duration, interval time.Duration, notifyFunc NotifyFunc) {
@@ -312,7 +406,7 @@ func (r *PromRule) getPqlQuery() (string, error) {
 		if len(r.ruleCondition.CompositeQuery.PromQueries) > 0 {
 			selectedQuery := r.GetSelectedQuery()
 			if promQuery, ok := r.ruleCondition.CompositeQuery.PromQueries[selectedQuery]; ok {
-				query := promQuery.Query
+				query := substituteVariables(promQuery.Query, r.variables)
 				if query == "" {
 					return query, fmt.Errorf("a promquery needs to be set for this rule to function")
 				}
@@ -338,22 +432,87 @@ func (r *PromRule) compareOp() CompareOp {
 	return r.ruleCondition.CompareOp
 }
 
+// runQuery runs the query, or reuses the result of the same query run
+// by another rule for the same window and step
+func (r *PromRule) runQuery(ctx context.Context, queriers *Queriers, q string, start, end time.Time, interval time.Duration) (matrix pql.Matrix, err error) {
+	ctx, span := startSpan(ctx, "rule.query",
+		attribute.String("rule.query.type", "promql"),
+		attribute.Int64("rule.query.start", start.UnixMilli()),
+		attribute.Int64("rule.query.end", end.UnixMilli()),
+		attribute.Int64("rule.query.step", int64(interval.Seconds())),
+	)
+	defer func() { endSpan(span, err) }()
+
+	key, err := queryCacheKey("promql", q, start.UnixMilli(), end.UnixMilli(), interval.Milliseconds())
+	if err != nil {
+		return queriers.PqlEngine.RunAlertQuery(ctx, q, start, end, interval)
+	}
+	fetched := false
+	res, err := queriers.Cache.Get(ctx, key, func() (interface{}, error) {
+		fetched = true
+		return queriers.PqlEngine.RunAlertQuery(ctx, q, start, end, interval)
+	})
+	span.SetAttributes(attribute.Bool("rule.query.cached", !fetched))
+	if err != nil {
+		return nil, err
+	}
+	matrix, _ = res.(pql.Matrix)
+	return matrix, nil
+}
+
+// Explain runs the query of the rule for ts and tells for every series
+// whether it matches the condition
+func (r *PromRule) Explain(ctx context.Context, ts time.Time, queriers *Queriers) (*Explanation, error) {
+	start := ts.Add(-r.evalWindow)
+	q, err := r.getPqlQuery()
+	if err != nil {
+		return nil, err
+	}
+	explanation := newExplanation(r, r.GetSelectedQuery(), r.Unit(), r.targetVal(), ts, start.UnixMilli(), ts.UnixMilli(), q)
+
+	res, err := r.runQuery(ctx, queriers, q, start, ts, r.evalResolution)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, series := range res {
+		values := make([]float64, 0, len(series.Floats))
+		for _, p := range series.Floats {
+			values = append(values, p.F)
+		}
+		shouldAlert := false
+		if len(series.Floats) > 0 {
+			_, shouldAlert = r.shouldAlert(series)
+		}
+		explanation.addSeries(series.Metric.Map(), values, shouldAlert)
+	}
+	explanation.sortSeries()
+	return explanation, nil
+}
+
 func (r *PromRule) Eval(ctx context.Context, ts time.Time, queriers *Queriers) (interface{}, error) {
 
 	prevState := r.State()
+	r.calendarFactor.set(r.opts.Calendars.factor(r.ID(), r.calendars, ts))
 
 	start := ts.Add(-r.evalWindow)
 	end := ts
-	interval := 60 * time.Second // TODO(srikanthccv): this should be configurable
+	interval := r.evalResolution
 
-	valueFormatter := formatter.FromUnit(r.Unit())
+	format := r.opts.Formats.forRule(r.ID())
 
 	q, err := r.getPqlQuery()
 	if err != nil {
+		err = newRuleError(ErrorQueryParse, err)
+		r.SetHealth(HealthBad)
+		r.SetLastError(err)
 		return nil, err
 	}
 	zap.L().Info("evaluating promql query", zap.String("name", r.Name()), zap.String("query", q))
-	res, err := queriers.PqlEngine.RunAlertQuery(ctx, q, start, end, interval)
+	r.mtx.Lock()
+	r.lastEvaluation = evaluationDetails{query: q}
+	r.mtx.Unlock()
+	res, err := r.runQuery(ctx, queriers, q, start, end, interval)
 	if err != nil {
 		r.SetHealth(HealthBad)
 		r.SetLastError(err)
@@ -363,9 +522,14 @@ func (r *PromRule) Eval(ctx context.Context, ts time.Time, queriers *Queriers) (
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
+	r.samplesReturned = len(res)
+	r.lastEvaluation.series = len(res)
+	r.lastEvaluation.samples = matrixSamples(res)
 	resultFPs := map[uint64]struct{}{}
 
 	var alerts = make(map[uint64]*Alert, len(res))
+	snippets := r.opts.Snippets.All()
+	var templateErr error
 
 	for _, series := range res {
 		l := make(map[string]string, len(series.Metric))
@@ -383,14 +547,15 @@ func (r *PromRule) Eval(ctx context.Context, ts time.Time, queriers *Queriers) (
 		}
 		zap.L().Debug("alerting for series", zap.String("name", r.Name()), zap.Any("series", series))
 
-		threshold := valueFormatter.Format(r.targetVal(), r.Unit())
+		threshold := formatter.Format(r.targetVal(), r.Unit(), format)
 
-		tmplData := AlertTemplateData(l, valueFormatter.Format(alertSmpl.F, r.Unit()), threshold)
+		tmplData := AlertTemplateData(l, formatter.Format(alertSmpl.F, r.Unit(), format), threshold, map[string]float64{r.GetSelectedQuery(): alertSmpl.F})
 		// Inject some convenience variables that are easier to remember for users
 		// who are not used to Go's templating system.
-		defs := "{{$labels := .Labels}}{{$value := .Value}}{{$threshold := .Threshold}}"
+		defs := "{{$labels := .Labels}}{{$value := .Value}}{{$threshold := .Threshold}}{{$values := .Values}}"
 
 		expand := func(text string) string {
+			_, span := startSpan(ctx, "rule.template.expand", attribute.Int("rule.template.length", len(text)))
 
 			tmpl := NewTemplateExpander(
 				ctx,
@@ -399,10 +564,12 @@ func (r *PromRule) Eval(ctx context.Context, ts time.Time, queriers *Queriers) (
 				tmplData,
 				times.Time(timestamp.FromTime(ts)),
 				nil,
-			)
+			).WithSnippets(snippets).WithFormat(format)
 			result, err := tmpl.Expand()
+			endSpan(span, err)
 			if err != nil {
 				result = fmt.Sprintf("<error expanding template: %s>", err)
+				templateErr = newRuleError(ErrorTemplate, fmt.Errorf("expanding template: %w", err))
 				r.logger.Warn("Expanding alert template failed", zap.Error(err), zap.Any("data", tmplData))
 			}
 			return result
@@ -424,6 +591,20 @@ func (r *PromRule) Eval(ctx context.Context, ts time.Time, queriers *Queriers) (
 			annotations = append(annotations, plabels.Label{Name: a.Name, Value: expand(a.Value)})
 		}
 
+		// the runbook of the rule takes precedence over the one of the
+		// service
+		if link := r.runbook.link(r.ID(), r.GeneratorURL()); link != "" && annotations.Get(RunbookAnnotation) == "" {
+			annotations = append(annotations, plabels.Label{Name: RunbookAnnotation, Value: link})
+		}
+		// the alerts of a cataloged service inherit its ownership
+		owner := r.opts.Services.ownership(r.ID(), qslabels.FromMap(lb.Labels().Map()), qslabels.FromMap(annotations.Map()), r.preferredChannels)
+		if owner.team != "" {
+			lb.Set(owner.teamLabel, owner.team)
+		}
+		if owner.runbook != "" {
+			annotations = append(annotations, plabels.Label{Name: RunbookAnnotation, Value: owner.runbook})
+		}
+
 		lbs := lb.Labels()
 		h := lbs.Hash()
 		resultFPs[h] = struct{}{}
@@ -433,7 +614,7 @@ func (r *PromRule) Eval(ctx context.Context, ts time.Time, queriers *Queriers) (
 			// We have already acquired the lock above hence using SetHealth and
 			// SetLastError will deadlock.
 			r.health = HealthBad
-			r.lastError = err
+			r.lastError = categorize(err)
 			return nil, err
 		}
 
@@ -445,7 +626,7 @@ func (r *PromRule) Eval(ctx context.Context, ts time.Time, queriers *Queriers) (
 			State:             StatePending,
 			Value:             alertSmpl.F,
 			GeneratorURL:      r.GeneratorURL(),
-			Receivers:         r.preferredChannels,
+			Receivers:         owner.receivers,
 		}
 	}
 
@@ -454,21 +635,25 @@ func (r *PromRule) Eval(ctx context.Context, ts time.Time, queriers *Queriers) (
 	for h, a := range alerts {
 		// Check whether we already have alerting state for the identifying label set.
 		// Update the last value and annotations if so, create a new alert entry otherwise.
-		if alert, ok := r.active[h]; ok && alert.State != StateInactive {
+		if alert, ok := r.active.Get(h); ok && alert.State != StateInactive {
 			alert.Value = a.Value
+			alert.recordValue(ts, a.Value)
+			alert.Breaches++
 			alert.Annotations = a.Annotations
-			alert.Receivers = r.preferredChannels
+			alert.Receivers = a.Receivers
 			continue
 		}
 
-		r.active[h] = a
+		a.recordValue(ts, a.Value)
+		a.Breaches = 1
+		r.active.Set(h, a)
 
 	}
 
 	itemsToAdd := []v3.RuleStateHistory{}
 
 	// Check if any pending alerts should be removed or fire now. Write out alert timeseries.
-	for fp, a := range r.active {
+	r.active.Range(func(fp uint64, a *Alert) {
 		labelsJSON, err := json.Marshal(a.Labels)
 		if err != nil {
 			zap.L().Error("error marshaling labels", zap.Error(err), zap.String("name", r.Name()))
@@ -477,7 +662,7 @@ func (r *PromRule) Eval(ctx context.Context, ts time.Time, queriers *Queriers) (
 			// If the alert was previously firing, keep it around for a given
 			// retention time so it is reported as resolved to the AlertManager.
 			if a.State == StatePending || (!a.ResolvedAt.IsZero() && ts.Sub(a.ResolvedAt) > resolvedRetention) {
-				delete(r.active, fp)
+				r.active.Delete(fp)
 			}
 			if a.State != StateInactive {
 				a.State = StateInactive
@@ -492,7 +677,7 @@ func (r *PromRule) Eval(ctx context.Context, ts time.Time, queriers *Queriers) (
 					Fingerprint:  a.QueryResultLables.Hash(),
 				})
 			}
-			continue
+			return
 		}
 
 		if a.State == StatePending && ts.Sub(a.ActiveAt) >= r.holdDuration {
@@ -513,10 +698,14 @@ func (r *PromRule) Eval(ctx context.Context, ts time.Time, queriers *Queriers) (
 				Value:        a.Value,
 			})
 		}
-
-	}
+	})
 	r.health = HealthGood
-	r.lastError = err
+	// a template failing to expand does not fail the evaluation, it is
+	// recorded for the rule to be fixed
+	r.lastError = categorize(err)
+	if templateErr != nil {
+		r.lastError = templateErr
+	}
 
 	currentState := r.State()
 
@@ -531,14 +720,11 @@ func (r *PromRule) Eval(ctx context.Context, ts time.Time, queriers *Queriers) (
 		}
 	}
 
-	if len(itemsToAdd) > 0 && r.reader != nil {
-		err := r.reader.AddRuleStateHistory(ctx, itemsToAdd)
-		if err != nil {
-			zap.L().Error("error while inserting rule state history", zap.Error(err), zap.Any("itemsToAdd", itemsToAdd))
-		}
+	if r.reader != nil {
+		writeStateHistory(ctx, r.opts.History, r.reader, itemsToAdd)
 	}
 
-	return len(r.active), nil
+	return r.active.Len(), nil
 }
 
 func (r *PromRule) shouldAlert(series pql.Series) (pql.Sample, bool) {
@@ -705,6 +891,7 @@ func (r *PromRule) String() string {
 		AlertName:         r.name,
 		RuleCondition:     r.ruleCondition,
 		EvalWindow:        Duration(r.evalWindow),
+		HoldDuration:      Duration(r.holdDuration),
 		Labels:            r.labels.Map(),
 		Annotations:       r.annotations.Map(),
 		PreferredChannels: r.preferredChannels,
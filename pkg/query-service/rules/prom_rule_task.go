@@ -2,13 +2,12 @@ package rules
 
 import (
 	"context"
-	"fmt"
 	"sort"
 	"sync"
 	"time"
 
-	opentracing "github.com/opentracing/opentracing-go"
 	plabels "github.com/prometheus/prometheus/model/labels"
+	"go.opentelemetry.io/otel/attribute"
 	"go.signoz.io/signoz/pkg/query-service/common"
 	"go.uber.org/zap"
 )
@@ -36,6 +35,8 @@ type PromRuleTask struct {
 	notify NotifyFunc
 
 	ruleDB RuleDB
+
+	evalTracker
 }
 
 // newPromRuleTask holds rules that have promql condition
@@ -102,7 +103,21 @@ func (g *PromRuleTask) Run(ctx context.Context) {
 		},
 	})
 
+	// stopping the task cancels its queued or running evaluation
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-g.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
 	iter := func() {
+		if g.opts.scheduler.isPaused() {
+			return
+		}
 
 		start := time.Now()
 		g.Eval(ctx, evalTimestamp)
@@ -136,6 +151,10 @@ func (g *PromRuleTask) Run(ctx context.Context) {
 
 	}()
 
+	// replay the evaluations before the first one so that the pending
+	// and firing alerts are restored after a restart
+	g.catchUp(ctx, catchUpTimestamps(evalTimestamp, maxCatchUpEvaluations, g.frequency, g.opts.EvalCatchUpLookback))
+
 	iter()
 
 	// let the group iterate and run
@@ -149,6 +168,12 @@ func (g *PromRuleTask) Run(ctx context.Context) {
 				return
 			case <-tick.C:
 				missed := (time.Since(evalTimestamp) / g.frequency) - 1
+				if missed > 0 {
+					zap.L().Warn("missed rule evaluations", zap.String("name", g.name), zap.Int64("missed", int64(missed)), zap.Time("since", evalTimestamp.Add(g.frequency)))
+					g.recordMissed(int64(missed), evalTimestamp.Add(g.frequency))
+					next := evalTimestamp.Add((missed + 1) * g.frequency)
+					g.catchUp(ctx, catchUpTimestamps(next, int64(missed), g.frequency, g.opts.EvalCatchUpLookback))
+				}
 				evalTimestamp = evalTimestamp.Add((missed + 1) * g.frequency)
 				iter()
 			}
@@ -254,66 +279,66 @@ func (g *PromRuleTask) EvalTimestamp(startTime int64) time.Time {
 
 // CopyState copies the alerting rule and staleness related state from the given group.
 //
-// Rules are matched based on their name and labels. If there are duplicates, the
-// first is matched with the first, second with the second etc.
+// Rules are matched based on their id and then their name and labels. If there are
+// duplicates, the first is matched with the first, second with the second etc.
 func (g *PromRuleTask) CopyState(fromTask Task) error {
 
+	copyRulesState(g.rules, fromTask)
+
+	// the staleness state only applies to the tasks of the same type
 	from, ok := fromTask.(*PromRuleTask)
 	if !ok {
-		return fmt.Errorf("you can only copy rule groups with same type")
+		return nil
 	}
 
 	g.evaluationTime = from.evaluationTime
 	g.lastEvaluation = from.lastEvaluation
 
-	ruleMap := make(map[string][]int, len(from.rules))
-
-	for fi, fromRule := range from.rules {
-		nameAndLabels := nameAndLabels(fromRule)
-		l := ruleMap[nameAndLabels]
-		ruleMap[nameAndLabels] = append(l, fi)
-	}
-
-	for i, rule := range g.rules {
-		nameAndLabels := nameAndLabels(rule)
-		indexes := ruleMap[nameAndLabels]
-		if len(indexes) == 0 {
-			continue
-		}
-		fi := indexes[0]
+	matched := make(map[int]bool, len(from.rules))
+	for i, fi := range matchRules(g.rules, from.rules) {
 		g.seriesInPreviousEval[i] = from.seriesInPreviousEval[fi]
-		ruleMap[nameAndLabels] = indexes[1:]
-
-		ar, ok := rule.(*ThresholdRule)
-		if !ok {
-			continue
-		}
-		far, ok := from.rules[fi].(*ThresholdRule)
-		if !ok {
-			continue
-		}
-
-		for fp, a := range far.active {
-			ar.active[fp] = a
-		}
+		matched[fi] = true
 	}
 
 	// Handle deleted and unmatched duplicate rules.
 	g.staleSeries = from.staleSeries
-	for fi, fromRule := range from.rules {
-		nameAndLabels := nameAndLabels(fromRule)
-		l := ruleMap[nameAndLabels]
-		if len(l) != 0 {
-			for _, series := range from.seriesInPreviousEval[fi] {
-				g.staleSeries = append(g.staleSeries, series)
-			}
+	for fi := range from.rules {
+		if matched[fi] {
+			continue
+		}
+		for _, series := range from.seriesInPreviousEval[fi] {
+			g.staleSeries = append(g.staleSeries, series)
 		}
 	}
 	return nil
 }
 
-// Eval runs a single evaluation cycle in which all rules are evaluated sequentially.
+// Eval runs a single evaluation cycle in which all rules are evaluated
+// sequentially on the workers of the evaluation pool.
 func (g *PromRuleTask) Eval(ctx context.Context, ts time.Time) {
+	g.eval(ctx, ts, true)
+}
+
+// catchUp evaluates the rules at the missed timestamps to bring the state
+// of their alerts up to date, the alerts are sent by the next evaluation
+func (g *PromRuleTask) catchUp(ctx context.Context, timestamps []time.Time) {
+	if len(timestamps) == 0 || g.pause {
+		return
+	}
+	zap.L().Info("catching up missed rule evaluations", zap.String("name", g.name), zap.Int("evaluations", len(timestamps)))
+	for _, ts := range timestamps {
+		select {
+		case <-g.done:
+			return
+		default:
+		}
+		g.eval(ctx, ts, false)
+	}
+	g.recordCaughtUp(int64(len(timestamps)))
+}
+
+// eval evaluates the rules at ts and sends their alerts when notify is set
+func (g *PromRuleTask) eval(ctx context.Context, ts time.Time, notify bool) {
 	zap.L().Info("promql rule task", zap.String("name", g.name), zap.Time("eval started at", ts))
 
 	maintenance, err := g.ruleDB.GetAllPlannedMaintenance(ctx)
@@ -322,20 +347,34 @@ func (g *PromRuleTask) Eval(ctx context.Context, ts time.Time) {
 		zap.L().Error("Error in processing sql query", zap.Error(err))
 	}
 
-	for i, rule := range g.rules {
+	for _, rule := range g.rules {
 		if rule == nil {
 			continue
 		}
 
+		// the rule is evaluated by another replica
+		if !g.opts.sharder.Owns(rule.ID()) {
+			continue
+		}
+
 		shouldSkip := false
+		org := g.opts.tenants.of(rule.ID())
 		for _, m := range maintenance {
 			zap.L().Info("checking if rule should be skipped", zap.String("rule", rule.ID()), zap.Any("maintenance", m))
-			if m.shouldSkip(rule.ID(), ts) {
+			if m.appliesTo(org) && m.shouldSkip(rule.ID(), ts) {
 				shouldSkip = true
 				break
 			}
 		}
+		// the events of the calendars of the rule suspend it like a
+		// maintenance
+		if !shouldSkip && calendarSuspended(g.opts, rule, ts) {
+			shouldSkip = true
+		}
 
+		if g.setMuted(rule.ID(), shouldSkip) {
+			publishSilenced(g.opts, rule, ts)
+		}
 		if shouldSkip {
 			zap.L().Info("rule should be skipped", zap.String("rule", rule.ID()))
 			continue
@@ -347,16 +386,38 @@ func (g *PromRuleTask) Eval(ctx context.Context, ts time.Time) {
 		default:
 		}
 
-		func(i int, rule Rule) {
-			sp, ctx := opentracing.StartSpanFromContext(ctx, "rule")
+		// the query of the rule keeps failing, wait for the backoff
+		breaker := g.breaker(rule.ID(), g.opts)
+		if !breaker.allow(time.Now()) {
+			zap.L().Debug("rule evaluation backed off", zap.String("ruleid", rule.ID()))
+			continue
+		}
 
-			sp.SetTag("name", rule.Name())
+		err := g.opts.EvalPool.Run(ctx, rule.ID(), g.opts.evalTimeout(g.frequency), func(ctx context.Context) {
+			ctx, span := startSpan(ctx, "rule.eval", append(ruleAttributes(rule),
+				attribute.String("rule.eval_timestamp", ts.Format(time.RFC3339)),
+				attribute.Bool("rule.catch_up", !notify),
+			)...)
+			ctx, meter := withCostMeter(ctx)
+			ctx, stopProfile := g.opts.profiler.begin(ctx, rule)
+			var evalErr error
+			health := rule.Health()
 			defer func(t time.Time) {
-				sp.Finish()
+				stopProfile()
+				endSpan(span, evalErr)
+
+				cost := meter.cost(ts)
+				g.opts.costs.record(rule.ID(), cost)
+				g.opts.costReport.record(rule.ID(), cost)
+				g.opts.metrics.observeQueryCost(rule, cost)
 
 				since := time.Since(t)
 				rule.SetEvaluationDuration(since)
 				rule.SetEvaluationTimestamp(t)
+				g.opts.metrics.observeEvaluation(rule, since)
+				g.opts.profiler.record(rule.ID(), since)
+				recordEvaluation(ctx, g.ruleDB, g.opts, rule, ts, since, evalErr)
+				publishHealthChange(g.opts, rule, health, ts)
 			}(time.Now())
 
 			kvs := map[string]string{
@@ -366,10 +427,17 @@ func (g *PromRuleTask) Eval(ctx context.Context, ts time.Time) {
 			}
 			ctx = context.WithValue(ctx, common.LogCommentKey, kvs)
 
-			_, err := rule.Eval(ctx, ts, g.opts.Queriers)
+			before := alertStates(rule)
+			queriers, err := g.opts.Queriers.For(rule)
+			if err == nil {
+				_, err = rule.Eval(ctx, ts, queriers)
+			}
+			evalErr = err
 			if err != nil {
 				rule.SetHealth(HealthBad)
 				rule.SetLastError(err)
+				breaker.failure(time.Now(), g.frequency, err)
+				g.opts.metrics.evaluationFailed(rule)
 
 				zap.L().Warn("Evaluating rule failed", zap.String("ruleid", rule.ID()), zap.Error(err))
 
@@ -380,8 +448,17 @@ func (g *PromRuleTask) Eval(ctx context.Context, ts time.Time) {
 				//}
 				return
 			}
-			rule.SendAlerts(ctx, ts, g.opts.ResendDelay, g.frequency, g.notify)
-
-		}(i, rule)
+			breaker.success()
+			publishTransitions(g.opts, rule, before, ts)
+			observeSeries(ctx, g.opts, rule, ts)
+			observeTeamAlerts(g.opts, rule)
+			if notify {
+				sendAlerts(ctx, g.opts, rule, ts, g.frequency, g.notify)
+			}
+		})
+		if err != nil {
+			breaker.abort()
+			zap.L().Warn("rule evaluation skipped", zap.String("ruleid", rule.ID()), zap.Error(err))
+		}
 	}
 }
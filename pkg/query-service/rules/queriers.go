@@ -1,10 +1,36 @@
 package rules
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
 	"github.com/ClickHouse/clickhouse-go/v2"
+	pql "github.com/prometheus/prometheus/promql"
 	pqle "go.signoz.io/signoz/pkg/query-service/pqlEngine"
+	yaml "gopkg.in/yaml.v2"
 )
 
+// DefaultBackend is the name of the backend of the rules that do not
+// select one
+const DefaultBackend = "default"
+
+// PromQuerier runs the promql queries of the rules
+type PromQuerier interface {
+	RunAlertQuery(ctx context.Context, qs string, start, end time.Time, interval time.Duration) (pql.Matrix, error)
+}
+
+// Backend is a source the rules can be evaluated against, e.g. a remote
+// prometheus through remote read or another clickhouse cluster. A backend
+// only serves the query types it has a querier for.
+type Backend struct {
+	PqlEngine PromQuerier
+	Ch        clickhouse.Conn
+}
+
 // Queriers register the options for querying metrics or event sources
 // which return a condition that results in a alert. Currently we support
 // promql engine and clickhouse queries but in future we may include
@@ -14,8 +40,148 @@ import (
 // the query engines.
 type Queriers struct {
 	// promql engine
-	PqlEngine *pqle.PqlEngine
+	PqlEngine PromQuerier
 
 	// metric querier
 	Ch clickhouse.Conn
+
+	// Cache shares the query results between the rules
+	Cache *QueryCache
+
+	// backends are the additional sources the rules can select
+	mtx      sync.RWMutex
+	backends map[string]*Queriers
+}
+
+// Register adds a backend the rules can select by name
+func (q *Queriers) Register(name string, backend Backend) error {
+	if name == "" || name == DefaultBackend {
+		return fmt.Errorf("invalid query backend name %q", name)
+	}
+	if backend.PqlEngine == nil && backend.Ch == nil {
+		return fmt.Errorf("query backend %s has no querier", name)
+	}
+
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	if _, ok := q.backends[name]; ok {
+		return fmt.Errorf("query backend %s is already registered", name)
+	}
+	if q.backends == nil {
+		q.backends = map[string]*Queriers{}
+	}
+	// the cache keys do not include the backend, every backend has its
+	// own cache
+	var cache *QueryCache
+	if q.Cache != nil {
+		cache = NewQueryCache(q.Cache.ttl)
+	}
+	q.backends[name] = &Queriers{PqlEngine: backend.PqlEngine, Ch: backend.Ch, Cache: cache}
+	return nil
+}
+
+// Backend returns the queriers of the backend, the default backend is q
+func (q *Queriers) Backend(name string) (*Queriers, error) {
+	if name == "" || name == DefaultBackend {
+		return q, nil
+	}
+	q.mtx.RLock()
+	defer q.mtx.RUnlock()
+	backend, ok := q.backends[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown query backend %s", name)
+	}
+	return backend, nil
+}
+
+// Backends returns the names of the registered backends
+func (q *Queriers) Backends() []string {
+	q.mtx.RLock()
+	defer q.mtx.RUnlock()
+	names := []string{DefaultBackend}
+	for name := range q.backends {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// For returns the queriers of the backend selected by the rule
+func (q *Queriers) For(rule Rule) (*Queriers, error) {
+	r, ok := rule.(interface{ Backend() string })
+	if !ok {
+		return q, nil
+	}
+	return q.backendFor(r.Backend(), rule.Type())
+}
+
+// backendFor returns the backend if it serves the queries of the rule type
+func (q *Queriers) backendFor(name string, ruleType RuleType) (*Queriers, error) {
+	backend, err := q.Backend(name)
+	if err != nil || backend == q {
+		return backend, err
+	}
+	switch ruleType {
+	case RuleTypeProm:
+		if backend.PqlEngine == nil {
+			return nil, fmt.Errorf("query backend %s does not serve promql queries", name)
+		}
+	case RuleTypeThreshold:
+		if backend.Ch == nil {
+			return nil, fmt.Errorf("query backend %s does not serve clickhouse queries", name)
+		}
+	}
+	return backend, nil
+}
+
+// BackendConfig is an entry of the query backends configuration file
+type BackendConfig struct {
+	Name string `yaml:"name"`
+	// Type is prometheus or clickhouse
+	Type string `yaml:"type"`
+	// PrometheusConfig is the path of the prometheus configuration whose
+	// remote_read endpoints serve the promql queries
+	PrometheusConfig string `yaml:"prometheus_config"`
+	// DSN is the address of the clickhouse cluster
+	DSN string `yaml:"dsn"`
+}
+
+// RegisterBackends registers the backends of the configuration file
+func (q *Queriers) RegisterBackends(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read the query backends: %w", err)
+	}
+	var configs []BackendConfig
+	if err := yaml.Unmarshal(b, &configs); err != nil {
+		return fmt.Errorf("failed to parse the query backends: %w", err)
+	}
+
+	for _, c := range configs {
+		var backend Backend
+		switch c.Type {
+		case "prometheus":
+			engine, err := pqle.FromConfigPath(c.PrometheusConfig)
+			if err != nil {
+				return fmt.Errorf("query backend %s: %w", c.Name, err)
+			}
+			backend.PqlEngine = engine
+		case "clickhouse":
+			options, err := clickhouse.ParseDSN(c.DSN)
+			if err != nil {
+				return fmt.Errorf("query backend %s: %w", c.Name, err)
+			}
+			conn, err := clickhouse.Open(options)
+			if err != nil {
+				return fmt.Errorf("query backend %s: %w", c.Name, err)
+			}
+			backend.Ch = conn
+		default:
+			return fmt.Errorf("query backend %s has an unknown type %q", c.Name, c.Type)
+		}
+		if err := q.Register(c.Name, backend); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,60 @@
+package rules
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	pql "github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.uber.org/zap"
+)
+
+type mockPromQuerier struct {
+	queries []string
+	matrix  pql.Matrix
+}
+
+func (m *mockPromQuerier) RunAlertQuery(ctx context.Context, qs string, start, end time.Time, interval time.Duration) (pql.Matrix, error) {
+	m.queries = append(m.queries, qs)
+	return m.matrix, nil
+}
+
+func TestRuleSelectsQueryBackend(t *testing.T) {
+	ts := time.Now()
+	series := pql.Series{
+		Metric: labels.FromStrings("service", "api"),
+		Floats: []pql.FPoint{{T: ts.UnixMilli(), F: 20}},
+	}
+	defaultQuerier := &mockPromQuerier{}
+	mock := &mockPromQuerier{matrix: pql.Matrix{series}}
+
+	queriers := &Queriers{PqlEngine: defaultQuerier, Cache: NewQueryCache(time.Minute)}
+	require.NoError(t, queriers.Register("mock", Backend{PqlEngine: mock}))
+	assert.Error(t, queriers.Register("mock", Backend{PqlEngine: mock}))
+	assert.Error(t, queriers.Register(DefaultBackend, Backend{PqlEngine: mock}))
+	assert.Error(t, queriers.Register("empty", Backend{}))
+	assert.Equal(t, []string{DefaultBackend, "mock"}, queriers.Backends())
+
+	postable := reloadTestRule("remote", v3.QueryTypePromQL)
+	postable.Backend = "mock"
+	rule, err := NewPromRule("1", postable, zap.NewNop(), PromRuleOpts{}, nil)
+	require.NoError(t, err)
+
+	backend, err := queriers.For(rule)
+	require.NoError(t, err)
+	count, err := rule.Eval(context.Background(), ts, backend)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, []string{"up"}, mock.queries)
+	assert.Empty(t, defaultQuerier.queries)
+
+	postable.Backend = "unknown"
+	rule, err = NewPromRule("2", postable, zap.NewNop(), PromRuleOpts{}, nil)
+	require.NoError(t, err)
+	_, err = queriers.For(rule)
+	assert.Error(t, err)
+}
@@ -0,0 +1,155 @@
+package rules
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+// queryCacheEntry is a query result shared by the rules, done is closed
+// when the result is available
+type queryCacheEntry struct {
+	done    chan struct{}
+	value   interface{}
+	err     error
+	expires time.Time
+}
+
+// QueryCache shares the results of the alert queries between rules for a
+// short time. Rules that run the same query for the same window and step
+// in an evaluation tick, such as a warning and a critical rule on the same
+// query, wait for the first of them to fetch the result instead of
+// querying the datastore again. Errors are not cached.
+type QueryCache struct {
+	ttl time.Duration
+
+	mtx       sync.Mutex
+	entries   map[string]*queryCacheEntry
+	lastSweep time.Time
+}
+
+// NewQueryCache returns a cache that keeps results for ttl, a zero ttl
+// disables the cache
+func NewQueryCache(ttl time.Duration) *QueryCache {
+	return &QueryCache{
+		ttl:     ttl,
+		entries: map[string]*queryCacheEntry{},
+	}
+}
+
+// Get returns the cached result for the key or the result of fetch, which
+// is called once for concurrent gets of the same key. The result is shared
+// and must not be modified.
+func (c *QueryCache) Get(ctx context.Context, key string, fetch func() (interface{}, error)) (interface{}, error) {
+	if c == nil || c.ttl <= 0 {
+		return fetch()
+	}
+
+	now := time.Now()
+	c.mtx.Lock()
+	c.sweep(now)
+	entry, ok := c.entries[key]
+	if ok && (entry.expires.IsZero() || now.Before(entry.expires)) {
+		c.mtx.Unlock()
+		select {
+		case <-entry.done:
+			return entry.value, entry.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	entry = &queryCacheEntry{done: make(chan struct{})}
+	c.entries[key] = entry
+	c.mtx.Unlock()
+
+	entry.value, entry.err = fetch()
+
+	c.mtx.Lock()
+	if entry.err != nil {
+		if c.entries[key] == entry {
+			delete(c.entries, key)
+		}
+	} else {
+		entry.expires = time.Now().Add(c.ttl)
+	}
+	c.mtx.Unlock()
+	close(entry.done)
+
+	return entry.value, entry.err
+}
+
+// sweep removes the expired entries, at most once per ttl
+func (c *QueryCache) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < c.ttl {
+		return
+	}
+	c.lastSweep = now
+	for key, entry := range c.entries {
+		if !entry.expires.IsZero() && !now.Before(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// Len returns the number of cached results
+func (c *QueryCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return len(c.entries)
+}
+
+// queryCacheKey returns the key of a query, its window and its step
+func queryCacheKey(kind string, query interface{}, start, end int64, step int64) (string, error) {
+	data, err := json.Marshal(query)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(data)
+	return fmt.Sprintf("%s:%s:%d:%d:%d", kind, hex.EncodeToString(hash[:]), start, end, step), nil
+}
+
+// cloneResults copies the series of the results so that post processing
+// does not modify the cached results
+func cloneResults(results []*v3.Result) []*v3.Result {
+	cloned := make([]*v3.Result, 0, len(results))
+	for _, res := range results {
+		if res == nil {
+			cloned = append(cloned, nil)
+			continue
+		}
+		c := *res
+		c.Series = make([]*v3.Series, 0, len(res.Series))
+		for _, s := range res.Series {
+			if s == nil {
+				continue
+			}
+			series := &v3.Series{
+				Labels:      make(map[string]string, len(s.Labels)),
+				LabelsArray: make([]map[string]string, 0, len(s.LabelsArray)),
+				Points:      append([]v3.Point(nil), s.Points...),
+			}
+			for k, v := range s.Labels {
+				series.Labels[k] = v
+			}
+			for _, l := range s.LabelsArray {
+				m := make(map[string]string, len(l))
+				for k, v := range l {
+					m[k] = v
+				}
+				series.LabelsArray = append(series.LabelsArray, m)
+			}
+			c.Series = append(c.Series, series)
+		}
+		cloned = append(cloned, &c)
+	}
+	return cloned
+}
@@ -0,0 +1,121 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func TestQueryCacheSharesResults(t *testing.T) {
+	cache := NewQueryCache(time.Minute)
+
+	var calls int32
+	release := make(chan struct{})
+	fetch := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "result", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := cache.Get(context.Background(), "key", fetch)
+			assert.NoError(t, err)
+			assert.Equal(t, "result", res)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), calls)
+
+	// other keys are fetched separately
+	_, err := cache.Get(context.Background(), "other", func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return "other", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), calls)
+	assert.Equal(t, 2, cache.Len())
+}
+
+func TestQueryCacheExpiryAndErrors(t *testing.T) {
+	cache := NewQueryCache(20 * time.Millisecond)
+
+	calls := 0
+	fetch := func() (interface{}, error) {
+		calls++
+		if calls == 1 {
+			return nil, errors.New("query failed")
+		}
+		return calls, nil
+	}
+
+	_, err := cache.Get(context.Background(), "key", fetch)
+	assert.Error(t, err)
+
+	// errors are not cached
+	res, err := cache.Get(context.Background(), "key", fetch)
+	require.NoError(t, err)
+	assert.Equal(t, 2, res)
+	res, _ = cache.Get(context.Background(), "key", fetch)
+	assert.Equal(t, 2, res)
+
+	time.Sleep(30 * time.Millisecond)
+	res, _ = cache.Get(context.Background(), "key", fetch)
+	assert.Equal(t, 3, res)
+
+	// a zero ttl disables the cache
+	disabled := NewQueryCache(0)
+	disabled.Get(context.Background(), "key", fetch)
+	res, _ = disabled.Get(context.Background(), "key", fetch)
+	assert.Equal(t, 5, res)
+}
+
+func TestQueryCacheKey(t *testing.T) {
+	query := &v3.CompositeQuery{
+		QueryType: v3.QueryTypeClickHouseSQL,
+		ClickHouseQueries: map[string]*v3.ClickHouseQuery{
+			"A": {Query: "SELECT 1"},
+		},
+	}
+	key, err := queryCacheKey("threshold", query, 1, 2, 60)
+	require.NoError(t, err)
+
+	same, _ := queryCacheKey("threshold", query, 1, 2, 60)
+	assert.Equal(t, key, same)
+
+	otherStep, _ := queryCacheKey("threshold", query, 1, 2, 15)
+	assert.NotEqual(t, key, otherStep)
+
+	otherWindow, _ := queryCacheKey("threshold", query, 0, 2, 60)
+	assert.NotEqual(t, key, otherWindow)
+}
+
+func TestCloneResults(t *testing.T) {
+	results := []*v3.Result{
+		{
+			QueryName: "A",
+			Series: []*v3.Series{
+				{Labels: map[string]string{"service": "checkout"}, Points: []v3.Point{{Timestamp: 1, Value: 10}}},
+			},
+		},
+	}
+	cloned := cloneResults(results)
+	cloned[0].Series[0].Labels["service"] = "cart"
+	cloned[0].Series[0].Points[0].Value = 20
+	cloned[0].Series = nil
+
+	assert.Equal(t, "checkout", results[0].Series[0].Labels["service"])
+	assert.Equal(t, float64(10), results[0].Series[0].Points[0].Value)
+}
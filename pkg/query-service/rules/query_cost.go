@@ -0,0 +1,211 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// maxQueryCostHistory is the number of evaluations whose query cost is
+// kept for every rule
+const maxQueryCostHistory = 60
+
+// CostVerdict tells how the estimated cost of a rule compares to the budget
+type CostVerdict string
+
+const (
+	CostWithinBudget CostVerdict = "ok"
+	CostWarn         CostVerdict = "warn"
+	CostDeny         CostVerdict = "deny"
+)
+
+// QueryEstimate is the estimate of a single query from ClickHouse
+// EXPLAIN ESTIMATE
+type QueryEstimate struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+	Parts uint64 `json:"parts"`
+	Rows  uint64 `json:"rows"`
+	Marks uint64 `json:"marks"`
+	Error string `json:"error,omitempty"`
+}
+
+// CostEstimate is the estimated cost of the queries of a rule for one
+// evaluation and the verdict of the budget
+type CostEstimate struct {
+	Queries  []QueryEstimate `json:"queries"`
+	Rows     uint64          `json:"rows"`
+	Marks    uint64          `json:"marks"`
+	WarnRows uint64          `json:"warnRows,omitempty"`
+	MaxRows  uint64          `json:"maxRows,omitempty"`
+	Verdict  CostVerdict     `json:"verdict"`
+	Message  string          `json:"message,omitempty"`
+}
+
+// CostEstimator is implemented by the rules whose query cost can be
+// estimated
+type CostEstimator interface {
+	EstimateCost(ctx context.Context, ts time.Time, queriers *Queriers) (*CostEstimate, error)
+}
+
+// estimateQueries estimates the rows read by every query with EXPLAIN
+// ESTIMATE. A query that cannot be estimated is reported with its error.
+func estimateQueries(ctx context.Context, ch clickhouse.Conn, queries map[string]string) (*CostEstimate, error) {
+	if ch == nil {
+		return nil, fmt.Errorf("no clickhouse connection to estimate the query cost")
+	}
+	estimate := &CostEstimate{Queries: []QueryEstimate{}}
+	for name, query := range queries {
+		qe := QueryEstimate{Name: name, Query: query}
+		if err := explainEstimate(ctx, ch, &qe); err != nil {
+			qe.Error = err.Error()
+		}
+		estimate.Rows += qe.Rows
+		estimate.Marks += qe.Marks
+		estimate.Queries = append(estimate.Queries, qe)
+	}
+	sort.Slice(estimate.Queries, func(i, j int) bool {
+		return estimate.Queries[i].Name < estimate.Queries[j].Name
+	})
+	return estimate, nil
+}
+
+func explainEstimate(ctx context.Context, ch clickhouse.Conn, qe *QueryEstimate) error {
+	rows, err := ch.Query(ctx, "EXPLAIN ESTIMATE "+qe.Query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var database, table string
+		var parts, rowCount, marks uint64
+		if err := rows.Scan(&database, &table, &parts, &rowCount, &marks); err != nil {
+			return err
+		}
+		qe.Parts += parts
+		qe.Rows += rowCount
+		qe.Marks += marks
+	}
+	return rows.Err()
+}
+
+// applyBudget sets the verdict of the estimate, zero budgets are unlimited
+func (e *CostEstimate) applyBudget(warnRows, maxRows uint64) {
+	e.WarnRows = warnRows
+	e.MaxRows = maxRows
+	e.Verdict = CostWithinBudget
+	e.Message = ""
+	switch {
+	case maxRows > 0 && e.Rows > maxRows:
+		e.Verdict = CostDeny
+		e.Message = fmt.Sprintf("the queries of the rule scan about %d rows per evaluation, more than the limit of %d rows", e.Rows, maxRows)
+	case warnRows > 0 && e.Rows > warnRows:
+		e.Verdict = CostWarn
+		e.Message = fmt.Sprintf("the queries of the rule scan about %d rows per evaluation, more than the budget of %d rows", e.Rows, warnRows)
+	}
+}
+
+// QueryCost is the amount of data read by the queries of an evaluation
+type QueryCost struct {
+	Timestamp time.Time `json:"timestamp"`
+	ReadRows  uint64    `json:"readRows"`
+	ReadBytes uint64    `json:"readBytes"`
+}
+
+// costMeter adds up the progress of the clickhouse queries run with its
+// context, the queries of an evaluation can run concurrently
+type costMeter struct {
+	rows  atomic.Uint64
+	bytes atomic.Uint64
+}
+
+// withCostMeter returns a context whose clickhouse queries report the data
+// they read to the returned meter
+func withCostMeter(ctx context.Context) (context.Context, *costMeter) {
+	meter := &costMeter{}
+	return clickhouse.Context(ctx, clickhouse.WithProgress(func(p *clickhouse.Progress) {
+		meter.rows.Add(p.Rows)
+		meter.bytes.Add(p.Bytes)
+	})), meter
+}
+
+func (m *costMeter) cost(ts time.Time) QueryCost {
+	return QueryCost{Timestamp: ts, ReadRows: m.rows.Load(), ReadBytes: m.bytes.Load()}
+}
+
+// QueryCostStats is the query cost of a rule over its recent evaluations
+type QueryCostStats struct {
+	Evaluations  []QueryCost `json:"evaluations"`
+	Count        int         `json:"count"`
+	TotalRows    uint64      `json:"totalRows"`
+	TotalBytes   uint64      `json:"totalBytes"`
+	AvgReadRows  uint64      `json:"avgReadRows"`
+	AvgReadBytes uint64      `json:"avgReadBytes"`
+	MaxReadRows  uint64      `json:"maxReadRows"`
+	MaxReadBytes uint64      `json:"maxReadBytes"`
+}
+
+// queryCostTracker keeps the query cost of the last evaluations of every
+// rule
+type queryCostTracker struct {
+	mtx   sync.Mutex
+	costs map[string][]QueryCost
+}
+
+func newQueryCostTracker() *queryCostTracker {
+	return &queryCostTracker{costs: map[string][]QueryCost{}}
+}
+
+func (t *queryCostTracker) record(ruleID string, cost QueryCost) {
+	if t == nil {
+		return
+	}
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	costs := append(t.costs[ruleID], cost)
+	if len(costs) > maxQueryCostHistory {
+		costs = costs[len(costs)-maxQueryCostHistory:]
+	}
+	t.costs[ruleID] = costs
+}
+
+func (t *queryCostTracker) forget(ruleID string) {
+	if t == nil {
+		return
+	}
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	delete(t.costs, ruleID)
+}
+
+func (t *queryCostTracker) stats(ruleID string) QueryCostStats {
+	stats := QueryCostStats{Evaluations: []QueryCost{}}
+	if t == nil {
+		return stats
+	}
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	for _, c := range t.costs[ruleID] {
+		stats.Evaluations = append(stats.Evaluations, c)
+		stats.TotalRows += c.ReadRows
+		stats.TotalBytes += c.ReadBytes
+		if c.ReadRows > stats.MaxReadRows {
+			stats.MaxReadRows = c.ReadRows
+		}
+		if c.ReadBytes > stats.MaxReadBytes {
+			stats.MaxReadBytes = c.ReadBytes
+		}
+	}
+	stats.Count = len(stats.Evaluations)
+	if stats.Count > 0 {
+		stats.AvgReadRows = stats.TotalRows / uint64(stats.Count)
+		stats.AvgReadBytes = stats.TotalBytes / uint64(stats.Count)
+	}
+	return stats
+}
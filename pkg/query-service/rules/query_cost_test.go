@@ -0,0 +1,90 @@
+package rules
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cmock "github.com/srikanthccv/ClickHouse-go-mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func TestThresholdRuleEstimateCost(t *testing.T) {
+	target := 1.0
+	postableRule := PostableRule{
+		AlertName:  "Errors",
+		AlertType:  "LOGS_BASED_ALERT",
+		RuleType:   RuleTypeThreshold,
+		EvalWindow: Duration(5 * time.Minute),
+		Frequency:  Duration(1 * time.Minute),
+		RuleCondition: &RuleCondition{
+			CompositeQuery: &v3.CompositeQuery{
+				QueryType: v3.QueryTypeClickHouseSQL,
+				ClickHouseQueries: map[string]*v3.ClickHouseQuery{
+					"A": {Query: "SELECT count() FROM signoz_logs.distributed_logs WHERE timestamp > {{.start_timestamp_nano}}"},
+					"B": {Query: "SELECT 1", Disabled: true},
+				},
+			},
+			CompareOp: ValueIsAbove,
+			MatchType: AtleastOnce,
+			Target:    &target,
+		},
+	}
+	rule, err := NewThresholdRule("8", &postableRule, ThresholdRuleOpts{}, nil, nil)
+	require.NoError(t, err)
+
+	mock, err := cmock.NewClickHouseWithQueryMatcher(nil, &queryMatcherAny{})
+	require.NoError(t, err)
+	cols := []cmock.ColumnType{
+		{Name: "database", Type: "String"},
+		{Name: "table", Type: "String"},
+		{Name: "parts", Type: "UInt64"},
+		{Name: "rows", Type: "UInt64"},
+		{Name: "marks", Type: "UInt64"},
+	}
+	mock.ExpectQuery("EXPLAIN ESTIMATE").WillReturnRows(cmock.NewRows(cols, [][]interface{}{
+		{"signoz_logs", "logs", uint64(2), uint64(120000), uint64(15)},
+		{"signoz_logs", "logs_v2", uint64(1), uint64(30000), uint64(4)},
+	}))
+
+	estimate, err := rule.EstimateCost(context.Background(), time.Now(), &Queriers{Ch: mock})
+	require.NoError(t, err)
+	require.Len(t, estimate.Queries, 1)
+	assert.Equal(t, "A", estimate.Queries[0].Name)
+	assert.NotContains(t, estimate.Queries[0].Query, "{{")
+	assert.Equal(t, uint64(3), estimate.Queries[0].Parts)
+	assert.Equal(t, uint64(150000), estimate.Rows)
+	assert.Equal(t, uint64(19), estimate.Marks)
+
+	estimate.applyBudget(0, 0)
+	assert.Equal(t, CostWithinBudget, estimate.Verdict)
+	estimate.applyBudget(100000, 0)
+	assert.Equal(t, CostWarn, estimate.Verdict)
+	estimate.applyBudget(100000, 140000)
+	assert.Equal(t, CostDeny, estimate.Verdict)
+	assert.NotEmpty(t, estimate.Message)
+}
+
+func TestQueryCostTracker(t *testing.T) {
+	tracker := newQueryCostTracker()
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < maxQueryCostHistory+10; i++ {
+		tracker.record("1", QueryCost{Timestamp: ts.Add(time.Duration(i) * time.Minute), ReadRows: uint64(i), ReadBytes: uint64(10 * i)})
+	}
+
+	stats := tracker.stats("1")
+	assert.Equal(t, maxQueryCostHistory, stats.Count)
+	assert.Equal(t, ts.Add(10*time.Minute), stats.Evaluations[0].Timestamp)
+	assert.Equal(t, uint64(69), stats.MaxReadRows)
+	assert.Equal(t, uint64(690), stats.MaxReadBytes)
+	assert.Equal(t, uint64(39), stats.AvgReadRows)
+
+	tracker.forget("1")
+	assert.Equal(t, QueryCostStats{Evaluations: []QueryCost{}}, tracker.stats("1"))
+
+	var nilTracker *queryCostTracker
+	nilTracker.record("1", QueryCost{})
+	assert.Equal(t, 0, nilTracker.stats("1").Count)
+}
@@ -0,0 +1,93 @@
+package rules
+
+import (
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/converter"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// QueryNameLabel is the label of the alerts of a rule with query
+// thresholds, it holds the name of the query that crossed its threshold
+const QueryNameLabel = "queryName"
+
+// queryCondition returns the condition of the query threshold, with the
+// target converted to the unit of the query
+func (r *ThresholdRule) queryCondition(t QueryThreshold) (thresholdCondition, string) {
+	unit := r.queryUnit(t.QueryName)
+	cond := thresholdCondition{op: t.CompareOp, match: t.MatchType}
+	if cond.op == "" {
+		cond.op = r.compareOp()
+	}
+	if cond.match == "" {
+		cond.match = r.matchType()
+	}
+	if t.Target != nil {
+		cond.target = converter.Convert(converter.Value{
+			F: *t.Target,
+			U: converter.Unit(t.TargetUnit),
+		}, converter.Unit(unit)).F * r.calendarFactor.get()
+	}
+	return cond, unit
+}
+
+// queryThresholdsVector compares the series of every query of the query
+// thresholds with the threshold of the query. The samples are labeled with
+// the name of the query so that every query raises its own alerts.
+func (r *ThresholdRule) queryThresholdsVector(results []*v3.Result) (Vector, error) {
+	byName := make(map[string]*v3.Result, len(results))
+	for _, res := range results {
+		byName[res.QueryName] = res
+	}
+
+	var series []*v3.Series
+	for _, t := range r.ruleCondition.QueryThresholds {
+		if res, ok := byName[t.QueryName]; ok {
+			series = append(series, res.Series...)
+		}
+	}
+	if len(series) > 0 {
+		r.lastTimestampWithDatapoints = time.Now()
+	}
+	r.mtx.Lock()
+	r.samplesReturned = len(series)
+	r.lastEvaluation.series = len(series)
+	r.lastEvaluation.samples = seriesSamples(series)
+	r.mtx.Unlock()
+
+	if err := r.checkSeriesLimit(len(series)); err != nil {
+		r.SetHealth(HealthBad)
+		return nil, err
+	}
+
+	if absent, ok := r.absentVector(); ok {
+		return absent, nil
+	}
+
+	var resultVector Vector
+	queryValues := r.reduceQueryResults(results)
+	for _, t := range r.ruleCondition.QueryThresholds {
+		res, ok := byName[t.QueryName]
+		if !ok {
+			continue
+		}
+		cond, unit := r.queryCondition(t)
+		for _, s := range res.Series {
+			smpl, shouldAlert := shouldAlertWith(*s, cond)
+			if !shouldAlert {
+				continue
+			}
+			smpl.Metric = labels.NewBuilder(smpl.Metric).Set(QueryNameLabel, t.QueryName).Labels()
+			smpl.MetricOrig = labels.NewBuilder(smpl.MetricOrig).Set(QueryNameLabel, t.QueryName).Labels()
+			smpl.SeriesPoints = s.Points
+			smpl.QueryValues = queryValues.valuesFor(s.Labels)
+			smpl.QueryValues[t.QueryName] = smpl.V
+			target := cond.target
+			smpl.Threshold = &target
+			smpl.Unit = unit
+			resultVector = append(resultVector, smpl)
+		}
+	}
+	return resultVector, nil
+}
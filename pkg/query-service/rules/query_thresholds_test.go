@@ -0,0 +1,70 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func TestQueryThresholdsVector(t *testing.T) {
+	rule, err := ParsePostableRule([]byte(`{
+		"alert": "service health",
+		"ruleType": "threshold_rule",
+		"condition": {
+			"compositeQuery": {
+				"queryType": "builder",
+				"panelType": "graph",
+				"builderQueries": {
+					"A": {"queryName": "A", "dataSource": "traces", "aggregateOperator": "count", "stepInterval": 60},
+					"B": {"queryName": "B", "dataSource": "traces", "aggregateOperator": "p99", "stepInterval": 60}
+				}
+			},
+			"queryUnits": {"A": "cpm", "B": "ms"},
+			"queryThresholds": [
+				{"queryName": "A", "target": 100},
+				{"queryName": "B", "target": 2, "targetUnit": "s"}
+			],
+			"op": "1",
+			"matchType": "1"
+		}
+	}`))
+	require.NoError(t, err)
+	require.NoError(t, rule.Validate())
+
+	tr, err := NewThresholdRule("1", rule, ThresholdRuleOpts{}, nil, nil)
+	require.NoError(t, err)
+
+	series := func(value float64) []*v3.Series {
+		return []*v3.Series{{
+			Labels: map[string]string{"service": "frontend"},
+			Points: []v3.Point{{Timestamp: 1, Value: value}},
+		}}
+	}
+	vector, err := tr.queryThresholdsVector([]*v3.Result{
+		{QueryName: "A", Series: series(150)},
+		{QueryName: "B", Series: series(2500)},
+	})
+	require.NoError(t, err)
+	require.Len(t, vector, 2)
+
+	assert.Equal(t, "A", vector[0].Metric.Get(QueryNameLabel))
+	assert.Equal(t, 100.0, *vector[0].Threshold)
+	assert.Equal(t, "cpm", vector[0].Unit)
+	// the target of B is converted to the unit of the query
+	assert.Equal(t, "B", vector[1].Metric.Get(QueryNameLabel))
+	assert.Equal(t, 2000.0, *vector[1].Threshold)
+	assert.NotEqual(t, vector[0].Metric.Hash(), vector[1].Metric.Hash())
+
+	vector, err = tr.queryThresholdsVector([]*v3.Result{
+		{QueryName: "A", Series: series(50)},
+		{QueryName: "B", Series: series(2500)},
+	})
+	require.NoError(t, err)
+	require.Len(t, vector, 1)
+	assert.Equal(t, "B", vector[0].MetricOrig.Get(QueryNameLabel))
+
+	rule.RuleCondition.QueryThresholds = append(rule.RuleCondition.QueryThresholds, QueryThreshold{QueryName: "C"})
+	assert.Error(t, rule.Validate())
+}
@@ -0,0 +1,384 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.uber.org/zap"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// QuotaLimit is a limit of a team, a warning is logged over Warn and
+// Max is enforced. Zero is unlimited.
+type QuotaLimit struct {
+	Warn int `yaml:"warn,omitempty" json:"warn,omitempty"`
+	Max  int `yaml:"max,omitempty" json:"max,omitempty"`
+}
+
+// exceeds tells whether the usage is over the warning and over the max
+func (l QuotaLimit) exceeds(usage int) (warn bool, max bool) {
+	return l.Warn > 0 && usage > l.Warn, l.Max > 0 && usage > l.Max
+}
+
+// TeamQuota limits the rules of a team
+type TeamQuota struct {
+	Rules               QuotaLimit `yaml:"rules,omitempty" json:"rules"`
+	ActiveAlerts        QuotaLimit `yaml:"activeAlerts,omitempty" json:"activeAlerts"`
+	NotificationsPerDay QuotaLimit `yaml:"notificationsPerDay,omitempty" json:"notificationsPerDay"`
+}
+
+// TeamQuotasConfig is the configuration of the quotas of the teams. The
+// team of a rule is the value of its team label, the rules without the
+// label are not limited. The teams without a quota get the default one.
+type TeamQuotasConfig struct {
+	TeamLabel string               `yaml:"teamLabel"`
+	Default   TeamQuota            `yaml:"default"`
+	Teams     map[string]TeamQuota `yaml:"teams"`
+}
+
+// TeamQuotas enforces the quotas of the teams and counts their
+// notifications of the day
+type TeamQuotas struct {
+	cfg TeamQuotasConfig
+	now func() time.Time
+
+	mtx sync.Mutex
+	// day is the UTC day the notifications are counted for
+	day           string
+	notifications map[string]int
+	// warned holds the teams warned today about their notifications
+	warned map[string]bool
+	// firing holds the firing alerts of every rule by team as of its last
+	// evaluation, the notifications read them without the lock of the
+	// rules, which may be held while the rule tasks stop
+	firing map[string]map[string][]teamAlert
+}
+
+// LoadTeamQuotas reads the quotas of the teams from the yaml file
+func LoadTeamQuotas(path string) (*TeamQuotas, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the team quotas: %w", err)
+	}
+	var cfg TeamQuotasConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse the team quotas: %w", err)
+	}
+	return NewTeamQuotas(cfg)
+}
+
+func NewTeamQuotas(cfg TeamQuotasConfig) (*TeamQuotas, error) {
+	if cfg.TeamLabel == "" {
+		cfg.TeamLabel = "team"
+	}
+	check := func(team string, q TeamQuota) error {
+		for name, l := range map[string]QuotaLimit{"rules": q.Rules, "activeAlerts": q.ActiveAlerts, "notificationsPerDay": q.NotificationsPerDay} {
+			if l.Warn < 0 || l.Max < 0 {
+				return fmt.Errorf("negative %s quota of team %q", name, team)
+			}
+			if l.Warn > 0 && l.Max > 0 && l.Warn > l.Max {
+				return fmt.Errorf("the %s warning of team %q is over the max", name, team)
+			}
+		}
+		return nil
+	}
+	if err := check("default", cfg.Default); err != nil {
+		return nil, err
+	}
+	for team, q := range cfg.Teams {
+		if err := check(team, q); err != nil {
+			return nil, err
+		}
+	}
+	return &TeamQuotas{
+		cfg:           cfg,
+		now:           time.Now,
+		notifications: map[string]int{},
+		warned:        map[string]bool{},
+		firing:        map[string]map[string][]teamAlert{},
+	}, nil
+}
+
+// quota returns the quota of the team
+func (q *TeamQuotas) quota(team string) TeamQuota {
+	if quota, ok := q.cfg.Teams[team]; ok {
+		return quota
+	}
+	return q.cfg.Default
+}
+
+// rollDay resets the notification counts on a new day, the lock is held
+func (q *TeamQuotas) rollDay() {
+	day := q.now().UTC().Format(time.DateOnly)
+	if day != q.day {
+		q.day = day
+		q.notifications = map[string]int{}
+		q.warned = map[string]bool{}
+	}
+}
+
+// teamAlert is a firing alert of a team
+type teamAlert struct {
+	fingerprint uint64
+	activeAt    time.Time
+}
+
+// observe records the firing alerts of the rule after its evaluation
+func (q *TeamQuotas) observe(ruleID string, alerts []*Alert) {
+	firing := map[string][]teamAlert{}
+	for _, a := range alerts {
+		if a.State != StateFiring {
+			continue
+		}
+		if team := a.Labels.Get(q.cfg.TeamLabel); team != "" {
+			firing[team] = append(firing[team], teamAlert{fingerprint: a.Labels.Hash(), activeAt: a.ActiveAt})
+		}
+	}
+
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	if len(firing) == 0 {
+		delete(q.firing, ruleID)
+		return
+	}
+	q.firing[ruleID] = firing
+}
+
+// forget drops the firing alerts of a deleted rule
+func (q *TeamQuotas) forget(ruleID string) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	delete(q.firing, ruleID)
+}
+
+// firingAlerts returns the firing alerts of every team of the visible
+// rules, of all the rules when visible is nil
+func (q *TeamQuotas) firingAlerts(visible map[string]bool) map[string][]teamAlert {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	firing := map[string][]teamAlert{}
+	for ruleID, teams := range q.firing {
+		if visible != nil && !visible[ruleID] {
+			continue
+		}
+		for team, alerts := range teams {
+			firing[team] = append(firing[team], alerts...)
+		}
+	}
+	return firing
+}
+
+// observeTeamAlerts records the firing alerts of the rule for the active
+// alerts quotas of the teams
+func observeTeamAlerts(opts *ManagerOptions, rule Rule) {
+	if opts.TeamQuotas != nil {
+		opts.TeamQuotas.observe(rule.ID(), rule.ActiveAlerts())
+	}
+}
+
+// filterNotifications drops the notifications over the quotas of the
+// teams. Only the oldest firing alerts of a team within its active alerts
+// quota are notified, and the notifications over the quota of the day are
+// dropped. Resolved alerts are always sent.
+func (q *TeamQuotas) filterNotifications(alerts []*Alert, active map[string][]teamAlert) []*Alert {
+	allowed := map[uint64]bool{}
+	for team, firing := range active {
+		max := q.quota(team).ActiveAlerts.Max
+		if max == 0 || len(firing) <= max {
+			continue
+		}
+		sort.Slice(firing, func(i, j int) bool { return firing[i].activeAt.Before(firing[j].activeAt) })
+		for _, a := range firing[:max] {
+			allowed[a.fingerprint] = true
+		}
+	}
+
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	q.rollDay()
+
+	res := make([]*Alert, 0, len(alerts))
+	for _, a := range alerts {
+		team := a.Labels.Get(q.cfg.TeamLabel)
+		if team == "" || !a.ResolvedAt.IsZero() {
+			res = append(res, a)
+			continue
+		}
+		quota := q.quota(team)
+		if _, over := quota.ActiveAlerts.exceeds(len(active[team])); over && !allowed[a.Labels.Hash()] {
+			zap.L().Debug("dropping the notification over the active alerts quota", zap.String("team", team))
+			continue
+		}
+		warn, over := quota.NotificationsPerDay.exceeds(q.notifications[team] + 1)
+		if over {
+			zap.L().Debug("dropping the notification over the daily quota", zap.String("team", team))
+			continue
+		}
+		if warn && !q.warned[team] {
+			q.warned[team] = true
+			zap.L().Warn("team is over the warning of its daily notifications", zap.String("team", team), zap.Int("warn", quota.NotificationsPerDay.Warn))
+		}
+		q.notifications[team]++
+		res = append(res, a)
+	}
+	return res
+}
+
+// notificationsToday returns the notifications sent today by team
+func (q *TeamQuotas) notificationsToday() map[string]int {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	q.rollDay()
+	counts := make(map[string]int, len(q.notifications))
+	for team, n := range q.notifications {
+		counts[team] = n
+	}
+	return counts
+}
+
+// ruleLabels is what the quotas read from a stored rule
+type ruleLabels struct {
+	Labels map[string]string `json:"labels"`
+}
+
+// teamRules counts the stored rules of every team visible to the org,
+// all the orgs when empty, without the rule being edited
+func (m *Manager) teamRules(ctx context.Context, label string, exclude string, org string) (map[string]int, error) {
+	storedRules, err := m.ruleDB.GetStoredRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	for _, stored := range storedRules {
+		if strconv.Itoa(stored.Id) == exclude || !visibleTo(stored.OrgId, org) {
+			continue
+		}
+		var r ruleLabels
+		if err := json.Unmarshal([]byte(stored.Data), &r); err != nil {
+			continue
+		}
+		if team := r.Labels[label]; team != "" {
+			counts[team]++
+		}
+	}
+	return counts, nil
+}
+
+// checkTeamQuota refuses the rule when its team has reached its rules
+// quota, id is the rule being edited
+func (m *Manager) checkTeamQuota(ctx context.Context, rule *PostableRule, id string) error {
+	q := m.opts.TeamQuotas
+	if q == nil {
+		return nil
+	}
+	team := rule.Labels[q.cfg.TeamLabel]
+	if team == "" {
+		return nil
+	}
+	limit := q.quota(team).Rules
+	if limit.Warn == 0 && limit.Max == 0 {
+		return nil
+	}
+	counts, err := m.teamRules(ctx, q.cfg.TeamLabel, id, "")
+	if err != nil {
+		return err
+	}
+	warn, over := limit.exceeds(counts[team] + 1)
+	if over {
+		return fmt.Errorf("team %s has reached its quota of %d rules", team, limit.Max)
+	}
+	if warn {
+		zap.L().Warn("team is over the warning of its rules quota", zap.String("team", team), zap.Int("rules", counts[team]+1), zap.Int("warn", limit.Warn))
+	}
+	return nil
+}
+
+// filterTeamQuotas drops the notifications over the quotas of the teams
+func (m *Manager) filterTeamQuotas(alerts []*Alert) []*Alert {
+	q := m.opts.TeamQuotas
+	if q == nil || len(alerts) == 0 {
+		return alerts
+	}
+	return q.filterNotifications(alerts, q.firingAlerts(nil))
+}
+
+// TeamUsage is the usage of the quota of a team
+type TeamUsage struct {
+	Team               string    `json:"team"`
+	Quota              TeamQuota `json:"quota"`
+	Rules              int       `json:"rules"`
+	ActiveAlerts       int       `json:"activeAlerts"`
+	NotificationsToday int       `json:"notificationsToday"`
+	// Warnings name the quotas over their warning, Exceeded the quotas
+	// refusing the next rule or notification and the active alerts over
+	// their max, which are not notified
+	Warnings []string `json:"warnings"`
+	Exceeded []string `json:"exceeded"`
+}
+
+// TeamQuotaUsage returns the usage of the quotas of the configured teams
+// and of the teams having rules. The requests of a user only count the
+// rules and alerts of their org.
+func (m *Manager) TeamQuotaUsage(ctx context.Context) ([]TeamUsage, *model.ApiError) {
+	q := m.opts.TeamQuotas
+	if q == nil {
+		return nil, &model.ApiError{Typ: model.ErrorUnavailable, Err: fmt.Errorf("the team quotas are not configured")}
+	}
+	visible, err := m.tenantRules(ctx)
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	rules, err := m.teamRules(ctx, q.cfg.TeamLabel, "", tenantOf(ctx))
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	firing := q.firingAlerts(visible)
+	notifications := q.notificationsToday()
+
+	teams := map[string]bool{}
+	for team := range q.cfg.Teams {
+		teams[team] = true
+	}
+	for team := range rules {
+		teams[team] = true
+	}
+
+	usage := make([]TeamUsage, 0, len(teams))
+	for team := range teams {
+		u := TeamUsage{
+			Team:               team,
+			Quota:              q.quota(team),
+			Rules:              rules[team],
+			ActiveAlerts:       len(firing[team]),
+			NotificationsToday: notifications[team],
+			Warnings:           []string{},
+			Exceeded:           []string{},
+		}
+		for _, c := range []struct {
+			name  string
+			limit QuotaLimit
+			usage int
+		}{
+			{"rules", u.Quota.Rules, u.Rules + 1},
+			{"activeAlerts", u.Quota.ActiveAlerts, u.ActiveAlerts},
+			{"notificationsPerDay", u.Quota.NotificationsPerDay, u.NotificationsToday + 1},
+		} {
+			warn, over := c.limit.exceeds(c.usage)
+			if over {
+				u.Exceeded = append(u.Exceeded, c.name)
+			} else if warn {
+				u.Warnings = append(u.Warnings, c.name)
+			}
+		}
+		usage = append(usage, u)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Team < usage[j].Team })
+	return usage, nil
+}
@@ -0,0 +1,66 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+func TestTeamQuotasFilterNotifications(t *testing.T) {
+	q, err := NewTeamQuotas(TeamQuotasConfig{
+		Default: TeamQuota{NotificationsPerDay: QuotaLimit{Warn: 1, Max: 2}},
+		Teams: map[string]TeamQuota{
+			"payments": {ActiveAlerts: QuotaLimit{Max: 1}},
+		},
+	})
+	require.NoError(t, err)
+	now := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	q.now = func() time.Time { return now }
+
+	alert := func(team, name string) *Alert {
+		return &Alert{Labels: labels.FromStrings("team", team, "alertname", name)}
+	}
+
+	// only the oldest firing alert of payments is notified
+	old, recent := alert("payments", "old"), alert("payments", "recent")
+	active := map[string][]teamAlert{"payments": {
+		{fingerprint: recent.Labels.Hash(), activeAt: now.Add(-time.Minute)},
+		{fingerprint: old.Labels.Hash(), activeAt: now.Add(-time.Hour)},
+	}}
+	assert.Equal(t, []*Alert{old}, q.filterNotifications([]*Alert{old, recent}, active))
+
+	// the resolved alerts and the alerts without a team are always sent
+	resolved := alert("payments", "recent")
+	resolved.ResolvedAt = now
+	unowned := &Alert{Labels: labels.FromStrings("alertname", "x")}
+	assert.Len(t, q.filterNotifications([]*Alert{resolved, unowned}, active), 2)
+
+	// the other teams get two notifications a day
+	web := []*Alert{alert("web", "a"), alert("web", "b"), alert("web", "c")}
+	assert.Len(t, q.filterNotifications(web, nil), 2)
+	assert.Empty(t, q.filterNotifications(web[:1], nil))
+	assert.Equal(t, 2, q.notificationsToday()["web"])
+
+	now = now.Add(24 * time.Hour)
+	assert.Len(t, q.filterNotifications(web[:1], nil), 1)
+
+	// the firing alerts are those of the last evaluation of the rules
+	firing := func(team string, activeAt time.Time) *Alert {
+		return &Alert{State: StateFiring, Labels: labels.FromStrings("team", team), ActiveAt: activeAt}
+	}
+	q.observe("1", []*Alert{firing("payments", now), firing("web", now), {State: StatePending, Labels: labels.FromStrings("team", "web")}})
+	q.observe("2", []*Alert{firing("payments", now.Add(time.Minute))})
+	assert.Len(t, q.firingAlerts(nil)["payments"], 2)
+	assert.Len(t, q.firingAlerts(nil)["web"], 1)
+	assert.Len(t, q.firingAlerts(map[string]bool{"2": true})["payments"], 1)
+	assert.Empty(t, q.firingAlerts(map[string]bool{"2": true})["web"])
+	q.observe("1", nil)
+	q.forget("2")
+	assert.Empty(t, q.firingAlerts(nil))
+
+	_, err = NewTeamQuotas(TeamQuotasConfig{Teams: map[string]TeamQuota{"web": {Rules: QuotaLimit{Warn: 5, Max: 2}}}})
+	assert.Error(t, err)
+}
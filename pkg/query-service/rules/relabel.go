@@ -0,0 +1,187 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// RelabelAction is the action of a relabel config
+type RelabelAction string
+
+const (
+	// RelabelReplace sets the target label to the replacement when the
+	// joined source labels match the regex
+	RelabelReplace RelabelAction = "replace"
+	// RelabelKeep drops the series whose joined source labels do not
+	// match the regex
+	RelabelKeep RelabelAction = "keep"
+	// RelabelDrop drops the series whose joined source labels match the
+	// regex
+	RelabelDrop RelabelAction = "drop"
+	// RelabelLabelDrop removes the labels whose name matches the regex
+	RelabelLabelDrop RelabelAction = "labeldrop"
+	// RelabelLabelKeep removes the labels whose name does not match the
+	// regex
+	RelabelLabelKeep RelabelAction = "labelkeep"
+	// RelabelLabelMap copies the labels whose name matches the regex to
+	// the replacement, e.g. pod_(.+) to $1
+	RelabelLabelMap RelabelAction = "labelmap"
+)
+
+// RelabelConfig rewrites the labels of the series of a rule before the
+// alerts are created, the same way as the relabel configs of prometheus
+type RelabelConfig struct {
+	SourceLabels []string      `yaml:"sourceLabels,omitempty" json:"sourceLabels,omitempty"`
+	Separator    string        `yaml:"separator,omitempty" json:"separator,omitempty"`
+	Regex        string        `yaml:"regex,omitempty" json:"regex,omitempty"`
+	TargetLabel  string        `yaml:"targetLabel,omitempty" json:"targetLabel,omitempty"`
+	Replacement  *string       `yaml:"replacement,omitempty" json:"replacement,omitempty"`
+	Action       RelabelAction `yaml:"action,omitempty" json:"action,omitempty"`
+}
+
+// relabeler is a compiled relabel config
+type relabeler struct {
+	RelabelConfig
+	regex *regexp.Regexp
+}
+
+// compileRelabelConfigs checks the relabel configs and compiles their
+// regexes, the regexes are anchored
+func compileRelabelConfigs(configs []RelabelConfig) ([]relabeler, error) {
+	compiled := make([]relabeler, 0, len(configs))
+	for i, c := range configs {
+		if c.Action == "" {
+			c.Action = RelabelReplace
+		}
+		if c.Separator == "" {
+			c.Separator = ";"
+		}
+		if c.Regex == "" {
+			c.Regex = "(.*)"
+		}
+		if c.Replacement == nil {
+			replacement := "$1"
+			c.Replacement = &replacement
+		}
+		re, err := regexp.Compile("^(?:" + c.Regex + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("relabel config %d: invalid regex: %w", i, err)
+		}
+		switch c.Action {
+		case RelabelReplace:
+			if c.TargetLabel == "" {
+				return nil, fmt.Errorf("relabel config %d: replace requires a target label", i)
+			}
+			if len(c.SourceLabels) == 0 {
+				return nil, fmt.Errorf("relabel config %d: replace requires source labels", i)
+			}
+		case RelabelKeep, RelabelDrop:
+			if len(c.SourceLabels) == 0 {
+				return nil, fmt.Errorf("relabel config %d: %s requires source labels", i, c.Action)
+			}
+		case RelabelLabelDrop, RelabelLabelKeep, RelabelLabelMap:
+		default:
+			return nil, fmt.Errorf("relabel config %d: unknown action %q", i, c.Action)
+		}
+		compiled = append(compiled, relabeler{RelabelConfig: c, regex: re})
+	}
+	return compiled, nil
+}
+
+// relabel applies the relabel configs to the labels, it returns false
+// when the series is dropped
+func relabel(lbls labels.Labels, configs []relabeler) (labels.Labels, bool) {
+	for _, c := range configs {
+		values := make([]string, 0, len(c.SourceLabels))
+		for _, name := range c.SourceLabels {
+			values = append(values, lbls.Get(name))
+		}
+		value := strings.Join(values, c.Separator)
+
+		switch c.Action {
+		case RelabelKeep:
+			if !c.regex.MatchString(value) {
+				return nil, false
+			}
+		case RelabelDrop:
+			if c.regex.MatchString(value) {
+				return nil, false
+			}
+		case RelabelReplace:
+			match := c.regex.FindStringSubmatchIndex(value)
+			if match == nil {
+				continue
+			}
+			target := string(c.regex.ExpandString(nil, c.TargetLabel, value, match))
+			replacement := string(c.regex.ExpandString(nil, *c.Replacement, value, match))
+			b := labels.NewBuilder(lbls)
+			if replacement == "" {
+				b.Del(target)
+			} else {
+				b.Set(target, replacement)
+			}
+			lbls = b.Labels()
+		case RelabelLabelDrop, RelabelLabelKeep:
+			b := labels.NewBuilder(lbls)
+			for _, l := range lbls {
+				if c.regex.MatchString(l.Name) == (c.Action == RelabelLabelDrop) {
+					b.Del(l.Name)
+				}
+			}
+			lbls = b.Labels()
+		case RelabelLabelMap:
+			b := labels.NewBuilder(lbls)
+			for _, l := range lbls {
+				if match := c.regex.FindStringSubmatchIndex(l.Name); match != nil {
+					b.Set(string(c.regex.ExpandString(nil, *c.Replacement, l.Name, match)), l.Value)
+				}
+			}
+			lbls = b.Labels()
+		}
+	}
+	return lbls, true
+}
+
+// relabelVector relabels the samples of the rule. The samples that end up
+// with the same labels, e.g. the pods of a workload once the pod label is
+// dropped, are merged into the sample furthest past the threshold.
+func (r *ThresholdRule) relabelVector(vector Vector) Vector {
+	if len(r.relabelers) == 0 {
+		return vector
+	}
+	relabeled := make(Vector, 0, len(vector))
+	byHash := map[uint64]int{}
+	for _, smpl := range vector {
+		lbls, ok := relabel(smpl.Metric, r.relabelers)
+		if !ok {
+			continue
+		}
+		smpl.Metric = lbls
+		h := lbls.Hash()
+		i, ok := byHash[h]
+		if !ok {
+			byHash[h] = len(relabeled)
+			relabeled = append(relabeled, smpl)
+			continue
+		}
+		if r.worse(smpl, relabeled[i]) {
+			relabeled[i] = smpl
+		}
+	}
+	return relabeled
+}
+
+// worse tells whether the sample a is further past the threshold than b
+func (r *ThresholdRule) worse(a, b Sample) bool {
+	switch {
+	case a.IsMissing || b.IsMissing:
+		return false
+	case r.compareOp() == ValueIsBelow:
+		return a.V < b.V
+	default:
+		return a.V > b.V
+	}
+}
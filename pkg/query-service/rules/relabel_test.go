@@ -0,0 +1,74 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+func TestRelabel(t *testing.T) {
+	empty := ""
+	configs, err := compileRelabelConfigs([]RelabelConfig{
+		{SourceLabels: []string{"namespace"}, Regex: "kube-.*", Action: RelabelDrop},
+		{SourceLabels: []string{"pod"}, Regex: "(.+)-[a-z0-9]+-[a-z0-9]{5}", TargetLabel: "workload"},
+		{Regex: "pod|instance", Action: RelabelLabelDrop},
+		{Regex: "k8s_(.+)", Action: RelabelLabelMap},
+		{SourceLabels: []string{"k8s_node"}, TargetLabel: "k8s_node", Replacement: &empty},
+	})
+	require.NoError(t, err)
+
+	lbls, ok := relabel(labels.FromMap(map[string]string{
+		"namespace": "shop",
+		"pod":       "checkout-7d9f8b6c4-x2k9p",
+		"instance":  "10.0.0.1:8080",
+		"k8s_node":  "node-1",
+	}), configs)
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{"namespace": "shop", "workload": "checkout", "node": "node-1"}, lbls.Map())
+
+	_, ok = relabel(labels.FromMap(map[string]string{"namespace": "kube-system"}), configs)
+	assert.False(t, ok)
+
+	_, err = compileRelabelConfigs([]RelabelConfig{{SourceLabels: []string{"pod"}}})
+	assert.Error(t, err)
+	_, err = compileRelabelConfigs([]RelabelConfig{{Regex: "(", Action: RelabelLabelDrop}})
+	assert.Error(t, err)
+}
+
+func TestThresholdRuleRelabelVector(t *testing.T) {
+	rule, err := ParsePostableRule([]byte(`{
+		"alert": "restarts",
+		"ruleType": "threshold_rule",
+		"condition": {
+			"compositeQuery": {
+				"queryType": "builder",
+				"panelType": "graph",
+				"builderQueries": {"A": {"queryName": "A", "dataSource": "metrics", "aggregateOperator": "sum", "stepInterval": 60}}
+			},
+			"op": "1",
+			"target": 1,
+			"matchType": "1"
+		},
+		"relabelConfigs": [{"regex": "pod", "action": "labeldrop"}]
+	}`))
+	require.NoError(t, err)
+	require.NoError(t, rule.Validate())
+
+	tr, err := NewThresholdRule("1", rule, ThresholdRuleOpts{}, nil, nil)
+	require.NoError(t, err)
+
+	vector := tr.relabelVector(Vector{
+		{Metric: labels.FromStrings("pod", "a", "service", "cart"), Point: Point{V: 2}},
+		{Metric: labels.FromStrings("pod", "b", "service", "cart"), Point: Point{V: 5}},
+		{Metric: labels.FromStrings("pod", "c", "service", "web"), Point: Point{V: 3}},
+	})
+	require.Len(t, vector, 2)
+	assert.Equal(t, labels.FromStrings("service", "cart"), vector[0].Metric)
+	assert.Equal(t, 5.0, vector[0].V)
+	assert.Equal(t, labels.FromStrings("service", "web"), vector[1].Metric)
+
+	rule.RelabelConfigs = []RelabelConfig{{Action: "rename"}}
+	assert.Error(t, rule.Validate())
+}
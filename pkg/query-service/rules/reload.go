@@ -0,0 +1,227 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// ReloadResult tells what a reload changed in the running rules
+type ReloadResult struct {
+	Added     []string `json:"added"`
+	Updated   []string `json:"updated"`
+	Removed   []string `json:"removed"`
+	Unchanged int      `json:"unchanged"`
+	// Errors holds the rules that could not be applied by id, they keep
+	// running with their previous definition
+	Errors map[string]string `json:"errors"`
+}
+
+// alertStoreHolder is implemented by the rules keeping their active
+// alerts in an AlertStore
+type alertStoreHolder interface {
+	alertStore() AlertStore
+}
+
+func (r *ThresholdRule) alertStore() AlertStore {
+	return r.active
+}
+
+func (r *PromRule) alertStore() AlertStore {
+	return r.active
+}
+
+// matchRules pairs the rules of a task with the rules of the task it
+// replaces, by id and then by name and labels. If there are duplicates,
+// the first is matched with the first, second with the second etc.
+func matchRules(to, from []Rule) map[int]int {
+	matched := make(map[int]int, len(to))
+	used := make(map[int]bool, len(from))
+
+	byID := make(map[string]int, len(from))
+	for fi, fromRule := range from {
+		if _, ok := byID[fromRule.ID()]; !ok {
+			byID[fromRule.ID()] = fi
+		}
+	}
+	for i, rule := range to {
+		if fi, ok := byID[rule.ID()]; ok && !used[fi] {
+			matched[i] = fi
+			used[fi] = true
+		}
+	}
+
+	byNameAndLabels := make(map[string][]int, len(from))
+	for fi, fromRule := range from {
+		if used[fi] {
+			continue
+		}
+		key := nameAndLabels(fromRule)
+		byNameAndLabels[key] = append(byNameAndLabels[key], fi)
+	}
+	for i, rule := range to {
+		if _, ok := matched[i]; ok {
+			continue
+		}
+		key := nameAndLabels(rule)
+		indexes := byNameAndLabels[key]
+		if len(indexes) == 0 {
+			continue
+		}
+		matched[i] = indexes[0]
+		byNameAndLabels[key] = indexes[1:]
+	}
+	return matched
+}
+
+// copyAlerts moves the active alerts of an edited rule to its new
+// definition. The fingerprint of an alert is the hash of its labels, the
+// alerts the new definition still produces keep their ActiveAt and
+// FiredAt, the others are resolved by the next evaluation.
+func copyAlerts(to, from Rule) {
+	dst, ok := to.(alertStoreHolder)
+	if !ok {
+		return
+	}
+	src, ok := from.(alertStoreHolder)
+	if !ok {
+		return
+	}
+	src.alertStore().Range(func(fp uint64, a *Alert) {
+		dst.alertStore().Set(fp, a)
+	})
+}
+
+// copyRulesState copies the active alerts of the matched rules of the
+// tasks, the tasks may be of different types when the rule changed its
+// query type
+func copyRulesState(to []Rule, from Task) {
+	fromRules := from.Rules()
+	for i, fi := range matchRules(to, fromRules) {
+		copyAlerts(to[i], fromRules[fi])
+	}
+}
+
+// parseStoredRule parses a rule from the rule db, older rules are stored
+// in yaml
+func parseStoredRule(data string) (*PostableRule, error) {
+	parsedRule, err := ParsePostableRule([]byte(data))
+	if errors.Is(err, ErrFailedToParseJSON) {
+		return parsePostableRule([]byte(data), RuleDataKindYaml)
+	}
+	return parsedRule, err
+}
+
+// ruleDigest identifies the definition a task runs, the same stored rule
+// always has the same digest
+func ruleDigest(rule *PostableRule) string {
+	b, err := json.Marshal(rule)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// Reload applies the rules of the rule db to the running tasks without a
+// restart. New and enabled rules are started, the edited ones replace
+// their task keeping the state of their alerts, and the deleted and
+// disabled ones are stopped. Unchanged rules are left running.
+func (m *Manager) Reload(ctx context.Context) (*ReloadResult, error) {
+	if err := m.ReloadTemplateSnippets(ctx); err != nil {
+		return nil, err
+	}
+	if err := m.ReloadCustomUnits(ctx); err != nil {
+		return nil, err
+	}
+	if err := m.ReloadFormatSettings(ctx); err != nil {
+		return nil, err
+	}
+	if err := m.ReloadCalendars(ctx); err != nil {
+		return nil, err
+	}
+	if err := m.ReloadServiceCatalog(ctx); err != nil {
+		return nil, err
+	}
+	storedRules, err := m.ruleDB.GetStoredRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.setTenants(storedRules)
+
+	result := &ReloadResult{
+		Added:   []string{},
+		Updated: []string{},
+		Removed: []string{},
+		Errors:  map[string]string{},
+	}
+	if m.opts.DisableRules {
+		return result, nil
+	}
+
+	stored := make(map[string]struct{}, len(storedRules))
+	for _, rec := range storedRules {
+		id := strconv.Itoa(rec.Id)
+		taskName := prepareTaskName(int64(rec.Id))
+		stored[taskName] = struct{}{}
+
+		parsedRule, err := parseStoredRule(rec.Data)
+		if err != nil {
+			result.Errors[id] = err.Error()
+			continue
+		}
+
+		m.mtx.RLock()
+		_, running := m.tasks[taskName]
+		digest := m.digests[taskName]
+		m.mtx.RUnlock()
+
+		switch {
+		case parsedRule.Disabled:
+			if running {
+				m.deleteTask(taskName)
+				result.Removed = append(result.Removed, id)
+			} else {
+				result.Unchanged++
+			}
+		case !running:
+			if err := m.addTask(parsedRule, taskName); err != nil {
+				result.Errors[id] = err.Error()
+				continue
+			}
+			result.Added = append(result.Added, id)
+		case digest != ruleDigest(parsedRule):
+			if err := m.editTask(parsedRule, taskName); err != nil {
+				result.Errors[id] = err.Error()
+				continue
+			}
+			result.Updated = append(result.Updated, id)
+		default:
+			result.Unchanged++
+		}
+	}
+
+	var deleted []string
+	m.mtx.RLock()
+	for taskName := range m.tasks {
+		if _, ok := stored[taskName]; !ok {
+			deleted = append(deleted, taskName)
+		}
+	}
+	m.mtx.RUnlock()
+	for _, taskName := range deleted {
+		m.deleteTask(taskName)
+		result.Removed = append(result.Removed, ruleIdFromTaskName(taskName))
+	}
+
+	zap.L().Info("reloaded the rules",
+		zap.Int("added", len(result.Added)),
+		zap.Int("updated", len(result.Updated)),
+		zap.Int("removed", len(result.Removed)),
+		zap.Int("unchanged", result.Unchanged),
+		zap.Int("errors", len(result.Errors)),
+	)
+	return result, nil
+}
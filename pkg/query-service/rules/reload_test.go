@@ -0,0 +1,76 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.uber.org/zap"
+)
+
+func reloadTestRule(name string, queryType v3.QueryType) *PostableRule {
+	target := 10.0
+	cq := &v3.CompositeQuery{QueryType: queryType}
+	ruleType := RuleType(RuleTypeThreshold)
+	if queryType == v3.QueryTypePromQL {
+		ruleType = RuleTypeProm
+		cq.PromQueries = map[string]*v3.PromQuery{"A": {Query: "up"}}
+	} else {
+		cq.ClickHouseQueries = map[string]*v3.ClickHouseQuery{"A": {Query: "SELECT 1"}}
+	}
+	return &PostableRule{
+		AlertName:  name,
+		AlertType:  "METRIC_BASED_ALERT",
+		RuleType:   ruleType,
+		EvalWindow: Duration(5 * time.Minute),
+		Frequency:  Duration(1 * time.Minute),
+		RuleCondition: &RuleCondition{
+			CompositeQuery: cq,
+			CompareOp:      ValueIsAbove,
+			MatchType:      AtleastOnce,
+			Target:         &target,
+		},
+	}
+}
+
+func TestCopyStateKeepsAlertsOfEditedRule(t *testing.T) {
+	oldRule, err := NewThresholdRule("3", reloadTestRule("High latency", v3.QueryTypeClickHouseSQL), ThresholdRuleOpts{}, nil, nil)
+	require.NoError(t, err)
+
+	activeAt := time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC)
+	firedAt := activeAt.Add(5 * time.Minute)
+	oldRule.active.Set(1, &Alert{State: StateFiring, ActiveAt: activeAt, FiredAt: firedAt})
+	oldRule.active.Set(2, &Alert{State: StatePending, ActiveAt: firedAt})
+
+	opts := &ManagerOptions{}
+	oldTask := newRuleTask("3-groupname", "", time.Minute, []Rule{oldRule}, opts, nil, nil)
+
+	// the rule is renamed and moved to promql, it is matched by its id
+	newRule, err := NewPromRule("3", reloadTestRule("High p99 latency", v3.QueryTypePromQL), zap.NewNop(), PromRuleOpts{}, nil)
+	require.NoError(t, err)
+	newTask := newPromRuleTask("3-groupname", "", time.Minute, []Rule{newRule}, opts, nil, nil)
+
+	require.NoError(t, newTask.CopyState(oldTask))
+
+	assert.Equal(t, 2, newRule.active.Len())
+	a, ok := newRule.active.Get(1)
+	require.True(t, ok)
+	assert.Equal(t, StateFiring, a.State)
+	assert.Equal(t, activeAt, a.ActiveAt)
+	assert.Equal(t, firedAt, a.FiredAt)
+}
+
+func TestMatchRules(t *testing.T) {
+	newRule := func(id, name string) Rule {
+		r, err := NewThresholdRule(id, reloadTestRule(name, v3.QueryTypeClickHouseSQL), ThresholdRuleOpts{}, nil, nil)
+		require.NoError(t, err)
+		return r
+	}
+
+	from := []Rule{newRule("1", "a"), newRule("2", "b"), newRule("9", "c")}
+	to := []Rule{newRule("2", "renamed"), newRule("7", "c"), newRule("8", "new")}
+
+	assert.Equal(t, map[int]int{0: 1, 1: 2}, matchRules(to, from))
+}
@@ -0,0 +1,230 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/snappy"
+	config_util "github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/storage/remote"
+	"go.uber.org/zap"
+)
+
+const (
+	remoteWriteQueueSize   = 10000
+	remoteWriteBatchSize   = 500
+	remoteWriteFlush       = 5 * time.Second
+	remoteWriteMaxAttempts = 3
+)
+
+// RemoteWriteOptions configures the remote write of the results of the
+// recording rules to an external TSDB, in addition to ClickHouse
+type RemoteWriteOptions struct {
+	// URL is the Prometheus remote write endpoint, e.g.
+	// https://mimir:9009/api/v1/push
+	URL      string
+	Headers  map[string]string
+	Username string
+	Password string
+	// Timeout of a write, thirty seconds when not set
+	Timeout time.Duration
+}
+
+// RecordedSample is a sample produced by a rule, the labels hold the
+// metric name as __name__
+type RecordedSample struct {
+	Labels    map[string]string
+	Value     float64
+	Timestamp time.Time
+}
+
+// RemoteWriter sends the recorded samples with the Prometheus remote
+// write protocol. The samples are queued and written in batches, the
+// samples of a full queue or of a batch the endpoint keeps failing are
+// dropped, the rules are never held up by the endpoint.
+type RemoteWriter struct {
+	client  remote.WriteClient
+	queue   chan RecordedSample
+	dropped atomic.Uint64
+
+	done       chan struct{}
+	terminated chan struct{}
+}
+
+func NewRemoteWriter(opts RemoteWriteOptions) (*RemoteWriter, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid remote write url %q", opts.URL)
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	conf := &remote.ClientConfig{
+		URL:     &config_util.URL{URL: u},
+		Timeout: model.Duration(timeout),
+		Headers: opts.Headers,
+	}
+	if opts.Username != "" {
+		conf.HTTPClientConfig.BasicAuth = &config_util.BasicAuth{
+			Username: opts.Username,
+			Password: config_util.Secret(opts.Password),
+		}
+	}
+	client, err := remote.NewWriteClient("signoz_rules", conf)
+	if err != nil {
+		return nil, err
+	}
+	return &RemoteWriter{
+		client:     client,
+		queue:      make(chan RecordedSample, remoteWriteQueueSize),
+		done:       make(chan struct{}),
+		terminated: make(chan struct{}),
+	}, nil
+}
+
+// Write queues the samples, it does not block
+func (w *RemoteWriter) Write(samples ...RecordedSample) {
+	if w == nil {
+		return
+	}
+	for _, s := range samples {
+		select {
+		case w.queue <- s:
+		default:
+			w.dropped.Add(1)
+		}
+	}
+}
+
+// Dropped returns the number of samples that were not written
+func (w *RemoteWriter) Dropped() uint64 {
+	if w == nil {
+		return 0
+	}
+	return w.dropped.Load()
+}
+
+func (w *RemoteWriter) Run(ctx context.Context) {
+	defer close(w.terminated)
+
+	tick := time.NewTicker(remoteWriteFlush)
+	defer tick.Stop()
+
+	batch := make([]RecordedSample, 0, remoteWriteBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := w.send(ctx, batch); err != nil {
+			w.dropped.Add(uint64(len(batch)))
+			zap.L().Error("failed to remote write the recorded samples", zap.Int("samples", len(batch)), zap.Error(err))
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case <-w.done:
+			// write what is left before stopping
+			for {
+				select {
+				case s := <-w.queue:
+					batch = append(batch, s)
+					if len(batch) == remoteWriteBatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		case s := <-w.queue:
+			batch = append(batch, s)
+			if len(batch) == remoteWriteBatchSize {
+				flush()
+			}
+		case <-tick.C:
+			flush()
+		}
+	}
+}
+
+// send writes the batch, retrying the recoverable errors
+func (w *RemoteWriter) send(ctx context.Context, samples []RecordedSample) error {
+	req, err := encodeWriteRequest(samples)
+	if err != nil {
+		return err
+	}
+	for attempt := 0; ; attempt++ {
+		err = w.client.Store(ctx, req, attempt)
+		var recoverable remote.RecoverableError
+		if err == nil || !errors.As(err, &recoverable) || attempt+1 == remoteWriteMaxAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt+1) * time.Second):
+		}
+	}
+}
+
+func (w *RemoteWriter) Stop() {
+	if w == nil {
+		return
+	}
+	close(w.done)
+	<-w.terminated
+}
+
+// encodeWriteRequest returns the snappy compressed write request of the
+// samples, the samples of the same labels in one series
+func encodeWriteRequest(samples []RecordedSample) ([]byte, error) {
+	series := map[string]*prompb.TimeSeries{}
+	var keys []string
+	for _, s := range samples {
+		if s.Labels[model.MetricNameLabel] == "" {
+			return nil, fmt.Errorf("recorded sample without a metric name")
+		}
+		lbls := make([]prompb.Label, 0, len(s.Labels))
+		for k, v := range s.Labels {
+			lbls = append(lbls, prompb.Label{Name: k, Value: v})
+		}
+		sort.Slice(lbls, func(i, j int) bool { return lbls[i].Name < lbls[j].Name })
+
+		var key strings.Builder
+		for _, l := range lbls {
+			key.WriteString(l.Name)
+			key.WriteByte(0xff)
+			key.WriteString(l.Value)
+			key.WriteByte(0xff)
+		}
+		ts, ok := series[key.String()]
+		if !ok {
+			ts = &prompb.TimeSeries{Labels: lbls}
+			series[key.String()] = ts
+			keys = append(keys, key.String())
+		}
+		ts.Samples = append(ts.Samples, prompb.Sample{Value: s.Value, Timestamp: s.Timestamp.UnixMilli()})
+	}
+
+	req := &prompb.WriteRequest{Timeseries: make([]prompb.TimeSeries, 0, len(keys))}
+	for _, k := range keys {
+		ts := series[k]
+		sort.Slice(ts.Samples, func(i, j int) bool { return ts.Samples[i].Timestamp < ts.Samples[j].Timestamp })
+		req.Timeseries = append(req.Timeseries, *ts)
+	}
+	data, err := req.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return snappy.Encode(nil, data), nil
+}
@@ -0,0 +1,84 @@
+package rules
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeWriteRequest(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	samples := []RecordedSample{
+		{Labels: map[string]string{"__name__": "job:errors:rate5m", "job": "api"}, Value: 2, Timestamp: now.Add(time.Minute)},
+		{Labels: map[string]string{"job": "api", "__name__": "job:errors:rate5m"}, Value: 1, Timestamp: now},
+		{Labels: map[string]string{"__name__": "job:errors:rate5m", "job": "db"}, Value: 3, Timestamp: now},
+	}
+
+	data, err := encodeWriteRequest(samples)
+	require.NoError(t, err)
+	raw, err := snappy.Decode(nil, data)
+	require.NoError(t, err)
+	var req prompb.WriteRequest
+	require.NoError(t, req.Unmarshal(raw))
+
+	require.Len(t, req.Timeseries, 2)
+	assert.Equal(t, []prompb.Label{{Name: "__name__", Value: "job:errors:rate5m"}, {Name: "job", Value: "api"}}, req.Timeseries[0].Labels)
+	assert.Equal(t, []prompb.Sample{{Value: 1, Timestamp: now.UnixMilli()}, {Value: 2, Timestamp: now.Add(time.Minute).UnixMilli()}}, req.Timeseries[0].Samples)
+	assert.Equal(t, "db", req.Timeseries[1].Labels[1].Value)
+
+	_, err = encodeWriteRequest([]RecordedSample{{Labels: map[string]string{"job": "api"}}})
+	assert.Error(t, err)
+}
+
+func TestRemoteWriter(t *testing.T) {
+	var mtx sync.Mutex
+	var received []prompb.TimeSeries
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ := r.BasicAuth()
+		assert.Equal(t, "signoz", username)
+		assert.Equal(t, "secret", password)
+		assert.Equal(t, "snappy", r.Header.Get("Content-Encoding"))
+
+		body, _ := io.ReadAll(r.Body)
+		raw, err := snappy.Decode(nil, body)
+		require.NoError(t, err)
+		var req prompb.WriteRequest
+		require.NoError(t, req.Unmarshal(raw))
+		mtx.Lock()
+		received = append(received, req.Timeseries...)
+		mtx.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	_, err := NewRemoteWriter(RemoteWriteOptions{URL: "mimir:9009"})
+	assert.Error(t, err)
+
+	w, err := NewRemoteWriter(RemoteWriteOptions{URL: srv.URL, Username: "signoz", Password: "secret"})
+	require.NoError(t, err)
+	go w.Run(context.Background())
+
+	w.Write(RecordedSample{Labels: map[string]string{"__name__": "job:errors:rate5m", "job": "api"}, Value: 1, Timestamp: time.Now()})
+	// the queued samples are written on stop
+	w.Stop()
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	require.Len(t, received, 1)
+	assert.Equal(t, float64(1), received[0].Samples[0].Value)
+	assert.Zero(t, w.Dropped())
+
+	// nil writers are no-ops
+	var none *RemoteWriter
+	none.Write(RecordedSample{})
+	none.Stop()
+}
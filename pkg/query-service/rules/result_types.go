@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strconv"
 
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
 	"go.signoz.io/signoz/pkg/query-service/utils/labels"
 )
 
@@ -22,6 +23,19 @@ type Sample struct {
 	MetricOrig labels.Labels
 
 	IsMissing bool
+
+	// Points of the series the sample was picked from, used to
+	// render the chart snapshot of the alert
+	SeriesPoints []v3.Point
+
+	// QueryValues are the values of all the queries of the rule for
+	// the series, exposed to templates as .Values
+	QueryValues map[string]float64
+
+	// Threshold and Unit are the threshold the sample was compared with
+	// and the unit of its query, set for the query thresholds of a rule
+	Threshold *float64
+	Unit      string
 }
 
 func (s Sample) String() string {
@@ -0,0 +1,375 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+const (
+	DefaultRoutingSimulationDays = 7
+	MaxRoutingSimulationDays     = 30
+
+	// maxSimulatedNotifications caps the notifications read from the
+	// notification log for a simulation
+	maxSimulatedNotifications = 100000
+	// notificationMatchWindow is how long after a state change of an alert
+	// its notification is looked up in the notification log, the notifier
+	// groups the alerts before sending them
+	notificationMatchWindow = 15 * time.Minute
+	// notificationMatchSkew allows for the notification to be logged
+	// slightly before the state change is written to the history
+	notificationMatchSkew = time.Minute
+
+	// the baselines the proposed routing is compared with
+	RoutingBaselineNotificationLog = "notification_log"
+	RoutingBaselineCurrentConfig   = "current_config"
+)
+
+// RoutingSimulationRequest is a proposed change of the channels the rules
+// notify, of the silences and of the planned maintenances, replayed over
+// the alerts of the last days
+type RoutingSimulationRequest struct {
+	// Days is how many days of alerts are replayed
+	Days int `json:"days,omitempty"`
+	// Channels replaces the preferred channels of the rules by rule id, an
+	// empty list sends the alerts of the rule to all the channels
+	Channels map[string][]string `json:"channels,omitempty"`
+	// Silences and Maintenances are added to the current maintenances
+	Silences     []AMv2Silence        `json:"silences,omitempty"`
+	Maintenances []PlannedMaintenance `json:"maintenances,omitempty"`
+	// RemovedMaintenances are the ids of the current maintenances the
+	// change deletes
+	RemovedMaintenances []int64 `json:"removedMaintenances,omitempty"`
+}
+
+func (r *RoutingSimulationRequest) Validate() error {
+	if r.Days == 0 {
+		r.Days = DefaultRoutingSimulationDays
+	}
+	if r.Days < 0 || r.Days > MaxRoutingSimulationDays {
+		return fmt.Errorf("days must be between 1 and %d", MaxRoutingSimulationDays)
+	}
+	for i := range r.Silences {
+		s := &r.Silences[i]
+		if len(s.Matchers) == 0 {
+			return fmt.Errorf("silence %d: at least one matcher is required", i)
+		}
+		if s.EndsAt.IsZero() {
+			return fmt.Errorf("silence %d: endsAt is required", i)
+		}
+		if !s.StartsAt.IsZero() && s.EndsAt.Before(s.StartsAt) {
+			return fmt.Errorf("silence %d: endsAt must not be before startsAt", i)
+		}
+	}
+	for i := range r.Maintenances {
+		if err := r.Maintenances[i].Validate(); err != nil {
+			return fmt.Errorf("maintenance %s: %w", r.Maintenances[i].Name, err)
+		}
+	}
+	return nil
+}
+
+// SimulatedNotification is a notification of a state change of an alert,
+// with the channels it went to and the channels it would go to with the
+// proposed change
+type SimulatedNotification struct {
+	RuleID   string            `json:"ruleId"`
+	RuleName string            `json:"ruleName"`
+	Labels   map[string]string `json:"labels"`
+	// State is firing or resolved
+	State string    `json:"state"`
+	At    time.Time `json:"at"`
+	// Actual are the channels notified according to the baseline
+	Actual   []string `json:"actual"`
+	Proposed []string `json:"proposed"`
+	// MutedBy names the silences and maintenances muting the notification
+	// with the proposed change
+	MutedBy []string `json:"mutedBy,omitempty"`
+}
+
+// ChannelSimulation compares the notifications a channel received with the
+// ones it would receive with the proposed change
+type ChannelSimulation struct {
+	Channel  string `json:"channel"`
+	Actual   int    `json:"actual"`
+	Proposed int    `json:"proposed"`
+}
+
+// RoutingSimulation is the outcome of a proposed routing change over the
+// alerts of the last days. The notifications are compared with the
+// notification log, or with the current routing when the log is not kept.
+type RoutingSimulation struct {
+	Start         time.Time           `json:"start"`
+	End           time.Time           `json:"end"`
+	Baseline      string              `json:"baseline"`
+	Notifications int                 `json:"notifications"`
+	Changed       int                 `json:"changed"`
+	Muted         int                 `json:"muted"`
+	Channels      []ChannelSimulation `json:"channels"`
+	// Differences are the notifications routed differently by the
+	// proposed change
+	Differences []SimulatedNotification `json:"differences"`
+}
+
+// routingConfig is the routing of the notifications of the rules
+type routingConfig struct {
+	// channels are the preferred channels of the rules by rule id
+	channels     map[string][]string
+	maintenances []PlannedMaintenance
+}
+
+// route returns the channels notified of a state change of an alert of the
+// rule at the time, none when the rule is muted
+func (c *routingConfig) route(ruleID string, at time.Time, all []string) ([]string, []string) {
+	var mutedBy []string
+	for i := range c.maintenances {
+		if c.maintenances[i].shouldSkip(ruleID, at) {
+			mutedBy = append(mutedBy, c.maintenances[i].Name)
+		}
+	}
+	if len(mutedBy) > 0 {
+		return []string{}, mutedBy
+	}
+	return routedChannels(c.channels[ruleID], all), nil
+}
+
+// routedChannels returns the channels the alerts with the receivers go
+// to, the alerts without receivers go to all the channels
+func routedChannels(receivers []string, all []string) []string {
+	if len(receivers) == 0 {
+		receivers = all
+	}
+	channels := slices.Clone(receivers)
+	sort.Strings(channels)
+	return slices.Compact(channels)
+}
+
+// loggedNotifications looks up the delivered notifications of the state
+// changes in the notification log
+type loggedNotifications struct {
+	byRule map[string][]NotificationRecord
+	used   map[*NotificationRecord]bool
+}
+
+func newLoggedNotifications(records []NotificationRecord) *loggedNotifications {
+	l := &loggedNotifications{byRule: map[string][]NotificationRecord{}, used: map[*NotificationRecord]bool{}}
+	for _, r := range records {
+		if r.Status == NotificationDelivered {
+			l.byRule[r.RuleId] = append(l.byRule[r.RuleId], r)
+		}
+	}
+	return l
+}
+
+// channels returns the channels of the first notification of the alert
+// with the labels sent for the state change at the time. An alert not
+// notified has no channels.
+func (l *loggedNotifications) channels(ruleID, state string, lbls map[string]string, at time.Time, all []string) []string {
+	records := l.byRule[ruleID]
+	for i := range records {
+		r := &records[i]
+		if l.used[r] || r.State != state || r.SentAt.Before(at.Add(-notificationMatchSkew)) || r.SentAt.After(at.Add(notificationMatchWindow)) {
+			continue
+		}
+		sent := map[string]string{}
+		if err := json.Unmarshal([]byte(r.Labels), &sent); err != nil {
+			continue
+		}
+		if !containsLabels(sent, lbls) {
+			continue
+		}
+		l.used[r] = true
+		return routedChannels(r.Receivers, all)
+	}
+	return []string{}
+}
+
+// episodeChange is a state change of an alert that is notified
+type episodeChange struct {
+	state string
+	at    int64
+}
+
+// simulateRouting replays the firing episodes of the alerts with the
+// current and the proposed routing. The actual channels come from the
+// notification log when it is given, from the current routing otherwise.
+// The alerts of the rules missing from current are left out.
+func simulateRouting(episodes []alertEpisode, current, proposed *routingConfig, logged *loggedNotifications, all []string) *RoutingSimulation {
+	sim := &RoutingSimulation{Baseline: RoutingBaselineCurrentConfig, Channels: []ChannelSimulation{}, Differences: []SimulatedNotification{}}
+	if logged != nil {
+		sim.Baseline = RoutingBaselineNotificationLog
+	}
+	channels := map[string]*ChannelSimulation{}
+	channel := func(name string) *ChannelSimulation {
+		c, ok := channels[name]
+		if !ok {
+			c = &ChannelSimulation{Channel: name}
+			channels[name] = c
+		}
+		return c
+	}
+
+	for _, e := range episodes {
+		if _, ok := current.channels[e.ruleID]; !ok {
+			continue
+		}
+		lbls := map[string]string{}
+		if e.labels != "" {
+			if err := json.Unmarshal([]byte(e.labels), &lbls); err != nil {
+				continue
+			}
+		}
+		changes := []episodeChange{{StateFiring.String(), e.start}}
+		if e.resolved {
+			changes = append(changes, episodeChange{"resolved", e.end})
+		}
+
+		for _, change := range changes {
+			at := time.UnixMilli(change.at)
+			n := SimulatedNotification{RuleID: e.ruleID, RuleName: e.ruleName, Labels: lbls, State: change.state, At: at}
+			if logged != nil {
+				n.Actual = logged.channels(e.ruleID, change.state, lbls, at, all)
+			} else {
+				n.Actual, _ = current.route(e.ruleID, at, all)
+			}
+			n.Proposed, n.MutedBy = proposed.route(e.ruleID, at, all)
+
+			sim.Notifications++
+			if len(n.MutedBy) > 0 {
+				sim.Muted++
+			}
+			for _, name := range n.Actual {
+				channel(name).Actual++
+			}
+			for _, name := range n.Proposed {
+				channel(name).Proposed++
+			}
+			if !slices.Equal(n.Actual, n.Proposed) {
+				sim.Changed++
+				sim.Differences = append(sim.Differences, n)
+			}
+		}
+	}
+
+	for _, c := range channels {
+		sim.Channels = append(sim.Channels, *c)
+	}
+	sort.Slice(sim.Channels, func(i, j int) bool {
+		return sim.Channels[i].Channel < sim.Channels[j].Channel
+	})
+	sort.SliceStable(sim.Differences, func(i, j int) bool {
+		return sim.Differences[i].At.Before(sim.Differences[j].At)
+	})
+	return sim
+}
+
+// SimulateRouting replays the alerts of the last days from the state
+// history with the proposed channels, silences and maintenances, and
+// reports where the notifications would have gone. The rules muted by a
+// maintenance were not evaluated, their alerts cannot be replayed when the
+// change removes the maintenance.
+func (m *Manager) SimulateRouting(ctx context.Context, req *RoutingSimulationRequest) (*RoutingSimulation, *model.ApiError) {
+	if err := req.Validate(); err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorBadData, Err: err}
+	}
+	if m.reader == nil {
+		return nil, &model.ApiError{Typ: model.ErrorUnavailable, Err: fmt.Errorf("the state history is not stored")}
+	}
+	end := time.Now()
+	start := end.Add(-time.Duration(req.Days) * 24 * time.Hour)
+
+	channelItems, apiErr := m.reader.GetChannels()
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	org := tenantOf(ctx)
+	var all []string
+	for _, c := range *channelItems {
+		if org == "" || c.VisibleTo(org) {
+			all = append(all, c.Name)
+		}
+	}
+	for ruleID, names := range req.Channels {
+		for _, name := range names {
+			if !slices.Contains(all, name) {
+				return nil, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("rule %s: unknown channel %s", ruleID, name)}
+			}
+		}
+	}
+
+	visible, err := m.tenantRules(ctx)
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	current := &routingConfig{channels: map[string][]string{}}
+	proposed := &routingConfig{channels: map[string][]string{}}
+	ruleNames := map[string]string{}
+	m.mtx.RLock()
+	for id, r := range m.rules {
+		if visible != nil && !visible[id] {
+			continue
+		}
+		ruleNames[id] = r.Name()
+		current.channels[id] = r.PreferredChannels()
+		proposed.channels[id] = r.PreferredChannels()
+	}
+	m.mtx.RUnlock()
+	for ruleID, names := range req.Channels {
+		if _, ok := current.channels[ruleID]; !ok {
+			return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("rule %s is not active", ruleID)}
+		}
+		proposed.channels[ruleID] = names
+	}
+
+	maintenances, err := m.ruleDB.GetAllPlannedMaintenance(ctx)
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	for _, maintenance := range maintenances {
+		if !maintenance.appliesTo(org) {
+			continue
+		}
+		current.maintenances = append(current.maintenances, maintenance)
+		if !slices.Contains(req.RemovedMaintenances, maintenance.Id) {
+			proposed.maintenances = append(proposed.maintenances, maintenance)
+		}
+	}
+	proposed.maintenances = append(proposed.maintenances, req.Maintenances...)
+	for i := range req.Silences {
+		s := req.Silences[i]
+		if s.StartsAt.IsZero() {
+			s.StartsAt = start
+		}
+		// the silences of the past are replayed as they are
+		maintenance, err := maintenanceFromSilence(&s, ruleNames, time.Time{})
+		if err != nil {
+			return nil, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("silence %d: %w", i, err)}
+		}
+		proposed.maintenances = append(proposed.maintenances, *maintenance)
+	}
+
+	changes, err := m.reader.ReadRuleStateChanges(ctx, start.UnixMilli(), end.UnixMilli())
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	episodes, _ := alertEpisodes(changes, end.UnixMilli())
+
+	var logged *loggedNotifications
+	if m.opts.notificationLog != nil {
+		records, err := m.ruleDB.GetNotifications(ctx, NotificationFilter{Start: start.Add(-notificationMatchSkew), End: end, Limit: maxSimulatedNotifications})
+		if err != nil {
+			return nil, newApiErrorInternal(err)
+		}
+		logged = newLoggedNotifications(records)
+	}
+
+	sim := simulateRouting(episodes, current, proposed, logged, all)
+	sim.Start, sim.End = start, end
+	return sim, nil
+}
@@ -0,0 +1,99 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func TestSimulateRouting(t *testing.T) {
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	changes := []v3.RuleStateHistory{
+		{RuleID: "1", RuleName: "HighLatency", State: "firing", UnixMilli: base.UnixMilli(), Fingerprint: 1, Labels: `{"service":"api"}`},
+		{RuleID: "2", RuleName: "DiskFull", State: "firing", UnixMilli: base.Add(time.Hour).UnixMilli(), Fingerprint: 2},
+		{RuleID: "1", RuleName: "HighLatency", State: "normal", UnixMilli: base.Add(2 * time.Hour).UnixMilli(), Fingerprint: 1, Labels: `{"service":"api"}`},
+		// the rule was deleted since
+		{RuleID: "3", RuleName: "Gone", State: "firing", UnixMilli: base.UnixMilli(), Fingerprint: 3},
+	}
+	episodes, _ := alertEpisodes(changes, base.Add(3*time.Hour).UnixMilli())
+	all := []string{"email", "pagerduty", "slack"}
+
+	current := &routingConfig{channels: map[string][]string{"1": {"slack"}, "2": nil}}
+	// rule 1 moves to pagerduty and rule 2 is silenced during its alert
+	proposed := &routingConfig{
+		channels: map[string][]string{"1": {"pagerduty"}, "2": nil},
+		maintenances: []PlannedMaintenance{{
+			Name:     "disk migration",
+			Schedule: &Schedule{Timezone: "UTC", StartTime: base.Add(30 * time.Minute), EndTime: base.Add(90 * time.Minute)},
+			AlertIds: &AlertIds{"2"},
+		}},
+	}
+
+	t.Run("current config", func(t *testing.T) {
+		sim := simulateRouting(episodes, current, proposed, nil, all)
+		assert.Equal(t, RoutingBaselineCurrentConfig, sim.Baseline)
+		assert.Equal(t, 3, sim.Notifications)
+		assert.Equal(t, 3, sim.Changed)
+		assert.Equal(t, 1, sim.Muted)
+		assert.Equal(t, []ChannelSimulation{
+			{Channel: "email", Actual: 1},
+			{Channel: "pagerduty", Actual: 1, Proposed: 2},
+			{Channel: "slack", Actual: 3},
+		}, sim.Channels)
+
+		require.Len(t, sim.Differences, 3)
+		first := sim.Differences[0]
+		assert.Equal(t, "1", first.RuleID)
+		assert.Equal(t, "firing", first.State)
+		assert.Equal(t, map[string]string{"service": "api"}, first.Labels)
+		assert.Equal(t, []string{"slack"}, first.Actual)
+		assert.Equal(t, []string{"pagerduty"}, first.Proposed)
+
+		muted := sim.Differences[1]
+		assert.Equal(t, "2", muted.RuleID)
+		assert.Equal(t, all, muted.Actual)
+		assert.Empty(t, muted.Proposed)
+		assert.Equal(t, []string{"disk migration"}, muted.MutedBy)
+
+		assert.Equal(t, "resolved", sim.Differences[2].State)
+	})
+
+	t.Run("notification log", func(t *testing.T) {
+		logged := newLoggedNotifications([]NotificationRecord{
+			// the firing notification of rule 1 went to pagerduty already
+			{RuleId: "1", State: "firing", Status: NotificationDelivered, Receivers: []string{"pagerduty"}, Labels: `{"alertname":"HighLatency","service":"api"}`, SentAt: base.Add(30 * time.Second)},
+			// a dropped notification is not counted
+			{RuleId: "2", State: "firing", Status: NotificationDropped, Labels: `{}`, SentAt: base.Add(time.Hour)},
+			// a notification of another alert of the rule
+			{RuleId: "1", State: "resolved", Status: NotificationDelivered, Receivers: []string{"slack"}, Labels: `{"service":"web"}`, SentAt: base.Add(2 * time.Hour)},
+		})
+		sim := simulateRouting(episodes, current, proposed, logged, all)
+		assert.Equal(t, RoutingBaselineNotificationLog, sim.Baseline)
+		assert.Equal(t, 3, sim.Notifications)
+		// the firing notification of rule 1 and the muted one of rule 2 are
+		// unchanged, the resolved notification of rule 1 was not logged
+		assert.Equal(t, 1, sim.Changed)
+		require.Len(t, sim.Differences, 1)
+		assert.Equal(t, "resolved", sim.Differences[0].State)
+		assert.Empty(t, sim.Differences[0].Actual)
+		assert.Equal(t, []string{"pagerduty"}, sim.Differences[0].Proposed)
+	})
+}
+
+func TestRoutingSimulationRequestValidate(t *testing.T) {
+	req := RoutingSimulationRequest{}
+	require.NoError(t, req.Validate())
+	assert.Equal(t, DefaultRoutingSimulationDays, req.Days)
+
+	req = RoutingSimulationRequest{Days: MaxRoutingSimulationDays + 1}
+	assert.Error(t, req.Validate())
+
+	req = RoutingSimulationRequest{Silences: []AMv2Silence{{EndsAt: time.Now()}}}
+	assert.Error(t, req.Validate())
+
+	req = RoutingSimulationRequest{Maintenances: []PlannedMaintenance{{Name: "no schedule"}}}
+	assert.Error(t, req.Validate())
+}
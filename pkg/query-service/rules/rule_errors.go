@@ -0,0 +1,208 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	am "go.signoz.io/signoz/pkg/query-service/integrations/alertManager"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// ErrorCategory classifies the failures of the rules so that the UI can
+// explain them and the automations act on them
+type ErrorCategory string
+
+const (
+	// ErrorQueryParse is a query the datasource or the rule can not parse
+	ErrorQueryParse ErrorCategory = "query_parse"
+	// ErrorDatasourceTimeout is a query that did not complete in time
+	ErrorDatasourceTimeout ErrorCategory = "datasource_timeout"
+	// ErrorDatasource is a query the datasource failed otherwise, e.g.
+	// unavailable
+	ErrorDatasource ErrorCategory = "datasource"
+	// ErrorCardinalityLimit is a query returning more series or rows than
+	// allowed
+	ErrorCardinalityLimit ErrorCategory = "cardinality_limit"
+	// ErrorTemplate is a template of the rule that failed to expand
+	ErrorTemplate ErrorCategory = "template"
+	// ErrorNotification is a notification of the alerts of the rule the
+	// alert managers did not accept
+	ErrorNotification ErrorCategory = "notification"
+	// ErrorUnknown is any other failure
+	ErrorUnknown ErrorCategory = "unknown"
+)
+
+// the codes of the clickhouse exceptions by category
+var clickhouseErrorCategories = map[int32]ErrorCategory{
+	47:  ErrorQueryParse, // UNKNOWN_IDENTIFIER
+	62:  ErrorQueryParse, // SYNTAX_ERROR
+	46:  ErrorQueryParse, // UNKNOWN_FUNCTION
+	60:  ErrorQueryParse, // UNKNOWN_TABLE
+	159: ErrorDatasourceTimeout,
+	160: ErrorDatasourceTimeout, // TOO_SLOW
+	158: ErrorCardinalityLimit,  // TOO_MANY_ROWS
+	396: ErrorCardinalityLimit,  // TOO_MANY_ROWS_OR_BYTES
+	241: ErrorCardinalityLimit,  // MEMORY_LIMIT_EXCEEDED
+}
+
+// RuleError is a failure of a rule with its category
+type RuleError struct {
+	Category ErrorCategory
+	Err      error
+}
+
+func (e *RuleError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
+
+func newRuleError(category ErrorCategory, err error) *RuleError {
+	return &RuleError{Category: category, Err: err}
+}
+
+// categorize returns err as a rule error, the errors without a category
+// are classified from their cause
+func categorize(err error) error {
+	if err == nil {
+		return nil
+	}
+	var re *RuleError
+	if errors.As(err, &re) {
+		return err
+	}
+	return newRuleError(ErrorCategoryOf(err), err)
+}
+
+// ErrorCategoryOf returns the category of the failure of a rule
+func ErrorCategoryOf(err error) ErrorCategory {
+	if err == nil {
+		return ""
+	}
+	var re *RuleError
+	if errors.As(err, &re) {
+		return re.Category
+	}
+	var parseErrs parser.ParseErrors
+	var parseErr *parser.ParseErr
+	if errors.As(err, &parseErrs) || errors.As(err, &parseErr) {
+		return ErrorQueryParse
+	}
+	var promTimeout promql.ErrQueryTimeout
+	var promCanceled promql.ErrQueryCanceled
+	if errors.As(err, &promTimeout) || errors.As(err, &promCanceled) {
+		return ErrorDatasourceTimeout
+	}
+	var promSamples promql.ErrTooManySamples
+	if errors.As(err, &promSamples) {
+		return ErrorCardinalityLimit
+	}
+	var ex *clickhouse.Exception
+	if errors.As(err, &ex) {
+		if c, ok := clickhouseErrorCategories[ex.Code]; ok {
+			return c
+		}
+		return ErrorDatasource
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorDatasourceTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ErrorDatasourceTimeout
+		}
+		return ErrorDatasource
+	}
+	// the queriers do not always wrap the errors of the datasources
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "deadline exceeded") || strings.Contains(msg, "timeout"):
+		return ErrorDatasourceTimeout
+	case strings.Contains(msg, "syntax error") || strings.Contains(msg, "parse error"):
+		return ErrorQueryParse
+	}
+	return ErrorUnknown
+}
+
+// queryErrorCategory returns the category of the failure of the queries of
+// a threshold rule, from the errors of the queries when the error of the
+// run does not tell
+func queryErrorCategory(err error, errs map[string]error) ErrorCategory {
+	category := ErrorCategoryOf(err)
+	for _, e := range errs {
+		if category != ErrorUnknown {
+			break
+		}
+		category = ErrorCategoryOf(e)
+	}
+	if category == ErrorUnknown {
+		return ErrorDatasource
+	}
+	return category
+}
+
+// notificationFailure is the last notification of the alerts of a rule the
+// alert managers did not accept
+type notificationFailure struct {
+	err *RuleError
+	at  time.Time
+}
+
+// notificationFailures tracks the rules whose notifications are failing,
+// a rule is cleared once a notification of its alerts is delivered
+type notificationFailures struct {
+	mtx   sync.Mutex
+	rules map[string]notificationFailure
+}
+
+func newNotificationFailures() *notificationFailures {
+	return &notificationFailures{rules: map[string]notificationFailure{}}
+}
+
+func (f *notificationFailures) failed(alerts []*am.Alert, err error, now time.Time) {
+	if f == nil || err == nil {
+		return
+	}
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	for _, a := range alerts {
+		if id := a.Labels.Get(labels.AlertRuleIdLabel); id != "" {
+			f.rules[id] = notificationFailure{err: newRuleError(ErrorNotification, err), at: now}
+		}
+	}
+}
+
+func (f *notificationFailures) delivered(alerts []*am.Alert) {
+	if f == nil {
+		return
+	}
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	for _, a := range alerts {
+		delete(f.rules, a.Labels.Get(labels.AlertRuleIdLabel))
+	}
+}
+
+// get returns the failure of the last notification of the rule, nil when
+// it was delivered
+func (f *notificationFailures) get(ruleID string) *notificationFailure {
+	if f == nil {
+		return nil
+	}
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if nf, ok := f.rules[ruleID]; ok {
+		return &nf
+	}
+	return nil
+}
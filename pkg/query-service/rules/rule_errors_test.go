@@ -0,0 +1,93 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/stretchr/testify/assert"
+	am "go.signoz.io/signoz/pkg/query-service/integrations/alertManager"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+func TestErrorCategoryOf(t *testing.T) {
+	_, parseErr := parser.ParseExpr("rate(http_requests_total[5m]")
+
+	cases := []struct {
+		name string
+		err  error
+		want ErrorCategory
+	}{
+		{"nil", nil, ""},
+		{"categorized", fmt.Errorf("evaluating: %w", newRuleError(ErrorCardinalityLimit, errors.New("too many series"))), ErrorCardinalityLimit},
+		{"promql parse", parseErr, ErrorQueryParse},
+		{"promql timeout", promql.ErrQueryTimeout("query evaluation"), ErrorDatasourceTimeout},
+		{"promql samples", promql.ErrTooManySamples("query execution"), ErrorCardinalityLimit},
+		{"clickhouse syntax", &clickhouse.Exception{Code: 62, Message: "Syntax error"}, ErrorQueryParse},
+		{"clickhouse timeout", fmt.Errorf("query: %w", &clickhouse.Exception{Code: 159, Message: "Timeout exceeded"}), ErrorDatasourceTimeout},
+		{"clickhouse rows", &clickhouse.Exception{Code: 158, Message: "Limit for rows exceeded"}, ErrorCardinalityLimit},
+		{"clickhouse other", &clickhouse.Exception{Code: 81, Message: "Database does not exist"}, ErrorDatasource},
+		{"deadline", fmt.Errorf("query: %w", context.DeadlineExceeded), ErrorDatasourceTimeout},
+		{"unwrapped timeout", errors.New("read tcp 10.0.0.1:9000: i/o timeout"), ErrorDatasourceTimeout},
+		{"other", errors.New("duplicate alert found"), ErrorUnknown},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.want, ErrorCategoryOf(c.err))
+		})
+	}
+}
+
+func TestQueryErrorCategory(t *testing.T) {
+	// the category of the run is taken before the ones of the queries
+	err := &clickhouse.Exception{Code: 159}
+	assert.Equal(t, ErrorDatasourceTimeout, queryErrorCategory(err, map[string]error{"A": &clickhouse.Exception{Code: 62}}))
+
+	assert.Equal(t, ErrorQueryParse, queryErrorCategory(errors.New("error in query A"), map[string]error{"A": &clickhouse.Exception{Code: 62}}))
+	// the failures of the queries are failures of the datasource
+	assert.Equal(t, ErrorDatasource, queryErrorCategory(errors.New("error in query A"), nil))
+}
+
+func TestRuleLastErrorCategory(t *testing.T) {
+	r := &ThresholdRule{}
+	r.SetLastError(errors.New("connection refused"))
+	var re *RuleError
+	assert.ErrorAs(t, r.LastError(), &re)
+	assert.Equal(t, ErrorUnknown, re.Category)
+	assert.Equal(t, "connection refused", r.LastError().Error())
+
+	r.SetLastError(newRuleError(ErrorTemplate, errors.New("expanding template")))
+	assert.Equal(t, ErrorTemplate, ErrorCategoryOf(r.LastError()))
+
+	r.SetLastError(nil)
+	assert.NoError(t, r.LastError())
+}
+
+func TestNotificationFailures(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	alert := func(ruleID string) *am.Alert {
+		return &am.Alert{Labels: labels.FromMap(map[string]string{labels.AlertRuleIdLabel: ruleID})}
+	}
+
+	f := newNotificationFailures()
+	f.failed([]*am.Alert{alert("1"), alert("2")}, errors.New("bad response status 503"), now)
+	nf := f.get("1")
+	if assert.NotNil(t, nf) {
+		assert.Equal(t, ErrorNotification, nf.err.Category)
+		assert.Equal(t, "bad response status 503", nf.err.Error())
+		assert.Equal(t, now, nf.at)
+	}
+
+	f.delivered([]*am.Alert{alert("1")})
+	assert.Nil(t, f.get("1"))
+	assert.NotNil(t, f.get("2"))
+
+	// the rules without notifications tracked have none failing
+	var none *notificationFailures
+	assert.Nil(t, none.get("2"))
+}
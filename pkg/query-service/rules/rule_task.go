@@ -2,12 +2,11 @@ package rules
 
 import (
 	"context"
-	"fmt"
 	"sort"
 	"sync"
 	"time"
 
-	opentracing "github.com/opentracing/opentracing-go"
+	"go.opentelemetry.io/otel/attribute"
 	"go.signoz.io/signoz/pkg/query-service/common"
 	"go.signoz.io/signoz/pkg/query-service/utils/labels"
 	"go.uber.org/zap"
@@ -33,6 +32,8 @@ type RuleTask struct {
 	notify NotifyFunc
 
 	ruleDB RuleDB
+
+	evalTracker
 }
 
 const DefaultFrequency = 1 * time.Minute
@@ -106,8 +107,19 @@ func (g *RuleTask) Run(ctx context.Context) {
 		},
 	})
 
+	// stopping the task cancels its queued or running evaluation
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-g.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
 	iter := func() {
-		if g.pause {
+		if g.pause || g.opts.scheduler.isPaused() {
 			// todo(amol): remove in memory active alerts
 			// and last series state
 			return
@@ -126,6 +138,10 @@ func (g *RuleTask) Run(ctx context.Context) {
 	tick := time.NewTicker(g.frequency)
 	defer tick.Stop()
 
+	// replay the evaluations before the first one so that the pending
+	// and firing alerts are restored after a restart
+	g.catchUp(ctx, catchUpTimestamps(evalTimestamp, maxCatchUpEvaluations, g.frequency, g.opts.EvalCatchUpLookback))
+
 	iter()
 
 	// let the group iterate and run
@@ -139,6 +155,12 @@ func (g *RuleTask) Run(ctx context.Context) {
 				return
 			case <-tick.C:
 				missed := (time.Since(evalTimestamp) / g.frequency) - 1
+				if missed > 0 {
+					zap.L().Warn("missed rule evaluations", zap.String("name", g.name), zap.Int64("missed", int64(missed)), zap.Time("since", evalTimestamp.Add(g.frequency)))
+					g.recordMissed(int64(missed), evalTimestamp.Add(g.frequency))
+					next := evalTimestamp.Add((missed + 1) * g.frequency)
+					g.catchUp(ctx, catchUpTimestamps(next, int64(missed), g.frequency, g.opts.EvalCatchUpLookback))
+				}
 				evalTimestamp = evalTimestamp.Add((missed + 1) * g.frequency)
 				iter()
 			}
@@ -248,53 +270,45 @@ func nameAndLabels(rule Rule) string {
 
 // CopyState copies the alerting rule and staleness related state from the given group.
 //
-// Rules are matched based on their name and labels. If there are duplicates, the
-// first is matched with the first, second with the second etc.
+// Rules are matched based on their id and then their name and labels. If there are
+// duplicates, the first is matched with the first, second with the second etc.
 func (g *RuleTask) CopyState(fromTask Task) error {
 
-	from, ok := fromTask.(*RuleTask)
-	if !ok {
-		return fmt.Errorf("invalid from task for copy")
+	if from, ok := fromTask.(*RuleTask); ok {
+		g.evaluationTime = from.evaluationTime
+		g.lastEvaluation = from.lastEvaluation
 	}
-	g.evaluationTime = from.evaluationTime
-	g.lastEvaluation = from.lastEvaluation
 
-	ruleMap := make(map[string][]int, len(from.rules))
-
-	for fi, fromRule := range from.rules {
-		nameAndLabels := nameAndLabels(fromRule)
-		l := ruleMap[nameAndLabels]
-		ruleMap[nameAndLabels] = append(l, fi)
-	}
-
-	for _, rule := range g.rules {
-		nameAndLabels := nameAndLabels(rule)
-		indexes := ruleMap[nameAndLabels]
-		if len(indexes) == 0 {
-			continue
-		}
-		fi := indexes[0]
-		ruleMap[nameAndLabels] = indexes[1:]
+	copyRulesState(g.rules, fromTask)
+	return nil
+}
 
-		ar, ok := rule.(*ThresholdRule)
-		if !ok {
-			continue
-		}
-		far, ok := from.rules[fi].(*ThresholdRule)
-		if !ok {
-			continue
-		}
+// Eval runs a single evaluation cycle in which all rules are evaluated
+// sequentially on the workers of the evaluation pool.
+func (g *RuleTask) Eval(ctx context.Context, ts time.Time) {
+	g.eval(ctx, ts, true)
+}
 
-		for fp, a := range far.active {
-			ar.active[fp] = a
+// catchUp evaluates the rules at the missed timestamps to bring the state
+// of their alerts up to date, the alerts are sent by the next evaluation
+func (g *RuleTask) catchUp(ctx context.Context, timestamps []time.Time) {
+	if len(timestamps) == 0 || g.pause {
+		return
+	}
+	zap.L().Info("catching up missed rule evaluations", zap.String("name", g.name), zap.Int("evaluations", len(timestamps)))
+	for _, ts := range timestamps {
+		select {
+		case <-g.done:
+			return
+		default:
 		}
+		g.eval(ctx, ts, false)
 	}
-
-	return nil
+	g.recordCaughtUp(int64(len(timestamps)))
 }
 
-// Eval runs a single evaluation cycle in which all rules are evaluated sequentially.
-func (g *RuleTask) Eval(ctx context.Context, ts time.Time) {
+// eval evaluates the rules at ts and sends their alerts when notify is set
+func (g *RuleTask) eval(ctx context.Context, ts time.Time, notify bool) {
 
 	zap.L().Debug("rule task eval started", zap.String("name", g.name), zap.Time("start time", ts))
 
@@ -304,20 +318,34 @@ func (g *RuleTask) Eval(ctx context.Context, ts time.Time) {
 		zap.L().Error("Error in processing sql query", zap.Error(err))
 	}
 
-	for i, rule := range g.rules {
+	for _, rule := range g.rules {
 		if rule == nil {
 			continue
 		}
 
+		// the rule is evaluated by another replica
+		if !g.opts.sharder.Owns(rule.ID()) {
+			continue
+		}
+
 		shouldSkip := false
+		org := g.opts.tenants.of(rule.ID())
 		for _, m := range maintenance {
 			zap.L().Info("checking if rule should be skipped", zap.String("rule", rule.ID()), zap.Any("maintenance", m))
-			if m.shouldSkip(rule.ID(), ts) {
+			if m.appliesTo(org) && m.shouldSkip(rule.ID(), ts) {
 				shouldSkip = true
 				break
 			}
 		}
+		// the events of the calendars of the rule suspend it like a
+		// maintenance
+		if !shouldSkip && calendarSuspended(g.opts, rule, ts) {
+			shouldSkip = true
+		}
 
+		if g.setMuted(rule.ID(), shouldSkip) {
+			publishSilenced(g.opts, rule, ts)
+		}
 		if shouldSkip {
 			zap.L().Info("rule should be skipped", zap.String("rule", rule.ID()))
 			continue
@@ -329,16 +357,38 @@ func (g *RuleTask) Eval(ctx context.Context, ts time.Time) {
 		default:
 		}
 
-		func(i int, rule Rule) {
-			sp, ctx := opentracing.StartSpanFromContext(ctx, "rule")
+		// the query of the rule keeps failing, wait for the backoff
+		breaker := g.breaker(rule.ID(), g.opts)
+		if !breaker.allow(time.Now()) {
+			zap.L().Debug("rule evaluation backed off", zap.String("ruleid", rule.ID()))
+			continue
+		}
 
-			sp.SetTag("name", rule.Name())
+		err := g.opts.EvalPool.Run(ctx, rule.ID(), g.opts.evalTimeout(g.frequency), func(ctx context.Context) {
+			ctx, span := startSpan(ctx, "rule.eval", append(ruleAttributes(rule),
+				attribute.String("rule.eval_timestamp", ts.Format(time.RFC3339)),
+				attribute.Bool("rule.catch_up", !notify),
+			)...)
+			ctx, meter := withCostMeter(ctx)
+			ctx, stopProfile := g.opts.profiler.begin(ctx, rule)
+			var evalErr error
+			health := rule.Health()
 			defer func(t time.Time) {
-				sp.Finish()
+				stopProfile()
+				endSpan(span, evalErr)
+
+				cost := meter.cost(ts)
+				g.opts.costs.record(rule.ID(), cost)
+				g.opts.costReport.record(rule.ID(), cost)
+				g.opts.metrics.observeQueryCost(rule, cost)
 
 				since := time.Since(t)
 				rule.SetEvaluationDuration(since)
 				rule.SetEvaluationTimestamp(t)
+				g.opts.metrics.observeEvaluation(rule, since)
+				g.opts.profiler.record(rule.ID(), since)
+				recordEvaluation(ctx, g.ruleDB, g.opts, rule, ts, since, evalErr)
+				publishHealthChange(g.opts, rule, health, ts)
 			}(time.Now())
 
 			kvs := map[string]string{
@@ -348,10 +398,17 @@ func (g *RuleTask) Eval(ctx context.Context, ts time.Time) {
 			}
 			ctx = context.WithValue(ctx, common.LogCommentKey, kvs)
 
-			_, err := rule.Eval(ctx, ts, g.opts.Queriers)
+			before := alertStates(rule)
+			queriers, err := g.opts.Queriers.For(rule)
+			if err == nil {
+				_, err = rule.Eval(ctx, ts, queriers)
+			}
+			evalErr = err
 			if err != nil {
 				rule.SetHealth(HealthBad)
 				rule.SetLastError(err)
+				breaker.failure(time.Now(), g.frequency, err)
+				g.opts.metrics.evaluationFailed(rule)
 
 				zap.L().Warn("Evaluating rule failed", zap.String("ruleid", rule.ID()), zap.Error(err))
 
@@ -362,9 +419,18 @@ func (g *RuleTask) Eval(ctx context.Context, ts time.Time) {
 				//}
 				return
 			}
+			breaker.success()
+			publishTransitions(g.opts, rule, before, ts)
+			observeSeries(ctx, g.opts, rule, ts)
+			observeTeamAlerts(g.opts, rule)
 
-			rule.SendAlerts(ctx, ts, g.opts.ResendDelay, g.frequency, g.notify)
-
-		}(i, rule)
+			if notify {
+				sendAlerts(ctx, g.opts, rule, ts, g.frequency, g.notify)
+			}
+		})
+		if err != nil {
+			breaker.abort()
+			zap.L().Warn("rule evaluation skipped", zap.String("ruleid", rule.ID()), zap.Error(err))
+		}
 	}
 }
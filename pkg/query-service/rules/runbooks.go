@@ -0,0 +1,234 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	am "go.signoz.io/signoz/pkg/query-service/integrations/alertManager"
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+const (
+	// MaxRunbookSize bounds the markdown of the runbooks stored in SigNoz
+	MaxRunbookSize = 64 * 1024
+
+	// runbookCheckInterval is how long the health of an external runbook
+	// is cached
+	runbookCheckInterval = 10 * time.Minute
+	runbookCheckTimeout  = 5 * time.Second
+)
+
+// Runbook is the runbook of a rule, either markdown stored with the rule
+// or the url of an external page
+type Runbook struct {
+	URL      string `yaml:"url,omitempty" json:"url,omitempty"`
+	Markdown string `yaml:"markdown,omitempty" json:"markdown,omitempty"`
+}
+
+func (rb *Runbook) Validate() error {
+	switch {
+	case rb.URL != "" && rb.Markdown != "":
+		return fmt.Errorf("runbook must have either a url or markdown, not both")
+	case rb.URL != "":
+		u, err := url.Parse(rb.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("runbook url must be an absolute http or https url")
+		}
+	case rb.Markdown != "":
+		if len(rb.Markdown) > MaxRunbookSize {
+			return fmt.Errorf("runbook markdown exceeds %d bytes", MaxRunbookSize)
+		}
+	default:
+		return fmt.Errorf("runbook must have a url or markdown")
+	}
+	return nil
+}
+
+// link returns the link to the runbook sent with the notifications, the
+// stored runbooks are linked to their page on the host of the rule. It is
+// empty when the host is not known.
+func (rb *Runbook) link(ruleID, generatorURL string) string {
+	if rb == nil {
+		return ""
+	}
+	if rb.URL != "" {
+		return rb.URL
+	}
+	u, err := url.Parse(generatorURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s://%s/api/v1/rules/%s/runbook", u.Scheme, u.Host, url.PathEscape(ruleID))
+}
+
+// runbookRule is implemented by the rules with a runbook
+type runbookRule interface {
+	Runbook() *Runbook
+}
+
+// RunbookHealth is the result of the last check of an external runbook
+type RunbookHealth struct {
+	Healthy    bool      `json:"healthy"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	CheckedAt  time.Time `json:"checkedAt"`
+}
+
+// runbookChecker checks that the external runbooks are reachable, the
+// results are cached so the alert pages do not hit the runbooks on every
+// load
+type runbookChecker struct {
+	client *http.Client
+	mtx    sync.Mutex
+	health map[string]RunbookHealth
+}
+
+func newRunbookChecker() *runbookChecker {
+	return &runbookChecker{
+		client: &http.Client{Transport: am.DefaultEgressPolicy().Transport(), Timeout: runbookCheckTimeout},
+		health: map[string]RunbookHealth{},
+	}
+}
+
+// check returns the health of the runbook at the url, it is checked again
+// when the cached result is older than runbookCheckInterval
+func (c *runbookChecker) check(ctx context.Context, rawURL string, now time.Time) RunbookHealth {
+	c.mtx.Lock()
+	h, ok := c.health[rawURL]
+	c.mtx.Unlock()
+	if ok && now.Sub(h.CheckedAt) < runbookCheckInterval {
+		return h
+	}
+
+	h = RunbookHealth{CheckedAt: now}
+	status, err := c.fetch(ctx, http.MethodHead, rawURL)
+	// some servers do not allow HEAD
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		status, err = c.fetch(ctx, http.MethodGet, rawURL)
+	}
+	if err != nil {
+		h.Error = err.Error()
+	} else {
+		h.StatusCode = status
+		h.Healthy = status < 400
+	}
+
+	c.mtx.Lock()
+	c.health[rawURL] = h
+	c.mtx.Unlock()
+	return h
+}
+
+func (c *runbookChecker) fetch(ctx context.Context, method, rawURL string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// RuleRunbook is the runbook of a rule for the responders, the markdown
+// runbooks are rendered to html and the external ones are checked
+type RuleRunbook struct {
+	RuleID   string         `json:"ruleId"`
+	URL      string         `json:"url,omitempty"`
+	Markdown string         `json:"markdown,omitempty"`
+	HTML     string         `json:"html,omitempty"`
+	Health   *RunbookHealth `json:"health,omitempty"`
+}
+
+func (m *Manager) ruleRunbook(ctx context.Context, ruleID string, rb *Runbook) *RuleRunbook {
+	if rb == nil {
+		return nil
+	}
+	res := &RuleRunbook{RuleID: ruleID, URL: rb.URL, Markdown: rb.Markdown}
+	if rb.Markdown != "" {
+		res.HTML = am.RenderMarkdown(rb.Markdown, am.MarkupHTML)
+	}
+	if rb.URL != "" && m.opts.runbooks != nil {
+		h := m.opts.runbooks.check(ctx, rb.URL, time.Now())
+		res.Health = &h
+	}
+	return res
+}
+
+// activeRunbook returns the runbook of the active rule, nil when it has
+// none
+func (m *Manager) activeRunbook(ruleID string) (*Runbook, *model.ApiError) {
+	m.mtx.RLock()
+	rule, ok := m.rules[ruleID]
+	m.mtx.RUnlock()
+	if !ok {
+		return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("rule %s is not active", ruleID)}
+	}
+	if r, ok := rule.(runbookRule); ok {
+		return r.Runbook(), nil
+	}
+	return nil, nil
+}
+
+// RuleRunbook returns the runbook of the rule
+func (m *Manager) RuleRunbook(ctx context.Context, ruleID string) (*RuleRunbook, *model.ApiError) {
+	rb, apiErr := m.activeRunbook(ruleID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if rb == nil {
+		return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("rule %s has no runbook", ruleID)}
+	}
+	return m.ruleRunbook(ctx, ruleID, rb), nil
+}
+
+// AlertDetail is an active alert of a rule with what the responders need
+type AlertDetail struct {
+	ActiveAlert
+	Annotations map[string]string `json:"annotations"`
+	Runbook     *RuleRunbook      `json:"runbook,omitempty"`
+}
+
+// AlertDetail returns the active alert of the rule with the fingerprint
+// and the runbook of the rule
+func (m *Manager) AlertDetail(ctx context.Context, ruleID string, fingerprint uint64) (*AlertDetail, *model.ApiError) {
+	alerts, apiErr := m.RuleAlerts(ruleID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	var detail *AlertDetail
+	for _, a := range alerts {
+		if a.Fingerprint == fingerprint {
+			detail = &AlertDetail{ActiveAlert: a}
+			break
+		}
+	}
+	if detail == nil {
+		return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("alert %d of rule %s is not active", fingerprint, ruleID)}
+	}
+
+	m.mtx.RLock()
+	rule, ok := m.rules[ruleID]
+	m.mtx.RUnlock()
+	if ok {
+		for _, a := range rule.ActiveAlerts() {
+			if a.Labels.Hash() == fingerprint {
+				detail.Annotations = a.Annotations.Map()
+				break
+			}
+		}
+	}
+
+	rb, apiErr := m.activeRunbook(ruleID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	detail.Runbook = m.ruleRunbook(ctx, ruleID, rb)
+	return detail, nil
+}
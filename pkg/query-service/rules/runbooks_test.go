@@ -0,0 +1,86 @@
+package rules
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunbookValidate(t *testing.T) {
+	assert.NoError(t, (&Runbook{URL: "https://wiki.example.com/checkout"}).Validate())
+	assert.NoError(t, (&Runbook{Markdown: "# Checkout latency\n- check the db"}).Validate())
+	assert.Error(t, (&Runbook{}).Validate())
+	assert.Error(t, (&Runbook{URL: "https://wiki.example.com", Markdown: "steps"}).Validate())
+	assert.Error(t, (&Runbook{URL: "wiki/checkout"}).Validate())
+	assert.Error(t, (&Runbook{URL: "file:///etc/passwd"}).Validate())
+	assert.Error(t, (&Runbook{Markdown: strings.Repeat("a", MaxRunbookSize+1)}).Validate())
+}
+
+func TestRunbookLink(t *testing.T) {
+	var none *Runbook
+	assert.Empty(t, none.link("1", "https://signoz.example.com/alerts/edit?ruleId=1"))
+
+	assert.Equal(t, "https://wiki.example.com/checkout", (&Runbook{URL: "https://wiki.example.com/checkout"}).link("1", ""))
+
+	stored := &Runbook{Markdown: "steps"}
+	assert.Equal(t, "https://signoz.example.com/api/v1/rules/1/runbook", stored.link("1", "https://signoz.example.com/alerts/edit?ruleId=1"))
+	// the stored runbooks are not linked without the host of the rule
+	assert.Empty(t, stored.link("1", ""))
+}
+
+func TestRunbookChecker(t *testing.T) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.URL.Path {
+		case "/ok":
+		case "/no-head":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := newRunbookChecker()
+	c.client = srv.Client()
+	now := time.Now()
+
+	h := c.check(context.Background(), srv.URL+"/ok", now)
+	assert.True(t, h.Healthy)
+	assert.Equal(t, http.StatusOK, h.StatusCode)
+
+	h = c.check(context.Background(), srv.URL+"/no-head", now)
+	assert.True(t, h.Healthy)
+
+	h = c.check(context.Background(), srv.URL+"/missing", now)
+	assert.False(t, h.Healthy)
+	assert.Equal(t, http.StatusNotFound, h.StatusCode)
+	assert.Equal(t, []string{"HEAD /ok", "HEAD /no-head", "GET /no-head", "HEAD /missing"}, requests)
+
+	// the results are cached
+	c.check(context.Background(), srv.URL+"/ok", now.Add(time.Minute))
+	assert.Len(t, requests, 4)
+	c.check(context.Background(), srv.URL+"/ok", now.Add(runbookCheckInterval))
+	assert.Len(t, requests, 5)
+
+	h = c.check(context.Background(), "http://127.0.0.1:1/unreachable", now)
+	assert.False(t, h.Healthy)
+	assert.NotEmpty(t, h.Error)
+}
+
+func TestManagerRuleRunbook(t *testing.T) {
+	m := &Manager{opts: &ManagerOptions{}}
+	res := m.ruleRunbook(context.Background(), "1", &Runbook{Markdown: "# Steps\n- restart the **worker**"})
+	assert.Equal(t, "1", res.RuleID)
+	assert.Contains(t, res.HTML, "<strong>worker</strong>")
+	assert.Nil(t, res.Health)
+	assert.Nil(t, m.ruleRunbook(context.Background(), "1", nil))
+}
@@ -0,0 +1,243 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/common"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.uber.org/zap"
+)
+
+// ScheduledTask is a rule task run by the scheduler of the manager
+type ScheduledTask struct {
+	Name        string   `json:"name"`
+	Type        TaskType `json:"type"`
+	RuleIDs     []string `json:"ruleIds"`
+	FrequencyMs int64    `json:"frequencyMs"`
+	// LastRun is when the last evaluation started and LastRunMs how long
+	// it took, zero when the task was not evaluated yet
+	LastRun   time.Time `json:"lastRun"`
+	LastRunMs int64     `json:"lastRunMs"`
+	NextRun   time.Time `json:"nextRun"`
+	Paused    bool      `json:"paused"`
+}
+
+// SchedulerStatus is the state of the scheduler of the rules and of the
+// evaluations waiting for or running in the workers
+type SchedulerStatus struct {
+	Paused   bool            `json:"paused"`
+	PausedAt time.Time       `json:"pausedAt,omitempty"`
+	PausedBy string          `json:"pausedBy,omitempty"`
+	Tasks    []ScheduledTask `json:"tasks"`
+	Queue    []EvalPoolJob   `json:"queue"`
+}
+
+// RuleEvaluationRequest are the options of an evaluation of a rule forced
+// through the api, e.g. by a deploy pipeline
+type RuleEvaluationRequest struct {
+	// Notify sends the alerts of the evaluation like a scheduled one, it
+	// is the default. The evaluation only updates the alerts otherwise.
+	Notify *bool `json:"notify,omitempty"`
+	// Reason is logged with the evaluation, e.g. the deploy triggering it
+	Reason string `json:"reason,omitempty"`
+}
+
+func (req *RuleEvaluationRequest) notify() bool {
+	return req == nil || req.Notify == nil || *req.Notify
+}
+
+// RuleEvaluation is the outcome of an evaluation of a rule forced by the
+// scheduler api
+type RuleEvaluation struct {
+	RuleID            string        `json:"ruleId"`
+	Health            RuleHealth    `json:"health"`
+	LastError         string        `json:"lastError,omitempty"`
+	LastErrorCategory ErrorCategory `json:"lastErrorCategory,omitempty"`
+	EvaluatedAt       time.Time     `json:"evaluatedAt"`
+	DurationMs        int64         `json:"durationMs"`
+	// Skipped is set when the rule was not evaluated, it is muted by a
+	// maintenance or a calendar or its failing query is backed off
+	Skipped bool `json:"skipped,omitempty"`
+	// State is the state of the rule after the evaluation
+	State   string           `json:"state"`
+	Firing  int              `json:"firing"`
+	Pending int              `json:"pending"`
+	Alerts  []EvaluatedAlert `json:"alerts"`
+	// Canary is the verdict of the evaluation of a canary rule
+	Canary *CanaryVerdict `json:"canary,omitempty"`
+}
+
+// EvaluatedAlert is an active alert of the rule after a forced evaluation
+type EvaluatedAlert struct {
+	Labels   map[string]string `json:"labels"`
+	State    string            `json:"state"`
+	Value    float64           `json:"value"`
+	ActiveAt time.Time         `json:"activeAt"`
+}
+
+// taskEvaluator is implemented by the tasks that can evaluate their rules
+// without sending the alerts
+type taskEvaluator interface {
+	eval(ctx context.Context, ts time.Time, notify bool)
+}
+
+// scheduledTask is implemented by the tasks whose schedule can be shown
+type scheduledTask interface {
+	Interval() time.Duration
+	GetLastEvaluation() time.Time
+	GetEvaluationTime() time.Duration
+	EvalTimestamp(startTime int64) time.Time
+}
+
+// schedulerPause stops the scheduled evaluations of all tasks, the
+// evaluations forced by the api still run
+type schedulerPause struct {
+	mtx    sync.Mutex
+	paused bool
+	at     time.Time
+	by     string
+}
+
+func (p *schedulerPause) isPaused() bool {
+	if p == nil {
+		return false
+	}
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return p.paused
+}
+
+func (p *schedulerPause) set(paused bool, by string) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.paused = paused
+	p.at, p.by = time.Time{}, ""
+	if paused {
+		p.at, p.by = time.Now(), by
+	}
+}
+
+// SchedulerStatus returns the scheduled tasks with their last and next
+// runs, sorted by next run, and the evaluation queue
+func (m *Manager) SchedulerStatus() SchedulerStatus {
+	status := SchedulerStatus{Tasks: []ScheduledTask{}, Queue: m.opts.EvalPool.Jobs()}
+	if p := m.opts.scheduler; p != nil {
+		p.mtx.Lock()
+		status.Paused, status.PausedAt, status.PausedBy = p.paused, p.at, p.by
+		p.mtx.Unlock()
+	}
+
+	now := time.Now()
+	m.mtx.RLock()
+	for _, t := range m.tasks {
+		task := ScheduledTask{Name: t.Name(), Type: t.Type(), RuleIDs: []string{}, Paused: status.Paused}
+		for _, r := range t.Rules() {
+			task.RuleIDs = append(task.RuleIDs, r.ID())
+		}
+		if s, ok := t.(scheduledTask); ok {
+			task.FrequencyMs = s.Interval().Milliseconds()
+			task.LastRun = s.GetLastEvaluation()
+			task.LastRunMs = s.GetEvaluationTime().Milliseconds()
+			task.NextRun = s.EvalTimestamp(now.UnixNano()).Add(s.Interval())
+		}
+		status.Tasks = append(status.Tasks, task)
+	}
+	m.mtx.RUnlock()
+	if status.Queue == nil {
+		status.Queue = []EvalPoolJob{}
+	}
+	sort.Slice(status.Tasks, func(i, j int) bool {
+		a, b := status.Tasks[i], status.Tasks[j]
+		if !a.NextRun.Equal(b.NextRun) {
+			return a.NextRun.Before(b.NextRun)
+		}
+		return a.Name < b.Name
+	})
+	return status
+}
+
+// PauseScheduler stops or resumes the scheduled evaluations of all rules,
+// the alerts keep their state while the scheduler is paused
+func (m *Manager) PauseScheduler(ctx context.Context, paused bool) SchedulerStatus {
+	var by string
+	if user := common.GetUserFromContext(ctx); user != nil {
+		by = user.Email
+	}
+	m.opts.scheduler.set(paused, by)
+	zap.L().Info("the rules scheduler was paused or resumed", zap.Bool("paused", paused), zap.String("by", by))
+	return m.SchedulerStatus()
+}
+
+// EvaluateRuleNow evaluates the rule immediately, also when the scheduler
+// is paused, and returns its health and its alerts after the evaluation.
+// The schedule of the rule is unchanged.
+func (m *Manager) EvaluateRuleNow(ctx context.Context, id string, req *RuleEvaluationRequest) (*RuleEvaluation, *model.ApiError) {
+	m.mtx.RLock()
+	task, ok := m.tasks[prepareTaskName(id)]
+	rule := m.rules[id]
+	m.mtx.RUnlock()
+	if !ok || rule == nil {
+		return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("rule %s is not running", id)}
+	}
+	if m.opts.EvalPool.Busy(id) {
+		return nil, &model.ApiError{Typ: model.ErrorConflict, Err: ErrEvalInProgress}
+	}
+
+	var by string
+	if user := common.GetUserFromContext(ctx); user != nil {
+		by = user.Email
+	}
+	zap.L().Info("rule evaluation triggered", zap.String("ruleid", id), zap.String("by", by), zap.String("reason", req.Reason), zap.Bool("notify", req.notify()))
+
+	// the evaluation is not canceled with the request, a cut short query
+	// would mark the rule unhealthy
+	evalCtx := NewQueryOriginContext(m.opts.Context, map[string]interface{}{
+		"ruleRuleTask": map[string]string{
+			"name": task.Name(),
+		},
+	})
+	previous := rule.GetEvaluationTimestamp()
+	if t, ok := task.(taskEvaluator); ok {
+		t.eval(evalCtx, time.Now(), req.notify())
+	} else {
+		task.Eval(evalCtx, time.Now())
+	}
+
+	eval := &RuleEvaluation{
+		RuleID:      id,
+		Health:      rule.Health(),
+		EvaluatedAt: rule.GetEvaluationTimestamp(),
+		DurationMs:  rule.GetEvaluationDuration().Milliseconds(),
+		State:       rule.State().String(),
+		Alerts:      []EvaluatedAlert{},
+	}
+	eval.Skipped = eval.EvaluatedAt.Equal(previous)
+	if err := rule.LastError(); err != nil && eval.Health == HealthBad {
+		eval.LastError = err.Error()
+		eval.LastErrorCategory = ErrorCategoryOf(err)
+	}
+	if tr, ok := rule.(*ThresholdRule); ok && tr.ruleCondition != nil && tr.ruleCondition.Canary != nil {
+		eval.Canary = tr.CanaryVerdict()
+	}
+	for _, a := range rule.ActiveAlerts() {
+		switch a.State {
+		case StateFiring:
+			eval.Firing++
+		case StatePending:
+			eval.Pending++
+		default:
+			continue
+		}
+		eval.Alerts = append(eval.Alerts, EvaluatedAlert{
+			Labels:   a.Labels.Map(),
+			State:    a.State.String(),
+			Value:    a.Value,
+			ActiveAt: a.ActiveAt,
+		})
+	}
+	return eval, nil
+}
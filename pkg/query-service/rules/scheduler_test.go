@@ -0,0 +1,104 @@
+package rules
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// schedulerRule fires an alert on every evaluation and records the
+// notifications it sends
+type schedulerRule struct {
+	Rule
+	alerts    []*Alert
+	health    RuleHealth
+	evaluated time.Time
+	duration  time.Duration
+	sent      int
+}
+
+func (r *schedulerRule) ID() string                            { return "7" }
+func (r *schedulerRule) Name() string                          { return "high latency" }
+func (r *schedulerRule) Type() RuleType                        { return RuleTypeThreshold }
+func (r *schedulerRule) Labels() labels.BaseLabels             { return labels.FromMap(nil) }
+func (r *schedulerRule) State() AlertState                     { return StateFiring }
+func (r *schedulerRule) ActiveAlerts() []*Alert                { return r.alerts }
+func (r *schedulerRule) Health() RuleHealth                    { return r.health }
+func (r *schedulerRule) SetHealth(h RuleHealth)                { r.health = h }
+func (r *schedulerRule) LastError() error                      { return nil }
+func (r *schedulerRule) SetEvaluationDuration(d time.Duration) { r.duration = d }
+func (r *schedulerRule) GetEvaluationDuration() time.Duration  { return r.duration }
+func (r *schedulerRule) SetEvaluationTimestamp(ts time.Time)   { r.evaluated = ts }
+func (r *schedulerRule) GetEvaluationTimestamp() time.Time     { return r.evaluated }
+func (r *schedulerRule) Condition() *RuleCondition             { return nil }
+func (r *schedulerRule) PreferredChannels() []string           { return nil }
+func (r *schedulerRule) Annotations() labels.BaseLabels        { return labels.FromMap(nil) }
+func (r *schedulerRule) Eval(context.Context, time.Time, *Queriers) (interface{}, error) {
+	r.health = HealthGood
+	r.alerts = []*Alert{{
+		State:    StateFiring,
+		Labels:   labels.FromMap(map[string]string{"service": "checkout"}),
+		Value:    850,
+		ActiveAt: time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC),
+	}}
+	return len(r.alerts), nil
+}
+func (r *schedulerRule) SendAlerts(ctx context.Context, ts time.Time, _ time.Duration, _ time.Duration, notify NotifyFunc) {
+	r.sent++
+	notify(ctx, "", nil)
+}
+
+func TestEvaluateRuleNow(t *testing.T) {
+	opts := &ManagerOptions{Context: context.Background()}
+	var notified int
+	notify := func(context.Context, string, ...*Alert) { notified++ }
+
+	for _, tc := range []struct {
+		name     string
+		req      *RuleEvaluationRequest
+		notified int
+	}{
+		{name: "notify by default", req: &RuleEvaluationRequest{}, notified: 1},
+		{name: "notify", req: &RuleEvaluationRequest{Notify: boolPtr(true)}, notified: 1},
+		{name: "without notifying", req: &RuleEvaluationRequest{Notify: boolPtr(false), Reason: "deploy"}, notified: 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			notified = 0
+			rule := &schedulerRule{}
+			task := newRuleTask(prepareTaskName(rule.ID()), "", time.Minute, []Rule{rule}, opts, notify, &bundleDB{})
+			m := &Manager{
+				tasks: map[string]Task{task.Name(): task},
+				rules: map[string]Rule{rule.ID(): rule},
+				opts:  opts,
+			}
+
+			eval, apiErr := m.EvaluateRuleNow(context.Background(), rule.ID(), tc.req)
+			require.Nil(t, apiErr)
+			assert.Equal(t, tc.notified, notified)
+			assert.Equal(t, tc.notified, rule.sent)
+
+			assert.Equal(t, "7", eval.RuleID)
+			assert.Equal(t, HealthGood, eval.Health)
+			assert.False(t, eval.Skipped)
+			assert.Equal(t, 1, eval.Firing)
+			assert.Equal(t, []EvaluatedAlert{{
+				Labels:   map[string]string{"service": "checkout"},
+				State:    StateFiring.String(),
+				Value:    850,
+				ActiveAt: time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC),
+			}}, eval.Alerts)
+		})
+	}
+}
+
+func TestEvaluateRuleNowNotRunning(t *testing.T) {
+	m := &Manager{tasks: map[string]Task{}, rules: map[string]Rule{}, opts: &ManagerOptions{}}
+	_, apiErr := m.EvaluateRuleNow(context.Background(), "7", &RuleEvaluationRequest{})
+	require.NotNil(t, apiErr)
+	assert.Equal(t, model.ErrorNotFound, apiErr.Typ)
+}
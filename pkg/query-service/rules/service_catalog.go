@@ -0,0 +1,247 @@
+package rules
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// RunbookAnnotation links an alert to the runbook of its service
+const RunbookAnnotation = "runbook_url"
+
+// ChannelNames are stored as json in the channels column
+type ChannelNames []string
+
+func (c *ChannelNames) Scan(src interface{}) error {
+	return scanJSON(src, c)
+}
+
+func (c ChannelNames) Value() (driver.Value, error) {
+	if c == nil {
+		c = ChannelNames{}
+	}
+	return json.Marshal(c)
+}
+
+// ServiceEntry is the ownership of a service in the service catalog of an
+// org. The alerts of the service inherit the team, the channels and the
+// runbook the rule and the series do not set. The entries without an org
+// are shared by all the orgs, an entry of the org for the same service
+// takes precedence.
+type ServiceEntry struct {
+	Id      int64  `json:"id" db:"id"`
+	OrgId   string `json:"orgId" db:"org_id"`
+	Service string `json:"service" db:"service"`
+	Team    string `json:"team" db:"team"`
+	// Channels are the notification channels of the alerts of the rules
+	// without preferred channels
+	Channels  ChannelNames `json:"channels" db:"channels"`
+	Runbook   string       `json:"runbook" db:"runbook"`
+	CreatedAt time.Time    `json:"createdAt" db:"created_at"`
+	CreatedBy string       `json:"createdBy" db:"created_by"`
+	UpdatedAt time.Time    `json:"updatedAt" db:"updated_at"`
+	UpdatedBy string       `json:"updatedBy" db:"updated_by"`
+}
+
+func (e *ServiceEntry) Validate() error {
+	if strings.TrimSpace(e.Service) == "" {
+		return fmt.Errorf("service is required")
+	}
+	if e.Team == "" && len(e.Channels) == 0 && e.Runbook == "" {
+		return fmt.Errorf("service %s: a team, a channel or a runbook is required", e.Service)
+	}
+	for _, c := range e.Channels {
+		if strings.TrimSpace(c) == "" {
+			return fmt.Errorf("service %s: channel names must not be empty", e.Service)
+		}
+	}
+	return nil
+}
+
+// ServiceCatalog holds the service catalogs of the orgs for the
+// evaluations. The map is replaced as a whole on every update so readers
+// can use it without copying.
+type ServiceCatalog struct {
+	mtx sync.RWMutex
+	// orgs are the entries of every org by service
+	orgs map[string]map[string]*ServiceEntry
+	// orgOf returns the org of a rule
+	orgOf func(ruleID string) string
+	// teamLabel is the label the team of the service is set in
+	teamLabel string
+}
+
+func NewServiceCatalog() *ServiceCatalog {
+	return &ServiceCatalog{orgs: map[string]map[string]*ServiceEntry{}, teamLabel: defaultCostTeamLabel}
+}
+
+func (c *ServiceCatalog) set(entries []ServiceEntry) {
+	orgs := map[string]map[string]*ServiceEntry{}
+	for i := range entries {
+		e := &entries[i]
+		if orgs[e.OrgId] == nil {
+			orgs[e.OrgId] = map[string]*ServiceEntry{}
+		}
+		orgs[e.OrgId][e.Service] = e
+	}
+	c.mtx.Lock()
+	c.orgs = orgs
+	c.mtx.Unlock()
+}
+
+// lookup returns the entry of the service for the org of the rule, or the
+// shared one, nil when the service is not in the catalog
+func (c *ServiceCatalog) lookup(ruleID, service string) *ServiceEntry {
+	if c == nil || service == "" {
+		return nil
+	}
+	org := ""
+	if c.orgOf != nil {
+		org = c.orgOf(ruleID)
+	}
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	if e, ok := c.orgs[org][service]; ok {
+		return e
+	}
+	return c.orgs[""][service]
+}
+
+// serviceOwnership is what the catalog adds to an alert of a service
+type serviceOwnership struct {
+	// teamLabel and team are the label to set, empty when the alert has it
+	teamLabel string
+	team      string
+	// runbook is the runbook annotation to add, empty when the alert has it
+	runbook string
+	// receivers are the channels of the alert
+	receivers []string
+}
+
+// ownership returns what the alert of the rule with the labels and the
+// annotations inherits from the catalog entry of its service. The labels,
+// the annotations and the preferred channels of the rule take precedence
+// over the catalog.
+func (c *ServiceCatalog) ownership(ruleID string, lbls, annotations labels.Labels, receivers []string) serviceOwnership {
+	o := serviceOwnership{receivers: receivers}
+	e := c.lookup(ruleID, serviceName(lbls))
+	if e == nil {
+		return o
+	}
+	if e.Team != "" && lbls.Get(c.teamLabel) == "" {
+		o.teamLabel, o.team = c.teamLabel, e.Team
+	}
+	if e.Runbook != "" && annotations.Get(RunbookAnnotation) == "" {
+		o.runbook = e.Runbook
+	}
+	if len(receivers) == 0 && len(e.Channels) > 0 {
+		o.receivers = e.Channels
+	}
+	return o
+}
+
+// ReloadServiceCatalog loads the service catalogs of all the orgs for the
+// evaluations
+func (m *Manager) ReloadServiceCatalog(ctx context.Context) error {
+	entries, err := m.ruleDB.GetServiceEntries(context.Background())
+	if err != nil {
+		return err
+	}
+	m.opts.Services.set(entries)
+	return nil
+}
+
+// ListServiceEntries returns the service catalog visible to the org of the
+// request
+func (m *Manager) ListServiceEntries(ctx context.Context) ([]ServiceEntry, *model.ApiError) {
+	entries, err := m.ruleDB.GetServiceEntries(ctx)
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	return entries, nil
+}
+
+// checkService denies a service another entry of the org has, the org may
+// override a shared entry of the service
+func (m *Manager) checkService(ctx context.Context, service string, id int64) *model.ApiError {
+	entries, err := m.ruleDB.GetServiceEntries(ctx)
+	if err != nil {
+		return newApiErrorInternal(err)
+	}
+	org := tenantOf(ctx)
+	for _, e := range entries {
+		if e.Service == service && e.OrgId == org && e.Id != id {
+			return &model.ApiError{Typ: model.ErrorConflict, Err: fmt.Errorf("service %s is already in the catalog", service)}
+		}
+	}
+	return nil
+}
+
+// CreateServiceEntry adds the service to the catalog of the org of the
+// request
+func (m *Manager) CreateServiceEntry(ctx context.Context, entry *ServiceEntry) *model.ApiError {
+	if err := entry.Validate(); err != nil {
+		return newApiErrorBadData(err)
+	}
+	if apiErr := m.checkService(ctx, entry.Service, 0); apiErr != nil {
+		return apiErr
+	}
+	id, err := m.ruleDB.CreateServiceEntry(ctx, *entry)
+	if err != nil {
+		return newApiErrorInternal(err)
+	}
+	entry.Id, entry.OrgId = id, tenantOf(ctx)
+	if err := m.ReloadServiceCatalog(ctx); err != nil {
+		return newApiErrorInternal(err)
+	}
+	return nil
+}
+
+// EditServiceEntry replaces the catalog entry with the id
+func (m *Manager) EditServiceEntry(ctx context.Context, entry *ServiceEntry, id string) *model.ApiError {
+	if err := entry.Validate(); err != nil {
+		return newApiErrorBadData(err)
+	}
+	entryID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return newApiErrorBadData(fmt.Errorf("invalid service catalog id %s", id))
+	}
+	if apiErr := m.checkService(ctx, entry.Service, entryID); apiErr != nil {
+		return apiErr
+	}
+	if err := m.ruleDB.EditServiceEntry(ctx, *entry, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("service catalog entry %s not found", id)}
+		}
+		return newApiErrorInternal(err)
+	}
+	if err := m.ReloadServiceCatalog(ctx); err != nil {
+		return newApiErrorInternal(err)
+	}
+	return nil
+}
+
+// DeleteServiceEntry removes the service from the catalog, its new alerts
+// only carry what the rules set
+func (m *Manager) DeleteServiceEntry(ctx context.Context, id string) *model.ApiError {
+	if err := m.ruleDB.DeleteServiceEntry(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("service catalog entry %s not found", id)}
+		}
+		return newApiErrorInternal(err)
+	}
+	if err := m.ReloadServiceCatalog(ctx); err != nil {
+		return newApiErrorInternal(err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+func TestServiceEntryValidate(t *testing.T) {
+	assert.NoError(t, (&ServiceEntry{Service: "checkout", Team: "payments"}).Validate())
+	assert.NoError(t, (&ServiceEntry{Service: "checkout", Channels: ChannelNames{"payments-slack"}}).Validate())
+	assert.Error(t, (&ServiceEntry{Team: "payments"}).Validate())
+	assert.Error(t, (&ServiceEntry{Service: "checkout"}).Validate())
+	assert.Error(t, (&ServiceEntry{Service: "checkout", Channels: ChannelNames{" "}}).Validate())
+}
+
+func TestServiceCatalogOwnership(t *testing.T) {
+	var nilCatalog *ServiceCatalog
+	owner := nilCatalog.ownership("1", labels.FromMap(map[string]string{"service_name": "checkout"}), nil, []string{"ops"})
+	assert.Equal(t, serviceOwnership{receivers: []string{"ops"}}, owner)
+
+	c := NewServiceCatalog()
+	c.orgOf = func(ruleID string) string {
+		if ruleID == "1" {
+			return "acme"
+		}
+		return "other"
+	}
+	c.set([]ServiceEntry{
+		{Service: "checkout", Team: "shared", Channels: ChannelNames{"shared-slack"}},
+		{OrgId: "acme", Service: "checkout", Team: "payments", Channels: ChannelNames{"payments-slack"}, Runbook: "https://runbooks.example.com/checkout"},
+	})
+
+	lbls := labels.FromMap(map[string]string{"service.name": "checkout", "severity": "critical"})
+	owner = c.ownership("1", lbls, nil, nil)
+	assert.Equal(t, "team", owner.teamLabel)
+	assert.Equal(t, "payments", owner.team)
+	assert.Equal(t, "https://runbooks.example.com/checkout", owner.runbook)
+	assert.Equal(t, []string{"payments-slack"}, owner.receivers)
+
+	// the other orgs get the shared entry
+	owner = c.ownership("2", lbls, nil, nil)
+	assert.Equal(t, "shared", owner.team)
+	assert.Empty(t, owner.runbook)
+	assert.Equal(t, []string{"shared-slack"}, owner.receivers)
+
+	// what the rule sets takes precedence
+	owner = c.ownership("1",
+		labels.FromMap(map[string]string{"service_name": "checkout", "team": "sre"}),
+		labels.FromMap(map[string]string{RunbookAnnotation: "https://wiki.example.com"}),
+		[]string{"sre-pager"})
+	assert.Empty(t, owner.team)
+	assert.Empty(t, owner.runbook)
+	assert.Equal(t, []string{"sre-pager"}, owner.receivers)
+
+	// the alerts of other services are left as they are
+	owner = c.ownership("1", labels.FromMap(map[string]string{"service_name": "cart"}), nil, nil)
+	assert.Equal(t, serviceOwnership{}, owner)
+
+	c.teamLabel = "owner"
+	assert.Equal(t, "owner", c.ownership("1", lbls, nil, nil).teamLabel)
+}
@@ -0,0 +1,199 @@
+package rules
+
+import (
+	"context"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// shardVirtualNodes is the number of points of a replica on the ring,
+	// more points spread the rules more evenly
+	shardVirtualNodes = 128
+
+	DefaultShardHeartbeatInterval = 10 * time.Second
+)
+
+// hashRing assigns keys to members with consistent hashing so that only
+// the keys of a member that joins or leaves move to another member
+type hashRing struct {
+	points  []uint64
+	members map[uint64]string
+}
+
+func newHashRing(members []string) *hashRing {
+	ring := &hashRing{members: make(map[uint64]string, len(members)*shardVirtualNodes)}
+	for _, member := range members {
+		for i := 0; i < shardVirtualNodes; i++ {
+			point := hashKey(member + "#" + strconv.Itoa(i))
+			ring.points = append(ring.points, point)
+			ring.members[point] = member
+		}
+	}
+	sort.Slice(ring.points, func(i, j int) bool { return ring.points[i] < ring.points[j] })
+	return ring
+}
+
+// owner returns the member of the first point after the key on the ring
+func (h *hashRing) owner(key string) string {
+	if len(h.points) == 0 {
+		return ""
+	}
+	point := hashKey(key)
+	i := sort.Search(len(h.points), func(i int) bool { return h.points[i] >= point })
+	if i == len(h.points) {
+		i = 0
+	}
+	return h.members[h.points[i]]
+}
+
+// hashKey hashes the key with fnv and mixes the bits, fnv alone places
+// keys that only differ in the last characters close on the ring
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// ShardOptions configures the sharding of rules across replicas
+type ShardOptions struct {
+	// ReplicaID identifies this replica, it must be unique and stable
+	ReplicaID string
+	// HeartbeatInterval is how often the replica announces itself and
+	// refreshes the other replicas, replicas that miss three heartbeats
+	// are removed from the ring
+	HeartbeatInterval time.Duration
+}
+
+// Sharder splits the evaluation of the rules across the replicas sharing
+// the rule database. Every replica records a heartbeat and the replicas
+// alive are placed on a consistent hash ring, a replica evaluates only
+// the rules it owns on the ring. The ring is rebuilt on every heartbeat
+// so the rules are rebalanced when replicas join or leave.
+type Sharder struct {
+	opts   ShardOptions
+	ruleDB RuleDB
+
+	mtx     sync.RWMutex
+	ring    *hashRing
+	members []string
+
+	done       chan struct{}
+	terminated chan struct{}
+}
+
+func NewSharder(opts ShardOptions, ruleDB RuleDB) *Sharder {
+	if opts.HeartbeatInterval <= 0 {
+		opts.HeartbeatInterval = DefaultShardHeartbeatInterval
+	}
+	return &Sharder{
+		opts:       opts,
+		ruleDB:     ruleDB,
+		done:       make(chan struct{}),
+		terminated: make(chan struct{}),
+	}
+}
+
+// Run sends heartbeats and rebalances the rules until Stop is called
+func (s *Sharder) Run(ctx context.Context) {
+	defer close(s.terminated)
+
+	tick := time.NewTicker(s.opts.HeartbeatInterval)
+	defer tick.Stop()
+
+	for {
+		s.refresh(ctx)
+		select {
+		case <-s.done:
+			// leave the ring so that the other replicas take over the
+			// rules without waiting for the heartbeat to expire
+			if err := s.ruleDB.DeleteEvaluator(ctx, s.opts.ReplicaID); err != nil {
+				zap.L().Error("failed to remove the replica from the rule evaluators", zap.String("replica", s.opts.ReplicaID), zap.Error(err))
+			}
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+func (s *Sharder) Stop() {
+	close(s.done)
+	<-s.terminated
+}
+
+// refresh records the heartbeat of the replica and rebuilds the ring
+// from the replicas that are alive
+func (s *Sharder) refresh(ctx context.Context) {
+	now := time.Now().UTC()
+	if err := s.ruleDB.HeartbeatEvaluator(ctx, s.opts.ReplicaID, now); err != nil {
+		zap.L().Error("failed to record the rule evaluator heartbeat", zap.String("replica", s.opts.ReplicaID), zap.Error(err))
+		return
+	}
+	members, err := s.ruleDB.GetLiveEvaluators(ctx, now.Add(-3*s.opts.HeartbeatInterval))
+	if err != nil {
+		zap.L().Error("failed to get the rule evaluators", zap.Error(err))
+		return
+	}
+	s.setMembers(members)
+}
+
+func (s *Sharder) setMembers(members []string) {
+	sort.Strings(members)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if equalStrings(s.members, members) {
+		return
+	}
+	zap.L().Info("rebalancing rules across replicas", zap.String("replica", s.opts.ReplicaID), zap.Strings("previous", s.members), zap.Strings("members", members))
+	s.members = members
+	s.ring = newHashRing(members)
+}
+
+// Owns tells whether this replica evaluates the rule. Every replica
+// evaluates all rules until the ring is known, a nil sharder owns all
+// the rules.
+func (s *Sharder) Owns(ruleID string) bool {
+	if s == nil {
+		return true
+	}
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	if s.ring == nil || len(s.members) == 0 {
+		return true
+	}
+	return s.ring.owner(ruleID) == s.opts.ReplicaID
+}
+
+// Members returns the replicas on the ring
+func (s *Sharder) Members() []string {
+	if s == nil {
+		return nil
+	}
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return append([]string(nil), s.members...)
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package rules
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashRingRebalance(t *testing.T) {
+	members := []string{"replica-0", "replica-1", "replica-2"}
+	ring := newHashRing(members)
+
+	owners := map[string]string{}
+	counts := map[string]int{}
+	for i := 0; i < 3000; i++ {
+		rule := fmt.Sprintf("%d", i)
+		owners[rule] = ring.owner(rule)
+		counts[owners[rule]]++
+	}
+	for _, member := range members {
+		assert.InDelta(t, 1000, counts[member], 250, member)
+	}
+
+	// only the rules of the replica that left move
+	ring = newHashRing([]string{"replica-0", "replica-2"})
+	for rule, owner := range owners {
+		if owner != "replica-1" {
+			assert.Equal(t, owner, ring.owner(rule), rule)
+		}
+	}
+
+	// a replica that joins only takes rules from the others
+	ring = newHashRing(append(members, "replica-3"))
+	moved := 0
+	for rule, owner := range owners {
+		if newOwner := ring.owner(rule); newOwner != owner {
+			assert.Equal(t, "replica-3", newOwner, rule)
+			moved++
+		}
+	}
+	assert.InDelta(t, 750, moved, 250)
+}
+
+func TestSharderOwns(t *testing.T) {
+	var nilSharder *Sharder
+	assert.True(t, nilSharder.Owns("1"))
+
+	a := NewSharder(ShardOptions{ReplicaID: "a"}, nil)
+	b := NewSharder(ShardOptions{ReplicaID: "b"}, nil)
+
+	// all rules are evaluated until the ring is known
+	assert.True(t, a.Owns("1"))
+
+	a.setMembers([]string{"b", "a"})
+	b.setMembers([]string{"a", "b"})
+	assert.Equal(t, []string{"a", "b"}, a.Members())
+
+	for i := 0; i < 100; i++ {
+		rule := fmt.Sprintf("%d", i)
+		assert.NotEqual(t, a.Owns(rule), b.Owns(rule), rule)
+	}
+
+	// the remaining replica takes over the rules
+	a.setMembers([]string{"a"})
+	for i := 0; i < 100; i++ {
+		assert.True(t, a.Owns(fmt.Sprintf("%d", i)))
+	}
+}
@@ -0,0 +1,225 @@
+package rules
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/common"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultShareLinkTTL is how long a share link is valid when the
+	// request does not set its expiry
+	DefaultShareLinkTTL = 24 * time.Hour
+	// MaxShareLinkTTL caps the validity of the share links
+	MaxShareLinkTTL = 7 * 24 * time.Hour
+	// shareLinkHistory is how far back the shared view shows the firing
+	// periods of the alerts
+	shareLinkHistory = 7 * 24 * time.Hour
+)
+
+// sharedAnnotations are the annotations of the rule shown by the share
+// links, the others may link into the app
+var sharedAnnotations = []string{"summary", "description"}
+
+var errShareLinkNotFound = errors.New("share link not found or expired")
+
+// ShareLink gives read-only access without login to the current state and
+// history of a rule, or of a single alert of it when Fingerprint is set
+type ShareLink struct {
+	Id          int64  `json:"id"`
+	RuleID      string `json:"ruleId"`
+	Fingerprint uint64 `json:"fingerprint,omitempty"`
+	// Token is only returned when the link is created, the db keeps its
+	// hash
+	Token string `json:"token,omitempty"`
+	// Path is the path of the shared view, with the token
+	Path      string    `json:"path,omitempty"`
+	TokenHash string    `json:"-"`
+	OrgId     string    `json:"-"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+	CreatedBy string    `json:"createdBy"`
+}
+
+// ShareLinkRequest creates a share link of a rule
+type ShareLinkRequest struct {
+	Fingerprint uint64    `json:"fingerprint,omitempty"`
+	ExpiresAt   time.Time `json:"expiresAt,omitempty"`
+}
+
+func (r *ShareLinkRequest) Validate(now time.Time) error {
+	if r.ExpiresAt.IsZero() {
+		r.ExpiresAt = now.Add(DefaultShareLinkTTL)
+	}
+	if !r.ExpiresAt.After(now) {
+		return fmt.Errorf("expiresAt must be in the future")
+	}
+	if r.ExpiresAt.Sub(now) > MaxShareLinkTTL {
+		return fmt.Errorf("share links expire at most %s after they are created", MaxShareLinkTTL)
+	}
+	return nil
+}
+
+// SharedAlertView is what a share link shows: the rule with its pending
+// and firing alerts, and the periods they fired recently
+type SharedAlertView struct {
+	RuleID      string            `json:"ruleId"`
+	RuleName    string            `json:"ruleName"`
+	State       string            `json:"state"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	Fingerprint uint64            `json:"fingerprint,omitempty"`
+	Alerts      []ActiveAlert     `json:"alerts"`
+	History     []Annotation      `json:"history"`
+	ExpiresAt   time.Time         `json:"expiresAt"`
+}
+
+func shareTokenHash(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// CreateShareLink creates an expiring share link of the rule, the token
+// of the link is only returned here
+func (m *Manager) CreateShareLink(ctx context.Context, ruleID string, req *ShareLinkRequest) (*ShareLink, *model.ApiError) {
+	now := time.Now()
+	if err := req.Validate(now); err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorBadData, Err: err}
+	}
+	m.mtx.RLock()
+	_, ok := m.rules[ruleID]
+	m.mtx.RUnlock()
+	if !ok {
+		return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("rule %s is not active", ruleID)}
+	}
+
+	token, err := utils.RandomHex(32)
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	link := ShareLink{
+		RuleID:      ruleID,
+		Fingerprint: req.Fingerprint,
+		TokenHash:   shareTokenHash(token),
+		OrgId:       tenantOf(ctx),
+		ExpiresAt:   req.ExpiresAt,
+		CreatedAt:   now,
+	}
+	if user := common.GetUserFromContext(ctx); user != nil {
+		link.CreatedBy = user.Email
+	}
+	link.Id, err = m.ruleDB.CreateShareLink(ctx, link)
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	link.Token = token
+	link.Path = "/api/v1/public/share/" + token
+	return &link, nil
+}
+
+// ShareLinks returns the share links of the rule that did not expire
+func (m *Manager) ShareLinks(ctx context.Context, ruleID string) ([]ShareLink, *model.ApiError) {
+	links, err := m.ruleDB.GetShareLinks(ctx, ruleID, time.Now())
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	return links, nil
+}
+
+// RevokeShareLink deletes the share link of the rule, the link stops
+// working immediately
+func (m *Manager) RevokeShareLink(ctx context.Context, ruleID string, id int64) *model.ApiError {
+	if err := m.ruleDB.DeleteShareLink(ctx, ruleID, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("share link %d not found", id)}
+		}
+		return newApiErrorInternal(err)
+	}
+	return nil
+}
+
+// SharedAlertView returns the view of the share link with the token, the
+// unknown and the expired links are not told apart
+func (m *Manager) SharedAlertView(ctx context.Context, token string) (*SharedAlertView, *model.ApiError) {
+	notFound := &model.ApiError{Typ: model.ErrorNotFound, Err: errShareLinkNotFound}
+	link, err := m.ruleDB.GetShareLink(ctx, shareTokenHash(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, notFound
+		}
+		return nil, newApiErrorInternal(err)
+	}
+	now := time.Now()
+	if !link.ExpiresAt.After(now) {
+		return nil, notFound
+	}
+
+	m.mtx.RLock()
+	rule, ok := m.rules[link.RuleID]
+	m.mtx.RUnlock()
+	if !ok {
+		return nil, notFound
+	}
+	view := &SharedAlertView{
+		RuleID:      link.RuleID,
+		RuleName:    rule.Name(),
+		State:       rule.State().String(),
+		Labels:      rule.Labels().Map(),
+		Annotations: map[string]string{},
+		Fingerprint: link.Fingerprint,
+		Alerts:      []ActiveAlert{},
+		History:     []Annotation{},
+		ExpiresAt:   link.ExpiresAt,
+	}
+
+	for _, name := range sharedAnnotations {
+		if v := rule.Annotations().Get(name); v != "" {
+			view.Annotations[name] = v
+		}
+	}
+
+	alerts, apiErr := m.RuleAlerts(link.RuleID)
+	if apiErr != nil {
+		return nil, notFound
+	}
+	for _, a := range alerts {
+		if link.Fingerprint == 0 || a.Fingerprint == link.Fingerprint {
+			view.Alerts = append(view.Alerts, a)
+		}
+	}
+
+	if m.reader != nil {
+		req := &AnnotationsRequest{Start: now.Add(-shareLinkHistory).UnixMilli(), End: now.UnixMilli(), RuleID: link.RuleID}
+		changes, err := m.reader.ReadRuleStateChanges(ctx, req.Start, req.End)
+		if err != nil {
+			zap.L().Error("failed to read the history of a shared rule", zap.String("rule", link.RuleID), zap.Error(err))
+			return view, nil
+		}
+		if history, err := sharedHistory(changes, link, req, rule.Labels().Map()); err == nil {
+			view.History = history
+		}
+	}
+	return view, nil
+}
+
+// sharedHistory returns the firing periods of the alerts of the share
+// link, without the links into the app the viewers cannot open
+func sharedHistory(changes []v3.RuleStateHistory, link *ShareLink, req *AnnotationsRequest, ruleLabels map[string]string) ([]Annotation, error) {
+	selected := make([]v3.RuleStateHistory, 0, len(changes))
+	for _, c := range changes {
+		if c.RuleID == link.RuleID && (link.Fingerprint == 0 || c.Fingerprint == link.Fingerprint) {
+			selected = append(selected, c)
+		}
+	}
+	return buildAnnotations(selected, req, map[string]ruleAnnotationInfo{link.RuleID: {labels: ruleLabels}})
+}
@@ -0,0 +1,132 @@
+package rules
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// shareLinkDB keeps the share links in memory
+type shareLinkDB struct {
+	RuleDB
+	links []ShareLink
+}
+
+func (db *shareLinkDB) CreateShareLink(_ context.Context, link ShareLink) (int64, error) {
+	link.Id = int64(len(db.links) + 1)
+	db.links = append(db.links, link)
+	return link.Id, nil
+}
+
+func (db *shareLinkDB) GetShareLink(_ context.Context, tokenHash string) (*ShareLink, error) {
+	for _, link := range db.links {
+		if link.TokenHash == tokenHash {
+			return &link, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (db *shareLinkDB) DeleteShareLink(_ context.Context, ruleId string, id int64) error {
+	for i, link := range db.links {
+		if link.RuleID == ruleId && link.Id == id {
+			db.links = append(db.links[:i], db.links[i+1:]...)
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func TestShareLinks(t *testing.T) {
+	target := 10.0
+	postableRule := PostableRule{
+		AlertName:   "High error rate",
+		AlertType:   "METRIC_BASED_ALERT",
+		RuleType:    RuleTypeThreshold,
+		EvalWindow:  Duration(5 * time.Minute),
+		Frequency:   Duration(1 * time.Minute),
+		Annotations: map[string]string{"summary": "errors are high", "dashboard": "http://signoz.internal/d/1"},
+		RuleCondition: &RuleCondition{
+			CompositeQuery: &v3.CompositeQuery{
+				QueryType: v3.QueryTypeClickHouseSQL,
+				ClickHouseQueries: map[string]*v3.ClickHouseQuery{
+					"A": {Query: "SELECT 1"},
+				},
+			},
+			CompareOp: ValueIsAbove,
+			MatchType: AtleastOnce,
+			Target:    &target,
+		},
+	}
+	rule, err := NewThresholdRule("7", &postableRule, ThresholdRuleOpts{}, nil, nil)
+	require.NoError(t, err)
+	api := labels.FromMap(map[string]string{"service": "api"})
+	rule.active.Set(1, &Alert{State: StateFiring, Labels: api})
+	rule.active.Set(2, &Alert{State: StateFiring, Labels: labels.FromMap(map[string]string{"service": "web"})})
+
+	db := &shareLinkDB{}
+	m := &Manager{rules: map[string]Rule{"7": rule}, ruleDB: db, opts: &ManagerOptions{}}
+	ctx := context.Background()
+
+	_, apiErr := m.CreateShareLink(ctx, "8", &ShareLinkRequest{})
+	require.NotNil(t, apiErr)
+	assert.Equal(t, model.ErrorNotFound, apiErr.Typ)
+	_, apiErr = m.CreateShareLink(ctx, "7", &ShareLinkRequest{ExpiresAt: time.Now().Add(MaxShareLinkTTL + time.Hour)})
+	require.NotNil(t, apiErr)
+	assert.Equal(t, model.ErrorBadData, apiErr.Typ)
+
+	link, apiErr := m.CreateShareLink(ctx, "7", &ShareLinkRequest{Fingerprint: api.Hash()})
+	require.Nil(t, apiErr)
+	assert.NotEmpty(t, link.Token)
+	// only the hash of the token is stored
+	assert.NotEqual(t, link.Token, db.links[0].TokenHash)
+	assert.Empty(t, db.links[0].Token)
+
+	view, apiErr := m.SharedAlertView(ctx, link.Token)
+	require.Nil(t, apiErr)
+	assert.Equal(t, "High error rate", view.RuleName)
+	assert.Equal(t, map[string]string{"summary": "errors are high"}, view.Annotations)
+	require.Len(t, view.Alerts, 1)
+	assert.Equal(t, "api", view.Alerts[0].Labels["service"])
+
+	_, apiErr = m.SharedAlertView(ctx, "unknown")
+	assert.Equal(t, model.ErrorNotFound, apiErr.Typ)
+
+	// the expired links no longer show the rule
+	db.links[0].ExpiresAt = time.Now().Add(-time.Minute)
+	_, apiErr = m.SharedAlertView(ctx, link.Token)
+	assert.Equal(t, model.ErrorNotFound, apiErr.Typ)
+
+	require.Nil(t, m.RevokeShareLink(ctx, "7", link.Id))
+	assert.Empty(t, db.links)
+	apiErr = m.RevokeShareLink(ctx, "7", link.Id)
+	assert.Equal(t, model.ErrorNotFound, apiErr.Typ)
+}
+
+func TestSharedHistory(t *testing.T) {
+	changes := []v3.RuleStateHistory{
+		{RuleID: "7", RuleName: "High error rate", State: "firing", StateChanged: true, UnixMilli: 1000, Fingerprint: 1},
+		{RuleID: "7", RuleName: "High error rate", State: "firing", StateChanged: true, UnixMilli: 2000, Fingerprint: 2},
+		{RuleID: "8", RuleName: "Slow queries", State: "firing", StateChanged: true, UnixMilli: 3000, Fingerprint: 1},
+		{RuleID: "7", RuleName: "High error rate", State: "normal", StateChanged: true, UnixMilli: 4000, Fingerprint: 1},
+	}
+	req := &AnnotationsRequest{Start: 0, End: 5000, RuleID: "7"}
+
+	history, err := sharedHistory(changes, &ShareLink{RuleID: "7", Fingerprint: 1}, req, nil)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, int64(1000), history[0].Time)
+	assert.Equal(t, int64(4000), history[0].TimeEnd)
+	assert.Empty(t, history[0].Link)
+
+	history, err = sharedHistory(changes, &ShareLink{RuleID: "7"}, req, nil)
+	require.NoError(t, err)
+	assert.Len(t, history, 2)
+}
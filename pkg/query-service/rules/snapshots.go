@@ -0,0 +1,155 @@
+package rules
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// snapshotRetention is how long a rendered chart stays available
+	// for notification channels to fetch
+	snapshotRetention = 24 * time.Hour
+
+	// maxSnapshots bounds the memory used by the snapshot store
+	maxSnapshots = 1000
+
+	// ChartSnapshotAnnotation holds the url of the chart snapshot
+	// rendered when the alert started firing
+	ChartSnapshotAnnotation = "chart_snapshot"
+)
+
+type snapshot struct {
+	id        string
+	png       []byte
+	createdAt time.Time
+}
+
+// snapshotDB stores the snapshots in the rule db
+type snapshotDB interface {
+	CreateChartSnapshot(ctx context.Context, id string, png []byte, createdAt, before time.Time) error
+	GetChartSnapshot(ctx context.Context, id string, after time.Time) ([]byte, error)
+}
+
+// SnapshotStore keeps the chart snapshots rendered by the rules. Slack,
+// email and teams notifications embed the image by url so the store only
+// needs to serve them for a limited time. The snapshots are stored in the
+// rule db when there is one, so that every replica serves them and they
+// survive restarts, and in memory otherwise.
+//
+// The clients fetching the images have no credentials, so the urls are
+// signed with key and expire with the snapshot.
+type SnapshotStore struct {
+	db  snapshotDB
+	key []byte
+
+	mtx   sync.RWMutex
+	items map[string]*snapshot
+	order []string
+}
+
+func NewSnapshotStore() *SnapshotStore {
+	// the urls signed with a random key are only valid on this replica,
+	// the manager shares the key derived from the jwt secret when set
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		zap.L().Error("failed to generate the chart snapshots key", zap.Error(err))
+	}
+	return &SnapshotStore{
+		key:   key,
+		items: map[string]*snapshot{},
+	}
+}
+
+// snapshotKey derives the key signing the snapshot urls from the secret
+// shared by the replicas
+func snapshotKey(secret string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("chart snapshots"))
+	return mac.Sum(nil)
+}
+
+func (s *SnapshotStore) signature(id string, expires int64) string {
+	mac := hmac.New(sha256.New, s.key)
+	fmt.Fprintf(mac, "%s:%d", id, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignedPath returns the path the snapshot is fetched with, valid for as
+// long as the snapshot is kept
+func (s *SnapshotStore) SignedPath(id string) string {
+	expires := time.Now().Add(snapshotRetention).Unix()
+	return fmt.Sprintf("/api/v1/alerts/snapshots/%s?expires=%d&signature=%s", id, expires, s.signature(id, expires))
+}
+
+// Verify tells whether the signature of the snapshot url is valid and
+// the url did not expire
+func (s *SnapshotStore) Verify(id, expires, signature string, now time.Time) bool {
+	ts, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || now.Unix() >= ts {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(s.signature(id, ts)))
+}
+
+// Put stores the png and returns the id it can be fetched with
+func (s *SnapshotStore) Put(ctx context.Context, png []byte) string {
+	id := uuid.New().String()
+	now := time.Now()
+	if s.db != nil {
+		err := s.db.CreateChartSnapshot(ctx, id, png, now, now.Add(-snapshotRetention))
+		if err == nil {
+			return id
+		}
+		zap.L().Error("failed to store the chart snapshot, it is only served by this replica", zap.Error(err))
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	// order is sorted by creation time, drop expired entries and
+	// the oldest ones when the store is full
+	for len(s.order) > 0 {
+		oldest := s.items[s.order[0]]
+		if now.Sub(oldest.createdAt) < snapshotRetention && len(s.order) < maxSnapshots {
+			break
+		}
+		delete(s.items, oldest.id)
+		s.order = s.order[1:]
+	}
+
+	s.items[id] = &snapshot{id: id, png: png, createdAt: now}
+	s.order = append(s.order, id)
+	return id
+}
+
+// Get returns the png stored with the given id
+func (s *SnapshotStore) Get(ctx context.Context, id string) ([]byte, bool) {
+	s.mtx.RLock()
+	item, ok := s.items[id]
+	s.mtx.RUnlock()
+	if ok {
+		if time.Since(item.createdAt) >= snapshotRetention {
+			return nil, false
+		}
+		return item.png, true
+	}
+
+	if s.db == nil {
+		return nil, false
+	}
+	png, err := s.db.GetChartSnapshot(ctx, id, time.Now().Add(-snapshotRetention))
+	if err != nil {
+		return nil, false
+	}
+	return png, true
+}
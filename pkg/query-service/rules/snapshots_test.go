@@ -0,0 +1,184 @@
+package rules
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/constants"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// snapshotsDB keeps the chart snapshots in memory like the shared rule db
+type snapshotsDB struct {
+	RuleDB
+	pngs    map[string][]byte
+	created map[string]time.Time
+	err     error
+}
+
+func (db *snapshotsDB) CreateChartSnapshot(_ context.Context, id string, png []byte, createdAt, before time.Time) error {
+	if db.err != nil {
+		return db.err
+	}
+	for id, t := range db.created {
+		if t.Before(before) {
+			delete(db.pngs, id)
+			delete(db.created, id)
+		}
+	}
+	db.pngs[id] = png
+	db.created[id] = createdAt
+	return nil
+}
+
+func (db *snapshotsDB) GetChartSnapshot(_ context.Context, id string, after time.Time) ([]byte, error) {
+	png, ok := db.pngs[id]
+	if !ok || !db.created[id].After(after) {
+		return nil, sql.ErrNoRows
+	}
+	return png, nil
+}
+
+func TestSnapshotStoreSharedDB(t *testing.T) {
+	ctx := context.Background()
+	db := &snapshotsDB{pngs: map[string][]byte{}, created: map[string]time.Time{}}
+
+	// the snapshot rendered by a replica is served by the others and
+	// after a restart
+	rendering := &SnapshotStore{db: db, items: map[string]*snapshot{}}
+	serving := &SnapshotStore{db: db, items: map[string]*snapshot{}}
+	id := rendering.Put(ctx, []byte("png"))
+	assert.Empty(t, rendering.items)
+
+	png, ok := serving.Get(ctx, id)
+	require.True(t, ok)
+	assert.Equal(t, []byte("png"), png)
+
+	_, ok = serving.Get(ctx, "unknown")
+	assert.False(t, ok)
+
+	// the expired snapshots are not served
+	db.created[id] = time.Now().Add(-snapshotRetention - time.Minute)
+	_, ok = serving.Get(ctx, id)
+	assert.False(t, ok)
+
+	// and removed with the next snapshot
+	rendering.Put(ctx, []byte("next"))
+	assert.NotContains(t, db.pngs, id)
+}
+
+func TestSnapshotStoreMemory(t *testing.T) {
+	ctx := context.Background()
+	s := NewSnapshotStore()
+	id := s.Put(ctx, []byte("png"))
+	png, ok := s.Get(ctx, id)
+	require.True(t, ok)
+	assert.Equal(t, []byte("png"), png)
+
+	s.items[id].createdAt = time.Now().Add(-snapshotRetention)
+	_, ok = s.Get(ctx, id)
+	assert.False(t, ok)
+
+	// the snapshots the db fails to store are kept in memory
+	failing := &SnapshotStore{db: &snapshotsDB{err: errors.New("database is locked")}, items: map[string]*snapshot{}}
+	id = failing.Put(ctx, []byte("png"))
+	png, ok = failing.Get(ctx, id)
+	require.True(t, ok)
+	assert.Equal(t, []byte("png"), png)
+}
+
+func TestSnapshotSignedPath(t *testing.T) {
+	ctx := context.Background()
+	s := NewSnapshotStore()
+	id := s.Put(ctx, []byte("png"))
+	u, err := url.Parse(s.SignedPath(id))
+	require.NoError(t, err)
+	assert.Equal(t, "/api/v1/alerts/snapshots/"+id, u.Path)
+	expires, signature := u.Query().Get("expires"), u.Query().Get("signature")
+
+	now := time.Now()
+	assert.True(t, s.Verify(id, expires, signature, now))
+	// the signature is bound to the snapshot and its expiry
+	assert.False(t, s.Verify(uuid.New().String(), expires, signature, now))
+	assert.False(t, s.Verify(id, fmt.Sprint(now.Add(2*snapshotRetention).Unix()), signature, now))
+	assert.False(t, s.Verify(id, expires, "", now))
+	assert.False(t, s.Verify(id, "", signature, now))
+	assert.False(t, s.Verify(id, expires, signature, now.Add(snapshotRetention+time.Minute)))
+	// the stores of other replicas only accept the urls with the shared key
+	assert.False(t, NewSnapshotStore().Verify(id, expires, signature, now))
+	s.key = snapshotKey("secret")
+	replica := &SnapshotStore{key: snapshotKey("secret"), items: map[string]*snapshot{}}
+	u, err = url.Parse(s.SignedPath(id))
+	require.NoError(t, err)
+	assert.True(t, replica.Verify(id, u.Query().Get("expires"), u.Query().Get("signature"), now))
+
+	// the manager only serves the snapshots of valid urls
+	m := &Manager{opts: &ManagerOptions{Snapshots: s}}
+	png, ok := m.GetChartSnapshot(ctx, id, u.Query().Get("expires"), u.Query().Get("signature"))
+	require.True(t, ok)
+	assert.Equal(t, []byte("png"), png)
+	_, ok = m.GetChartSnapshot(ctx, id, "", "")
+	assert.False(t, ok)
+}
+
+func TestEnrichFiringSpilledAlert(t *testing.T) {
+	enabled := constants.AlertChartSnapshots
+	constants.AlertChartSnapshots = "true"
+	defer func() { constants.AlertChartSnapshots = enabled }()
+
+	spill, err := NewDiskAlertSpill(t.TempDir())
+	require.NoError(t, err)
+	target := 10.0
+	rule := &PostableRule{
+		AlertName: "high load",
+		Source:    "http://signoz:3301/alerts",
+		RuleCondition: &RuleCondition{
+			CompositeQuery: &v3.CompositeQuery{QueryType: v3.QueryTypeBuilder},
+			Target:         &target,
+			CompareOp:      ValueIsAbove,
+			MatchType:      AtleastOnce,
+			SelectedQuery:  "A",
+		},
+	}
+	snapshots := NewSnapshotStore()
+	tr, err := NewThresholdRule("1", rule, ThresholdRuleOpts{Snapshots: snapshots, MaxActiveAlerts: 1, AlertSpill: spill}, nil, nil)
+	require.NoError(t, err)
+
+	firedAt := time.Now()
+	tr.active.Set(1, &Alert{State: StateFiring, FiredAt: firedAt})
+	tr.active.Set(2, &Alert{State: StateFiring, FiredAt: firedAt})
+	require.Equal(t, 1, tr.active.Stats().Spilled)
+
+	points := []v3.Point{{Timestamp: 0, Value: 5}, {Timestamp: 60000, Value: 15}}
+	tr.enrichFiring(context.Background(), []firingAlert{
+		{fp: 1, firedAt: firedAt, points: points, alert: &Alert{Annotations: labels.Labels{}}},
+		// fired again since, the snapshot of the old breach is dropped
+		{fp: 2, firedAt: firedAt.Add(-time.Hour), points: points, alert: &Alert{Annotations: labels.Labels{}}},
+	}, firedAt)
+
+	a, ok := tr.active.Get(1)
+	require.True(t, ok)
+	require.NotEmpty(t, a.SnapshotURL)
+	assert.Equal(t, a.SnapshotURL, a.Annotations.Map()[ChartSnapshotAnnotation])
+	u, err := url.Parse(a.SnapshotURL)
+	require.NoError(t, err)
+	assert.Equal(t, "signoz:3301", u.Host)
+	id := strings.TrimPrefix(u.Path, "/api/v1/alerts/snapshots/")
+	assert.True(t, snapshots.Verify(id, u.Query().Get("expires"), u.Query().Get("signature"), time.Now()))
+	_, ok = snapshots.Get(context.Background(), id)
+	assert.True(t, ok)
+
+	a, ok = tr.active.Get(2)
+	require.True(t, ok)
+	assert.Empty(t, a.SnapshotURL)
+}
@@ -0,0 +1,74 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	text_template "text/template"
+)
+
+var snippetNameRe = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_.-]*$`)
+
+// TemplateSnippet is a named template stored on the server that the
+// annotations of any rule can include with {{ template "name" . }}
+type TemplateSnippet struct {
+	Id          int64     `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	Content     string    `json:"content" db:"content"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+	CreatedBy   string    `json:"createdBy" db:"created_by"`
+	UpdatedAt   time.Time `json:"updatedAt" db:"updated_at"`
+	UpdatedBy   string    `json:"updatedBy" db:"updated_by"`
+}
+
+func (s *TemplateSnippet) Validate() error {
+	if !snippetNameRe.MatchString(s.Name) {
+		return fmt.Errorf("invalid snippet name %q, it must start with a letter and contain only letters, digits, '_', '.' and '-'", s.Name)
+	}
+	if s.Content == "" {
+		return fmt.Errorf("snippet content is required")
+	}
+	// parse with the functions of the expander so that unknown functions
+	// are reported when the snippet is saved
+	expander := NewTemplateExpander(context.Background(), "", s.Name, nil, 0, nil)
+	if _, err := text_template.New(s.Name).Funcs(expander.funcMap).Parse(s.Content); err != nil {
+		return fmt.Errorf("invalid snippet template: %w", err)
+	}
+	return nil
+}
+
+// SnippetCache holds the content of the stored snippets by name for the
+// rules to expand their templates with. The map is replaced as a whole
+// on every update so readers can use it without copying.
+type SnippetCache struct {
+	mtx      sync.RWMutex
+	snippets map[string]string
+}
+
+func NewSnippetCache() *SnippetCache {
+	return &SnippetCache{snippets: map[string]string{}}
+}
+
+func (c *SnippetCache) Set(snippets []TemplateSnippet) {
+	m := make(map[string]string, len(snippets))
+	for _, s := range snippets {
+		m[s.Name] = s.Content
+	}
+	c.mtx.Lock()
+	c.snippets = m
+	c.mtx.Unlock()
+}
+
+// All returns the snippet contents by name, the map must not be modified
+func (c *SnippetCache) All() map[string]string {
+	if c == nil {
+		return nil
+	}
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return c.snippets
+}
@@ -0,0 +1,271 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultStaleRuleAfter is how long the query of a rule returns no
+	// series before the rule is stale
+	DefaultStaleRuleAfter = 7 * 24 * time.Hour
+
+	staleRuleCheckInterval = time.Hour
+	staleRuleCheckTimeout  = 5 * time.Minute
+	staleRuleKey           = "stale-rules"
+)
+
+// StaleRuleOptions configures the detection of the rules whose query
+// stopped returning series
+type StaleRuleOptions struct {
+	// After is how long the query of a rule returns no series before the
+	// rule is stale, DefaultStaleRuleAfter when not set
+	After time.Duration
+	// AutoDisable disables the stale rules
+	AutoDisable bool
+}
+
+// StaleRule is a rule whose query stopped returning series, e.g. after
+// the metric was renamed or the service deleted
+type StaleRule struct {
+	RuleID   string `json:"ruleId"`
+	RuleName string `json:"ruleName"`
+	// Disabled tells whether the rule is disabled, by hand or
+	// automatically
+	Disabled   bool      `json:"disabled"`
+	EmptySince time.Time `json:"emptySince"`
+	// EmptyDays is the number of days the query returned no series
+	EmptyDays    float64    `json:"emptyDays"`
+	LastSeriesAt *time.Time `json:"lastSeriesAt,omitempty"`
+	// LastFired is when an alert of the rule last started firing, zero
+	// when none did within the retention of the state history
+	LastFired      int64      `json:"lastFired,omitempty"`
+	AutoDisabledAt *time.Time `json:"autoDisabledAt,omitempty"`
+}
+
+// seriesActivity tracks since when the queries of the rules return no
+// series. It is written to the rule db only when a query starts or
+// stops returning series.
+type seriesActivity struct {
+	db RuleDB
+
+	mtx      sync.Mutex
+	activity map[string]SeriesActivity
+}
+
+func newSeriesActivity(db RuleDB) *seriesActivity {
+	return &seriesActivity{db: db, activity: map[string]SeriesActivity{}}
+}
+
+func (s *seriesActivity) load(ctx context.Context) {
+	stored, err := s.db.GetSeriesActivity(ctx)
+	if err != nil {
+		zap.L().Error("failed to load the series activity of the rules", zap.Error(err))
+		return
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, a := range stored {
+		s.activity[a.RuleID] = a
+	}
+}
+
+// observe records the number of series an evaluation of the rule returned
+func (s *seriesActivity) observe(ctx context.Context, ruleID string, series int, ts time.Time) {
+	s.mtx.Lock()
+	a, ok := s.activity[ruleID]
+	prev := a
+	a.RuleID = ruleID
+	// the rule is evaluated again after it was disabled
+	a.AutoDisabledAt = nil
+	if series > 0 {
+		a.EmptySince = nil
+		a.LastSeriesAt = &ts
+	} else if a.EmptySince == nil || prev.AutoDisabledAt != nil {
+		a.EmptySince = &ts
+	}
+	changed := !ok || prev.AutoDisabledAt != nil || (prev.EmptySince == nil) != (a.EmptySince == nil)
+	s.activity[ruleID] = a
+	s.mtx.Unlock()
+
+	if !changed {
+		return
+	}
+	if err := s.db.SetSeriesActivity(ctx, a); err != nil {
+		zap.L().Error("failed to store the series activity of the rule", zap.String("ruleid", ruleID), zap.Error(err))
+	}
+}
+
+// disabled records that the rule was disabled for returning no series
+func (s *seriesActivity) disabled(ctx context.Context, ruleID string, ts time.Time) error {
+	s.mtx.Lock()
+	a := s.activity[ruleID]
+	a.RuleID = ruleID
+	a.AutoDisabledAt = &ts
+	s.activity[ruleID] = a
+	s.mtx.Unlock()
+	return s.db.SetSeriesActivity(ctx, a)
+}
+
+func (s *seriesActivity) forget(ctx context.Context, ruleID string) {
+	s.mtx.Lock()
+	delete(s.activity, ruleID)
+	s.mtx.Unlock()
+	if err := s.db.DeleteSeriesActivity(ctx, ruleID); err != nil {
+		zap.L().Error("failed to delete the series activity of the rule", zap.String("ruleid", ruleID), zap.Error(err))
+	}
+}
+
+// observeSeries records whether the last evaluation of the rule returned
+// series
+func observeSeries(ctx context.Context, opts *ManagerOptions, rule Rule, ts time.Time) {
+	if opts.seriesActivity == nil {
+		return
+	}
+	if r, ok := rule.(interface{ SamplesReturned() int }); ok {
+		opts.seriesActivity.observe(ctx, rule.ID(), r.SamplesReturned(), ts)
+	}
+}
+
+// findStaleRules returns the rules returning no series for at least
+// after, and the ones disabled for it, the longest empty first
+func findStaleRules(activity []SeriesActivity, rules map[string]*PostableRule, lastFired map[string]int64, after time.Duration, now time.Time) []StaleRule {
+	stale := []StaleRule{}
+	for _, a := range activity {
+		rule, ok := rules[a.RuleID]
+		if !ok || a.EmptySince == nil {
+			continue
+		}
+		empty := now.Sub(*a.EmptySince)
+		if empty < after && a.AutoDisabledAt == nil {
+			continue
+		}
+		stale = append(stale, StaleRule{
+			RuleID:         a.RuleID,
+			RuleName:       rule.AlertName,
+			Disabled:       rule.Disabled,
+			EmptySince:     *a.EmptySince,
+			EmptyDays:      empty.Hours() / 24,
+			LastSeriesAt:   a.LastSeriesAt,
+			LastFired:      lastFired[a.RuleID],
+			AutoDisabledAt: a.AutoDisabledAt,
+		})
+	}
+	sort.Slice(stale, func(i, j int) bool {
+		if !stale[i].EmptySince.Equal(stale[j].EmptySince) {
+			return stale[i].EmptySince.Before(stale[j].EmptySince)
+		}
+		return stale[i].RuleID < stale[j].RuleID
+	})
+	return stale
+}
+
+// StaleRules returns the rules whose query returned no series for at
+// least after, the configured duration when zero. They need attention,
+// their alerts can no longer fire. The requests of a user only see the
+// rules of their org.
+func (m *Manager) StaleRules(ctx context.Context, after time.Duration) ([]StaleRule, error) {
+	if after <= 0 {
+		after = m.opts.StaleRules.After
+	}
+	org := tenantOf(ctx)
+	activity, err := m.ruleDB.GetSeriesActivity(ctx)
+	if err != nil {
+		return nil, err
+	}
+	storedRules, err := m.ruleDB.GetStoredRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rules := make(map[string]*PostableRule, len(storedRules))
+	for _, stored := range storedRules {
+		if !visibleTo(stored.OrgId, org) {
+			continue
+		}
+		rule, err := parseStoredRule(stored.Data)
+		if err != nil {
+			continue
+		}
+		rules[strconv.Itoa(stored.Id)] = rule
+	}
+	lastFired := map[string]int64{}
+	if m.reader != nil {
+		if lastFired, err = m.reader.GetRulesLastFired(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return findStaleRules(activity, rules, lastFired, after, time.Now()), nil
+}
+
+// disableStaleRules disables the stale rules that are still enabled
+func (m *Manager) disableStaleRules(ctx context.Context) error {
+	stale, err := m.StaleRules(ctx, 0)
+	if err != nil {
+		return err
+	}
+	for _, r := range stale {
+		if r.Disabled {
+			continue
+		}
+		if _, err := m.PatchRule(ctx, `{"disabled": true}`, r.RuleID); err != nil {
+			return fmt.Errorf("failed to disable the stale rule %s: %w", r.RuleID, err)
+		}
+		if err := m.opts.seriesActivity.disabled(ctx, r.RuleID, time.Now()); err != nil {
+			return err
+		}
+		zap.L().Info("disabled the rule returning no series", zap.String("ruleid", r.RuleID), zap.Time("emptySince", r.EmptySince))
+	}
+	return nil
+}
+
+// staleRuleChecker disables the stale rules every hour
+type staleRuleChecker struct {
+	disable func(ctx context.Context) error
+	sharder *Sharder
+
+	done       chan struct{}
+	terminated chan struct{}
+}
+
+func newStaleRuleChecker(sharder *Sharder, disable func(ctx context.Context) error) *staleRuleChecker {
+	return &staleRuleChecker{
+		disable:    disable,
+		sharder:    sharder,
+		done:       make(chan struct{}),
+		terminated: make(chan struct{}),
+	}
+}
+
+func (c *staleRuleChecker) Run(ctx context.Context) {
+	defer close(c.terminated)
+
+	tick := time.NewTicker(staleRuleCheckInterval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-tick.C:
+		}
+		if !c.sharder.Owns(staleRuleKey) {
+			continue
+		}
+		cctx, cancel := context.WithTimeout(ctx, staleRuleCheckTimeout)
+		if err := c.disable(cctx); err != nil {
+			zap.L().Error("failed to disable the stale rules", zap.Error(err))
+		}
+		cancel()
+	}
+}
+
+func (c *staleRuleChecker) Stop() {
+	close(c.done)
+	<-c.terminated
+}
@@ -0,0 +1,89 @@
+package rules
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type seriesActivityDB struct {
+	RuleDB
+	writes []SeriesActivity
+}
+
+func (db *seriesActivityDB) SetSeriesActivity(_ context.Context, a SeriesActivity) error {
+	db.writes = append(db.writes, a)
+	return nil
+}
+
+func TestSeriesActivityObserve(t *testing.T) {
+	db := &seriesActivityDB{}
+	s := newSeriesActivity(db)
+	ctx := context.Background()
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	s.observe(ctx, "1", 3, start)
+	require.Len(t, db.writes, 1)
+	assert.Nil(t, db.writes[0].EmptySince)
+
+	// the activity is only written when the query starts or stops
+	// returning series
+	s.observe(ctx, "1", 2, start.Add(time.Minute))
+	s.observe(ctx, "1", 0, start.Add(2*time.Minute))
+	s.observe(ctx, "1", 0, start.Add(3*time.Minute))
+	require.Len(t, db.writes, 2)
+	assert.Equal(t, start.Add(2*time.Minute), *db.writes[1].EmptySince)
+	assert.Equal(t, start.Add(time.Minute), *db.writes[1].LastSeriesAt)
+
+	require.NoError(t, s.disabled(ctx, "1", start.Add(time.Hour)))
+	require.Len(t, db.writes, 3)
+	assert.NotNil(t, db.writes[2].AutoDisabledAt)
+
+	// a rule enabled again starts over
+	s.observe(ctx, "1", 0, start.Add(2*time.Hour))
+	require.Len(t, db.writes, 4)
+	assert.Nil(t, db.writes[3].AutoDisabledAt)
+	assert.Equal(t, start.Add(2*time.Hour), *db.writes[3].EmptySince)
+}
+
+func TestFindStaleRules(t *testing.T) {
+	now := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	at := func(d time.Duration) *time.Time {
+		ts := now.Add(-d)
+		return &ts
+	}
+	activity := []SeriesActivity{
+		{RuleID: "1", EmptySince: at(10 * day), LastSeriesAt: at(10*day + time.Minute)},
+		{RuleID: "2", EmptySince: at(2 * day)},
+		{RuleID: "3"},
+		{RuleID: "4", EmptySince: at(day), AutoDisabledAt: at(time.Hour)},
+		{RuleID: "5", EmptySince: at(30 * day)},
+		// deleted rule
+		{RuleID: "6", EmptySince: at(30 * day)},
+	}
+	rules := map[string]*PostableRule{
+		"1": {AlertName: "latency"},
+		"2": {AlertName: "errors"},
+		"3": {AlertName: "saturation"},
+		"4": {AlertName: "queue", Disabled: true},
+		"5": {AlertName: "old metric"},
+	}
+	lastFired := map[string]int64{"1": now.Add(-20 * day).UnixMilli()}
+
+	stale := findStaleRules(activity, rules, lastFired, 7*day, now)
+	require.Len(t, stale, 3)
+	assert.Equal(t, "5", stale[0].RuleID)
+	assert.Equal(t, 30.0, stale[0].EmptyDays)
+	assert.Equal(t, "1", stale[1].RuleID)
+	assert.Equal(t, "latency", stale[1].RuleName)
+	assert.Equal(t, lastFired["1"], stale[1].LastFired)
+	assert.Equal(t, "4", stale[2].RuleID)
+	assert.True(t, stale[2].Disabled)
+
+	stale = findStaleRules(activity, rules, lastFired, day, now)
+	assert.Len(t, stale, 4)
+}
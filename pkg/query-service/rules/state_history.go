@@ -0,0 +1,217 @@
+package rules
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultHistoryBufferSize is the number of state history items held
+	// while the writes are behind, the oldest items are dropped beyond it
+	DefaultHistoryBufferSize = 10000
+	// DefaultHistoryBatchSize is the maximum number of items of an insert
+	DefaultHistoryBatchSize = 1000
+	// DefaultHistoryFlushInterval is the time the items wait for a batch
+	DefaultHistoryFlushInterval = 5 * time.Second
+
+	historyWriteRetries = 3
+	historyRetryBackoff = 500 * time.Millisecond
+	historyWriteTimeout = 30 * time.Second
+)
+
+// historyStore persists the state history, implemented by the reader
+type historyStore interface {
+	AddRuleStateHistory(ctx context.Context, ruleStateHistory []v3.RuleStateHistory) error
+}
+
+// StateHistoryWriter buffers the state history of all rules and writes it
+// in batches so that evaluations never wait on the history store. Failed
+// batches are retried with a backoff and dropped after the retries. When
+// the writes fall behind the buffer sheds the oldest items.
+type StateHistoryWriter struct {
+	store         historyStore
+	bufferSize    int
+	batchSize     int
+	flushInterval time.Duration
+	retryBackoff  time.Duration
+
+	mtx    sync.Mutex
+	buffer []v3.RuleStateHistory
+
+	flush   chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+	started atomic.Bool
+
+	dropped atomic.Uint64
+}
+
+func NewStateHistoryWriter(store historyStore, bufferSize, batchSize int, flushInterval time.Duration) *StateHistoryWriter {
+	if bufferSize <= 0 {
+		bufferSize = DefaultHistoryBufferSize
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultHistoryBatchSize
+	}
+	if flushInterval <= 0 {
+		flushInterval = DefaultHistoryFlushInterval
+	}
+	return &StateHistoryWriter{
+		store:         store,
+		bufferSize:    bufferSize,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		retryBackoff:  historyRetryBackoff,
+		flush:         make(chan struct{}, 1),
+		done:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+}
+
+// Add queues the items for the next batch, it never blocks
+func (w *StateHistoryWriter) Add(items []v3.RuleStateHistory) {
+	if len(items) == 0 {
+		return
+	}
+	w.mtx.Lock()
+	w.buffer = append(w.buffer, items...)
+	if over := len(w.buffer) - w.bufferSize; over > 0 {
+		w.buffer = append(w.buffer[:0:0], w.buffer[over:]...)
+		w.dropped.Add(uint64(over))
+		zap.L().Warn("state history writes are behind, dropping the oldest items", zap.Int("dropped", over))
+	}
+	full := len(w.buffer) >= w.batchSize
+	w.mtx.Unlock()
+
+	if full {
+		select {
+		case w.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run writes the buffered items every flush interval or as soon as a
+// batch is full, until the writer is stopped
+func (w *StateHistoryWriter) Run() {
+	w.started.Store(true)
+	defer close(w.stopped)
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			w.flushAll()
+			return
+		case <-ticker.C:
+			w.flushAll()
+		case <-w.flush:
+			w.flushAll()
+		}
+	}
+}
+
+// Stop writes the buffered items and stops the writer
+func (w *StateHistoryWriter) Stop() {
+	w.once.Do(func() {
+		close(w.done)
+	})
+	if !w.started.Load() {
+		w.flushAll()
+		return
+	}
+	<-w.stopped
+}
+
+// Pending returns the number of items waiting to be written
+func (w *StateHistoryWriter) Pending() int {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return len(w.buffer)
+}
+
+// Dropped returns the number of items that were never written
+func (w *StateHistoryWriter) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+func (w *StateHistoryWriter) flushAll() {
+	for {
+		batch := w.next()
+		if len(batch) == 0 {
+			return
+		}
+		w.write(batch)
+	}
+}
+
+// next takes the next batch out of the buffer
+func (w *StateHistoryWriter) next() []v3.RuleStateHistory {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	n := len(w.buffer)
+	if n > w.batchSize {
+		n = w.batchSize
+	}
+	batch := w.buffer[:n:n]
+	w.buffer = w.buffer[n:]
+	return batch
+}
+
+func (w *StateHistoryWriter) write(batch []v3.RuleStateHistory) {
+	var err error
+	backoff := w.retryBackoff
+	for attempt := 0; attempt < historyWriteRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-w.done:
+				// shutting down, try once more without waiting
+			}
+			backoff *= 2
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), historyWriteTimeout)
+		ctx, span := startSpan(ctx, "rule.state_history.write",
+			attribute.Int("rule.state_history.items", len(batch)),
+			attribute.Int("rule.state_history.attempt", attempt+1),
+		)
+		err = w.store.AddRuleStateHistory(ctx, batch)
+		endSpan(span, err)
+		cancel()
+		if err == nil {
+			return
+		}
+	}
+	w.dropped.Add(uint64(len(batch)))
+	zap.L().Error("error while inserting rule state history", zap.Int("items", len(batch)), zap.Error(err))
+}
+
+// writeStateHistory hands the items to the writer, the rules without a
+// writer, e.g. the rules of a test notification, write them right away
+func writeStateHistory(ctx context.Context, writer *StateHistoryWriter, store historyStore, items []v3.RuleStateHistory) {
+	if len(items) == 0 {
+		return
+	}
+	if writer != nil {
+		writer.Add(items)
+		return
+	}
+	if store == nil {
+		return
+	}
+	ctx, span := startSpan(ctx, "rule.state_history.write", attribute.Int("rule.state_history.items", len(items)))
+	err := store.AddRuleStateHistory(ctx, items)
+	endSpan(span, err)
+	if err != nil {
+		zap.L().Error("error while inserting rule state history", zap.Error(err), zap.Any("itemsToAdd", items))
+	}
+}
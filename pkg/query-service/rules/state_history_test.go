@@ -0,0 +1,150 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+type historyStoreStub struct {
+	mtx      sync.Mutex
+	batches  [][]v3.RuleStateHistory
+	failures int
+	block    chan struct{}
+}
+
+func (s *historyStoreStub) AddRuleStateHistory(ctx context.Context, items []v3.RuleStateHistory) error {
+	if s.block != nil {
+		<-s.block
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("insert failed")
+	}
+	s.batches = append(s.batches, items)
+	return nil
+}
+
+func (s *historyStoreStub) written() (batches, items int) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, b := range s.batches {
+		items += len(b)
+	}
+	return len(s.batches), items
+}
+
+func historyItems(n int) []v3.RuleStateHistory {
+	items := make([]v3.RuleStateHistory, n)
+	for i := range items {
+		items[i] = v3.RuleStateHistory{RuleID: "1", Fingerprint: uint64(i)}
+	}
+	return items
+}
+
+func TestStateHistoryWriterBatches(t *testing.T) {
+	store := &historyStoreStub{}
+	w := NewStateHistoryWriter(store, 100, 10, time.Hour)
+	go w.Run()
+
+	w.Add(historyItems(25))
+	w.Stop()
+
+	batches, items := store.written()
+	if batches != 3 || items != 25 {
+		t.Errorf("expected 25 items in 3 batches, got %d items in %d batches", items, batches)
+	}
+	if w.Pending() != 0 || w.Dropped() != 0 {
+		t.Errorf("expected nothing pending or dropped, got %d pending and %d dropped", w.Pending(), w.Dropped())
+	}
+}
+
+func TestStateHistoryWriterFlushesFullBatch(t *testing.T) {
+	store := &historyStoreStub{}
+	w := NewStateHistoryWriter(store, 100, 10, time.Hour)
+	go w.Run()
+	defer w.Stop()
+
+	w.Add(historyItems(10))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, items := store.written(); items == 10 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the full batch to be written before the flush interval")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStateHistoryWriterRetries(t *testing.T) {
+	store := &historyStoreStub{failures: 2}
+	w := NewStateHistoryWriter(store, 100, 10, time.Hour)
+	w.retryBackoff = time.Millisecond
+
+	w.Add(historyItems(5))
+	w.Stop()
+
+	if _, items := store.written(); items != 5 {
+		t.Errorf("expected the batch to be written on the last retry, got %d items", items)
+	}
+	if w.Dropped() != 0 {
+		t.Errorf("expected nothing dropped, got %d", w.Dropped())
+	}
+}
+
+func TestStateHistoryWriterDropsAfterRetries(t *testing.T) {
+	store := &historyStoreStub{failures: historyWriteRetries}
+	w := NewStateHistoryWriter(store, 100, 10, time.Hour)
+	w.retryBackoff = time.Millisecond
+
+	w.Add(historyItems(5))
+	w.Stop()
+
+	if _, items := store.written(); items != 0 {
+		t.Errorf("expected nothing written, got %d items", items)
+	}
+	if w.Dropped() != 5 {
+		t.Errorf("expected 5 items dropped, got %d", w.Dropped())
+	}
+}
+
+func TestStateHistoryWriterDoesNotBlock(t *testing.T) {
+	store := &historyStoreStub{block: make(chan struct{})}
+	w := NewStateHistoryWriter(store, 20, 10, time.Hour)
+	go w.Run()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			w.Add(historyItems(10))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Add not to wait on the store")
+	}
+
+	if w.Pending() > 20 {
+		t.Errorf("expected at most 20 pending items, got %d", w.Pending())
+	}
+	if w.Dropped() == 0 {
+		t.Error("expected the oldest items to be dropped")
+	}
+
+	close(store.block)
+	w.Stop()
+	if w.Pending() != 0 {
+		t.Errorf("expected the buffer to be written on stop, got %d pending", w.Pending())
+	}
+}
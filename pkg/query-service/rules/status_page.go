@@ -0,0 +1,217 @@
+package rules
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ComponentStatus is the status of a component of a status page, named
+// like the statuses of Statuspage
+type ComponentStatus string
+
+const (
+	ComponentOperational ComponentStatus = "operational"
+	ComponentDegraded    ComponentStatus = "degraded_performance"
+	ComponentMajorOutage ComponentStatus = "major_outage"
+)
+
+// StatusComponentConfig maps the alerts of the rules to a component of the
+// status page. The component is degraded when Degraded alerts are firing
+// and in major outage when Major alerts are firing.
+type StatusComponentConfig struct {
+	// ID is the id of the component in the status provider
+	ID string `yaml:"id"`
+	// RuleIDs are the rules of the component, all rules when empty
+	RuleIDs []string `yaml:"ruleIds"`
+	// Labels the alerts have to match
+	Labels map[string]string `yaml:"labels"`
+	// Degraded is one when not set
+	Degraded int `yaml:"degraded"`
+	// Major is never reached when not set
+	Major int `yaml:"major"`
+}
+
+func (c *StatusComponentConfig) validate() error {
+	if c.ID == "" {
+		return fmt.Errorf("a status component has no id")
+	}
+	if c.Degraded <= 0 {
+		c.Degraded = 1
+	}
+	if c.Major > 0 && c.Major < c.Degraded {
+		return fmt.Errorf("status component %s has a major threshold below the degraded threshold", c.ID)
+	}
+	return nil
+}
+
+func (c *StatusComponentConfig) matches(e AlertEvent) bool {
+	if len(c.RuleIDs) > 0 {
+		found := false
+		for _, id := range c.RuleIDs {
+			if id == e.RuleID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for k, v := range c.Labels {
+		if e.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *StatusComponentConfig) status(firing int) ComponentStatus {
+	switch {
+	case c.Major > 0 && firing >= c.Major:
+		return ComponentMajorOutage
+	case firing >= c.Degraded:
+		return ComponentDegraded
+	}
+	return ComponentOperational
+}
+
+// statusPageSink flips the components of a status page from the firing
+// alerts of their rules. The firing alerts are tracked from the events, a
+// component is updated only when its status changes.
+type statusPageSink struct {
+	name       string
+	url        string
+	headers    map[string]string
+	statuspage bool
+	components []StatusComponentConfig
+	client     *http.Client
+
+	mtx sync.Mutex
+	// firing holds the fingerprints of the firing alerts of every
+	// component
+	firing map[string]map[uint64]struct{}
+	// pushed is the last status sent for every component
+	pushed map[string]ComponentStatus
+}
+
+func newStatusPageSink(c EventSinkConfig, client *http.Client) (*statusPageSink, error) {
+	if len(c.Components) == 0 {
+		return nil, fmt.Errorf("event sink %s has no components", c.Name)
+	}
+	s := &statusPageSink{
+		name:       c.Name,
+		url:        strings.TrimSuffix(c.URL, "/"),
+		headers:    c.Headers,
+		statuspage: c.Type == "statuspage",
+		client:     client,
+		firing:     map[string]map[uint64]struct{}{},
+		pushed:     map[string]ComponentStatus{},
+	}
+	for i := range c.Components {
+		if err := c.Components[i].validate(); err != nil {
+			return nil, fmt.Errorf("event sink %s: %w", c.Name, err)
+		}
+		s.components = append(s.components, c.Components[i])
+		s.firing[c.Components[i].ID] = map[uint64]struct{}{}
+	}
+	return s, nil
+}
+
+func (s *statusPageSink) Name() string {
+	return s.name
+}
+
+// apply tracks the firing alerts of the events and returns the components
+// whose status differs from the one last sent
+func (s *statusPageSink) apply(events []AlertEvent) map[string]ComponentStatus {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for _, e := range events {
+		for _, c := range s.components {
+			if !c.matches(e) {
+				continue
+			}
+			switch e.Type {
+			case EventFiring:
+				s.firing[c.ID][e.Fingerprint] = struct{}{}
+			case EventResolved:
+				delete(s.firing[c.ID], e.Fingerprint)
+			}
+		}
+	}
+
+	changed := map[string]ComponentStatus{}
+	for _, c := range s.components {
+		status := c.status(len(s.firing[c.ID]))
+		last, ok := s.pushed[c.ID]
+		if ok && last == status {
+			continue
+		}
+		// the components start operational, nothing is sent until an
+		// alert fires
+		if !ok && status == ComponentOperational {
+			continue
+		}
+		changed[c.ID] = status
+	}
+	return changed
+}
+
+func (s *statusPageSink) Send(ctx context.Context, events []AlertEvent) error {
+	var errs []string
+	for id, status := range s.apply(events) {
+		if err := s.update(ctx, id, status); err != nil {
+			errs = append(errs, fmt.Sprintf("component %s: %v", id, err))
+			continue
+		}
+		s.mtx.Lock()
+		s.pushed[id] = status
+		s.mtx.Unlock()
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to update the status page: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// update sends the status of the component. Statuspage is sent
+// PATCH {url}/components/{id} {"component": {"status": ...}}, the generic
+// providers are sent POST {url} {"component": id, "status": ...}.
+func (s *statusPageSink) update(ctx context.Context, id string, status ComponentStatus) error {
+	method, url := http.MethodPost, s.url
+	var payload interface{} = map[string]string{"component": id, "status": string(status)}
+	if s.statuspage {
+		method, url = http.MethodPatch, s.url+"/components/"+id
+		payload = map[string]interface{}{"component": map[string]string{"status": string(status)}}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusPageSink(t *testing.T) {
+	var updates []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPatch, r.Method)
+		assert.Equal(t, "OAuth key", r.Header.Get("Authorization"))
+		var body struct {
+			Component struct {
+				Status string `json:"status"`
+			} `json:"component"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		updates = append(updates, r.URL.Path+" "+body.Component.Status)
+	}))
+	defer srv.Close()
+
+	sink, err := newEventSink(EventSinkConfig{
+		Name:    "status",
+		Type:    "statuspage",
+		URL:     srv.URL + "/v1/pages/p1",
+		Headers: map[string]string{"Authorization": "OAuth key"},
+		Components: []StatusComponentConfig{
+			{ID: "api", RuleIDs: []string{"1"}, Labels: map[string]string{"env": "prod"}, Major: 2},
+		},
+	})
+	require.NoError(t, err)
+
+	event := func(typ EventType, ruleID string, fp uint64, env string) AlertEvent {
+		return AlertEvent{Type: typ, RuleID: ruleID, Fingerprint: fp, Labels: map[string]string{"env": env}}
+	}
+	ctx := context.Background()
+
+	// alerts of other rules or labels do not change the component
+	require.NoError(t, sink.Send(ctx, []AlertEvent{event(EventFiring, "2", 1, "prod"), event(EventFiring, "1", 2, "dev")}))
+	assert.Empty(t, updates)
+
+	require.NoError(t, sink.Send(ctx, []AlertEvent{event(EventFiring, "1", 3, "prod")}))
+	require.NoError(t, sink.Send(ctx, []AlertEvent{event(EventFiring, "1", 4, "prod"), event(EventAcked, "1", 4, "prod")}))
+	require.NoError(t, sink.Send(ctx, []AlertEvent{event(EventResolved, "1", 3, "prod")}))
+	require.NoError(t, sink.Send(ctx, []AlertEvent{event(EventResolved, "1", 4, "prod")}))
+	assert.Equal(t, []string{
+		"/v1/pages/p1/components/api degraded_performance",
+		"/v1/pages/p1/components/api major_outage",
+		"/v1/pages/p1/components/api degraded_performance",
+		"/v1/pages/p1/components/api operational",
+	}, updates)
+
+	_, err = newEventSink(EventSinkConfig{Name: "status", Type: "status", URL: srv.URL})
+	assert.Error(t, err)
+	_, err = newEventSink(EventSinkConfig{Name: "status", Type: "status", URL: srv.URL, Components: []StatusComponentConfig{{ID: "api", Degraded: 3, Major: 2}}})
+	assert.Error(t, err)
+}
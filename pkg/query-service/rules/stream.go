@@ -0,0 +1,217 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.signoz.io/signoz/pkg/query-service/app/clickhouseReader"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.uber.org/zap"
+)
+
+// seriesReducer keeps the running reduction of a streamed series, its
+// points are not kept
+type seriesReducer struct {
+	labels map[string]string
+	points int
+	sum    float64
+	min    float64
+	max    float64
+	// first is the first point of the series
+	first float64
+	// matched is the first point matching the condition
+	matched    float64
+	hasMatched bool
+	// allMatch tells whether every point matches the condition
+	allMatch bool
+	latest   v3.Point
+}
+
+func newSeriesReducer(lbls map[string]string) *seriesReducer {
+	return &seriesReducer{
+		labels:   lbls,
+		min:      math.Inf(1),
+		max:      math.Inf(-1),
+		allMatch: true,
+	}
+}
+
+// matches compares v to the target of the rule
+func (r *ThresholdRule) matches(v float64) bool {
+	switch r.compareOp() {
+	case ValueIsAbove:
+		return v > r.targetVal()
+	case ValueIsBelow:
+		return v < r.targetVal()
+	case ValueIsEq:
+		return v == r.targetVal()
+	case ValueIsNotEq:
+		return v != r.targetVal()
+	}
+	return false
+}
+
+func (s *seriesReducer) add(r *ThresholdRule, p v3.Point) {
+	// the same points shouldAlert skips
+	if p.Timestamp < 0 || math.IsNaN(p.Value) || math.IsInf(p.Value, 0) {
+		return
+	}
+	if s.points == 0 {
+		s.first = p.Value
+	}
+	if s.points == 0 || p.Timestamp >= s.latest.Timestamp {
+		s.latest = p
+	}
+	s.points++
+	s.sum += p.Value
+	s.min = math.Min(s.min, p.Value)
+	s.max = math.Max(s.max, p.Value)
+	if r.matches(p.Value) {
+		if !s.hasMatched {
+			s.matched = p.Value
+			s.hasMatched = true
+		}
+	} else {
+		s.allMatch = false
+	}
+}
+
+// sample reduces the series the same way shouldAlert does
+func (s *seriesReducer) sample(r *ThresholdRule) (Sample, bool) {
+	if s.points == 0 {
+		return Sample{}, false
+	}
+	lbls, lblsNormalized := sampleLabels(s.labels)
+	smpl := Sample{Metric: lblsNormalized, MetricOrig: lbls}
+
+	switch r.matchType() {
+	case AtleastOnce:
+		smpl.V = s.matched
+		return smpl, s.hasMatched
+	case AllTheTimes:
+		switch r.compareOp() {
+		case ValueIsAbove:
+			smpl.V = s.min
+		case ValueIsBelow:
+			smpl.V = s.max
+		case ValueIsNotEq:
+			smpl.V = s.first
+		default:
+			smpl.V = r.targetVal()
+		}
+		return smpl, s.allMatch
+	case OnAverage:
+		smpl.V = s.sum / float64(s.points)
+		return smpl, r.matches(smpl.V)
+	case InTotal:
+		smpl.V = s.sum
+		return smpl, r.matches(smpl.V)
+	}
+	return smpl, false
+}
+
+// streamsResults tells whether the rule evaluates the rows of its query
+// as they are read
+func (r *ThresholdRule) streamsResults(ch clickhouse.Conn) bool {
+	// the query thresholds, the discovery of the expected members, the
+	// confirmation and the canary analysis read several queries, the
+	// streamed query is the selected one, and the anomaly detection needs
+	// the whole series
+	return ch != nil && r.ruleCondition.StreamResults && r.ruleCondition.QueryType() == v3.QueryTypeClickHouseSQL &&
+		len(r.ruleCondition.QueryThresholds) == 0 && r.ruleCondition.ExpectedMembers == nil &&
+		r.ruleCondition.Confirmation == nil && r.ruleCondition.Canary == nil &&
+		r.ruleCondition.Anomaly == nil
+}
+
+// checkSeriesLimit fails the evaluation when the selected query returns
+// more series than the limit
+func (r *ThresholdRule) checkSeriesLimit(series int) error {
+	if r.opts.MaxSeries > 0 && series > r.opts.MaxSeries {
+		return newRuleError(ErrorCardinalityLimit, fmt.Errorf("the query of the rule returned more than %d series", r.opts.MaxSeries))
+	}
+	return nil
+}
+
+// streamAndRunQuery evaluates the selected clickhouse query row by row,
+// only the reduction of every series is kept so the memory does not grow
+// with the number of points. The evaluation is aborted as soon as the
+// series limit is reached. The other queries of the rule are not run and
+// the results are not shared through the query cache.
+func (r *ThresholdRule) streamAndRunQuery(ctx context.Context, params *v3.QueryRangeParamsV3, ch clickhouse.Conn) (vector Vector, err error) {
+	selectedQuery := r.GetSelectedQuery()
+	query, ok := params.CompositeQuery.ClickHouseQueries[selectedQuery]
+	if !ok {
+		return nil, fmt.Errorf("the selected query %s is not a clickhouse query", selectedQuery)
+	}
+
+	ctx, span := startSpan(ctx, "rule.query",
+		attribute.String("rule.query.type", string(params.CompositeQuery.QueryType)),
+		attribute.Int64("rule.query.start", params.Start),
+		attribute.Int64("rule.query.end", params.End),
+		attribute.Int64("rule.query.step", params.Step),
+		attribute.Bool("rule.query.streamed", true),
+	)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := r.sandboxContext(ctx)
+	defer cancel()
+
+	var keys []string
+	reducers := map[string]*seriesReducer{}
+	err = clickhouseReader.StreamTimeSeriesResultV3(ctx, ch, query.Query, func(key string, lbls map[string]string, point *v3.Point) error {
+		reducer, ok := reducers[key]
+		if !ok {
+			if err := r.checkSeriesLimit(len(reducers) + 1); err != nil {
+				return err
+			}
+			reducer = newSeriesReducer(lbls)
+			reducers[key] = reducer
+			keys = append(keys, key)
+		}
+		if point != nil {
+			reducer.add(r, *point)
+		}
+		return nil
+	})
+	span.SetAttributes(attribute.Int("rule.query.series", len(reducers)))
+	if err != nil {
+		zap.L().Error("failed to stream the alert query result", zap.String("rule", r.Name()), zap.Int("series", len(reducers)), zap.Error(err))
+		r.SetHealth(HealthBad)
+		return nil, err
+	}
+
+	samples := []EvaluationSample{}
+	for _, key := range keys {
+		reducer := reducers[key]
+		if reducer.points == 0 || len(samples) == maxEvaluationSamples {
+			continue
+		}
+		samples = append(samples, EvaluationSample{Labels: reducer.labels, Timestamp: reducer.latest.Timestamp, Value: reducer.latest.Value})
+	}
+
+	if len(reducers) > 0 {
+		r.lastTimestampWithDatapoints = time.Now()
+	}
+	r.mtx.Lock()
+	r.samplesReturned = len(reducers)
+	r.lastEvaluation.series = len(reducers)
+	r.lastEvaluation.samples = samples
+	r.mtx.Unlock()
+
+	if absent, ok := r.absentVector(); ok {
+		return absent, nil
+	}
+
+	for _, key := range keys {
+		smpl, shouldAlert := reducers[key].sample(r)
+		if shouldAlert {
+			smpl.QueryValues = map[string]float64{selectedQuery: smpl.V}
+			vector = append(vector, smpl)
+		}
+	}
+	return vector, nil
+}
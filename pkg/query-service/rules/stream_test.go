@@ -0,0 +1,113 @@
+package rules
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	cmock "github.com/srikanthccv/ClickHouse-go-mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func TestStreamedReductionMatchesShouldAlert(t *testing.T) {
+	series := v3.Series{
+		Labels: map[string]string{"service": "api"},
+		Points: []v3.Point{
+			{Timestamp: 1000, Value: 12},
+			{Timestamp: 2000, Value: math.NaN()},
+			{Timestamp: -1, Value: 100},
+			{Timestamp: 3000, Value: 8},
+			{Timestamp: 4000, Value: 15},
+		},
+	}
+
+	for _, matchType := range []MatchType{AtleastOnce, AllTheTimes, OnAverage, InTotal} {
+		for _, op := range []CompareOp{ValueIsAbove, ValueIsBelow, ValueIsEq, ValueIsNotEq} {
+			for _, target := range []float64{5, 12, 20} {
+				postable := reloadTestRule("streamed", v3.QueryTypeClickHouseSQL)
+				postable.RuleCondition.MatchType = matchType
+				postable.RuleCondition.CompareOp = op
+				postable.RuleCondition.Target = &target
+				rule, err := NewThresholdRule("1", postable, ThresholdRuleOpts{}, nil, nil)
+				require.NoError(t, err)
+
+				expected, expectedAlert := rule.shouldAlert(series)
+				reducer := newSeriesReducer(series.Labels)
+				for _, p := range series.Points {
+					reducer.add(rule, p)
+				}
+				smpl, shouldAlert := reducer.sample(rule)
+
+				assert.Equal(t, expectedAlert, shouldAlert, "match type %s op %s target %v", matchType, op, target)
+				if expectedAlert {
+					assert.Equal(t, expected.V, smpl.V, "match type %s op %s target %v", matchType, op, target)
+					assert.Equal(t, expected.MetricOrig, smpl.MetricOrig)
+				}
+			}
+		}
+	}
+}
+
+func TestStreamsResults(t *testing.T) {
+	mock, err := cmock.NewClickHouseWithQueryMatcher(nil, &queryMatcherAny{})
+	require.NoError(t, err)
+
+	postable := reloadTestRule("streamed", v3.QueryTypeClickHouseSQL)
+	postable.RuleCondition.StreamResults = true
+	rule, err := NewThresholdRule("1", postable, ThresholdRuleOpts{}, nil, nil)
+	require.NoError(t, err)
+	assert.True(t, rule.streamsResults(mock))
+	assert.False(t, rule.streamsResults(nil))
+
+	// the canary analysis reads the queries of both cohorts
+	postable.RuleCondition.Canary = &CanaryAnalysis{
+		Canary:   map[string]string{"version": "v2"},
+		Baseline: map[string]string{"version": "v1"},
+		Metrics:  []CanaryMetric{{QueryName: "A", Direction: CanaryIncrease}},
+	}
+	rule, err = NewThresholdRule("1", postable, ThresholdRuleOpts{}, nil, nil)
+	require.NoError(t, err)
+	assert.False(t, rule.streamsResults(mock))
+}
+
+func TestStreamedEvaluation(t *testing.T) {
+	cols := []cmock.ColumnType{
+		{Name: "value", Type: "Float64"},
+		{Name: "service", Type: "String"},
+	}
+	values := [][]interface{}{
+		{float64(20), "api"},
+		{float64(5), "web"},
+		{float64(1), "api"},
+		{float64(30), "db"},
+	}
+
+	newRule := func(maxSeries int) *ThresholdRule {
+		postable := reloadTestRule("streamed", v3.QueryTypeClickHouseSQL)
+		postable.RuleCondition.StreamResults = true
+		rule, err := NewThresholdRule("1", postable, ThresholdRuleOpts{MaxSeries: maxSeries}, nil, nil)
+		require.NoError(t, err)
+		return rule
+	}
+
+	mock, err := cmock.NewClickHouseWithQueryMatcher(nil, &queryMatcherAny{})
+	require.NoError(t, err)
+	mock.ExpectQuery("SELECT 1").WillReturnRows(cmock.NewRows(cols, values))
+
+	now := time.Now()
+	rule := newRule(0)
+	count, err := rule.Eval(context.Background(), now, &Queriers{Ch: mock})
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, 3, rule.SamplesReturned())
+
+	// the evaluation is aborted at the third series
+	mock.ExpectQuery("SELECT 1").WillReturnRows(cmock.NewRows(cols, values))
+	rule = newRule(2)
+	_, err = rule.Eval(context.Background(), now, &Queriers{Ch: mock})
+	assert.ErrorContains(t, err, "more than 2 series")
+	assert.Equal(t, HealthBad, rule.Health())
+}
@@ -25,6 +25,10 @@ type Task interface {
 	Rules() []Rule
 	Stop()
 	Pause(b bool)
+	// EvalStats returns the missed and caught up evaluations of the task
+	EvalStats() EvalStats
+	// BreakerStatus returns the circuit breaker state of the rule
+	BreakerStatus(ruleID string) BreakerStatus
 }
 
 // newTask returns an appropriate group for
@@ -0,0 +1,58 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"go.signoz.io/signoz/pkg/query-service/auth"
+	"go.signoz.io/signoz/pkg/query-service/common"
+)
+
+// setRuleOwner labels the new rule with the team of the user when the
+// user is in a single team and the rule names none, so that the rule and
+// its notifications are routed to the team. It returns the rule to store.
+func (m *Manager) setRuleOwner(ctx context.Context, rule *PostableRule, ruleStr string) (string, error) {
+	label := m.opts.OwnerTeamLabel
+	user := common.GetUserFromContext(ctx)
+	if label == "" || user == nil {
+		return ruleStr, nil
+	}
+	if rule.Labels[label] == "" && len(user.Teams) == 1 {
+		if rule.Labels == nil {
+			rule.Labels = map[string]string{}
+		}
+		rule.Labels[label] = user.Teams[0]
+		data, err := json.Marshal(rule)
+		if err != nil {
+			return "", err
+		}
+		ruleStr = string(data)
+	}
+	if !auth.IsTeamMember(user, rule.Labels[label]) {
+		return "", fmt.Errorf("rules of team %s can only be created by its members", rule.Labels[label])
+	}
+	return ruleStr, nil
+}
+
+// checkRuleOwner refuses the changes of the rule by the users out of the
+// team owning it, and the moves of the rule to a team of which the user
+// is not a member. The rule is nil when it is deleted.
+func (m *Manager) checkRuleOwner(ctx context.Context, stored *StoredRule, rule *PostableRule) error {
+	label := m.opts.OwnerTeamLabel
+	user := common.GetUserFromContext(ctx)
+	if label == "" || user == nil {
+		return nil
+	}
+	storedRule := PostableRule{}
+	if err := json.Unmarshal([]byte(stored.Data), &storedRule); err != nil {
+		return err
+	}
+	if team := storedRule.Labels[label]; !auth.IsTeamMember(user, team) {
+		return fmt.Errorf("rule %d is owned by team %s and can only be changed by its members", stored.Id, team)
+	}
+	if rule != nil && !auth.IsTeamMember(user, rule.Labels[label]) {
+		return fmt.Errorf("rules can only be moved to the teams of the user")
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package rules
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/auth"
+	"go.signoz.io/signoz/pkg/query-service/constants"
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+func TestRuleTeamOwnership(t *testing.T) {
+	defer func(id string) { auth.AuthCacheObj.AdminGroupId = id }(auth.AuthCacheObj.AdminGroupId)
+	auth.AuthCacheObj.AdminGroupId = "admin"
+	m := &Manager{opts: &ManagerOptions{OwnerTeamLabel: "team"}}
+	asUser := func(teams ...string) context.Context {
+		return context.WithValue(context.Background(), constants.ContextUserKey, &model.UserPayload{User: model.User{GroupId: "editor", Teams: teams}})
+	}
+
+	// the rules of the users of a single team are owned by the team
+	rule := &PostableRule{AlertName: "high latency"}
+	ruleStr, err := m.setRuleOwner(asUser("payments"), rule, `{"alert":"high latency"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "payments", rule.Labels["team"])
+	assert.Contains(t, ruleStr, `"team":"payments"`)
+
+	// the users of several teams pick the team
+	rule = &PostableRule{AlertName: "errors"}
+	_, err = m.setRuleOwner(asUser("payments", "search"), rule, `{"alert":"errors"}`)
+	require.NoError(t, err)
+	assert.Empty(t, rule.Labels["team"])
+	rule = &PostableRule{AlertName: "errors", Labels: map[string]string{"team": "checkout"}}
+	_, err = m.setRuleOwner(asUser("payments", "search"), rule, `{}`)
+	assert.Error(t, err)
+
+	stored := &StoredRule{Id: 1, Data: `{"alert":"errors","labels":{"team":"payments"}}`}
+	assert.NoError(t, m.checkRuleOwner(asUser("payments"), stored, nil))
+	assert.Error(t, m.checkRuleOwner(asUser("search"), stored, nil))
+	// the rules can not be moved to the teams of others
+	moved := &PostableRule{Labels: map[string]string{"team": "search"}}
+	assert.Error(t, m.checkRuleOwner(asUser("payments"), stored, moved))
+
+	// the admins and the internal requests change all the rules
+	admin := context.WithValue(context.Background(), constants.ContextUserKey, &model.UserPayload{User: model.User{GroupId: "admin"}})
+	assert.NoError(t, m.checkRuleOwner(admin, stored, moved))
+	assert.NoError(t, m.checkRuleOwner(context.Background(), stored, moved))
+}
@@ -0,0 +1,49 @@
+package rules
+
+// TemplateFunctionDoc describes a function available in alert templates,
+// it is served to the UI for autocomplete
+type TemplateFunctionDoc struct {
+	Name        string `json:"name"`
+	Signature   string `json:"signature"`
+	Description string `json:"description"`
+	Example     string `json:"example,omitempty"`
+}
+
+var templateFunctionDocs = []TemplateFunctionDoc{
+	{Name: "first", Signature: "first(queryResult) sample", Description: "Returns the first sample of a query result."},
+	{Name: "label", Signature: "label(name, sample) string", Description: "Returns the value of the label on the sample."},
+	{Name: "value", Signature: "value(sample) float", Description: "Returns the value of the sample."},
+	{Name: "strvalue", Signature: "strvalue(sample) string", Description: "Returns the value of the __value__ label of the sample."},
+	{Name: "args", Signature: "args(values...) map", Description: "Collects the values into a map with the keys arg0, arg1 and so on."},
+	{Name: "reReplaceAll", Signature: "reReplaceAll(pattern, replacement, text) string", Description: "Replaces all the matches of the regular expression, panics on an invalid pattern.", Example: `{{ reReplaceAll "-.*" "" $labels.host }}`},
+	{Name: "regexReplace", Signature: "regexReplace(pattern, replacement, text) string", Description: "Replaces all the matches of the regular expression, fails the template on an invalid pattern.", Example: `{{ regexReplace "^pod-(.*)$" "$1" $labels.pod }}`},
+	{Name: "safeHtml", Signature: "safeHtml(text) html", Description: "Marks the text as safe HTML so that it is not escaped."},
+	{Name: "match", Signature: "match(pattern, text) bool", Description: "Tells whether the text matches the regular expression."},
+	{Name: "title", Signature: "title(text) string", Description: "Capitalises the first letter of every word."},
+	{Name: "toUpper", Signature: "toUpper(text) string", Description: "Converts the text to upper case.", Example: `{{ toUpper $labels.severity }}`},
+	{Name: "toLower", Signature: "toLower(text) string", Description: "Converts the text to lower case."},
+	{Name: "sortByLabel", Signature: "sortByLabel(label, queryResult) queryResult", Description: "Sorts the query result by the value of the label."},
+	{Name: "humanize", Signature: "humanize(number) string", Description: "Formats the number with metric prefixes.", Example: `{{ humanize 1234567 }} => 1.235M`},
+	{Name: "humanize1024", Signature: "humanize1024(number) string", Description: "Formats the number with binary prefixes.", Example: `{{ humanize1024 2048 }} => 2ki`},
+	{Name: "humanizeBytes", Signature: "humanizeBytes(number) string", Description: "Formats a number of bytes with binary units.", Example: `{{ humanizeBytes 1536 }} => 1.5 KiB`},
+	{Name: "humanizeDuration", Signature: "humanizeDuration(seconds) string", Description: "Formats a number of seconds as a duration.", Example: `{{ humanizeDuration 3723 }} => 1h 2m 3s`},
+	{Name: "humanizeTimestamp", Signature: "humanizeTimestamp(unixSeconds) string", Description: "Formats a unix timestamp as a UTC date."},
+	{Name: "percentage", Signature: "percentage(part, total) float", Description: "Returns part as a percentage of total, fails on a zero total.", Example: `{{ percentage 25 200 | printf "%.1f" }}% => 12.5%`},
+	{Name: "add", Signature: "add(a, b) float", Description: "Adds two numbers. Strings like \"42 ms\" are read up to the first space.", Example: `{{ add $value 10 }}`},
+	{Name: "sub", Signature: "sub(a, b) float", Description: "Subtracts b from a.", Example: `{{ sub $value $threshold }}`},
+	{Name: "mul", Signature: "mul(a, b) float", Description: "Multiplies two numbers."},
+	{Name: "div", Signature: "div(a, b) float", Description: "Divides a by b, fails on a zero divisor."},
+	{Name: "formatDate", Signature: "formatDate(time, layout, timezone) string", Description: "Formats a time, unix seconds or RFC3339 string with a Go layout in the IANA timezone.", Example: `{{ formatDate 1704067200 "2006-01-02 15:04 MST" "Asia/Kolkata" }}`},
+	{Name: "jsonGet", Signature: "jsonGet(json, path) string", Description: "Reads the value at the dotted path of a JSON encoded label, returns an empty string when the path does not exist.", Example: `{{ jsonGet $labels.metadata "owner.team" }}`},
+	{Name: "formatValue", Signature: "formatValue(number, unit) string", Description: "Formats the number of the unit like the alert values, with the format settings of the org.", Example: `{{ formatValue (index $values "A") "bytes" }} => 1.5 KiB`},
+	{Name: "pathPrefix", Signature: "pathPrefix() string", Description: "Returns the path of the external url."},
+	{Name: "externalURL", Signature: "externalURL() string", Description: "Returns the external url of SigNoz."},
+}
+
+// TemplateFunctionDocs returns the documentation of the functions
+// available in alert templates
+func TemplateFunctionDocs() []TemplateFunctionDoc {
+	docs := make([]TemplateFunctionDoc, len(templateFunctionDocs))
+	copy(docs, templateFunctionDocs)
+	return docs
+}
@@ -0,0 +1,172 @@
+package rules
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	text_template "text/template"
+
+	"go.signoz.io/signoz/pkg/query-service/formatter"
+	"go.signoz.io/signoz/pkg/query-service/utils/times"
+	"go.signoz.io/signoz/pkg/query-service/utils/timestamp"
+)
+
+const templatePreviewName = "preview"
+
+// templatePreviewDefs are the convenience variables available in rule
+// templates, they are prepended on the first line of the template
+const templatePreviewDefs = "{{$labels := .Labels}}{{$value := .Value}}{{$threshold := .Threshold}}{{$values := .Values}}"
+
+// templateErrorRe matches the position in text/template errors, columns
+// are only reported for execution errors
+var templateErrorRe = regexp.MustCompile(`^template: ` + templatePreviewName + `:(\d+)(?::(\d+))?: (.*)$`)
+
+// TemplatePreviewSample is the alert data a template is rendered with
+type TemplatePreviewSample struct {
+	Labels    map[string]string  `json:"labels"`
+	Value     string             `json:"value"`
+	Threshold string             `json:"threshold"`
+	Values    map[string]float64 `json:"values,omitempty"`
+}
+
+// TemplatePreviewRequest renders the template with the sample or, when
+// RuleId is set, with the latest alert of the rule
+type TemplatePreviewRequest struct {
+	Template string                 `json:"template"`
+	RuleId   string                 `json:"ruleId,omitempty"`
+	Sample   *TemplatePreviewSample `json:"sample,omitempty"`
+}
+
+// TemplateError is a parse or execution error of a template, line and
+// column are 1-based and zero when unknown
+type TemplateError struct {
+	Message string `json:"message"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+}
+
+type TemplatePreviewResponse struct {
+	Result string                `json:"result"`
+	Errors []TemplateError       `json:"errors"`
+	Sample TemplatePreviewSample `json:"sample"`
+}
+
+// PreviewTemplate renders the template with the sample and reports the
+// errors with their position in the template
+func PreviewTemplate(ctx context.Context, text string, sample TemplatePreviewSample, snippets map[string]string, format *formatter.Options, ts time.Time) *TemplatePreviewResponse {
+	if sample.Labels == nil {
+		sample.Labels = map[string]string{}
+	}
+	resp := &TemplatePreviewResponse{Errors: []TemplateError{}, Sample: sample}
+
+	expander := NewTemplateExpander(
+		ctx,
+		templatePreviewDefs+text,
+		templatePreviewName,
+		AlertTemplateData(sample.Labels, sample.Value, sample.Threshold, sample.Values),
+		times.Time(timestamp.FromTime(ts)),
+		nil,
+	).WithSnippets(snippets).WithFormat(format)
+
+	tmpl := text_template.New(templatePreviewName).Funcs(expander.funcMap).Option("missingkey=zero")
+	if err := expander.parseSnippets(tmpl); err != nil {
+		resp.Errors = append(resp.Errors, TemplateError{Message: err.Error()})
+		return resp
+	}
+	tmpl, err := tmpl.Parse(expander.text)
+	if err != nil {
+		resp.Errors = append(resp.Errors, templateError(err))
+		return resp
+	}
+
+	var buffer bytes.Buffer
+	if err := executeTemplate(tmpl, &buffer, expander.data); err != nil {
+		resp.Errors = append(resp.Errors, templateError(err))
+		return resp
+	}
+	resp.Result = buffer.String()
+	return resp
+}
+
+// executeTemplate recovers the panics that are not raised by template
+// functions, the same way Expand does
+func executeTemplate(tmpl *text_template.Template, buffer *bytes.Buffer, data interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic expanding template: %v", r)
+		}
+	}()
+	return tmpl.Execute(buffer, data)
+}
+
+func templateError(err error) TemplateError {
+	msg := err.Error()
+	match := templateErrorRe.FindStringSubmatch(msg)
+	if match == nil {
+		return TemplateError{Message: msg}
+	}
+	line, _ := strconv.Atoi(match[1])
+	column := 0
+	if match[2] != "" {
+		// text/template reports 0-based byte offsets
+		offset, _ := strconv.Atoi(match[2])
+		// the definitions are not part of the user template
+		if line == 1 {
+			offset -= len(templatePreviewDefs)
+		}
+		if offset >= 0 {
+			column = offset + 1
+		}
+	}
+	return TemplateError{Message: match[3], Line: line, Column: column}
+}
+
+// previewSampleFromRule returns the template data of the most recent
+// alert of the rule, or an empty sample with the threshold of the rule
+// when it has no alerts
+func previewSampleFromRule(rule Rule, format *formatter.Options) TemplatePreviewSample {
+	sample := TemplatePreviewSample{Labels: map[string]string{}}
+
+	var unit, selectedQuery string
+	var target float64
+	if r, ok := rule.(interface {
+		Unit() string
+		targetVal() float64
+		GetSelectedQuery() string
+	}); ok {
+		unit = r.Unit()
+		target = r.targetVal()
+		selectedQuery = r.GetSelectedQuery()
+	}
+	sample.Threshold = formatter.Format(target, unit, format)
+	sample.Value = formatter.Format(0, unit, format)
+
+	alerts := rule.ActiveAlerts()
+	if len(alerts) == 0 {
+		return sample
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].ActiveAt.After(alerts[j].ActiveAt)
+	})
+	latest := alerts[0]
+	sample.Value = formatter.Format(latest.Value, unit, format)
+	if latest.QueryResultLables == nil {
+		return sample
+	}
+	for name, value := range latest.QueryResultLables.Map() {
+		if strings.HasPrefix(name, "__") {
+			continue
+		}
+		sample.Labels[normalizeLabelName(name)] = value
+	}
+	if selectedQuery != "" {
+		sample.Values = map[string]float64{selectedQuery: latest.Value}
+	}
+	return sample
+}
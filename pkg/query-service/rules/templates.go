@@ -3,19 +3,23 @@ package rules
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
 	"net/url"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	html_template "html/template"
 	text_template "text/template"
 
 	"golang.org/x/text/cases"
 
+	"go.signoz.io/signoz/pkg/query-service/formatter"
 	"go.signoz.io/signoz/pkg/query-service/utils/times"
 )
 
@@ -52,6 +56,9 @@ type TemplateExpander struct {
 	name    string
 	data    interface{}
 	funcMap text_template.FuncMap
+	// snippets are the stored templates by name that the text can
+	// include with the template action
+	snippets map[string]string
 }
 
 // NewTemplateExpander returns a template expander ready to use.
@@ -192,6 +199,35 @@ func NewTemplateExpander(
 				t := times.TimeFromUnixNano(int64(v * 1e9)).Time().UTC()
 				return fmt.Sprint(t)
 			},
+			"humanizeBytes": humanizeBytes,
+			"percentage":    percentage,
+			"add": func(a, b interface{}) (float64, error) {
+				return applyMath(a, b, func(x, y float64) (float64, error) { return x + y, nil })
+			},
+			"sub": func(a, b interface{}) (float64, error) {
+				return applyMath(a, b, func(x, y float64) (float64, error) { return x - y, nil })
+			},
+			"mul": func(a, b interface{}) (float64, error) {
+				return applyMath(a, b, func(x, y float64) (float64, error) { return x * y, nil })
+			},
+			"div": func(a, b interface{}) (float64, error) {
+				return applyMath(a, b, func(x, y float64) (float64, error) {
+					if y == 0 {
+						return 0, errors.New("div() called with a zero divisor")
+					}
+					return x / y, nil
+				})
+			},
+			"regexReplace": func(pattern, repl, text string) (string, error) {
+				re, err := regexp.Compile(pattern)
+				if err != nil {
+					return "", err
+				}
+				return re.ReplaceAllString(text, repl), nil
+			},
+			"formatDate":  formatDate,
+			"jsonGet":     jsonGet,
+			"formatValue": formatValueFunc(nil),
 			"pathPrefix": func() string {
 				return externalURL.Path
 			},
@@ -202,19 +238,197 @@ func NewTemplateExpander(
 	}
 }
 
+// toFloat converts template arguments to numbers. Strings are parsed up to
+// the first space so that formatted values like "42 ms" can be used.
+func toFloat(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case float32:
+		return float64(n), nil
+	case int:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case uint64:
+		return float64(n), nil
+	case string:
+		fields := strings.Fields(n)
+		if len(fields) == 0 {
+			return 0, fmt.Errorf("can not convert empty string to a number")
+		}
+		return strconv.ParseFloat(fields[0], 64)
+	default:
+		return 0, fmt.Errorf("can not convert %T to a number", v)
+	}
+}
+
+func applyMath(a, b interface{}, op func(x, y float64) (float64, error)) (float64, error) {
+	x, err := toFloat(a)
+	if err != nil {
+		return 0, err
+	}
+	y, err := toFloat(b)
+	if err != nil {
+		return 0, err
+	}
+	return op(x, y)
+}
+
+// humanizeBytes renders the value with binary units, e.g. 1.5 KiB
+func humanizeBytes(v interface{}) (string, error) {
+	n, err := toFloat(v)
+	if err != nil {
+		return "", err
+	}
+	if math.IsNaN(n) || math.IsInf(n, 0) {
+		return fmt.Sprintf("%.4g", n), nil
+	}
+	unit := "B"
+	for _, u := range []string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB"} {
+		if math.Abs(n) < 1024 {
+			break
+		}
+		n /= 1024
+		unit = u
+	}
+	return fmt.Sprintf("%.4g %s", n, unit), nil
+}
+
+// percentage returns part as a percentage of total
+func percentage(part, total interface{}) (float64, error) {
+	return applyMath(part, total, func(x, y float64) (float64, error) {
+		if y == 0 {
+			return 0, errors.New("percentage() called with a zero total")
+		}
+		return x / y * 100, nil
+	})
+}
+
+// formatDate formats the time in the given timezone. The time can be a
+// time.Time, unix seconds or a RFC3339 string.
+func formatDate(v interface{}, layout string, timezone string) (string, error) {
+	var t time.Time
+	switch tv := v.(type) {
+	case time.Time:
+		t = tv
+	case times.Time:
+		t = tv.Time()
+	case string:
+		parsed, err := time.Parse(time.RFC3339, tv)
+		if err != nil {
+			secs, numErr := toFloat(tv)
+			if numErr != nil {
+				return "", err
+			}
+			parsed = time.Unix(0, int64(secs*1e9))
+		}
+		t = parsed
+	default:
+		secs, err := toFloat(v)
+		if err != nil {
+			return "", err
+		}
+		t = time.Unix(0, int64(secs*1e9))
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return "", err
+	}
+	return t.In(loc).Format(layout), nil
+}
+
+// jsonGet reads the value at the dotted path of a JSON encoded string,
+// e.g. a label holding a JSON object. Missing paths and invalid JSON
+// return an empty string instead of failing the template.
+func jsonGet(data string, path string) string {
+	var v interface{}
+	if err := json.Unmarshal([]byte(data), &v); err != nil {
+		return ""
+	}
+	if path != "" {
+		for _, key := range strings.Split(path, ".") {
+			switch node := v.(type) {
+			case map[string]interface{}:
+				v = node[key]
+			case []interface{}:
+				idx, err := strconv.Atoi(key)
+				if err != nil || idx < 0 || idx >= len(node) {
+					return ""
+				}
+				v = node[idx]
+			default:
+				return ""
+			}
+		}
+	}
+	switch node := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return node
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(node)
+		return string(b)
+	default:
+		return fmt.Sprint(node)
+	}
+}
+
+// formatValueFunc returns the formatValue template function formatting
+// with the options
+func formatValueFunc(opts *formatter.Options) func(v interface{}, unit string) (string, error) {
+	return func(v interface{}, unit string) (string, error) {
+		f, err := toFloat(v)
+		if err != nil {
+			return "", err
+		}
+		return formatter.Format(f, unit, opts), nil
+	}
+}
+
 // AlertTemplateData returns the interface to be used in expanding the template.
-func AlertTemplateData(labels map[string]string, value string, threshold string) interface{} {
+// values holds the raw value of every query of the rule by query name.
+func AlertTemplateData(labels map[string]string, value string, threshold string, values map[string]float64) interface{} {
+	if values == nil {
+		values = map[string]float64{}
+	}
 	return struct {
 		Labels    map[string]string
 		Value     string
 		Threshold string
+		Values    map[string]float64
 	}{
 		Labels:    labels,
 		Value:     value,
 		Threshold: threshold,
+		Values:    values,
 	}
 }
 
+// WithSnippets makes the snippets available to the template action
+func (te *TemplateExpander) WithSnippets(snippets map[string]string) *TemplateExpander {
+	te.snippets = snippets
+	return te
+}
+
+// WithFormat makes formatValue format with the options of the org
+func (te *TemplateExpander) WithFormat(opts *formatter.Options) *TemplateExpander {
+	te.funcMap["formatValue"] = formatValueFunc(opts)
+	return te
+}
+
+// parseSnippets adds the snippets as associated templates, definitions
+// in the text itself take precedence as it is parsed afterwards
+func (te TemplateExpander) parseSnippets(tmpl *text_template.Template) error {
+	for name, content := range te.snippets {
+		if _, err := tmpl.New(name).Parse(content); err != nil {
+			return fmt.Errorf("error parsing snippet %v: %v", name, err)
+		}
+	}
+	return nil
+}
+
 // Funcs adds the functions in fm to the Expander's function map.
 // Existing functions will be overwritten in case of conflict.
 func (te TemplateExpander) Funcs(fm text_template.FuncMap) {
@@ -237,7 +451,11 @@ func (te TemplateExpander) Expand() (result string, resultErr error) {
 		}
 	}()
 
-	tmpl, err := text_template.New(te.name).Funcs(te.funcMap).Option("missingkey=zero").Parse(te.text)
+	tmpl := text_template.New(te.name).Funcs(te.funcMap).Option("missingkey=zero")
+	if err := te.parseSnippets(tmpl); err != nil {
+		return "", err
+	}
+	tmpl, err := tmpl.Parse(te.text)
 	if err != nil {
 		return "", fmt.Errorf("error parsing template %v: %v", te.name, err)
 	}
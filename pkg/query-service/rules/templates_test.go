@@ -0,0 +1,202 @@
+package rules
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/utils/times"
+)
+
+func TestTemplateExpanderFunctions(t *testing.T) {
+	defs := "{{$labels := .Labels}}{{$value := .Value}}{{$threshold := .Threshold}}{{$values := .Values}}"
+	labels := map[string]string{
+		"service":  "checkout",
+		"pod":      "pod-checkout-7f9",
+		"metadata": `{"owner":{"team":"payments"},"tags":["a","b"]}`,
+	}
+
+	cases := []struct {
+		name     string
+		text     string
+		expected string
+		wantErr  bool
+	}{
+		{name: "humanizeBytes", text: "{{ humanizeBytes 1536 }}", expected: "1.5 KiB"},
+		{name: "humanizeBytes small", text: "{{ humanizeBytes 512 }}", expected: "512 B"},
+		{name: "humanizeBytes from value", text: "{{ humanizeBytes $value }}", expected: "2 MiB"},
+		{name: "humanizeDuration", text: "{{ humanizeDuration 3723 }}", expected: "1h 2m 3s"},
+		{name: "percentage", text: `{{ percentage 25 200 | printf "%.1f" }}`, expected: "12.5"},
+		{name: "percentage of zero", text: "{{ percentage 1 0 }}", wantErr: true},
+		{name: "add", text: "{{ add 1 2.5 }}", expected: "3.5"},
+		{name: "sub with formatted strings", text: `{{ sub $value $threshold | printf "%.0f" }}`, expected: "2096476"},
+		{name: "mul", text: "{{ mul 4 2 }}", expected: "8"},
+		{name: "div", text: "{{ div 9 3 }}", expected: "3"},
+		{name: "div by zero", text: "{{ div 1 0 }}", wantErr: true},
+		{name: "math on non numbers", text: `{{ add "abc" 1 }}`, wantErr: true},
+		{name: "regexReplace", text: `{{ regexReplace "^pod-(.*)-[a-z0-9]+$" "$1" $labels.pod }}`, expected: "checkout"},
+		{name: "regexReplace invalid pattern", text: `{{ regexReplace "(" "" $labels.pod }}`, wantErr: true},
+		{name: "toUpper", text: "{{ toUpper $labels.service }}", expected: "CHECKOUT"},
+		{name: "toLower", text: `{{ toLower "CheckOut" }}`, expected: "checkout"},
+		{name: "formatDate in timezone", text: `{{ formatDate 1704067200 "2006-01-02 15:04" "Asia/Kolkata" }}`, expected: "2024-01-01 05:30"},
+		{name: "formatDate rfc3339", text: `{{ formatDate "2024-01-01T00:00:00Z" "15:04 MST" "UTC" }}`, expected: "00:00 UTC"},
+		{name: "formatDate unknown timezone", text: `{{ formatDate 0 "2006" "Mars/Olympus" }}`, wantErr: true},
+		{name: "jsonGet nested", text: `{{ jsonGet $labels.metadata "owner.team" }}`, expected: "payments"},
+		{name: "jsonGet array", text: `{{ jsonGet $labels.metadata "tags.1" }}`, expected: "b"},
+		{name: "jsonGet object", text: `{{ jsonGet $labels.metadata "owner" }}`, expected: `{"team":"payments"}`},
+		{name: "jsonGet missing path", text: `{{ jsonGet $labels.metadata "owner.name" }}`, expected: ""},
+		{name: "jsonGet invalid json", text: `{{ jsonGet $labels.service "a" }}`, expected: ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			expander := NewTemplateExpander(
+				context.Background(),
+				defs+c.text,
+				"test",
+				AlertTemplateData(labels, "2097152 bytes", "676 bytes", nil),
+				times.Time(0),
+				nil,
+			)
+			result, err := expander.Expand()
+			if c.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, result)
+		})
+	}
+}
+
+func TestTemplateFunctionDocs(t *testing.T) {
+	expander := NewTemplateExpander(context.Background(), "", "test", nil, times.Time(0), nil)
+
+	documented := map[string]bool{}
+	for _, doc := range TemplateFunctionDocs() {
+		assert.NotEmpty(t, doc.Signature, doc.Name)
+		assert.NotEmpty(t, doc.Description, doc.Name)
+		documented[doc.Name] = true
+	}
+	for name := range expander.funcMap {
+		assert.True(t, documented[name], "template function %s is not documented", name)
+	}
+	for name := range documented {
+		_, ok := expander.funcMap[name]
+		assert.True(t, ok, "documented function %s does not exist", name)
+	}
+}
+
+func TestFormatDateTime(t *testing.T) {
+	out, err := formatDate(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), time.Kitchen, "America/New_York")
+	require.NoError(t, err)
+	assert.Equal(t, "7:00AM", out)
+}
+
+func TestPreviewTemplate(t *testing.T) {
+	sample := TemplatePreviewSample{
+		Labels:    map[string]string{"service": "checkout"},
+		Value:     "42",
+		Threshold: "10",
+		Values:    map[string]float64{"A": 42, "B": 1000},
+	}
+
+	cases := []struct {
+		name     string
+		text     string
+		expected string
+		errors   []TemplateError
+	}{
+		{
+			name:     "renders",
+			text:     `{{ $labels.service }} is at {{ $value }} (threshold {{ $threshold }}), total={{ index $values "B" }}`,
+			expected: "checkout is at 42 (threshold 10), total=1000",
+		},
+		{
+			name:   "parse error",
+			text:   "first line\n{{ unknownFunc }}",
+			errors: []TemplateError{{Message: `function "unknownFunc" not defined`, Line: 2}},
+		},
+		{
+			name:   "execution error on the first line",
+			text:   "ratio {{ div 1 0 }}",
+			errors: []TemplateError{{Message: `executing "preview" at <div 1 0>: error calling div: div() called with a zero divisor`, Line: 1, Column: 10}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resp := PreviewTemplate(context.Background(), c.text, sample, nil, nil, time.Now())
+			assert.Equal(t, c.expected, resp.Result)
+			if c.errors == nil {
+				assert.Empty(t, resp.Errors)
+			} else {
+				assert.Equal(t, c.errors, resp.Errors)
+			}
+		})
+	}
+}
+
+func TestTemplateExpanderSnippets(t *testing.T) {
+	snippets := NewSnippetCache()
+	snippets.Set([]TemplateSnippet{
+		{Name: "runbook_footer", Content: `Runbook: https://runbooks.example.com/{{ .Labels.service }}`},
+		{Name: "label_table", Content: `{{ range $k, $v := .Labels }}{{ $k }}={{ $v }};{{ end }}`},
+	})
+
+	expand := func(text string) (string, error) {
+		return NewTemplateExpander(
+			context.Background(),
+			text,
+			"test",
+			AlertTemplateData(map[string]string{"service": "checkout", "env": "prod"}, "1", "0", nil),
+			times.Time(0),
+			nil,
+		).WithSnippets(snippets.All()).Expand()
+	}
+
+	result, err := expand(`High latency. {{ template "runbook_footer" . }}`)
+	require.NoError(t, err)
+	assert.Equal(t, "High latency. Runbook: https://runbooks.example.com/checkout", result)
+
+	result, err = expand(`{{ template "label_table" . }}`)
+	require.NoError(t, err)
+	assert.Equal(t, "env=prod;service=checkout;", result)
+
+	// templates defined in the text take precedence
+	result, err = expand(`{{ define "runbook_footer" }}custom{{ end }}{{ template "runbook_footer" . }}`)
+	require.NoError(t, err)
+	assert.Equal(t, "custom", result)
+
+	// updates are picked up by the next expansion
+	snippets.Set([]TemplateSnippet{{Name: "runbook_footer", Content: "See the wiki"}})
+	result, err = expand(`{{ template "runbook_footer" . }}`)
+	require.NoError(t, err)
+	assert.Equal(t, "See the wiki", result)
+
+	_, err = expand(`{{ template "label_table" . }}`)
+	assert.Error(t, err)
+}
+
+func TestTemplateSnippetValidate(t *testing.T) {
+	cases := []struct {
+		snippet TemplateSnippet
+		wantErr bool
+	}{
+		{snippet: TemplateSnippet{Name: "runbook_footer", Content: "Runbook: {{ .Labels.service }}"}},
+		{snippet: TemplateSnippet{Name: "1footer", Content: "x"}, wantErr: true},
+		{snippet: TemplateSnippet{Name: "footer", Content: ""}, wantErr: true},
+		{snippet: TemplateSnippet{Name: "footer", Content: "{{ unknownFunc }}"}, wantErr: true},
+		{snippet: TemplateSnippet{Name: "footer", Content: "{{ humanizeBytes 1024 }}"}},
+	}
+	for _, c := range cases {
+		err := c.snippet.Validate()
+		if c.wantErr {
+			assert.Error(t, err, c.snippet.Name)
+		} else {
+			assert.NoError(t, err, c.snippet.Name)
+		}
+	}
+}
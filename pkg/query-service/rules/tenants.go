@@ -0,0 +1,128 @@
+package rules
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"go.signoz.io/signoz/pkg/query-service/common"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+// tenantOf returns the org of the user of the request, empty for the
+// internal requests, e.g. of the rule tasks, which see all the orgs
+func tenantOf(ctx context.Context) string {
+	if user := common.GetUserFromContext(ctx); user != nil {
+		return user.OrgId
+	}
+	return ""
+}
+
+// visibleTo tells whether the requests of the org see an object of the
+// owner. The objects stored before they were scoped by org are assigned
+// to the only org of the install on start, see ManagerOptions.LegacyOrg,
+// the ones left without an owner are only seen by the internal requests.
+func visibleTo(owner, org string) bool {
+	return org == "" || owner == org
+}
+
+// ruleTenants holds the org of the running rules, for the evaluations and
+// the maintenances which are scoped by the org of the rule
+type ruleTenants struct {
+	mtx  sync.RWMutex
+	orgs map[string]string
+}
+
+func newRuleTenants() *ruleTenants {
+	return &ruleTenants{orgs: map[string]string{}}
+}
+
+func (t *ruleTenants) set(ruleID, org string) {
+	if t == nil {
+		return
+	}
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.orgs[ruleID] = org
+}
+
+func (t *ruleTenants) forget(ruleID string) {
+	if t == nil {
+		return
+	}
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	delete(t.orgs, ruleID)
+}
+
+// of returns the org of the rule, empty when it is not known
+func (t *ruleTenants) of(ruleID string) string {
+	if t == nil {
+		return ""
+	}
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	return t.orgs[ruleID]
+}
+
+// setTenants records the orgs of the stored rules
+func (m *Manager) setTenants(storedRules []StoredRule) {
+	for _, rec := range storedRules {
+		m.opts.tenants.set(strconv.Itoa(rec.Id), rec.OrgId)
+	}
+}
+
+// tenantRules returns the ids of the rules visible to the org of the
+// request, nil when the request sees all the rules
+func (m *Manager) tenantRules(ctx context.Context) (map[string]bool, error) {
+	org := tenantOf(ctx)
+	if org == "" {
+		return nil, nil
+	}
+	storedRules, err := m.ruleDB.GetStoredRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := map[string]bool{}
+	for _, rec := range storedRules {
+		if visibleTo(rec.OrgId, org) {
+			ids[strconv.Itoa(rec.Id)] = true
+		}
+	}
+	return ids, nil
+}
+
+// visibleStateChanges keeps the state changes of the visible rules, all
+// of them when visible is nil
+func visibleStateChanges(changes []v3.RuleStateHistory, visible map[string]bool) []v3.RuleStateHistory {
+	if visible == nil {
+		return changes
+	}
+	scoped := make([]v3.RuleStateHistory, 0, len(changes))
+	for _, c := range changes {
+		if visible[c.RuleID] {
+			scoped = append(scoped, c)
+		}
+	}
+	return scoped
+}
+
+// visibleAcks keeps the acknowledgements of the visible rules, all of
+// them when visible is nil
+func visibleAcks(acks []AlertAck, visible map[string]bool) []AlertAck {
+	if visible == nil {
+		return acks
+	}
+	scoped := make([]AlertAck, 0, len(acks))
+	for _, a := range acks {
+		if visible[a.RuleID] {
+			scoped = append(scoped, a)
+		}
+	}
+	return scoped
+}
+
+// appliesTo tells whether the maintenance silences the rules of the org
+func (m *PlannedMaintenance) appliesTo(org string) bool {
+	return m.OrgId == org
+}
@@ -0,0 +1,54 @@
+package rules
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.signoz.io/signoz/pkg/query-service/constants"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func TestTenantScoping(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constants.ContextUserKey, &model.UserPayload{User: model.User{OrgId: "org-a"}})
+	assert.Equal(t, "org-a", tenantOf(ctx))
+	assert.Equal(t, "", tenantOf(context.Background()))
+
+	// the users only see the rules of their org, the rules left without
+	// an org are not shared by the orgs
+	assert.True(t, visibleTo("org-a", "org-a"))
+	assert.False(t, visibleTo("", "org-a"))
+	assert.False(t, visibleTo("org-b", "org-a"))
+	// the rule tasks see all the rules
+	assert.True(t, visibleTo("org-b", ""))
+
+	// the maintenances of an org only silence the rules of the org
+	global := &PlannedMaintenance{}
+	scoped := &PlannedMaintenance{OrgId: "org-a"}
+	assert.False(t, global.appliesTo("org-b"))
+	assert.True(t, global.appliesTo(""))
+	assert.True(t, scoped.appliesTo("org-a"))
+	assert.False(t, scoped.appliesTo("org-b"))
+	assert.False(t, scoped.appliesTo(""))
+
+	tenants := newRuleTenants()
+	tenants.set("1", "org-a")
+	assert.Equal(t, "org-a", tenants.of("1"))
+	tenants.forget("1")
+	assert.Equal(t, "", tenants.of("1"))
+	var unset *ruleTenants
+	assert.Equal(t, "", unset.of("1"))
+}
+
+func TestVisibleStateChangesAndAcks(t *testing.T) {
+	changes := []v3.RuleStateHistory{{RuleID: "1"}, {RuleID: "2"}, {RuleID: "1"}}
+	acks := []AlertAck{{RuleID: "2"}, {RuleID: "3"}}
+	visible := map[string]bool{"1": true, "3": true}
+
+	assert.Equal(t, []v3.RuleStateHistory{{RuleID: "1"}, {RuleID: "1"}}, visibleStateChanges(changes, visible))
+	assert.Equal(t, []AlertAck{{RuleID: "3"}}, visibleAcks(acks, visible))
+	// the internal requests see all the rules
+	assert.Equal(t, changes, visibleStateChanges(changes, nil))
+	assert.Equal(t, acks, visibleAcks(acks, nil))
+}
@@ -14,6 +14,7 @@ import (
 	"time"
 	"unicode"
 
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
@@ -28,12 +29,17 @@ import (
 	"go.signoz.io/signoz/pkg/query-service/constants"
 	"go.signoz.io/signoz/pkg/query-service/interfaces"
 	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils"
+	"go.signoz.io/signoz/pkg/query-service/utils/chart"
 	"go.signoz.io/signoz/pkg/query-service/utils/labels"
 	querytemplate "go.signoz.io/signoz/pkg/query-service/utils/queryTemplate"
 	"go.signoz.io/signoz/pkg/query-service/utils/times"
 	"go.signoz.io/signoz/pkg/query-service/utils/timestamp"
 
 	logsv3 "go.signoz.io/signoz/pkg/query-service/app/logs/v3"
+	metricsV3 "go.signoz.io/signoz/pkg/query-service/app/metrics/v3"
+	metricsV4 "go.signoz.io/signoz/pkg/query-service/app/metrics/v4"
+	tracesV3 "go.signoz.io/signoz/pkg/query-service/app/traces/v3"
 	"go.signoz.io/signoz/pkg/query-service/formatter"
 
 	yaml "gopkg.in/yaml.v2"
@@ -48,17 +54,39 @@ type ThresholdRule struct {
 	// i.e each time we lookback from the current time, we look at data for the last
 	// evalWindow duration
 	evalWindow time.Duration
+	// evalResolution is the step of the evaluated series, zero uses the
+	// step of the queries and at least the default resolution
+	evalResolution time.Duration
 	// holdDuration is the duration for which the alert waits before firing
 	holdDuration time.Duration
+	// alertingSeries are the series that breached at the last evaluation,
+	// they keep alerting until they are past the recovery target
+	alertingSeries map[uint64]struct{}
+	// calendars suspend the rule or relax its thresholds during their
+	// events, calendarFactor is the factor of the current evaluation
+	calendars      []CalendarRef
+	calendarFactor calendarFactor
+	// deployPolicy applies to the alerts starting after a deploy
+	deployPolicy *DeployPolicy
+	runbook      *Runbook
 	// holds the static set of labels and annotations for the rule
 	// these are the same for all alerts created for this rule
 	labels      labels.Labels
 	annotations labels.Labels
+	// variables are substituted in the queries on every evaluation
+	variables map[string]string
+	// queryQuota limits the raw clickhouse queries of the rule
+	queryQuota *QueryQuota
+	// relabelers rewrite the labels of the series before the alerts are
+	// created
+	relabelers []relabeler
 
 	// preferredChannels is the list of channels to send the alert to
 	// if the rule is triggered
 	preferredChannels []string
-	mtx               sync.Mutex
+	// backend is the query backend the rule is evaluated against
+	backend string
+	mtx     sync.Mutex
 
 	// the time it took to evaluate the rule
 	evaluationDuration time.Duration
@@ -69,8 +97,15 @@ type ThresholdRule struct {
 
 	lastError error
 
+	// samplesReturned is the number of series returned by the query
+	// on the last evaluation
+	samplesReturned int
+	// lastEvaluation is what the rule queried and saw on the last
+	// evaluation, for the evaluation log
+	lastEvaluation evaluationDetails
+
 	// map of active alerts
-	active map[uint64]*Alert
+	active AlertStore
 
 	// Ever since we introduced the new metrics query builder, the version is "v4"
 	// for all the rules
@@ -89,12 +124,20 @@ type ThresholdRule struct {
 	// for this rule
 	// this is used for missing data alerts
 	lastTimestampWithDatapoints time.Time
+	// memberLastSeen is the last time each expected member was returned
+	// by the selected query
+	memberLastSeen map[string]time.Time
+
+	// canaryVerdict is the outcome of the last canary analysis of the rule
+	canaryVerdict *CanaryVerdict
 
 	// Type of the rule
 	typ AlertType
 
 	// querier is used for alerts created before the introduction of new metrics query builder
 	querier interfaces.Querier
+	// builder prepares the sql of the builder queries to estimate their cost
+	builder *queryBuilder.QueryBuilder
 	// querierV2 is used for alerts created after the introduction of new metrics query builder
 	querierV2 interfaces.Querier
 
@@ -117,6 +160,52 @@ type ThresholdRuleOpts struct {
 	// where data might not be available in the system immediately
 	// after the timestamp.
 	EvalDelay time.Duration
+
+	// Snapshots stores the chart rendered when an alert starts firing,
+	// nil disables the snapshots
+	Snapshots *SnapshotStore
+
+	// Snippets are the stored templates the annotations can include
+	Snippets *SnippetCache
+
+	// Formats are the format options of the values by org
+	Formats *FormatCache
+
+	// Calendars are the event calendars that suspend or relax the rule
+	Calendars *CalendarCache
+
+	// Services is the service catalog the alerts inherit their team,
+	// channels and runbook from
+	Services *ServiceCatalog
+
+	// History writes the state history in batches, the history is
+	// written on every evaluation when nil
+	History *StateHistoryWriter
+
+	// MaxActiveAlerts is the number of active alerts held in memory, the
+	// least recently seen ones go to AlertSpill or are dropped beyond it
+	MaxActiveAlerts int
+	AlertSpill      AlertSpill
+
+	// MaxSeries is the number of series of the selected query after which
+	// the evaluation fails, zero is unlimited
+	MaxSeries int
+
+	// Downsampling lets the metrics queries read the aggregated samples
+	// tables when the rule does not select one
+	Downsampling bool
+
+	// Sandbox restricts the raw clickhouse queries of the rule, nil
+	// runs them without limits
+	Sandbox *ClickHouseSandbox
+
+	// TraceExemplars is the number of example traces attached to the
+	// alerts of a traces rule when they start firing, zero disables them
+	TraceExemplars int
+
+	// LogSamples is the number of log lines attached to the alerts of a
+	// logs query when they start firing, zero disables them
+	LogSamples int
 }
 
 func NewThresholdRule(
@@ -141,11 +230,19 @@ func NewThresholdRule(
 		source:            p.Source,
 		ruleCondition:     p.RuleCondition,
 		evalWindow:        time.Duration(p.EvalWindow),
-		labels:            labels.FromMap(p.Labels),
-		annotations:       labels.FromMap(p.Annotations),
+		evalResolution:    time.Duration(p.EvalResolution),
+		holdDuration:      time.Duration(p.HoldDuration),
+		calendars:         p.Calendars,
+		deployPolicy:      p.DeployPolicy,
+		runbook:           p.Runbook,
+		labels:            labels.FromMap(substituteVariablesMap(p.Labels, p.Variables)),
+		annotations:       labels.FromMap(substituteVariablesMap(p.Annotations, p.Variables)),
+		variables:         p.Variables,
+		queryQuota:        p.QueryQuota,
 		preferredChannels: p.PreferredChannels,
+		backend:           p.Backend,
 		health:            HealthUnknown,
-		active:            map[uint64]*Alert{},
+		active:            NewAlertStore(id, opts.MaxActiveAlerts, opts.AlertSpill),
 		opts:              opts,
 		typ:               p.AlertType,
 		version:           p.Version,
@@ -153,6 +250,12 @@ func NewThresholdRule(
 		evalDelay:         opts.EvalDelay,
 	}
 
+	relabelers, err := compileRelabelConfigs(p.RelabelConfigs)
+	if err != nil {
+		return nil, err
+	}
+	t.relabelers = relabelers
+
 	if int64(t.evalWindow) == 0 {
 		t.evalWindow = 5 * time.Minute
 	}
@@ -173,6 +276,16 @@ func NewThresholdRule(
 
 	t.querier = querier.NewQuerier(querierOption)
 	t.querierV2 = querierV2.NewQuerier(querierOptsV2)
+
+	buildMetricQuery := metricsV3.PrepareMetricQuery
+	if t.version == "v4" {
+		buildMetricQuery = metricsV4.PrepareMetricQuery
+	}
+	t.builder = queryBuilder.NewQueryBuilder(queryBuilder.QueryBuilderOptions{
+		BuildTraceQuery:  tracesV3.PrepareTracesQuery,
+		BuildLogQuery:    logsv3.PrepareLogsQuery,
+		BuildMetricQuery: buildMetricQuery,
+	}, featureFlags)
 	t.reader = reader
 
 	zap.L().Info("creating new ThresholdRule", zap.String("name", t.name), zap.String("id", t.id))
@@ -200,6 +313,12 @@ func (r *ThresholdRule) PreferredChannels() []string {
 	return r.preferredChannels
 }
 
+// Backend returns the name of the query backend the rule is evaluated
+// against
+func (r *ThresholdRule) Backend() string {
+	return r.backend
+}
+
 // targetVal returns the target value for the rule condition
 // when the y-axis and target units are non-empty, it
 // converts the target value to the y-axis unit
@@ -208,15 +327,25 @@ func (r *ThresholdRule) targetVal() float64 {
 		return 0
 	}
 
-	// get the converter for the target unit
-	unitConverter := converter.FromUnit(converter.Unit(r.ruleCondition.TargetUnit))
-	// convert the target value to the y-axis unit
-	value := unitConverter.Convert(converter.Value{
+	// convert the target value to the y-axis unit, the custom units are
+	// converted through their base
+	value := converter.Convert(converter.Value{
 		F: *r.ruleCondition.Target,
 		U: converter.Unit(r.ruleCondition.TargetUnit),
 	}, converter.Unit(r.Unit()))
 
-	return value.F
+	return value.F * r.calendarFactor.get()
+}
+
+// recoveryVal returns the recovery target converted to the y-axis unit
+func (r *ThresholdRule) recoveryVal() float64 {
+	if r.ruleCondition == nil || r.ruleCondition.RecoveryTarget == nil {
+		return 0
+	}
+	return converter.Convert(converter.Value{
+		F: *r.ruleCondition.RecoveryTarget,
+		U: converter.Unit(r.ruleCondition.TargetUnit),
+	}, converter.Unit(r.Unit())).F * r.calendarFactor.get()
 }
 
 func (r *ThresholdRule) matchType() MatchType {
@@ -237,10 +366,12 @@ func (r *ThresholdRule) Type() RuleType {
 	return RuleTypeThreshold
 }
 
+// SetLastError sets the error of the last evaluation, the errors without a
+// category are classified from their cause
 func (r *ThresholdRule) SetLastError(err error) {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
-	r.lastError = err
+	r.lastError = categorize(err)
 }
 
 func (r *ThresholdRule) LastError() error {
@@ -255,6 +386,20 @@ func (r *ThresholdRule) SetHealth(health RuleHealth) {
 	r.health = health
 }
 
+// SamplesReturned returns the number of series returned by the query on
+// the last evaluation
+func (r *ThresholdRule) SamplesReturned() int {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.samplesReturned
+}
+
+func (r *ThresholdRule) lastEvaluationDetails() evaluationDetails {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.lastEvaluation
+}
+
 func (r *ThresholdRule) Health() RuleHealth {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
@@ -272,6 +417,21 @@ func (r *ThresholdRule) HoldDuration() time.Duration {
 	return r.holdDuration
 }
 
+// Calendars returns the calendars the rule follows
+func (r *ThresholdRule) Calendars() []CalendarRef {
+	return r.calendars
+}
+
+// DeployPolicy returns the deploy policy of the rule, nil when not set
+func (r *ThresholdRule) DeployPolicy() *DeployPolicy {
+	return r.deployPolicy
+}
+
+// Runbook returns the runbook of the rule, nil when not set
+func (r *ThresholdRule) Runbook() *Runbook {
+	return r.runbook
+}
+
 func (r *ThresholdRule) EvalWindow() time.Duration {
 	return r.evalWindow
 }
@@ -312,11 +472,11 @@ func (r *ThresholdRule) GetEvaluationTimestamp() time.Time {
 func (r *ThresholdRule) State() AlertState {
 
 	maxState := StateInactive
-	for _, a := range r.active {
+	r.active.Range(func(_ uint64, a *Alert) {
 		if a.State > maxState {
 			maxState = a.State
 		}
-	}
+	})
 	return maxState
 }
 
@@ -324,15 +484,21 @@ func (r *ThresholdRule) currentAlerts() []*Alert {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
-	alerts := make([]*Alert, 0, len(r.active))
+	alerts := make([]*Alert, 0, r.active.Len())
 
-	for _, a := range r.active {
+	r.active.Range(func(_ uint64, a *Alert) {
 		anew := *a
 		alerts = append(alerts, &anew)
-	}
+	})
 	return alerts
 }
 
+// AlertStoreStats returns the number of alerts the rule holds in memory
+// and in the spill
+func (r *ThresholdRule) AlertStoreStats() AlertStoreStats {
+	return r.active.Stats()
+}
+
 func (r *ThresholdRule) ActiveAlerts() []*Alert {
 	var res []*Alert
 	for _, a := range r.currentAlerts() {
@@ -434,9 +600,9 @@ func (r *ThresholdRule) ForEachActiveAlert(f func(*Alert)) {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
-	for _, a := range r.active {
+	r.active.Range(func(_ uint64, a *Alert) {
 		f(a)
-	}
+	})
 }
 
 func (r *ThresholdRule) SendAlerts(ctx context.Context, ts time.Time, resendDelay time.Duration, interval time.Duration, notifyFunc NotifyFunc) {
@@ -459,11 +625,23 @@ func (r *ThresholdRule) SendAlerts(ctx context.Context, ts time.Time, resendDela
 	notifyFunc(ctx, "", alerts...)
 }
 
+// Unit returns the unit of the composite query, or the unit of the
+// selected query taken from the query units, inferred for a formula
 func (r *ThresholdRule) Unit() string {
-	if r.ruleCondition != nil && r.ruleCondition.CompositeQuery != nil {
-		return r.ruleCondition.CompositeQuery.Unit
+	return r.queryUnit(r.GetSelectedQuery())
+}
+
+func (r *ThresholdRule) queryUnit(name string) string {
+	if r.ruleCondition == nil || r.ruleCondition.CompositeQuery == nil {
+		return ""
 	}
-	return ""
+	if unit := r.ruleCondition.CompositeQuery.Unit; unit != "" || len(r.ruleCondition.QueryUnits) == 0 {
+		return unit
+	}
+	if q, ok := r.ruleCondition.CompositeQuery.BuilderQueries[name]; ok && isFormula(name, q) {
+		return FormulaUnit(q.Expression, r.ruleCondition.QueryUnits)
+	}
+	return r.ruleCondition.QueryUnits[name]
 }
 
 func (r *ThresholdRule) prepareQueryRange(ts time.Time) *v3.QueryRangeParamsV3 {
@@ -476,15 +654,21 @@ func (r *ThresholdRule) prepareQueryRange(ts time.Time) *v3.QueryRangeParamsV3 {
 		start = start - int64(r.evalDelay.Milliseconds())
 		end = end - int64(r.evalDelay.Milliseconds())
 	}
-	// round to minute otherwise we could potentially miss data
-	start = start - (start % (60 * 1000))
-	end = end - (end % (60 * 1000))
+	// round to minute, or to the resolution when it is finer, otherwise
+	// we could potentially miss data
+	roundTo := time.Minute.Milliseconds()
+	if r.evalResolution > 0 && r.evalResolution < time.Minute {
+		roundTo = r.evalResolution.Milliseconds()
+	}
+	start = start - (start % roundTo)
+	end = end - (end % roundTo)
+	step := r.step(start, end)
 
 	if r.ruleCondition.QueryType() == v3.QueryTypeClickHouseSQL {
 		params := &v3.QueryRangeParamsV3{
 			Start: start,
 			End:   end,
-			Step:  int64(math.Max(float64(common.MinAllowedStepInterval(start, end)), 60)),
+			Step:  step,
 			CompositeQuery: &v3.CompositeQuery{
 				QueryType:         r.ruleCondition.CompositeQuery.QueryType,
 				PanelType:         r.ruleCondition.CompositeQuery.PanelType,
@@ -497,6 +681,11 @@ func (r *ThresholdRule) prepareQueryRange(ts time.Time) *v3.QueryRangeParamsV3 {
 			NoCache:   true,
 		}
 		querytemplate.AssignReservedVarsV3(params)
+		// the variables of the rule are formatted like the variables of the
+		// dashboards, the strings are quoted
+		for name, value := range r.variables {
+			params.Variables[name] = utils.ClickHouseFormattedValue(value)
+		}
 		for name, chQuery := range r.ruleCondition.CompositeQuery.ClickHouseQueries {
 			if chQuery.Disabled {
 				continue
@@ -526,10 +715,17 @@ func (r *ThresholdRule) prepareQueryRange(ts time.Time) *v3.QueryRangeParamsV3 {
 
 	if r.ruleCondition.CompositeQuery != nil && r.ruleCondition.CompositeQuery.BuilderQueries != nil {
 		for _, q := range r.ruleCondition.CompositeQuery.BuilderQueries {
+			// the resolution of the rule overrides the step of the queries
+			if r.evalResolution > 0 {
+				q.StepInterval = step
+			}
 			// If the step interval is less than the minimum allowed step interval, set it to the minimum allowed step interval
 			if minStep := common.MinAllowedStepInterval(start, end); q.StepInterval < minStep {
 				q.StepInterval = minStep
 			}
+			if q.DataSource == v3.DataSourceMetrics && q.Downsampling == v3.DownsamplingUnspecified {
+				q.Downsampling = r.downsampling()
+			}
 		}
 	}
 
@@ -537,17 +733,68 @@ func (r *ThresholdRule) prepareQueryRange(ts time.Time) *v3.QueryRangeParamsV3 {
 		r.ruleCondition.CompositeQuery.PanelType = v3.PanelTypeGraph
 	}
 
+	compositeQuery := r.ruleCondition.CompositeQuery
+	if len(r.variables) > 0 {
+		cq := *compositeQuery
+		cq.BuilderQueries = withBuilderVariables(compositeQuery.BuilderQueries, r.variables)
+		compositeQuery = &cq
+	}
+
 	// default mode
 	return &v3.QueryRangeParamsV3{
 		Start:          start,
 		End:            end,
-		Step:           int64(math.Max(float64(common.MinAllowedStepInterval(start, end)), 60)),
-		CompositeQuery: r.ruleCondition.CompositeQuery,
+		Step:           step,
+		CompositeQuery: compositeQuery,
 		Variables:      make(map[string]interface{}, 0),
 		NoCache:        true,
 	}
 }
 
+// downsampling returns the samples table selection of the metrics queries
+// of the rule, the aggregated tables are picked by the window and step of
+// the rule when the manager allows them
+func (r *ThresholdRule) downsampling() v3.Downsampling {
+	if r.ruleCondition.Downsampling == v3.DownsamplingAuto {
+		return autoDataTier(r.evalWindow)
+	}
+	if r.ruleCondition.Downsampling != v3.DownsamplingUnspecified {
+		return r.ruleCondition.Downsampling
+	}
+	if r.opts.Downsampling {
+		return v3.DownsamplingAuto
+	}
+	return v3.DownsamplingRaw
+}
+
+// autoDataTier returns the data tier the automatic mode of a rule reads
+// for the eval window, the coarser tiers for the longer windows
+func autoDataTier(window time.Duration) v3.Downsampling {
+	switch {
+	case window >= 24*time.Hour:
+		return v3.Downsampling1h
+	case window >= 6*time.Hour:
+		return v3.Downsampling5m
+	}
+	return v3.DownsamplingRaw
+}
+
+// step returns the step in seconds for the range, the eval resolution of
+// the rule or one minute, and never less than the minimum allowed step
+func (r *ThresholdRule) step(start, end int64) int64 {
+	resolution := int64(defaultEvalResolution.Seconds())
+	if r.evalResolution > 0 {
+		resolution = int64(r.evalResolution.Seconds())
+	} else if r.ruleCondition.Downsampling != v3.DownsamplingUnspecified {
+		// the data tier selected by the rule sets its resolution, so that
+		// the queries read whole aggregated intervals
+		if tier := r.downsampling().Resolution(); tier > 0 {
+			resolution = int64(tier.Seconds())
+		}
+	}
+	return int64(math.Max(float64(common.MinAllowedStepInterval(start, end)), float64(resolution)))
+}
+
 // The following function is used to prepare the where clause for the query
 // `lbls` contains the key value pairs of the labels from the result of the query
 // We iterate over the where clause and replace the labels with the actual values
@@ -622,6 +869,12 @@ func (r *ThresholdRule) prepareLinksToLogs(ts time.Time, lbls labels.Labels) str
 		return ""
 	}
 
+	return r.logsExplorerParams(ts, r.fetchFilters(selectedQuery, lbls))
+}
+
+// logsExplorerParams returns the query params of the logs explorer for the
+// eval window ending at ts filtered with filterItems
+func (r *ThresholdRule) logsExplorerParams(ts time.Time, filterItems []v3.FilterItem) string {
 	q := r.prepareQueryRange(ts)
 	// Logs list view expects time in milliseconds
 	tr := v3.URLShareableTimeRange{
@@ -639,7 +892,6 @@ func (r *ThresholdRule) prepareLinksToLogs(ts time.Time, lbls labels.Labels) str
 	period, _ := json.Marshal(tr)
 	urlEncodedTimeRange := url.QueryEscape(string(period))
 
-	filterItems := r.fetchFilters(selectedQuery, lbls)
 	urlData := v3.URLShareableCompositeQuery{
 		QueryType: string(v3.QueryTypeBuilder),
 		Builder: v3.URLShareableBuilderQuery{
@@ -686,6 +938,12 @@ func (r *ThresholdRule) prepareLinksToTraces(ts time.Time, lbls labels.Labels) s
 		return ""
 	}
 
+	return r.tracesExplorerParams(ts, r.fetchFilters(selectedQuery, lbls))
+}
+
+// tracesExplorerParams returns the query params of the traces explorer for
+// the eval window ending at ts filtered with filterItems
+func (r *ThresholdRule) tracesExplorerParams(ts time.Time, filterItems []v3.FilterItem) string {
 	q := r.prepareQueryRange(ts)
 	// Traces list view expects time in nanoseconds
 	tr := v3.URLShareableTimeRange{
@@ -703,7 +961,6 @@ func (r *ThresholdRule) prepareLinksToTraces(ts time.Time, lbls labels.Labels) s
 	period, _ := json.Marshal(tr)
 	urlEncodedTimeRange := url.QueryEscape(string(period))
 
-	filterItems := r.fetchFilters(selectedQuery, lbls)
 	urlData := v3.URLShareableCompositeQuery{
 		QueryType: string(v3.QueryTypeBuilder),
 		Builder: v3.URLShareableBuilderQuery{
@@ -753,6 +1010,83 @@ func (r *ThresholdRule) hostFromSource() string {
 	return fmt.Sprintf("%s://%s", parsedUrl.Scheme, parsedUrl.Hostname())
 }
 
+// attachSnapshot renders the series of the alert around the firing time
+// and adds the url of the image to the alert annotations so that the
+// notification templates can embed it
+func (r *ThresholdRule) attachSnapshot(ctx context.Context, a *Alert, points []v3.Point, ts time.Time) {
+	if r.opts.Snapshots == nil || len(points) == 0 || r.hostFromSource() == "" || !constants.IsAlertChartSnapshotsEnabled() {
+		return
+	}
+	threshold := r.targetVal()
+	png, err := chart.RenderPNG(points, chart.Options{
+		Threshold: &threshold,
+		Marker:    ts.UnixMilli(),
+	})
+	if err != nil {
+		zap.L().Error("failed to render chart snapshot", zap.String("ruleid", r.ID()), zap.Error(err))
+		return
+	}
+	id := r.opts.Snapshots.Put(ctx, png)
+	a.SnapshotURL = r.hostFromSource() + r.opts.Snapshots.SignedPath(id)
+	a.Annotations = labels.NewBuilder(labels.FromMap(a.Annotations.Map())).Set(ChartSnapshotAnnotation, a.SnapshotURL).Labels()
+}
+
+// enrichTimeout bounds the rendering and the queries enriching the alerts
+// that started firing in an evaluation
+const enrichTimeout = 30 * time.Second
+
+// firingAlert is an alert that started firing in an evaluation, alert
+// holds the fields the enrichment reads and receives its results
+type firingAlert struct {
+	fp      uint64
+	firedAt time.Time
+	points  []v3.Point
+	alert   *Alert
+}
+
+// enrichFiring renders the chart snapshot and reads the exemplar traces
+// and the sample logs of the alerts that started firing and stores them in
+// the active alerts. It runs without r.mtx so that the readers of the rule
+// are not blocked by the rendering and the queries, and writes the alerts
+// back to the store so that the spilled alerts keep the results.
+func (r *ThresholdRule) enrichFiring(ctx context.Context, firing []firingAlert, ts time.Time) {
+	if len(firing) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, enrichTimeout)
+	defer cancel()
+
+	for _, f := range firing {
+		r.attachSnapshot(ctx, f.alert, f.points, ts)
+		r.attachExemplars(ctx, f.alert, ts)
+		r.attachLogSamples(ctx, f.alert, ts)
+		if f.alert.SnapshotURL == "" && f.alert.Exemplars == "" && f.alert.LogSamples == "" {
+			continue
+		}
+
+		r.mtx.Lock()
+		// the alert may have resolved or fired again meanwhile
+		if a, ok := r.active.Get(f.fp); ok && a.State == StateFiring && a.FiredAt.Equal(f.firedAt) {
+			lb := labels.NewBuilder(labels.FromMap(a.Annotations.Map()))
+			if f.alert.SnapshotURL != "" {
+				a.SnapshotURL = f.alert.SnapshotURL
+				lb.Set(ChartSnapshotAnnotation, a.SnapshotURL)
+			}
+			if f.alert.Exemplars != "" {
+				a.Exemplars = f.alert.Exemplars
+				lb.Set(TraceExemplarsAnnotation, a.Exemplars)
+			}
+			if f.alert.LogSamples != "" {
+				a.LogSamples = f.alert.LogSamples
+				lb.Set(LogSamplesAnnotation, a.LogSamples)
+			}
+			a.Annotations = lb.Labels()
+			r.active.Set(f.fp, a)
+		}
+		r.mtx.Unlock()
+	}
+}
+
 func (r *ThresholdRule) GetSelectedQuery() string {
 	if r.ruleCondition != nil {
 		if r.ruleCondition.SelectedQuery != "" {
@@ -773,6 +1107,11 @@ func (r *ThresholdRule) GetSelectedQuery() string {
 			}
 		}
 
+		// the confirmation only runs when the selected query breaches
+		if c := r.ruleCondition.Confirmation; c != nil {
+			delete(queryNames, c.QueryName)
+		}
+
 		// The following logic exists for backward compatibility
 		// If there is no selected query, then
 		// - check if F1 is present, if yes, return F1
@@ -794,17 +1133,190 @@ func (r *ThresholdRule) GetSelectedQuery() string {
 	return ""
 }
 
-func (r *ThresholdRule) buildAndRunQuery(ctx context.Context, ts time.Time, ch clickhouse.Conn) (Vector, error) {
+func (r *ThresholdRule) buildAndRunQuery(ctx context.Context, ts time.Time, ch clickhouse.Conn, cache *QueryCache) (Vector, error) {
 	if r.ruleCondition == nil || r.ruleCondition.CompositeQuery == nil {
 		r.SetHealth(HealthBad)
-		r.SetLastError(fmt.Errorf("no rule condition"))
+		r.SetLastError(newRuleError(ErrorQueryParse, fmt.Errorf("no rule condition")))
+		return nil, newRuleError(ErrorQueryParse, fmt.Errorf("invalid rule condition"))
+	}
+
+	params, confirmParams := r.splitConfirmation(r.prepareQueryRange(ts))
+	r.mtx.Lock()
+	r.lastEvaluation = evaluationDetails{query: renderedQuery(params.CompositeQuery)}
+	r.mtx.Unlock()
+
+	if r.streamsResults(ch) {
+		return r.streamAndRunQuery(ctx, params, ch)
+	}
+
+	results, err := r.queryResults(ctx, params, ch, cache)
+	if err != nil {
+		r.SetHealth(HealthBad)
+		return nil, err
+	}
+
+	if len(r.ruleCondition.QueryThresholds) > 0 {
+		return r.queryThresholdsVector(results)
+	}
+	if r.ruleCondition.Canary != nil {
+		return r.canaryVector(results, ts), nil
+	}
+
+	selectedQuery := r.GetSelectedQuery()
+
+	var queryResult *v3.Result
+	for _, res := range results {
+		if res.QueryName == selectedQuery {
+			queryResult = res
+			break
+		}
+	}
+
+	if queryResult != nil && len(queryResult.Series) > 0 {
+		r.lastTimestampWithDatapoints = time.Now()
+	}
+
+	samples := 0
+	if queryResult != nil {
+		samples = len(queryResult.Series)
+	}
+	r.mtx.Lock()
+	r.samplesReturned = samples
+	r.lastEvaluation.series = samples
+	if queryResult != nil {
+		r.lastEvaluation.samples = seriesSamples(queryResult.Series)
+	}
+	r.mtx.Unlock()
+
+	if err := r.checkSeriesLimit(samples); err != nil {
+		r.SetHealth(HealthBad)
+		return nil, err
+	}
+
+	if absent, ok := r.absentVector(); ok {
+		return absent, nil
+	}
+
+	var resultVector Vector
+	queryValues := r.reduceQueryResults(results)
+	switch {
+	case r.ruleCondition.Anomaly != nil:
+		// anomaly rules have no target, see validateAnomaly
+		resultVector = r.anomalyVector(queryResult, ts)
+	case r.ruleCondition.Target != nil && queryResult != nil:
+		alerting := map[uint64]struct{}{}
+		for _, series := range queryResult.Series {
+			smpl, shouldAlert := r.shouldAlert(*series)
+			if !shouldAlert {
+				smpl, shouldAlert = r.keepAlerting(*series)
+			}
+			if shouldAlert {
+				alerting[labels.FromMap(series.Labels).Hash()] = struct{}{}
+				smpl.SeriesPoints = series.Points
+				smpl.QueryValues = queryValues.valuesFor(series.Labels)
+				smpl.QueryValues[selectedQuery] = smpl.V
+				resultVector = append(resultVector, smpl)
+			}
+		}
+		r.alertingSeries = alerting
+	}
+	resultVector, err = r.confirm(ctx, resultVector, confirmParams, ch, cache)
+	if err != nil {
+		return nil, err
+	}
+	resultVector = append(resultVector, r.missingMembersVector(results, queryResult)...)
+	return resultVector, nil
+}
+
+// absentVector returns the no data alert when the data is missing for
+// the absent duration of the rule
+func (r *ThresholdRule) absentVector() (Vector, bool) {
+	if !r.ruleCondition.AlertOnAbsent || !r.lastTimestampWithDatapoints.Add(time.Duration(r.Condition().AbsentFor)*time.Minute).Before(time.Now()) {
+		return nil, false
+	}
+	zap.L().Info("no data found for rule condition", zap.String("ruleid", r.ID()))
+	lbls := labels.NewBuilder(labels.Labels{})
+	if !r.lastTimestampWithDatapoints.IsZero() {
+		lbls.Set("lastSeen", r.lastTimestampWithDatapoints.Format(constants.AlertTimeFormat))
+	}
+	return Vector{{
+		Metric:    lbls.Labels(),
+		IsMissing: true,
+	}}, true
+}
+
+// Explain runs the queries of the rule for ts and tells for every series
+// of the selected query whether it matches the condition
+func (r *ThresholdRule) Explain(ctx context.Context, ts time.Time, queriers *Queriers) (*Explanation, error) {
+	if r.ruleCondition == nil || r.ruleCondition.CompositeQuery == nil {
+		return nil, fmt.Errorf("invalid rule condition")
+	}
+
+	params := r.prepareQueryRange(ts)
+	explanation := newExplanation(r, r.GetSelectedQuery(), r.Unit(), r.targetVal(), ts, params.Start, params.End, renderedQuery(params.CompositeQuery))
+
+	results, err := r.queryResults(ctx, params, queriers.Ch, queriers.Cache)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, res := range results {
+		if res.QueryName != explanation.SelectedQuery {
+			continue
+		}
+		for _, series := range res.Series {
+			points := removeGroupinSetPoints(*series)
+			values := make([]float64, 0, len(points))
+			for _, p := range points {
+				values = append(values, p.Value)
+			}
+			_, shouldAlert := r.shouldAlert(*series)
+			explanation.addSeries(series.Labels, values, shouldAlert)
+		}
+	}
+	explanation.sortSeries()
+	return explanation, nil
+}
+
+// EstimateCost estimates the rows read by the queries of the rule for an
+// evaluation at ts
+func (r *ThresholdRule) EstimateCost(ctx context.Context, ts time.Time, queriers *Queriers) (*CostEstimate, error) {
+	if r.ruleCondition == nil || r.ruleCondition.CompositeQuery == nil {
 		return nil, fmt.Errorf("invalid rule condition")
 	}
 
 	params := r.prepareQueryRange(ts)
+	queries := map[string]string{}
+	switch params.CompositeQuery.QueryType {
+	case v3.QueryTypeClickHouseSQL:
+		for name, q := range params.CompositeQuery.ClickHouseQueries {
+			if !q.Disabled {
+				queries[name] = q.Query
+			}
+		}
+	case v3.QueryTypeBuilder:
+		if err := r.populateTemporality(ctx, params, queriers.Ch); err != nil {
+			return nil, fmt.Errorf("internal error while setting temporality")
+		}
+		if logsv3.EnrichmentRequired(params) {
+			logsv3.Enrich(params, map[string]v3.AttributeKey{})
+		}
+		built, err := r.builder.PrepareQueries(params)
+		if err != nil {
+			return nil, err
+		}
+		queries = built
+	default:
+		return nil, fmt.Errorf("the cost of %s queries cannot be estimated", params.CompositeQuery.QueryType)
+	}
+	return estimateQueries(ctx, queriers.Ch, queries)
+}
+
+// queryResults runs the queries of the rule for the range of params and
+// post processes the results
+func (r *ThresholdRule) queryResults(ctx context.Context, params *v3.QueryRangeParamsV3, ch clickhouse.Conn, cache *QueryCache) ([]*v3.Result, error) {
 	err := r.populateTemporality(ctx, params, ch)
 	if err != nil {
-		r.SetHealth(HealthBad)
 		zap.L().Error("failed to set temporality", zap.String("rule", r.Name()), zap.Error(err))
 		return nil, fmt.Errorf("internal error while setting temporality")
 	}
@@ -818,67 +1330,126 @@ func (r *ThresholdRule) buildAndRunQuery(ctx context.Context, ts time.Time, ch c
 		}
 	}
 
-	var results []*v3.Result
-	var errQuriesByName map[string]error
-
-	if r.version == "v4" {
-		results, errQuriesByName, err = r.querierV2.QueryRange(ctx, params, map[string]v3.AttributeKey{})
-	} else {
-		results, errQuriesByName, err = r.querier.QueryRange(ctx, params, map[string]v3.AttributeKey{})
-	}
-
+	results, errQuriesByName, err := r.runQuery(ctx, params, cache)
 	if err != nil {
 		zap.L().Error("failed to get alert query result", zap.String("rule", r.Name()), zap.Error(err), zap.Any("queries", errQuriesByName))
-		r.SetHealth(HealthBad)
-		return nil, fmt.Errorf("internal error while querying")
+		// the message of the datasource is not exposed, its category is
+		return nil, newRuleError(queryErrorCategory(err, errQuriesByName), fmt.Errorf("internal error while querying"))
 	}
 
 	if params.CompositeQuery.QueryType == v3.QueryTypeBuilder {
 		results, err = postprocess.PostProcessResult(results, params)
 		if err != nil {
-			r.SetHealth(HealthBad)
 			zap.L().Error("failed to post process result", zap.String("rule", r.Name()), zap.Error(err))
 			return nil, fmt.Errorf("internal error while post processing")
 		}
 	}
+	return results, nil
+}
 
-	selectedQuery := r.GetSelectedQuery()
+// thresholdQueryResult is the result of the queries of a threshold rule
+type thresholdQueryResult struct {
+	results []*v3.Result
+	errs    map[string]error
+}
 
-	var queryResult *v3.Result
-	for _, res := range results {
-		if res.QueryName == selectedQuery {
-			queryResult = res
-			break
+// runQuery runs the queries of the rule, or reuses the result of the same
+// queries run by another rule for the same window and step
+func (r *ThresholdRule) runQuery(ctx context.Context, params *v3.QueryRangeParamsV3, cache *QueryCache) (results []*v3.Result, errs map[string]error, err error) {
+	ctx, span := startSpan(ctx, "rule.query",
+		attribute.String("rule.query.type", string(params.CompositeQuery.QueryType)),
+		attribute.Int64("rule.query.start", params.Start),
+		attribute.Int64("rule.query.end", params.End),
+		attribute.Int64("rule.query.step", params.Step),
+	)
+	defer func() { endSpan(span, err) }()
+
+	fetched := false
+	query := func() ([]*v3.Result, map[string]error, error) {
+		fetched = true
+		ctx, cancel := r.sandboxContext(ctx)
+		defer cancel()
+		if r.version == "v4" {
+			return r.querierV2.QueryRange(ctx, params, map[string]v3.AttributeKey{})
 		}
+		return r.querier.QueryRange(ctx, params, map[string]v3.AttributeKey{})
 	}
 
-	if queryResult != nil && len(queryResult.Series) > 0 {
-		r.lastTimestampWithDatapoints = time.Now()
+	key, err := queryCacheKey("threshold"+r.version, params.CompositeQuery, params.Start, params.End, params.Step)
+	if err != nil {
+		return query()
+	}
+	res, err := cache.Get(ctx, key, func() (interface{}, error) {
+		results, errs, err := query()
+		return thresholdQueryResult{results: results, errs: errs}, err
+	})
+	span.SetAttributes(attribute.Bool("rule.query.cached", !fetched))
+	result, _ := res.(thresholdQueryResult)
+	if err != nil {
+		return nil, result.errs, err
 	}
+	// post processing modifies the series
+	return cloneResults(result.results), result.errs, nil
+}
 
-	var resultVector Vector
+// queryValues holds the reduced value of every series by query name
+// and label set hash
+type queryValues map[string]map[uint64]float64
 
-	// if the data is missing for `For` duration then we should send alert
-	if r.ruleCondition.AlertOnAbsent && r.lastTimestampWithDatapoints.Add(time.Duration(r.Condition().AbsentFor)*time.Minute).Before(time.Now()) {
-		zap.L().Info("no data found for rule condition", zap.String("ruleid", r.ID()))
-		lbls := labels.NewBuilder(labels.Labels{})
-		if !r.lastTimestampWithDatapoints.IsZero() {
-			lbls.Set("lastSeen", r.lastTimestampWithDatapoints.Format(constants.AlertTimeFormat))
+// reduceQueryResults reduces the series of all the queries to a single
+// value each, the same way the match type reduces the selected query.
+// Conditions that pick a matching sample use the latest value instead.
+func (r *ThresholdRule) reduceQueryResults(results []*v3.Result) queryValues {
+	values := queryValues{}
+	for _, res := range results {
+		byLabels := map[uint64]float64{}
+		for _, series := range res.Series {
+			points := removeGroupinSetPoints(*series)
+			if len(points) == 0 {
+				continue
+			}
+			var v float64
+			switch r.matchType() {
+			case OnAverage, InTotal:
+				for _, p := range points {
+					v += p.Value
+				}
+				if r.matchType() == OnAverage {
+					v /= float64(len(points))
+				}
+			default:
+				latest := points[0]
+				for _, p := range points[1:] {
+					if p.Timestamp > latest.Timestamp {
+						latest = p
+					}
+				}
+				v = latest.Value
+			}
+			byLabels[labels.FromMap(series.Labels).Hash()] = v
 		}
-		resultVector = append(resultVector, Sample{
-			Metric:    lbls.Labels(),
-			IsMissing: true,
-		})
-		return resultVector, nil
+		values[res.QueryName] = byLabels
 	}
+	return values
+}
 
-	for _, series := range queryResult.Series {
-		smpl, shouldAlert := r.shouldAlert(*series)
-		if shouldAlert {
-			resultVector = append(resultVector, smpl)
+// valuesFor returns the value of every query for the series. Queries
+// with a single series, e.g. a total without group by, match all series.
+func (qv queryValues) valuesFor(lbls map[string]string) map[string]float64 {
+	hash := labels.FromMap(lbls).Hash()
+	values := make(map[string]float64, len(qv))
+	for name, byLabels := range qv {
+		if v, ok := byLabels[hash]; ok {
+			values[name] = v
+			continue
+		}
+		if len(byLabels) == 1 {
+			for _, v := range byLabels {
+				values[name] = v
+			}
 		}
 	}
-	return resultVector, nil
+	return values
 }
 
 func normalizeLabelName(name string) string {
@@ -901,9 +1472,9 @@ func normalizeLabelName(name string) string {
 func (r *ThresholdRule) Eval(ctx context.Context, ts time.Time, queriers *Queriers) (interface{}, error) {
 
 	prevState := r.State()
+	r.calendarFactor.set(r.opts.Calendars.factor(r.ID(), r.calendars, ts))
 
-	valueFormatter := formatter.FromUnit(r.Unit())
-	res, err := r.buildAndRunQuery(ctx, ts, queriers.Ch)
+	res, err := r.buildAndRunQuery(ctx, ts, queriers.Ch, queriers.Cache)
 
 	if err != nil {
 		r.SetHealth(HealthBad)
@@ -911,12 +1482,22 @@ func (r *ThresholdRule) Eval(ctx context.Context, ts time.Time, queriers *Querie
 		zap.L().Error("failure in buildAndRunQuery", zap.String("ruleid", r.ID()), zap.Error(err))
 		return nil, err
 	}
+	res = r.relabelVector(res)
+
+	// the alerts that started firing are enriched once r.mtx is released,
+	// the deferred calls run in reverse order
+	var firing []firingAlert
+	defer func() { r.enrichFiring(ctx, firing, ts) }()
 
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
 	resultFPs := map[uint64]struct{}{}
 	var alerts = make(map[uint64]*Alert, len(res))
+	snippets := r.opts.Snippets.All()
+	format := r.opts.Formats.forRule(r.ID())
+	seriesPoints := make(map[uint64][]v3.Point, len(res))
+	var templateErr error
 
 	for _, smpl := range res {
 		l := make(map[string]string, len(smpl.Metric))
@@ -924,17 +1505,22 @@ func (r *ThresholdRule) Eval(ctx context.Context, ts time.Time, queriers *Querie
 			l[lbl.Name] = lbl.Value
 		}
 
-		value := valueFormatter.Format(smpl.V, r.Unit())
-		threshold := valueFormatter.Format(r.targetVal(), r.Unit())
-		zap.L().Debug("Alert template data for rule", zap.String("name", r.Name()), zap.String("formatter", valueFormatter.Name()), zap.String("value", value), zap.String("threshold", threshold))
+		unit, target := r.Unit(), r.targetVal()
+		if smpl.Threshold != nil {
+			unit, target = smpl.Unit, *smpl.Threshold
+		}
+		value := formatter.Format(smpl.V, unit, format)
+		threshold := formatter.Format(target, unit, format)
+		zap.L().Debug("Alert template data for rule", zap.String("name", r.Name()), zap.String("formatter", formatter.FromUnit(unit).Name()), zap.String("value", value), zap.String("threshold", threshold))
 
-		tmplData := AlertTemplateData(l, value, threshold)
+		tmplData := AlertTemplateData(l, value, threshold, smpl.QueryValues)
 		// Inject some convenience variables that are easier to remember for users
 		// who are not used to Go's templating system.
-		defs := "{{$labels := .Labels}}{{$value := .Value}}{{$threshold := .Threshold}}"
+		defs := "{{$labels := .Labels}}{{$value := .Value}}{{$threshold := .Threshold}}{{$values := .Values}}"
 
 		// utility function to apply go template on labels and annotations
 		expand := func(text string) string {
+			_, span := startSpan(ctx, "rule.template.expand", attribute.Int("rule.template.length", len(text)))
 
 			tmpl := NewTemplateExpander(
 				ctx,
@@ -943,10 +1529,12 @@ func (r *ThresholdRule) Eval(ctx context.Context, ts time.Time, queriers *Querie
 				tmplData,
 				times.Time(timestamp.FromTime(ts)),
 				nil,
-			)
+			).WithSnippets(snippets).WithFormat(format)
 			result, err := tmpl.Expand()
+			endSpan(span, err)
 			if err != nil {
 				result = fmt.Sprintf("<error expanding template: %s>", err)
+				templateErr = newRuleError(ErrorTemplate, fmt.Errorf("expanding template: %w", err))
 				zap.L().Error("Expanding alert template failed", zap.Error(err), zap.Any("data", tmplData))
 			}
 			return result
@@ -971,24 +1559,57 @@ func (r *ThresholdRule) Eval(ctx context.Context, ts time.Time, queriers *Querie
 			lb.Set(labels.AlertNameLabel, "[No data] "+r.Name())
 		}
 
+		// the runbook of the rule takes precedence over the one of the
+		// service
+		if link := r.runbook.link(r.ID(), r.GeneratorURL()); link != "" && annotations.Get(RunbookAnnotation) == "" {
+			annotations = append(annotations, labels.Label{Name: RunbookAnnotation, Value: link})
+		}
+		// the alerts of a cataloged service inherit its ownership
+		owner := r.opts.Services.ownership(r.ID(), lb.Labels(), annotations, r.preferredChannels)
+		if owner.team != "" {
+			lb.Set(owner.teamLabel, owner.team)
+		}
+		if owner.runbook != "" {
+			annotations = append(annotations, labels.Label{Name: RunbookAnnotation, Value: owner.runbook})
+		}
+
 		// Links with timestamps should go in annotations since labels
 		// is used alert grouping, and we want to group alerts with the same
 		// label set, but different timestamps, together.
 		if r.typ == AlertTypeTraces {
 			link := r.prepareLinksToTraces(ts, smpl.MetricOrig)
 			if link != "" && r.hostFromSource() != "" {
-				annotations = append(annotations, labels.Label{Name: "related_traces", Value: fmt.Sprintf("%s/traces-explorer?%s", r.hostFromSource(), link)})
+				annotations = append(annotations, labels.Label{Name: RelatedTracesAnnotation, Value: fmt.Sprintf("%s/traces-explorer?%s", r.hostFromSource(), link)})
 			}
 		} else if r.typ == AlertTypeLogs {
 			link := r.prepareLinksToLogs(ts, smpl.MetricOrig)
 			if link != "" && r.hostFromSource() != "" {
-				annotations = append(annotations, labels.Label{Name: "related_logs", Value: fmt.Sprintf("%s/logs/logs-explorer?%s", r.hostFromSource(), link)})
+				annotations = append(annotations, labels.Label{Name: RelatedLogsAnnotation, Value: fmt.Sprintf("%s/logs/logs-explorer?%s", r.hostFromSource(), link)})
 			}
 		}
 
 		lbs := lb.Labels()
 		h := lbs.Hash()
+
+		activeAt := ts
+		if active, ok := r.active.Get(h); ok && active.State != StateInactive {
+			activeAt = active.ActiveAt
+		}
+		annotations = r.addDeepLinks(annotations, ts, activeAt, smpl.MetricOrig)
 		resultFPs[h] = struct{}{}
+		seriesPoints[h] = smpl.SeriesPoints
+
+		// keep the snapshot taken when the alert started firing
+		if active, ok := r.active.Get(h); ok && active.SnapshotURL != "" {
+			annotations = append(annotations, labels.Label{Name: ChartSnapshotAnnotation, Value: active.SnapshotURL})
+		}
+		// and the exemplar traces and sample logs of the breach
+		if active, ok := r.active.Get(h); ok && active.Exemplars != "" {
+			annotations = append(annotations, labels.Label{Name: TraceExemplarsAnnotation, Value: active.Exemplars})
+		}
+		if active, ok := r.active.Get(h); ok && active.LogSamples != "" {
+			annotations = append(annotations, labels.Label{Name: LogSamplesAnnotation, Value: active.LogSamples})
+		}
 
 		if _, ok := alerts[h]; ok {
 			zap.L().Error("the alert query returns duplicate records", zap.String("ruleid", r.ID()), zap.Any("alert", alerts[h]))
@@ -996,7 +1617,7 @@ func (r *ThresholdRule) Eval(ctx context.Context, ts time.Time, queriers *Querie
 			// We have already acquired the lock above hence using SetHealth and
 			// SetLastError will deadlock.
 			r.health = HealthBad
-			r.lastError = err
+			r.lastError = categorize(err)
 			return nil, err
 		}
 
@@ -1008,7 +1629,7 @@ func (r *ThresholdRule) Eval(ctx context.Context, ts time.Time, queriers *Querie
 			State:             StatePending,
 			Value:             smpl.V,
 			GeneratorURL:      r.GeneratorURL(),
-			Receivers:         r.preferredChannels,
+			Receivers:         owner.receivers,
 			Missing:           smpl.IsMissing,
 		}
 	}
@@ -1019,22 +1640,26 @@ func (r *ThresholdRule) Eval(ctx context.Context, ts time.Time, queriers *Querie
 	for h, a := range alerts {
 		// Check whether we already have alerting state for the identifying label set.
 		// Update the last value and annotations if so, create a new alert entry otherwise.
-		if alert, ok := r.active[h]; ok && alert.State != StateInactive {
+		if alert, ok := r.active.Get(h); ok && alert.State != StateInactive {
 
 			alert.Value = a.Value
+			alert.recordValue(ts, a.Value)
+			alert.Breaches++
 			alert.Annotations = a.Annotations
-			alert.Receivers = r.preferredChannels
+			alert.Receivers = a.Receivers
 			continue
 		}
 
-		r.active[h] = a
+		a.recordValue(ts, a.Value)
+		a.Breaches = 1
+		r.active.Set(h, a)
 
 	}
 
 	itemsToAdd := []v3.RuleStateHistory{}
 
 	// Check if any pending alerts should be removed or fire now. Write out alert timeseries.
-	for fp, a := range r.active {
+	r.active.Range(func(fp uint64, a *Alert) {
 		labelsJSON, err := json.Marshal(a.QueryResultLables)
 		if err != nil {
 			zap.L().Error("error marshaling labels", zap.Error(err), zap.Any("labels", a.Labels))
@@ -1043,7 +1668,7 @@ func (r *ThresholdRule) Eval(ctx context.Context, ts time.Time, queriers *Querie
 			// If the alert was previously firing, keep it around for a given
 			// retention time so it is reported as resolved to the AlertManager.
 			if a.State == StatePending || (!a.ResolvedAt.IsZero() && ts.Sub(a.ResolvedAt) > resolvedRetention) {
-				delete(r.active, fp)
+				r.active.Delete(fp)
 			}
 			if a.State != StateInactive {
 				a.State = StateInactive
@@ -1058,12 +1683,18 @@ func (r *ThresholdRule) Eval(ctx context.Context, ts time.Time, queriers *Querie
 					Fingerprint:  a.QueryResultLables.Hash(),
 				})
 			}
-			continue
+			return
 		}
 
 		if a.State == StatePending && ts.Sub(a.ActiveAt) >= r.holdDuration {
 			a.State = StateFiring
 			a.FiredAt = ts
+			firing = append(firing, firingAlert{
+				fp:      fp,
+				firedAt: a.FiredAt,
+				points:  seriesPoints[fp],
+				alert:   &Alert{QueryResultLables: a.QueryResultLables, Missing: a.Missing, Annotations: labels.Labels{}},
+			})
 			state := "firing"
 			if a.Missing {
 				state = "no_data"
@@ -1079,7 +1710,7 @@ func (r *ThresholdRule) Eval(ctx context.Context, ts time.Time, queriers *Querie
 				Value:        a.Value,
 			})
 		}
-	}
+	})
 
 	currentState := r.State()
 
@@ -1099,16 +1730,18 @@ func (r *ThresholdRule) Eval(ctx context.Context, ts time.Time, queriers *Querie
 		}
 	}
 
-	if len(itemsToAdd) > 0 && r.reader != nil {
-		err := r.reader.AddRuleStateHistory(ctx, itemsToAdd)
-		if err != nil {
-			zap.L().Error("error while inserting rule state history", zap.Error(err), zap.Any("itemsToAdd", itemsToAdd))
-		}
+	if r.reader != nil {
+		writeStateHistory(ctx, r.opts.History, r.reader, itemsToAdd)
 	}
 	r.health = HealthGood
-	r.lastError = err
+	// a template failing to expand does not fail the evaluation, it is
+	// recorded for the rule to be fixed
+	r.lastError = categorize(err)
+	if templateErr != nil {
+		r.lastError = templateErr
+	}
 
-	return len(r.active), nil
+	return r.active.Len(), nil
 }
 
 func (r *ThresholdRule) String() string {
@@ -1117,6 +1750,7 @@ func (r *ThresholdRule) String() string {
 		AlertName:         r.name,
 		RuleCondition:     r.ruleCondition,
 		EvalWindow:        Duration(r.evalWindow),
+		HoldDuration:      Duration(r.holdDuration),
 		Labels:            r.labels.Map(),
 		Annotations:       r.annotations.Map(),
 		PreferredChannels: r.preferredChannels,
@@ -1140,16 +1774,46 @@ func removeGroupinSetPoints(series v3.Series) []v3.Point {
 	return result
 }
 
-func (r *ThresholdRule) shouldAlert(series v3.Series) (Sample, bool) {
-	var alertSmpl Sample
-	var shouldAlert bool
+// sampleLabels returns the labels of a series and the labels with their
+// names normalized
+func sampleLabels(m map[string]string) (labels.Labels, labels.Labels) {
 	var lbls labels.Labels
 	var lblsNormalized labels.Labels
-
-	for name, value := range series.Labels {
+	for name, value := range m {
 		lbls = append(lbls, labels.Label{Name: name, Value: value})
 		lblsNormalized = append(lblsNormalized, labels.Label{Name: normalizeLabelName(name), Value: value})
 	}
+	return lbls, lblsNormalized
+}
+
+// thresholdCondition is the threshold a series is compared with, the one
+// of the rule or of a query threshold
+type thresholdCondition struct {
+	target float64
+	op     CompareOp
+	match  MatchType
+}
+
+func (r *ThresholdRule) shouldAlert(series v3.Series) (Sample, bool) {
+	return shouldAlertWith(series, thresholdCondition{target: r.targetVal(), op: r.compareOp(), match: r.matchType()})
+}
+
+// keepAlerting tells whether the series that breached at the last
+// evaluation is not yet past the recovery target of the rule
+func (r *ThresholdRule) keepAlerting(series v3.Series) (Sample, bool) {
+	if r.ruleCondition.RecoveryTarget == nil {
+		return Sample{}, false
+	}
+	if _, ok := r.alertingSeries[labels.FromMap(series.Labels).Hash()]; !ok {
+		return Sample{}, false
+	}
+	return shouldAlertWith(series, thresholdCondition{target: r.recoveryVal(), op: r.compareOp(), match: r.matchType()})
+}
+
+func shouldAlertWith(series v3.Series, cond thresholdCondition) (Sample, bool) {
+	var alertSmpl Sample
+	var shouldAlert bool
+	lbls, lblsNormalized := sampleLabels(series.Labels)
 
 	series.Points = removeGroupinSetPoints(series)
 
@@ -1158,36 +1822,36 @@ func (r *ThresholdRule) shouldAlert(series v3.Series) (Sample, bool) {
 		return alertSmpl, false
 	}
 
-	switch r.matchType() {
+	switch cond.match {
 	case AtleastOnce:
 		// If any sample matches the condition, the rule is firing.
-		if r.compareOp() == ValueIsAbove {
+		if cond.op == ValueIsAbove {
 			for _, smpl := range series.Points {
-				if smpl.Value > r.targetVal() {
+				if smpl.Value > cond.target {
 					alertSmpl = Sample{Point: Point{V: smpl.Value}, Metric: lblsNormalized, MetricOrig: lbls}
 					shouldAlert = true
 					break
 				}
 			}
-		} else if r.compareOp() == ValueIsBelow {
+		} else if cond.op == ValueIsBelow {
 			for _, smpl := range series.Points {
-				if smpl.Value < r.targetVal() {
+				if smpl.Value < cond.target {
 					alertSmpl = Sample{Point: Point{V: smpl.Value}, Metric: lblsNormalized, MetricOrig: lbls}
 					shouldAlert = true
 					break
 				}
 			}
-		} else if r.compareOp() == ValueIsEq {
+		} else if cond.op == ValueIsEq {
 			for _, smpl := range series.Points {
-				if smpl.Value == r.targetVal() {
+				if smpl.Value == cond.target {
 					alertSmpl = Sample{Point: Point{V: smpl.Value}, Metric: lblsNormalized, MetricOrig: lbls}
 					shouldAlert = true
 					break
 				}
 			}
-		} else if r.compareOp() == ValueIsNotEq {
+		} else if cond.op == ValueIsNotEq {
 			for _, smpl := range series.Points {
-				if smpl.Value != r.targetVal() {
+				if smpl.Value != cond.target {
 					alertSmpl = Sample{Point: Point{V: smpl.Value}, Metric: lblsNormalized, MetricOrig: lbls}
 					shouldAlert = true
 					break
@@ -1197,10 +1861,10 @@ func (r *ThresholdRule) shouldAlert(series v3.Series) (Sample, bool) {
 	case AllTheTimes:
 		// If all samples match the condition, the rule is firing.
 		shouldAlert = true
-		alertSmpl = Sample{Point: Point{V: r.targetVal()}, Metric: lblsNormalized, MetricOrig: lbls}
-		if r.compareOp() == ValueIsAbove {
+		alertSmpl = Sample{Point: Point{V: cond.target}, Metric: lblsNormalized, MetricOrig: lbls}
+		if cond.op == ValueIsAbove {
 			for _, smpl := range series.Points {
-				if smpl.Value <= r.targetVal() {
+				if smpl.Value <= cond.target {
 					shouldAlert = false
 					break
 				}
@@ -1215,9 +1879,9 @@ func (r *ThresholdRule) shouldAlert(series v3.Series) (Sample, bool) {
 				}
 				alertSmpl = Sample{Point: Point{V: minValue}, Metric: lblsNormalized, MetricOrig: lbls}
 			}
-		} else if r.compareOp() == ValueIsBelow {
+		} else if cond.op == ValueIsBelow {
 			for _, smpl := range series.Points {
-				if smpl.Value >= r.targetVal() {
+				if smpl.Value >= cond.target {
 					shouldAlert = false
 					break
 				}
@@ -1231,16 +1895,16 @@ func (r *ThresholdRule) shouldAlert(series v3.Series) (Sample, bool) {
 				}
 				alertSmpl = Sample{Point: Point{V: maxValue}, Metric: lblsNormalized, MetricOrig: lbls}
 			}
-		} else if r.compareOp() == ValueIsEq {
+		} else if cond.op == ValueIsEq {
 			for _, smpl := range series.Points {
-				if smpl.Value != r.targetVal() {
+				if smpl.Value != cond.target {
 					shouldAlert = false
 					break
 				}
 			}
-		} else if r.compareOp() == ValueIsNotEq {
+		} else if cond.op == ValueIsNotEq {
 			for _, smpl := range series.Points {
-				if smpl.Value == r.targetVal() {
+				if smpl.Value == cond.target {
 					shouldAlert = false
 					break
 				}
@@ -1267,20 +1931,20 @@ func (r *ThresholdRule) shouldAlert(series v3.Series) (Sample, bool) {
 		}
 		avg := sum / count
 		alertSmpl = Sample{Point: Point{V: avg}, Metric: lblsNormalized, MetricOrig: lbls}
-		if r.compareOp() == ValueIsAbove {
-			if avg > r.targetVal() {
+		if cond.op == ValueIsAbove {
+			if avg > cond.target {
 				shouldAlert = true
 			}
-		} else if r.compareOp() == ValueIsBelow {
-			if avg < r.targetVal() {
+		} else if cond.op == ValueIsBelow {
+			if avg < cond.target {
 				shouldAlert = true
 			}
-		} else if r.compareOp() == ValueIsEq {
-			if avg == r.targetVal() {
+		} else if cond.op == ValueIsEq {
+			if avg == cond.target {
 				shouldAlert = true
 			}
-		} else if r.compareOp() == ValueIsNotEq {
-			if avg != r.targetVal() {
+		} else if cond.op == ValueIsNotEq {
+			if avg != cond.target {
 				shouldAlert = true
 			}
 		}
@@ -1295,20 +1959,20 @@ func (r *ThresholdRule) shouldAlert(series v3.Series) (Sample, bool) {
 			sum += smpl.Value
 		}
 		alertSmpl = Sample{Point: Point{V: sum}, Metric: lblsNormalized, MetricOrig: lbls}
-		if r.compareOp() == ValueIsAbove {
-			if sum > r.targetVal() {
+		if cond.op == ValueIsAbove {
+			if sum > cond.target {
 				shouldAlert = true
 			}
-		} else if r.compareOp() == ValueIsBelow {
-			if sum < r.targetVal() {
+		} else if cond.op == ValueIsBelow {
+			if sum < cond.target {
 				shouldAlert = true
 			}
-		} else if r.compareOp() == ValueIsEq {
-			if sum == r.targetVal() {
+		} else if cond.op == ValueIsEq {
+			if sum == cond.target {
 				shouldAlert = true
 			}
-		} else if r.compareOp() == ValueIsNotEq {
-			if sum != r.targetVal() {
+		} else if cond.op == ValueIsNotEq {
+			if sum != cond.target {
 				shouldAlert = true
 			}
 		}
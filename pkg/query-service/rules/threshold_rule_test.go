@@ -7,10 +7,12 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.signoz.io/signoz/pkg/query-service/app/clickhouseReader"
 	"go.signoz.io/signoz/pkg/query-service/featureManager"
 	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
 	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+	"go.signoz.io/signoz/pkg/query-service/utils/times"
 
 	cmock "github.com/srikanthccv/ClickHouse-go-mock"
 )
@@ -827,6 +829,65 @@ func TestPrepareLinksToTraces(t *testing.T) {
 	assert.Contains(t, link, "&timeRange=%7B%22start%22%3A1705468620000000000%2C%22end%22%3A1705468920000000000%2C%22pageSize%22%3A100%7D&startTime=1705468620000000000&endTime=1705468920000000000")
 }
 
+func TestThresholdRuleDeepLinks(t *testing.T) {
+	postableRule := PostableRule{
+		AlertName:  "Deep links test",
+		AlertType:  "METRIC_BASED_ALERT",
+		RuleType:   RuleTypeThreshold,
+		EvalWindow: Duration(5 * time.Minute),
+		Frequency:  Duration(1 * time.Minute),
+		Source:     "http://signoz.example.com:3301/alerts/new",
+		RuleCondition: &RuleCondition{
+			CompositeQuery: &v3.CompositeQuery{
+				QueryType: v3.QueryTypeBuilder,
+				BuilderQueries: map[string]*v3.BuilderQuery{
+					"A": {
+						QueryName:          "A",
+						StepInterval:       60,
+						AggregateAttribute: v3.AttributeKey{Key: "signoz_calls_total"},
+						AggregateOperator:  v3.AggregateOperatorSumRate,
+						DataSource:         v3.DataSourceMetrics,
+						Expression:         "A",
+					},
+				},
+			},
+			CompareOp:     ValueIsAbove,
+			MatchType:     AtleastOnce,
+			Target:        &[]float64{1.0}[0],
+			SelectedQuery: "A",
+		},
+	}
+	fm := featureManager.StartManager()
+
+	rule, err := NewThresholdRule("69", &postableRule, ThresholdRuleOpts{}, fm, nil)
+	require.NoError(t, err)
+
+	ts := time.UnixMilli(1705469040000)
+	activeAt := ts.Add(-10 * time.Minute)
+	lbls := labels.Labels{{Name: "service.name", Value: "frontend"}}
+
+	annotations := rule.addDeepLinks(labels.Labels{{Name: "summary", Value: "high rate"}}, ts, activeAt, lbls)
+
+	chart := annotations.Get(RelatedChartAnnotation)
+	assert.True(t, strings.HasPrefix(chart, "http://signoz.example.com:3301/alerts/edit?"), chart)
+	assert.Contains(t, chart, "ruleId=69")
+	assert.Contains(t, chart, "startTime=1705466340000")
+	assert.Contains(t, chart, "endTime=1705470240000")
+
+	logs := annotations.Get(RelatedLogsAnnotation)
+	assert.True(t, strings.HasPrefix(logs, "http://signoz.example.com:3301/logs/logs-explorer?"), logs)
+	assert.Contains(t, logs, "frontend")
+
+	traces := annotations.Get(RelatedTracesAnnotation)
+	assert.True(t, strings.HasPrefix(traces, "http://signoz.example.com:3301/traces-explorer?"), traces)
+	assert.Contains(t, traces, "serviceName")
+
+	// links set by the user are kept
+	annotations = rule.addDeepLinks(labels.Labels{{Name: RelatedLogsAnnotation, Value: "custom"}}, ts, activeAt, labels.Labels{})
+	assert.Equal(t, "custom", annotations.Get(RelatedLogsAnnotation))
+	assert.Empty(t, annotations.Get(RelatedTracesAnnotation))
+}
+
 func TestThresholdRuleLabelNormalization(t *testing.T) {
 	postableRule := PostableRule{
 		AlertName:  "Tricky Condition Tests",
@@ -911,6 +972,67 @@ func TestThresholdRuleLabelNormalization(t *testing.T) {
 	}
 }
 
+func TestThresholdRuleQueryValues(t *testing.T) {
+	postableRule := PostableRule{
+		AlertName:  "Error ratio",
+		AlertType:  "METRIC_BASED_ALERT",
+		RuleType:   RuleTypeThreshold,
+		EvalWindow: Duration(5 * time.Minute),
+		Frequency:  Duration(1 * time.Minute),
+		RuleCondition: &RuleCondition{
+			CompositeQuery: &v3.CompositeQuery{
+				QueryType: v3.QueryTypeBuilder,
+				BuilderQueries: map[string]*v3.BuilderQuery{
+					"A": {QueryName: "A", StepInterval: 60, DataSource: v3.DataSourceMetrics, Expression: "A"},
+					"B": {QueryName: "B", StepInterval: 60, DataSource: v3.DataSourceMetrics, Expression: "B"},
+				},
+			},
+			CompareOp:     ValueIsAbove,
+			MatchType:     InTotal,
+			SelectedQuery: "A",
+		},
+	}
+	target := 1.0
+	postableRule.RuleCondition.Target = &target
+
+	fm := featureManager.StartManager()
+	rule, err := NewThresholdRule("69", &postableRule, ThresholdRuleOpts{}, fm, nil)
+	require.NoError(t, err)
+
+	results := []*v3.Result{
+		{
+			QueryName: "A",
+			Series: []*v3.Series{
+				{Labels: map[string]string{"service": "a"}, Points: []v3.Point{{Timestamp: 1, Value: 2}, {Timestamp: 2, Value: 40}}},
+				{Labels: map[string]string{"service": "b"}, Points: []v3.Point{{Timestamp: 1, Value: 5}}},
+			},
+		},
+		{
+			// total without group by matches every series
+			QueryName: "B",
+			Series: []*v3.Series{
+				{Labels: map[string]string{}, Points: []v3.Point{{Timestamp: 1, Value: 600}, {Timestamp: 2, Value: 400}}},
+			},
+		},
+	}
+
+	values := rule.reduceQueryResults(results)
+	assert.Equal(t, map[string]float64{"A": 42, "B": 1000}, values.valuesFor(map[string]string{"service": "a"}))
+	assert.Equal(t, map[string]float64{"A": 5, "B": 1000}, values.valuesFor(map[string]string{"service": "b"}))
+
+	tmpl := NewTemplateExpander(
+		context.Background(),
+		`{{$values := .Values}}errors={{ index $values "A" }}, total={{ index .Values "B" }}, ratio={{ percentage (index $values "A") (index $values "B") | printf "%.1f" }}%`,
+		"test",
+		AlertTemplateData(nil, "42", "1", values.valuesFor(map[string]string{"service": "a"})),
+		times.Time(0),
+		nil,
+	)
+	result, err := tmpl.Expand()
+	require.NoError(t, err)
+	assert.Equal(t, "errors=42, total=1000, ratio=4.2%", result)
+}
+
 func TestThresholdRuleEvalDelay(t *testing.T) {
 	postableRule := PostableRule{
 		AlertName:  "Test Eval Delay",
@@ -960,6 +1082,89 @@ func TestThresholdRuleEvalDelay(t *testing.T) {
 	}
 }
 
+func TestThresholdRuleEvalResolution(t *testing.T) {
+	target := 1.0
+	postableRule := PostableRule{
+		AlertName:      "Test Eval Resolution",
+		AlertType:      "METRIC_BASED_ALERT",
+		RuleType:       RuleTypeThreshold,
+		EvalWindow:     Duration(5 * time.Minute),
+		Frequency:      Duration(1 * time.Minute),
+		EvalResolution: Duration(15 * time.Second),
+		RuleCondition: &RuleCondition{
+			CompositeQuery: &v3.CompositeQuery{
+				QueryType: v3.QueryTypeBuilder,
+				BuilderQueries: map[string]*v3.BuilderQuery{
+					"A": {
+						QueryName:    "A",
+						StepInterval: 60,
+						AggregateAttribute: v3.AttributeKey{
+							Key: "probe_success",
+						},
+						AggregateOperator: v3.AggregateOperatorNoOp,
+						DataSource:        v3.DataSourceMetrics,
+						Expression:        "A",
+					},
+				},
+			},
+			CompareOp: ValueIsAbove,
+			MatchType: AtleastOnce,
+			Target:    &target,
+		},
+	}
+
+	// 01:39:47
+	ts := time.Unix(1717205987, 0)
+
+	fm := featureManager.StartManager()
+	rule, err := NewThresholdRule("69", &postableRule, ThresholdRuleOpts{}, fm, nil)
+	require.NoError(t, err)
+
+	params := rule.prepareQueryRange(ts)
+	// 01:34:45 - 01:39:45
+	assert.Equal(t, int64(1717205685000), params.Start)
+	assert.Equal(t, int64(1717205985000), params.End)
+	assert.Equal(t, int64(15), params.Step)
+	assert.Equal(t, int64(15), params.CompositeQuery.BuilderQueries["A"].StepInterval)
+
+	// without a resolution the step of the query is kept
+	postableRule.EvalResolution = 0
+	postableRule.RuleCondition.CompositeQuery.BuilderQueries["A"].StepInterval = 60
+	rule, err = NewThresholdRule("69", &postableRule, ThresholdRuleOpts{}, fm, nil)
+	require.NoError(t, err)
+
+	params = rule.prepareQueryRange(ts)
+	assert.Equal(t, int64(1717205640000), params.Start)
+	assert.Equal(t, int64(60), params.Step)
+	assert.Equal(t, int64(60), params.CompositeQuery.BuilderQueries["A"].StepInterval)
+}
+
+func TestValidateEvalResolution(t *testing.T) {
+	cases := []struct {
+		name       string
+		window     time.Duration
+		resolution time.Duration
+		wantErr    bool
+	}{
+		{name: "not set", window: 5 * time.Minute},
+		{name: "high frequency", window: 5 * time.Minute, resolution: 15 * time.Second},
+		{name: "expensive", window: time.Hour, resolution: 5 * time.Minute},
+		{name: "below minimum", window: 5 * time.Minute, resolution: time.Second, wantErr: true},
+		{name: "fraction of a second", window: 5 * time.Minute, resolution: 7500 * time.Millisecond, wantErr: true},
+		{name: "larger than window", window: 5 * time.Minute, resolution: 10 * time.Minute, wantErr: true},
+		{name: "too many points", window: 24 * time.Hour, resolution: 15 * time.Second, wantErr: true},
+	}
+	for _, c := range cases {
+		rule := PostableRule{EvalWindow: Duration(c.window), EvalResolution: Duration(c.resolution)}
+		err := rule.validateEvalResolution()
+		if c.wantErr {
+			assert.Error(t, err, c.name)
+		} else {
+			assert.NoError(t, err, c.name)
+		}
+	}
+}
+
 func TestThresholdRuleClickHouseTmpl(t *testing.T) {
 	postableRule := PostableRule{
 		AlertName:  "Tricky Condition Tests",
@@ -1159,7 +1364,7 @@ func TestThresholdRuleUnitCombinations(t *testing.T) {
 		assert.Equal(t, c.expectAlerts, retVal.(int), "case %d", idx)
 		if c.expectAlerts != 0 {
 			foundCount := 0
-			for _, item := range rule.active {
+			for _, item := range rule.currentAlerts() {
 				for _, summary := range c.summaryAny {
 					if strings.Contains(item.Annotations.Get("summary"), summary) {
 						foundCount++
@@ -1260,7 +1465,7 @@ func TestThresholdRuleNoData(t *testing.T) {
 		}
 
 		assert.Equal(t, 1, retVal.(int), "case %d", idx)
-		for _, item := range rule.active {
+		for _, item := range rule.currentAlerts() {
 			if c.expectNoData {
 				assert.True(t, strings.Contains(item.Labels.Get(labels.AlertNameLabel), "[No data]"), "case %d", idx)
 			} else {
@@ -1269,3 +1474,56 @@ func TestThresholdRuleNoData(t *testing.T) {
 		}
 	}
 }
+
+func TestThresholdRuleDataTier(t *testing.T) {
+	newRule := func(window time.Duration, tier v3.Downsampling) *PostableRule {
+		return &PostableRule{
+			AlertName:  "data tier",
+			RuleType:   RuleTypeThreshold,
+			EvalWindow: Duration(window),
+			RuleCondition: &RuleCondition{
+				CompositeQuery: &v3.CompositeQuery{
+					QueryType: v3.QueryTypeBuilder,
+					BuilderQueries: map[string]*v3.BuilderQuery{
+						"A": {QueryName: "A", Expression: "A", DataSource: v3.DataSourceMetrics, StepInterval: 60},
+					},
+				},
+				CompareOp:    ValueIsAbove,
+				MatchType:    AtleastOnce,
+				Target:       new(float64),
+				Downsampling: tier,
+			},
+		}
+	}
+
+	assert.NoError(t, newRule(time.Hour, v3.Downsampling5m).Validate())
+	assert.Error(t, newRule(7*time.Minute, v3.Downsampling5m).Validate())
+	assert.Error(t, newRule(30*time.Minute, v3.Downsampling1h).Validate())
+	withResolution := newRule(time.Hour, v3.Downsampling30m)
+	withResolution.EvalResolution = Duration(10 * time.Minute)
+	assert.Error(t, withResolution.Validate())
+	logs := newRule(time.Hour, v3.DownsamplingAuto)
+	logs.RuleCondition.CompositeQuery.BuilderQueries["A"].DataSource = v3.DataSourceLogs
+	assert.Error(t, logs.Validate())
+
+	ts := time.Unix(1717200000, 0)
+	cases := []struct {
+		window time.Duration
+		tier   v3.Downsampling
+		read   v3.Downsampling
+		step   int64
+	}{
+		{window: time.Hour, tier: v3.Downsampling5m, read: v3.Downsampling5m, step: 300},
+		{window: 2 * time.Hour, tier: v3.Downsampling1h, read: v3.Downsampling1h, step: 3600},
+		{window: time.Hour, tier: v3.DownsamplingAuto, read: v3.DownsamplingRaw, step: 60},
+		{window: 12 * time.Hour, tier: v3.DownsamplingAuto, read: v3.Downsampling5m, step: 300},
+		{window: 48 * time.Hour, tier: v3.DownsamplingAuto, read: v3.Downsampling1h, step: 3600},
+	}
+	for _, c := range cases {
+		rule, err := NewThresholdRule("1", newRule(c.window, c.tier), ThresholdRuleOpts{}, nil, nil)
+		require.NoError(t, err)
+		params := rule.prepareQueryRange(ts)
+		assert.Equal(t, c.step, params.Step, "%s over %s", c.tier, c.window)
+		assert.Equal(t, c.read, params.CompositeQuery.BuilderQueries["A"].Downsampling, "%s over %s", c.tier, c.window)
+	}
+}
@@ -0,0 +1,336 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/converter"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultSuggestionLookback = 7 * 24 * time.Hour
+	MaxSuggestionLookback     = 30 * 24 * time.Hour
+
+	// maxSuggestionPoints bounds the points of a series the promql query
+	// of the lookback returns
+	maxSuggestionPoints = 2000
+)
+
+// The methods the thresholds are suggested with, the rules alerting below
+// the threshold get the lower percentiles and mean-3σ
+const (
+	SuggestionP95             = "p95"
+	SuggestionP99             = "p99"
+	SuggestionThreeSigma      = "mean+3σ"
+	SuggestionP5              = "p5"
+	SuggestionP1              = "p1"
+	SuggestionMinusThreeSigma = "mean-3σ"
+)
+
+// ThresholdSuggestionRequest asks for thresholds for the query of a rule
+// being edited, the threshold and the compare op of the rule are optional
+type ThresholdSuggestionRequest struct {
+	Rule     json.RawMessage `json:"rule"`
+	Lookback Duration        `json:"lookback"`
+}
+
+func (req *ThresholdSuggestionRequest) Validate() error {
+	if len(req.Rule) == 0 {
+		return fmt.Errorf("rule is required")
+	}
+	if req.Lookback == 0 {
+		req.Lookback = Duration(DefaultSuggestionLookback)
+	}
+	if lookback := time.Duration(req.Lookback); lookback < time.Hour || lookback > MaxSuggestionLookback {
+		return fmt.Errorf("lookback must be between 1h and %s", MaxSuggestionLookback)
+	}
+	return nil
+}
+
+// SuggestedThreshold is a candidate threshold with the alerts it would
+// have raised over the lookback
+type SuggestedThreshold struct {
+	Method string `json:"method"`
+	// Threshold is in the target unit of the rule
+	Threshold    float64 `json:"threshold"`
+	Alerts       int     `json:"alerts"`
+	AlertsPerDay float64 `json:"alertsPerDay"`
+	// BreachRatio is the share of the points breaching the threshold
+	BreachRatio float64 `json:"breachRatio"`
+}
+
+// ThresholdSuggestion is the distribution of the values of the query over
+// the lookback and the thresholds suggested from it. The statistics are in
+// the unit of the query.
+type ThresholdSuggestion struct {
+	Start       int64                `json:"start"`
+	End         int64                `json:"end"`
+	Step        int64                `json:"step"`
+	Unit        string               `json:"unit"`
+	TargetUnit  string               `json:"targetUnit"`
+	Series      int                  `json:"series"`
+	Points      int                  `json:"points"`
+	Min         float64              `json:"min"`
+	Max         float64              `json:"max"`
+	Mean        float64              `json:"mean"`
+	StdDev      float64              `json:"stdDev"`
+	Suggestions []SuggestedThreshold `json:"suggestions"`
+}
+
+// valueSampler is implemented by the rules whose query values can be read
+// for a past range
+type valueSampler interface {
+	// sampleValues runs the query of the rule over the eval window ending
+	// at ts and returns the values of every series with their step
+	sampleValues(ctx context.Context, ts time.Time, queriers *Queriers) ([][]float64, time.Duration, error)
+}
+
+func (r *ThresholdRule) sampleValues(ctx context.Context, ts time.Time, queriers *Queriers) ([][]float64, time.Duration, error) {
+	if r.ruleCondition == nil || r.ruleCondition.CompositeQuery == nil {
+		return nil, 0, fmt.Errorf("invalid rule condition")
+	}
+	params := r.prepareQueryRange(ts)
+	results, err := r.queryResults(ctx, params, queriers.Ch, queriers.Cache)
+	if err != nil {
+		return nil, 0, err
+	}
+	selectedQuery := r.GetSelectedQuery()
+	values := [][]float64{}
+	for _, res := range results {
+		if res.QueryName != selectedQuery {
+			continue
+		}
+		for _, series := range res.Series {
+			points := removeGroupinSetPoints(*series)
+			seriesValues := make([]float64, 0, len(points))
+			for _, p := range points {
+				seriesValues = append(seriesValues, p.Value)
+			}
+			values = append(values, seriesValues)
+		}
+	}
+	return values, time.Duration(params.Step) * time.Second, nil
+}
+
+func (r *PromRule) sampleValues(ctx context.Context, ts time.Time, queriers *Queriers) ([][]float64, time.Duration, error) {
+	q, err := r.getPqlQuery()
+	if err != nil {
+		return nil, 0, err
+	}
+	step := r.evalResolution
+	if step < time.Minute {
+		step = time.Minute
+	}
+	if minStep := (r.evalWindow / maxSuggestionPoints).Truncate(time.Minute) + time.Minute; step < minStep {
+		step = minStep
+	}
+	res, err := r.runQuery(ctx, queriers, q, ts.Add(-r.evalWindow), ts, step)
+	if err != nil {
+		return nil, 0, err
+	}
+	values := make([][]float64, 0, len(res))
+	for _, series := range res {
+		seriesValues := make([]float64, 0, len(series.Floats))
+		for _, p := range series.Floats {
+			if !math.IsNaN(p.F) && !math.IsInf(p.F, 0) {
+				seriesValues = append(seriesValues, p.F)
+			}
+		}
+		values = append(values, seriesValues)
+	}
+	return values, step, nil
+}
+
+// suggestionRule parses the rule of the request, the threshold is what is
+// suggested so the rule does not need one yet
+func suggestionRule(content []byte) (*PostableRule, error) {
+	var rule PostableRule
+	if err := json.Unmarshal(content, &rule); err != nil {
+		return nil, ErrFailedToParseJSON
+	}
+	if cond := rule.RuleCondition; cond != nil {
+		if cond.Target == nil && len(cond.QueryThresholds) == 0 && cond.ExpectedMembers == nil {
+			var target float64
+			cond.Target = &target
+		}
+		if cond.CompareOp == "" {
+			cond.CompareOp = ValueIsAbove
+		}
+		if cond.MatchType == "" {
+			cond.MatchType = AtleastOnce
+		}
+	}
+	content, err := json.Marshal(rule)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePostableRule(content)
+}
+
+// SuggestThresholds runs the query of the rule over the lookback and
+// suggests thresholds from the distribution of its values
+func (m *Manager) SuggestThresholds(ctx context.Context, req *ThresholdSuggestionRequest) (*ThresholdSuggestion, *model.ApiError) {
+	if err := req.Validate(); err != nil {
+		return nil, newApiErrorBadData(err)
+	}
+	parsedRule, err := suggestionRule(req.Rule)
+	if err != nil {
+		return nil, newApiErrorBadData(err)
+	}
+	if err := parsedRule.validateSandbox(); err != nil {
+		return nil, newApiErrorBadData(err)
+	}
+	op := parsedRule.RuleCondition.CompareOp
+	if op != ValueIsAbove && op != ValueIsBelow {
+		return nil, newApiErrorBadData(fmt.Errorf("thresholds are only suggested for the rules alerting above or below a value"))
+	}
+	lookback := time.Duration(req.Lookback)
+	parsedRule.EvalWindow = req.Lookback
+
+	rule, err := m.newRule("", parsedRule)
+	if err != nil {
+		return nil, newApiErrorBadData(err)
+	}
+	sampler, ok := rule.(valueSampler)
+	if !ok {
+		return nil, newApiErrorBadData(fmt.Errorf("thresholds cannot be suggested for rules of type %s", rule.Type()))
+	}
+	queriers, err := m.opts.Queriers.For(rule)
+	if err != nil {
+		return nil, newApiErrorBadData(err)
+	}
+	ts := time.Now()
+	values, step, err := sampler.sampleValues(ctx, ts, queriers)
+	if err != nil {
+		zap.L().Error("querying the lookback of the rule failed", zap.Error(err))
+		return nil, newApiErrorInternal(err)
+	}
+
+	var hold time.Duration
+	if r, ok := rule.(interface{ HoldDuration() time.Duration }); ok {
+		hold = r.HoldDuration()
+	}
+	suggestion := suggestThresholds(values, op, step, hold, lookback)
+	suggestion.Start = ts.Add(-lookback).UnixMilli()
+	suggestion.End = ts.UnixMilli()
+	if r, ok := rule.(interface{ Unit() string }); ok {
+		suggestion.Unit = r.Unit()
+	}
+	suggestion.TargetUnit = parsedRule.RuleCondition.TargetUnit
+	if suggestion.Unit != "" && suggestion.TargetUnit != "" {
+		for i := range suggestion.Suggestions {
+			s := &suggestion.Suggestions[i]
+			s.Threshold = converter.Convert(converter.Value{F: s.Threshold, U: converter.Unit(suggestion.Unit)}, converter.Unit(suggestion.TargetUnit)).F
+		}
+	}
+	return suggestion, nil
+}
+
+// suggestThresholds computes the distribution of the values and replays
+// every candidate threshold over the series, a breach lasting the hold
+// duration of the rule counts as an alert
+func suggestThresholds(values [][]float64, op CompareOp, step, hold, lookback time.Duration) *ThresholdSuggestion {
+	suggestion := &ThresholdSuggestion{Step: step.Milliseconds(), Suggestions: []SuggestedThreshold{}}
+
+	all := []float64{}
+	for _, series := range values {
+		if len(series) > 0 {
+			suggestion.Series++
+		}
+		all = append(all, series...)
+	}
+	suggestion.Points = len(all)
+	if len(all) == 0 {
+		return suggestion
+	}
+	sort.Float64s(all)
+
+	var sum float64
+	for _, v := range all {
+		sum += v
+	}
+	mean := sum / float64(len(all))
+	var squares float64
+	for _, v := range all {
+		squares += (v - mean) * (v - mean)
+	}
+	stdDev := math.Sqrt(squares / float64(len(all)))
+	suggestion.Min, suggestion.Max = all[0], all[len(all)-1]
+	suggestion.Mean, suggestion.StdDev = mean, stdDev
+
+	candidates := []struct {
+		method    string
+		threshold float64
+	}{
+		{SuggestionP95, quantile(all, 0.95)},
+		{SuggestionP99, quantile(all, 0.99)},
+		{SuggestionThreeSigma, mean + 3*stdDev},
+	}
+	if op == ValueIsBelow {
+		candidates[0].method, candidates[0].threshold = SuggestionP5, quantile(all, 0.05)
+		candidates[1].method, candidates[1].threshold = SuggestionP1, quantile(all, 0.01)
+		candidates[2].method, candidates[2].threshold = SuggestionMinusThreeSigma, mean-3*stdDev
+	}
+
+	days := lookback.Hours() / 24
+	for _, c := range candidates {
+		alerts, breaching := replayThreshold(values, op, c.threshold, step, hold)
+		suggestion.Suggestions = append(suggestion.Suggestions, SuggestedThreshold{
+			Method:       c.method,
+			Threshold:    c.threshold,
+			Alerts:       alerts,
+			AlertsPerDay: float64(alerts) / days,
+			BreachRatio:  float64(breaching) / float64(len(all)),
+		})
+	}
+	return suggestion
+}
+
+// replayThreshold returns the alerts the threshold raises over the series
+// and the number of points breaching it
+func replayThreshold(values [][]float64, op CompareOp, threshold float64, step, hold time.Duration) (int, int) {
+	// the alert fires once per breach, when the breach has lasted the hold
+	// duration of the rule
+	need := 1
+	if step > 0 && hold > step {
+		need = int(math.Ceil(float64(hold) / float64(step)))
+	}
+	alerts, breaching := 0, 0
+	for _, series := range values {
+		run := 0
+		for _, v := range series {
+			breached := v > threshold
+			if op == ValueIsBelow {
+				breached = v < threshold
+			}
+			if !breached {
+				run = 0
+				continue
+			}
+			breaching++
+			run++
+			if run == need {
+				alerts++
+			}
+		}
+	}
+	return alerts, breaching
+}
+
+// quantile returns the q quantile of the sorted values, interpolating
+// between the closest ranks
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+	rank := q * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
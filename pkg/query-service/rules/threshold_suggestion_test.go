@@ -0,0 +1,86 @@
+package rules
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuggestThresholds(t *testing.T) {
+	// one series rising from 1 to 100 and one flat at 10
+	rising := make([]float64, 100)
+	for i := range rising {
+		rising[i] = float64(i + 1)
+	}
+	flat := []float64{10, 10, 10, 10}
+	values := [][]float64{rising, flat, {}}
+
+	suggestion := suggestThresholds(values, ValueIsAbove, time.Minute, 0, 2*24*time.Hour)
+	assert.Equal(t, 2, suggestion.Series)
+	assert.Equal(t, 104, suggestion.Points)
+	assert.Equal(t, 1.0, suggestion.Min)
+	assert.Equal(t, 100.0, suggestion.Max)
+
+	require.Len(t, suggestion.Suggestions, 3)
+	p95 := suggestion.Suggestions[0]
+	assert.Equal(t, SuggestionP95, p95.Method)
+	assert.InDelta(t, 94.85, p95.Threshold, 1e-9)
+	// the rising series breaches once, for its last six points
+	assert.Equal(t, 1, p95.Alerts)
+	assert.Equal(t, 0.5, p95.AlertsPerDay)
+	assert.InDelta(t, 6.0/104, p95.BreachRatio, 1e-9)
+	assert.Equal(t, SuggestionThreeSigma, suggestion.Suggestions[2].Method)
+
+	below := suggestThresholds(values, ValueIsBelow, time.Minute, 0, 24*time.Hour)
+	assert.Equal(t, []string{SuggestionP5, SuggestionP1, SuggestionMinusThreeSigma}, []string{below.Suggestions[0].Method, below.Suggestions[1].Method, below.Suggestions[2].Method})
+
+	empty := suggestThresholds(nil, ValueIsAbove, time.Minute, 0, 24*time.Hour)
+	assert.Zero(t, empty.Points)
+	assert.Empty(t, empty.Suggestions)
+}
+
+func TestReplayThreshold(t *testing.T) {
+	series := [][]float64{{1, 5, 5, 1, 5, 1, 5, 5, 5}}
+
+	alerts, breaching := replayThreshold(series, ValueIsAbove, 2, time.Minute, 0)
+	assert.Equal(t, 3, alerts)
+	assert.Equal(t, 6, breaching)
+
+	// the breaches shorter than the hold duration do not alert
+	alerts, _ = replayThreshold(series, ValueIsAbove, 2, time.Minute, 3*time.Minute)
+	assert.Equal(t, 1, alerts)
+
+	alerts, breaching = replayThreshold(series, ValueIsBelow, 2, time.Minute, 0)
+	assert.Equal(t, 3, alerts)
+	assert.Equal(t, 3, breaching)
+}
+
+func TestSuggestionRule(t *testing.T) {
+	content, err := json.Marshal(map[string]interface{}{
+		"alert": "latency",
+		"condition": map[string]interface{}{
+			"compositeQuery": map[string]interface{}{
+				"queryType": "promql",
+				"promQueries": map[string]interface{}{
+					"A": map[string]interface{}{"query": "rate(http_requests_total[5m])"},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	rule, err := suggestionRule(content)
+	require.NoError(t, err)
+	assert.Equal(t, ValueIsAbove, rule.RuleCondition.CompareOp)
+	assert.Equal(t, AtleastOnce, rule.RuleCondition.MatchType)
+
+	req := ThresholdSuggestionRequest{Rule: content}
+	require.NoError(t, req.Validate())
+	assert.Equal(t, Duration(DefaultSuggestionLookback), req.Lookback)
+
+	req = ThresholdSuggestionRequest{Rule: content, Lookback: Duration(MaxSuggestionLookback + time.Hour)}
+	assert.Error(t, req.Validate())
+}
@@ -0,0 +1,36 @@
+package rules
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer traces the rule evaluations, the spans are dropped unless a
+// tracer provider is registered with otel
+var tracer = otel.Tracer("go.signoz.io/signoz/pkg/query-service/rules")
+
+func ruleAttributes(rule Rule) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("rule.id", rule.ID()),
+		attribute.String("rule.name", rule.Name()),
+		attribute.String("rule.type", string(rule.Type())),
+	}
+}
+
+// endSpan records the error on the span, if any, and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// startSpan starts a child span of the span in ctx
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
@@ -0,0 +1,91 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.signoz.io/signoz/pkg/query-service/featureManager"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+type tracingQuerier struct {
+	err error
+}
+
+func (q *tracingQuerier) QueryRange(context.Context, *v3.QueryRangeParamsV3, map[string]v3.AttributeKey) ([]*v3.Result, map[string]error, error) {
+	return []*v3.Result{{QueryName: "A"}}, nil, q.err
+}
+
+func (q *tracingQuerier) QueriesExecuted() []string { return nil }
+
+func (q *tracingQuerier) TimeRanges() [][]int { return nil }
+
+func TestRuleQueryTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	target := 10.0
+	postableRule := PostableRule{
+		AlertName:  "Slow queries",
+		AlertType:  "METRIC_BASED_ALERT",
+		RuleType:   RuleTypeThreshold,
+		EvalWindow: Duration(5 * time.Minute),
+		Frequency:  Duration(1 * time.Minute),
+		RuleCondition: &RuleCondition{
+			CompositeQuery: &v3.CompositeQuery{
+				QueryType: v3.QueryTypeClickHouseSQL,
+				ClickHouseQueries: map[string]*v3.ClickHouseQuery{
+					"A": {Query: "SELECT 1"},
+				},
+			},
+			CompareOp: ValueIsAbove,
+			MatchType: AtleastOnce,
+			Target:    &target,
+		},
+	}
+	rule, err := NewThresholdRule("1", &postableRule, ThresholdRuleOpts{}, featureManager.StartManager(), nil)
+	require.NoError(t, err)
+	querier := &tracingQuerier{}
+	rule.querier = querier
+
+	params := &v3.QueryRangeParamsV3{Start: 1, End: 2, Step: 60, CompositeQuery: postableRule.RuleCondition.CompositeQuery}
+	cache := NewQueryCache(time.Minute)
+
+	_, _, err = rule.runQuery(context.Background(), params, cache)
+	require.NoError(t, err)
+	_, _, err = rule.runQuery(context.Background(), params, cache)
+	require.NoError(t, err)
+
+	querier.err = errors.New("too many simultaneous queries")
+	params.End = 3
+	_, _, err = rule.runQuery(context.Background(), params, cache)
+	require.Error(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	cached := func(span sdktrace.ReadOnlySpan) attribute.Value {
+		for _, attr := range span.Attributes() {
+			if attr.Key == "rule.query.cached" {
+				return attr.Value
+			}
+		}
+		return attribute.Value{}
+	}
+	for _, span := range spans {
+		assert.Equal(t, "rule.query", span.Name())
+	}
+	assert.False(t, cached(spans[0]).AsBool())
+	assert.True(t, cached(spans[1]).AsBool())
+	assert.Equal(t, codes.Unset, spans[1].Status().Code)
+	assert.Equal(t, codes.Error, spans[2].Status().Code)
+	assert.Len(t, spans[2].Events(), 1)
+}
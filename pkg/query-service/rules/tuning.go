@@ -0,0 +1,536 @@
+package rules
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.uber.org/zap"
+)
+
+// The kinds of the tuning recommendations
+const (
+	// TuningRaiseHold raises the for duration of the rule above the
+	// duration of its short lived alerts
+	TuningRaiseHold = "raise_hold"
+	// TuningHysteresis adds a recovery target to the rule whose alerts
+	// fire again soon after they resolve
+	TuningHysteresis = "hysteresis"
+	// TuningGroupBy removes a label from the group by of the selected
+	// query when its alerts fire together across the values of the label
+	TuningGroupBy = "reduce_group_by"
+)
+
+const (
+	// DefaultTuningLookback is the state history the recommendations are
+	// computed from
+	DefaultTuningLookback = 14 * 24 * time.Hour
+
+	tuningInterval = 6 * time.Hour
+	tuningTimeout  = 5 * time.Minute
+	tuningKey      = "tuning"
+
+	// minTuningAlerts is the number of alerts under which a rule is not
+	// tuned, there is too little to learn from
+	minTuningAlerts = 5
+	// minTuningReduction is the share of the alerts a change must remove
+	// to be recommended
+	minTuningReduction = 0.3
+	// refireGap is the time after resolving within which an alert firing
+	// again is flapping
+	refireGap = 15 * time.Minute
+	// recoveryMargin is the share of the target the recommended recovery
+	// target is short of it
+	recoveryMargin = 0.1
+)
+
+// tuningHolds are the for durations the short lived alerts are replayed
+// with, the shortest one removing enough alerts is recommended. The longer
+// for durations would delay the alerts that matter too much.
+var tuningHolds = []time.Duration{2 * time.Minute, 5 * time.Minute, 10 * time.Minute}
+
+// TuningRecommendation is a change to a rule that would have removed some
+// of its alerts over the lookback. It is applied with the proposed for
+// duration, recovery target or group by.
+type TuningRecommendation struct {
+	ID       string `json:"id"`
+	RuleID   string `json:"ruleId"`
+	RuleName string `json:"ruleName"`
+	Kind     string `json:"kind"`
+	Summary  string `json:"summary"`
+	Current  string `json:"current"`
+	Proposed string `json:"proposed"`
+	// Alerts is the number of alerts of the rule over the lookback and
+	// Removed the number of them the change would have removed
+	Alerts           int     `json:"alerts"`
+	Removed          int     `json:"removed"`
+	ReductionPercent float64 `json:"reductionPercent"`
+
+	HoldDuration   *Duration `json:"for,omitempty"`
+	RecoveryTarget *float64  `json:"recoveryTarget,omitempty"`
+	QueryName      string    `json:"queryName,omitempty"`
+	DropLabel      string    `json:"dropLabel,omitempty"`
+}
+
+// TuningReport holds the recommendations of the rules, the ones removing
+// the most alerts first
+type TuningReport struct {
+	GeneratedAt     time.Time              `json:"generatedAt"`
+	Start           int64                  `json:"start"`
+	End             int64                  `json:"end"`
+	Recommendations []TuningRecommendation `json:"recommendations"`
+}
+
+func newTuningRecommendation(rule *PostableRule, ruleID, kind string, alerts, removed int) TuningRecommendation {
+	return TuningRecommendation{
+		ID:               ruleID + "-" + kind,
+		RuleID:           ruleID,
+		RuleName:         rule.AlertName,
+		Kind:             kind,
+		Alerts:           alerts,
+		Removed:          removed,
+		ReductionPercent: 100 * float64(removed) / float64(alerts),
+	}
+}
+
+// recommendTunings replays the alert episodes of the rules with the
+// changes that make them quieter, rules maps the ids to their definitions
+func recommendTunings(episodes []alertEpisode, rules map[string]*PostableRule) []TuningRecommendation {
+	byRule := map[string][]alertEpisode{}
+	for _, e := range episodes {
+		if _, ok := rules[e.ruleID]; ok {
+			byRule[e.ruleID] = append(byRule[e.ruleID], e)
+		}
+	}
+
+	recommendations := []TuningRecommendation{}
+	for id, ruleEpisodes := range byRule {
+		if len(ruleEpisodes) < minTuningAlerts {
+			continue
+		}
+		rule := rules[id]
+		if rec, ok := recommendHold(id, rule, ruleEpisodes); ok {
+			recommendations = append(recommendations, rec)
+		}
+		if rec, ok := recommendHysteresis(id, rule, ruleEpisodes); ok {
+			recommendations = append(recommendations, rec)
+		}
+		if rec, ok := recommendGroupBy(id, rule, ruleEpisodes); ok {
+			recommendations = append(recommendations, rec)
+		}
+	}
+	sort.Slice(recommendations, func(i, j int) bool {
+		a, b := recommendations[i], recommendations[j]
+		if a.Removed != b.Removed {
+			return a.Removed > b.Removed
+		}
+		return a.ID < b.ID
+	})
+	return recommendations
+}
+
+// recommendHold returns the shortest for duration removing enough of the
+// alerts. An alert that fired for d with the current for duration h would
+// not fire with a for duration above d+h.
+func recommendHold(id string, rule *PostableRule, episodes []alertEpisode) (TuningRecommendation, bool) {
+	current := time.Duration(rule.HoldDuration)
+	for _, hold := range tuningHolds {
+		if hold <= current {
+			continue
+		}
+		removed := 0
+		for _, e := range episodes {
+			if e.resolved && time.Duration(e.duration())*time.Millisecond < hold-current {
+				removed++
+			}
+		}
+		// a for duration removing all the alerts silences the rule
+		if float64(removed) < minTuningReduction*float64(len(episodes)) || removed == len(episodes) {
+			continue
+		}
+		rec := newTuningRecommendation(rule, id, TuningRaiseHold, len(episodes), removed)
+		proposed := Duration(hold)
+		rec.HoldDuration = &proposed
+		rec.Current = current.String()
+		rec.Proposed = hold.String()
+		rec.Summary = fmt.Sprintf("increase `for` to %s would remove %.0f%% of the alerts", hold, rec.ReductionPercent)
+		return rec, true
+	}
+	return TuningRecommendation{}, false
+}
+
+// recommendHysteresis returns a recovery target for the rule whose alerts
+// fire again soon after they resolve, the alerts firing again would have
+// kept firing instead
+func recommendHysteresis(id string, rule *PostableRule, episodes []alertEpisode) (TuningRecommendation, bool) {
+	cond := rule.RuleCondition
+	if rule.RuleType != RuleTypeThreshold || cond == nil || cond.Target == nil || *cond.Target == 0 ||
+		cond.RecoveryTarget != nil || len(cond.QueryThresholds) > 0 || cond.StreamResults {
+		return TuningRecommendation{}, false
+	}
+	if cond.CompareOp != ValueIsAbove && cond.CompareOp != ValueIsBelow {
+		return TuningRecommendation{}, false
+	}
+
+	lastEnd := map[uint64]int64{}
+	sorted := append([]alertEpisode(nil), episodes...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].start < sorted[j].start })
+	refires := 0
+	for _, e := range sorted {
+		if end, ok := lastEnd[e.fingerprint]; ok && e.start-end <= refireGap.Milliseconds() {
+			refires++
+		}
+		if e.resolved {
+			lastEnd[e.fingerprint] = e.end
+		} else {
+			delete(lastEnd, e.fingerprint)
+		}
+	}
+	if float64(refires) < minTuningReduction*float64(len(episodes)) {
+		return TuningRecommendation{}, false
+	}
+
+	margin := math.Abs(*cond.Target) * recoveryMargin
+	recovery := *cond.Target - margin
+	if cond.CompareOp == ValueIsBelow {
+		recovery = *cond.Target + margin
+	}
+	rec := newTuningRecommendation(rule, id, TuningHysteresis, len(episodes), refires)
+	rec.RecoveryTarget = &recovery
+	rec.Current = strconv.FormatFloat(*cond.Target, 'g', -1, 64)
+	rec.Proposed = strconv.FormatFloat(recovery, 'g', -1, 64)
+	rec.Summary = fmt.Sprintf("add hysteresis resolving at %s instead of %s would remove %.0f%% of the alerts, they fire again within %s of resolving", rec.Proposed, rec.Current, rec.ReductionPercent, refireGap)
+	return rec, true
+}
+
+// tuningQuery returns the name of the selected builder query of the rule
+// and the query, formulas are not tuned
+func tuningQuery(rule *PostableRule) (string, *v3.BuilderQuery) {
+	cond := rule.RuleCondition
+	if cond == nil || cond.QueryType() != v3.QueryTypeBuilder || len(cond.QueryThresholds) > 0 {
+		return "", nil
+	}
+	name := cond.SelectedQuery
+	if name == "" {
+		for n := range cond.CompositeQuery.BuilderQueries {
+			if n > name {
+				name = n
+			}
+		}
+	}
+	q, ok := cond.CompositeQuery.BuilderQueries[name]
+	if !ok || q.Expression != name {
+		return "", nil
+	}
+	return name, q
+}
+
+// recommendGroupBy returns the label of the group by whose removal merges
+// the most alerts, the alerts starting in the same minute with the same
+// other labels would have been one alert
+func recommendGroupBy(id string, rule *PostableRule, episodes []alertEpisode) (TuningRecommendation, bool) {
+	name, q := tuningQuery(rule)
+	if q == nil || len(q.GroupBy) < 2 {
+		return TuningRecommendation{}, false
+	}
+	episodeLabels := make([]map[string]string, len(episodes))
+	for i, e := range episodes {
+		if err := json.Unmarshal([]byte(e.labels), &episodeLabels[i]); err != nil {
+			return TuningRecommendation{}, false
+		}
+	}
+
+	var best string
+	bestRemoved := 0
+	for _, key := range q.GroupBy {
+		merged := map[string]struct{}{}
+		for i, e := range episodes {
+			parts := []string{strconv.FormatInt(e.start/time.Minute.Milliseconds(), 10)}
+			for _, other := range q.GroupBy {
+				if other.Key != key.Key {
+					parts = append(parts, other.Key+"="+episodeLabels[i][other.Key])
+				}
+			}
+			merged[strings.Join(parts, "\xff")] = struct{}{}
+		}
+		if removed := len(episodes) - len(merged); removed > bestRemoved {
+			best, bestRemoved = key.Key, removed
+		}
+	}
+	if float64(bestRemoved) < minTuningReduction*float64(len(episodes)) {
+		return TuningRecommendation{}, false
+	}
+
+	current := make([]string, 0, len(q.GroupBy))
+	proposed := make([]string, 0, len(q.GroupBy)-1)
+	for _, key := range q.GroupBy {
+		current = append(current, key.Key)
+		if key.Key != best {
+			proposed = append(proposed, key.Key)
+		}
+	}
+	rec := newTuningRecommendation(rule, id, TuningGroupBy, len(episodes), bestRemoved)
+	rec.QueryName = name
+	rec.DropLabel = best
+	rec.Current = strings.Join(current, ", ")
+	rec.Proposed = strings.Join(proposed, ", ")
+	rec.Summary = fmt.Sprintf("group by %s instead of %s would remove %.0f%% of the alerts, they fire together across the values of %s", rec.Proposed, rec.Current, rec.ReductionPercent, best)
+	return rec, true
+}
+
+// tuningPatch returns the patch applying the recommendation to the stored
+// definition of the rule
+func tuningPatch(rule *PostableRule, rec TuningRecommendation) (string, error) {
+	var patch interface{}
+	switch rec.Kind {
+	case TuningRaiseHold:
+		if rec.HoldDuration == nil {
+			return "", fmt.Errorf("the recommendation has no for duration")
+		}
+		patch = map[string]interface{}{"for": *rec.HoldDuration}
+	case TuningHysteresis:
+		if rec.RecoveryTarget == nil {
+			return "", fmt.Errorf("the recommendation has no recovery target")
+		}
+		patch = map[string]interface{}{"condition": map[string]interface{}{"recoveryTarget": *rec.RecoveryTarget}}
+	case TuningGroupBy:
+		name, q := tuningQuery(rule)
+		if q == nil || name != rec.QueryName {
+			return "", fmt.Errorf("the selected query of the rule changed since the recommendation")
+		}
+		// the queries are replaced as a whole by the patch
+		tuned := *q
+		tuned.GroupBy = make([]v3.AttributeKey, 0, len(q.GroupBy))
+		for _, key := range q.GroupBy {
+			if key.Key != rec.DropLabel {
+				tuned.GroupBy = append(tuned.GroupBy, key)
+			}
+		}
+		if len(tuned.GroupBy) == len(q.GroupBy) {
+			return "", fmt.Errorf("the query no longer groups by %s", rec.DropLabel)
+		}
+		patch = map[string]interface{}{"condition": map[string]interface{}{
+			"compositeQuery": map[string]interface{}{
+				"builderQueries": map[string]*v3.BuilderQuery{name: &tuned},
+			},
+		}}
+	default:
+		return "", fmt.Errorf("unknown recommendation kind %s", rec.Kind)
+	}
+	b, err := json.Marshal(patch)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// AnalyzeTuning computes the recommendations of the rules from the state
+// history of the lookback and keeps them for the api
+func (m *Manager) AnalyzeTuning(ctx context.Context) (*TuningReport, error) {
+	if m.reader == nil {
+		return nil, fmt.Errorf("the state history is not stored")
+	}
+	end := time.Now()
+	start := end.Add(-DefaultTuningLookback)
+	changes, err := m.reader.ReadRuleStateChanges(ctx, start.UnixMilli(), end.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	storedRules, err := m.ruleDB.GetStoredRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rules := make(map[string]*PostableRule, len(storedRules))
+	for _, stored := range storedRules {
+		rule, err := parseStoredRule(stored.Data)
+		if err != nil || rule.Disabled {
+			continue
+		}
+		rules[strconv.Itoa(stored.Id)] = rule
+	}
+
+	episodes, _ := alertEpisodes(changes, end.UnixMilli())
+	report := &TuningReport{
+		GeneratedAt:     end,
+		Start:           start.UnixMilli(),
+		End:             end.UnixMilli(),
+		Recommendations: recommendTunings(episodes, rules),
+	}
+	m.opts.tuning.set(report)
+	return report, nil
+}
+
+// TuningRecommendations returns the recommendations of the rules of the
+// org of the request, computed again when refresh is set or none were yet
+func (m *Manager) TuningRecommendations(ctx context.Context, refresh bool) (*TuningReport, *model.ApiError) {
+	report := m.opts.tuning.get()
+	if refresh || report == nil {
+		var err error
+		if report, err = m.AnalyzeTuning(ctx); err != nil {
+			return nil, newApiErrorInternal(err)
+		}
+	}
+	visible, err := m.tenantRules(ctx)
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	filtered := *report
+	filtered.Recommendations = []TuningRecommendation{}
+	for _, rec := range report.Recommendations {
+		if visible == nil || visible[rec.RuleID] {
+			filtered.Recommendations = append(filtered.Recommendations, rec)
+		}
+	}
+	return &filtered, nil
+}
+
+// ApplyTuning applies the recommendation to its rule and drops it from the
+// recommendations. The recommendations are computed again when this
+// replica does not know the recommendation, e.g. it was computed by the
+// replica owning the tuning or before a restart.
+func (m *Manager) ApplyTuning(ctx context.Context, id string) (*GettableRule, *model.ApiError) {
+	rec, ok := m.opts.tuning.find(id)
+	if !ok {
+		if _, err := m.AnalyzeTuning(ctx); err != nil {
+			return nil, newApiErrorInternal(err)
+		}
+		rec, ok = m.opts.tuning.find(id)
+	}
+	if !ok {
+		return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("recommendation %s not found", id)}
+	}
+	visible, err := m.tenantRules(ctx)
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	if visible != nil && !visible[rec.RuleID] {
+		return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("recommendation %s not found", id)}
+	}
+
+	stored, err := m.ruleDB.GetStoredRule(ctx, rec.RuleID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			m.opts.tuning.remove(id)
+			return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("rule %s not found", rec.RuleID)}
+		}
+		return nil, newApiErrorInternal(err)
+	}
+	rule, err := parseStoredRule(stored.Data)
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	patch, err := tuningPatch(rule, rec)
+	if err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorConflict, Err: err}
+	}
+	gettable, err := m.PatchRule(ctx, patch, rec.RuleID)
+	if err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorBadData, Err: err}
+	}
+	m.opts.tuning.remove(id)
+	zap.L().Info("applied the tuning recommendation", zap.String("ruleid", rec.RuleID), zap.String("kind", rec.Kind), zap.String("patch", patch))
+	return gettable, nil
+}
+
+// tuningAdvisor keeps the latest recommendations and computes them again
+// every few hours on the replica owning the tuning
+type tuningAdvisor struct {
+	analyze func(ctx context.Context) (*TuningReport, error)
+	sharder *Sharder
+
+	mtx    sync.RWMutex
+	report *TuningReport
+
+	done       chan struct{}
+	terminated chan struct{}
+}
+
+func newTuningAdvisor(sharder *Sharder) *tuningAdvisor {
+	return &tuningAdvisor{
+		sharder:    sharder,
+		done:       make(chan struct{}),
+		terminated: make(chan struct{}),
+	}
+}
+
+func (t *tuningAdvisor) set(report *TuningReport) {
+	t.mtx.Lock()
+	t.report = report
+	t.mtx.Unlock()
+}
+
+func (t *tuningAdvisor) get() *TuningReport {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	return t.report
+}
+
+func (t *tuningAdvisor) find(id string) (TuningRecommendation, bool) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	if t.report == nil {
+		return TuningRecommendation{}, false
+	}
+	for _, rec := range t.report.Recommendations {
+		if rec.ID == id {
+			return rec, true
+		}
+	}
+	return TuningRecommendation{}, false
+}
+
+// remove drops the recommendation, the report is copied as the readers
+// use it without the lock
+func (t *tuningAdvisor) remove(id string) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.report == nil {
+		return
+	}
+	report := *t.report
+	report.Recommendations = make([]TuningRecommendation, 0, len(t.report.Recommendations))
+	for _, rec := range t.report.Recommendations {
+		if rec.ID != id {
+			report.Recommendations = append(report.Recommendations, rec)
+		}
+	}
+	t.report = &report
+}
+
+// Run computes the recommendations at start and then every few hours
+func (t *tuningAdvisor) Run(ctx context.Context) {
+	defer close(t.terminated)
+
+	tick := time.NewTicker(tuningInterval)
+	defer tick.Stop()
+
+	for {
+		if t.sharder.Owns(tuningKey) {
+			cctx, cancel := context.WithTimeout(ctx, tuningTimeout)
+			if _, err := t.analyze(cctx); err != nil {
+				zap.L().Error("failed to compute the tuning recommendations", zap.Error(err))
+			}
+			cancel()
+		}
+		select {
+		case <-t.done:
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+func (t *tuningAdvisor) Stop() {
+	close(t.done)
+	<-t.terminated
+}
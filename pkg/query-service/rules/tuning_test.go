@@ -0,0 +1,210 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/featureManager"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+func tuningTestRule(target float64) *PostableRule {
+	return &PostableRule{
+		AlertName:    "HighLatency",
+		RuleType:     RuleTypeThreshold,
+		HoldDuration: Duration(time.Minute),
+		RuleCondition: &RuleCondition{
+			CompareOp: ValueIsAbove,
+			MatchType: AtleastOnce,
+			Target:    &target,
+			CompositeQuery: &v3.CompositeQuery{
+				QueryType: v3.QueryTypeBuilder,
+				BuilderQueries: map[string]*v3.BuilderQuery{
+					"A": {
+						QueryName:          "A",
+						StepInterval:       60,
+						AggregateAttribute: v3.AttributeKey{Key: "latency"},
+						AggregateOperator:  v3.AggregateOperatorAvg,
+						DataSource:         v3.DataSourceMetrics,
+						Expression:         "A",
+						GroupBy:            []v3.AttributeKey{{Key: "service"}, {Key: "pod"}},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestRecommendTunings(t *testing.T) {
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC).UnixMilli()
+	minute := time.Minute.Milliseconds()
+	episode := func(fingerprint uint64, pod string, start, minutes int64) alertEpisode {
+		return alertEpisode{
+			ruleID:      "1",
+			ruleName:    "HighLatency",
+			fingerprint: fingerprint,
+			labels:      v3.LabelsString(fmt.Sprintf(`{"service":"api","pod":"%s"}`, pod)),
+			start:       base + start*minute,
+			end:         base + (start+minutes)*minute,
+			resolved:    true,
+		}
+	}
+
+	t.Run("short lived alerts", func(t *testing.T) {
+		episodes := []alertEpisode{
+			episode(1, "a", 0, 1), episode(1, "a", 60, 2), episode(1, "a", 120, 3),
+			episode(1, "a", 180, 30), episode(1, "a", 240, 60),
+		}
+		recs := recommendTunings(episodes, map[string]*PostableRule{"1": tuningTestRule(100)})
+		require.Len(t, recs, 1)
+		rec := recs[0]
+		assert.Equal(t, "1-"+TuningRaiseHold, rec.ID)
+		// with a for of 1m, a 5m for removes the alerts firing less than 4m
+		assert.Equal(t, "5m0s", rec.Proposed)
+		assert.Equal(t, 3, rec.Removed)
+		assert.Equal(t, 60.0, rec.ReductionPercent)
+		assert.Equal(t, "increase `for` to 5m0s would remove 60% of the alerts", rec.Summary)
+	})
+
+	t.Run("flapping alerts", func(t *testing.T) {
+		episodes := []alertEpisode{
+			episode(1, "a", 0, 20), episode(1, "a", 25, 20), episode(1, "a", 50, 20),
+			episode(1, "a", 75, 20), episode(1, "a", 300, 20),
+		}
+		recs := recommendTunings(episodes, map[string]*PostableRule{"1": tuningTestRule(100)})
+		require.Len(t, recs, 1)
+		assert.Equal(t, TuningHysteresis, recs[0].Kind)
+		assert.Equal(t, 3, recs[0].Removed)
+		assert.Equal(t, 90.0, *recs[0].RecoveryTarget)
+	})
+
+	t.Run("alerts firing together", func(t *testing.T) {
+		episodes := []alertEpisode{
+			episode(1, "a", 0, 20), episode(2, "b", 0, 20), episode(3, "c", 0, 20),
+			episode(1, "a", 300, 20), episode(2, "b", 300, 20),
+		}
+		recs := recommendTunings(episodes, map[string]*PostableRule{"1": tuningTestRule(100)})
+		require.Len(t, recs, 1)
+		rec := recs[0]
+		assert.Equal(t, TuningGroupBy, rec.Kind)
+		assert.Equal(t, "pod", rec.DropLabel)
+		assert.Equal(t, "service", rec.Proposed)
+		assert.Equal(t, 3, rec.Removed)
+	})
+
+	t.Run("too few alerts", func(t *testing.T) {
+		episodes := []alertEpisode{episode(1, "a", 0, 1), episode(1, "a", 60, 1)}
+		assert.Empty(t, recommendTunings(episodes, map[string]*PostableRule{"1": tuningTestRule(100)}))
+	})
+}
+
+func TestTuningPatch(t *testing.T) {
+	rule := tuningTestRule(100)
+	stored, err := json.Marshal(rule)
+	require.NoError(t, err)
+
+	hold := Duration(5 * time.Minute)
+	recovery := 90.0
+	cases := []struct {
+		rec   TuningRecommendation
+		check func(t *testing.T, patched *PostableRule)
+	}{
+		{
+			rec: TuningRecommendation{Kind: TuningRaiseHold, HoldDuration: &hold},
+			check: func(t *testing.T, patched *PostableRule) {
+				assert.Equal(t, hold, patched.HoldDuration)
+			},
+		},
+		{
+			rec: TuningRecommendation{Kind: TuningHysteresis, RecoveryTarget: &recovery},
+			check: func(t *testing.T, patched *PostableRule) {
+				assert.Equal(t, recovery, *patched.RuleCondition.RecoveryTarget)
+				assert.Equal(t, 100.0, *patched.RuleCondition.Target)
+			},
+		},
+		{
+			rec: TuningRecommendation{Kind: TuningGroupBy, QueryName: "A", DropLabel: "pod"},
+			check: func(t *testing.T, patched *PostableRule) {
+				q := patched.RuleCondition.CompositeQuery.BuilderQueries["A"]
+				assert.Equal(t, []v3.AttributeKey{{Key: "service"}}, q.GroupBy)
+				assert.Equal(t, "latency", q.AggregateAttribute.Key)
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.rec.Kind, func(t *testing.T) {
+			var storedRule PostableRule
+			require.NoError(t, json.Unmarshal(stored, &storedRule))
+			patch, err := tuningPatch(&storedRule, c.rec)
+			require.NoError(t, err)
+			patched, err := parseIntoRule(storedRule, []byte(patch), "json")
+			require.NoError(t, err)
+			c.check(t, patched)
+		})
+	}
+
+	_, err = tuningPatch(rule, TuningRecommendation{Kind: TuningGroupBy, QueryName: "A", DropLabel: "host"})
+	assert.Error(t, err)
+}
+
+func TestRecoveryTarget(t *testing.T) {
+	rule := tuningTestRule(100)
+	recovery := 110.0
+	rule.RuleCondition.RecoveryTarget = &recovery
+	assert.Error(t, rule.RuleCondition.validateRecoveryTarget())
+	recovery = 90
+	require.NoError(t, rule.RuleCondition.validateRecoveryTarget())
+
+	// the streamed evaluation and the promql rules keep no breached series
+	rule.RuleCondition.StreamResults = true
+	assert.Error(t, rule.RuleCondition.validateRecoveryTarget())
+	rule.RuleCondition.StreamResults = false
+	rule.RuleCondition.CompositeQuery.QueryType = v3.QueryTypePromQL
+	assert.Error(t, rule.RuleCondition.validateRecoveryTarget())
+	rule.RuleCondition.CompositeQuery.QueryType = v3.QueryTypeBuilder
+
+	fm := featureManager.StartManager()
+	r, err := NewThresholdRule("1", rule, ThresholdRuleOpts{}, fm, nil)
+	require.NoError(t, err)
+	series := v3.Series{Labels: map[string]string{"service": "api"}, Points: []v3.Point{{Value: 95}}}
+
+	_, ok := r.shouldAlert(series)
+	assert.False(t, ok)
+	// the series did not breach at the last evaluation
+	_, ok = r.keepAlerting(series)
+	assert.False(t, ok)
+
+	r.alertingSeries = map[uint64]struct{}{labels.FromMap(series.Labels).Hash(): {}}
+	_, ok = r.keepAlerting(series)
+	assert.True(t, ok)
+	series.Points = []v3.Point{{Value: 80}}
+	_, ok = r.keepAlerting(series)
+	assert.False(t, ok)
+}
+
+func TestTuningAdvisorAnalyzesAtStart(t *testing.T) {
+	advisor := newTuningAdvisor(nil)
+	analyzed := make(chan struct{}, 1)
+	advisor.analyze = func(ctx context.Context) (*TuningReport, error) {
+		report := &TuningReport{Recommendations: []TuningRecommendation{{ID: "1-hold"}}}
+		advisor.set(report)
+		analyzed <- struct{}{}
+		return report, nil
+	}
+	go advisor.Run(context.Background())
+	defer advisor.Stop()
+
+	select {
+	case <-analyzed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the recommendations were not computed at start")
+	}
+	_, ok := advisor.find("1-hold")
+	assert.True(t, ok)
+}
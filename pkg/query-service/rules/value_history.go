@@ -0,0 +1,133 @@
+package rules
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	am "go.signoz.io/signoz/pkg/query-service/integrations/alertManager"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+const (
+	// ValueHistoryAnnotation holds the recent values of an alert sent in
+	// the notifications, oldest first and comma separated
+	ValueHistoryAnnotation = am.ValueHistoryAnnotation
+
+	// valueHistorySize is the number of evaluated values kept per alert
+	valueHistorySize = 30
+)
+
+// ValuePoint is the value of an alert at an evaluation
+type ValuePoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+}
+
+// recordValue adds the value of the evaluation at ts to the recent values
+// of the alert, only the latest values are kept
+func (a *Alert) recordValue(ts time.Time, v float64) {
+	if a.Missing {
+		return
+	}
+	history := a.ValueHistory
+	if len(history) >= valueHistorySize {
+		// copy rather than shift, the copies of the alert share the array
+		history = append([]ValuePoint(nil), history[len(history)-valueHistorySize+1:]...)
+	}
+	a.ValueHistory = append(history, ValuePoint{Timestamp: ts, Value: v})
+}
+
+// valueHistoryAnnotations returns the annotations of the alert with the
+// recent values of the alert
+func valueHistoryAnnotations(a *Alert) labels.BaseLabels {
+	if len(a.ValueHistory) == 0 {
+		return a.Annotations
+	}
+	values := make([]string, 0, len(a.ValueHistory))
+	for _, p := range a.ValueHistory {
+		values = append(values, strconv.FormatFloat(p.Value, 'g', 6, 64))
+	}
+	annotations := labels.Labels{}
+	if a.Annotations != nil {
+		annotations = labels.FromMap(a.Annotations.Map())
+	}
+	return labels.NewBuilder(annotations).Set(ValueHistoryAnnotation, strings.Join(values, ",")).Labels()
+}
+
+// ActiveAlert is a pending or firing alert of a rule with its recent
+// values, to draw how the value approached the threshold. The pending
+// alerts tell how long they are pending and how much of the hold duration
+// of the rule remains before they fire.
+type ActiveAlert struct {
+	Fingerprint     uint64            `json:"fingerprint"`
+	Labels          map[string]string `json:"labels"`
+	State           string            `json:"state"`
+	Value           float64           `json:"value"`
+	ActiveAt        time.Time         `json:"activeAt"`
+	FiredAt         time.Time         `json:"firedAt,omitempty"`
+	Values          []ValuePoint      `json:"values"`
+	Breaches        int               `json:"breaches"`
+	PendingForMs    int64             `json:"pendingForMs,omitempty"`
+	HoldRemainingMs int64             `json:"holdRemainingMs,omitempty"`
+}
+
+// holdBurnDown returns how long the pending alert is pending at now and
+// how much of the hold duration remains
+func holdBurnDown(a *Alert, hold time.Duration, now time.Time) (time.Duration, time.Duration) {
+	if a.State != StatePending {
+		return 0, 0
+	}
+	pending := now.Sub(a.ActiveAt)
+	if pending < 0 {
+		pending = 0
+	}
+	remaining := hold - pending
+	if remaining < 0 {
+		remaining = 0
+	}
+	return pending, remaining
+}
+
+// RuleAlerts returns the pending and firing alerts of the rule with their
+// recent values
+func (m *Manager) RuleAlerts(ruleID string) ([]ActiveAlert, *model.ApiError) {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	rule, ok := m.rules[ruleID]
+	if !ok {
+		return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("rule %s is not active", ruleID)}
+	}
+	var hold time.Duration
+	if r, ok := rule.(interface{ HoldDuration() time.Duration }); ok {
+		hold = r.HoldDuration()
+	}
+	now := time.Now()
+	alerts := []ActiveAlert{}
+	for _, a := range rule.ActiveAlerts() {
+		if a.State == StateInactive {
+			continue
+		}
+		pending, remaining := holdBurnDown(a, hold, now)
+		alerts = append(alerts, ActiveAlert{
+			Fingerprint:     a.Labels.Hash(),
+			Labels:          a.Labels.Map(),
+			State:           a.State.String(),
+			Value:           a.Value,
+			ActiveAt:        a.ActiveAt,
+			FiredAt:         a.FiredAt,
+			Values:          append([]ValuePoint{}, a.ValueHistory...),
+			Breaches:        a.Breaches,
+			PendingForMs:    pending.Milliseconds(),
+			HoldRemainingMs: remaining.Milliseconds(),
+		})
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].ActiveAt.Before(alerts[j].ActiveAt)
+	})
+	return alerts, nil
+}
@@ -0,0 +1,55 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+func TestAlertValueHistory(t *testing.T) {
+	a := &Alert{Annotations: labels.FromStrings("summary", "high")}
+	start := time.Unix(1717205940, 0)
+	for i := 0; i < valueHistorySize+5; i++ {
+		a.recordValue(start.Add(time.Duration(i)*time.Minute), float64(i))
+	}
+	assert.Len(t, a.ValueHistory, valueHistorySize)
+	assert.Equal(t, 5.0, a.ValueHistory[0].Value)
+	assert.Equal(t, float64(valueHistorySize+4), a.ValueHistory[valueHistorySize-1].Value)
+
+	// the copies keep their values
+	copied := *a
+	a.recordValue(start.Add(time.Hour), 100)
+	assert.Equal(t, 5.0, copied.ValueHistory[0].Value)
+	assert.Equal(t, 100.0, a.ValueHistory[valueHistorySize-1].Value)
+
+	a = &Alert{Annotations: labels.FromStrings("summary", "high")}
+	a.recordValue(start, 1.5)
+	a.recordValue(start.Add(time.Minute), 2)
+	annotations := valueHistoryAnnotations(a)
+	assert.Equal(t, "1.5,2", annotations.Map()[ValueHistoryAnnotation])
+	assert.Equal(t, "high", annotations.Map()["summary"])
+
+	missing := &Alert{Missing: true}
+	missing.recordValue(start, 0)
+	assert.Empty(t, missing.ValueHistory)
+}
+
+func TestHoldBurnDown(t *testing.T) {
+	now := time.Unix(1717205940, 0)
+	a := &Alert{State: StatePending, ActiveAt: now.Add(-3 * time.Minute)}
+
+	pending, remaining := holdBurnDown(a, 5*time.Minute, now)
+	assert.Equal(t, 3*time.Minute, pending)
+	assert.Equal(t, 2*time.Minute, remaining)
+
+	pending, remaining = holdBurnDown(a, time.Minute, now)
+	assert.Equal(t, 3*time.Minute, pending)
+	assert.Equal(t, time.Duration(0), remaining)
+
+	a.State = StateFiring
+	pending, remaining = holdBurnDown(a, 5*time.Minute, now)
+	assert.Zero(t, pending)
+	assert.Zero(t, remaining)
+}
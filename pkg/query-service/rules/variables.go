@@ -0,0 +1,165 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+// ruleVariableRe matches the references to the variables of a rule, like
+// {{.env}}
+var ruleVariableRe = regexp.MustCompile(`\{\{\s*\.([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+var ruleVariableNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// reservedVariableNames are the fields of the alert templates and the
+// variables of the clickhouse queries, the rule variables can not shadow
+// them
+var reservedVariableNames = map[string]bool{
+	"Labels": true, "Value": true, "Threshold": true, "Values": true,
+	"start_timestamp": true, "end_timestamp": true,
+	"start_timestamp_ms": true, "end_timestamp_ms": true,
+	"start_timestamp_nano": true, "end_timestamp_nano": true,
+	"start_datetime": true, "end_datetime": true,
+	"SIGNOZ_START_TIME": true, "SIGNOZ_END_TIME": true,
+}
+
+func validateVariables(variables map[string]string) error {
+	for name := range variables {
+		if !ruleVariableNameRe.MatchString(name) {
+			return fmt.Errorf("invalid variable name %q", name)
+		}
+		if reservedVariableNames[name] {
+			return fmt.Errorf("variable name %s is reserved", name)
+		}
+	}
+	return nil
+}
+
+// substituteVariables replaces the references to the variables in the
+// text, the references to other names are left for the templates
+func substituteVariables(text string, variables map[string]string) string {
+	if len(variables) == 0 {
+		return text
+	}
+	return ruleVariableRe.ReplaceAllStringFunc(text, func(ref string) string {
+		if v, ok := variables[ruleVariableRe.FindStringSubmatch(ref)[1]]; ok {
+			return v
+		}
+		return ref
+	})
+}
+
+// substituteVariablesMap returns the labels or annotations with the
+// variables substituted in their values
+func substituteVariablesMap(m map[string]string, variables map[string]string) map[string]string {
+	if len(variables) == 0 {
+		return m
+	}
+	result := make(map[string]string, len(m))
+	for k, v := range m {
+		result[k] = substituteVariables(v, variables)
+	}
+	return result
+}
+
+// withBuilderVariables returns a copy of the builder queries with the
+// variables substituted in the values of their filters. The rule keeps
+// the references so that the values are read on every evaluation.
+func withBuilderVariables(queries map[string]*v3.BuilderQuery, variables map[string]string) map[string]*v3.BuilderQuery {
+	if len(variables) == 0 {
+		return queries
+	}
+	result := make(map[string]*v3.BuilderQuery, len(queries))
+	for name, q := range queries {
+		c := *q
+		if q.Filters != nil {
+			filters := *q.Filters
+			filters.Items = make([]v3.FilterItem, len(q.Filters.Items))
+			for i, item := range q.Filters.Items {
+				switch x := item.Value.(type) {
+				case string:
+					item.Value = substituteVariables(x, variables)
+				case []interface{}:
+					values := make([]interface{}, len(x))
+					for j, v := range x {
+						if s, ok := v.(string); ok {
+							v = substituteVariables(s, variables)
+						}
+						values[j] = v
+					}
+					item.Value = values
+				}
+				filters.Items[i] = item
+			}
+			c.Filters = &filters
+		}
+		result[name] = &c
+	}
+	return result
+}
+
+// RuleInstance is a copy of a rule with other variable values
+type RuleInstance struct {
+	// AlertName is the name of the copy, the name of the rule when not set
+	AlertName string            `json:"alert,omitempty"`
+	Variables map[string]string `json:"variables"`
+}
+
+// RuleCloneResult is the rule created for an instance, or the error
+// creating it
+type RuleCloneResult struct {
+	AlertName string            `json:"alert"`
+	Variables map[string]string `json:"variables"`
+	RuleID    string            `json:"ruleId,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// CloneRule creates a copy of the rule for every instance, with the
+// variables of the instance over the variables of the rule, e.g. one rule
+// per environment from a rule using {{.env}}
+func (m *Manager) CloneRule(ctx context.Context, id string, instances []RuleInstance) ([]RuleCloneResult, *model.ApiError) {
+	if len(instances) == 0 {
+		return nil, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("at least one instance is required")}
+	}
+	stored, err := m.ruleDB.GetStoredRule(ctx, id)
+	if err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: err}
+	}
+
+	results := make([]RuleCloneResult, 0, len(instances))
+	for _, instance := range instances {
+		// every copy starts from the stored rule
+		rule, err := ParsePostableRule([]byte(stored.Data))
+		if err != nil {
+			return nil, newApiErrorInternal(err)
+		}
+		variables := make(map[string]string, len(rule.Variables)+len(instance.Variables))
+		for k, v := range rule.Variables {
+			variables[k] = v
+		}
+		for k, v := range instance.Variables {
+			variables[k] = v
+		}
+		rule.Variables = variables
+		if instance.AlertName != "" {
+			rule.AlertName = instance.AlertName
+		}
+
+		result := RuleCloneResult{AlertName: rule.AlertName, Variables: variables}
+		data, err := json.Marshal(rule)
+		if err != nil {
+			result.Error = err.Error()
+		} else if created, err := m.CreateRule(ctx, string(data)); err != nil {
+			result.Error = err.Error()
+		} else {
+			result.RuleID = created.Id
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,81 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.signoz.io/signoz/pkg/query-service/featureManager"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func TestSubstituteVariables(t *testing.T) {
+	vars := map[string]string{"env": "prod", "region": "eu"}
+	assert.Equal(t, "prod in eu", substituteVariables("{{.env}} in {{ .region }}", vars))
+	// the references to other names are left for the alert templates
+	assert.Equal(t, "prod {{$value}} {{.Labels.host}}", substituteVariables("{{.env}} {{$value}} {{.Labels.host}}", vars))
+	assert.Equal(t, "{{.cluster}}", substituteVariables("{{.cluster}}", vars))
+
+	assert.NoError(t, validateVariables(vars))
+	assert.Error(t, validateVariables(map[string]string{"Value": "1"}))
+	assert.Error(t, validateVariables(map[string]string{"start_timestamp": "1"}))
+	assert.Error(t, validateVariables(map[string]string{"my-env": "1"}))
+}
+
+func TestThresholdRuleVariables(t *testing.T) {
+	postableRule := PostableRule{
+		AlertName:  "High error rate",
+		AlertType:  "METRIC_BASED_ALERT",
+		RuleType:   RuleTypeThreshold,
+		EvalWindow: Duration(5 * time.Minute),
+		Frequency:  Duration(1 * time.Minute),
+		Labels:     map[string]string{"env": "{{.env}}", "severity": "critical"},
+		Variables:  map[string]string{"env": "prod"},
+		RuleCondition: &RuleCondition{
+			CompositeQuery: &v3.CompositeQuery{
+				QueryType: v3.QueryTypeBuilder,
+				BuilderQueries: map[string]*v3.BuilderQuery{
+					"A": {
+						QueryName:          "A",
+						StepInterval:       60,
+						DataSource:         v3.DataSourceMetrics,
+						AggregateOperator:  v3.AggregateOperatorSumRate,
+						AggregateAttribute: v3.AttributeKey{Key: "signoz_calls_total"},
+						Expression:         "A",
+						Filters: &v3.FilterSet{Operator: "AND", Items: []v3.FilterItem{
+							{Key: v3.AttributeKey{Key: "deployment.environment"}, Value: "{{.env}}", Operator: v3.FilterOperatorEqual},
+							{Key: v3.AttributeKey{Key: "cluster"}, Value: []interface{}{"{{.env}}-1", "{{.env}}-2"}, Operator: v3.FilterOperatorIn},
+						}},
+					},
+				},
+			},
+			Target:    &[]float64{1.0}[0],
+			CompareOp: ValueIsAbove,
+			MatchType: AtleastOnce,
+		},
+	}
+
+	fm := featureManager.StartManager()
+	rule, err := NewThresholdRule("69", &postableRule, ThresholdRuleOpts{}, fm, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "prod", rule.Labels().Map()["env"])
+
+	params := rule.prepareQueryRange(time.Unix(1717205987, 0))
+	items := params.CompositeQuery.BuilderQueries["A"].Filters.Items
+	assert.Equal(t, "prod", items[0].Value)
+	assert.Equal(t, []interface{}{"prod-1", "prod-2"}, items[1].Value)
+	// the rule keeps the references for the next evaluations
+	assert.Equal(t, "{{.env}}", postableRule.RuleCondition.CompositeQuery.BuilderQueries["A"].Filters.Items[0].Value)
+
+	postableRule.RuleCondition.CompositeQuery = &v3.CompositeQuery{
+		QueryType: v3.QueryTypeClickHouseSQL,
+		ClickHouseQueries: map[string]*v3.ClickHouseQuery{
+			"A": {Query: "SELECT count() FROM t WHERE env = {{.env}} AND ts >= {{.start_timestamp}}"},
+		},
+	}
+	rule, err = NewThresholdRule("69", &postableRule, ThresholdRuleOpts{}, fm, nil)
+	require.NoError(t, err)
+	params = rule.prepareQueryRange(time.Unix(1717205987, 0))
+	assert.Equal(t, "SELECT count() FROM t WHERE env = 'prod' AND ts >= 1717205640", params.CompositeQuery.ClickHouseQueries["A"].Query)
+}
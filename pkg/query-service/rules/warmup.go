@@ -0,0 +1,157 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// WarmUpMode tells what happens to the alerts found during the warm up
+type WarmUpMode string
+
+const (
+	// WarmUpSuppress holds the notifications until the warm up ends, the
+	// alerts still firing are sent then
+	WarmUpSuppress WarmUpMode = "suppress"
+	// WarmUpRebuild only rebuilds the state of the alerts, the alerts
+	// firing during the warm up are considered already notified and are
+	// sent again after the resend delay or when they resolve
+	WarmUpRebuild WarmUpMode = "rebuild"
+)
+
+// ParseWarmUpMode returns the warm up mode, WarmUpSuppress when not set
+func ParseWarmUpMode(s string) (WarmUpMode, error) {
+	switch WarmUpMode(s) {
+	case "", WarmUpSuppress:
+		return WarmUpSuppress, nil
+	case WarmUpRebuild:
+		return WarmUpRebuild, nil
+	}
+	return "", fmt.Errorf("unknown warm up mode %q", s)
+}
+
+// WarmUpStatus is the state of the warm up of the manager
+type WarmUpStatus struct {
+	Active bool       `json:"active"`
+	Mode   WarmUpMode `json:"mode"`
+	Until  time.Time  `json:"until"`
+	// Suppressed is the number of alert notifications held back during
+	// the warm up
+	Suppressed int64 `json:"suppressed"`
+}
+
+// warmUp suppresses the notifications for a period after the manager
+// starts, so that a restart does not page for the conditions that were
+// already known
+type warmUp struct {
+	mtx        sync.Mutex
+	duration   time.Duration
+	mode       WarmUpMode
+	until      time.Time
+	suppressed int64
+}
+
+func newWarmUp(duration time.Duration, mode WarmUpMode) *warmUp {
+	if mode == "" {
+		mode = WarmUpSuppress
+	}
+	return &warmUp{duration: duration, mode: mode}
+}
+
+// start begins the warm up at now
+func (w *warmUp) start(now time.Time) {
+	if w == nil || w.duration <= 0 {
+		return
+	}
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	w.until = now.Add(w.duration)
+	zap.L().Info("rules are warming up, notifications are suppressed", zap.Time("until", w.until), zap.String("mode", string(w.mode)))
+}
+
+// end stops the warm up before its time
+func (w *warmUp) end() {
+	if w == nil {
+		return
+	}
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	w.until = time.Time{}
+}
+
+func (w *warmUp) active(now time.Time) bool {
+	if w == nil {
+		return false
+	}
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return now.Before(w.until)
+}
+
+func (w *warmUp) status(now time.Time) WarmUpStatus {
+	if w == nil {
+		return WarmUpStatus{Mode: WarmUpSuppress}
+	}
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return WarmUpStatus{
+		Active:     now.Before(w.until),
+		Mode:       w.mode,
+		Until:      w.until,
+		Suppressed: w.suppressed,
+	}
+}
+
+// rebuildNotifyFunc counts the alerts instead of sending them
+func (w *warmUp) rebuildNotifyFunc() NotifyFunc {
+	return func(ctx context.Context, expr string, alerts ...*Alert) {
+		w.suppress(len(alerts))
+	}
+}
+
+func (w *warmUp) suppress(n int) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	w.suppressed += int64(n)
+}
+
+// sendAlerts sends the alerts of the rule, unless the manager is warming
+// up
+func sendAlerts(ctx context.Context, opts *ManagerOptions, rule Rule, ts time.Time, frequency time.Duration, notify NotifyFunc) {
+	notify = deployNotifyFunc(opts, rule, notify)
+	w := opts.warmUp
+	if !w.active(time.Now()) {
+		rule.SendAlerts(ctx, ts, opts.ResendDelay, frequency, notify)
+		return
+	}
+	if w.mode == WarmUpRebuild {
+		// the alerts are marked as sent without notifying
+		rule.SendAlerts(ctx, ts, opts.ResendDelay, frequency, w.rebuildNotifyFunc())
+		return
+	}
+
+	held := 0
+	for _, a := range rule.ActiveAlerts() {
+		if a.needsSending(ts, opts.ResendDelay) {
+			held++
+		}
+	}
+	w.suppress(held)
+	zap.L().Debug("notifications are suppressed during the warm up", zap.String("ruleid", rule.ID()), zap.Int("alerts", held))
+}
+
+// WarmUpStatus returns the state of the warm up of the manager
+func (m *Manager) WarmUpStatus() WarmUpStatus {
+	return m.opts.warmUp.status(time.Now())
+}
+
+// EndWarmUp ends the warm up, the alerts are sent from the next
+// evaluation of the rules
+func (m *Manager) EndWarmUp() WarmUpStatus {
+	m.opts.warmUp.end()
+	zap.L().Info("the warm up of the rules was ended")
+	return m.WarmUpStatus()
+}
@@ -0,0 +1,54 @@
+package rules
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func TestWarmUpSuppressesNotifications(t *testing.T) {
+	ts := time.Now()
+	for _, c := range []struct {
+		mode            WarmUpMode
+		sentAfterWarmUp int
+	}{
+		// the alert still firing is sent when the warm up ends
+		{mode: WarmUpSuppress, sentAfterWarmUp: 1},
+		// the alert is considered notified until the resend delay
+		{mode: WarmUpRebuild, sentAfterWarmUp: 0},
+	} {
+		rule, err := NewThresholdRule("1", reloadTestRule("warm", v3.QueryTypeClickHouseSQL), ThresholdRuleOpts{}, nil, nil)
+		require.NoError(t, err)
+		rule.active.Set(1, &Alert{State: StateFiring, ActiveAt: ts, FiredAt: ts})
+
+		opts := &ManagerOptions{ResendDelay: time.Hour, warmUp: newWarmUp(time.Minute, c.mode)}
+		opts.warmUp.start(time.Now())
+
+		sent := 0
+		notify := func(ctx context.Context, expr string, alerts ...*Alert) {
+			sent += len(alerts)
+		}
+
+		sendAlerts(context.Background(), opts, rule, ts, time.Minute, notify)
+		assert.Equal(t, 0, sent, c.mode)
+		status := opts.warmUp.status(time.Now())
+		assert.True(t, status.Active)
+		assert.Equal(t, int64(1), status.Suppressed)
+
+		opts.warmUp.end()
+		sendAlerts(context.Background(), opts, rule, ts.Add(time.Minute), time.Minute, notify)
+		assert.Equal(t, c.sentAfterWarmUp, sent, c.mode)
+	}
+}
+
+func TestParseWarmUpMode(t *testing.T) {
+	mode, err := ParseWarmUpMode("")
+	require.NoError(t, err)
+	assert.Equal(t, WarmUpSuppress, mode)
+	_, err = ParseWarmUpMode("mute")
+	assert.Error(t, err)
+}
@@ -0,0 +1,186 @@
+// Package chart renders small line charts of query results. It only
+// depends on the standard library so that query-service can embed
+// images in notifications without a headless browser.
+package chart
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+const (
+	DefaultWidth  = 600
+	DefaultHeight = 200
+
+	padding = 10
+)
+
+var (
+	backgroundColor = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	gridColor       = color.RGBA{R: 230, G: 230, B: 235, A: 255}
+	seriesColor     = color.RGBA{R: 78, G: 116, B: 248, A: 255}
+	thresholdColor  = color.RGBA{R: 242, G: 71, B: 71, A: 255}
+	markerColor     = color.RGBA{R: 255, G: 163, B: 0, A: 255}
+)
+
+// Options controls the size and the overlays of the rendered chart
+type Options struct {
+	Width  int
+	Height int
+
+	// Threshold is drawn as a dashed horizontal line when set
+	Threshold *float64
+
+	// Marker is a unix milli timestamp drawn as a vertical line,
+	// usually the time the alert started firing
+	Marker int64
+}
+
+// RenderPNG draws the points as a line chart and returns the PNG bytes
+func RenderPNG(points []v3.Point, opts Options) ([]byte, error) {
+	if len(points) == 0 {
+		return nil, fmt.Errorf("no points to render")
+	}
+	if opts.Width <= 2*padding {
+		opts.Width = DefaultWidth
+	}
+	if opts.Height <= 2*padding {
+		opts.Height = DefaultHeight
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, opts.Width, opts.Height))
+	fill(img, backgroundColor)
+
+	minT, maxT := points[0].Timestamp, points[0].Timestamp
+	minV, maxV := math.Inf(1), math.Inf(-1)
+	for _, p := range points {
+		minT = min(minT, p.Timestamp)
+		maxT = max(maxT, p.Timestamp)
+		if math.IsNaN(p.Value) || math.IsInf(p.Value, 0) {
+			continue
+		}
+		minV = math.Min(minV, p.Value)
+		maxV = math.Max(maxV, p.Value)
+	}
+	if math.IsInf(minV, 1) {
+		return nil, fmt.Errorf("no finite values to render")
+	}
+	if opts.Threshold != nil {
+		minV = math.Min(minV, *opts.Threshold)
+		maxV = math.Max(maxV, *opts.Threshold)
+	}
+	if opts.Marker != 0 {
+		minT = min(minT, opts.Marker)
+		maxT = max(maxT, opts.Marker)
+	}
+	// leave some head room so the line does not touch the edges
+	spread := maxV - minV
+	if spread == 0 {
+		spread = math.Max(math.Abs(maxV), 1)
+	}
+	minV -= spread * 0.1
+	maxV += spread * 0.1
+	if maxT == minT {
+		maxT = minT + 1
+	}
+
+	plotW := float64(opts.Width - 2*padding)
+	plotH := float64(opts.Height - 2*padding)
+	x := func(ts int64) int {
+		return padding + int(math.Round(float64(ts-minT)/float64(maxT-minT)*plotW))
+	}
+	y := func(v float64) int {
+		return padding + int(math.Round((maxV-v)/(maxV-minV)*plotH))
+	}
+
+	for i := 0; i <= 4; i++ {
+		gy := padding + int(plotH*float64(i)/4)
+		line(img, padding, gy, opts.Width-padding, gy, gridColor, 1, 0)
+	}
+
+	if opts.Threshold != nil {
+		ty := y(*opts.Threshold)
+		line(img, padding, ty, opts.Width-padding, ty, thresholdColor, 1, 6)
+	}
+	if opts.Marker != 0 {
+		mx := x(opts.Marker)
+		line(img, mx, padding, mx, opts.Height-padding, markerColor, 1, 4)
+	}
+
+	prevX, prevY, havePrev := 0, 0, false
+	for _, p := range points {
+		if math.IsNaN(p.Value) || math.IsInf(p.Value, 0) {
+			havePrev = false
+			continue
+		}
+		px, py := x(p.Timestamp), y(p.Value)
+		if havePrev {
+			line(img, prevX, prevY, px, py, seriesColor, 2, 0)
+		} else {
+			img.Set(px, py, seriesColor)
+		}
+		prevX, prevY, havePrev = px, py, true
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func fill(img *image.RGBA, c color.RGBA) {
+	b := img.Bounds()
+	for py := b.Min.Y; py < b.Max.Y; py++ {
+		for px := b.Min.X; px < b.Max.X; px++ {
+			img.SetRGBA(px, py, c)
+		}
+	}
+}
+
+// line draws a straight line using Bresenham's algorithm, dash is
+// the length of the dashes in pixels, zero draws a solid line
+func line(img *image.RGBA, x0, y0, x1, y1 int, c color.RGBA, width, dash int) {
+	dx := abs(x1 - x0)
+	dy := -abs(y1 - y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	e := dx + dy
+	for step := 0; ; step++ {
+		if dash == 0 || (step/dash)%2 == 0 {
+			for w := 0; w < width; w++ {
+				img.SetRGBA(x0, y0+w, c)
+			}
+		}
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
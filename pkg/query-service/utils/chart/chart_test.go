@@ -0,0 +1,68 @@
+package chart
+
+import (
+	"bytes"
+	"image/png"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func TestRenderPNG(t *testing.T) {
+	threshold := 5.0
+	cases := []struct {
+		name    string
+		points  []v3.Point
+		opts    Options
+		wantErr bool
+	}{
+		{
+			name:    "no points",
+			points:  nil,
+			wantErr: true,
+		},
+		{
+			name:    "only NaN",
+			points:  []v3.Point{{Timestamp: 1, Value: math.NaN()}},
+			wantErr: true,
+		},
+		{
+			name:   "single point",
+			points: []v3.Point{{Timestamp: 1000, Value: 3}},
+		},
+		{
+			name: "with threshold and marker",
+			points: []v3.Point{
+				{Timestamp: 1000, Value: 1},
+				{Timestamp: 2000, Value: 7},
+				{Timestamp: 3000, Value: math.NaN()},
+				{Timestamp: 4000, Value: 2},
+			},
+			opts: Options{Width: 300, Height: 100, Threshold: &threshold, Marker: 2000},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			data, err := RenderPNG(c.points, c.opts)
+			if c.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			img, err := png.Decode(bytes.NewReader(data))
+			require.NoError(t, err)
+
+			wantW, wantH := c.opts.Width, c.opts.Height
+			if wantW == 0 {
+				wantW, wantH = DefaultWidth, DefaultHeight
+			}
+			assert.Equal(t, wantW, img.Bounds().Dx())
+			assert.Equal(t, wantH, img.Bounds().Dy())
+		})
+	}
+}